@@ -51,6 +51,7 @@
           headerFunc: (dataTable, hasKeyServerStats) => {
             dataTable.addColumn('date', 'Date');
             dataTable.addColumn('number', 'Codes Issued');
+            dataTable.addColumn({ type: 'string', role: 'annotation' });
             dataTable.addColumn('number', 'Codes Claimed');
             dataTable.addColumn('number', 'Invalid Codes');
             dataTable.addColumn('number', 'Tokens Claimed');
@@ -63,6 +64,7 @@
               dataTable.addRow([
                 utcDate(row.date),
                 row.data.codes_issued,
+                (row.annotations || []).join('; ') || null,
                 row.data.codes_claimed,
                 row.data.codes_invalid,
                 row.data.tokens_claimed,
@@ -72,6 +74,7 @@
               dataTable.addRow([
                 utcDate(row.date),
                 row.data.codes_issued,
+                (row.annotations || []).join('; ') || null,
                 row.data.codes_claimed,
                 row.data.codes_invalid,
                 row.data.tokens_claimed,
@@ -28,12 +28,13 @@ import (
 	"github.com/google/exposure-notifications-verification-server/internal/routes"
 	"github.com/google/exposure-notifications-verification-server/pkg/cache"
 	"github.com/google/exposure-notifications-verification-server/pkg/config"
+	"github.com/google/exposure-notifications-verification-server/pkg/controller/grpcapi"
+	"github.com/google/exposure-notifications-verification-server/pkg/observability"
 	"github.com/google/exposure-notifications-verification-server/pkg/ratelimit"
+	"github.com/google/exposure-notifications-verification-server/pkg/workloadidentity"
 	"github.com/gorilla/handlers"
 
-	"github.com/google/exposure-notifications-server/pkg/keys"
 	"github.com/google/exposure-notifications-server/pkg/logging"
-	"github.com/google/exposure-notifications-server/pkg/observability"
 	"github.com/google/exposure-notifications-server/pkg/server"
 )
 
@@ -69,18 +70,22 @@ func realMain(ctx context.Context) error {
 		return fmt.Errorf("failed to process config: %w", err)
 	}
 
-	// Setup monitoring
-	logger.Info("configuring observability exporter")
-	oeConfig := cfg.ObservabilityExporterConfig()
-	oe, err := observability.NewFromEnv(ctx, oeConfig)
+	// Verify data residency
+	residencyChecker, err := cfg.DataResidencyChecker()
 	if err != nil {
-		return fmt.Errorf("unable to create ObservabilityExporter provider: %w", err)
+		return fmt.Errorf("failed to create data residency checker: %w", err)
+	}
+	if err := residencyChecker.Check(ctx); err != nil {
+		return fmt.Errorf("failed data residency check: %w", err)
 	}
-	if err := oe.StartExporter(); err != nil {
-		return fmt.Errorf("error initializing observability exporter: %w", err)
+	go residencyChecker.Run(ctx)
+
+	// Setup monitoring
+	stopObservability, err := observability.Setup(ctx, cfg.ObservabilityExporterConfig(), cfg.Prometheus.Port)
+	if err != nil {
+		return fmt.Errorf("failed to setup observability: %w", err)
 	}
-	defer oe.Close()
-	logger.Infow("observability exporter", "config", oeConfig)
+	defer stopObservability()
 
 	// Setup cacher
 	cacher, err := cache.CacherFor(ctx, &cfg.Cache, cache.HMACKeyFunc(sha1.New, cfg.Cache.HMACKey))
@@ -99,6 +104,11 @@ func realMain(ctx context.Context) error {
 	}
 	defer db.Close()
 
+	// Record the build, so deploys appear on the system event timeline.
+	if err := db.RecordBuildSeen("apiserver", buildinfo.BuildID, buildinfo.BuildTag); err != nil {
+		logger.Errorw("failed to record build", "error", err)
+	}
+
 	// Setup rate limiter
 	limiterStore, err := ratelimit.RateLimiterFor(ctx, &cfg.RateLimit)
 	if err != nil {
@@ -107,15 +117,19 @@ func realMain(ctx context.Context) error {
 	defer limiterStore.Close(ctx)
 
 	// Setup signers
-	tokenSigner, err := keys.KeyManagerFor(ctx, &cfg.TokenSigning.Keys)
+	tokenSigner, err := workloadidentity.KeyManagerFor(ctx, &cfg.WorkloadIdentity, &cfg.TokenSigning.Keys)
 	if err != nil {
 		return fmt.Errorf("failed to create token key manager: %w", err)
 	}
-	certificateSigner, err := keys.KeyManagerFor(ctx, &cfg.CertificateSigning.Keys)
+	certificateSigner, err := workloadidentity.KeyManagerFor(ctx, &cfg.WorkloadIdentity, &cfg.CertificateSigning.Keys)
 	if err != nil {
 		return fmt.Errorf("failed to create certificate key manager: %w", err)
 	}
 
+	if cfg.Shadow.DryRun {
+		logger.Warnw("running in shadow dry-run mode, codes and tokens will not be claimed")
+	}
+
 	// Setup routes
 	mux, closer, err := routes.APIServer(ctx, cfg, db, cacher, limiterStore, tokenSigner, certificateSigner)
 	defer closer()
@@ -128,6 +142,17 @@ func realMain(ctx context.Context) error {
 		mux = handlers.LoggingHandler(os.Stdout, mux)
 	}
 
+	// Serve the gRPC API on the same port as the JSON API, if enabled.
+	if cfg.EnableGRPC {
+		grpcServer, grpcCloser, err := routes.APIServerGRPC(ctx, cfg, db, cacher, limiterStore, tokenSigner, certificateSigner)
+		defer grpcCloser()
+		if err != nil {
+			return fmt.Errorf("failed to setup grpc server: %w", err)
+		}
+		mux = grpcapi.Handler(grpcServer, mux)
+		logger.Infow("serving grpc api", "trusted_proxy", cfg.GRPCTrustedProxy)
+	}
+
 	// Run server
 	srv, err := server.New(cfg.Port)
 	if err != nil {
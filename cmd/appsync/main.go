@@ -25,11 +25,12 @@ import (
 	"github.com/google/exposure-notifications-verification-server/internal/buildinfo"
 	"github.com/google/exposure-notifications-verification-server/pkg/config"
 	"github.com/google/exposure-notifications-verification-server/pkg/controller/appsync"
+	"github.com/google/exposure-notifications-verification-server/pkg/controller/jobstatus"
 	"github.com/google/exposure-notifications-verification-server/pkg/controller/middleware"
+	"github.com/google/exposure-notifications-verification-server/pkg/observability"
 	"github.com/google/exposure-notifications-verification-server/pkg/render"
 
 	"github.com/google/exposure-notifications-server/pkg/logging"
-	"github.com/google/exposure-notifications-server/pkg/observability"
 	"github.com/google/exposure-notifications-server/pkg/server"
 
 	"github.com/gorilla/mux"
@@ -68,18 +69,12 @@ func realMain(ctx context.Context) error {
 	}
 
 	// Setup monitoring
-	logger.Info("configuring observability exporter")
-	oeConfig := cfg.ObservabilityExporterConfig()
-	oe, err := observability.NewFromEnv(ctx, oeConfig)
+	stopObservability, err := observability.Setup(ctx, cfg.ObservabilityExporterConfig(), cfg.Prometheus.Port)
 	if err != nil {
-		return fmt.Errorf("unable to create ObservabilityExporter provider: %w", err)
+		return fmt.Errorf("failed to setup observability: %w", err)
 	}
-	if err := oe.StartExporter(); err != nil {
-		return fmt.Errorf("error initializing observability exporter: %w", err)
-	}
-	defer oe.Close()
+	defer stopObservability()
 	ctx, obs := middleware.WithObservability(ctx)
-	logger.Infow("observability exporter", "config", oeConfig)
 
 	// Setup database
 	db, err := cfg.Database.Load(ctx)
@@ -91,6 +86,11 @@ func realMain(ctx context.Context) error {
 	}
 	defer db.Close()
 
+	// Record the build, so deploys appear on the system event timeline.
+	if err := db.RecordBuildSeen("appsync", buildinfo.BuildID, buildinfo.BuildTag); err != nil {
+		logger.Errorw("failed to record build", "error", err)
+	}
+
 	// Create the renderer
 	h, err := render.New(ctx, nil, cfg.DevMode)
 	if err != nil {
@@ -124,6 +124,7 @@ func realMain(ctx context.Context) error {
 		return fmt.Errorf("failed to create cleanup controller: %w", err)
 	}
 	r.Handle("/", appSyncController.HandleSync()).Methods(http.MethodGet)
+	r.Handle("/status", jobstatus.HandleStatus(db, h, jobstatus.JobAppSync)).Methods(http.MethodGet)
 
 	srv, err := server.New(cfg.Port)
 	if err != nil {
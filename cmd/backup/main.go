@@ -26,10 +26,10 @@ import (
 	"github.com/google/exposure-notifications-verification-server/pkg/config"
 	"github.com/google/exposure-notifications-verification-server/pkg/controller/backup"
 	"github.com/google/exposure-notifications-verification-server/pkg/controller/middleware"
+	"github.com/google/exposure-notifications-verification-server/pkg/observability"
 	"github.com/google/exposure-notifications-verification-server/pkg/render"
 
 	"github.com/google/exposure-notifications-server/pkg/logging"
-	"github.com/google/exposure-notifications-server/pkg/observability"
 	"github.com/google/exposure-notifications-server/pkg/server"
 
 	"github.com/gorilla/mux"
@@ -68,18 +68,12 @@ func realMain(ctx context.Context) error {
 	}
 
 	// Setup monitoring
-	logger.Info("configuring observability exporter")
-	oeConfig := cfg.ObservabilityExporterConfig()
-	oe, err := observability.NewFromEnv(ctx, oeConfig)
+	stopObservability, err := observability.Setup(ctx, cfg.ObservabilityExporterConfig(), cfg.Prometheus.Port)
 	if err != nil {
-		return fmt.Errorf("unable to create ObservabilityExporter provider: %w", err)
+		return fmt.Errorf("failed to setup observability: %w", err)
 	}
-	if err := oe.StartExporter(); err != nil {
-		return fmt.Errorf("error initializing observability exporter: %w", err)
-	}
-	defer oe.Close()
+	defer stopObservability()
 	ctx, obs := middleware.WithObservability(ctx)
-	logger.Infow("observability exporter", "config", oeConfig)
 
 	// Setup database
 	db, err := cfg.Database.Load(ctx)
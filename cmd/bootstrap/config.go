@@ -0,0 +1,189 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Config is the bootstrap configuration, read from a YAML file.
+type Config struct {
+	// KeysDir is the directory where the filesystem key manager stores signing
+	// and encryption keys.
+	KeysDir string `yaml:"keysDir"`
+
+	// SecretsDir is the directory where the filesystem secret manager stores
+	// secrets.
+	SecretsDir string `yaml:"secretsDir"`
+
+	// EnvFile is the file where the environment for the services is written. It
+	// contains the database password, so it is only readable by the owner.
+	EnvFile string `yaml:"envFile"`
+
+	// ProjectID identifies the installation. It does not need to be a Google
+	// Cloud project.
+	ProjectID string `yaml:"projectID"`
+
+	// Database is the database connection configuration.
+	Database DatabaseConfig `yaml:"database"`
+
+	// SystemAdmin is the initial system administrator.
+	SystemAdmin SystemAdminConfig `yaml:"systemAdmin"`
+}
+
+// DatabaseConfig is the database connection configuration.
+type DatabaseConfig struct {
+	Host     string `yaml:"host"`
+	Port     string `yaml:"port"`
+	Name     string `yaml:"name"`
+	User     string `yaml:"user"`
+	Password string `yaml:"password"`
+	SSLMode  string `yaml:"sslMode"`
+}
+
+// SystemAdminConfig is the initial system administrator.
+type SystemAdminConfig struct {
+	Email string `yaml:"email"`
+	Name  string `yaml:"name"`
+}
+
+// loadConfig reads and validates the configuration at the given path. Relative
+// directories are resolved against the current working directory.
+func loadConfig(pth string) (*Config, error) {
+	b, err := os.ReadFile(pth)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config: %w", err)
+	}
+
+	cfg := &Config{
+		ProjectID: "local",
+		SystemAdmin: SystemAdminConfig{
+			Name: "System Admin",
+		},
+		Database: DatabaseConfig{
+			Host:    "localhost",
+			Port:    "5432",
+			SSLMode: "require",
+		},
+	}
+	if err := yaml.Unmarshal(b, cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse config: %w", err)
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	for _, dir := range []*string{&cfg.KeysDir, &cfg.SecretsDir, &cfg.EnvFile} {
+		abs, err := filepath.Abs(*dir)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve %q: %w", *dir, err)
+		}
+		*dir = abs
+	}
+	return cfg, nil
+}
+
+// Validate validates the configuration.
+func (c *Config) Validate() error {
+	if c.KeysDir == "" {
+		return fmt.Errorf("keysDir is required")
+	}
+	if c.SecretsDir == "" {
+		return fmt.Errorf("secretsDir is required")
+	}
+	if c.EnvFile == "" {
+		return fmt.Errorf("envFile is required")
+	}
+	if c.ProjectID == "" {
+		return fmt.Errorf("projectID cannot be empty")
+	}
+	if c.Database.Name == "" {
+		return fmt.Errorf("database.name is required")
+	}
+	if c.Database.User == "" {
+		return fmt.Errorf("database.user is required")
+	}
+	if c.SystemAdmin.Email == "" {
+		return fmt.Errorf("systemAdmin.email is required")
+	}
+	if _, err := mail.ParseAddress(c.SystemAdmin.Email); err != nil {
+		return fmt.Errorf("systemAdmin.email is invalid: %w", err)
+	}
+	return nil
+}
+
+// Env returns the environment shared by all services, with the keys and
+// secrets created by bootstrap. It is also used to configure bootstrap
+// itself, so the services see exactly what bootstrap used.
+func (c *Config) Env(k *Keys, s *Secrets) map[string]string {
+	env := map[string]string{
+		"PROJECT_ID": c.ProjectID,
+
+		"DB_HOST":     c.Database.Host,
+		"DB_PORT":     c.Database.Port,
+		"DB_NAME":     c.Database.Name,
+		"DB_USER":     c.Database.User,
+		"DB_PASSWORD": c.Database.Password,
+		"DB_SSLMODE":  c.Database.SSLMode,
+
+		"SECRET_MANAGER":         "FILESYSTEM",
+		"SECRET_FILESYSTEM_ROOT": c.SecretsDir,
+		"SECRETS_PARENT":         secretsParent,
+	}
+
+	// Every key manager uses the same filesystem root.
+	for _, prefix := range []string{"", "DB_", "CERTIFICATE_", "TOKEN_", "SMS_"} {
+		env[prefix+"KEY_MANAGER"] = "FILESYSTEM"
+		env[prefix+"KEY_FILESYSTEM_ROOT"] = c.KeysDir
+	}
+
+	if k != nil {
+		env["CERTIFICATE_SIGNING_KEY"] = k.CertificateSigningKey
+		env["TOKEN_SIGNING_KEY"] = k.TokenSigningKey
+		env["DB_ENCRYPTION_KEY"] = k.DatabaseEncryptionKey
+		env["DB_KEYRING"] = k.DatabaseKeyRing
+	}
+
+	if s != nil {
+		env["CACHE_HMAC_KEY"] = "secret://" + s.CacheHMACKey
+		env["RATE_LIMIT_HMAC_KEY"] = "secret://" + s.RateLimitHMACKey
+	}
+	return env
+}
+
+// formatEnv formats the environment as a file that can be sourced by a shell.
+// Values are single-quoted, so they are never expanded.
+func formatEnv(env map[string]string) string {
+	names := make([]string, 0, len(env))
+	for k := range env {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	b.WriteString("# Generated by bootstrap. Do not edit, re-run bootstrap instead.\n")
+	for _, k := range names {
+		fmt.Fprintf(&b, "export %s='%s'\n", k, strings.ReplaceAll(env[k], "'", `'\''`))
+	}
+	return b.String()
+}
@@ -0,0 +1,168 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadConfig(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name string
+		yaml string
+		err  string
+	}{
+		{
+			name: "valid",
+			yaml: `
+keysDir: keys
+secretsDir: secrets
+envFile: env
+database:
+  name: verification
+  user: verification
+systemAdmin:
+  email: admin@example.com
+`,
+		},
+		{
+			name: "missing_keys_dir",
+			yaml: `
+secretsDir: secrets
+envFile: env
+database:
+  name: verification
+  user: verification
+systemAdmin:
+  email: admin@example.com
+`,
+			err: "keysDir is required",
+		},
+		{
+			name: "missing_database",
+			yaml: `
+keysDir: keys
+secretsDir: secrets
+envFile: env
+systemAdmin:
+  email: admin@example.com
+`,
+			err: "database.name is required",
+		},
+		{
+			name: "invalid_email",
+			yaml: `
+keysDir: keys
+secretsDir: secrets
+envFile: env
+database:
+  name: verification
+  user: verification
+systemAdmin:
+  email: not an email
+`,
+			err: "systemAdmin.email is invalid",
+		},
+		{
+			name: "invalid_yaml",
+			yaml: `keysDir: [`,
+			err:  "failed to parse config",
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			pth := filepath.Join(t.TempDir(), "bootstrap.yaml")
+			if err := os.WriteFile(pth, []byte(tc.yaml), 0o600); err != nil {
+				t.Fatal(err)
+			}
+
+			cfg, err := loadConfig(pth)
+			if err != nil {
+				if tc.err == "" {
+					t.Fatal(err)
+				}
+				if !strings.Contains(err.Error(), tc.err) {
+					t.Fatalf("expected %q to contain %q", err, tc.err)
+				}
+				return
+			}
+			if tc.err != "" {
+				t.Fatalf("expected error %q", tc.err)
+			}
+
+			// Defaults are applied and directories are absolute.
+			if got, want := cfg.Database.Host, "localhost"; got != want {
+				t.Errorf("expected %q to be %q", got, want)
+			}
+			if got, want := cfg.SystemAdmin.Name, "System Admin"; got != want {
+				t.Errorf("expected %q to be %q", got, want)
+			}
+			if !filepath.IsAbs(cfg.KeysDir) {
+				t.Errorf("expected %q to be absolute", cfg.KeysDir)
+			}
+		})
+	}
+}
+
+func TestConfig_Env(t *testing.T) {
+	t.Parallel()
+
+	cfg := &Config{
+		KeysDir:    "/keys",
+		SecretsDir: "/secrets",
+		ProjectID:  "local",
+		Database: DatabaseConfig{
+			Name:     "verification",
+			User:     "verification",
+			Password: "it's a secret",
+		},
+	}
+
+	env := cfg.Env(&Keys{
+		CertificateSigningKey: "/system/certificate-signing/1",
+		TokenSigningKey:       "/system/token-signing",
+		DatabaseEncryptionKey: "/system/database-encryption",
+		DatabaseKeyRing:       "/realm",
+	}, &Secrets{
+		CacheHMACKey:     "system/cache-hmac-key/1",
+		RateLimitHMACKey: "system/ratelimit-hmac-key/1",
+	})
+
+	for k, want := range map[string]string{
+		"DB_KEY_MANAGER":            "FILESYSTEM",
+		"TOKEN_KEY_FILESYSTEM_ROOT": "/keys",
+		"SECRET_FILESYSTEM_ROOT":    "/secrets",
+		"TOKEN_SIGNING_KEY":         "/system/token-signing",
+		"CACHE_HMAC_KEY":            "secret://system/cache-hmac-key/1",
+	} {
+		if got := env[k]; got != want {
+			t.Errorf("expected %s to be %q, got %q", k, want, got)
+		}
+	}
+
+	if got, want := formatEnv(env), `export DB_PASSWORD='it'\''s a secret'`; !strings.Contains(got, want) {
+		t.Errorf("expected %q to contain %q", got, want)
+	}
+}
@@ -0,0 +1,367 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// A binary for standing up a new installation without Terraform or Google
+// Cloud. It creates keys in a filesystem key manager and secrets in a
+// filesystem secret manager, runs the database migrations, creates the initial
+// rotated secrets, creates the first system admin, and writes the resulting
+// environment for the services.
+//
+// Bootstrap is idempotent. Existing keys, secrets, and users are kept, so it
+// is safe to re-run after a failure or to regenerate the environment file.
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"path"
+	"path/filepath"
+	"syscall"
+
+	"github.com/google/exposure-notifications-verification-server/internal/buildinfo"
+	"github.com/google/exposure-notifications-verification-server/pkg/config"
+	"github.com/google/exposure-notifications-verification-server/pkg/controller/rotation"
+	"github.com/google/exposure-notifications-verification-server/pkg/database"
+	"github.com/google/exposure-notifications-verification-server/pkg/render"
+
+	"github.com/google/exposure-notifications-server/pkg/keys"
+	"github.com/google/exposure-notifications-server/pkg/logging"
+	"github.com/google/exposure-notifications-server/pkg/secrets"
+
+	_ "github.com/jinzhu/gorm/dialects/postgres"
+	"github.com/sethvargo/go-envconfig"
+)
+
+var configFlag = flag.String("config", "bootstrap.yaml", "path to the bootstrap configuration file")
+
+// secretsParent is the directory, relative to the secrets directory, where all
+// secrets are created.
+const secretsParent = "system"
+
+// hmacKeyBytes is the number of random bytes in generated HMAC keys. This
+// matches the keys created by Terraform.
+const hmacKeyBytes = 128
+
+func main() {
+	flag.Parse()
+
+	ctx, done := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+
+	logger := logging.NewLoggerFromEnv().
+		With("build_id", buildinfo.BuildID).
+		With("build_tag", buildinfo.BuildTag)
+	ctx = logging.WithLogger(ctx, logger)
+
+	defer func() {
+		done()
+		if r := recover(); r != nil {
+			logger.Fatalw("application panic", "panic", r)
+		}
+	}()
+
+	err := realMain(ctx)
+	done()
+
+	if err != nil {
+		logger.Fatal(err)
+	}
+}
+
+func realMain(ctx context.Context) error {
+	logger := logging.FromContext(ctx).Named("bootstrap")
+
+	cfg, err := loadConfig(*configFlag)
+	if err != nil {
+		return err
+	}
+
+	// Keys
+	keyManager, err := keys.NewFilesystem(ctx, &keys.Config{FilesystemRoot: cfg.KeysDir})
+	if err != nil {
+		return fmt.Errorf("failed to create key manager: %w", err)
+	}
+	fsKeyManager, ok := keyManager.(*keys.Filesystem)
+	if !ok {
+		return fmt.Errorf("key manager is not a filesystem key manager (got %T)", keyManager)
+	}
+	createdKeys, err := createKeys(ctx, fsKeyManager, cfg.KeysDir)
+	if err != nil {
+		return fmt.Errorf("failed to create keys: %w", err)
+	}
+	logger.Infow("created keys", "dir", cfg.KeysDir)
+
+	// Secrets
+	secretManager, err := secrets.NewFilesystem(ctx, &secrets.Config{FilesystemRoot: cfg.SecretsDir})
+	if err != nil {
+		return fmt.Errorf("failed to create secret manager: %w", err)
+	}
+	secretVersionManager, ok := secretManager.(secrets.SecretVersionManager)
+	if !ok {
+		return fmt.Errorf("secret manager is not a secret version manager (got %T)", secretManager)
+	}
+	createdSecrets, err := createSecrets(ctx, secretVersionManager, cfg.SecretsDir)
+	if err != nil {
+		return fmt.Errorf("failed to create secrets: %w", err)
+	}
+	logger.Infow("created secrets", "dir", cfg.SecretsDir)
+
+	// The remaining steps are configured exactly like the services will be.
+	// Values from the environment fill in anything bootstrap does not set, like
+	// DB_DEBUG.
+	env := cfg.Env(createdKeys, createdSecrets)
+	lookuper := envconfig.MultiLookuper(envconfig.MapLookuper(env), envconfig.OsLookuper())
+
+	// Migrations
+	var dbConfig database.Config
+	if err := config.ProcessWith(ctx, &dbConfig, lookuper); err != nil {
+		return fmt.Errorf("failed to process database config: %w", err)
+	}
+	db, err := dbConfig.Load(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to load database config: %w", err)
+	}
+	if err := db.Open(ctx); err != nil {
+		return fmt.Errorf("failed to connect to database: %w", err)
+	}
+	defer db.Close()
+
+	if err := db.MigrateTo(ctx, "", false); err != nil {
+		return fmt.Errorf("failed to run migrations: %w", err)
+	}
+	logger.Infow("ran migrations")
+
+	// Rotated secrets and keys
+	if err := rotateSecrets(ctx, db, lookuper, fsKeyManager); err != nil {
+		return fmt.Errorf("failed to create initial secrets: %w", err)
+	}
+	logger.Infow("created initial rotated secrets")
+
+	// System admin
+	admin, err := createSystemAdmin(db, &cfg.SystemAdmin)
+	if err != nil {
+		return fmt.Errorf("failed to create system admin: %w", err)
+	}
+	logger.Infow("created system admin", "email", admin.Email)
+
+	// Environment
+	if err := os.WriteFile(cfg.EnvFile, []byte(formatEnv(env)), 0o600); err != nil {
+		return fmt.Errorf("failed to write environment file: %w", err)
+	}
+	logger.Infow("wrote environment file", "path", cfg.EnvFile)
+
+	return nil
+}
+
+// Keys are the keys created by bootstrap.
+type Keys struct {
+	// CertificateSigningKey is the certificate signing key version.
+	CertificateSigningKey string
+
+	// TokenSigningKey is the token signing key. Versions are created by the
+	// rotation service.
+	TokenSigningKey string
+
+	// DatabaseEncryptionKey is the application-layer database encryption key.
+	DatabaseEncryptionKey string
+
+	// DatabaseKeyRing is the key ring for per-realm keys.
+	DatabaseKeyRing string
+}
+
+// createKeys creates the system keys, reusing any that already exist.
+func createKeys(ctx context.Context, km *keys.Filesystem, root string) (*Keys, error) {
+	certParent, err := km.CreateSigningKey(ctx, "system", "certificate-signing")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create certificate signing key: %w", err)
+	}
+	list, err := km.SigningKeyVersions(ctx, certParent)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list certificate signing key versions: %w", err)
+	}
+	var certVersion string
+	if len(list) == 0 {
+		certVersion, err = km.CreateKeyVersion(ctx, certParent)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create certificate signing key version: %w", err)
+		}
+	} else {
+		certVersion = list[0].KeyID()
+	}
+
+	tokenParent, err := km.CreateSigningKey(ctx, "system", "token-signing")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create token signing key: %w", err)
+	}
+
+	dbParent, err := km.CreateEncryptionKey(ctx, "system", "database-encryption")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create database encryption key: %w", err)
+	}
+	versions, err := versionsIn(filepath.Join(root, dbParent))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list database encryption key versions: %w", err)
+	}
+	if len(versions) == 0 {
+		if _, err := km.CreateKeyVersion(ctx, dbParent); err != nil {
+			return nil, fmt.Errorf("failed to create database encryption key version: %w", err)
+		}
+	}
+
+	return &Keys{
+		CertificateSigningKey: certVersion,
+		TokenSigningKey:       tokenParent,
+		DatabaseEncryptionKey: dbParent,
+		DatabaseKeyRing:       "/realm",
+	}, nil
+}
+
+// Secrets are the secrets created by bootstrap, as references for the secret
+// manager.
+type Secrets struct {
+	CacheHMACKey     string
+	RateLimitHMACKey string
+}
+
+// createSecrets creates the static secrets, reusing any that already exist.
+// Secrets that are rotated by the system are created by rotateSecrets.
+func createSecrets(ctx context.Context, sm secrets.SecretVersionManager, root string) (*Secrets, error) {
+	cacheHMACKey, err := ensureSecret(ctx, sm, root, "cache-hmac-key")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cache hmac key: %w", err)
+	}
+
+	rateLimitHMACKey, err := ensureSecret(ctx, sm, root, "ratelimit-hmac-key")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create rate limit hmac key: %w", err)
+	}
+
+	return &Secrets{
+		CacheHMACKey:     cacheHMACKey,
+		RateLimitHMACKey: rateLimitHMACKey,
+	}, nil
+}
+
+// ensureSecret returns a reference to the latest version of the secret,
+// creating a random one if none exists.
+func ensureSecret(ctx context.Context, sm secrets.SecretVersionManager, root, name string) (string, error) {
+	parent := path.Join(secretsParent, name)
+
+	versions, err := versionsIn(filepath.Join(root, parent))
+	if err != nil {
+		return "", fmt.Errorf("failed to list versions: %w", err)
+	}
+	if len(versions) > 0 {
+		return path.Join(parent, versions[len(versions)-1]), nil
+	}
+
+	b := make([]byte, hmacKeyBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate secret: %w", err)
+	}
+
+	// Encode to base64 so we can use our secret:// resolution in the server.
+	ref, err := sm.CreateSecretVersion(ctx, parent, []byte(base64.StdEncoding.EncodeToString(b)))
+	if err != nil {
+		return "", fmt.Errorf("failed to create version: %w", err)
+	}
+	return ref, nil
+}
+
+// versionsIn returns the names of the versions in a filesystem key or secret
+// directory, oldest first. It returns an empty list if the directory does not
+// exist.
+func versionsIn(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	versions := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		if entry.IsDir() || entry.Name() == "metadata" {
+			continue
+		}
+		versions = append(versions, entry.Name())
+	}
+	return versions, nil
+}
+
+// rotateSecrets creates the secrets and keys that are managed by the rotation
+// service, the same way the seed tool does.
+func rotateSecrets(ctx context.Context, db *database.Database, lookuper envconfig.Lookuper, km keys.SigningKeyManager) error {
+	var cfg config.RotationConfig
+	if err := config.ProcessWith(ctx, &cfg, lookuper); err != nil {
+		return fmt.Errorf("failed to process rotation config: %w", err)
+	}
+
+	h, err := render.New(ctx, nil, false)
+	if err != nil {
+		return fmt.Errorf("failed to create renderer: %w", err)
+	}
+
+	sm, err := secrets.NewFilesystem(ctx, &cfg.Secrets)
+	if err != nil {
+		return fmt.Errorf("failed to create secret manager: %w", err)
+	}
+	smv, ok := sm.(secrets.SecretVersionManager)
+	if !ok {
+		return fmt.Errorf("secret manager is not a secret version manager (got %T)", sm)
+	}
+
+	rotationController := rotation.New(&cfg, db, km, smv, h)
+
+	if err := rotationController.RotateSecrets(ctx); err != nil {
+		return fmt.Errorf("failed to create secrets: %w", err)
+	}
+
+	if err := rotationController.RotateCookieKeys(ctx); err != nil {
+		return fmt.Errorf("failed to create cookie keys: %w", err)
+	}
+
+	if err := rotationController.RotateTokenSigningKey(ctx); err != nil {
+		return fmt.Errorf("failed to create token signing key: %w", err)
+	}
+
+	return nil
+}
+
+// createSystemAdmin creates the system admin, or promotes an existing user with
+// the same email.
+func createSystemAdmin(db *database.Database, cfg *SystemAdminConfig) (*database.User, error) {
+	user, err := db.FindUserByEmail(cfg.Email)
+	if err != nil {
+		if !database.IsNotFound(err) {
+			return nil, fmt.Errorf("failed to find user: %w", err)
+		}
+		user = &database.User{Email: cfg.Email, Name: cfg.Name}
+	}
+
+	if user.SystemAdmin {
+		return user, nil
+	}
+
+	user.SystemAdmin = true
+	if err := db.SaveUser(user, database.System); err != nil {
+		return nil, fmt.Errorf("failed to save user: %w: %v", err, user.ErrorMessages())
+	}
+	return user, nil
+}
@@ -0,0 +1,95 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/exposure-notifications-server/pkg/keys"
+	"github.com/google/exposure-notifications-server/pkg/secrets"
+)
+
+func TestCreateKeys(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	root := t.TempDir()
+
+	km, err := keys.NewFilesystem(ctx, &keys.Config{FilesystemRoot: root})
+	if err != nil {
+		t.Fatal(err)
+	}
+	fs := km.(*keys.Filesystem)
+
+	first, err := createKeys(ctx, fs, root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fs.NewSigner(ctx, first.CertificateSigningKey); err != nil {
+		t.Errorf("expected certificate signing key to exist: %s", err)
+	}
+
+	// Running again reuses the keys.
+	second, err := createKeys(ctx, fs, root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *first != *second {
+		t.Errorf("expected %#v to be %#v", second, first)
+	}
+
+	versions, err := versionsIn(filepath.Join(root, first.DatabaseEncryptionKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(versions), 1; got != want {
+		t.Errorf("expected %d database encryption key versions, got %d", want, got)
+	}
+}
+
+func TestCreateSecrets(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	root := t.TempDir()
+
+	sm, err := secrets.NewFilesystem(ctx, &secrets.Config{FilesystemRoot: root})
+	if err != nil {
+		t.Fatal(err)
+	}
+	smv := sm.(secrets.SecretVersionManager)
+
+	first, err := createSecrets(ctx, smv, root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.CacheHMACKey == first.RateLimitHMACKey {
+		t.Errorf("expected different secrets")
+	}
+	if _, err := sm.GetSecretValue(ctx, first.CacheHMACKey); err != nil {
+		t.Errorf("expected cache hmac key to exist: %s", err)
+	}
+
+	// Running again reuses the secrets.
+	second, err := createSecrets(ctx, smv, root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *first != *second {
+		t.Errorf("expected %#v to be %#v", second, first)
+	}
+}
@@ -24,14 +24,21 @@ import (
 	"syscall"
 
 	"github.com/google/exposure-notifications-verification-server/internal/buildinfo"
+	"github.com/google/exposure-notifications-verification-server/internal/firebase"
 	"github.com/google/exposure-notifications-verification-server/pkg/config"
+	"github.com/google/exposure-notifications-verification-server/pkg/controller/callbacks"
 	"github.com/google/exposure-notifications-verification-server/pkg/controller/cleanup"
+	"github.com/google/exposure-notifications-verification-server/pkg/controller/jobstatus"
 	"github.com/google/exposure-notifications-verification-server/pkg/controller/middleware"
+	"github.com/google/exposure-notifications-verification-server/pkg/controller/realmexport"
+	"github.com/google/exposure-notifications-verification-server/pkg/controller/userimport"
+	"github.com/google/exposure-notifications-verification-server/pkg/observability"
 	"github.com/google/exposure-notifications-verification-server/pkg/render"
+	"github.com/google/exposure-notifications-verification-server/pkg/storage"
+	"github.com/google/exposure-notifications-verification-server/pkg/workloadidentity"
 
 	"github.com/google/exposure-notifications-server/pkg/keys"
 	"github.com/google/exposure-notifications-server/pkg/logging"
-	"github.com/google/exposure-notifications-server/pkg/observability"
 	"github.com/google/exposure-notifications-server/pkg/server"
 
 	"github.com/gorilla/mux"
@@ -70,18 +77,12 @@ func realMain(ctx context.Context) error {
 	}
 
 	// Setup monitoring
-	logger.Info("configuring observability exporter")
-	oeConfig := cfg.ObservabilityExporterConfig()
-	oe, err := observability.NewFromEnv(ctx, oeConfig)
+	stopObservability, err := observability.Setup(ctx, cfg.ObservabilityExporterConfig(), cfg.Prometheus.Port)
 	if err != nil {
-		return fmt.Errorf("unable to create ObservabilityExporter provider: %w", err)
+		return fmt.Errorf("failed to setup observability: %w", err)
 	}
-	if err := oe.StartExporter(); err != nil {
-		return fmt.Errorf("error initializing observability exporter: %w", err)
-	}
-	defer oe.Close()
+	defer stopObservability()
 	ctx, obs := middleware.WithObservability(ctx)
-	logger.Infow("observability exporter", "config", oeConfig)
 
 	// Setup database
 	db, err := cfg.Database.Load(ctx)
@@ -93,6 +94,11 @@ func realMain(ctx context.Context) error {
 	}
 	defer db.Close()
 
+	// Record the build, so deploys appear on the system event timeline.
+	if err := db.RecordBuildSeen("cleanup", buildinfo.BuildID, buildinfo.BuildTag); err != nil {
+		logger.Errorw("failed to record build", "error", err)
+	}
+
 	// Create the renderer
 	h, err := render.New(ctx, nil, cfg.DevMode)
 	if err != nil {
@@ -100,7 +106,7 @@ func realMain(ctx context.Context) error {
 	}
 
 	// Get token key manager.
-	tokenSigner, err := keys.KeyManagerFor(ctx, &cfg.TokenSigning.Keys)
+	tokenSigner, err := workloadidentity.KeyManagerFor(ctx, &cfg.WorkloadIdentity, &cfg.TokenSigning.Keys)
 	if err != nil {
 		return fmt.Errorf("failed to token signing key manager: %w", err)
 	}
@@ -131,8 +137,56 @@ func realMain(ctx context.Context) error {
 	recovery := middleware.Recovery(h)
 	r.Use(recovery)
 
-	cleanupController := cleanup.New(cfg, db, tokenSignerTyp, h)
+	// Worker authentication
+	requireWorkerAuth, err := middleware.RequireWorkerAuth(ctx, &cfg.WorkerAuth, h)
+	if err != nil {
+		return fmt.Errorf("failed to create worker auth middleware: %w", err)
+	}
+	r.Use(requireWorkerAuth)
+
+	// Firebase accounts are only disabled and deleted when enabled.
+	var firebaseUsers cleanup.FirebaseUserManager
+	if cfg.FirebaseUserDeletion.Enabled {
+		firebaseClient, err := firebase.New(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to create firebase client: %w", err)
+		}
+		firebaseUsers = firebaseClient
+	}
+
+	cleanupController := cleanup.New(cfg, db, tokenSignerTyp, firebaseUsers, h)
 	r.Handle("/", cleanupController.HandleCleanup()).Methods(http.MethodGet)
+	r.Handle("/consistency", cleanupController.HandleConsistency()).Methods(http.MethodGet)
+	r.Handle("/dual-write-verify", cleanupController.HandleDualWriteVerify()).Methods(http.MethodGet)
+	r.Handle("/realm-kpi", cleanupController.HandleRealmKPI()).Methods(http.MethodGet)
+	r.Handle("/status", jobstatus.HandleStatus(db, h, jobstatus.JobCleanup, jobstatus.JobConsistency, jobstatus.JobRealmKPI, jobstatus.JobCallbacks, jobstatus.JobDualWriteVerify, jobstatus.JobUserImports)).Methods(http.MethodGet)
+
+	callbacksController := callbacks.New(&cfg.Callbacks, db, h)
+	r.Handle("/callbacks", callbacksController.HandleDeliver()).Methods(http.MethodGet)
+
+	userImportController := userimport.New(&cfg.UserImport, db, h)
+	r.Handle("/user-imports", userImportController.HandleProcess()).Methods(http.MethodGet)
+
+	// Realm exports are optional and only enabled when a destination is
+	// configured.
+	if cfg.RealmExport.Enabled() {
+		exportKeyManager, err := workloadidentity.KeyManagerFor(ctx, &cfg.WorkloadIdentity, &cfg.RealmExport.Keys)
+		if err != nil {
+			return fmt.Errorf("failed to create realm export key manager: %w", err)
+		}
+		exportSigner, err := exportKeyManager.NewSigner(ctx, cfg.RealmExport.SigningKey)
+		if err != nil {
+			return fmt.Errorf("failed to create realm export signer: %w", err)
+		}
+
+		exportBlobstore, err := storage.BlobstoreFor(ctx, &cfg.RealmExport.Storage)
+		if err != nil {
+			return fmt.Errorf("failed to create realm export blobstore: %w", err)
+		}
+
+		realmExportController := realmexport.New(&cfg.RealmExport, db, exportBlobstore, exportSigner, h)
+		r.Handle("/realm-export", realmExportController.HandleExport()).Methods(http.MethodGet)
+	}
 
 	srv, err := server.New(cfg.Port)
 	if err != nil {
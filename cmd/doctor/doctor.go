@@ -0,0 +1,257 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/google/exposure-notifications-verification-server/pkg/config"
+	"github.com/google/exposure-notifications-verification-server/pkg/database"
+	"github.com/google/exposure-notifications-verification-server/pkg/pagination"
+
+	"github.com/google/exposure-notifications-server/pkg/keys"
+)
+
+// severity is the priority of a finding. Higher values are more severe.
+type severity int
+
+const (
+	severityWarning severity = iota + 1
+	severityCritical
+)
+
+func (s severity) String() string {
+	switch s {
+	case severityWarning:
+		return "warning"
+	case severityCritical:
+		return "critical"
+	default:
+		return fmt.Sprintf("severity(%d)", int(s))
+	}
+}
+
+func parseSeverity(s string) (severity, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "warning":
+		return severityWarning, nil
+	case "critical":
+		return severityCritical, nil
+	default:
+		return 0, fmt.Errorf("unknown severity %q", s)
+	}
+}
+
+// finding is a single problem discovered by a check.
+type finding struct {
+	severity severity
+	check    string
+	subject  string
+	message  string
+}
+
+// doctor runs the checks and collects findings.
+type doctor struct {
+	config          *config.DoctorConfig
+	db              *database.Database
+	tokenKeyManager keys.KeyManager
+
+	// realmKeyManager is the key manager for realm certificate and SMS signing
+	// keys. It is usually the database key manager.
+	realmKeyManager keys.KeyManager
+
+	now      time.Time
+	findings []*finding
+}
+
+func (d *doctor) addf(sev severity, check, subject, format string, args ...interface{}) {
+	d.findings = append(d.findings, &finding{
+		severity: sev,
+		check:    check,
+		subject:  subject,
+		message:  fmt.Sprintf(format, args...),
+	})
+}
+
+// run executes all checks. Checks never abort the run; failures to query a
+// dependency are recorded as critical findings so the report is always
+// complete.
+func (d *doctor) run(ctx context.Context) {
+	d.now = time.Now().UTC()
+
+	d.checkTokenSigningKey(ctx)
+
+	realms, _, err := d.db.ListRealms(pagination.UnlimitedResults)
+	if err != nil {
+		d.addf(severityCritical, "database", "system", "failed to list realms: %s", err)
+		return
+	}
+
+	for _, realm := range realms {
+		d.checkRealm(ctx, realm)
+	}
+}
+
+// checkTokenSigningKey verifies there is an active token signing key, that it
+// is reachable in the key manager, and that it has been rotated recently.
+func (d *doctor) checkTokenSigningKey(ctx context.Context) {
+	const check = "token-signing-key"
+
+	key, err := d.db.ActiveTokenSigningKey()
+	if err != nil {
+		if database.IsNotFound(err) {
+			d.addf(severityCritical, check, "system", "no active token signing key")
+			return
+		}
+		d.addf(severityCritical, check, "system", "failed to find active token signing key: %s", err)
+		return
+	}
+
+	if _, err := d.tokenKeyManager.NewSigner(ctx, key.KeyVersionID); err != nil {
+		d.addf(severityCritical, check, "system", "active token signing key %s is not usable: %s", key.KeyVersionID, err)
+	}
+
+	if maxAge := d.config.TokenSigningKeyMaxAge + d.config.RotationGracePeriod; d.now.Sub(key.CreatedAt) > maxAge {
+		d.addf(severityWarning, check, "system", "active token signing key was created %s ago, rotation may not be running",
+			d.now.Sub(key.CreatedAt).Truncate(time.Hour))
+	}
+}
+
+// checkRealm runs all realm-level checks.
+func (d *doctor) checkRealm(ctx context.Context, realm *database.Realm) {
+	subject := fmt.Sprintf("realm %d (%s)", realm.ID, realm.Name)
+
+	// Realms using their own certificate signing keys must have an active key,
+	// and those keys must be rotated if auto-rotation is enabled.
+	if realm.UseRealmCertificateKey {
+		const check = "certificate-signing-key"
+
+		signingKeys, err := realm.ListSigningKeys(d.db)
+		if err != nil {
+			d.addf(severityCritical, check, subject, "failed to list signing keys: %s", err)
+		} else {
+			d.checkManagedKeys(ctx, check, subject, len(signingKeys), func() (string, bool) {
+				for _, k := range signingKeys {
+					if k.Active {
+						return k.ManagedKeyID(), true
+					}
+				}
+				return "", false
+			})
+
+			if realm.AutoRotateCertificateKey && len(signingKeys) > 0 {
+				newest := signingKeys[0]
+				if maxAge := d.config.VerificationSigningKeyMaxAge + d.config.RotationGracePeriod; d.now.Sub(newest.CreatedAt) > maxAge {
+					d.addf(severityWarning, check, subject, "newest signing key was created %s ago, rotation may not be running",
+						d.now.Sub(newest.CreatedAt).Truncate(time.Hour))
+				}
+			}
+		}
+	}
+
+	// Realms using authenticated SMS must have an active SMS signing key.
+	if realm.UseAuthenticatedSMS {
+		const check = "sms-signing-key"
+
+		smsKeys, err := realm.ListSMSSigningKeys(d.db)
+		if err != nil {
+			d.addf(severityCritical, check, subject, "failed to list SMS signing keys: %s", err)
+		} else {
+			d.checkManagedKeys(ctx, check, subject, len(smsKeys), func() (string, bool) {
+				for _, k := range smsKeys {
+					if k.Active {
+						return k.ManagedKeyID(), true
+					}
+				}
+				return "", false
+			})
+		}
+	}
+
+	// User report sends text messages, so an SMS configuration is required.
+	if realm.AllowsUserReport() {
+		const check = "user-report-sms"
+
+		ok, err := realm.HasSMSConfig(d.db)
+		if err != nil {
+			d.addf(severityCritical, check, subject, "failed to check SMS configuration: %s", err)
+		} else if !ok {
+			d.addf(severityCritical, check, subject, "user report is enabled, but there is no SMS configuration")
+		}
+	}
+}
+
+// checkManagedKeys checks the number of key versions against the configured
+// maximum, and that the active key exists and is usable in the key manager.
+func (d *doctor) checkManagedKeys(ctx context.Context, check, subject string, count int, active func() (string, bool)) {
+	if max := d.db.MaxKeyVersions(); int64(count) > max {
+		d.addf(severityCritical, check, subject, "has %d key versions, which exceeds the maximum of %d", count, max)
+	} else if int64(count) == max {
+		d.addf(severityWarning, check, subject, "has %d key versions, new keys cannot be created until one is destroyed", count)
+	}
+
+	keyID, ok := active()
+	if !ok {
+		d.addf(severityCritical, check, subject, "no active signing key")
+		return
+	}
+
+	if _, err := d.realmKeyManager.NewSigner(ctx, keyID); err != nil {
+		d.addf(severityCritical, check, subject, "active signing key %s is not usable: %s", keyID, err)
+	}
+}
+
+// exceeds returns true if any finding is at or above the given severity.
+func (d *doctor) exceeds(sev severity) bool {
+	for _, f := range d.findings {
+		if f.severity >= sev {
+			return true
+		}
+	}
+	return false
+}
+
+// writeReport writes the findings to w, most severe first.
+func (d *doctor) writeReport(w io.Writer) error {
+	sort.SliceStable(d.findings, func(i, j int) bool {
+		if d.findings[i].severity != d.findings[j].severity {
+			return d.findings[i].severity > d.findings[j].severity
+		}
+		return d.findings[i].check < d.findings[j].check
+	})
+
+	counts := make(map[severity]int, 2)
+	for _, f := range d.findings {
+		counts[f.severity]++
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(tw, "SEVERITY\tCHECK\tSUBJECT\tMESSAGE\n")
+	for _, f := range d.findings {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", strings.ToUpper(f.severity.String()), f.check, f.subject, f.message)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	_, err := fmt.Fprintf(w, "\n%d critical, %d warning(s)\n", counts[severityCritical], counts[severityWarning])
+	return err
+}
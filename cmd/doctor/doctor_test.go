@@ -0,0 +1,519 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/exposure-notifications-server/pkg/keys"
+	"github.com/google/exposure-notifications-verification-server/internal/project"
+	"github.com/google/exposure-notifications-verification-server/pkg/config"
+	"github.com/google/exposure-notifications-verification-server/pkg/database"
+	"github.com/jinzhu/gorm"
+)
+
+// unusableKeyManager is a key manager whose keys cannot be used for signing.
+type unusableKeyManager struct {
+	keys.KeyManager
+}
+
+func (unusableKeyManager) NewSigner(ctx context.Context, keyID string) (crypto.Signer, error) {
+	return nil, fmt.Errorf("key %s is disabled", keyID)
+}
+
+// wantFinding is an expected finding. message is matched as a substring.
+type wantFinding struct {
+	severity severity
+	check    string
+	message  string
+}
+
+func checkFindings(tb testing.TB, got []*finding, want []*wantFinding) {
+	tb.Helper()
+
+	if len(got) != len(want) {
+		for _, f := range got {
+			tb.Logf("finding: %s %s %s: %s", f.severity, f.check, f.subject, f.message)
+		}
+		tb.Fatalf("expected %d findings, got %d", len(want), len(got))
+	}
+
+	for i, w := range want {
+		g := got[i]
+		if g.severity != w.severity {
+			tb.Errorf("finding %d: expected severity %s to be %s", i, g.severity, w.severity)
+		}
+		if g.check != w.check {
+			tb.Errorf("finding %d: expected check %q to be %q", i, g.check, w.check)
+		}
+		if !strings.Contains(g.message, w.message) {
+			tb.Errorf("finding %d: expected %q to contain %q", i, g.message, w.message)
+		}
+	}
+}
+
+func testDoctorConfig() *config.DoctorConfig {
+	return &config.DoctorConfig{
+		TokenSigningKeyMaxAge:        time.Hour,
+		VerificationSigningKeyMaxAge: time.Hour,
+		RotationGracePeriod:          time.Minute,
+	}
+}
+
+func TestParseSeverity(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		in   string
+		want severity
+		err  bool
+	}{
+		{in: "warning", want: severityWarning},
+		{in: " Critical ", want: severityCritical},
+		{in: "info", err: true},
+		{in: "", err: true},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.in, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := parseSeverity(tc.in)
+			if (err != nil) != tc.err {
+				t.Fatalf("expected error to be %t, got %v", tc.err, err)
+			}
+			if got != tc.want {
+				t.Errorf("expected %s to be %s", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestDoctor_Exceeds(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name     string
+		findings []*finding
+		sev      severity
+		want     bool
+	}{
+		{
+			name: "no_findings",
+			sev:  severityWarning,
+			want: false,
+		},
+		{
+			name:     "warning_at_warning",
+			findings: []*finding{{severity: severityWarning}},
+			sev:      severityWarning,
+			want:     true,
+		},
+		{
+			name:     "warning_at_critical",
+			findings: []*finding{{severity: severityWarning}},
+			sev:      severityCritical,
+			want:     false,
+		},
+		{
+			name:     "critical_at_warning",
+			findings: []*finding{{severity: severityCritical}},
+			sev:      severityWarning,
+			want:     true,
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			d := &doctor{findings: tc.findings}
+			if got, want := d.exceeds(tc.sev), tc.want; got != want {
+				t.Errorf("expected %t to be %t", got, want)
+			}
+		})
+	}
+}
+
+func TestDoctor_WriteReport(t *testing.T) {
+	t.Parallel()
+
+	d := &doctor{}
+	d.addf(severityWarning, "b-check", "system", "warning one")
+	d.addf(severityCritical, "z-check", "system", "critical one")
+	d.addf(severityCritical, "a-check", "system", "critical two")
+
+	var b bytes.Buffer
+	if err := d.writeReport(&b); err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(b.String()), "\n")
+	if got, want := len(lines), 6; got != want {
+		t.Fatalf("expected %d lines, got %d: %q", want, got, lines)
+	}
+
+	// Most severe first, then ordered by check.
+	for i, want := range []string{"critical two", "critical one", "warning one"} {
+		if got := lines[i+1]; !strings.Contains(got, want) {
+			t.Errorf("expected line %d %q to contain %q", i+1, got, want)
+		}
+	}
+	if got, want := lines[5], "2 critical, 1 warning(s)"; got != want {
+		t.Errorf("expected %q to be %q", got, want)
+	}
+}
+
+func TestDoctor_CheckTokenSigningKey(t *testing.T) {
+	t.Parallel()
+
+	ctx := project.TestContext(t)
+
+	keyManager := keys.TestKeyManager(t)
+	keyManagerSigner, ok := keyManager.(keys.SigningKeyManager)
+	if !ok {
+		t.Fatal("kms cannot manage signing keys")
+	}
+	parent := keys.TestSigningKey(t, keyManager)
+
+	cases := []struct {
+		name       string
+		setup      func(tb testing.TB, db *database.Database)
+		keyManager keys.KeyManager
+		want       []*wantFinding
+	}{
+		{
+			name:       "missing",
+			keyManager: keyManager,
+			want: []*wantFinding{
+				{severityCritical, "token-signing-key", "no active token signing key"},
+			},
+		},
+		{
+			name: "unusable",
+			setup: func(tb testing.TB, db *database.Database) {
+				if _, err := db.RotateTokenSigningKey(ctx, keyManagerSigner, parent, database.SystemTest); err != nil {
+					tb.Fatal(err)
+				}
+			},
+			keyManager: &unusableKeyManager{keyManager},
+			want: []*wantFinding{
+				{severityCritical, "token-signing-key", "is not usable"},
+			},
+		},
+		{
+			name: "stale",
+			setup: func(tb testing.TB, db *database.Database) {
+				key, err := db.RotateTokenSigningKey(ctx, keyManagerSigner, parent, database.SystemTest)
+				if err != nil {
+					tb.Fatal(err)
+				}
+				key.CreatedAt = time.Now().UTC().Add(-24 * time.Hour)
+				if err := db.SaveTokenSigningKey(key, database.SystemTest); err != nil {
+					tb.Fatal(err)
+				}
+			},
+			keyManager: keyManager,
+			want: []*wantFinding{
+				{severityWarning, "token-signing-key", "rotation may not be running"},
+			},
+		},
+		{
+			name: "healthy",
+			setup: func(tb testing.TB, db *database.Database) {
+				if _, err := db.RotateTokenSigningKey(ctx, keyManagerSigner, parent, database.SystemTest); err != nil {
+					tb.Fatal(err)
+				}
+			},
+			keyManager: keyManager,
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			db, _ := testDatabaseInstance.NewDatabase(t, nil)
+			if tc.setup != nil {
+				tc.setup(t, db)
+			}
+
+			d := &doctor{
+				config:          testDoctorConfig(),
+				db:              db,
+				tokenKeyManager: tc.keyManager,
+				now:             time.Now().UTC(),
+			}
+			d.checkTokenSigningKey(ctx)
+			checkFindings(t, d.findings, tc.want)
+		})
+	}
+}
+
+func TestDoctor_CheckRealm(t *testing.T) {
+	t.Parallel()
+
+	ctx := project.TestContext(t)
+
+	// newRealm builds a realm that passes every check unless modified.
+	newRealm := func() *database.Realm {
+		realm := database.NewRealmWithDefaults(fmt.Sprintf("realm-%d", time.Now().UnixNano()))
+		realm.CertificateIssuer = "iss"
+		realm.CertificateAudience = "aud"
+		realm.CertificateDuration = database.FromDuration(time.Minute)
+		return realm
+	}
+
+	cases := []struct {
+		name           string
+		setup          func(tb testing.TB, db *database.Database) *database.Realm
+		maxKeyVersions int64
+		unusableKeys   bool
+		want           []*wantFinding
+	}{
+		{
+			name: "healthy",
+			setup: func(tb testing.TB, db *database.Database) *database.Realm {
+				realm := newRealm()
+				realm.UseRealmCertificateKey = true
+				realm.AutoRotateCertificateKey = true
+				saveRealm(tb, db, realm)
+				if _, err := realm.CreateSigningKeyVersion(ctx, db, database.SystemTest); err != nil {
+					tb.Fatal(err)
+				}
+				return realm
+			},
+		},
+		{
+			name: "certificate_key_missing",
+			setup: func(tb testing.TB, db *database.Database) *database.Realm {
+				realm := newRealm()
+				realm.UseRealmCertificateKey = true
+				saveRealm(tb, db, realm)
+				return realm
+			},
+			want: []*wantFinding{
+				{severityCritical, "certificate-signing-key", "no active signing key"},
+			},
+		},
+		{
+			name: "certificate_key_unusable",
+			setup: func(tb testing.TB, db *database.Database) *database.Realm {
+				realm := newRealm()
+				realm.UseRealmCertificateKey = true
+				saveRealm(tb, db, realm)
+				if _, err := realm.CreateSigningKeyVersion(ctx, db, database.SystemTest); err != nil {
+					tb.Fatal(err)
+				}
+				return realm
+			},
+			unusableKeys: true,
+			want: []*wantFinding{
+				{severityCritical, "certificate-signing-key", "is not usable"},
+			},
+		},
+		{
+			name: "certificate_key_stale",
+			setup: func(tb testing.TB, db *database.Database) *database.Realm {
+				realm := newRealm()
+				realm.UseRealmCertificateKey = true
+				realm.AutoRotateCertificateKey = true
+				saveRealm(tb, db, realm)
+				if _, err := realm.CreateSigningKeyVersion(ctx, db, database.SystemTest); err != nil {
+					tb.Fatal(err)
+				}
+				if err := db.RawDB().
+					Model(&database.SigningKey{}).
+					Where("realm_id = ?", realm.ID).
+					UpdateColumns(&database.SigningKey{
+						Model: gorm.Model{CreatedAt: time.Now().UTC().Add(-24 * time.Hour)},
+					}).Error; err != nil {
+					tb.Fatal(err)
+				}
+				return realm
+			},
+			want: []*wantFinding{
+				{severityWarning, "certificate-signing-key", "rotation may not be running"},
+			},
+		},
+		{
+			name: "certificate_key_at_max",
+			setup: func(tb testing.TB, db *database.Database) *database.Realm {
+				realm := newRealm()
+				realm.UseRealmCertificateKey = true
+				saveRealm(tb, db, realm)
+				if _, err := realm.CreateSigningKeyVersion(ctx, db, database.SystemTest); err != nil {
+					tb.Fatal(err)
+				}
+				return realm
+			},
+			maxKeyVersions: 1,
+			want: []*wantFinding{
+				{severityWarning, "certificate-signing-key", "new keys cannot be created"},
+			},
+		},
+		{
+			name: "certificate_key_over_max",
+			setup: func(tb testing.TB, db *database.Database) *database.Realm {
+				realm := newRealm()
+				realm.UseRealmCertificateKey = true
+				saveRealm(tb, db, realm)
+				for i := 0; i < 2; i++ {
+					if _, err := realm.CreateSigningKeyVersion(ctx, db, database.SystemTest); err != nil {
+						tb.Fatal(err)
+					}
+				}
+				return realm
+			},
+			maxKeyVersions: 1,
+			want: []*wantFinding{
+				{severityCritical, "certificate-signing-key", "exceeds the maximum"},
+			},
+		},
+		{
+			name: "sms_key_missing",
+			setup: func(tb testing.TB, db *database.Database) *database.Realm {
+				realm := newRealm()
+				realm.UseAuthenticatedSMS = true
+				saveRealm(tb, db, realm)
+				return realm
+			},
+			want: []*wantFinding{
+				{severityCritical, "sms-signing-key", "no active signing key"},
+			},
+		},
+		{
+			name: "sms_key_unusable",
+			setup: func(tb testing.TB, db *database.Database) *database.Realm {
+				realm := newRealm()
+				realm.UseAuthenticatedSMS = true
+				saveRealm(tb, db, realm)
+				if _, err := realm.CreateSMSSigningKeyVersion(ctx, db, database.SystemTest); err != nil {
+					tb.Fatal(err)
+				}
+				return realm
+			},
+			unusableKeys: true,
+			want: []*wantFinding{
+				{severityCritical, "sms-signing-key", "is not usable"},
+			},
+		},
+		{
+			name: "user_report_without_sms",
+			setup: func(tb testing.TB, db *database.Database) *database.Realm {
+				realm := newRealm()
+				realm.AddUserReportToAllowedTestTypes()
+				realm.SMSCountry = "us"
+				saveRealm(tb, db, realm)
+				return realm
+			},
+			want: []*wantFinding{
+				{severityCritical, "user-report-sms", "there is no SMS configuration"},
+			},
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			db, dbConfig := testDatabaseInstance.NewDatabase(t, nil)
+			realm := tc.setup(t, db)
+
+			// Lower the maximum after setup so the realm can be given more keys than
+			// are allowed.
+			if tc.maxKeyVersions > 0 {
+				dbConfig.MaxKeyVersions = tc.maxKeyVersions
+			}
+
+			var realmKeyManager keys.KeyManager = db.KeyManager()
+			if tc.unusableKeys {
+				realmKeyManager = &unusableKeyManager{realmKeyManager}
+			}
+
+			d := &doctor{
+				config:          testDoctorConfig(),
+				db:              db,
+				realmKeyManager: realmKeyManager,
+				now:             time.Now().UTC(),
+			}
+			d.checkRealm(ctx, realm)
+			checkFindings(t, d.findings, tc.want)
+		})
+	}
+}
+
+func TestDoctor_Run(t *testing.T) {
+	t.Parallel()
+
+	ctx := project.TestContext(t)
+	db, _ := testDatabaseInstance.NewDatabase(t, nil)
+
+	realm := database.NewRealmWithDefaults("unhealthy")
+	realm.UseAuthenticatedSMS = true
+	saveRealm(t, db, realm)
+
+	d := &doctor{
+		config:          testDoctorConfig(),
+		db:              db,
+		tokenKeyManager: db.KeyManager(),
+		realmKeyManager: db.KeyManager(),
+	}
+	d.run(ctx)
+
+	// A failing check must not stop the remaining checks from running.
+	var sawToken, sawRealm bool
+	for _, f := range d.findings {
+		switch f.check {
+		case "token-signing-key":
+			sawToken = true
+		case "sms-signing-key":
+			if strings.Contains(f.subject, "unhealthy") {
+				sawRealm = true
+			}
+		}
+	}
+	if !sawToken {
+		t.Errorf("expected a token-signing-key finding")
+	}
+	if !sawRealm {
+		t.Errorf("expected an sms-signing-key finding for the realm")
+	}
+	if !d.exceeds(severityCritical) {
+		t.Errorf("expected critical findings")
+	}
+}
+
+func saveRealm(tb testing.TB, db *database.Database, realm *database.Realm) {
+	tb.Helper()
+
+	if err := db.SaveRealm(realm, database.SystemTest); err != nil {
+		tb.Fatal(err)
+	}
+}
@@ -0,0 +1,105 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// A binary for checking deployment-wide configuration invariants. It connects
+// to the database and key manager, runs a series of read-only checks, and
+// prints a report ordered by severity.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/google/exposure-notifications-verification-server/internal/buildinfo"
+	"github.com/google/exposure-notifications-verification-server/pkg/config"
+	"github.com/google/exposure-notifications-verification-server/pkg/workloadidentity"
+
+	"github.com/google/exposure-notifications-server/pkg/logging"
+
+	_ "github.com/jinzhu/gorm/dialects/postgres"
+)
+
+var failOnFlag = flag.String("fail-on", "critical", "minimum severity (warning or critical) that causes a non-zero exit")
+
+func main() {
+	flag.Parse()
+
+	ctx, done := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+
+	logger := logging.NewLoggerFromEnv().
+		With("build_id", buildinfo.BuildID).
+		With("build_tag", buildinfo.BuildTag)
+	ctx = logging.WithLogger(ctx, logger)
+
+	defer func() {
+		done()
+		if r := recover(); r != nil {
+			logger.Fatalw("application panic", "panic", r)
+		}
+	}()
+
+	err := realMain(ctx)
+	done()
+
+	if err != nil {
+		logger.Fatal(err)
+	}
+}
+
+func realMain(ctx context.Context) error {
+	failOn, err := parseSeverity(*failOnFlag)
+	if err != nil {
+		return fmt.Errorf("invalid -fail-on: %w", err)
+	}
+
+	cfg, err := config.NewDoctorConfig(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to process config: %w", err)
+	}
+
+	db, err := cfg.Database.Load(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to load database config: %w", err)
+	}
+	if err := db.Open(ctx); err != nil {
+		return fmt.Errorf("failed to connect to database: %w", err)
+	}
+	defer db.Close()
+
+	tokenKeyManager, err := workloadidentity.KeyManagerFor(ctx, &cfg.WorkloadIdentity, &cfg.TokenSigning.Keys)
+	if err != nil {
+		return fmt.Errorf("failed to get token signing key manager: %w", err)
+	}
+
+	d := &doctor{
+		config:          cfg,
+		db:              db,
+		tokenKeyManager: tokenKeyManager,
+		realmKeyManager: db.KeyManager(),
+	}
+	d.run(ctx)
+
+	if err := d.writeReport(os.Stdout); err != nil {
+		return fmt.Errorf("failed to write report: %w", err)
+	}
+
+	if d.exceeds(failOn) {
+		return fmt.Errorf("found problems at or above %s severity", failOn)
+	}
+	return nil
+}
@@ -25,7 +25,6 @@ import (
 	"time"
 
 	"github.com/google/exposure-notifications-server/pkg/logging"
-	"github.com/google/exposure-notifications-server/pkg/observability"
 	"github.com/google/exposure-notifications-server/pkg/server"
 
 	"github.com/google/exposure-notifications-verification-server/internal/buildinfo"
@@ -35,6 +34,7 @@ import (
 	"github.com/google/exposure-notifications-verification-server/pkg/config"
 	"github.com/google/exposure-notifications-verification-server/pkg/controller/e2erunner"
 	"github.com/google/exposure-notifications-verification-server/pkg/controller/middleware"
+	"github.com/google/exposure-notifications-verification-server/pkg/observability"
 	"github.com/google/exposure-notifications-verification-server/pkg/render"
 
 	"github.com/gorilla/handlers"
@@ -75,17 +75,12 @@ func realMain(ctx context.Context) error {
 	}
 
 	// Setup monitoring
-	logger.Info("configuring observability exporter")
-	oe, err := observability.NewFromEnv(ctx, cfg.Observability)
+	stopObservability, err := observability.Setup(ctx, cfg.Observability, cfg.Prometheus.Port)
 	if err != nil {
-		return fmt.Errorf("unable to create ObservabilityExporter provider: %w", err)
+		return fmt.Errorf("failed to setup observability: %w", err)
 	}
-	if err := oe.StartExporter(); err != nil {
-		return fmt.Errorf("error initializing observability exporter: %w", err)
-	}
-	defer oe.Close()
+	defer stopObservability()
 	ctx, obs := middleware.WithObservability(ctx)
-	logger.Infow("observability exporter", "config", cfg.Observability)
 
 	db, err := cfg.Database.Load(ctx)
 	if err != nil {
@@ -165,6 +160,7 @@ func realMain(ctx context.Context) error {
 	r.Handle("/revise", e2erunnerController.HandleRevise())
 	r.Handle("/user-report", e2erunnerController.HandleUserReport())
 	r.Handle("/enx-redirect", e2erunnerController.HandleENXRedirect())
+	r.Handle("/app-links", e2erunnerController.HandleAppLinks())
 
 	mux := http.Handler(r)
 	if cfg.DevMode {
@@ -27,10 +27,10 @@ import (
 	"github.com/google/exposure-notifications-verification-server/pkg/config"
 	"github.com/google/exposure-notifications-verification-server/pkg/controller/emailer"
 	"github.com/google/exposure-notifications-verification-server/pkg/controller/middleware"
+	"github.com/google/exposure-notifications-verification-server/pkg/observability"
 	"github.com/google/exposure-notifications-verification-server/pkg/render"
 
 	"github.com/google/exposure-notifications-server/pkg/logging"
-	"github.com/google/exposure-notifications-server/pkg/observability"
 	"github.com/google/exposure-notifications-server/pkg/server"
 
 	"github.com/gorilla/mux"
@@ -69,18 +69,12 @@ func realMain(ctx context.Context) error {
 	}
 
 	// Setup monitoring
-	logger.Info("configuring observability exporter")
-	oeConfig := cfg.ObservabilityExporterConfig()
-	oe, err := observability.NewFromEnv(ctx, oeConfig)
+	stopObservability, err := observability.Setup(ctx, cfg.ObservabilityExporterConfig(), cfg.Prometheus.Port)
 	if err != nil {
-		return fmt.Errorf("unable to create ObservabilityExporter provider: %w", err)
+		return fmt.Errorf("failed to setup observability: %w", err)
 	}
-	if err := oe.StartExporter(); err != nil {
-		return fmt.Errorf("error initializing observability exporter: %w", err)
-	}
-	defer oe.Close()
+	defer stopObservability()
 	ctx, obs := middleware.WithObservability(ctx)
-	logger.Infow("observability exporter", "config", oeConfig)
 
 	// Setup database
 	db, err := cfg.Database.Load(ctx)
@@ -92,6 +86,11 @@ func realMain(ctx context.Context) error {
 	}
 	defer db.Close()
 
+	// Record the build, so deploys appear on the system event timeline.
+	if err := db.RecordBuildSeen("emailer", buildinfo.BuildID, buildinfo.BuildTag); err != nil {
+		logger.Errorw("failed to record build", "error", err)
+	}
+
 	// Create the router
 	r := mux.NewRouter()
 
@@ -130,6 +129,10 @@ func realMain(ctx context.Context) error {
 	emailerController := emailer.New(cfg, db, h)
 	r.Handle("/anomalies", emailerController.HandleAnomalies()).Methods(http.MethodGet)
 	r.Handle("/sms-errors", emailerController.HandleSMSErrors()).Methods(http.MethodGet)
+	r.Handle("/sms-from-number-changes", emailerController.HandleSMSFromNumberChanges()).Methods(http.MethodGet)
+	r.Handle("/inactive-api-keys", emailerController.HandleInactiveAPIKeys()).Methods(http.MethodGet)
+	r.Handle("/realm-notifications", emailerController.HandleRealmNotifications()).Methods(http.MethodGet)
+	r.Handle("/unclaimed-codes", emailerController.HandleUnclaimedCodes()).Methods(http.MethodGet)
 
 	srv, err := server.New(cfg.Port)
 	if err != nil {
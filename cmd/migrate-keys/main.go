@@ -0,0 +1,158 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// A binary for moving realm signing keys from one key manager to another, for
+// example from filesystem development keys to Cloud KMS. For each realm, it
+// creates a new key version in the target key manager, makes it active, and
+// marks the old versions for destruction after a grace period. Old versions
+// stay published in the realm's JWKS until they are destroyed. Run it again
+// with -destroy after the grace period to destroy the old versions in the
+// source key manager.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/google/exposure-notifications-verification-server/internal/buildinfo"
+	"github.com/google/exposure-notifications-verification-server/pkg/config"
+	"github.com/google/exposure-notifications-verification-server/pkg/database"
+	"github.com/google/exposure-notifications-verification-server/pkg/pagination"
+	"github.com/google/exposure-notifications-verification-server/pkg/workloadidentity"
+
+	"github.com/google/exposure-notifications-server/pkg/keys"
+	"github.com/google/exposure-notifications-server/pkg/logging"
+
+	_ "github.com/jinzhu/gorm/dialects/postgres"
+)
+
+var (
+	realmFlag   = flag.Uint("realm", 0, "ID of the realm to migrate, defaults to all realms")
+	destroyFlag = flag.Bool("destroy", false, "if true, destroys migrated key versions whose grace period has passed instead of migrating")
+)
+
+func main() {
+	flag.Parse()
+
+	ctx, done := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+
+	logger := logging.NewLoggerFromEnv().
+		With("build_id", buildinfo.BuildID).
+		With("build_tag", buildinfo.BuildTag)
+	ctx = logging.WithLogger(ctx, logger)
+
+	defer func() {
+		done()
+		if r := recover(); r != nil {
+			logger.Fatalw("application panic", "panic", r)
+		}
+	}()
+
+	err := realMain(ctx)
+	done()
+
+	if err != nil {
+		logger.Fatal(err)
+	}
+}
+
+func realMain(ctx context.Context) error {
+	cfg, err := config.NewMigrateKeysConfig(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to process config: %w", err)
+	}
+
+	db, err := cfg.Database.Load(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to load database config: %w", err)
+	}
+	if err := db.Open(ctx); err != nil {
+		return fmt.Errorf("failed to connect to database: %w", err)
+	}
+	defer db.Close()
+
+	source, err := workloadidentity.KeyManagerFor(ctx, &cfg.WorkloadIdentity, &cfg.SourceKeys)
+	if err != nil {
+		return fmt.Errorf("failed to get source key manager: %w", err)
+	}
+
+	if *destroyFlag {
+		destroyer, ok := source.(keys.KeyVersionDestroyer)
+		if !ok {
+			return fmt.Errorf("source key manager cannot destroy key versions (is %T)", source)
+		}
+
+		destroyed, err := db.DestroyMigratedSigningKeys(ctx, destroyer, database.System)
+		if err != nil {
+			return fmt.Errorf("failed to destroy migrated signing keys: %w", err)
+		}
+		fmt.Fprintf(os.Stdout, "destroyed %d migrated key versions\n", destroyed)
+		return nil
+	}
+
+	var realms []*database.Realm
+	if id := *realmFlag; id != 0 {
+		realm, err := db.FindRealm(id)
+		if err != nil {
+			return fmt.Errorf("failed to find realm %d: %w", id, err)
+		}
+		realms = append(realms, realm)
+	} else {
+		realms, _, err = db.ListRealms(pagination.UnlimitedResults)
+		if err != nil {
+			return fmt.Errorf("failed to list realms: %w", err)
+		}
+	}
+
+	return migrate(ctx, db, source, cfg, realms)
+}
+
+// migrate moves the certificate and SMS signing keys of each realm and prints
+// the results.
+func migrate(ctx context.Context, db *database.Database, source keys.KeyManager, cfg *config.MigrateKeysConfig, realms []*database.Realm) error {
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "REALM\tPURPOSE\tMIGRATED VERSIONS\tNEW ACTIVE KEY")
+
+	for _, realm := range realms {
+		for _, m := range []struct {
+			purpose string
+			fn      func(context.Context, *database.Database, keys.KeyManager, time.Duration, database.Auditable) (string, int, error)
+		}{
+			{"certificate", realm.MigrateSigningKeys},
+			{"sms", realm.MigrateSMSSigningKeys},
+		} {
+			kid, migrated, err := m.fn(ctx, db, source, cfg.GracePeriod, database.System)
+			if err != nil {
+				if errors.Is(err, database.ErrNoSigningKeysToMigrate) {
+					continue
+				}
+				_ = w.Flush()
+				return fmt.Errorf("failed to migrate %s keys for realm %d: %w", m.purpose, realm.ID, err)
+			}
+			fmt.Fprintf(w, "%d\t%s\t%d\t%s\n", realm.ID, m.purpose, migrated, kid)
+		}
+	}
+
+	if err := w.Flush(); err != nil {
+		return fmt.Errorf("failed to write results: %w", err)
+	}
+	return nil
+}
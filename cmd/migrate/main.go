@@ -17,10 +17,15 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"flag"
 	"fmt"
+	"os"
 	"os/signal"
+	"sort"
+	"strconv"
 	"syscall"
+	"text/tabwriter"
 
 	"github.com/google/exposure-notifications-verification-server/internal/buildinfo"
 	"github.com/google/exposure-notifications-verification-server/pkg/config"
@@ -35,6 +40,13 @@ import (
 var (
 	targetFlag   = flag.String("id", "", "migration ID to move to")
 	rollbackFlag = flag.Bool("rollback", false, "if true, will run a rollback migration towards the target")
+
+	verifyDualWriteFlag = flag.Bool("verify-dual-write", false, "if true, compares the primary and dual-write secondary databases instead of running migrations")
+
+	legacyPlanFlag    = flag.Bool("legacy-plan", false, "if true, prints the migration status and the stages of a legacy upgrade instead of running migrations")
+	legacyUpgradeFlag = flag.Bool("legacy-upgrade", false, "if true, runs migrations stage by stage, verifying each stage and stopping on the first problem")
+	legacyStageFlag   = flag.String("legacy-stage", "", "name of the last stage to run with -legacy-upgrade, defaults to all stages")
+	legacyReportFlag  = flag.String("legacy-report", "", "path to write a JSON report of the stages run with -legacy-upgrade")
 )
 
 func main() {
@@ -77,9 +89,203 @@ func realMain(ctx context.Context) error {
 	}
 	defer db.Close()
 
+	if *verifyDualWriteFlag {
+		return verifyDualWrite(ctx, db)
+	}
+
+	if *legacyPlanFlag {
+		return legacyPlan(ctx, db)
+	}
+
+	if *legacyUpgradeFlag {
+		return legacyUpgrade(ctx, db, *legacyStageFlag, *legacyReportFlag)
+	}
+
 	if err := db.MigrateTo(ctx, *targetFlag, *rollbackFlag); err != nil {
 		return fmt.Errorf("failed to run migrations: %w", err)
 	}
 
 	return nil
 }
+
+// verifyDualWrite compares every table in the primary and secondary databases,
+// prints the results, and returns an error if any table differs.
+func verifyDualWrite(ctx context.Context, db *database.Database) error {
+	results, err := db.VerifyDualWrite(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to verify dual-write: %w", err)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "TABLE\tPRIMARY ROWS\tSECONDARY ROWS\tSTATUS")
+
+	var diverged int
+	for _, result := range results {
+		status := "ok"
+		if result.Diverged() {
+			status = "DIVERGED"
+			diverged++
+		}
+		fmt.Fprintf(w, "%s\t%d\t%d\t%s\n", result.Table, result.PrimaryRows, result.SecondaryRows, status)
+	}
+	if err := w.Flush(); err != nil {
+		return fmt.Errorf("failed to write results: %w", err)
+	}
+
+	if diverged > 0 {
+		return fmt.Errorf("%d of %d tables diverged", diverged, len(results))
+	}
+	return nil
+}
+
+// legacyPlan prints the current migration status and whether each stage of a
+// legacy upgrade has been applied.
+func legacyPlan(ctx context.Context, db *database.Database) error {
+	snapshot, err := db.TakeLegacyUpgradeSnapshot(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to inspect database: %w", err)
+	}
+
+	last := snapshot.LastMigration
+	if last == "" {
+		last = "(none)"
+	}
+	fmt.Printf("last migration:     %s\n", last)
+	fmt.Printf("pending migrations: %d\n", snapshot.Pending)
+	fmt.Printf("legacy memberships: %d\n\n", len(snapshot.LegacyMemberships))
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "STAGE\tTARGET\tSTATUS\tDESCRIPTION")
+	for _, stage := range database.LegacyUpgradeStages {
+		target := stage.Target
+		if target == "" {
+			target = "(latest)"
+		}
+		status := "pending"
+		if snapshot.StageComplete(stage) {
+			status = "done"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", stage.Name, target, status, stage.Description)
+	}
+	if err := w.Flush(); err != nil {
+		return fmt.Errorf("failed to write plan: %w", err)
+	}
+	return nil
+}
+
+// legacyUpgrade runs each pending stage of a legacy upgrade up to and including
+// the named stage, printing the verification results after each one. It stops
+// at the first stage with findings. If reportPath is given, the checkpoints of
+// every stage that ran are written there as JSON, including on failure.
+func legacyUpgrade(ctx context.Context, db *database.Database, lastStage, reportPath string) (retErr error) {
+	if lastStage != "" && database.FindLegacyUpgradeStage(lastStage) == nil {
+		return fmt.Errorf("unknown stage %q", lastStage)
+	}
+
+	var checkpoints []*database.LegacyUpgradeCheckpoint
+	if reportPath != "" {
+		defer func() {
+			if err := writeLegacyReport(reportPath, checkpoints); err != nil && retErr == nil {
+				retErr = err
+			}
+		}()
+	}
+
+	for _, stage := range database.LegacyUpgradeStages {
+		snapshot, err := db.TakeLegacyUpgradeSnapshot(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to inspect database: %w", err)
+		}
+
+		if !snapshot.StageComplete(stage) {
+			fmt.Printf("==> stage %s: %s\n", stage.Name, stage.Description)
+
+			checkpoint, err := db.RunLegacyUpgradeStage(ctx, stage)
+			if err != nil {
+				return err
+			}
+			checkpoints = append(checkpoints, checkpoint)
+
+			if err := printLegacyCheckpoint(checkpoint); err != nil {
+				return err
+			}
+			if n := len(checkpoint.Findings); n > 0 {
+				return fmt.Errorf("stage %s has %d findings, roll back to %q or fix the data before continuing",
+					stage.Name, n, checkpoint.RollbackTo)
+			}
+		}
+
+		if stage.Name == lastStage {
+			break
+		}
+	}
+	return nil
+}
+
+// printLegacyCheckpoint prints the row counts and findings of a completed
+// stage.
+func printLegacyCheckpoint(checkpoint *database.LegacyUpgradeCheckpoint) error {
+	rollbackTo := checkpoint.RollbackTo
+	if rollbackTo == "" {
+		rollbackTo = "(none)"
+	}
+	fmt.Printf("rollback point: %s\n", rollbackTo)
+	for _, backup := range checkpoint.Backups {
+		fmt.Printf("backup table:   %s\n", backup)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "TABLE\tBEFORE\tAFTER")
+	for _, table := range countedTables(checkpoint.Before, checkpoint.After) {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", table,
+			formatCount(checkpoint.Before, table), formatCount(checkpoint.After, table))
+	}
+	for _, finding := range checkpoint.Findings {
+		fmt.Fprintf(w, "FINDING\t%s\t%s\n", finding.Check, finding.Message)
+	}
+	if err := w.Flush(); err != nil {
+		return fmt.Errorf("failed to write results: %w", err)
+	}
+	fmt.Println()
+	return nil
+}
+
+// countedTables returns the sorted names of the tables counted in either
+// snapshot.
+func countedTables(snapshots ...*database.LegacyUpgradeSnapshot) []string {
+	seen := make(map[string]struct{})
+	for _, s := range snapshots {
+		for table := range s.Counts {
+			seen[table] = struct{}{}
+		}
+	}
+
+	tables := make([]string, 0, len(seen))
+	for table := range seen {
+		tables = append(tables, table)
+	}
+	sort.Strings(tables)
+	return tables
+}
+
+// formatCount returns the row count of the table in the snapshot, or "-" if the
+// table did not exist.
+func formatCount(s *database.LegacyUpgradeSnapshot, table string) string {
+	count, ok := s.Counts[table]
+	if !ok {
+		return "-"
+	}
+	return strconv.FormatInt(count, 10)
+}
+
+// writeLegacyReport writes the checkpoints as JSON to the given path.
+func writeLegacyReport(pth string, checkpoints []*database.LegacyUpgradeCheckpoint) error {
+	b, err := json.MarshalIndent(checkpoints, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal report: %w", err)
+	}
+	if err := os.WriteFile(pth, b, 0o600); err != nil {
+		return fmt.Errorf("failed to write report: %w", err)
+	}
+	return nil
+}
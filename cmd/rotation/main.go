@@ -25,13 +25,15 @@ import (
 
 	"github.com/google/exposure-notifications-verification-server/internal/buildinfo"
 	"github.com/google/exposure-notifications-verification-server/pkg/config"
+	"github.com/google/exposure-notifications-verification-server/pkg/controller/jobstatus"
 	"github.com/google/exposure-notifications-verification-server/pkg/controller/middleware"
 	"github.com/google/exposure-notifications-verification-server/pkg/controller/rotation"
+	"github.com/google/exposure-notifications-verification-server/pkg/observability"
 	"github.com/google/exposure-notifications-verification-server/pkg/render"
+	"github.com/google/exposure-notifications-verification-server/pkg/workloadidentity"
 
 	"github.com/google/exposure-notifications-server/pkg/keys"
 	"github.com/google/exposure-notifications-server/pkg/logging"
-	"github.com/google/exposure-notifications-server/pkg/observability"
 	"github.com/google/exposure-notifications-server/pkg/secrets"
 	"github.com/google/exposure-notifications-server/pkg/server"
 
@@ -71,18 +73,12 @@ func realMain(ctx context.Context) error {
 	}
 
 	// Setup monitoring
-	logger.Info("configuring observability exporter")
-	oeConfig := cfg.ObservabilityExporterConfig()
-	oe, err := observability.NewFromEnv(ctx, oeConfig)
+	stopObservability, err := observability.Setup(ctx, cfg.ObservabilityExporterConfig(), cfg.Prometheus.Port)
 	if err != nil {
-		return fmt.Errorf("unable to create ObservabilityExporter provider: %w", err)
+		return fmt.Errorf("failed to setup observability: %w", err)
 	}
-	if err := oe.StartExporter(); err != nil {
-		return fmt.Errorf("error initializing observability exporter: %w", err)
-	}
-	defer oe.Close()
+	defer stopObservability()
 	ctx, obs := middleware.WithObservability(ctx)
-	logger.Infow("observability exporter", "config", oeConfig)
 
 	// Setup database
 	db, err := cfg.Database.Load(ctx)
@@ -94,6 +90,11 @@ func realMain(ctx context.Context) error {
 	}
 	defer db.Close()
 
+	// Record the build, so deploys appear on the system event timeline.
+	if err := db.RecordBuildSeen("rotation", buildinfo.BuildID, buildinfo.BuildTag); err != nil {
+		logger.Errorw("failed to record build", "error", err)
+	}
+
 	// Create the renderer
 	h, err := render.New(ctx, nil, cfg.DevMode)
 	if err != nil {
@@ -101,7 +102,7 @@ func realMain(ctx context.Context) error {
 	}
 
 	// Get token key manager.
-	tokenSigner, err := keys.KeyManagerFor(ctx, &cfg.TokenSigning.Keys)
+	tokenSigner, err := workloadidentity.KeyManagerFor(ctx, &cfg.WorkloadIdentity, &cfg.TokenSigning.Keys)
 	if err != nil {
 		return fmt.Errorf("failed to get token signing key manager: %w", err)
 	}
@@ -111,7 +112,7 @@ func realMain(ctx context.Context) error {
 	}
 
 	// Get secret manager.
-	secretManager, err := secrets.SecretManagerFor(ctx, &cfg.Secrets)
+	secretManager, err := workloadidentity.SecretManagerFor(ctx, &cfg.WorkloadIdentity, &cfg.Secrets)
 	if err != nil {
 		return fmt.Errorf("failed to get secret manager: %w", err)
 	}
@@ -142,10 +143,19 @@ func realMain(ctx context.Context) error {
 	recovery := middleware.Recovery(h)
 	r.Use(recovery)
 
+	// Worker authentication
+	requireWorkerAuth, err := middleware.RequireWorkerAuth(ctx, &cfg.WorkerAuth, h)
+	if err != nil {
+		return fmt.Errorf("failed to create worker auth middleware: %w", err)
+	}
+	r.Use(requireWorkerAuth)
+
 	rotationController := rotation.New(cfg, db, tokenSignerTyp, secretManagerTyp, h)
 	r.Handle("/token-signing-key", rotationController.HandleRotateTokenSigningKey()).Methods(http.MethodGet)
 	r.Handle("/realm-verification-keys", rotationController.HandleRotateVerificationKeys()).Methods(http.MethodGet)
 	r.Handle("/secrets", rotationController.HandleRotateSecrets()).Methods(http.MethodGet)
+	r.Handle("/cookie-keys", rotationController.HandleRotateCookieKeys()).Methods(http.MethodGet)
+	r.Handle("/status", jobstatus.HandleStatus(db, h, jobstatus.JobRotateTokenKeys, jobstatus.JobRotateVerificationKeys, jobstatus.JobRotateSecrets, jobstatus.JobRotateCookieKeys)).Methods(http.MethodGet)
 
 	srv, err := server.New(cfg.Port)
 	if err != nil {
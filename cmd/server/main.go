@@ -27,12 +27,12 @@ import (
 	"github.com/google/exposure-notifications-verification-server/internal/routes"
 	"github.com/google/exposure-notifications-verification-server/pkg/cache"
 	"github.com/google/exposure-notifications-verification-server/pkg/config"
+	"github.com/google/exposure-notifications-verification-server/pkg/observability"
 	"github.com/google/exposure-notifications-verification-server/pkg/ratelimit"
+	"github.com/google/exposure-notifications-verification-server/pkg/workloadidentity"
 	"github.com/gorilla/handlers"
 
-	"github.com/google/exposure-notifications-server/pkg/keys"
 	"github.com/google/exposure-notifications-server/pkg/logging"
-	"github.com/google/exposure-notifications-server/pkg/observability"
 	"github.com/google/exposure-notifications-server/pkg/server"
 )
 
@@ -67,19 +67,24 @@ func realMain(ctx context.Context) error {
 	if err != nil {
 		return fmt.Errorf("failed to process config: %w", err)
 	}
+	config.LogDevWarnings(ctx, &cfg.Dev, &cfg.Database)
 
-	// Setup monitoring
-	logger.Info("configuring observability exporter")
-	oeConfig := cfg.ObservabilityExporterConfig()
-	oe, err := observability.NewFromEnv(ctx, oeConfig)
+	// Verify data residency
+	residencyChecker, err := cfg.DataResidencyChecker()
 	if err != nil {
-		return fmt.Errorf("unable to create ObservabilityExporter provider: %w", err)
+		return fmt.Errorf("failed to create data residency checker: %w", err)
+	}
+	if err := residencyChecker.Check(ctx); err != nil {
+		return fmt.Errorf("failed data residency check: %w", err)
 	}
-	if err := oe.StartExporter(); err != nil {
-		return fmt.Errorf("error initializing observability exporter: %w", err)
+	go residencyChecker.Run(ctx)
+
+	// Setup monitoring
+	stopObservability, err := observability.Setup(ctx, cfg.ObservabilityExporterConfig(), cfg.Prometheus.Port)
+	if err != nil {
+		return fmt.Errorf("failed to setup observability: %w", err)
 	}
-	defer oe.Close()
-	logger.Infow("observability exporter", "config", oeConfig)
+	defer stopObservability()
 
 	// Setup cacher
 	cacher, err := cache.CacherFor(ctx, &cfg.Cache, cache.HMACKeyFunc(sha1.New, cfg.Cache.HMACKey))
@@ -98,12 +103,17 @@ func realMain(ctx context.Context) error {
 	}
 	defer db.Close()
 
+	// Record the build, so deploys appear on the system event timeline.
+	if err := db.RecordBuildSeen("server", buildinfo.BuildID, buildinfo.BuildTag); err != nil {
+		logger.Errorw("failed to record build", "error", err)
+	}
+
 	// Setup signers
-	certificateSigner, err := keys.KeyManagerFor(ctx, &cfg.CertificateSigning.Keys)
+	certificateSigner, err := workloadidentity.KeyManagerFor(ctx, &cfg.WorkloadIdentity, &cfg.CertificateSigning.Keys)
 	if err != nil {
 		return fmt.Errorf("failed to create certificate key manager: %w", err)
 	}
-	smsSigner, err := keys.KeyManagerFor(ctx, &cfg.SMSSigning.Keys)
+	smsSigner, err := workloadidentity.KeyManagerFor(ctx, &cfg.WorkloadIdentity, &cfg.SMSSigning.Keys)
 	if err != nil {
 		return fmt.Errorf("failed to create sms key manager: %w", err)
 	}
@@ -116,7 +126,11 @@ func realMain(ctx context.Context) error {
 	defer limiterStore.Close(ctx)
 
 	// Setup auth provider
-	authProvider, err := auth.NewFirebase(ctx, cfg.FirebaseConfig())
+	var authOpts []auth.FirebaseOption
+	if cfg.Dev.SkipFirebaseVerification {
+		authOpts = append(authOpts, auth.WithSkipVerification())
+	}
+	authProvider, err := auth.NewFirebase(ctx, cfg.FirebaseConfig(), authOpts...)
 	if err != nil {
 		return fmt.Errorf("failed to create firebase auth provider: %w", err)
 	}
@@ -12,8 +12,9 @@
 // See the License for the specific language governing permissions and
 // limitations under the License.
 
-// This server implements the key-server statistics puller. The server itself is unauthenticated
-// and should not be deployed as a public service.
+// This server implements the key-server statistics puller and the national
+// statistics pusher. The server itself is unauthenticated and should not be
+// deployed as a public service.
 package main
 
 import (
@@ -26,13 +27,15 @@ import (
 	"github.com/google/exposure-notifications-verification-server/internal/buildinfo"
 	"github.com/google/exposure-notifications-verification-server/internal/clients"
 	"github.com/google/exposure-notifications-verification-server/pkg/config"
+	"github.com/google/exposure-notifications-verification-server/pkg/controller/jobstatus"
 	"github.com/google/exposure-notifications-verification-server/pkg/controller/middleware"
 	"github.com/google/exposure-notifications-verification-server/pkg/controller/statspuller"
+	"github.com/google/exposure-notifications-verification-server/pkg/controller/statspusher"
+	"github.com/google/exposure-notifications-verification-server/pkg/observability"
 	"github.com/google/exposure-notifications-verification-server/pkg/render"
+	"github.com/google/exposure-notifications-verification-server/pkg/workloadidentity"
 
-	"github.com/google/exposure-notifications-server/pkg/keys"
 	"github.com/google/exposure-notifications-server/pkg/logging"
-	"github.com/google/exposure-notifications-server/pkg/observability"
 	"github.com/google/exposure-notifications-server/pkg/server"
 
 	"github.com/gorilla/mux"
@@ -71,18 +74,12 @@ func realMain(ctx context.Context) error {
 	}
 
 	// Setup monitoring
-	logger.Info("configuring observability exporter")
-	oeConfig := cfg.ObservabilityExporterConfig()
-	oe, err := observability.NewFromEnv(ctx, oeConfig)
+	stopObservability, err := observability.Setup(ctx, cfg.ObservabilityExporterConfig(), cfg.Prometheus.Port)
 	if err != nil {
-		return fmt.Errorf("unable to create ObservabilityExporter provider: %w", err)
+		return fmt.Errorf("failed to setup observability: %w", err)
 	}
-	if err := oe.StartExporter(); err != nil {
-		return fmt.Errorf("error initializing observability exporter: %w", err)
-	}
-	defer oe.Close()
+	defer stopObservability()
 	ctx, obs := middleware.WithObservability(ctx)
-	logger.Infow("observability exporter", "config", oeConfig)
 
 	// Setup database
 	db, err := cfg.Database.Load(ctx)
@@ -94,6 +91,11 @@ func realMain(ctx context.Context) error {
 	}
 	defer db.Close()
 
+	// Record the build, so deploys appear on the system event timeline.
+	if err := db.RecordBuildSeen("stats-puller", buildinfo.BuildID, buildinfo.BuildTag); err != nil {
+		logger.Errorw("failed to record build", "error", err)
+	}
+
 	// Create the renderer
 	h, err := render.New(ctx, nil, cfg.DevMode)
 	if err != nil {
@@ -122,6 +124,13 @@ func realMain(ctx context.Context) error {
 	recovery := middleware.Recovery(h)
 	r.Use(recovery)
 
+	// Worker authentication
+	requireWorkerAuth, err := middleware.RequireWorkerAuth(ctx, &cfg.WorkerAuth, h)
+	if err != nil {
+		return fmt.Errorf("failed to create worker auth middleware: %w", err)
+	}
+	r.Use(requireWorkerAuth)
+
 	client, err := clients.NewKeyServerClient(cfg.KeyServerURL,
 		clients.WithTimeout(cfg.DownloadTimeout),
 		clients.WithMaxBodySize(cfg.FileSizeLimitBytes))
@@ -129,7 +138,7 @@ func realMain(ctx context.Context) error {
 		return fmt.Errorf("failed to create key server client: %w", err)
 	}
 
-	certificateSigner, err := keys.KeyManagerFor(ctx, &cfg.CertificateSigning.Keys)
+	certificateSigner, err := workloadidentity.KeyManagerFor(ctx, &cfg.WorkloadIdentity, &cfg.CertificateSigning.Keys)
 	if err != nil {
 		return fmt.Errorf("failed to create certificate key manager: %w", err)
 	}
@@ -140,6 +149,11 @@ func realMain(ctx context.Context) error {
 	}
 	r.Handle("/", statsController.HandlePullStats()).Methods(http.MethodGet)
 
+	statsPushController := statspusher.New(&cfg.StatsPush, db, h)
+	r.Handle("/push", statsPushController.HandlePush()).Methods(http.MethodGet)
+
+	r.Handle("/status", jobstatus.HandleStatus(db, h, jobstatus.JobStatsPuller, jobstatus.JobStatsPusher)).Methods(http.MethodGet)
+
 	srv, err := server.New(cfg.Port)
 	if err != nil {
 		return fmt.Errorf("failed to create server: %w", err)
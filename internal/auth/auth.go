@@ -86,6 +86,11 @@ type Provider interface {
 
 	// MFAEnabled returns true if MFA is enabled, false otherwise.
 	MFAEnabled(context.Context, *sessions.Session) (bool, error)
+
+	// AuthenticatedAt returns the time at which the user last signed in or
+	// re-authenticated with their credentials. It returns an error if the
+	// session does not exist.
+	AuthenticatedAt(context.Context, *sessions.Session) (time.Time, error)
 }
 
 // SessionInfo is a generic struct used to store session information. Not all
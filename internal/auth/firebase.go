@@ -16,7 +16,10 @@ package auth
 
 import (
 	"context"
+	"encoding/base64"
+	"encoding/json"
 	"fmt"
+	"strings"
 	"time"
 
 	firebaseinternal "github.com/google/exposure-notifications-verification-server/internal/firebase"
@@ -34,10 +37,26 @@ const (
 type firebaseAuth struct {
 	firebaseAuth     *auth.Client
 	firebaseInternal *firebaseinternal.Client
+
+	skipVerification bool
+}
+
+// FirebaseOption is an option for the firebase auth provider.
+type FirebaseOption func(f *firebaseAuth)
+
+// WithSkipVerification accepts ID tokens without verifying their signature,
+// and stores the ID token in the session instead of exchanging it for a
+// session cookie. Revocation is not checked. This is only for local
+// development, where Google credentials or the Firebase Auth emulator's
+// unsigned tokens are used.
+func WithSkipVerification() FirebaseOption {
+	return func(f *firebaseAuth) {
+		f.skipVerification = true
+	}
 }
 
 // NewFirebase creates a new auth provider for firebase.
-func NewFirebase(ctx context.Context, config *firebase.Config) (Provider, error) {
+func NewFirebase(ctx context.Context, config *firebase.Config, opts ...FirebaseOption) (Provider, error) {
 	app, err := firebase.NewApp(ctx, config)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create firebase app: %w", err)
@@ -53,10 +72,14 @@ func NewFirebase(ctx context.Context, config *firebase.Config) (Provider, error)
 		return nil, fmt.Errorf("failed to configure firebase client: %w", err)
 	}
 
-	return &firebaseAuth{
+	f := &firebaseAuth{
 		firebaseAuth:     auth,
 		firebaseInternal: internal,
-	}, nil
+	}
+	for _, opt := range opts {
+		opt(f)
+	}
+	return f, nil
 }
 
 // CheckRevoked checks if the users auth has been revoked.
@@ -73,6 +96,14 @@ func (f *firebaseAuth) CheckRevoked(ctx context.Context, session *sessions.Sessi
 		return ErrSessionMissing
 	}
 
+	if f.skipVerification {
+		if _, err := parseUnverifiedToken(cookie); err != nil {
+			f.ClearSession(ctx, session)
+			return err
+		}
+		return nil
+	}
+
 	if _, err := f.firebaseAuth.VerifySessionCookieAndCheckRevoked(ctx, cookie); err != nil {
 		f.ClearSession(ctx, session)
 		return err
@@ -94,7 +125,7 @@ func (f *firebaseAuth) StoreSession(ctx context.Context, session *sessions.Sessi
 	}
 
 	// Verify ID token.
-	decoded, err := f.firebaseAuth.VerifyIDToken(ctx, idToken)
+	decoded, err := f.verifyIDToken(ctx, idToken)
 	if err != nil {
 		f.ClearSession(ctx, session)
 		return fmt.Errorf("invalid id token: %w", err)
@@ -105,11 +136,15 @@ func (f *firebaseAuth) StoreSession(ctx context.Context, session *sessions.Sessi
 		return fmt.Errorf("session expired")
 	}
 
-	// Convert ID token to long-lived cookie
-	cookie, err := f.firebaseAuth.SessionCookie(ctx, idToken, i.TTL)
-	if err != nil {
-		f.ClearSession(ctx, session)
-		return err
+	// Convert ID token to long-lived cookie. Without verification, the ID token
+	// itself is stored.
+	cookie := idToken
+	if !f.skipVerification {
+		cookie, err = f.firebaseAuth.SessionCookie(ctx, idToken, i.TTL)
+		if err != nil {
+			f.ClearSession(ctx, session)
+			return err
+		}
 	}
 
 	// Set cookie
@@ -225,6 +260,16 @@ func (f *firebaseAuth) MFAEnabled(ctx context.Context, session *sessions.Session
 	return data.MFAEnabled, nil
 }
 
+// AuthenticatedAt returns the auth_time of the session cookie, which is the
+// time the user last provided their credentials.
+func (f *firebaseAuth) AuthenticatedAt(ctx context.Context, session *sessions.Session) (time.Time, error) {
+	data, err := f.loadCookie(ctx, session)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return data.AuthTime, nil
+}
+
 // ChangePassword changes the users password. The data must be an oobCode as a
 // string.
 func (f *firebaseAuth) ChangePassword(ctx context.Context, newPassword string, data interface{}) error {
@@ -328,12 +373,13 @@ type firebaseCookieData struct {
 	Email         string
 	EmailVerified bool
 	MFAEnabled    bool
+	AuthTime      time.Time
 }
 
 // dataFromCookie extracts the information from the provided firebase cookie, if
 // it exists.
 func (f *firebaseAuth) dataFromCookie(ctx context.Context, cookie string) (*firebaseCookieData, error) {
-	token, err := f.firebaseAuth.VerifySessionCookie(ctx, cookie)
+	token, err := f.verifySessionCookie(ctx, cookie)
 	if err != nil {
 		return nil, fmt.Errorf("failed to verify firebase cookie: %w", err)
 	}
@@ -372,6 +418,7 @@ func (f *firebaseAuth) dataFromCookie(ctx context.Context, cookie string) (*fire
 		Email:         email,
 		EmailVerified: emailVerified,
 		MFAEnabled:    mfaEnabled,
+		AuthTime:      time.Unix(token.AuthTime, 0).UTC(),
 	}, nil
 }
 
@@ -396,3 +443,49 @@ func (f *firebaseAuth) loadCookie(ctx context.Context, session *sessions.Session
 	}
 	return data, nil
 }
+
+// verifyIDToken verifies the ID token, unless verification is skipped.
+func (f *firebaseAuth) verifyIDToken(ctx context.Context, idToken string) (*auth.Token, error) {
+	if f.skipVerification {
+		return parseUnverifiedToken(idToken)
+	}
+	return f.firebaseAuth.VerifyIDToken(ctx, idToken)
+}
+
+// verifySessionCookie verifies the session cookie, unless verification is
+// skipped.
+func (f *firebaseAuth) verifySessionCookie(ctx context.Context, cookie string) (*auth.Token, error) {
+	if f.skipVerification {
+		return parseUnverifiedToken(cookie)
+	}
+	return f.firebaseAuth.VerifySessionCookie(ctx, cookie)
+}
+
+// parseUnverifiedToken decodes the claims of a Firebase JWT without verifying
+// its signature or expiration.
+func parseUnverifiedToken(s string) (*auth.Token, error) {
+	parts := strings.Split(s, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("token is not a jwt")
+	}
+
+	b, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode token payload: %w", err)
+	}
+
+	var claims map[string]interface{}
+	if err := json.Unmarshal(b, &claims); err != nil {
+		return nil, fmt.Errorf("failed to parse token payload: %w", err)
+	}
+
+	token := &auth.Token{Claims: claims}
+	if v, ok := claims["auth_time"].(float64); ok {
+		token.AuthTime = int64(v)
+	}
+	if v, ok := claims["sub"].(string); ok {
+		token.Subject = v
+		token.UID = v
+	}
+	return token, nil
+}
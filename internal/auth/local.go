@@ -18,6 +18,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"time"
 
 	"github.com/gorilla/sessions"
 )
@@ -77,12 +78,20 @@ func (a *localAuth) StoreSession(ctx context.Context, session *sessions.Session,
 		return fmt.Errorf("missing revoked: %w", ErrSessionInfoMissing)
 	}
 
+	// Authenticated at is optional and defaults to now, since storing the
+	// session is the act of signing in.
+	authenticatedAt, ok := i.Data["authenticated_at"].(time.Time)
+	if !ok {
+		authenticatedAt = time.Now()
+	}
+
 	// Convert ID token to long-lived cookie
 	cookie, err := json.Marshal(&localCookieData{
-		Email:         email,
-		EmailVerified: emailVerified,
-		MFAEnabled:    mfaEnabled,
-		Revoked:       revoked,
+		Email:           email,
+		EmailVerified:   emailVerified,
+		MFAEnabled:      mfaEnabled,
+		Revoked:         revoked,
+		AuthenticatedAt: authenticatedAt.UTC().Unix(),
 	})
 	if err != nil {
 		a.ClearSession(ctx, session)
@@ -164,6 +173,15 @@ func (a *localAuth) MFAEnabled(ctx context.Context, session *sessions.Session) (
 	return data.MFAEnabled, nil
 }
 
+// AuthenticatedAt returns the time the session was stored.
+func (a *localAuth) AuthenticatedAt(ctx context.Context, session *sessions.Session) (time.Time, error) {
+	data, err := a.loadCookie(ctx, session)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Unix(data.AuthenticatedAt, 0).UTC(), nil
+}
+
 // ChangePassword changes the users password. The data is not used. Since local
 // auth does not use passwords, this is a noop.
 func (a *localAuth) ChangePassword(ctx context.Context, newPassword string, data interface{}) error {
@@ -226,10 +244,11 @@ func (a *localAuth) emailVerificationLink(ctx context.Context, email string) (st
 }
 
 type localCookieData struct {
-	Email         string `json:"email"`
-	EmailVerified bool   `json:"email_verified"`
-	MFAEnabled    bool   `json:"mfa_enabled"`
-	Revoked       bool   `json:"revoked"`
+	Email           string `json:"email"`
+	EmailVerified   bool   `json:"email_verified"`
+	MFAEnabled      bool   `json:"mfa_enabled"`
+	Revoked         bool   `json:"revoked"`
+	AuthenticatedAt int64  `json:"authenticated_at"`
 }
 
 // dataFromCookie extracts the information from the provided local cookie, if it
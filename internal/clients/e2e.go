@@ -29,7 +29,9 @@ import (
 
 	"github.com/google/exposure-notifications-verification-server/internal/project"
 	"github.com/google/exposure-notifications-verification-server/pkg/api"
+	"github.com/google/exposure-notifications-verification-server/pkg/apiclient"
 	"github.com/google/exposure-notifications-verification-server/pkg/config"
+	"github.com/google/exposure-notifications-verification-server/pkg/controller"
 	"github.com/google/exposure-notifications-verification-server/pkg/observability"
 
 	"go.opencensus.io/plugin/ochttp"
@@ -81,16 +83,16 @@ func init() {
 func RunEndToEnd(ctx context.Context, cfg *config.E2ERunnerConfig) error {
 	logger := logging.FromContext(ctx)
 
-	adminAPIClient, err := NewAdminAPIServerClient(cfg.VerificationAdminAPIServer, cfg.VerificationAdminAPIKey,
-		WithTimeout(timeout),
-		WithUserAgent("en/e2e-client"))
+	adminAPIClient, err := apiclient.NewAdminAPIServerClient(cfg.VerificationAdminAPIServer, cfg.VerificationAdminAPIKey,
+		apiclient.WithHTTPClient(controller.TracedHTTPClient(timeout)),
+		apiclient.WithUserAgent("en/e2e-client"))
 	if err != nil {
 		return fmt.Errorf("failed to make adminapi server client: %w", err)
 	}
 
-	apiServerClient, err := NewAPIServerClient(cfg.VerificationAPIServer, cfg.VerificationAPIServerKey,
-		WithTimeout(timeout),
-		WithUserAgent("en/e2e-client"))
+	apiServerClient, err := apiclient.NewAPIServerClient(cfg.VerificationAPIServer, cfg.VerificationAPIServerKey,
+		apiclient.WithHTTPClient(controller.TracedHTTPClient(timeout)),
+		apiclient.WithUserAgent("en/e2e-client"))
 	if err != nil {
 		return fmt.Errorf("failed to make apiserver client: %w", err)
 	}
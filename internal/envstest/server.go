@@ -253,8 +253,12 @@ func NewServerConfig(tb testing.TB, testDatabaseInstance *database.TestInstance)
 		},
 		RateLimit: *harness.RateLimiterConfig,
 
-		// DevMode has to be enabled for tests. Otherwise the cookies fail.
 		DevMode: true,
+
+		// Relaxed CSRF has to be enabled for tests. Otherwise the cookies fail.
+		Dev: config.DevModeConfig{
+			RelaxedCSRF: true,
+		},
 	}
 
 	// Process the config - this simulates production setups and also ensures we
@@ -0,0 +1,132 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package firebase
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+type lookupAccountsRequest struct {
+	Email []string `json:"email"`
+}
+
+type lookupAccountsResponse struct {
+	Users []struct {
+		LocalID string `json:"localId"`
+	} `json:"users"`
+}
+
+type updateAccountRequest struct {
+	LocalID     string `json:"localId"`
+	DisableUser bool   `json:"disableUser"`
+}
+
+type deleteAccountRequest struct {
+	LocalID string `json:"localId"`
+}
+
+// LookupUserIDByEmail returns the Firebase user ID for the email. It returns
+// the empty string if no account exists.
+//
+// See: https://cloud.google.com/identity-platform/docs/reference/rest/v1/accounts/lookup
+func (c *Client) LookupUserIDByEmail(ctx context.Context, email string) (string, error) {
+	var resp lookupAccountsResponse
+	if err := c.postAccounts(ctx, "/v1/accounts:lookup", &lookupAccountsRequest{
+		Email: []string{email},
+	}, &resp); err != nil {
+		return "", fmt.Errorf("failed to lookup user: %w", err)
+	}
+
+	if len(resp.Users) == 0 {
+		return "", nil
+	}
+	return resp.Users[0].LocalID, nil
+}
+
+// DisableUser disables the Firebase account with the user ID, so it can no
+// longer sign in.
+//
+// See: https://cloud.google.com/identity-platform/docs/reference/rest/v1/accounts/update
+func (c *Client) DisableUser(ctx context.Context, localID string) error {
+	if err := c.postAccounts(ctx, "/v1/accounts:update", &updateAccountRequest{
+		LocalID:     localID,
+		DisableUser: true,
+	}, nil); err != nil {
+		return fmt.Errorf("failed to disable user: %w", err)
+	}
+	return nil
+}
+
+// DeleteUser deletes the Firebase account with the user ID.
+//
+// See: https://cloud.google.com/identity-platform/docs/reference/rest/v1/accounts/delete
+func (c *Client) DeleteUser(ctx context.Context, localID string) error {
+	if err := c.postAccounts(ctx, "/v1/accounts:delete", &deleteAccountRequest{
+		LocalID: localID,
+	}, nil); err != nil {
+		return fmt.Errorf("failed to delete user: %w", err)
+	}
+	return nil
+}
+
+// postAccounts sends the JSON request to the accounts API path and decodes the
+// response into out, if out is not nil.
+func (c *Client) postAccounts(ctx context.Context, path string, in, out interface{}) error {
+	var body bytes.Buffer
+	if err := json.NewEncoder(&body).Encode(in); err != nil {
+		return fmt.Errorf("failed to create json body: %w", err)
+	}
+
+	u := c.buildURL(path)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u, &body)
+	if err != nil {
+		return fmt.Errorf("failed to build request: %w", err)
+	}
+	req.Header.Add("Accept", "application/json")
+	req.Header.Add("Content-Type", "application/json")
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("response was %d, but failed to read body: %w", resp.StatusCode, err)
+	}
+
+	if status := resp.StatusCode; status != http.StatusOK {
+		// Try to unmarshal the error message. Firebase uses these as enum values to expand on the code.
+		var m map[string]ErrorDetails
+		if err := json.Unmarshal(b, &m); err == nil {
+			d := m["error"]
+			return &d
+		}
+		return fmt.Errorf("failure %d: %s", status, string(b))
+	}
+
+	if out != nil {
+		if err := json.Unmarshal(b, out); err != nil {
+			return fmt.Errorf("failed to decode response: %w", err)
+		}
+	}
+	return nil
+}
@@ -111,7 +111,9 @@ func (l *LocaleMap) SetDynamicTranslations(incoming []*database.DynamicTranslati
 			curFile = fmt.Sprintf(poHeader, locale)
 		}
 
-		addOn := fmt.Sprintf("msgid \"%s\"\nmsgstr \"%s\"\n\n", dt.MessageID, dt.Message)
+		// Quote the strings, since messages can contain quotes and newlines that
+		// would otherwise break the PO syntax.
+		addOn := fmt.Sprintf("msgid %q\nmsgstr %q\n\n", dt.MessageID, dt.Message)
 		curFile = curFile + addOn
 
 		realm[locale] = curFile
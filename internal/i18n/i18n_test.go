@@ -231,3 +231,25 @@ func TestDynamicTranslations(t *testing.T) {
 		t.Fatalf("wrong translation, got %q want %q", res, "hello")
 	}
 }
+
+func TestDynamicTranslations_escaping(t *testing.T) {
+	t.Parallel()
+
+	want := "Call \"the hotline\"\nfor help \\ support"
+	translations := []*database.DynamicTranslation{
+		{
+			RealmID:   1,
+			MessageID: "webReportIntroMessage",
+			Locale:    "en",
+			Message:   want,
+		},
+	}
+
+	l := &LocaleMap{}
+	l.SetDynamicTranslations(translations)
+
+	translator := l.LookupDynamic(1, "en", "en")
+	if got := translator.Get("webReportIntroMessage"); got != want {
+		t.Errorf("wrong translation, got %q want %q", got, want)
+	}
+}
@@ -30,3 +30,19 @@ func CanonicalPhoneNumber(phone string, defaultRegion string) (string, error) {
 	}
 	return phonenumbers.Format(pn, phonenumbers.E164), nil
 }
+
+// PhoneNumberRegion returns the lowercase ISO 3166 region code (e.g. "us") for
+// the E.164 formatted phone number. It returns an error if the number cannot be
+// parsed or does not belong to a single region.
+func PhoneNumberRegion(phone string) (string, error) {
+	pn, err := phonenumbers.Parse(phone, "")
+	if err != nil {
+		return "", fmt.Errorf("phonenumbers.Parse: %w", err)
+	}
+
+	region := phonenumbers.GetRegionCodeForNumber(pn)
+	if region == "" || region == phonenumbers.UNKNOWN_REGION {
+		return "", fmt.Errorf("unknown region for phone number")
+	}
+	return strings.ToLower(region), nil
+}
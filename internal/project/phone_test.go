@@ -84,3 +84,30 @@ func TestPhoneParse(t *testing.T) {
 		})
 	}
 }
+
+func TestPhoneNumberRegion(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		phone string
+		want  string
+		err   bool
+	}{
+		{phone: "+12068675309", want: "us"},
+		{phone: "+442071838750", want: "gb"},
+		{phone: "+551155256325", want: "br"},
+		{phone: "2068675309", err: true},
+		{phone: "banana", err: true},
+	}
+
+	for _, tc := range cases {
+		got, err := PhoneNumberRegion(tc.phone)
+		if (err != nil) != tc.err {
+			t.Errorf("%q: expected error to be %t, got %v", tc.phone, tc.err, err)
+			continue
+		}
+		if got != tc.want {
+			t.Errorf("%q: expected %q to be %q", tc.phone, got, tc.want)
+		}
+	}
+}
@@ -21,13 +21,18 @@ import (
 
 	"github.com/google/exposure-notifications-server/pkg/keys"
 	"github.com/google/exposure-notifications-server/pkg/logging"
+	"github.com/google/exposure-notifications-verification-server/pkg/api"
 	"github.com/google/exposure-notifications-verification-server/pkg/cache"
 	"github.com/google/exposure-notifications-verification-server/pkg/config"
 	"github.com/google/exposure-notifications-verification-server/pkg/controller"
+	"github.com/google/exposure-notifications-verification-server/pkg/controller/apikey"
+	"github.com/google/exposure-notifications-verification-server/pkg/controller/audits"
+	"github.com/google/exposure-notifications-verification-server/pkg/controller/branding"
 	"github.com/google/exposure-notifications-verification-server/pkg/controller/codes"
 	"github.com/google/exposure-notifications-verification-server/pkg/controller/issueapi"
 	"github.com/google/exposure-notifications-verification-server/pkg/controller/middleware"
 	"github.com/google/exposure-notifications-verification-server/pkg/controller/stats"
+	"github.com/google/exposure-notifications-verification-server/pkg/controller/userimport"
 	"github.com/google/exposure-notifications-verification-server/pkg/database"
 	"github.com/google/exposure-notifications-verification-server/pkg/ratelimit/limitware"
 	"github.com/google/exposure-notifications-verification-server/pkg/render"
@@ -36,6 +41,59 @@ import (
 	"github.com/gorilla/mux"
 )
 
+// adminAPIRoutes are the routes served by the adminapi service.
+var adminAPIRoutes = []*Route{
+	{Name: "adminapi.health", Path: "/health", Methods: []string{http.MethodGet}, Auth: AuthNone, RateLimit: RateLimitNone},
+	{Name: "adminapi.readyz", Path: "/readyz", Methods: []string{http.MethodGet}, Auth: AuthNone, RateLimit: RateLimitNone},
+	{Name: "adminapi.schema", Path: "/schema", Methods: []string{http.MethodGet}, Auth: AuthNone, RateLimit: RateLimitNone},
+
+	{Name: "adminapi.issue", Path: "/api/issue", Methods: []string{http.MethodPost}, Auth: AuthAdminAPIKey, RateLimit: RateLimitAPIKey, APIVersion: api.SchemaVersion},
+	{Name: "adminapi.batch-issue", Path: "/api/batch-issue", Methods: []string{http.MethodPost}, Auth: AuthAdminAPIKey, RateLimit: RateLimitAPIKey, APIVersion: api.SchemaVersion},
+	{Name: "adminapi.checkcodestatus", Path: "/api/checkcodestatus", Methods: []string{http.MethodPost}, Auth: AuthAdminAPIKey, RateLimit: RateLimitAPIKey, APIVersion: api.SchemaVersion},
+	{Name: "adminapi.expirecode", Path: "/api/expirecode", Methods: []string{http.MethodPost}, Auth: AuthAdminAPIKey, RateLimit: RateLimitAPIKey, APIVersion: api.SchemaVersion},
+	{Name: "adminapi.resendcode", Path: "/api/resendcode", Methods: []string{http.MethodPost}, Auth: AuthAdminAPIKey, RateLimit: RateLimitAPIKey, APIVersion: api.SchemaVersion},
+	{Name: "adminapi.revokeapikey", Path: "/api/revokeapikey", Methods: []string{http.MethodPost}, Auth: AuthAdminAPIKey, RateLimit: RateLimitAPIKey, APIVersion: api.SchemaVersion},
+	{Name: "adminapi.listcodes", Path: "/api/listcodes", Methods: []string{http.MethodPost}, Auth: AuthAdminAPIKey, RateLimit: RateLimitAPIKey, APIVersion: api.SchemaVersion},
+	{Name: "adminapi.codes.search", Path: "/api/codes/search", Methods: []string{http.MethodPost}, Auth: AuthAdminAPIKey, RateLimit: RateLimitAPIKey, APIVersion: api.SchemaVersion},
+	{Name: "adminapi.sandbox-sms", Path: "/api/sandbox/sms", Methods: []string{http.MethodPost}, Auth: AuthAdminAPIKey, RateLimit: RateLimitAPIKey, APIVersion: api.SchemaVersion},
+	{Name: "adminapi.branding.show", Path: "/api/realm/branding", Methods: []string{http.MethodGet}, Auth: AuthAdminAPIKey, RateLimit: RateLimitAPIKey, APIVersion: api.SchemaVersion},
+	{Name: "adminapi.branding.update", Path: "/api/realm/branding", Methods: []string{http.MethodPut}, Auth: AuthAdminAPIKey, RateLimit: RateLimitAPIKey, APIVersion: api.SchemaVersion},
+	{Name: "adminapi.branding.reset", Path: "/api/realm/branding", Methods: []string{http.MethodDelete}, Auth: AuthAdminAPIKey, RateLimit: RateLimitAPIKey, APIVersion: api.SchemaVersion},
+	{Name: "adminapi.branding.agency-image.upload", Path: "/api/realm/branding/agency-image", Methods: []string{http.MethodPut}, Auth: AuthAdminAPIKey, RateLimit: RateLimitAPIKey, APIVersion: api.SchemaVersion},
+	{Name: "adminapi.branding.agency-image.delete", Path: "/api/realm/branding/agency-image", Methods: []string{http.MethodDelete}, Auth: AuthAdminAPIKey, RateLimit: RateLimitAPIKey, APIVersion: api.SchemaVersion},
+	{Name: "adminapi.stats.export.csv", Path: "/api/stats/export.csv", Methods: []string{http.MethodGet}, Auth: AuthAdminAPIKey, RateLimit: RateLimitAPIKey, APIVersion: api.SchemaVersion},
+	{Name: "adminapi.stats.export.json", Path: "/api/stats/export.json", Methods: []string{http.MethodGet}, Auth: AuthAdminAPIKey, RateLimit: RateLimitAPIKey, APIVersion: api.SchemaVersion},
+	{Name: "adminapi.audits.csv", Path: "/api/audits.csv", Methods: []string{http.MethodGet}, Auth: AuthAdminAPIKey, RateLimit: RateLimitAPIKey, APIVersion: api.SchemaVersion},
+	{Name: "adminapi.audits.ndjson", Path: "/api/audits.ndjson", Methods: []string{http.MethodGet}, Auth: AuthAdminAPIKey, RateLimit: RateLimitAPIKey, APIVersion: api.SchemaVersion},
+	{Name: "adminapi.users.import", Path: "/api/users/import", Methods: []string{http.MethodPost}, Auth: AuthAdminAPIKey, RateLimit: RateLimitAPIKey, APIVersion: api.SchemaVersion},
+	{Name: "adminapi.users.import.status", Path: "/api/users/import/status", Methods: []string{http.MethodPost}, Auth: AuthAdminAPIKey, RateLimit: RateLimitAPIKey, APIVersion: api.SchemaVersion},
+	{Name: "adminapi.callbacks.events", Path: "/api/callbacks/events", Methods: []string{http.MethodGet}, Auth: AuthAdminAPIKey, RateLimit: RateLimitAPIKey, APIVersion: api.SchemaVersion},
+	{Name: "adminapi.callbacks.test", Path: "/api/callbacks/test", Methods: []string{http.MethodPost}, Auth: AuthAdminAPIKey, RateLimit: RateLimitAPIKey, APIVersion: api.SchemaVersion},
+
+	{Name: "adminapi.stats.metrics.json", Path: "/api/stats/metrics.json", Methods: []string{http.MethodGet}, Auth: AuthStatsAPIKey, RateLimit: RateLimitAPIKey, APIVersion: api.SchemaVersion},
+	{Name: "adminapi.stats.realm.csv", Path: "/api/stats/realm.csv", Methods: []string{http.MethodGet}, Auth: AuthStatsAPIKey, RateLimit: RateLimitAPIKey, APIVersion: api.SchemaVersion},
+	{Name: "adminapi.stats.realm.json", Path: "/api/stats/realm.json", Methods: []string{http.MethodGet}, Auth: AuthStatsAPIKey, RateLimit: RateLimitAPIKey, APIVersion: api.SchemaVersion},
+	{Name: "adminapi.stats.composite.csv", Path: "/api/stats/realm/composite.csv", Methods: []string{http.MethodGet}, Auth: AuthStatsAPIKey, RateLimit: RateLimitAPIKey, APIVersion: api.SchemaVersion},
+	{Name: "adminapi.stats.composite.json", Path: "/api/stats/realm/composite.json", Methods: []string{http.MethodGet}, Auth: AuthStatsAPIKey, RateLimit: RateLimitAPIKey, APIVersion: api.SchemaVersion},
+	{Name: "adminapi.stats.users.csv", Path: "/api/stats/realm/users.csv", Methods: []string{http.MethodGet}, Auth: AuthStatsAPIKey, RateLimit: RateLimitAPIKey, APIVersion: api.SchemaVersion},
+	{Name: "adminapi.stats.users.json", Path: "/api/stats/realm/users.json", Methods: []string{http.MethodGet}, Auth: AuthStatsAPIKey, RateLimit: RateLimitAPIKey, APIVersion: api.SchemaVersion},
+	{Name: "adminapi.stats.user.csv", Path: "/api/stats/realm/users/{id}.csv", Methods: []string{http.MethodGet}, Auth: AuthStatsAPIKey, RateLimit: RateLimitAPIKey, APIVersion: api.SchemaVersion},
+	{Name: "adminapi.stats.user.json", Path: "/api/stats/realm/users/{id}.json", Methods: []string{http.MethodGet}, Auth: AuthStatsAPIKey, RateLimit: RateLimitAPIKey, APIVersion: api.SchemaVersion},
+	{Name: "adminapi.stats.api-key.csv", Path: "/api/stats/realm/api-keys/{id}.csv", Methods: []string{http.MethodGet}, Auth: AuthStatsAPIKey, RateLimit: RateLimitAPIKey, APIVersion: api.SchemaVersion},
+	{Name: "adminapi.stats.api-key.json", Path: "/api/stats/realm/api-keys/{id}.json", Methods: []string{http.MethodGet}, Auth: AuthStatsAPIKey, RateLimit: RateLimitAPIKey, APIVersion: api.SchemaVersion},
+	{Name: "adminapi.stats.external-issuers.csv", Path: "/api/stats/realm/external-issuers.csv", Methods: []string{http.MethodGet}, Auth: AuthStatsAPIKey, RateLimit: RateLimitAPIKey, APIVersion: api.SchemaVersion},
+	{Name: "adminapi.stats.external-issuers.json", Path: "/api/stats/realm/external-issuers.json", Methods: []string{http.MethodGet}, Auth: AuthStatsAPIKey, RateLimit: RateLimitAPIKey, APIVersion: api.SchemaVersion},
+	{Name: "adminapi.stats.external-issuers.issuance.json", Path: "/api/stats/realm/external-issuers/issuance.json", Methods: []string{http.MethodGet}, Auth: AuthStatsAPIKey, RateLimit: RateLimitAPIKey, APIVersion: api.SchemaVersion},
+	{Name: "adminapi.stats.sms-errors.csv", Path: "/api/stats/realm/sms-errors.csv", Methods: []string{http.MethodGet}, Auth: AuthStatsAPIKey, RateLimit: RateLimitAPIKey, APIVersion: api.SchemaVersion},
+	{Name: "adminapi.stats.sms-errors.json", Path: "/api/stats/realm/sms-errors.json", Methods: []string{http.MethodGet}, Auth: AuthStatsAPIKey, RateLimit: RateLimitAPIKey, APIVersion: api.SchemaVersion},
+	{Name: "adminapi.stats.epi-weekly.csv", Path: "/api/stats/realm/epi-weekly.csv", Methods: []string{http.MethodGet}, Auth: AuthStatsAPIKey, RateLimit: RateLimitAPIKey, APIVersion: api.SchemaVersion},
+	{Name: "adminapi.stats.epi-weekly.json", Path: "/api/stats/realm/epi-weekly.json", Methods: []string{http.MethodGet}, Auth: AuthStatsAPIKey, RateLimit: RateLimitAPIKey, APIVersion: api.SchemaVersion},
+	{Name: "adminapi.stats.key-server.csv", Path: "/api/stats/realm/key-server.csv", Methods: []string{http.MethodGet}, Auth: AuthStatsAPIKey, RateLimit: RateLimitAPIKey, APIVersion: api.SchemaVersion},
+	{Name: "adminapi.stats.key-server.json", Path: "/api/stats/realm/key-server.json", Methods: []string{http.MethodGet}, Auth: AuthStatsAPIKey, RateLimit: RateLimitAPIKey, APIVersion: api.SchemaVersion},
+	{Name: "adminapi.stats.key-server-sources.csv", Path: "/api/stats/realm/key-server-sources.csv", Methods: []string{http.MethodGet}, Auth: AuthStatsAPIKey, RateLimit: RateLimitAPIKey, APIVersion: api.SchemaVersion},
+	{Name: "adminapi.stats.key-server-sources.json", Path: "/api/stats/realm/key-server-sources.json", Methods: []string{http.MethodGet}, Auth: AuthStatsAPIKey, RateLimit: RateLimitAPIKey, APIVersion: api.SchemaVersion},
+}
+
 // AdminAPI defines routes for the adminapi service.
 func AdminAPI(
 	ctx context.Context,
@@ -89,6 +147,12 @@ func AdminAPI(
 	processDebug := middleware.ProcessDebug()
 	r.Use(processDebug)
 
+	// Advertise and enforce the API schema version
+	r.Use(middleware.ProcessSchemaVersion(h))
+
+	// Limit request body sizes
+	r.Use(middleware.LimitBody(cfg.BodyLimits.Default))
+
 	// Other common middlewares
 	requireAdminAPIKey := middleware.RequireAPIKey(cacher, db, h, []database.APIKeyType{
 		database.APIKeyTypeAdmin,
@@ -97,24 +161,70 @@ func AdminAPI(
 		database.APIKeyTypeStats,
 	})
 	processFirewall := middleware.ProcessFirewall(h, "adminapi")
+	captureAPIRequests := middleware.CaptureAPIRequests(db)
+
+	// API keys are not realm memberships, so no routes declare permissions or
+	// recent authentication.
+	m := newMounter(nil, nil)
 
 	// Health route
-	r.Handle("/health", controller.HandleHealthz(db, h, cfg.IsMaintenanceMode())).Methods(http.MethodGet)
+	m.handle(r, "", "adminapi.health", controller.HandleHealthz(db, h, cfg.IsMaintenanceMode()))
+	m.handle(r, "", "adminapi.readyz", controller.HandleReadyz(h, cfg.Readiness.TimeoutFor,
+		controller.DatabaseProbe(db),
+		controller.CacheProbe(cacher),
+		controller.RateLimiterProbe(limiterStore),
+		controller.KeyManagerProbe(db)))
+
+	// Schema changelog
+	m.handle(r, "", "adminapi.schema", controller.HandleSchema(h))
 
 	// API routes
 	{
 		sub := r.PathPrefix("/api").Subrouter()
 		sub.Use(requireAdminAPIKey)
+		sub.Use(captureAPIRequests)
 		sub.Use(rateLimit)
 		sub.Use(processFirewall)
+		m.protect(sub, AuthAdminAPIKey, RateLimitAPIKey)
 
 		issueapiController := issueapi.New(cfg, db, limiterStore, smsSigner, h)
-		sub.Handle("/issue", issueapiController.HandleIssueAPI()).Methods(http.MethodPost)
-		sub.Handle("/batch-issue", issueapiController.HandleBatchIssueAPI()).Methods(http.MethodPost)
+		m.handle(sub, "/api", "adminapi.issue", issueapiController.HandleIssueAPI())
+		m.handle(sub, "/api", "adminapi.batch-issue", middleware.LimitBody(cfg.BodyLimits.BatchIssue)(issueapiController.HandleBatchIssueAPI()))
+		m.handle(sub, "/api", "adminapi.resendcode", issueapiController.HandleResendAPI())
 
 		codesController := codes.NewAPI(cfg, db, h)
-		sub.Handle("/checkcodestatus", codesController.HandleCheckCodeStatus()).Methods(http.MethodPost)
-		sub.Handle("/expirecode", codesController.HandleExpireAPI()).Methods(http.MethodPost)
+		m.handle(sub, "/api", "adminapi.checkcodestatus", codesController.HandleCheckCodeStatus())
+		m.handle(sub, "/api", "adminapi.expirecode", codesController.HandleExpireAPI())
+		m.handle(sub, "/api", "adminapi.revokeapikey", codesController.HandleRevokeAPIKey())
+		m.handle(sub, "/api", "adminapi.listcodes", codesController.HandleListCodes())
+		m.handle(sub, "/api", "adminapi.codes.search", codesController.HandleSearchAPI())
+		m.handle(sub, "/api", "adminapi.sandbox-sms", codesController.HandleSandboxSMS())
+
+		brandingController := branding.New(&cfg.Issue, db, h)
+		m.handle(sub, "/api", "adminapi.branding.show", brandingController.HandleShow())
+		m.handle(sub, "/api", "adminapi.branding.update", brandingController.HandleUpdate())
+		m.handle(sub, "/api", "adminapi.branding.reset", brandingController.HandleReset())
+		m.handle(sub, "/api", "adminapi.branding.agency-image.upload", middleware.LimitBody(cfg.BodyLimits.AgencyImage)(brandingController.HandleUploadAgencyImage()))
+		m.handle(sub, "/api", "adminapi.branding.agency-image.delete", brandingController.HandleDeleteAgencyImage())
+
+		// Stats exports require an admin API key, so they are registered here
+		// instead of with the stats API key routes below.
+		statsExportController := stats.New(cacher, db, h)
+		linkMetrics := stats.LinkMetrics("/api/stats/metrics.json")
+		m.handle(sub, "/api", "adminapi.stats.export.csv", linkMetrics(statsExportController.HandleExport(stats.TypeCSV)))
+		m.handle(sub, "/api", "adminapi.stats.export.json", linkMetrics(statsExportController.HandleExport(stats.TypeJSON)))
+
+		auditsController := audits.New(db, h)
+		m.handle(sub, "/api", "adminapi.audits.csv", auditsController.HandleRealmExport(audits.TypeCSV))
+		m.handle(sub, "/api", "adminapi.audits.ndjson", auditsController.HandleRealmExport(audits.TypeNDJSON))
+
+		userImportController := userimport.NewAPI(db, h)
+		m.handle(sub, "/api", "adminapi.users.import", middleware.LimitBody(cfg.BodyLimits.UserImport)(userImportController.HandleCreate()))
+		m.handle(sub, "/api", "adminapi.users.import.status", userImportController.HandleStatus())
+
+		apikeyController := apikey.New(cacher, db, h)
+		m.handle(sub, "/api", "adminapi.callbacks.events", apikeyController.HandleCallbackEventsAPI())
+		m.handle(sub, "/api", "adminapi.callbacks.test", apikeyController.HandleCallbackTestAPI())
 	}
 
 	// Stats routes
@@ -123,31 +233,45 @@ func AdminAPI(
 		sub.Use(requireStatsAPIKey)
 		sub.Use(rateLimit)
 		sub.Use(processFirewall)
+		sub.Use(stats.LinkMetrics("/api/stats/metrics.json"))
+		m.protect(sub, AuthStatsAPIKey, RateLimitAPIKey)
 
 		statsController := stats.New(cacher, db, h)
-		sub.Handle("/realm.csv", statsController.HandleRealmStats(stats.TypeCSV)).Methods(http.MethodGet)
-		sub.Handle("/realm.json", statsController.HandleRealmStats(stats.TypeJSON)).Methods(http.MethodGet)
+		m.handle(sub, "/api/stats", "adminapi.stats.metrics.json", statsController.HandleMetrics())
 
-		sub.Handle("/realm/composite.csv", statsController.HandleComposite(stats.TypeCSV)).Methods(http.MethodGet)
-		sub.Handle("/realm/composite.json", statsController.HandleComposite(stats.TypeJSON)).Methods(http.MethodGet)
+		m.handle(sub, "/api/stats", "adminapi.stats.realm.csv", statsController.HandleRealmStats(stats.TypeCSV))
+		m.handle(sub, "/api/stats", "adminapi.stats.realm.json", statsController.HandleRealmStats(stats.TypeJSON))
 
-		sub.Handle("/realm/users.csv", statsController.HandleRealmUsersStats(stats.TypeCSV)).Methods(http.MethodGet)
-		sub.Handle("/realm/users.json", statsController.HandleRealmUsersStats(stats.TypeJSON)).Methods(http.MethodGet)
+		m.handle(sub, "/api/stats", "adminapi.stats.composite.csv", statsController.HandleComposite(stats.TypeCSV))
+		m.handle(sub, "/api/stats", "adminapi.stats.composite.json", statsController.HandleComposite(stats.TypeJSON))
 
-		sub.Handle("/realm/users/{id}.csv", statsController.HandleRealmUserStats(stats.TypeCSV)).Methods(http.MethodGet)
-		sub.Handle("/realm/users/{id}.json", statsController.HandleRealmUserStats(stats.TypeJSON)).Methods(http.MethodGet)
+		m.handle(sub, "/api/stats", "adminapi.stats.users.csv", statsController.HandleRealmUsersStats(stats.TypeCSV))
+		m.handle(sub, "/api/stats", "adminapi.stats.users.json", statsController.HandleRealmUsersStats(stats.TypeJSON))
 
-		sub.Handle("/realm/api-keys/{id}.csv", statsController.HandleRealmAuthorizedAppStats(stats.TypeCSV)).Methods(http.MethodGet)
-		sub.Handle("/realm/api-keys/{id}.json", statsController.HandleRealmAuthorizedAppStats(stats.TypeJSON)).Methods(http.MethodGet)
+		m.handle(sub, "/api/stats", "adminapi.stats.user.csv", statsController.HandleRealmUserStats(stats.TypeCSV))
+		m.handle(sub, "/api/stats", "adminapi.stats.user.json", statsController.HandleRealmUserStats(stats.TypeJSON))
 
-		sub.Handle("/realm/external-issuers.csv", statsController.HandleRealmExternalIssuersStats(stats.TypeCSV)).Methods(http.MethodGet)
-		sub.Handle("/realm/external-issuers.json", statsController.HandleRealmExternalIssuersStats(stats.TypeJSON)).Methods(http.MethodGet)
+		m.handle(sub, "/api/stats", "adminapi.stats.api-key.csv", statsController.HandleRealmAuthorizedAppStats(stats.TypeCSV))
+		m.handle(sub, "/api/stats", "adminapi.stats.api-key.json", statsController.HandleRealmAuthorizedAppStats(stats.TypeJSON))
 
-		sub.Handle("/realm/sms-errors.csv", statsController.HandleRealmSMSErrorStats(stats.TypeCSV)).Methods(http.MethodGet)
-		sub.Handle("/realm/sms-errors.json", statsController.HandleRealmSMSErrorStats(stats.TypeJSON)).Methods(http.MethodGet)
+		m.handle(sub, "/api/stats", "adminapi.stats.external-issuers.csv", statsController.HandleRealmExternalIssuersStats(stats.TypeCSV))
+		m.handle(sub, "/api/stats", "adminapi.stats.external-issuers.json", statsController.HandleRealmExternalIssuersStats(stats.TypeJSON))
+		m.handle(sub, "/api/stats", "adminapi.stats.external-issuers.issuance.json", statsController.HandleRealmExternalIssuersIssuance())
+
+		m.handle(sub, "/api/stats", "adminapi.stats.sms-errors.csv", statsController.HandleRealmSMSErrorStats(stats.TypeCSV))
+		m.handle(sub, "/api/stats", "adminapi.stats.sms-errors.json", statsController.HandleRealmSMSErrorStats(stats.TypeJSON))
+
+		m.handle(sub, "/api/stats", "adminapi.stats.epi-weekly.csv", statsController.HandleRealmEpiWeeklyStats(stats.TypeCSV))
+		m.handle(sub, "/api/stats", "adminapi.stats.epi-weekly.json", statsController.HandleRealmEpiWeeklyStats(stats.TypeJSON))
+
+		m.handle(sub, "/api/stats", "adminapi.stats.key-server.csv", statsController.HandleKeyServerStats(stats.TypeCSV))
+		m.handle(sub, "/api/stats", "adminapi.stats.key-server.json", statsController.HandleKeyServerStats(stats.TypeJSON))
+		m.handle(sub, "/api/stats", "adminapi.stats.key-server-sources.csv", statsController.HandleKeyServerSourceStats(stats.TypeCSV))
+		m.handle(sub, "/api/stats", "adminapi.stats.key-server-sources.json", statsController.HandleKeyServerSourceStats(stats.TypeJSON))
+	}
 
-		sub.Handle("/realm/key-server.csv", statsController.HandleKeyServerStats(stats.TypeCSV)).Methods(http.MethodGet)
-		sub.Handle("/realm/key-server.json", statsController.HandleKeyServerStats(stats.TypeJSON)).Methods(http.MethodGet)
+	if err := checkMounted(r, ServerAdminAPI); err != nil {
+		return nil, err
 	}
 
 	// Wrap the main router in the mutating middleware method. This cannot be
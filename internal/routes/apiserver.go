@@ -32,12 +32,26 @@ import (
 	"github.com/google/exposure-notifications-verification-server/pkg/database"
 	"github.com/google/exposure-notifications-verification-server/pkg/ratelimit/limitware"
 	"github.com/google/exposure-notifications-verification-server/pkg/render"
+	"github.com/google/exposure-notifications-verification-server/pkg/shadow"
 	"github.com/mikehelmick/go-chaff"
 	"github.com/sethvargo/go-limiter"
 
 	"github.com/gorilla/mux"
 )
 
+// apiServerRoutes are the routes served by the apiserver service.
+var apiServerRoutes = []*Route{
+	{Name: "apiserver.health", Path: "/health", Methods: []string{http.MethodGet}, Auth: AuthNone, RateLimit: RateLimitNone},
+	{Name: "apiserver.readyz", Path: "/readyz", Methods: []string{http.MethodGet}, Auth: AuthNone, RateLimit: RateLimitNone},
+	{Name: "apiserver.schema", Path: "/schema", Methods: []string{http.MethodGet}, Auth: AuthNone, RateLimit: RateLimitNone},
+
+	{Name: "apiserver.user-report", Path: "/api/user-report", Methods: []string{http.MethodPost}, Auth: AuthDeviceAPIKey, RateLimit: RateLimitAPIKey, APIVersion: api.SchemaVersion},
+	{Name: "apiserver.user-report.consent", Path: "/api/user-report/consent", Methods: []string{http.MethodPost}, Auth: AuthDeviceAPIKey, RateLimit: RateLimitAPIKey, APIVersion: api.SchemaVersion},
+	{Name: "apiserver.user-report.purge", Path: "/api/user-report/purge", Methods: []string{http.MethodPost}, Auth: AuthDeviceAPIKey, RateLimit: RateLimitAPIKey, APIVersion: api.SchemaVersion},
+	{Name: "apiserver.verify", Path: "/api/verify", Methods: []string{http.MethodPost}, Auth: AuthDeviceAPIKey, RateLimit: RateLimitAPIKey, APIVersion: api.SchemaVersion},
+	{Name: "apiserver.certificate", Path: "/api/certificate", Methods: []string{http.MethodPost}, Auth: AuthDeviceAPIKey, RateLimit: RateLimitAPIKey, APIVersion: api.SchemaVersion},
+}
+
 // APIServer defines routes for the apiserver service.
 func APIServer(
 	ctx context.Context,
@@ -81,14 +95,25 @@ func APIServer(
 
 	// Note that rate limiting is installed _after_ the chaff middleware because
 	// we do not want chaff requests to count towards rate-limiting quota.
-	httplimiter, err := limitware.NewMiddleware(ctx, limiterStore,
-		limitware.APIKeyFunc(ctx, db, "apiserver:ratelimit:", cfg.RateLimit.HMACKey),
-		limitware.AllowOnError(false))
+	apiKeyFunc := limitware.APIKeyFunc(ctx, db, "apiserver:ratelimit:", cfg.RateLimit.HMACKey)
+	realmPolicy := limitware.RealmPolicy(cfg.RateLimit.Tokens, cfg.RateLimit.Interval)
+	httplimiter, err := limitware.NewMiddleware(ctx, limiterStore, apiKeyFunc,
+		limitware.AllowOnError(false),
+		limitware.WithPolicy(realmPolicy),
+		limitware.WithBans(cacher),
+		limitware.WithRenderer(h))
 	if err != nil {
 		return nil, closer, fmt.Errorf("failed to create limiter middleware: %w", err)
 	}
 	rateLimit := httplimiter.Handle
 
+	// The fair share limiter sheds load from realms and API keys that use more
+	// than their share of the server's capacity during spikes.
+	fairShare, err := limitware.NewFairShare(limiterStore, "apiserver:ratelimit:", &cfg.RateLimit, h)
+	if err != nil {
+		return nil, closer, fmt.Errorf("failed to create fair share middleware: %w", err)
+	}
+
 	// Install common security headers
 	r.Use(middleware.SecureHeaders(cfg.DevMode, "json"))
 
@@ -96,14 +121,47 @@ func APIServer(
 	processDebug := middleware.ProcessDebug()
 	r.Use(processDebug)
 
+	// Advertise and enforce the API schema version
+	r.Use(middleware.ProcessSchemaVersion(h))
+
+	// Limit request body sizes
+	r.Use(middleware.LimitBody(cfg.BodyLimits.Default))
+
 	// Other common middlewares
 	requireAPIKey := middleware.RequireAPIKey(cacher, db, h, []database.APIKeyType{
 		database.APIKeyTypeDevice,
 	})
 	processFirewall := middleware.ProcessFirewall(h, "apiserver")
+	requireMinimumAppVersion := middleware.RequireMinimumAppVersion(h)
+	checkClientFingerprint := middleware.CheckClientFingerprint(h)
+	captureAPIRequests := middleware.CaptureAPIRequests(db)
+
+	// Shadow traffic mirrors a sample of verify and certificate requests to a
+	// candidate release. It is a no-op unless a candidate is configured.
+	shadowTraffic := func(next http.Handler) http.Handler { return next }
+	if cfg.Shadow.Enabled() {
+		mirror, err := shadow.New(&cfg.Shadow)
+		if err != nil {
+			return nil, closer, fmt.Errorf("failed to create shadow traffic mirror: %w", err)
+		}
+		shadowTraffic = mirror.Handle
+	}
+
+	// API keys are not realm memberships, so no routes declare permissions or
+	// recent authentication.
+	m := newMounter(nil, nil)
 
 	// Health route
-	r.Handle("/health", controller.HandleHealthz(db, h, cfg.IsMaintenanceMode())).Methods(http.MethodGet)
+	m.handle(r, "", "apiserver.health", controller.HandleHealthz(db, h, cfg.IsMaintenanceMode()))
+	m.handle(r, "", "apiserver.readyz", controller.HandleReadyz(h, cfg.Readiness.TimeoutFor,
+		controller.DatabaseProbe(db),
+		controller.CacheProbe(cacher),
+		controller.RateLimiterProbe(limiterStore),
+		controller.KeyManagerProbe(db),
+		controller.TokenSigningProbe(db, cacher, tokenSigner)))
+
+	// Schema changelog
+	m.handle(r, "", "apiserver.schema", controller.HandleSchema(h))
 
 	// Make verify chaff tracker.
 	verifyChaffTracker, err := chaff.NewTracker(
@@ -135,24 +193,54 @@ func APIServer(
 		sub.Use(requireAPIKey)
 		sub.Use(processFirewall)
 		sub.Use(middleware.ProcessChaff(db, verifyChaffTracker, middleware.ChaffHeaderDetector()))
+		sub.Use(captureAPIRequests)
+		sub.Use(requireMinimumAppVersion)
+		sub.Use(checkClientFingerprint)
 		sub.Use(rateLimit)
+		sub.Use(fairShare.Handle)
+		m.protect(sub, AuthDeviceAPIKey, RateLimitAPIKey)
 
 		// POST /api/user-report
 		issueController := issueapi.New(cfg, db, limiterStore, certificateSigner, h)
-		sub.Handle("", issueController.HandleUserReport()).Methods(http.MethodPost)
+		m.handle(sub, "/api/user-report", "apiserver.user-report", issueController.HandleUserReport())
+
+		// POST /api/user-report/consent
+		m.handle(sub, "/api/user-report", "apiserver.user-report.consent", issueController.HandleUserReportConsent())
+
+		// POST /api/user-report/purge
+		m.handle(sub, "/api/user-report", "apiserver.user-report.purge", issueController.HandleUserReportPurge())
 	}
 
 	{
+		verifyapiController := verifyapi.New(cfg, db, cacher, tokenSigner, h)
+
+		// The verify endpoint shares the same rate limit, but also records
+		// rejected requests for claim failure diagnostics.
+		verifyLimiter, err := limitware.NewMiddleware(ctx, limiterStore, apiKeyFunc,
+			limitware.AllowOnError(false),
+			limitware.WithPolicy(realmPolicy),
+			limitware.WithBans(cacher),
+			limitware.WithRenderer(h),
+			limitware.OnRateLimited(verifyapiController.RecordRateLimited))
+		if err != nil {
+			return nil, closer, fmt.Errorf("failed to create verify limiter middleware: %w", err)
+		}
+
 		sub := r.PathPrefix("/api/verify").Subrouter()
 		sub.Use(requireAPIKey)
 		sub.Use(processFirewall)
 		sub.Use(middleware.ProcessChaff(db, verifyChaffTracker, middleware.ChaffHeaderDetector()))
-		sub.Use(rateLimit)
+		sub.Use(captureAPIRequests)
+		sub.Use(requireMinimumAppVersion)
+		sub.Use(checkClientFingerprint)
+		sub.Use(verifyLimiter.Handle)
+		sub.Use(fairShare.Handle)
 		sub.Use(middleware.AddOperatingSystemFromUserAgent())
+		sub.Use(shadowTraffic)
+		m.protect(sub, AuthDeviceAPIKey, RateLimitAPIKey)
 
 		// POST /api/verify
-		verifyapiController := verifyapi.New(cfg, db, cacher, tokenSigner, h)
-		sub.Handle("", verifyapiController.HandleVerify()).Methods(http.MethodPost)
+		m.handle(sub, "/api/verify", "apiserver.verify", verifyapiController.HandleVerify())
 	}
 
 	{
@@ -160,14 +248,24 @@ func APIServer(
 		sub.Use(requireAPIKey)
 		sub.Use(processFirewall)
 		sub.Use(middleware.ProcessChaff(db, certChaffTracker, middleware.ChaffHeaderDetector()))
+		sub.Use(captureAPIRequests)
+		sub.Use(requireMinimumAppVersion)
+		sub.Use(checkClientFingerprint)
 		sub.Use(rateLimit)
+		sub.Use(fairShare.Handle)
+		sub.Use(shadowTraffic)
+		m.protect(sub, AuthDeviceAPIKey, RateLimitAPIKey)
 
 		// POST /api/certificate
 		certapiController, err := certapi.New(ctx, cfg, db, cacher, certificateSigner, h)
 		if err != nil {
 			return nil, closer, fmt.Errorf("failed to create certapi controller: %w", err)
 		}
-		sub.Handle("", certapiController.HandleCertificate()).Methods(http.MethodPost)
+		m.handle(sub, "/api/certificate", "apiserver.certificate", certapiController.HandleCertificate())
+	}
+
+	if err := checkMounted(r, ServerAPIServer); err != nil {
+		return nil, closer, err
 	}
 
 	// Wrap the main router in the mutating middleware method. This cannot be
@@ -0,0 +1,158 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package routes
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/exposure-notifications-server/pkg/keys"
+	"github.com/google/exposure-notifications-server/pkg/logging"
+	"github.com/google/exposure-notifications-verification-server/pkg/api/devicepb"
+	"github.com/google/exposure-notifications-verification-server/pkg/cache"
+	"github.com/google/exposure-notifications-verification-server/pkg/config"
+	"github.com/google/exposure-notifications-verification-server/pkg/controller/certapi"
+	"github.com/google/exposure-notifications-verification-server/pkg/controller/grpcapi"
+	"github.com/google/exposure-notifications-verification-server/pkg/controller/issueapi"
+	"github.com/google/exposure-notifications-verification-server/pkg/controller/middleware"
+	"github.com/google/exposure-notifications-verification-server/pkg/controller/verifyapi"
+	"github.com/google/exposure-notifications-verification-server/pkg/database"
+	"github.com/google/exposure-notifications-verification-server/pkg/ratelimit/limitware"
+	"github.com/google/exposure-notifications-verification-server/pkg/render"
+	"github.com/mikehelmick/go-chaff"
+	"github.com/sethvargo/go-limiter"
+
+	"github.com/gorilla/mux"
+	"go.opencensus.io/plugin/ocgrpc"
+	"google.golang.org/grpc"
+)
+
+// APIServerGRPC defines the gRPC variant of the device API served by the
+// apiserver service. Each method runs the same middleware as its JSON
+// endpoint, except for API request capture and shadow traffic, which only
+// apply to the JSON API. The returned closer must be called when the server
+// stops.
+func APIServerGRPC(
+	ctx context.Context,
+	cfg *config.APIServerConfig,
+	db *database.Database,
+	cacher cache.Cacher,
+	limiterStore limiter.Store,
+	tokenSigner keys.KeyManager,
+	certificateSigner keys.KeyManager,
+) (*grpc.Server, func(), error) {
+	closer := func() {}
+
+	// Common observability context
+	ctx, obs := middleware.WithObservability(ctx)
+
+	// Create the renderer
+	h, err := render.New(ctx, nil, cfg.DevMode)
+	if err != nil {
+		return nil, closer, fmt.Errorf("failed to create renderer: %w", err)
+	}
+
+	// Rate limiting shares its keys with the JSON API, so requests count towards
+	// the same quota regardless of transport.
+	apiKeyFunc := limitware.APIKeyFunc(ctx, db, "apiserver:ratelimit:", cfg.RateLimit.HMACKey)
+	realmPolicy := limitware.RealmPolicy(cfg.RateLimit.Tokens, cfg.RateLimit.Interval)
+	httplimiter, err := limitware.NewMiddleware(ctx, limiterStore, apiKeyFunc,
+		limitware.AllowOnError(false),
+		limitware.WithPolicy(realmPolicy),
+		limitware.WithBans(cacher),
+		limitware.WithRenderer(h))
+	if err != nil {
+		return nil, closer, fmt.Errorf("failed to create limiter middleware: %w", err)
+	}
+	rateLimit := httplimiter.Handle
+
+	verifyapiController := verifyapi.New(cfg, db, cacher, tokenSigner, h)
+	verifyLimiter, err := limitware.NewMiddleware(ctx, limiterStore, apiKeyFunc,
+		limitware.AllowOnError(false),
+		limitware.WithPolicy(realmPolicy),
+		limitware.WithBans(cacher),
+		limitware.WithRenderer(h),
+		limitware.OnRateLimited(verifyapiController.RecordRateLimited))
+	if err != nil {
+		return nil, closer, fmt.Errorf("failed to create verify limiter middleware: %w", err)
+	}
+
+	certapiController, err := certapi.New(ctx, cfg, db, cacher, certificateSigner, h)
+	if err != nil {
+		return nil, closer, fmt.Errorf("failed to create certapi controller: %w", err)
+	}
+
+	fairShare, err := limitware.NewFairShare(limiterStore, "apiserver:ratelimit:", &cfg.RateLimit, h)
+	if err != nil {
+		return nil, closer, fmt.Errorf("failed to create fair share limiter: %w", err)
+	}
+
+	issueController := issueapi.New(cfg, db, limiterStore, certificateSigner, h)
+
+	// Chaff calls are tracked separately from the JSON API, since gRPC responses
+	// have different sizes. The chaff middleware's response body is discarded
+	// and the call returns an empty message.
+	verifyChaffTracker, err := chaff.NewTracker(chaff.DefaultJSONResponder(), chaff.DefaultCapacity,
+		chaff.WithMaxLatency(cfg.ChaffMaxLatencyMs))
+	if err != nil {
+		return nil, closer, fmt.Errorf("error creating verify chaffer: %w", err)
+	}
+	closer = func() {
+		verifyChaffTracker.Close()
+	}
+
+	certChaffTracker, err := chaff.NewTracker(chaff.DefaultJSONResponder(), chaff.DefaultCapacity,
+		chaff.WithMaxLatency(cfg.ChaffMaxLatencyMs))
+	if err != nil {
+		return nil, closer, fmt.Errorf("error creating cert chaffer: %w", err)
+	}
+	closer = func() {
+		verifyChaffTracker.Close()
+		certChaffTracker.Close()
+	}
+
+	common := []mux.MiddlewareFunc{
+		obs,
+		middleware.PopulateRequestID(h),
+		middleware.PopulateTraceID(),
+		middleware.PopulateLogger(logging.FromContext(ctx)),
+		middleware.Recovery(h),
+		middleware.RequireAPIKey(cacher, db, h, []database.APIKeyType{
+			database.APIKeyTypeDevice,
+		}),
+		middleware.ProcessFirewall(h, "apiserver"),
+	}
+	chain := func(tracker *chaff.Tracker, extra ...mux.MiddlewareFunc) []mux.MiddlewareFunc {
+		c := make([]mux.MiddlewareFunc, 0, len(common)+len(extra)+3)
+		c = append(c, common...)
+		c = append(c,
+			middleware.ProcessChaff(db, tracker, middleware.ChaffHeaderDetector()),
+			middleware.RequireMinimumAppVersion(h),
+			middleware.CheckClientFingerprint(h))
+		return append(c, extra...)
+	}
+
+	deviceServer := grpcapi.New(verifyapiController, certapiController, issueController, cfg.GRPCTrustedProxy)
+	srv := grpc.NewServer(
+		grpc.StatsHandler(&ocgrpc.ServerHandler{}),
+		grpc.UnaryInterceptor(deviceServer.HTTPMiddleware(map[string][]mux.MiddlewareFunc{
+			grpcapi.MethodVerify:      chain(verifyChaffTracker, verifyLimiter.Handle, fairShare.Handle, middleware.AddOperatingSystemFromUserAgent()),
+			grpcapi.MethodCertificate: chain(certChaffTracker, rateLimit, fairShare.Handle),
+			grpcapi.MethodUserReport:  chain(verifyChaffTracker, rateLimit, fairShare.Handle),
+		})),
+	)
+	devicepb.RegisterDeviceVerificationServer(srv, deviceServer)
+	return srv, closer, nil
+}
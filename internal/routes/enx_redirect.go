@@ -25,6 +25,7 @@ import (
 	"github.com/google/exposure-notifications-verification-server/pkg/config"
 	"github.com/google/exposure-notifications-verification-server/pkg/controller"
 	"github.com/google/exposure-notifications-verification-server/pkg/controller/associated"
+	"github.com/google/exposure-notifications-verification-server/pkg/controller/branding"
 	"github.com/google/exposure-notifications-verification-server/pkg/controller/middleware"
 	"github.com/google/exposure-notifications-verification-server/pkg/controller/redirect"
 	"github.com/google/exposure-notifications-verification-server/pkg/controller/userreport"
@@ -116,6 +117,14 @@ func ENXRedirect(
 		r.Path("/robots.txt").Handler(fs)
 	}
 
+	// Agency images uploaded through the realm branding API. These are public
+	// and cacheable, so they are served before the session and host checks.
+	{
+		brandingController := branding.New(&cfg.Issue, db, h)
+		r.Handle("/branding/{realm_id:[0-9]+}/agency-image/{checksum:[0-9a-f]{64}}", brandingController.HandleAgencyImage()).
+			Methods(http.MethodGet, http.MethodHead)
+	}
+
 	// User report web-view configuration.
 	{
 		// Setup sessions
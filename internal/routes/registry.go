@@ -0,0 +1,295 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package routes
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/google/exposure-notifications-verification-server/pkg/rbac"
+
+	"github.com/gorilla/mux"
+)
+
+// Names of the servers whose routes are declared in the registry.
+const (
+	ServerAdminAPI  = "adminapi"
+	ServerAPIServer = "apiserver"
+	ServerUI        = "server"
+)
+
+// AuthRequirement describes how callers of a route are authenticated.
+type AuthRequirement string
+
+const (
+	// AuthNone indicates the route is publicly accessible.
+	AuthNone AuthRequirement = "none"
+
+	// AuthSession indicates the caller must be a signed-in user.
+	AuthSession AuthRequirement = "session"
+
+	// AuthMembership indicates the caller must be a signed-in user with a
+	// membership in the currently-selected realm.
+	AuthMembership AuthRequirement = "membership"
+
+	// AuthSystemAdmin indicates the caller must be a signed-in system
+	// administrator.
+	AuthSystemAdmin AuthRequirement = "system-admin"
+
+	// AuthAdminAPIKey indicates the caller must present an admin API key.
+	AuthAdminAPIKey AuthRequirement = "admin-api-key"
+
+	// AuthDeviceAPIKey indicates the caller must present a device API key.
+	AuthDeviceAPIKey AuthRequirement = "device-api-key"
+
+	// AuthStatsAPIKey indicates the caller must present a stats API key.
+	AuthStatsAPIKey AuthRequirement = "stats-api-key"
+
+	// AuthWebhook indicates the request is authenticated by a signature from
+	// an upstream provider.
+	AuthWebhook AuthRequirement = "webhook"
+
+	// AuthWorker indicates the caller must present an OIDC ID token from the
+	// scheduler, if worker authentication is configured.
+	AuthWorker AuthRequirement = "worker"
+)
+
+// RateLimitClass describes the key by which requests to a route are rate
+// limited.
+type RateLimitClass string
+
+const (
+	// RateLimitNone indicates the route is not rate limited.
+	RateLimitNone RateLimitClass = "none"
+
+	// RateLimitAPIKey indicates requests are limited per API key.
+	RateLimitAPIKey RateLimitClass = "api-key"
+
+	// RateLimitUser indicates requests are limited per signed-in user, falling
+	// back to the client IP address.
+	RateLimitUser RateLimitClass = "user"
+)
+
+// Route is the metadata for a single route. The routers mount handlers by
+// name, so the path, methods, and metadata of a route are declared only once.
+type Route struct {
+	// Name uniquely identifies the route. By convention it is prefixed with the
+	// name of the server (e.g. "adminapi.issue").
+	Name string
+
+	// Server is the name of the server on which the route is mounted. It is
+	// populated by the registry.
+	Server string
+
+	// Methods are the HTTP methods accepted by the route.
+	Methods []string
+
+	// Path is the full path of the route, including any mux variables.
+	Path string
+
+	// Queries are optional mux query pairs that must also match.
+	Queries []string
+
+	// Auth is the authentication requirement for the route.
+	Auth AuthRequirement
+
+	// RateLimit is the rate limit class of the route.
+	RateLimit RateLimitClass
+
+	// APIVersion is the API schema version served by the route, or zero if
+	// the route is not part of a versioned API.
+	APIVersion uint
+
+	// Permissions are the realm permissions required to reach the handler. The
+	// caller must hold at least one of them. Handlers may check additional
+	// permissions depending on the request method.
+	Permissions rbac.Permission
+
+	// RecentAuth indicates the route requires the user to have recently
+	// authenticated.
+	RecentAuth bool
+}
+
+// registry is the set of all declared routes, indexed by name.
+var registry = buildRegistry(map[string][]*Route{
+	ServerAdminAPI:  adminAPIRoutes,
+	ServerAPIServer: apiServerRoutes,
+	ServerUI:        serverRoutes,
+})
+
+// buildRegistry indexes the routes for each server by name and records the
+// server on each route.
+func buildRegistry(servers map[string][]*Route) map[string]*Route {
+	m := make(map[string]*Route)
+	for server, routes := range servers {
+		for _, route := range routes {
+			if _, ok := m[route.Name]; ok {
+				panic(fmt.Sprintf("routes: duplicate route %q", route.Name))
+			}
+			route.Server = server
+			m[route.Name] = route
+		}
+	}
+	return m
+}
+
+// Routes returns all registered routes, sorted by server, path, and name. The
+// returned routes must not be modified.
+func Routes() []*Route {
+	routes := make([]*Route, 0, len(registry))
+	for _, route := range registry {
+		routes = append(routes, route)
+	}
+
+	sort.Slice(routes, func(i, j int) bool {
+		if routes[i].Server != routes[j].Server {
+			return routes[i].Server < routes[j].Server
+		}
+		if routes[i].Path != routes[j].Path {
+			return routes[i].Path < routes[j].Path
+		}
+		return routes[i].Name < routes[j].Name
+	})
+	return routes
+}
+
+// RoutesFor returns the registered routes for the given server.
+func RoutesFor(server string) []*Route {
+	var routes []*Route
+	for _, route := range Routes() {
+		if route.Server == server {
+			routes = append(routes, route)
+		}
+	}
+	return routes
+}
+
+// Lookup returns the route with the given name.
+func Lookup(name string) (*Route, bool) {
+	route, ok := registry[name]
+	return route, ok
+}
+
+// mounter mounts registered routes. It applies the permission and recent
+// authentication middleware declared on each route, and verifies that the
+// router a route is mounted on enforces the route's declared authentication
+// requirement and rate limit class.
+type mounter struct {
+	requirePermissions func(rbac.Permission) mux.MiddlewareFunc
+	requireRecentAuth  mux.MiddlewareFunc
+
+	guards map[*mux.Router]routerGuard
+}
+
+// routerGuard is the authentication requirement and rate limit class enforced
+// by a router's middleware.
+type routerGuard struct {
+	auth      AuthRequirement
+	rateLimit RateLimitClass
+}
+
+// newMounter creates a new mounter. Either middleware may be nil if no routes
+// mounted with it declare permissions or recent authentication.
+func newMounter(requirePermissions func(rbac.Permission) mux.MiddlewareFunc, requireRecentAuth mux.MiddlewareFunc) *mounter {
+	return &mounter{
+		requirePermissions: requirePermissions,
+		requireRecentAuth:  requireRecentAuth,
+		guards:             make(map[*mux.Router]routerGuard),
+	}
+}
+
+// protect records that the middleware on r enforces the given authentication
+// requirement and rate limit class. Routers that were not protected are
+// treated as public and unlimited. It returns r for chaining.
+func (m *mounter) protect(r *mux.Router, auth AuthRequirement, rateLimit RateLimitClass) *mux.Router {
+	m.guards[r] = routerGuard{auth: auth, rateLimit: rateLimit}
+	return r
+}
+
+// handle mounts the handler for the named route onto r, which is mounted at
+// prefix. The handler is wrapped in the permission and recent authentication
+// middleware declared on the route.
+//
+// It panics if the route is not registered, is not beneath prefix, or declares
+// an authentication requirement or rate limit class that r does not enforce,
+// since each is a programming error.
+func (m *mounter) handle(r *mux.Router, prefix, name string, h http.Handler) *mux.Route {
+	route, ok := Lookup(name)
+	if !ok {
+		panic(fmt.Sprintf("routes: %q is not registered", name))
+	}
+	if !strings.HasPrefix(route.Path, prefix) {
+		panic(fmt.Sprintf("routes: %q (%s) is not beneath %q", name, route.Path, prefix))
+	}
+
+	guard, ok := m.guards[r]
+	if !ok {
+		guard = routerGuard{auth: AuthNone, rateLimit: RateLimitNone}
+	}
+	if guard.auth != route.Auth {
+		panic(fmt.Sprintf("routes: %q requires %q auth, but router enforces %q", name, route.Auth, guard.auth))
+	}
+	if guard.rateLimit != route.RateLimit {
+		panic(fmt.Sprintf("routes: %q requires %q rate limit, but router enforces %q", name, route.RateLimit, guard.rateLimit))
+	}
+
+	// Wrap recent authentication first so that permissions are checked before
+	// the user is asked to re-authenticate.
+	if route.RecentAuth {
+		if m.requireRecentAuth == nil {
+			panic(fmt.Sprintf("routes: %q requires recent auth, but no middleware was given", name))
+		}
+		h = m.requireRecentAuth(h)
+	}
+	if route.Permissions != 0 {
+		if m.requirePermissions == nil {
+			panic(fmt.Sprintf("routes: %q requires permissions, but no middleware was given", name))
+		}
+		h = m.requirePermissions(route.Permissions)(h)
+	}
+
+	mr := r.Handle(strings.TrimPrefix(route.Path, prefix), h).Methods(route.Methods...)
+	if len(route.Queries) > 0 {
+		mr = mr.Queries(route.Queries...)
+	}
+	return mr.Name(name)
+}
+
+// checkMounted returns an error if any registered route for the server was not
+// mounted on r.
+func checkMounted(r *mux.Router, server string) error {
+	mounted := make(map[string]struct{})
+	if err := r.Walk(func(route *mux.Route, _ *mux.Router, _ []*mux.Route) error {
+		if name := route.GetName(); name != "" {
+			mounted[name] = struct{}{}
+		}
+		return nil
+	}); err != nil {
+		return fmt.Errorf("failed to walk routes: %w", err)
+	}
+
+	var missing []string
+	for _, route := range RoutesFor(server) {
+		if _, ok := mounted[route.Name]; !ok {
+			missing = append(missing, route.Name)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("registered routes were not mounted: %s", strings.Join(missing, ", "))
+	}
+	return nil
+}
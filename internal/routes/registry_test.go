@@ -0,0 +1,294 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package routes
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/exposure-notifications-verification-server/pkg/rbac"
+
+	"github.com/gorilla/mux"
+)
+
+func TestRoutes(t *testing.T) {
+	t.Parallel()
+
+	routes := Routes()
+	if got, want := len(routes), len(registry); got != want {
+		t.Fatalf("expected %d routes to be %d", got, want)
+	}
+
+	for _, route := range routes {
+		route := route
+
+		t.Run(route.Name, func(t *testing.T) {
+			t.Parallel()
+
+			if !strings.HasPrefix(route.Name, route.Server+".") {
+				t.Errorf("expected name to be prefixed with %q", route.Server+".")
+			}
+			if !strings.HasPrefix(route.Path, "/") {
+				t.Errorf("expected path %q to start with /", route.Path)
+			}
+			if len(route.Methods) == 0 {
+				t.Errorf("expected methods")
+			}
+			if len(route.Queries)%2 != 0 {
+				t.Errorf("expected queries to be pairs, got %q", route.Queries)
+			}
+			if route.Auth == "" {
+				t.Errorf("expected auth requirement")
+			}
+			if route.RateLimit == "" {
+				t.Errorf("expected rate limit class")
+			}
+		})
+	}
+}
+
+// TestRoutes_permissions audits the permissions declared for each route.
+func TestRoutes_permissions(t *testing.T) {
+	t.Parallel()
+
+	for _, route := range Routes() {
+		// Every realm route that can change state must declare the permissions
+		// it requires.
+		if route.Auth == AuthMembership && route.Permissions == 0 {
+			for _, method := range route.Methods {
+				if method != http.MethodGet {
+					t.Errorf("%s: %s requests must declare permissions", route.Name, method)
+				}
+			}
+		}
+
+		// Permissions only apply to realm members.
+		if route.Permissions != 0 && route.Auth != AuthMembership {
+			t.Errorf("%s: permissions require %q auth, got %q", route.Name, AuthMembership, route.Auth)
+		}
+
+		// Recent authentication only applies to signed-in users.
+		if route.RecentAuth && route.Auth != AuthMembership && route.Auth != AuthSystemAdmin {
+			t.Errorf("%s: recent auth requires a signed-in user, got %q", route.Name, route.Auth)
+		}
+
+		// Only API key routes are versioned.
+		switch route.Auth {
+		case AuthAdminAPIKey, AuthDeviceAPIKey, AuthStatsAPIKey:
+			if route.APIVersion == 0 {
+				t.Errorf("%s: expected API version", route.Name)
+			}
+		default:
+			if route.APIVersion != 0 {
+				t.Errorf("%s: expected no API version, got %d", route.Name, route.APIVersion)
+			}
+		}
+	}
+}
+
+func TestRoutesFor(t *testing.T) {
+	t.Parallel()
+
+	for _, server := range []string{ServerAdminAPI, ServerAPIServer, ServerUI} {
+		routes := RoutesFor(server)
+		if len(routes) == 0 {
+			t.Errorf("expected routes for %q", server)
+		}
+		for _, route := range routes {
+			if got, want := route.Server, server; got != want {
+				t.Errorf("expected %q to be %q", got, want)
+			}
+		}
+	}
+}
+
+func TestMounter_Handle(t *testing.T) {
+	t.Parallel()
+
+	teapot := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	})
+
+	// deny returns a middleware that rejects requests with the given code.
+	deny := func(code int) mux.MiddlewareFunc {
+		return func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(code)
+			})
+		}
+	}
+
+	t.Run("mounts", func(t *testing.T) {
+		t.Parallel()
+
+		r := mux.NewRouter()
+		sub := r.PathPrefix("/api").Subrouter()
+
+		m := newMounter(nil, nil)
+		m.protect(sub, AuthAdminAPIKey, RateLimitAPIKey)
+		m.handle(sub, "/api", "adminapi.issue", teapot)
+
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/issue", nil))
+		if got, want := w.Code, http.StatusTeapot; got != want {
+			t.Errorf("expected %d to be %d", got, want)
+		}
+
+		w = httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/issue", nil))
+		if got, want := w.Code, http.StatusMethodNotAllowed; got != want {
+			t.Errorf("expected %d to be %d", got, want)
+		}
+
+		if route := r.Get("adminapi.issue"); route == nil {
+			t.Errorf("expected route to be named")
+		}
+	})
+
+	t.Run("permissions", func(t *testing.T) {
+		t.Parallel()
+
+		var got rbac.Permission
+		m := newMounter(func(p rbac.Permission) mux.MiddlewareFunc {
+			got = p
+			return deny(http.StatusUnauthorized)
+		}, nil)
+
+		r := mux.NewRouter()
+		m.protect(r, AuthMembership, RateLimitUser)
+		m.handle(r, "", "server.apikeys.index", teapot)
+
+		if want := rbac.APIKeyRead; got != want {
+			t.Errorf("expected %v to be %v", got, want)
+		}
+
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/realm/apikeys", nil))
+		if got, want := w.Code, http.StatusUnauthorized; got != want {
+			t.Errorf("expected %d to be %d", got, want)
+		}
+	})
+
+	t.Run("recent_auth", func(t *testing.T) {
+		t.Parallel()
+
+		m := newMounter(func(p rbac.Permission) mux.MiddlewareFunc {
+			return func(next http.Handler) http.Handler { return next }
+		}, deny(http.StatusSeeOther))
+
+		r := mux.NewRouter()
+		m.protect(r, AuthMembership, RateLimitUser)
+		m.handle(r, "", "server.users.delete", teapot)
+		m.handle(r, "", "server.users.show", teapot)
+
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodDelete, "/realm/users/1", nil))
+		if got, want := w.Code, http.StatusSeeOther; got != want {
+			t.Errorf("expected %d to be %d", got, want)
+		}
+
+		w = httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/realm/users/1", nil))
+		if got, want := w.Code, http.StatusTeapot; got != want {
+			t.Errorf("expected %d to be %d", got, want)
+		}
+	})
+
+	panics := []struct {
+		name  string
+		mount func()
+	}{
+		{
+			name: "unregistered",
+			mount: func() {
+				newMounter(nil, nil).handle(mux.NewRouter(), "", "adminapi.nope", teapot)
+			},
+		},
+		{
+			name: "wrong_prefix",
+			mount: func() {
+				r := mux.NewRouter()
+				m := newMounter(nil, nil)
+				m.protect(r, AuthAdminAPIKey, RateLimitAPIKey)
+				m.handle(r, "/nope", "adminapi.issue", teapot)
+			},
+		},
+		{
+			name: "unprotected",
+			mount: func() {
+				newMounter(nil, nil).handle(mux.NewRouter(), "", "adminapi.issue", teapot)
+			},
+		},
+		{
+			name: "wrong_auth",
+			mount: func() {
+				r := mux.NewRouter()
+				m := newMounter(nil, nil)
+				m.protect(r, AuthStatsAPIKey, RateLimitAPIKey)
+				m.handle(r, "", "adminapi.issue", teapot)
+			},
+		},
+		{
+			name: "wrong_rate_limit",
+			mount: func() {
+				r := mux.NewRouter()
+				m := newMounter(nil, nil)
+				m.protect(r, AuthAdminAPIKey, RateLimitNone)
+				m.handle(r, "", "adminapi.issue", teapot)
+			},
+		},
+		{
+			name: "missing_permissions_middleware",
+			mount: func() {
+				r := mux.NewRouter()
+				m := newMounter(nil, nil)
+				m.protect(r, AuthMembership, RateLimitUser)
+				m.handle(r, "", "server.apikeys.index", teapot)
+			},
+		},
+	}
+
+	for _, tc := range panics {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			defer func() {
+				if recover() == nil {
+					t.Errorf("expected panic")
+				}
+			}()
+			tc.mount()
+		})
+	}
+}
+
+func TestCheckMounted(t *testing.T) {
+	t.Parallel()
+
+	r := mux.NewRouter()
+	newMounter(nil, nil).handle(r, "", "apiserver.health", http.NotFoundHandler())
+
+	err := checkMounted(r, ServerAPIServer)
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	if got, want := err.Error(), "apiserver.verify"; !strings.Contains(got, want) {
+		t.Errorf("expected %q to contain %q", got, want)
+	}
+}
@@ -14,4 +14,9 @@
 
 // Package routes defines the routing for services. It's in a central package so
 // it can be shared among tests.
+//
+// The path, methods, and metadata (authentication, rate limiting, API version,
+// and permissions) of each route are declared once in a registry. Routers mount
+// handlers by route name, and the registry can be listed with Routes to
+// generate API documentation or audit permissions.
 package routes
@@ -26,15 +26,22 @@ import (
 	"github.com/google/exposure-notifications-verification-server/pkg/config"
 	"github.com/google/exposure-notifications-verification-server/pkg/controller"
 	"github.com/google/exposure-notifications-verification-server/pkg/controller/admin"
+	"github.com/google/exposure-notifications-verification-server/pkg/controller/announcements"
 	"github.com/google/exposure-notifications-verification-server/pkg/controller/apikey"
+	"github.com/google/exposure-notifications-verification-server/pkg/controller/audits"
+	"github.com/google/exposure-notifications-verification-server/pkg/controller/bulkissue"
 	"github.com/google/exposure-notifications-verification-server/pkg/controller/codes"
+	"github.com/google/exposure-notifications-verification-server/pkg/controller/cspreport"
 	"github.com/google/exposure-notifications-verification-server/pkg/controller/issueapi"
 	"github.com/google/exposure-notifications-verification-server/pkg/controller/jwks"
 	"github.com/google/exposure-notifications-verification-server/pkg/controller/login"
 	"github.com/google/exposure-notifications-verification-server/pkg/controller/middleware"
 	"github.com/google/exposure-notifications-verification-server/pkg/controller/mobileapps"
+	"github.com/google/exposure-notifications-verification-server/pkg/controller/notifications"
+	"github.com/google/exposure-notifications-verification-server/pkg/controller/onboarding"
 	"github.com/google/exposure-notifications-verification-server/pkg/controller/realmadmin"
 	"github.com/google/exposure-notifications-verification-server/pkg/controller/realmkeys"
+	"github.com/google/exposure-notifications-verification-server/pkg/controller/realmstatus"
 	"github.com/google/exposure-notifications-verification-server/pkg/controller/smskeys"
 	"github.com/google/exposure-notifications-verification-server/pkg/controller/stats"
 	"github.com/google/exposure-notifications-verification-server/pkg/controller/user"
@@ -43,6 +50,7 @@ import (
 	"github.com/google/exposure-notifications-verification-server/pkg/database"
 	"github.com/google/exposure-notifications-verification-server/pkg/keyutils"
 	"github.com/google/exposure-notifications-verification-server/pkg/ratelimit/limitware"
+	"github.com/google/exposure-notifications-verification-server/pkg/rbac"
 	"github.com/google/exposure-notifications-verification-server/pkg/render"
 	"github.com/sethvargo/go-limiter"
 
@@ -53,6 +61,222 @@ import (
 	"github.com/gorilla/sessions"
 )
 
+// serverRoutes are the routes served by the UI server.
+var serverRoutes = []*Route{
+	{Name: "server.health", Path: "/health", Methods: []string{http.MethodGet}, Auth: AuthNone, RateLimit: RateLimitNone},
+	{Name: "server.readyz", Path: "/readyz", Methods: []string{http.MethodGet}, Auth: AuthNone, RateLimit: RateLimitNone},
+	{Name: "server.csp-report", Path: "/csp-report", Methods: []string{http.MethodPost}, Auth: AuthNone, RateLimit: RateLimitUser},
+	{Name: "server.jobs.bulk-issue", Path: "/jobs/bulk-issue", Methods: []string{http.MethodGet}, Auth: AuthWorker, RateLimit: RateLimitNone},
+
+	{Name: "server.session", Path: "/session", Methods: []string{http.MethodPost}, Auth: AuthNone, RateLimit: RateLimitUser},
+	{Name: "server.signout", Path: "/signout", Methods: []string{http.MethodGet}, Auth: AuthNone, RateLimit: RateLimitUser},
+	{Name: "server.login", Path: "/", Methods: []string{http.MethodGet}, Auth: AuthNone, RateLimit: RateLimitUser},
+	{Name: "server.login.reset-password", Path: "/login/reset-password", Methods: []string{http.MethodGet}, Auth: AuthNone, RateLimit: RateLimitUser},
+	{Name: "server.login.reset-password.submit", Path: "/login/reset-password", Methods: []string{http.MethodPost}, Auth: AuthNone, RateLimit: RateLimitUser},
+	{Name: "server.login.select-new-password", Path: "/login/manage-account", Queries: []string{"oobCode", "", "mode", "resetPassword"}, Methods: []string{http.MethodGet}, Auth: AuthNone, RateLimit: RateLimitUser},
+	{Name: "server.login.select-new-password.submit", Path: "/login/manage-account", Queries: []string{"oobCode", "", "mode", "resetPassword"}, Methods: []string{http.MethodPost}, Auth: AuthNone, RateLimit: RateLimitUser},
+	{Name: "server.login.receive-verify-email", Path: "/login/manage-account", Queries: []string{"oobCode", "{oobCode:.+}", "mode", "{mode:(?:verifyEmail|recoverEmail)}"}, Methods: []string{http.MethodGet}, Auth: AuthNone, RateLimit: RateLimitUser},
+	{Name: "server.login.reauth", Path: "/login", Methods: []string{http.MethodGet}, Auth: AuthSession, RateLimit: RateLimitUser},
+	{Name: "server.login.reauth.redir", Path: "/login", Queries: []string{"redir", ""}, Methods: []string{http.MethodGet}, Auth: AuthSession, RateLimit: RateLimitUser},
+	{Name: "server.login.post-authenticate", Path: "/login/post-authenticate", Methods: []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch}, Auth: AuthSession, RateLimit: RateLimitUser},
+	{Name: "server.login.select-realm", Path: "/login/select-realm", Methods: []string{http.MethodGet, http.MethodPost}, Auth: AuthSession, RateLimit: RateLimitUser},
+	{Name: "server.login.realms", Path: "/login/realms", Methods: []string{http.MethodGet}, Auth: AuthSession, RateLimit: RateLimitUser},
+	{Name: "server.login.default-realm", Path: "/login/default-realm", Methods: []string{http.MethodPost}, Auth: AuthSession, RateLimit: RateLimitUser},
+	{Name: "server.login.change-password", Path: "/login/change-password", Methods: []string{http.MethodGet}, Auth: AuthSession, RateLimit: RateLimitUser},
+	{Name: "server.login.change-password.submit", Path: "/login/change-password", Methods: []string{http.MethodPost}, Auth: AuthSession, RateLimit: RateLimitUser},
+	{Name: "server.account", Path: "/account", Methods: []string{http.MethodGet}, Auth: AuthSession, RateLimit: RateLimitUser},
+	{Name: "server.login.verify-email", Path: "/login/manage-account", Queries: []string{"mode", "verifyEmail"}, Methods: []string{http.MethodGet}, Auth: AuthSession, RateLimit: RateLimitUser},
+	{Name: "server.login.verify-email.submit", Path: "/login/manage-account", Queries: []string{"mode", "verifyEmail"}, Methods: []string{http.MethodPost}, Auth: AuthSession, RateLimit: RateLimitUser},
+	{Name: "server.login.register-phone", Path: "/login/register-phone", Methods: []string{http.MethodGet}, Auth: AuthSession, RateLimit: RateLimitUser},
+
+	{Name: "server.announcements.dismiss", Path: "/announcements/{id:[0-9]+}/dismiss", Methods: []string{http.MethodPost}, Auth: AuthSession, RateLimit: RateLimitUser},
+
+	{Name: "server.onboarding", Path: "/onboarding/{token}", Methods: []string{http.MethodGet}, Auth: AuthSession, RateLimit: RateLimitUser},
+	{Name: "server.onboarding.update", Path: "/onboarding/{token}", Methods: []string{http.MethodPost}, Auth: AuthSession, RateLimit: RateLimitUser},
+
+	{Name: "server.codes.index", Path: "/codes", Methods: []string{http.MethodGet}, Auth: AuthMembership, RateLimit: RateLimitUser},
+	{Name: "server.codes.index.slash", Path: "/codes/", Methods: []string{http.MethodGet}, Auth: AuthMembership, RateLimit: RateLimitUser},
+	{Name: "server.codes.issue.submit", Path: "/codes/issue", Methods: []string{http.MethodPost}, Auth: AuthMembership, RateLimit: RateLimitUser, Permissions: rbac.CodeIssue},
+	{Name: "server.codes.batch-issue", Path: "/codes/batch-issue", Methods: []string{http.MethodPost}, Auth: AuthMembership, RateLimit: RateLimitUser, Permissions: rbac.CodeBulkIssue},
+	{Name: "server.codes.issue", Path: "/codes/issue", Methods: []string{http.MethodGet}, Auth: AuthMembership, RateLimit: RateLimitUser, Permissions: rbac.CodeIssue},
+	{Name: "server.codes.bulk-issue", Path: "/codes/bulk-issue", Methods: []string{http.MethodGet}, Auth: AuthMembership, RateLimit: RateLimitUser, Permissions: rbac.CodeBulkIssue},
+	{Name: "server.codes.bulk-issue.jobs.create", Path: "/codes/bulk-issue/jobs", Methods: []string{http.MethodPost}, Auth: AuthMembership, RateLimit: RateLimitUser, Permissions: rbac.CodeBulkIssue},
+	{Name: "server.codes.bulk-issue.jobs.show", Path: "/codes/bulk-issue/jobs/{id:[0-9]+}", Methods: []string{http.MethodGet}, Auth: AuthMembership, RateLimit: RateLimitUser, Permissions: rbac.CodeBulkIssue},
+	{Name: "server.codes.bulk-issue.jobs.report", Path: "/codes/bulk-issue/jobs/{id:[0-9]+}/report.csv", Methods: []string{http.MethodGet}, Auth: AuthMembership, RateLimit: RateLimitUser, Permissions: rbac.CodeBulkIssue},
+	{Name: "server.codes.status", Path: "/codes/status", Methods: []string{http.MethodGet}, Auth: AuthMembership, RateLimit: RateLimitUser, Permissions: rbac.CodeRead},
+	{Name: "server.codes.search", Path: "/codes/search", Methods: []string{http.MethodGet}, Auth: AuthMembership, RateLimit: RateLimitUser, Permissions: rbac.CodeRead | rbac.UserRead | rbac.APIKeyRead},
+	{Name: "server.codes.show", Path: "/codes/{uuid}", Methods: []string{http.MethodGet}, Auth: AuthMembership, RateLimit: RateLimitUser, Permissions: rbac.CodeRead},
+	{Name: "server.codes.expire", Path: "/codes/{uuid}/expire", Methods: []string{http.MethodPatch}, Auth: AuthMembership, RateLimit: RateLimitUser, Permissions: rbac.CodeExpire},
+	{Name: "server.codes.transfer", Path: "/codes/{uuid}/transfer", Methods: []string{http.MethodPost}, Auth: AuthMembership, RateLimit: RateLimitUser, Permissions: rbac.CodeExpire},
+	{Name: "server.codes.resend", Path: "/codes/{uuid}/resend", Methods: []string{http.MethodPost}, Auth: AuthMembership, RateLimit: RateLimitUser, Permissions: rbac.CodeIssue},
+
+	{Name: "server.ui-api.csrf", Path: "/ui-api/csrf", Methods: []string{http.MethodGet}, Auth: AuthNone, RateLimit: RateLimitUser},
+	{Name: "server.ui-api.codes.issue", Path: "/ui-api/codes/issue", Methods: []string{http.MethodPost}, Auth: AuthMembership, RateLimit: RateLimitUser, Permissions: rbac.CodeIssue},
+	{Name: "server.ui-api.codes.batch-issue", Path: "/ui-api/codes/batch-issue", Methods: []string{http.MethodPost}, Auth: AuthMembership, RateLimit: RateLimitUser, Permissions: rbac.CodeBulkIssue},
+	{Name: "server.ui-api.realm.template-preview", Path: "/ui-api/realm/template-preview", Methods: []string{http.MethodPost}, Auth: AuthMembership, RateLimit: RateLimitUser, Permissions: rbac.SettingsWrite},
+
+	{Name: "server.mobile-apps.index", Path: "/realm/mobile-apps", Methods: []string{http.MethodGet}, Auth: AuthMembership, RateLimit: RateLimitUser, Permissions: rbac.MobileAppRead},
+	{Name: "server.mobile-apps.create", Path: "/realm/mobile-apps", Methods: []string{http.MethodPost}, Auth: AuthMembership, RateLimit: RateLimitUser, Permissions: rbac.MobileAppWrite},
+	{Name: "server.mobile-apps.new", Path: "/realm/mobile-apps/new", Methods: []string{http.MethodGet}, Auth: AuthMembership, RateLimit: RateLimitUser, Permissions: rbac.MobileAppWrite},
+	{Name: "server.mobile-apps.edit", Path: "/realm/mobile-apps/{id:[0-9]+}/edit", Methods: []string{http.MethodGet}, Auth: AuthMembership, RateLimit: RateLimitUser, Permissions: rbac.MobileAppWrite},
+	{Name: "server.mobile-apps.show", Path: "/realm/mobile-apps/{id:[0-9]+}", Methods: []string{http.MethodGet}, Auth: AuthMembership, RateLimit: RateLimitUser, Permissions: rbac.MobileAppRead},
+	{Name: "server.mobile-apps.update", Path: "/realm/mobile-apps/{id:[0-9]+}", Methods: []string{http.MethodPatch}, Auth: AuthMembership, RateLimit: RateLimitUser, Permissions: rbac.MobileAppWrite},
+	{Name: "server.mobile-apps.disable", Path: "/realm/mobile-apps/{id:[0-9]+}/disable", Methods: []string{http.MethodPatch}, Auth: AuthMembership, RateLimit: RateLimitUser, Permissions: rbac.MobileAppWrite},
+	{Name: "server.mobile-apps.enable", Path: "/realm/mobile-apps/{id:[0-9]+}/enable", Methods: []string{http.MethodPatch}, Auth: AuthMembership, RateLimit: RateLimitUser, Permissions: rbac.MobileAppWrite},
+
+	{Name: "server.apikeys.index", Path: "/realm/apikeys", Methods: []string{http.MethodGet}, Auth: AuthMembership, RateLimit: RateLimitUser, Permissions: rbac.APIKeyRead},
+	{Name: "server.apikeys.create", Path: "/realm/apikeys", Methods: []string{http.MethodPost}, Auth: AuthMembership, RateLimit: RateLimitUser, Permissions: rbac.APIKeyWrite},
+	{Name: "server.apikeys.new", Path: "/realm/apikeys/new", Methods: []string{http.MethodGet}, Auth: AuthMembership, RateLimit: RateLimitUser, Permissions: rbac.APIKeyWrite},
+	{Name: "server.apikeys.edit", Path: "/realm/apikeys/{id:[0-9]+}/edit", Methods: []string{http.MethodGet}, Auth: AuthMembership, RateLimit: RateLimitUser, Permissions: rbac.APIKeyWrite},
+	{Name: "server.apikeys.show", Path: "/realm/apikeys/{id:[0-9]+}", Methods: []string{http.MethodGet}, Auth: AuthMembership, RateLimit: RateLimitUser, Permissions: rbac.APIKeyRead},
+	{Name: "server.apikeys.update", Path: "/realm/apikeys/{id:[0-9]+}", Methods: []string{http.MethodPatch}, Auth: AuthMembership, RateLimit: RateLimitUser, Permissions: rbac.APIKeyWrite},
+	{Name: "server.apikeys.disable", Path: "/realm/apikeys/{id:[0-9]+}/disable", Methods: []string{http.MethodPatch}, Auth: AuthMembership, RateLimit: RateLimitUser, Permissions: rbac.APIKeyWrite},
+	{Name: "server.apikeys.enable", Path: "/realm/apikeys/{id:[0-9]+}/enable", Methods: []string{http.MethodPatch}, Auth: AuthMembership, RateLimit: RateLimitUser, Permissions: rbac.APIKeyWrite},
+	{Name: "server.apikeys.rotate", Path: "/realm/apikeys/{id:[0-9]+}/rotate", Methods: []string{http.MethodPatch}, Auth: AuthMembership, RateLimit: RateLimitUser, Permissions: rbac.APIKeyWrite},
+	{Name: "server.apikeys.callbacks.test", Path: "/realm/apikeys/{id:[0-9]+}/callbacks/test", Methods: []string{http.MethodPost}, Auth: AuthMembership, RateLimit: RateLimitUser, Permissions: rbac.APIKeyWrite},
+	{Name: "server.apikeys.deliveries", Path: "/realm/apikeys/deliveries", Methods: []string{http.MethodGet}, Auth: AuthMembership, RateLimit: RateLimitUser, Permissions: rbac.APIKeyRead},
+	{Name: "server.apikeys.deliveries.show", Path: "/realm/apikeys/deliveries/{id:[0-9]+}", Methods: []string{http.MethodGet}, Auth: AuthMembership, RateLimit: RateLimitUser, Permissions: rbac.APIKeyRead},
+	{Name: "server.apikeys.deliveries.replay", Path: "/realm/apikeys/deliveries/{id:[0-9]+}/replay", Methods: []string{http.MethodPost}, Auth: AuthMembership, RateLimit: RateLimitUser, Permissions: rbac.APIKeyWrite},
+	{Name: "server.apikeys.captures", Path: "/realm/apikeys/captures", Methods: []string{http.MethodGet}, Auth: AuthMembership, RateLimit: RateLimitUser, Permissions: rbac.APIKeyRead},
+	{Name: "server.apikeys.captures.show", Path: "/realm/apikeys/captures/{id:[0-9]+}", Methods: []string{http.MethodGet}, Auth: AuthMembership, RateLimit: RateLimitUser, Permissions: rbac.APIKeyRead},
+
+	{Name: "server.users.index", Path: "/realm/users", Methods: []string{http.MethodGet}, Auth: AuthMembership, RateLimit: RateLimitUser, Permissions: rbac.UserRead},
+	{Name: "server.users.create", Path: "/realm/users", Methods: []string{http.MethodPost}, Auth: AuthMembership, RateLimit: RateLimitUser, Permissions: rbac.UserWrite},
+	{Name: "server.users.new", Path: "/realm/users/new", Methods: []string{http.MethodGet}, Auth: AuthMembership, RateLimit: RateLimitUser, Permissions: rbac.UserWrite},
+	{Name: "server.users.export", Path: "/realm/users/export.csv", Methods: []string{http.MethodGet}, Auth: AuthMembership, RateLimit: RateLimitUser, Permissions: rbac.UserRead},
+	{Name: "server.users.import", Path: "/realm/users/import", Methods: []string{http.MethodGet}, Auth: AuthMembership, RateLimit: RateLimitUser, Permissions: rbac.UserWrite},
+	{Name: "server.users.import.submit", Path: "/realm/users/import", Methods: []string{http.MethodPost}, Auth: AuthMembership, RateLimit: RateLimitUser, Permissions: rbac.UserWrite},
+	{Name: "server.users.bulk-permissions.add", Path: "/realm/users/bulk-permissions/add", Methods: []string{http.MethodPost}, Auth: AuthMembership, RateLimit: RateLimitUser, Permissions: rbac.UserWrite},
+	{Name: "server.users.bulk-permissions.remove", Path: "/realm/users/bulk-permissions/remove", Methods: []string{http.MethodPost}, Auth: AuthMembership, RateLimit: RateLimitUser, Permissions: rbac.UserWrite},
+	{Name: "server.users.edit", Path: "/realm/users/{id:[0-9]+}/edit", Methods: []string{http.MethodGet}, Auth: AuthMembership, RateLimit: RateLimitUser, Permissions: rbac.UserWrite},
+	{Name: "server.users.show", Path: "/realm/users/{id:[0-9]+}", Methods: []string{http.MethodGet}, Auth: AuthMembership, RateLimit: RateLimitUser, Permissions: rbac.UserRead},
+	{Name: "server.users.update", Path: "/realm/users/{id:[0-9]+}", Methods: []string{http.MethodPatch}, Auth: AuthMembership, RateLimit: RateLimitUser, Permissions: rbac.UserWrite},
+	{Name: "server.users.delete", Path: "/realm/users/{id:[0-9]+}", Methods: []string{http.MethodDelete}, Auth: AuthMembership, RateLimit: RateLimitUser, Permissions: rbac.UserWrite, RecentAuth: true},
+	{Name: "server.users.reset-password", Path: "/realm/users/{id:[0-9]+}/reset-password", Methods: []string{http.MethodPost}, Auth: AuthMembership, RateLimit: RateLimitUser, Permissions: rbac.UserWrite},
+
+	{Name: "server.stats.metrics.json", Path: "/stats/metrics.json", Methods: []string{http.MethodGet}, Auth: AuthMembership, RateLimit: RateLimitUser, Permissions: rbac.StatsRead},
+	{Name: "server.stats.realm.csv", Path: "/stats/realm.csv", Methods: []string{http.MethodGet}, Auth: AuthMembership, RateLimit: RateLimitUser, Permissions: rbac.StatsRead},
+	{Name: "server.stats.realm.json", Path: "/stats/realm.json", Methods: []string{http.MethodGet}, Auth: AuthMembership, RateLimit: RateLimitUser, Permissions: rbac.StatsRead},
+	{Name: "server.stats.users.csv", Path: "/stats/realm/users.csv", Methods: []string{http.MethodGet}, Auth: AuthMembership, RateLimit: RateLimitUser, Permissions: rbac.StatsRead},
+	{Name: "server.stats.users.json", Path: "/stats/realm/users.json", Methods: []string{http.MethodGet}, Auth: AuthMembership, RateLimit: RateLimitUser, Permissions: rbac.StatsRead},
+	{Name: "server.stats.user.csv", Path: "/stats/realm/users/{id}.csv", Methods: []string{http.MethodGet}, Auth: AuthMembership, RateLimit: RateLimitUser, Permissions: rbac.StatsRead | rbac.UserRead},
+	{Name: "server.stats.user.json", Path: "/stats/realm/users/{id}.json", Methods: []string{http.MethodGet}, Auth: AuthMembership, RateLimit: RateLimitUser, Permissions: rbac.StatsRead | rbac.UserRead},
+	{Name: "server.stats.api-key.csv", Path: "/stats/realm/api-keys/{id}.csv", Methods: []string{http.MethodGet}, Auth: AuthMembership, RateLimit: RateLimitUser, Permissions: rbac.StatsRead | rbac.APIKeyRead},
+	{Name: "server.stats.api-key.json", Path: "/stats/realm/api-keys/{id}.json", Methods: []string{http.MethodGet}, Auth: AuthMembership, RateLimit: RateLimitUser, Permissions: rbac.StatsRead | rbac.APIKeyRead},
+	{Name: "server.stats.external-issuers.csv", Path: "/stats/realm/external-issuers.csv", Methods: []string{http.MethodGet}, Auth: AuthMembership, RateLimit: RateLimitUser, Permissions: rbac.StatsRead},
+	{Name: "server.stats.external-issuers.json", Path: "/stats/realm/external-issuers.json", Methods: []string{http.MethodGet}, Auth: AuthMembership, RateLimit: RateLimitUser, Permissions: rbac.StatsRead},
+	{Name: "server.stats.external-issuers.issuance.json", Path: "/stats/realm/external-issuers/issuance.json", Methods: []string{http.MethodGet}, Auth: AuthMembership, RateLimit: RateLimitUser, Permissions: rbac.StatsRead},
+	{Name: "server.stats.sms-errors.csv", Path: "/stats/realm/sms-errors.csv", Methods: []string{http.MethodGet}, Auth: AuthMembership, RateLimit: RateLimitUser, Permissions: rbac.StatsRead},
+	{Name: "server.stats.sms-errors.json", Path: "/stats/realm/sms-errors.json", Methods: []string{http.MethodGet}, Auth: AuthMembership, RateLimit: RateLimitUser, Permissions: rbac.StatsRead},
+	{Name: "server.stats.epi-weekly.csv", Path: "/stats/realm/epi-weekly.csv", Methods: []string{http.MethodGet}, Auth: AuthMembership, RateLimit: RateLimitUser, Permissions: rbac.StatsRead},
+	{Name: "server.stats.epi-weekly.json", Path: "/stats/realm/epi-weekly.json", Methods: []string{http.MethodGet}, Auth: AuthMembership, RateLimit: RateLimitUser, Permissions: rbac.StatsRead},
+	{Name: "server.stats.key-server.csv", Path: "/stats/realm/key-server.csv", Methods: []string{http.MethodGet}, Auth: AuthMembership, RateLimit: RateLimitUser, Permissions: rbac.StatsRead | rbac.UserRead},
+	{Name: "server.stats.key-server.json", Path: "/stats/realm/key-server.json", Methods: []string{http.MethodGet}, Auth: AuthMembership, RateLimit: RateLimitUser, Permissions: rbac.StatsRead | rbac.UserRead},
+	{Name: "server.stats.key-server-sources.csv", Path: "/stats/realm/key-server-sources.csv", Methods: []string{http.MethodGet}, Auth: AuthMembership, RateLimit: RateLimitUser, Permissions: rbac.StatsRead | rbac.UserRead},
+	{Name: "server.stats.key-server-sources.json", Path: "/stats/realm/key-server-sources.json", Methods: []string{http.MethodGet}, Auth: AuthMembership, RateLimit: RateLimitUser, Permissions: rbac.StatsRead | rbac.UserRead},
+	{Name: "server.stats.composite.csv", Path: "/stats/realm/composite.csv", Methods: []string{http.MethodGet}, Auth: AuthMembership, RateLimit: RateLimitUser, Permissions: rbac.StatsRead},
+	{Name: "server.stats.composite.json", Path: "/stats/realm/composite.json", Methods: []string{http.MethodGet}, Auth: AuthMembership, RateLimit: RateLimitUser, Permissions: rbac.StatsRead},
+
+	{Name: "server.realm.settings", Path: "/realm/settings", Methods: []string{http.MethodGet, http.MethodPost}, Auth: AuthMembership, RateLimit: RateLimitUser, Permissions: rbac.SettingsRead},
+	{Name: "server.realm.settings.enable-express", Path: "/realm/settings/enable-express", Methods: []string{http.MethodPost}, Auth: AuthMembership, RateLimit: RateLimitUser, Permissions: rbac.SettingsWrite},
+	{Name: "server.realm.settings.disable-express", Path: "/realm/settings/disable-express", Methods: []string{http.MethodPost}, Auth: AuthMembership, RateLimit: RateLimitUser, Permissions: rbac.SettingsWrite},
+	{Name: "server.realm.stats", Path: "/realm/stats", Methods: []string{http.MethodGet}, Auth: AuthMembership, RateLimit: RateLimitUser, Permissions: rbac.StatsRead},
+	{Name: "server.realm.stats.annotations.create", Path: "/realm/stats/annotations", Methods: []string{http.MethodPost}, Auth: AuthMembership, RateLimit: RateLimitUser, Permissions: rbac.SettingsWrite},
+	{Name: "server.realm.stats.annotations.delete", Path: "/realm/stats/annotations/{id:[0-9]+}", Methods: []string{http.MethodDelete}, Auth: AuthMembership, RateLimit: RateLimitUser, Permissions: rbac.SettingsWrite},
+	{Name: "server.realm.events", Path: "/realm/events", Methods: []string{http.MethodGet}, Auth: AuthMembership, RateLimit: RateLimitUser, Permissions: rbac.AuditRead},
+	{Name: "server.realm.access-logs", Path: "/realm/access-logs", Methods: []string{http.MethodGet}, Auth: AuthMembership, RateLimit: RateLimitUser, Permissions: rbac.AuditRead},
+	{Name: "server.realm.user-report-consent", Path: "/realm/user-report-consent", Methods: []string{http.MethodGet, http.MethodPost}, Auth: AuthMembership, RateLimit: RateLimitUser, Permissions: rbac.SettingsRead},
+	{Name: "server.realm.user-report-consent.acceptances", Path: "/realm/user-report-consent/acceptances.csv", Methods: []string{http.MethodGet}, Auth: AuthMembership, RateLimit: RateLimitUser, Permissions: rbac.AuditRead},
+	{Name: "server.realm.checklist", Path: "/realm/checklist", Methods: []string{http.MethodGet}, Auth: AuthMembership, RateLimit: RateLimitUser, Permissions: rbac.SettingsRead},
+	{Name: "server.realm.checklist.json", Path: "/realm/checklist.json", Methods: []string{http.MethodGet}, Auth: AuthMembership, RateLimit: RateLimitUser, Permissions: rbac.SettingsRead},
+
+	{Name: "server.realm.notifications", Path: "/realm/notifications", Methods: []string{http.MethodGet}, Auth: AuthMembership, RateLimit: RateLimitUser, Permissions: rbac.SettingsRead},
+	{Name: "server.realm.notifications.read", Path: "/realm/notifications/{id:[0-9]+}/read", Methods: []string{http.MethodPost}, Auth: AuthMembership, RateLimit: RateLimitUser, Permissions: rbac.SettingsRead},
+	{Name: "server.realm.notifications.read-all", Path: "/realm/notifications/read", Methods: []string{http.MethodPost}, Auth: AuthMembership, RateLimit: RateLimitUser, Permissions: rbac.SettingsRead},
+
+	{Name: "server.realm.keys", Path: "/realm/keys", Methods: []string{http.MethodGet}, Auth: AuthMembership, RateLimit: RateLimitUser, Permissions: rbac.SettingsRead},
+	{Name: "server.realm.keys.destroy", Path: "/realm/keys/{id:[0-9]+}", Methods: []string{http.MethodDelete}, Auth: AuthMembership, RateLimit: RateLimitUser, Permissions: rbac.SettingsWrite, RecentAuth: true},
+	{Name: "server.realm.keys.create", Path: "/realm/keys/create", Methods: []string{http.MethodPost}, Auth: AuthMembership, RateLimit: RateLimitUser, Permissions: rbac.SettingsWrite},
+	{Name: "server.realm.keys.upgrade", Path: "/realm/keys/upgrade", Methods: []string{http.MethodPost}, Auth: AuthMembership, RateLimit: RateLimitUser, Permissions: rbac.SettingsWrite},
+	{Name: "server.realm.keys.automatic", Path: "/realm/keys/automatic", Methods: []string{http.MethodPost}, Auth: AuthMembership, RateLimit: RateLimitUser, Permissions: rbac.SettingsWrite},
+	{Name: "server.realm.keys.manual", Path: "/realm/keys/manual", Methods: []string{http.MethodPost}, Auth: AuthMembership, RateLimit: RateLimitUser, Permissions: rbac.SettingsWrite},
+	{Name: "server.realm.keys.save", Path: "/realm/keys/save", Methods: []string{http.MethodPost}, Auth: AuthMembership, RateLimit: RateLimitUser, Permissions: rbac.SettingsWrite, RecentAuth: true},
+	{Name: "server.realm.keys.activate", Path: "/realm/keys/activate", Methods: []string{http.MethodPost}, Auth: AuthMembership, RateLimit: RateLimitUser, Permissions: rbac.SettingsWrite},
+
+	{Name: "server.realm.sms-keys", Path: "/realm/sms-keys", Methods: []string{http.MethodGet}, Auth: AuthMembership, RateLimit: RateLimitUser, Permissions: rbac.SettingsRead},
+	{Name: "server.realm.sms-keys.create", Path: "/realm/sms-keys", Methods: []string{http.MethodPost}, Auth: AuthMembership, RateLimit: RateLimitUser, Permissions: rbac.SettingsWrite},
+	{Name: "server.realm.sms-keys.enable", Path: "/realm/sms-keys/enable", Methods: []string{http.MethodPut}, Auth: AuthMembership, RateLimit: RateLimitUser, Permissions: rbac.SettingsWrite},
+	{Name: "server.realm.sms-keys.disable", Path: "/realm/sms-keys/disable", Methods: []string{http.MethodPut}, Auth: AuthMembership, RateLimit: RateLimitUser, Permissions: rbac.SettingsWrite},
+	{Name: "server.realm.sms-keys.destroy", Path: "/realm/sms-keys/{id:[0-9]+}", Methods: []string{http.MethodDelete}, Auth: AuthMembership, RateLimit: RateLimitUser, Permissions: rbac.SettingsWrite, RecentAuth: true},
+	{Name: "server.realm.sms-keys.activate", Path: "/realm/sms-keys/activate", Methods: []string{http.MethodPost}, Auth: AuthMembership, RateLimit: RateLimitUser, Permissions: rbac.SettingsWrite},
+
+	{Name: "server.webhooks.twilio", Path: "/webhooks/{realm_id:[0-9]+}/twilio", Methods: []string{http.MethodPost}, Auth: AuthWebhook, RateLimit: RateLimitNone},
+	{Name: "server.webhooks.twilio-inbound", Path: "/webhooks/{realm_id:[0-9]+}/twilio/inbound", Methods: []string{http.MethodPost}, Auth: AuthWebhook, RateLimit: RateLimitNone},
+
+	{Name: "server.jwks", Path: "/jwks/{realm_id:[0-9]+}", Methods: []string{http.MethodGet}, Auth: AuthNone, RateLimit: RateLimitUser},
+
+	{Name: "server.status", Path: "/status/{realm_id:[0-9]+}", Methods: []string{http.MethodGet}, Auth: AuthNone, RateLimit: RateLimitUser},
+	{Name: "server.status.json", Path: "/status/{realm_id:[0-9]+}.json", Methods: []string{http.MethodGet}, Auth: AuthNone, RateLimit: RateLimitUser},
+
+	{Name: "server.admin.index", Path: "/admin", Methods: []string{http.MethodGet}, Auth: AuthSystemAdmin, RateLimit: RateLimitUser},
+	{Name: "server.admin.index.slash", Path: "/admin/", Methods: []string{http.MethodGet}, Auth: AuthSystemAdmin, RateLimit: RateLimitUser},
+	{Name: "server.admin.stats.system", Path: "/admin/stats/system.json", Methods: []string{http.MethodGet}, Auth: AuthSystemAdmin, RateLimit: RateLimitUser},
+	{Name: "server.admin.realms", Path: "/admin/realms", Methods: []string{http.MethodGet}, Auth: AuthSystemAdmin, RateLimit: RateLimitUser},
+	{Name: "server.admin.realms.create", Path: "/admin/realms", Methods: []string{http.MethodPost}, Auth: AuthSystemAdmin, RateLimit: RateLimitUser},
+	{Name: "server.admin.realms.new", Path: "/admin/realms/new", Methods: []string{http.MethodGet}, Auth: AuthSystemAdmin, RateLimit: RateLimitUser},
+	{Name: "server.admin.realms.edit", Path: "/admin/realms/{id:[0-9]+}/edit", Methods: []string{http.MethodGet}, Auth: AuthSystemAdmin, RateLimit: RateLimitUser},
+	{Name: "server.admin.realms.add", Path: "/admin/realms/{realm_id:[0-9]+}/add/{user_id:[0-9]+}", Methods: []string{http.MethodPatch}, Auth: AuthSystemAdmin, RateLimit: RateLimitUser},
+	{Name: "server.admin.realms.remove", Path: "/admin/realms/{realm_id:[0-9]+}/remove/{user_id:[0-9]+}", Methods: []string{http.MethodPatch}, Auth: AuthSystemAdmin, RateLimit: RateLimitUser},
+	{Name: "server.admin.realms.update", Path: "/admin/realms/{id:[0-9]+}", Methods: []string{http.MethodPatch}, Auth: AuthSystemAdmin, RateLimit: RateLimitUser},
+	{Name: "server.admin.realms.export", Path: "/admin/realms/{id:[0-9]+}/export", Methods: []string{http.MethodPost}, Auth: AuthSystemAdmin, RateLimit: RateLimitUser},
+	{Name: "server.admin.realms.notify", Path: "/admin/realms/{id:[0-9]+}/notifications", Methods: []string{http.MethodPost}, Auth: AuthSystemAdmin, RateLimit: RateLimitUser},
+	{Name: "server.admin.realms.impersonate", Path: "/admin/realms/{id:[0-9]+}/impersonate", Methods: []string{http.MethodPost}, Auth: AuthSystemAdmin, RateLimit: RateLimitUser, RecentAuth: true},
+	{Name: "server.admin.realms.impersonate.end", Path: "/admin/realms/{id:[0-9]+}/impersonate", Methods: []string{http.MethodDelete}, Auth: AuthSystemAdmin, RateLimit: RateLimitUser},
+	{Name: "server.admin.key-servers", Path: "/admin/key-servers", Methods: []string{http.MethodGet}, Auth: AuthSystemAdmin, RateLimit: RateLimitUser},
+	{Name: "server.admin.key-servers.create", Path: "/admin/key-servers", Methods: []string{http.MethodPost}, Auth: AuthSystemAdmin, RateLimit: RateLimitUser},
+	{Name: "server.admin.key-servers.new", Path: "/admin/key-servers/new", Methods: []string{http.MethodGet}, Auth: AuthSystemAdmin, RateLimit: RateLimitUser},
+	{Name: "server.admin.key-servers.edit", Path: "/admin/key-servers/{id:[0-9]+}/edit", Methods: []string{http.MethodGet}, Auth: AuthSystemAdmin, RateLimit: RateLimitUser},
+	{Name: "server.admin.key-servers.update", Path: "/admin/key-servers/{id:[0-9]+}", Methods: []string{http.MethodPatch}, Auth: AuthSystemAdmin, RateLimit: RateLimitUser},
+	{Name: "server.admin.user-report", Path: "/admin/user-report", Methods: []string{http.MethodGet}, Auth: AuthSystemAdmin, RateLimit: RateLimitUser},
+	{Name: "server.admin.user-report.purge", Path: "/admin/user-report", Methods: []string{http.MethodDelete}, Auth: AuthSystemAdmin, RateLimit: RateLimitUser, RecentAuth: true},
+	{Name: "server.admin.users", Path: "/admin/users", Methods: []string{http.MethodGet}, Auth: AuthSystemAdmin, RateLimit: RateLimitUser},
+	{Name: "server.admin.users.show", Path: "/admin/users/{id:[0-9]+}", Methods: []string{http.MethodGet}, Auth: AuthSystemAdmin, RateLimit: RateLimitUser},
+	{Name: "server.admin.users.delete", Path: "/admin/users/{id:[0-9]+}", Methods: []string{http.MethodDelete}, Auth: AuthSystemAdmin, RateLimit: RateLimitUser, RecentAuth: true},
+	{Name: "server.admin.users.create", Path: "/admin/users", Methods: []string{http.MethodPost}, Auth: AuthSystemAdmin, RateLimit: RateLimitUser},
+	{Name: "server.admin.users.new", Path: "/admin/users/new", Methods: []string{http.MethodGet}, Auth: AuthSystemAdmin, RateLimit: RateLimitUser},
+	{Name: "server.admin.users.revoke", Path: "/admin/users/{id:[0-9]+}/revoke", Methods: []string{http.MethodDelete}, Auth: AuthSystemAdmin, RateLimit: RateLimitUser, RecentAuth: true},
+	{Name: "server.admin.users.break-glass.request", Path: "/admin/users/{id:[0-9]+}/break-glass", Methods: []string{http.MethodPost}, Auth: AuthSystemAdmin, RateLimit: RateLimitUser, RecentAuth: true},
+	{Name: "server.admin.users.break-glass.approve", Path: "/admin/users/{id:[0-9]+}/break-glass/{activation_id:[0-9]+}/approve", Methods: []string{http.MethodPatch}, Auth: AuthSystemAdmin, RateLimit: RateLimitUser, RecentAuth: true},
+	{Name: "server.admin.users.break-glass.revoke", Path: "/admin/users/{id:[0-9]+}/break-glass/{activation_id:[0-9]+}/revoke", Methods: []string{http.MethodPatch}, Auth: AuthSystemAdmin, RateLimit: RateLimitUser},
+	{Name: "server.admin.mobile-apps", Path: "/admin/mobile-apps", Methods: []string{http.MethodGet}, Auth: AuthSystemAdmin, RateLimit: RateLimitUser},
+	{Name: "server.admin.mobile-apps.show", Path: "/admin/mobile-apps/{id:[0-9]+}", Methods: []string{http.MethodGet}, Auth: AuthSystemAdmin, RateLimit: RateLimitUser},
+	{Name: "server.admin.sms", Path: "/admin/sms", Methods: []string{http.MethodGet, http.MethodPost}, Auth: AuthSystemAdmin, RateLimit: RateLimitUser},
+	{Name: "server.admin.sms.sandbox", Path: "/admin/sms/sandbox", Methods: []string{http.MethodGet}, Auth: AuthSystemAdmin, RateLimit: RateLimitUser},
+	{Name: "server.admin.email", Path: "/admin/email", Methods: []string{http.MethodGet, http.MethodPost}, Auth: AuthSystemAdmin, RateLimit: RateLimitUser},
+	{Name: "server.admin.events", Path: "/admin/events", Methods: []string{http.MethodGet}, Auth: AuthSystemAdmin, RateLimit: RateLimitUser},
+	{Name: "server.admin.events.export.csv", Path: "/admin/events/export.csv", Methods: []string{http.MethodGet}, Auth: AuthSystemAdmin, RateLimit: RateLimitUser},
+	{Name: "server.admin.events.export.ndjson", Path: "/admin/events/export.ndjson", Methods: []string{http.MethodGet}, Auth: AuthSystemAdmin, RateLimit: RateLimitUser},
+	{Name: "server.admin.claim-failures", Path: "/admin/claim-failures", Methods: []string{http.MethodGet}, Auth: AuthSystemAdmin, RateLimit: RateLimitUser},
+	{Name: "server.admin.claim-failures.json", Path: "/admin/claim-failures.json", Methods: []string{http.MethodGet}, Auth: AuthSystemAdmin, RateLimit: RateLimitUser},
+	{Name: "server.admin.timeline", Path: "/admin/timeline", Methods: []string{http.MethodGet}, Auth: AuthSystemAdmin, RateLimit: RateLimitUser},
+	{Name: "server.admin.timeline.json", Path: "/admin/timeline.json", Methods: []string{http.MethodGet}, Auth: AuthSystemAdmin, RateLimit: RateLimitUser},
+	{Name: "server.admin.realm-invitations", Path: "/admin/realm-invitations", Methods: []string{http.MethodGet}, Auth: AuthSystemAdmin, RateLimit: RateLimitUser},
+	{Name: "server.admin.realm-invitations.create", Path: "/admin/realm-invitations", Methods: []string{http.MethodPost}, Auth: AuthSystemAdmin, RateLimit: RateLimitUser},
+	{Name: "server.admin.realm-invitations.new", Path: "/admin/realm-invitations/new", Methods: []string{http.MethodGet}, Auth: AuthSystemAdmin, RateLimit: RateLimitUser},
+	{Name: "server.admin.realm-invitations.show", Path: "/admin/realm-invitations/{id:[0-9]+}", Methods: []string{http.MethodGet}, Auth: AuthSystemAdmin, RateLimit: RateLimitUser},
+	{Name: "server.admin.realm-invitations.approve", Path: "/admin/realm-invitations/{id:[0-9]+}/approve", Methods: []string{http.MethodPatch}, Auth: AuthSystemAdmin, RateLimit: RateLimitUser},
+	{Name: "server.admin.announcements", Path: "/admin/announcements", Methods: []string{http.MethodGet}, Auth: AuthSystemAdmin, RateLimit: RateLimitUser},
+	{Name: "server.admin.announcements.create", Path: "/admin/announcements", Methods: []string{http.MethodPost}, Auth: AuthSystemAdmin, RateLimit: RateLimitUser},
+	{Name: "server.admin.announcements.new", Path: "/admin/announcements/new", Methods: []string{http.MethodGet}, Auth: AuthSystemAdmin, RateLimit: RateLimitUser},
+	{Name: "server.admin.announcements.edit", Path: "/admin/announcements/{id:[0-9]+}/edit", Methods: []string{http.MethodGet}, Auth: AuthSystemAdmin, RateLimit: RateLimitUser},
+	{Name: "server.admin.announcements.update", Path: "/admin/announcements/{id:[0-9]+}", Methods: []string{http.MethodPatch}, Auth: AuthSystemAdmin, RateLimit: RateLimitUser},
+	{Name: "server.admin.stats-corrections", Path: "/admin/stats-corrections", Methods: []string{http.MethodGet}, Auth: AuthSystemAdmin, RateLimit: RateLimitUser},
+	{Name: "server.admin.stats-corrections.create", Path: "/admin/stats-corrections", Methods: []string{http.MethodPost}, Auth: AuthSystemAdmin, RateLimit: RateLimitUser},
+	{Name: "server.admin.caches", Path: "/admin/caches", Methods: []string{http.MethodGet}, Auth: AuthSystemAdmin, RateLimit: RateLimitUser},
+	{Name: "server.admin.caches.clear", Path: "/admin/caches/clear/{id}", Methods: []string{http.MethodPost}, Auth: AuthSystemAdmin, RateLimit: RateLimitUser},
+	{Name: "server.admin.info", Path: "/admin/info", Methods: []string{http.MethodGet}, Auth: AuthSystemAdmin, RateLimit: RateLimitUser},
+}
+
 // Server defines routes for the UI server.
 func Server(
 	ctx context.Context,
@@ -68,7 +292,7 @@ func Server(
 	sessionOpts := &sessions.Options{
 		Domain:   cfg.CookieDomain,
 		MaxAge:   int(cfg.SessionDuration.Seconds()),
-		Secure:   !cfg.DevMode,
+		Secure:   !cfg.Dev.RelaxedCSRF,
 		SameSite: http.SameSiteStrictMode,
 		HttpOnly: true,
 	}
@@ -81,8 +305,14 @@ func Server(
 
 	r.Use(middleware.GzipResponse())
 
-	// Install common security headers
-	r.Use(middleware.SecureHeaders(cfg.DevMode, "html"))
+	// Install common security headers. The Content-Security-Policy can be
+	// replaced for specific routes with middleware.ContentSecurityPolicy.
+	r.Use(middleware.SecureHeaders(cfg.DevMode, "html",
+		middleware.WithContentSecurityPolicy(&middleware.ContentSecurityPolicyConfig{
+			Policy:     middleware.ServerContentSecurityPolicy(cfg.Firebase.AuthDomain),
+			ReportOnly: cfg.CSPReportOnly,
+			ReportURI:  "/csp-report",
+		})))
 
 	// Mount and register static assets before any middleware.
 	{
@@ -122,6 +352,9 @@ func Server(
 	populateLogger := middleware.PopulateLogger(logging.FromContext(ctx))
 	sub.Use(populateLogger)
 
+	// Limit request body sizes
+	sub.Use(middleware.LimitBody(cfg.BodyLimits.Default))
+
 	// Recovery injection
 	recovery := middleware.Recovery(h)
 	sub.Use(recovery)
@@ -156,25 +389,51 @@ func Server(
 	processDebug := middleware.ProcessDebug()
 	sub.Use(processDebug)
 
+	// Realms served on a custom domain are bound by hostname.
+	processCustomDomain := middleware.ProcessCustomDomain(cacher, db, h)
+	sub.Use(processCustomDomain)
+
 	// Sessions
 	requireSession := middleware.RequireSession(sessions, []interface{}{auth.SessionKeyFirebaseCookie}, h)
 	sub.Use(requireSession)
 
-	// Install the CSRF protection middleware.
-	handleCSRF := middleware.HandleCSRF(h)
-	sub.Use(handleCSRF)
+	// Install the CSRF protection middleware. Tokens are issued for every
+	// request, but verification differs for form-based pages and the JSON API
+	// used by the UI.
+	processCSRF := middleware.ProcessCSRF(h)
+	sub.Use(processCSRF)
+
+	// The UI JSON API is forked before form-based CSRF verification so it can
+	// enforce same-origin and double-submit checks instead.
+	uiAPI := sub.PathPrefix("/ui-api").Subrouter()
+	uiAPI.Use(middleware.VerifyCSRFJSON(h, cfg.Dev.RelaxedCSRF))
+
+	// JSON responses never load resources.
+	uiAPI.Use(middleware.ContentSecurityPolicy(&middleware.ContentSecurityPolicyConfig{
+		Policy: "default-src 'none'; frame-ancestors 'none'",
+	}))
+
+	sub = sub.PathPrefix("").Subrouter()
+	sub.Use(middleware.VerifyCSRF(h))
 
 	// Create common middleware
 	requireAuth := middleware.RequireAuth(cacher, authProvider, db, h, cfg.SessionIdleTimeout, cfg.SessionDuration)
 	checkIdleNoAuth := middleware.CheckSessionIdleNoAuth(h, cfg.SessionIdleTimeout)
 	requireEmailVerified := middleware.RequireEmailVerified(authProvider, h)
-	loadCurrentMembership := middleware.LoadCurrentMembership(h)
+	loadCurrentMembership := middleware.LoadCurrentMembership(db, h)
 	requireMembership := middleware.RequireMembership(h)
+	loadAnnouncements := middleware.LoadAnnouncements(cacher, db)
+	loadNotifications := middleware.LoadUnreadNotifications(db)
 	requireSystemAdmin := middleware.RequireSystemAdmin(h)
 	requireMFA := middleware.RequireMFA(authProvider, h)
+	requireRecentAuth := middleware.RequireRecentAuth(authProvider, db, h, cfg.RecentAuthTimeout)
 	processFirewall := middleware.ProcessFirewall(h, "server")
 	rateLimit := httplimiter.Handle
 
+	m := newMounter(func(p rbac.Permission) mux.MiddlewareFunc {
+		return middleware.RequirePermissions(h, p)
+	}, requireRecentAuth)
+
 	// health
 	{
 		// We don't need locales or template parsing, minimize middleware stack by
@@ -184,7 +443,46 @@ func Server(
 		sub.Use(populateLogger)
 		sub.Use(recovery)
 		sub.Use(obs)
-		sub.Handle("/health", controller.HandleHealthz(db, h, cfg.IsMaintenanceMode())).Methods(http.MethodGet)
+		m.handle(sub, "", "server.health", controller.HandleHealthz(db, h, cfg.IsMaintenanceMode()))
+		m.handle(sub, "", "server.readyz", controller.HandleReadyz(h, cfg.Readiness.TimeoutFor,
+			controller.DatabaseProbe(db),
+			controller.CacheProbe(cacher),
+			controller.RateLimiterProbe(limiterStore),
+			controller.KeyManagerProbe(db)))
+	}
+
+	// csp reports - browsers send these without cookies or CSRF tokens.
+	{
+		sub := r.PathPrefix("").Subrouter()
+		sub.Use(populateRequestID)
+		sub.Use(populateLogger)
+		sub.Use(recovery)
+		sub.Use(obs)
+		sub.Use(rateLimit)
+		m.protect(sub, AuthNone, RateLimitUser)
+
+		cspreportController := cspreport.New(h)
+		m.handle(sub, "", "server.csp-report", cspreportController.HandleReport())
+	}
+
+	// bulk issue worker - called by the scheduler without a session.
+	{
+		sub := r.PathPrefix("").Subrouter()
+		sub.Use(populateRequestID)
+		sub.Use(populateLogger)
+		sub.Use(recovery)
+		sub.Use(obs)
+
+		requireWorkerAuth, err := middleware.RequireWorkerAuth(ctx, &cfg.WorkerAuth, h)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create worker auth middleware: %w", err)
+		}
+		sub.Use(requireWorkerAuth)
+		m.protect(sub, AuthWorker, RateLimitNone)
+
+		issueapiController := issueapi.New(cfg, db, limiterStore, smsSigner, h)
+		bulkissueController := bulkissue.New(cfg, db, issueapiController, h)
+		m.handle(sub, "", "server.jobs.bulk-issue", bulkissueController.HandleProcess())
 	}
 
 	{
@@ -192,43 +490,67 @@ func Server(
 		{
 			sub := sub.PathPrefix("").Subrouter()
 			sub.Use(rateLimit)
-			sub.Handle("/session", loginController.HandleCreateSession()).Methods(http.MethodPost)
-			sub.Handle("/signout", loginController.HandleSignOut()).Methods(http.MethodGet)
+			m.protect(sub, AuthNone, RateLimitUser)
+			m.handle(sub, "", "server.session", loginController.HandleCreateSession())
+			m.handle(sub, "", "server.signout", loginController.HandleSignOut())
 
 			sub = sub.PathPrefix("").Subrouter()
 			sub.Use(rateLimit)
 			sub.Use(checkIdleNoAuth)
+			m.protect(sub, AuthNone, RateLimitUser)
 
-			sub.Handle("/", loginController.HandleLogin()).Methods(http.MethodGet)
-			sub.Handle("/login/reset-password", loginController.HandleShowResetPassword()).Methods(http.MethodGet)
-			sub.Handle("/login/reset-password", loginController.HandleSubmitResetPassword()).Methods(http.MethodPost)
-			sub.Handle("/login/manage-account", loginController.HandleShowSelectNewPassword()).
-				Queries("oobCode", "", "mode", "resetPassword").Methods(http.MethodGet)
-			sub.Handle("/login/manage-account", loginController.HandleSubmitNewPassword()).
-				Queries("oobCode", "", "mode", "resetPassword").Methods(http.MethodPost)
-			sub.Handle("/login/manage-account", loginController.HandleReceiveVerifyEmail()).
-				Queries("oobCode", "{oobCode:.+}", "mode", "{mode:(?:verifyEmail|recoverEmail)}").Methods(http.MethodGet)
+			m.handle(sub, "", "server.login", loginController.HandleLogin())
+			m.handle(sub, "", "server.login.reset-password", loginController.HandleShowResetPassword())
+			m.handle(sub, "", "server.login.reset-password.submit", loginController.HandleSubmitResetPassword())
+			m.handle(sub, "", "server.login.select-new-password", loginController.HandleShowSelectNewPassword())
+			m.handle(sub, "", "server.login.select-new-password.submit", loginController.HandleSubmitNewPassword())
+			m.handle(sub, "", "server.login.receive-verify-email", loginController.HandleReceiveVerifyEmail())
 
 			// Realm selection & account settings
 			sub = sub.PathPrefix("").Subrouter()
 			sub.Use(requireAuth)
 			sub.Use(rateLimit)
 			sub.Use(loadCurrentMembership)
-			sub.Handle("/login", loginController.HandleReauth()).Methods(http.MethodGet)
-			sub.Handle("/login", loginController.HandleReauth()).Queries("redir", "").Methods(http.MethodGet)
-			sub.Handle("/login/post-authenticate", loginController.HandlePostAuthenticate()).Methods(http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch)
-			sub.Handle("/login/select-realm", loginController.HandleSelectRealm()).Methods(http.MethodGet, http.MethodPost)
-			sub.Handle("/login/change-password", loginController.HandleShowChangePassword()).Methods(http.MethodGet)
-			sub.Handle("/login/change-password", loginController.HandleSubmitChangePassword()).Methods(http.MethodPost)
-			sub.Handle("/account", loginController.HandleAccountSettings()).Methods(http.MethodGet)
-			sub.Handle("/login/manage-account", loginController.HandleShowVerifyEmail()).
-				Queries("mode", "verifyEmail").Methods(http.MethodGet)
-			sub.Handle("/login/manage-account", loginController.HandleSubmitVerifyEmail()).
-				Queries("mode", "verifyEmail").Methods(http.MethodPost)
-			sub.Handle("/login/register-phone", loginController.HandleRegisterPhone()).Methods(http.MethodGet)
+			m.protect(sub, AuthSession, RateLimitUser)
+			m.handle(sub, "", "server.login.reauth", loginController.HandleReauth())
+			m.handle(sub, "", "server.login.reauth.redir", loginController.HandleReauth())
+			m.handle(sub, "", "server.login.post-authenticate", loginController.HandlePostAuthenticate())
+			m.handle(sub, "", "server.login.select-realm", loginController.HandleSelectRealm())
+			m.handle(sub, "", "server.login.realms", loginController.HandleListRealms())
+			m.handle(sub, "", "server.login.default-realm", loginController.HandleSetDefaultRealm())
+			m.handle(sub, "", "server.login.change-password", loginController.HandleShowChangePassword())
+			m.handle(sub, "", "server.login.change-password.submit", loginController.HandleSubmitChangePassword())
+			m.handle(sub, "", "server.account", loginController.HandleAccountSettings())
+			m.handle(sub, "", "server.login.verify-email", loginController.HandleShowVerifyEmail())
+			m.handle(sub, "", "server.login.verify-email.submit", loginController.HandleSubmitVerifyEmail())
+			m.handle(sub, "", "server.login.register-phone", loginController.HandleRegisterPhone())
 		}
 	}
 
+	// announcements
+	{
+		sub := sub.PathPrefix("/announcements").Subrouter()
+		sub.Use(requireAuth)
+		sub.Use(rateLimit)
+		m.protect(sub, AuthSession, RateLimitUser)
+
+		announcementsController := announcements.New(db, h)
+		m.handle(sub, "/announcements", "server.announcements.dismiss", announcementsController.HandleDismiss())
+	}
+
+	// onboarding - the invitee of a pending realm does not have a membership
+	// until the realm is approved.
+	{
+		sub := sub.PathPrefix("/onboarding").Subrouter()
+		sub.Use(requireAuth)
+		sub.Use(rateLimit)
+		m.protect(sub, AuthSession, RateLimitUser)
+
+		onboardingController := onboarding.New(db, h)
+		m.handle(sub, "/onboarding", "server.onboarding", onboardingController.HandleShow())
+		m.handle(sub, "/onboarding", "server.onboarding.update", onboardingController.HandleUpdate())
+	}
+
 	// codes
 	{
 		sub := sub.PathPrefix("/codes").Subrouter()
@@ -238,18 +560,57 @@ func Server(
 		sub.Use(processFirewall)
 		sub.Use(requireEmailVerified)
 		sub.Use(requireMFA)
+		sub.Use(loadAnnouncements)
+		sub.Use(loadNotifications)
 		sub.Use(rateLimit)
+		m.protect(sub, AuthMembership, RateLimitUser)
 
-		sub.Handle("", http.RedirectHandler("/codes/issue", http.StatusSeeOther)).Methods(http.MethodGet)
-		sub.Handle("/", http.RedirectHandler("/codes/issue", http.StatusSeeOther)).Methods(http.MethodGet)
+		m.handle(sub, "/codes", "server.codes.index", http.RedirectHandler("/codes/issue", http.StatusSeeOther))
+		m.handle(sub, "/codes", "server.codes.index.slash", http.RedirectHandler("/codes/issue", http.StatusSeeOther))
 
 		// API for creating new verification codes. Called via AJAX.
 		issueapiController := issueapi.New(cfg, db, limiterStore, smsSigner, h)
-		sub.Handle("/issue", issueapiController.HandleIssueUI()).Methods(http.MethodPost)
-		sub.Handle("/batch-issue", issueapiController.HandleBatchIssueUI()).Methods(http.MethodPost)
+		m.handle(sub, "/codes", "server.codes.issue.submit", issueapiController.HandleIssueUI())
+		m.handle(sub, "/codes", "server.codes.batch-issue", middleware.LimitBody(cfg.BodyLimits.BatchIssue)(issueapiController.HandleBatchIssueUI()))
+		m.handle(sub, "/codes", "server.codes.resend", issueapiController.HandleResendPage())
 
 		codesController := codes.NewServer(cfg, db, h)
-		codesRoutes(sub, codesController)
+		codesRoutes(m, sub, codesController)
+	}
+
+	// ui-api - same-origin JSON endpoints called by the UI.
+	{
+		sub := uiAPI.PathPrefix("").Subrouter()
+		sub.Use(rateLimit)
+		m.protect(sub, AuthNone, RateLimitUser)
+		m.handle(sub, "/ui-api", "server.ui-api.csrf", middleware.HandleCSRFToken(h))
+
+		sub = uiAPI.PathPrefix("/codes").Subrouter()
+		sub.Use(requireAuth)
+		sub.Use(loadCurrentMembership)
+		sub.Use(requireMembership)
+		sub.Use(processFirewall)
+		sub.Use(requireEmailVerified)
+		sub.Use(requireMFA)
+		sub.Use(rateLimit)
+		m.protect(sub, AuthMembership, RateLimitUser)
+
+		issueapiController := issueapi.New(cfg, db, limiterStore, smsSigner, h)
+		m.handle(sub, "/ui-api/codes", "server.ui-api.codes.issue", issueapiController.HandleIssueUI())
+		m.handle(sub, "/ui-api/codes", "server.ui-api.codes.batch-issue", middleware.LimitBody(cfg.BodyLimits.BatchIssue)(issueapiController.HandleBatchIssueUI()))
+
+		sub = uiAPI.PathPrefix("/realm").Subrouter()
+		sub.Use(requireAuth)
+		sub.Use(loadCurrentMembership)
+		sub.Use(requireMembership)
+		sub.Use(processFirewall)
+		sub.Use(requireEmailVerified)
+		sub.Use(requireMFA)
+		sub.Use(rateLimit)
+		m.protect(sub, AuthMembership, RateLimitUser)
+
+		realmadminController := realmadmin.New(cfg, db, limiterStore, h, cacher)
+		m.handle(sub, "/ui-api/realm", "server.ui-api.realm.template-preview", realmadminController.HandleTemplatePreview())
 	}
 
 	// mobileapp
@@ -261,10 +622,13 @@ func Server(
 		sub.Use(processFirewall)
 		sub.Use(requireEmailVerified)
 		sub.Use(requireMFA)
+		sub.Use(loadAnnouncements)
+		sub.Use(loadNotifications)
 		sub.Use(rateLimit)
+		m.protect(sub, AuthMembership, RateLimitUser)
 
 		mobileappsController := mobileapps.New(db, h)
-		mobileappsRoutes(sub, mobileappsController)
+		mobileappsRoutes(m, sub, mobileappsController)
 	}
 
 	// apikeys
@@ -276,10 +640,13 @@ func Server(
 		sub.Use(processFirewall)
 		sub.Use(requireEmailVerified)
 		sub.Use(requireMFA)
+		sub.Use(loadAnnouncements)
+		sub.Use(loadNotifications)
 		sub.Use(rateLimit)
+		m.protect(sub, AuthMembership, RateLimitUser)
 
 		apikeyController := apikey.New(cacher, db, h)
-		apikeyRoutes(sub, apikeyController)
+		apikeyRoutes(m, sub, apikeyController)
 	}
 
 	// users
@@ -291,10 +658,16 @@ func Server(
 		sub.Use(processFirewall)
 		sub.Use(requireEmailVerified)
 		sub.Use(requireMFA)
+		sub.Use(loadAnnouncements)
+		sub.Use(loadNotifications)
 		sub.Use(rateLimit)
+		m.protect(sub, AuthMembership, RateLimitUser)
+
+		// Only the bulk import endpoint accepts JSON.
+		sub.Use(middleware.LimitBody(cfg.BodyLimits.UserImport))
 
 		userController := user.New(authProvider, cacher, db, h)
-		userRoutes(sub, userController)
+		userRoutes(m, sub, userController)
 	}
 
 	// stats
@@ -306,10 +679,14 @@ func Server(
 		sub.Use(processFirewall)
 		sub.Use(requireEmailVerified)
 		sub.Use(requireMFA)
+		sub.Use(loadAnnouncements)
+		sub.Use(loadNotifications)
 		sub.Use(rateLimit)
+		sub.Use(stats.LinkMetrics("/stats/metrics.json"))
+		m.protect(sub, AuthMembership, RateLimitUser)
 
 		statsController := stats.New(cacher, db, h)
-		statsRoutes(sub, statsController)
+		statsRoutes(m, sub, statsController)
 	}
 
 	// realms
@@ -321,10 +698,13 @@ func Server(
 		sub.Use(processFirewall)
 		sub.Use(requireEmailVerified)
 		sub.Use(requireMFA)
+		sub.Use(loadAnnouncements)
+		sub.Use(loadNotifications)
 		sub.Use(rateLimit)
+		m.protect(sub, AuthMembership, RateLimitUser)
 
 		realmadminController := realmadmin.New(cfg, db, limiterStore, h, cacher)
-		realmadminRoutes(sub, realmadminController)
+		realmadminRoutes(m, sub, realmadminController)
 
 		publicKeyCache, err := keyutils.NewPublicKeyCache(ctx, cacher, cfg.CertificateSigning.PublicKeyCacheDuration)
 		if err != nil {
@@ -332,10 +712,13 @@ func Server(
 		}
 
 		realmkeysController := realmkeys.New(cfg, db, certificateSigner, publicKeyCache, h)
-		realmkeysRoutes(sub, realmkeysController)
+		realmkeysRoutes(m, sub, realmkeysController)
 
 		realmSMSKeysController := smskeys.New(cfg, db, publicKeyCache, h)
-		realmSMSkeysRoutes(sub, realmSMSKeysController)
+		realmSMSkeysRoutes(m, sub, realmSMSKeysController)
+
+		notificationsController := notifications.New(db, h)
+		notificationsRoutes(m, sub, notificationsController)
 	}
 
 	// webhooks
@@ -348,20 +731,35 @@ func Server(
 		sub.Use(recovery)
 		sub.Use(obs)
 
+		// Webhooks authenticate each request in the handler.
+		m.protect(sub, AuthWebhook, RateLimitNone)
+
 		webhooksController := webhooks.New(cacher, db, h)
-		webhooksRoutes(sub, webhooksController)
+		webhooksRoutes(m, sub, webhooksController)
 	}
 
 	// JWKs
 	{
 		sub := sub.PathPrefix("/jwks").Subrouter()
 		sub.Use(rateLimit)
+		m.protect(sub, AuthNone, RateLimitUser)
 
 		jwksController, err := jwks.New(ctx, db, cacher, h)
 		if err != nil {
 			return nil, fmt.Errorf("failed to create jwks controller: %w", err)
 		}
-		jwksRoutes(sub, jwksController)
+		jwksRoutes(m, sub, jwksController)
+	}
+
+	// Realm status pages - public, so realm admins can share them.
+	{
+		sub := sub.PathPrefix("/status").Subrouter()
+		sub.Use(rateLimit)
+		m.protect(sub, AuthNone, RateLimitUser)
+
+		realmstatusController := realmstatus.New(cfg, cacher, db, h)
+		m.handle(sub, "/status", "server.status", realmstatusController.HandleShow())
+		m.handle(sub, "/status", "server.status.json", realmstatusController.HandleShowJSON())
 	}
 
 	// System admin
@@ -371,9 +769,14 @@ func Server(
 		sub.Use(loadCurrentMembership)
 		sub.Use(requireSystemAdmin)
 		sub.Use(rateLimit)
+		m.protect(sub, AuthSystemAdmin, RateLimitUser)
 
 		adminController := admin.New(cfg, cacher, db, authProvider, limiterStore, h)
-		systemAdminRoutes(sub, adminController)
+		systemAdminRoutes(m, sub, adminController)
+
+		auditsController := audits.New(db, h)
+		m.handle(sub, "/admin", "server.admin.events.export.csv", auditsController.HandleSystemExport(audits.TypeCSV))
+		m.handle(sub, "/admin", "server.admin.events.export.ndjson", auditsController.HandleSystemExport(audits.TypeNDJSON))
 	}
 
 	// Blanket handle any missing routes.
@@ -382,6 +785,10 @@ func Server(
 		return
 	})))
 
+	if err := checkMounted(r, ServerUI); err != nil {
+		return nil, err
+	}
+
 	// Wrap the main router in the mutating middleware method. This cannot be
 	// inserted as middleware because gorilla processes the method before
 	// middleware.
@@ -391,157 +798,229 @@ func Server(
 }
 
 // codesRoutes are the routes for checking codes.
-func codesRoutes(r *mux.Router, c *codes.Controller) {
-	r.Handle("/issue", c.HandleIssue()).Methods(http.MethodGet)
-	r.Handle("/bulk-issue", c.HandleBulkIssue()).Methods(http.MethodGet)
-	r.Handle("/status", c.HandleIndex()).Methods(http.MethodGet)
-	r.Handle("/{uuid}", c.HandleShow()).Methods(http.MethodGet)
-	r.Handle("/{uuid}/expire", c.HandleExpirePage()).Methods(http.MethodPatch)
+func codesRoutes(m *mounter, r *mux.Router, c *codes.Controller) {
+	m.handle(r, "/codes", "server.codes.issue", c.HandleIssue())
+	m.handle(r, "/codes", "server.codes.bulk-issue", c.HandleBulkIssue())
+	m.handle(r, "/codes", "server.codes.bulk-issue.jobs.create", c.HandleBulkIssueCreate())
+	m.handle(r, "/codes", "server.codes.bulk-issue.jobs.show", c.HandleBulkIssueShow())
+	m.handle(r, "/codes", "server.codes.bulk-issue.jobs.report", c.HandleBulkIssueReport())
+	m.handle(r, "/codes", "server.codes.status", c.HandleIndex())
+	m.handle(r, "/codes", "server.codes.search", c.HandleSearch())
+	m.handle(r, "/codes", "server.codes.show", c.HandleShow())
+	m.handle(r, "/codes", "server.codes.expire", c.HandleExpirePage())
+	m.handle(r, "/codes", "server.codes.transfer", c.HandleTransfer())
 }
 
 // mobileappsRoutes are the Mobile App routes.
-func mobileappsRoutes(r *mux.Router, c *mobileapps.Controller) {
-	r.Handle("", c.HandleIndex()).Methods(http.MethodGet)
-	r.Handle("", c.HandleCreate()).Methods(http.MethodPost)
-	r.Handle("/new", c.HandleCreate()).Methods(http.MethodGet)
-	r.Handle("/{id:[0-9]+}/edit", c.HandleUpdate()).Methods(http.MethodGet)
-	r.Handle("/{id:[0-9]+}", c.HandleShow()).Methods(http.MethodGet)
-	r.Handle("/{id:[0-9]+}", c.HandleUpdate()).Methods(http.MethodPatch)
-	r.Handle("/{id:[0-9]+}/disable", c.HandleDisable()).Methods(http.MethodPatch)
-	r.Handle("/{id:[0-9]+}/enable", c.HandleEnable()).Methods(http.MethodPatch)
+func mobileappsRoutes(m *mounter, r *mux.Router, c *mobileapps.Controller) {
+	m.handle(r, "/realm/mobile-apps", "server.mobile-apps.index", c.HandleIndex())
+	m.handle(r, "/realm/mobile-apps", "server.mobile-apps.create", c.HandleCreate())
+	m.handle(r, "/realm/mobile-apps", "server.mobile-apps.new", c.HandleCreate())
+	m.handle(r, "/realm/mobile-apps", "server.mobile-apps.edit", c.HandleUpdate())
+	m.handle(r, "/realm/mobile-apps", "server.mobile-apps.show", c.HandleShow())
+	m.handle(r, "/realm/mobile-apps", "server.mobile-apps.update", c.HandleUpdate())
+	m.handle(r, "/realm/mobile-apps", "server.mobile-apps.disable", c.HandleDisable())
+	m.handle(r, "/realm/mobile-apps", "server.mobile-apps.enable", c.HandleEnable())
 }
 
 // apikeyRoutes are the API key routes.
-func apikeyRoutes(r *mux.Router, c *apikey.Controller) {
-	r.Handle("", c.HandleIndex()).Methods(http.MethodGet)
-	r.Handle("", c.HandleCreate()).Methods(http.MethodPost)
-	r.Handle("/new", c.HandleCreate()).Methods(http.MethodGet)
-	r.Handle("/{id:[0-9]+}/edit", c.HandleUpdate()).Methods(http.MethodGet)
-	r.Handle("/{id:[0-9]+}", c.HandleShow()).Methods(http.MethodGet)
-	r.Handle("/{id:[0-9]+}", c.HandleUpdate()).Methods(http.MethodPatch)
-	r.Handle("/{id:[0-9]+}/disable", c.HandleDisable()).Methods(http.MethodPatch)
-	r.Handle("/{id:[0-9]+}/enable", c.HandleEnable()).Methods(http.MethodPatch)
+func apikeyRoutes(m *mounter, r *mux.Router, c *apikey.Controller) {
+	m.handle(r, "/realm/apikeys", "server.apikeys.index", c.HandleIndex())
+	m.handle(r, "/realm/apikeys", "server.apikeys.create", c.HandleCreate())
+	m.handle(r, "/realm/apikeys", "server.apikeys.new", c.HandleCreate())
+	m.handle(r, "/realm/apikeys", "server.apikeys.edit", c.HandleUpdate())
+	m.handle(r, "/realm/apikeys", "server.apikeys.show", c.HandleShow())
+	m.handle(r, "/realm/apikeys", "server.apikeys.update", c.HandleUpdate())
+	m.handle(r, "/realm/apikeys", "server.apikeys.disable", c.HandleDisable())
+	m.handle(r, "/realm/apikeys", "server.apikeys.enable", c.HandleEnable())
+	m.handle(r, "/realm/apikeys", "server.apikeys.rotate", c.HandleRotate())
+	m.handle(r, "/realm/apikeys", "server.apikeys.callbacks.test", c.HandleCallbackTest())
+	m.handle(r, "/realm/apikeys", "server.apikeys.deliveries", c.HandleDeliveries())
+	m.handle(r, "/realm/apikeys", "server.apikeys.deliveries.show", c.HandleDeliveryShow())
+	m.handle(r, "/realm/apikeys", "server.apikeys.deliveries.replay", c.HandleDeliveryReplay())
+	m.handle(r, "/realm/apikeys", "server.apikeys.captures", c.HandleCaptures())
+	m.handle(r, "/realm/apikeys", "server.apikeys.captures.show", c.HandleCaptureShow())
+}
+
+// userRoutes are the user routes. Deleting users requires recent
+// authentication.
+func userRoutes(m *mounter, r *mux.Router, c *user.Controller) {
+	m.handle(r, "/realm/users", "server.users.index", c.HandleIndex())
+	m.handle(r, "/realm/users", "server.users.create", c.HandleCreate())
+	m.handle(r, "/realm/users", "server.users.new", c.HandleCreate())
+	m.handle(r, "/realm/users", "server.users.export", c.HandleExport())
+	m.handle(r, "/realm/users", "server.users.import", c.HandleImport())
+	m.handle(r, "/realm/users", "server.users.import.submit", c.HandleImportBatch())
+	m.handle(r, "/realm/users", "server.users.bulk-permissions.add", c.HandleBulkPermissions(database.BulkPermissionActionAdd))
+	m.handle(r, "/realm/users", "server.users.bulk-permissions.remove", c.HandleBulkPermissions(database.BulkPermissionActionRemove))
+	m.handle(r, "/realm/users", "server.users.edit", c.HandleUpdate())
+	m.handle(r, "/realm/users", "server.users.show", c.HandleShow())
+	m.handle(r, "/realm/users", "server.users.update", c.HandleUpdate())
+	m.handle(r, "/realm/users", "server.users.delete", c.HandleDelete())
+	m.handle(r, "/realm/users", "server.users.reset-password", c.HandleResetPassword())
 }
 
-// userRoutes are the user routes.
-func userRoutes(r *mux.Router, c *user.Controller) {
-	r.Handle("", c.HandleIndex()).Methods(http.MethodGet)
-	r.Handle("", c.HandleCreate()).Methods(http.MethodPost)
-	r.Handle("/new", c.HandleCreate()).Methods(http.MethodGet)
-	r.Handle("/export.csv", c.HandleExport()).Methods(http.MethodGet)
-	r.Handle("/import", c.HandleImport()).Methods(http.MethodGet)
-	r.Handle("/import", c.HandleImportBatch()).Methods(http.MethodPost)
-	r.Handle("/bulk-permissions/add", c.HandleBulkPermissions(database.BulkPermissionActionAdd)).Methods(http.MethodPost)
-	r.Handle("/bulk-permissions/remove", c.HandleBulkPermissions(database.BulkPermissionActionRemove)).Methods(http.MethodPost)
-	r.Handle("/{id:[0-9]+}/edit", c.HandleUpdate()).Methods(http.MethodGet)
-	r.Handle("/{id:[0-9]+}", c.HandleShow()).Methods(http.MethodGet)
-	r.Handle("/{id:[0-9]+}", c.HandleUpdate()).Methods(http.MethodPatch)
-	r.Handle("/{id:[0-9]+}", c.HandleDelete()).Methods(http.MethodDelete)
-	r.Handle("/{id:[0-9]+}/reset-password", c.HandleResetPassword()).Methods(http.MethodPost)
+// realmkeysRoutes are the realm key routes. Destroying keys and changing
+// certificate settings require recent authentication.
+func realmkeysRoutes(m *mounter, r *mux.Router, c *realmkeys.Controller) {
+	m.handle(r, "/realm", "server.realm.keys", c.HandleIndex())
+	m.handle(r, "/realm", "server.realm.keys.destroy", c.HandleDestroy())
+	m.handle(r, "/realm", "server.realm.keys.create", c.HandleCreateKey())
+	m.handle(r, "/realm", "server.realm.keys.upgrade", c.HandleUpgrade())
+	m.handle(r, "/realm", "server.realm.keys.automatic", c.HandleAutomaticRotate())
+	m.handle(r, "/realm", "server.realm.keys.manual", c.HandleManualRotate())
+	m.handle(r, "/realm", "server.realm.keys.save", c.HandleSave())
+	m.handle(r, "/realm", "server.realm.keys.activate", c.HandleActivate())
 }
 
-// realmkeysRoutes are the realm key routes.
-func realmkeysRoutes(r *mux.Router, c *realmkeys.Controller) {
-	r.Handle("/keys", c.HandleIndex()).Methods(http.MethodGet)
-	r.Handle("/keys/{id:[0-9]+}", c.HandleDestroy()).Methods(http.MethodDelete)
-	r.Handle("/keys/create", c.HandleCreateKey()).Methods(http.MethodPost)
-	r.Handle("/keys/upgrade", c.HandleUpgrade()).Methods(http.MethodPost)
-	r.Handle("/keys/automatic", c.HandleAutomaticRotate()).Methods(http.MethodPost)
-	r.Handle("/keys/manual", c.HandleManualRotate()).Methods(http.MethodPost)
-	r.Handle("/keys/save", c.HandleSave()).Methods(http.MethodPost)
-	r.Handle("/keys/activate", c.HandleActivate()).Methods(http.MethodPost)
+// realmSMSkeysRoutes are the realm key routes. Destroying keys requires recent
+// authentication.
+func realmSMSkeysRoutes(m *mounter, r *mux.Router, c *smskeys.Controller) {
+	m.handle(r, "/realm", "server.realm.sms-keys", c.HandleIndex())
+	m.handle(r, "/realm", "server.realm.sms-keys.create", c.HandleCreateKey())
+	m.handle(r, "/realm", "server.realm.sms-keys.enable", c.HandleEnable())
+	m.handle(r, "/realm", "server.realm.sms-keys.disable", c.HandleDisable())
+	m.handle(r, "/realm", "server.realm.sms-keys.destroy", c.HandleDestroy())
+	m.handle(r, "/realm", "server.realm.sms-keys.activate", c.HandleActivate())
 }
 
-// realmSMSkeysRoutes are the realm key routes.
-func realmSMSkeysRoutes(r *mux.Router, c *smskeys.Controller) {
-	r.Handle("/sms-keys", c.HandleIndex()).Methods(http.MethodGet)
-	r.Handle("/sms-keys", c.HandleCreateKey()).Methods(http.MethodPost)
-	r.Handle("/sms-keys/enable", c.HandleEnable()).Methods(http.MethodPut)
-	r.Handle("/sms-keys/disable", c.HandleDisable()).Methods(http.MethodPut)
-	r.Handle("/sms-keys/{id:[0-9]+}", c.HandleDestroy()).Methods(http.MethodDelete)
-	r.Handle("/sms-keys/activate", c.HandleActivate()).Methods(http.MethodPost)
+// notificationsRoutes are the realm notification center routes.
+func notificationsRoutes(m *mounter, r *mux.Router, c *notifications.Controller) {
+	m.handle(r, "/realm", "server.realm.notifications", c.HandleIndex())
+	m.handle(r, "/realm", "server.realm.notifications.read", c.HandleMarkRead())
+	m.handle(r, "/realm", "server.realm.notifications.read-all", c.HandleMarkAllRead())
 }
 
 // statsRoutes are the statistics routes, rooted at /stats.
-func statsRoutes(r *mux.Router, c *stats.Controller) {
-	r.Handle("/realm.csv", c.HandleRealmStats(stats.TypeCSV)).Methods(http.MethodGet)
-	r.Handle("/realm.json", c.HandleRealmStats(stats.TypeJSON)).Methods(http.MethodGet)
+func statsRoutes(m *mounter, r *mux.Router, c *stats.Controller) {
+	m.handle(r, "/stats", "server.stats.metrics.json", c.HandleMetrics())
+
+	m.handle(r, "/stats", "server.stats.realm.csv", c.HandleRealmStats(stats.TypeCSV))
+	m.handle(r, "/stats", "server.stats.realm.json", c.HandleRealmStats(stats.TypeJSON))
+
+	m.handle(r, "/stats", "server.stats.users.csv", c.HandleRealmUsersStats(stats.TypeCSV))
+	m.handle(r, "/stats", "server.stats.users.json", c.HandleRealmUsersStats(stats.TypeJSON))
 
-	r.Handle("/realm/users.csv", c.HandleRealmUsersStats(stats.TypeCSV)).Methods(http.MethodGet)
-	r.Handle("/realm/users.json", c.HandleRealmUsersStats(stats.TypeJSON)).Methods(http.MethodGet)
+	m.handle(r, "/stats", "server.stats.user.csv", c.HandleRealmUserStats(stats.TypeCSV))
+	m.handle(r, "/stats", "server.stats.user.json", c.HandleRealmUserStats(stats.TypeJSON))
 
-	r.Handle("/realm/users/{id}.csv", c.HandleRealmUserStats(stats.TypeCSV)).Methods(http.MethodGet)
-	r.Handle("/realm/users/{id}.json", c.HandleRealmUserStats(stats.TypeJSON)).Methods(http.MethodGet)
+	m.handle(r, "/stats", "server.stats.api-key.csv", c.HandleRealmAuthorizedAppStats(stats.TypeCSV))
+	m.handle(r, "/stats", "server.stats.api-key.json", c.HandleRealmAuthorizedAppStats(stats.TypeJSON))
 
-	r.Handle("/realm/api-keys/{id}.csv", c.HandleRealmAuthorizedAppStats(stats.TypeCSV)).Methods(http.MethodGet)
-	r.Handle("/realm/api-keys/{id}.json", c.HandleRealmAuthorizedAppStats(stats.TypeJSON)).Methods(http.MethodGet)
+	m.handle(r, "/stats", "server.stats.external-issuers.csv", c.HandleRealmExternalIssuersStats(stats.TypeCSV))
+	m.handle(r, "/stats", "server.stats.external-issuers.json", c.HandleRealmExternalIssuersStats(stats.TypeJSON))
+	m.handle(r, "/stats", "server.stats.external-issuers.issuance.json", c.HandleRealmExternalIssuersIssuance())
 
-	r.Handle("/realm/external-issuers.csv", c.HandleRealmExternalIssuersStats(stats.TypeCSV)).Methods(http.MethodGet)
-	r.Handle("/realm/external-issuers.json", c.HandleRealmExternalIssuersStats(stats.TypeJSON)).Methods(http.MethodGet)
+	m.handle(r, "/stats", "server.stats.sms-errors.csv", c.HandleRealmSMSErrorStats(stats.TypeCSV))
+	m.handle(r, "/stats", "server.stats.sms-errors.json", c.HandleRealmSMSErrorStats(stats.TypeJSON))
 
-	r.Handle("/realm/sms-errors.csv", c.HandleRealmSMSErrorStats(stats.TypeCSV)).Methods(http.MethodGet)
-	r.Handle("/realm/sms-errors.json", c.HandleRealmSMSErrorStats(stats.TypeJSON)).Methods(http.MethodGet)
+	m.handle(r, "/stats", "server.stats.epi-weekly.csv", c.HandleRealmEpiWeeklyStats(stats.TypeCSV))
+	m.handle(r, "/stats", "server.stats.epi-weekly.json", c.HandleRealmEpiWeeklyStats(stats.TypeJSON))
 
-	r.Handle("/realm/key-server.csv", c.HandleKeyServerStats(stats.TypeCSV)).Methods(http.MethodGet)
-	r.Handle("/realm/key-server.json", c.HandleKeyServerStats(stats.TypeJSON)).Methods(http.MethodGet)
+	m.handle(r, "/stats", "server.stats.key-server.csv", c.HandleKeyServerStats(stats.TypeCSV))
+	m.handle(r, "/stats", "server.stats.key-server.json", c.HandleKeyServerStats(stats.TypeJSON))
+	m.handle(r, "/stats", "server.stats.key-server-sources.csv", c.HandleKeyServerSourceStats(stats.TypeCSV))
+	m.handle(r, "/stats", "server.stats.key-server-sources.json", c.HandleKeyServerSourceStats(stats.TypeJSON))
 
-	r.Handle("/realm/composite.csv", c.HandleComposite(stats.TypeCSV)).Methods(http.MethodGet)
-	r.Handle("/realm/composite.json", c.HandleComposite(stats.TypeJSON)).Methods(http.MethodGet)
+	m.handle(r, "/stats", "server.stats.composite.csv", c.HandleComposite(stats.TypeCSV))
+	m.handle(r, "/stats", "server.stats.composite.json", c.HandleComposite(stats.TypeJSON))
 }
 
 // webhooksRoutes are the webhook routes.
-func webhooksRoutes(r *mux.Router, c *webhooks.Controller) {
-	r.Handle("/{realm_id:[0-9]+}/twilio", c.HandleTwilio()).Methods(http.MethodPost)
+func webhooksRoutes(m *mounter, r *mux.Router, c *webhooks.Controller) {
+	m.handle(r, "/webhooks", "server.webhooks.twilio", c.HandleTwilio())
+	m.handle(r, "/webhooks", "server.webhooks.twilio-inbound", c.HandleTwilioInbound())
 }
 
 // realmadminRoutes are the realm admin routes.
-func realmadminRoutes(r *mux.Router, c *realmadmin.Controller) {
-	r.Handle("/settings", c.HandleSettings()).Methods(http.MethodGet, http.MethodPost)
-	r.Handle("/settings/enable-express", c.HandleEnableExpress()).Methods(http.MethodPost)
-	r.Handle("/settings/disable-express", c.HandleDisableExpress()).Methods(http.MethodPost)
-	r.Handle("/stats", c.HandleStats()).Methods(http.MethodGet)
-	r.Handle("/events", c.HandleEvents()).Methods(http.MethodGet)
+func realmadminRoutes(m *mounter, r *mux.Router, c *realmadmin.Controller) {
+	m.handle(r, "/realm", "server.realm.settings", c.HandleSettings())
+	m.handle(r, "/realm", "server.realm.settings.enable-express", c.HandleEnableExpress())
+	m.handle(r, "/realm", "server.realm.settings.disable-express", c.HandleDisableExpress())
+	m.handle(r, "/realm", "server.realm.stats", c.HandleStats())
+	m.handle(r, "/realm", "server.realm.stats.annotations.create", c.HandleStatsAnnotationCreate())
+	m.handle(r, "/realm", "server.realm.stats.annotations.delete", c.HandleStatsAnnotationDelete())
+	m.handle(r, "/realm", "server.realm.events", c.HandleEvents())
+	m.handle(r, "/realm", "server.realm.access-logs", c.HandleAccessLogs())
+	m.handle(r, "/realm", "server.realm.user-report-consent", c.HandleUserReportConsent())
+	m.handle(r, "/realm", "server.realm.user-report-consent.acceptances", c.HandleUserReportConsentAcceptances())
+	m.handle(r, "/realm", "server.realm.checklist", c.HandleChecklist())
+	m.handle(r, "/realm", "server.realm.checklist.json", c.HandleChecklistJSON())
 }
 
 // jwksRoutes are the JWK routes, rooted at /jwks.
-func jwksRoutes(r *mux.Router, c *jwks.Controller) {
-	r.Handle("/{realm_id:[0-9]+}", c.HandleIndex()).Methods(http.MethodGet)
+func jwksRoutes(m *mounter, r *mux.Router, c *jwks.Controller) {
+	m.handle(r, "/jwks", "server.jwks", c.HandleIndex())
 }
 
-// systemAdminRoutes are the system routes, rooted at /admin.
-func systemAdminRoutes(r *mux.Router, c *admin.Controller) {
+// systemAdminRoutes are the system routes, rooted at /admin. Destructive
+// actions require recent authentication.
+func systemAdminRoutes(m *mounter, r *mux.Router, c *admin.Controller) {
 	// Redirect / to /admin/realms
-	r.Handle("", http.RedirectHandler("/admin/realms", http.StatusSeeOther)).Methods(http.MethodGet)
-	r.Handle("/", http.RedirectHandler("/admin/realms", http.StatusSeeOther)).Methods(http.MethodGet)
-
-	r.Handle("/stats/system.json", c.HandleCodeStats()).Methods(http.MethodGet)
-
-	r.Handle("/realms", c.HandleRealmsIndex()).Methods(http.MethodGet)
-	r.Handle("/realms", c.HandleRealmsCreate()).Methods(http.MethodPost)
-	r.Handle("/realms/new", c.HandleRealmsCreate()).Methods(http.MethodGet)
-	r.Handle("/realms/{id:[0-9]+}/edit", c.HandleRealmsUpdate()).Methods(http.MethodGet)
-	r.Handle("/realms/{realm_id:[0-9]+}/add/{user_id:[0-9]+}", c.HandleRealmsAdd()).Methods(http.MethodPatch)
-	r.Handle("/realms/{realm_id:[0-9]+}/remove/{user_id:[0-9]+}", c.HandleRealmsRemove()).Methods(http.MethodPatch)
-	r.Handle("/realms/{id:[0-9]+}", c.HandleRealmsUpdate()).Methods(http.MethodPatch)
-
-	r.Handle("/user-report", c.HandleUserReportIndex()).Methods(http.MethodGet)
-	r.Handle("/user-report", c.HandleUserReportPurge()).Methods(http.MethodDelete)
-
-	r.Handle("/users", c.HandleUsersIndex()).Methods(http.MethodGet)
-	r.Handle("/users/{id:[0-9]+}", c.HandleUserShow()).Methods(http.MethodGet)
-	r.Handle("/users/{id:[0-9]+}", c.HandleUserDelete()).Methods(http.MethodDelete)
-	r.Handle("/users", c.HandleSystemAdminCreate()).Methods(http.MethodPost)
-	r.Handle("/users/new", c.HandleSystemAdminCreate()).Methods(http.MethodGet)
-	r.Handle("/users/{id:[0-9]+}/revoke", c.HandleSystemAdminRevoke()).Methods(http.MethodDelete)
-
-	r.Handle("/mobile-apps", c.HandleMobileAppsIndex()).Methods(http.MethodGet)
-	r.Handle("/mobile-apps/{id:[0-9]+}", c.HandleMobileAppsShow()).Methods(http.MethodGet)
-	r.Handle("/sms", c.HandleSMSUpdate()).Methods(http.MethodGet, http.MethodPost)
-	r.Handle("/email", c.HandleEmailUpdate()).Methods(http.MethodGet, http.MethodPost)
-	r.Handle("/events", c.HandleEventsShow()).Methods(http.MethodGet)
-
-	r.Handle("/caches", c.HandleCachesIndex()).Methods(http.MethodGet)
-	r.Handle("/caches/clear/{id}", c.HandleCachesClear()).Methods(http.MethodPost)
-
-	r.Handle("/info", c.HandleInfoShow()).Methods(http.MethodGet)
+	m.handle(r, "/admin", "server.admin.index", http.RedirectHandler("/admin/realms", http.StatusSeeOther))
+	m.handle(r, "/admin", "server.admin.index.slash", http.RedirectHandler("/admin/realms", http.StatusSeeOther))
+
+	m.handle(r, "/admin", "server.admin.stats.system", c.HandleCodeStats())
+
+	m.handle(r, "/admin", "server.admin.realms", c.HandleRealmsIndex())
+	m.handle(r, "/admin", "server.admin.realms.create", c.HandleRealmsCreate())
+	m.handle(r, "/admin", "server.admin.realms.new", c.HandleRealmsCreate())
+	m.handle(r, "/admin", "server.admin.realms.edit", c.HandleRealmsUpdate())
+	m.handle(r, "/admin", "server.admin.realms.add", c.HandleRealmsAdd())
+	m.handle(r, "/admin", "server.admin.realms.remove", c.HandleRealmsRemove())
+	m.handle(r, "/admin", "server.admin.realms.update", c.HandleRealmsUpdate())
+	m.handle(r, "/admin", "server.admin.realms.export", c.HandleRealmsExport())
+	m.handle(r, "/admin", "server.admin.realms.notify", c.HandleRealmsNotify())
+	m.handle(r, "/admin", "server.admin.realms.impersonate", c.HandleRealmsImpersonate())
+	m.handle(r, "/admin", "server.admin.realms.impersonate.end", c.HandleRealmsImpersonateEnd())
+
+	m.handle(r, "/admin", "server.admin.realm-invitations", c.HandleRealmInvitationsIndex())
+	m.handle(r, "/admin", "server.admin.realm-invitations.create", c.HandleRealmInvitationsCreate())
+	m.handle(r, "/admin", "server.admin.realm-invitations.new", c.HandleRealmInvitationsCreate())
+	m.handle(r, "/admin", "server.admin.realm-invitations.show", c.HandleRealmInvitationsShow())
+	m.handle(r, "/admin", "server.admin.realm-invitations.approve", c.HandleRealmInvitationsApprove())
+
+	m.handle(r, "/admin", "server.admin.key-servers", c.HandleKeyServersIndex())
+	m.handle(r, "/admin", "server.admin.key-servers.create", c.HandleKeyServersCreate())
+	m.handle(r, "/admin", "server.admin.key-servers.new", c.HandleKeyServersCreate())
+	m.handle(r, "/admin", "server.admin.key-servers.edit", c.HandleKeyServersUpdate())
+	m.handle(r, "/admin", "server.admin.key-servers.update", c.HandleKeyServersUpdate())
+
+	m.handle(r, "/admin", "server.admin.user-report", c.HandleUserReportIndex())
+	m.handle(r, "/admin", "server.admin.user-report.purge", c.HandleUserReportPurge())
+
+	m.handle(r, "/admin", "server.admin.users", c.HandleUsersIndex())
+	m.handle(r, "/admin", "server.admin.users.show", c.HandleUserShow())
+	m.handle(r, "/admin", "server.admin.users.delete", c.HandleUserDelete())
+	m.handle(r, "/admin", "server.admin.users.create", c.HandleSystemAdminCreate())
+	m.handle(r, "/admin", "server.admin.users.new", c.HandleSystemAdminCreate())
+	m.handle(r, "/admin", "server.admin.users.revoke", c.HandleSystemAdminRevoke())
+	m.handle(r, "/admin", "server.admin.users.break-glass.request", c.HandleBreakGlassRequest())
+	m.handle(r, "/admin", "server.admin.users.break-glass.approve", c.HandleBreakGlassApprove())
+	m.handle(r, "/admin", "server.admin.users.break-glass.revoke", c.HandleBreakGlassRevoke())
+
+	m.handle(r, "/admin", "server.admin.mobile-apps", c.HandleMobileAppsIndex())
+	m.handle(r, "/admin", "server.admin.mobile-apps.show", c.HandleMobileAppsShow())
+	m.handle(r, "/admin", "server.admin.sms", c.HandleSMSUpdate())
+	m.handle(r, "/admin", "server.admin.sms.sandbox", c.HandleSMSSandboxIndex())
+	m.handle(r, "/admin", "server.admin.email", c.HandleEmailUpdate())
+	m.handle(r, "/admin", "server.admin.events", c.HandleEventsShow())
+	m.handle(r, "/admin", "server.admin.claim-failures", c.HandleClaimFailuresIndex())
+	m.handle(r, "/admin", "server.admin.claim-failures.json", c.HandleClaimFailuresJSON())
+	m.handle(r, "/admin", "server.admin.timeline", c.HandleTimelineIndex())
+	m.handle(r, "/admin", "server.admin.timeline.json", c.HandleTimelineJSON())
+
+	m.handle(r, "/admin", "server.admin.announcements", c.HandleAnnouncementsIndex())
+	m.handle(r, "/admin", "server.admin.announcements.create", c.HandleAnnouncementsCreate())
+	m.handle(r, "/admin", "server.admin.announcements.new", c.HandleAnnouncementsCreate())
+	m.handle(r, "/admin", "server.admin.announcements.edit", c.HandleAnnouncementsUpdate())
+	m.handle(r, "/admin", "server.admin.announcements.update", c.HandleAnnouncementsUpdate())
+
+	m.handle(r, "/admin", "server.admin.stats-corrections", c.HandleStatsCorrectionsIndex())
+	m.handle(r, "/admin", "server.admin.stats-corrections.create", c.HandleStatsCorrectionsCreate())
+
+	m.handle(r, "/admin", "server.admin.caches", c.HandleCachesIndex())
+	m.handle(r, "/admin", "server.admin.caches.clear", c.HandleCachesClear())
+
+	m.handle(r, "/admin", "server.admin.info", c.HandleInfoShow())
 }
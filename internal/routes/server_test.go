@@ -28,6 +28,7 @@ import (
 	"github.com/google/exposure-notifications-verification-server/pkg/cache"
 	"github.com/google/exposure-notifications-verification-server/pkg/config"
 	"github.com/google/exposure-notifications-verification-server/pkg/database"
+	"github.com/google/exposure-notifications-verification-server/pkg/rbac"
 
 	"github.com/gorilla/mux"
 	"github.com/sethvargo/go-limiter/memorystore"
@@ -67,8 +68,8 @@ func TestServer(t *testing.T) {
 func TestRoutes_codesRoutes(t *testing.T) {
 	t.Parallel()
 
-	m := mux.NewRouter()
-	codesRoutes(m, nil)
+	r := mux.NewRouter()
+	codesRoutes(testMounter(r, AuthMembership, RateLimitUser), r, nil)
 
 	cases := []struct {
 		req  *http.Request
@@ -80,9 +81,23 @@ func TestRoutes_codesRoutes(t *testing.T) {
 		{
 			req: httptest.NewRequest(http.MethodGet, "/bulk-issue", nil),
 		},
+		{
+			req: httptest.NewRequest(http.MethodPost, "/bulk-issue/jobs", nil),
+		},
+		{
+			req:  httptest.NewRequest(http.MethodGet, "/bulk-issue/jobs/12", nil),
+			vars: map[string]string{"id": "12"},
+		},
+		{
+			req:  httptest.NewRequest(http.MethodGet, "/bulk-issue/jobs/12/report.csv", nil),
+			vars: map[string]string{"id": "12"},
+		},
 		{
 			req: httptest.NewRequest(http.MethodGet, "/status", nil),
 		},
+		{
+			req: httptest.NewRequest(http.MethodGet, "/search", nil),
+		},
 		{
 			req: httptest.NewRequest(http.MethodGet, "/aaa-aaa-aaa-aaa", nil),
 		},
@@ -92,15 +107,15 @@ func TestRoutes_codesRoutes(t *testing.T) {
 	}
 
 	for _, tc := range cases {
-		testRoute(t, m, tc.req, tc.vars)
+		testRoute(t, r, tc.req, tc.vars)
 	}
 }
 
 func TestRoutes_mobileappsRoutes(t *testing.T) {
 	t.Parallel()
 
-	m := mux.NewRouter()
-	mobileappsRoutes(m, nil)
+	r := mux.NewRouter()
+	mobileappsRoutes(testMounter(r, AuthMembership, RateLimitUser), r, nil)
 
 	cases := []struct {
 		req  *http.Request
@@ -127,15 +142,15 @@ func TestRoutes_mobileappsRoutes(t *testing.T) {
 	}
 
 	for _, tc := range cases {
-		testRoute(t, m, tc.req, tc.vars)
+		testRoute(t, r, tc.req, tc.vars)
 	}
 }
 
 func TestRoutes_apikeyRoutes(t *testing.T) {
 	t.Parallel()
 
-	m := mux.NewRouter()
-	apikeyRoutes(m, nil)
+	r := mux.NewRouter()
+	apikeyRoutes(testMounter(r, AuthMembership, RateLimitUser), r, nil)
 
 	cases := []struct {
 		req  *http.Request
@@ -159,18 +174,39 @@ func TestRoutes_apikeyRoutes(t *testing.T) {
 		{
 			req: httptest.NewRequest(http.MethodPatch, "/12345/enable", nil),
 		},
+		{
+			req: httptest.NewRequest(http.MethodPatch, "/12345/rotate", nil),
+		},
+		{
+			req: httptest.NewRequest(http.MethodPost, "/12345/callbacks/test", nil),
+		},
+		{
+			req: httptest.NewRequest(http.MethodGet, "/deliveries", nil),
+		},
+		{
+			req: httptest.NewRequest(http.MethodGet, "/deliveries/12345", nil),
+		},
+		{
+			req: httptest.NewRequest(http.MethodPost, "/deliveries/12345/replay", nil),
+		},
+		{
+			req: httptest.NewRequest(http.MethodGet, "/captures", nil),
+		},
+		{
+			req: httptest.NewRequest(http.MethodGet, "/captures/12345", nil),
+		},
 	}
 
 	for _, tc := range cases {
-		testRoute(t, m, tc.req, tc.vars)
+		testRoute(t, r, tc.req, tc.vars)
 	}
 }
 
 func TestRoutes_userRoutes(t *testing.T) {
 	t.Parallel()
 
-	m := mux.NewRouter()
-	userRoutes(m, nil)
+	r := mux.NewRouter()
+	userRoutes(testMounter(r, AuthMembership, RateLimitUser), r, nil)
 
 	cases := []struct {
 		req  *http.Request
@@ -203,15 +239,15 @@ func TestRoutes_userRoutes(t *testing.T) {
 	}
 
 	for _, tc := range cases {
-		testRoute(t, m, tc.req, tc.vars)
+		testRoute(t, r, tc.req, tc.vars)
 	}
 }
 
 func TestRoutes_realmkeysRoutes(t *testing.T) {
 	t.Parallel()
 
-	m := mux.NewRouter()
-	realmkeysRoutes(m, nil)
+	r := mux.NewRouter()
+	realmkeysRoutes(testMounter(r, AuthMembership, RateLimitUser), r, nil)
 
 	cases := []struct {
 		req  *http.Request
@@ -239,15 +275,15 @@ func TestRoutes_realmkeysRoutes(t *testing.T) {
 	}
 
 	for _, tc := range cases {
-		testRoute(t, m, tc.req, tc.vars)
+		testRoute(t, r, tc.req, tc.vars)
 	}
 }
 
 func TestRoutes_realmSMSkeysRoutes(t *testing.T) {
 	t.Parallel()
 
-	m := mux.NewRouter()
-	realmSMSkeysRoutes(m, nil)
+	r := mux.NewRouter()
+	realmSMSkeysRoutes(testMounter(r, AuthMembership, RateLimitUser), r, nil)
 
 	cases := []struct {
 		req  *http.Request
@@ -275,15 +311,15 @@ func TestRoutes_realmSMSkeysRoutes(t *testing.T) {
 	}
 
 	for _, tc := range cases {
-		testRoute(t, m, tc.req, tc.vars)
+		testRoute(t, r, tc.req, tc.vars)
 	}
 }
 
 func TestRoutes_statsRoutes(t *testing.T) {
 	t.Parallel()
 
-	m := mux.NewRouter()
-	statsRoutes(m, nil)
+	r := mux.NewRouter()
+	statsRoutes(testMounter(r, AuthMembership, RateLimitUser), r, nil)
 
 	cases := []struct {
 		req  *http.Request
@@ -319,18 +355,21 @@ func TestRoutes_statsRoutes(t *testing.T) {
 		{
 			req: httptest.NewRequest(http.MethodGet, "/realm/external-issuers.json", nil),
 		},
+		{
+			req: httptest.NewRequest(http.MethodGet, "/realm/external-issuers/issuance.json", nil),
+		},
 	}
 
 	for _, tc := range cases {
-		testRoute(t, m, tc.req, tc.vars)
+		testRoute(t, r, tc.req, tc.vars)
 	}
 }
 
 func TestRoutes_webhooksRoutes(t *testing.T) {
 	t.Parallel()
 
-	m := mux.NewRouter()
-	webhooksRoutes(m, nil)
+	r := mux.NewRouter()
+	webhooksRoutes(testMounter(r, AuthWebhook, RateLimitNone), r, nil)
 
 	cases := []struct {
 		req  *http.Request
@@ -347,15 +386,15 @@ func TestRoutes_webhooksRoutes(t *testing.T) {
 	}
 
 	for _, tc := range cases {
-		testRoute(t, m, tc.req, tc.vars)
+		testRoute(t, r, tc.req, tc.vars)
 	}
 }
 
 func TestRoutes_realmadminRoutes(t *testing.T) {
 	t.Parallel()
 
-	m := mux.NewRouter()
-	realmadminRoutes(m, nil)
+	r := mux.NewRouter()
+	realmadminRoutes(testMounter(r, AuthMembership, RateLimitUser), r, nil)
 
 	cases := []struct {
 		req  *http.Request
@@ -376,21 +415,37 @@ func TestRoutes_realmadminRoutes(t *testing.T) {
 		{
 			req: httptest.NewRequest(http.MethodGet, "/stats", nil),
 		},
+		{
+			req: httptest.NewRequest(http.MethodPost, "/stats/annotations", nil),
+		},
+		{
+			req:  httptest.NewRequest(http.MethodDelete, "/stats/annotations/12345", nil),
+			vars: map[string]string{"id": "12345"},
+		},
 		{
 			req: httptest.NewRequest(http.MethodGet, "/events", nil),
 		},
+		{
+			req: httptest.NewRequest(http.MethodGet, "/access-logs", nil),
+		},
+		{
+			req: httptest.NewRequest(http.MethodGet, "/checklist", nil),
+		},
+		{
+			req: httptest.NewRequest(http.MethodGet, "/checklist.json", nil),
+		},
 	}
 
 	for _, tc := range cases {
-		testRoute(t, m, tc.req, tc.vars)
+		testRoute(t, r, tc.req, tc.vars)
 	}
 }
 
 func TestRoutes_jwksRoutes(t *testing.T) {
 	t.Parallel()
 
-	m := mux.NewRouter()
-	jwksRoutes(m, nil)
+	r := mux.NewRouter()
+	jwksRoutes(testMounter(r, AuthNone, RateLimitUser), r, nil)
 
 	cases := []struct {
 		req  *http.Request
@@ -403,15 +458,15 @@ func TestRoutes_jwksRoutes(t *testing.T) {
 	}
 
 	for _, tc := range cases {
-		testRoute(t, m, tc.req, tc.vars)
+		testRoute(t, r, tc.req, tc.vars)
 	}
 }
 
 func TestRoutes_systemAdminRoutes(t *testing.T) {
 	t.Parallel()
 
-	m := mux.NewRouter()
-	systemAdminRoutes(m, nil)
+	r := mux.NewRouter()
+	systemAdminRoutes(testMounter(r, AuthSystemAdmin, RateLimitUser), r, nil)
 
 	cases := []struct {
 		req  *http.Request
@@ -437,6 +492,18 @@ func TestRoutes_systemAdminRoutes(t *testing.T) {
 			req:  httptest.NewRequest(http.MethodPatch, "/realms/12345", nil),
 			vars: map[string]string{"id": "12345"},
 		},
+		{
+			req:  httptest.NewRequest(http.MethodPost, "/realms/12345/export", nil),
+			vars: map[string]string{"id": "12345"},
+		},
+		{
+			req:  httptest.NewRequest(http.MethodPost, "/realms/12345/impersonate", nil),
+			vars: map[string]string{"id": "12345"},
+		},
+		{
+			req:  httptest.NewRequest(http.MethodDelete, "/realms/12345/impersonate", nil),
+			vars: map[string]string{"id": "12345"},
+		},
 		{
 			req:  httptest.NewRequest(http.MethodPatch, "/realms/12345/add/67890", nil),
 			vars: map[string]string{"realm_id": "12345", "user_id": "67890"},
@@ -445,6 +512,23 @@ func TestRoutes_systemAdminRoutes(t *testing.T) {
 			req:  httptest.NewRequest(http.MethodPatch, "/realms/12345/remove/67890", nil),
 			vars: map[string]string{"realm_id": "12345", "user_id": "67890"},
 		},
+		{
+			req: httptest.NewRequest(http.MethodGet, "/key-servers", nil),
+		},
+		{
+			req: httptest.NewRequest(http.MethodPost, "/key-servers", nil),
+		},
+		{
+			req: httptest.NewRequest(http.MethodGet, "/key-servers/new", nil),
+		},
+		{
+			req:  httptest.NewRequest(http.MethodGet, "/key-servers/12345/edit", nil),
+			vars: map[string]string{"id": "12345"},
+		},
+		{
+			req:  httptest.NewRequest(http.MethodPatch, "/key-servers/12345", nil),
+			vars: map[string]string{"id": "12345"},
+		},
 		{
 			req: httptest.NewRequest(http.MethodGet, "/users", nil),
 		},
@@ -466,18 +550,39 @@ func TestRoutes_systemAdminRoutes(t *testing.T) {
 			req:  httptest.NewRequest(http.MethodDelete, "/users/12345/revoke", nil),
 			vars: map[string]string{"id": "12345"},
 		},
+		{
+			req:  httptest.NewRequest(http.MethodPost, "/users/12345/break-glass", nil),
+			vars: map[string]string{"id": "12345"},
+		},
+		{
+			req:  httptest.NewRequest(http.MethodPatch, "/users/12345/break-glass/678/approve", nil),
+			vars: map[string]string{"id": "12345", "activation_id": "678"},
+		},
+		{
+			req:  httptest.NewRequest(http.MethodPatch, "/users/12345/break-glass/678/revoke", nil),
+			vars: map[string]string{"id": "12345", "activation_id": "678"},
+		},
 		{
 			req: httptest.NewRequest(http.MethodGet, "/mobile-apps", nil),
 		},
 		{
 			req: httptest.NewRequest(http.MethodGet, "/sms", nil),
 		},
+		{
+			req: httptest.NewRequest(http.MethodGet, "/sms/sandbox", nil),
+		},
 		{
 			req: httptest.NewRequest(http.MethodGet, "/email", nil),
 		},
 		{
 			req: httptest.NewRequest(http.MethodGet, "/events", nil),
 		},
+		{
+			req: httptest.NewRequest(http.MethodGet, "/timeline", nil),
+		},
+		{
+			req: httptest.NewRequest(http.MethodGet, "/timeline.json", nil),
+		},
 		{
 			req: httptest.NewRequest(http.MethodGet, "/caches", nil),
 		},
@@ -491,7 +596,7 @@ func TestRoutes_systemAdminRoutes(t *testing.T) {
 	}
 
 	for _, tc := range cases {
-		testRoute(t, m, tc.req, tc.vars)
+		testRoute(t, r, tc.req, tc.vars)
 	}
 }
 
@@ -523,3 +628,19 @@ func testRoute(t *testing.T, m *mux.Router, r *http.Request, vars map[string]str
 		}
 	})
 }
+
+// testMounter returns a mounter for testing route matching. Permission and
+// recent authentication checks pass through, and r is treated as enforcing the
+// given authentication requirement and rate limit class.
+func testMounter(r *mux.Router, auth AuthRequirement, rateLimit RateLimitClass) *mounter {
+	m := newMounter(func(rbac.Permission) mux.MiddlewareFunc {
+		return passthrough
+	}, passthrough)
+	m.protect(r, auth, rateLimit)
+	return m
+}
+
+// passthrough is a middleware that does nothing, for testing route matching.
+func passthrough(next http.Handler) http.Handler {
+	return next
+}
@@ -40,6 +40,40 @@ const (
 
 	// ErrUnparsableRequest indicates that the request could not be correctly parsed.
 	ErrUnparsableRequest = "unparsable_request"
+	// ErrInvalidRequest indicates the request was parsed, but a field or query
+	// parameter has an invalid value.
+	ErrInvalidRequest = "invalid_request"
+	// ErrRequestTooLarge indicates that the request body exceeded the maximum
+	// allowed size for the endpoint.
+	ErrRequestTooLarge = "request_too_large"
+	// ErrInvalidCSRFToken indicates the request was missing a valid CSRF token
+	// or did not originate from the same origin.
+	ErrInvalidCSRFToken = "invalid_csrf_token"
+	// ErrUnsupportedSchemaVersion indicates the client declared an API schema
+	// version the server does not support.
+	ErrUnsupportedSchemaVersion = "unsupported_schema_version"
+	// ErrAppVersionUnsupported indicates the mobile app's reported version is
+	// older than the realm's minimum supported version. The user should be
+	// directed to upgrade their app. Accompanied by an HTTP status of
+	// StatusUpgradeRequired (426).
+	ErrAppVersionUnsupported = "app_version_unsupported"
+	// ErrRateLimited indicates the client exceeded its rate limit. Accompanied
+	// by an HTTP status of StatusTooManyRequests (429) and RateLimit-* headers
+	// that describe when the client may retry.
+	ErrRateLimited = "rate_limited"
+	// ErrLoadShed indicates the server is under heavy load and the caller's realm
+	// or API key is using more than its fair share of capacity. Accompanied by
+	// an HTTP status of StatusServiceUnavailable (503) and a Retry-After header.
+	ErrLoadShed = "load_shed"
+	// ErrUnauthorized indicates the request did not include a valid API key, or
+	// the API key is not permitted to call the endpoint.
+	ErrUnauthorized = "unauthorized"
+	// ErrMissingRealm indicates the request could not be associated with a
+	// realm.
+	ErrMissingRealm = "missing_realm"
+	// ErrNotFound indicates the requested endpoint, or the resource the request
+	// refers to, does not exist.
+	ErrNotFound = "not_found"
 	// ErrInternal indicates some server-side error whose details are opaque to the caller.
 	// this could mean a database or RPC connection drop or some other internal outage.
 	ErrInternal = "internal_server_error"
@@ -61,6 +95,12 @@ const (
 	// ErrInvalidTestType indicates the client says it supports a test type this server doesn't
 	// know about.
 	ErrInvalidTestType = "invalid_test_type"
+	// ErrInvalidPreset indicates the requested issuance preset does not exist in
+	// the realm.
+	ErrInvalidPreset = "invalid_preset"
+	// ErrInvalidExternalCaseID indicates the external case ID is missing but
+	// required, or does not match the realm's pattern.
+	ErrInvalidExternalCaseID = "invalid_external_case_id"
 	// ErrMissingDate indicates the realm requires a date, but none was supplied.
 	ErrMissingDate = "missing_date"
 	// ErrInvalidDate indicates the realm requires a date, but the supplied date
@@ -72,21 +112,73 @@ const (
 	ErrMaintenanceMode = "maintenance_mode"
 	// ErrQuotaExceeded indicates the realm has exceeded its daily allotment of codes.
 	ErrQuotaExceeded = "quota_exceeded"
+	// ErrUserQuotaExceeded indicates the user has exceeded their daily
+	// allotment of codes.
+	ErrUserQuotaExceeded = "user_quota_exceeded"
+	// ErrAPIKeyQuotaExceeded indicates the API key has exceeded its daily
+	// allotment of codes.
+	ErrAPIKeyQuotaExceeded = "api_key_quota_exceeded"
 	// ErrSMSQueueFull indicates that Twilio's SMS queue is full and may not accept more SMS messages to send.
 	ErrSMSQueueFull = "sms_queue_full"
 	// ErrPhoneNumberInvalid indicates the phone number could not be parsed, details in the error message.
 	ErrPhoneNumberInvalid = "phone_number_invalid"
+	// ErrPhoneCountryNotAllowed indicates the phone number belongs to a country
+	// that is not in the realm's list of allowed SMS destinations.
+	ErrPhoneCountryNotAllowed = "phone_country_not_allowed"
+	// ErrPhoneNumberSuppressed indicates the phone number opted out of SMS
+	// messages from the realm.
+	ErrPhoneNumberSuppressed = "phone_number_suppressed"
+	// ErrCodeResendLimit indicates the code was already resent the maximum
+	// number of times. A new code must be issued instead.
+	ErrCodeResendLimit = "code_resend_limit"
+	// ErrSMSNotConfigured indicates a phone number was provided, but the realm
+	// does not have an SMS provider configured.
+	ErrSMSNotConfigured = "sms_not_configured"
+	// ErrBulkIssueDisabled indicates the realm does not allow batch issuing.
+	ErrBulkIssueDisabled = "bulk_issue_disabled"
+	// ErrBatchSizeExceeded indicates the batch contains more codes than the
+	// server allows in a single request.
+	ErrBatchSizeExceeded = "batch_size_exceeded"
 	// ErrSMSFailure indicates that Twilio's responded with a failure.
 	ErrSMSFailure = "sms_failure"
 	// ErrMissingNonce indicates a UserReport request is missing the nonce value.
 	ErrMissingNonce = "missing_nonce"
-	// ErrMissingPhone indicates a UserReport request is missing the phone number.
+	// ErrMissingPhone indicates a UserReport request, or an issue request that
+	// only generates the SMS, is missing the phone number.
 	ErrMissingPhone = "missing_phone"
 
 	// User report specific responses
 	// ErrUserReportTryLater indicates that user report is not allowed right now, which could be for several
 	// reasons: phone number already used, PHA hit self report quota, PHA disabled self report.
 	ErrUserReportTryLater = "user_report_try_later"
+	// ErrUserReportPhoneLimited indicates too many user reports were initiated
+	// for the phone number.
+	ErrUserReportPhoneLimited = "user_report_phone_limited"
+	// ErrUserReportIPLimited indicates too many user reports were initiated from
+	// the client's IP address.
+	ErrUserReportIPLimited = "user_report_ip_limited"
+	// ErrUserReportRealmLimited indicates too many user reports were initiated
+	// for the realm.
+	ErrUserReportRealmLimited = "user_report_realm_limited"
+	// ErrUserReportAppLimited indicates too many user reports were initiated by
+	// the API key.
+	ErrUserReportAppLimited = "user_report_app_limited"
+	// ErrUserReportConsentMismatch indicates the consent version accepted by the
+	// user is not the realm's current version. The client should fetch and
+	// display the current consent text again.
+	ErrUserReportConsentMismatch = "user_report_consent_mismatch"
+	// ErrUserReportNotFound indicates no user report matches the phone number
+	// and nonce of a user report purge request.
+	ErrUserReportNotFound = "user_report_not_found"
+
+	// Callback API responses
+
+	// ErrCallbackEventUnknown indicates the requested callback event does not
+	// exist.
+	ErrCallbackEventUnknown = "callback_event_unknown"
+	// ErrCallbackURLMissing indicates the API key does not have a callback URL
+	// configured.
+	ErrCallbackURLMissing = "callback_url_missing"
 
 	// Certificate API responses
 
@@ -196,6 +288,87 @@ type UserBatchResponse struct {
 	ErrorCode string `json:"errorCode,omitempty"`
 }
 
+// UserRealmsResponse lists the realms of which the current user is a member.
+// This is called by the Web frontend.
+// API is served at /login/realms
+type UserRealmsResponse struct {
+	Realms []*UserRealm `json:"realms"`
+
+	Error     string `json:"error,omitempty"`
+	ErrorCode string `json:"errorCode,omitempty"`
+}
+
+// UserRealm is a single realm membership of the current user.
+type UserRealm struct {
+	ID         uint   `json:"id"`
+	Name       string `json:"name"`
+	RegionCode string `json:"regionCode"`
+
+	// Permissions are the names of the effective permissions the user has on
+	// the realm, including implied permissions.
+	Permissions []string `json:"permissions"`
+
+	// LastActiveAt is the UTC unix seconds timestamp of when the user last
+	// selected the realm, or 0 if they never have.
+	LastActiveAt int64 `json:"lastActiveAt"`
+
+	// Default indicates this is the user's default realm.
+	Default bool `json:"default"`
+
+	// Current indicates this is the realm currently selected in the session.
+	Current bool `json:"current"`
+}
+
+// CSRFTokenResponse is the CSRF token for the current session. The token must
+// be sent in the named header on all mutating requests to the UI JSON API.
+// This is called by the Web frontend.
+// API is served at /ui-api/csrf
+type CSRFTokenResponse struct {
+	Token      string `json:"token"`
+	HeaderName string `json:"headerName"`
+	CookieName string `json:"cookieName"`
+
+	Error     string `json:"error,omitempty"`
+	ErrorCode string `json:"errorCode,omitempty"`
+}
+
+// TemplatePreviewRequest is a request to render a draft SMS or email template
+// with sample data for the current realm. Nothing is saved.
+// This is called by the Web frontend.
+// API is served at /ui-api/realm/template-preview
+type TemplatePreviewRequest struct {
+	// Kind is the kind of template: "sms", "email-invite",
+	// "email-password-reset", or "email-verify".
+	Kind string `json:"kind"`
+
+	// Label is the label of the SMS template. It is ignored for email
+	// templates.
+	Label string `json:"label"`
+
+	// Template is the draft template text.
+	Template string `json:"template"`
+}
+
+// TemplatePreviewResponse is the response for TemplatePreviewRequest.
+type TemplatePreviewResponse struct {
+	// Rendered is the template expanded with sample data.
+	Rendered string `json:"rendered"`
+
+	// Length is the length of the rendered template in characters.
+	Length int `json:"length"`
+
+	// Segments is the number of SMS segments the message will be sent as. It is
+	// omitted for email templates.
+	Segments int `json:"segments,omitempty"`
+
+	// Warnings are validation errors that would prevent the template from being
+	// saved, and other problems with the rendered message.
+	Warnings []string `json:"warnings,omitempty"`
+
+	Error     string `json:"error,omitempty"`
+	ErrorCode string `json:"errorCode,omitempty"`
+}
+
 // IssueCodeRequest defines the parameters to request an new OTP (short term)
 // code. This is called by the Web frontend.
 // API is served at /api/issue
@@ -211,10 +384,24 @@ type IssueCodeRequest struct {
 	Phone            string  `json:"phone"`
 	SMSTemplateLabel string  `json:"smsTemplateLabel"`
 
+	// Optional: Preset is the name of a realm issuance preset. Values from the
+	// preset are used for any of testType, symptomDate, and smsTemplateLabel
+	// that are not provided in the request. The symptom date from the preset is
+	// only used if neither symptomDate nor testDate are provided.
+	Preset string `json:"preset"`
+
 	// Optional: UUID is a handle which allows the issuer to track status
 	// of the issued verification code. If omitted the server will generate the UUID.
 	UUID string `json:"uuid"`
 
+	// Optional: Locale is the BCP-47 language tag (e.g. "es-MX") of the SMS
+	// recipient. If the realm has a localized variant of the SMS template for
+	// the locale or its base language, that variant is sent. If omitted, the
+	// first language in the request's Accept-Language header is used. Without a
+	// match, the variant for the realm's default locale, and then the template
+	// itself, are used.
+	Locale string `json:"locale,omitempty"`
+
 	// ExternalIssuerID is optional information supplied by the API caller to
 	// uniquely identify the entity making this request. This is useful where
 	// callers are using a single API key behind an ERP, or when callers are using
@@ -228,6 +415,13 @@ type IssueCodeRequest struct {
 	// responsibility to do so.
 	ExternalIssuerID string `json:"externalIssuerID"`
 
+	// ExternalCaseID is an optional identifier for the case in an external
+	// system, such as a lab accession number scanned from a barcode. Leading and
+	// trailing whitespace and non-printable characters are removed. If the
+	// realm has an external case ID pattern, the ID must match it. If the realm
+	// requires an external case ID, it must be provided.
+	ExternalCaseID string `json:"externalCaseID,omitempty"`
+
 	// OnlyGenerateSMS is a boolean field which indicates whether the response
 	// should generate and return the SMS message.
 	//
@@ -296,6 +490,104 @@ type BatchIssueCodeResponse struct {
 	ErrorCode string `json:"errorCode,omitempty"`
 }
 
+// CallbackEventBatchIssueCompleted is the callback event sent when a batch
+// issuance completes.
+const CallbackEventBatchIssueCompleted = "batch_issue.completed"
+
+// CallbackNotification is the payload sent to an API key's callback URL when a
+// long-running operation completes. It never includes verification codes;
+// clients should use the UUIDs to look up individual results.
+type CallbackNotification struct {
+	Event       string   `json:"event"`
+	CompletedAt int64    `json:"completedAt"`
+	Succeeded   int      `json:"succeeded"`
+	Failed      int      `json:"failed"`
+	UUIDs       []string `json:"uuids,omitempty"`
+	ErrorCode   string   `json:"errorCode,omitempty"`
+
+	// ImportID is the ID of the user import, for user import events.
+	ImportID uint `json:"importID,omitempty"`
+
+	// Codes are the unclaimed codes, for unclaimed codes report events.
+	Codes []*CodeMetadata `json:"codes,omitempty"`
+
+	// Test is true for notifications sent with the callback test endpoint.
+	// Test notifications contain example data.
+	Test bool `json:"test,omitempty"`
+}
+
+// CallbackEventUserImportCompleted is the callback event sent when a user
+// import has been processed.
+const CallbackEventUserImportCompleted = "user_import.completed"
+
+// CallbackEventUnclaimedCodesReport is the callback event sent daily to API
+// keys that opted in to reports of the codes they issued that have not been
+// claimed.
+const CallbackEventUnclaimedCodesReport = "unclaimed_codes.report"
+
+// UserImportRequest is a request to create or update the realm memberships of
+// many users at once. The import is processed asynchronously; use the returned
+// ID to check its status.
+// API is served at /api/users/import
+type UserImportRequest struct {
+	Padding Padding `json:"padding"`
+
+	Users []*UserImportUser `json:"users"`
+}
+
+// UserImportUser is a single user to import.
+type UserImportUser struct {
+	Email string `json:"email"`
+	Name  string `json:"name"`
+
+	// Permissions are the names of the permissions to grant, for example
+	// "CodeIssue". Only code and statistics permissions can be granted by an
+	// import. If empty, the code permissions are granted.
+	Permissions []string `json:"permissions,omitempty"`
+}
+
+// UserImportStatusRequest is a request for the status of a user import.
+// API is served at /api/users/import/status
+type UserImportStatusRequest struct {
+	Padding Padding `json:"padding"`
+
+	ID uint `json:"id"`
+}
+
+// UserImportFailure is the reason a single user could not be imported.
+type UserImportFailure struct {
+	Email string `json:"email"`
+	Error string `json:"error"`
+}
+
+// UserImportResponse is the response for UserImportRequest and
+// UserImportStatusRequest.
+type UserImportResponse struct {
+	Padding Padding `json:"padding"`
+
+	// ID is the import's ID.
+	ID uint `json:"id"`
+
+	// Status is one of "pending", "completed", or "failed". A completed import
+	// may still have individual failures.
+	Status string `json:"status"`
+
+	Total   int `json:"total"`
+	Created int `json:"created"`
+	Updated int `json:"updated"`
+	Failed  int `json:"failed"`
+
+	Failures []*UserImportFailure `json:"failures,omitempty"`
+
+	// CreatedAtTimestamp and CompletedAtTimestamp are in UTC seconds since
+	// epoch. CompletedAtTimestamp is 0 while the import is pending.
+	CreatedAtTimestamp   int64 `json:"createdAtTimestamp"`
+	CompletedAtTimestamp int64 `json:"completedAtTimestamp,omitempty"`
+
+	Error     string `json:"error,omitempty"`
+	ErrorCode string `json:"errorCode,omitempty"`
+}
+
 // CheckCodeStatusRequest defines the parameters to request the status for a
 // previously issued OTP code. This is called by the Web frontend.
 // API is served at /api/checkcodestatus
@@ -335,6 +627,48 @@ type ExpireCodeRequest struct {
 	UUID string `json:"uuid"`
 }
 
+// ResendCodeRequest defines the parameters to request that an unclaimed code
+// be sent by SMS again. Only HMACs of codes are stored, so the code is replaced
+// with a new value; the previous value stops working. The code keeps its UUID
+// and expiration.
+// API is served at /api/resendcode
+type ResendCodeRequest struct {
+	Padding Padding `json:"padding"`
+
+	// UUID is the handle of the code to resend.
+	UUID string `json:"uuid"`
+
+	// Phone is the phone number to which to send the code.
+	Phone string `json:"phone"`
+
+	// SMSTemplateLabel is the optional label of the SMS template to use. If
+	// empty, the realm's default template is used.
+	SMSTemplateLabel string `json:"smsTemplateLabel,omitempty"`
+}
+
+// ResendCodeResponse defines the response type for ResendCodeRequest.
+type ResendCodeResponse struct {
+	Padding Padding `json:"padding"`
+
+	// UUID is the handle of the resent code.
+	UUID string `json:"uuid"`
+
+	// ExpiresAtTimestamp represents Unix, seconds since the epoch. Still UTC.
+	// Resending a code does not extend its expiration.
+	ExpiresAtTimestamp int64 `json:"expiresAtTimestamp"`
+
+	// LongExpiresAtTimestamp represents the time when the long code expires, in
+	// UTC seconds since epoch.
+	LongExpiresAtTimestamp int64 `json:"longExpiresAtTimestamp,omitempty"`
+
+	// ResendCount is the number of times the code has been resent, including
+	// this request.
+	ResendCount uint `json:"resendCount"`
+
+	Error     string `json:"error,omitempty"`
+	ErrorCode string `json:"errorCode,omitempty"`
+}
+
 // ExpireCodeResponse defines the response type for ExpireCodeRequest.
 type ExpireCodeResponse struct {
 	Padding Padding `json:"padding"`
@@ -351,6 +685,260 @@ type ExpireCodeResponse struct {
 	ErrorCode string `json:"errorCode,omitempty"`
 }
 
+// RevokeAPIKeyRequest defines the parameters to contain a leaked API key. All
+// unclaimed codes issued by the API key within the time range are expired and
+// the API key is disabled.
+// API is served at /api/revokeapikey
+type RevokeAPIKeyRequest struct {
+	Padding Padding `json:"padding"`
+
+	// AuthorizedAppID is the ID of the API key to revoke. It must belong to the
+	// same realm as the calling API key.
+	AuthorizedAppID uint `json:"authorizedAppID"`
+
+	// StartTimestamp and EndTimestamp bound the issue time of codes to expire,
+	// in UTC seconds since epoch. If EndTimestamp is 0, it defaults to now.
+	StartTimestamp int64 `json:"startTimestamp"`
+	EndTimestamp   int64 `json:"endTimestamp,omitempty"`
+}
+
+// RevokeAPIKeyResponse defines the response type for RevokeAPIKeyRequest.
+type RevokeAPIKeyResponse struct {
+	Padding Padding `json:"padding"`
+
+	// CodesExpired is the number of unclaimed codes that were expired.
+	CodesExpired int64 `json:"codesExpired"`
+
+	// DisabledAtTimestamp is the time at which the API key was disabled, in UTC
+	// seconds since epoch.
+	DisabledAtTimestamp int64 `json:"disabledAtTimestamp"`
+
+	Error     string `json:"error,omitempty"`
+	ErrorCode string `json:"errorCode,omitempty"`
+}
+
+// ListCodesRequest defines the parameters to download metadata for the codes
+// issued in the caller's realm within a time range. The codes themselves are
+// never returned.
+// API is served at /api/listcodes
+type ListCodesRequest struct {
+	Padding Padding `json:"padding"`
+
+	// StartTimestamp and EndTimestamp bound the issue time of codes to list, in
+	// UTC seconds since epoch. If EndTimestamp is 0, it defaults to now.
+	StartTimestamp int64 `json:"startTimestamp"`
+	EndTimestamp   int64 `json:"endTimestamp,omitempty"`
+
+	// Page is the 1-indexed page of results to return. If 0, it defaults to
+	// the first page. Limit is the number of results per page, capped by the
+	// server.
+	Page  uint64 `json:"page,omitempty"`
+	Limit uint64 `json:"limit,omitempty"`
+}
+
+// CodeMetadata is the metadata for a single issued code.
+type CodeMetadata struct {
+	// UUID is a handle which allows the issuer to track status of the issued verification code.
+	UUID string `json:"uuid"`
+
+	// IssuedAtTimestamp is the time the code was issued, in UTC seconds since
+	// epoch.
+	IssuedAtTimestamp int64 `json:"issuedAtTimestamp"`
+
+	Claimed  bool   `json:"claimed"`
+	TestType string `json:"testType"`
+
+	// IssuingUserID, IssuingAppID, and IssuingExternalID identify the issuer of
+	// the code. Only the fields relevant to the issuer are populated.
+	IssuingUserID     uint   `json:"issuingUserID,omitempty"`
+	IssuingAppID      uint   `json:"issuingAppID,omitempty"`
+	IssuingExternalID string `json:"issuingExternalID,omitempty"`
+
+	// ExternalCaseID is the external case ID provided when the code was
+	// issued, if any.
+	ExternalCaseID string `json:"externalCaseID,omitempty"`
+
+	// ExpiresAtTimestamp and LongExpiresAtTimestamp represent the expiry times
+	// of the code and long code, in UTC seconds since epoch.
+	ExpiresAtTimestamp     int64 `json:"expiresAtTimestamp"`
+	LongExpiresAtTimestamp int64 `json:"longExpiresAtTimestamp,omitempty"`
+}
+
+// ListCodesResponse defines the response type for ListCodesRequest.
+type ListCodesResponse struct {
+	Padding Padding `json:"padding"`
+
+	Codes []*CodeMetadata `json:"codes"`
+
+	// NextPage is the page to request for the next set of results. It is 0 if
+	// there are no further results.
+	NextPage uint64 `json:"nextPage,omitempty"`
+
+	Error     string `json:"error,omitempty"`
+	ErrorCode string `json:"errorCode,omitempty"`
+}
+
+// SearchCodesRequest defines the filters for searching the codes issued in the
+// caller's realm. All filters are optional. The codes themselves are never
+// returned.
+// API is served at /api/codes/search
+type SearchCodesRequest struct {
+	Padding Padding `json:"padding"`
+
+	// IssuingUserID and IssuingAppID filter codes by their issuer.
+	IssuingUserID uint `json:"issuingUserID,omitempty"`
+	IssuingAppID  uint `json:"issuingAppID,omitempty"`
+
+	// TestType filters codes by test type.
+	TestType string `json:"testType,omitempty"`
+
+	// Status is "claimed" or "unclaimed" to filter codes by whether they have
+	// been claimed.
+	Status string `json:"status,omitempty"`
+
+	// StartTimestamp and EndTimestamp bound the issue time of codes to list, in
+	// UTC seconds since epoch.
+	StartTimestamp int64 `json:"startTimestamp,omitempty"`
+	EndTimestamp   int64 `json:"endTimestamp,omitempty"`
+
+	// Cursor is the NextCursor of the previous response. Limit is the number of
+	// results to return, capped by the server.
+	Cursor uint `json:"cursor,omitempty"`
+	Limit  uint `json:"limit,omitempty"`
+}
+
+// SearchCodesResponse defines the response type for SearchCodesRequest. Codes
+// are returned newest first.
+type SearchCodesResponse struct {
+	Padding Padding `json:"padding"`
+
+	Codes []*CodeMetadata `json:"codes"`
+
+	// NextCursor is the cursor to request for the next set of results. It is 0
+	// if there are no further results.
+	NextCursor uint `json:"nextCursor,omitempty"`
+
+	Error     string `json:"error,omitempty"`
+	ErrorCode string `json:"errorCode,omitempty"`
+}
+
+// ExternalIssuerIssuanceResponse is the code issuance of the realm's external
+// issuers over a date range, paginated by issuer. Served at
+// /api/stats/realm/external-issuers/issuance.json and
+// /stats/realm/external-issuers/issuance.json.
+type ExternalIssuerIssuanceResponse struct {
+	// StartDate and EndDate are the inclusive date range of the results, in
+	// YYYY-MM-DD format.
+	StartDate string `json:"startDate"`
+	EndDate   string `json:"endDate"`
+
+	Issuers []*ExternalIssuerIssuance `json:"issuers"`
+
+	// NextPage is the page to request for the next set of issuers. It is 0 if
+	// there are no further results.
+	NextPage uint64 `json:"nextPage,omitempty"`
+
+	Error     string `json:"error,omitempty"`
+	ErrorCode string `json:"errorCode,omitempty"`
+}
+
+// ExternalIssuerIssuance is the code issuance of a single external issuer, as
+// identified by the externalIssuerID given when codes were issued.
+type ExternalIssuerIssuance struct {
+	IssuerID string `json:"issuerID"`
+
+	// CodesIssued is the total number of codes issued in the date range.
+	CodesIssued uint `json:"codesIssued"`
+
+	// Days is the issuance on each day of the date range, oldest first.
+	Days []*ExternalIssuerIssuanceDay `json:"days"`
+}
+
+// ExternalIssuerIssuanceDay is the number of codes an external issuer issued on
+// a single UTC day.
+type ExternalIssuerIssuanceDay struct {
+	Date        string `json:"date"`
+	CodesIssued uint   `json:"codesIssued"`
+}
+
+// SandboxSMSRequest is the request to list the SMS messages recorded by the
+// NOOP_INSPECT SMS provider for the caller's realm. It is only available when
+// the server is running with DEV_FAKE_SMS enabled.
+type SandboxSMSRequest struct {
+	Padding Padding `json:"padding"`
+
+	// Phone optionally filters the messages to those sent to the given phone
+	// number, in E.164 format.
+	Phone string `json:"phone,omitempty"`
+}
+
+// SandboxSMS is a single recorded SMS message.
+type SandboxSMS struct {
+	Phone     string `json:"phone"`
+	Message   string `json:"message"`
+	Timestamp int64  `json:"timestamp"`
+}
+
+// SandboxSMSResponse is the response from listing sandbox SMS messages.
+// Messages are returned newest first.
+type SandboxSMSResponse struct {
+	Padding Padding `json:"padding"`
+
+	Messages []*SandboxSMS `json:"messages"`
+
+	Error     string `json:"error,omitempty"`
+	ErrorCode string `json:"errorCode,omitempty"`
+}
+
+// RealmBrandingRequest updates the agency branding shown on the ENX Express
+// user report pages. Omitted fields are left unchanged and empty values clear
+// the field. Once updated through this API, the branding is no longer
+// overwritten by the ENX Express sync.
+//
+// Requires API key in a HTTP header, X-API-Key: APIKEY
+type RealmBrandingRequest struct {
+	Padding Padding `json:"padding"`
+
+	// AgencyBackgroundColor is a hex color, like "#1a73e8".
+	AgencyBackgroundColor *string `json:"agencyBackgroundColor,omitempty"`
+
+	// DefaultLocale is the BCP 47 language tag used when the user's language
+	// is not available, like "en" or "es-419".
+	DefaultLocale *string `json:"defaultLocale,omitempty"`
+
+	// UserReportLearnMoreURL is an https:// URL linked from the user report
+	// pages.
+	UserReportLearnMoreURL *string `json:"userReportLearnMoreURL,omitempty"`
+}
+
+// RealmAgencyImageRequest uploads the agency image shown on the ENX Express
+// user report pages, replacing any previous image.
+//
+// Requires API key in a HTTP header, X-API-Key: APIKEY
+type RealmAgencyImageRequest struct {
+	Padding Padding `json:"padding"`
+
+	// Image is the base64-encoded PNG or JPEG image.
+	Image string `json:"image"`
+}
+
+// RealmBrandingResponse is the realm's agency branding.
+type RealmBrandingResponse struct {
+	Padding Padding `json:"padding"`
+
+	AgencyBackgroundColor  string `json:"agencyBackgroundColor"`
+	AgencyImage            string `json:"agencyImage"`
+	DefaultLocale          string `json:"defaultLocale"`
+	UserReportLearnMoreURL string `json:"userReportLearnMoreURL"`
+
+	// Managed is true if the branding was set through the API, in which case
+	// the ENX Express sync does not overwrite it.
+	Managed bool `json:"managed"`
+
+	Error     string `json:"error,omitempty"`
+	ErrorCode string `json:"errorCode,omitempty"`
+}
+
 // UserReportRequest defines the structure for a user initiated report.
 // This is a device API hosted on the apiserver.
 //
@@ -379,6 +967,37 @@ type UserReportRequest struct {
 
 	// Nonce must be 256 bytes of random data, base64 encoded.
 	Nonce string `json:"nonce"`
+
+	// ConsentVersion is the version of the realm's consent text the user
+	// accepted, as returned by the user report consent API. It is required if
+	// the realm has published consent text.
+	ConsentVersion uint `json:"consentVersion,omitempty"`
+	// ConsentLocale is the locale of the consent text that was displayed.
+	ConsentLocale string `json:"consentLocale,omitempty"`
+}
+
+// UserReportConsentRequest is a request for the realm's current user report
+// consent text.
+type UserReportConsentRequest struct {
+	Padding Padding `json:"padding"`
+
+	// Locale is the user's preferred locale (e.g. "es-MX"). If there is no text
+	// in that locale, the realm's default is returned.
+	Locale string `json:"locale"`
+}
+
+// UserReportConsentResponse is the reply from a UserReportConsentRequest. If
+// the realm has not published consent text, Version is 0 and the app should
+// display its own agreement.
+type UserReportConsentResponse struct {
+	Padding Padding `json:"padding"`
+
+	Version uint   `json:"version"`
+	Locale  string `json:"locale,omitempty"`
+	Text    string `json:"text,omitempty"`
+
+	Error     string `json:"error,omitempty"`
+	ErrorCode string `json:"errorCode,omitempty"`
 }
 
 // UserReportResponse is the reply from a UserReportRequest.
@@ -397,6 +1016,45 @@ type UserReportResponse struct {
 	ErrorCode string `json:"errorCode,omitempty"`
 }
 
+// UserReportPurgeRequest is a request from a self-reporter to delete the data
+// derived from their phone number. The nonce proves the request comes from the
+// device that made the user report.
+//
+// Requires API key in a HTTP header, X-API-Key: APIKEY
+type UserReportPurgeRequest struct {
+	Padding Padding `json:"padding"`
+
+	// Phone is the phone number that was used for the user report.
+	Phone string `json:"phone"`
+
+	// Nonce is the base64 encoded nonce that was sent with the user report.
+	Nonce string `json:"nonce"`
+}
+
+// UserReportPurgeResponse is the deletion receipt for a UserReportPurgeRequest.
+type UserReportPurgeResponse struct {
+	Padding Padding `json:"padding"`
+
+	// ReceiptID identifies the purge. Apps should show it to the user so they
+	// can refer to the deletion with the health authority.
+	ReceiptID string `json:"receiptID,omitempty"`
+
+	// PurgedAt is a RFC1123 formatted string formatted timestamp, in UTC.
+	PurgedAt string `json:"purgedAt,omitempty"`
+
+	// PurgedAtTimestamp represents Unix, seconds since the epoch. Still UTC.
+	PurgedAtTimestamp int64 `json:"purgedAtTimestamp,omitempty"`
+
+	// CodesExpired is the number of unexpired verification codes that were
+	// expired, and ConsentsUnlinked is the number of consent acceptances that
+	// were unlinked from the user report.
+	CodesExpired     uint `json:"codesExpired"`
+	ConsentsUnlinked uint `json:"consentsUnlinked"`
+
+	Error     string `json:"error,omitempty"`
+	ErrorCode string `json:"errorCode,omitempty"`
+}
+
 // VerifyCodeRequest is the request structure for exchanging a short term
 // Verification Code (OTP) for a long term token (a JWT) that can later be used
 // to sign TEKs.
@@ -15,8 +15,18 @@
 package api
 
 import (
+	"bytes"
 	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
 	"testing"
+
+	"github.com/google/exposure-notifications-verification-server/internal/project"
 )
 
 func TestInternalError(t *testing.T) {
@@ -90,3 +100,57 @@ func TestPadding_MarshalJSON(t *testing.T) {
 		t.Errorf("expected %q to be %q", p, b)
 	}
 }
+
+func TestErrorCodes_Documented(t *testing.T) {
+	t.Parallel()
+
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "api.go", nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	docs, err := os.ReadFile(filepath.Join(project.Root(), "docs", "api.md"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	seen := make(map[string]string)
+	for _, decl := range f.Decls {
+		gen, ok := decl.(*ast.GenDecl)
+		if !ok || gen.Tok != token.CONST {
+			continue
+		}
+
+		for _, spec := range gen.Specs {
+			vs := spec.(*ast.ValueSpec)
+			for i, name := range vs.Names {
+				if !strings.HasPrefix(name.Name, "Err") || i >= len(vs.Values) {
+					continue
+				}
+				lit, ok := vs.Values[i].(*ast.BasicLit)
+				if !ok || lit.Kind != token.STRING {
+					continue
+				}
+
+				code, err := strconv.Unquote(lit.Value)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				if other, ok := seen[code]; ok {
+					t.Errorf("%s and %s both use error code %q", name.Name, other, code)
+				}
+				seen[code] = name.Name
+
+				if !bytes.Contains(docs, []byte(fmt.Sprintf("| `%s` |", code))) {
+					t.Errorf("error code %q (%s) is not documented in docs/api.md", code, name.Name)
+				}
+			}
+		}
+	}
+
+	if len(seen) == 0 {
+		t.Fatal("found no error codes")
+	}
+}
@@ -0,0 +1,93 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// AppVersionHeader is the HTTP header in which mobile apps report their own
+// version, for example "1.12.3".
+const AppVersionHeader = "X-App-Version"
+
+// AppPackageHeader is the HTTP header in which mobile apps report their
+// Android package name or iOS bundle identifier, for example
+// "com.example.health".
+const AppPackageHeader = "X-App-Package"
+
+// maxAppVersionParts is the maximum number of dot-separated numbers in an app
+// version.
+const maxAppVersionParts = 4
+
+// AppVersion is a dot-separated numeric app version.
+type AppVersion []uint64
+
+// ParseAppVersion parses a version such as "1.12.3". A leading "v" and any
+// pre-release or build suffix (e.g. "-beta" or "+42") are ignored.
+func ParseAppVersion(s string) (AppVersion, error) {
+	s = strings.TrimSpace(s)
+	s = strings.TrimPrefix(strings.TrimPrefix(s, "v"), "V")
+	if i := strings.IndexAny(s, "-+ "); i >= 0 {
+		s = s[:i]
+	}
+
+	parts := strings.Split(s, ".")
+	if s == "" || len(parts) > maxAppVersionParts {
+		return nil, fmt.Errorf("invalid app version %q", s)
+	}
+
+	v := make(AppVersion, 0, len(parts))
+	for _, p := range parts {
+		n, err := strconv.ParseUint(p, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid app version %q", s)
+		}
+		v = append(v, n)
+	}
+	return v, nil
+}
+
+// Compare returns -1 if v is older than o, 1 if v is newer than o, and 0 if
+// they are the same. Missing parts are treated as 0, so "1.2" equals "1.2.0".
+func (v AppVersion) Compare(o AppVersion) int {
+	for i := 0; i < len(v) || i < len(o); i++ {
+		var a, b uint64
+		if i < len(v) {
+			a = v[i]
+		}
+		if i < len(o) {
+			b = o[i]
+		}
+
+		switch {
+		case a < b:
+			return -1
+		case a > b:
+			return 1
+		}
+	}
+	return 0
+}
+
+// String returns the dot-separated version.
+func (v AppVersion) String() string {
+	parts := make([]string, 0, len(v))
+	for _, n := range v {
+		parts = append(parts, strconv.FormatUint(n, 10))
+	}
+	return strings.Join(parts, ".")
+}
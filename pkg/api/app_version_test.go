@@ -0,0 +1,92 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"testing"
+)
+
+func TestParseAppVersion(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		in   string
+		want string
+		err  bool
+	}{
+		{in: "1", want: "1"},
+		{in: "1.12.3", want: "1.12.3"},
+		{in: " v2.0 ", want: "2.0"},
+		{in: "1.2.3-beta.1", want: "1.2.3"},
+		{in: "1.2.3+42", want: "1.2.3"},
+		{in: "1.2 (45)", want: "1.2"},
+		{in: "", err: true},
+		{in: "abc", err: true},
+		{in: "1..2", err: true},
+		{in: "1.2.3.4.5", err: true},
+		{in: "-1.2", err: true},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.in, func(t *testing.T) {
+			t.Parallel()
+
+			v, err := ParseAppVersion(tc.in)
+			if (err != nil) != tc.err {
+				t.Fatalf("expected error %t, got %v", tc.err, err)
+			}
+			if got, want := v.String(), tc.want; got != want {
+				t.Errorf("expected %q to be %q", got, want)
+			}
+		})
+	}
+}
+
+func TestAppVersion_Compare(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		a, b string
+		want int
+	}{
+		{a: "1.2.3", b: "1.2.3", want: 0},
+		{a: "1.2", b: "1.2.0", want: 0},
+		{a: "1.2.3", b: "1.10", want: -1},
+		{a: "2", b: "1.99.99", want: 1},
+		{a: "1.2.0.1", b: "1.2", want: 1},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.a+"_"+tc.b, func(t *testing.T) {
+			t.Parallel()
+
+			a, err := ParseAppVersion(tc.a)
+			if err != nil {
+				t.Fatal(err)
+			}
+			b, err := ParseAppVersion(tc.b)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got, want := a.Compare(b), tc.want; got != want {
+				t.Errorf("expected %d to be %d", got, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,179 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// callbackCommonProperties are the JSON schema properties shared by every
+// callback event.
+const callbackCommonProperties = `
+    "event": {"type": "string", "description": "Name of the event."},
+    "completedAt": {"type": "integer", "description": "Time the event occurred, in UTC seconds since epoch."},
+    "test": {"type": "boolean", "description": "True if the event was sent with the test endpoint and does not describe real activity."},
+    "succeeded": {"type": "integer", "description": "Number of items that succeeded. Zero for events that do not process items."},
+    "failed": {"type": "integer", "description": "Number of items that failed. Zero for events that do not process items."}`
+
+// CallbackEvent describes an event that is delivered to API key callback URLs.
+type CallbackEvent struct {
+	// Event is the value of the "event" field in the notification.
+	Event string `json:"event"`
+
+	// Description is a human-readable description of when the event is sent.
+	Description string `json:"description"`
+
+	// Schema is the JSON schema of the notification payload.
+	Schema json.RawMessage `json:"schema"`
+
+	// Example is an example notification. It is also the payload delivered by
+	// the test endpoint.
+	Example *CallbackNotification `json:"example"`
+}
+
+// TestNotification returns a copy of the example notification marked as a
+// test, completed at the given time.
+func (e *CallbackEvent) TestNotification(now time.Time) *CallbackNotification {
+	n := *e.Example
+	n.CompletedAt = now.Unix()
+	n.Test = true
+	return &n
+}
+
+// CallbackEvents is the catalog of events delivered to API key callback URLs.
+// New events must be added here so integrators can discover and test them.
+var CallbackEvents = []*CallbackEvent{
+	{
+		Event:       CallbackEventBatchIssueCompleted,
+		Description: "Sent when a batch issue request completes. Includes the UUIDs of the codes that were issued, never the codes themselves.",
+		Schema: json.RawMessage(`{
+  "$schema": "http://json-schema.org/draft-07/schema#",
+  "type": "object",
+  "required": ["event", "completedAt", "succeeded", "failed"],
+  "properties": {` + callbackCommonProperties + `,
+    "uuids": {"type": "array", "items": {"type": "string"}, "description": "UUIDs of the issued codes."},
+    "errorCode": {"type": "string", "description": "Error code if the batch failed as a whole."}
+  }
+}`),
+		Example: &CallbackNotification{
+			Event:     CallbackEventBatchIssueCompleted,
+			Succeeded: 2,
+			Failed:    1,
+			UUIDs: []string{
+				"a1b2c3d4-0000-4000-8000-000000000001",
+				"a1b2c3d4-0000-4000-8000-000000000002",
+			},
+		},
+	},
+	{
+		Event:       CallbackEventUserImportCompleted,
+		Description: "Sent when a user import submitted by the API key has been processed.",
+		Schema: json.RawMessage(`{
+  "$schema": "http://json-schema.org/draft-07/schema#",
+  "type": "object",
+  "required": ["event", "completedAt", "succeeded", "failed", "importID"],
+  "properties": {` + callbackCommonProperties + `,
+    "importID": {"type": "integer", "description": "ID of the user import. Use it with the import status API for per-user results."}
+  }
+}`),
+		Example: &CallbackNotification{
+			Event:     CallbackEventUserImportCompleted,
+			Succeeded: 10,
+			Failed:    1,
+			ImportID:  1,
+		},
+	},
+	{
+		Event:       CallbackEventUnclaimedCodesReport,
+		Description: "Sent daily to API keys that opted in to reports of the codes they issued that have not been claimed.",
+		Schema: json.RawMessage(`{
+  "$schema": "http://json-schema.org/draft-07/schema#",
+  "type": "object",
+  "required": ["event", "completedAt", "codes"],
+  "properties": {` + callbackCommonProperties + `,
+    "codes": {
+      "type": "array",
+      "description": "Metadata for the unclaimed codes.",
+      "items": {
+        "type": "object",
+        "required": ["uuid", "issuedAtTimestamp", "claimed", "testType", "expiresAtTimestamp"],
+        "properties": {
+          "uuid": {"type": "string"},
+          "issuedAtTimestamp": {"type": "integer"},
+          "claimed": {"type": "boolean"},
+          "testType": {"type": "string"},
+          "issuingAppID": {"type": "integer"},
+          "issuingExternalID": {"type": "string"},
+          "externalCaseID": {"type": "string"},
+          "expiresAtTimestamp": {"type": "integer"},
+          "longExpiresAtTimestamp": {"type": "integer"}
+        }
+      }
+    }
+  }
+}`),
+		Example: &CallbackNotification{
+			Event: CallbackEventUnclaimedCodesReport,
+			Codes: []*CodeMetadata{
+				{
+					UUID:                   "a1b2c3d4-0000-4000-8000-000000000003",
+					IssuedAtTimestamp:      1640995200,
+					TestType:               TestTypeConfirmed,
+					IssuingAppID:           1,
+					ExternalCaseID:         "case-123",
+					ExpiresAtTimestamp:     1640996100,
+					LongExpiresAtTimestamp: 1641081600,
+				},
+			},
+		},
+	},
+}
+
+// FindCallbackEvent returns the callback event with the given name, or nil if
+// no such event exists.
+func FindCallbackEvent(event string) *CallbackEvent {
+	for _, e := range CallbackEvents {
+		if e.Event == event {
+			return e
+		}
+	}
+	return nil
+}
+
+// CallbackEventsResponse is the response for listing callback events.
+// API is served at /api/callbacks/events
+type CallbackEventsResponse struct {
+	Padding Padding          `json:"padding"`
+	Events  []*CallbackEvent `json:"events"`
+}
+
+// CallbackTestRequest queues a test notification for the given event to the
+// calling API key's callback URL.
+// API is served at /api/callbacks/test
+type CallbackTestRequest struct {
+	Padding Padding `json:"padding"`
+	Event   string  `json:"event"`
+}
+
+// CallbackTestResponse is the response for CallbackTestRequest. DeliveryID
+// identifies the queued delivery on the callback deliveries page.
+type CallbackTestResponse struct {
+	Padding    Padding `json:"padding"`
+	DeliveryID uint    `json:"deliveryID,omitempty"`
+
+	Error     string `json:"error,omitempty"`
+	ErrorCode string `json:"errorCode,omitempty"`
+}
@@ -0,0 +1,95 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestCallbackEvents(t *testing.T) {
+	t.Parallel()
+
+	seen := make(map[string]struct{}, len(CallbackEvents))
+	for _, e := range CallbackEvents {
+		e := e
+
+		t.Run(e.Event, func(t *testing.T) {
+			t.Parallel()
+
+			var schema struct {
+				Required   []string                   `json:"required"`
+				Properties map[string]json.RawMessage `json:"properties"`
+			}
+			if err := json.Unmarshal(e.Schema, &schema); err != nil {
+				t.Fatalf("invalid schema: %s", err)
+			}
+
+			if got, want := e.Example.Event, e.Event; got != want {
+				t.Errorf("expected example event %q to be %q", got, want)
+			}
+
+			b, err := json.Marshal(e.TestNotification(time.Unix(100, 0)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			var example map[string]json.RawMessage
+			if err := json.Unmarshal(b, &example); err != nil {
+				t.Fatal(err)
+			}
+
+			for k := range example {
+				if _, ok := schema.Properties[k]; !ok {
+					t.Errorf("example field %q is not in the schema", k)
+				}
+			}
+			for _, k := range schema.Required {
+				if _, ok := example[k]; !ok {
+					t.Errorf("required field %q is not in the example", k)
+				}
+			}
+		})
+
+		if _, ok := seen[e.Event]; ok {
+			t.Errorf("duplicate event %q", e.Event)
+		}
+		seen[e.Event] = struct{}{}
+	}
+}
+
+func TestCallbackEvent_TestNotification(t *testing.T) {
+	t.Parallel()
+
+	e := FindCallbackEvent(CallbackEventBatchIssueCompleted)
+	if e == nil {
+		t.Fatal("expected event")
+	}
+
+	n := e.TestNotification(time.Unix(100, 0))
+	if !n.Test {
+		t.Errorf("expected test notification")
+	}
+	if got, want := n.CompletedAt, int64(100); got != want {
+		t.Errorf("expected completedAt %d to be %d", got, want)
+	}
+	if e.Example.Test || e.Example.CompletedAt != 0 {
+		t.Errorf("expected example to be unchanged")
+	}
+
+	if got := FindCallbackEvent("nope"); got != nil {
+		t.Errorf("expected nil, got %#v", got)
+	}
+}
@@ -0,0 +1,189 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.2.0
+// - protoc             v3.21.12
+// source: device.proto
+
+package devicepb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+// DeviceVerificationClient is the client API for DeviceVerification service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type DeviceVerificationClient interface {
+	// Verify exchanges a verification code for a verification token. It is the
+	// equivalent of POST /api/verify.
+	Verify(ctx context.Context, in *VerifyCodeRequest, opts ...grpc.CallOption) (*VerifyCodeResponse, error)
+	// Certificate exchanges a verification token and exposure key HMAC for a
+	// verification certificate. It is the equivalent of POST /api/certificate.
+	Certificate(ctx context.Context, in *VerificationCertificateRequest, opts ...grpc.CallOption) (*VerificationCertificateResponse, error)
+	// UserReport requests a user report verification code be sent to the phone
+	// number. It is the equivalent of POST /api/user-report.
+	UserReport(ctx context.Context, in *UserReportRequest, opts ...grpc.CallOption) (*UserReportResponse, error)
+}
+
+type deviceVerificationClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewDeviceVerificationClient(cc grpc.ClientConnInterface) DeviceVerificationClient {
+	return &deviceVerificationClient{cc}
+}
+
+func (c *deviceVerificationClient) Verify(ctx context.Context, in *VerifyCodeRequest, opts ...grpc.CallOption) (*VerifyCodeResponse, error) {
+	out := new(VerifyCodeResponse)
+	err := c.cc.Invoke(ctx, "/verification.device.v1.DeviceVerification/Verify", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *deviceVerificationClient) Certificate(ctx context.Context, in *VerificationCertificateRequest, opts ...grpc.CallOption) (*VerificationCertificateResponse, error) {
+	out := new(VerificationCertificateResponse)
+	err := c.cc.Invoke(ctx, "/verification.device.v1.DeviceVerification/Certificate", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *deviceVerificationClient) UserReport(ctx context.Context, in *UserReportRequest, opts ...grpc.CallOption) (*UserReportResponse, error) {
+	out := new(UserReportResponse)
+	err := c.cc.Invoke(ctx, "/verification.device.v1.DeviceVerification/UserReport", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// DeviceVerificationServer is the server API for DeviceVerification service.
+// All implementations must embed UnimplementedDeviceVerificationServer
+// for forward compatibility
+type DeviceVerificationServer interface {
+	// Verify exchanges a verification code for a verification token. It is the
+	// equivalent of POST /api/verify.
+	Verify(context.Context, *VerifyCodeRequest) (*VerifyCodeResponse, error)
+	// Certificate exchanges a verification token and exposure key HMAC for a
+	// verification certificate. It is the equivalent of POST /api/certificate.
+	Certificate(context.Context, *VerificationCertificateRequest) (*VerificationCertificateResponse, error)
+	// UserReport requests a user report verification code be sent to the phone
+	// number. It is the equivalent of POST /api/user-report.
+	UserReport(context.Context, *UserReportRequest) (*UserReportResponse, error)
+	mustEmbedUnimplementedDeviceVerificationServer()
+}
+
+// UnimplementedDeviceVerificationServer must be embedded to have forward compatible implementations.
+type UnimplementedDeviceVerificationServer struct {
+}
+
+func (UnimplementedDeviceVerificationServer) Verify(context.Context, *VerifyCodeRequest) (*VerifyCodeResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Verify not implemented")
+}
+func (UnimplementedDeviceVerificationServer) Certificate(context.Context, *VerificationCertificateRequest) (*VerificationCertificateResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Certificate not implemented")
+}
+func (UnimplementedDeviceVerificationServer) UserReport(context.Context, *UserReportRequest) (*UserReportResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UserReport not implemented")
+}
+func (UnimplementedDeviceVerificationServer) mustEmbedUnimplementedDeviceVerificationServer() {}
+
+// UnsafeDeviceVerificationServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to DeviceVerificationServer will
+// result in compilation errors.
+type UnsafeDeviceVerificationServer interface {
+	mustEmbedUnimplementedDeviceVerificationServer()
+}
+
+func RegisterDeviceVerificationServer(s grpc.ServiceRegistrar, srv DeviceVerificationServer) {
+	s.RegisterService(&DeviceVerification_ServiceDesc, srv)
+}
+
+func _DeviceVerification_Verify_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(VerifyCodeRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DeviceVerificationServer).Verify(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/verification.device.v1.DeviceVerification/Verify",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DeviceVerificationServer).Verify(ctx, req.(*VerifyCodeRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _DeviceVerification_Certificate_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(VerificationCertificateRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DeviceVerificationServer).Certificate(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/verification.device.v1.DeviceVerification/Certificate",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DeviceVerificationServer).Certificate(ctx, req.(*VerificationCertificateRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _DeviceVerification_UserReport_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UserReportRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DeviceVerificationServer).UserReport(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/verification.device.v1.DeviceVerification/UserReport",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DeviceVerificationServer).UserReport(ctx, req.(*UserReportRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// DeviceVerification_ServiceDesc is the grpc.ServiceDesc for DeviceVerification service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var DeviceVerification_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "verification.device.v1.DeviceVerification",
+	HandlerType: (*DeviceVerificationServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Verify",
+			Handler:    _DeviceVerification_Verify_Handler,
+		},
+		{
+			MethodName: "Certificate",
+			Handler:    _DeviceVerification_Certificate_Handler,
+		},
+		{
+			MethodName: "UserReport",
+			Handler:    _DeviceVerification_UserReport_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "device.proto",
+}
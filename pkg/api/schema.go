@@ -0,0 +1,78 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const (
+	// SchemaVersionHeader is the HTTP header in which clients may declare the
+	// schema version they expect. The server always returns the current schema
+	// version in the same header.
+	SchemaVersionHeader = "X-API-Schema-Version"
+
+	// SchemaVersion is the current version of the request and response types in
+	// this package. It must be incremented, and an entry added to
+	// SchemaChangelog, whenever a field is removed, renamed, or changes type.
+	// Adding new optional fields does not require a new version.
+	SchemaVersion = uint(1)
+
+	// MinSchemaVersion is the oldest schema version the server still accepts.
+	MinSchemaVersion = uint(1)
+)
+
+// SchemaChange describes the changes introduced in a schema version.
+type SchemaChange struct {
+	Version uint     `json:"version"`
+	Changes []string `json:"changes"`
+}
+
+// SchemaChangelog is the list of schema versions, oldest first.
+var SchemaChangelog = []*SchemaChange{
+	{
+		Version: 1,
+		Changes: []string{
+			"Initial versioned schema.",
+		},
+	},
+}
+
+// SchemaChangelogResponse is the response for the schema changelog.
+// API is served at /schema
+type SchemaChangelogResponse struct {
+	CurrentVersion uint            `json:"currentVersion"`
+	MinVersion     uint            `json:"minVersion"`
+	Changelog      []*SchemaChange `json:"changelog"`
+}
+
+// ParseSchemaVersion parses the value of the schema version header. It returns
+// an error if the value is not a supported version.
+func ParseSchemaVersion(s string) (uint, error) {
+	s = strings.TrimSpace(s)
+	v, err := strconv.ParseUint(s, 10, 32)
+	if err != nil {
+		return 0, fmt.Errorf("invalid schema version %q", s)
+	}
+
+	version := uint(v)
+	if version < MinSchemaVersion || version > SchemaVersion {
+		return 0, fmt.Errorf("unsupported schema version %d, must be between %d and %d",
+			version, MinSchemaVersion, SchemaVersion)
+	}
+	return version, nil
+}
@@ -0,0 +1,166 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// schemaTypes maps the golden payload file names in testdata/schema/v* to the
+// type the payload must decode into.
+var schemaTypes = map[string]func() interface{}{
+	"check_code_status_request.json":         func() interface{} { return new(CheckCodeStatusRequest) },
+	"check_code_status_response.json":        func() interface{} { return new(CheckCodeStatusResponse) },
+	"expire_code_request.json":               func() interface{} { return new(ExpireCodeRequest) },
+	"expire_code_response.json":              func() interface{} { return new(ExpireCodeResponse) },
+	"issue_code_request.json":                func() interface{} { return new(IssueCodeRequest) },
+	"issue_code_response.json":               func() interface{} { return new(IssueCodeResponse) },
+	"user_report_request.json":               func() interface{} { return new(UserReportRequest) },
+	"user_report_response.json":              func() interface{} { return new(UserReportResponse) },
+	"verification_certificate_request.json":  func() interface{} { return new(VerificationCertificateRequest) },
+	"verification_certificate_response.json": func() interface{} { return new(VerificationCertificateResponse) },
+	"verify_code_request.json":               func() interface{} { return new(VerifyCodeRequest) },
+	"verify_code_response.json":              func() interface{} { return new(VerifyCodeResponse) },
+}
+
+func TestSchemaChangelog(t *testing.T) {
+	t.Parallel()
+
+	if got, want := len(SchemaChangelog), int(SchemaVersion); got != want {
+		t.Fatalf("expected %d changelog entries to be %d", got, want)
+	}
+
+	for i, entry := range SchemaChangelog {
+		if got, want := entry.Version, uint(i+1); got != want {
+			t.Errorf("expected changelog entry %d to be version %d, got %d", i, want, got)
+		}
+		if len(entry.Changes) == 0 {
+			t.Errorf("expected changelog entry for version %d to have changes", entry.Version)
+		}
+	}
+}
+
+func TestParseSchemaVersion(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name string
+		in   string
+		err  bool
+	}{
+		{name: "current", in: fmt.Sprintf("%d", SchemaVersion)},
+		{name: "min", in: fmt.Sprintf(" %d ", MinSchemaVersion)},
+		{name: "empty", in: "", err: true},
+		{name: "not_a_number", in: "v1", err: true},
+		{name: "negative", in: "-1", err: true},
+		{name: "too_old", in: fmt.Sprintf("%d", MinSchemaVersion-1), err: true},
+		{name: "too_new", in: fmt.Sprintf("%d", SchemaVersion+1), err: true},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := ParseSchemaVersion(tc.in)
+			if got, want := err != nil, tc.err; got != want {
+				t.Errorf("expected error to be %t, got %v", want, err)
+			}
+		})
+	}
+}
+
+// TestSchemaCompatibility ensures that payloads for every supported schema
+// version still decode into the current types without dropping or changing
+// any fields. If this test fails, the change is breaking for existing clients:
+// restore the field, or increment SchemaVersion, add a changelog entry, and add
+// golden payloads for the new version.
+func TestSchemaCompatibility(t *testing.T) {
+	t.Parallel()
+
+	for v := MinSchemaVersion; v <= SchemaVersion; v++ {
+		dir := filepath.Join("testdata", "schema", fmt.Sprintf("v%d", v))
+
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			t.Fatalf("missing golden payloads for schema version %d: %s", v, err)
+		}
+
+		for _, entry := range entries {
+			name := entry.Name()
+			newFn, ok := schemaTypes[name]
+			if !ok {
+				t.Errorf("%s/%s: no type registered in schemaTypes", dir, name)
+				continue
+			}
+
+			t.Run(fmt.Sprintf("v%d/%s", v, name), func(t *testing.T) {
+				t.Parallel()
+
+				b, err := os.ReadFile(filepath.Join(dir, name))
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				// Removed or renamed fields are unknown to the current type.
+				obj := newFn()
+				dec := json.NewDecoder(bytes.NewReader(b))
+				dec.DisallowUnknownFields()
+				if err := dec.Decode(obj); err != nil {
+					t.Fatalf("failed to decode golden payload: %s", err)
+				}
+
+				out, err := json.Marshal(obj)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				var want, got map[string]interface{}
+				if err := json.Unmarshal(b, &want); err != nil {
+					t.Fatal(err)
+				}
+				if err := json.Unmarshal(out, &got); err != nil {
+					t.Fatal(err)
+				}
+
+				// Fields that changed type or are no longer serialized will not
+				// round-trip.
+				for k, wantV := range want {
+					gotV, ok := got[k]
+					if !ok {
+						t.Errorf("field %q is no longer serialized", k)
+						continue
+					}
+
+					// Padding is random and is not preserved.
+					if k == "padding" {
+						continue
+					}
+
+					if !reflect.DeepEqual(gotV, wantV) {
+						t.Errorf("field %q: expected %#v to be %#v", k, gotV, wantV)
+					}
+				}
+			})
+		}
+	}
+}
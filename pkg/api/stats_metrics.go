@@ -0,0 +1,145 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+// StatsMetric is the machine-readable definition of a metric that appears in
+// the statistics APIs and dashboards.
+type StatsMetric struct {
+	// Name is the metric's name, matching the CSV column or JSON field.
+	Name string `json:"name"`
+
+	// Definition is the operational definition: exactly what is counted.
+	Definition string `json:"definition"`
+
+	// Unit is the unit of the metric, such as "codes" or "seconds".
+	Unit string `json:"unit"`
+
+	// Caveats are known limitations to keep in mind when interpreting the
+	// metric.
+	Caveats []string `json:"caveats,omitempty"`
+}
+
+// StatsMetricsResponse is the response to a request for the statistics metric
+// definitions.
+type StatsMetricsResponse struct {
+	Metrics []StatsMetric `json:"metrics"`
+}
+
+// StatsMetrics are the definitions of the metrics in the statistics APIs.
+var StatsMetrics = []StatsMetric{
+	{
+		Name:       "codes_issued",
+		Definition: "Verification codes issued by the realm, through the web interface or the API, including self-report codes.",
+		Unit:       "codes",
+		Caveats: []string{
+			"Does not equal the number of patients notified; a patient may be issued more than one code.",
+		},
+	},
+	{
+		Name:       "codes_claimed",
+		Definition: "Verification codes that a device exchanged for a verification token. This happens when the patient enters the code or opens the link, before they consent to share keys.",
+		Unit:       "codes",
+		Caveats: []string{
+			"A claimed code has not necessarily resulted in shared keys; see tokens_claimed and publish requests.",
+			"Counted on the day the code was claimed, not the day it was issued.",
+		},
+	},
+	{
+		Name:       "codes_invalid",
+		Definition: "Attempts to claim a code that was mistyped, already used, or expired.",
+		Unit:       "requests",
+		Caveats: []string{
+			"A single patient can generate several invalid attempts.",
+		},
+	},
+	{
+		Name:       "tokens_claimed",
+		Definition: "Verification tokens exchanged for a verification certificate. This happens after the patient consents to share keys.",
+		Unit:       "tokens",
+		Caveats: []string{
+			"The gap between codes_claimed and tokens_claimed is mostly patients who did not consent.",
+			"An iOS device that has already published keys can claim a token for a later self-report without publishing again.",
+		},
+	},
+	{
+		Name:       "tokens_invalid",
+		Definition: "Attempts to exchange a token that was invalid, already used, or expired.",
+		Unit:       "requests",
+	},
+	{
+		Name:       "code_claim_mean_age_seconds",
+		Definition: "Mean time between a code being issued and being claimed, for codes claimed that day.",
+		Unit:       "seconds",
+	},
+	{
+		Name:       "code_claim_age_distribution",
+		Definition: "Codes claimed that day, bucketed by the time between issue and claim.",
+		Unit:       "codes",
+	},
+	{
+		Name:       "user_reports_issued",
+		Definition: "Self-report codes issued to patients through the user report API or web page.",
+		Unit:       "codes",
+	},
+	{
+		Name:       "user_reports_claimed",
+		Definition: "Self-report codes exchanged for a verification token.",
+		Unit:       "codes",
+	},
+	{
+		Name:       "user_report_tokens_claimed",
+		Definition: "Verification tokens from self-report codes exchanged for a verification certificate.",
+		Unit:       "tokens",
+	},
+	{
+		Name:       "sms_suppressed",
+		Definition: "Code issue requests that were rejected because the phone number opted out of SMS messages from the realm.",
+		Unit:       "requests",
+	},
+	{
+		Name:       "publish_requests",
+		Definition: "Successful uploads of temporary exposure keys to the key server that used a certificate issued by the realm.",
+		Unit:       "requests",
+		Caveats: []string{
+			"Only available when key server statistics are enabled for the realm.",
+			"A day is not reported by the key server until it meets a minimum number of uploads or 48 hours have passed.",
+		},
+	},
+	{
+		Name:       "total_teks_published",
+		Definition: "Temporary exposure keys published to the key server in those uploads.",
+		Unit:       "keys",
+		Caveats: []string{
+			"Only available when key server statistics are enabled for the realm.",
+		},
+	},
+	{
+		Name:       "requests_missing_onset_date",
+		Definition: "Uploads with neither a symptom onset date nor a test date.",
+		Unit:       "requests",
+		Caveats: []string{
+			"Only available when key server statistics are enabled for the realm.",
+		},
+	},
+}
+
+// StatsMetricsByName returns the statistics metric definitions, keyed by name.
+func StatsMetricsByName() map[string]StatsMetric {
+	m := make(map[string]StatsMetric, len(StatsMetrics))
+	for _, metric := range StatsMetrics {
+		m[metric.Name] = metric
+	}
+	return m
+}
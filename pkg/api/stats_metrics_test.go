@@ -0,0 +1,45 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"testing"
+)
+
+func TestStatsMetrics(t *testing.T) {
+	t.Parallel()
+
+	seen := make(map[string]struct{}, len(StatsMetrics))
+	for _, metric := range StatsMetrics {
+		if metric.Name == "" {
+			t.Errorf("metric is missing a name: %#v", metric)
+		}
+		if _, ok := seen[metric.Name]; ok {
+			t.Errorf("duplicate metric %q", metric.Name)
+		}
+		seen[metric.Name] = struct{}{}
+
+		if metric.Definition == "" {
+			t.Errorf("metric %q is missing a definition", metric.Name)
+		}
+		if metric.Unit == "" {
+			t.Errorf("metric %q is missing a unit", metric.Name)
+		}
+	}
+
+	if got, want := len(StatsMetricsByName()), len(StatsMetrics); got != want {
+		t.Errorf("expected %d metrics by name, got %d", want, got)
+	}
+}
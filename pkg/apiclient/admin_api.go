@@ -0,0 +1,164 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package apiclient
+
+import (
+	"context"
+
+	"github.com/google/exposure-notifications-verification-server/pkg/api"
+)
+
+// AdminAPI is the admin API served by the adminapi server.
+type AdminAPI interface {
+	// IssueCode issues a verification code.
+	IssueCode(ctx context.Context, in *api.IssueCodeRequest) (*api.IssueCodeResponse, error)
+
+	// BatchIssueCode issues multiple verification codes. If some codes fail,
+	// the response contains the per-code results alongside the error.
+	BatchIssueCode(ctx context.Context, in *api.BatchIssueCodeRequest) (*api.BatchIssueCodeResponse, error)
+
+	// CheckCodeStatus returns the status of an issued code.
+	CheckCodeStatus(ctx context.Context, in *api.CheckCodeStatusRequest) (*api.CheckCodeStatusResponse, error)
+
+	// ExpireCode expires an issued code.
+	ExpireCode(ctx context.Context, in *api.ExpireCodeRequest) (*api.ExpireCodeResponse, error)
+
+	// RevokeAPIKey revokes a leaked API key.
+	RevokeAPIKey(ctx context.Context, in *api.RevokeAPIKeyRequest) (*api.RevokeAPIKeyResponse, error)
+
+	// ListCodes lists metadata for the realm's issued codes.
+	ListCodes(ctx context.Context, in *api.ListCodesRequest) (*api.ListCodesResponse, error)
+
+	// ImportUsers requests a bulk user import. The import is processed
+	// asynchronously.
+	ImportUsers(ctx context.Context, in *api.UserImportRequest) (*api.UserImportResponse, error)
+
+	// UserImportStatus returns the status of a user import.
+	UserImportStatus(ctx context.Context, in *api.UserImportStatusRequest) (*api.UserImportResponse, error)
+}
+
+var _ AdminAPI = (*AdminAPIServerClient)(nil)
+
+// AdminAPIServerClient is a client that talks to an admin API server.
+type AdminAPIServerClient struct {
+	*client
+}
+
+// NewAdminAPIServerClient creates a new admin API server http client.
+func NewAdminAPIServerClient(base, apiKey string, opts ...Option) (*AdminAPIServerClient, error) {
+	opts = append([]Option{WithUserAgent("en/adminapi-client")}, opts...)
+	client, err := newClient(base, apiKey, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	return &AdminAPIServerClient{
+		client: client,
+	}, nil
+}
+
+// IssueCode calls the /issue endpoint. Set the request's UUID so a retried
+// request does not send a second SMS.
+func (c *AdminAPIServerClient) IssueCode(ctx context.Context, in *api.IssueCodeRequest) (*api.IssueCodeResponse, error) {
+	var out api.IssueCodeResponse
+	if err := c.post(ctx, "/api/issue", in, &out); err != nil {
+		return &out, err
+	}
+	return &out, nil
+}
+
+// BatchIssueCode calls the /batch-issue endpoint.
+func (c *AdminAPIServerClient) BatchIssueCode(ctx context.Context, in *api.BatchIssueCodeRequest) (*api.BatchIssueCodeResponse, error) {
+	var out api.BatchIssueCodeResponse
+	if err := c.post(ctx, "/api/batch-issue", in, &out); err != nil {
+		return &out, err
+	}
+	return &out, nil
+}
+
+// CheckCodeStatus calls the /checkcodestatus endpoint.
+func (c *AdminAPIServerClient) CheckCodeStatus(ctx context.Context, in *api.CheckCodeStatusRequest) (*api.CheckCodeStatusResponse, error) {
+	var out api.CheckCodeStatusResponse
+	if err := c.post(ctx, "/api/checkcodestatus", in, &out); err != nil {
+		return &out, err
+	}
+	return &out, nil
+}
+
+// ExpireCode calls the /expirecode endpoint.
+func (c *AdminAPIServerClient) ExpireCode(ctx context.Context, in *api.ExpireCodeRequest) (*api.ExpireCodeResponse, error) {
+	var out api.ExpireCodeResponse
+	if err := c.post(ctx, "/api/expirecode", in, &out); err != nil {
+		return &out, err
+	}
+	return &out, nil
+}
+
+// ResendCode calls the /resendcode endpoint.
+func (c *AdminAPIServerClient) ResendCode(ctx context.Context, in *api.ResendCodeRequest) (*api.ResendCodeResponse, error) {
+	var out api.ResendCodeResponse
+	if err := c.post(ctx, "/api/resendcode", in, &out); err != nil {
+		return &out, err
+	}
+	return &out, nil
+}
+
+// RevokeAPIKey calls the /revokeapikey endpoint.
+func (c *AdminAPIServerClient) RevokeAPIKey(ctx context.Context, in *api.RevokeAPIKeyRequest) (*api.RevokeAPIKeyResponse, error) {
+	var out api.RevokeAPIKeyResponse
+	if err := c.post(ctx, "/api/revokeapikey", in, &out); err != nil {
+		return &out, err
+	}
+	return &out, nil
+}
+
+// ListCodes calls the /listcodes endpoint.
+func (c *AdminAPIServerClient) ListCodes(ctx context.Context, in *api.ListCodesRequest) (*api.ListCodesResponse, error) {
+	var out api.ListCodesResponse
+	if err := c.post(ctx, "/api/listcodes", in, &out); err != nil {
+		return &out, err
+	}
+	return &out, nil
+}
+
+// ImportUsers calls the /users/import endpoint. The returned ID can be passed
+// to UserImportStatus.
+func (c *AdminAPIServerClient) ImportUsers(ctx context.Context, in *api.UserImportRequest) (*api.UserImportResponse, error) {
+	var out api.UserImportResponse
+	if err := c.post(ctx, "/api/users/import", in, &out); err != nil {
+		return &out, err
+	}
+	return &out, nil
+}
+
+// UserImportStatus calls the /users/import/status endpoint.
+func (c *AdminAPIServerClient) UserImportStatus(ctx context.Context, in *api.UserImportStatusRequest) (*api.UserImportResponse, error) {
+	var out api.UserImportResponse
+	if err := c.post(ctx, "/api/users/import/status", in, &out); err != nil {
+		return &out, err
+	}
+	return &out, nil
+}
+
+// SandboxSMS lists the SMS messages recorded by the NOOP_INSPECT SMS provider.
+// The server must be running with DEV_FAKE_SMS enabled. It is not part of
+// AdminAPI.
+func (c *AdminAPIServerClient) SandboxSMS(ctx context.Context, in *api.SandboxSMSRequest) (*api.SandboxSMSResponse, error) {
+	var out api.SandboxSMSResponse
+	if err := c.post(ctx, "/api/sandbox/sms", in, &out); err != nil {
+		return &out, err
+	}
+	return &out, nil
+}
@@ -0,0 +1,124 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package apiclient
+
+import (
+	"context"
+
+	"github.com/google/exposure-notifications-verification-server/pkg/api"
+)
+
+// DeviceAPI is the device API served by the apiserver.
+type DeviceAPI interface {
+	// Verify exchanges a verification code for a token.
+	Verify(ctx context.Context, in *api.VerifyCodeRequest) (*api.VerifyCodeResponse, error)
+
+	// Certificate exchanges a token and TEK HMAC for a certificate.
+	Certificate(ctx context.Context, in *api.VerificationCertificateRequest) (*api.VerificationCertificateResponse, error)
+
+	// UserReport requests a self-report code be sent to a phone number.
+	UserReport(ctx context.Context, in *api.UserReportRequest) (*api.UserReportResponse, error)
+
+	// UserReportConsent returns the realm's current user report consent text.
+	UserReportConsent(ctx context.Context, in *api.UserReportConsentRequest) (*api.UserReportConsentResponse, error)
+
+	// UserReportPurge deletes the data derived from a self-reporter's phone
+	// number.
+	UserReportPurge(ctx context.Context, in *api.UserReportPurgeRequest) (*api.UserReportPurgeResponse, error)
+
+	// ChaffVerify and ChaffCertificate send chaff requests that look like
+	// Verify and Certificate requests on the wire.
+	ChaffVerify(ctx context.Context) error
+	ChaffCertificate(ctx context.Context) error
+}
+
+var _ DeviceAPI = (*APIServerClient)(nil)
+
+// APIServerClient is a client that talks to a device API server.
+type APIServerClient struct {
+	*client
+}
+
+// NewAPIServerClient creates a new API server http client.
+func NewAPIServerClient(base, apiKey string, opts ...Option) (*APIServerClient, error) {
+	opts = append([]Option{WithUserAgent("en/apiserver-client")}, opts...)
+	client, err := newClient(base, apiKey, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	return &APIServerClient{
+		client: client,
+	}, nil
+}
+
+// Verify calls the /verify endpoint to convert a code into a token.
+func (c *APIServerClient) Verify(ctx context.Context, in *api.VerifyCodeRequest) (*api.VerifyCodeResponse, error) {
+	var out api.VerifyCodeResponse
+	if err := c.post(ctx, "/api/verify", in, &out); err != nil {
+		return &out, err
+	}
+	return &out, nil
+}
+
+// Certificate calls the /certificate endpoint to exchange a token for a
+// certificate.
+func (c *APIServerClient) Certificate(ctx context.Context, in *api.VerificationCertificateRequest) (*api.VerificationCertificateResponse, error) {
+	var out api.VerificationCertificateResponse
+	if err := c.post(ctx, "/api/certificate", in, &out); err != nil {
+		return &out, err
+	}
+	return &out, nil
+}
+
+// UserReport calls the /user-report endpoint to request a verification code be
+// created with a self-report type, with the code only dispatched via SMS.
+func (c *APIServerClient) UserReport(ctx context.Context, in *api.UserReportRequest) (*api.UserReportResponse, error) {
+	var out api.UserReportResponse
+	if err := c.post(ctx, "/api/user-report", in, &out); err != nil {
+		return &out, err
+	}
+	return &out, nil
+}
+
+// UserReportConsent calls the /user-report/consent endpoint to get the realm's
+// current user report consent text.
+func (c *APIServerClient) UserReportConsent(ctx context.Context, in *api.UserReportConsentRequest) (*api.UserReportConsentResponse, error) {
+	var out api.UserReportConsentResponse
+	if err := c.post(ctx, "/api/user-report/consent", in, &out); err != nil {
+		return &out, err
+	}
+	return &out, nil
+}
+
+// UserReportPurge calls the /user-report/purge endpoint to delete the data
+// derived from a self-reporter's phone number.
+func (c *APIServerClient) UserReportPurge(ctx context.Context, in *api.UserReportPurgeRequest) (*api.UserReportPurgeResponse, error) {
+	var out api.UserReportPurgeResponse
+	if err := c.post(ctx, "/api/user-report/purge", in, &out); err != nil {
+		return &out, err
+	}
+	return &out, nil
+}
+
+// ChaffVerify sends a chaff request to the /verify endpoint.
+func (c *APIServerClient) ChaffVerify(ctx context.Context) error {
+	return c.chaff(ctx, "/api/verify")
+}
+
+// ChaffCertificate sends a chaff request to the /certificate endpoint.
+func (c *APIServerClient) ChaffCertificate(ctx context.Context) error {
+	return c.chaff(ctx, "/api/certificate")
+}
@@ -0,0 +1,310 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package apiclient is a Go client for the verification server's device API
+// (apiserver) and admin API (adminapi).
+//
+// Requests always include random padding and the API schema version the
+// client was built against. Failed requests return an *APIError that carries
+// the HTTP status and the structured error code (the Err* constants in package
+// api). Requests that fail with a transient error are retried with
+// exponential backoff.
+//
+// DeviceAPI and AdminAPI are implemented by APIServerClient and
+// AdminAPIServerClient respectively. Code that calls the verification server
+// should depend on those interfaces so it can substitute fakes in tests.
+package apiclient
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/exposure-notifications-verification-server/pkg/api"
+)
+
+const (
+	// ChaffHeader is the header that marks a request as chaff.
+	ChaffHeader = "X-Chaff"
+
+	defaultTimeout     = 5 * time.Second
+	defaultMaxBodySize = 65536 // 64 KiB
+	defaultRetries     = 2
+	defaultBackoff     = 250 * time.Millisecond
+	maxBackoff         = 10 * time.Second
+)
+
+// Option is a customization option for the client.
+type Option func(c *client) *client
+
+// WithHTTPClient sets the HTTP client used to make requests, for example to
+// install tracing or a custom transport. It replaces any timeout set with
+// WithTimeout, so it should be given first.
+func WithHTTPClient(hc *http.Client) Option {
+	return func(c *client) *client {
+		c.httpClient = hc
+		return c
+	}
+}
+
+// WithTimeout sets a custom timeout for each attempt. The default is 5s.
+func WithTimeout(d time.Duration) Option {
+	return func(c *client) *client {
+		c.httpClient.Timeout = d
+		return c
+	}
+}
+
+// WithMaxBodySize sets a custom max response body size. The default is 64KiB.
+func WithMaxBodySize(max int64) Option {
+	return func(c *client) *client {
+		c.maxBodySize = max
+		return c
+	}
+}
+
+// WithUserAgent sets a custom User-Agent header.
+func WithUserAgent(userAgent string) Option {
+	return func(c *client) *client {
+		c.userAgent = userAgent
+		return c
+	}
+}
+
+// WithRetries sets the number of times a request is retried after a transient
+// failure: a connection error, a 429, or a 502, 503, or 504 response. The
+// default is 2. Use 0 to disable retries.
+func WithRetries(n uint) Option {
+	return func(c *client) *client {
+		c.retries = n
+		return c
+	}
+}
+
+// WithBackoff sets the delay before the first retry. The delay doubles after
+// each retry, with jitter, up to 10s. If the server sends a Retry-After header,
+// that delay is used instead. The default is 250ms.
+func WithBackoff(d time.Duration) Option {
+	return func(c *client) *client {
+		c.backoff = d
+		return c
+	}
+}
+
+// client handles the heavy lifting for the exported clients.
+type client struct {
+	httpClient  *http.Client
+	baseURL     *url.URL
+	apiKey      string
+	maxBodySize int64
+	userAgent   string
+	retries     uint
+	backoff     time.Duration
+}
+
+// newClient creates a new client.
+func newClient(base, apiKey string, opts ...Option) (*client, error) {
+	u, err := url.Parse(base)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse base url: %w", err)
+	}
+
+	c := &client{
+		httpClient:  &http.Client{Timeout: defaultTimeout},
+		baseURL:     u,
+		apiKey:      apiKey,
+		maxBodySize: defaultMaxBodySize,
+		retries:     defaultRetries,
+		backoff:     defaultBackoff,
+	}
+
+	for _, opt := range opts {
+		c = opt(c)
+	}
+	return c, nil
+}
+
+// post sends body as JSON to the given path (relative to the baseURL) and
+// decodes the JSON response into out. The response is decoded even when the
+// request fails, so callers can inspect partial results (e.g. batch issue).
+func (c *client) post(ctx context.Context, pth string, body, out interface{}) error {
+	b, err := json.Marshal(body)
+	if err != nil {
+		return fmt.Errorf("failed to encode JSON: %w", err)
+	}
+
+	resp, respBody, err := c.doWithRetries(ctx, pth, b, nil)
+	if err != nil {
+		return err
+	}
+
+	if ct := resp.Header.Get("Content-Type"); !strings.HasPrefix(ct, "application/json") {
+		return &APIError{
+			StatusCode: resp.StatusCode,
+			Message:    fmt.Sprintf("response content-type is not application/json (got %q)", ct),
+		}
+	}
+
+	decodeErr := json.Unmarshal(respBody, out)
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return newAPIError(resp.StatusCode, respBody)
+	}
+	if decodeErr != nil {
+		return fmt.Errorf("failed to decode JSON response: %w", decodeErr)
+	}
+	return nil
+}
+
+// chaff sends a chaff request to the given path. The body is padding only and
+// the response is discarded.
+func (c *client) chaff(ctx context.Context, pth string) error {
+	b, err := json.Marshal(&struct {
+		Padding api.Padding `json:"padding"`
+	}{})
+	if err != nil {
+		return fmt.Errorf("failed to encode JSON: %w", err)
+	}
+
+	resp, _, err := c.doWithRetries(ctx, pth, b, http.Header{ChaffHeader: []string{"1"}})
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return &APIError{StatusCode: resp.StatusCode, Message: "chaff request failed"}
+	}
+	return nil
+}
+
+// doWithRetries sends the request, retrying transient failures. It returns the
+// final response and its body, which has already been read and closed.
+func (c *client) doWithRetries(ctx context.Context, pth string, body []byte, headers http.Header) (*http.Response, []byte, error) {
+	delay := c.backoff
+
+	for attempt := uint(0); ; attempt++ {
+		resp, respBody, err := c.do(ctx, pth, body, headers)
+		if attempt >= c.retries || !shouldRetry(resp, err) {
+			return resp, respBody, err
+		}
+
+		wait := delay
+		if resp != nil {
+			if d, ok := retryAfter(resp); ok {
+				wait = d
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			if err == nil {
+				return resp, respBody, nil
+			}
+			return nil, nil, err
+		case <-time.After(jitter(wait)):
+		}
+
+		delay *= 2
+		if delay > maxBackoff {
+			delay = maxBackoff
+		}
+	}
+}
+
+// do sends a single POST request.
+func (c *client) do(ctx context.Context, pth string, body []byte, headers http.Header) (*http.Response, []byte, error) {
+	u := c.baseURL.ResolveReference(&url.URL{Path: strings.TrimPrefix(pth, "/")})
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to build request: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(api.SchemaVersionHeader, strconv.FormatUint(uint64(api.SchemaVersion), 10))
+	if c.apiKey != "" {
+		req.Header.Set("X-API-Key", c.apiKey)
+	}
+	if c.userAgent != "" {
+		req.Header.Set("User-Agent", c.userAgent)
+	}
+	for k := range headers {
+		req.Header.Set(k, headers.Get(k))
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, nil, fmt.Errorf("POST %s: %w", u.String(), err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(io.LimitReader(resp.Body, c.maxBodySize))
+	if err != nil {
+		return nil, nil, fmt.Errorf("POST %s - %d: failed to read body: %w", u.String(), resp.StatusCode, err)
+	}
+	return resp, respBody, nil
+}
+
+// shouldRetry returns true if the request failed with a transient error.
+// Context cancellation is never retried.
+func shouldRetry(resp *http.Response, err error) bool {
+	if err != nil {
+		return !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded)
+	}
+
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests,
+		http.StatusBadGateway,
+		http.StatusServiceUnavailable,
+		http.StatusGatewayTimeout:
+		return true
+	default:
+		return false
+	}
+}
+
+// retryAfter parses the Retry-After header, in seconds.
+func retryAfter(resp *http.Response) (time.Duration, bool) {
+	v := resp.Header.Get("Retry-After")
+	if v == "" {
+		return 0, false
+	}
+	secs, err := strconv.ParseUint(v, 10, 32)
+	if err != nil {
+		return 0, false
+	}
+
+	d := time.Duration(secs) * time.Second
+	if d > maxBackoff {
+		d = maxBackoff
+	}
+	return d, true
+}
+
+// jitter returns a random duration between d/2 and d.
+func jitter(d time.Duration) time.Duration {
+	if d <= 1 {
+		return d
+	}
+	half := d / 2
+	return half + time.Duration(rand.Int63n(int64(half)+1))
+}
@@ -0,0 +1,221 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package apiclient
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/exposure-notifications-verification-server/pkg/api"
+)
+
+func TestAPIServerClient_Verify(t *testing.T) {
+	t.Parallel()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got, want := r.URL.Path, "/api/verify"; got != want {
+			t.Errorf("expected %q to be %q", got, want)
+		}
+		if got, want := r.Header.Get("X-API-Key"), "apikey"; got != want {
+			t.Errorf("expected %q to be %q", got, want)
+		}
+		if got, want := r.Header.Get(api.SchemaVersionHeader), strconv.FormatUint(uint64(api.SchemaVersion), 10); got != want {
+			t.Errorf("expected %q to be %q", got, want)
+		}
+
+		var body map[string]interface{}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Error(err)
+		}
+		if padding, _ := body["padding"].(string); len(padding) < 1024 {
+			t.Errorf("expected padding, got %q", padding)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		_ = json.NewEncoder(w).Encode(&api.VerifyCodeResponse{
+			TestType:          api.TestTypeConfirmed,
+			VerificationToken: "token",
+		})
+	}))
+	t.Cleanup(srv.Close)
+
+	c, err := NewAPIServerClient(srv.URL, "apikey")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := c.Verify(context.Background(), &api.VerifyCodeRequest{VerificationCode: "12345678"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := resp.VerificationToken, "token"; got != want {
+		t.Errorf("expected %q to be %q", got, want)
+	}
+}
+
+func TestAPIServerClient_errors(t *testing.T) {
+	t.Parallel()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		_ = json.NewEncoder(w).Encode(api.Errorf("verification code expired").WithCode(api.ErrVerifyCodeExpired))
+	}))
+	t.Cleanup(srv.Close)
+
+	c, err := NewAPIServerClient(srv.URL, "apikey")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = c.Verify(context.Background(), &api.VerifyCodeRequest{})
+
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("expected %v to be an APIError", err)
+	}
+	if got, want := apiErr.StatusCode, http.StatusBadRequest; got != want {
+		t.Errorf("expected %d to be %d", got, want)
+	}
+	if got, want := apiErr.Message, "verification code expired"; got != want {
+		t.Errorf("expected %q to be %q", got, want)
+	}
+	if !IsErrorCode(err, api.ErrVerifyCodeExpired) {
+		t.Errorf("expected %v to have code %q", err, api.ErrVerifyCodeExpired)
+	}
+	if IsErrorCode(err, api.ErrVerifyCodeInvalid) {
+		t.Errorf("expected %v to not have code %q", err, api.ErrVerifyCodeInvalid)
+	}
+}
+
+func TestClient_retries(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name     string
+		statuses []int
+		retries  uint
+		attempts int32
+		expErr   bool
+	}{
+		{
+			name:     "success",
+			statuses: []int{http.StatusOK},
+			retries:  2,
+			attempts: 1,
+		},
+		{
+			name:     "retries_unavailable",
+			statuses: []int{http.StatusServiceUnavailable, http.StatusTooManyRequests, http.StatusOK},
+			retries:  2,
+			attempts: 3,
+		},
+		{
+			name:     "exhausts_retries",
+			statuses: []int{http.StatusServiceUnavailable, http.StatusServiceUnavailable, http.StatusServiceUnavailable},
+			retries:  2,
+			attempts: 3,
+			expErr:   true,
+		},
+		{
+			name:     "no_retries",
+			statuses: []int{http.StatusServiceUnavailable, http.StatusOK},
+			retries:  0,
+			attempts: 1,
+			expErr:   true,
+		},
+		{
+			name:     "does_not_retry_client_error",
+			statuses: []int{http.StatusBadRequest, http.StatusOK},
+			retries:  2,
+			attempts: 1,
+			expErr:   true,
+		},
+		{
+			name:     "does_not_retry_internal_error",
+			statuses: []int{http.StatusInternalServerError, http.StatusOK},
+			retries:  2,
+			attempts: 1,
+			expErr:   true,
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			var attempts int32
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				i := atomic.AddInt32(&attempts, 1) - 1
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(tc.statuses[i])
+				_ = json.NewEncoder(w).Encode(&api.IssueCodeResponse{})
+			}))
+			t.Cleanup(srv.Close)
+
+			c, err := NewAdminAPIServerClient(srv.URL, "apikey",
+				WithRetries(tc.retries),
+				WithBackoff(time.Millisecond))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			_, err = c.IssueCode(context.Background(), &api.IssueCodeRequest{})
+			if (err != nil) != tc.expErr {
+				t.Errorf("expected error %t, got %v", tc.expErr, err)
+			}
+			if got, want := atomic.LoadInt32(&attempts), tc.attempts; got != want {
+				t.Errorf("expected %d attempts, got %d", want, got)
+			}
+		})
+	}
+}
+
+func TestAPIServerClient_Chaff(t *testing.T) {
+	t.Parallel()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got, want := r.Header.Get(ChaffHeader), "1"; got != want {
+			t.Errorf("expected %q to be %q", got, want)
+		}
+
+		// Chaff responses are not JSON and must not be parsed.
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte("chaff"))
+	}))
+	t.Cleanup(srv.Close)
+
+	c, err := NewAPIServerClient(srv.URL, "apikey")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := c.ChaffVerify(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.ChaffCertificate(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+}
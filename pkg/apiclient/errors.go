@@ -0,0 +1,84 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package apiclient
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/google/exposure-notifications-verification-server/pkg/api"
+)
+
+var _ error = (*APIError)(nil)
+
+// APIError is the error returned when the server responds with a non-2xx
+// status.
+type APIError struct {
+	// StatusCode is the HTTP status code of the response.
+	StatusCode int
+
+	// Code is the structured error code from the response, one of the Err*
+	// constants in package api. It is empty if the response did not include an
+	// error code (e.g. an error from a load balancer).
+	Code string
+
+	// Message is the human-readable error message from the response.
+	Message string
+}
+
+// newAPIError builds an APIError from the response body.
+func newAPIError(statusCode int, body []byte) *APIError {
+	apiErr := &APIError{StatusCode: statusCode}
+
+	var errResp api.ErrorReturn
+	if err := json.Unmarshal(body, &errResp); err == nil {
+		apiErr.Message = errResp.Error
+		apiErr.Code = errResp.ErrorCode
+		if apiErr.Code == "" {
+			apiErr.Code = errResp.ErrorCodeLegacy
+		}
+	}
+
+	if apiErr.Message == "" {
+		apiErr.Message = http.StatusText(statusCode)
+	}
+	return apiErr
+}
+
+// Error implements error.
+func (e *APIError) Error() string {
+	if e.Code != "" {
+		return fmt.Sprintf("%d %s: %s", e.StatusCode, e.Code, e.Message)
+	}
+	return fmt.Sprintf("%d: %s", e.StatusCode, e.Message)
+}
+
+// ErrorCode returns the structured error code of err, or the empty string if
+// err is not an *APIError.
+func ErrorCode(err error) string {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.Code
+	}
+	return ""
+}
+
+// IsErrorCode returns true if err is an *APIError with the given error code,
+// for example api.ErrVerifyCodeExpired.
+func IsErrorCode(err error, code string) bool {
+	return code != "" && ErrorCode(err) == code
+}
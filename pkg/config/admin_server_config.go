@@ -22,6 +22,8 @@ import (
 	"github.com/google/exposure-notifications-verification-server/pkg/cache"
 	"github.com/google/exposure-notifications-verification-server/pkg/database"
 	"github.com/google/exposure-notifications-verification-server/pkg/ratelimit"
+	"github.com/google/exposure-notifications-verification-server/pkg/residency"
+	"github.com/google/exposure-notifications-verification-server/pkg/workloadidentity"
 
 	"github.com/google/exposure-notifications-server/pkg/observability"
 
@@ -32,10 +34,12 @@ var _ IssueAPIConfig = (*AdminAPIServerConfig)(nil)
 
 // AdminAPIServerConfig represents the environment based config for the Admin API Server.
 type AdminAPIServerConfig struct {
-	Database      database.Config
-	Observability observability.Config
-	Cache         cache.Config
-	Features      FeatureConfig
+	Database         database.Config
+	WorkloadIdentity workloadidentity.Config
+	Observability    observability.Config
+	Prometheus       PrometheusConfig
+	Cache            cache.Config
+	Features         FeatureConfig
 
 	// SMSSigning defines the SMS signing configuration.
 	SMSSigning SMSSigningConfig
@@ -54,6 +58,15 @@ type AdminAPIServerConfig struct {
 	APIKeyCacheDuration time.Duration `env:"API_KEY_CACHE_DURATION,default=5m"`
 
 	Issue IssueAPIVars
+
+	// DataResidency is the data residency configuration.
+	DataResidency residency.Config
+
+	// BodyLimits is the maximum request body size configuration.
+	BodyLimits BodyLimitsConfig
+
+	// Readiness configures the dependency probes of the readiness endpoint.
+	Readiness ReadinessConfig
 }
 
 // NewAdminAPIServerConfig returns the environment config for the Admin API server.
@@ -84,6 +97,14 @@ func (c *AdminAPIServerConfig) Validate() error {
 		return fmt.Errorf("failed to validate issue API configuration: %w", err)
 	}
 
+	if err := c.BodyLimits.Validate(); err != nil {
+		return fmt.Errorf("failed to validate body limits configuration: %w", err)
+	}
+
+	if err := c.Readiness.Validate(); err != nil {
+		return fmt.Errorf("failed to validate readiness configuration: %w", err)
+	}
+
 	return nil
 }
 
@@ -22,6 +22,8 @@ import (
 	"github.com/google/exposure-notifications-verification-server/pkg/cache"
 	"github.com/google/exposure-notifications-verification-server/pkg/database"
 	"github.com/google/exposure-notifications-verification-server/pkg/ratelimit"
+	"github.com/google/exposure-notifications-verification-server/pkg/residency"
+	"github.com/google/exposure-notifications-verification-server/pkg/workloadidentity"
 
 	"github.com/google/exposure-notifications-server/pkg/observability"
 
@@ -32,10 +34,12 @@ var _ IssueAPIConfig = (*APIServerConfig)(nil)
 
 // APIServerConfig represnets the environment based configuration for the API server.
 type APIServerConfig struct {
-	Database      database.Config
-	Observability observability.Config
-	Cache         cache.Config
-	Features      FeatureConfig
+	Database         database.Config
+	WorkloadIdentity workloadidentity.Config
+	Observability    observability.Config
+	Prometheus       PrometheusConfig
+	Cache            cache.Config
+	Features         FeatureConfig
 
 	// SMSSigning defines the SMS signing configuration.
 	SMSSigning SMSSigningConfig
@@ -50,6 +54,17 @@ type APIServerConfig struct {
 	Port              string `env:"PORT,default=8080"`
 	ChaffMaxLatencyMs uint64 `env:"CHAFF_MAX_LATENCY_MS, default=1000"`
 
+	// EnableGRPC serves the gRPC variant of the device API on Port, alongside
+	// the JSON API. The port then also accepts HTTP/2 without TLS (h2c), which
+	// is how Cloud Run forwards requests when end-to-end HTTP/2 is enabled.
+	EnableGRPC bool `env:"ENABLE_GRPC"`
+
+	// GRPCTrustedProxy indicates that gRPC calls reach the server through a
+	// trusted proxy, such as Cloud Run or a Google Cloud load balancer, that
+	// appends the client's address to X-Forwarded-For. If false, the gRPC peer
+	// address is the client address and forwarding metadata is ignored.
+	GRPCTrustedProxy bool `env:"GRPC_TRUSTED_PROXY"`
+
 	APIKeyCacheDuration time.Duration `env:"API_KEY_CACHE_DURATION,default=5m"`
 
 	// Verification Token Config
@@ -66,6 +81,18 @@ type APIServerConfig struct {
 
 	// variables for Issue API
 	Issue IssueAPIVars
+
+	// DataResidency is the data residency configuration.
+	DataResidency residency.Config
+
+	// BodyLimits is the maximum request body size configuration.
+	BodyLimits BodyLimitsConfig
+
+	// Readiness configures the dependency probes of the readiness endpoint.
+	Readiness ReadinessConfig
+
+	// Shadow configures mirroring requests to a candidate release.
+	Shadow ShadowConfig
 }
 
 // NewAPIServerConfig returns the environment config for the API server.
@@ -100,6 +127,22 @@ func (c *APIServerConfig) Validate() error {
 		return fmt.Errorf("failed to validate issue API configuration: %w", err)
 	}
 
+	if err := c.BodyLimits.Validate(); err != nil {
+		return fmt.Errorf("failed to validate body limits configuration: %w", err)
+	}
+
+	if err := c.Readiness.Validate(); err != nil {
+		return fmt.Errorf("failed to validate readiness configuration: %w", err)
+	}
+
+	if err := c.Shadow.Validate(); err != nil {
+		return fmt.Errorf("failed to validate shadow configuration: %w", err)
+	}
+
+	if err := c.RateLimit.Validate(); err != nil {
+		return fmt.Errorf("failed to validate rate limit configuration: %w", err)
+	}
+
 	return nil
 }
 
@@ -27,6 +27,7 @@ import (
 type BackupConfig struct {
 	Database      database.Config
 	Observability observability.Config
+	Prometheus    PrometheusConfig
 
 	// DevMode produces additional debugging information. Do not enable in
 	// production environments.
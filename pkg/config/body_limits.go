@@ -0,0 +1,59 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"fmt"
+)
+
+// BodyLimitsConfig defines the maximum request body sizes, in bytes, for
+// endpoints that accept JSON. Requests that exceed the limit are rejected with
+// a 413 before the full body is read.
+type BodyLimitsConfig struct {
+	// Default applies to all JSON endpoints without a more specific limit.
+	Default int64 `env:"MAX_BODY_BYTES, default=64000"`
+
+	// BatchIssue applies to the batch issue endpoints and to CSV files uploaded
+	// for bulk issuing in the UI.
+	BatchIssue int64 `env:"MAX_BODY_BYTES_BATCH_ISSUE, default=1000000"`
+
+	// UserImport applies to the bulk user (CSV) import endpoint and the admin
+	// API user import endpoint.
+	UserImport int64 `env:"MAX_BODY_BYTES_USER_IMPORT, default=1000000"`
+
+	// AgencyImage applies to the agency image upload endpoint. The image is
+	// base64 encoded, so the largest image is about 3/4 of this size.
+	AgencyImage int64 `env:"MAX_BODY_BYTES_AGENCY_IMAGE, default=350000"`
+}
+
+// Validate validates the configuration.
+func (c *BodyLimitsConfig) Validate() error {
+	fields := []struct {
+		Var  int64
+		Name string
+	}{
+		{c.Default, "MAX_BODY_BYTES"},
+		{c.BatchIssue, "MAX_BODY_BYTES_BATCH_ISSUE"},
+		{c.UserImport, "MAX_BODY_BYTES_USER_IMPORT"},
+		{c.AgencyImage, "MAX_BODY_BYTES_AGENCY_IMAGE"},
+	}
+
+	for _, f := range fields {
+		if f.Var <= 0 {
+			return fmt.Errorf("%v must be a positive value, got: %v", f.Name, f.Var)
+		}
+	}
+	return nil
+}
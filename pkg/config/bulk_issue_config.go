@@ -0,0 +1,62 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"fmt"
+	"time"
+)
+
+// BulkIssueConfig represents the settings for bulk issue jobs, which are
+// uploaded as a CSV file in the realm UI and processed by a scheduled worker.
+type BulkIssueConfig struct {
+	// MaxRows is the maximum number of rows in a single upload.
+	MaxRows uint `env:"BULK_ISSUE_MAX_ROWS, default=10000"`
+
+	// BatchSize is the maximum number of jobs processed per run.
+	BatchSize uint64 `env:"BULK_ISSUE_BATCH_SIZE, default=5"`
+
+	// RowsPerRun is the maximum number of rows of a single job issued per run,
+	// so one large upload does not delay the others.
+	RowsPerRun uint `env:"BULK_ISSUE_ROWS_PER_RUN, default=1000"`
+
+	// MaxAttempts is the number of times a row that failed with a server error
+	// is attempted before it is marked as failed.
+	MaxAttempts uint `env:"BULK_ISSUE_MAX_ATTEMPTS, default=3"`
+
+	// MinPeriod is the minimum amount of time between runs.
+	MinPeriod time.Duration `env:"BULK_ISSUE_MIN_PERIOD, default=30s"`
+}
+
+// Validate validates the configuration.
+func (c *BulkIssueConfig) Validate() error {
+	if c.MaxRows < 1 {
+		return fmt.Errorf("BULK_ISSUE_MAX_ROWS must be at least 1")
+	}
+	if c.BatchSize < 1 {
+		return fmt.Errorf("BULK_ISSUE_BATCH_SIZE must be at least 1")
+	}
+	if c.RowsPerRun < 1 {
+		return fmt.Errorf("BULK_ISSUE_ROWS_PER_RUN must be at least 1")
+	}
+	if c.MaxAttempts < 1 {
+		return fmt.Errorf("BULK_ISSUE_MAX_ATTEMPTS must be at least 1")
+	}
+
+	if err := checkPositiveDuration(c.MinPeriod, "BULK_ISSUE_MIN_PERIOD"); err != nil {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"time"
+)
+
+// CallbackConfig represents the settings for delivering notifications to API
+// key callback URLs.
+type CallbackConfig struct {
+	// BatchSize is the maximum number of deliveries attempted per run.
+	BatchSize uint64 `env:"CALLBACK_BATCH_SIZE, default=100"`
+
+	// Timeout is the maximum amount of time to wait for a single callback
+	// request.
+	Timeout time.Duration `env:"CALLBACK_TIMEOUT, default=10s"`
+
+	// Lease is how long a claimed delivery is hidden from other workers. It
+	// should be longer than BatchSize * Timeout.
+	Lease time.Duration `env:"CALLBACK_LEASE, default=20m"`
+
+	// MaxAge is the maximum amount of time to retain deliveries, whether or not
+	// they were delivered.
+	MaxAge time.Duration `env:"CALLBACK_DELIVERY_MAX_AGE, default=168h"`
+}
+
+// Validate validates the configuration.
+func (c *CallbackConfig) Validate() error {
+	fields := []struct {
+		Var  time.Duration
+		Name string
+	}{
+		{c.Timeout, "CALLBACK_TIMEOUT"},
+		{c.Lease, "CALLBACK_LEASE"},
+		{c.MaxAge, "CALLBACK_DELIVERY_MAX_AGE"},
+	}
+
+	for _, f := range fields {
+		if err := checkPositiveDuration(f.Var, f.Name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -21,6 +21,7 @@ import (
 
 	"github.com/google/exposure-notifications-verification-server/internal/project"
 	"github.com/google/exposure-notifications-verification-server/pkg/database"
+	"github.com/google/exposure-notifications-verification-server/pkg/workloadidentity"
 
 	"github.com/google/exposure-notifications-server/pkg/observability"
 
@@ -29,14 +30,33 @@ import (
 
 // CleanupConfig represents the environment based configuration for the Cleanup server.
 type CleanupConfig struct {
-	Database      database.Config
-	Observability observability.Config
-	Features      FeatureConfig
+	Database         database.Config
+	WorkloadIdentity workloadidentity.Config
+	Observability    observability.Config
+	Prometheus       PrometheusConfig
+	Features         FeatureConfig
 
 	// TokenSigning is the token signing configuration to purge old keys in the
 	// key manager when they are cleaned.
 	TokenSigning TokenSigningConfig
 
+	// RealmExport is the configuration for writing realm offboarding exports.
+	RealmExport RealmExportConfig
+
+	// Callbacks is the configuration for delivering API key callbacks.
+	Callbacks CallbackConfig
+
+	// UserImport is the configuration for processing bulk user imports.
+	UserImport UserImportConfig
+
+	// FirebaseUserDeletion is the configuration for deleting the Firebase
+	// accounts of users who no longer belong to any realm.
+	FirebaseUserDeletion FirebaseUserDeletionConfig
+
+	// WorkerAuth is the configuration for authenticating requests from the
+	// scheduler.
+	WorkerAuth WorkerAuthConfig
+
 	// DevMode produces additional debugging information. Do not enable in
 	// production environments.
 	DevMode bool `env:"DEV_MODE"`
@@ -44,12 +64,47 @@ type CleanupConfig struct {
 	// Port is the port on which to bind.
 	Port string `env:"PORT,default=8080"`
 
+	// ConsistencyMinPeriod is the minimum amount of time between database
+	// consistency checks.
+	ConsistencyMinPeriod time.Duration `env:"CONSISTENCY_MIN_PERIOD, default=20h"`
+
+	// DualWriteVerifyMinPeriod is the minimum amount of time between
+	// comparisons of the primary and dual-write secondary databases.
+	DualWriteVerifyMinPeriod time.Duration `env:"DUAL_WRITE_VERIFY_MIN_PERIOD, default=5h"`
+
 	// Cleanup config
 	AuditEntryMaxAge    time.Duration `env:"AUDIT_ENTRY_MAX_AGE, default=720h"`
 	AuthorizedAppMaxAge time.Duration `env:"AUTHORIZED_APP_MAX_AGE, default=336h"`
 	CleanupMinPeriod    time.Duration `env:"CLEANUP_MIN_PERIOD, default=5m"`
 	MobileAppMaxAge     time.Duration `env:"MOBILE_APP_MAX_AGE, default=168h"`
 
+	// RealmMaxWorkers is the maximum number of realms whose verification codes
+	// and tokens are purged in parallel. The value must be greater than 0.
+	RealmMaxWorkers int64 `env:"CLEANUP_REALM_MAX_WORKERS, default=4"`
+
+	// RealmTimeout bounds the time spent purging per-realm data in a single run.
+	// Realms that are not reached are processed first on the next run. It must
+	// be less than CleanupMinPeriod so runs do not overlap.
+	RealmTimeout time.Duration `env:"CLEANUP_REALM_TIMEOUT, default=3m"`
+
+	// BatchSize is the maximum number of rows purged by a single statement, to
+	// keep row locks short.
+	BatchSize uint `env:"CLEANUP_BATCH_SIZE, default=1000"`
+
+	// DataAccessLogMaxAge is the maximum amount of time to retain records of
+	// PII reads. Like audit entries, they must be kept for at least 7 days.
+	DataAccessLogMaxAge time.Duration `env:"DATA_ACCESS_LOG_MAX_AGE, default=2160h"` // 90 days
+
+	// APICaptureMaxAge is the maximum amount of time to retain captured API
+	// requests. Captures can include partner request details, so they are kept
+	// only long enough to debug an integration.
+	APICaptureMaxAge time.Duration `env:"API_CAPTURE_MAX_AGE, default=72h"`
+
+	// BulkIssueJobMaxAge is the maximum amount of time to retain bulk issue
+	// jobs. Jobs include the uploaded phone numbers, so they are kept only long
+	// enough to download the results.
+	BulkIssueJobMaxAge time.Duration `env:"BULK_ISSUE_JOB_MAX_AGE, default=72h"`
+
 	// StatsMaxAge is the maximum amount of time to retain statistics. The default
 	// value is 91d. It can be extended up to 120 days and cannot be less than 30
 	// days.
@@ -59,6 +114,18 @@ type CleanupConfig struct {
 	// realm had received a chaff request.
 	RealmChaffEventMaxAge time.Duration `env:"REALM_CHAFF_EVENT_MAX_AGE, default=168h"` // 7 days
 
+	// ClaimFailureMaxAge is the maximum amount of time to retain claim failure
+	// events used for near-real-time diagnostics.
+	ClaimFailureMaxAge time.Duration `env:"CLAIM_FAILURE_MAX_AGE, default=24h"`
+
+	// SystemEventMaxAge is the maximum amount of time to retain the system event
+	// timeline used for incident retrospectives.
+	SystemEventMaxAge time.Duration `env:"SYSTEM_EVENT_MAX_AGE, default=2160h"` // 90 days
+
+	// SandboxSMSMaxAge is the maximum amount of time to retain SMS messages
+	// recorded by the NOOP_INSPECT SMS provider.
+	SandboxSMSMaxAge time.Duration `env:"SANDBOX_SMS_MAX_AGE, default=24h"`
+
 	// SigningTokenKeyMaxAge is the maximum amount of time that a rotated signing
 	// token key should remain unpurged.
 	SigningTokenKeyMaxAge time.Duration `env:"SIGNING_TOKEN_KEY_MAX_AGE, default=36h"`
@@ -76,6 +143,10 @@ type CleanupConfig struct {
 	VerificationCodeStatusMaxAge time.Duration `env:"VERIFICATION_CODE_STATUS_MAX_AGE, default=336h"`
 	VerificationTokenMaxAge      time.Duration `env:"VERIFICATION_TOKEN_MAX_AGE, default=24h"`
 
+	// VerificationCodeHistoryMaxAge is how long the anonymized history of
+	// purged verification codes is kept for program evaluation.
+	VerificationCodeHistoryMaxAge time.Duration `env:"VERIFICATION_CODE_HISTORY_MAX_AGE, default=17520h"` // 2 years
+
 	// UserReportUnclaimedMaxAge is how long a user report phone hash will be kept if the record goes unclaimed.
 	UserReportUnclaimedMaxAge time.Duration `env:"USER_REPORT_UNCLAIMED_MAX_AGE, default=60m"`
 	// UserReportMaxAge is how long a claimed user report phone hash will be kept.
@@ -99,10 +170,17 @@ func (c *CleanupConfig) Validate() error {
 	}{
 		{c.VerificationCodeMaxAge, "VERIFICATION_TOKEN_DURATION"},
 		{c.CleanupMinPeriod, "CLEANUP_MIN_PERIOD"},
+		{c.RealmTimeout, "CLEANUP_REALM_TIMEOUT"},
+		{c.ConsistencyMinPeriod, "CONSISTENCY_MIN_PERIOD"},
+		{c.DualWriteVerifyMinPeriod, "DUAL_WRITE_VERIFY_MIN_PERIOD"},
 		{c.VerificationCodeMaxAge, "VERIFICATION_CODE_MAX_AGE"},
 		{c.VerificationCodeStatusMaxAge, "VERIFICATION_CODE_STATUS_MAX_AGE"},
 		{c.VerificationTokenMaxAge, "VERIFICATION_TOKEN_MAX_AGE"},
+		{c.VerificationCodeHistoryMaxAge, "VERIFICATION_CODE_HISTORY_MAX_AGE"},
 		{c.AuditEntryMaxAge, "AUDIT_ENTRY_MAX_AGE"},
+		{c.DataAccessLogMaxAge, "DATA_ACCESS_LOG_MAX_AGE"},
+		{c.APICaptureMaxAge, "API_CAPTURE_MAX_AGE"},
+		{c.BulkIssueJobMaxAge, "BULK_ISSUE_JOB_MAX_AGE"},
 		{c.StatsMaxAge, "STATS_MAX_AGE"},
 	}
 
@@ -112,16 +190,53 @@ func (c *CleanupConfig) Validate() error {
 		}
 	}
 
+	if c.RealmMaxWorkers < 1 {
+		return fmt.Errorf("CLEANUP_REALM_MAX_WORKERS must be at least 1")
+	}
+	if c.BatchSize < 1 {
+		return fmt.Errorf("CLEANUP_BATCH_SIZE must be at least 1")
+	}
+	if c.RealmTimeout >= c.CleanupMinPeriod {
+		return fmt.Errorf("CLEANUP_REALM_TIMEOUT must be less than CLEANUP_MIN_PERIOD")
+	}
+
+	if err := c.RealmExport.Validate(); err != nil {
+		return err
+	}
+
+	if err := c.Callbacks.Validate(); err != nil {
+		return err
+	}
+
+	if err := c.UserImport.Validate(); err != nil {
+		return err
+	}
+
+	if err := c.FirebaseUserDeletion.Validate(); err != nil {
+		return err
+	}
+
+	if err := c.WorkerAuth.Validate(); err != nil {
+		return err
+	}
+
 	// Audit entries need to persist for at least 7 days. The default is 30d ays.
 	if c.AuditEntryMaxAge < 7*24*time.Hour {
 		return fmt.Errorf("AUDIT_ENTRY_MAX_AGE must be at least 7 days")
 	}
+	if c.DataAccessLogMaxAge < 7*24*time.Hour {
+		return fmt.Errorf("DATA_ACCESS_LOG_MAX_AGE must be at least 7 days")
+	}
 
 	if c.VerificationCodeStatusMaxAge < c.VerificationCodeMaxAge {
 		return fmt.Errorf("the code status %q is expected to live longer than the life of the code %q",
 			c.VerificationCodeStatusMaxAge.String(), c.VerificationCodeMaxAge.String())
 	}
 
+	if c.VerificationCodeHistoryMaxAge < c.VerificationCodeStatusMaxAge {
+		return fmt.Errorf("VERIFICATION_CODE_HISTORY_MAX_AGE must be at least VERIFICATION_CODE_STATUS_MAX_AGE")
+	}
+
 	// Stats must be valid for at least 30 days, but no more than 60 days.
 	if min := 30; c.StatsMaxAge < time.Duration(min)*24*time.Hour {
 		return fmt.Errorf("STATS_MAX_AGE must be at least %d days", min)
@@ -22,6 +22,7 @@ import (
 	"time"
 
 	"github.com/google/exposure-notifications-server/pkg/secrets"
+	"github.com/google/exposure-notifications-verification-server/pkg/workloadidentity"
 
 	"github.com/sethvargo/go-envconfig"
 )
@@ -39,13 +40,22 @@ func ProcessWith(ctx context.Context, spec interface{}, l envconfig.Lookuper) er
 
 	{
 		// Load the secret manager configuration first - this needs to be loaded first
-		// because other processors may need secrets.
+		// because other processors may need secrets. The secret manager
+		// authenticates through workload identity federation, if configured.
+		var wiConfig workloadidentity.Config
+		if err := envconfig.ProcessWith(ctx, &wiConfig, l); err != nil {
+			return fmt.Errorf("unable to process workload identity configuration: %w", err)
+		}
+		if err := wiConfig.Validate(); err != nil {
+			return err
+		}
+
 		var smConfig secrets.Config
 		if err := envconfig.ProcessWith(ctx, &smConfig, l); err != nil {
 			return fmt.Errorf("unable to process secret configuration: %w", err)
 		}
 
-		sm, err := secrets.SecretManagerFor(ctx, &smConfig)
+		sm, err := workloadidentity.SecretManagerFor(ctx, &wiConfig, &smConfig)
 		if err != nil {
 			return fmt.Errorf("unable to connect to secret manager: %w", err)
 		}
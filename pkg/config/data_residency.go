@@ -0,0 +1,64 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"fmt"
+
+	"github.com/google/exposure-notifications-verification-server/pkg/database"
+	"github.com/google/exposure-notifications-verification-server/pkg/residency"
+	"github.com/google/exposure-notifications-verification-server/pkg/workloadidentity"
+)
+
+// databaseResidencyResources returns the key management resources used by the
+// database for application-layer encryption.
+func databaseResidencyResources(c *database.Config) []*residency.Resource {
+	return []*residency.Resource{
+		residency.KeyResource("database keyring", c.KeyRing),
+		residency.KeyResource("database encryption key", c.EncryptionKey),
+	}
+}
+
+// newResidencyChecker creates a checker whose clients authenticate through
+// workload identity federation, if configured.
+func newResidencyChecker(cfg *residency.Config, wi *workloadidentity.Config, resources ...*residency.Resource) (*residency.Checker, error) {
+	opts, err := wi.ClientOptions()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build client options: %w", err)
+	}
+	return residency.New(cfg, opts, resources...), nil
+}
+
+// DataResidencyChecker returns a checker for the server's resources.
+func (c *ServerConfig) DataResidencyChecker() (*residency.Checker, error) {
+	resources := databaseResidencyResources(&c.Database)
+	resources = append(resources,
+		residency.KeyResource("certificate signing key", c.CertificateSigning.CertificateSigningKey))
+	return newResidencyChecker(&c.DataResidency, &c.WorkloadIdentity, resources...)
+}
+
+// DataResidencyChecker returns a checker for the API server's resources.
+func (c *APIServerConfig) DataResidencyChecker() (*residency.Checker, error) {
+	resources := databaseResidencyResources(&c.Database)
+	resources = append(resources,
+		residency.KeyResource("token signing key", c.TokenSigning.TokenSigningKey),
+		residency.KeyResource("certificate signing key", c.CertificateSigning.CertificateSigningKey))
+	return newResidencyChecker(&c.DataResidency, &c.WorkloadIdentity, resources...)
+}
+
+// DataResidencyChecker returns a checker for the admin API server's resources.
+func (c *AdminAPIServerConfig) DataResidencyChecker() (*residency.Checker, error) {
+	return newResidencyChecker(&c.DataResidency, &c.WorkloadIdentity, databaseResidencyResources(&c.Database)...)
+}
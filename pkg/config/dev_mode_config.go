@@ -0,0 +1,73 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"context"
+
+	"github.com/google/exposure-notifications-server/pkg/logging"
+	"github.com/google/exposure-notifications-verification-server/pkg/database"
+)
+
+// DevModeConfig holds the development toggles that weaken security. Each
+// toggle defaults to the safe production behavior and is enabled
+// independently. DEV_MODE does not enable any of them. Fake SMS and verbose SQL
+// are part of the database configuration, because every service shares them.
+type DevModeConfig struct {
+	// SkipFirebaseVerification accepts Firebase ID tokens without verifying
+	// their signature or exchanging them for session cookies. This allows
+	// signing in locally without Google credentials or with the Firebase Auth
+	// emulator. Do not enable in production environments.
+	SkipFirebaseVerification bool `env:"DEV_SKIP_FIREBASE_VERIFICATION"`
+
+	// RelaxedCSRF sends the session cookie over plain HTTP and skips the
+	// same-origin check on the UI JSON API, so the UI can be served by a proxy
+	// on another host or port. CSRF tokens are still required. Do not enable in
+	// production environments.
+	RelaxedCSRF bool `env:"DEV_RELAXED_CSRF"`
+}
+
+// EnabledDevSettings returns the environment variable names of the enabled
+// development toggles. Either config may be nil, for services that do not have
+// it.
+func EnabledDevSettings(dev *DevModeConfig, db *database.Config) []string {
+	var enabled []string
+	if dev != nil && dev.SkipFirebaseVerification {
+		enabled = append(enabled, "DEV_SKIP_FIREBASE_VERIFICATION")
+	}
+	if dev != nil && dev.RelaxedCSRF {
+		enabled = append(enabled, "DEV_RELAXED_CSRF")
+	}
+	if db != nil && db.FakeSMS {
+		enabled = append(enabled, "DEV_FAKE_SMS")
+	}
+	if db != nil && db.Debug {
+		enabled = append(enabled, "DB_DEBUG")
+	}
+	return enabled
+}
+
+// LogDevWarnings logs a prominent warning at startup if any development toggle
+// is enabled. Either config may be nil.
+func LogDevWarnings(ctx context.Context, dev *DevModeConfig, db *database.Config) {
+	enabled := EnabledDevSettings(dev, db)
+	if len(enabled) == 0 {
+		return
+	}
+
+	logger := logging.FromContext(ctx).Named("config.LogDevWarnings")
+	logger.Warnw("!!! DEVELOPMENT SETTINGS ENABLED - DO NOT USE IN PRODUCTION !!!",
+		"settings", enabled)
+}
@@ -0,0 +1,80 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/exposure-notifications-verification-server/pkg/database"
+	"github.com/google/exposure-notifications-verification-server/pkg/workloadidentity"
+
+	"github.com/sethvargo/go-envconfig"
+)
+
+// DoctorConfig represents the environment-based configuration for the doctor
+// command, which checks deployment-wide configuration invariants. It shares
+// environment variables with the rotation service so it can be run with the
+// same configuration.
+type DoctorConfig struct {
+	Database         database.Config
+	WorkloadIdentity workloadidentity.Config
+
+	// TokenSigning is the token signing configuration. It is used to verify the
+	// active token signing key is reachable in the key manager.
+	TokenSigning TokenSigningConfig
+
+	// TokenSigningKeyMaxAge and VerificationSigningKeyMaxAge are the maximum
+	// ages configured on the rotation service. Keys older than this (plus the
+	// grace period) indicate rotation is not running.
+	TokenSigningKeyMaxAge        time.Duration `env:"TOKEN_SIGNING_KEY_MAX_AGE, default=720h"`        // 30 days
+	VerificationSigningKeyMaxAge time.Duration `env:"VERIFICATION_SIGNING_KEY_MAX_AGE, default=720h"` // 30 days
+
+	// RotationGracePeriod is the additional time allowed beyond the maximum key
+	// age before a key is reported as stale.
+	RotationGracePeriod time.Duration `env:"DOCTOR_ROTATION_GRACE_PERIOD, default=24h"`
+}
+
+// NewDoctorConfig returns the config for the doctor command.
+func NewDoctorConfig(ctx context.Context) (*DoctorConfig, error) {
+	var config DoctorConfig
+	if err := ProcessWith(ctx, &config, envconfig.OsLookuper()); err != nil {
+		return nil, err
+	}
+	return &config, nil
+}
+
+func (c *DoctorConfig) Validate() error {
+	if err := c.TokenSigning.Validate(); err != nil {
+		return err
+	}
+
+	fields := []struct {
+		Var  time.Duration
+		Name string
+	}{
+		{c.TokenSigningKeyMaxAge, "TOKEN_SIGNING_KEY_MAX_AGE"},
+		{c.VerificationSigningKeyMaxAge, "VERIFICATION_SIGNING_KEY_MAX_AGE"},
+		{c.RotationGracePeriod, "DOCTOR_ROTATION_GRACE_PERIOD"},
+	}
+
+	for _, f := range fields {
+		if err := checkPositiveDuration(f.Var, f.Name); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
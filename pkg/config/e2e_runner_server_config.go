@@ -26,6 +26,7 @@ import (
 type E2ERunnerConfig struct {
 	Database      database.Config
 	Observability *observability.Config
+	Prometheus    PrometheusConfig
 	Features      FeatureConfig
 
 	// DevMode produces additional debugging information. Do not enable in
@@ -50,6 +51,12 @@ type E2ERunnerConfig struct {
 	// your enx redirect domain. The protocol is required. If this value is blank,
 	// the enx redirect tests are not executed on the e2e-runner.
 	ENXRedirectURL string `env:"ENX_REDIRECT_URL"`
+
+	// HostnameConfig is the same hostname to region mapping given to the ENX
+	// redirector. Each hostname's well-known files are fetched and validated
+	// against the mobile apps registered for the region's realm. If this value
+	// is blank, the app link checks are not executed on the e2e-runner.
+	HostnameConfig map[string]string `env:"HOSTNAME_TO_REGION"`
 }
 
 // NewE2ERunnerConfig returns the environment config for the e2e-runner server.
@@ -61,3 +68,9 @@ func NewE2ERunnerConfig(ctx context.Context) (*E2ERunnerConfig, error) {
 	}
 	return &config, nil
 }
+
+// HostnameToRegion returns a normalized map of the HOSTNAME_TO_REGION config
+// value.
+func (c *E2ERunnerConfig) HostnameToRegion() (map[string]string, error) {
+	return normalizeHostnameToRegion(c.HostnameConfig)
+}
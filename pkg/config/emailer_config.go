@@ -33,6 +33,7 @@ import (
 type EmailerConfig struct {
 	Database      database.Config
 	Observability observability.Config
+	Prometheus    PrometheusConfig
 	Features      FeatureConfig
 	Secrets       secrets.Config
 
@@ -48,6 +49,29 @@ type EmailerConfig struct {
 	// the controller layer, independent of being invoked via a scheduler.
 	MinTTL time.Duration `env:"MIN_TTL, default=4h"`
 
+	// SMSFromNumberChangesMinTTL is the minimum amount of time between attempts
+	// to send notifications about system SMS from number changes. It is shorter
+	// than MinTTL since each change is only ever notified once.
+	SMSFromNumberChangesMinTTL time.Duration `env:"SMS_FROM_NUMBER_CHANGES_MIN_TTL, default=10m"`
+
+	// InactiveAPIKeysMinTTL is the minimum amount of time between attempts to
+	// send notifications about API keys that were flagged or disabled for
+	// inactivity.
+	InactiveAPIKeysMinTTL time.Duration `env:"INACTIVE_API_KEYS_MIN_TTL, default=10m"`
+
+	// RealmNotificationsMinTTL is the minimum amount of time between attempts to
+	// fan out realm notifications by email and SMS.
+	RealmNotificationsMinTTL time.Duration `env:"REALM_NOTIFICATIONS_MIN_TTL, default=10m"`
+
+	// UnclaimedCodesReportMinTTL is the minimum amount of time between sending
+	// unclaimed codes reports. It is just under a day so a daily schedule is not
+	// skipped because of clock drift.
+	UnclaimedCodesReportMinTTL time.Duration `env:"UNCLAIMED_CODES_REPORT_MIN_TTL, default=20h"`
+
+	// UnclaimedCodesReportDays is the number of days of unclaimed codes to
+	// include in each unclaimed codes report.
+	UnclaimedCodesReportDays uint `env:"UNCLAIMED_CODES_REPORT_DAYS, default=3"`
+
 	// FromAddress is the address from which to send emails. This must be an
 	// address that resides in the Google Workspace domain. It can be of the
 	// format "user@example.com". The recommended value is
@@ -102,6 +126,10 @@ func (c *EmailerConfig) Validate() error {
 		Min  time.Duration
 	}{
 		{c.MinTTL, "MIN_TTL", 0},
+		{c.SMSFromNumberChangesMinTTL, "SMS_FROM_NUMBER_CHANGES_MIN_TTL", 0},
+		{c.InactiveAPIKeysMinTTL, "INACTIVE_API_KEYS_MIN_TTL", 0},
+		{c.RealmNotificationsMinTTL, "REALM_NOTIFICATIONS_MIN_TTL", 0},
+		{c.UnclaimedCodesReportMinTTL, "UNCLAIMED_CODES_REPORT_MIN_TTL", 0},
 	}
 
 	for _, f := range fields {
@@ -116,6 +144,10 @@ func (c *EmailerConfig) Validate() error {
 		}
 	}
 
+	if c.UnclaimedCodesReportDays == 0 {
+		return fmt.Errorf("UNCLAIMED_CODES_REPORT_DAYS must be at least 1")
+	}
+
 	for _, addr := range c.CCAddresses {
 		if _, err := mail.ParseAddress(addr); err != nil {
 			return fmt.Errorf("invalid CC_ADDRESSES %q: %w", addr, err)
@@ -0,0 +1,60 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"fmt"
+	"time"
+)
+
+// FirebaseUserDeletionConfig represents the settings for disabling and then
+// deleting the Firebase accounts of users who were deleted or lost their last
+// realm membership.
+type FirebaseUserDeletionConfig struct {
+	// Enabled determines whether Firebase accounts are disabled and deleted. If
+	// false, pending deletions are still recorded but not processed.
+	Enabled bool `env:"FIREBASE_USER_DELETION_ENABLED, default=false"`
+
+	// DisableAfter is the grace period after the user loses their last
+	// membership before their Firebase account is disabled.
+	DisableAfter time.Duration `env:"FIREBASE_USER_DISABLE_AFTER, default=72h"`
+
+	// DeleteAfter is the grace period after the Firebase account is disabled
+	// before it is deleted. Disabled accounts can be re-enabled in the Firebase
+	// console until then.
+	DeleteAfter time.Duration `env:"FIREBASE_USER_DELETE_AFTER, default=720h"`
+
+	// BatchSize is the maximum number of accounts disabled and deleted in a
+	// single cleanup run, to stay within Firebase quotas.
+	BatchSize uint `env:"FIREBASE_USER_DELETION_BATCH_SIZE, default=100"`
+}
+
+// Validate validates the configuration.
+func (c *FirebaseUserDeletionConfig) Validate() error {
+	if !c.Enabled {
+		return nil
+	}
+
+	if err := checkPositiveDuration(c.DisableAfter, "FIREBASE_USER_DISABLE_AFTER"); err != nil {
+		return err
+	}
+	if err := checkPositiveDuration(c.DeleteAfter, "FIREBASE_USER_DELETE_AFTER"); err != nil {
+		return err
+	}
+	if c.BatchSize < 1 {
+		return fmt.Errorf("FIREBASE_USER_DELETION_BATCH_SIZE must be at least 1")
+	}
+	return nil
+}
@@ -15,12 +15,20 @@
 package config
 
 import (
+	"fmt"
 	"strings"
 	"time"
 
 	"github.com/google/exposure-notifications-verification-server/pkg/ratelimit"
 )
 
+// MinBatchIssueSize and MaxBatchIssueSize are the bounds for
+// BATCH_ISSUE_MAX_SIZE. The bulk issue UI sends batches of MinBatchIssueSize.
+const (
+	MinBatchIssueSize = 10
+	MaxBatchIssueSize = 100
+)
+
 // IssueAPIVars is an interface that represents what is needed of the verification
 // code issue API.
 type IssueAPIVars struct {
@@ -28,10 +36,43 @@ type IssueAPIVars struct {
 	AllowedSymptomAge   time.Duration `env:"ALLOWED_PAST_SYMPTOM_DAYS,default=672h"` // 672h is 28 days.
 	EnforceRealmQuotas  bool          `env:"ENFORCE_REALM_QUOTAS, default=true"`
 
+	// BatchIssueMaxSize is the maximum number of codes in a single batch issue
+	// request.
+	BatchIssueMaxSize uint `env:"BATCH_ISSUE_MAX_SIZE, default=10"`
+
+	// CodeMaxResends is the maximum number of times a code can be resent by
+	// SMS. Setting this to 0 disables resends.
+	CodeMaxResends uint `env:"CODE_MAX_RESENDS, default=3"`
+
+	// CodeCollisionWarnRate is the rate of code collisions per code issued
+	// above which realm admins are advised to increase the code length.
+	CodeCollisionWarnRate float64 `env:"CODE_COLLISION_WARN_RATE, default=0.01"`
+
 	// For EN Express, the link will be
 	// https://[realm-region].[ENX_REDIRECT_DOMAIN]/v?c=[longcode]
 	// This repository contains a redirect service that can be used for this purpose.
 	ENExpressRedirectDomain string `env:"ENX_REDIRECT_DOMAIN"`
+
+	// UserReportLimits are the layered rate limits for user-initiated reports.
+	UserReportLimits UserReportLimitsConfig
+}
+
+// UserReportLimitsConfig configures the layered rate limits applied when a
+// user initiates a report. Each layer has its own threshold so that, for
+// example, a shared IP address can be allowed more attempts than a single
+// phone number. Setting the tokens for a layer to 0 disables that layer.
+type UserReportLimitsConfig struct {
+	PhoneTokens   uint64        `env:"USER_REPORT_PHONE_LIMIT_TOKENS, default=3"`
+	PhoneInterval time.Duration `env:"USER_REPORT_PHONE_LIMIT_INTERVAL, default=24h"`
+
+	IPTokens   uint64        `env:"USER_REPORT_IP_LIMIT_TOKENS, default=10"`
+	IPInterval time.Duration `env:"USER_REPORT_IP_LIMIT_INTERVAL, default=1h"`
+
+	RealmTokens   uint64        `env:"USER_REPORT_REALM_LIMIT_TOKENS, default=5000"`
+	RealmInterval time.Duration `env:"USER_REPORT_REALM_LIMIT_INTERVAL, default=1h"`
+
+	AppTokens   uint64        `env:"USER_REPORT_APP_LIMIT_TOKENS, default=2500"`
+	AppInterval time.Duration `env:"USER_REPORT_APP_LIMIT_INTERVAL, default=1h"`
 }
 
 func (c *IssueAPIVars) Validate() error {
@@ -40,6 +81,10 @@ func (c *IssueAPIVars) Validate() error {
 		Name string
 	}{
 		{c.AllowedSymptomAge, "ALLOWED_PAST_SYMPTOM_DAYS"},
+		{c.UserReportLimits.PhoneInterval, "USER_REPORT_PHONE_LIMIT_INTERVAL"},
+		{c.UserReportLimits.IPInterval, "USER_REPORT_IP_LIMIT_INTERVAL"},
+		{c.UserReportLimits.RealmInterval, "USER_REPORT_REALM_LIMIT_INTERVAL"},
+		{c.UserReportLimits.AppInterval, "USER_REPORT_APP_LIMIT_INTERVAL"},
 	}
 
 	for _, f := range fields {
@@ -48,6 +93,14 @@ func (c *IssueAPIVars) Validate() error {
 		}
 	}
 
+	if c.BatchIssueMaxSize < MinBatchIssueSize || c.BatchIssueMaxSize > MaxBatchIssueSize {
+		return fmt.Errorf("BATCH_ISSUE_MAX_SIZE must be between %d and %d", MinBatchIssueSize, MaxBatchIssueSize)
+	}
+
+	if c.CodeCollisionWarnRate < 0 || c.CodeCollisionWarnRate > 1 {
+		return fmt.Errorf("CODE_COLLISION_WARN_RATE must be between 0 and 1")
+	}
+
 	c.ENExpressRedirectDomain = strings.ToLower(c.ENExpressRedirectDomain)
 
 	return nil
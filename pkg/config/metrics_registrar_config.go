@@ -26,6 +26,7 @@ import (
 // metrics registration server.
 type MetricsRegistrarConfig struct {
 	Observability observability.Config
+	Prometheus    PrometheusConfig
 	Features      FeatureConfig
 
 	// DevMode produces additional debugging information. Do not enable in
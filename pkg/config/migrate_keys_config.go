@@ -0,0 +1,67 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/exposure-notifications-server/pkg/keys"
+	"github.com/google/exposure-notifications-verification-server/pkg/database"
+	"github.com/google/exposure-notifications-verification-server/pkg/workloadidentity"
+
+	"github.com/sethvargo/go-envconfig"
+)
+
+// MigrateKeysConfig represents the environment-based configuration for the
+// migrate-keys command, which moves realm signing keys from one key manager to
+// another. The database configuration describes the target key manager, which
+// is the one the services will use after the migration.
+type MigrateKeysConfig struct {
+	Database         database.Config
+	WorkloadIdentity workloadidentity.Config
+
+	// SourceKeys is the key manager that currently holds the realm signing keys.
+	SourceKeys keys.Config `env:",prefix=SOURCE_"`
+
+	// GracePeriod is how long migrated key versions are kept, and published for
+	// verification, before they are destroyed in the source key manager. It
+	// must be longer than the longest certificate duration so certificates
+	// signed before the migration can still be verified.
+	GracePeriod time.Duration `env:"MIGRATE_KEYS_GRACE_PERIOD, default=24h"`
+}
+
+// NewMigrateKeysConfig returns the config for the migrate-keys command.
+func NewMigrateKeysConfig(ctx context.Context) (*MigrateKeysConfig, error) {
+	var config MigrateKeysConfig
+	if err := ProcessWith(ctx, &config, envconfig.OsLookuper()); err != nil {
+		return nil, err
+	}
+	return &config, nil
+}
+
+func (c *MigrateKeysConfig) Validate() error {
+	if c.SourceKeys.Type == c.Database.Keys.Type &&
+		c.SourceKeys.FilesystemRoot == c.Database.Keys.FilesystemRoot {
+		return fmt.Errorf("SOURCE_KEY_MANAGER must differ from DB_KEY_MANAGER")
+	}
+
+	if c.GracePeriod < time.Hour {
+		return fmt.Errorf("MIGRATE_KEYS_GRACE_PERIOD must be at least 1h, got: %v", c.GracePeriod)
+	}
+
+	return nil
+}
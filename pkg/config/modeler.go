@@ -31,6 +31,7 @@ type Modeler struct {
 	Cache         cache.Config
 	Database      database.Config
 	Observability observability.Config
+	Prometheus    PrometheusConfig
 	RateLimit     ratelimit.Config
 
 	// DevMode produces additional debugging information. Do not enable in
@@ -43,6 +44,10 @@ type Modeler struct {
 	// modeler.
 	MinValue uint `env:"MODELER_MIN_VALUE, default=10"`
 	MaxValue uint `env:"MODELER_MAX_VALUE, default=20000"`
+
+	// WorkerAuth is the configuration for authenticating requests from the
+	// scheduler.
+	WorkerAuth WorkerAuthConfig
 }
 
 // NewModeler returns the config for the modeler server.
@@ -55,6 +60,9 @@ func NewModeler(ctx context.Context) (*Modeler, error) {
 }
 
 func (c *Modeler) Validate() error {
+	if err := c.WorkerAuth.Validate(); err != nil {
+		return err
+	}
 	return nil
 }
 
@@ -0,0 +1,25 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+// PrometheusConfig configures the optional Prometheus metrics endpoint. It is
+// independent of the observability exporter, so self-hosted operators can
+// scrape metrics in addition to (or instead of) exporting them.
+type PrometheusConfig struct {
+	// Port is the port on which metrics are served at /metrics. It is separate
+	// from the service's port so metrics are never served publicly. If empty,
+	// the endpoint is disabled.
+	Port string `env:"PROMETHEUS_METRICS_PORT"`
+}
@@ -0,0 +1,54 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"fmt"
+	"time"
+)
+
+// ReadinessConfig configures the readiness endpoint (/readyz), which probes the
+// service's dependencies so load balancers stop routing to instances that
+// cannot serve requests.
+type ReadinessConfig struct {
+	// Timeout is how long each dependency probe may take before the dependency
+	// is reported as unavailable.
+	Timeout time.Duration `env:"READINESS_TIMEOUT, default=2s"`
+
+	// Timeouts overrides the timeout of individual probes, keyed by dependency
+	// name (e.g. "database:1s,key_manager:5s").
+	Timeouts map[string]time.Duration `env:"READINESS_TIMEOUTS"`
+}
+
+// TimeoutFor returns the probe timeout for the named dependency.
+func (c *ReadinessConfig) TimeoutFor(name string) time.Duration {
+	if d, ok := c.Timeouts[name]; ok && d > 0 {
+		return d
+	}
+	return c.Timeout
+}
+
+// Validate validates the configuration.
+func (c *ReadinessConfig) Validate() error {
+	if c.Timeout <= 0 {
+		return fmt.Errorf("READINESS_TIMEOUT must be a positive duration, got: %v", c.Timeout)
+	}
+	for name, d := range c.Timeouts {
+		if d <= 0 {
+			return fmt.Errorf("READINESS_TIMEOUTS value for %q must be a positive duration, got: %v", name, d)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/google/exposure-notifications-server/pkg/keys"
+	"github.com/google/exposure-notifications-verification-server/pkg/storage"
+)
+
+// RealmExportConfig represents the settings for exporting a realm's records
+// for retention when it is offboarded.
+type RealmExportConfig struct {
+	// Keys determines the key manager configuration used to sign exports.
+	Keys keys.Config `env:", prefix=REALM_EXPORT_"`
+
+	// SigningKey is the key (version) used to sign the export archive. It is
+	// required when exports are enabled.
+	SigningKey string `env:"REALM_EXPORT_SIGNING_KEY"`
+
+	// Storage is the blob storage configuration for export archives.
+	Storage storage.Config
+
+	// Bucket is the name of the bucket to which export archives are written. If
+	// empty, realm exports are disabled.
+	Bucket string `env:"REALM_EXPORT_BUCKET"`
+
+	// MinPeriod is the minimum amount of time between export runs.
+	MinPeriod time.Duration `env:"REALM_EXPORT_MIN_PERIOD, default=5m"`
+}
+
+// Enabled returns true if realm exports are configured.
+func (c *RealmExportConfig) Enabled() bool {
+	return c.Bucket != ""
+}
+
+// Validate validates the configuration.
+func (c *RealmExportConfig) Validate() error {
+	if !c.Enabled() {
+		return nil
+	}
+
+	if c.SigningKey == "" {
+		return fmt.Errorf("REALM_EXPORT_SIGNING_KEY is required when REALM_EXPORT_BUCKET is set")
+	}
+
+	if err := checkPositiveDuration(c.MinPeriod, "REALM_EXPORT_MIN_PERIOD"); err != nil {
+		return err
+	}
+	return nil
+}
@@ -24,6 +24,7 @@ import (
 	"github.com/google/exposure-notifications-verification-server/pkg/cache"
 	"github.com/google/exposure-notifications-verification-server/pkg/database"
 	"github.com/google/exposure-notifications-verification-server/pkg/ratelimit"
+	"github.com/google/exposure-notifications-verification-server/pkg/workloadidentity"
 
 	"github.com/sethvargo/go-envconfig"
 )
@@ -32,10 +33,12 @@ var _ IssueAPIConfig = (*RedirectConfig)(nil)
 
 // RedirectConfig represents the environment based config for the redirect server.
 type RedirectConfig struct {
-	Database      database.Config
-	Observability observability.Config
-	Cache         cache.Config
-	Features      FeatureConfig
+	Database         database.Config
+	WorkloadIdentity workloadidentity.Config
+	Observability    observability.Config
+	Prometheus       PrometheusConfig
+	Cache            cache.Config
+	Features         FeatureConfig
 
 	Port string `env:"PORT, default=8080"`
 
@@ -123,8 +126,14 @@ func (c *RedirectConfig) DatabaseConfig() *database.Config {
 // Hostnames (key) are lowercased
 // Regions (value) are uppercased
 func (c *RedirectConfig) HostnameToRegion() (map[string]string, error) {
-	hostnameToRegion := make(map[string]string, len(c.HostnameConfig))
-	for hostname, region := range c.HostnameConfig {
+	return normalizeHostnameToRegion(c.HostnameConfig)
+}
+
+// normalizeHostnameToRegion lowercases the hostnames and uppercases the
+// regions in a HOSTNAME_TO_REGION mapping.
+func normalizeHostnameToRegion(m map[string]string) (map[string]string, error) {
+	hostnameToRegion := make(map[string]string, len(m))
+	for hostname, region := range m {
 		if hostname == "" {
 			return nil, fmt.Errorf("hostname empty for region value: %v", region)
 		}
@@ -19,6 +19,7 @@ import (
 	"time"
 
 	"github.com/google/exposure-notifications-verification-server/pkg/database"
+	"github.com/google/exposure-notifications-verification-server/pkg/workloadidentity"
 
 	"github.com/google/exposure-notifications-server/pkg/observability"
 	"github.com/google/exposure-notifications-server/pkg/secrets"
@@ -29,10 +30,12 @@ import (
 // RotationConfig represents the environment-based configuration for the
 // rotation service.
 type RotationConfig struct {
-	Database      database.Config
-	Observability observability.Config
-	Features      FeatureConfig
-	Secrets       secrets.Config
+	Database         database.Config
+	WorkloadIdentity workloadidentity.Config
+	Observability    observability.Config
+	Prometheus       PrometheusConfig
+	Features         FeatureConfig
+	Secrets          secrets.Config
 
 	// ProjectID is the Google Cloud project ID.
 	ProjectID string `env:"PROJECT_ID, required"`
@@ -87,6 +90,11 @@ type RotationConfig struct {
 	PhoneNumberDatabaseHMACKeyMinAge time.Duration `env:"PHONE_NUMBER_DATABASE_HMAC_MIN_AGE, default=720h"`  // 30d
 	PhoneNumberDatabaseHMACKeyMaxAge time.Duration `env:"PHONE_NUMBER_DATABASE_HMAC_MAX_AGE, default=2928h"` // 30d + 90d + 2d
 
+	// SMSSuppressionHMACKeyMinAge is the age at which to generate a new HMAC key
+	// for HMACing suppressed phone numbers. Suppressions never expire, so
+	// existing values are kept.
+	SMSSuppressionHMACKeyMinAge time.Duration `env:"SMS_SUPPRESSION_HMAC_KEY_MIN_AGE, default=4320h"` // 180d
+
 	// VerificationCodeDatabaseHMACKeyMinAge is the age at which to generate a new
 	// HMAC key for HMACing verification codes in the database.
 	// VerificationCodeDatabaseHMACKeyMaxAge is the age at which the HMAC key can
@@ -108,6 +116,10 @@ type RotationConfig struct {
 	// the upstream key server time to import the new allowed public key.
 	// A deactivated key will also be kept for this time period.
 	VerificationActivationDelay time.Duration `env:"VERIFICATION_ACTIVATION_DELAY, default=1h"`
+
+	// WorkerAuth is the configuration for authenticating requests from the
+	// scheduler.
+	WorkerAuth WorkerAuthConfig
 }
 
 // NewRotationConfig returns the config for the rotation service.
@@ -134,6 +146,7 @@ func (c *RotationConfig) Validate() error {
 		{c.APIKeySignatureHMACKeyMinAge, "API_KEY_SIGNATURE_HMAC_KEY_MIN_AGE", 0},
 		{c.PhoneNumberDatabaseHMACKeyMinAge, "PHONE_NUMBER_DATABASE_HMAC_MIN_AGE", 0},
 		{c.PhoneNumberDatabaseHMACKeyMaxAge, "PHONE_NUMBER_DATABASE_HMAC_MAX_AGE", 0},
+		{c.SMSSuppressionHMACKeyMinAge, "SMS_SUPPRESSION_HMAC_KEY_MIN_AGE", 0},
 		{c.VerificationCodeDatabaseHMACKeyMinAge, "VERIFICATION_CODE_DATABASE_HMAC_KEY_MIN_AGE", 0},
 		{c.VerificationCodeDatabaseHMACKeyMaxAge, "VERIFICATION_CODE_DATABASE_HMAC_KEY_MAX_AGE", 0},
 		{c.VerificationSigningKeyMaxAge, "VERIFICATION_SIGNING_KEY_MAX_AGE", 0},
@@ -147,6 +160,10 @@ func (c *RotationConfig) Validate() error {
 		}
 	}
 
+	if err := c.WorkerAuth.Validate(); err != nil {
+		return err
+	}
+
 	return nil
 }
 
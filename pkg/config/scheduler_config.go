@@ -0,0 +1,243 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/exposure-notifications-verification-server/pkg/database"
+
+	"github.com/google/exposure-notifications-server/pkg/observability"
+
+	"github.com/sethvargo/go-envconfig"
+)
+
+// SchedulerConfig represents the environment-based configuration for the
+// built-in scheduler. The scheduler replaces Cloud Scheduler for small
+// deployments by triggering the worker services itself.
+type SchedulerConfig struct {
+	Database      database.Config
+	Observability observability.Config
+	Prometheus    PrometheusConfig
+
+	// Port is the port upon which to bind.
+	Port string `env:"PORT, default=8080"`
+
+	// DevMode produces additional debugging information. Do not enable in
+	// production environments.
+	DevMode bool `env:"DEV_MODE"`
+
+	// The base URLs of the worker services. A service's jobs are only scheduled
+	// when its URL is set.
+	RotationURL    string `env:"SCHEDULER_ROTATION_URL"`
+	CleanupURL     string `env:"SCHEDULER_CLEANUP_URL"`
+	ModelerURL     string `env:"SCHEDULER_MODELER_URL"`
+	AppSyncURL     string `env:"SCHEDULER_APPSYNC_URL"`
+	StatsPullerURL string `env:"SCHEDULER_STATS_PULLER_URL"`
+	ServerURL      string `env:"SCHEDULER_SERVER_URL"`
+
+	// Intervals overrides the default interval of individual jobs, keyed by job
+	// name (e.g. "cleanup:10m,appsync:6h").
+	Intervals map[string]time.Duration `env:"SCHEDULER_INTERVALS"`
+
+	// EnabledJobs enables jobs which are not scheduled by default.
+	// DisabledJobs prevents jobs from being scheduled.
+	EnabledJobs  []string `env:"SCHEDULER_ENABLED_JOBS"`
+	DisabledJobs []string `env:"SCHEDULER_DISABLED_JOBS"`
+
+	// TickInterval is how often the scheduler checks for due jobs.
+	TickInterval time.Duration `env:"SCHEDULER_TICK_INTERVAL, default=15s"`
+
+	// MaxJitter is the maximum random delay added to each job's interval, so
+	// jobs with the same interval do not all fire at once.
+	MaxJitter time.Duration `env:"SCHEDULER_MAX_JITTER, default=30s"`
+
+	// LeaseDuration is how long leadership is held without renewal. Only the
+	// leader triggers jobs, so multiple scheduler instances can run for
+	// availability. It must be longer than TickInterval.
+	LeaseDuration time.Duration `env:"SCHEDULER_LEASE_DURATION, default=1m"`
+
+	// RequestTimeout is the maximum time to wait for a job to respond.
+	RequestTimeout time.Duration `env:"SCHEDULER_REQUEST_TIMEOUT, default=10m"`
+
+	// RunHistoryMaxAge is how long run history is kept.
+	RunHistoryMaxAge time.Duration `env:"SCHEDULER_RUN_HISTORY_MAX_AGE, default=720h"`
+
+	// UseIDTokens attaches a Google-signed ID token to each request, with the
+	// service's base URL as the audience. Enable this when the workers have
+	// WORKER_AUTH_AUDIENCE set.
+	UseIDTokens bool `env:"SCHEDULER_USE_ID_TOKENS"`
+}
+
+// ScheduledJob is a single endpoint triggered by the scheduler.
+type ScheduledJob struct {
+	// Name is the unique name of the job.
+	Name string
+
+	// URL and Method are the request made to trigger the job. Audience is the
+	// ID token audience, the service's base URL.
+	URL      string
+	Method   string
+	Audience string
+
+	// Interval is the time between runs, before jitter.
+	Interval time.Duration
+}
+
+// scheduledJobDefinition is an entry in the catalog of jobs the scheduler
+// knows how to trigger. The default intervals match the Cloud Scheduler jobs
+// in the Terraform configuration.
+type scheduledJobDefinition struct {
+	name     string
+	service  func(c *SchedulerConfig) string
+	path     string
+	method   string
+	interval time.Duration
+
+	// optIn jobs are only scheduled when listed in SCHEDULER_ENABLED_JOBS,
+	// because the endpoint is not always present.
+	optIn bool
+}
+
+var scheduledJobDefinitions = []*scheduledJobDefinition{
+	{name: "rotation-token-signing-key", service: rotationURL, path: "/token-signing-key", interval: 30 * time.Minute},
+	{name: "rotation-realm-verification-keys", service: rotationURL, path: "/realm-verification-keys", interval: 15 * time.Minute},
+	{name: "rotation-secrets", service: rotationURL, path: "/secrets", interval: 5 * time.Minute},
+	{name: "rotation-cookie-keys", service: rotationURL, path: "/cookie-keys", interval: 5 * time.Minute},
+	{name: "cleanup", service: cleanupURL, path: "/", interval: 5 * time.Minute},
+	{name: "cleanup-consistency", service: cleanupURL, path: "/consistency", interval: 24 * time.Hour},
+	{name: "cleanup-realm-kpi", service: cleanupURL, path: "/realm-kpi", interval: 5 * time.Minute},
+	{name: "cleanup-callbacks", service: cleanupURL, path: "/callbacks", interval: time.Minute},
+	{name: "cleanup-user-imports", service: cleanupURL, path: "/user-imports", interval: time.Minute},
+	{name: "cleanup-realm-export", service: cleanupURL, path: "/realm-export", interval: 15 * time.Minute, optIn: true},
+	{name: "cleanup-dual-write-verify", service: cleanupURL, path: "/dual-write-verify", interval: 6 * time.Hour, optIn: true},
+	{name: "modeler", service: modelerURL, path: "/", method: http.MethodPost, interval: 4 * time.Hour},
+	{name: "appsync", service: appSyncURL, path: "/", interval: 4 * time.Hour},
+	{name: "stats-puller", service: statsPullerURL, path: "/", interval: 15 * time.Minute},
+	{name: "stats-pusher", service: statsPullerURL, path: "/push", interval: time.Hour},
+	{name: "server-bulk-issue", service: serverURL, path: "/jobs/bulk-issue", interval: time.Minute},
+}
+
+func rotationURL(c *SchedulerConfig) string    { return c.RotationURL }
+func cleanupURL(c *SchedulerConfig) string     { return c.CleanupURL }
+func modelerURL(c *SchedulerConfig) string     { return c.ModelerURL }
+func appSyncURL(c *SchedulerConfig) string     { return c.AppSyncURL }
+func statsPullerURL(c *SchedulerConfig) string { return c.StatsPullerURL }
+func serverURL(c *SchedulerConfig) string      { return c.ServerURL }
+
+// NewSchedulerConfig returns the config for the scheduler service.
+func NewSchedulerConfig(ctx context.Context) (*SchedulerConfig, error) {
+	var config SchedulerConfig
+	if err := ProcessWith(ctx, &config, envconfig.OsLookuper()); err != nil {
+		return nil, err
+	}
+	return &config, nil
+}
+
+// Validate validates the configuration.
+func (c *SchedulerConfig) Validate() error {
+	known := make(map[string]struct{}, len(scheduledJobDefinitions))
+	for _, def := range scheduledJobDefinitions {
+		known[def.name] = struct{}{}
+	}
+
+	for name, interval := range c.Intervals {
+		if _, ok := known[name]; !ok {
+			return fmt.Errorf("SCHEDULER_INTERVALS: unknown job %q", name)
+		}
+		if interval <= 0 {
+			return fmt.Errorf("SCHEDULER_INTERVALS: interval for %q must be positive", name)
+		}
+	}
+	for _, name := range append(c.EnabledJobs, c.DisabledJobs...) {
+		if _, ok := known[strings.TrimSpace(name)]; !ok {
+			return fmt.Errorf("unknown scheduler job %q", name)
+		}
+	}
+
+	if c.TickInterval <= 0 {
+		return fmt.Errorf("SCHEDULER_TICK_INTERVAL must be positive")
+	}
+	if c.MaxJitter < 0 {
+		return fmt.Errorf("SCHEDULER_MAX_JITTER cannot be negative")
+	}
+	if c.LeaseDuration <= c.TickInterval {
+		return fmt.Errorf("SCHEDULER_LEASE_DURATION (%s) must be longer than SCHEDULER_TICK_INTERVAL (%s)",
+			c.LeaseDuration, c.TickInterval)
+	}
+
+	if len(c.Jobs()) == 0 {
+		return fmt.Errorf("no jobs to schedule, set at least one SCHEDULER_*_URL")
+	}
+	return nil
+}
+
+// Jobs returns the jobs to schedule, sorted by name.
+func (c *SchedulerConfig) Jobs() []*ScheduledJob {
+	enabled := make(map[string]struct{}, len(c.EnabledJobs))
+	for _, name := range c.EnabledJobs {
+		enabled[strings.TrimSpace(name)] = struct{}{}
+	}
+	disabled := make(map[string]struct{}, len(c.DisabledJobs))
+	for _, name := range c.DisabledJobs {
+		disabled[strings.TrimSpace(name)] = struct{}{}
+	}
+
+	jobs := make([]*ScheduledJob, 0, len(scheduledJobDefinitions))
+	for _, def := range scheduledJobDefinitions {
+		base := strings.TrimRight(def.service(c), "/")
+		if base == "" {
+			continue
+		}
+		if _, ok := disabled[def.name]; ok {
+			continue
+		}
+		if _, ok := enabled[def.name]; def.optIn && !ok {
+			continue
+		}
+
+		method := def.method
+		if method == "" {
+			method = http.MethodGet
+		}
+
+		interval := def.interval
+		if override, ok := c.Intervals[def.name]; ok {
+			interval = override
+		}
+
+		jobs = append(jobs, &ScheduledJob{
+			Name:     def.name,
+			URL:      base + def.path,
+			Method:   method,
+			Audience: base,
+			Interval: interval,
+		})
+	}
+
+	sort.Slice(jobs, func(i, j int) bool {
+		return jobs[i].Name < jobs[j].Name
+	})
+	return jobs
+}
+
+func (c *SchedulerConfig) ObservabilityExporterConfig() *observability.Config {
+	return &c.Observability
+}
@@ -25,6 +25,8 @@ import (
 	"github.com/google/exposure-notifications-verification-server/pkg/cache"
 	"github.com/google/exposure-notifications-verification-server/pkg/database"
 	"github.com/google/exposure-notifications-verification-server/pkg/ratelimit"
+	"github.com/google/exposure-notifications-verification-server/pkg/residency"
+	"github.com/google/exposure-notifications-verification-server/pkg/workloadidentity"
 	"github.com/microcosm-cc/bluemonday"
 	"github.com/russross/blackfriday/v2"
 
@@ -53,11 +55,13 @@ func (c *PasswordRequirementsConfig) HasRequirements() bool {
 
 // ServerConfig represents the environment based config for the server.
 type ServerConfig struct {
-	Firebase      FirebaseConfig
-	Database      database.Config
-	Observability observability.Config
-	Cache         cache.Config
-	Features      FeatureConfig
+	Firebase         FirebaseConfig
+	Database         database.Config
+	WorkloadIdentity workloadidentity.Config
+	Observability    observability.Config
+	Prometheus       PrometheusConfig
+	Cache            cache.Config
+	Features         FeatureConfig
 
 	// SystemNotice is an optional notice that will be presented at the top of all
 	// pages on the UI if provided. It supports markdown syntax.
@@ -77,6 +81,23 @@ type ServerConfig struct {
 	SessionIdleTimeout time.Duration `env:"SESSION_IDLE_TIMEOUT, default=20m"`
 	RevokeCheckPeriod  time.Duration `env:"REVOKE_CHECK_DURATION, default=5m"`
 
+	// RecentAuthTimeout is how recently a user must have signed in or
+	// re-authenticated before performing sensitive actions like destroying
+	// signing keys or deleting users. Set to 0 to disable.
+	RecentAuthTimeout time.Duration `env:"RECENT_AUTH_TIMEOUT, default=15m"`
+
+	// BreakGlassDuration is how long a break-glass account is a system admin
+	// after its activation is approved. It cannot exceed 24 hours.
+	BreakGlassDuration time.Duration `env:"BREAK_GLASS_DURATION, default=4h"`
+
+	// ImpersonationDuration is the default length of a system admin's realm
+	// impersonation. It cannot exceed 4 hours.
+	ImpersonationDuration time.Duration `env:"IMPERSONATION_DURATION, default=30m"`
+
+	// CSPReportOnly sends the UI Content-Security-Policy in report-only mode, so
+	// violations are reported to /csp-report but not blocked.
+	CSPReportOnly bool `env:"CSP_REPORT_ONLY, default=true"`
+
 	// Password Config
 	PasswordRequirements PasswordRequirementsConfig
 
@@ -93,13 +114,30 @@ type ServerConfig struct {
 	// Issue is configuration specific to the code issue APIs.
 	Issue IssueAPIVars
 
-	// If Dev mode is true, cookies aren't required to be sent over secure channels.
-	// This includes CSRF protection base cookie. You want this false in production (the default).
+	// BulkIssue is the configuration for bulk issue jobs uploaded in the UI.
+	BulkIssue BulkIssueConfig
+
+	// WorkerAuth is the configuration for authenticating the scheduler's
+	// requests to process bulk issue jobs.
+	WorkerAuth WorkerAuthConfig
+
+	// DevMode enables local development conveniences, such as reloading
+	// templates and locales, logging requests, and serving without the HTTPS
+	// redirect. You want this false in production (the default).
 	DevMode bool `env:"DEV_MODE"`
 
+	// Dev is the granular development configuration. Each toggle is off by
+	// default.
+	Dev DevModeConfig
+
 	// If MaintenanceMode is true, the server is temporarily read-only and will not issue codes.
 	MaintenanceMode bool `env:"MAINTENANCE_MODE"`
 
+	// SMSIgnoredErrorCodes is a list of SMS error codes that are not counted
+	// against SMS delivery health on realm status pages. See the emailer
+	// configuration for details on the defaults.
+	SMSIgnoredErrorCodes []string `env:"SMS_IGNORED_ERROR_CODES, default=30003,30004,30005,30006"`
+
 	// MinRealmsForSystemStatistics gives a minimum threshold for displaying system
 	// admin level statistics
 	MinRealmsForSystemStatistics uint `env:"MIN_REALMS_FOR_SYSTEM_STATS, default=2"`
@@ -109,6 +147,15 @@ type ServerConfig struct {
 
 	// Rate limiting configuration
 	RateLimit ratelimit.Config
+
+	// DataResidency is the data residency configuration.
+	DataResidency residency.Config
+
+	// BodyLimits is the maximum request body size configuration.
+	BodyLimits BodyLimitsConfig
+
+	// Readiness configures the dependency probes of the readiness endpoint.
+	Readiness ReadinessConfig
 }
 
 // NewServerConfig initializes and validates a ServerConfig struct.
@@ -160,6 +207,9 @@ func (c *ServerConfig) Validate() error {
 	}{
 		{c.SessionDuration, "SESSION_DURATION"},
 		{c.RevokeCheckPeriod, "REVOKE_CHECK_DURATION"},
+		{c.RecentAuthTimeout, "RECENT_AUTH_TIMEOUT"},
+		{c.BreakGlassDuration, "BREAK_GLASS_DURATION"},
+		{c.ImpersonationDuration, "IMPERSONATION_DURATION"},
 	}
 
 	for _, f := range fields {
@@ -172,6 +222,30 @@ func (c *ServerConfig) Validate() error {
 		return fmt.Errorf("failed to validate issue API configuration: %w", err)
 	}
 
+	if err := c.BulkIssue.Validate(); err != nil {
+		return fmt.Errorf("failed to validate bulk issue configuration: %w", err)
+	}
+
+	if err := c.WorkerAuth.Validate(); err != nil {
+		return fmt.Errorf("failed to validate worker auth configuration: %w", err)
+	}
+
+	if err := c.BodyLimits.Validate(); err != nil {
+		return fmt.Errorf("failed to validate body limits configuration: %w", err)
+	}
+
+	if err := c.Readiness.Validate(); err != nil {
+		return fmt.Errorf("failed to validate readiness configuration: %w", err)
+	}
+
+	if c.BreakGlassDuration > database.BreakGlassMaxDuration {
+		return fmt.Errorf("BREAK_GLASS_DURATION cannot be longer than %s", database.BreakGlassMaxDuration)
+	}
+
+	if c.ImpersonationDuration > database.RealmImpersonationMaxDuration {
+		return fmt.Errorf("IMPERSONATION_DURATION cannot be longer than %s", database.RealmImpersonationMaxDuration)
+	}
+
 	if c.MinRealmsForSystemStatistics < 2 {
 		return fmt.Errorf("MIN_REALMS_FOR_SYSTEM_STATS cannot be set lower than 2")
 	}
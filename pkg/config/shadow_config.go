@@ -0,0 +1,87 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"time"
+)
+
+// ShadowConfig configures shadow traffic, which validates a candidate API
+// server release against production traffic.
+//
+// The production API server mirrors a sample of verify and certificate
+// requests to the candidate and compares the responses in the background. The
+// candidate runs in dry-run mode, so mirrored requests never claim codes or
+// tokens.
+type ShadowConfig struct {
+	// CandidateURL is the base URL of the candidate deployment, with no trailing
+	// slash. If empty, no requests are mirrored.
+	CandidateURL string `env:"SHADOW_CANDIDATE_URL"`
+
+	// SampleRate is the fraction of eligible requests, between 0 and 1, that are
+	// mirrored to the candidate.
+	SampleRate float64 `env:"SHADOW_SAMPLE_RATE, default=0.01"`
+
+	// Timeout is the maximum amount of time to wait for the candidate to
+	// respond to a mirrored request.
+	Timeout time.Duration `env:"SHADOW_TIMEOUT, default=5s"`
+
+	// MaxInFlight is the maximum number of mirrored requests outstanding at a
+	// time. Requests sampled while at the limit are not mirrored, so a slow
+	// candidate cannot build up work on the production server.
+	MaxInFlight int `env:"SHADOW_MAX_IN_FLIGHT, default=50"`
+
+	// DryRun runs this deployment as a shadow candidate. Verification codes and
+	// tokens are checked, but never claimed, and claim failures and statistics
+	// are not recorded. Never enable this on a deployment that serves real
+	// traffic, since the verification tokens it issues cannot be used.
+	DryRun bool `env:"SHADOW_DRY_RUN"`
+}
+
+// Enabled returns true if requests should be mirrored to a candidate.
+func (c *ShadowConfig) Enabled() bool {
+	return c.CandidateURL != "" && c.SampleRate > 0
+}
+
+// Validate validates the configuration.
+func (c *ShadowConfig) Validate() error {
+	if c.CandidateURL != "" {
+		u, err := url.Parse(c.CandidateURL)
+		if err != nil {
+			return fmt.Errorf("SHADOW_CANDIDATE_URL is invalid: %w", err)
+		}
+		if u.Scheme != "http" && u.Scheme != "https" {
+			return fmt.Errorf("SHADOW_CANDIDATE_URL must be an http or https URL, got %q", c.CandidateURL)
+		}
+		if c.DryRun {
+			return fmt.Errorf("SHADOW_CANDIDATE_URL and SHADOW_DRY_RUN cannot both be set")
+		}
+	}
+
+	if c.SampleRate < 0 || c.SampleRate > 1 {
+		return fmt.Errorf("SHADOW_SAMPLE_RATE must be between 0 and 1, got %v", c.SampleRate)
+	}
+
+	if c.Timeout <= 0 {
+		return fmt.Errorf("SHADOW_TIMEOUT must be a positive duration, got: %v", c.Timeout)
+	}
+
+	if c.MaxInFlight <= 0 {
+		return fmt.Errorf("SHADOW_MAX_IN_FLIGHT must be a positive value, got: %v", c.MaxInFlight)
+	}
+	return nil
+}
@@ -16,9 +16,11 @@ package config
 
 import (
 	"context"
+	"fmt"
 	"time"
 
 	"github.com/google/exposure-notifications-verification-server/pkg/database"
+	"github.com/google/exposure-notifications-verification-server/pkg/workloadidentity"
 
 	"github.com/google/exposure-notifications-server/pkg/observability"
 
@@ -28,9 +30,11 @@ import (
 // StatsPullerConfig represents the environment-based configuration for the
 // stats-puller service.
 type StatsPullerConfig struct {
-	Database      database.Config
-	Observability observability.Config
-	Features      FeatureConfig
+	Database         database.Config
+	WorkloadIdentity workloadidentity.Config
+	Observability    observability.Config
+	Prometheus       PrometheusConfig
+	Features         FeatureConfig
 
 	// Certificate signing
 	CertificateSigning CertificateSigningConfig
@@ -43,6 +47,13 @@ type StatsPullerConfig struct {
 	FileSizeLimitBytes     int64         `env:"STATS_PULLER_SIZE_LIMIT, default=64000"`
 	DownloadTimeout        time.Duration `env:"STATS_PULLER_DOWNLOAD_TIMEOUT, default=1m"`
 
+	// KeyServerSources are additional key servers (for example, regional key
+	// servers next to a national one) to pull stats from for every realm, in
+	// addition to each realm's primary key server. It maps a source label to the
+	// key server URL, e.g. "regional:https://keys.example.com". Requests to
+	// these key servers use KeyServerStatsAudience.
+	KeyServerSources map[string]string `env:"KEY_SERVER_SOURCES"`
+
 	// Port is the port upon which to bind.
 	Port string `env:"PORT, default=8080"`
 
@@ -63,6 +74,14 @@ type StatsPullerConfig struct {
 	// MaxWorkers is the maximum number of parallel workers to use when pulling
 	// statistics. The value must be greater than 0.
 	MaxWorkers int64 `env:"STATS_PULLER_MAX_WORKERS, default=5"`
+
+	// StatsPush is the configuration for pushing statistics to a national
+	// aggregation service.
+	StatsPush StatsPushConfig
+
+	// WorkerAuth is the configuration for authenticating requests from the
+	// scheduler.
+	WorkerAuth WorkerAuthConfig
 }
 
 // NewStatsPullerConfig returns the config for the stats-puller service.
@@ -74,6 +93,26 @@ func NewStatsPullerConfig(ctx context.Context) (*StatsPullerConfig, error) {
 	return &config, nil
 }
 
+// Validate validates the configuration.
+func (c *StatsPullerConfig) Validate() error {
+	if err := c.StatsPush.Validate(); err != nil {
+		return fmt.Errorf("failed to validate stats push config: %w", err)
+	}
+	if err := c.WorkerAuth.Validate(); err != nil {
+		return err
+	}
+
+	for label, u := range c.KeyServerSources {
+		if label == "" || label == database.KeyServerStatsSourcePrimary {
+			return fmt.Errorf("KEY_SERVER_SOURCES label %q is reserved", label)
+		}
+		if u == "" {
+			return fmt.Errorf("KEY_SERVER_SOURCES entry %q is missing a URL", label)
+		}
+	}
+	return nil
+}
+
 func (c *StatsPullerConfig) ObservabilityExporterConfig() *observability.Config {
 	return &c.Observability
 }
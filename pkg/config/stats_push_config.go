@@ -0,0 +1,96 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"time"
+)
+
+// StatsPushConfig represents the settings for pushing daily aggregate
+// statistics to a national or central aggregation service. Pushing is
+// disabled unless a URL is provided.
+type StatsPushConfig struct {
+	// URL is the aggregation service endpoint. If empty, statistics are not
+	// pushed.
+	URL string `env:"STATS_PUSH_URL"`
+
+	// Secret is the shared secret used to sign payloads. It is required when
+	// URL is set.
+	Secret string `env:"STATS_PUSH_SECRET"`
+
+	// Source identifies this server to the aggregation service. It is required
+	// when URL is set.
+	Source string `env:"STATS_PUSH_SOURCE"`
+
+	// Days is the number of most recent days included in each push. Days are
+	// re-sent on every push so that late-arriving statistics are corrected.
+	Days uint `env:"STATS_PUSH_DAYS, default=7"`
+
+	// Timeout is the maximum amount of time to wait for a single request.
+	Timeout time.Duration `env:"STATS_PUSH_TIMEOUT, default=30s"`
+
+	// MaxRetries and RetryBackoff control how failed requests are retried.
+	// The backoff doubles after each attempt.
+	MaxRetries   uint64        `env:"STATS_PUSH_MAX_RETRIES, default=3"`
+	RetryBackoff time.Duration `env:"STATS_PUSH_RETRY_BACKOFF, default=1s"`
+
+	// MinPeriod defines the period for which the stats pusher will hold a lock
+	// which prevents other calls from entering.
+	MinPeriod time.Duration `env:"STATS_PUSH_MIN_PERIOD, default=30m"`
+}
+
+// Enabled returns true if statistics should be pushed.
+func (c *StatsPushConfig) Enabled() bool {
+	return c.URL != ""
+}
+
+// Validate validates the configuration.
+func (c *StatsPushConfig) Validate() error {
+	fields := []struct {
+		Var  time.Duration
+		Name string
+	}{
+		{c.Timeout, "STATS_PUSH_TIMEOUT"},
+		{c.RetryBackoff, "STATS_PUSH_RETRY_BACKOFF"},
+		{c.MinPeriod, "STATS_PUSH_MIN_PERIOD"},
+	}
+
+	for _, f := range fields {
+		if err := checkPositiveDuration(f.Var, f.Name); err != nil {
+			return err
+		}
+	}
+
+	if !c.Enabled() {
+		return nil
+	}
+
+	u, err := url.Parse(c.URL)
+	if err != nil || u.Scheme != "https" || u.Host == "" {
+		return fmt.Errorf("STATS_PUSH_URL must be an https:// URL")
+	}
+	if c.Secret == "" {
+		return fmt.Errorf("STATS_PUSH_SECRET is required when STATS_PUSH_URL is set")
+	}
+	if c.Source == "" {
+		return fmt.Errorf("STATS_PUSH_SOURCE is required when STATS_PUSH_URL is set")
+	}
+	if c.Days == 0 {
+		return fmt.Errorf("STATS_PUSH_DAYS must be greater than 0")
+	}
+	return nil
+}
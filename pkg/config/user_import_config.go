@@ -0,0 +1,42 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"fmt"
+	"time"
+)
+
+// UserImportConfig represents the settings for processing bulk user imports
+// requested through the admin API.
+type UserImportConfig struct {
+	// BatchSize is the maximum number of imports processed per run.
+	BatchSize uint64 `env:"USER_IMPORT_BATCH_SIZE, default=10"`
+
+	// MinPeriod is the minimum amount of time between import runs.
+	MinPeriod time.Duration `env:"USER_IMPORT_MIN_PERIOD, default=30s"`
+}
+
+// Validate validates the configuration.
+func (c *UserImportConfig) Validate() error {
+	if c.BatchSize < 1 {
+		return fmt.Errorf("USER_IMPORT_BATCH_SIZE must be at least 1")
+	}
+
+	if err := checkPositiveDuration(c.MinPeriod, "USER_IMPORT_MIN_PERIOD"); err != nil {
+		return err
+	}
+	return nil
+}
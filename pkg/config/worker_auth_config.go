@@ -0,0 +1,50 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"fmt"
+)
+
+// WorkerAuthConfig is the configuration for authenticating requests to
+// internal workers (rotation, cleanup, modeler, stats-puller, and the server's
+// bulk issue worker). Workers are triggered by a scheduler and are not meant to
+// be publicly reachable. When an
+// audience is set, every request must carry a Google-signed OIDC ID token
+// (e.g. from Cloud Scheduler or a Cloud Run service identity) for that
+// audience, so an accidentally exposed worker URL cannot be invoked by anyone
+// else.
+type WorkerAuthConfig struct {
+	// Audience is the expected "aud" claim of the ID token, usually the
+	// worker's URL. If empty, requests are not authenticated.
+	Audience string `env:"WORKER_AUTH_AUDIENCE"`
+
+	// AllowedEmails restricts callers to the given service account emails. If
+	// empty, any valid token for the audience is accepted.
+	AllowedEmails []string `env:"WORKER_AUTH_ALLOWED_EMAILS"`
+}
+
+// Enabled returns true if requests must be authenticated.
+func (c *WorkerAuthConfig) Enabled() bool {
+	return c.Audience != ""
+}
+
+// Validate validates the configuration.
+func (c *WorkerAuthConfig) Validate() error {
+	if !c.Enabled() && len(c.AllowedEmails) > 0 {
+		return fmt.Errorf("WORKER_AUTH_ALLOWED_EMAILS requires WORKER_AUTH_AUDIENCE")
+	}
+	return nil
+}
@@ -0,0 +1,224 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package admin
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/google/exposure-notifications-verification-server/internal/project"
+	"github.com/google/exposure-notifications-verification-server/pkg/controller"
+	"github.com/google/exposure-notifications-verification-server/pkg/database"
+	"github.com/gorilla/mux"
+)
+
+// announcementTimeFormat is the format of datetime-local form inputs. Times
+// are interpreted as UTC.
+const announcementTimeFormat = "2006-01-02T15:04"
+
+// announcementFormData is the form for creating or updating an announcement.
+type announcementFormData struct {
+	Title    string `form:"title"`
+	Body     string `form:"body"`
+	Audience string `form:"audience"`
+	StartsAt string `form:"starts_at"`
+	EndsAt   string `form:"ends_at"`
+}
+
+// apply sets the form values on the announcement. Unparseable times are added
+// as errors, which fail validation when the announcement is saved.
+func (f *announcementFormData) apply(a *database.Announcement) {
+	a.Title = f.Title
+	a.Body = f.Body
+	a.Audience = database.AnnouncementAudience(f.Audience)
+
+	a.StartsAt = time.Time{}
+	if v := project.TrimSpace(f.StartsAt); v != "" {
+		t, err := time.Parse(announcementTimeFormat, v)
+		if err != nil {
+			a.AddError("startsAt", "is not a valid time")
+		}
+		a.StartsAt = t
+	}
+
+	a.EndsAt = nil
+	if v := project.TrimSpace(f.EndsAt); v != "" {
+		t, err := time.Parse(announcementTimeFormat, v)
+		if err != nil {
+			a.AddError("endsAt", "is not a valid time")
+		} else {
+			a.EndsAt = &t
+		}
+	}
+}
+
+// HandleAnnouncementsIndex lists the announcements.
+func (c *Controller) HandleAnnouncementsIndex() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		announcements, err := c.db.ListAnnouncements()
+		if err != nil {
+			controller.InternalError(w, r, c.h, err)
+			return
+		}
+
+		m := controller.TemplateMapFromContext(ctx)
+		m.Title("Announcements - System Admin")
+		m["announcements"] = announcements
+		m["now"] = time.Now().UTC()
+		c.h.RenderHTML(w, "admin/announcements/index", m)
+	})
+}
+
+// HandleAnnouncementsCreate renders the form for and creates a new
+// announcement.
+func (c *Controller) HandleAnnouncementsCreate() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		session := controller.SessionFromContext(ctx)
+		if session == nil {
+			controller.MissingSession(w, r, c.h)
+			return
+		}
+		flash := controller.Flash(session)
+
+		currentUser := controller.UserFromContext(ctx)
+		if currentUser == nil {
+			controller.MissingUser(w, r, c.h)
+			return
+		}
+
+		// Requested form, stop processing.
+		if r.Method == http.MethodGet {
+			announcement := &database.Announcement{
+				Audience: database.AnnouncementAudienceAll,
+				StartsAt: time.Now().UTC(),
+			}
+			c.renderEditAnnouncement(ctx, w, announcement, 0)
+			return
+		}
+
+		announcement := new(database.Announcement)
+
+		var form announcementFormData
+		if err := controller.BindForm(w, r, &form); err != nil {
+			announcement.AddError("", err.Error())
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			c.renderEditAnnouncement(ctx, w, announcement, 0)
+			return
+		}
+
+		form.apply(announcement)
+		if err := c.db.SaveAnnouncement(announcement, currentUser); err != nil {
+			if database.IsValidationError(err) {
+				w.WriteHeader(http.StatusUnprocessableEntity)
+				c.renderEditAnnouncement(ctx, w, announcement, 0)
+				return
+			}
+
+			controller.InternalError(w, r, c.h, err)
+			return
+		}
+
+		flash.Alert("Created announcement %q", announcement.Title)
+		http.Redirect(w, r, "/admin/announcements", http.StatusSeeOther)
+	})
+}
+
+// HandleAnnouncementsUpdate renders the form for and updates an existing
+// announcement.
+func (c *Controller) HandleAnnouncementsUpdate() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		vars := mux.Vars(r)
+
+		session := controller.SessionFromContext(ctx)
+		if session == nil {
+			controller.MissingSession(w, r, c.h)
+			return
+		}
+		flash := controller.Flash(session)
+
+		currentUser := controller.UserFromContext(ctx)
+		if currentUser == nil {
+			controller.MissingUser(w, r, c.h)
+			return
+		}
+
+		announcement, err := c.db.FindAnnouncement(vars["id"])
+		if err != nil {
+			if database.IsNotFound(err) {
+				controller.Unauthorized(w, r, c.h)
+				return
+			}
+
+			controller.InternalError(w, r, c.h, err)
+			return
+		}
+
+		reads, err := c.db.CountAnnouncementReads(announcement)
+		if err != nil {
+			controller.InternalError(w, r, c.h, err)
+			return
+		}
+
+		// Requested form, stop processing.
+		if r.Method == http.MethodGet {
+			c.renderEditAnnouncement(ctx, w, announcement, reads)
+			return
+		}
+
+		var form announcementFormData
+		if err := controller.BindForm(w, r, &form); err != nil {
+			announcement.AddError("", err.Error())
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			c.renderEditAnnouncement(ctx, w, announcement, reads)
+			return
+		}
+
+		form.apply(announcement)
+		if err := c.db.SaveAnnouncement(announcement, currentUser); err != nil {
+			if database.IsValidationError(err) {
+				w.WriteHeader(http.StatusUnprocessableEntity)
+				c.renderEditAnnouncement(ctx, w, announcement, reads)
+				return
+			}
+
+			controller.InternalError(w, r, c.h, err)
+			return
+		}
+
+		flash.Alert("Updated announcement %q", announcement.Title)
+		http.Redirect(w, r, fmt.Sprintf("/admin/announcements/%d/edit", announcement.ID), http.StatusSeeOther)
+	})
+}
+
+func (c *Controller) renderEditAnnouncement(ctx context.Context, w http.ResponseWriter, announcement *database.Announcement, reads int64) {
+	m := controller.TemplateMapFromContext(ctx)
+	if announcement.ID == 0 {
+		m.Title("New announcement - System Admin")
+	} else {
+		m.Title("Announcement: %s - System Admin", announcement.Title)
+	}
+	m["announcement"] = announcement
+	m["audiences"] = database.AnnouncementAudiences
+	m["timeFormat"] = announcementTimeFormat
+	m["reads"] = reads
+	c.h.RenderHTML(w, "admin/announcements/edit", m)
+}
@@ -0,0 +1,114 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package admin
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/google/exposure-notifications-verification-server/pkg/controller"
+	"github.com/google/exposure-notifications-verification-server/pkg/database"
+)
+
+// claimFailureWindow is how far back the claim failure diagnostics look.
+const claimFailureWindow = 1 * time.Hour
+
+// claimFailureReasonCount is the total number of claim failures for a reason.
+type claimFailureReasonCount struct {
+	Reason string `json:"reason"`
+	Count  int64  `json:"count"`
+}
+
+// claimFailuresResponse is the JSON response for claim failure diagnostics.
+type claimFailuresResponse struct {
+	Since   time.Time                       `json:"since"`
+	Reasons []*claimFailureReasonCount      `json:"reasons"`
+	APIKeys []*database.ClaimFailureSummary `json:"apiKeys"`
+}
+
+// HandleClaimFailuresIndex shows the verification code claim failures in the
+// last hour across all realms, grouped by reason and by API key.
+func (c *Controller) HandleClaimFailuresIndex() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		session := controller.SessionFromContext(ctx)
+		if session == nil {
+			controller.MissingSession(w, r, c.h)
+			return
+		}
+
+		resp, err := c.claimFailures()
+		if err != nil {
+			controller.InternalError(w, r, c.h, err)
+			return
+		}
+
+		c.renderClaimFailures(ctx, w, resp)
+	})
+}
+
+// HandleClaimFailuresJSON returns the same data as HandleClaimFailuresIndex as
+// JSON, for polling and scripting during incidents.
+func (c *Controller) HandleClaimFailuresJSON() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		resp, err := c.claimFailures()
+		if err != nil {
+			controller.InternalError(w, r, c.h, err)
+			return
+		}
+
+		c.h.RenderJSON(w, http.StatusOK, resp)
+	})
+}
+
+// claimFailures builds the claim failure summary for the current window.
+func (c *Controller) claimFailures() (*claimFailuresResponse, error) {
+	since := time.Now().UTC().Add(-claimFailureWindow)
+
+	summaries, err := c.db.SummarizeClaimFailures(since)
+	if err != nil {
+		return nil, err
+	}
+
+	totals := make(map[string]int64, len(database.ClaimFailureReasons))
+	for _, s := range summaries {
+		totals[s.Reason] += s.Count
+	}
+
+	reasons := make([]*claimFailureReasonCount, 0, len(database.ClaimFailureReasons))
+	for _, reason := range database.ClaimFailureReasons {
+		reasons = append(reasons, &claimFailureReasonCount{
+			Reason: reason,
+			Count:  totals[reason],
+		})
+	}
+
+	return &claimFailuresResponse{
+		Since:   since,
+		Reasons: reasons,
+		APIKeys: summaries,
+	}, nil
+}
+
+func (c *Controller) renderClaimFailures(ctx context.Context, w http.ResponseWriter, resp *claimFailuresResponse) {
+	m := controller.TemplateMapFromContext(ctx)
+	m.Title("Claim failures - System Admin")
+	m["since"] = resp.Since
+	m["reasons"] = resp.Reasons
+	m["apiKeys"] = resp.APIKeys
+	c.h.RenderHTML(w, "admin/claim-failures/index", m)
+}
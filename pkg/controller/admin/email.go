@@ -83,6 +83,8 @@ func (c *Controller) HandleEmailUpdate() http.Handler {
 			return
 		}
 
+		c.recordConfigChange(ctx, "system email config", "updated system email config")
+
 		flash.Alert("Successfully updated system email config")
 		http.Redirect(w, r, "/admin/email", http.StatusSeeOther)
 	})
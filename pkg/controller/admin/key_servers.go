@@ -0,0 +1,191 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package admin
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/google/exposure-notifications-verification-server/pkg/controller"
+	"github.com/google/exposure-notifications-verification-server/pkg/database"
+	"github.com/gorilla/mux"
+)
+
+// keyServerFormData is the form for creating or updating a key server.
+type keyServerFormData struct {
+	Name                string `form:"name"`
+	URL                 string `form:"url"`
+	StatsAudience       string `form:"stats_audience"`
+	CertificateAudience string `form:"certificate_audience"`
+	SigningKey          string `form:"signing_key"`
+	SigningKeyID        string `form:"signing_key_id"`
+	Issuer              string `form:"issuer"`
+}
+
+// HandleKeyServersIndex lists the key servers.
+func (c *Controller) HandleKeyServersIndex() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		keyServers, err := c.db.ListKeyServers()
+		if err != nil {
+			controller.InternalError(w, r, c.h, err)
+			return
+		}
+
+		m := controller.TemplateMapFromContext(ctx)
+		m.Title("Key servers - System Admin")
+		m["keyServers"] = keyServers
+		c.h.RenderHTML(w, "admin/key-servers/index", m)
+	})
+}
+
+// HandleKeyServersCreate renders the form for and creates a new key server.
+func (c *Controller) HandleKeyServersCreate() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		session := controller.SessionFromContext(ctx)
+		if session == nil {
+			controller.MissingSession(w, r, c.h)
+			return
+		}
+		flash := controller.Flash(session)
+
+		currentUser := controller.UserFromContext(ctx)
+		if currentUser == nil {
+			controller.MissingUser(w, r, c.h)
+			return
+		}
+
+		// Requested form, stop processing.
+		if r.Method == http.MethodGet {
+			c.renderEditKeyServer(ctx, w, new(database.KeyServer))
+			return
+		}
+
+		var form keyServerFormData
+		if err := controller.BindForm(w, r, &form); err != nil {
+			keyServer := new(database.KeyServer)
+			keyServer.AddError("", err.Error())
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			c.renderEditKeyServer(ctx, w, keyServer)
+			return
+		}
+
+		keyServer := &database.KeyServer{
+			Name:                form.Name,
+			URL:                 form.URL,
+			StatsAudience:       form.StatsAudience,
+			CertificateAudience: form.CertificateAudience,
+			SigningKey:          form.SigningKey,
+			SigningKeyID:        form.SigningKeyID,
+			Issuer:              form.Issuer,
+		}
+		if err := c.db.SaveKeyServer(keyServer, currentUser); err != nil {
+			if database.IsValidationError(err) {
+				w.WriteHeader(http.StatusUnprocessableEntity)
+				c.renderEditKeyServer(ctx, w, keyServer)
+				return
+			}
+
+			controller.InternalError(w, r, c.h, err)
+			return
+		}
+
+		flash.Alert("Created key server %q", keyServer.Name)
+		http.Redirect(w, r, "/admin/key-servers", http.StatusSeeOther)
+	})
+}
+
+// HandleKeyServersUpdate renders the form for and updates an existing key
+// server.
+func (c *Controller) HandleKeyServersUpdate() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		vars := mux.Vars(r)
+
+		session := controller.SessionFromContext(ctx)
+		if session == nil {
+			controller.MissingSession(w, r, c.h)
+			return
+		}
+		flash := controller.Flash(session)
+
+		currentUser := controller.UserFromContext(ctx)
+		if currentUser == nil {
+			controller.MissingUser(w, r, c.h)
+			return
+		}
+
+		keyServer, err := c.db.FindKeyServer(vars["id"])
+		if err != nil {
+			if database.IsNotFound(err) {
+				controller.Unauthorized(w, r, c.h)
+				return
+			}
+
+			controller.InternalError(w, r, c.h, err)
+			return
+		}
+
+		// Requested form, stop processing.
+		if r.Method == http.MethodGet {
+			c.renderEditKeyServer(ctx, w, keyServer)
+			return
+		}
+
+		var form keyServerFormData
+		if err := controller.BindForm(w, r, &form); err != nil {
+			keyServer.AddError("", err.Error())
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			c.renderEditKeyServer(ctx, w, keyServer)
+			return
+		}
+
+		keyServer.Name = form.Name
+		keyServer.URL = form.URL
+		keyServer.StatsAudience = form.StatsAudience
+		keyServer.CertificateAudience = form.CertificateAudience
+		keyServer.SigningKey = form.SigningKey
+		keyServer.SigningKeyID = form.SigningKeyID
+		keyServer.Issuer = form.Issuer
+		if err := c.db.SaveKeyServer(keyServer, currentUser); err != nil {
+			if database.IsValidationError(err) {
+				w.WriteHeader(http.StatusUnprocessableEntity)
+				c.renderEditKeyServer(ctx, w, keyServer)
+				return
+			}
+
+			controller.InternalError(w, r, c.h, err)
+			return
+		}
+
+		flash.Alert("Updated key server %q", keyServer.Name)
+		http.Redirect(w, r, fmt.Sprintf("/admin/key-servers/%d/edit", keyServer.ID), http.StatusSeeOther)
+	})
+}
+
+func (c *Controller) renderEditKeyServer(ctx context.Context, w http.ResponseWriter, keyServer *database.KeyServer) {
+	m := controller.TemplateMapFromContext(ctx)
+	if keyServer.ID == 0 {
+		m.Title("New key server - System Admin")
+	} else {
+		m.Title("Key server: %s - System Admin", keyServer.Name)
+	}
+	m["keyServer"] = keyServer
+	c.h.RenderHTML(w, "admin/key-servers/edit", m)
+}
@@ -0,0 +1,45 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package admin
+
+import (
+	enobs "github.com/google/exposure-notifications-server/pkg/observability"
+	"github.com/google/exposure-notifications-verification-server/pkg/observability"
+
+	"go.opencensus.io/stats"
+	"go.opencensus.io/stats/view"
+	"go.opencensus.io/tag"
+)
+
+const metricPrefix = observability.MetricRoot + "/admin"
+
+var (
+	mBreakGlass = stats.Int64(metricPrefix+"/break_glass", "The number of break-glass activation changes.", stats.UnitDimensionless)
+
+	// breakGlassActionTagKey is one of requested, approved, or revoked.
+	breakGlassActionTagKey = tag.MustNewKey("action")
+)
+
+func init() {
+	enobs.CollectViews([]*view.View{
+		{
+			Name:        metricPrefix + "/break_glass_count",
+			Measure:     mBreakGlass,
+			Description: "The count of break-glass activation changes, tagged by action.",
+			TagKeys:     append(observability.CommonTagKeys(), breakGlassActionTagKey),
+			Aggregation: view.Count(),
+		},
+	}...)
+}
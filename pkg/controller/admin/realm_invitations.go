@@ -0,0 +1,326 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package admin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/exposure-notifications-verification-server/pkg/controller"
+	"github.com/google/exposure-notifications-verification-server/pkg/database"
+	"github.com/google/exposure-notifications-verification-server/pkg/pagination"
+	"github.com/gorilla/mux"
+)
+
+// realmInvitationDuration is how long an invitation link works before the
+// invitee must submit it.
+const realmInvitationDuration = 14 * 24 * time.Hour
+
+// HandleRealmInvitationsIndex lists the realm invitations.
+func (c *Controller) HandleRealmInvitationsIndex() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		pageParams, err := pagination.FromRequest(r)
+		if err != nil {
+			controller.BadRequest(w, r, c.h)
+			return
+		}
+
+		invitations, paginator, err := c.db.ListRealmInvitations(pageParams)
+		if err != nil {
+			controller.InternalError(w, r, c.h, err)
+			return
+		}
+
+		realms := make(map[uint]*database.Realm, len(invitations))
+		for _, invitation := range invitations {
+			if _, ok := realms[invitation.RealmID]; ok {
+				continue
+			}
+
+			realm, err := invitation.Realm(c.db)
+			if err != nil {
+				controller.InternalError(w, r, c.h, err)
+				return
+			}
+			realms[invitation.RealmID] = realm
+		}
+
+		m := controller.TemplateMapFromContext(ctx)
+		m.Title("Realm invitations - System Admin")
+		m["invitations"] = invitations
+		m["realms"] = realms
+		m["paginator"] = paginator
+		c.h.RenderHTML(w, "admin/realm-invitations/index", m)
+	})
+}
+
+// HandleRealmInvitationsCreate renders the form for and creates a new pending
+// realm and an invitation for its admin. The invitee's user account is created
+// if it does not exist, but they are not added to the realm until the
+// invitation is approved.
+func (c *Controller) HandleRealmInvitationsCreate() http.Handler {
+	type FormData struct {
+		Name                  string `form:"name"`
+		Email                 string `form:"email"`
+		CanUseSystemSMSConfig bool   `form:"can_use_system_sms_config"`
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		session := controller.SessionFromContext(ctx)
+		if session == nil {
+			controller.MissingSession(w, r, c.h)
+			return
+		}
+		flash := controller.Flash(session)
+
+		currentUser := controller.UserFromContext(ctx)
+		if currentUser == nil {
+			controller.MissingUser(w, r, c.h)
+			return
+		}
+
+		// Requested form, stop processing.
+		if r.Method == http.MethodGet {
+			c.renderNewRealmInvitation(ctx, w, r, &database.Realm{}, &database.RealmInvitation{})
+			return
+		}
+
+		var form FormData
+		if err := controller.BindForm(w, r, &form); err != nil {
+			invitation := &database.RealmInvitation{}
+			invitation.AddError("", err.Error())
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			c.renderNewRealmInvitation(ctx, w, r, &database.Realm{}, invitation)
+			return
+		}
+
+		// Check the email before the realm is created, since the invitation is
+		// saved last.
+		invitation := &database.RealmInvitation{Email: form.Email}
+		if !strings.Contains(form.Email, "@") {
+			invitation.AddError("email", "is invalid")
+		}
+
+		realm := database.NewRealmWithDefaults(form.Name)
+		realm.UseRealmCertificateKey = c.db.SupportsPerRealmSigning()
+		realm.CanUseSystemSMSConfig = form.CanUseSystemSMSConfig
+		if len(invitation.ErrorMessages()) > 0 {
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			c.renderNewRealmInvitation(ctx, w, r, realm, invitation)
+			return
+		}
+
+		if err := c.db.SaveRealm(realm, currentUser); err != nil {
+			if database.IsValidationError(err) {
+				w.WriteHeader(http.StatusUnprocessableEntity)
+				c.renderNewRealmInvitation(ctx, w, r, realm, invitation)
+				return
+			}
+
+			controller.InternalError(w, r, c.h, err)
+			return
+		}
+
+		// Create the invitee's user account so they can sign in to complete the
+		// setup wizard.
+		user, err := c.db.FindUserByEmail(form.Email)
+		if err != nil {
+			if !database.IsNotFound(err) {
+				controller.InternalError(w, r, c.h, err)
+				return
+			}
+
+			user = &database.User{
+				Name:  form.Email,
+				Email: form.Email,
+			}
+			if err := c.db.SaveUser(user, currentUser); err != nil {
+				if database.IsValidationError(err) {
+					for _, msg := range user.ErrorsFor("email") {
+						invitation.AddError("email", msg)
+					}
+					w.WriteHeader(http.StatusUnprocessableEntity)
+					c.renderNewRealmInvitation(ctx, w, r, realm, invitation)
+					return
+				}
+
+				controller.InternalError(w, r, c.h, err)
+				return
+			}
+		}
+
+		inviteComposer, err := c.inviteComposer(ctx, user.Email)
+		if err != nil {
+			controller.InternalError(w, r, c.h, err)
+			return
+		}
+		if _, err := c.authProvider.CreateUser(ctx, user.Name, user.Email, "", true, inviteComposer); err != nil {
+			flash.Error("Failed to create the invitee's account: %v", err)
+		}
+
+		invitation, token, err := c.db.CreateRealmInvitation(realm, user.Email, time.Now().UTC().Add(realmInvitationDuration), currentUser)
+		if err != nil {
+			if database.IsValidationError(err) {
+				w.WriteHeader(http.StatusUnprocessableEntity)
+				c.renderNewRealmInvitation(ctx, w, r, realm, invitation)
+				return
+			}
+
+			controller.InternalError(w, r, c.h, err)
+			return
+		}
+
+		flash.Alert("Created pending realm %q and invited %s", realm.Name, invitation.Email)
+
+		// The token cannot be recovered, so render the link instead of
+		// redirecting.
+		c.renderShowRealmInvitation(ctx, w, r, invitation, token)
+	})
+}
+
+// HandleRealmInvitationsShow displays the invitation and the settings the
+// invitee has entered so far.
+func (c *Controller) HandleRealmInvitationsShow() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		vars := mux.Vars(r)
+
+		invitation, err := c.db.FindRealmInvitation(vars["id"])
+		if err != nil {
+			if database.IsNotFound(err) {
+				controller.NotFound(w, r, c.h)
+				return
+			}
+
+			controller.InternalError(w, r, c.h, err)
+			return
+		}
+
+		c.renderShowRealmInvitation(ctx, w, r, invitation, "")
+	})
+}
+
+// HandleRealmInvitationsApprove approves a submitted invitation, making the
+// invitee an admin of the realm.
+func (c *Controller) HandleRealmInvitationsApprove() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		vars := mux.Vars(r)
+
+		session := controller.SessionFromContext(ctx)
+		if session == nil {
+			controller.MissingSession(w, r, c.h)
+			return
+		}
+		flash := controller.Flash(session)
+
+		currentUser := controller.UserFromContext(ctx)
+		if currentUser == nil {
+			controller.MissingUser(w, r, c.h)
+			return
+		}
+
+		invitation, err := c.db.FindRealmInvitation(vars["id"])
+		if err != nil {
+			if database.IsNotFound(err) {
+				controller.NotFound(w, r, c.h)
+				return
+			}
+
+			controller.InternalError(w, r, c.h, err)
+			return
+		}
+
+		if err := c.db.ApproveRealmInvitation(invitation, currentUser); err != nil {
+			if errors.Is(err, database.ErrRealmInvitationApproved) || errors.Is(err, database.ErrRealmInvitationNotSubmitted) {
+				flash.Error("Failed to approve invitation: %v", err)
+				w.WriteHeader(http.StatusUnprocessableEntity)
+				c.renderShowRealmInvitation(ctx, w, r, invitation, "")
+				return
+			}
+
+			controller.InternalError(w, r, c.h, err)
+			return
+		}
+
+		realm, err := invitation.Realm(c.db)
+		if err != nil {
+			controller.InternalError(w, r, c.h, err)
+			return
+		}
+		flash.Alert("Approved realm %q. %s is now an administrator.", realm.Name, invitation.Email)
+
+		if realm.UseRealmCertificateKey {
+			// The realm was pending, so it does not have a signing key yet.
+			keyID, err := realm.CreateSigningKeyVersion(ctx, c.db, currentUser)
+			if err != nil {
+				flash.Error("Failed to create signing keys for realm. This can be done from the realm's admin screens.")
+			} else {
+				flash.Alert("Created initial signing key %q", keyID)
+			}
+		}
+
+		http.Redirect(w, r, fmt.Sprintf("/admin/realms/%d/edit", realm.ID), http.StatusSeeOther)
+	})
+}
+
+func (c *Controller) renderNewRealmInvitation(ctx context.Context, w http.ResponseWriter, r *http.Request,
+	realm *database.Realm, invitation *database.RealmInvitation,
+) {
+	smsConfig, err := c.db.SystemSMSConfig()
+	if err != nil && !database.IsNotFound(err) {
+		controller.InternalError(w, r, c.h, err)
+		return
+	}
+
+	m := controller.TemplateMapFromContext(ctx)
+	m.Title("New realm invitation - System Admin")
+	m["realm"] = realm
+	m["invitation"] = invitation
+	m["systemSMSConfig"] = smsConfig
+	c.h.RenderHTML(w, "admin/realm-invitations/new", m)
+}
+
+func (c *Controller) renderShowRealmInvitation(ctx context.Context, w http.ResponseWriter, r *http.Request,
+	invitation *database.RealmInvitation, token string,
+) {
+	realm, err := invitation.Realm(c.db)
+	if err != nil {
+		controller.InternalError(w, r, c.h, err)
+		return
+	}
+
+	smsConfig, err := realm.SMSConfig(c.db)
+	if err != nil && !database.IsNotFound(err) {
+		controller.InternalError(w, r, c.h, err)
+		return
+	}
+
+	m := controller.TemplateMapFromContext(ctx)
+	m.Title("Realm invitation - System Admin")
+	m["invitation"] = invitation
+	m["realm"] = realm
+	m["smsConfig"] = smsConfig
+	m["token"] = token
+	c.h.RenderHTML(w, "admin/realm-invitations/show", m)
+}
@@ -0,0 +1,89 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package admin
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/google/exposure-notifications-verification-server/pkg/controller"
+	"github.com/google/exposure-notifications-verification-server/pkg/database"
+	"github.com/gorilla/mux"
+)
+
+// HandleRealmsNotify posts an operator notification to the realm's
+// notification center, optionally fanning it out by email and SMS.
+func (c *Controller) HandleRealmsNotify() http.Handler {
+	type FormData struct {
+		Title     string `form:"title"`
+		Body      string `form:"body"`
+		Link      string `form:"link"`
+		SendEmail bool   `form:"send_email"`
+		SendSMS   bool   `form:"send_sms"`
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		vars := mux.Vars(r)
+
+		session := controller.SessionFromContext(ctx)
+		if session == nil {
+			controller.MissingSession(w, r, c.h)
+			return
+		}
+		flash := controller.Flash(session)
+
+		currentUser := controller.UserFromContext(ctx)
+		if currentUser == nil {
+			controller.MissingUser(w, r, c.h)
+			return
+		}
+
+		realm, err := c.db.FindRealm(vars["id"])
+		if err != nil {
+			if database.IsNotFound(err) {
+				controller.Unauthorized(w, r, c.h)
+				return
+			}
+
+			controller.InternalError(w, r, c.h, err)
+			return
+		}
+
+		var form FormData
+		if err := controller.BindForm(w, r, &form); err != nil {
+			flash.Error("Failed to process form: %v", err)
+			controller.Back(w, r, c.h)
+			return
+		}
+
+		notification := &database.RealmNotification{
+			RealmID:   realm.ID,
+			Title:     form.Title,
+			Body:      form.Body,
+			Link:      form.Link,
+			SendEmail: form.SendEmail,
+			SendSMS:   form.SendSMS,
+		}
+		if err := c.db.PostOperatorRealmNotification(notification, currentUser); err != nil {
+			flash.Error("Failed to post notification to %q: %s", realm.Name, err)
+			controller.Back(w, r, c.h)
+			return
+		}
+
+		flash.Alert("Posted notification to %q.", realm.Name)
+		http.Redirect(w, r, fmt.Sprintf("/admin/realms/%d/edit", realm.ID), http.StatusSeeOther)
+	})
+}
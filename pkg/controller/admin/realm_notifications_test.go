@@ -0,0 +1,133 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package admin_test
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"testing"
+
+	"github.com/google/exposure-notifications-verification-server/internal/envstest"
+	"github.com/google/exposure-notifications-verification-server/internal/project"
+	"github.com/google/exposure-notifications-verification-server/pkg/controller"
+	"github.com/google/exposure-notifications-verification-server/pkg/controller/admin"
+	"github.com/google/exposure-notifications-verification-server/pkg/database"
+	"github.com/gorilla/mux"
+	"github.com/gorilla/sessions"
+)
+
+func TestHandleRealmsNotify(t *testing.T) {
+	t.Parallel()
+
+	ctx := project.TestContext(t)
+	harness := envstest.NewServerConfig(t, testDatabaseInstance)
+	db := harness.Database
+
+	realm := database.NewRealmWithDefaults("notify")
+	if err := db.SaveRealm(realm, database.SystemTest); err != nil {
+		t.Fatal(err)
+	}
+
+	operator := &database.User{
+		Name:  "Operator",
+		Email: "operator@example.com",
+	}
+
+	c := admin.New(harness.Config, harness.Cacher, db, harness.AuthProvider, harness.RateLimiter, harness.Renderer)
+	handler := harness.WithCommonMiddlewares(c.HandleRealmsNotify())
+
+	t.Run("middleware", func(t *testing.T) {
+		t.Parallel()
+
+		envstest.ExerciseSessionMissing(t, handler)
+		envstest.ExerciseUserMissing(t, handler)
+	})
+
+	t.Run("realm_not_found", func(t *testing.T) {
+		t.Parallel()
+
+		ctx := ctx
+		ctx = controller.WithSession(ctx, &sessions.Session{})
+		ctx = controller.WithUser(ctx, operator)
+
+		w, r := envstest.BuildFormRequest(ctx, t, http.MethodPost, "/", nil)
+		r = mux.SetURLVars(r, map[string]string{"id": "12345"})
+		handler.ServeHTTP(w, r)
+
+		if got, want := w.Code, http.StatusUnauthorized; got != want {
+			t.Errorf("Expected %d to be %d", got, want)
+		}
+	})
+
+	t.Run("validation", func(t *testing.T) {
+		t.Parallel()
+
+		ctx := ctx
+		ctx = controller.WithSession(ctx, &sessions.Session{})
+		ctx = controller.WithUser(ctx, operator)
+
+		w, r := envstest.BuildFormRequest(ctx, t, http.MethodPost, "/", &url.Values{
+			"title": []string{"No body"},
+			"link":  []string{"https://example.com"},
+		})
+		r = mux.SetURLVars(r, map[string]string{"id": strconv.FormatUint(uint64(realm.ID), 10)})
+		handler.ServeHTTP(w, r)
+
+		if got, want := w.Code, http.StatusSeeOther; got != want {
+			t.Errorf("Expected %d to be %d", got, want)
+		}
+		if got, want := w.Header().Get("Location"), "/back"; got != want {
+			t.Errorf("Expected %q to be %q", got, want)
+		}
+	})
+
+	t.Run("posts", func(t *testing.T) {
+		t.Parallel()
+
+		ctx := ctx
+		ctx = controller.WithSession(ctx, &sessions.Session{})
+		ctx = controller.WithUser(ctx, operator)
+
+		w, r := envstest.BuildFormRequest(ctx, t, http.MethodPost, "/", &url.Values{
+			"title":      []string{"Scheduled maintenance"},
+			"body":       []string{"The server will be unavailable for one hour."},
+			"link":       []string{"/realm/settings"},
+			"send_email": []string{"true"},
+		})
+		r = mux.SetURLVars(r, map[string]string{"id": strconv.FormatUint(uint64(realm.ID), 10)})
+		handler.ServeHTTP(w, r)
+
+		if got, want := w.Code, http.StatusSeeOther; got != want {
+			t.Errorf("Expected %d to be %d", got, want)
+		}
+
+		notifications, _, err := realm.ListRealmNotifications(db, nil, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := len(notifications), 1; got != want {
+			t.Fatalf("expected %d notifications, got %d", want, got)
+		}
+
+		notification := notifications[0]
+		if got, want := notification.Kind, database.RealmNotificationKindOperator; got != want {
+			t.Errorf("expected %q to be %q", got, want)
+		}
+		if !notification.SendEmail || notification.SendSMS {
+			t.Errorf("expected email fan-out only, got email=%t sms=%t", notification.SendEmail, notification.SendSMS)
+		}
+	})
+}
@@ -18,6 +18,7 @@ import (
 	"context"
 	"fmt"
 	"net/http"
+	"strings"
 
 	"github.com/google/exposure-notifications-verification-server/pkg/controller"
 	"github.com/google/exposure-notifications-verification-server/pkg/database"
@@ -76,13 +77,14 @@ func (c *Controller) HandleRealmsIndex() http.Handler {
 
 func (c *Controller) HandleRealmsCreate() http.Handler {
 	type FormData struct {
-		Name                    string `form:"name"`
-		RegionCode              string `form:"regionCode"`
-		UseRealmCertificateKey  bool   `form:"useRealmCertificateKey"`
-		CertificateIssuer       string `form:"certificateIssuer"`
-		CertificateAudience     string `form:"certificateAudience"`
-		CanUseSystemSMSConfig   bool   `form:"can_use_system_sms_config"`
-		CanUseSystemEmailConfig bool   `form:"can_use_system_email_config"`
+		Name                        string `form:"name"`
+		RegionCode                  string `form:"regionCode"`
+		UseRealmCertificateKey      bool   `form:"useRealmCertificateKey"`
+		CertificateIssuer           string `form:"certificateIssuer"`
+		CertificateAudience         string `form:"certificateAudience"`
+		CertificateSigningAlgorithm string `form:"certificateSigningAlgorithm"`
+		CanUseSystemSMSConfig       bool   `form:"can_use_system_sms_config"`
+		CanUseSystemEmailConfig     bool   `form:"can_use_system_email_config"`
 	}
 
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -137,8 +139,16 @@ func (c *Controller) HandleRealmsCreate() http.Handler {
 		realm.UseRealmCertificateKey = form.UseRealmCertificateKey
 		realm.CertificateIssuer = form.CertificateIssuer
 		realm.CertificateAudience = form.CertificateAudience
+		realm.CertificateSigningAlgorithm = form.CertificateSigningAlgorithm
 		realm.CanUseSystemSMSConfig = form.CanUseSystemSMSConfig
 		realm.CanUseSystemEmailConfig = form.CanUseSystemEmailConfig
+		if alg := realm.CertificateSigningAlgorithm; realm.UseRealmCertificateKey && alg != "" &&
+			!c.db.SupportsSigningAlgorithm(alg) {
+			realm.AddError("certificateSigningAlgorithm", "is not supported by the configured key manager")
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			c.renderNewRealm(ctx, w, realm, smsConfig, emailConfig)
+			return
+		}
 		if err := c.db.SaveRealm(realm, currentUser); err != nil {
 			if database.IsValidationError(err) {
 				w.WriteHeader(http.StatusUnprocessableEntity)
@@ -184,17 +194,26 @@ func (c *Controller) renderNewRealm(ctx context.Context, w http.ResponseWriter,
 	m["systemSMSConfig"] = smsConfig
 	m["systemEmailConfig"] = emailConfig
 	m["supportsPerRealmSigning"] = c.db.SupportsPerRealmSigning()
+	m["signingAlgorithms"] = c.db.SupportedSigningAlgorithms()
 	c.h.RenderHTML(w, "admin/realms/new", m)
 }
 
 func (c *Controller) HandleRealmsUpdate() http.Handler {
 	type FormData struct {
-		CanUseSystemSMSConfig         bool `form:"can_use_system_sms_config"`
-		CanUseSystemEmailConfig       bool `form:"can_use_system_email_config"`
-		ShortCodeMaxMinutes           uint `form:"short_code_max_minutes"`
-		ENXCodeExpirationConfigurable bool `form:"enx_code_expiration_configurable"`
-		AllowGeneratedSMS             bool `form:"allow_generated_sms"`
-		MaintenanceMode               bool `form:"maintenance_mode"`
+		CanUseSystemSMSConfig         bool   `form:"can_use_system_sms_config"`
+		CanUseSystemEmailConfig       bool   `form:"can_use_system_email_config"`
+		ShortCodeMaxMinutes           uint   `form:"short_code_max_minutes"`
+		ENXCodeExpirationConfigurable bool   `form:"enx_code_expiration_configurable"`
+		AllowGeneratedSMS             bool   `form:"allow_generated_sms"`
+		MaintenanceMode               bool   `form:"maintenance_mode"`
+		KeyServerID                   uint   `form:"key_server_id"`
+		CustomDomain                  string `form:"custom_domain"`
+		AuditRetentionDays            uint   `form:"audit_retention_days"`
+		VerificationCodeRetentionDays uint   `form:"verification_code_retention_days"`
+		TokenRetentionDays            uint   `form:"token_retention_days"`
+		StatsRetentionDays            uint   `form:"stats_retention_days"`
+		UserReportRetentionDays       uint   `form:"user_report_retention_days"`
+		AllowedCertificateClaims      string `form:"allowed_certificate_claims"`
 	}
 
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -226,6 +245,12 @@ func (c *Controller) HandleRealmsUpdate() http.Handler {
 			return
 		}
 
+		impersonation, err := currentUser.FindActiveRealmImpersonation(c.db, realm.ID)
+		if err != nil && !database.IsNotFound(err) {
+			controller.InternalError(w, r, c.h, err)
+			return
+		}
+
 		smsConfig, err := c.db.SystemSMSConfig()
 		if err != nil && !database.IsNotFound(err) {
 			controller.InternalError(w, r, c.h, err)
@@ -244,6 +269,18 @@ func (c *Controller) HandleRealmsUpdate() http.Handler {
 			return
 		}
 
+		exports, err := realm.ListRealmExports(c.db)
+		if err != nil {
+			controller.InternalError(w, r, c.h, err)
+			return
+		}
+
+		keyServers, err := c.db.ListKeyServers()
+		if err != nil {
+			controller.InternalError(w, r, c.h, err)
+			return
+		}
+
 		var quotaLimit, quotaRemaining uint64
 		if realm.AbusePreventionEnabled {
 			key, err := realm.QuotaKey(c.config.RateLimit.HMACKey)
@@ -273,7 +310,7 @@ func (c *Controller) HandleRealmsUpdate() http.Handler {
 
 		// Requested form, stop processing.
 		if r.Method == http.MethodGet {
-			c.renderEditRealm(ctx, w, realm, membership, smsConfig, emailConfig, chaffEvents, quotaLimit, quotaRemaining, realmTranslations)
+			c.renderEditRealm(ctx, w, realm, membership, impersonation, smsConfig, emailConfig, chaffEvents, exports, keyServers, quotaLimit, quotaRemaining, realmTranslations)
 			return
 		}
 
@@ -281,7 +318,7 @@ func (c *Controller) HandleRealmsUpdate() http.Handler {
 		if err := controller.BindForm(w, r, &form); err != nil {
 			realm.AddError("", err.Error())
 			w.WriteHeader(http.StatusUnprocessableEntity)
-			c.renderEditRealm(ctx, w, realm, membership, smsConfig, emailConfig, chaffEvents, quotaLimit, quotaRemaining, realmTranslations)
+			c.renderEditRealm(ctx, w, realm, membership, impersonation, smsConfig, emailConfig, chaffEvents, exports, keyServers, quotaLimit, quotaRemaining, realmTranslations)
 			return
 		}
 
@@ -291,10 +328,33 @@ func (c *Controller) HandleRealmsUpdate() http.Handler {
 		realm.ENXCodeExpirationConfigurable = form.ENXCodeExpirationConfigurable
 		realm.AllowGeneratedSMS = form.AllowGeneratedSMS
 		realm.MaintenanceMode = form.MaintenanceMode
+		realm.CustomDomain = form.CustomDomain
+		realm.AuditRetentionDays = form.AuditRetentionDays
+		realm.VerificationCodeRetentionDays = form.VerificationCodeRetentionDays
+		realm.TokenRetentionDays = form.TokenRetentionDays
+		realm.StatsRetentionDays = form.StatsRetentionDays
+		realm.UserReportRetentionDays = form.UserReportRetentionDays
+
+		// Static claims are only valid while their names are approved.
+		realm.AllowedCertificateClaims = strings.Fields(strings.ReplaceAll(form.AllowedCertificateClaims, ",", " "))
+		realm.PruneCertificateClaims()
+
+		// The key server is only selectable when key servers exist.
+		if len(keyServers) > 0 {
+			realm.KeyServerID = nil
+			for _, ks := range keyServers {
+				if ks.ID == form.KeyServerID {
+					id := ks.ID
+					realm.KeyServerID = &id
+					break
+				}
+			}
+		}
+
 		if err := c.db.SaveRealm(realm, currentUser); err != nil {
 			if database.IsValidationError(err) {
 				w.WriteHeader(http.StatusUnprocessableEntity)
-				c.renderEditRealm(ctx, w, realm, membership, smsConfig, emailConfig, chaffEvents, quotaLimit, quotaRemaining, realmTranslations)
+				c.renderEditRealm(ctx, w, realm, membership, impersonation, smsConfig, emailConfig, chaffEvents, exports, keyServers, quotaLimit, quotaRemaining, realmTranslations)
 				return
 			}
 
@@ -308,8 +368,10 @@ func (c *Controller) HandleRealmsUpdate() http.Handler {
 }
 
 func (c *Controller) renderEditRealm(ctx context.Context, w http.ResponseWriter,
-	realm *database.Realm, membership *database.Membership, smsConfig *database.SMSConfig, emailConfig *database.EmailConfig,
+	realm *database.Realm, membership *database.Membership, impersonation *database.RealmImpersonation, smsConfig *database.SMSConfig, emailConfig *database.EmailConfig,
 	chaffEvents []*database.RealmChaffEvent,
+	exports []*database.RealmExport,
+	keyServers []*database.KeyServer,
 	quotaLimit, quotaRemaining uint64,
 	translations []*database.DynamicTranslation,
 ) {
@@ -317,9 +379,21 @@ func (c *Controller) renderEditRealm(ctx context.Context, w http.ResponseWriter,
 	m.Title("Realm: %s - System Admin", realm.Name)
 	m["realm"] = realm
 	m["membership"] = membership
+	m["impersonation"] = impersonation
+	m["impersonationDefault"] = &database.Membership{Permissions: database.RealmImpersonationReadOnly}
+	m["impersonationMinutes"] = int(c.config.ImpersonationDuration.Minutes())
+	m["impersonationMaxMinutes"] = int(database.RealmImpersonationMaxDuration.Minutes())
+	m["permissions"] = rbac.NamePermissionMap
 	m["systemSMSConfig"] = smsConfig
 	m["systemEmailConfig"] = emailConfig
 	m["chaffEvents"] = chaffEvents
+	m["realmExports"] = exports
+	var realmKeyServerID uint
+	if realm.KeyServerID != nil {
+		realmKeyServerID = *realm.KeyServerID
+	}
+	m["realmKeyServerID"] = realmKeyServerID
+	m["keyServers"] = keyServers
 	m["supportsPerRealmSigning"] = c.db.SupportsPerRealmSigning()
 	m["quotaLimit"] = quotaLimit
 	m["quotaRemaining"] = quotaRemaining
@@ -0,0 +1,208 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package admin
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/google/exposure-notifications-server/pkg/logging"
+	"github.com/google/exposure-notifications-verification-server/pkg/controller"
+	"github.com/google/exposure-notifications-verification-server/pkg/database"
+	"github.com/google/exposure-notifications-verification-server/pkg/rbac"
+	"github.com/gorilla/mux"
+)
+
+// impersonationErrors are the errors from the database that are shown to the
+// system admin instead of rendering an internal error.
+var impersonationErrors = []error{
+	database.ErrRealmImpersonationOperator,
+	database.ErrRealmImpersonationMember,
+	database.ErrRealmImpersonationDuration,
+	database.ErrRealmImpersonationEnded,
+}
+
+// HandleRealmsImpersonate starts an impersonation of the realm by the current
+// system admin and switches the session to the realm.
+func (c *Controller) HandleRealmsImpersonate() http.Handler {
+	type FormData struct {
+		Permissions []rbac.Permission `form:"permissions"`
+		Minutes     uint              `form:"minutes"`
+		Reason      string            `form:"reason"`
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		vars := mux.Vars(r)
+
+		logger := logging.FromContext(ctx).Named("admin.HandleRealmsImpersonate")
+
+		session := controller.SessionFromContext(ctx)
+		if session == nil {
+			controller.MissingSession(w, r, c.h)
+			return
+		}
+		flash := controller.Flash(session)
+
+		currentUser := controller.UserFromContext(ctx)
+		if currentUser == nil {
+			controller.MissingUser(w, r, c.h)
+			return
+		}
+
+		realm, err := c.db.FindRealm(vars["id"])
+		if err != nil {
+			if database.IsNotFound(err) {
+				controller.Unauthorized(w, r, c.h)
+				return
+			}
+
+			controller.InternalError(w, r, c.h, err)
+			return
+		}
+		redirectTo := fmt.Sprintf("/admin/realms/%d/edit", realm.ID)
+
+		var form FormData
+		if err := controller.BindForm(w, r, &form); err != nil {
+			flash.Error("Failed to impersonate realm: %v", err)
+			http.Redirect(w, r, redirectTo, http.StatusSeeOther)
+			return
+		}
+
+		// System admins can grant any realm permission, but only known
+		// permissions.
+		permissions, err := rbac.CompileAndAuthorize(rbac.LegacyRealmAdmin, form.Permissions)
+		if err != nil {
+			flash.Error("Failed to impersonate realm: %v", err)
+			http.Redirect(w, r, redirectTo, http.StatusSeeOther)
+			return
+		}
+
+		duration := c.config.ImpersonationDuration
+		if form.Minutes > 0 {
+			duration = time.Duration(form.Minutes) * time.Minute
+		}
+
+		impersonation, err := c.db.StartRealmImpersonation(realm, permissions, duration, form.Reason, currentUser)
+		if err != nil {
+			if database.IsValidationError(err) || isImpersonationError(err) {
+				flash.Error("Failed to impersonate realm: %v", err)
+				http.Redirect(w, r, redirectTo, http.StatusSeeOther)
+				return
+			}
+
+			controller.InternalError(w, r, c.h, err)
+			return
+		}
+
+		logger.Warnw("realm impersonation started",
+			"realm_id", realm.ID,
+			"user", currentUser.Email,
+			"impersonation_id", impersonation.ID,
+			"permissions", rbac.PermissionNames(impersonation.Permissions),
+			"expires_at", impersonation.ExpiresAt)
+
+		controller.StoreSessionRealm(session, realm)
+		controller.StoreSessionMFAPrompted(session, false)
+
+		flash.Alert("Impersonating %q until %v. Every page you view is recorded in the realm's audit log.",
+			realm.Name, impersonation.ExpiresAt.Format("2006-01-02 15:04 MST"))
+		http.Redirect(w, r, "/login/post-authenticate", http.StatusSeeOther)
+	})
+}
+
+// HandleRealmsImpersonateEnd ends the current system admin's impersonation of
+// the realm.
+func (c *Controller) HandleRealmsImpersonateEnd() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		vars := mux.Vars(r)
+
+		logger := logging.FromContext(ctx).Named("admin.HandleRealmsImpersonateEnd")
+
+		session := controller.SessionFromContext(ctx)
+		if session == nil {
+			controller.MissingSession(w, r, c.h)
+			return
+		}
+		flash := controller.Flash(session)
+
+		currentUser := controller.UserFromContext(ctx)
+		if currentUser == nil {
+			controller.MissingUser(w, r, c.h)
+			return
+		}
+
+		realm, err := c.db.FindRealm(vars["id"])
+		if err != nil {
+			if database.IsNotFound(err) {
+				controller.Unauthorized(w, r, c.h)
+				return
+			}
+
+			controller.InternalError(w, r, c.h, err)
+			return
+		}
+		redirectTo := fmt.Sprintf("/admin/realms/%d/edit", realm.ID)
+
+		impersonation, err := currentUser.FindActiveRealmImpersonation(c.db, realm.ID)
+		if err != nil {
+			if database.IsNotFound(err) {
+				flash.Error("Failed to end realm impersonation: %v", database.ErrRealmImpersonationEnded)
+				http.Redirect(w, r, redirectTo, http.StatusSeeOther)
+				return
+			}
+
+			controller.InternalError(w, r, c.h, err)
+			return
+		}
+
+		if err := c.db.EndRealmImpersonation(impersonation, currentUser); err != nil {
+			if isImpersonationError(err) {
+				flash.Error("Failed to end realm impersonation: %v", err)
+				http.Redirect(w, r, redirectTo, http.StatusSeeOther)
+				return
+			}
+
+			controller.InternalError(w, r, c.h, err)
+			return
+		}
+
+		logger.Warnw("realm impersonation ended",
+			"realm_id", realm.ID,
+			"user", currentUser.Email,
+			"impersonation_id", impersonation.ID)
+
+		if controller.RealmIDFromSession(session) == realm.ID {
+			controller.ClearSessionRealm(session)
+		}
+
+		flash.Alert("Stopped impersonating %q.", realm.Name)
+		http.Redirect(w, r, redirectTo, http.StatusSeeOther)
+	})
+}
+
+// isImpersonationError returns true if the error is one of the expected realm
+// impersonation errors.
+func isImpersonationError(err error) bool {
+	for _, target := range impersonationErrors {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
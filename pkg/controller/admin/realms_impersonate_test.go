@@ -0,0 +1,159 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package admin_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/google/exposure-notifications-verification-server/internal/envstest"
+	"github.com/google/exposure-notifications-verification-server/internal/project"
+	"github.com/google/exposure-notifications-verification-server/pkg/controller"
+	"github.com/google/exposure-notifications-verification-server/pkg/controller/admin"
+	"github.com/google/exposure-notifications-verification-server/pkg/database"
+	"github.com/google/exposure-notifications-verification-server/pkg/rbac"
+	"github.com/gorilla/mux"
+	"github.com/gorilla/sessions"
+)
+
+func TestHandleRealmsImpersonate(t *testing.T) {
+	t.Parallel()
+
+	ctx := project.TestContext(t)
+	harness := envstest.NewServerConfig(t, testDatabaseInstance)
+	db := harness.Database
+
+	c := admin.New(harness.Config, harness.Cacher, db, harness.AuthProvider, harness.RateLimiter, harness.Renderer)
+	startHandler := harness.WithCommonMiddlewares(c.HandleRealmsImpersonate())
+	endHandler := harness.WithCommonMiddlewares(c.HandleRealmsImpersonateEnd())
+
+	t.Run("middleware", func(t *testing.T) {
+		t.Parallel()
+
+		for _, handler := range []http.Handler{startHandler, endHandler} {
+			envstest.ExerciseSessionMissing(t, handler)
+			envstest.ExerciseUserMissing(t, handler)
+		}
+	})
+
+	realm, err := db.FindRealm(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	vars := map[string]string{"id": fmt.Sprintf("%d", realm.ID)}
+	editPath := fmt.Sprintf("/admin/realms/%d/edit", realm.ID)
+
+	createAdmin := func(tb testing.TB) *database.User {
+		tb.Helper()
+
+		suffix, err := project.RandomHexString(6)
+		if err != nil {
+			tb.Fatal(err)
+		}
+
+		user := &database.User{
+			Name:        "Admin",
+			Email:       fmt.Sprintf("admin-%s@example.com", suffix),
+			SystemAdmin: true,
+		}
+		if err := db.SaveUser(user, database.SystemTest); err != nil {
+			tb.Fatal(err)
+		}
+		return user
+	}
+
+	t.Run("no_reason", func(t *testing.T) {
+		t.Parallel()
+
+		user := createAdmin(t)
+
+		session := &sessions.Session{}
+		ctx := controller.WithSession(ctx, session)
+		ctx = controller.WithUser(ctx, user)
+
+		w, r := envstest.BuildFormRequest(ctx, t, http.MethodPost, "/", &url.Values{
+			"permissions": []string{fmt.Sprintf("%d", rbac.SettingsRead)},
+		})
+		r = mux.SetURLVars(r, vars)
+		startHandler.ServeHTTP(w, r)
+
+		if got, want := w.Code, http.StatusSeeOther; got != want {
+			t.Fatalf("expected %d to be %d", got, want)
+		}
+		if got, want := w.Header().Get("Location"), editPath; got != want {
+			t.Errorf("expected %q to be %q", got, want)
+		}
+		if _, err := user.FindActiveRealmImpersonation(db, realm.ID); !database.IsNotFound(err) {
+			t.Errorf("expected no impersonation, got %v", err)
+		}
+	})
+
+	t.Run("lifecycle", func(t *testing.T) {
+		t.Parallel()
+
+		user := createAdmin(t)
+
+		// Start.
+		session := &sessions.Session{}
+		ctx := controller.WithSession(ctx, session)
+		ctx = controller.WithUser(ctx, user)
+
+		w, r := envstest.BuildFormRequest(ctx, t, http.MethodPost, "/", &url.Values{
+			"reason":      []string{"support ticket 123"},
+			"minutes":     []string{"15"},
+			"permissions": []string{fmt.Sprintf("%d", rbac.SettingsRead), fmt.Sprintf("%d", rbac.StatsRead)},
+		})
+		r = mux.SetURLVars(r, vars)
+		startHandler.ServeHTTP(w, r)
+
+		if got, want := w.Code, http.StatusSeeOther; got != want {
+			t.Fatalf("expected %d to be %d: %s", got, want, controller.Flash(session).Errors())
+		}
+		if got, want := w.Header().Get("Location"), "/login/post-authenticate"; got != want {
+			t.Errorf("expected %q to be %q", got, want)
+		}
+		if got, want := controller.RealmIDFromSession(session), realm.ID; got != want {
+			t.Errorf("expected session realm %d to be %d", got, want)
+		}
+
+		impersonation, err := user.FindActiveRealmImpersonation(db, realm.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := impersonation.Permissions, rbac.SettingsRead|rbac.StatsRead; got != want {
+			t.Errorf("expected permissions %v to be %v", got, want)
+		}
+
+		// End.
+		w, r = envstest.BuildFormRequest(ctx, t, http.MethodDelete, "/", nil)
+		r = mux.SetURLVars(r, vars)
+		endHandler.ServeHTTP(w, r)
+
+		if got, want := w.Code, http.StatusSeeOther; got != want {
+			t.Fatalf("expected %d to be %d: %s", got, want, controller.Flash(session).Errors())
+		}
+		if got, want := w.Header().Get("Location"), editPath; got != want {
+			t.Errorf("expected %q to be %q", got, want)
+		}
+		if got := controller.RealmIDFromSession(session); got != 0 {
+			t.Errorf("expected session realm to be cleared, got %d", got)
+		}
+		if _, err := user.FindActiveRealmImpersonation(db, realm.ID); !database.IsNotFound(err) {
+			t.Errorf("expected impersonation to be ended, got %v", err)
+		}
+	})
+}
@@ -245,6 +245,8 @@ func TestHandleRealmsUpdate(t *testing.T) {
 			"can_use_system_sms_config":   []string{"1"},
 			"can_use_system_email_config": []string{"1"},
 			"short_code_max_minutes":      []string{"60"},
+			"custom_domain":               []string{"Verify.Example.com"},
+			"allowed_certificate_claims":  []string{"region\r\nlab, region"},
 		})
 		r = mux.SetURLVars(r, map[string]string{"id": "1"})
 		handler.ServeHTTP(w, r)
@@ -255,6 +257,35 @@ func TestHandleRealmsUpdate(t *testing.T) {
 		if got, want := w.Header().Get("Location"), "/admin/realms/1/edit"; got != want {
 			t.Errorf("Expected %q to be %q", got, want)
 		}
+
+		realm, err := harness.Database.FindRealm(1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := realm.CustomDomain, "verify.example.com"; got != want {
+			t.Errorf("Expected %q to be %q", got, want)
+		}
+		if got, want := strings.Join(realm.AllowedCertificateClaims, ","), "lab,region"; got != want {
+			t.Errorf("Expected %q to be %q", got, want)
+		}
+	})
+
+	t.Run("invalid_custom_domain", func(t *testing.T) {
+		t.Parallel()
+
+		ctx := ctx
+		ctx = controller.WithSession(ctx, &sessions.Session{})
+		ctx = controller.WithUser(ctx, &database.User{})
+
+		w, r := envstest.BuildFormRequest(ctx, t, http.MethodPost, "/", &url.Values{
+			"custom_domain": []string{"https://verify.example.com/"},
+		})
+		r = mux.SetURLVars(r, map[string]string{"id": "1"})
+		handler.ServeHTTP(w, r)
+
+		if got, want := w.Code, http.StatusUnprocessableEntity; got != want {
+			t.Errorf("Expected %d to be %d", got, want)
+		}
 	})
 }
 
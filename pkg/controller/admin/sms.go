@@ -37,6 +37,10 @@ func (c *Controller) HandleSMSUpdate() http.Handler {
 		TwilioAuthToken  string `form:"twilio_auth_token"`
 
 		TwilioFromNumbers []*FormDataFromNumber `form:"twilio_from_numbers"`
+
+		// Sandbox records messages instead of sending them. It is only honored
+		// when fake SMS is enabled.
+		Sandbox bool `form:"sandbox"`
 	}
 
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -81,6 +85,9 @@ func (c *Controller) HandleSMSUpdate() http.Handler {
 
 		// Update Twilio config
 		smsConfig.ProviderType = sms.ProviderTypeTwilio
+		if c.config.Database.FakeSMS && form.Sandbox {
+			smsConfig.ProviderType = sms.ProviderTypeNoopInspect
+		}
 		smsConfig.TwilioAccountSid = form.TwilioAccountSid
 		if form.TwilioAuthToken != project.PasswordSentinel {
 			smsConfig.TwilioAuthToken = form.TwilioAuthToken
@@ -113,6 +120,8 @@ func (c *Controller) HandleSMSUpdate() http.Handler {
 			return
 		}
 
+		c.recordConfigChange(ctx, "system SMS config", "updated system SMS config")
+
 		flash.Alert("Successfully updated system SMS config")
 		http.Redirect(w, r, "/admin/sms", http.StatusSeeOther)
 	})
@@ -125,5 +134,6 @@ func (c *Controller) renderShowSMS(ctx context.Context, w http.ResponseWriter,
 	m.Title("SMS - System Admin")
 	m["smsConfig"] = smsConfig
 	m["smsFromNumbers"] = smsFromNumbers
+	m["fakeSMS"] = c.config.Database.FakeSMS
 	c.h.RenderHTML(w, "admin/sms/show", m)
 }
@@ -0,0 +1,79 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package admin
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/google/exposure-notifications-verification-server/internal/project"
+	"github.com/google/exposure-notifications-verification-server/pkg/controller"
+	"github.com/google/exposure-notifications-verification-server/pkg/database"
+	"github.com/google/exposure-notifications-verification-server/pkg/pagination"
+)
+
+// QueryToNumberSearch is the query key to filter sandbox messages by phone
+// number.
+const QueryToNumberSearch = "to"
+
+// HandleSMSSandboxIndex lists the messages recorded by the NOOP_INSPECT SMS
+// provider. It is only available when fake SMS is enabled.
+func (c *Controller) HandleSMSSandboxIndex() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		if !c.config.Database.FakeSMS {
+			controller.NotFound(w, r, c.h)
+			return
+		}
+
+		session := controller.SessionFromContext(ctx)
+		if session == nil {
+			controller.MissingSession(w, r, c.h)
+			return
+		}
+
+		pageParams, err := pagination.FromRequest(r)
+		if err != nil {
+			controller.BadRequest(w, r, c.h)
+			return
+		}
+
+		var scopes []database.Scope
+		to := project.TrimSpace(r.FormValue(QueryToNumberSearch))
+		if to != "" {
+			scopes = append(scopes, database.WithSandboxSMSToNumber(to))
+		}
+
+		messages, paginator, err := c.db.ListSandboxSMS(pageParams, scopes...)
+		if err != nil {
+			controller.InternalError(w, r, c.h, err)
+			return
+		}
+
+		c.renderSMSSandbox(ctx, w, messages, paginator, to)
+	})
+}
+
+func (c *Controller) renderSMSSandbox(ctx context.Context, w http.ResponseWriter,
+	messages []*database.SandboxSMS, paginator *pagination.Paginator, to string,
+) {
+	m := controller.TemplateMapFromContext(ctx)
+	m.Title("SMS sandbox - System Admin")
+	m["messages"] = messages
+	m["paginator"] = paginator
+	m[QueryToNumberSearch] = to
+	c.h.RenderHTML(w, "admin/sms/sandbox", m)
+}
@@ -0,0 +1,133 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package admin
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/google/exposure-notifications-server/pkg/logging"
+	"github.com/google/exposure-notifications-verification-server/internal/project"
+	"github.com/google/exposure-notifications-verification-server/pkg/cache"
+	"github.com/google/exposure-notifications-verification-server/pkg/controller"
+	"github.com/google/exposure-notifications-verification-server/pkg/database"
+)
+
+// statsCorrectionsLimit is the number of recent corrections to display.
+const statsCorrectionsLimit = 100
+
+// statsCorrectionFormData is the form for applying a stats correction.
+type statsCorrectionFormData struct {
+	RealmID        uint   `form:"realm_id"`
+	Date           string `form:"date"`
+	Field          string `form:"field"`
+	CorrectedValue uint   `form:"corrected_value"`
+	Reason         string `form:"reason"`
+}
+
+// HandleStatsCorrectionsIndex lists the recent stats corrections and renders
+// the form for applying a new one.
+func (c *Controller) HandleStatsCorrectionsIndex() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		c.renderStatsCorrections(ctx, w, r, new(database.RealmStatCorrection))
+	})
+}
+
+// HandleStatsCorrectionsCreate applies a correction to a realm's historical
+// statistics. The original value is kept alongside the corrected value and the
+// change is audited.
+func (c *Controller) HandleStatsCorrectionsCreate() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		session := controller.SessionFromContext(ctx)
+		if session == nil {
+			controller.MissingSession(w, r, c.h)
+			return
+		}
+		flash := controller.Flash(session)
+
+		currentUser := controller.UserFromContext(ctx)
+		if currentUser == nil {
+			controller.MissingUser(w, r, c.h)
+			return
+		}
+
+		correction := new(database.RealmStatCorrection)
+
+		var form statsCorrectionFormData
+		if err := controller.BindForm(w, r, &form); err != nil {
+			correction.AddError("", err.Error())
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			c.renderStatsCorrections(ctx, w, r, correction)
+			return
+		}
+
+		correction.RealmID = form.RealmID
+		correction.Field = form.Field
+		correction.CorrectedValue = form.CorrectedValue
+		correction.Reason = form.Reason
+		if v := project.TrimSpace(form.Date); v != "" {
+			date, err := time.Parse(project.RFC3339Date, v)
+			if err != nil {
+				correction.AddError("date", "is not a valid date")
+			}
+			correction.Date = date
+		}
+
+		if err := c.db.ApplyRealmStatCorrection(correction, currentUser); err != nil {
+			if database.IsValidationError(err) {
+				w.WriteHeader(http.StatusUnprocessableEntity)
+				c.renderStatsCorrections(ctx, w, r, correction)
+				return
+			}
+
+			controller.InternalError(w, r, c.h, err)
+			return
+		}
+
+		// Purge the cached realm stats so the correction is visible immediately.
+		// The correction is already committed, so a failure here only delays it.
+		if err := c.cacher.Delete(ctx, &cache.Key{
+			Namespace: "stats:realm",
+			Key:       strconv.FormatUint(uint64(correction.RealmID), 10),
+		}); err != nil {
+			logger := logging.FromContext(ctx).Named("admin.HandleStatsCorrectionsCreate")
+			logger.Errorw("failed to purge realm stats cache", "realm_id", correction.RealmID, "error", err)
+		}
+
+		flash.Alert("Corrected %s for realm %d on %s",
+			correction.Field, correction.RealmID, correction.Date.Format(project.RFC3339Date))
+		http.Redirect(w, r, "/admin/stats-corrections", http.StatusSeeOther)
+	})
+}
+
+func (c *Controller) renderStatsCorrections(ctx context.Context, w http.ResponseWriter, r *http.Request, correction *database.RealmStatCorrection) {
+	corrections, err := c.db.ListRealmStatCorrections(statsCorrectionsLimit)
+	if err != nil {
+		controller.InternalError(w, r, c.h, err)
+		return
+	}
+
+	m := controller.TemplateMapFromContext(ctx)
+	m.Title("Stats corrections - System Admin")
+	m["correction"] = correction
+	m["corrections"] = corrections
+	m["fields"] = database.RealmStatCorrectionFields
+	c.h.RenderHTML(w, "admin/stats-corrections/index", m)
+}
@@ -0,0 +1,161 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package admin_test
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/google/exposure-notifications-server/pkg/timeutils"
+	"github.com/google/exposure-notifications-verification-server/internal/envstest"
+	"github.com/google/exposure-notifications-verification-server/internal/project"
+	"github.com/google/exposure-notifications-verification-server/pkg/controller"
+	"github.com/google/exposure-notifications-verification-server/pkg/controller/admin"
+	"github.com/google/exposure-notifications-verification-server/pkg/database"
+
+	"github.com/gorilla/sessions"
+)
+
+func TestHandleStatsCorrectionsIndex(t *testing.T) {
+	t.Parallel()
+
+	ctx := project.TestContext(t)
+	harness := envstest.NewServerConfig(t, testDatabaseInstance)
+
+	c := admin.New(harness.Config, harness.Cacher, harness.Database, harness.AuthProvider, harness.RateLimiter, harness.Renderer)
+	handler := harness.WithCommonMiddlewares(c.HandleStatsCorrectionsIndex())
+
+	t.Run("internal_error", func(t *testing.T) {
+		t.Parallel()
+
+		c := admin.New(harness.Config, harness.Cacher, harness.BadDatabase, harness.AuthProvider, harness.RateLimiter, harness.Renderer)
+		handler := harness.WithCommonMiddlewares(c.HandleStatsCorrectionsIndex())
+
+		ctx := ctx
+		ctx = controller.WithSession(ctx, &sessions.Session{})
+		ctx = controller.WithUser(ctx, &database.User{})
+
+		w, r := envstest.BuildFormRequest(ctx, t, http.MethodGet, "/", nil)
+		handler.ServeHTTP(w, r)
+
+		if got, want := w.Code, http.StatusInternalServerError; got != want {
+			t.Errorf("Expected %d to be %d", got, want)
+		}
+	})
+
+	t.Run("renders", func(t *testing.T) {
+		t.Parallel()
+
+		ctx := ctx
+		ctx = controller.WithSession(ctx, &sessions.Session{})
+		ctx = controller.WithUser(ctx, &database.User{})
+
+		w, r := envstest.BuildFormRequest(ctx, t, http.MethodGet, "/", nil)
+		handler.ServeHTTP(w, r)
+
+		if got, want := w.Code, http.StatusOK; got != want {
+			t.Errorf("Expected %d to be %d", got, want)
+		}
+	})
+}
+
+func TestHandleStatsCorrectionsCreate(t *testing.T) {
+	t.Parallel()
+
+	ctx := project.TestContext(t)
+	harness := envstest.NewServerConfig(t, testDatabaseInstance)
+
+	date := timeutils.UTCMidnight(time.Now()).Add(-48 * time.Hour)
+	if err := harness.Database.RawDB().Create(&database.RealmStat{
+		Date:         date,
+		RealmID:      1,
+		CodesIssued:  10,
+		CodesClaimed: 20,
+	}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	c := admin.New(harness.Config, harness.Cacher, harness.Database, harness.AuthProvider, harness.RateLimiter, harness.Renderer)
+	handler := harness.WithCommonMiddlewares(c.HandleStatsCorrectionsCreate())
+
+	t.Run("middleware", func(t *testing.T) {
+		t.Parallel()
+
+		envstest.ExerciseSessionMissing(t, handler)
+		envstest.ExerciseUserMissing(t, handler)
+	})
+
+	t.Run("validation", func(t *testing.T) {
+		t.Parallel()
+
+		ctx := ctx
+		ctx = controller.WithSession(ctx, &sessions.Session{})
+		ctx = controller.WithUser(ctx, &database.User{})
+
+		w, r := envstest.BuildFormRequest(ctx, t, http.MethodPost, "/", &url.Values{
+			"realm_id": []string{"1"},
+			"date":     []string{date.Format(project.RFC3339Date)},
+			"field":    []string{"not_a_field"},
+			"reason":   []string{"incident"},
+		})
+		handler.ServeHTTP(w, r)
+
+		if got, want := w.Code, http.StatusUnprocessableEntity; got != want {
+			t.Errorf("Expected %d to be %d", got, want)
+		}
+	})
+
+	t.Run("corrects", func(t *testing.T) {
+		t.Parallel()
+
+		user, err := harness.Database.FindUser(1)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		ctx := ctx
+		ctx = controller.WithSession(ctx, &sessions.Session{})
+		ctx = controller.WithUser(ctx, user)
+
+		w, r := envstest.BuildFormRequest(ctx, t, http.MethodPost, "/", &url.Values{
+			"realm_id":        []string{"1"},
+			"date":            []string{date.Format(project.RFC3339Date)},
+			"field":           []string{"codes_claimed"},
+			"corrected_value": []string{"10"},
+			"reason":          []string{"claims were double-counted"},
+		})
+		handler.ServeHTTP(w, r)
+
+		if got, want := w.Code, http.StatusSeeOther; got != want {
+			t.Fatalf("Expected %d to be %d", got, want)
+		}
+		if got, want := w.Header().Get("Location"), "/admin/stats-corrections"; got != want {
+			t.Errorf("Expected %q to be %q", got, want)
+		}
+
+		corrections, err := harness.Database.ListRealmStatCorrections(10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := len(corrections), 1; got != want {
+			t.Fatalf("Expected %d to be %d", got, want)
+		}
+		if got, want := corrections[0].OriginalValue, uint(20); got != want {
+			t.Errorf("Expected %d to be %d", got, want)
+		}
+	})
+}
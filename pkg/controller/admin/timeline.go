@@ -0,0 +1,134 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package admin
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/google/exposure-notifications-server/pkg/logging"
+	"github.com/google/exposure-notifications-verification-server/pkg/controller"
+	"github.com/google/exposure-notifications-verification-server/pkg/database"
+	"github.com/google/exposure-notifications-verification-server/pkg/pagination"
+)
+
+// QueryKindSearch is the query key to filter the timeline by event kind.
+const QueryKindSearch = "kind"
+
+// timelineResponse is the JSON response for the system event timeline.
+type timelineResponse struct {
+	Events   []*database.SystemEvent `json:"events"`
+	NextPage uint64                  `json:"nextPage,omitempty"`
+}
+
+// HandleTimelineIndex shows the system event timeline, newest first.
+func (c *Controller) HandleTimelineIndex() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		session := controller.SessionFromContext(ctx)
+		if session == nil {
+			controller.MissingSession(w, r, c.h)
+			return
+		}
+
+		pageParams, err := pagination.FromRequest(r)
+		if err != nil {
+			controller.BadRequest(w, r, c.h)
+			return
+		}
+
+		from := r.FormValue(QueryFromSearch)
+		to := r.FormValue(QueryToSearch)
+		kind := database.SystemEventKind(r.FormValue(QueryKindSearch))
+
+		events, paginator, err := c.db.ListSystemEvents(pageParams, timelineScopes(from, to, kind)...)
+		if err != nil {
+			controller.InternalError(w, r, c.h, err)
+			return
+		}
+
+		m := controller.TemplateMapFromContext(ctx)
+		m.Title("Timeline - System Admin")
+		m["events"] = events
+		m["paginator"] = paginator
+		m["kinds"] = database.SystemEventKinds
+		m[QueryFromSearch] = from
+		m[QueryToSearch] = to
+		m[QueryKindSearch] = kind
+		c.h.RenderHTML(w, "admin/timeline/index", m)
+	})
+}
+
+// HandleTimelineJSON returns the same events as HandleTimelineIndex as JSON,
+// for correlating with other logs during incident reviews.
+func (c *Controller) HandleTimelineJSON() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		pageParams, err := pagination.FromRequest(r)
+		if err != nil {
+			controller.BadRequest(w, r, c.h)
+			return
+		}
+
+		from := r.FormValue(QueryFromSearch)
+		to := r.FormValue(QueryToSearch)
+		kind := database.SystemEventKind(r.FormValue(QueryKindSearch))
+
+		events, paginator, err := c.db.ListSystemEvents(pageParams, timelineScopes(from, to, kind)...)
+		if err != nil {
+			controller.InternalError(w, r, c.h, err)
+			return
+		}
+
+		resp := &timelineResponse{
+			Events: events,
+		}
+		if resp.Events == nil {
+			resp.Events = []*database.SystemEvent{}
+		}
+		if paginator != nil && paginator.NextPage != nil {
+			resp.NextPage = paginator.NextPage.Number
+		}
+
+		c.h.RenderJSON(w, http.StatusOK, resp)
+	})
+}
+
+func timelineScopes(from, to string, kind database.SystemEventKind) []database.Scope {
+	return []database.Scope{
+		database.WithSystemEventTime(from, to),
+		database.WithSystemEventKind(kind),
+	}
+}
+
+// recordConfigChange records a change to system configuration on the system
+// event timeline. Failures are only logged, because the change itself has
+// already been saved.
+func (c *Controller) recordConfigChange(ctx context.Context, source, message string) {
+	logger := logging.FromContext(ctx).Named("admin.recordConfigChange")
+
+	actor := database.System
+	if currentUser := controller.UserFromContext(ctx); currentUser != nil {
+		actor = currentUser
+	}
+
+	if err := c.db.RecordSystemEvent(&database.SystemEvent{
+		Kind:    database.SystemEventConfigChange,
+		Source:  source,
+		Message: message + " by " + actor.AuditDisplay(),
+	}); err != nil {
+		logger.Errorw("failed to record config change", "source", source, "error", err)
+	}
+}
@@ -0,0 +1,265 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package admin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/google/exposure-notifications-server/pkg/logging"
+	"github.com/google/exposure-notifications-verification-server/pkg/controller"
+	"github.com/google/exposure-notifications-verification-server/pkg/database"
+	"github.com/gorilla/mux"
+	"go.opencensus.io/stats"
+	"go.opencensus.io/tag"
+)
+
+// breakGlassErrors are the errors from the database that are shown to the
+// system admin instead of rendering an internal error.
+var breakGlassErrors = []error{
+	database.ErrNotBreakGlassAccount,
+	database.ErrBreakGlassOperator,
+	database.ErrBreakGlassSameApprover,
+	database.ErrBreakGlassOpen,
+	database.ErrBreakGlassNotPending,
+	database.ErrBreakGlassNotOpen,
+	database.ErrBreakGlassDuration,
+}
+
+// HandleBreakGlassRequest requests an activation of a break-glass account. A
+// second system admin must approve the request.
+func (c *Controller) HandleBreakGlassRequest() http.Handler {
+	type FormData struct {
+		Reason string `form:"reason"`
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		vars := mux.Vars(r)
+
+		logger := logging.FromContext(ctx).Named("admin.HandleBreakGlassRequest")
+
+		session := controller.SessionFromContext(ctx)
+		if session == nil {
+			controller.MissingSession(w, r, c.h)
+			return
+		}
+		flash := controller.Flash(session)
+
+		currentUser := controller.UserFromContext(ctx)
+		if currentUser == nil {
+			controller.MissingUser(w, r, c.h)
+			return
+		}
+
+		user, err := c.db.FindUser(vars["id"])
+		if err != nil {
+			if database.IsNotFound(err) {
+				controller.Unauthorized(w, r, c.h)
+				return
+			}
+
+			controller.InternalError(w, r, c.h, err)
+			return
+		}
+		redirectTo := fmt.Sprintf("/admin/users/%d", user.ID)
+
+		var form FormData
+		if err := controller.BindForm(w, r, &form); err != nil {
+			flash.Error("Failed to request break-glass activation: %v", err)
+			http.Redirect(w, r, redirectTo, http.StatusSeeOther)
+			return
+		}
+
+		activation, err := c.db.RequestBreakGlassActivation(user, form.Reason, currentUser)
+		if err != nil {
+			if database.IsValidationError(err) || isBreakGlassError(err) {
+				flash.Error("Failed to request break-glass activation: %v", err)
+				http.Redirect(w, r, redirectTo, http.StatusSeeOther)
+				return
+			}
+
+			controller.InternalError(w, r, c.h, err)
+			return
+		}
+
+		logger.Warnw("break-glass activation requested",
+			"user", user.Email,
+			"requested_by", currentUser.Email,
+			"activation_id", activation.ID)
+		recordBreakGlass(ctx, "requested")
+
+		flash.Alert("Requested break-glass activation of %v. A second system admin must approve it within %v.",
+			user.Email, database.BreakGlassRequestTTL)
+		http.Redirect(w, r, redirectTo, http.StatusSeeOther)
+	})
+}
+
+// HandleBreakGlassApprove approves a pending break-glass activation, making
+// the account a system admin for the configured duration.
+func (c *Controller) HandleBreakGlassApprove() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		logger := logging.FromContext(ctx).Named("admin.HandleBreakGlassApprove")
+
+		session := controller.SessionFromContext(ctx)
+		if session == nil {
+			controller.MissingSession(w, r, c.h)
+			return
+		}
+		flash := controller.Flash(session)
+
+		currentUser := controller.UserFromContext(ctx)
+		if currentUser == nil {
+			controller.MissingUser(w, r, c.h)
+			return
+		}
+
+		user, activation, ok := c.findBreakGlassActivation(w, r)
+		if !ok {
+			return
+		}
+		redirectTo := fmt.Sprintf("/admin/users/%d", user.ID)
+
+		if err := c.db.ApproveBreakGlassActivation(activation, c.config.BreakGlassDuration, currentUser); err != nil {
+			if isBreakGlassError(err) {
+				flash.Error("Failed to approve break-glass activation: %v", err)
+				http.Redirect(w, r, redirectTo, http.StatusSeeOther)
+				return
+			}
+
+			controller.InternalError(w, r, c.h, err)
+			return
+		}
+
+		logger.Warnw("break-glass activation approved",
+			"user", user.Email,
+			"approved_by", currentUser.Email,
+			"activation_id", activation.ID,
+			"expires_at", activation.ExpiresAt)
+		recordBreakGlass(ctx, "approved")
+
+		flash.Alert("Approved break-glass activation of %v until %v.",
+			user.Email, activation.ExpiresAt.Format("2006-01-02 15:04 MST"))
+		http.Redirect(w, r, redirectTo, http.StatusSeeOther)
+	})
+}
+
+// HandleBreakGlassRevoke cancels a pending break-glass activation or ends an
+// active one.
+func (c *Controller) HandleBreakGlassRevoke() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		logger := logging.FromContext(ctx).Named("admin.HandleBreakGlassRevoke")
+
+		session := controller.SessionFromContext(ctx)
+		if session == nil {
+			controller.MissingSession(w, r, c.h)
+			return
+		}
+		flash := controller.Flash(session)
+
+		currentUser := controller.UserFromContext(ctx)
+		if currentUser == nil {
+			controller.MissingUser(w, r, c.h)
+			return
+		}
+
+		user, activation, ok := c.findBreakGlassActivation(w, r)
+		if !ok {
+			return
+		}
+
+		if err := c.db.RevokeBreakGlassActivation(activation, currentUser); err != nil {
+			if isBreakGlassError(err) {
+				flash.Error("Failed to revoke break-glass activation: %v", err)
+				http.Redirect(w, r, fmt.Sprintf("/admin/users/%d", user.ID), http.StatusSeeOther)
+				return
+			}
+
+			controller.InternalError(w, r, c.h, err)
+			return
+		}
+
+		logger.Warnw("break-glass activation revoked",
+			"user", user.Email,
+			"revoked_by", currentUser.Email,
+			"activation_id", activation.ID)
+		recordBreakGlass(ctx, "revoked")
+
+		flash.Alert("Revoked break-glass activation of %v.", user.Email)
+
+		// A break-glass account that revoked its own activation is no longer a
+		// system admin.
+		if user.ID == currentUser.ID {
+			http.Redirect(w, r, "/login/select-realm", http.StatusSeeOther)
+			return
+		}
+		http.Redirect(w, r, fmt.Sprintf("/admin/users/%d", user.ID), http.StatusSeeOther)
+	})
+}
+
+// findBreakGlassActivation loads the break-glass account and activation from
+// the URL. If either is not found, it renders the appropriate error and
+// returns false.
+func (c *Controller) findBreakGlassActivation(w http.ResponseWriter, r *http.Request) (*database.User, *database.BreakGlassActivation, bool) {
+	vars := mux.Vars(r)
+
+	user, err := c.db.FindUser(vars["id"])
+	if err != nil {
+		if database.IsNotFound(err) {
+			controller.Unauthorized(w, r, c.h)
+			return nil, nil, false
+		}
+
+		controller.InternalError(w, r, c.h, err)
+		return nil, nil, false
+	}
+
+	activation, err := user.FindBreakGlassActivation(c.db, vars["activation_id"])
+	if err != nil {
+		if database.IsNotFound(err) {
+			controller.Unauthorized(w, r, c.h)
+			return nil, nil, false
+		}
+
+		controller.InternalError(w, r, c.h, err)
+		return nil, nil, false
+	}
+
+	return user, activation, true
+}
+
+// recordBreakGlass records the break-glass metric with the given action.
+func recordBreakGlass(ctx context.Context, action string) {
+	stats.RecordWithTags(ctx, []tag.Mutator{
+		tag.Upsert(breakGlassActionTagKey, action),
+	}, mBreakGlass.M(1))
+}
+
+// isBreakGlassError returns true if the error is one of the expected
+// break-glass errors.
+func isBreakGlassError(err error) bool {
+	for _, target := range breakGlassErrors {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
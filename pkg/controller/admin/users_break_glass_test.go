@@ -0,0 +1,194 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package admin_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/google/exposure-notifications-verification-server/internal/envstest"
+	"github.com/google/exposure-notifications-verification-server/internal/project"
+	"github.com/google/exposure-notifications-verification-server/pkg/controller"
+	"github.com/google/exposure-notifications-verification-server/pkg/controller/admin"
+	"github.com/google/exposure-notifications-verification-server/pkg/database"
+	"github.com/gorilla/mux"
+	"github.com/gorilla/sessions"
+)
+
+func TestHandleBreakGlass(t *testing.T) {
+	t.Parallel()
+
+	ctx := project.TestContext(t)
+	harness := envstest.NewServerConfig(t, testDatabaseInstance)
+	db := harness.Database
+
+	c := admin.New(harness.Config, harness.Cacher, db, harness.AuthProvider, harness.RateLimiter, harness.Renderer)
+	requestHandler := harness.WithCommonMiddlewares(c.HandleBreakGlassRequest())
+	approveHandler := harness.WithCommonMiddlewares(c.HandleBreakGlassApprove())
+	revokeHandler := harness.WithCommonMiddlewares(c.HandleBreakGlassRevoke())
+
+	t.Run("middleware", func(t *testing.T) {
+		t.Parallel()
+
+		for _, handler := range []http.Handler{requestHandler, approveHandler, revokeHandler} {
+			envstest.ExerciseSessionMissing(t, handler)
+			envstest.ExerciseUserMissing(t, handler)
+		}
+	})
+
+	createUser := func(tb testing.TB, systemAdmin, breakGlass bool) *database.User {
+		tb.Helper()
+
+		suffix, err := project.RandomHexString(6)
+		if err != nil {
+			tb.Fatal(err)
+		}
+
+		user := &database.User{
+			Name:        "Tester",
+			Email:       fmt.Sprintf("tester-%s@example.com", suffix),
+			SystemAdmin: systemAdmin,
+			BreakGlass:  breakGlass,
+		}
+		if err := db.SaveUser(user, database.SystemTest); err != nil {
+			tb.Fatal(err)
+		}
+		return user
+	}
+
+	t.Run("lifecycle", func(t *testing.T) {
+		t.Parallel()
+
+		account := createUser(t, false, true)
+		requester := createUser(t, true, false)
+		approver := createUser(t, true, false)
+		accountPath := fmt.Sprintf("/admin/users/%d", account.ID)
+
+		// Request.
+		session := &sessions.Session{}
+		ctx := controller.WithSession(ctx, session)
+		ctx = controller.WithUser(ctx, requester)
+
+		w, r := envstest.BuildFormRequest(ctx, t, http.MethodPost, "/", &url.Values{
+			"reason": []string{"database outage"},
+		})
+		r = mux.SetURLVars(r, map[string]string{"id": fmt.Sprintf("%d", account.ID)})
+		requestHandler.ServeHTTP(w, r)
+
+		if got, want := w.Code, http.StatusSeeOther; got != want {
+			t.Fatalf("expected %d to be %d: %s", got, want, controller.Flash(session).Errors())
+		}
+		if got, want := w.Header().Get("Location"), accountPath; got != want {
+			t.Errorf("expected %q to be %q", got, want)
+		}
+
+		activations, err := account.ListBreakGlassActivations(db)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := len(activations), 1; got != want {
+			t.Fatalf("expected %d activations, got %d", want, got)
+		}
+		activation := activations[0]
+		vars := map[string]string{
+			"id":            fmt.Sprintf("%d", account.ID),
+			"activation_id": fmt.Sprintf("%d", activation.ID),
+		}
+
+		// The requester cannot approve their own request.
+		session = &sessions.Session{}
+		ctx = controller.WithSession(ctx, session)
+		ctx = controller.WithUser(ctx, requester)
+
+		w, r = envstest.BuildFormRequest(ctx, t, http.MethodPatch, "/", nil)
+		r = mux.SetURLVars(r, vars)
+		approveHandler.ServeHTTP(w, r)
+
+		if got, want := w.Code, http.StatusSeeOther; got != want {
+			t.Errorf("expected %d to be %d", got, want)
+		}
+		if got, want := strings.Join(controller.Flash(session).Errors(), ", "), "second system admin"; !strings.Contains(got, want) {
+			t.Errorf("expected %q to contain %q", got, want)
+		}
+
+		// Approve.
+		session = &sessions.Session{}
+		ctx = controller.WithSession(ctx, session)
+		ctx = controller.WithUser(ctx, approver)
+
+		w, r = envstest.BuildFormRequest(ctx, t, http.MethodPatch, "/", nil)
+		r = mux.SetURLVars(r, vars)
+		approveHandler.ServeHTTP(w, r)
+
+		if got, want := w.Code, http.StatusSeeOther; got != want {
+			t.Fatalf("expected %d to be %d: %s", got, want, controller.Flash(session).Errors())
+		}
+
+		account, err = db.FindUser(account.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !account.IsSystemAdmin() {
+			t.Errorf("expected approved break-glass account to be a system admin")
+		}
+
+		// The account ends its own activation.
+		session = &sessions.Session{}
+		ctx = controller.WithSession(ctx, session)
+		ctx = controller.WithUser(ctx, account)
+
+		w, r = envstest.BuildFormRequest(ctx, t, http.MethodPatch, "/", nil)
+		r = mux.SetURLVars(r, vars)
+		revokeHandler.ServeHTTP(w, r)
+
+		if got, want := w.Code, http.StatusSeeOther; got != want {
+			t.Fatalf("expected %d to be %d: %s", got, want, controller.Flash(session).Errors())
+		}
+		if got, want := w.Header().Get("Location"), "/login/select-realm"; got != want {
+			t.Errorf("expected %q to be %q", got, want)
+		}
+
+		account, err = db.FindUser(account.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if account.IsSystemAdmin() {
+			t.Errorf("expected revoked break-glass account to not be a system admin")
+		}
+	})
+
+	t.Run("not_found", func(t *testing.T) {
+		t.Parallel()
+
+		account := createUser(t, false, true)
+
+		ctx := controller.WithSession(ctx, &sessions.Session{})
+		ctx = controller.WithUser(ctx, account)
+
+		w, r := envstest.BuildFormRequest(ctx, t, http.MethodPatch, "/", nil)
+		r = mux.SetURLVars(r, map[string]string{
+			"id":            fmt.Sprintf("%d", account.ID),
+			"activation_id": "999999",
+		})
+		revokeHandler.ServeHTTP(w, r)
+
+		if got, want := w.Code, http.StatusUnauthorized; got != want {
+			t.Errorf("expected %d to be %d", got, want)
+		}
+	})
+}
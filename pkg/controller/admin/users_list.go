@@ -99,6 +99,23 @@ func (c *Controller) HandleUserShow() http.Handler {
 		}
 
 		m := controller.TemplateMapFromContext(ctx)
+
+		if user.BreakGlass {
+			activations, err := user.ListBreakGlassActivations(c.db)
+			if err != nil {
+				controller.InternalError(w, r, c.h, err)
+				return
+			}
+
+			if err := c.db.LoadBreakGlassOperators(activations); err != nil {
+				controller.InternalError(w, r, c.h, err)
+				return
+			}
+
+			m["breakGlassActivations"] = activations
+			m["breakGlassDuration"] = c.config.BreakGlassDuration
+		}
+
 		m.Title("User: %s - System Admin", user.Name)
 		m["user"] = user
 		m["memberships"] = memberships
@@ -25,11 +25,12 @@ import (
 	"github.com/gorilla/mux"
 )
 
-// HandleSystemAdminCreate creates a new system admin.
+// HandleSystemAdminCreate creates a new system admin or break-glass account.
 func (c *Controller) HandleSystemAdminCreate() http.Handler {
 	type FormData struct {
-		Email string `form:"email"`
-		Name  string `form:"name"`
+		Email      string `form:"email"`
+		Name       string `form:"name"`
+		BreakGlass bool   `form:"break_glass"`
 	}
 
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -59,8 +60,9 @@ func (c *Controller) HandleSystemAdminCreate() http.Handler {
 		err := controller.BindForm(w, r, &form)
 		if err != nil {
 			user := &database.User{
-				Email: form.Email,
-				Name:  form.Name,
+				Email:      form.Email,
+				Name:       form.Name,
+				BreakGlass: form.BreakGlass,
 			}
 
 			user.AddError("", err.Error())
@@ -84,7 +86,10 @@ func (c *Controller) HandleSystemAdminCreate() http.Handler {
 			}
 		}
 
-		user.SystemAdmin = true
+		// Break-glass accounts are only system admins while an approved
+		// activation is in effect.
+		user.BreakGlass = form.BreakGlass
+		user.SystemAdmin = !form.BreakGlass
 		if err := c.db.SaveUser(user, currentUser); err != nil {
 			if database.IsValidationError(err) {
 				w.WriteHeader(http.StatusUnprocessableEntity)
@@ -108,7 +113,11 @@ func (c *Controller) HandleSystemAdminCreate() http.Handler {
 			c.renderNewUser(ctx, w, user)
 		}
 
-		flash.Alert("Successfully created system admin '%v'", user.Name)
+		if user.BreakGlass {
+			flash.Alert("Successfully created break-glass account '%v'", user.Name)
+		} else {
+			flash.Alert("Successfully created system admin '%v'", user.Name)
+		}
 		http.Redirect(w, r, fmt.Sprintf("/admin/users/%d", user.ID), http.StatusSeeOther)
 		return
 	})
@@ -0,0 +1,108 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package announcements_test
+
+import (
+	"net/http"
+	"strconv"
+	"testing"
+
+	"github.com/google/exposure-notifications-verification-server/internal/envstest"
+	"github.com/google/exposure-notifications-verification-server/internal/project"
+	"github.com/google/exposure-notifications-verification-server/pkg/controller"
+	"github.com/google/exposure-notifications-verification-server/pkg/controller/announcements"
+	"github.com/google/exposure-notifications-verification-server/pkg/database"
+	"github.com/gorilla/mux"
+	"github.com/gorilla/sessions"
+)
+
+func TestHandleDismiss(t *testing.T) {
+	t.Parallel()
+
+	ctx := project.TestContext(t)
+	harness := envstest.NewServerConfig(t, testDatabaseInstance)
+	db := harness.Database
+
+	user := &database.User{
+		Email: "dismiss@example.com",
+		Name:  "Dismiss",
+	}
+	if err := db.SaveUser(user, database.SystemTest); err != nil {
+		t.Fatal(err)
+	}
+
+	announcement := &database.Announcement{
+		Title:    "New",
+		Body:     "Something new",
+		Audience: database.AnnouncementAudienceAll,
+	}
+	if err := db.SaveAnnouncement(announcement, database.SystemTest); err != nil {
+		t.Fatal(err)
+	}
+
+	c := announcements.New(db, harness.Renderer)
+	handler := harness.WithCommonMiddlewares(c.HandleDismiss())
+
+	t.Run("missing_user", func(t *testing.T) {
+		t.Parallel()
+
+		ctx := controller.WithSession(ctx, &sessions.Session{})
+
+		w, r := envstest.BuildFormRequest(ctx, t, http.MethodPost, "/", nil)
+		handler.ServeHTTP(w, r)
+
+		if got, want := w.Code, http.StatusInternalServerError; got != want {
+			t.Errorf("Expected %d to be %d", got, want)
+		}
+	})
+
+	t.Run("not_found", func(t *testing.T) {
+		t.Parallel()
+
+		ctx := controller.WithSession(ctx, &sessions.Session{})
+		ctx = controller.WithUser(ctx, user)
+
+		w, r := envstest.BuildFormRequest(ctx, t, http.MethodPost, "/", nil)
+		r = mux.SetURLVars(r, map[string]string{"id": "123456"})
+		handler.ServeHTTP(w, r)
+
+		if got, want := w.Code, http.StatusNotFound; got != want {
+			t.Errorf("Expected %d to be %d", got, want)
+		}
+	})
+
+	t.Run("dismisses", func(t *testing.T) {
+		t.Parallel()
+
+		ctx := controller.WithSession(ctx, &sessions.Session{})
+		ctx = controller.WithUser(ctx, user)
+
+		w, r := envstest.BuildFormRequest(ctx, t, http.MethodPost, "/", nil)
+		r = mux.SetURLVars(r, map[string]string{"id": strconv.FormatUint(uint64(announcement.ID), 10)})
+		handler.ServeHTTP(w, r)
+
+		if got, want := w.Code, http.StatusSeeOther; got != want {
+			t.Errorf("Expected %d to be %d", got, want)
+		}
+
+		read, err := db.ReadAnnouncementIDs(user, []uint{announcement.ID})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, ok := read[announcement.ID]; !ok {
+			t.Errorf("expected announcement to be read")
+		}
+	})
+}
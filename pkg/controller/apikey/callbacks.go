@@ -0,0 +1,178 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package apikey
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/google/exposure-notifications-verification-server/pkg/api"
+	"github.com/google/exposure-notifications-verification-server/pkg/controller"
+	"github.com/google/exposure-notifications-verification-server/pkg/database"
+	"github.com/google/exposure-notifications-verification-server/pkg/rbac"
+	"github.com/gorilla/mux"
+)
+
+// HandleCallbackEventsAPI lists the events delivered to callback URLs, with
+// their JSON schemas and example payloads.
+func (c *Controller) HandleCallbackEventsAPI() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		authorizedApp := controller.AuthorizedAppFromContext(ctx)
+		if authorizedApp == nil {
+			controller.Unauthorized(w, r, c.h)
+			return
+		}
+
+		c.h.RenderJSON(w, http.StatusOK, &api.CallbackEventsResponse{
+			Events: api.CallbackEvents,
+		})
+	})
+}
+
+// HandleCallbackTestAPI queues a test notification for an event to the calling
+// API key's callback URL.
+func (c *Controller) HandleCallbackTestAPI() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		authorizedApp := controller.AuthorizedAppFromContext(ctx)
+		if authorizedApp == nil {
+			controller.Unauthorized(w, r, c.h)
+			return
+		}
+
+		var request api.CallbackTestRequest
+		if err := controller.BindJSON(w, r, &request); err != nil {
+			c.h.RenderJSON(w, http.StatusBadRequest, api.Error(err).WithCode(api.ErrUnparsableRequest))
+			return
+		}
+
+		event := api.FindCallbackEvent(request.Event)
+		if event == nil {
+			c.h.RenderJSON(w, http.StatusBadRequest,
+				api.Errorf("unknown callback event %q", request.Event).WithCode(api.ErrCallbackEventUnknown))
+			return
+		}
+
+		if authorizedApp.CallbackURL == "" {
+			c.h.RenderJSON(w, http.StatusBadRequest,
+				api.Errorf("api key does not have a callback url").WithCode(api.ErrCallbackURLMissing))
+			return
+		}
+
+		delivery, err := c.enqueueTestCallback(authorizedApp, event)
+		if err != nil {
+			controller.InternalError(w, r, c.h, err)
+			return
+		}
+
+		c.h.RenderJSON(w, http.StatusAccepted, &api.CallbackTestResponse{
+			DeliveryID: delivery.ID,
+		})
+	})
+}
+
+// HandleCallbackTest queues a test notification for an event to the API key's
+// callback URL.
+func (c *Controller) HandleCallbackTest() http.Handler {
+	type FormData struct {
+		Event string `form:"event"`
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		vars := mux.Vars(r)
+
+		session := controller.SessionFromContext(ctx)
+		if session == nil {
+			controller.MissingSession(w, r, c.h)
+			return
+		}
+		flash := controller.Flash(session)
+
+		membership := controller.MembershipFromContext(ctx)
+		if membership == nil {
+			controller.MissingMembership(w, r, c.h)
+			return
+		}
+		if !membership.Can(rbac.APIKeyWrite) {
+			controller.Unauthorized(w, r, c.h)
+			return
+		}
+		currentRealm := membership.Realm
+
+		authApp, err := currentRealm.FindAuthorizedApp(c.db, vars["id"])
+		if err != nil {
+			if database.IsNotFound(err) {
+				controller.Unauthorized(w, r, c.h)
+				return
+			}
+
+			controller.InternalError(w, r, c.h, err)
+			return
+		}
+
+		var form FormData
+		if err := controller.BindForm(w, r, &form); err != nil {
+			authApp.AddError("", err.Error())
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			c.renderShow(ctx, w, authApp)
+			return
+		}
+
+		event := api.FindCallbackEvent(form.Event)
+		if event == nil {
+			authApp.AddError("event", "is invalid")
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			c.renderShow(ctx, w, authApp)
+			return
+		}
+
+		if authApp.CallbackURL == "" {
+			authApp.AddError("callbackURL", "is required to send test events")
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			c.renderShow(ctx, w, authApp)
+			return
+		}
+
+		delivery, err := c.enqueueTestCallback(authApp, event)
+		if err != nil {
+			controller.InternalError(w, r, c.h, err)
+			return
+		}
+
+		flash.Alert("Queued a test %s event for %q", event.Event, authApp.Name)
+		http.Redirect(w, r, fmt.Sprintf("/realm/apikeys/deliveries/%d", delivery.ID), http.StatusSeeOther)
+	})
+}
+
+// enqueueTestCallback queues the event's test notification for delivery to the
+// API key's callback URL.
+func (c *Controller) enqueueTestCallback(authApp *database.AuthorizedApp, event *api.CallbackEvent) (*database.CallbackDelivery, error) {
+	b, err := json.Marshal(event.TestNotification(time.Now().UTC()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal test notification: %w", err)
+	}
+
+	delivery, err := c.db.EnqueueCallbackDelivery(authApp.ID, b)
+	if err != nil {
+		return nil, fmt.Errorf("failed to enqueue test notification: %w", err)
+	}
+	return delivery, nil
+}
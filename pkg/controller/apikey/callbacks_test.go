@@ -0,0 +1,277 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package apikey_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/google/exposure-notifications-verification-server/internal/envstest"
+	"github.com/google/exposure-notifications-verification-server/internal/project"
+	"github.com/google/exposure-notifications-verification-server/pkg/api"
+	"github.com/google/exposure-notifications-verification-server/pkg/controller"
+	"github.com/google/exposure-notifications-verification-server/pkg/controller/apikey"
+	"github.com/google/exposure-notifications-verification-server/pkg/database"
+	"github.com/google/exposure-notifications-verification-server/pkg/rbac"
+	"github.com/gorilla/mux"
+	"github.com/gorilla/sessions"
+)
+
+// createCallbackApp creates an API key in the realm with the given callback
+// URL.
+func createCallbackApp(tb testing.TB, db *database.Database, realm *database.Realm, name, callbackURL string) *database.AuthorizedApp {
+	tb.Helper()
+
+	authApp := &database.AuthorizedApp{
+		RealmID:     realm.ID,
+		Name:        name,
+		CallbackURL: callbackURL,
+	}
+	if _, err := realm.CreateAuthorizedApp(db, authApp, database.SystemTest); err != nil {
+		tb.Fatal(err)
+	}
+	return authApp
+}
+
+func TestHandleCallbackEventsAPI(t *testing.T) {
+	t.Parallel()
+
+	ctx := project.TestContext(t)
+	harness := envstest.NewServerConfig(t, testDatabaseInstance)
+
+	c := apikey.New(harness.Cacher, harness.Database, harness.Renderer)
+	handler := harness.WithCommonMiddlewares(c.HandleCallbackEventsAPI())
+
+	t.Run("unauthorized", func(t *testing.T) {
+		t.Parallel()
+
+		w, r := envstest.BuildJSONRequest(ctx, t, http.MethodGet, "/", nil)
+		handler.ServeHTTP(w, r)
+
+		if got, want := w.Code, http.StatusUnauthorized; got != want {
+			t.Errorf("Expected %d to be %d", got, want)
+		}
+	})
+
+	t.Run("lists", func(t *testing.T) {
+		t.Parallel()
+
+		ctx := controller.WithAuthorizedApp(ctx, &database.AuthorizedApp{})
+
+		w, r := envstest.BuildJSONRequest(ctx, t, http.MethodGet, "/", nil)
+		handler.ServeHTTP(w, r)
+
+		if got, want := w.Code, http.StatusOK; got != want {
+			t.Fatalf("Expected %d to be %d: %s", got, want, w.Body.String())
+		}
+
+		var resp api.CallbackEventsResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatal(err)
+		}
+		if got, want := len(resp.Events), len(api.CallbackEvents); got != want {
+			t.Errorf("expected %d events, got %d", want, got)
+		}
+	})
+}
+
+func TestHandleCallbackTestAPI(t *testing.T) {
+	t.Parallel()
+
+	ctx := project.TestContext(t)
+	harness := envstest.NewServerConfig(t, testDatabaseInstance)
+
+	realm, err := harness.Database.FindRealm(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := apikey.New(harness.Cacher, harness.Database, harness.Renderer)
+	handler := harness.WithCommonMiddlewares(c.HandleCallbackTestAPI())
+
+	send := func(tb testing.TB, authApp *database.AuthorizedApp, event string) (int, *api.CallbackTestResponse) {
+		tb.Helper()
+
+		ctx := controller.WithAuthorizedApp(ctx, authApp)
+		w, r := envstest.BuildJSONRequest(ctx, tb, http.MethodPost, "/", &api.CallbackTestRequest{
+			Event: event,
+		})
+		handler.ServeHTTP(w, r)
+
+		var resp api.CallbackTestResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			tb.Fatal(err)
+		}
+		return w.Code, &resp
+	}
+
+	t.Run("unauthorized", func(t *testing.T) {
+		t.Parallel()
+
+		w, r := envstest.BuildJSONRequest(ctx, t, http.MethodPost, "/", &api.CallbackTestRequest{})
+		handler.ServeHTTP(w, r)
+
+		if got, want := w.Code, http.StatusUnauthorized; got != want {
+			t.Errorf("Expected %d to be %d", got, want)
+		}
+	})
+
+	t.Run("unknown_event", func(t *testing.T) {
+		t.Parallel()
+
+		authApp := createCallbackApp(t, harness.Database, realm, "CallbackTestAPI1", "https://example.com/callback")
+
+		code, resp := send(t, authApp, "nope")
+		if got, want := code, http.StatusBadRequest; got != want {
+			t.Errorf("Expected %d to be %d", got, want)
+		}
+		if got, want := resp.ErrorCode, api.ErrCallbackEventUnknown; got != want {
+			t.Errorf("Expected %q to be %q", got, want)
+		}
+	})
+
+	t.Run("no_callback_url", func(t *testing.T) {
+		t.Parallel()
+
+		authApp := createCallbackApp(t, harness.Database, realm, "CallbackTestAPI2", "")
+
+		code, resp := send(t, authApp, api.CallbackEventBatchIssueCompleted)
+		if got, want := code, http.StatusBadRequest; got != want {
+			t.Errorf("Expected %d to be %d", got, want)
+		}
+		if got, want := resp.ErrorCode, api.ErrCallbackURLMissing; got != want {
+			t.Errorf("Expected %q to be %q", got, want)
+		}
+	})
+
+	t.Run("queues", func(t *testing.T) {
+		t.Parallel()
+
+		authApp := createCallbackApp(t, harness.Database, realm, "CallbackTestAPI3", "https://example.com/callback")
+
+		code, resp := send(t, authApp, api.CallbackEventUserImportCompleted)
+		if got, want := code, http.StatusAccepted; got != want {
+			t.Fatalf("Expected %d to be %d", got, want)
+		}
+
+		delivery, err := realm.FindCallbackDelivery(harness.Database, resp.DeliveryID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := delivery.AuthorizedAppID, authApp.ID; got != want {
+			t.Errorf("expected delivery for app %d, got %d", want, got)
+		}
+
+		var notification api.CallbackNotification
+		if err := json.Unmarshal([]byte(delivery.Payload), &notification); err != nil {
+			t.Fatal(err)
+		}
+		if got, want := notification.Event, api.CallbackEventUserImportCompleted; got != want {
+			t.Errorf("Expected %q to be %q", got, want)
+		}
+		if !notification.Test {
+			t.Errorf("expected test notification")
+		}
+	})
+}
+
+func TestHandleCallbackTest(t *testing.T) {
+	t.Parallel()
+
+	ctx := project.TestContext(t)
+	harness := envstest.NewServerConfig(t, testDatabaseInstance)
+
+	c := apikey.New(harness.Cacher, harness.Database, harness.Renderer)
+	handler := harness.WithCommonMiddlewares(c.HandleCallbackTest())
+
+	t.Run("middleware", func(t *testing.T) {
+		t.Parallel()
+
+		envstest.ExerciseSessionMissing(t, handler)
+		envstest.ExerciseMembershipMissing(t, handler)
+		envstest.ExercisePermissionMissing(t, handler)
+		envstest.ExerciseIDNotFound(t, &database.Membership{
+			Realm:       &database.Realm{},
+			User:        &database.User{},
+			Permissions: rbac.APIKeyWrite,
+		}, handler)
+	})
+
+	realm, err := harness.Database.FindRealm(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	send := func(tb testing.TB, authApp *database.AuthorizedApp, event string) int {
+		tb.Helper()
+
+		ctx := ctx
+		ctx = controller.WithSession(ctx, &sessions.Session{})
+		ctx = controller.WithMembership(ctx, &database.Membership{
+			Realm:       realm,
+			User:        &database.User{},
+			Permissions: rbac.APIKeyWrite,
+		})
+
+		w, r := envstest.BuildFormRequest(ctx, tb, http.MethodPost, "/", &url.Values{
+			"event": []string{event},
+		})
+		r = mux.SetURLVars(r, map[string]string{"id": fmt.Sprintf("%d", authApp.ID)})
+		handler.ServeHTTP(w, r)
+		return w.Code
+	}
+
+	t.Run("unknown_event", func(t *testing.T) {
+		t.Parallel()
+
+		authApp := createCallbackApp(t, harness.Database, realm, "CallbackTest1", "https://example.com/callback")
+
+		if got, want := send(t, authApp, "nope"), http.StatusUnprocessableEntity; got != want {
+			t.Errorf("Expected %d to be %d", got, want)
+		}
+	})
+
+	t.Run("no_callback_url", func(t *testing.T) {
+		t.Parallel()
+
+		authApp := createCallbackApp(t, harness.Database, realm, "CallbackTest2", "")
+
+		if got, want := send(t, authApp, api.CallbackEventBatchIssueCompleted), http.StatusUnprocessableEntity; got != want {
+			t.Errorf("Expected %d to be %d", got, want)
+		}
+	})
+
+	t.Run("queues", func(t *testing.T) {
+		t.Parallel()
+
+		authApp := createCallbackApp(t, harness.Database, realm, "CallbackTest3", "https://example.com/callback")
+
+		if got, want := send(t, authApp, api.CallbackEventUnclaimedCodesReport), http.StatusSeeOther; got != want {
+			t.Fatalf("Expected %d to be %d", got, want)
+		}
+
+		deliveries, _, err := realm.ListCallbackDeliveries(harness.Database, nil,
+			database.WithCallbackDeliveryAuthorizedAppID(authApp.ID))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := len(deliveries), 1; got != want {
+			t.Fatalf("expected %d deliveries, got %d", want, got)
+		}
+	})
+}
@@ -0,0 +1,141 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package apikey
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/google/exposure-notifications-verification-server/pkg/controller"
+	"github.com/google/exposure-notifications-verification-server/pkg/database"
+	"github.com/google/exposure-notifications-verification-server/pkg/pagination"
+	"github.com/google/exposure-notifications-verification-server/pkg/rbac"
+	"github.com/gorilla/mux"
+)
+
+// HandleCaptures lists the requests captured for the realm's API keys.
+func (c *Controller) HandleCaptures() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		membership := controller.MembershipFromContext(ctx)
+		if membership == nil {
+			controller.MissingMembership(w, r, c.h)
+			return
+		}
+		if !membership.Can(rbac.APIKeyRead) {
+			controller.Unauthorized(w, r, c.h)
+			return
+		}
+		currentRealm := membership.Realm
+
+		pageParams, err := pagination.FromRequest(r)
+		if err != nil {
+			controller.BadRequest(w, r, c.h)
+			return
+		}
+
+		var appID uint
+		if v := r.FormValue(QueryKeyApp); v != "" {
+			id, err := strconv.ParseUint(v, 10, 64)
+			if err != nil {
+				controller.BadRequest(w, r, c.h)
+				return
+			}
+			appID = uint(id)
+		}
+
+		captures, paginator, err := currentRealm.ListAPICaptures(c.db, pageParams,
+			database.WithAPICaptureAuthorizedAppID(appID))
+		if err != nil {
+			controller.InternalError(w, r, c.h, err)
+			return
+		}
+
+		apps, err := c.captureApps(currentRealm, captures)
+		if err != nil {
+			controller.InternalError(w, r, c.h, err)
+			return
+		}
+
+		m := controller.TemplateMapFromContext(ctx)
+		m.Title("Captured requests")
+		m["captures"] = captures
+		m["apps"] = apps
+		m["paginator"] = paginator
+		m[QueryKeyApp] = appID
+		c.h.RenderHTML(w, "apikeys/captures", m)
+	})
+}
+
+// HandleCaptureShow displays a captured request and its response.
+func (c *Controller) HandleCaptureShow() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		vars := mux.Vars(r)
+
+		membership := controller.MembershipFromContext(ctx)
+		if membership == nil {
+			controller.MissingMembership(w, r, c.h)
+			return
+		}
+		if !membership.Can(rbac.APIKeyRead) {
+			controller.Unauthorized(w, r, c.h)
+			return
+		}
+		currentRealm := membership.Realm
+
+		capture, err := currentRealm.FindAPICapture(c.db, vars["id"])
+		if err != nil {
+			if database.IsNotFound(err) {
+				controller.Unauthorized(w, r, c.h)
+				return
+			}
+
+			controller.InternalError(w, r, c.h, err)
+			return
+		}
+
+		apps, err := c.captureApps(currentRealm, []*database.APICapture{capture})
+		if err != nil {
+			controller.InternalError(w, r, c.h, err)
+			return
+		}
+
+		m := controller.TemplateMapFromContext(ctx)
+		m.Title("Captured request %d", capture.ID)
+		m["capture"] = capture
+		m["authApp"] = apps[capture.AuthorizedAppID]
+		c.h.RenderHTML(w, "apikeys/capture", m)
+	})
+}
+
+// captureApps returns the API keys for the captures, keyed by ID.
+func (c *Controller) captureApps(realm *database.Realm, captures []*database.APICapture) (map[uint]*database.AuthorizedApp, error) {
+	apps := make(map[uint]*database.AuthorizedApp, len(captures))
+	for _, capture := range captures {
+		if _, ok := apps[capture.AuthorizedAppID]; ok {
+			continue
+		}
+
+		app, err := realm.FindAuthorizedApp(c.db, capture.AuthorizedAppID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to find api key %d: %w", capture.AuthorizedAppID, err)
+		}
+		apps[capture.AuthorizedAppID] = app
+	}
+	return apps, nil
+}
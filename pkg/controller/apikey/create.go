@@ -86,14 +86,21 @@ func (c *Controller) HandleCreate() http.Handler {
 
 func bindCreateForm(r *http.Request, app *database.AuthorizedApp) error {
 	type FormData struct {
-		Name string              `form:"name"`
-		Type database.APIKeyType `form:"type"`
+		Name           string              `form:"name"`
+		Type           database.APIKeyType `form:"type"`
+		CallbackURL    string              `form:"callback_url"`
+		CallbackSecret string              `form:"callback_secret"`
+
+		UnclaimedCodesReport bool `form:"unclaimed_codes_report"`
 	}
 
 	var form FormData
 	err := controller.BindForm(nil, r, &form)
 	app.Name = form.Name
 	app.APIKeyType = form.Type
+	app.CallbackURL = form.CallbackURL
+	app.CallbackSecret = form.CallbackSecret
+	app.UnclaimedCodesReport = form.UnclaimedCodesReport
 	return err
 }
 
@@ -0,0 +1,227 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package apikey
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/google/exposure-notifications-verification-server/pkg/controller"
+	"github.com/google/exposure-notifications-verification-server/pkg/database"
+	"github.com/google/exposure-notifications-verification-server/pkg/pagination"
+	"github.com/google/exposure-notifications-verification-server/pkg/rbac"
+	"github.com/gorilla/mux"
+)
+
+const (
+	// QueryKeyStatus and QueryKeyApp are the query keys for filtering callback
+	// deliveries by status and API key.
+	QueryKeyStatus = "status"
+	QueryKeyApp    = "app"
+
+	// failedDeliveriesWindow is how far back failed deliveries are counted
+	// for the failure alert.
+	failedDeliveriesWindow = 7 * 24 * time.Hour
+)
+
+// HandleDeliveries lists the callback deliveries for the realm's API keys.
+func (c *Controller) HandleDeliveries() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		membership := controller.MembershipFromContext(ctx)
+		if membership == nil {
+			controller.MissingMembership(w, r, c.h)
+			return
+		}
+		if !membership.Can(rbac.APIKeyRead) {
+			controller.Unauthorized(w, r, c.h)
+			return
+		}
+		currentRealm := membership.Realm
+
+		pageParams, err := pagination.FromRequest(r)
+		if err != nil {
+			controller.BadRequest(w, r, c.h)
+			return
+		}
+
+		status := r.FormValue(QueryKeyStatus)
+		var appID uint
+		if v := r.FormValue(QueryKeyApp); v != "" {
+			id, err := strconv.ParseUint(v, 10, 64)
+			if err != nil {
+				controller.BadRequest(w, r, c.h)
+				return
+			}
+			appID = uint(id)
+		}
+
+		deliveries, paginator, err := currentRealm.ListCallbackDeliveries(c.db, pageParams,
+			database.WithCallbackDeliveryStatus(status),
+			database.WithCallbackDeliveryAuthorizedAppID(appID))
+		if err != nil {
+			controller.InternalError(w, r, c.h, err)
+			return
+		}
+
+		apps, err := c.deliveryApps(currentRealm, deliveries)
+		if err != nil {
+			controller.InternalError(w, r, c.h, err)
+			return
+		}
+
+		failed, err := currentRealm.CountFailedCallbackDeliveries(c.db, time.Now().UTC().Add(-failedDeliveriesWindow))
+		if err != nil {
+			controller.InternalError(w, r, c.h, err)
+			return
+		}
+
+		m := controller.TemplateMapFromContext(ctx)
+		m.Title("Callback deliveries")
+		m["deliveries"] = deliveries
+		m["apps"] = apps
+		m["paginator"] = paginator
+		m["failedDeliveries"] = failed
+		m[QueryKeyStatus] = status
+		m[QueryKeyApp] = appID
+		c.h.RenderHTML(w, "apikeys/deliveries", m)
+	})
+}
+
+// HandleDeliveryShow displays a callback delivery and its captured attempts.
+func (c *Controller) HandleDeliveryShow() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		vars := mux.Vars(r)
+
+		membership := controller.MembershipFromContext(ctx)
+		if membership == nil {
+			controller.MissingMembership(w, r, c.h)
+			return
+		}
+		if !membership.Can(rbac.APIKeyRead) {
+			controller.Unauthorized(w, r, c.h)
+			return
+		}
+		currentRealm := membership.Realm
+
+		delivery, err := currentRealm.FindCallbackDelivery(c.db, vars["id"])
+		if err != nil {
+			if database.IsNotFound(err) {
+				controller.Unauthorized(w, r, c.h)
+				return
+			}
+
+			controller.InternalError(w, r, c.h, err)
+			return
+		}
+
+		c.renderDeliveryShow(ctx, w, r, currentRealm, delivery)
+	})
+}
+
+// HandleDeliveryReplay queues a new delivery with the same payload as an
+// existing delivery.
+func (c *Controller) HandleDeliveryReplay() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		vars := mux.Vars(r)
+
+		session := controller.SessionFromContext(ctx)
+		if session == nil {
+			controller.MissingSession(w, r, c.h)
+			return
+		}
+		flash := controller.Flash(session)
+
+		membership := controller.MembershipFromContext(ctx)
+		if membership == nil {
+			controller.MissingMembership(w, r, c.h)
+			return
+		}
+		if !membership.Can(rbac.APIKeyWrite) {
+			controller.Unauthorized(w, r, c.h)
+			return
+		}
+		currentRealm := membership.Realm
+
+		delivery, err := currentRealm.FindCallbackDelivery(c.db, vars["id"])
+		if err != nil {
+			if database.IsNotFound(err) {
+				controller.Unauthorized(w, r, c.h)
+				return
+			}
+
+			controller.InternalError(w, r, c.h, err)
+			return
+		}
+
+		replay, err := c.db.ReplayCallbackDelivery(delivery)
+		if err != nil {
+			flash.Error("Failed to replay delivery: %v", err)
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			c.renderDeliveryShow(ctx, w, r, currentRealm, delivery)
+			return
+		}
+
+		flash.Alert("Queued delivery %d as a replay of delivery %d", replay.ID, delivery.ID)
+		http.Redirect(w, r, fmt.Sprintf("/realm/apikeys/deliveries/%d", replay.ID), http.StatusSeeOther)
+	})
+}
+
+// renderDeliveryShow renders the delivery page.
+func (c *Controller) renderDeliveryShow(ctx context.Context, w http.ResponseWriter, r *http.Request,
+	realm *database.Realm, delivery *database.CallbackDelivery,
+) {
+	attempts, err := c.db.ListCallbackDeliveryAttempts(delivery.ID)
+	if err != nil {
+		controller.InternalError(w, r, c.h, err)
+		return
+	}
+
+	apps, err := c.deliveryApps(realm, []*database.CallbackDelivery{delivery})
+	if err != nil {
+		controller.InternalError(w, r, c.h, err)
+		return
+	}
+
+	m := controller.TemplateMapFromContext(ctx)
+	m.Title("Callback delivery %d", delivery.ID)
+	m["delivery"] = delivery
+	m["authApp"] = apps[delivery.AuthorizedAppID]
+	m["attempts"] = attempts
+	c.h.RenderHTML(w, "apikeys/delivery", m)
+}
+
+// deliveryApps returns the API keys for the deliveries, keyed by ID.
+func (c *Controller) deliveryApps(realm *database.Realm, deliveries []*database.CallbackDelivery) (map[uint]*database.AuthorizedApp, error) {
+	apps := make(map[uint]*database.AuthorizedApp, len(deliveries))
+	for _, d := range deliveries {
+		if _, ok := apps[d.AuthorizedAppID]; ok {
+			continue
+		}
+
+		app, err := realm.FindAuthorizedApp(c.db, d.AuthorizedAppID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to find api key %d: %w", d.AuthorizedAppID, err)
+		}
+		apps[d.AuthorizedAppID] = app
+	}
+	return apps, nil
+}
@@ -59,6 +59,8 @@ func (c *Controller) HandleEnable() http.Handler {
 		}
 
 		authApp.DeletedAt = nil
+		authApp.InactiveFlaggedAt = nil
+		authApp.DisabledForInactivityAt = nil
 		if err := c.db.SaveAuthorizedApp(authApp, currentUser); err != nil {
 			authApp.AddError("", err.Error())
 			w.WriteHeader(http.StatusUnprocessableEntity)
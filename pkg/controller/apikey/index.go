@@ -17,6 +17,7 @@ package apikey
 import (
 	"context"
 	"net/http"
+	"time"
 
 	"github.com/google/exposure-notifications-verification-server/pkg/controller"
 	"github.com/google/exposure-notifications-verification-server/pkg/database"
@@ -60,18 +61,25 @@ func (c *Controller) HandleIndex() http.Handler {
 			return
 		}
 
-		c.renderIndex(ctx, w, apps, paginator, q)
+		failed, err := currentRealm.CountFailedCallbackDeliveries(c.db, time.Now().UTC().Add(-failedDeliveriesWindow))
+		if err != nil {
+			controller.InternalError(w, r, c.h, err)
+			return
+		}
+
+		c.renderIndex(ctx, w, apps, paginator, q, failed)
 	})
 }
 
 // renderIndex renders the index page.
 func (c *Controller) renderIndex(ctx context.Context, w http.ResponseWriter,
-	apps []*database.AuthorizedApp, paginator *pagination.Paginator, query string,
+	apps []*database.AuthorizedApp, paginator *pagination.Paginator, query string, failedDeliveries uint64,
 ) {
 	m := controller.TemplateMapFromContext(ctx)
 	m.Title("API keys")
 	m["apps"] = apps
 	m["paginator"] = paginator
 	m["query"] = query
+	m["failedDeliveries"] = failedDeliveries
 	c.h.RenderHTML(w, "apikeys/index", m)
 }
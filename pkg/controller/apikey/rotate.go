@@ -0,0 +1,110 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package apikey
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/google/exposure-notifications-verification-server/pkg/controller"
+	"github.com/google/exposure-notifications-verification-server/pkg/database"
+	"github.com/google/exposure-notifications-verification-server/pkg/rbac"
+	"github.com/gorilla/mux"
+)
+
+// HandleRotate issues a new API key for the authorized app. The previous key
+// remains valid for the requested overlap so callers can switch without
+// downtime.
+func (c *Controller) HandleRotate() http.Handler {
+	type FormData struct {
+		Overlap string `form:"overlap"`
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		vars := mux.Vars(r)
+
+		session := controller.SessionFromContext(ctx)
+		if session == nil {
+			controller.MissingSession(w, r, c.h)
+			return
+		}
+		flash := controller.Flash(session)
+
+		membership := controller.MembershipFromContext(ctx)
+		if membership == nil {
+			controller.MissingMembership(w, r, c.h)
+			return
+		}
+		if !membership.Can(rbac.APIKeyWrite) {
+			controller.Unauthorized(w, r, c.h)
+			return
+		}
+		currentRealm := membership.Realm
+		currentUser := membership.User
+
+		authApp, err := currentRealm.FindAuthorizedApp(c.db, vars["id"])
+		if err != nil {
+			if database.IsNotFound(err) {
+				controller.Unauthorized(w, r, c.h)
+				return
+			}
+
+			controller.InternalError(w, r, c.h, err)
+			return
+		}
+
+		var form FormData
+		if err := controller.BindForm(w, r, &form); err != nil {
+			authApp.AddError("", err.Error())
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			c.renderShow(ctx, w, authApp)
+			return
+		}
+
+		overlap, err := time.ParseDuration(form.Overlap)
+		if err != nil {
+			authApp.AddError("overlap", "is invalid")
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			c.renderShow(ctx, w, authApp)
+			return
+		}
+
+		apiKey, err := c.db.RotateAuthorizedApp(authApp, overlap, currentUser)
+		if err != nil {
+			if database.IsValidationError(err) {
+				w.WriteHeader(http.StatusUnprocessableEntity)
+				c.renderShow(ctx, w, authApp)
+				return
+			}
+
+			controller.InternalError(w, r, c.h, err)
+			return
+		}
+
+		// Store the API key on the session temporarily so it can be displayed on
+		// the next page.
+		controller.StoreSessionAPIKey(session, apiKey)
+
+		if authApp.HasPreviousAPIKey() {
+			flash.Alert("Successfully rotated API key %q. The previous key is valid until %s.",
+				authApp.Name, authApp.PreviousAPIKeyExpiresAt.Format(time.RFC1123))
+		} else {
+			flash.Alert("Successfully rotated API key %q. The previous key is no longer valid.", authApp.Name)
+		}
+		http.Redirect(w, r, fmt.Sprintf("/realm/apikeys/%d", authApp.ID), http.StatusSeeOther)
+	})
+}
@@ -0,0 +1,139 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package apikey_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/google/exposure-notifications-verification-server/internal/envstest"
+	"github.com/google/exposure-notifications-verification-server/internal/project"
+	"github.com/google/exposure-notifications-verification-server/pkg/controller"
+	"github.com/google/exposure-notifications-verification-server/pkg/controller/apikey"
+	"github.com/google/exposure-notifications-verification-server/pkg/database"
+	"github.com/google/exposure-notifications-verification-server/pkg/rbac"
+	"github.com/gorilla/mux"
+	"github.com/gorilla/sessions"
+)
+
+func TestHandleRotate(t *testing.T) {
+	t.Parallel()
+
+	ctx := project.TestContext(t)
+	harness := envstest.NewServerConfig(t, testDatabaseInstance)
+
+	c := apikey.New(harness.Cacher, harness.Database, harness.Renderer)
+	handler := harness.WithCommonMiddlewares(c.HandleRotate())
+
+	t.Run("middleware", func(t *testing.T) {
+		t.Parallel()
+
+		envstest.ExerciseSessionMissing(t, handler)
+		envstest.ExerciseMembershipMissing(t, handler)
+		envstest.ExercisePermissionMissing(t, handler)
+		envstest.ExerciseIDNotFound(t, &database.Membership{
+			Realm:       &database.Realm{},
+			User:        &database.User{},
+			Permissions: rbac.APIKeyWrite,
+		}, handler)
+	})
+
+	t.Run("invalid_overlap", func(t *testing.T) {
+		t.Parallel()
+
+		realm, err := harness.Database.FindRealm(1)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		authApp := &database.AuthorizedApp{
+			RealmID: realm.ID,
+			Name:    "Rotatey1",
+		}
+		if _, err := realm.CreateAuthorizedApp(harness.Database, authApp, database.SystemTest); err != nil {
+			t.Fatal(err)
+		}
+
+		ctx := ctx
+		ctx = controller.WithSession(ctx, &sessions.Session{})
+		ctx = controller.WithMembership(ctx, &database.Membership{
+			Realm:       realm,
+			User:        &database.User{},
+			Permissions: rbac.APIKeyWrite,
+		})
+
+		w, r := envstest.BuildFormRequest(ctx, t, http.MethodPatch, "/", &url.Values{
+			"overlap": []string{"9999h"},
+		})
+		r = mux.SetURLVars(r, map[string]string{"id": fmt.Sprintf("%d", authApp.ID)})
+		handler.ServeHTTP(w, r)
+
+		if got, want := w.Code, http.StatusUnprocessableEntity; got != want {
+			t.Errorf("Expected %d to be %d", got, want)
+		}
+	})
+
+	t.Run("success", func(t *testing.T) {
+		t.Parallel()
+
+		realm, err := harness.Database.FindRealm(1)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		authApp := &database.AuthorizedApp{
+			RealmID: realm.ID,
+			Name:    "Rotatey2",
+		}
+		oldKey, err := realm.CreateAuthorizedApp(harness.Database, authApp, database.SystemTest)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		session := &sessions.Session{}
+
+		ctx := ctx
+		ctx = controller.WithSession(ctx, session)
+		ctx = controller.WithMembership(ctx, &database.Membership{
+			Realm:       realm,
+			User:        &database.User{},
+			Permissions: rbac.APIKeyWrite,
+		})
+
+		w, r := envstest.BuildFormRequest(ctx, t, http.MethodPatch, "/", &url.Values{
+			"overlap": []string{"24h"},
+		})
+		r = mux.SetURLVars(r, map[string]string{"id": fmt.Sprintf("%d", authApp.ID)})
+		handler.ServeHTTP(w, r)
+
+		if got, want := w.Code, http.StatusSeeOther; got != want {
+			t.Errorf("Expected %d to be %d", got, want)
+		}
+
+		newKey := controller.APIKeyFromSession(session)
+		if newKey == "" || newKey == oldKey {
+			t.Fatalf("expected a new API key in the session")
+		}
+
+		// Both keys are valid during the overlap.
+		for _, key := range []string{oldKey, newKey} {
+			if _, err := harness.Database.FindAuthorizedAppByAPIKey(key); err != nil {
+				t.Errorf("expected key to be valid: %v", err)
+			}
+		}
+	})
+}
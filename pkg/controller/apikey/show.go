@@ -17,8 +17,10 @@ package apikey
 import (
 	"context"
 	"net/http"
+	"time"
 
 	"github.com/google/exposure-notifications-server/pkg/logging"
+	"github.com/google/exposure-notifications-verification-server/pkg/api"
 	"github.com/google/exposure-notifications-verification-server/pkg/controller"
 	"github.com/google/exposure-notifications-verification-server/pkg/database"
 	"github.com/google/exposure-notifications-verification-server/pkg/rbac"
@@ -85,5 +87,7 @@ func (c *Controller) renderShow(ctx context.Context, w http.ResponseWriter, auth
 	m := controller.TemplateMapFromContext(ctx)
 	m.Title("API key: %s", authApp.Name)
 	m["authApp"] = authApp
+	m["callbackEvents"] = api.CallbackEvents
+	m["captureActive"] = authApp.IsCapturing(time.Now())
 	c.h.RenderHTML(w, "apikeys/show", m)
 }
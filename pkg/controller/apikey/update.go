@@ -18,7 +18,9 @@ import (
 	"context"
 	"fmt"
 	"net/http"
+	"time"
 
+	"github.com/google/exposure-notifications-verification-server/internal/project"
 	"github.com/google/exposure-notifications-verification-server/pkg/controller"
 	"github.com/google/exposure-notifications-verification-server/pkg/database"
 	"github.com/google/exposure-notifications-verification-server/pkg/rbac"
@@ -68,7 +70,7 @@ func (c *Controller) HandleUpdate() http.Handler {
 			return
 		}
 
-		if err := bindUpdateForm(r, authApp); err != nil {
+		if err := bindUpdateForm(r, authApp, time.Now()); err != nil {
 			authApp.AddError("", err.Error())
 			w.WriteHeader(http.StatusUnprocessableEntity)
 			c.renderNew(ctx, w, authApp)
@@ -91,14 +93,47 @@ func (c *Controller) HandleUpdate() http.Handler {
 	})
 }
 
-func bindUpdateForm(r *http.Request, app *database.AuthorizedApp) error {
+func bindUpdateForm(r *http.Request, app *database.AuthorizedApp, now time.Time) error {
 	type FormData struct {
-		Name string `form:"name"`
+		Name           string `form:"name"`
+		CallbackURL    string `form:"callback_url"`
+		CallbackSecret string `form:"callback_secret"`
+
+		UnclaimedCodesReport bool `form:"unclaimed_codes_report"`
+
+		AllowedUserAgents        string `form:"allowed_user_agents"`
+		AllowedAppPackages       string `form:"allowed_app_packages"`
+		EnforceClientFingerprint bool   `form:"enforce_client_fingerprint"`
+
+		CaptureSampleRate uint   `form:"capture_sample_rate"`
+		CaptureDuration   string `form:"capture_duration"`
 	}
 
 	var form FormData
 	err := controller.BindForm(nil, r, &form)
 	app.Name = form.Name
+	app.CallbackURL = form.CallbackURL
+	if form.CallbackSecret != project.PasswordSentinel {
+		app.CallbackSecret = form.CallbackSecret
+	}
+	app.UnclaimedCodesReport = form.UnclaimedCodesReport
+	if app.IsDeviceType() {
+		app.AllowedUserAgents = database.ToLineList(form.AllowedUserAgents)
+		app.AllowedAppPackages = database.ToLineList(form.AllowedAppPackages)
+		app.EnforceClientFingerprint = form.EnforceClientFingerprint
+	}
+
+	// An empty duration keeps the current end time, so editing other fields
+	// does not extend capture.
+	app.CaptureSampleRate = form.CaptureSampleRate
+	if form.CaptureDuration != "" {
+		d, perr := time.ParseDuration(form.CaptureDuration)
+		if perr != nil || d <= 0 {
+			return fmt.Errorf("invalid capture duration %q", form.CaptureDuration)
+		}
+		until := now.Add(d).UTC()
+		app.CaptureUntil = &until
+	}
 	return err
 }
 
@@ -107,5 +142,6 @@ func (c *Controller) renderEdit(ctx context.Context, w http.ResponseWriter, auth
 	m := controller.TemplateMapFromContext(ctx)
 	m.Title("Edit API key: %s", authApp.Name)
 	m["authApp"] = authApp
+	m["captureActive"] = authApp.IsCapturing(time.Now())
 	c.h.RenderHTML(w, "apikeys/edit", m)
 }
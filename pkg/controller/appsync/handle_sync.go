@@ -22,6 +22,7 @@ import (
 	"go.opencensus.io/stats"
 
 	"github.com/google/exposure-notifications-verification-server/pkg/controller"
+	"github.com/google/exposure-notifications-verification-server/pkg/controller/jobstatus"
 )
 
 // HandleSync performs the logic to sync mobile apps.
@@ -41,12 +42,14 @@ func (c *Controller) HandleSync() http.Handler {
 		}
 		if !ok {
 			logger.Debugw("skipping (too early)")
+			jobstatus.RecordFreshness(ctx, c.db, jobstatus.JobAppSync)
 			c.h.RenderJSON(w, http.StatusOK, fmt.Errorf("too early"))
 			return
 		}
 
 		apps, err := c.appSyncClient.AppSync(ctx)
 		if err != nil {
+			jobstatus.Record(ctx, c.db, jobstatus.JobAppSync, 0, err)
 			controller.InternalError(w, r, c.h, err)
 			return
 		}
@@ -55,11 +58,13 @@ func (c *Controller) HandleSync() http.Handler {
 		if merr := c.syncApps(ctx, apps); merr != nil {
 			if errs := merr.WrappedErrors(); len(errs) > 0 {
 				logger.Errorw("failed to sync apps", "errors", errs)
+				jobstatus.Record(ctx, c.db, jobstatus.JobAppSync, 0, merr)
 				c.h.RenderJSON(w, http.StatusInternalServerError, errs)
 				return
 			}
 		}
 
+		jobstatus.Record(ctx, c.db, jobstatus.JobAppSync, int64(len(apps.Apps)), nil)
 		stats.Record(ctx, mSuccess.M(1))
 		c.h.RenderJSON(w, http.StatusOK, nil)
 	})
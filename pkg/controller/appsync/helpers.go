@@ -52,13 +52,16 @@ func (c *Controller) syncApps(ctx context.Context, apps *appsync.AppsResponse) *
 			continue
 		}
 
-		realm.AgencyBackgroundColor = strings.ToLower(app.AgencyColor)
-		realm.AgencyImage = app.AgencyImage
-		realm.DefaultLocale = app.DefaultLocale
-		realm.UserReportLearnMoreURL = app.WebReportLearnMoreURL
-		if err := c.db.SaveRealm(realm, database.System); err != nil {
-			merr = multierror.Append(merr, fmt.Errorf("unable to update agency information: %w", err))
-			continue
+		// Branding managed through the realm branding API takes precedence.
+		if !realm.AgencyBrandingManaged {
+			realm.AgencyBackgroundColor = strings.ToLower(app.AgencyColor)
+			realm.AgencyImage = app.AgencyImage
+			realm.DefaultLocale = app.DefaultLocale
+			realm.UserReportLearnMoreURL = app.WebReportLearnMoreURL
+			if err := c.db.SaveRealm(realm, database.System); err != nil {
+				merr = multierror.Append(merr, fmt.Errorf("unable to update agency information: %w", err))
+				continue
+			}
 		}
 
 		if res, err := c.db.SyncRealmTranslations(realm.ID, app.Localizations); err != nil {
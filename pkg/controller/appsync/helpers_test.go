@@ -152,4 +152,48 @@ func Test_syncApps(t *testing.T) {
 			t.Fatalf("mismatch (-want, +got):\n%s", diff)
 		}
 	})
+
+	t.Run("managed_branding", func(t *testing.T) {
+		t.Parallel()
+
+		realm := database.NewRealmWithDefaults("managed")
+		realm.RegionCode = "US-OR"
+		realm.AgencyBackgroundColor = "#ffffff"
+		realm.AgencyBrandingManaged = true
+		if err := db.SaveRealm(realm, database.SystemTest); err != nil {
+			t.Fatalf("error saving realm: %v", err)
+		}
+
+		resp := &appsync.AppsResponse{
+			Apps: []appsync.App{
+				{
+					Region: "US-OR",
+					IsEnx:  true,
+					AndroidTarget: appsync.AndroidTarget{
+						Namespace:              "android_app",
+						PackageName:            "testAppIdOR",
+						SHA256CertFingerprints: "CC:AA:AA:AA:AA:AA:AA:AA:AA:AA:AA:AA:AA:AA:AA:AA:AA:AA:AA:AA:AA:AA:AA:AA:AA:AA:AA:AA:AA:AA:AA:AA",
+					},
+					AgencyColor: "#000000",
+					AgencyImage: "https://example.com/logo.png",
+				},
+			},
+		}
+
+		merr := c.syncApps(ctx, resp)
+		if e := merr.ErrorOrNil(); e != nil {
+			t.Fatalf(e.Error())
+		}
+
+		gotRealm, err := db.FindRealmByRegion(realm.RegionCode)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := gotRealm.AgencyBackgroundColor, "#ffffff"; got != want {
+			t.Errorf("wrong agency color, got %q want %q", got, want)
+		}
+		if got, want := gotRealm.AgencyImage, ""; got != want {
+			t.Errorf("wrong agency image, got %q want %q", got, want)
+		}
+	})
 }
@@ -0,0 +1,44 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package audits exports audit entries for archival in external systems.
+package audits
+
+import (
+	"github.com/google/exposure-notifications-verification-server/pkg/database"
+	"github.com/google/exposure-notifications-verification-server/pkg/render"
+)
+
+// Type represents an export format.
+type Type int64
+
+const (
+	_ Type = iota
+	TypeCSV
+	TypeNDJSON
+)
+
+// Controller is an audit export controller.
+type Controller struct {
+	db *database.Database
+	h  *render.Renderer
+}
+
+// New creates a new audit export controller.
+func New(db *database.Database, h *render.Renderer) *Controller {
+	return &Controller{
+		db: db,
+		h:  h,
+	}
+}
@@ -0,0 +1,177 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package audits
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/google/exposure-notifications-verification-server/internal/project"
+	"github.com/google/exposure-notifications-verification-server/pkg/api"
+	"github.com/google/exposure-notifications-verification-server/pkg/controller"
+	"github.com/google/exposure-notifications-verification-server/pkg/database"
+)
+
+const (
+	// QueryKeyActorID, QueryKeyAction, QueryKeyFrom, and QueryKeyTo are the
+	// query keys for filtering exported entries.
+	QueryKeyActorID = "actor_id"
+	QueryKeyAction  = "action"
+	QueryKeyFrom    = "from"
+	QueryKeyTo      = "to"
+
+	// QueryKeyAfter is the query key for the export cursor, the ID of the last
+	// entry of the previous page. QueryKeyLimit is the query key for the
+	// maximum number of entries to return.
+	QueryKeyAfter = "after"
+	QueryKeyLimit = "limit"
+
+	// QueryKeyRealmID is the query key for filtering entries by realm. It is
+	// only available to system admins.
+	QueryKeyRealmID = "realm_id"
+
+	// maxExportEntries is the largest number of entries that can be exported in
+	// one request.
+	maxExportEntries = 10000
+)
+
+// exportTimeFormats are the accepted formats for the time range filters. The
+// second is the format of datetime-local inputs on the events pages.
+var exportTimeFormats = []string{time.RFC3339, "2006-01-02T15:04", project.RFC3339Date}
+
+// HandleRealmExport exports the audit entries for the API key's realm. It is
+// only available via an admin API key.
+func (c *Controller) HandleRealmExport(typ Type) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		authorizedApp := controller.AuthorizedAppFromContext(ctx)
+		if authorizedApp == nil {
+			controller.Unauthorized(w, r, c.h)
+			return
+		}
+
+		scopes, limit, err := exportFilters(r)
+		if err != nil {
+			c.h.RenderJSON(w, http.StatusBadRequest, api.Error(err).WithCode(api.ErrInvalidRequest))
+			return
+		}
+		scopes = append(scopes, database.WithAuditRealmID(authorizedApp.RealmID))
+
+		entries, err := c.db.ExportAudits(limit, scopes...)
+		if err != nil {
+			controller.InternalError(w, r, c.h, err)
+			return
+		}
+
+		c.render(w, r, typ, entries)
+	})
+}
+
+// HandleSystemExport exports audit entries across all realms, optionally
+// filtered to a single realm or to system events (realm 0).
+func (c *Controller) HandleSystemExport(typ Type) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		scopes, limit, err := exportFilters(r)
+		if err != nil {
+			controller.BadRequest(w, r, c.h)
+			return
+		}
+
+		if v := project.TrimSpace(r.FormValue(QueryKeyRealmID)); v != "" {
+			realmID, err := strconv.ParseUint(v, 10, 64)
+			if err != nil {
+				controller.BadRequest(w, r, c.h)
+				return
+			}
+			scopes = append(scopes, database.WithAuditRealmID(uint(realmID)))
+		}
+
+		entries, err := c.db.ExportAudits(limit, scopes...)
+		if err != nil {
+			controller.InternalError(w, r, c.h, err)
+			return
+		}
+
+		c.render(w, r, typ, entries)
+	})
+}
+
+// render writes the entries in the requested format.
+func (c *Controller) render(w http.ResponseWriter, r *http.Request, typ Type, entries database.AuditEntries) {
+	switch typ {
+	case TypeCSV:
+		c.h.RenderCSV(w, http.StatusOK, "audit-entries.csv", entries)
+	case TypeNDJSON:
+		c.h.RenderNDJSON(w, http.StatusOK, "audit-entries.ndjson", entries)
+	default:
+		controller.NotFound(w, r, c.h)
+	}
+}
+
+// exportFilters parses the filters and page size from the request.
+func exportFilters(r *http.Request) ([]database.Scope, uint, error) {
+	scopes := []database.Scope{
+		database.WithAuditActorID(r.FormValue(QueryKeyActorID)),
+		database.WithAuditAction(r.FormValue(QueryKeyAction)),
+	}
+
+	from, err := parseExportTime(QueryKeyFrom, r.FormValue(QueryKeyFrom))
+	if err != nil {
+		return nil, 0, err
+	}
+	to, err := parseExportTime(QueryKeyTo, r.FormValue(QueryKeyTo))
+	if err != nil {
+		return nil, 0, err
+	}
+	scopes = append(scopes, database.WithAuditTime(from, to))
+
+	if v := project.TrimSpace(r.FormValue(QueryKeyAfter)); v != "" {
+		after, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			return nil, 0, fmt.Errorf("%s must be an entry id", QueryKeyAfter)
+		}
+		scopes = append(scopes, database.WithAuditAfterID(uint(after)))
+	}
+
+	limit := uint(maxExportEntries)
+	if v := project.TrimSpace(r.FormValue(QueryKeyLimit)); v != "" {
+		l, err := strconv.ParseUint(v, 10, 64)
+		if err != nil || l == 0 || l > maxExportEntries {
+			return nil, 0, fmt.Errorf("%s must be between 1 and %d", QueryKeyLimit, maxExportEntries)
+		}
+		limit = uint(l)
+	}
+
+	return scopes, limit, nil
+}
+
+// parseExportTime parses a time range filter and returns it in RFC3339 format.
+// An empty value returns the empty string, which does not filter.
+func parseExportTime(key, v string) (string, error) {
+	v = project.TrimSpace(v)
+	if v == "" {
+		return "", nil
+	}
+
+	for _, layout := range exportTimeFormats {
+		if t, err := time.Parse(layout, v); err == nil {
+			return t.UTC().Format(time.RFC3339), nil
+		}
+	}
+	return "", fmt.Errorf("%s must be a timestamp in RFC3339 format", key)
+}
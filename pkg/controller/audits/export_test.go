@@ -0,0 +1,113 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package audits
+
+import (
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func TestParseExportTime(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name      string
+		value     string
+		exp       string
+		expectErr bool
+	}{
+		{name: "empty", value: "", exp: ""},
+		{name: "rfc3339", value: "2022-03-04T05:06:07-02:00", exp: "2022-03-04T07:06:07Z"},
+		{name: "datetime_local", value: "2022-03-04T05:06", exp: "2022-03-04T05:06:00Z"},
+		{name: "date", value: "2022-03-04", exp: "2022-03-04T00:00:00Z"},
+		{name: "invalid", value: "03/04/2022", expectErr: true},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := parseExportTime(QueryKeyFrom, tc.value)
+			if (err != nil) != tc.expectErr {
+				t.Fatalf("expected error %t, got %v", tc.expectErr, err)
+			}
+			if got != tc.exp {
+				t.Errorf("expected %q to be %q", got, tc.exp)
+			}
+		})
+	}
+}
+
+func TestExportFilters(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name      string
+		query     url.Values
+		limit     uint
+		expectErr bool
+	}{
+		{
+			name:  "default",
+			query: url.Values{},
+			limit: maxExportEntries,
+		},
+		{
+			name:  "all",
+			query: url.Values{"actor_id": {"users:1"}, "action": {"created"}, "from": {"2022-01-01"}, "to": {"2022-02-01"}, "after": {"10"}, "limit": {"50"}},
+			limit: 50,
+		},
+		{
+			name:      "bad_after",
+			query:     url.Values{"after": {"nope"}},
+			expectErr: true,
+		},
+		{
+			name:      "zero_limit",
+			query:     url.Values{"limit": {"0"}},
+			expectErr: true,
+		},
+		{
+			name:      "large_limit",
+			query:     url.Values{"limit": {"10001"}},
+			expectErr: true,
+		},
+		{
+			name:      "bad_to",
+			query:     url.Values{"to": {"tomorrow"}},
+			expectErr: true,
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			r := httptest.NewRequest("GET", "/?"+tc.query.Encode(), nil)
+			_, limit, err := exportFilters(r)
+			if (err != nil) != tc.expectErr {
+				t.Fatalf("expected error %t, got %v", tc.expectErr, err)
+			}
+			if limit != tc.limit {
+				t.Errorf("expected %d to be %d", limit, tc.limit)
+			}
+		})
+	}
+}
@@ -0,0 +1,52 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package branding manages the agency branding shown on the ENX Express user
+// report pages.
+package branding
+
+import (
+	"github.com/google/exposure-notifications-verification-server/pkg/api"
+	"github.com/google/exposure-notifications-verification-server/pkg/config"
+	"github.com/google/exposure-notifications-verification-server/pkg/database"
+	"github.com/google/exposure-notifications-verification-server/pkg/render"
+)
+
+// Controller is a controller for realm branding.
+type Controller struct {
+	config *config.IssueAPIVars
+	db     *database.Database
+	h      *render.Renderer
+}
+
+// New creates a new branding controller. The config is used for the ENX
+// redirect domain on which agency images are served.
+func New(cfg *config.IssueAPIVars, db *database.Database, h *render.Renderer) *Controller {
+	return &Controller{
+		config: cfg,
+		db:     db,
+		h:      h,
+	}
+}
+
+// brandingResponse builds the API response for the realm's branding.
+func brandingResponse(realm *database.Realm) *api.RealmBrandingResponse {
+	return &api.RealmBrandingResponse{
+		AgencyBackgroundColor:  realm.AgencyBackgroundColor,
+		AgencyImage:            realm.AgencyImage,
+		DefaultLocale:          realm.DefaultLocale,
+		UserReportLearnMoreURL: realm.UserReportLearnMoreURL,
+		Managed:                realm.AgencyBrandingManaged,
+	}
+}
@@ -0,0 +1,192 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package branding
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"image"
+	"net/http"
+	"strconv"
+
+	// Register the supported agency image formats.
+	_ "image/jpeg"
+	_ "image/png"
+
+	"github.com/google/exposure-notifications-verification-server/pkg/api"
+	"github.com/google/exposure-notifications-verification-server/pkg/controller"
+	"github.com/google/exposure-notifications-verification-server/pkg/database"
+	"github.com/gorilla/mux"
+)
+
+const (
+	// maxAgencyImageDimension is the maximum width and height of an agency
+	// image, in pixels.
+	maxAgencyImageDimension = 2048
+
+	// agencyImageCacheControl allows browsers and CDNs to cache agency images
+	// indefinitely. The URL contains the image checksum, so it changes when
+	// the image does.
+	agencyImageCacheControl = "public, max-age=31536000, immutable"
+)
+
+// HandleUploadAgencyImage validates and stores the realm's agency image, and
+// points the realm's agency image at it.
+func (c *Controller) HandleUploadAgencyImage() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		authorizedApp := controller.AuthorizedAppFromContext(ctx)
+		if authorizedApp == nil {
+			controller.Unauthorized(w, r, c.h)
+			return
+		}
+		realm, err := authorizedApp.Realm(c.db)
+		if err != nil {
+			controller.InternalError(w, r, c.h, err)
+			return
+		}
+
+		var request api.RealmAgencyImageRequest
+		if err := controller.BindJSON(w, r, &request); err != nil {
+			if errors.Is(err, controller.ErrBodyTooLarge) {
+				controller.RequestTooLarge(w, r, c.h, err)
+				return
+			}
+
+			c.h.RenderJSON(w, http.StatusBadRequest, api.Error(err).WithCode(api.ErrUnparsableRequest))
+			return
+		}
+
+		data, err := base64.StdEncoding.DecodeString(request.Image)
+		if err != nil {
+			c.h.RenderJSON(w, http.StatusBadRequest, api.Errorf("image must be base64 encoded").WithCode(api.ErrInvalidRequest))
+			return
+		}
+
+		contentType, err := validateAgencyImage(data)
+		if err != nil {
+			c.h.RenderJSON(w, http.StatusBadRequest, api.Error(err).WithCode(api.ErrInvalidRequest))
+			return
+		}
+
+		agencyImage := database.NewRealmAgencyImage(realm.ID, contentType, data)
+		if err := c.db.SaveRealmAgencyImage(realm, agencyImage, c.agencyImageURL(agencyImage), authorizedApp); err != nil {
+			controller.InternalError(w, r, c.h, err)
+			return
+		}
+
+		c.h.RenderJSON(w, http.StatusOK, brandingResponse(realm))
+	})
+}
+
+// HandleDeleteAgencyImage deletes the realm's uploaded agency image.
+func (c *Controller) HandleDeleteAgencyImage() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		authorizedApp := controller.AuthorizedAppFromContext(ctx)
+		if authorizedApp == nil {
+			controller.Unauthorized(w, r, c.h)
+			return
+		}
+		realm, err := authorizedApp.Realm(c.db)
+		if err != nil {
+			controller.InternalError(w, r, c.h, err)
+			return
+		}
+
+		if err := c.db.DeleteRealmAgencyImage(realm, authorizedApp); err != nil {
+			controller.InternalError(w, r, c.h, err)
+			return
+		}
+
+		c.h.RenderJSON(w, http.StatusOK, brandingResponse(realm))
+	})
+}
+
+// HandleAgencyImage serves an uploaded agency image. It is public, and the
+// response may be cached by browsers and CDNs.
+func (c *Controller) HandleAgencyImage() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+
+		realmID, err := strconv.ParseUint(vars["realm_id"], 10, 64)
+		if err != nil {
+			controller.NotFound(w, r, c.h)
+			return
+		}
+
+		agencyImage, err := c.db.FindRealmAgencyImage(uint(realmID), vars["checksum"])
+		if err != nil {
+			if database.IsNotFound(err) {
+				controller.NotFound(w, r, c.h)
+				return
+			}
+
+			controller.InternalError(w, r, c.h, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", agencyImage.ContentType)
+		w.Header().Set("Content-Length", strconv.Itoa(len(agencyImage.Data)))
+		w.Header().Set("Cache-Control", agencyImageCacheControl)
+		w.Header().Set("ETag", strconv.Quote(agencyImage.Checksum))
+		w.WriteHeader(http.StatusOK)
+		if r.Method != http.MethodHead {
+			_, _ = w.Write(agencyImage.Data)
+		}
+	})
+}
+
+// agencyImageURL returns the URL at which the image is served. Images are
+// served by the ENX redirect server, so the URL is absolute when the redirect
+// domain is configured.
+func (c *Controller) agencyImageURL(agencyImage *database.RealmAgencyImage) string {
+	if c.config == nil || c.config.ENExpressRedirectDomain == "" {
+		return agencyImage.Path()
+	}
+	return "https://" + c.config.ENExpressRedirectDomain + agencyImage.Path()
+}
+
+// validateAgencyImage returns the content type of the image, or an error if
+// it is not a PNG or JPEG within the maximum dimensions.
+func validateAgencyImage(data []byte) (string, error) {
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return "", fmt.Errorf("image must be a PNG or JPEG")
+	}
+
+	var contentType string
+	switch format {
+	case "png":
+		contentType = "image/png"
+	case "jpeg":
+		contentType = "image/jpeg"
+	default:
+		return "", fmt.Errorf("image must be a PNG or JPEG")
+	}
+
+	if cfg.Width < 1 || cfg.Height < 1 {
+		return "", fmt.Errorf("image must not be empty")
+	}
+	if cfg.Width > maxAgencyImageDimension || cfg.Height > maxAgencyImageDimension {
+		return "", fmt.Errorf("image must be at most %dx%d pixels, got %dx%d",
+			maxAgencyImageDimension, maxAgencyImageDimension, cfg.Width, cfg.Height)
+	}
+	return contentType, nil
+}
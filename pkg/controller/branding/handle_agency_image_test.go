@@ -0,0 +1,159 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package branding_test
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"image"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/exposure-notifications-verification-server/internal/envstest"
+	"github.com/google/exposure-notifications-verification-server/internal/project"
+	"github.com/google/exposure-notifications-verification-server/pkg/api"
+	"github.com/google/exposure-notifications-verification-server/pkg/config"
+	"github.com/google/exposure-notifications-verification-server/pkg/controller"
+	"github.com/google/exposure-notifications-verification-server/pkg/controller/branding"
+	"github.com/google/exposure-notifications-verification-server/pkg/database"
+	"github.com/gorilla/mux"
+)
+
+func testPNG(tb testing.TB, width, height int) []byte {
+	tb.Helper()
+
+	var b bytes.Buffer
+	if err := png.Encode(&b, image.NewRGBA(image.Rect(0, 0, width, height))); err != nil {
+		tb.Fatal(err)
+	}
+	return b.Bytes()
+}
+
+func TestHandleUploadAgencyImage(t *testing.T) {
+	t.Parallel()
+
+	ctx := project.TestContext(t)
+
+	cases := []struct {
+		name   string
+		image  string
+		status int
+	}{
+		{
+			name:   "png",
+			image:  base64.StdEncoding.EncodeToString(testPNG(t, 64, 32)),
+			status: http.StatusOK,
+		},
+		{
+			name:   "not_base64",
+			image:  "not base64!",
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "not_an_image",
+			image:  base64.StdEncoding.EncodeToString([]byte("<svg></svg>")),
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "too_large",
+			image:  base64.StdEncoding.EncodeToString(testPNG(t, 4096, 1)),
+			status: http.StatusBadRequest,
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			harness := envstest.NewServerConfig(t, testDatabaseInstance)
+
+			realm, err := harness.Database.FindRealm(1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			app := &database.AuthorizedApp{
+				RealmID:    realm.ID,
+				Name:       "Branding",
+				APIKeyType: database.APIKeyTypeAdmin,
+			}
+			if _, err := realm.CreateAuthorizedApp(harness.Database, app, database.SystemTest); err != nil {
+				t.Fatal(err)
+			}
+
+			cfg := &config.IssueAPIVars{
+				ENExpressRedirectDomain: "enx.example.com",
+			}
+			c := branding.New(cfg, harness.Database, harness.Renderer)
+			handler := harness.WithCommonMiddlewares(c.HandleUploadAgencyImage())
+
+			ctx := controller.WithAuthorizedApp(ctx, app)
+			w, r := envstest.BuildJSONRequest(ctx, t, http.MethodPut, "/", &api.RealmAgencyImageRequest{
+				Image: tc.image,
+			})
+			handler.ServeHTTP(w, r)
+
+			if got, want := w.Code, tc.status; got != want {
+				t.Fatalf("Expected %d to be %d: %s", got, want, w.Body.String())
+			}
+			if tc.status != http.StatusOK {
+				return
+			}
+
+			var resp api.RealmBrandingResponse
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatal(err)
+			}
+			if !strings.HasPrefix(resp.AgencyImage, "https://enx.example.com/branding/") {
+				t.Errorf("expected %q to be served from the redirect domain", resp.AgencyImage)
+			}
+			if !resp.Managed {
+				t.Errorf("expected branding to be managed")
+			}
+
+			// The uploaded image is served publicly and is cacheable.
+			path := strings.TrimPrefix(resp.AgencyImage, "https://enx.example.com")
+			router := mux.NewRouter()
+			router.Handle("/branding/{realm_id:[0-9]+}/agency-image/{checksum:[0-9a-f]{64}}", c.HandleAgencyImage())
+
+			w = httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+			if got, want := w.Code, http.StatusOK; got != want {
+				t.Fatalf("Expected %d to be %d", got, want)
+			}
+			if got, want := w.Header().Get("Content-Type"), "image/png"; got != want {
+				t.Errorf("expected %q to be %q", got, want)
+			}
+			if got := w.Header().Get("Cache-Control"); !strings.Contains(got, "public") {
+				t.Errorf("expected %q to be public", got)
+			}
+
+			// Deleting the image stops serving it.
+			if err := harness.Database.DeleteRealmAgencyImage(realm, database.SystemTest); err != nil {
+				t.Fatal(err)
+			}
+			w = httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+			if got, want := w.Code, http.StatusNotFound; got != want {
+				t.Errorf("Expected %d to be %d", got, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,139 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package branding
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/google/exposure-notifications-verification-server/internal/project"
+	"github.com/google/exposure-notifications-verification-server/pkg/api"
+	"github.com/google/exposure-notifications-verification-server/pkg/controller"
+	"github.com/google/exposure-notifications-verification-server/pkg/database"
+	"golang.org/x/text/language"
+)
+
+// HandleShow returns the realm's agency branding.
+func (c *Controller) HandleShow() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		authorizedApp := controller.AuthorizedAppFromContext(ctx)
+		if authorizedApp == nil {
+			controller.Unauthorized(w, r, c.h)
+			return
+		}
+		realm, err := authorizedApp.Realm(c.db)
+		if err != nil {
+			controller.InternalError(w, r, c.h, err)
+			return
+		}
+
+		c.h.RenderJSON(w, http.StatusOK, brandingResponse(realm))
+	})
+}
+
+// HandleUpdate updates the realm's agency branding. Afterwards the branding is
+// managed, so the ENX Express sync no longer overwrites it.
+func (c *Controller) HandleUpdate() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		authorizedApp := controller.AuthorizedAppFromContext(ctx)
+		if authorizedApp == nil {
+			controller.Unauthorized(w, r, c.h)
+			return
+		}
+		realm, err := authorizedApp.Realm(c.db)
+		if err != nil {
+			controller.InternalError(w, r, c.h, err)
+			return
+		}
+
+		var request api.RealmBrandingRequest
+		if err := controller.BindJSON(w, r, &request); err != nil {
+			c.h.RenderJSON(w, http.StatusBadRequest, api.Error(err).WithCode(api.ErrUnparsableRequest))
+			return
+		}
+
+		if v := request.AgencyBackgroundColor; v != nil {
+			realm.AgencyBackgroundColor = strings.ToLower(project.TrimSpace(*v))
+		}
+
+		if v := request.DefaultLocale; v != nil {
+			locale := project.TrimSpace(*v)
+			if locale != "" {
+				if _, err := language.Parse(locale); err != nil {
+					c.h.RenderJSON(w, http.StatusBadRequest, api.Errorf("defaultLocale is not a valid language tag").WithCode(api.ErrInvalidRequest))
+					return
+				}
+			}
+			realm.DefaultLocale = locale
+		}
+
+		if v := request.UserReportLearnMoreURL; v != nil {
+			learnMore := project.TrimSpace(*v)
+			if learnMore != "" {
+				u, err := url.Parse(learnMore)
+				if err != nil || u.Scheme != "https" || u.Host == "" {
+					c.h.RenderJSON(w, http.StatusBadRequest, api.Errorf("userReportLearnMoreURL must be an https:// URL").WithCode(api.ErrInvalidRequest))
+					return
+				}
+			}
+			realm.UserReportLearnMoreURL = learnMore
+		}
+
+		realm.AgencyBrandingManaged = true
+		if err := c.db.SaveRealm(realm, authorizedApp); err != nil {
+			if errors.Is(err, database.ErrValidationFailed) {
+				c.h.RenderJSON(w, http.StatusBadRequest, api.Errorf("%s", strings.Join(realm.ErrorMessages(), ", ")).WithCode(api.ErrInvalidRequest))
+				return
+			}
+
+			controller.InternalError(w, r, c.h, err)
+			return
+		}
+
+		c.h.RenderJSON(w, http.StatusOK, brandingResponse(realm))
+	})
+}
+
+// HandleReset returns control of the realm's agency branding to the ENX
+// Express sync and deletes any uploaded agency image.
+func (c *Controller) HandleReset() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		authorizedApp := controller.AuthorizedAppFromContext(ctx)
+		if authorizedApp == nil {
+			controller.Unauthorized(w, r, c.h)
+			return
+		}
+		realm, err := authorizedApp.Realm(c.db)
+		if err != nil {
+			controller.InternalError(w, r, c.h, err)
+			return
+		}
+
+		if err := c.db.ResetRealmAgencyBranding(realm, authorizedApp); err != nil {
+			controller.InternalError(w, r, c.h, err)
+			return
+		}
+
+		c.h.RenderJSON(w, http.StatusOK, brandingResponse(realm))
+	})
+}
@@ -0,0 +1,186 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package branding_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/google/exposure-notifications-verification-server/internal/envstest"
+	"github.com/google/exposure-notifications-verification-server/internal/project"
+	"github.com/google/exposure-notifications-verification-server/pkg/api"
+	"github.com/google/exposure-notifications-verification-server/pkg/config"
+	"github.com/google/exposure-notifications-verification-server/pkg/controller"
+	"github.com/google/exposure-notifications-verification-server/pkg/controller/branding"
+	"github.com/google/exposure-notifications-verification-server/pkg/database"
+	"github.com/google/go-cmp/cmp"
+)
+
+func stringPtr(s string) *string {
+	return &s
+}
+
+func TestHandleUpdate(t *testing.T) {
+	t.Parallel()
+
+	ctx := project.TestContext(t)
+
+	cases := []struct {
+		name    string
+		request *api.RealmBrandingRequest
+		status  int
+		want    *api.RealmBrandingResponse
+	}{
+		{
+			name: "updates",
+			request: &api.RealmBrandingRequest{
+				AgencyBackgroundColor:  stringPtr("#1A73E8"),
+				DefaultLocale:          stringPtr("es-419"),
+				UserReportLearnMoreURL: stringPtr("https://health.example.gov/learn-more"),
+			},
+			status: http.StatusOK,
+			want: &api.RealmBrandingResponse{
+				AgencyBackgroundColor:  "#1a73e8",
+				DefaultLocale:          "es-419",
+				UserReportLearnMoreURL: "https://health.example.gov/learn-more",
+				Managed:                true,
+			},
+		},
+		{
+			name: "invalid_color",
+			request: &api.RealmBrandingRequest{
+				AgencyBackgroundColor: stringPtr("blue"),
+			},
+			status: http.StatusBadRequest,
+		},
+		{
+			name: "invalid_locale",
+			request: &api.RealmBrandingRequest{
+				DefaultLocale: stringPtr("not a locale"),
+			},
+			status: http.StatusBadRequest,
+		},
+		{
+			name: "insecure_learn_more_url",
+			request: &api.RealmBrandingRequest{
+				UserReportLearnMoreURL: stringPtr("http://health.example.gov"),
+			},
+			status: http.StatusBadRequest,
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			harness := envstest.NewServerConfig(t, testDatabaseInstance)
+
+			realm, err := harness.Database.FindRealm(1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			app := &database.AuthorizedApp{
+				RealmID:    realm.ID,
+				Name:       "Branding",
+				APIKeyType: database.APIKeyTypeAdmin,
+			}
+			if _, err := realm.CreateAuthorizedApp(harness.Database, app, database.SystemTest); err != nil {
+				t.Fatal(err)
+			}
+
+			c := branding.New(&config.IssueAPIVars{}, harness.Database, harness.Renderer)
+			handler := harness.WithCommonMiddlewares(c.HandleUpdate())
+
+			ctx := controller.WithAuthorizedApp(ctx, app)
+			w, r := envstest.BuildJSONRequest(ctx, t, http.MethodPut, "/", tc.request)
+			handler.ServeHTTP(w, r)
+
+			if got, want := w.Code, tc.status; got != want {
+				t.Fatalf("Expected %d to be %d: %s", got, want, w.Body.String())
+			}
+			if tc.want == nil {
+				return
+			}
+
+			var resp api.RealmBrandingResponse
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tc.want, &resp); diff != "" {
+				t.Errorf("mismatch (-want, +got):\n%s", diff)
+			}
+
+			updated, err := harness.Database.FindRealm(realm.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !updated.AgencyBrandingManaged {
+				t.Errorf("expected branding to be managed")
+			}
+		})
+	}
+}
+
+func TestHandleReset(t *testing.T) {
+	t.Parallel()
+
+	ctx := project.TestContext(t)
+
+	harness := envstest.NewServerConfig(t, testDatabaseInstance)
+
+	realm, err := harness.Database.FindRealm(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	realm.AgencyBackgroundColor = "#000000"
+	realm.AgencyBrandingManaged = true
+	if err := harness.Database.SaveRealm(realm, database.SystemTest); err != nil {
+		t.Fatal(err)
+	}
+
+	app := &database.AuthorizedApp{
+		RealmID:    realm.ID,
+		Name:       "Branding",
+		APIKeyType: database.APIKeyTypeAdmin,
+	}
+	if _, err := realm.CreateAuthorizedApp(harness.Database, app, database.SystemTest); err != nil {
+		t.Fatal(err)
+	}
+
+	c := branding.New(&config.IssueAPIVars{}, harness.Database, harness.Renderer)
+	handler := harness.WithCommonMiddlewares(c.HandleReset())
+
+	ctx = controller.WithAuthorizedApp(ctx, app)
+	w, r := envstest.BuildJSONRequest(ctx, t, http.MethodDelete, "/", nil)
+	handler.ServeHTTP(w, r)
+
+	if got, want := w.Code, http.StatusOK; got != want {
+		t.Fatalf("Expected %d to be %d: %s", got, want, w.Body.String())
+	}
+
+	updated, err := harness.Database.FindRealm(realm.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if updated.AgencyBrandingManaged {
+		t.Errorf("expected branding to not be managed")
+	}
+	if got, want := updated.AgencyBackgroundColor, "#000000"; got != want {
+		t.Errorf("expected %q to be %q", got, want)
+	}
+}
@@ -0,0 +1,58 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package bulkissue processes bulk issue jobs uploaded in the realm UI. Jobs
+// are stored when the file is uploaded and their rows are issued by a
+// scheduled worker, so large uploads complete without the browser.
+package bulkissue
+
+import (
+	"context"
+
+	"github.com/google/exposure-notifications-verification-server/pkg/config"
+	"github.com/google/exposure-notifications-verification-server/pkg/controller/issueapi"
+	"github.com/google/exposure-notifications-verification-server/pkg/database"
+	"github.com/google/exposure-notifications-verification-server/pkg/render"
+)
+
+const lockName = "bulkIssueLock"
+
+// Issuer issues verification codes and sends their SMS messages.
+type Issuer interface {
+	IssueMany(ctx context.Context, requests []*issueapi.IssueRequestInternal) []*issueapi.IssueResult
+}
+
+var _ Issuer = (*issueapi.Controller)(nil)
+
+// Controller is a controller for processing bulk issue jobs.
+type Controller struct {
+	config      *config.BulkIssueConfig
+	batchSize   int
+	maintenance bool
+	db          *database.Database
+	issuer      Issuer
+	h           *render.Renderer
+}
+
+// New creates a new bulk issue worker controller.
+func New(cfg *config.ServerConfig, db *database.Database, issuer Issuer, h *render.Renderer) *Controller {
+	return &Controller{
+		config:      &cfg.BulkIssue,
+		batchSize:   int(cfg.Issue.BatchIssueMaxSize),
+		maintenance: cfg.IsMaintenanceMode(),
+		db:          db,
+		issuer:      issuer,
+		h:           h,
+	}
+}
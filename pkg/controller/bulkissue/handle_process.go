@@ -0,0 +1,282 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bulkissue
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/google/exposure-notifications-server/pkg/logging"
+	"github.com/google/exposure-notifications-verification-server/pkg/api"
+	"github.com/google/exposure-notifications-verification-server/pkg/controller"
+	"github.com/google/exposure-notifications-verification-server/pkg/controller/issueapi"
+	"github.com/google/exposure-notifications-verification-server/pkg/controller/jobstatus"
+	"github.com/google/exposure-notifications-verification-server/pkg/database"
+	"github.com/google/exposure-notifications-verification-server/pkg/observability"
+	"github.com/google/exposure-notifications-verification-server/pkg/rbac"
+	"github.com/hashicorp/go-multierror"
+	"go.opencensus.io/stats"
+)
+
+// progressSaveRows is the number of rows issued between saves of a job's
+// progress. If a run is interrupted, at most this many rows are repeated, and
+// repeated rows reuse their UUID, so they do not issue a second code.
+const progressSaveRows = 100
+
+// outcome is the result of issuing a code for a single row.
+type outcome int
+
+const (
+	outcomeIssued outcome = iota
+	outcomeFailed
+	outcomeRetry
+	outcomeDeferred
+)
+
+// HandleProcess processes pending bulk issue jobs, oldest first.
+func (c *Controller) HandleProcess() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		logger := logging.FromContext(ctx).Named("bulkissue.HandleProcess")
+		logger.Debugw("starting")
+		defer logger.Debugw("finishing")
+
+		if c.maintenance {
+			logger.Debugw("skipping (maintenance mode)")
+			c.h.RenderJSON(w, http.StatusOK, fmt.Errorf("maintenance mode"))
+			return
+		}
+
+		ok, err := c.db.TryLock(ctx, lockName, c.config.MinPeriod)
+		if err != nil {
+			logger.Errorw("failed to acquire lock", "error", err)
+			c.h.RenderJSON(w, http.StatusInternalServerError, err)
+			return
+		}
+		if !ok {
+			logger.Debugw("skipping (too early)")
+			c.h.RenderJSON(w, http.StatusOK, fmt.Errorf("too early"))
+			return
+		}
+
+		jobs, err := c.db.ListPendingBulkIssueJobs(c.config.BatchSize)
+		if err != nil {
+			logger.Errorw("failed to list pending jobs", "error", err)
+			jobstatus.Record(ctx, c.db, jobstatus.JobBulkIssue, 0, err)
+			c.h.RenderJSON(w, http.StatusInternalServerError, err)
+			return
+		}
+
+		// If one job fails, still attempt the others. Jobs that fail here are
+		// attempted again on the next run.
+		var merr *multierror.Error
+		var processed int64
+		for _, job := range jobs {
+			n, err := c.processJob(ctx, job)
+			processed += n
+			if err != nil {
+				merr = multierror.Append(merr, fmt.Errorf("failed to process job %d: %w", job.ID, err))
+			}
+		}
+
+		if err := merr.ErrorOrNil(); err != nil {
+			logger.Errorw("failed to process bulk issue jobs", "error", err)
+			jobstatus.Record(ctx, c.db, jobstatus.JobBulkIssue, processed, err)
+			c.h.RenderJSON(w, http.StatusInternalServerError, err)
+			return
+		}
+
+		jobstatus.Record(ctx, c.db, jobstatus.JobBulkIssue, processed, nil)
+		c.h.RenderJSON(w, http.StatusOK, map[string]interface{}{
+			"jobs":      len(jobs),
+			"processed": processed,
+		})
+	})
+}
+
+// processJob issues codes for up to RowsPerRun pending rows of the job, as the
+// user who uploaded it. It returns the number of rows that were processed. If
+// the job can never be processed, for example because the user was removed
+// from the realm, the job is marked as failed.
+func (c *Controller) processJob(ctx context.Context, job *database.BulkIssueJob) (int64, error) {
+	logger := logging.FromContext(ctx).Named("bulkissue.processJob").
+		With("bulk_issue_job", job.ID).
+		With("realm", job.RealmID)
+	ctx = observability.WithRealmID(ctx, uint64(job.RealmID))
+
+	realm, err := c.db.FindRealm(job.RealmID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to find realm: %w", err)
+	}
+	if !realm.AllowBulkUpload {
+		return 0, c.failJob(job, fmt.Errorf("bulk issuing is not enabled on this realm"))
+	}
+
+	membership, err := c.findMembership(job)
+	if err != nil {
+		if database.IsNotFound(err) {
+			return 0, c.failJob(job, fmt.Errorf("user is no longer a member of this realm"))
+		}
+		return 0, err
+	}
+	if !membership.Can(rbac.CodeBulkIssue) {
+		return 0, c.failJob(job, fmt.Errorf("user is no longer permitted to bulk issue codes"))
+	}
+
+	ctx = controller.WithRealm(ctx, realm)
+	ctx = controller.WithMembership(ctx, membership)
+
+	rows := job.PendingRows(int(c.config.RowsPerRun))
+
+	var processed, issued, failed, retried int64
+	var unsaved int
+	for start := 0; start < len(rows); start += c.batchSize {
+		end := start + c.batchSize
+		if end > len(rows) {
+			end = len(rows)
+		}
+		batch := rows[start:end]
+
+		requests := make([]*issueapi.IssueRequestInternal, 0, len(batch))
+		for _, row := range batch {
+			requests = append(requests, &issueapi.IssueRequestInternal{
+				IssueRequest: buildRequest(job, row),
+			})
+		}
+
+		deferred := false
+		for i, result := range c.issuer.IssueMany(ctx, requests) {
+			switch applyResult(batch[i], result, c.config.MaxAttempts) {
+			case outcomeIssued:
+				processed++
+				issued++
+			case outcomeFailed:
+				processed++
+				failed++
+			case outcomeRetry:
+				retried++
+			case outcomeDeferred:
+				deferred = true
+			}
+		}
+
+		// Quotas reset over time, so stop issuing for this job until the next
+		// run.
+		if deferred {
+			logger.Infow("deferring job, realm or user quota exceeded")
+			break
+		}
+
+		unsaved += len(batch)
+		if unsaved >= progressSaveRows {
+			if err := c.db.SaveBulkIssueJobProgress(job); err != nil {
+				return processed, fmt.Errorf("failed to save progress: %w", err)
+			}
+			unsaved = 0
+		}
+	}
+
+	if err := c.db.SaveBulkIssueJobProgress(job); err != nil {
+		return processed, fmt.Errorf("failed to save progress: %w", err)
+	}
+
+	logger.Infow("processed bulk issue job",
+		"status", job.Status,
+		"issued", issued,
+		"failed", failed,
+		"retried", retried)
+	stats.Record(ctx,
+		mJobs.M(1),
+		mIssued.M(issued),
+		mFailed.M(failed),
+		mRetried.M(retried))
+	return processed, nil
+}
+
+// findMembership finds the membership of the user who uploaded the job in the
+// job's realm.
+func (c *Controller) findMembership(job *database.BulkIssueJob) (*database.Membership, error) {
+	user, err := c.db.FindUser(job.UserID)
+	if err != nil {
+		return nil, err
+	}
+	return user.FindMembership(c.db, job.RealmID)
+}
+
+// failJob marks the job as failed. It returns an error only if the job could
+// not be saved.
+func (c *Controller) failJob(job *database.BulkIssueJob, reason error) error {
+	if err := c.db.FailBulkIssueJob(job, reason); err != nil {
+		return fmt.Errorf("failed to mark job as failed: %w", err)
+	}
+	return nil
+}
+
+// buildRequest builds the issue request for a row of the job.
+func buildRequest(job *database.BulkIssueJob, row *database.BulkIssueJobRow) *api.IssueCodeRequest {
+	return &api.IssueCodeRequest{
+		Phone:            row.Phone,
+		TestDate:         row.TestDate,
+		SymptomDate:      row.SymptomDate,
+		TestType:         row.TestType,
+		UUID:             row.UUID,
+		SMSTemplateLabel: job.SMSTemplateLabel,
+		TZOffset:         job.TZOffset,
+	}
+}
+
+// applyResult records the result of issuing a code on the row.
+//
+// Server errors and SMS failures are retried on later runs until the row has
+// been attempted maxAttempts times. The code is deleted when its SMS fails, so
+// a retry with the same UUID is safe. If the UUID already has a code, an
+// earlier attempt issued it, but the job's progress was not saved.
+//
+// Quota errors leave the row unchanged and are deferred to a later run.
+func applyResult(row *database.BulkIssueJobRow, result *issueapi.IssueResult, maxAttempts uint) outcome {
+	if result.ErrorReturn == nil {
+		row.Status = database.BulkIssueRowIssued
+		row.ErrorCode, row.Error = "", ""
+		if result.VerCode != nil {
+			row.UUID = result.VerCode.UUID
+		}
+		return outcomeIssued
+	}
+
+	code := result.ErrorReturn.ErrorCode
+	switch code {
+	case api.ErrUUIDAlreadyExists:
+		row.Status = database.BulkIssueRowIssued
+		row.ErrorCode, row.Error = "", ""
+		return outcomeIssued
+	case api.ErrQuotaExceeded, api.ErrUserQuotaExceeded, api.ErrAPIKeyQuotaExceeded:
+		return outcomeDeferred
+	}
+
+	row.Attempts++
+	row.ErrorCode = code
+	row.Error = result.ErrorReturn.Error
+
+	retryable := result.HTTPCode >= http.StatusInternalServerError ||
+		code == api.ErrSMSFailure || code == api.ErrSMSQueueFull
+	if retryable && row.Attempts < maxAttempts {
+		return outcomeRetry
+	}
+
+	row.Status = database.BulkIssueRowFailed
+	return outcomeFailed
+}
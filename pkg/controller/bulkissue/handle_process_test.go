@@ -0,0 +1,159 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bulkissue
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/google/exposure-notifications-verification-server/pkg/api"
+	"github.com/google/exposure-notifications-verification-server/pkg/controller/issueapi"
+	"github.com/google/exposure-notifications-verification-server/pkg/database"
+)
+
+func TestApplyResult(t *testing.T) {
+	t.Parallel()
+
+	errorResult := func(code int, errCode string) *issueapi.IssueResult {
+		return &issueapi.IssueResult{
+			HTTPCode:    code,
+			ErrorReturn: api.Errorf("oops").WithCode(errCode),
+		}
+	}
+
+	cases := []struct {
+		name     string
+		attempts uint
+		result   *issueapi.IssueResult
+		outcome  outcome
+		status   string
+		uuid     string
+		errCode  string
+	}{
+		{
+			name: "issued",
+			result: &issueapi.IssueResult{
+				HTTPCode: http.StatusOK,
+				VerCode:  &database.VerificationCode{UUID: "issued-uuid"},
+			},
+			outcome: outcomeIssued,
+			status:  database.BulkIssueRowIssued,
+			uuid:    "issued-uuid",
+		},
+		{
+			name:     "uuid_exists",
+			attempts: 1,
+			result:   errorResult(http.StatusConflict, api.ErrUUIDAlreadyExists),
+			outcome:  outcomeIssued,
+			status:   database.BulkIssueRowIssued,
+			uuid:     "row-uuid",
+		},
+		{
+			name:    "quota",
+			result:  errorResult(http.StatusTooManyRequests, api.ErrQuotaExceeded),
+			outcome: outcomeDeferred,
+			status:  database.BulkIssueRowPending,
+			uuid:    "row-uuid",
+		},
+		{
+			name:    "invalid",
+			result:  errorResult(http.StatusBadRequest, api.ErrInvalidDate),
+			outcome: outcomeFailed,
+			status:  database.BulkIssueRowFailed,
+			uuid:    "row-uuid",
+			errCode: api.ErrInvalidDate,
+		},
+		{
+			name:    "server_error",
+			result:  errorResult(http.StatusInternalServerError, api.ErrInternal),
+			outcome: outcomeRetry,
+			status:  database.BulkIssueRowPending,
+			uuid:    "row-uuid",
+			errCode: api.ErrInternal,
+		},
+		{
+			name:    "sms_failure",
+			result:  errorResult(http.StatusBadRequest, api.ErrSMSFailure),
+			outcome: outcomeRetry,
+			status:  database.BulkIssueRowPending,
+			uuid:    "row-uuid",
+			errCode: api.ErrSMSFailure,
+		},
+		{
+			name:     "sms_failure_exhausted",
+			attempts: 2,
+			result:   errorResult(http.StatusBadRequest, api.ErrSMSFailure),
+			outcome:  outcomeFailed,
+			status:   database.BulkIssueRowFailed,
+			uuid:     "row-uuid",
+			errCode:  api.ErrSMSFailure,
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			row := &database.BulkIssueJobRow{
+				UUID:     "row-uuid",
+				Status:   database.BulkIssueRowPending,
+				Attempts: tc.attempts,
+			}
+
+			if got, want := applyResult(row, tc.result, 3), tc.outcome; got != want {
+				t.Errorf("expected %d to be %d", got, want)
+			}
+			if got, want := row.Status, tc.status; got != want {
+				t.Errorf("expected %q to be %q", got, want)
+			}
+			if got, want := row.UUID, tc.uuid; got != want {
+				t.Errorf("expected %q to be %q", got, want)
+			}
+			if got, want := row.ErrorCode, tc.errCode; got != want {
+				t.Errorf("expected %q to be %q", got, want)
+			}
+		})
+	}
+}
+
+func TestBuildRequest(t *testing.T) {
+	t.Parallel()
+
+	job := &database.BulkIssueJob{
+		SMSTemplateLabel: "Spanish",
+		TZOffset:         -420,
+	}
+	row := &database.BulkIssueJobRow{
+		Phone:       "+12065550100",
+		TestDate:    "2022-11-01",
+		SymptomDate: "2022-10-30",
+		TestType:    "likely",
+		UUID:        "row-uuid",
+	}
+
+	got := buildRequest(job, row)
+	if got.Phone != row.Phone || got.TestDate != row.TestDate || got.SymptomDate != row.SymptomDate ||
+		got.TestType != row.TestType || got.UUID != row.UUID {
+		t.Errorf("expected row values, got %#v", got)
+	}
+	if got, want := got.SMSTemplateLabel, job.SMSTemplateLabel; got != want {
+		t.Errorf("expected %q to be %q", got, want)
+	}
+	if got, want := got.TZOffset, job.TZOffset; got != want {
+		t.Errorf("expected %v to be %v", got, want)
+	}
+}
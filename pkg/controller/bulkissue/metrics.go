@@ -0,0 +1,65 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bulkissue
+
+import (
+	enobs "github.com/google/exposure-notifications-server/pkg/observability"
+	"github.com/google/exposure-notifications-verification-server/pkg/observability"
+
+	"go.opencensus.io/stats"
+	"go.opencensus.io/stats/view"
+)
+
+const metricPrefix = observability.MetricRoot + "/bulk_issue"
+
+var (
+	mJobs    = stats.Int64(metricPrefix+"/jobs", "bulk issue jobs processed", stats.UnitDimensionless)
+	mIssued  = stats.Int64(metricPrefix+"/issued", "codes issued by bulk issue jobs", stats.UnitDimensionless)
+	mFailed  = stats.Int64(metricPrefix+"/failed", "bulk issue rows that permanently failed", stats.UnitDimensionless)
+	mRetried = stats.Int64(metricPrefix+"/retried", "bulk issue rows that will be retried", stats.UnitDimensionless)
+)
+
+func init() {
+	enobs.CollectViews([]*view.View{
+		{
+			Name:        metricPrefix + "/jobs",
+			Description: "Number of bulk issue jobs processed",
+			TagKeys:     observability.CommonTagKeys(),
+			Measure:     mJobs,
+			Aggregation: view.Sum(),
+		},
+		{
+			Name:        metricPrefix + "/issued",
+			Description: "Number of codes issued by bulk issue jobs",
+			TagKeys:     observability.CommonTagKeys(),
+			Measure:     mIssued,
+			Aggregation: view.Sum(),
+		},
+		{
+			Name:        metricPrefix + "/failed",
+			Description: "Number of bulk issue rows that permanently failed",
+			TagKeys:     observability.CommonTagKeys(),
+			Measure:     mFailed,
+			Aggregation: view.Sum(),
+		},
+		{
+			Name:        metricPrefix + "/retried",
+			Description: "Number of bulk issue rows that failed and will be retried",
+			TagKeys:     observability.CommonTagKeys(),
+			Measure:     mRetried,
+			Aggregation: view.Sum(),
+		},
+	}...)
+}
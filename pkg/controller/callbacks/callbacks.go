@@ -0,0 +1,49 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package callbacks delivers queued notifications to API key callback URLs.
+package callbacks
+
+import (
+	"net/http"
+
+	"github.com/google/exposure-notifications-verification-server/internal/project"
+	"github.com/google/exposure-notifications-verification-server/pkg/config"
+	"github.com/google/exposure-notifications-verification-server/pkg/database"
+	"github.com/google/exposure-notifications-verification-server/pkg/render"
+)
+
+// Controller is a controller for the callback delivery worker.
+type Controller struct {
+	config     *config.CallbackConfig
+	db         *database.Database
+	httpClient *http.Client
+	h          *render.Renderer
+}
+
+// New creates a new callback delivery controller.
+func New(cfg *config.CallbackConfig, db *database.Database, h *render.Renderer) *Controller {
+	// Callback URLs are customer servers, so do not propagate trace headers.
+	httpClient := &http.Client{
+		Timeout:   cfg.Timeout,
+		Transport: project.DefaultHTTPTransport(),
+	}
+
+	return &Controller{
+		config:     cfg,
+		db:         db,
+		httpClient: httpClient,
+		h:          h,
+	}
+}
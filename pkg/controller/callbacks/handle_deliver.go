@@ -0,0 +1,124 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package callbacks
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/google/exposure-notifications-server/pkg/logging"
+	"github.com/google/exposure-notifications-verification-server/pkg/controller/jobstatus"
+	"github.com/google/exposure-notifications-verification-server/pkg/database"
+	"github.com/hashicorp/go-multierror"
+	"go.opencensus.io/stats"
+)
+
+// HandleDeliver attempts all due callback deliveries. Failed deliveries are
+// rescheduled with backoff by the database, so a failure here only affects
+// the job status.
+func (c *Controller) HandleDeliver() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		logger := logging.FromContext(ctx).Named("callbacks.HandleDeliver")
+		logger.Debugw("starting")
+		defer logger.Debugw("finishing")
+
+		deliveries, err := c.db.ClaimCallbackDeliveries(c.config.BatchSize, c.config.Lease)
+		if err != nil {
+			logger.Errorw("failed to claim deliveries", "error", err)
+			jobstatus.Record(ctx, c.db, jobstatus.JobCallbacks, 0, err)
+			c.h.RenderJSON(w, http.StatusInternalServerError, err)
+			return
+		}
+
+		// Many deliveries usually share an app, and the lookup decrypts the
+		// callback secret.
+		apps := make(map[uint]*database.AuthorizedApp)
+
+		var merr *multierror.Error
+		var delivered int64
+		for _, delivery := range deliveries {
+			app, ok := apps[delivery.AuthorizedAppID]
+			if !ok {
+				app, err = c.db.FindAuthorizedApp(delivery.AuthorizedAppID)
+				if err != nil && !database.IsNotFound(err) {
+					merr = multierror.Append(merr, fmt.Errorf("failed to find authorized app %d: %w", delivery.AuthorizedAppID, err))
+					continue
+				}
+				apps[delivery.AuthorizedAppID] = app
+			}
+
+			if err := c.deliver(ctx, app, delivery); err != nil {
+				merr = multierror.Append(merr, err)
+				continue
+			}
+			delivered++
+		}
+
+		if err := merr.ErrorOrNil(); err != nil {
+			logger.Errorw("failed to record deliveries", "error", err)
+			jobstatus.Record(ctx, c.db, jobstatus.JobCallbacks, delivered, err)
+			c.h.RenderJSON(w, http.StatusInternalServerError, err)
+			return
+		}
+
+		jobstatus.Record(ctx, c.db, jobstatus.JobCallbacks, delivered, nil)
+		c.h.RenderJSON(w, http.StatusOK, map[string]interface{}{
+			"claimed":   len(deliveries),
+			"delivered": delivered,
+		})
+	})
+}
+
+// deliver sends a single delivery and records the outcome. It only returns an
+// error if the outcome could not be recorded.
+func (c *Controller) deliver(ctx context.Context, app *database.AuthorizedApp, delivery *database.CallbackDelivery) error {
+	logger := logging.FromContext(ctx).Named("callbacks.deliver").
+		With("delivery", delivery.ID).
+		With("authorized_app", delivery.AuthorizedAppID)
+
+	// The API key was deleted or its callback was removed after the delivery was
+	// queued.
+	if app == nil || app.CallbackURL == "" {
+		stats.Record(ctx, mAbandoned.M(1))
+		if err := c.db.FailCallbackDelivery(delivery, nil, fmt.Errorf("callback is no longer configured"), false); err != nil {
+			return fmt.Errorf("failed to record delivery %d: %w", delivery.ID, err)
+		}
+		return nil
+	}
+
+	attempt, sendErr := sendCallbackRequest(ctx, c.httpClient, app, delivery, time.Now().UTC())
+	if sendErr == nil {
+		stats.Record(ctx, mDelivered.M(1))
+		if err := c.db.CompleteCallbackDelivery(delivery, attempt); err != nil {
+			return fmt.Errorf("failed to record delivery %d: %w", delivery.ID, err)
+		}
+		return nil
+	}
+
+	logger.Warnw("failed to deliver callback", "attempt", delivery.Attempts+1, "error", sendErr)
+	stats.Record(ctx, mFailed.M(1))
+
+	if err := c.db.FailCallbackDelivery(delivery, attempt, sendErr, retryable(sendErr)); err != nil {
+		return fmt.Errorf("failed to record delivery %d: %w", delivery.ID, err)
+	}
+	if delivery.FailedAt != nil {
+		stats.Record(ctx, mAbandoned.M(1))
+	}
+	return nil
+}
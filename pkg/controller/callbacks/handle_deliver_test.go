@@ -0,0 +1,191 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package callbacks
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/exposure-notifications-verification-server/internal/envstest"
+	"github.com/google/exposure-notifications-verification-server/internal/project"
+	"github.com/google/exposure-notifications-verification-server/pkg/config"
+	"github.com/google/exposure-notifications-verification-server/pkg/database"
+	"github.com/google/exposure-notifications-verification-server/pkg/render"
+)
+
+func TestHandleDeliver(t *testing.T) {
+	t.Parallel()
+
+	ctx := project.TestContext(t)
+
+	h, err := render.New(ctx, nil, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := &config.CallbackConfig{
+		BatchSize: 10,
+		Timeout:   2 * time.Second,
+		Lease:     time.Minute,
+	}
+
+	// setup creates an API key with a callback to a server that responds with
+	// the given status, and queues a delivery for it. Callback URLs must be
+	// https, so the controller is updated to trust the test server.
+	setup := func(tb testing.TB, db *database.Database, c *Controller, status int) (*database.CallbackDelivery, *int32) {
+		tb.Helper()
+
+		var calls int32
+		srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(&calls, 1)
+			w.WriteHeader(status)
+		}))
+		tb.Cleanup(func() {
+			srv.Close()
+		})
+		c.httpClient = srv.Client()
+
+		realm, err := db.FindRealm(1)
+		if err != nil {
+			tb.Fatal(err)
+		}
+
+		app := &database.AuthorizedApp{
+			Name:           "callbacks",
+			APIKeyType:     database.APIKeyTypeAdmin,
+			CallbackURL:    srv.URL,
+			CallbackSecret: "super-secret-value",
+		}
+		if _, err := realm.CreateAuthorizedApp(db, app, database.SystemTest); err != nil {
+			tb.Fatal(err)
+		}
+
+		delivery, err := db.EnqueueCallbackDelivery(app.ID, []byte(`{"event":"batch_issue.completed"}`))
+		if err != nil {
+			tb.Fatal(err)
+		}
+		return delivery, &calls
+	}
+
+	// reload returns the current state of the delivery.
+	reload := func(tb testing.TB, db *database.Database, id uint) *database.CallbackDelivery {
+		tb.Helper()
+
+		var delivery database.CallbackDelivery
+		if err := db.RawDB().Where("id = ?", id).First(&delivery).Error; err != nil {
+			tb.Fatal(err)
+		}
+		return &delivery
+	}
+
+	invoke := func(tb testing.TB, c *Controller) {
+		tb.Helper()
+
+		w, r := envstest.BuildJSONRequest(ctx, tb, http.MethodGet, "/", nil)
+		c.HandleDeliver().ServeHTTP(w, r)
+		if got, want := w.Code, http.StatusOK; got != want {
+			tb.Fatalf("expected %d to be %d: %s", got, want, w.Body.String())
+		}
+	}
+
+	t.Run("delivers", func(t *testing.T) {
+		t.Parallel()
+
+		db, _ := testDatabaseInstance.NewDatabase(t, nil)
+		c := New(cfg, db, h)
+
+		delivery, calls := setup(t, db, c, http.StatusOK)
+		invoke(t, c)
+
+		if got, want := atomic.LoadInt32(calls), int32(1); got != want {
+			t.Errorf("expected %d calls, got %d", want, got)
+		}
+		if got := reload(t, db, delivery.ID); got.DeliveredAt == nil {
+			t.Errorf("expected delivery to be delivered")
+		}
+
+		// A delivered callback is not sent again.
+		invoke(t, c)
+		if got, want := atomic.LoadInt32(calls), int32(1); got != want {
+			t.Errorf("expected %d calls, got %d", want, got)
+		}
+	})
+
+	t.Run("retries", func(t *testing.T) {
+		t.Parallel()
+
+		db, _ := testDatabaseInstance.NewDatabase(t, nil)
+		c := New(cfg, db, h)
+
+		delivery, _ := setup(t, db, c, http.StatusServiceUnavailable)
+		invoke(t, c)
+
+		got := reload(t, db, delivery.ID)
+		if got.DeliveredAt != nil || got.FailedAt != nil {
+			t.Errorf("expected delivery to still be pending")
+		}
+		if got, want := got.Attempts, 1; got != want {
+			t.Errorf("expected %d attempts, got %d", want, got)
+		}
+		if !got.NextAttemptAt.After(time.Now()) {
+			t.Errorf("expected next attempt to be scheduled in the future")
+		}
+	})
+
+	t.Run("abandons_client_errors", func(t *testing.T) {
+		t.Parallel()
+
+		db, _ := testDatabaseInstance.NewDatabase(t, nil)
+		c := New(cfg, db, h)
+
+		delivery, _ := setup(t, db, c, http.StatusGone)
+		invoke(t, c)
+
+		if got := reload(t, db, delivery.ID); got.FailedAt == nil {
+			t.Errorf("expected delivery to be abandoned")
+		}
+	})
+
+	t.Run("abandons_removed_callbacks", func(t *testing.T) {
+		t.Parallel()
+
+		db, _ := testDatabaseInstance.NewDatabase(t, nil)
+		c := New(cfg, db, h)
+
+		delivery, calls := setup(t, db, c, http.StatusOK)
+
+		app, err := db.FindAuthorizedApp(delivery.AuthorizedAppID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		app.CallbackURL = ""
+		app.CallbackSecret = ""
+		if err := db.SaveAuthorizedApp(app, database.SystemTest); err != nil {
+			t.Fatal(err)
+		}
+
+		invoke(t, c)
+
+		if got, want := atomic.LoadInt32(calls), int32(0); got != want {
+			t.Errorf("expected %d calls, got %d", want, got)
+		}
+		if got := reload(t, db, delivery.ID); got.FailedAt == nil {
+			t.Errorf("expected delivery to be abandoned")
+		}
+	})
+}
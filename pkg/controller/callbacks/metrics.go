@@ -0,0 +1,57 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package callbacks
+
+import (
+	enobs "github.com/google/exposure-notifications-server/pkg/observability"
+	"github.com/google/exposure-notifications-verification-server/pkg/observability"
+
+	"go.opencensus.io/stats"
+	"go.opencensus.io/stats/view"
+)
+
+const metricPrefix = observability.MetricRoot + "/callbacks"
+
+var (
+	mDelivered = stats.Int64(metricPrefix+"/delivered", "callbacks delivered", stats.UnitDimensionless)
+	mFailed    = stats.Int64(metricPrefix+"/failed", "callback delivery attempts that failed", stats.UnitDimensionless)
+	mAbandoned = stats.Int64(metricPrefix+"/abandoned", "callbacks that will not be retried", stats.UnitDimensionless)
+)
+
+func init() {
+	enobs.CollectViews([]*view.View{
+		{
+			Name:        metricPrefix + "/delivered_count",
+			Description: "Number of callbacks delivered",
+			TagKeys:     observability.CommonTagKeys(),
+			Measure:     mDelivered,
+			Aggregation: view.Sum(),
+		},
+		{
+			Name:        metricPrefix + "/failed_count",
+			Description: "Number of failed callback delivery attempts",
+			TagKeys:     observability.CommonTagKeys(),
+			Measure:     mFailed,
+			Aggregation: view.Sum(),
+		},
+		{
+			Name:        metricPrefix + "/abandoned_count",
+			Description: "Number of callbacks abandoned without delivery",
+			TagKeys:     observability.CommonTagKeys(),
+			Measure:     mAbandoned,
+			Aggregation: view.Sum(),
+		},
+	}...)
+}
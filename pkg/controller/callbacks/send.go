@@ -0,0 +1,162 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package callbacks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha512"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/exposure-notifications-verification-server/pkg/database"
+)
+
+const (
+	// HeaderSignature is the hex-encoded SHA-512 HMAC of the timestamp and body.
+	HeaderSignature = "X-Signature"
+
+	// HeaderTimestamp is the unix timestamp at which the request was signed.
+	HeaderTimestamp = "X-Signature-Timestamp"
+
+	// HeaderDeliveryID uniquely identifies the delivery. It is the same across
+	// retries so receivers can discard duplicates.
+	HeaderDeliveryID = "X-Delivery-ID"
+
+	// maxCapturedBody is the maximum number of response body bytes that are
+	// captured for the delivery log.
+	maxCapturedBody = 4096
+)
+
+// redactedHeaders are the headers whose values are never captured.
+var redactedHeaders = map[string]struct{}{
+	"Authorization":       {},
+	"Cookie":              {},
+	"Proxy-Authorization": {},
+	"Set-Cookie":          {},
+	HeaderSignature:       {},
+}
+
+// statusError is returned when the callback URL responds with a non-2xx
+// status.
+type statusError struct {
+	code int
+	body []byte
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("unsuccessful response from callback (%d): %s", e.code, e.body)
+}
+
+// retryable returns true if the delivery should be attempted again after err.
+// Client errors other than timeouts and rate limits are not retried, since
+// they will not succeed without the receiver changing.
+func retryable(err error) bool {
+	serr, ok := err.(*statusError)
+	if !ok {
+		return true
+	}
+	switch serr.code {
+	case http.StatusRequestTimeout, http.StatusTooManyRequests:
+		return true
+	}
+	return serr.code >= 500
+}
+
+// sendCallbackRequest sends the delivery's payload to the authorized app's
+// callback URL. It returns a redacted capture of the attempt, which is never
+// nil, and an error if the attempt failed.
+func sendCallbackRequest(ctx context.Context, client *http.Client, app *database.AuthorizedApp, delivery *database.CallbackDelivery, now time.Time) (*database.CallbackDeliveryAttempt, error) {
+	attempt := &database.CallbackDeliveryAttempt{
+		URL: app.CallbackURL,
+	}
+
+	body := []byte(delivery.Payload)
+	timestamp := strconv.FormatInt(now.Unix(), 10)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, app.CallbackURL, bytes.NewReader(body))
+	if err != nil {
+		return attempt, fmt.Errorf("failed to build callback request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderTimestamp, timestamp)
+	req.Header.Set(HeaderSignature, signCallbackPayload(app.CallbackSecret, timestamp, body))
+	req.Header.Set(HeaderDeliveryID, strconv.FormatUint(uint64(delivery.ID), 10))
+	attempt.RequestHeaders = captureHeaders(req.Header)
+
+	start := time.Now()
+	resp, err := client.Do(req)
+	attempt.DurationMs = time.Since(start).Milliseconds()
+	if err != nil {
+		return attempt, fmt.Errorf("failed to issue callback request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	b, _ := io.ReadAll(io.LimitReader(resp.Body, maxCapturedBody))
+	attempt.ResponseStatus = resp.StatusCode
+	attempt.ResponseHeaders = captureHeaders(resp.Header)
+	attempt.ResponseBody = captureBody(b)
+
+	if code := resp.StatusCode; code < 200 || code > 299 {
+		return attempt, &statusError{code: code, body: b}
+	}
+	return attempt, nil
+}
+
+// captureHeaders formats the headers for the delivery log, one "Name: value"
+// per line, sorted by name. Credentials and signatures are redacted.
+func captureHeaders(h http.Header) string {
+	names := make([]string, 0, len(h))
+	for name := range h {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	for _, name := range names {
+		for _, v := range h[name] {
+			if _, ok := redactedHeaders[http.CanonicalHeaderKey(name)]; ok {
+				v = "REDACTED"
+			}
+			fmt.Fprintf(&b, "%s: %s\n", name, v)
+		}
+	}
+	return b.String()
+}
+
+// captureBody converts the response body for storage, dropping bytes that
+// cannot be stored as text.
+func captureBody(b []byte) string {
+	s := strings.ToValidUTF8(string(b), "")
+	return strings.ReplaceAll(s, "\x00", "")
+}
+
+// signCallbackPayload returns the hex-encoded SHA-512 HMAC of
+// "<timestamp>.<body>" using the given secret. Including the timestamp lets
+// receivers reject replayed requests.
+func signCallbackPayload(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha512.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,164 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package callbacks
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/exposure-notifications-verification-server/internal/project"
+	"github.com/google/exposure-notifications-verification-server/pkg/database"
+)
+
+func TestSendCallbackRequest(t *testing.T) {
+	t.Parallel()
+
+	ctx := project.TestContext(t)
+	client := &http.Client{Timeout: 2 * time.Second}
+	now := time.Unix(1667347200, 0)
+
+	app := &database.AuthorizedApp{
+		CallbackSecret: "super-secret-value",
+	}
+	delivery := &database.CallbackDelivery{
+		ID:      42,
+		Payload: `{"event":"batch_issue.completed"}`,
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer r.Body.Close()
+
+		b, err := io.ReadAll(r.Body)
+		if err != nil {
+			t.Errorf("failed to read body: %s", err)
+		}
+		if got, want := string(b), delivery.Payload; got != want {
+			t.Errorf("expected %q to be %q", got, want)
+		}
+
+		if got, want := r.Header.Get(HeaderTimestamp), "1667347200"; got != want {
+			t.Errorf("expected timestamp %q to be %q", got, want)
+		}
+		if got, want := r.Header.Get(HeaderDeliveryID), "42"; got != want {
+			t.Errorf("expected delivery id %q to be %q", got, want)
+		}
+
+		mac := signCallbackPayload(app.CallbackSecret, r.Header.Get(HeaderTimestamp), b)
+		if got, want := r.Header.Get(HeaderSignature), mac; got != want {
+			t.Errorf("expected signature %q to be %q", got, want)
+		}
+
+		w.Header().Set("Set-Cookie", "session=abc")
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	t.Cleanup(func() {
+		srv.Close()
+	})
+	app.CallbackURL = srv.URL
+
+	attempt, err := sendCallbackRequest(ctx, client, app, delivery, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := attempt.URL, srv.URL; got != want {
+		t.Errorf("expected url %q to be %q", got, want)
+	}
+	if got, want := attempt.ResponseStatus, http.StatusNoContent; got != want {
+		t.Errorf("expected status %d to be %d", got, want)
+	}
+	if !strings.Contains(attempt.RequestHeaders, HeaderSignature+": REDACTED") {
+		t.Errorf("expected signature to be redacted in %q", attempt.RequestHeaders)
+	}
+	if strings.Contains(attempt.ResponseHeaders, "session=abc") {
+		t.Errorf("expected cookie to be redacted in %q", attempt.ResponseHeaders)
+	}
+
+	t.Run("error_response", func(t *testing.T) {
+		t.Parallel()
+
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusGone)
+			fmt.Fprint(w, "no such hook")
+		}))
+		t.Cleanup(func() {
+			srv.Close()
+		})
+
+		app := &database.AuthorizedApp{
+			CallbackURL:    srv.URL,
+			CallbackSecret: "super-secret-value",
+		}
+		attempt, err := sendCallbackRequest(ctx, client, app, delivery, now)
+		if err == nil {
+			t.Fatal("expected error")
+		}
+		if retryable(err) {
+			t.Errorf("expected %s to not be retryable", err)
+		}
+		if got, want := attempt.ResponseBody, "no such hook"; got != want {
+			t.Errorf("expected body %q to be %q", got, want)
+		}
+	})
+}
+
+func TestSignCallbackPayload(t *testing.T) {
+	t.Parallel()
+
+	body := []byte(`{"a":1}`)
+	sig := signCallbackPayload("secret", "100", body)
+
+	// The timestamp is part of the signature, so a replayed body with a new
+	// timestamp does not verify.
+	if other := signCallbackPayload("secret", "101", body); other == sig {
+		t.Errorf("expected signature to depend on the timestamp")
+	}
+	if other := signCallbackPayload("other", "100", body); other == sig {
+		t.Errorf("expected signature to depend on the secret")
+	}
+}
+
+func TestRetryable(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"network", fmt.Errorf("connection refused"), true},
+		{"server_error", &statusError{code: http.StatusBadGateway}, true},
+		{"rate_limited", &statusError{code: http.StatusTooManyRequests}, true},
+		{"timeout", &statusError{code: http.StatusRequestTimeout}, true},
+		{"not_found", &statusError{code: http.StatusNotFound}, false},
+		{"unauthorized", &statusError{code: http.StatusUnauthorized}, false},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			if got, want := retryable(tc.err), tc.want; got != want {
+				t.Errorf("expected %t to be %t", got, want)
+			}
+		})
+	}
+}
@@ -22,6 +22,7 @@ import (
 	vcache "github.com/google/exposure-notifications-verification-server/pkg/cache"
 	"github.com/google/exposure-notifications-verification-server/pkg/config"
 	"github.com/google/exposure-notifications-verification-server/pkg/database"
+	"github.com/google/exposure-notifications-verification-server/pkg/jwthelper"
 	"github.com/google/exposure-notifications-verification-server/pkg/keyutils"
 	"github.com/google/exposure-notifications-verification-server/pkg/render"
 
@@ -41,6 +42,8 @@ type Controller struct {
 	pubKeyCache *keyutils.PublicKeyCache  // Cache of public keys for verification token verification.
 	signerCache *cache.Cache[*SignerInfo] // Cache signers on a per-realm basis.
 	kms         keys.KeyManager
+
+	claimsProviders []ClaimsProvider // Providers of additional certificate claims.
 }
 
 func New(ctx context.Context, config *config.APIServerConfig, db *database.Database, cacher vcache.Cacher, kms keys.KeyManager, h *render.Renderer) (*Controller, error) {
@@ -92,6 +95,16 @@ func (c *Controller) validateToken(ctx context.Context, verToken string) (string
 		if err != nil {
 			return nil, fmt.Errorf("failed to find public key for kid %q: %w", kid, err)
 		}
+
+		// Ensure the token's algorithm matches the key's algorithm to prevent
+		// algorithm substitution.
+		alg, err := jwthelper.AlgorithmForKey(publicKey)
+		if err != nil {
+			return nil, fmt.Errorf("unsupported public key for kid %q: %w", kid, err)
+		}
+		if got := token.Method.Alg(); got != alg {
+			return nil, fmt.Errorf("token algorithm %q does not match key algorithm %q", got, alg)
+		}
 		return publicKey, nil
 	})
 	if err != nil {
@@ -15,11 +15,11 @@
 package certapi
 
 import (
+	"context"
 	"errors"
 	"net/http"
 	"time"
 
-	"github.com/golang-jwt/jwt"
 	"github.com/google/exposure-notifications-server/pkg/base64util"
 	"github.com/google/exposure-notifications-server/pkg/logging"
 	enobs "github.com/google/exposure-notifications-server/pkg/observability"
@@ -35,21 +35,27 @@ const (
 	HMACLength = 32
 )
 
+// CertificateResult is the outcome of exchanging a verification token for a
+// verification certificate. If ErrorReturn is non-nil, the exchange failed and
+// HTTPCode is the status code to return to the client.
+type CertificateResult struct {
+	Response    *api.VerificationCertificateResponse
+	ErrorReturn *api.ErrorReturn
+	HTTPCode    int
+}
+
 func (c *Controller) HandleCertificate() http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		ctx := r.Context()
 
 		logger := logging.FromContext(ctx).Named("certapi.HandleCertificate")
 
-		blame := enobs.BlameNone
-		result := enobs.ResultOK
-		defer enobs.RecordLatency(ctx, time.Now(), mLatencyMs, &blame, &result)
-
 		authApp := controller.AuthorizedAppFromContext(ctx)
 		if authApp == nil {
 			logger.Errorf("missing authorized app")
-			blame = enobs.BlameClient
-			result = enobs.ResultError("MISSING_AUTHORIZED_APP")
+			blame := enobs.BlameClient
+			result := enobs.ResultError("MISSING_AUTHORIZED_APP")
+			enobs.RecordLatency(ctx, time.Now(), mLatencyMs, &blame, &result)
 
 			controller.MissingAuthorizedApp(w, r, c.h)
 			return
@@ -58,116 +64,184 @@ func (c *Controller) HandleCertificate() http.Handler {
 		var request api.VerificationCertificateRequest
 		if err := controller.BindJSON(w, r, &request); err != nil {
 			logger.Errorw("failed to parse json request", "error", err)
-			blame = enobs.BlameClient
-			result = enobs.ResultError("FAILED_TO_PARSE_JSON_REQUEST")
+			blame := enobs.BlameClient
+			result := enobs.ResultError("FAILED_TO_PARSE_JSON_REQUEST")
+			enobs.RecordLatency(ctx, time.Now(), mLatencyMs, &blame, &result)
 
 			c.h.RenderJSON(w, http.StatusBadRequest, api.Error(err).WithCode(api.ErrTokenInvalid))
 			return
 		}
 
-		// Parse and validate the verification token.
-		tokenID, subject, err := c.validateToken(ctx, request.VerificationToken)
-		if err != nil {
-			blame = enobs.BlameClient
-			result = enobs.ResultError("FAILED_TO_VALIDATE_TOKEN")
-
-			c.h.RenderJSON(w, http.StatusBadRequest, api.Error(err).WithCode(api.ErrTokenInvalid))
+		result := c.Certificate(ctx, authApp, &request)
+		if result.ErrorReturn != nil {
+			c.h.RenderJSON(w, result.HTTPCode, result.ErrorReturn)
 			return
 		}
+		c.h.RenderJSON(w, http.StatusOK, result.Response)
+	})
+}
 
-		// Validate the HMAC length. SHA 256 HMAC must be 32 bytes in length.
-		hmacBytes, err := base64util.DecodeString(request.ExposureKeyHMAC)
-		if err != nil {
-			logger.Debugw("provided invalid hmac, not base64", "error", err)
-			blame = enobs.BlameClient
-			result = enobs.ResultError("FAILED_TO_DECODE_HMAC")
-
-			c.h.RenderJSON(w, http.StatusBadRequest,
-				api.Errorf("exposure key HMAC is not a valid base64: %v", err).WithCode(api.ErrHMACInvalid))
-			return
+// Certificate validates the verification token and exposure key HMAC in the
+// request and issues a signed verification certificate. It is shared by the
+// JSON and gRPC device APIs, which are responsible for authenticating the
+// authorized app.
+func (c *Controller) Certificate(ctx context.Context, authApp *database.AuthorizedApp, request *api.VerificationCertificateRequest) *CertificateResult {
+	logger := logging.FromContext(ctx).Named("certapi.Certificate")
+
+	blame := enobs.BlameNone
+	result := enobs.ResultOK
+	defer enobs.RecordLatency(ctx, time.Now(), mLatencyMs, &blame, &result)
+
+	// Parse and validate the verification token.
+	tokenID, subject, err := c.validateToken(ctx, request.VerificationToken)
+	if err != nil {
+		blame = enobs.BlameClient
+		result = enobs.ResultError("FAILED_TO_VALIDATE_TOKEN")
+
+		return &CertificateResult{
+			HTTPCode:    http.StatusBadRequest,
+			ErrorReturn: api.Error(err).WithCode(api.ErrTokenInvalid),
 		}
-		if l := len(hmacBytes); l != HMACLength {
-			logger.Debugw("provided invalid hmac, wrong length", "length", l)
-			blame = enobs.BlameClient
-			result = enobs.ResultError("INVALID_HMAC_LENGTH")
-
-			c.h.RenderJSON(w, http.StatusBadRequest,
-				api.Errorf("exposure key HMAC is not the correct length, want: %v got: %v", HMACLength, l).WithCode(api.ErrHMACInvalid))
-			return
+	}
+
+	// Validate the HMAC length. SHA 256 HMAC must be 32 bytes in length.
+	hmacBytes, err := base64util.DecodeString(request.ExposureKeyHMAC)
+	if err != nil {
+		logger.Debugw("provided invalid hmac, not base64", "error", err)
+		blame = enobs.BlameClient
+		result = enobs.ResultError("FAILED_TO_DECODE_HMAC")
+
+		return &CertificateResult{
+			HTTPCode:    http.StatusBadRequest,
+			ErrorReturn: api.Errorf("exposure key HMAC is not a valid base64: %v", err).WithCode(api.ErrHMACInvalid),
 		}
-
-		// determine the correct signing key to use.
-		signerInfo, err := c.getSignerForAuthApp(ctx, authApp)
-		if err != nil {
-			logger.Errorw("failed to get signer", "error", err)
-			// FIXME: should we blame server here?
-			blame = enobs.BlameServer
-			result = enobs.ResultError("FAILED_TO_GET_SIGNER")
-
-			c.h.RenderJSON(w, http.StatusInternalServerError, api.InternalError())
-			return
+	}
+	if l := len(hmacBytes); l != HMACLength {
+		logger.Debugw("provided invalid hmac, wrong length", "length", l)
+		blame = enobs.BlameClient
+		result = enobs.ResultError("INVALID_HMAC_LENGTH")
+
+		return &CertificateResult{
+			HTTPCode:    http.StatusBadRequest,
+			ErrorReturn: api.Errorf("exposure key HMAC is not the correct length, want: %v got: %v", HMACLength, l).WithCode(api.ErrHMACInvalid),
 		}
-
-		// Create the Certificate
-		now := time.Now().UTC()
-		claims := verifyapi.NewVerificationClaims()
-		// Assign the report type.
-		claims.ReportType = subject.TestType
-		if subject.SymptomDate != nil {
-			claims.SymptomOnsetInterval = subject.SymptomInterval()
+	}
+
+	// determine the correct signing key to use.
+	signerInfo, err := c.getSignerForAuthApp(ctx, authApp)
+	if err != nil {
+		logger.Errorw("failed to get signer", "error", err)
+		// FIXME: should we blame server here?
+		blame = enobs.BlameServer
+		result = enobs.ResultError("FAILED_TO_GET_SIGNER")
+
+		return &CertificateResult{
+			HTTPCode:    http.StatusInternalServerError,
+			ErrorReturn: api.InternalError(),
 		}
-
-		issueTime := now.Add(-1 * c.config.CertificateSigning.AllowedClockSkew).Unix()
-		claims.SignedMAC = request.ExposureKeyHMAC
-		claims.StandardClaims.Audience = signerInfo.Audience
-		claims.StandardClaims.Issuer = signerInfo.Issuer
-		claims.StandardClaims.IssuedAt = issueTime
-		claims.StandardClaims.ExpiresAt = now.Add(signerInfo.Duration).Unix()
-		claims.StandardClaims.NotBefore = issueTime
-
-		certToken := jwt.NewWithClaims(jwt.SigningMethodES256, claims)
-		certToken.Header[verifyapi.KeyIDHeader] = signerInfo.KeyID
-		certificate, err := jwthelper.SignJWT(certToken, signerInfo.Signer)
-		if err != nil {
-			logger.Errorw("failed to sign certificate", "error", err)
-			blame = enobs.BlameServer
-			result = enobs.ResultError("FAILED_TO_SIGN_JWT")
-
-			c.h.RenderJSON(w, http.StatusInternalServerError, api.Error(err).WithCode(api.ErrInternal))
-			return
+	}
+
+	// Create the Certificate
+	now := time.Now().UTC()
+	claims := verifyapi.NewVerificationClaims()
+	// Assign the report type.
+	claims.ReportType = subject.TestType
+	if subject.SymptomDate != nil {
+		claims.SymptomOnsetInterval = subject.SymptomInterval()
+	}
+
+	issueTime := now.Add(-1 * c.config.CertificateSigning.AllowedClockSkew).Unix()
+	claims.SignedMAC = request.ExposureKeyHMAC
+	claims.StandardClaims.Audience = signerInfo.Audience
+	claims.StandardClaims.Issuer = signerInfo.Issuer
+	claims.StandardClaims.IssuedAt = issueTime
+	claims.StandardClaims.ExpiresAt = now.Add(signerInfo.Duration).Unix()
+	claims.StandardClaims.NotBefore = issueTime
+
+	extra, err := c.extraClaims(ctx, authApp.RealmID, signerInfo, subject)
+	if err != nil {
+		logger.Errorw("failed to build certificate claims", "error", err)
+		blame = enobs.BlameServer
+		result = enobs.ResultError("FAILED_TO_BUILD_CLAIMS")
+
+		return &CertificateResult{
+			HTTPCode:    http.StatusInternalServerError,
+			ErrorReturn: api.InternalError(),
 		}
+	}
 
-		// Do the transactional update to the database last so that if it fails, the
-		// client can retry.
-		if err := c.db.ClaimToken(now, authApp, tokenID, subject); err != nil {
-			blame = enobs.BlameClient
-			switch {
-			case errors.Is(err, database.ErrTokenExpired):
-				logger.Infow("failed to claim token, expired", "tokenID", tokenID, "error", err)
-				result = enobs.ResultError("TOKEN_EXPIRED")
-				c.h.RenderJSON(w, http.StatusBadRequest, api.Error(err).WithCode(api.ErrTokenExpired))
-				return
-			case errors.Is(err, database.ErrTokenUsed):
-				logger.Infow("failed to claim token, already used", "tokenID", tokenID, "error", err)
-				result = enobs.ResultError("TOKEN_USED")
-				c.h.RenderJSON(w, http.StatusBadRequest, api.Errorf("verification token invalid").WithCode(api.ErrTokenExpired))
-				return
-			case errors.Is(err, database.ErrTokenMetadataMismatch):
-				logger.Infow("failed to claim token, metadata mismatch", "tokenID", tokenID, "error", err)
-				result = enobs.ResultError("TOKEN_METADATA_MISMATCH")
-				c.h.RenderJSON(w, http.StatusBadRequest, api.Errorf("verification token invalid").WithCode(api.ErrTokenExpired))
-				return
-			default:
-				blame = enobs.BlameServer
-				logger.Errorw("failed to claim token, unknown", "tokenID", tokenID, "error", err)
-				result = enobs.ResultError("UNKNOWN_TOKEN_CLAIM_ERROR")
-				c.h.RenderJSON(w, http.StatusInternalServerError, api.Error(err))
-				return
+	certToken, err := jwthelper.NewWithClaims(signerInfo.Signer, &certificateClaims{
+		VerificationClaims: claims,
+		extra:              extra,
+	})
+	if err != nil {
+		logger.Errorw("failed to create certificate", "error", err)
+		blame = enobs.BlameServer
+		result = enobs.ResultError("FAILED_TO_SIGN_JWT")
+
+		return &CertificateResult{
+			HTTPCode:    http.StatusInternalServerError,
+			ErrorReturn: api.Error(err).WithCode(api.ErrInternal),
+		}
+	}
+	certToken.Header[verifyapi.KeyIDHeader] = signerInfo.KeyID
+	certificate, err := jwthelper.SignJWT(certToken, signerInfo.Signer)
+	if err != nil {
+		logger.Errorw("failed to sign certificate", "error", err)
+		blame = enobs.BlameServer
+		result = enobs.ResultError("FAILED_TO_SIGN_JWT")
+
+		return &CertificateResult{
+			HTTPCode:    http.StatusInternalServerError,
+			ErrorReturn: api.Error(err).WithCode(api.ErrInternal),
+		}
+	}
+
+	// Do the transactional update to the database last so that if it fails, the
+	// client can retry. Shadow deployments only check the token.
+	claimToken := c.db.ClaimToken
+	if c.config.Shadow.DryRun {
+		claimToken = c.db.CheckToken
+	}
+	if err := claimToken(now, authApp, tokenID, subject); err != nil {
+		blame = enobs.BlameClient
+		switch {
+		case errors.Is(err, database.ErrTokenExpired):
+			logger.Infow("failed to claim token, expired", "tokenID", tokenID, "error", err)
+			result = enobs.ResultError("TOKEN_EXPIRED")
+			return &CertificateResult{
+				HTTPCode:    http.StatusBadRequest,
+				ErrorReturn: api.Error(err).WithCode(api.ErrTokenExpired),
+			}
+		case errors.Is(err, database.ErrTokenUsed):
+			logger.Infow("failed to claim token, already used", "tokenID", tokenID, "error", err)
+			result = enobs.ResultError("TOKEN_USED")
+			return &CertificateResult{
+				HTTPCode:    http.StatusBadRequest,
+				ErrorReturn: api.Errorf("verification token invalid").WithCode(api.ErrTokenExpired),
+			}
+		case errors.Is(err, database.ErrTokenMetadataMismatch):
+			logger.Infow("failed to claim token, metadata mismatch", "tokenID", tokenID, "error", err)
+			result = enobs.ResultError("TOKEN_METADATA_MISMATCH")
+			return &CertificateResult{
+				HTTPCode:    http.StatusBadRequest,
+				ErrorReturn: api.Errorf("verification token invalid").WithCode(api.ErrTokenExpired),
+			}
+		default:
+			blame = enobs.BlameServer
+			logger.Errorw("failed to claim token, unknown", "tokenID", tokenID, "error", err)
+			result = enobs.ResultError("UNKNOWN_TOKEN_CLAIM_ERROR")
+			return &CertificateResult{
+				HTTPCode:    http.StatusInternalServerError,
+				ErrorReturn: api.InternalError(),
 			}
 		}
+	}
 
-		c.h.RenderJSON(w, http.StatusOK, &api.VerificationCertificateResponse{
+	return &CertificateResult{
+		HTTPCode: http.StatusOK,
+		Response: &api.VerificationCertificateResponse{
 			Certificate: certificate,
-		})
-	})
+		},
+	}
 }
@@ -0,0 +1,136 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package certapi
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/google/exposure-notifications-server/pkg/logging"
+	"github.com/google/exposure-notifications-verification-server/pkg/database"
+
+	verifyapi "github.com/google/exposure-notifications-server/pkg/api/v1"
+)
+
+// ClaimsProvider supplies additional claims for verification certificates,
+// for key servers that need more than the standard claims (e.g. regional
+// identifiers). Providers are called for every certificate, after the
+// verification token is validated.
+//
+// Only claims whose names a system administrator approved for the realm are
+// added to the certificate; all others are dropped. Returning an error fails
+// the request.
+type ClaimsProvider interface {
+	CertificateClaims(ctx context.Context, realmID uint, subject *database.Subject) (map[string]interface{}, error)
+}
+
+// ClaimsProviderFunc is a function that implements ClaimsProvider.
+type ClaimsProviderFunc func(ctx context.Context, realmID uint, subject *database.Subject) (map[string]interface{}, error)
+
+// CertificateClaims implements ClaimsProvider.
+func (f ClaimsProviderFunc) CertificateClaims(ctx context.Context, realmID uint, subject *database.Subject) (map[string]interface{}, error) {
+	return f(ctx, realmID, subject)
+}
+
+// RegisterClaimsProvider adds a provider of additional certificate claims.
+// Providers are called in the order they are registered, and later providers
+// override earlier ones and the realm's static claims. It must be called before
+// the controller serves requests.
+func (c *Controller) RegisterClaimsProvider(p ClaimsProvider) {
+	c.claimsProviders = append(c.claimsProviders, p)
+}
+
+// extraClaims returns the realm's static claims merged with the claims from
+// any registered providers, keeping only approved claims with scalar values.
+func (c *Controller) extraClaims(ctx context.Context, realmID uint, signerInfo *SignerInfo, subject *database.Subject) (map[string]interface{}, error) {
+	logger := logging.FromContext(ctx).Named("certapi.extraClaims")
+
+	if len(signerInfo.AllowedClaims) == 0 {
+		return nil, nil
+	}
+
+	allowed := make(map[string]struct{}, len(signerInfo.AllowedClaims))
+	for _, name := range signerInfo.AllowedClaims {
+		if !database.IsReservedCertificateClaim(name) {
+			allowed[name] = struct{}{}
+		}
+	}
+
+	claims := make(map[string]interface{}, len(signerInfo.Claims))
+	add := func(from map[string]interface{}) {
+		for k, v := range from {
+			if _, ok := allowed[k]; !ok {
+				logger.Warnw("dropping unapproved certificate claim", "realm_id", realmID, "claim", k)
+				continue
+			}
+			if !isScalarClaim(v) {
+				logger.Warnw("dropping certificate claim with invalid value", "realm_id", realmID, "claim", k)
+				continue
+			}
+			claims[k] = v
+		}
+	}
+
+	add(signerInfo.Claims)
+	for _, p := range c.claimsProviders {
+		provided, err := p.CertificateClaims(ctx, realmID, subject)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get certificate claims: %w", err)
+		}
+		add(provided)
+	}
+	return claims, nil
+}
+
+// isScalarClaim returns true if v is a string, boolean, or number.
+func isScalarClaim(v interface{}) bool {
+	switch v.(type) {
+	case string, bool, json.Number,
+		int, int8, int16, int32, int64,
+		uint, uint8, uint16, uint32, uint64,
+		float32, float64:
+		return true
+	default:
+		return false
+	}
+}
+
+// certificateClaims are verification claims with additional claims. The
+// additional claims can never replace the verification claims.
+type certificateClaims struct {
+	*verifyapi.VerificationClaims
+	extra map[string]interface{}
+}
+
+// MarshalJSON merges the additional claims into the verification claims.
+func (c *certificateClaims) MarshalJSON() ([]byte, error) {
+	b, err := json.Marshal(c.VerificationClaims)
+	if err != nil || len(c.extra) == 0 {
+		return b, err
+	}
+
+	var merged map[string]interface{}
+	if err := json.Unmarshal(b, &merged); err != nil {
+		return nil, err
+	}
+	for k, v := range c.extra {
+		if _, ok := merged[k]; ok || database.IsReservedCertificateClaim(k) {
+			continue
+		}
+		merged[k] = v
+	}
+	return json.Marshal(merged)
+}
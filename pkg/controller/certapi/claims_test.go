@@ -0,0 +1,167 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package certapi
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/google/exposure-notifications-verification-server/internal/project"
+	"github.com/google/exposure-notifications-verification-server/pkg/database"
+	"github.com/google/go-cmp/cmp"
+
+	verifyapi "github.com/google/exposure-notifications-server/pkg/api/v1"
+)
+
+func TestController_ExtraClaims(t *testing.T) {
+	t.Parallel()
+
+	signerInfo := &SignerInfo{
+		Claims: map[string]interface{}{
+			"region":  "us-wa",
+			"cluster": "a",
+		},
+		AllowedClaims: []string{"region", "cluster", "lab"},
+	}
+
+	t.Run("none_allowed", func(t *testing.T) {
+		t.Parallel()
+
+		ctx := project.TestContext(t)
+		c := new(Controller)
+		c.RegisterClaimsProvider(ClaimsProviderFunc(func(ctx context.Context, realmID uint, subject *database.Subject) (map[string]interface{}, error) {
+			t.Error("provider should not be called")
+			return nil, nil
+		}))
+
+		claims, err := c.extraClaims(ctx, 1, &SignerInfo{}, &database.Subject{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(claims) > 0 {
+			t.Errorf("expected no claims, got %v", claims)
+		}
+	})
+
+	t.Run("static", func(t *testing.T) {
+		t.Parallel()
+
+		ctx := project.TestContext(t)
+		c := new(Controller)
+
+		claims, err := c.extraClaims(ctx, 1, signerInfo, &database.Subject{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if diff := cmp.Diff(signerInfo.Claims, claims); diff != "" {
+			t.Errorf("mismatch (-want, +got):\n%s", diff)
+		}
+	})
+
+	t.Run("providers", func(t *testing.T) {
+		t.Parallel()
+
+		ctx := project.TestContext(t)
+		c := new(Controller)
+		c.RegisterClaimsProvider(ClaimsProviderFunc(func(ctx context.Context, realmID uint, subject *database.Subject) (map[string]interface{}, error) {
+			return map[string]interface{}{
+				"cluster":    "b",
+				"lab":        subject.TestType,
+				"unapproved": "x",
+				"reportType": "negative",
+				"region":     []string{"not", "scalar"},
+			}, nil
+		}))
+
+		claims, err := c.extraClaims(ctx, 1, signerInfo, &database.Subject{TestType: "confirmed"})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		want := map[string]interface{}{
+			"region":  "us-wa",
+			"cluster": "b",
+			"lab":     "confirmed",
+		}
+		if diff := cmp.Diff(want, claims); diff != "" {
+			t.Errorf("mismatch (-want, +got):\n%s", diff)
+		}
+	})
+
+	t.Run("provider_error", func(t *testing.T) {
+		t.Parallel()
+
+		ctx := project.TestContext(t)
+		c := new(Controller)
+		c.RegisterClaimsProvider(ClaimsProviderFunc(func(ctx context.Context, realmID uint, subject *database.Subject) (map[string]interface{}, error) {
+			return nil, fmt.Errorf("boom")
+		}))
+
+		if _, err := c.extraClaims(ctx, 1, signerInfo, &database.Subject{}); err == nil {
+			t.Error("expected error")
+		}
+	})
+}
+
+func TestCertificateClaims_MarshalJSON(t *testing.T) {
+	t.Parallel()
+
+	vc := verifyapi.NewVerificationClaims()
+	vc.ReportType = "confirmed"
+	vc.SignedMAC = "mac"
+	vc.Issuer = "iss"
+
+	b, err := json.Marshal(&certificateClaims{
+		VerificationClaims: vc,
+		extra: map[string]interface{}{
+			"region":     "us-wa",
+			"reportType": "negative",
+			"iss":        "other",
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got map[string]interface{}
+	if err := json.Unmarshal(b, &got); err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]interface{}{
+		"iss":        "iss",
+		"reportType": "confirmed",
+		"tekmac":     "mac",
+		"region":     "us-wa",
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("mismatch (-want, +got):\n%s", diff)
+	}
+
+	// Without extra claims, the verification claims are unchanged.
+	plain, err := json.Marshal(&certificateClaims{VerificationClaims: vc})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected, err := json.Marshal(vc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(plain), string(expected); got != want {
+		t.Errorf("expected %q to be %q", got, want)
+	}
+}
@@ -32,6 +32,11 @@ type SignerInfo struct {
 	Issuer   string
 	Audience string
 	Duration time.Duration
+
+	// Claims are the realm's static certificate claims, and AllowedClaims are
+	// the claim names approved for the realm by a system administrator.
+	Claims        map[string]interface{}
+	AllowedClaims []string
 }
 
 func (c *Controller) getSignerForAuthApp(ctx context.Context, authApp *database.AuthorizedApp) (*SignerInfo, error) {
@@ -50,17 +55,28 @@ func GetSignerForRealm(ctx context.Context, realmID uint,
 			}
 
 			if !realm.UseRealmCertificateKey {
-				// This realm is using the system key.
-				signer, err := kms.NewSigner(ctx, cfg.CertificateSigningKey)
+				// This realm is using the system key, unless its key server has its
+				// own credentials.
+				keyServer, err := db.FindKeyServerForRealm(realmID)
+				if err != nil {
+					return nil, fmt.Errorf("unable to load key server: realmId: %d: %w", realmID, err)
+				}
+				settings := systemSignerSettings(cfg, keyServer)
+
+				signer, err := kms.NewSigner(ctx, settings.signingKey)
 				if err != nil {
 					return nil, fmt.Errorf("unable to get signing key from key manager: realmId: %d: %w", realmID, err)
 				}
+
 				return &SignerInfo{
 					Signer:   signer,
-					KeyID:    cfg.CertificateSigningKeyID,
-					Issuer:   cfg.CertificateIssuer,
-					Audience: cfg.CertificateAudience,
+					KeyID:    settings.keyID,
+					Issuer:   settings.issuer,
+					Audience: settings.audience,
 					Duration: cfg.CertificateDuration,
+
+					Claims:        realm.StaticCertificateClaims(),
+					AllowedClaims: realm.AllowedCertificateClaims,
 				}, nil
 			}
 
@@ -81,6 +97,9 @@ func GetSignerForRealm(ctx context.Context, realmID uint,
 				Issuer:   realm.CertificateIssuer,
 				Audience: realm.CertificateAudience,
 				Duration: realm.CertificateDuration.Duration,
+
+				Claims:        realm.StaticCertificateClaims(),
+				AllowedClaims: realm.AllowedCertificateClaims,
 			}, nil
 		})
 	if err != nil {
@@ -88,3 +107,40 @@ func GetSignerForRealm(ctx context.Context, realmID uint,
 	}
 	return signer, nil
 }
+
+// signerSettings are the values used to build a SignerInfo for a realm that
+// uses the system certificate signing key.
+type signerSettings struct {
+	signingKey string
+	keyID      string
+	issuer     string
+	audience   string
+}
+
+// systemSignerSettings returns the signer settings for a realm that uses the
+// system certificate signing key. Values configured on the realm's key server,
+// if any, take precedence over the system configuration.
+func systemSignerSettings(cfg config.CertificateSigningConfig, keyServer *database.KeyServer) *signerSettings {
+	settings := &signerSettings{
+		signingKey: cfg.CertificateSigningKey,
+		keyID:      cfg.CertificateSigningKeyID,
+		issuer:     cfg.CertificateIssuer,
+		audience:   cfg.CertificateAudience,
+	}
+
+	if keyServer == nil {
+		return settings
+	}
+
+	if keyServer.SigningKey != "" {
+		settings.signingKey = keyServer.SigningKey
+		settings.keyID = keyServer.SigningKeyID
+	}
+	if keyServer.Issuer != "" {
+		settings.issuer = keyServer.Issuer
+	}
+	if keyServer.CertificateAudience != "" {
+		settings.audience = keyServer.CertificateAudience
+	}
+	return settings
+}
@@ -0,0 +1,95 @@
+// Copyright 2020 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package certapi
+
+import (
+	"testing"
+
+	"github.com/google/exposure-notifications-verification-server/pkg/config"
+	"github.com/google/exposure-notifications-verification-server/pkg/database"
+)
+
+func TestSystemSignerSettings(t *testing.T) {
+	t.Parallel()
+
+	cfg := config.CertificateSigningConfig{
+		CertificateSigningKey:   "system-key",
+		CertificateSigningKeyID: "system-kid",
+		CertificateIssuer:       "system-iss",
+		CertificateAudience:     "system-aud",
+	}
+
+	system := &signerSettings{
+		signingKey: "system-key",
+		keyID:      "system-kid",
+		issuer:     "system-iss",
+		audience:   "system-aud",
+	}
+
+	cases := []struct {
+		name      string
+		keyServer *database.KeyServer
+		want      *signerSettings
+	}{
+		{
+			name: "no_key_server",
+			want: system,
+		},
+		{
+			name:      "no_overrides",
+			keyServer: &database.KeyServer{},
+			want:      system,
+		},
+		{
+			name: "audience_only",
+			keyServer: &database.KeyServer{
+				CertificateAudience: "pilot-aud",
+			},
+			want: &signerSettings{
+				signingKey: "system-key",
+				keyID:      "system-kid",
+				issuer:     "system-iss",
+				audience:   "pilot-aud",
+			},
+		},
+		{
+			name: "credentials",
+			keyServer: &database.KeyServer{
+				SigningKey:          "pilot-key",
+				SigningKeyID:        "pilot-kid",
+				Issuer:              "pilot-iss",
+				CertificateAudience: "pilot-aud",
+			},
+			want: &signerSettings{
+				signingKey: "pilot-key",
+				keyID:      "pilot-kid",
+				issuer:     "pilot-iss",
+				audience:   "pilot-aud",
+			},
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			if got, want := *systemSignerSettings(cfg, tc.keyServer), *tc.want; got != want {
+				t.Errorf("expected %#v to be %#v", got, want)
+			}
+		})
+	}
+}
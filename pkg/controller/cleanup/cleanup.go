@@ -22,22 +22,30 @@ import (
 	"github.com/google/exposure-notifications-verification-server/pkg/render"
 )
 
-const cleanupName = "cleanupLock"
+const (
+	cleanupName     = "cleanupLock"
+	consistencyName = "consistencyLock"
+
+	dualWriteVerifyName = "dualWriteVerifyLock"
+)
 
 // Controller is a controller for the cleanup service.
 type Controller struct {
 	config                 *config.CleanupConfig
 	db                     *database.Database
 	signingTokenKeyManager keys.SigningKeyManager
+	firebaseUsers          FirebaseUserManager
 	h                      *render.Renderer
 }
 
-// New creates a new cleanup controller.
-func New(config *config.CleanupConfig, db *database.Database, signingTokenKeyManager keys.SigningKeyManager, h *render.Renderer) *Controller {
+// New creates a new cleanup controller. The firebaseUsers manager may be nil if
+// Firebase user deletion is disabled.
+func New(config *config.CleanupConfig, db *database.Database, signingTokenKeyManager keys.SigningKeyManager, firebaseUsers FirebaseUserManager, h *render.Renderer) *Controller {
 	return &Controller{
 		config:                 config,
 		db:                     db,
 		signingTokenKeyManager: signingTokenKeyManager,
+		firebaseUsers:          firebaseUsers,
 		h:                      h,
 	}
 }
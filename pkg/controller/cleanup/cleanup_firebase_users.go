@@ -0,0 +1,106 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cleanup
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/exposure-notifications-server/pkg/logging"
+	"github.com/google/exposure-notifications-verification-server/internal/firebase"
+	"github.com/google/exposure-notifications-verification-server/pkg/database"
+	"github.com/hashicorp/go-multierror"
+)
+
+var _ FirebaseUserManager = (*firebase.Client)(nil)
+
+// FirebaseUserManager disables and deletes Firebase accounts.
+type FirebaseUserManager interface {
+	LookupUserIDByEmail(ctx context.Context, email string) (string, error)
+	DisableUser(ctx context.Context, localID string) error
+	DeleteUser(ctx context.Context, localID string) error
+}
+
+// cleanupFirebaseUsers disables the Firebase accounts of users whose grace
+// period has passed, and deletes the accounts that have been disabled for
+// longer than the deletion grace period. Accounts that no longer exist in
+// Firebase are treated as deleted. It returns the number of accounts disabled
+// or deleted.
+func (c *Controller) cleanupFirebaseUsers(ctx context.Context) (int64, error) {
+	logger := logging.FromContext(ctx).Named("cleanup.cleanupFirebaseUsers")
+
+	cfg := &c.config.FirebaseUserDeletion
+	now := time.Now().UTC()
+
+	var processed int64
+	var merr *multierror.Error
+
+	toDelete, err := c.db.ListFirebaseUserDeletionsToDelete(now.Add(-cfg.DeleteAfter), cfg.BatchSize)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list firebase users to delete: %w", err)
+	}
+	for _, d := range toDelete {
+		localID, err := c.firebaseUsers.LookupUserIDByEmail(ctx, d.Email)
+		if err != nil {
+			merr = multierror.Append(merr, fmt.Errorf("failed to lookup firebase user %d: %w", d.ID, err))
+			continue
+		}
+		if localID != "" {
+			if err := c.firebaseUsers.DeleteUser(ctx, localID); err != nil {
+				merr = multierror.Append(merr, fmt.Errorf("failed to delete firebase user %d: %w", d.ID, err))
+				continue
+			}
+		}
+		if err := c.db.CompleteFirebaseUserDeletion(d, database.System); err != nil {
+			merr = multierror.Append(merr, err)
+			continue
+		}
+		logger.Debugw("deleted firebase user", "id", d.ID)
+		processed++
+	}
+
+	toDisable, err := c.db.ListFirebaseUserDeletionsToDisable(now.Add(-cfg.DisableAfter), cfg.BatchSize)
+	if err != nil {
+		merr = multierror.Append(merr, fmt.Errorf("failed to list firebase users to disable: %w", err))
+		return processed, merr.ErrorOrNil()
+	}
+	for _, d := range toDisable {
+		localID, err := c.firebaseUsers.LookupUserIDByEmail(ctx, d.Email)
+		if err != nil {
+			merr = multierror.Append(merr, fmt.Errorf("failed to lookup firebase user %d: %w", d.ID, err))
+			continue
+		}
+		if localID == "" {
+			// There is no account to disable or delete.
+			if err := c.db.CompleteFirebaseUserDeletion(d, database.System); err != nil {
+				merr = multierror.Append(merr, err)
+			}
+			continue
+		}
+		if err := c.firebaseUsers.DisableUser(ctx, localID); err != nil {
+			merr = multierror.Append(merr, fmt.Errorf("failed to disable firebase user %d: %w", d.ID, err))
+			continue
+		}
+		if err := c.db.MarkFirebaseUserDisabled(d, database.System); err != nil {
+			merr = multierror.Append(merr, err)
+			continue
+		}
+		logger.Debugw("disabled firebase user", "id", d.ID)
+		processed++
+	}
+
+	return processed, merr.ErrorOrNil()
+}
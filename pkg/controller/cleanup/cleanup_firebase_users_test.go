@@ -0,0 +1,128 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cleanup
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/exposure-notifications-verification-server/internal/project"
+	"github.com/google/exposure-notifications-verification-server/pkg/config"
+	"github.com/google/exposure-notifications-verification-server/pkg/database"
+	"github.com/google/exposure-notifications-verification-server/pkg/rbac"
+	"github.com/google/go-cmp/cmp"
+)
+
+type fakeFirebaseUsers struct {
+	lock     sync.Mutex
+	ids      map[string]string
+	disabled []string
+	deleted  []string
+}
+
+func (f *fakeFirebaseUsers) LookupUserIDByEmail(_ context.Context, email string) (string, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	return f.ids[email], nil
+}
+
+func (f *fakeFirebaseUsers) DisableUser(_ context.Context, localID string) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.disabled = append(f.disabled, localID)
+	return nil
+}
+
+func (f *fakeFirebaseUsers) DeleteUser(_ context.Context, localID string) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.deleted = append(f.deleted, localID)
+	return nil
+}
+
+func TestCleanupFirebaseUsers(t *testing.T) {
+	t.Parallel()
+
+	ctx := project.TestContext(t)
+	db, _ := testDatabaseInstance.NewDatabase(t, nil)
+
+	realm, err := db.FindRealm(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, email := range []string{"removed@example.com", "missing@example.com", "member@example.com"} {
+		user := &database.User{
+			Email: email,
+			Name:  email,
+		}
+		if err := db.SaveUser(user, database.SystemTest); err != nil {
+			t.Fatal(err)
+		}
+		if err := user.AddToRealm(db, realm, rbac.CodeIssue, database.SystemTest); err != nil {
+			t.Fatal(err)
+		}
+		if email != "member@example.com" {
+			if err := user.DeleteFromRealm(db, realm, database.SystemTest); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	fake := &fakeFirebaseUsers{
+		ids: map[string]string{
+			"removed@example.com": "removed-id",
+			"member@example.com":  "member-id",
+		},
+	}
+
+	cfg := &config.CleanupConfig{
+		FirebaseUserDeletion: config.FirebaseUserDeletionConfig{
+			Enabled:      true,
+			DisableAfter: time.Nanosecond,
+			DeleteAfter:  time.Nanosecond,
+			BatchSize:    10,
+		},
+	}
+	c := New(cfg, db, nil, fake, nil)
+
+	// The first run disables the removed user's account and drops the deletion
+	// for the account that does not exist.
+	if _, err := c.cleanupFirebaseUsers(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff([]string{"removed-id"}, fake.disabled); diff != "" {
+		t.Errorf("mismatch (-want, +got):\n%s", diff)
+	}
+	if got, want := len(fake.deleted), 0; got != want {
+		t.Errorf("expected %d to be %d", got, want)
+	}
+	if _, err := db.FindFirebaseUserDeletion("missing@example.com"); !database.IsNotFound(err) {
+		t.Errorf("expected not found, got %v", err)
+	}
+
+	// The second run deletes the disabled account.
+	if _, err := c.cleanupFirebaseUsers(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff([]string{"removed-id"}, fake.deleted); diff != "" {
+		t.Errorf("mismatch (-want, +got):\n%s", diff)
+	}
+	if _, err := db.FindFirebaseUserDeletion("removed@example.com"); !database.IsNotFound(err) {
+		t.Errorf("expected not found, got %v", err)
+	}
+}
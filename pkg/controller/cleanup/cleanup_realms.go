@@ -0,0 +1,184 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cleanup
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/exposure-notifications-server/pkg/logging"
+	enobs "github.com/google/exposure-notifications-server/pkg/observability"
+	"github.com/google/exposure-notifications-verification-server/pkg/database"
+	"github.com/google/exposure-notifications-verification-server/pkg/observability"
+	"github.com/hashicorp/go-multierror"
+	"go.opencensus.io/stats"
+	"go.opencensus.io/tag"
+	"golang.org/x/sync/semaphore"
+)
+
+// realmPurge is a purge of one realm's data.
+type realmPurge struct {
+	item  string
+	purge func(ctx context.Context, realm *database.Realm) (int64, error)
+}
+
+// realmPurges returns the per-realm purges, in the order they run. These are
+// the largest tables, so they are purged one realm at a time in batches
+// instead of in a single statement. Verification codes and tokens are kept for
+// the realm's retention period, if it sets one.
+func (c *Controller) realmPurges() []*realmPurge {
+	return []*realmPurge{
+		{
+			// Purge codes from database entirely, keeping an anonymized history.
+			// Their code/long_code hmac values will have been set to "".
+			item: "VERIFICATION_CODE",
+			purge: func(ctx context.Context, realm *database.Realm) (int64, error) {
+				return c.db.PurgeRealmVerificationCodes(ctx, realm.ID,
+					realm.VerificationCodeRetention(c.config.VerificationCodeStatusMaxAge), c.config.BatchSize)
+			},
+		},
+		{
+			// Zero out the code/long_code values so status can be reported, but
+			// codes couldn't be recalculated or checked.
+			item: "VERIFICATION_CODE_RECYCLE",
+			purge: func(ctx context.Context, realm *database.Realm) (int64, error) {
+				return c.db.RecycleRealmVerificationCodes(ctx, realm.ID, c.config.VerificationCodeMaxAge, c.config.BatchSize)
+			},
+		},
+		{
+			item: "VERIFICATION_TOKEN",
+			purge: func(ctx context.Context, realm *database.Realm) (int64, error) {
+				return c.db.PurgeRealmTokens(ctx, realm.ID, realm.TokenRetention(c.config.VerificationTokenMaxAge), c.config.BatchSize)
+			},
+		},
+	}
+}
+
+// cleanupRealms runs the per-realm purges for up to RealmMaxWorkers realms in
+// parallel, starting with the realms that were cleaned longest ago. Realms
+// that are not finished before RealmTimeout keep their progress marker, so
+// they are processed first on the next run. It returns the number of records
+// purged.
+func (c *Controller) cleanupRealms(ctx context.Context) (int64, error) {
+	logger := logging.FromContext(ctx).Named("cleanup.cleanupRealms")
+
+	realmIDs, err := c.db.ListRealmIDsForCleanup()
+	if err != nil {
+		return 0, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, c.config.RealmTimeout)
+	defer cancel()
+
+	var processed int64
+	var incomplete int64
+	var merr *multierror.Error
+	var lock sync.Mutex
+
+	sem := semaphore.NewWeighted(c.config.RealmMaxWorkers)
+	var wg sync.WaitGroup
+	for i, realmID := range realmIDs {
+		if err := sem.Acquire(ctx, 1); err != nil {
+			// The deadline passed. The remaining realms were not started.
+			lock.Lock()
+			incomplete += int64(len(realmIDs) - i)
+			lock.Unlock()
+			break
+		}
+
+		wg.Add(1)
+		go func(realmID uint) {
+			defer sem.Release(1)
+			defer wg.Done()
+
+			count, err := c.cleanupRealm(ctx, realmID)
+
+			lock.Lock()
+			defer lock.Unlock()
+			processed += count
+			if err != nil {
+				if errors.Is(err, context.DeadlineExceeded) {
+					incomplete++
+					return
+				}
+				merr = multierror.Append(merr, fmt.Errorf("failed to clean realm %d: %w", realmID, err))
+			}
+		}(realmID)
+	}
+	wg.Wait()
+
+	stats.Record(ctx, mRealmsIncomplete.M(incomplete))
+	if incomplete > 0 {
+		logger.Warnw("realm cleanup did not finish before the deadline",
+			"incomplete", incomplete,
+			"timeout", c.config.RealmTimeout)
+	}
+
+	return processed, merr.ErrorOrNil()
+}
+
+// cleanupRealm runs the per-realm purges for one realm and, if they all
+// succeed, records the realm's progress marker.
+func (c *Controller) cleanupRealm(ctx context.Context, realmID uint) (int64, error) {
+	logger := logging.FromContext(ctx).Named("cleanup.cleanupRealm").With("realm_id", realmID)
+	ctx = observability.WithRealmID(ctx, uint64(realmID))
+
+	realm, err := c.db.FindRealm(realmID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to find realm: %w", err)
+	}
+
+	var result, item tag.Mutator
+	var merr *multierror.Error
+	var total int64
+
+	for _, p := range c.realmPurges() {
+		func() {
+			defer enobs.RecordLatency(ctx, time.Now(), mRealmLatencyMs, &result, &item)
+			item = tag.Upsert(itemTagKey, p.item)
+
+			count, err := p.purge(ctx, realm)
+			total += count
+			if err := stats.RecordWithTags(ctx, []tag.Mutator{item}, mRealmPurged.M(count)); err != nil {
+				logger.Errorw("failed to record purged count", "item", p.item, "error", err)
+			}
+
+			if err != nil {
+				merr = multierror.Append(merr, fmt.Errorf("failed to purge %s: %w", p.item, err))
+				result = enobs.ResultError("FAILED")
+				return
+			}
+			logger.Debugw("purged realm records", "item", p.item, "count", count)
+			result = enobs.ResultOK
+		}()
+
+		// Stop at the deadline instead of starting the next purge.
+		if err := ctx.Err(); err != nil {
+			return total, err
+		}
+	}
+
+	if err := merr.ErrorOrNil(); err != nil {
+		return total, err
+	}
+
+	if err := c.db.MarkRealmCleanupComplete(realmID, total); err != nil {
+		return total, err
+	}
+	return total, nil
+}
@@ -21,6 +21,8 @@ import (
 
 	"github.com/google/exposure-notifications-server/pkg/logging"
 	enobs "github.com/google/exposure-notifications-server/pkg/observability"
+	"github.com/google/exposure-notifications-verification-server/pkg/controller/jobstatus"
+	"github.com/google/exposure-notifications-verification-server/pkg/database"
 	"github.com/hashicorp/go-multierror"
 	"go.opencensus.io/stats"
 	"go.opencensus.io/tag"
@@ -44,6 +46,7 @@ func (c *Controller) HandleCleanup() http.Handler {
 		}
 		if !ok {
 			logger.Debugw("skipping (too early)")
+			jobstatus.RecordFreshness(ctx, c.db, jobstatus.JobCleanup)
 			c.h.RenderJSON(w, http.StatusOK, fmt.Errorf("too early"))
 			return
 		}
@@ -52,56 +55,85 @@ func (c *Controller) HandleCleanup() http.Handler {
 		// attempt the other purges.
 		var merr *multierror.Error
 
-		// API keys
+		// processed is the total number of records purged across all items.
+		var processed int64
+
+		// Inactive API keys, per realm policy
 		func() {
 			defer enobs.RecordLatency(ctx, time.Now(), mLatencyMs, &result, &item)
-			item = tag.Upsert(itemTagKey, "API_KEYS")
-			if count, err := c.db.PurgeAuthorizedApps(c.config.AuthorizedAppMaxAge); err != nil {
-				merr = multierror.Append(merr, fmt.Errorf("failed to purge api keys: %w", err))
+			item = tag.Upsert(itemTagKey, "INACTIVE_API_KEYS")
+
+			flagged, err := c.db.FlagInactiveAuthorizedApps()
+			if err != nil {
+				merr = multierror.Append(merr, fmt.Errorf("failed to flag inactive api keys: %w", err))
+				result = enobs.ResultError("FAILED")
+				return
+			}
+			logger.Infow("flagged inactive api keys", "count", flagged)
+
+			disabled, err := c.db.DisableInactiveAuthorizedApps(database.System)
+			if err != nil {
+				merr = multierror.Append(merr, fmt.Errorf("failed to disable inactive api keys: %w", err))
+				result = enobs.ResultError("FAILED")
+				return
+			}
+			logger.Infow("disabled inactive api keys", "count", disabled)
+			processed += flagged + disabled
+			result = enobs.ResultOK
+		}()
+
+		// Previous API keys whose rotation overlap ended
+		func() {
+			defer enobs.RecordLatency(ctx, time.Now(), mLatencyMs, &result, &item)
+			item = tag.Upsert(itemTagKey, "ROTATED_API_KEYS")
+			if count, err := c.db.ExpireRotatedAPIKeys(); err != nil {
+				merr = multierror.Append(merr, fmt.Errorf("failed to expire rotated api keys: %w", err))
 				result = enobs.ResultError("FAILED")
 			} else {
-				logger.Infow("purged api keys", "count", count)
+				logger.Infow("expired rotated api keys", "count", count)
+				processed += count
 				result = enobs.ResultOK
 			}
 		}()
 
-		// Verification codes - purge codes from database entirely.
-		// Their code/long_code hmac values will have been set to "".
+		// API keys
 		func() {
 			defer enobs.RecordLatency(ctx, time.Now(), mLatencyMs, &result, &item)
-			item = tag.Upsert(itemTagKey, "VERIFICATION_CODE")
-			if count, err := c.db.PurgeVerificationCodes(c.config.VerificationCodeStatusMaxAge); err != nil {
-				merr = multierror.Append(merr, fmt.Errorf("failed to purge verification codes: %w", err))
+			item = tag.Upsert(itemTagKey, "API_KEYS")
+			if count, err := c.db.PurgeAuthorizedApps(c.config.AuthorizedAppMaxAge); err != nil {
+				merr = multierror.Append(merr, fmt.Errorf("failed to purge api keys: %w", err))
 				result = enobs.ResultError("FAILED")
 			} else {
-				logger.Infow("purged verification codes", "count", count)
+				logger.Infow("purged api keys", "count", count)
+				processed += count
 				result = enobs.ResultOK
 			}
 		}()
 
-		// Verification codes - recycle codes. Zero out the code/long_code values
-		// so status can be reported, but codes couldn't be recalculated or checked.
+		// Verification codes and tokens, per realm
 		func() {
 			defer enobs.RecordLatency(ctx, time.Now(), mLatencyMs, &result, &item)
-			item = tag.Upsert(itemTagKey, "VERIFICATION_CODE_RECYCLE")
-			if count, err := c.db.RecycleVerificationCodes(c.config.VerificationCodeMaxAge); err != nil {
-				merr = multierror.Append(merr, fmt.Errorf("failed to purge verification codes: %w", err))
+			item = tag.Upsert(itemTagKey, "REALMS")
+			if count, err := c.cleanupRealms(ctx); err != nil {
+				merr = multierror.Append(merr, fmt.Errorf("failed to purge realm data: %w", err))
 				result = enobs.ResultError("FAILED")
 			} else {
-				logger.Infow("recycled verification codes", "count", count)
+				logger.Infow("purged realm data", "count", count)
+				processed += count
 				result = enobs.ResultOK
 			}
 		}()
 
-		// Verification tokens
+		// Verification code history
 		func() {
 			defer enobs.RecordLatency(ctx, time.Now(), mLatencyMs, &result, &item)
-			item = tag.Upsert(itemTagKey, "VERIFICATION_TOKEN")
-			if count, err := c.db.PurgeTokens(c.config.VerificationTokenMaxAge); err != nil {
+			item = tag.Upsert(itemTagKey, "VERIFICATION_CODE_HISTORY")
+			if count, err := c.db.PurgeVerificationCodeHistory(c.config.VerificationCodeHistoryMaxAge); err != nil {
+				merr = multierror.Append(merr, fmt.Errorf("failed to purge verification code history: %w", err))
 				result = enobs.ResultError("FAILED")
-				merr = multierror.Append(merr, fmt.Errorf("failed to purge tokens: %w", err))
 			} else {
-				logger.Infow("purged verification tokens", "count", count)
+				logger.Infow("purged verification code history", "count", count)
+				processed += count
 				result = enobs.ResultOK
 			}
 		}()
@@ -115,6 +147,7 @@ func (c *Controller) HandleCleanup() http.Handler {
 				result = enobs.ResultError("FAILED")
 			} else {
 				logger.Infow("purged orphaned memberships", "count", count)
+				processed += count
 				result = enobs.ResultOK
 			}
 		}()
@@ -128,6 +161,7 @@ func (c *Controller) HandleCleanup() http.Handler {
 				result = enobs.ResultError("FAILED")
 			} else {
 				logger.Infow("purged mobile apps", "count", count)
+				processed += count
 				result = enobs.ResultOK
 			}
 		}()
@@ -141,6 +175,64 @@ func (c *Controller) HandleCleanup() http.Handler {
 				result = enobs.ResultError("FAILED")
 			} else {
 				logger.Infow("purged audit entries", "count", count)
+				processed += count
+				result = enobs.ResultOK
+			}
+		}()
+
+		// Verification code transfers are kept as long as the audit entries that
+		// reference them.
+		func() {
+			defer enobs.RecordLatency(ctx, time.Now(), mLatencyMs, &result, &item)
+			item = tag.Upsert(itemTagKey, "VERIFICATION_CODE_TRANSFER")
+			if count, err := c.db.PurgeVerificationCodeTransfers(c.config.AuditEntryMaxAge); err != nil {
+				merr = multierror.Append(merr, fmt.Errorf("failed to purge verification code transfers: %w", err))
+				result = enobs.ResultError("FAILED")
+			} else {
+				logger.Infow("purged verification code transfers", "count", count)
+				processed += count
+				result = enobs.ResultOK
+			}
+		}()
+
+		// Realm impersonations
+		func() {
+			defer enobs.RecordLatency(ctx, time.Now(), mLatencyMs, &result, &item)
+			item = tag.Upsert(itemTagKey, "REALM_IMPERSONATION")
+			if count, err := c.db.PurgeRealmImpersonations(c.config.AuditEntryMaxAge); err != nil {
+				merr = multierror.Append(merr, fmt.Errorf("failed to purge realm impersonations: %w", err))
+				result = enobs.ResultError("FAILED")
+			} else {
+				logger.Infow("purged realm impersonations", "count", count)
+				processed += count
+				result = enobs.ResultOK
+			}
+		}()
+
+		// User report consent acceptances
+		func() {
+			defer enobs.RecordLatency(ctx, time.Now(), mLatencyMs, &result, &item)
+			item = tag.Upsert(itemTagKey, "USER_REPORT_CONSENT_ACCEPTANCE")
+			if count, err := c.db.PurgeUserReportConsentAcceptances(c.config.AuditEntryMaxAge); err != nil {
+				merr = multierror.Append(merr, fmt.Errorf("failed to purge user report consent acceptances: %w", err))
+				result = enobs.ResultError("FAILED")
+			} else {
+				logger.Infow("purged user report consent acceptances", "count", count)
+				processed += count
+				result = enobs.ResultOK
+			}
+		}()
+
+		// Data access logs
+		func() {
+			defer enobs.RecordLatency(ctx, time.Now(), mLatencyMs, &result, &item)
+			item = tag.Upsert(itemTagKey, "DATA_ACCESS_LOG")
+			if count, err := c.db.PurgeDataAccessLogs(c.config.DataAccessLogMaxAge); err != nil {
+				merr = multierror.Append(merr, fmt.Errorf("failed to purge data access logs: %w", err))
+				result = enobs.ResultError("FAILED")
+			} else {
+				logger.Infow("purged data access logs", "count", count)
+				processed += count
 				result = enobs.ResultOK
 			}
 		}()
@@ -154,10 +246,43 @@ func (c *Controller) HandleCleanup() http.Handler {
 				result = enobs.ResultError("FAILED")
 			} else {
 				logger.Infow("purged user entries", "count", count)
+				processed += count
 				result = enobs.ResultOK
 			}
 		}()
 
+		// Expired break-glass activations
+		func() {
+			defer enobs.RecordLatency(ctx, time.Now(), mLatencyMs, &result, &item)
+			item = tag.Upsert(itemTagKey, "BREAK_GLASS_ACTIVATION")
+			if count, err := c.db.RecordExpiredBreakGlassActivations(); err != nil {
+				merr = multierror.Append(merr, fmt.Errorf("failed to record expired break-glass activations: %w", err))
+				result = enobs.ResultError("FAILED")
+			} else {
+				if count > 0 {
+					logger.Warnw("recorded expired break-glass activations", "count", count)
+				}
+				processed += int64(count)
+				result = enobs.ResultOK
+			}
+		}()
+
+		// Firebase accounts of users without realms
+		if c.config.FirebaseUserDeletion.Enabled && c.firebaseUsers != nil {
+			func() {
+				defer enobs.RecordLatency(ctx, time.Now(), mLatencyMs, &result, &item)
+				item = tag.Upsert(itemTagKey, "FIREBASE_USER")
+				if count, err := c.cleanupFirebaseUsers(ctx); err != nil {
+					merr = multierror.Append(merr, fmt.Errorf("failed to delete firebase users: %w", err))
+					result = enobs.ResultError("FAILED")
+				} else {
+					logger.Infow("disabled or deleted firebase users", "count", count)
+					processed += count
+					result = enobs.ResultOK
+				}
+			}()
+		}
+
 		// Token signing keys
 		func() {
 			defer enobs.RecordLatency(ctx, time.Now(), mLatencyMs, &result, &item)
@@ -167,6 +292,7 @@ func (c *Controller) HandleCleanup() http.Handler {
 				result = enobs.ResultError("FAILED")
 			} else {
 				logger.Infow("purged token signing keys", "count", count)
+				processed += count
 				result = enobs.ResultOK
 			}
 		}()
@@ -180,6 +306,7 @@ func (c *Controller) HandleCleanup() http.Handler {
 				result = enobs.ResultError("FAILED")
 			} else {
 				logger.Infow("purged verification signing keys", "count", count)
+				processed += count
 				result = enobs.ResultOK
 			}
 		}()
@@ -193,6 +320,7 @@ func (c *Controller) HandleCleanup() http.Handler {
 				result = enobs.ResultError("FAILED")
 			} else {
 				logger.Infow("purged key-server stats", "count", count)
+				processed += count
 				result = enobs.ResultOK
 			}
 		}()
@@ -206,6 +334,7 @@ func (c *Controller) HandleCleanup() http.Handler {
 				result = enobs.ResultError("FAILED")
 			} else {
 				logger.Infow("purged authorized app stats", "count", count)
+				processed += count
 				result = enobs.ResultOK
 			}
 		}()
@@ -219,6 +348,7 @@ func (c *Controller) HandleCleanup() http.Handler {
 				result = enobs.ResultError("FAILED")
 			} else {
 				logger.Infow("purged external issuer stats", "count", count)
+				processed += count
 				result = enobs.ResultOK
 			}
 		}()
@@ -232,6 +362,7 @@ func (c *Controller) HandleCleanup() http.Handler {
 				result = enobs.ResultError("FAILED")
 			} else {
 				logger.Infow("purged sms error stats", "count", count)
+				processed += count
 				result = enobs.ResultOK
 			}
 		}()
@@ -245,6 +376,7 @@ func (c *Controller) HandleCleanup() http.Handler {
 				result = enobs.ResultError("FAILED")
 			} else {
 				logger.Infow("purged realm stats", "count", count)
+				processed += count
 				result = enobs.ResultOK
 			}
 		}()
@@ -258,6 +390,91 @@ func (c *Controller) HandleCleanup() http.Handler {
 				result = enobs.ResultError("FAILED")
 			} else {
 				logger.Infow("purged realm chaff events", "count", count)
+				processed += count
+				result = enobs.ResultOK
+			}
+		}()
+
+		// Sandbox SMS messages
+		func() {
+			defer enobs.RecordLatency(ctx, time.Now(), mLatencyMs, &result, &item)
+			item = tag.Upsert(itemTagKey, "SANDBOX_SMS")
+			if count, err := c.db.PurgeSandboxSMS(c.config.SandboxSMSMaxAge); err != nil {
+				merr = multierror.Append(merr, fmt.Errorf("failed to purge sandbox sms: %w", err))
+				result = enobs.ResultError("FAILED")
+			} else {
+				logger.Infow("purged sandbox sms", "count", count)
+				processed += count
+				result = enobs.ResultOK
+			}
+		}()
+
+		// Callback deliveries
+		func() {
+			defer enobs.RecordLatency(ctx, time.Now(), mLatencyMs, &result, &item)
+			item = tag.Upsert(itemTagKey, "CALLBACK_DELIVERY")
+			if count, err := c.db.PurgeCallbackDeliveries(c.config.Callbacks.MaxAge); err != nil {
+				merr = multierror.Append(merr, fmt.Errorf("failed to purge callback deliveries: %w", err))
+				result = enobs.ResultError("FAILED")
+			} else {
+				logger.Infow("purged callback deliveries", "count", count)
+				processed += count
+				result = enobs.ResultOK
+			}
+		}()
+
+		// API captures
+		func() {
+			defer enobs.RecordLatency(ctx, time.Now(), mLatencyMs, &result, &item)
+			item = tag.Upsert(itemTagKey, "API_CAPTURE")
+			if count, err := c.db.PurgeAPICaptures(c.config.APICaptureMaxAge); err != nil {
+				merr = multierror.Append(merr, fmt.Errorf("failed to purge api captures: %w", err))
+				result = enobs.ResultError("FAILED")
+			} else {
+				logger.Infow("purged api captures", "count", count)
+				processed += count
+				result = enobs.ResultOK
+			}
+		}()
+
+		// Bulk issue jobs
+		func() {
+			defer enobs.RecordLatency(ctx, time.Now(), mLatencyMs, &result, &item)
+			item = tag.Upsert(itemTagKey, "BULK_ISSUE_JOB")
+			if count, err := c.db.PurgeBulkIssueJobs(c.config.BulkIssueJobMaxAge); err != nil {
+				merr = multierror.Append(merr, fmt.Errorf("failed to purge bulk issue jobs: %w", err))
+				result = enobs.ResultError("FAILED")
+			} else {
+				logger.Infow("purged bulk issue jobs", "count", count)
+				processed += count
+				result = enobs.ResultOK
+			}
+		}()
+
+		// Claim failures
+		func() {
+			defer enobs.RecordLatency(ctx, time.Now(), mLatencyMs, &result, &item)
+			item = tag.Upsert(itemTagKey, "CLAIM_FAILURE")
+			if count, err := c.db.PurgeClaimFailures(c.config.ClaimFailureMaxAge); err != nil {
+				merr = multierror.Append(merr, fmt.Errorf("failed to purge claim failures: %w", err))
+				result = enobs.ResultError("FAILED")
+			} else {
+				logger.Infow("purged claim failures", "count", count)
+				processed += count
+				result = enobs.ResultOK
+			}
+		}()
+
+		// System events
+		func() {
+			defer enobs.RecordLatency(ctx, time.Now(), mLatencyMs, &result, &item)
+			item = tag.Upsert(itemTagKey, "SYSTEM_EVENT")
+			if count, err := c.db.PurgeSystemEvents(c.config.SystemEventMaxAge); err != nil {
+				merr = multierror.Append(merr, fmt.Errorf("failed to purge system events: %w", err))
+				result = enobs.ResultError("FAILED")
+			} else {
+				logger.Infow("purged system events", "count", count)
+				processed += count
 				result = enobs.ResultOK
 			}
 		}()
@@ -284,6 +501,7 @@ func (c *Controller) HandleCleanup() http.Handler {
 				result = enobs.ResultError("FAILED")
 			} else {
 				logger.Infow("purged unclaimed user reports", "count", count)
+				processed += count
 				result = enobs.ResultOK
 			}
 		}()
@@ -297,6 +515,7 @@ func (c *Controller) HandleCleanup() http.Handler {
 				result = enobs.ResultError("FAILED")
 			} else {
 				logger.Infow("purged user stats", "count", count)
+				processed += count
 				result = enobs.ResultOK
 			}
 		}()
@@ -310,6 +529,7 @@ func (c *Controller) HandleCleanup() http.Handler {
 				result = enobs.ResultError("FAILED")
 			} else {
 				logger.Infow("purged user reports", "count", count)
+				processed += count
 				result = enobs.ResultOK
 			}
 		}()
@@ -317,10 +537,12 @@ func (c *Controller) HandleCleanup() http.Handler {
 		// If there are any errors, return them
 		if errs := merr.WrappedErrors(); len(errs) > 0 {
 			logger.Errorw("failed to cleanup", "errors", errs)
+			jobstatus.Record(ctx, c.db, jobstatus.JobCleanup, 0, merr)
 			c.h.RenderJSON(w, http.StatusInternalServerError, errs)
 			return
 		}
 
+		jobstatus.Record(ctx, c.db, jobstatus.JobCleanup, processed, nil)
 		stats.Record(ctx, mSuccess.M(1))
 		c.h.RenderJSON(w, http.StatusOK, nil)
 	})
@@ -53,6 +53,9 @@ func TestHandleCleanup(t *testing.T) {
 			TokenSigningKey: tokenSigningKey,
 		},
 		SigningTokenKeyMaxAge: 1 * time.Second,
+		RealmMaxWorkers:       2,
+		RealmTimeout:          1 * time.Minute,
+		BatchSize:             1,
 	}
 
 	t.Run("api_keys", func(t *testing.T) {
@@ -64,7 +67,7 @@ func TestHandleCleanup(t *testing.T) {
 			t.Fatal(err)
 		}
 
-		c := New(config, db, keyManagerSigner, h)
+		c := New(config, db, keyManagerSigner, nil, h)
 
 		authApp := &database.AuthorizedApp{
 			Name: "appy",
@@ -97,7 +100,7 @@ func TestHandleCleanup(t *testing.T) {
 			t.Fatal(err)
 		}
 
-		c := New(config, db, keyManagerSigner, h)
+		c := New(config, db, keyManagerSigner, nil, h)
 
 		code := &database.VerificationCode{
 			RealmID:       realm.ID,
@@ -131,6 +134,15 @@ func TestHandleCleanup(t *testing.T) {
 		if got, want := len(codes), 0; got != want {
 			t.Errorf("got %d codes, expected %d", got, want)
 		}
+
+		// The realm's progress marker is recorded.
+		progress, err := db.FindRealmCleanupProgress(realm.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := progress.Purged, int64(1); got != want {
+			t.Errorf("expected %d to be %d", got, want)
+		}
 	})
 
 	t.Run("verification_tokens", func(t *testing.T) {
@@ -138,9 +150,10 @@ func TestHandleCleanup(t *testing.T) {
 
 		db, _ := testDatabaseInstance.NewDatabase(t, nil)
 
-		c := New(config, db, keyManagerSigner, h)
+		c := New(config, db, keyManagerSigner, nil, h)
 
 		token := &database.Token{
+			RealmID:   1,
 			TestType:  "confirmed",
 			ExpiresAt: time.Now().UTC().Add(2 * time.Second),
 		}
@@ -178,7 +191,7 @@ func TestHandleCleanup(t *testing.T) {
 			t.Fatal(err)
 		}
 
-		c := New(config, db, keyManagerSigner, h)
+		c := New(config, db, keyManagerSigner, nil, h)
 
 		// Create users in the realm with the provided permissions
 		user1 := &database.User{
@@ -248,7 +261,7 @@ func TestHandleCleanup(t *testing.T) {
 			t.Fatal(err)
 		}
 
-		c := New(config, db, keyManagerSigner, h)
+		c := New(config, db, keyManagerSigner, nil, h)
 
 		app := &database.MobileApp{
 			Name:    "Appy",
@@ -281,7 +294,7 @@ func TestHandleCleanup(t *testing.T) {
 
 		db, _ := testDatabaseInstance.NewDatabase(t, nil)
 
-		c := New(config, db, keyManagerSigner, h)
+		c := New(config, db, keyManagerSigner, nil, h)
 
 		audit := database.BuildAuditEntry(database.SystemTest, "read", database.SystemTest, 0)
 		if err := db.RawDB().Save(audit).Error; err != nil {
@@ -305,7 +318,7 @@ func TestHandleCleanup(t *testing.T) {
 
 		db, _ := testDatabaseInstance.NewDatabase(t, nil)
 
-		c := New(config, db, keyManagerSigner, h)
+		c := New(config, db, keyManagerSigner, nil, h)
 
 		user := &database.User{
 			Name:  "User",
@@ -344,7 +357,7 @@ func TestHandleCleanup(t *testing.T) {
 			t.Fatal(err)
 		}
 
-		c := New(config, db, keyManagerSigner, h)
+		c := New(config, db, keyManagerSigner, nil, h)
 
 		w, r := envstest.BuildJSONRequest(ctx, t, http.MethodGet, "/", nil)
 		c.HandleCleanup().ServeHTTP(w, r)
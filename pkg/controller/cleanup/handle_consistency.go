@@ -0,0 +1,103 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cleanup
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/google/exposure-notifications-server/pkg/keys"
+	"github.com/google/exposure-notifications-server/pkg/logging"
+	"github.com/google/exposure-notifications-verification-server/pkg/controller/jobstatus"
+	"github.com/google/exposure-notifications-verification-server/pkg/database"
+	"go.opencensus.io/stats"
+	"go.opencensus.io/tag"
+)
+
+// HandleConsistency runs the database consistency checks and records any
+// findings in the system audit log for system admins. It never modifies data,
+// since findings usually indicate a bug that should be investigated first.
+func (c *Controller) HandleConsistency() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		logger := logging.FromContext(ctx).Named("cleanup.HandleConsistency")
+		logger.Debugw("starting")
+		defer logger.Debugw("finishing")
+
+		ok, err := c.db.TryLock(ctx, consistencyName, c.config.ConsistencyMinPeriod)
+		if err != nil {
+			logger.Errorw("failed to acquire lock", "error", err)
+			c.h.RenderJSON(w, http.StatusInternalServerError, err)
+			return
+		}
+		if !ok {
+			logger.Debugw("skipping (too early)")
+			jobstatus.RecordFreshness(ctx, c.db, jobstatus.JobConsistency)
+			c.h.RenderJSON(w, http.StatusOK, fmt.Errorf("too early"))
+			return
+		}
+
+		findings, err := c.db.CheckConsistency()
+		if err != nil {
+			logger.Errorw("failed to check consistency", "error", err)
+			jobstatus.Record(ctx, c.db, jobstatus.JobConsistency, 0, err)
+			c.h.RenderJSON(w, http.StatusInternalServerError, err)
+			return
+		}
+
+		// The token signing key manager is created as a keys.KeyManager, so this
+		// only fails if it was replaced with a narrower implementation.
+		kms, ok := c.signingTokenKeyManager.(keys.KeyManager)
+		if !ok {
+			err := fmt.Errorf("token signing key manager cannot create signers (is %T)", c.signingTokenKeyManager)
+			logger.Errorw("failed to check token signing key", "error", err)
+			jobstatus.Record(ctx, c.db, jobstatus.JobConsistency, 0, err)
+			c.h.RenderJSON(w, http.StatusInternalServerError, err)
+			return
+		}
+
+		finding, err := c.db.CheckTokenSigningKeyConsistency(ctx, kms)
+		if err != nil {
+			logger.Errorw("failed to check token signing key", "error", err)
+			jobstatus.Record(ctx, c.db, jobstatus.JobConsistency, 0, err)
+			c.h.RenderJSON(w, http.StatusInternalServerError, err)
+			return
+		}
+		if finding != nil {
+			findings = append(findings, finding)
+		}
+
+		if err := c.db.RecordConsistencyFindings(findings); err != nil {
+			logger.Errorw("failed to record consistency findings", "error", err)
+			jobstatus.Record(ctx, c.db, jobstatus.JobConsistency, 0, err)
+			c.h.RenderJSON(w, http.StatusInternalServerError, err)
+			return
+		}
+
+		counts := make(map[string]int64, len(database.ConsistencyChecks))
+		for _, f := range findings {
+			logger.Warnw("consistency check finding", "check", f.Check, "count", f.Count, "message", f.Message)
+			counts[f.Check] += f.Count
+		}
+		for _, check := range database.ConsistencyChecks {
+			stats.RecordWithTags(ctx, []tag.Mutator{tag.Upsert(checkTagKey, check)},
+				mConsistencyFindings.M(counts[check]))
+		}
+
+		jobstatus.Record(ctx, c.db, jobstatus.JobConsistency, int64(len(findings)), nil)
+		c.h.RenderJSON(w, http.StatusOK, map[string]interface{}{"findings": findings})
+	})
+}
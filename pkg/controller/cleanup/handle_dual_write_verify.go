@@ -0,0 +1,89 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cleanup
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/google/exposure-notifications-server/pkg/logging"
+	"github.com/google/exposure-notifications-verification-server/pkg/controller/jobstatus"
+	"github.com/google/exposure-notifications-verification-server/pkg/database"
+)
+
+// HandleDualWriteVerify compares the primary and dual-write secondary databases
+// and records the number of diverged tables as a metric. It does nothing if
+// dual-write is not enabled. The comparison reads every row of every table, so
+// it is rate limited by DUAL_WRITE_VERIFY_MIN_PERIOD.
+func (c *Controller) HandleDualWriteVerify() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		logger := logging.FromContext(ctx).Named("cleanup.HandleDualWriteVerify")
+		logger.Debugw("starting")
+		defer logger.Debugw("finishing")
+
+		if !c.db.DualWriteEnabled() {
+			logger.Debugw("skipping (dual-write is not enabled)")
+			c.h.RenderJSON(w, http.StatusOK, database.ErrDualWriteDisabled)
+			return
+		}
+
+		ok, err := c.db.TryLock(ctx, dualWriteVerifyName, c.config.DualWriteVerifyMinPeriod)
+		if err != nil {
+			logger.Errorw("failed to acquire lock", "error", err)
+			c.h.RenderJSON(w, http.StatusInternalServerError, err)
+			return
+		}
+		if !ok {
+			logger.Debugw("skipping (too early)")
+			jobstatus.RecordFreshness(ctx, c.db, jobstatus.JobDualWriteVerify)
+			c.h.RenderJSON(w, http.StatusOK, fmt.Errorf("too early"))
+			return
+		}
+
+		results, err := c.db.VerifyDualWrite(ctx)
+		if err != nil {
+			// Dual-write may have been disabled between the check and now.
+			if errors.Is(err, database.ErrDualWriteDisabled) {
+				c.h.RenderJSON(w, http.StatusOK, err)
+				return
+			}
+
+			logger.Errorw("failed to verify dual-write", "error", err)
+			jobstatus.Record(ctx, c.db, jobstatus.JobDualWriteVerify, 0, err)
+			c.h.RenderJSON(w, http.StatusInternalServerError, err)
+			return
+		}
+
+		diverged := make([]*database.DualWriteTableResult, 0, len(results))
+		for _, result := range results {
+			if result.Diverged() {
+				logger.Warnw("dual-write table diverged",
+					"table", result.Table,
+					"primary_rows", result.PrimaryRows,
+					"secondary_rows", result.SecondaryRows)
+				diverged = append(diverged, result)
+			}
+		}
+
+		jobstatus.Record(ctx, c.db, jobstatus.JobDualWriteVerify, int64(len(results)), nil)
+		c.h.RenderJSON(w, http.StatusOK, map[string]interface{}{
+			"tables":   len(results),
+			"diverged": diverged,
+		})
+	})
+}
@@ -0,0 +1,99 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cleanup
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/google/exposure-notifications-verification-server/internal/envstest"
+	"github.com/google/exposure-notifications-verification-server/internal/project"
+	"github.com/google/exposure-notifications-verification-server/pkg/config"
+	"github.com/google/exposure-notifications-verification-server/pkg/controller/jobstatus"
+	"github.com/google/exposure-notifications-verification-server/pkg/database"
+	"github.com/google/exposure-notifications-verification-server/pkg/render"
+)
+
+func TestHandleDualWriteVerify(t *testing.T) {
+	t.Parallel()
+
+	ctx := project.TestContext(t)
+
+	h, err := render.New(ctx, nil, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := &config.CleanupConfig{
+		DualWriteVerifyMinPeriod: time.Hour,
+	}
+
+	t.Run("disabled", func(t *testing.T) {
+		t.Parallel()
+
+		db, _ := testDatabaseInstance.NewDatabase(t, nil)
+		c := New(cfg, db, nil, nil, h)
+
+		w, r := envstest.BuildJSONRequest(ctx, t, http.MethodGet, "/dual-write-verify", nil)
+		c.HandleDualWriteVerify().ServeHTTP(w, r)
+
+		if got, want := w.Code, http.StatusOK; got != want {
+			t.Fatalf("expected %d to be %d: %s", got, want, w.Body.String())
+		}
+
+		// Nothing ran, so no status is recorded.
+		if _, err := db.FindJobStatus(jobstatus.JobDualWriteVerify); !database.IsNotFound(err) {
+			t.Errorf("expected not found, got %v", err)
+		}
+	})
+
+	t.Run("enabled", func(t *testing.T) {
+		t.Parallel()
+
+		_, secondaryConfig := testDatabaseInstance.NewDatabase(t, nil)
+		db, _ := testDatabaseInstance.NewDatabase(t, nil, func(db *database.Database, c *database.Config) (*database.Database, *database.Config) {
+			c.DualWriteDSN = secondaryConfig.ConnectionString()
+			return db, c
+		})
+		c := New(cfg, db, nil, nil, h)
+
+		w, r := envstest.BuildJSONRequest(ctx, t, http.MethodGet, "/dual-write-verify", nil)
+		c.HandleDualWriteVerify().ServeHTTP(w, r)
+
+		if got, want := w.Code, http.StatusOK; got != want {
+			t.Fatalf("expected %d to be %d: %s", got, want, w.Body.String())
+		}
+
+		var resp struct {
+			Tables int `json:"tables"`
+		}
+		if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+			t.Fatal(err)
+		}
+		if resp.Tables == 0 {
+			t.Errorf("expected tables to be compared")
+		}
+
+		status, err := db.FindJobStatus(jobstatus.JobDualWriteVerify)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := status.LastProcessed, int64(resp.Tables); got != want {
+			t.Errorf("expected last processed %d to be %d", got, want)
+		}
+	})
+}
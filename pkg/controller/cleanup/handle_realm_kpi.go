@@ -0,0 +1,59 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cleanup
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/google/exposure-notifications-server/pkg/logging"
+	"github.com/google/exposure-notifications-verification-server/pkg/controller/jobstatus"
+	"github.com/google/exposure-notifications-verification-server/pkg/observability"
+	"go.opencensus.io/stats"
+)
+
+// HandleRealmKPI exports the per-realm business indicators for the current UTC
+// day as gauges tagged with the realm, so operators can alert on business
+// anomalies (e.g. a falling claim rate) in their monitoring stack. The
+// indicators are also returned in the response body.
+func (c *Controller) HandleRealmKPI() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		logger := logging.FromContext(ctx).Named("cleanup.HandleRealmKPI")
+		logger.Debugw("starting")
+		defer logger.Debugw("finishing")
+
+		kpis, err := c.db.ListRealmKPIs(time.Now())
+		if err != nil {
+			logger.Errorw("failed to list realm kpis", "error", err)
+			jobstatus.Record(ctx, c.db, jobstatus.JobRealmKPI, 0, err)
+			c.h.RenderJSON(w, http.StatusInternalServerError, err)
+			return
+		}
+
+		for _, kpi := range kpis {
+			ctx := observability.WithRealmID(ctx, uint64(kpi.RealmID))
+			stats.Record(ctx,
+				mKPICodesIssued.M(int64(kpi.CodesIssued)),
+				mKPIClaimRate.M(kpi.ClaimRate()),
+				mKPIUserReportShare.M(kpi.UserReportShare()),
+				mKPISMSFailureRate.M(kpi.SMSFailureRate()))
+		}
+
+		jobstatus.Record(ctx, c.db, jobstatus.JobRealmKPI, int64(len(kpis)), nil)
+		c.h.RenderJSON(w, http.StatusOK, map[string]interface{}{"realms": kpis})
+	})
+}
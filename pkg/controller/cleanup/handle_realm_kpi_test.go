@@ -0,0 +1,98 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cleanup
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/google/exposure-notifications-server/pkg/timeutils"
+	"github.com/google/exposure-notifications-verification-server/internal/envstest"
+	"github.com/google/exposure-notifications-verification-server/internal/project"
+	"github.com/google/exposure-notifications-verification-server/pkg/config"
+	"github.com/google/exposure-notifications-verification-server/pkg/controller/jobstatus"
+	"github.com/google/exposure-notifications-verification-server/pkg/database"
+	"github.com/google/exposure-notifications-verification-server/pkg/render"
+)
+
+func TestHandleRealmKPI(t *testing.T) {
+	t.Parallel()
+
+	ctx := project.TestContext(t)
+
+	h, err := render.New(ctx, nil, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db, _ := testDatabaseInstance.NewDatabase(t, nil)
+	realm, err := db.FindRealm(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := db.RawDB().Create(&database.RealmStat{
+		Date:         timeutils.UTCMidnight(time.Now()),
+		RealmID:      realm.ID,
+		CodesIssued:  10,
+		CodesClaimed: 3,
+	}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	c := New(&config.CleanupConfig{}, db, nil, nil, h)
+
+	w, r := envstest.BuildJSONRequest(ctx, t, http.MethodGet, "/realm-kpi", nil)
+	c.HandleRealmKPI().ServeHTTP(w, r)
+
+	if got, want := w.Code, http.StatusOK; got != want {
+		t.Fatalf("expected %d to be %d: %s", got, want, w.Body.String())
+	}
+
+	var resp struct {
+		Realms []*database.RealmKPI `json:"realms"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+
+	var found bool
+	for _, kpi := range resp.Realms {
+		if kpi.RealmID != realm.ID {
+			continue
+		}
+		found = true
+
+		if got, want := kpi.CodesIssued, uint(10); got != want {
+			t.Errorf("expected codes issued %d to be %d", got, want)
+		}
+		if got, want := kpi.ClaimRate(), 0.3; got != want {
+			t.Errorf("expected claim rate %v to be %v", got, want)
+		}
+	}
+	if !found {
+		t.Errorf("expected realm %d in %#v", realm.ID, resp.Realms)
+	}
+
+	status, err := db.FindJobStatus(jobstatus.JobRealmKPI)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := status.LastProcessed, int64(len(resp.Realms)); got != want {
+		t.Errorf("expected last processed %d to be %d", got, want)
+	}
+}
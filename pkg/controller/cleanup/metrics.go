@@ -24,12 +24,26 @@ import (
 	"go.opencensus.io/tag"
 )
 
-const metricPrefix = observability.MetricRoot + "/cleanup"
+const (
+	metricPrefix    = observability.MetricRoot + "/cleanup"
+	kpiMetricPrefix = observability.MetricRoot + "/realm_kpi"
+)
 
 var (
 	mClaimRequests = stats.Int64(metricPrefix+"/claim_requests", "The number of cleanup claim requests.", stats.UnitDimensionless)
 	mLatencyMs     = stats.Float64(metricPrefix+"/requests", "The number of cleanup requests.", stats.UnitMilliseconds)
 	mSuccess       = stats.Int64(metricPrefix+"/success", "successful execution", stats.UnitDimensionless)
+
+	mRealmLatencyMs   = stats.Float64(metricPrefix+"/realm_requests", "The number of per-realm cleanup requests.", stats.UnitMilliseconds)
+	mRealmPurged      = stats.Int64(metricPrefix+"/realm_purged", "The number of records purged for a realm.", stats.UnitDimensionless)
+	mRealmsIncomplete = stats.Int64(metricPrefix+"/realms_incomplete", "The number of realms not finished before the cleanup deadline.", stats.UnitDimensionless)
+
+	mConsistencyFindings = stats.Int64(metricPrefix+"/consistency_findings", "The number of rows affected by a consistency check finding.", stats.UnitDimensionless)
+
+	mKPICodesIssued     = stats.Int64(kpiMetricPrefix+"/codes_issued", "The number of codes issued by the realm today.", stats.UnitDimensionless)
+	mKPIClaimRate       = stats.Float64(kpiMetricPrefix+"/claim_rate", "The fraction of codes issued today that were claimed.", stats.UnitDimensionless)
+	mKPIUserReportShare = stats.Float64(kpiMetricPrefix+"/user_report_share", "The fraction of codes issued today that were user reports.", stats.UnitDimensionless)
+	mKPISMSFailureRate  = stats.Float64(kpiMetricPrefix+"/sms_failure_rate", "The number of SMS errors today relative to codes issued.", stats.UnitDimensionless)
 )
 
 // itemTagKey indicating what type of items is cleaned up in this step.
@@ -40,6 +54,9 @@ var (
 // AUDIT_ENTRY
 var itemTagKey = tag.MustNewKey("item")
 
+// checkTagKey is the name of the consistency check that produced a finding.
+var checkTagKey = tag.MustNewKey("check")
+
 func init() {
 	enobs.CollectViews([]*view.View{
 		{
@@ -56,6 +73,34 @@ func init() {
 			TagKeys:     append(observability.CommonTagKeys(), enobs.ResultTagKey, itemTagKey),
 			Aggregation: ochttp.DefaultLatencyDistribution,
 		},
+		{
+			Name:        metricPrefix + "/realm_requests_count",
+			Measure:     mRealmLatencyMs,
+			Description: "The count of the per-realm cleanup requests",
+			TagKeys:     append(observability.CommonTagKeys(), enobs.ResultTagKey, itemTagKey),
+			Aggregation: view.Count(),
+		},
+		{
+			Name:        metricPrefix + "/realm_requests_latency",
+			Measure:     mRealmLatencyMs,
+			Description: "The latency distribution of the per-realm cleanup requests",
+			TagKeys:     append(observability.CommonTagKeys(), enobs.ResultTagKey, itemTagKey),
+			Aggregation: ochttp.DefaultLatencyDistribution,
+		},
+		{
+			Name:        metricPrefix + "/realm_purged",
+			Measure:     mRealmPurged,
+			Description: "The total number of records purged for each realm",
+			TagKeys:     append(observability.CommonTagKeys(), itemTagKey),
+			Aggregation: view.Sum(),
+		},
+		{
+			Name:        metricPrefix + "/realms_incomplete",
+			Measure:     mRealmsIncomplete,
+			Description: "The number of realms not finished before the deadline in the latest run",
+			TagKeys:     observability.CommonTagKeys(),
+			Aggregation: view.LastValue(),
+		},
 		{
 			Name:        metricPrefix + "/claim_requests_count",
 			Measure:     mClaimRequests,
@@ -70,5 +115,40 @@ func init() {
 			Measure:     mSuccess,
 			Aggregation: view.Count(),
 		},
+		{
+			Name:        metricPrefix + "/consistency_findings",
+			Description: "The number of rows affected by each consistency check finding in the latest run",
+			TagKeys:     append(observability.CommonTagKeys(), checkTagKey),
+			Measure:     mConsistencyFindings,
+			Aggregation: view.LastValue(),
+		},
+		{
+			Name:        kpiMetricPrefix + "/codes_issued",
+			Description: "The number of codes issued by each realm today",
+			TagKeys:     observability.CommonTagKeys(),
+			Measure:     mKPICodesIssued,
+			Aggregation: view.LastValue(),
+		},
+		{
+			Name:        kpiMetricPrefix + "/claim_rate",
+			Description: "The fraction of codes issued by each realm today that were claimed",
+			TagKeys:     observability.CommonTagKeys(),
+			Measure:     mKPIClaimRate,
+			Aggregation: view.LastValue(),
+		},
+		{
+			Name:        kpiMetricPrefix + "/user_report_share",
+			Description: "The fraction of codes issued by each realm today that were user reports",
+			TagKeys:     observability.CommonTagKeys(),
+			Measure:     mKPIUserReportShare,
+			Aggregation: view.LastValue(),
+		},
+		{
+			Name:        kpiMetricPrefix + "/sms_failure_rate",
+			Description: "The number of SMS errors for each realm today relative to codes issued",
+			TagKeys:     observability.CommonTagKeys(),
+			Measure:     mKPISMSFailureRate,
+			Aggregation: view.LastValue(),
+		},
 	}...)
 }
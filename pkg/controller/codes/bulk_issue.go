@@ -15,13 +15,27 @@
 package codes
 
 import (
+	"fmt"
 	"net/http"
+	"strconv"
 
 	"github.com/google/exposure-notifications-verification-server/pkg/controller"
+	"github.com/google/exposure-notifications-verification-server/pkg/database"
 	"github.com/google/exposure-notifications-verification-server/pkg/rbac"
+	"github.com/gorilla/mux"
 )
 
-// HandleBulkIssue shows the page for bulk-issuing codes.
+const (
+	// recentBulkIssueJobs is the number of jobs listed on the bulk issue page.
+	recentBulkIssueJobs = 10
+
+	// maxFailedRowsShown is the number of failed rows shown on a job's page.
+	// Every row is in the downloadable report.
+	maxFailedRowsShown = 100
+)
+
+// HandleBulkIssue shows the page for bulk-issuing codes and the realm's recent
+// bulk issue jobs.
 func (c *Controller) HandleBulkIssue() http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		ctx := r.Context()
@@ -65,9 +79,199 @@ func (c *Controller) HandleBulkIssue() http.Handler {
 			flash.Error(t.Get("codes.bulk-issue.no-sms-provider"))
 		}
 
+		jobs, err := currentRealm.ListBulkIssueJobs(c.db, recentBulkIssueJobs)
+		if err != nil {
+			controller.InternalError(w, r, c.h, err)
+			return
+		}
+
 		m := controller.TemplateMapFromContext(ctx)
 		m["hasSMSConfig"] = hasSMSConfig
+		m["jobs"] = jobs
+		m["maxRows"] = c.serverconfig.BulkIssue.MaxRows
 		m.Title("Bulk issue codes")
 		c.h.RenderHTML(w, "codes/bulk-issue", m)
 	})
 }
+
+// HandleBulkIssueCreate accepts an uploaded CSV file and creates a bulk issue
+// job. Codes are issued by a background worker, so the upload does not depend
+// on the browser staying open.
+func (c *Controller) HandleBulkIssueCreate() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		session := controller.SessionFromContext(ctx)
+		if session == nil {
+			controller.MissingSession(w, r, c.h)
+			return
+		}
+		flash := controller.Flash(session)
+
+		membership := controller.MembershipFromContext(ctx)
+		if membership == nil {
+			controller.MissingMembership(w, r, c.h)
+			return
+		}
+		if !membership.Can(rbac.CodeBulkIssue) {
+			controller.Unauthorized(w, r, c.h)
+			return
+		}
+
+		currentRealm := membership.Realm
+		currentUser := membership.User
+
+		if !currentRealm.AllowBulkUpload {
+			flash.Error("That feature is not enabled for your realm!")
+			controller.Back(w, r, c.h)
+			return
+		}
+
+		hasSMSConfig, err := currentRealm.HasSMSConfig(c.db)
+		if err != nil {
+			controller.InternalError(w, r, c.h, err)
+			return
+		}
+		if !hasSMSConfig {
+			t := controller.LocaleFromContext(ctx)
+			if t == nil {
+				controller.MissingLocale(w, r, c.h)
+				return
+			}
+			flash.Error(t.Get("codes.bulk-issue.no-sms-provider"))
+			controller.Back(w, r, c.h)
+			return
+		}
+
+		label := r.FormValue("smsTemplateLabel")
+		if _, err := currentRealm.SMSTextTemplateFor(label, ""); err != nil {
+			flash.Error("Failed to create bulk issue job: %v", err)
+			controller.Back(w, r, c.h)
+			return
+		}
+
+		var tzOffset float64
+		if v := r.FormValue("tzOffset"); v != "" {
+			tzOffset, err = strconv.ParseFloat(v, 32)
+			if err != nil {
+				flash.Error("Failed to create bulk issue job: invalid timezone offset")
+				controller.Back(w, r, c.h)
+				return
+			}
+		}
+
+		file, header, err := r.FormFile("csv")
+		if err != nil {
+			flash.Error("Failed to create bulk issue job: missing CSV file")
+			controller.Back(w, r, c.h)
+			return
+		}
+		defer file.Close()
+
+		if limit := c.serverconfig.BodyLimits.BatchIssue; header.Size > limit {
+			flash.Error("Failed to create bulk issue job: file is larger than %d bytes", limit)
+			controller.Back(w, r, c.h)
+			return
+		}
+
+		rows, err := parseBulkIssueCSV(file, c.serverconfig.BulkIssue.MaxRows)
+		if err != nil {
+			flash.Error("Failed to create bulk issue job: %v", err)
+			controller.Back(w, r, c.h)
+			return
+		}
+
+		job := &database.BulkIssueJob{
+			RealmID:          currentRealm.ID,
+			UserID:           currentUser.ID,
+			Filename:         header.Filename,
+			SMSTemplateLabel: label,
+			TZOffset:         float32(tzOffset),
+			Rows:             rows,
+		}
+		if err := c.db.CreateBulkIssueJob(job, currentUser); err != nil {
+			if database.IsValidationError(err) {
+				flash.Error("Failed to create bulk issue job: %v", err)
+				controller.Back(w, r, c.h)
+				return
+			}
+
+			controller.InternalError(w, r, c.h, err)
+			return
+		}
+
+		flash.Alert("Queued %d rows for bulk issue", job.Total)
+		http.Redirect(w, r, fmt.Sprintf("/codes/bulk-issue/jobs/%d", job.ID), http.StatusSeeOther)
+	})
+}
+
+// HandleBulkIssueShow shows the progress of a bulk issue job.
+func (c *Controller) HandleBulkIssueShow() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		job, ok := c.findBulkIssueJob(w, r)
+		if !ok {
+			return
+		}
+
+		var failed []*database.BulkIssueJobRow
+		for _, row := range job.Rows {
+			if len(failed) >= maxFailedRowsShown {
+				break
+			}
+			if row.Status == database.BulkIssueRowFailed {
+				failed = append(failed, row)
+			}
+		}
+
+		m := controller.TemplateMapFromContext(ctx)
+		m["job"] = job
+		m["failedRows"] = failed
+		m.Title("Bulk issue job")
+		c.h.RenderHTML(w, "codes/bulk-issue-job", m)
+	})
+}
+
+// HandleBulkIssueReport downloads the results of a bulk issue job as CSV.
+func (c *Controller) HandleBulkIssueReport() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		job, ok := c.findBulkIssueJob(w, r)
+		if !ok {
+			return
+		}
+
+		filename := fmt.Sprintf("bulk-issue-%d-%s.csv", job.ID, job.CreatedAt.UTC().Format("2006-01-02"))
+		c.h.RenderCSV(w, http.StatusOK, filename, database.BulkIssueJobRows(job.Rows))
+	})
+}
+
+// findBulkIssueJob checks permissions and loads the bulk issue job in the
+// request path. If it returns false, a response has been rendered.
+func (c *Controller) findBulkIssueJob(w http.ResponseWriter, r *http.Request) (*database.BulkIssueJob, bool) {
+	ctx := r.Context()
+	vars := mux.Vars(r)
+
+	membership := controller.MembershipFromContext(ctx)
+	if membership == nil {
+		controller.MissingMembership(w, r, c.h)
+		return nil, false
+	}
+	if !membership.Can(rbac.CodeBulkIssue) {
+		controller.Unauthorized(w, r, c.h)
+		return nil, false
+	}
+	currentRealm := membership.Realm
+
+	job, err := currentRealm.FindBulkIssueJob(c.db, vars["id"])
+	if err != nil {
+		if database.IsNotFound(err) {
+			controller.Unauthorized(w, r, c.h)
+			return nil, false
+		}
+
+		controller.InternalError(w, r, c.h, err)
+		return nil, false
+	}
+	return job, true
+}
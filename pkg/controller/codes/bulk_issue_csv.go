@@ -0,0 +1,154 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package codes
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/google/exposure-notifications-verification-server/pkg/api"
+	"github.com/google/exposure-notifications-verification-server/pkg/database"
+	"github.com/google/uuid"
+)
+
+const (
+	// bulkIssueReportUUIDColumn and bulkIssueReportStatusColumn are the columns
+	// of the tracking UUID and error code (or "success") in a results report,
+	// see database.BulkIssueJobRows. When a report is uploaded again, rows keep
+	// their UUID so codes are not issued twice, and successful rows are skipped.
+	bulkIssueReportUUIDColumn   = 6
+	bulkIssueReportStatusColumn = 7
+
+	bulkIssueReportSuccess = "success"
+)
+
+// parseBulkIssueCSV parses an uploaded bulk issue file into job rows. The file
+// has the columns phone,testDate,[symptomDate],[testType], optionally followed
+// by the columns of a results report. Files saved by Excel are supported: a
+// leading byte order mark is ignored and semicolon or tab delimiters are
+// detected. A header row and blank lines are skipped.
+func parseBulkIssueCSV(r io.Reader, maxRows uint) ([]*database.BulkIssueJobRow, error) {
+	br := bufio.NewReader(r)
+
+	// Strip the UTF-8 byte order mark.
+	if b, err := br.Peek(3); err == nil && bytes.Equal(b, []byte{0xEF, 0xBB, 0xBF}) {
+		if _, err := br.Discard(3); err != nil {
+			return nil, fmt.Errorf("failed to read file: %w", err)
+		}
+	}
+
+	// Detect the delimiter from the first line.
+	first, err := br.Peek(br.Size())
+	if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, bufio.ErrBufferFull) {
+		return nil, fmt.Errorf("failed to read file: %w", err)
+	}
+	if i := bytes.IndexByte(first, '\n'); i >= 0 {
+		first = first[:i]
+	}
+
+	reader := csv.NewReader(br)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	reader.LazyQuotes = true
+	if !bytes.ContainsRune(first, ',') {
+		switch {
+		case bytes.ContainsRune(first, ';'):
+			reader.Comma = ';'
+		case bytes.ContainsRune(first, '\t'):
+			reader.Comma = '\t'
+		}
+	}
+
+	rows := make([]*database.BulkIssueJobRow, 0, 64)
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse file: %w", err)
+		}
+		line, _ := reader.FieldPos(0)
+
+		for i := range record {
+			record[i] = strings.TrimSpace(record[i])
+		}
+		if isBlankRecord(record) {
+			continue
+		}
+		if len(rows) == 0 && strings.EqualFold(record[0], "phone") {
+			continue
+		}
+
+		if column(record, bulkIssueReportStatusColumn) == bulkIssueReportSuccess {
+			continue
+		}
+
+		if uint(len(rows)) >= maxRows {
+			return nil, fmt.Errorf("file has more than %d rows", maxRows)
+		}
+
+		row := &database.BulkIssueJobRow{
+			Line:        line,
+			Phone:       record[0],
+			TestDate:    column(record, 1),
+			SymptomDate: column(record, 2),
+			TestType:    column(record, 3),
+			UUID:        column(record, bulkIssueReportUUIDColumn),
+			Status:      database.BulkIssueRowPending,
+		}
+		if row.TestType == "" {
+			row.TestType = "confirmed"
+		}
+		if len(row.UUID) != 36 {
+			row.UUID = uuid.New().String()
+		}
+		if row.Phone == "" {
+			row.Status = database.BulkIssueRowFailed
+			row.ErrorCode = api.ErrPhoneNumberInvalid
+			row.Error = "phone number missing"
+		}
+
+		rows = append(rows, row)
+	}
+
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("file has no rows")
+	}
+	return rows, nil
+}
+
+// column returns the i-th column of the record, or the empty string if the
+// record is too short.
+func column(record []string, i int) string {
+	if i < len(record) {
+		return record[i]
+	}
+	return ""
+}
+
+func isBlankRecord(record []string) bool {
+	for _, v := range record {
+		if v != "" {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,132 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package codes
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/exposure-notifications-verification-server/pkg/database"
+)
+
+func TestParseBulkIssueCSV(t *testing.T) {
+	t.Parallel()
+
+	existingUUID := "5a3b4c9e-2b8d-4f7a-9c1e-0d6f5a7b8c9d"
+
+	type row struct {
+		line     int
+		phone    string
+		testDate string
+		testType string
+		status   string
+	}
+
+	cases := []struct {
+		name    string
+		input   string
+		maxRows uint
+		exp     []row
+		err     string
+	}{
+		{
+			name:    "comma",
+			input:   "+12065550100,2022-11-01\n+12065550101,2022-11-02,2022-11-01,likely\n",
+			maxRows: 10,
+			exp: []row{
+				{1, "+12065550100", "2022-11-01", "confirmed", database.BulkIssueRowPending},
+				{2, "+12065550101", "2022-11-02", "likely", database.BulkIssueRowPending},
+			},
+		},
+		{
+			name:    "excel_semicolon_bom_header",
+			input:   "\ufeffPhone;Test date\r\n+12065550100;2022-11-01\r\n\r\n;2022-11-02\r\n",
+			maxRows: 10,
+			exp: []row{
+				{2, "+12065550100", "2022-11-01", "confirmed", database.BulkIssueRowPending},
+				{4, "", "2022-11-02", "confirmed", database.BulkIssueRowFailed},
+			},
+		},
+		{
+			name:    "tab",
+			input:   "+12065550100\t2022-11-01\n",
+			maxRows: 10,
+			exp: []row{
+				{1, "+12065550100", "2022-11-01", "confirmed", database.BulkIssueRowPending},
+			},
+		},
+		{
+			name: "report",
+			input: "phone,testDate,symptomDate,testType,line,attempts,uuid,errorCode,error\n" +
+				"+12065550100,2022-11-01,,confirmed,2,1,5a3b4c9e-0000-4f7a-9c1e-0d6f5a7b8c9d,success,\n" +
+				"+12065550101,2022-11-01,,confirmed,3,1," + existingUUID + ",sms_failure,oops\n",
+			maxRows: 10,
+			exp: []row{
+				{3, "+12065550101", "2022-11-01", "confirmed", database.BulkIssueRowPending},
+			},
+		},
+		{
+			name:    "too_many_rows",
+			input:   "+12065550100,2022-11-01\n+12065550101,2022-11-01\n",
+			maxRows: 1,
+			err:     "more than 1 rows",
+		},
+		{
+			name:    "empty",
+			input:   "phone,testDate\n\n",
+			maxRows: 10,
+			err:     "no rows",
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			rows, err := parseBulkIssueCSV(strings.NewReader(tc.input), tc.maxRows)
+			if tc.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.err) {
+					t.Fatalf("expected %q to contain %q", err, tc.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got, want := len(rows), len(tc.exp); got != want {
+				t.Fatalf("expected %d rows to be %d", got, want)
+			}
+			for i, exp := range tc.exp {
+				got := rows[i]
+				if got.Line != exp.line || got.Phone != exp.phone || got.TestDate != exp.testDate ||
+					got.TestType != exp.testType || got.Status != exp.status {
+					t.Errorf("row %d: expected %#v to match %#v", i, got, exp)
+				}
+				if len(got.UUID) != 36 {
+					t.Errorf("row %d: expected a uuid, got %q", i, got.UUID)
+				}
+			}
+
+			if tc.name == "report" {
+				if got, want := rows[0].UUID, existingUUID; got != want {
+					t.Errorf("expected %q to be %q", got, want)
+				}
+			}
+		})
+	}
+}
@@ -15,7 +15,13 @@
 package codes_test
 
 import (
+	"bytes"
+	"context"
+	"fmt"
+	"mime/multipart"
 	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
 
 	"github.com/google/exposure-notifications-verification-server/internal/envstest"
@@ -25,6 +31,7 @@ import (
 	"github.com/google/exposure-notifications-verification-server/pkg/database"
 	"github.com/google/exposure-notifications-verification-server/pkg/rbac"
 	"github.com/google/exposure-notifications-verification-server/pkg/sms"
+	"github.com/gorilla/mux"
 	"github.com/gorilla/sessions"
 )
 
@@ -154,3 +161,228 @@ func TestRenderBulkIssue(t *testing.T) {
 		}
 	})
 }
+
+func TestHandleBulkIssueCreate(t *testing.T) {
+	t.Parallel()
+
+	ctx := project.TestContext(t)
+
+	harness := envstest.NewServerConfig(t, testDatabaseInstance)
+
+	c := codes.NewServer(harness.Config, harness.Database, harness.Renderer)
+	handler := harness.WithCommonMiddlewares(c.HandleBulkIssueCreate())
+
+	realm, err := harness.Database.FindRealm(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	realm.AllowBulkUpload = true
+	if err := harness.Database.SaveRealm(realm, database.SystemTest); err != nil {
+		t.Fatal(err)
+	}
+	if err := harness.Database.SaveSMSConfig(&database.SMSConfig{
+		RealmID:          realm.ID,
+		ProviderType:     sms.ProviderType("TWILIO"),
+		TwilioAccountSid: "abc123",
+		TwilioAuthToken:  "def123",
+		TwilioFromNumber: "+11234567890",
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	user := &database.User{
+		Name:  "Tester",
+		Email: "bulk-issue-create@example.com",
+	}
+	if err := harness.Database.SaveUser(user, database.SystemTest); err != nil {
+		t.Fatal(err)
+	}
+
+	membership := &database.Membership{
+		Realm:       realm,
+		User:        user,
+		Permissions: rbac.CodeBulkIssue,
+	}
+
+	t.Run("middleware", func(t *testing.T) {
+		t.Parallel()
+
+		envstest.ExerciseSessionMissing(t, handler)
+		envstest.ExerciseMembershipMissing(t, handler)
+		envstest.ExercisePermissionMissing(t, handler)
+	})
+
+	t.Run("invalid_csv", func(t *testing.T) {
+		t.Parallel()
+
+		ctx := ctx
+		ctx = controller.WithSession(ctx, &sessions.Session{})
+		ctx = controller.WithMembership(ctx, membership)
+
+		w, r := buildUploadRequest(ctx, t, "phone,testDate\n")
+		handler.ServeHTTP(w, r)
+
+		if got, want := w.Code, http.StatusSeeOther; got != want {
+			t.Errorf("Expected %d to be %d: %s", got, want, w.Body.String())
+		}
+		if got, want := w.Header().Get("Location"), "/back"; got != want {
+			t.Errorf("Expected %q to be %q", got, want)
+		}
+	})
+
+	t.Run("success", func(t *testing.T) {
+		t.Parallel()
+
+		ctx := ctx
+		ctx = controller.WithSession(ctx, &sessions.Session{})
+		ctx = controller.WithMembership(ctx, membership)
+
+		w, r := buildUploadRequest(ctx, t, "\ufeffphone,testDate\n+12065550100,2022-11-01\n,2022-11-01\n")
+		handler.ServeHTTP(w, r)
+
+		if got, want := w.Code, http.StatusSeeOther; got != want {
+			t.Fatalf("Expected %d to be %d: %s", got, want, w.Body.String())
+		}
+
+		location := w.Header().Get("Location")
+		if !strings.HasPrefix(location, "/codes/bulk-issue/jobs/") {
+			t.Fatalf("Expected %q to be a job", location)
+		}
+
+		job, err := realm.FindBulkIssueJob(harness.Database, strings.TrimPrefix(location, "/codes/bulk-issue/jobs/"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := job.Total, uint(2); got != want {
+			t.Errorf("Expected %d to be %d", got, want)
+		}
+		if got, want := job.Failed, uint(1); got != want {
+			t.Errorf("Expected %d to be %d", got, want)
+		}
+		if got, want := job.Status, database.BulkIssueJobStatusPending; got != want {
+			t.Errorf("Expected %q to be %q", got, want)
+		}
+	})
+}
+
+func TestHandleBulkIssueShow(t *testing.T) {
+	t.Parallel()
+
+	ctx := project.TestContext(t)
+
+	harness := envstest.NewServerConfig(t, testDatabaseInstance)
+
+	c := codes.NewServer(harness.Config, harness.Database, harness.Renderer)
+	showHandler := harness.WithCommonMiddlewares(c.HandleBulkIssueShow())
+	reportHandler := harness.WithCommonMiddlewares(c.HandleBulkIssueReport())
+
+	realm, err := harness.Database.FindRealm(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	user := &database.User{
+		Name:  "Tester",
+		Email: "bulk-issue-show@example.com",
+	}
+	if err := harness.Database.SaveUser(user, database.SystemTest); err != nil {
+		t.Fatal(err)
+	}
+
+	job := &database.BulkIssueJob{
+		RealmID:  realm.ID,
+		UserID:   user.ID,
+		Filename: "codes.csv",
+		Rows: []*database.BulkIssueJobRow{
+			{Line: 1, Phone: "+12065550100", UUID: "a", Status: database.BulkIssueRowIssued},
+			{Line: 2, Phone: "+12065550101", UUID: "b", Status: database.BulkIssueRowFailed, ErrorCode: "invalid_date"},
+		},
+	}
+	if err := harness.Database.CreateBulkIssueJob(job, user); err != nil {
+		t.Fatal(err)
+	}
+
+	membership := &database.Membership{
+		Realm:       realm,
+		User:        user,
+		Permissions: rbac.CodeBulkIssue,
+	}
+
+	t.Run("middleware", func(t *testing.T) {
+		t.Parallel()
+
+		envstest.ExerciseMembershipMissing(t, showHandler)
+		envstest.ExercisePermissionMissing(t, showHandler)
+		envstest.ExerciseIDNotFound(t, membership, showHandler)
+		envstest.ExerciseIDNotFound(t, membership, reportHandler)
+	})
+
+	t.Run("show", func(t *testing.T) {
+		t.Parallel()
+
+		ctx := ctx
+		ctx = controller.WithSession(ctx, &sessions.Session{})
+		ctx = controller.WithMembership(ctx, membership)
+
+		w, r := envstest.BuildFormRequest(ctx, t, http.MethodGet, "/", nil)
+		r = mux.SetURLVars(r, map[string]string{"id": fmt.Sprintf("%d", job.ID)})
+		showHandler.ServeHTTP(w, r)
+
+		if got, want := w.Code, http.StatusOK; got != want {
+			t.Errorf("Expected %d to be %d: %s", got, want, w.Body.String())
+		}
+	})
+
+	t.Run("report", func(t *testing.T) {
+		t.Parallel()
+
+		ctx := ctx
+		ctx = controller.WithSession(ctx, &sessions.Session{})
+		ctx = controller.WithMembership(ctx, membership)
+
+		w, r := envstest.BuildFormRequest(ctx, t, http.MethodGet, "/", nil)
+		r = mux.SetURLVars(r, map[string]string{"id": fmt.Sprintf("%d", job.ID)})
+		reportHandler.ServeHTTP(w, r)
+
+		if got, want := w.Code, http.StatusOK; got != want {
+			t.Fatalf("Expected %d to be %d: %s", got, want, w.Body.String())
+		}
+		if got, want := w.Body.String(), "2,0,b,invalid_date,"; !strings.Contains(got, want) {
+			t.Errorf("Expected %q to contain %q", got, want)
+		}
+		if got := w.Body.String(); strings.Contains(got, "+1206555010") {
+			t.Errorf("Expected %q to not contain phone numbers", got)
+		}
+	})
+}
+
+// buildUploadRequest builds a multipart form request that uploads body as the
+// bulk issue CSV file.
+func buildUploadRequest(ctx context.Context, tb testing.TB, body string) (*httptest.ResponseRecorder, *http.Request) {
+	tb.Helper()
+
+	var b bytes.Buffer
+	mw := multipart.NewWriter(&b)
+	fw, err := mw.CreateFormFile("csv", "codes.csv")
+	if err != nil {
+		tb.Fatal(err)
+	}
+	if _, err := fw.Write([]byte(body)); err != nil {
+		tb.Fatal(err)
+	}
+	if err := mw.WriteField("tzOffset", "-420"); err != nil {
+		tb.Fatal(err)
+	}
+	if err := mw.Close(); err != nil {
+		tb.Fatal(err)
+	}
+
+	r, err := http.NewRequestWithContext(ctx, http.MethodPost, "/", &b)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	r.Header.Set("Accept", "text/html")
+	r.Header.Set("Content-Type", mw.FormDataContentType())
+	r.Header.Set("Referer", "/back")
+	return httptest.NewRecorder(), r
+}
@@ -44,7 +44,7 @@ func (c *Controller) HandleExpireAPI() http.Handler {
 
 		var request api.ExpireCodeRequest
 		if err := controller.BindJSON(w, r, &request); err != nil {
-			c.h.RenderJSON(w, http.StatusBadRequest, api.Error(err))
+			c.h.RenderJSON(w, http.StatusBadRequest, api.Error(err).WithCode(api.ErrUnparsableRequest))
 			return
 		}
 
@@ -131,6 +131,9 @@ func (c *Controller) HandleExpirePage() http.Handler {
 			return
 		}
 
-		c.renderShow(ctx, w, retCode)
+		if err := c.renderShow(ctx, w, retCode); err != nil {
+			controller.InternalError(w, r, c.h, err)
+			return
+		}
 	})
 }
@@ -53,8 +53,15 @@ func (c *Controller) HandleIssue() http.Handler {
 			return
 		}
 
+		issuancePresets, err := currentRealm.ListIssuancePresets(c.db)
+		if err != nil {
+			controller.InternalError(w, r, c.h, err)
+			return
+		}
+
 		m := controller.TemplateMapFromContext(ctx)
 		m.Title("Issue code")
+		m["issuancePresets"] = issuancePresets
 
 		// Set test date params
 		now := time.Now().UTC()
@@ -0,0 +1,107 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package codes
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/google/exposure-notifications-verification-server/pkg/api"
+	"github.com/google/exposure-notifications-verification-server/pkg/controller"
+	"github.com/google/exposure-notifications-verification-server/pkg/database"
+	"github.com/google/exposure-notifications-verification-server/pkg/pagination"
+)
+
+// HandleListCodes returns metadata for the codes issued in the caller's realm
+// within a time range, so public health authorities can reconcile against
+// their own records. The codes themselves are never returned, and each
+// download is recorded in the realm's audit log.
+func (c *Controller) HandleListCodes() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		authorizedApp := controller.AuthorizedAppFromContext(ctx)
+		if authorizedApp == nil {
+			controller.Unauthorized(w, r, c.h)
+			return
+		}
+		realm, err := authorizedApp.Realm(c.db)
+		if err != nil {
+			controller.InternalError(w, r, c.h, err)
+			return
+		}
+
+		var request api.ListCodesRequest
+		if err := controller.BindJSON(w, r, &request); err != nil {
+			c.h.RenderJSON(w, http.StatusBadRequest, api.Error(err).WithCode(api.ErrUnparsableRequest))
+			return
+		}
+
+		start := time.Unix(request.StartTimestamp, 0).UTC()
+		end := time.Now().UTC()
+		if request.EndTimestamp != 0 {
+			end = time.Unix(request.EndTimestamp, 0).UTC()
+		}
+
+		pageParams := &pagination.PageParams{
+			Page:  request.Page,
+			Limit: request.Limit,
+		}
+		if pageParams.Limit > pagination.MaxLimit {
+			pageParams.Limit = pagination.MaxLimit
+		}
+
+		codes, paginator, err := realm.ListCodeMetadata(c.db, start, end, pageParams, authorizedApp)
+		if err != nil {
+			if errors.Is(err, database.ErrBadDateRange) {
+				c.h.RenderJSON(w, http.StatusBadRequest, api.Errorf("endTimestamp must be after startTimestamp").WithCode(api.ErrInvalidRequest))
+				return
+			}
+
+			controller.InternalError(w, r, c.h, err)
+			return
+		}
+
+		resp := &api.ListCodesResponse{
+			Codes: make([]*api.CodeMetadata, 0, len(codes)),
+		}
+		for _, code := range codes {
+			resp.Codes = append(resp.Codes, buildCodeMetadata(code))
+		}
+		if paginator != nil && paginator.NextPage != nil {
+			resp.NextPage = paginator.NextPage.Number
+		}
+
+		c.h.RenderJSON(w, http.StatusOK, resp)
+	})
+}
+
+// buildCodeMetadata returns the API metadata for the code. It never includes
+// the code itself.
+func buildCodeMetadata(code *database.VerificationCode) *api.CodeMetadata {
+	return &api.CodeMetadata{
+		UUID:                   code.UUID,
+		IssuedAtTimestamp:      code.CreatedAt.UTC().Unix(),
+		Claimed:                code.Claimed,
+		TestType:               code.TestType,
+		IssuingUserID:          code.IssuingUserID,
+		IssuingAppID:           code.IssuingAppID,
+		IssuingExternalID:      code.IssuingExternalID,
+		ExternalCaseID:         code.ExternalCaseID,
+		ExpiresAtTimestamp:     code.ExpiresAt.UTC().Unix(),
+		LongExpiresAtTimestamp: code.LongExpiresAt.UTC().Unix(),
+	}
+}
@@ -0,0 +1,137 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package codes_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/google/exposure-notifications-verification-server/internal/envstest"
+	"github.com/google/exposure-notifications-verification-server/internal/project"
+	"github.com/google/exposure-notifications-verification-server/pkg/api"
+	"github.com/google/exposure-notifications-verification-server/pkg/controller"
+	"github.com/google/exposure-notifications-verification-server/pkg/controller/codes"
+	"github.com/google/exposure-notifications-verification-server/pkg/database"
+)
+
+func TestHandleListCodes(t *testing.T) {
+	t.Parallel()
+
+	ctx := project.TestContext(t)
+
+	harness := envstest.NewServerConfig(t, testDatabaseInstance)
+
+	realm, err := harness.Database.FindRealm(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	authApp := &database.AuthorizedApp{
+		RealmID:    realm.ID,
+		Name:       "Appy",
+		APIKeyType: database.APIKeyTypeAdmin,
+	}
+	if _, err := realm.CreateAuthorizedApp(harness.Database, authApp, database.SystemTest); err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now().UTC()
+	code := &database.VerificationCode{
+		RealmID:           realm.ID,
+		Code:              "00000001",
+		LongCode:          "00000001ABC",
+		TestType:          "confirmed",
+		ExpiresAt:         now.Add(time.Hour),
+		LongExpiresAt:     now.Add(time.Hour),
+		IssuingAppID:      authApp.ID,
+		IssuingExternalID: "lab-1",
+	}
+	if err := realm.SaveVerificationCode(harness.Database, code); err != nil {
+		t.Fatal(err)
+	}
+
+	c := codes.NewServer(harness.Config, harness.Database, harness.Renderer)
+	handler := harness.WithCommonMiddlewares(c.HandleListCodes())
+
+	t.Run("unauthorized", func(t *testing.T) {
+		t.Parallel()
+
+		ctx := ctx
+		ctx = controller.WithAuthorizedApp(ctx, nil)
+
+		w, r := envstest.BuildJSONRequest(ctx, t, http.MethodPost, "/", &api.ListCodesRequest{})
+		handler.ServeHTTP(w, r)
+
+		if got, want := w.Code, http.StatusUnauthorized; got != want {
+			t.Errorf("Expected %d to be %d", got, want)
+		}
+	})
+
+	t.Run("bad_range", func(t *testing.T) {
+		t.Parallel()
+
+		ctx := ctx
+		ctx = controller.WithAuthorizedApp(ctx, authApp)
+
+		w, r := envstest.BuildJSONRequest(ctx, t, http.MethodPost, "/", &api.ListCodesRequest{
+			StartTimestamp: now.Unix(),
+			EndTimestamp:   now.Add(-time.Hour).Unix(),
+		})
+		handler.ServeHTTP(w, r)
+
+		if got, want := w.Code, http.StatusBadRequest; got != want {
+			t.Errorf("Expected %d to be %d", got, want)
+		}
+	})
+
+	t.Run("success", func(t *testing.T) {
+		t.Parallel()
+
+		ctx := ctx
+		ctx = controller.WithAuthorizedApp(ctx, authApp)
+
+		w, r := envstest.BuildJSONRequest(ctx, t, http.MethodPost, "/", &api.ListCodesRequest{
+			StartTimestamp: now.Add(-time.Hour).Unix(),
+		})
+		handler.ServeHTTP(w, r)
+
+		if got, want := w.Code, http.StatusOK; got != want {
+			t.Fatalf("Expected %d to be %d: %s", got, want, w.Body.String())
+		}
+
+		var resp api.ListCodesResponse
+		if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+			t.Fatal(err)
+		}
+
+		var found *api.CodeMetadata
+		for _, m := range resp.Codes {
+			if m.UUID == code.UUID {
+				found = m
+			}
+		}
+		if found == nil {
+			t.Fatalf("expected %s in %#v", code.UUID, resp.Codes)
+		}
+		if got, want := found.IssuingAppID, authApp.ID; got != want {
+			t.Errorf("Expected %d to be %d", got, want)
+		}
+		if got, want := found.IssuingExternalID, "lab-1"; got != want {
+			t.Errorf("Expected %q to be %q", got, want)
+		}
+	})
+}
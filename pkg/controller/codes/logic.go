@@ -33,7 +33,7 @@ func (c *Controller) checkCodeStatus(r *http.Request, uuid string) (*database.Ve
 
 	authApp, membership, realm, err := c.getAuthorizationFromContext(ctx)
 	if err != nil {
-		return nil, http.StatusUnauthorized, api.Error(err)
+		return nil, http.StatusUnauthorized, api.Error(err).WithCode(api.ErrUnauthorized)
 	}
 
 	code, err := realm.FindVerificationCodeByUUID(c.db, uuid)
@@ -0,0 +1,103 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package codes
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/google/exposure-notifications-server/pkg/logging"
+	"github.com/google/exposure-notifications-verification-server/pkg/api"
+	"github.com/google/exposure-notifications-verification-server/pkg/controller"
+	"github.com/google/exposure-notifications-verification-server/pkg/database"
+)
+
+// HandleRevokeAPIKey expires all unclaimed codes issued by an API key in a
+// time range and disables the API key. It is intended for incident response
+// when an API key has been leaked.
+func (c *Controller) HandleRevokeAPIKey() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		logger := logging.FromContext(ctx).Named("codes.HandleRevokeAPIKey")
+
+		authorizedApp := controller.AuthorizedAppFromContext(ctx)
+		if authorizedApp == nil {
+			controller.Unauthorized(w, r, c.h)
+			return
+		}
+		realm, err := authorizedApp.Realm(c.db)
+		if err != nil {
+			controller.InternalError(w, r, c.h, err)
+			return
+		}
+
+		var request api.RevokeAPIKeyRequest
+		if err := controller.BindJSON(w, r, &request); err != nil {
+			c.h.RenderJSON(w, http.StatusBadRequest, api.Error(err).WithCode(api.ErrUnparsableRequest))
+			return
+		}
+
+		if request.AuthorizedAppID == 0 {
+			c.h.RenderJSON(w, http.StatusBadRequest, api.Errorf("missing authorizedAppID").WithCode(api.ErrUnparsableRequest))
+			return
+		}
+
+		start := time.Unix(request.StartTimestamp, 0).UTC()
+		end := time.Now().UTC()
+		if request.EndTimestamp != 0 {
+			end = time.Unix(request.EndTimestamp, 0).UTC()
+		}
+
+		// Only API keys in the caller's realm can be revoked.
+		target, err := realm.FindAuthorizedApp(c.db, request.AuthorizedAppID)
+		if err != nil {
+			if database.IsNotFound(err) {
+				c.h.RenderJSON(w, http.StatusNotFound, api.Errorf("API key not found").WithCode(api.ErrNotFound))
+				return
+			}
+
+			controller.InternalError(w, r, c.h, err)
+			return
+		}
+
+		expired, err := c.db.RevokeAuthorizedApp(target, start, end, authorizedApp)
+		if err != nil {
+			if errors.Is(err, database.ErrBadDateRange) {
+				c.h.RenderJSON(w, http.StatusBadRequest, api.Errorf("endTimestamp must be after startTimestamp").WithCode(api.ErrInvalidRequest))
+				return
+			}
+
+			controller.InternalError(w, r, c.h, err)
+			return
+		}
+
+		logger.Infow("revoked API key",
+			"authorized_app_id", target.ID,
+			"codes_expired", expired,
+			"start", start,
+			"end", end)
+
+		var disabledAt int64
+		if target.DeletedAt != nil {
+			disabledAt = target.DeletedAt.UTC().Unix()
+		}
+
+		c.h.RenderJSON(w, http.StatusOK, &api.RevokeAPIKeyResponse{
+			CodesExpired:        expired,
+			DisabledAtTimestamp: disabledAt,
+		})
+	})
+}
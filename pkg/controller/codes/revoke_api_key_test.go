@@ -0,0 +1,221 @@
+// Copyright 2021 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package codes_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/google/exposure-notifications-verification-server/internal/envstest"
+	"github.com/google/exposure-notifications-verification-server/internal/project"
+	"github.com/google/exposure-notifications-verification-server/pkg/api"
+	"github.com/google/exposure-notifications-verification-server/pkg/controller"
+	"github.com/google/exposure-notifications-verification-server/pkg/controller/codes"
+	"github.com/google/exposure-notifications-verification-server/pkg/database"
+	"github.com/google/exposure-notifications-verification-server/pkg/pagination"
+)
+
+func TestHandleRevokeAPIKey(t *testing.T) {
+	t.Parallel()
+
+	ctx := project.TestContext(t)
+
+	harness := envstest.NewServerConfig(t, testDatabaseInstance)
+
+	realm, err := harness.Database.FindRealm(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	callerApp := &database.AuthorizedApp{
+		RealmID: realm.ID,
+		Name:    "Caller",
+	}
+	if _, err := realm.CreateAuthorizedApp(harness.Database, callerApp, database.SystemTest); err != nil {
+		t.Fatal(err)
+	}
+
+	c := codes.NewServer(harness.Config, harness.Database, harness.Renderer)
+	handler := harness.WithCommonMiddlewares(c.HandleRevokeAPIKey())
+
+	// createApp creates an API key in the realm with one unclaimed code issued
+	// by it.
+	createApp := func(tb testing.TB, realm *database.Realm, name string) *database.AuthorizedApp {
+		tb.Helper()
+
+		app := &database.AuthorizedApp{
+			RealmID: realm.ID,
+			Name:    name,
+		}
+		if _, err := realm.CreateAuthorizedApp(harness.Database, app, database.SystemTest); err != nil {
+			tb.Fatal(err)
+		}
+
+		code := &database.VerificationCode{
+			RealmID:       realm.ID,
+			Code:          fmt.Sprintf("%08d", app.ID),
+			LongCode:      fmt.Sprintf("%08dABC", app.ID),
+			TestType:      "confirmed",
+			IssuingAppID:  app.ID,
+			ExpiresAt:     time.Now().Add(time.Hour),
+			LongExpiresAt: time.Now().Add(time.Hour),
+		}
+		if err := realm.SaveVerificationCode(harness.Database, code); err != nil {
+			tb.Fatal(err)
+		}
+		return app
+	}
+
+	revoke := func(tb testing.TB, id uint) (int, *api.RevokeAPIKeyResponse) {
+		tb.Helper()
+
+		ctx := controller.WithAuthorizedApp(ctx, callerApp)
+		w, r := envstest.BuildJSONRequest(ctx, tb, http.MethodPost, "/", &api.RevokeAPIKeyRequest{
+			AuthorizedAppID: id,
+			StartTimestamp:  time.Now().Add(-time.Hour).Unix(),
+		})
+		handler.ServeHTTP(w, r)
+
+		var resp api.RevokeAPIKeyResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			tb.Fatal(err)
+		}
+		return w.Code, &resp
+	}
+
+	t.Run("unauthorized", func(t *testing.T) {
+		t.Parallel()
+
+		ctx := controller.WithAuthorizedApp(ctx, nil)
+
+		w, r := envstest.BuildJSONRequest(ctx, t, http.MethodPost, "/", &api.RevokeAPIKeyRequest{
+			AuthorizedAppID: callerApp.ID,
+		})
+		handler.ServeHTTP(w, r)
+
+		if got, want := w.Code, http.StatusUnauthorized; got != want {
+			t.Errorf("Expected %d to be %d", got, want)
+		}
+	})
+
+	t.Run("same_realm", func(t *testing.T) {
+		t.Parallel()
+
+		target := createApp(t, realm, "Leaked")
+
+		code, resp := revoke(t, target.ID)
+		if got, want := code, http.StatusOK; got != want {
+			t.Fatalf("Expected %d to be %d: %#v", got, want, resp)
+		}
+		if got, want := resp.CodesExpired, int64(1); got != want {
+			t.Errorf("Expected %d to be %d", got, want)
+		}
+		if resp.DisabledAtTimestamp == 0 {
+			t.Errorf("expected disabled timestamp")
+		}
+
+		app, err := realm.FindAuthorizedApp(harness.Database, target.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if app.DeletedAt == nil {
+			t.Errorf("expected API key to be disabled")
+		}
+	})
+
+	t.Run("other_realm", func(t *testing.T) {
+		t.Parallel()
+
+		otherRealm := database.NewRealmWithDefaults("revoke-other-realm")
+		if err := harness.Database.SaveRealm(otherRealm, database.SystemTest); err != nil {
+			t.Fatal(err)
+		}
+		target := createApp(t, otherRealm, "Other")
+
+		code, _ := revoke(t, target.ID)
+		if got, want := code, http.StatusNotFound; got != want {
+			t.Errorf("Expected %d to be %d", got, want)
+		}
+
+		app, err := otherRealm.FindAuthorizedApp(harness.Database, target.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if app.DeletedAt != nil {
+			t.Errorf("expected API key in other realm to remain enabled")
+		}
+	})
+
+	t.Run("already_revoked", func(t *testing.T) {
+		t.Parallel()
+
+		target := createApp(t, realm, "Revoked twice")
+
+		code, first := revoke(t, target.ID)
+		if got, want := code, http.StatusOK; got != want {
+			t.Fatalf("Expected %d to be %d", got, want)
+		}
+
+		code, second := revoke(t, target.ID)
+		if got, want := code, http.StatusOK; got != want {
+			t.Fatalf("Expected %d to be %d", got, want)
+		}
+		if got, want := second.CodesExpired, int64(0); got != want {
+			t.Errorf("Expected %d to be %d", got, want)
+		}
+		if got, want := second.DisabledAtTimestamp, first.DisabledAtTimestamp; got != want {
+			t.Errorf("Expected %d to be %d", got, want)
+		}
+	})
+
+	t.Run("audits", func(t *testing.T) {
+		t.Parallel()
+
+		target := createApp(t, realm, "Audited")
+
+		if code, _ := revoke(t, target.ID); code != http.StatusOK {
+			t.Fatalf("Expected %d to be %d", code, http.StatusOK)
+		}
+
+		audits, _, err := realm.ListAudits(harness.Database, pagination.UnlimitedResults)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		want := map[string]bool{
+			"expired verification codes issued by API key": false,
+			"updated API key enabled":                      false,
+		}
+		for _, audit := range audits {
+			if audit.TargetID != target.AuditID() {
+				continue
+			}
+			if _, ok := want[audit.Action]; ok {
+				want[audit.Action] = true
+			}
+			if got, want := audit.ActorID, callerApp.AuditID(); got != want {
+				t.Errorf("expected actor %q to be %q", got, want)
+			}
+		}
+		for action, found := range want {
+			if !found {
+				t.Errorf("missing audit entry %q", action)
+			}
+		}
+	})
+}
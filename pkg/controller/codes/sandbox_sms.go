@@ -0,0 +1,86 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package codes
+
+import (
+	"net/http"
+
+	"github.com/google/exposure-notifications-verification-server/internal/project"
+	"github.com/google/exposure-notifications-verification-server/pkg/api"
+	"github.com/google/exposure-notifications-verification-server/pkg/controller"
+	"github.com/google/exposure-notifications-verification-server/pkg/database"
+)
+
+// HandleSandboxSMS returns the most recent SMS messages recorded by the
+// NOOP_INSPECT SMS provider for the caller's realm. It is only available when
+// fake SMS is enabled.
+func (c *Controller) HandleSandboxSMS() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		if c.apiconfig == nil || !c.apiconfig.Database.FakeSMS {
+			controller.NotFound(w, r, c.h)
+			return
+		}
+
+		authorizedApp := controller.AuthorizedAppFromContext(ctx)
+		if authorizedApp == nil {
+			controller.Unauthorized(w, r, c.h)
+			return
+		}
+		realm, err := authorizedApp.Realm(c.db)
+		if err != nil {
+			controller.InternalError(w, r, c.h, err)
+			return
+		}
+
+		var request api.SandboxSMSRequest
+		if err := controller.BindJSON(w, r, &request); err != nil {
+			c.h.RenderJSON(w, http.StatusBadRequest, api.Error(err).WithCode(api.ErrUnparsableRequest))
+			return
+		}
+
+		var scopes []database.Scope
+		if phone := project.TrimSpace(request.Phone); phone != "" {
+			scopes = append(scopes, database.WithSandboxSMSToNumber(phone))
+		}
+
+		messages, _, err := realm.ListSandboxSMS(c.db, nil, scopes...)
+		if err != nil {
+			controller.InternalError(w, r, c.h, err)
+			return
+		}
+
+		if len(messages) > 0 {
+			if err := c.db.RecordDataAccess(authorizedApp, "listed sandbox SMS", realm, realm.ID,
+				uint(len(messages)), database.DataAccessFieldPhone); err != nil {
+				controller.InternalError(w, r, c.h, err)
+				return
+			}
+		}
+
+		resp := &api.SandboxSMSResponse{
+			Messages: make([]*api.SandboxSMS, 0, len(messages)),
+		}
+		for _, m := range messages {
+			resp.Messages = append(resp.Messages, &api.SandboxSMS{
+				Phone:     m.ToNumber,
+				Message:   m.Message,
+				Timestamp: m.CreatedAt.UTC().Unix(),
+			})
+		}
+		c.h.RenderJSON(w, http.StatusOK, resp)
+	})
+}
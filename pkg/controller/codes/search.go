@@ -0,0 +1,285 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package codes
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	verifyapi "github.com/google/exposure-notifications-server/pkg/api/v1"
+	"github.com/google/exposure-notifications-verification-server/internal/project"
+	"github.com/google/exposure-notifications-verification-server/pkg/api"
+	"github.com/google/exposure-notifications-verification-server/pkg/controller"
+	"github.com/google/exposure-notifications-verification-server/pkg/database"
+	"github.com/google/exposure-notifications-verification-server/pkg/pagination"
+	"github.com/google/exposure-notifications-verification-server/pkg/rbac"
+)
+
+const (
+	// QueryKeyUser, QueryKeyApp, QueryKeyTestType, QueryKeyStatus,
+	// QueryKeyFrom, and QueryKeyTo are the query keys for filtering the code
+	// search.
+	QueryKeyUser     = "user"
+	QueryKeyApp      = "app"
+	QueryKeyTestType = "test_type"
+	QueryKeyStatus   = "status"
+	QueryKeyFrom     = "from"
+	QueryKeyTo       = "to"
+
+	// QueryKeyBefore is the query key for the search cursor.
+	QueryKeyBefore = "before"
+
+	// searchPageSize is the number of codes shown per page in the UI.
+	searchPageSize = 50
+)
+
+// HandleSearch renders the codes issued in the realm that match the filters,
+// newest first, so realm admins can debug codes that did not arrive.
+func (c *Controller) HandleSearch() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		session := controller.SessionFromContext(ctx)
+		if session == nil {
+			controller.MissingSession(w, r, c.h)
+			return
+		}
+
+		membership := controller.MembershipFromContext(ctx)
+		if membership == nil {
+			controller.MissingMembership(w, r, c.h)
+			return
+		}
+		if !membership.Can(rbac.CodeRead | rbac.UserRead | rbac.APIKeyRead) {
+			controller.Unauthorized(w, r, c.h)
+			return
+		}
+		currentRealm := membership.Realm
+		currentUser := membership.User
+
+		userID, err := parseSearchID(r.FormValue(QueryKeyUser))
+		if err != nil {
+			controller.BadRequest(w, r, c.h)
+			return
+		}
+		appID, err := parseSearchID(r.FormValue(QueryKeyApp))
+		if err != nil {
+			controller.BadRequest(w, r, c.h)
+			return
+		}
+		before, err := parseSearchID(r.FormValue(QueryKeyBefore))
+		if err != nil {
+			controller.BadRequest(w, r, c.h)
+			return
+		}
+
+		from, to, err := parseSearchDates(r.FormValue(QueryKeyFrom), r.FormValue(QueryKeyTo))
+		if err != nil {
+			controller.BadRequest(w, r, c.h)
+			return
+		}
+
+		testType := project.TrimSpace(r.FormValue(QueryKeyTestType))
+		status := project.TrimSpace(r.FormValue(QueryKeyStatus))
+
+		codes, cursor, err := currentRealm.SearchCodes(c.db, searchPageSize, currentUser,
+			database.WithCodeIssuingUserID(userID),
+			database.WithCodeIssuingAppID(appID),
+			database.WithCodeTestType(testType),
+			database.WithCodeStatus(status),
+			database.WithCodeIssuedTime(from, to),
+			database.WithCodeBeforeID(before))
+		if err != nil {
+			controller.InternalError(w, r, c.h, err)
+			return
+		}
+
+		memberships, _, err := currentRealm.ListMemberships(c.db, &pagination.PageParams{Limit: pagination.MaxLimit})
+		if err != nil {
+			controller.InternalError(w, r, c.h, err)
+			return
+		}
+		users := make(map[uint]*database.User, len(memberships))
+		for _, m := range memberships {
+			users[m.UserID] = m.User
+		}
+
+		apps, _, err := currentRealm.ListAuthorizedApps(c.db, &pagination.PageParams{Limit: pagination.MaxLimit},
+			database.WithAuthorizedAppType(database.APIKeyTypeAdmin))
+		if err != nil {
+			controller.InternalError(w, r, c.h, err)
+			return
+		}
+		appsByID := make(map[uint]*database.AuthorizedApp, len(apps))
+		for _, a := range apps {
+			appsByID[a.ID] = a
+		}
+
+		// Keep the filters on the page links.
+		first := r.URL.Query()
+		first.Del(QueryKeyBefore)
+		next := r.URL.Query()
+		next.Set(QueryKeyBefore, strconv.FormatUint(uint64(cursor), 10))
+
+		m := controller.TemplateMapFromContext(ctx)
+		m.Title("Search codes")
+		m["codes"] = codes
+		m["memberships"] = memberships
+		m["users"] = users
+		m["apps"] = apps
+		m["appsByID"] = appsByID
+		m["testTypes"] = searchTestTypes
+		m[QueryKeyUser] = userID
+		m[QueryKeyApp] = appID
+		m[QueryKeyTestType] = testType
+		m[QueryKeyStatus] = status
+		m[QueryKeyFrom] = project.TrimSpace(r.FormValue(QueryKeyFrom))
+		m[QueryKeyTo] = project.TrimSpace(r.FormValue(QueryKeyTo))
+		if before > 0 {
+			m["firstPage"] = "/codes/search?" + first.Encode()
+		}
+		if cursor > 0 {
+			m["nextPage"] = "/codes/search?" + next.Encode()
+		}
+		c.h.RenderHTML(w, "codes/search", m)
+	})
+}
+
+// HandleSearchAPI returns metadata for the codes issued in the caller's realm
+// that match the filters, newest first. The codes themselves are never
+// returned, and each search is recorded in the realm's audit log.
+func (c *Controller) HandleSearchAPI() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		authorizedApp := controller.AuthorizedAppFromContext(ctx)
+		if authorizedApp == nil {
+			controller.Unauthorized(w, r, c.h)
+			return
+		}
+		realm, err := authorizedApp.Realm(c.db)
+		if err != nil {
+			controller.InternalError(w, r, c.h, err)
+			return
+		}
+
+		var request api.SearchCodesRequest
+		if err := controller.BindJSON(w, r, &request); err != nil {
+			c.h.RenderJSON(w, http.StatusBadRequest, api.Error(err).WithCode(api.ErrUnparsableRequest))
+			return
+		}
+
+		if request.TestType != "" {
+			if _, ok := database.ValidTestTypes[request.TestType]; !ok {
+				c.h.RenderJSON(w, http.StatusBadRequest, api.Error(database.ErrInvalidTestType).WithCode(api.ErrInvalidTestType))
+				return
+			}
+		}
+
+		switch request.Status {
+		case "", database.CodeStatusClaimed, database.CodeStatusUnclaimed:
+		default:
+			c.h.RenderJSON(w, http.StatusBadRequest, api.Errorf("status must be %q or %q",
+				database.CodeStatusClaimed, database.CodeStatusUnclaimed).WithCode(api.ErrInvalidRequest))
+			return
+		}
+
+		var from, to time.Time
+		if request.StartTimestamp != 0 {
+			from = time.Unix(request.StartTimestamp, 0).UTC()
+		}
+		if request.EndTimestamp != 0 {
+			to = time.Unix(request.EndTimestamp, 0).UTC()
+		}
+		if !from.IsZero() && !to.IsZero() && to.Before(from) {
+			c.h.RenderJSON(w, http.StatusBadRequest, api.Errorf("endTimestamp must be after startTimestamp").WithCode(api.ErrInvalidRequest))
+			return
+		}
+
+		codes, cursor, err := realm.SearchCodes(c.db, request.Limit, authorizedApp,
+			database.WithCodeIssuingUserID(request.IssuingUserID),
+			database.WithCodeIssuingAppID(request.IssuingAppID),
+			database.WithCodeTestType(request.TestType),
+			database.WithCodeStatus(request.Status),
+			database.WithCodeIssuedTime(from, to),
+			database.WithCodeBeforeID(request.Cursor))
+		if err != nil {
+			controller.InternalError(w, r, c.h, err)
+			return
+		}
+
+		resp := &api.SearchCodesResponse{
+			Codes:      make([]*api.CodeMetadata, 0, len(codes)),
+			NextCursor: cursor,
+		}
+		for _, code := range codes {
+			resp.Codes = append(resp.Codes, buildCodeMetadata(code))
+		}
+
+		c.h.RenderJSON(w, http.StatusOK, resp)
+	})
+}
+
+// searchTestTypes are the stored test types that can be searched in the UI.
+var searchTestTypes = []string{
+	verifyapi.ReportTypeConfirmed,
+	verifyapi.ReportTypeClinical,
+	verifyapi.ReportTypeNegative,
+	verifyapi.ReportTypeSelfReport,
+}
+
+// parseSearchID parses an optional ID filter. A blank value returns 0, which
+// does not filter.
+func parseSearchID(v string) (uint, error) {
+	v = project.TrimSpace(v)
+	if v == "" {
+		return 0, nil
+	}
+
+	id, err := strconv.ParseUint(v, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid id %q", v)
+	}
+	return uint(id), nil
+}
+
+// parseSearchDates parses the optional date range filters. The range is
+// inclusive of the whole "to" day, in UTC.
+func parseSearchDates(fromStr, toStr string) (time.Time, time.Time, error) {
+	var from, to time.Time
+
+	if v := project.TrimSpace(fromStr); v != "" {
+		t, err := time.Parse(project.RFC3339Date, v)
+		if err != nil {
+			return from, to, fmt.Errorf("%s must be a date", QueryKeyFrom)
+		}
+		from = t.UTC()
+	}
+
+	if v := project.TrimSpace(toStr); v != "" {
+		t, err := time.Parse(project.RFC3339Date, v)
+		if err != nil {
+			return from, to, fmt.Errorf("%s must be a date", QueryKeyTo)
+		}
+		to = t.UTC().Add(24*time.Hour - time.Nanosecond)
+	}
+
+	if !from.IsZero() && !to.IsZero() && to.Before(from) {
+		return from, to, fmt.Errorf("%s must be after %s", QueryKeyTo, QueryKeyFrom)
+	}
+	return from, to, nil
+}
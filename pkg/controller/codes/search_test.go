@@ -0,0 +1,216 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package codes_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/google/exposure-notifications-verification-server/internal/envstest"
+	"github.com/google/exposure-notifications-verification-server/internal/project"
+	"github.com/google/exposure-notifications-verification-server/pkg/api"
+	"github.com/google/exposure-notifications-verification-server/pkg/controller"
+	"github.com/google/exposure-notifications-verification-server/pkg/controller/codes"
+	"github.com/google/exposure-notifications-verification-server/pkg/database"
+	"github.com/google/exposure-notifications-verification-server/pkg/rbac"
+	"github.com/gorilla/sessions"
+)
+
+// createSearchCodes creates an API key in the realm that issued n codes. The
+// first code is claimed.
+func createSearchCodes(tb testing.TB, db *database.Database, realm *database.Realm, name string, n int) *database.AuthorizedApp {
+	tb.Helper()
+
+	authApp := &database.AuthorizedApp{
+		RealmID:    realm.ID,
+		Name:       name,
+		APIKeyType: database.APIKeyTypeAdmin,
+	}
+	if _, err := realm.CreateAuthorizedApp(db, authApp, database.SystemTest); err != nil {
+		tb.Fatal(err)
+	}
+
+	now := time.Now().UTC()
+	for i := 0; i < n; i++ {
+		code := &database.VerificationCode{
+			RealmID:       realm.ID,
+			Code:          fmt.Sprintf("%05d%03d", authApp.ID, i),
+			LongCode:      fmt.Sprintf("%05d%03dABC", authApp.ID, i),
+			TestType:      "confirmed",
+			Claimed:       i == 0,
+			ExpiresAt:     now.Add(time.Hour),
+			LongExpiresAt: now.Add(time.Hour),
+			IssuingAppID:  authApp.ID,
+		}
+		if err := realm.SaveVerificationCode(db, code); err != nil {
+			tb.Fatal(err)
+		}
+	}
+	return authApp
+}
+
+func TestHandleSearch(t *testing.T) {
+	t.Parallel()
+
+	ctx := project.TestContext(t)
+	harness := envstest.NewServerConfig(t, testDatabaseInstance)
+
+	realm, err := harness.Database.FindRealm(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	authApp := createSearchCodes(t, harness.Database, realm, "SearchUI", 3)
+
+	c := codes.NewServer(harness.Config, harness.Database, harness.Renderer)
+	handler := harness.WithCommonMiddlewares(c.HandleSearch())
+
+	t.Run("middleware", func(t *testing.T) {
+		t.Parallel()
+
+		envstest.ExerciseSessionMissing(t, handler)
+		envstest.ExerciseMembershipMissing(t, handler)
+		envstest.ExercisePermissionMissing(t, handler)
+	})
+
+	search := func(tb testing.TB, query string) int {
+		tb.Helper()
+
+		ctx := ctx
+		ctx = controller.WithSession(ctx, &sessions.Session{})
+		ctx = controller.WithMembership(ctx, &database.Membership{
+			Realm:       realm,
+			User:        &database.User{},
+			Permissions: rbac.CodeRead | rbac.UserRead | rbac.APIKeyRead,
+		})
+
+		w, r := envstest.BuildFormRequest(ctx, tb, http.MethodGet, "/?"+query, nil)
+		handler.ServeHTTP(w, r)
+		return w.Code
+	}
+
+	t.Run("bad_query", func(t *testing.T) {
+		t.Parallel()
+
+		for _, query := range []string{"user=nope", "app=nope", "before=nope", "from=nope", "from=2022-02-01&to=2022-01-01"} {
+			if got, want := search(t, query), http.StatusBadRequest; got != want {
+				t.Errorf("%s: expected %d to be %d", query, got, want)
+			}
+		}
+	})
+
+	t.Run("renders", func(t *testing.T) {
+		t.Parallel()
+
+		query := fmt.Sprintf("app=%d&status=unclaimed&test_type=confirmed&from=2000-01-01", authApp.ID)
+		if got, want := search(t, query), http.StatusOK; got != want {
+			t.Errorf("Expected %d to be %d", got, want)
+		}
+	})
+}
+
+func TestHandleSearchAPI(t *testing.T) {
+	t.Parallel()
+
+	ctx := project.TestContext(t)
+	harness := envstest.NewServerConfig(t, testDatabaseInstance)
+
+	realm, err := harness.Database.FindRealm(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	authApp := createSearchCodes(t, harness.Database, realm, "SearchAPI", 3)
+
+	c := codes.NewServer(harness.Config, harness.Database, harness.Renderer)
+	handler := harness.WithCommonMiddlewares(c.HandleSearchAPI())
+
+	search := func(tb testing.TB, request *api.SearchCodesRequest) (int, *api.SearchCodesResponse) {
+		tb.Helper()
+
+		ctx := controller.WithAuthorizedApp(ctx, authApp)
+		w, r := envstest.BuildJSONRequest(ctx, tb, http.MethodPost, "/", request)
+		handler.ServeHTTP(w, r)
+
+		var resp api.SearchCodesResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			tb.Fatal(err)
+		}
+		return w.Code, &resp
+	}
+
+	t.Run("unauthorized", func(t *testing.T) {
+		t.Parallel()
+
+		ctx := controller.WithAuthorizedApp(ctx, nil)
+
+		w, r := envstest.BuildJSONRequest(ctx, t, http.MethodPost, "/", &api.SearchCodesRequest{})
+		handler.ServeHTTP(w, r)
+
+		if got, want := w.Code, http.StatusUnauthorized; got != want {
+			t.Errorf("Expected %d to be %d", got, want)
+		}
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		t.Parallel()
+
+		cases := []*api.SearchCodesRequest{
+			{TestType: "nope"},
+			{Status: "nope"},
+			{StartTimestamp: 200, EndTimestamp: 100},
+		}
+		for _, tc := range cases {
+			code, _ := search(t, tc)
+			if got, want := code, http.StatusBadRequest; got != want {
+				t.Errorf("%#v: expected %d to be %d", tc, got, want)
+			}
+		}
+	})
+
+	t.Run("paginates", func(t *testing.T) {
+		t.Parallel()
+
+		request := &api.SearchCodesRequest{
+			IssuingAppID: authApp.ID,
+			Status:       database.CodeStatusUnclaimed,
+			Limit:        1,
+		}
+
+		var uuids []string
+		for i := 0; i < 5; i++ {
+			code, resp := search(t, request)
+			if got, want := code, http.StatusOK; got != want {
+				t.Fatalf("Expected %d to be %d", got, want)
+			}
+			for _, c := range resp.Codes {
+				if c.Claimed {
+					t.Errorf("expected unclaimed code: %#v", c)
+				}
+				uuids = append(uuids, c.UUID)
+			}
+
+			if resp.NextCursor == 0 {
+				break
+			}
+			request.Cursor = resp.NextCursor
+		}
+
+		if got, want := len(uuids), 2; got != want {
+			t.Errorf("expected %d codes to be %d", got, want)
+		}
+	})
+}
@@ -79,7 +79,10 @@ func (c *Controller) HandleShow() http.Handler {
 			return
 		}
 
-		c.renderShow(ctx, w, retCode)
+		if err := c.renderShow(ctx, w, retCode); err != nil {
+			controller.InternalError(w, r, c.h, err)
+			return
+		}
 	})
 }
 
@@ -128,6 +131,8 @@ func (c *Controller) responseCode(ctx context.Context, code *database.Verificati
 		retCode.Issuer = authApp.Name
 	}
 
+	retCode.UserReport = code.IsUserReport()
+	retCode.ResendCount = code.ResendCount
 	retCode.Claimed = code.Claimed
 	if code.Claimed {
 		retCode.Status = "Claimed by user"
@@ -154,11 +159,38 @@ type Code struct {
 	Expires        int64  `json:"expires"`
 	LongExpires    int64  `json:"longExpires"`
 	HasLongExpires bool   `json:"hasLongExpires"`
+	UserReport     bool   `json:"userReport"`
+	ResendCount    uint   `json:"resendCount"`
 }
 
-func (c *Controller) renderShow(ctx context.Context, w http.ResponseWriter, code *Code) {
+func (c *Controller) renderShow(ctx context.Context, w http.ResponseWriter, code *Code) error {
+	transferRealms, err := c.transferRealms(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to list transfer realms: %w", err)
+	}
+
+	transfers, err := c.codeTransfers(code.UUID)
+	if err != nil {
+		return fmt.Errorf("failed to list code transfers: %w", err)
+	}
+
+	var hasSMSConfig bool
+	if membership := controller.MembershipFromContext(ctx); membership != nil {
+		hasSMSConfig, err = membership.Realm.HasSMSConfig(c.db)
+		if err != nil {
+			return fmt.Errorf("failed to check for sms config: %w", err)
+		}
+	}
+
 	m := controller.TemplateMapFromContext(ctx)
 	m.Title("Verification code status")
 	m["code"] = code
+	m["transferRealms"] = transferRealms
+	m["transfers"] = transfers
+	m["hasSMSConfig"] = hasSMSConfig
+	if c.serverconfig != nil {
+		m["maxResends"] = c.serverconfig.IssueConfig().CodeMaxResends
+	}
 	c.h.RenderHTML(w, "codes/show", m)
+	return nil
 }
@@ -0,0 +1,210 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package codes
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/google/exposure-notifications-verification-server/pkg/controller"
+	"github.com/google/exposure-notifications-verification-server/pkg/database"
+	"github.com/google/exposure-notifications-verification-server/pkg/rbac"
+	"github.com/gorilla/mux"
+)
+
+// HandleTransfer moves an unclaimed code to another realm, for when a
+// patient's case is reassigned to a different jurisdiction. The user must be
+// able to expire codes in the current realm and issue codes in the
+// destination realm.
+func (c *Controller) HandleTransfer() http.Handler {
+	type FormData struct {
+		ToRealmID      uint   `form:"to_realm_id"`
+		PatientConsent bool   `form:"patient_consent"`
+		Reason         string `form:"reason"`
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		vars := mux.Vars(r)
+
+		session := controller.SessionFromContext(ctx)
+		if session == nil {
+			controller.MissingSession(w, r, c.h)
+			return
+		}
+		flash := controller.Flash(session)
+
+		membership := controller.MembershipFromContext(ctx)
+		if membership == nil {
+			controller.MissingMembership(w, r, c.h)
+			return
+		}
+		if !membership.Can(rbac.CodeExpire) {
+			controller.Unauthorized(w, r, c.h)
+			return
+		}
+
+		currentRealm := membership.Realm
+		currentUser := membership.User
+
+		// Retrieve once to check permissions.
+		code, _, apiErr := c.checkCodeStatus(r, vars["uuid"])
+		if apiErr != nil {
+			flash.Error("Failed to transfer code: %v.", apiErr.Error)
+			if err := c.renderStatus(ctx, w, currentRealm, currentUser, code); err != nil {
+				controller.InternalError(w, r, c.h, err)
+				return
+			}
+			return
+		}
+
+		renderError := func(msg string, args ...interface{}) {
+			flash.Error(msg, args...)
+
+			retCode, err := c.responseCode(ctx, code)
+			if err != nil {
+				controller.InternalError(w, r, c.h, err)
+				return
+			}
+
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			if err := c.renderShow(ctx, w, retCode); err != nil {
+				controller.InternalError(w, r, c.h, err)
+				return
+			}
+		}
+
+		var form FormData
+		if err := controller.BindForm(w, r, &form); err != nil {
+			renderError("Failed to process form: %v.", err)
+			return
+		}
+
+		// The user must be able to issue codes in the destination realm.
+		toMembership, err := currentUser.FindMembership(c.db, form.ToRealmID)
+		if err != nil {
+			if database.IsNotFound(err) {
+				renderError("Failed to transfer code: you are not a member of the destination realm.")
+				return
+			}
+
+			controller.InternalError(w, r, c.h, err)
+			return
+		}
+		if !toMembership.Can(rbac.CodeIssue) {
+			renderError("Failed to transfer code: you cannot issue codes in %s.", toMembership.Realm.Name)
+			return
+		}
+
+		if _, err := currentRealm.TransferCode(c.db, code.UUID, toMembership.Realm, form.PatientConsent, form.Reason, currentUser); err != nil {
+			switch {
+			case errors.Is(err, database.ErrCodeTransferSameRealm),
+				errors.Is(err, database.ErrCodeTransferConsent),
+				errors.Is(err, database.ErrCodeTransferTestType),
+				errors.Is(err, database.ErrCodeTransferConflict),
+				errors.Is(err, database.ErrCodeAlreadyClaimed),
+				errors.Is(err, database.ErrCodeAlreadyExpired):
+				renderError("Failed to transfer code: %v.", err)
+				return
+			}
+
+			controller.InternalError(w, r, c.h, err)
+			return
+		}
+
+		flash.Alert("Transferred code %s to %s.", code.UUID, toMembership.Realm.Name)
+		http.Redirect(w, r, "/codes/status", http.StatusSeeOther)
+	})
+}
+
+// transferRealms returns the memberships of the current user in other realms
+// where they can issue codes. These are the realms to which a code can be
+// transferred.
+func (c *Controller) transferRealms(ctx context.Context) ([]*database.Membership, error) {
+	membership := controller.MembershipFromContext(ctx)
+	if membership == nil || !membership.Can(rbac.CodeExpire) {
+		return nil, nil
+	}
+
+	memberships, err := membership.User.ListMemberships(c.db)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]*database.Membership, 0, len(memberships))
+	for _, m := range memberships {
+		if m.RealmID == membership.RealmID || !m.Can(rbac.CodeIssue) {
+			continue
+		}
+		result = append(result, m)
+	}
+	return result, nil
+}
+
+// codeTransfer is a transfer of a code for display.
+type codeTransfer struct {
+	*database.VerificationCodeTransfer
+
+	FromRealmName string
+	ToRealmName   string
+}
+
+// codeTransfers returns the transfers of the code, with the names of the
+// realms involved.
+func (c *Controller) codeTransfers(uuid string) ([]*codeTransfer, error) {
+	transfers, err := c.db.ListVerificationCodeTransfers(uuid)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make(map[uint]string)
+	realmName := func(id uint) (string, error) {
+		if name, ok := names[id]; ok {
+			return name, nil
+		}
+
+		realm, err := c.db.FindRealm(id)
+		if err != nil {
+			if !database.IsNotFound(err) {
+				return "", err
+			}
+
+			// Realm has since been deleted.
+			realm = &database.Realm{Name: "Unknown realm"}
+		}
+		names[id] = realm.Name
+		return realm.Name, nil
+	}
+
+	result := make([]*codeTransfer, 0, len(transfers))
+	for _, t := range transfers {
+		from, err := realmName(t.FromRealmID)
+		if err != nil {
+			return nil, err
+		}
+		to, err := realmName(t.ToRealmID)
+		if err != nil {
+			return nil, err
+		}
+
+		result = append(result, &codeTransfer{
+			VerificationCodeTransfer: t,
+			FromRealmName:            from,
+			ToRealmName:              to,
+		})
+	}
+	return result, nil
+}
@@ -32,7 +32,10 @@ type contextKey string
 const (
 	contextKeyAuthorizedApp = contextKey("authorizedApp")
 	contextKeyFirebaseUser  = contextKey("firebaseUser")
+	contextKeyHostRealm     = contextKey("hostRealm")
+	contextKeyImpersonation = contextKey("impersonation")
 	contextKeyLocale        = contextKey("locale")
+	contextKeyMaxBodyBytes  = contextKey("maxBodyBytes")
 	contextKeyMembership    = contextKey("membership")
 	contextKeyMemberships   = contextKey("memberships")
 	contextKeyNonce         = contextKey("nonce")
@@ -134,6 +137,53 @@ func RealmFromContext(ctx context.Context) *database.Realm {
 	return t
 }
 
+// WithHostRealm stores the realm bound to the request's custom domain on the
+// context.
+func WithHostRealm(ctx context.Context, r *database.Realm) context.Context {
+	m := TemplateMapFromContext(ctx)
+	m["hostRealm"] = r
+	ctx = WithTemplateMap(ctx, m)
+
+	return context.WithValue(ctx, contextKeyHostRealm, r)
+}
+
+// HostRealmFromContext retrieves the realm bound to the request's custom
+// domain from the context. If the request is not for a custom domain, it
+// returns nil.
+func HostRealmFromContext(ctx context.Context) *database.Realm {
+	v := ctx.Value(contextKeyHostRealm)
+	if v == nil {
+		return nil
+	}
+
+	t, ok := v.(*database.Realm)
+	if !ok {
+		return nil
+	}
+	return t
+}
+
+// WithMaxBodyBytes stores the maximum allowed request body size on the
+// context. It is used by BindJSON and BindJSONStream.
+func WithMaxBodyBytes(ctx context.Context, n int64) context.Context {
+	return context.WithValue(ctx, contextKeyMaxBodyBytes, n)
+}
+
+// MaxBodyBytesFromContext retrieves the maximum allowed request body size from
+// the context. If no value exists, it returns 0.
+func MaxBodyBytesFromContext(ctx context.Context) int64 {
+	v := ctx.Value(contextKeyMaxBodyBytes)
+	if v == nil {
+		return 0
+	}
+
+	t, ok := v.(int64)
+	if !ok {
+		return 0
+	}
+	return t
+}
+
 // WithRequestID stores the request ID on the context.
 func WithRequestID(ctx context.Context, id string) context.Context {
 	m := TemplateMapFromContext(ctx)
@@ -315,6 +365,31 @@ func MembershipFromContext(ctx context.Context) *database.Membership {
 	return t
 }
 
+// WithImpersonation stores the system admin's current realm impersonation on
+// the context.
+func WithImpersonation(ctx context.Context, i *database.RealmImpersonation) context.Context {
+	m := TemplateMapFromContext(ctx)
+	m["currentImpersonation"] = i
+	ctx = WithTemplateMap(ctx, m)
+
+	return context.WithValue(ctx, contextKeyImpersonation, i)
+}
+
+// ImpersonationFromContext retrieves the realm impersonation from the context.
+// If no value exists, it returns nil.
+func ImpersonationFromContext(ctx context.Context) *database.RealmImpersonation {
+	v := ctx.Value(contextKeyImpersonation)
+	if v == nil {
+		return nil
+	}
+
+	t, ok := v.(*database.RealmImpersonation)
+	if !ok {
+		return nil
+	}
+	return t
+}
+
 // WithFirebaseUser stores the current firebase user on the context.
 func WithFirebaseUser(ctx context.Context, u *auth.UserRecord) context.Context {
 	return context.WithValue(ctx, contextKeyFirebaseUser, u)
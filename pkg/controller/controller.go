@@ -30,9 +30,10 @@ import (
 )
 
 var (
-	apiErrorBadRequest   = api.Errorf("bad request")
-	apiErrorUnauthorized = api.Errorf("unauthorized")
-	apiErrorMissingRealm = api.Errorf("missing realm")
+	apiErrorBadRequest   = api.Errorf("bad request").WithCode(api.ErrUnparsableRequest)
+	apiErrorUnauthorized = api.Errorf("unauthorized").WithCode(api.ErrUnauthorized)
+	apiErrorMissingRealm = api.Errorf("missing realm").WithCode(api.ErrMissingRealm)
+	apiErrorNotFound     = api.Errorf("not found").WithCode(api.ErrNotFound)
 
 	errMissingAuthorizedApp = fmt.Errorf("authorized app missing in request context")
 	errMissingLocale        = fmt.Errorf("locale missing in request context")
@@ -86,7 +87,7 @@ func InternalError(w http.ResponseWriter, r *http.Request, h *render.Renderer, e
 	case prefixInList(accept, ContentTypeHTML):
 		h.RenderHTML500(w, err)
 	case prefixInList(accept, ContentTypeJSON):
-		h.RenderJSON500(w, err)
+		h.RenderJSON(w, http.StatusInternalServerError, api.InternalError())
 	default:
 		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
 	}
@@ -103,7 +104,7 @@ func NotFound(w http.ResponseWriter, r *http.Request, h *render.Renderer) {
 		m.Title(http.StatusText(http.StatusNotFound))
 		h.RenderHTMLStatus(w, http.StatusNotFound, "404", m)
 	case prefixInList(accept, ContentTypeJSON):
-		h.RenderJSON(w, http.StatusNotFound, http.StatusText(http.StatusNotFound))
+		h.RenderJSON(w, http.StatusNotFound, apiErrorNotFound)
 	default:
 		http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
 	}
@@ -157,6 +158,12 @@ func BadRequest(w http.ResponseWriter, r *http.Request, h *render.Renderer) {
 	}
 }
 
+// RequestTooLarge indicates the request body exceeded the maximum allowed size.
+// The err should be the error returned by BindJSON or BindJSONStream.
+func RequestTooLarge(w http.ResponseWriter, r *http.Request, h *render.Renderer, err error) {
+	h.RenderJSON(w, http.StatusRequestEntityTooLarge, api.Error(err).WithCode(api.ErrRequestTooLarge))
+}
+
 // MissingMembership returns an error indicating that the request requires a
 // realm selection, but one was not present.
 func MissingMembership(w http.ResponseWriter, r *http.Request, h *render.Renderer) {
@@ -212,6 +219,22 @@ func RedirectToChangePassword(w http.ResponseWriter, r *http.Request, h *render.
 	return
 }
 
+// RedirectToReauth redirects to the sign-in page to re-authenticate, returning
+// to redir afterwards. Non-HTML requests receive an unauthorized error, since
+// they cannot complete the sign-in flow.
+func RedirectToReauth(w http.ResponseWriter, r *http.Request, h *render.Renderer, redir string) {
+	accept := strings.Split(r.Header.Get("Accept"), ",")
+	accept = append(accept, strings.Split(r.Header.Get("Content-Type"), ",")...)
+
+	if prefixInList(accept, ContentTypeHTML) {
+		http.Redirect(w, r, "/login?redir="+url.QueryEscape(redir), http.StatusSeeOther)
+		return
+	}
+
+	Unauthorized(w, r, h)
+	return
+}
+
 // RealHostFromRequest attempts to find the "best" host for the HTTP request.
 // Sometimes, depending on the incoming request, the host will be part of the
 // URL. Other times, it could be part of the Host header. When developing
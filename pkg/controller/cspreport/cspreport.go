@@ -0,0 +1,240 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package cspreport receives Content-Security-Policy violation reports from
+// browsers and aggregates them into metrics.
+package cspreport
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/google/exposure-notifications-server/pkg/logging"
+	"github.com/google/exposure-notifications-verification-server/pkg/render"
+
+	"go.opencensus.io/stats"
+	"go.opencensus.io/tag"
+)
+
+// maxReportBytes is the maximum size of a report request body.
+const maxReportBytes = 64 * 1024
+
+// knownDirectives are the directives recorded in metrics. Reports are sent by
+// browsers (and anyone else), so unknown values are grouped to bound the
+// metric cardinality.
+var knownDirectives = map[string]struct{}{
+	"base-uri":        {},
+	"connect-src":     {},
+	"default-src":     {},
+	"font-src":        {},
+	"form-action":     {},
+	"frame-ancestors": {},
+	"frame-src":       {},
+	"img-src":         {},
+	"manifest-src":    {},
+	"media-src":       {},
+	"object-src":      {},
+	"script-src":      {},
+	"script-src-attr": {},
+	"script-src-elem": {},
+	"style-src":       {},
+	"style-src-attr":  {},
+	"style-src-elem":  {},
+	"worker-src":      {},
+}
+
+// Controller is a controller for CSP reports.
+type Controller struct {
+	h *render.Renderer
+}
+
+// New creates a new CSP report controller.
+func New(h *render.Renderer) *Controller {
+	return &Controller{
+		h: h,
+	}
+}
+
+// violation is a normalized CSP violation report.
+type violation struct {
+	DocumentURI string
+	Directive   string
+	BlockedURI  string
+	Disposition string
+}
+
+// legacyReport is the body sent for the report-uri directive with the
+// application/csp-report content type.
+type legacyReport struct {
+	Report struct {
+		DocumentURI        string `json:"document-uri"`
+		ViolatedDirective  string `json:"violated-directive"`
+		EffectiveDirective string `json:"effective-directive"`
+		BlockedURI         string `json:"blocked-uri"`
+		Disposition        string `json:"disposition"`
+	} `json:"csp-report"`
+}
+
+// reportingAPIReport is a single report sent by the Reporting API with the
+// application/reports+json content type.
+type reportingAPIReport struct {
+	Type string `json:"type"`
+	Body struct {
+		DocumentURL        string `json:"documentURL"`
+		EffectiveDirective string `json:"effectiveDirective"`
+		BlockedURL         string `json:"blockedURL"`
+		Disposition        string `json:"disposition"`
+	} `json:"body"`
+}
+
+// HandleReport accepts CSP violation reports and records them as metrics.
+// Reports are unauthenticated, so they are only logged and counted.
+func (c *Controller) HandleReport() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		logger := logging.FromContext(ctx).Named("cspreport.HandleReport")
+
+		b, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxReportBytes))
+		if err != nil {
+			c.h.RenderJSON(w, http.StatusRequestEntityTooLarge, fmt.Errorf("report too large"))
+			return
+		}
+
+		violations, err := parseReports(r.Header.Get("Content-Type"), b)
+		if err != nil {
+			logger.Debugw("failed to parse csp report", "error", err)
+			c.h.RenderJSON(w, http.StatusBadRequest, fmt.Errorf("invalid report"))
+			return
+		}
+
+		for _, v := range violations {
+			logger.Debugw("csp violation",
+				"document", v.DocumentURI,
+				"directive", v.Directive,
+				"blocked", v.BlockedURI,
+				"disposition", v.Disposition)
+
+			stats.RecordWithTags(ctx, []tag.Mutator{
+				tag.Upsert(directiveTagKey, normalizeDirective(v.Directive)),
+				tag.Upsert(blockedTagKey, classifyBlocked(v.BlockedURI, v.DocumentURI)),
+				tag.Upsert(dispositionTagKey, normalizeDisposition(v.Disposition)),
+			}, mViolations.M(1))
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+// parseReports parses the request body into violations based on the content
+// type.
+func parseReports(contentType string, b []byte) ([]*violation, error) {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return nil, fmt.Errorf("invalid content type: %w", err)
+	}
+
+	switch mediaType {
+	case "application/csp-report", "application/json":
+		var report legacyReport
+		if err := json.Unmarshal(b, &report); err != nil {
+			return nil, fmt.Errorf("failed to decode report: %w", err)
+		}
+
+		directive := report.Report.EffectiveDirective
+		if directive == "" {
+			// Older browsers only send the violated directive, which includes the
+			// sources.
+			directive = strings.SplitN(report.Report.ViolatedDirective, " ", 2)[0]
+		}
+
+		return []*violation{{
+			DocumentURI: report.Report.DocumentURI,
+			Directive:   directive,
+			BlockedURI:  report.Report.BlockedURI,
+			Disposition: report.Report.Disposition,
+		}}, nil
+	case "application/reports+json":
+		var reports []*reportingAPIReport
+		if err := json.Unmarshal(b, &reports); err != nil {
+			return nil, fmt.Errorf("failed to decode reports: %w", err)
+		}
+
+		violations := make([]*violation, 0, len(reports))
+		for _, report := range reports {
+			if report == nil || report.Type != "csp-violation" {
+				continue
+			}
+
+			violations = append(violations, &violation{
+				DocumentURI: report.Body.DocumentURL,
+				Directive:   report.Body.EffectiveDirective,
+				BlockedURI:  report.Body.BlockedURL,
+				Disposition: report.Body.Disposition,
+			})
+		}
+		return violations, nil
+	default:
+		return nil, fmt.Errorf("unsupported content type %q", mediaType)
+	}
+}
+
+// normalizeDirective returns the directive if it is known, or "other".
+func normalizeDirective(directive string) string {
+	directive = strings.ToLower(strings.TrimSpace(directive))
+	if _, ok := knownDirectives[directive]; ok {
+		return directive
+	}
+	return "other"
+}
+
+// normalizeDisposition returns "enforce" or "report".
+func normalizeDisposition(disposition string) string {
+	if strings.EqualFold(disposition, "enforce") {
+		return "enforce"
+	}
+	return "report"
+}
+
+// classifyBlocked groups the blocked URI into a small set of classes. The full
+// URI is only logged.
+func classifyBlocked(blocked, document string) string {
+	switch blocked = strings.ToLower(strings.TrimSpace(blocked)); blocked {
+	case "inline", "eval", "wasm-eval", "trusted-types-policy", "trusted-types-sink":
+		return blocked
+	case "", "self":
+		return "self"
+	}
+
+	u, err := url.Parse(blocked)
+	if err != nil {
+		return "other"
+	}
+
+	switch u.Scheme {
+	case "data", "blob":
+		return u.Scheme
+	case "http", "https", "ws", "wss":
+		if d, err := url.Parse(document); err == nil && strings.EqualFold(d.Host, u.Host) {
+			return "self"
+		}
+		return "external"
+	}
+	return "other"
+}
@@ -0,0 +1,112 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cspreport_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/exposure-notifications-verification-server/internal/project"
+	"github.com/google/exposure-notifications-verification-server/pkg/controller/cspreport"
+	"github.com/google/exposure-notifications-verification-server/pkg/render"
+)
+
+func TestHandleReport(t *testing.T) {
+	t.Parallel()
+
+	ctx := project.TestContext(t)
+	h, err := render.New(ctx, nil, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := cspreport.New(h)
+
+	cases := []struct {
+		name        string
+		contentType string
+		body        string
+		code        int
+	}{
+		{
+			name:        "legacy",
+			contentType: "application/csp-report",
+			body: `{"csp-report": {
+				"document-uri": "https://example.com/codes/issue",
+				"violated-directive": "script-src-elem",
+				"effective-directive": "script-src-elem",
+				"blocked-uri": "https://evil.example.com/x.js",
+				"disposition": "report"
+			}}`,
+			code: http.StatusNoContent,
+		},
+		{
+			name:        "reporting_api",
+			contentType: "application/reports+json",
+			body: `[{
+				"type": "csp-violation",
+				"body": {
+					"documentURL": "https://example.com/realm/stats",
+					"effectiveDirective": "style-src-attr",
+					"blockedURL": "inline",
+					"disposition": "enforce"
+				}
+			}, {
+				"type": "deprecation",
+				"body": {}
+			}]`,
+			code: http.StatusNoContent,
+		},
+		{
+			name:        "invalid_json",
+			contentType: "application/csp-report",
+			body:        `{`,
+			code:        http.StatusBadRequest,
+		},
+		{
+			name:        "unsupported_content_type",
+			contentType: "text/plain",
+			body:        `hello`,
+			code:        http.StatusBadRequest,
+		},
+		{
+			name:        "too_large",
+			contentType: "application/csp-report",
+			body:        `{"csp-report": {"document-uri": "` + strings.Repeat("a", 128*1024) + `"}}`,
+			code:        http.StatusRequestEntityTooLarge,
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			r := httptest.NewRequest(http.MethodPost, "/csp-report", strings.NewReader(tc.body))
+			r = r.Clone(ctx)
+			r.Header.Set("Content-Type", tc.contentType)
+			w := httptest.NewRecorder()
+
+			c.HandleReport().ServeHTTP(w, r)
+
+			if got, want := w.Code, tc.code; got != want {
+				t.Errorf("expected %d to be %d: %s", got, want, w.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,52 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cspreport
+
+import (
+	enobs "github.com/google/exposure-notifications-server/pkg/observability"
+	"github.com/google/exposure-notifications-verification-server/pkg/observability"
+
+	"go.opencensus.io/stats"
+	"go.opencensus.io/stats/view"
+	"go.opencensus.io/tag"
+)
+
+const metricPrefix = observability.MetricRoot + "/csp"
+
+var (
+	mViolations = stats.Int64(metricPrefix+"/violations", "content security policy violations", stats.UnitDimensionless)
+
+	// directiveTagKey is the violated directive, or "other" if unknown.
+	directiveTagKey = tag.MustNewKey("directive")
+
+	// blockedTagKey is the class of the blocked resource, such as "inline" or
+	// "external".
+	blockedTagKey = tag.MustNewKey("blocked")
+
+	// dispositionTagKey is whether the policy was enforced or report-only.
+	dispositionTagKey = tag.MustNewKey("disposition")
+)
+
+func init() {
+	enobs.CollectViews([]*view.View{
+		{
+			Name:        metricPrefix + "/violations",
+			Description: "Number of content security policy violations reported by browsers",
+			TagKeys:     append(observability.CommonTagKeys(), directiveTagKey, blockedTagKey, dispositionTagKey),
+			Measure:     mViolations,
+			Aggregation: view.Count(),
+		},
+	}...)
+}
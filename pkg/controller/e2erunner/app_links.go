@@ -0,0 +1,264 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package e2erunner
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/exposure-notifications-server/pkg/logging"
+	"github.com/google/exposure-notifications-verification-server/internal/clients"
+	"github.com/google/exposure-notifications-verification-server/pkg/api"
+	"github.com/google/exposure-notifications-verification-server/pkg/database"
+	"github.com/google/exposure-notifications-verification-server/pkg/observability"
+	"go.opencensus.io/stats"
+)
+
+// sha256FingerprintRe matches a SHA-256 certificate fingerprint in the format
+// expected by Android App Links (32 colon-separated uppercase hex bytes).
+var sha256FingerprintRe = regexp.MustCompile(`^([0-9A-F]{2}:){31}[0-9A-F]{2}$`)
+
+// appLinksResult is the outcome of validating the well-known files served for
+// a single redirect hostname.
+type appLinksResult struct {
+	Host       string   `json:"host"`
+	RegionCode string   `json:"region_code"`
+	RealmID    uint     `json:"realm_id,omitempty"`
+	Mismatches []string `json:"mismatches,omitempty"`
+}
+
+// HandleAppLinks fetches the live /.well-known files from each configured
+// redirect hostname and validates them against the mobile apps registered for
+// the hostname's realm. The response reports mismatches per realm and fails if
+// there are any.
+func (c *Controller) HandleAppLinks() http.Handler {
+	hostnameToRegion, err := c.config.HostnameToRegion()
+
+	// If no hostnames are configured, there is nothing to check.
+	if err == nil && len(hostnameToRegion) == 0 {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			c.h.RenderJSON(w, http.StatusOK, nil)
+		})
+	}
+
+	hosts := make([]string, 0, len(hostnameToRegion))
+	for host := range hostnameToRegion {
+		hosts = append(hosts, host)
+	}
+	sort.Strings(hosts)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		logger := logging.FromContext(ctx)
+
+		if err != nil {
+			logger.Errorw("invalid hostname config", "error", err)
+			c.h.RenderJSON(w, http.StatusInternalServerError, err)
+			return
+		}
+
+		results := make([]*appLinksResult, 0, len(hosts))
+		var failed bool
+		for _, host := range hosts {
+			result := c.checkAppLinks(ctx, "https://"+host, hostnameToRegion[host])
+			result.Host = host
+			results = append(results, result)
+
+			if result.RealmID != 0 {
+				ctx := observability.WithRealmID(ctx, uint64(result.RealmID))
+				stats.Record(ctx, mAppLinksMismatches.M(int64(len(result.Mismatches))))
+			}
+
+			if len(result.Mismatches) > 0 {
+				failed = true
+				logger.Errorw("app link mismatches",
+					"host", host,
+					"region", result.RegionCode,
+					"mismatches", result.Mismatches)
+			}
+		}
+
+		if failed {
+			c.h.RenderJSON(w, http.StatusInternalServerError, map[string]interface{}{"results": results})
+			return
+		}
+
+		stats.Record(ctx, mAppLinksSuccess.M(1))
+		c.h.RenderJSON(w, http.StatusOK, map[string]interface{}{"results": results})
+	})
+}
+
+// checkAppLinks validates the well-known files served at baseURL against the
+// mobile apps registered for the realm with the given region code.
+func (c *Controller) checkAppLinks(ctx context.Context, baseURL, region string) *appLinksResult {
+	result := &appLinksResult{
+		RegionCode: region,
+	}
+
+	fail := func(msg string, args ...interface{}) *appLinksResult {
+		result.Mismatches = append(result.Mismatches, fmt.Sprintf(msg, args...))
+		return result
+	}
+
+	realm, err := c.db.FindRealmByRegion(region)
+	if err != nil {
+		if database.IsNotFound(err) {
+			return fail("no realm exists for region %q", region)
+		}
+		return fail("failed to lookup realm: %s", err)
+	}
+	result.RealmID = realm.ID
+
+	androidApps, err := c.db.ListActiveApps(realm.ID, database.WithAppOS(database.OSTypeAndroid))
+	if err != nil {
+		return fail("failed to list android apps: %s", err)
+	}
+
+	iosApps, err := c.db.ListActiveApps(realm.ID, database.WithAppOS(database.OSTypeIOS))
+	if err != nil {
+		return fail("failed to list ios apps: %s", err)
+	}
+
+	client, err := clients.NewENXRedirectClient(baseURL, clients.WithTimeout(30*time.Second))
+	if err != nil {
+		return fail("failed to create client: %s", err)
+	}
+
+	// The redirector returns a 404 when no apps are registered for a platform,
+	// so only fetch the files that are expected to exist.
+	if len(androidApps) > 0 {
+		live, err := client.AndroidAssetLinks(ctx)
+		if err != nil {
+			fail("failed to fetch assetlinks.json: %s", err)
+		} else {
+			result.Mismatches = append(result.Mismatches, compareAndroidAssetLinks(androidApps, live)...)
+		}
+	}
+
+	if len(iosApps) > 0 {
+		live, err := client.AppleSiteAssociation(ctx)
+		if err != nil {
+			fail("failed to fetch apple-app-site-association: %s", err)
+		} else {
+			result.Mismatches = append(result.Mismatches, compareAppleSiteAssociation(iosApps, live)...)
+		}
+	}
+
+	return result
+}
+
+// compareAndroidAssetLinks returns the differences between the registered
+// Android apps and the live assetlinks.json entries, including malformed
+// fingerprints on either side.
+func compareAndroidAssetLinks(apps []*database.MobileApp, live []*api.AndroidDataResponse) []string {
+	var mismatches []string
+
+	liveFingerprints := make(map[string]map[string]struct{}, len(live))
+	for _, entry := range live {
+		if entry == nil {
+			continue
+		}
+
+		pkg := entry.Target.PackageName
+		if _, ok := liveFingerprints[pkg]; !ok {
+			liveFingerprints[pkg] = make(map[string]struct{})
+		}
+
+		for _, fp := range entry.Target.Fingerprints {
+			if !sha256FingerprintRe.MatchString(fp) {
+				mismatches = append(mismatches, fmt.Sprintf("assetlinks.json has malformed fingerprint %q for %q", fp, pkg))
+			}
+			liveFingerprints[pkg][strings.ToUpper(strings.TrimSpace(fp))] = struct{}{}
+		}
+	}
+
+	registered := make(map[string]struct{}, len(apps))
+	for _, app := range apps {
+		registered[app.AppID] = struct{}{}
+
+		fps, ok := liveFingerprints[app.AppID]
+		if !ok {
+			mismatches = append(mismatches, fmt.Sprintf("assetlinks.json is missing android app %q", app.AppID))
+			continue
+		}
+
+		want := make(map[string]struct{})
+		for _, sha := range strings.Split(app.SHA, "\n") {
+			sha = strings.ToUpper(strings.TrimSpace(sha))
+			if sha == "" {
+				continue
+			}
+			want[sha] = struct{}{}
+
+			if !sha256FingerprintRe.MatchString(sha) {
+				mismatches = append(mismatches, fmt.Sprintf("registered fingerprint %q for %q is malformed", sha, app.AppID))
+			}
+			if _, ok := fps[sha]; !ok {
+				mismatches = append(mismatches, fmt.Sprintf("assetlinks.json is missing fingerprint %q for %q", sha, app.AppID))
+			}
+		}
+
+		for fp := range fps {
+			if _, ok := want[fp]; !ok {
+				mismatches = append(mismatches, fmt.Sprintf("assetlinks.json has unregistered fingerprint %q for %q", fp, app.AppID))
+			}
+		}
+	}
+
+	for pkg := range liveFingerprints {
+		if _, ok := registered[pkg]; !ok {
+			mismatches = append(mismatches, fmt.Sprintf("assetlinks.json has unregistered android app %q", pkg))
+		}
+	}
+
+	sort.Strings(mismatches)
+	return mismatches
+}
+
+// compareAppleSiteAssociation returns the differences between the registered
+// iOS apps and the live apple-app-site-association entries.
+func compareAppleSiteAssociation(apps []*database.MobileApp, live *api.IOSDataResponse) []string {
+	var mismatches []string
+
+	liveIDs := make(map[string]struct{})
+	if live != nil {
+		for _, detail := range live.Applinks.Details {
+			liveIDs[detail.AppID] = struct{}{}
+		}
+	}
+
+	registered := make(map[string]struct{}, len(apps))
+	for _, app := range apps {
+		registered[app.AppID] = struct{}{}
+
+		if _, ok := liveIDs[app.AppID]; !ok {
+			mismatches = append(mismatches, fmt.Sprintf("apple-app-site-association is missing ios app %q", app.AppID))
+		}
+	}
+
+	for id := range liveIDs {
+		if _, ok := registered[id]; !ok {
+			mismatches = append(mismatches, fmt.Sprintf("apple-app-site-association has unregistered ios app %q", id))
+		}
+	}
+
+	sort.Strings(mismatches)
+	return mismatches
+}
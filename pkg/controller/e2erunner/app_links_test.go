@@ -0,0 +1,120 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package e2erunner
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/exposure-notifications-verification-server/pkg/api"
+	"github.com/google/exposure-notifications-verification-server/pkg/database"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestCompareAndroidAssetLinks(t *testing.T) {
+	t.Parallel()
+
+	sha1 := strings.TrimSuffix(strings.Repeat("AA:", 32), ":")
+	sha2 := strings.TrimSuffix(strings.Repeat("BB:", 32), ":")
+	typo := strings.TrimSuffix(strings.Repeat("AA;", 32), ";")
+
+	target := func(pkg string, fps ...string) *api.AndroidDataResponse {
+		return &api.AndroidDataResponse{
+			Target: api.AndroidTarget{
+				PackageName:  pkg,
+				Fingerprints: fps,
+			},
+		}
+	}
+
+	cases := []struct {
+		name string
+		apps []*database.MobileApp
+		live []*api.AndroidDataResponse
+		exp  []string
+	}{
+		{
+			name: "matches",
+			apps: []*database.MobileApp{{AppID: "com.example", SHA: sha1 + "\n" + sha2}},
+			live: []*api.AndroidDataResponse{target("com.example", sha1, sha2)},
+		},
+		{
+			name: "missing_app",
+			apps: []*database.MobileApp{{AppID: "com.example", SHA: sha1}},
+			exp:  []string{`assetlinks.json is missing android app "com.example"`},
+		},
+		{
+			name: "fingerprint_mismatch",
+			apps: []*database.MobileApp{{AppID: "com.example", SHA: sha1}},
+			live: []*api.AndroidDataResponse{target("com.example", sha2)},
+			exp: []string{
+				`assetlinks.json has unregistered fingerprint "` + sha2 + `" for "com.example"`,
+				`assetlinks.json is missing fingerprint "` + sha1 + `" for "com.example"`,
+			},
+		},
+		{
+			name: "malformed",
+			apps: []*database.MobileApp{{AppID: "com.example", SHA: typo}},
+			live: []*api.AndroidDataResponse{target("com.example", typo)},
+			exp: []string{
+				`assetlinks.json has malformed fingerprint "` + typo + `" for "com.example"`,
+				`registered fingerprint "` + typo + `" for "com.example" is malformed`,
+			},
+		},
+		{
+			name: "unregistered_app",
+			live: []*api.AndroidDataResponse{target("com.other", sha1)},
+			exp:  []string{`assetlinks.json has unregistered android app "com.other"`},
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			got := compareAndroidAssetLinks(tc.apps, tc.live)
+			if diff := cmp.Diff(tc.exp, got); diff != "" {
+				t.Errorf("mismatch (-want, +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestCompareAppleSiteAssociation(t *testing.T) {
+	t.Parallel()
+
+	live := &api.IOSDataResponse{
+		Applinks: api.IOSAppLinks{
+			Details: []api.IOSDetail{
+				{AppID: "ABCDE12345.com.example"},
+				{AppID: "ABCDE12345.com.other"},
+			},
+		},
+	}
+	apps := []*database.MobileApp{
+		{AppID: "ABCDE12345.com.example"},
+		{AppID: "ABCDE12345.com.missing"},
+	}
+
+	exp := []string{
+		`apple-app-site-association has unregistered ios app "ABCDE12345.com.other"`,
+		`apple-app-site-association is missing ios app "ABCDE12345.com.missing"`,
+	}
+	if diff := cmp.Diff(exp, compareAppleSiteAssociation(apps, live)); diff != "" {
+		t.Errorf("mismatch (-want, +got):\n%s", diff)
+	}
+}
@@ -29,6 +29,9 @@ var (
 	mRevisionSuccess   = stats.Int64(metricPrefix+"/revision/success", "successful revision execution", stats.UnitDimensionless)
 	mRedirectSuccess   = stats.Int64(metricPrefix+"/redirect/success", "successful redirect execution", stats.UnitDimensionless)
 	mUserReportSuccess = stats.Int64(metricPrefix+"/user-report/success", "successful user-report execution", stats.UnitDimensionless)
+
+	mAppLinksSuccess    = stats.Int64(metricPrefix+"/app-links/success", "successful app links execution", stats.UnitDimensionless)
+	mAppLinksMismatches = stats.Int64(metricPrefix+"/app-links/mismatches", "app link mismatches per realm", stats.UnitDimensionless)
 )
 
 func init() {
@@ -57,5 +60,18 @@ func init() {
 			Measure:     mUserReportSuccess,
 			Aggregation: view.Count(),
 		},
+		{
+			Name:        metricPrefix + "/app-links/success",
+			Description: "Number of app links successes",
+			Measure:     mAppLinksSuccess,
+			Aggregation: view.Count(),
+		},
+		{
+			Name:        metricPrefix + "/app-links/mismatches",
+			Description: "Number of app link mismatches for each realm in the latest run",
+			TagKeys:     observability.CommonTagKeys(),
+			Measure:     mAppLinksMismatches,
+			Aggregation: view.LastValue(),
+		},
 	}...)
 }
@@ -24,7 +24,8 @@ import (
 )
 
 // SendInviteEmailFunc returns a function capable of sending a new user invitation.
-func SendInviteEmailFunc(ctx context.Context, db *database.Database, h *render.Renderer, email string,
+// The realm's template variant for locale is used, if one exists.
+func SendInviteEmailFunc(ctx context.Context, db *database.Database, h *render.Renderer, email, locale string,
 	realm *database.Realm,
 ) (auth.InviteUserEmailFunc, error) {
 	// Lookup the email provider
@@ -39,7 +40,7 @@ func SendInviteEmailFunc(ctx context.Context, db *database.Database, h *render.R
 	// Return a function that does the actual sending.
 	return func(ctx context.Context, inviteLink string) error {
 		var message []byte
-		if realm.EmailInviteTemplate != "" {
+		if realm.EmailInviteTemplateFor(locale) != "" {
 			// Render from the realm template with the plain header.
 			header, err := h.RenderEmail("email/plainheader", map[string]interface{}{
 				"ToEmail":   email,
@@ -48,7 +49,7 @@ func SendInviteEmailFunc(ctx context.Context, db *database.Database, h *render.R
 			if err != nil {
 				return fmt.Errorf("failed to render email header template: %w", err)
 			}
-			body := []byte(realm.BuildInviteEmail(inviteLink))
+			body := []byte(realm.BuildInviteEmail(inviteLink, locale))
 			message = append(header, body...)
 		} else {
 			// Render the message invitation from the default template.
@@ -73,7 +74,7 @@ func SendInviteEmailFunc(ctx context.Context, db *database.Database, h *render.R
 
 // SendPasswordResetEmailFunc returns a function capable of sending a password
 // reset for the given user.
-func SendPasswordResetEmailFunc(ctx context.Context, db *database.Database, h *render.Renderer, email string,
+func SendPasswordResetEmailFunc(ctx context.Context, db *database.Database, h *render.Renderer, email, locale string,
 	realm *database.Realm,
 ) (auth.ResetPasswordEmailFunc, error) {
 	// Lookup the email provider
@@ -87,7 +88,7 @@ func SendPasswordResetEmailFunc(ctx context.Context, db *database.Database, h *r
 
 	return func(ctx context.Context, resetLink string) error {
 		var message []byte
-		if realm.EmailPasswordResetTemplate != "" {
+		if realm.EmailPasswordResetTemplateFor(locale) != "" {
 			// Render from the realm template with the plain header.
 			header, err := h.RenderEmail("email/plainheader", map[string]interface{}{
 				"ToEmail":   email,
@@ -96,7 +97,7 @@ func SendPasswordResetEmailFunc(ctx context.Context, db *database.Database, h *r
 			if err != nil {
 				return fmt.Errorf("failed to render email header template: %w", err)
 			}
-			body := []byte(realm.BuildPasswordResetEmail(resetLink))
+			body := []byte(realm.BuildPasswordResetEmail(resetLink, locale))
 			message = append(header, body...)
 		} else {
 			// Render the reset email.
@@ -121,7 +122,7 @@ func SendPasswordResetEmailFunc(ctx context.Context, db *database.Database, h *r
 
 // SendEmailVerificationEmailFunc returns a function capable of sending an email
 // verification email.
-func SendEmailVerificationEmailFunc(ctx context.Context, db *database.Database, h *render.Renderer, email string,
+func SendEmailVerificationEmailFunc(ctx context.Context, db *database.Database, h *render.Renderer, email, locale string,
 	realm *database.Realm,
 ) (auth.EmailVerificationEmailFunc, error) {
 	// Lookup the email provider
@@ -135,7 +136,7 @@ func SendEmailVerificationEmailFunc(ctx context.Context, db *database.Database,
 
 	return func(ctx context.Context, verifyLink string) error {
 		var message []byte
-		if realm.EmailVerifyTemplate != "" {
+		if realm.EmailVerifyTemplateFor(locale) != "" {
 			// Render from the realm template with the plain header.
 			header, err := h.RenderEmail("email/plainheader", map[string]interface{}{
 				"ToEmail":   email,
@@ -144,7 +145,7 @@ func SendEmailVerificationEmailFunc(ctx context.Context, db *database.Database,
 			if err != nil {
 				return fmt.Errorf("failed to render email header template: %w", err)
 			}
-			body := []byte(realm.BuildVerifyEmail(verifyLink))
+			body := []byte(realm.BuildVerifyEmail(verifyLink, locale))
 			message = append(header, body...)
 		} else {
 			// Render the reset email.
@@ -31,6 +31,11 @@ import (
 const (
 	emailerAnomaliesLock = "emailerAnomaliesLock"
 	emailerSMSErrorsLock = "emailerSMSErrorsLock"
+
+	emailerSMSFromNumberChangesLock = "emailerSMSFromNumberChangesLock"
+	emailerInactiveAPIKeysLock      = "emailerInactiveAPIKeysLock"
+	emailerRealmNotificationsLock   = "emailerRealmNotificationsLock"
+	emailerUnclaimedCodesLock       = "emailerUnclaimedCodesLock"
 )
 
 type Controller struct {
@@ -0,0 +1,166 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package emailer
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/google/exposure-notifications-server/pkg/logging"
+	"github.com/google/exposure-notifications-verification-server/pkg/database"
+	"github.com/google/exposure-notifications-verification-server/pkg/rbac"
+	"github.com/hashicorp/go-multierror"
+	"go.opencensus.io/stats"
+)
+
+// HandleInactiveAPIKeys handles a request to send emails to realm admins about
+// API keys that were flagged or disabled for inactivity.
+func (c *Controller) HandleInactiveAPIKeys() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		logger := logging.FromContext(ctx).Named("emailer.HandleInactiveAPIKeys")
+		logger.Debugw("starting")
+		defer logger.Debugw("finishing")
+
+		ok, err := c.db.TryLock(ctx, emailerInactiveAPIKeysLock, c.config.InactiveAPIKeysMinTTL)
+		if err != nil {
+			logger.Errorw("failed to acquire lock", "error", err)
+			c.h.RenderJSON(w, http.StatusInternalServerError, err)
+			return
+		}
+		if !ok {
+			logger.Debugw("skipping (too early)")
+			c.h.RenderJSON(w, http.StatusOK, fmt.Errorf("too early"))
+			return
+		}
+
+		events, err := c.db.ListPendingAPIKeyInactivityEvents()
+		if err != nil {
+			logger.Errorw("failed to list pending api key inactivity events", "error", err)
+			c.h.RenderJSON(w, http.StatusInternalServerError, err)
+			return
+		}
+
+		// Group the events by realm, preserving order.
+		var realmIDs []uint
+		byRealm := make(map[uint][]*database.APIKeyInactivityEvent)
+		for _, event := range events {
+			if _, ok := byRealm[event.RealmID]; !ok {
+				realmIDs = append(realmIDs, event.RealmID)
+			}
+			byRealm[event.RealmID] = append(byRealm[event.RealmID], event)
+		}
+
+		var merr *multierror.Error
+		for _, realmID := range realmIDs {
+			if err := c.sendInactiveAPIKeysEmails(ctx, realmID, byRealm[realmID]); err != nil {
+				merr = multierror.Append(merr, fmt.Errorf("failed to send emails for realm %d: %w", realmID, err))
+				continue
+			}
+		}
+
+		if err := merr.ErrorOrNil(); err != nil {
+			logger.Errorw("failed to send inactive api keys emails", "error", err)
+			c.h.RenderJSON(w, http.StatusInternalServerError, err)
+			return
+		}
+
+		stats.Record(ctx, mInactiveAPIKeysSuccess.M(1))
+		c.h.RenderJSON(w, http.StatusOK, nil)
+	})
+}
+
+// sendInactiveAPIKeysEmails sends an email about the events to the realm's API
+// key admins and email contacts, and records who was notified. Events for
+// realms without any recipients are still marked as notified, with no
+// recipients, so they are not retried forever.
+func (c *Controller) sendInactiveAPIKeysEmails(ctx context.Context, realmID uint, events []*database.APIKeyInactivityEvent) error {
+	logger := logging.FromContext(ctx).Named("emailer.sendInactiveAPIKeysEmails").
+		With("realm_id", realmID)
+
+	ids := make([]uint, 0, len(events))
+	var flagged, disabled []*database.APIKeyInactivityEvent
+	for _, event := range events {
+		ids = append(ids, event.ID)
+
+		switch {
+		case event.IsFlagged():
+			flagged = append(flagged, event)
+		case event.IsDisabled():
+			disabled = append(disabled, event)
+		}
+	}
+
+	realm, err := c.db.FindRealm(realmID)
+	if err != nil {
+		return fmt.Errorf("failed to find realm: %w", err)
+	}
+
+	admins, err := realm.ListUserEmailsWithPermission(c.db, rbac.APIKeyWrite)
+	if err != nil {
+		return fmt.Errorf("failed to list api key admins: %w", err)
+	}
+
+	// Realm contacts may also be admins, so only include each address once.
+	var tos []string
+	seen := make(map[string]struct{})
+	for _, address := range append(admins, realm.ContactEmailAddresses...) {
+		if _, ok := seen[address]; ok {
+			continue
+		}
+		seen[address] = struct{}{}
+		tos = append(tos, address)
+	}
+
+	from := c.config.FromAddress
+	ccs := c.config.CCAddresses
+	bccs := c.config.BCCAddresses
+
+	var addresses []string
+	addresses = append(addresses, tos...)
+	addresses = append(addresses, ccs...)
+	addresses = append(addresses, bccs...)
+
+	if len(addresses) == 0 {
+		logger.Warnw("no admin, contact, cc, or bcc email addresses registered, skipping")
+		return c.db.MarkAPIKeyInactivityEventsNotified(ids, nil)
+	}
+
+	msg, err := c.h.RenderEmail("email/inactive_api_keys", map[string]interface{}{
+		"FromAddress": from,
+		"ToAddresses": tos,
+		"CCAddresses": ccs,
+		"Realm":       realm,
+		"RootURL":     c.config.ServerEndpoint,
+		"Flagged":     flagged,
+		"Disabled":    disabled,
+		"GraceDays":   int(database.APIKeyInactivityGracePeriod.Hours() / 24),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to render template: %w", err)
+	}
+
+	logger.Debugw("sending email",
+		"tos", tos,
+		"ccs", ccs,
+		"bccs", bccs)
+	if err := c.sendMail(ctx, addresses, msg); err != nil {
+		return fmt.Errorf("failed to send: %w", err)
+	}
+
+	return c.db.MarkAPIKeyInactivityEventsNotified(ids, addresses)
+}
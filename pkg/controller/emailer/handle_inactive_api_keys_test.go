@@ -0,0 +1,124 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package emailer
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/exposure-notifications-server/pkg/logging"
+	"github.com/google/exposure-notifications-verification-server/assets"
+	"github.com/google/exposure-notifications-verification-server/internal/project"
+	"github.com/google/exposure-notifications-verification-server/pkg/config"
+	"github.com/google/exposure-notifications-verification-server/pkg/database"
+	"github.com/google/exposure-notifications-verification-server/pkg/render"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestSendInactiveAPIKeysEmails(t *testing.T) {
+	t.Parallel()
+
+	ctx := project.TestContext(t)
+
+	h, err := render.New(ctx, assets.ServerFS(), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("no_recipients", func(t *testing.T) {
+		t.Parallel()
+
+		logCore, logObserver := observer.New(zap.DebugLevel)
+		ctx := logging.WithLogger(ctx, zap.New(logCore).Sugar())
+
+		db, _ := testDatabaseInstance.NewDatabase(t, nil)
+
+		realm := database.NewRealmWithDefaults("inactivity")
+		if err := db.SaveRealm(realm, database.SystemTest); err != nil {
+			t.Fatal(err)
+		}
+
+		event := &database.APIKeyInactivityEvent{
+			RealmID:         realm.ID,
+			AuthorizedAppID: 1,
+			Name:            "old integration",
+			Action:          database.APIKeyInactivityActionFlagged,
+		}
+		if err := db.RawDB().Create(event).Error; err != nil {
+			t.Fatal(err)
+		}
+
+		c := New(&config.EmailerConfig{}, db, h)
+
+		if err := c.sendInactiveAPIKeysEmails(ctx, realm.ID, []*database.APIKeyInactivityEvent{event}); err != nil {
+			t.Fatal(err)
+		}
+
+		testExpectLog(t, logObserver, "no admin, contact, cc, or bcc email addresses registered, skipping")
+
+		pending, err := db.ListPendingAPIKeyInactivityEvents()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := len(pending), 0; got != want {
+			t.Errorf("expected %d pending events to be %d", got, want)
+		}
+	})
+
+	t.Run("renders", func(t *testing.T) {
+		t.Parallel()
+
+		db, _ := testDatabaseInstance.NewDatabase(t, nil)
+
+		realm, err := db.FindRealm(1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		realm.APIKeyInactivityDays = 90
+
+		c := New(&config.EmailerConfig{}, db, h)
+
+		msg, err := c.h.RenderEmail("email/inactive_api_keys", map[string]interface{}{
+			"FromAddress": "from@example.com",
+			"ToAddresses": []string{"admin@example.com"},
+			"Realm":       realm,
+			"RootURL":     "http://example.com",
+			"Flagged": []*database.APIKeyInactivityEvent{
+				{AuthorizedAppID: 4, Name: "old integration"},
+			},
+			"Disabled": []*database.APIKeyInactivityEvent{
+				{AuthorizedAppID: 7, Name: "retired pilot"},
+			},
+			"GraceDays": 7,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, want := range []string{
+			"From: from@example.com\n",
+			"To: admin@example.com\n",
+			"90 days",
+			"disabled in 7 days",
+			"http://example.com/realm/apikeys/4",
+			"retired pilot",
+		} {
+			if got := string(msg); !strings.Contains(got, want) {
+				t.Errorf("expected %q to contain %q", got, want)
+			}
+		}
+	})
+}
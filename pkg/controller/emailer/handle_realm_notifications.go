@@ -0,0 +1,177 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package emailer
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/google/exposure-notifications-server/pkg/logging"
+	"github.com/google/exposure-notifications-verification-server/pkg/database"
+	"github.com/hashicorp/go-multierror"
+	"go.opencensus.io/stats"
+)
+
+// HandleRealmNotifications handles a request to fan out realm notifications by
+// email to the realm's contacts and by SMS to the realm's notification phone
+// numbers.
+func (c *Controller) HandleRealmNotifications() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		logger := logging.FromContext(ctx).Named("emailer.HandleRealmNotifications")
+		logger.Debugw("starting")
+		defer logger.Debugw("finishing")
+
+		ok, err := c.db.TryLock(ctx, emailerRealmNotificationsLock, c.config.RealmNotificationsMinTTL)
+		if err != nil {
+			logger.Errorw("failed to acquire lock", "error", err)
+			c.h.RenderJSON(w, http.StatusInternalServerError, err)
+			return
+		}
+		if !ok {
+			logger.Debugw("skipping (too early)")
+			c.h.RenderJSON(w, http.StatusOK, fmt.Errorf("too early"))
+			return
+		}
+
+		notifications, err := c.db.ListPendingRealmNotificationFanouts()
+		if err != nil {
+			logger.Errorw("failed to list pending realm notifications", "error", err)
+			c.h.RenderJSON(w, http.StatusInternalServerError, err)
+			return
+		}
+
+		// Notifications are ordered by realm, so each realm is only loaded once.
+		var realm *database.Realm
+		var merr *multierror.Error
+		for _, notification := range notifications {
+			if realm == nil || realm.ID != notification.RealmID {
+				realm, err = c.db.FindRealm(notification.RealmID)
+				if err != nil {
+					merr = multierror.Append(merr, fmt.Errorf("failed to find realm %d: %w", notification.RealmID, err))
+					realm = nil
+					continue
+				}
+			}
+
+			if err := c.fanoutRealmNotification(ctx, realm, notification); err != nil {
+				merr = multierror.Append(merr, fmt.Errorf("failed to fan out notification %d: %w", notification.ID, err))
+				continue
+			}
+		}
+
+		if err := merr.ErrorOrNil(); err != nil {
+			logger.Errorw("failed to fan out realm notifications", "error", err)
+			c.h.RenderJSON(w, http.StatusInternalServerError, err)
+			return
+		}
+
+		stats.Record(ctx, mRealmNotificationsSuccess.M(1))
+		c.h.RenderJSON(w, http.StatusOK, nil)
+	})
+}
+
+// fanoutRealmNotification sends the notification by email and SMS, as
+// requested by the notification, and records who received it. Notifications
+// for realms without any recipients are still marked as fanned out, with no
+// recipients, so they are not retried forever.
+//
+// SMS messages only contain the title and a link to the notification center,
+// since the body may be long and contain markdown.
+func (c *Controller) fanoutRealmNotification(ctx context.Context, realm *database.Realm, notification *database.RealmNotification) error {
+	logger := logging.FromContext(ctx).Named("emailer.fanoutRealmNotification").
+		With("realm_id", realm.ID).
+		With("notification_id", notification.ID)
+
+	var recipients []string
+
+	if notification.SendEmail {
+		tos := realm.ContactEmailAddresses
+		ccs := c.config.CCAddresses
+		bccs := c.config.BCCAddresses
+
+		var addresses []string
+		addresses = append(addresses, tos...)
+		addresses = append(addresses, ccs...)
+		addresses = append(addresses, bccs...)
+
+		if len(addresses) == 0 {
+			logger.Warnw("no contact, cc, or bcc email addresses registered, skipping email")
+		} else {
+			msg, err := c.h.RenderEmail("email/realm_notification", map[string]interface{}{
+				"FromAddress":  c.config.FromAddress,
+				"ToAddresses":  tos,
+				"CCAddresses":  ccs,
+				"Realm":        realm,
+				"RootURL":      c.config.ServerEndpoint,
+				"Notification": notification,
+			})
+			if err != nil {
+				return fmt.Errorf("failed to render template: %w", err)
+			}
+
+			logger.Debugw("sending email",
+				"tos", tos,
+				"ccs", ccs,
+				"bccs", bccs)
+			if err := c.sendMail(ctx, addresses, msg); err != nil {
+				return fmt.Errorf("failed to send email: %w", err)
+			}
+			recipients = append(recipients, addresses...)
+		}
+	}
+
+	if notification.SendSMS {
+		phones := realm.NotificationPhoneNumbers
+
+		if len(phones) == 0 {
+			logger.Warnw("no notification phone numbers registered, skipping sms")
+		} else {
+			provider, err := realm.SMSProvider(c.db)
+			if err != nil {
+				return fmt.Errorf("failed to get sms provider: %w", err)
+			}
+
+			if provider == nil {
+				logger.Warnw("realm has no sms provider, skipping sms")
+			} else {
+				message := fmt.Sprintf("[%s] %s: %s/realm/notifications",
+					realm.Name, notification.Title, c.config.ServerEndpoint)
+
+				// Keep going if one number fails, so the others are still notified.
+				// The notification is only retried if nobody received it.
+				var merr *multierror.Error
+				for _, phone := range phones {
+					if err := provider.SendSMS(ctx, phone, message); err != nil {
+						merr = multierror.Append(merr, fmt.Errorf("failed to send sms: %w", err))
+						continue
+					}
+					recipients = append(recipients, phone)
+				}
+
+				if err := merr.ErrorOrNil(); err != nil {
+					logger.Errorw("failed to send sms", "error", err)
+					if len(recipients) == 0 {
+						return err
+					}
+				}
+			}
+		}
+	}
+
+	return c.db.MarkRealmNotificationFannedOut(notification, recipients)
+}
@@ -0,0 +1,172 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package emailer
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/exposure-notifications-server/pkg/logging"
+	"github.com/google/exposure-notifications-verification-server/assets"
+	"github.com/google/exposure-notifications-verification-server/internal/project"
+	"github.com/google/exposure-notifications-verification-server/pkg/config"
+	"github.com/google/exposure-notifications-verification-server/pkg/database"
+	"github.com/google/exposure-notifications-verification-server/pkg/render"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestFanoutRealmNotification(t *testing.T) {
+	t.Parallel()
+
+	ctx := project.TestContext(t)
+
+	h, err := render.New(ctx, assets.ServerFS(), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("no_recipients", func(t *testing.T) {
+		t.Parallel()
+
+		logCore, logObserver := observer.New(zap.DebugLevel)
+		ctx := logging.WithLogger(ctx, zap.New(logCore).Sugar())
+
+		db, _ := testDatabaseInstance.NewDatabase(t, nil)
+
+		realm, err := db.FindRealm(1)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		realm.ContactEmailAddresses = []string{}
+		realm.NotificationPhoneNumbers = []string{}
+		if err := db.SaveRealm(realm, database.SystemTest); err != nil {
+			t.Fatal(err)
+		}
+
+		notification := &database.RealmNotification{
+			RealmID:   realm.ID,
+			Kind:      database.RealmNotificationKindOperator,
+			Title:     "Hello",
+			Body:      "World",
+			SendEmail: true,
+			SendSMS:   true,
+		}
+		if _, err := db.PostRealmNotification(notification); err != nil {
+			t.Fatal(err)
+		}
+
+		c := New(&config.EmailerConfig{}, db, h)
+
+		if err := c.fanoutRealmNotification(ctx, realm, notification); err != nil {
+			t.Fatal(err)
+		}
+
+		testExpectLog(t, logObserver, "no contact, cc, or bcc email addresses registered, skipping email")
+		testExpectLog(t, logObserver, "no notification phone numbers registered, skipping sms")
+
+		pending, err := db.ListPendingRealmNotificationFanouts()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := len(pending), 0; got != want {
+			t.Errorf("expected %d pending notifications to be %d", got, want)
+		}
+	})
+
+	t.Run("no_sms_provider", func(t *testing.T) {
+		t.Parallel()
+
+		logCore, logObserver := observer.New(zap.DebugLevel)
+		ctx := logging.WithLogger(ctx, zap.New(logCore).Sugar())
+
+		db, _ := testDatabaseInstance.NewDatabase(t, nil)
+
+		realm := database.NewRealmWithDefaults("no-sms")
+		realm.NotificationPhoneNumbers = []string{"+15005550006"}
+		if err := db.SaveRealm(realm, database.SystemTest); err != nil {
+			t.Fatal(err)
+		}
+
+		notification := &database.RealmNotification{
+			RealmID: realm.ID,
+			Kind:    database.RealmNotificationKindOperator,
+			Title:   "Hello",
+			Body:    "World",
+			SendSMS: true,
+		}
+		if _, err := db.PostRealmNotification(notification); err != nil {
+			t.Fatal(err)
+		}
+
+		c := New(&config.EmailerConfig{}, db, h)
+
+		if err := c.fanoutRealmNotification(ctx, realm, notification); err != nil {
+			t.Fatal(err)
+		}
+
+		testExpectLog(t, logObserver, "realm has no sms provider, skipping sms")
+
+		if notification.FannedOutAt == nil {
+			t.Errorf("expected notification to be marked fanned out")
+		}
+		if got := notification.FanoutRecipients; len(got) != 0 {
+			t.Errorf("expected no recipients, got %v", got)
+		}
+	})
+
+	t.Run("renders", func(t *testing.T) {
+		t.Parallel()
+
+		db, _ := testDatabaseInstance.NewDatabase(t, nil)
+
+		realm, err := db.FindRealm(1)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		c := New(&config.EmailerConfig{}, db, h)
+
+		msg, err := c.h.RenderEmail("email/realm_notification", map[string]interface{}{
+			"FromAddress": "from@example.com",
+			"ToAddresses": []string{"to1@example.com", "to2@example.com"},
+			"CCAddresses": []string{"cc1@example.com"},
+			"Realm":       realm,
+			"RootURL":     "http://example.com",
+			"Notification": &database.RealmNotification{
+				Title: "Scheduled maintenance",
+				Body:  "The server will be unavailable for **one hour**.",
+				Link:  "/realm/settings",
+			},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, want := range []string{
+			"Subject: Exposure Notifications: Scheduled maintenance\n",
+			"From: from@example.com\n",
+			"To: to1@example.com,to2@example.com\n",
+			"Cc: cc1@example.com\n",
+			"<strong>one hour</strong>",
+			"http://example.com/realm/settings",
+		} {
+			if got := string(msg); !strings.Contains(got, want) {
+				t.Errorf("expected %q to contain %q", got, want)
+			}
+		}
+	})
+}
@@ -0,0 +1,140 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package emailer
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/google/exposure-notifications-server/pkg/logging"
+	"github.com/google/exposure-notifications-verification-server/pkg/database"
+	"github.com/hashicorp/go-multierror"
+	"go.opencensus.io/stats"
+)
+
+// HandleSMSFromNumberChanges handles a request to send emails to realms whose
+// system SMS from number changed.
+func (c *Controller) HandleSMSFromNumberChanges() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		logger := logging.FromContext(ctx).Named("emailer.HandleSMSFromNumberChanges")
+		logger.Debugw("starting")
+		defer logger.Debugw("finishing")
+
+		ok, err := c.db.TryLock(ctx, emailerSMSFromNumberChangesLock, c.config.SMSFromNumberChangesMinTTL)
+		if err != nil {
+			logger.Errorw("failed to acquire lock", "error", err)
+			c.h.RenderJSON(w, http.StatusInternalServerError, err)
+			return
+		}
+		if !ok {
+			logger.Debugw("skipping (too early)")
+			c.h.RenderJSON(w, http.StatusOK, fmt.Errorf("too early"))
+			return
+		}
+
+		changes, err := c.db.ListPendingSMSFromNumberChanges()
+		if err != nil {
+			logger.Errorw("failed to list pending sms from number changes", "error", err)
+			c.h.RenderJSON(w, http.StatusInternalServerError, err)
+			return
+		}
+
+		// Group the changes by realm, preserving order.
+		var realmIDs []uint
+		byRealm := make(map[uint][]*database.SMSFromNumberChange)
+		for _, change := range changes {
+			if _, ok := byRealm[change.RealmID]; !ok {
+				realmIDs = append(realmIDs, change.RealmID)
+			}
+			byRealm[change.RealmID] = append(byRealm[change.RealmID], change)
+		}
+
+		var merr *multierror.Error
+		for _, realmID := range realmIDs {
+			if err := c.sendSMSFromNumberChangesEmails(ctx, realmID, byRealm[realmID]); err != nil {
+				merr = multierror.Append(merr, fmt.Errorf("failed to send emails for realm %d: %w", realmID, err))
+				continue
+			}
+		}
+
+		if err := merr.ErrorOrNil(); err != nil {
+			logger.Errorw("failed to send sms from number changes emails", "error", err)
+			c.h.RenderJSON(w, http.StatusInternalServerError, err)
+			return
+		}
+
+		stats.Record(ctx, mSMSFromNumberChangesSuccess.M(1))
+		c.h.RenderJSON(w, http.StatusOK, nil)
+	})
+}
+
+// sendSMSFromNumberChangesEmails sends an email about the changes to all email
+// contacts in the realm and records who was notified. Changes for realms
+// without any recipients are still marked as notified, with no recipients, so
+// they are not retried forever.
+func (c *Controller) sendSMSFromNumberChangesEmails(ctx context.Context, realmID uint, changes []*database.SMSFromNumberChange) error {
+	logger := logging.FromContext(ctx).Named("emailer.sendSMSFromNumberChangesEmails").
+		With("realm_id", realmID)
+
+	ids := make([]uint, 0, len(changes))
+	for _, change := range changes {
+		ids = append(ids, change.ID)
+	}
+
+	realm, err := c.db.FindRealm(realmID)
+	if err != nil {
+		return fmt.Errorf("failed to find realm: %w", err)
+	}
+
+	from := c.config.FromAddress
+	tos := realm.ContactEmailAddresses
+	ccs := c.config.CCAddresses
+	bccs := c.config.BCCAddresses
+
+	var addresses []string
+	addresses = append(addresses, tos...)
+	addresses = append(addresses, ccs...)
+	addresses = append(addresses, bccs...)
+
+	if len(addresses) == 0 {
+		logger.Warnw("no contact, cc, or bcc email addresses registered, skipping")
+		return c.db.MarkSMSFromNumberChangesNotified(ids, nil)
+	}
+
+	msg, err := c.h.RenderEmail("email/sms_from_number_changes", map[string]interface{}{
+		"FromAddress": from,
+		"ToAddresses": tos,
+		"CCAddresses": ccs,
+		"Realm":       realm,
+		"RootURL":     c.config.ServerEndpoint,
+		"Changes":     changes,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to render template: %w", err)
+	}
+
+	logger.Debugw("sending email",
+		"tos", tos,
+		"ccs", ccs,
+		"bccs", bccs)
+	if err := c.sendMail(ctx, addresses, msg); err != nil {
+		return fmt.Errorf("failed to send: %w", err)
+	}
+
+	return c.db.MarkSMSFromNumberChangesNotified(ids, addresses)
+}
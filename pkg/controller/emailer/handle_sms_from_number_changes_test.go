@@ -0,0 +1,130 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package emailer
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/exposure-notifications-server/pkg/logging"
+	"github.com/google/exposure-notifications-verification-server/assets"
+	"github.com/google/exposure-notifications-verification-server/internal/project"
+	"github.com/google/exposure-notifications-verification-server/pkg/config"
+	"github.com/google/exposure-notifications-verification-server/pkg/database"
+	"github.com/google/exposure-notifications-verification-server/pkg/render"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestSendSMSFromNumberChangesEmails(t *testing.T) {
+	t.Parallel()
+
+	ctx := project.TestContext(t)
+
+	h, err := render.New(ctx, assets.ServerFS(), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("no_contacts", func(t *testing.T) {
+		t.Parallel()
+
+		logCore, logObserver := observer.New(zap.DebugLevel)
+		ctx := logging.WithLogger(ctx, zap.New(logCore).Sugar())
+
+		db, _ := testDatabaseInstance.NewDatabase(t, nil)
+
+		realm, err := db.FindRealm(1)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		realm.ContactEmailAddresses = []string{}
+		if err := db.SaveRealm(realm, database.SystemTest); err != nil {
+			t.Fatal(err)
+		}
+
+		number := &database.SMSFromNumber{Label: "primary", Value: "+15005550000"}
+		if err := db.CreateOrUpdateSMSFromNumbers([]*database.SMSFromNumber{number}); err != nil {
+			t.Fatal(err)
+		}
+
+		change := &database.SMSFromNumberChange{
+			RealmID:         realm.ID,
+			SMSFromNumberID: number.ID,
+			Label:           "primary",
+			OldValue:        "+15005550000",
+			NewValue:        "+15005550006",
+		}
+		if err := db.RawDB().Create(change).Error; err != nil {
+			t.Fatal(err)
+		}
+
+		c := New(&config.EmailerConfig{}, db, h)
+
+		if err := c.sendSMSFromNumberChangesEmails(ctx, realm.ID, []*database.SMSFromNumberChange{change}); err != nil {
+			t.Fatal(err)
+		}
+
+		testExpectLog(t, logObserver, "no contact, cc, or bcc email addresses registered, skipping")
+
+		pending, err := db.ListPendingSMSFromNumberChanges()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := len(pending), 0; got != want {
+			t.Errorf("expected %d pending changes to be %d", got, want)
+		}
+	})
+
+	t.Run("renders", func(t *testing.T) {
+		t.Parallel()
+
+		db, _ := testDatabaseInstance.NewDatabase(t, nil)
+
+		realm, err := db.FindRealm(1)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		c := New(&config.EmailerConfig{}, db, h)
+
+		msg, err := c.h.RenderEmail("email/sms_from_number_changes", map[string]interface{}{
+			"FromAddress": "from@example.com",
+			"ToAddresses": []string{"to1@example.com", "to2@example.com"},
+			"CCAddresses": []string{"cc1@example.com"},
+			"Realm":       realm,
+			"RootURL":     "http://example.com",
+			"Changes": []*database.SMSFromNumberChange{
+				{Label: "primary", OldValue: "+15005550000", NewValue: "+15005550006"},
+			},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, want := range []string{
+			"From: from@example.com\n",
+			"To: to1@example.com,to2@example.com\n",
+			"Cc: cc1@example.com\n",
+			"+15005550000",
+			"+15005550006",
+		} {
+			if got := string(msg); !strings.Contains(got, want) {
+				t.Errorf("expected %q to contain %q", got, want)
+			}
+		}
+	})
+}
@@ -0,0 +1,224 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package emailer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/google/exposure-notifications-server/pkg/logging"
+	"github.com/google/exposure-notifications-verification-server/pkg/api"
+	"github.com/google/exposure-notifications-verification-server/pkg/database"
+	"github.com/hashicorp/go-multierror"
+	"go.opencensus.io/stats"
+)
+
+// unclaimedCodesIssuer identifies the user or API key that issued unclaimed
+// codes in a realm. Exactly one of UserID and AppID is set.
+type unclaimedCodesIssuer struct {
+	RealmID uint
+	UserID  uint
+	AppID   uint
+}
+
+// HandleUnclaimedCodes handles a request to send the daily unclaimed codes
+// reports. Users who opted in are emailed the unclaimed codes they issued, and
+// API keys that opted in are sent a notification to their callback URL.
+func (c *Controller) HandleUnclaimedCodes() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		logger := logging.FromContext(ctx).Named("emailer.HandleUnclaimedCodes")
+		logger.Debugw("starting")
+		defer logger.Debugw("finishing")
+
+		ok, err := c.db.TryLock(ctx, emailerUnclaimedCodesLock, c.config.UnclaimedCodesReportMinTTL)
+		if err != nil {
+			logger.Errorw("failed to acquire lock", "error", err)
+			c.h.RenderJSON(w, http.StatusInternalServerError, err)
+			return
+		}
+		if !ok {
+			logger.Debugw("skipping (too early)")
+			c.h.RenderJSON(w, http.StatusOK, fmt.Errorf("too early"))
+			return
+		}
+
+		since := time.Now().UTC().Add(-24 * time.Hour * time.Duration(c.config.UnclaimedCodesReportDays))
+		codes, err := c.db.ListUnclaimedCodesForReport(since)
+		if err != nil {
+			logger.Errorw("failed to list unclaimed codes", "error", err)
+			c.h.RenderJSON(w, http.StatusInternalServerError, err)
+			return
+		}
+
+		// Group the codes by issuer, preserving order.
+		var issuers []unclaimedCodesIssuer
+		byIssuer := make(map[unclaimedCodesIssuer][]*database.VerificationCode)
+		for _, code := range codes {
+			issuer := unclaimedCodesIssuer{RealmID: code.RealmID, UserID: code.IssuingUserID}
+			if issuer.UserID == 0 {
+				issuer.AppID = code.IssuingAppID
+			}
+
+			if _, ok := byIssuer[issuer]; !ok {
+				issuers = append(issuers, issuer)
+			}
+			byIssuer[issuer] = append(byIssuer[issuer], code)
+		}
+
+		var merr *multierror.Error
+		for _, issuer := range issuers {
+			if issuer.UserID != 0 {
+				if err := c.sendUnclaimedCodesEmail(ctx, issuer.RealmID, issuer.UserID, byIssuer[issuer]); err != nil {
+					merr = multierror.Append(merr, fmt.Errorf("failed to send report to user %d: %w", issuer.UserID, err))
+				}
+				continue
+			}
+
+			if err := c.enqueueUnclaimedCodesReport(ctx, issuer.AppID, byIssuer[issuer]); err != nil {
+				merr = multierror.Append(merr, fmt.Errorf("failed to send report to api key %d: %w", issuer.AppID, err))
+			}
+		}
+
+		if err := merr.ErrorOrNil(); err != nil {
+			logger.Errorw("failed to send unclaimed codes reports", "error", err)
+			c.h.RenderJSON(w, http.StatusInternalServerError, err)
+			return
+		}
+
+		stats.Record(ctx, mUnclaimedCodesSuccess.M(1))
+		c.h.RenderJSON(w, http.StatusOK, nil)
+	})
+}
+
+// sendUnclaimedCodesEmail emails the user the unclaimed codes they issued in
+// the realm. Users who have since opted out or left the realm are skipped.
+func (c *Controller) sendUnclaimedCodesEmail(ctx context.Context, realmID, userID uint, codes []*database.VerificationCode) error {
+	logger := logging.FromContext(ctx).Named("emailer.sendUnclaimedCodesEmail").
+		With("realm_id", realmID).
+		With("user_id", userID)
+
+	user, err := c.db.FindUser(userID)
+	if err != nil {
+		if database.IsNotFound(err) {
+			logger.Debugw("user no longer exists, skipping")
+			return nil
+		}
+		return fmt.Errorf("failed to find user: %w", err)
+	}
+	if !user.UnclaimedCodesReport {
+		logger.Debugw("user opted out, skipping")
+		return nil
+	}
+
+	membership, err := user.FindMembership(c.db, realmID)
+	if err != nil {
+		if database.IsNotFound(err) {
+			logger.Debugw("user is no longer a member of the realm, skipping")
+			return nil
+		}
+		return fmt.Errorf("failed to find membership: %w", err)
+	}
+
+	tos := []string{user.Email}
+	ccs := c.config.CCAddresses
+	bccs := c.config.BCCAddresses
+
+	var addresses []string
+	addresses = append(addresses, tos...)
+	addresses = append(addresses, ccs...)
+	addresses = append(addresses, bccs...)
+
+	msg, err := c.h.RenderEmail("email/unclaimed_codes", map[string]interface{}{
+		"FromAddress": c.config.FromAddress,
+		"ToAddresses": tos,
+		"CCAddresses": ccs,
+		"Realm":       membership.Realm,
+		"RootURL":     c.config.ServerEndpoint,
+		"Codes":       codes,
+		"Days":        c.config.UnclaimedCodesReportDays,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to render template: %w", err)
+	}
+
+	logger.Debugw("sending email",
+		"tos", tos,
+		"ccs", ccs,
+		"bccs", bccs)
+	if err := c.sendMail(ctx, addresses, msg); err != nil {
+		return fmt.Errorf("failed to send: %w", err)
+	}
+	return nil
+}
+
+// enqueueUnclaimedCodesReport queues a notification listing the unclaimed
+// codes for delivery to the API key's callback URL. API keys that have since
+// opted out, been disabled, or removed their callback URL are skipped.
+func (c *Controller) enqueueUnclaimedCodesReport(ctx context.Context, appID uint, codes []*database.VerificationCode) error {
+	logger := logging.FromContext(ctx).Named("emailer.enqueueUnclaimedCodesReport").
+		With("authorized_app_id", appID)
+
+	app, err := c.db.FindAuthorizedApp(appID)
+	if err != nil {
+		if database.IsNotFound(err) {
+			logger.Debugw("api key no longer exists, skipping")
+			return nil
+		}
+		return fmt.Errorf("failed to find api key: %w", err)
+	}
+	if !app.UnclaimedCodesReport || app.CallbackURL == "" {
+		logger.Debugw("api key opted out, skipping")
+		return nil
+	}
+
+	b, err := json.Marshal(buildUnclaimedCodesNotification(codes, time.Now().UTC()))
+	if err != nil {
+		return fmt.Errorf("failed to marshal notification: %w", err)
+	}
+
+	if _, err := c.db.EnqueueCallbackDelivery(app.ID, b); err != nil {
+		return fmt.Errorf("failed to enqueue notification: %w", err)
+	}
+	return nil
+}
+
+// buildUnclaimedCodesNotification builds the unclaimed codes report sent to
+// API key callback URLs. It never includes verification codes.
+func buildUnclaimedCodesNotification(codes []*database.VerificationCode, now time.Time) *api.CallbackNotification {
+	notification := &api.CallbackNotification{
+		Event:       api.CallbackEventUnclaimedCodesReport,
+		CompletedAt: now.Unix(),
+		Codes:       make([]*api.CodeMetadata, 0, len(codes)),
+	}
+	for _, code := range codes {
+		notification.Codes = append(notification.Codes, &api.CodeMetadata{
+			UUID:                   code.UUID,
+			IssuedAtTimestamp:      code.CreatedAt.UTC().Unix(),
+			Claimed:                code.Claimed,
+			TestType:               code.TestType,
+			IssuingAppID:           code.IssuingAppID,
+			IssuingExternalID:      code.IssuingExternalID,
+			ExternalCaseID:         code.ExternalCaseID,
+			ExpiresAtTimestamp:     code.ExpiresAt.UTC().Unix(),
+			LongExpiresAtTimestamp: code.LongExpiresAt.UTC().Unix(),
+		})
+	}
+	return notification
+}
@@ -0,0 +1,247 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package emailer
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/exposure-notifications-server/pkg/logging"
+	"github.com/google/exposure-notifications-verification-server/assets"
+	"github.com/google/exposure-notifications-verification-server/internal/project"
+	"github.com/google/exposure-notifications-verification-server/pkg/api"
+	"github.com/google/exposure-notifications-verification-server/pkg/config"
+	"github.com/google/exposure-notifications-verification-server/pkg/database"
+	"github.com/google/exposure-notifications-verification-server/pkg/render"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestBuildUnclaimedCodesNotification(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2022, 11, 2, 0, 0, 0, 0, time.UTC)
+	issued := now.Add(-2 * time.Hour)
+
+	code := &database.VerificationCode{
+		UUID:           "5148c75c-2bc5-4874-9d1c-f9185d0e1b8a",
+		Code:           "12345678",
+		LongCode:       "abcdefgh12345678",
+		TestType:       "confirmed",
+		IssuingAppID:   4,
+		ExternalCaseID: "case-1",
+		ExpiresAt:      issued.Add(time.Hour),
+		LongExpiresAt:  issued.Add(24 * time.Hour),
+	}
+	code.CreatedAt = issued
+
+	notification := buildUnclaimedCodesNotification([]*database.VerificationCode{code}, now)
+
+	if got, want := notification.Event, api.CallbackEventUnclaimedCodesReport; got != want {
+		t.Errorf("expected event %q to be %q", got, want)
+	}
+	if got, want := notification.CompletedAt, now.Unix(); got != want {
+		t.Errorf("expected completed at %d to be %d", got, want)
+	}
+	if got, want := len(notification.Codes), 1; got != want {
+		t.Fatalf("expected %d codes to be %d", got, want)
+	}
+	if got, want := notification.Codes[0].IssuedAtTimestamp, issued.Unix(); got != want {
+		t.Errorf("expected issued at %d to be %d", got, want)
+	}
+
+	b, err := json.Marshal(notification)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, secret := range []string{"12345678", "abcdefgh12345678"} {
+		if strings.Contains(string(b), secret) {
+			t.Errorf("expected %s to not contain %q", b, secret)
+		}
+	}
+	for _, want := range []string{"5148c75c-2bc5-4874-9d1c-f9185d0e1b8a", `"externalCaseID":"case-1"`, `"issuingAppID":4`} {
+		if !strings.Contains(string(b), want) {
+			t.Errorf("expected %s to contain %q", b, want)
+		}
+	}
+}
+
+func TestEnqueueUnclaimedCodesReport(t *testing.T) {
+	t.Parallel()
+
+	ctx := project.TestContext(t)
+
+	h, err := render.New(ctx, assets.ServerFS(), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db, _ := testDatabaseInstance.NewDatabase(t, nil)
+
+	realm, err := db.FindRealm(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := New(&config.EmailerConfig{}, db, h)
+
+	codes := []*database.VerificationCode{
+		{UUID: "5148c75c-2bc5-4874-9d1c-f9185d0e1b8a", TestType: "confirmed"},
+	}
+
+	countDeliveries := func(tb testing.TB, appID uint) int {
+		tb.Helper()
+
+		var count int
+		if err := db.RawDB().
+			Model(&database.CallbackDelivery{}).
+			Where("authorized_app_id = ?", appID).
+			Count(&count).
+			Error; err != nil {
+			tb.Fatal(err)
+		}
+		return count
+	}
+
+	cases := []struct {
+		name  string
+		optIn bool
+		want  int
+	}{
+		{
+			name:  "opted_in",
+			optIn: true,
+			want:  1,
+		},
+		{
+			name:  "opted_out",
+			optIn: false,
+			want:  0,
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			app := &database.AuthorizedApp{
+				Name:                 "unclaimed " + tc.name,
+				APIKeyType:           database.APIKeyTypeAdmin,
+				CallbackURL:          "https://example.com/callback",
+				CallbackSecret:       "super-secret-value",
+				UnclaimedCodesReport: tc.optIn,
+			}
+			if _, err := realm.CreateAuthorizedApp(db, app, database.SystemTest); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := c.enqueueUnclaimedCodesReport(ctx, app.ID, codes); err != nil {
+				t.Fatal(err)
+			}
+
+			if got, want := countDeliveries(t, app.ID), tc.want; got != want {
+				t.Errorf("expected %d deliveries to be %d", got, want)
+			}
+		})
+	}
+}
+
+func TestSendUnclaimedCodesEmail(t *testing.T) {
+	t.Parallel()
+
+	ctx := project.TestContext(t)
+
+	h, err := render.New(ctx, assets.ServerFS(), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("not_member", func(t *testing.T) {
+		t.Parallel()
+
+		logCore, logObserver := observer.New(zap.DebugLevel)
+		ctx := logging.WithLogger(ctx, zap.New(logCore).Sugar())
+
+		db, _ := testDatabaseInstance.NewDatabase(t, nil)
+
+		user := &database.User{
+			Email:                "former@example.com",
+			Name:                 "Former",
+			UnclaimedCodesReport: true,
+		}
+		if err := db.SaveUser(user, database.SystemTest); err != nil {
+			t.Fatal(err)
+		}
+
+		c := New(&config.EmailerConfig{}, db, h)
+
+		if err := c.sendUnclaimedCodesEmail(ctx, 1, user.ID, nil); err != nil {
+			t.Fatal(err)
+		}
+
+		testExpectLog(t, logObserver, "user is no longer a member of the realm, skipping")
+	})
+
+	t.Run("renders", func(t *testing.T) {
+		t.Parallel()
+
+		db, _ := testDatabaseInstance.NewDatabase(t, nil)
+
+		realm, err := db.FindRealm(1)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		c := New(&config.EmailerConfig{}, db, h)
+
+		code := &database.VerificationCode{
+			UUID:     "5148c75c-2bc5-4874-9d1c-f9185d0e1b8a",
+			Code:     "12345678",
+			TestType: "confirmed",
+		}
+		code.CreatedAt = time.Date(2022, 11, 2, 15, 4, 0, 0, time.UTC)
+
+		msg, err := c.h.RenderEmail("email/unclaimed_codes", map[string]interface{}{
+			"FromAddress": "from@example.com",
+			"ToAddresses": []string{"issuer@example.com"},
+			"Realm":       realm,
+			"RootURL":     "http://example.com",
+			"Codes":       []*database.VerificationCode{code},
+			"Days":        3,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, want := range []string{
+			"From: from@example.com\n",
+			"To: issuer@example.com\n",
+			"last 3 days",
+			"http://example.com/codes/5148c75c-2bc5-4874-9d1c-f9185d0e1b8a",
+			"2022-11-02 15:04 UTC",
+		} {
+			if got := string(msg); !strings.Contains(got, want) {
+				t.Errorf("expected %q to contain %q", got, want)
+			}
+		}
+		if got := string(msg); strings.Contains(got, "12345678") {
+			t.Errorf("expected %q to not contain the code", got)
+		}
+	})
+}
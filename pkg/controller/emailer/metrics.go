@@ -27,6 +27,11 @@ const metricPrefix = observability.MetricRoot + "/emailer"
 var (
 	mAnomaliesSuccess = stats.Int64(metricPrefix+"/anomalies_success", "successful anomalies emails", stats.UnitDimensionless)
 	mSMSErrorsSuccess = stats.Int64(metricPrefix+"/sms_errors_success", "successful SMS errors emails", stats.UnitDimensionless)
+
+	mSMSFromNumberChangesSuccess = stats.Int64(metricPrefix+"/sms_from_number_changes_success", "successful SMS from number changes emails", stats.UnitDimensionless)
+	mInactiveAPIKeysSuccess      = stats.Int64(metricPrefix+"/inactive_api_keys_success", "successful inactive API keys emails", stats.UnitDimensionless)
+	mRealmNotificationsSuccess   = stats.Int64(metricPrefix+"/realm_notifications_success", "successful realm notification fan-outs", stats.UnitDimensionless)
+	mUnclaimedCodesSuccess       = stats.Int64(metricPrefix+"/unclaimed_codes_success", "successful unclaimed codes reports", stats.UnitDimensionless)
 )
 
 func init() {
@@ -45,5 +50,33 @@ func init() {
 			Measure:     mSMSErrorsSuccess,
 			Aggregation: view.Count(),
 		},
+		{
+			Name:        metricPrefix + "/sms_from_number_changes/success",
+			Description: "Number of SMS from number changes email successes",
+			TagKeys:     observability.CommonTagKeys(),
+			Measure:     mSMSFromNumberChangesSuccess,
+			Aggregation: view.Count(),
+		},
+		{
+			Name:        metricPrefix + "/inactive_api_keys/success",
+			Description: "Number of inactive API keys email successes",
+			TagKeys:     observability.CommonTagKeys(),
+			Measure:     mInactiveAPIKeysSuccess,
+			Aggregation: view.Count(),
+		},
+		{
+			Name:        metricPrefix + "/realm_notifications/success",
+			Description: "Number of realm notification fan-out successes",
+			TagKeys:     observability.CommonTagKeys(),
+			Measure:     mRealmNotificationsSuccess,
+			Aggregation: view.Count(),
+		},
+		{
+			Name:        metricPrefix + "/unclaimed_codes/success",
+			Description: "Number of unclaimed codes report successes",
+			TagKeys:     observability.CommonTagKeys(),
+			Measure:     mUnclaimedCodesSuccess,
+			Aggregation: view.Count(),
+		},
 	}...)
 }
@@ -0,0 +1,100 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package grpcapi
+
+import (
+	"context"
+
+	"github.com/google/exposure-notifications-verification-server/pkg/api"
+	"github.com/google/exposure-notifications-verification-server/pkg/api/devicepb"
+	"github.com/google/exposure-notifications-verification-server/pkg/controller"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Verify exchanges a verification code for a verification token. It is the
+// gRPC equivalent of POST /api/verify.
+func (s *Server) Verify(ctx context.Context, req *devicepb.VerifyCodeRequest) (*devicepb.VerifyCodeResponse, error) {
+	authApp := controller.AuthorizedAppFromContext(ctx)
+	if authApp == nil {
+		return nil, status.Error(codes.Unauthenticated, "missing authorized app")
+	}
+
+	result := s.verifyController.Verify(ctx, authApp, &api.VerifyCodeRequest{
+		VerificationCode: req.GetCode(),
+		AcceptTestTypes:  req.GetAccept(),
+		Nonce:            req.GetNonce(),
+	})
+	if result.ErrorReturn != nil {
+		return nil, errorStatus(result.HTTPCode, result.ErrorReturn)
+	}
+
+	return &devicepb.VerifyCodeResponse{
+		TestType:    result.Response.TestType,
+		SymptomDate: result.Response.SymptomDate,
+		TestDate:    result.Response.TestDate,
+		Token:       result.Response.VerificationToken,
+	}, nil
+}
+
+// Certificate exchanges a verification token and exposure key HMAC for a
+// verification certificate. It is the gRPC equivalent of POST
+// /api/certificate.
+func (s *Server) Certificate(ctx context.Context, req *devicepb.VerificationCertificateRequest) (*devicepb.VerificationCertificateResponse, error) {
+	authApp := controller.AuthorizedAppFromContext(ctx)
+	if authApp == nil {
+		return nil, status.Error(codes.Unauthenticated, "missing authorized app")
+	}
+
+	result := s.certController.Certificate(ctx, authApp, &api.VerificationCertificateRequest{
+		VerificationToken: req.GetToken(),
+		ExposureKeyHMAC:   req.GetEkeyHmac(),
+	})
+	if result.ErrorReturn != nil {
+		return nil, errorStatus(result.HTTPCode, result.ErrorReturn)
+	}
+
+	return &devicepb.VerificationCertificateResponse{
+		Certificate: result.Response.Certificate,
+	}, nil
+}
+
+// UserReport issues a user report verification code to the phone number. It is
+// the gRPC equivalent of POST /api/user-report.
+func (s *Server) UserReport(ctx context.Context, req *devicepb.UserReportRequest) (*devicepb.UserReportResponse, error) {
+	authApp := controller.AuthorizedAppFromContext(ctx)
+	if authApp == nil || controller.RealmFromContext(ctx) == nil {
+		return nil, status.Error(codes.Unauthenticated, "missing authorized app")
+	}
+
+	result := s.issueController.UserReport(ctx, s.clientIP(ctx), authApp, &api.UserReportRequest{
+		SymptomDate:    req.GetSymptomDate(),
+		TestDate:       req.GetTestDate(),
+		TZOffset:       req.GetTzOffset(),
+		Phone:          req.GetPhone(),
+		Nonce:          req.GetNonce(),
+		ConsentVersion: uint(req.GetConsentVersion()),
+		ConsentLocale:  req.GetConsentLocale(),
+	})
+	if result.ErrorReturn != nil {
+		return nil, errorStatus(result.HTTPCode, result.ErrorReturn)
+	}
+
+	return &devicepb.UserReportResponse{
+		ExpiresAt:          result.Response.ExpiresAt,
+		ExpiresAtTimestamp: result.Response.ExpiresAtTimestamp,
+	}, nil
+}
@@ -0,0 +1,58 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package grpcapi implements the gRPC variant of the device-facing
+// verification API. Each method shares its implementation with the equivalent
+// JSON endpoint served by the apiserver.
+package grpcapi
+
+import (
+	"github.com/google/exposure-notifications-verification-server/pkg/api/devicepb"
+	"github.com/google/exposure-notifications-verification-server/pkg/controller/certapi"
+	"github.com/google/exposure-notifications-verification-server/pkg/controller/issueapi"
+	"github.com/google/exposure-notifications-verification-server/pkg/controller/verifyapi"
+)
+
+// Full method names of the DeviceVerification service, used to select the
+// middleware for each method.
+const (
+	MethodVerify      = "/verification.device.v1.DeviceVerification/Verify"
+	MethodCertificate = "/verification.device.v1.DeviceVerification/Certificate"
+	MethodUserReport  = "/verification.device.v1.DeviceVerification/UserReport"
+)
+
+// Server implements the DeviceVerification gRPC service.
+type Server struct {
+	devicepb.UnimplementedDeviceVerificationServer
+
+	verifyController *verifyapi.Controller
+	certController   *certapi.Controller
+	issueController  *issueapi.Controller
+
+	// trustedProxy indicates that calls arrive through a proxy that appends the
+	// client address to X-Forwarded-For, so the metadata can be trusted.
+	trustedProxy bool
+}
+
+// New creates a new gRPC device API server backed by the given controllers. If
+// trustedProxy is true, the client address is taken from the X-Forwarded-For
+// metadata set by the proxy, otherwise it is the gRPC peer address.
+func New(verifyController *verifyapi.Controller, certController *certapi.Controller, issueController *issueapi.Controller, trustedProxy bool) *Server {
+	return &Server{
+		verifyController: verifyController,
+		certController:   certController,
+		issueController:  issueController,
+		trustedProxy:     trustedProxy,
+	}
+}
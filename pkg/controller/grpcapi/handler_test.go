@@ -0,0 +1,135 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package grpcapi
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/exposure-notifications-verification-server/internal/project"
+	"github.com/google/exposure-notifications-verification-server/pkg/api/devicepb"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+)
+
+func TestHandler(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name        string
+		protoMajor  int
+		contentType string
+		grpc        bool
+	}{
+		{
+			name:        "json",
+			protoMajor:  1,
+			contentType: "application/json",
+		},
+		{
+			name:        "http2_json",
+			protoMajor:  2,
+			contentType: "application/json",
+		},
+		{
+			name:        "grpc",
+			protoMajor:  2,
+			contentType: "application/grpc",
+			grpc:        true,
+		},
+		{
+			name:        "grpc_proto",
+			protoMajor:  2,
+			contentType: "application/grpc+proto",
+			grpc:        true,
+		},
+		{
+			name:        "grpc_http1",
+			protoMajor:  1,
+			contentType: "application/grpc",
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			var gotGRPC, gotNext bool
+			grpcServer := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				gotGRPC = true
+			})
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				gotNext = true
+			})
+
+			r := httptest.NewRequest(http.MethodPost, MethodVerify, nil)
+			r.ProtoMajor = tc.protoMajor
+			r.Header.Set("Content-Type", tc.contentType)
+			Handler(grpcServer, next).ServeHTTP(httptest.NewRecorder(), r)
+
+			if got, want := gotGRPC, tc.grpc; got != want {
+				t.Errorf("expected grpc to be %t", want)
+			}
+			if got, want := gotNext, !tc.grpc; got != want {
+				t.Errorf("expected next to be %t", want)
+			}
+		})
+	}
+}
+
+func TestHandler_h2c(t *testing.T) {
+	t.Parallel()
+
+	ctx := project.TestContext(t)
+
+	grpcServer := grpc.NewServer()
+	devicepb.RegisterDeviceVerificationServer(grpcServer, &Server{})
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	})
+
+	srv := httptest.NewServer(Handler(grpcServer, next))
+	t.Cleanup(srv.Close)
+
+	// JSON requests over HTTP/1.1 are served by next.
+	resp, err := srv.Client().Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if got, want := resp.StatusCode, http.StatusTeapot; got != want {
+		t.Errorf("expected %d to be %d", got, want)
+	}
+
+	// gRPC calls without TLS are served by the gRPC server.
+	conn, err := grpc.DialContext(ctx, strings.TrimPrefix(srv.URL, "http://"),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	_, err = devicepb.NewDeviceVerificationClient(conn).Verify(ctx, &devicepb.VerifyCodeRequest{})
+	if got, want := status.Code(err), codes.Unauthenticated; got != want {
+		t.Errorf("expected %s to be %s: %s", got, want, err)
+	}
+}
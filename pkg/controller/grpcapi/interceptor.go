@@ -0,0 +1,223 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package grpcapi
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/google/exposure-notifications-verification-server/pkg/api/devicepb"
+	"github.com/google/exposure-notifications-verification-server/pkg/ratelimit/limitware"
+	"github.com/google/exposure-notifications-verification-server/pkg/realip"
+	"github.com/gorilla/mux"
+	"github.com/sethvargo/go-limiter/httplimit"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// forwardedHeaders are the response headers set by the HTTP middleware that
+// are returned to gRPC clients as header metadata.
+var forwardedHeaders = []string{
+	httplimit.HeaderRateLimitLimit,
+	httplimit.HeaderRateLimitRemaining,
+	httplimit.HeaderRateLimitReset,
+	httplimit.HeaderRetryAfter,
+	limitware.HeaderRateLimitLimit,
+	limitware.HeaderRateLimitRemaining,
+	limitware.HeaderRateLimitReset,
+}
+
+// headerKeyXForwardedFor is the header a proxy uses to pass on the client
+// address.
+const headerKeyXForwardedFor = "X-Forwarded-For"
+
+type contextKey string
+
+const contextKeyRequest = contextKey("request")
+
+// withRequest stores the HTTP request that was built for the gRPC call on the
+// context.
+func withRequest(ctx context.Context, r *http.Request) context.Context {
+	return context.WithValue(ctx, contextKeyRequest, r)
+}
+
+// requestFromContext returns the HTTP request that was built for the gRPC call,
+// or nil if there is none.
+func requestFromContext(ctx context.Context) *http.Request {
+	r, ok := ctx.Value(contextKeyRequest).(*http.Request)
+	if !ok {
+		return nil
+	}
+	return r
+}
+
+// HTTPMiddleware returns a unary server interceptor that runs the HTTP
+// middleware for each method before invoking the gRPC handler. This lets the
+// gRPC API share the JSON API's authentication, firewall, app version, chaff,
+// and rate limiting middleware. The middleware sees a request built from the
+// call's metadata and peer address, and the context it passes on is given to
+// the gRPC handler. If the middleware rejects the request, its response is
+// converted to a gRPC status. If the middleware answers a chaff request, the
+// call returns an empty response.
+//
+// Calls to methods without an entry in chains are rejected.
+func (s *Server) HTTPMiddleware(chains map[string][]mux.MiddlewareFunc) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		chain, ok := chains[info.FullMethod]
+		if !ok {
+			return nil, status.Errorf(codes.Unimplemented, "method %s is not implemented", info.FullMethod)
+		}
+
+		r, err := newRequest(ctx, info.FullMethod, s.trustedProxy)
+		if err != nil {
+			return nil, status.Errorf(codes.Internal, "failed to build request: %s", err)
+		}
+
+		var (
+			called  bool
+			resp    interface{}
+			respErr error
+		)
+		var next http.Handler = http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+			called = true
+			resp, respErr = handler(withRequest(r.Context(), r), req)
+		})
+		for i := len(chain) - 1; i >= 0; i-- {
+			next = chain[i](next)
+		}
+
+		w := &responseRecorder{header: make(http.Header), code: http.StatusOK}
+		next.ServeHTTP(w, r)
+
+		md := metadata.MD{}
+		for _, k := range forwardedHeaders {
+			if v := w.header.Get(k); v != "" {
+				md.Set(k, v)
+			}
+		}
+		if md.Len() > 0 {
+			if err := grpc.SetHeader(ctx, md); err != nil {
+				return nil, status.Errorf(codes.Internal, "failed to set header: %s", err)
+			}
+		}
+
+		if !called {
+			if w.code == http.StatusOK {
+				if resp, ok := chaffResponse(info.FullMethod); ok {
+					return resp, nil
+				}
+			}
+			return nil, responseStatus(w.code, w.body.Bytes())
+		}
+		return resp, respErr
+	}
+}
+
+// newRequest builds an HTTP request for the gRPC call. The headers are the
+// call's metadata and the remote address is the peer address. Unless the call
+// came through a trusted proxy, X-Forwarded-For metadata is dropped so the
+// middleware cannot be given a client address chosen by the caller. Requests
+// are marked as JSON so that rejections from the middleware render as an API
+// error.
+func newRequest(ctx context.Context, fullMethod string, trustedProxy bool) (*http.Request, error) {
+	r, err := http.NewRequestWithContext(ctx, http.MethodPost, fullMethod, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	md, _ := metadata.FromIncomingContext(ctx)
+	for k, vs := range md {
+		// Skip pseudo-headers like :authority.
+		if strings.HasPrefix(k, ":") {
+			continue
+		}
+		if !trustedProxy && strings.EqualFold(k, headerKeyXForwardedFor) {
+			continue
+		}
+		for _, v := range vs {
+			r.Header.Add(k, v)
+		}
+	}
+	r.Header.Set("Accept", "application/json")
+	r.Header.Set("Content-Type", "application/json")
+
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		r.RemoteAddr = p.Addr.String()
+	}
+	return r, nil
+}
+
+// clientIP returns the IP address of the client that made the call. Behind a
+// trusted proxy, this is the address the proxy added to X-Forwarded-For.
+// Otherwise it is the gRPC peer address, since any forwarding metadata was set
+// by the caller.
+func (s *Server) clientIP(ctx context.Context) string {
+	if s.trustedProxy {
+		return realip.FromGoogleCloud(requestFromContext(ctx))
+	}
+
+	p, ok := peer.FromContext(ctx)
+	if !ok || p.Addr == nil {
+		return ""
+	}
+	host, _, err := net.SplitHostPort(p.Addr.String())
+	if err != nil {
+		return p.Addr.String()
+	}
+	return host
+}
+
+// chaffResponse returns the response for a chaff call to the method. Chaff
+// calls are answered by the chaff middleware after a delay that matches real
+// calls, and return an empty response.
+func chaffResponse(fullMethod string) (interface{}, bool) {
+	switch fullMethod {
+	case MethodVerify:
+		return &devicepb.VerifyCodeResponse{}, true
+	case MethodCertificate:
+		return &devicepb.VerificationCertificateResponse{}, true
+	case MethodUserReport:
+		return &devicepb.UserReportResponse{}, true
+	default:
+		return nil, false
+	}
+}
+
+// responseRecorder is an http.ResponseWriter that records the response written
+// by middleware that rejected the request.
+type responseRecorder struct {
+	header http.Header
+	code   int
+	body   bytes.Buffer
+}
+
+func (w *responseRecorder) Header() http.Header {
+	return w.header
+}
+
+func (w *responseRecorder) Write(b []byte) (int, error) {
+	return w.body.Write(b)
+}
+
+func (w *responseRecorder) WriteHeader(code int) {
+	w.code = code
+}
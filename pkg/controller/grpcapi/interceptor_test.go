@@ -0,0 +1,226 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package grpcapi
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"testing"
+
+	"github.com/google/exposure-notifications-verification-server/internal/project"
+	"github.com/google/exposure-notifications-verification-server/pkg/api/devicepb"
+	"github.com/google/exposure-notifications-verification-server/pkg/realip"
+
+	"github.com/gorilla/mux"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+func TestHTTPMiddleware(t *testing.T) {
+	t.Parallel()
+
+	type ctxKey string
+
+	// requireKey rejects requests without an API key, and otherwise stores the
+	// key on the context.
+	requireKey := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := r.Header.Get("X-API-Key")
+			if key == "" {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusUnauthorized)
+				_, _ = w.Write([]byte(`{"error":"unauthorized","errorCode":"unauthorized"}`))
+				return
+			}
+			ctx := context.WithValue(r.Context(), ctxKey("key"), key)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+
+	// answerChaff responds to the request the way the chaff middleware does,
+	// without calling the handler.
+	answerChaff := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusOK)
+			_, _ = w.Write([]byte(`{"padding":"abc"}`))
+		})
+	}
+
+	chains := map[string][]mux.MiddlewareFunc{
+		MethodVerify:      {requireKey},
+		MethodCertificate: {requireKey, answerChaff},
+	}
+	interceptor := (&Server{}).HTTPMiddleware(chains)
+	trustedInterceptor := (&Server{trustedProxy: true}).HTTPMiddleware(chains)
+
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		key, _ := ctx.Value(ctxKey("key")).(string)
+		return key + ":" + realip.FromGoogleCloud(requestFromContext(ctx)), nil
+	}
+
+	incoming := func(t *testing.T, md metadata.MD) context.Context {
+		t.Helper()
+
+		ctx := project.TestContext(t)
+		ctx = peer.NewContext(ctx, &peer.Peer{
+			Addr: &net.TCPAddr{IP: net.ParseIP("192.0.2.1"), Port: 1234},
+		})
+		return metadata.NewIncomingContext(ctx, md)
+	}
+
+	t.Run("allowed", func(t *testing.T) {
+		t.Parallel()
+
+		ctx := incoming(t, metadata.Pairs("x-api-key", "abc"))
+		resp, err := interceptor(ctx, nil, &grpc.UnaryServerInfo{FullMethod: MethodVerify}, handler)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := resp, "abc:192.0.2.1:1234"; got != want {
+			t.Errorf("expected %q to be %q", got, want)
+		}
+	})
+
+	t.Run("forwarded_untrusted", func(t *testing.T) {
+		t.Parallel()
+
+		ctx := incoming(t, metadata.Pairs(
+			"x-api-key", "abc",
+			"x-forwarded-for", "203.0.113.9,198.51.100.1"))
+		resp, err := interceptor(ctx, nil, &grpc.UnaryServerInfo{FullMethod: MethodVerify}, handler)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := resp, "abc:192.0.2.1:1234"; got != want {
+			t.Errorf("expected %q to be %q", got, want)
+		}
+	})
+
+	t.Run("forwarded_trusted", func(t *testing.T) {
+		t.Parallel()
+
+		ctx := incoming(t, metadata.Pairs(
+			"x-api-key", "abc",
+			"x-forwarded-for", "203.0.113.9,198.51.100.1"))
+		resp, err := trustedInterceptor(ctx, nil, &grpc.UnaryServerInfo{FullMethod: MethodVerify}, handler)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := resp, "abc:203.0.113.9"; got != want {
+			t.Errorf("expected %q to be %q", got, want)
+		}
+	})
+
+	t.Run("chaff", func(t *testing.T) {
+		t.Parallel()
+
+		ctx := incoming(t, metadata.Pairs("x-api-key", "abc"))
+		resp, err := interceptor(ctx, nil, &grpc.UnaryServerInfo{FullMethod: MethodCertificate}, handler)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, ok := resp.(*devicepb.VerificationCertificateResponse); !ok {
+			t.Errorf("expected %T to be *devicepb.VerificationCertificateResponse", resp)
+		}
+	})
+
+	t.Run("rejected", func(t *testing.T) {
+		t.Parallel()
+
+		ctx := incoming(t, metadata.MD{})
+		_, err := interceptor(ctx, nil, &grpc.UnaryServerInfo{FullMethod: MethodVerify}, handler)
+		if got, want := status.Code(err), codes.Unauthenticated; got != want {
+			t.Errorf("expected %s to be %s", got, want)
+		}
+	})
+
+	t.Run("unknown_method", func(t *testing.T) {
+		t.Parallel()
+
+		ctx := incoming(t, metadata.Pairs("x-api-key", "abc"))
+		_, err := interceptor(ctx, nil, &grpc.UnaryServerInfo{FullMethod: MethodUserReport}, handler)
+		if got, want := status.Code(err), codes.Unimplemented; got != want {
+			t.Errorf("expected %s to be %s", got, want)
+		}
+	})
+}
+
+func TestServer_clientIP(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name         string
+		trustedProxy bool
+		peer         net.Addr
+		xff          string
+		exp          string
+	}{
+		{
+			name: "no_peer",
+			exp:  "",
+		},
+		{
+			name: "peer",
+			peer: &net.TCPAddr{IP: net.ParseIP("192.0.2.1"), Port: 1234},
+			exp:  "192.0.2.1",
+		},
+		{
+			name: "peer_ignores_forwarded",
+			peer: &net.TCPAddr{IP: net.ParseIP("192.0.2.1"), Port: 1234},
+			xff:  "203.0.113.9,198.51.100.1",
+			exp:  "192.0.2.1",
+		},
+		{
+			name:         "trusted_proxy",
+			trustedProxy: true,
+			peer:         &net.TCPAddr{IP: net.ParseIP("192.0.2.1"), Port: 1234},
+			xff:          "203.0.113.9,198.51.100.1",
+			exp:          "203.0.113.9",
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			ctx := project.TestContext(t)
+			if tc.peer != nil {
+				ctx = peer.NewContext(ctx, &peer.Peer{Addr: tc.peer})
+			}
+			if tc.xff != "" {
+				ctx = metadata.NewIncomingContext(ctx, metadata.Pairs("x-forwarded-for", tc.xff))
+			}
+
+			r, err := newRequest(ctx, MethodUserReport, tc.trustedProxy)
+			if err != nil {
+				t.Fatal(err)
+			}
+			ctx = withRequest(ctx, r)
+
+			s := &Server{trustedProxy: tc.trustedProxy}
+			if got, want := s.clientIP(ctx), tc.exp; got != want {
+				t.Errorf("expected %q to be %q", got, want)
+			}
+		})
+	}
+}
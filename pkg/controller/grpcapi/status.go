@@ -0,0 +1,92 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package grpcapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/google/exposure-notifications-verification-server/pkg/api"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// errorInfoDomain is the domain of the ErrorInfo detail attached to errors.
+const errorInfoDomain = "verification.device.v1"
+
+// errorStatus converts an API error and its HTTP status code into a gRPC
+// status error. The API error code is attached as the reason of an ErrorInfo
+// detail so clients can handle the same error codes as the JSON API.
+func errorStatus(httpCode int, errRet *api.ErrorReturn) error {
+	st := status.New(grpcCode(httpCode), errRet.Error)
+	if errRet.ErrorCode == "" {
+		return st.Err()
+	}
+
+	withDetails, err := st.WithDetails(&errdetails.ErrorInfo{
+		Reason: errRet.ErrorCode,
+		Domain: errorInfoDomain,
+	})
+	if err != nil {
+		return st.Err()
+	}
+	return withDetails.Err()
+}
+
+// responseStatus converts a response rendered by HTTP middleware into a gRPC
+// status error. The body is usually a JSON API error, but some middleware
+// responds with plain text.
+func responseStatus(httpCode int, body []byte) error {
+	var errRet api.ErrorReturn
+	if err := json.Unmarshal(body, &errRet); err != nil || errRet.Error == "" {
+		errRet = api.ErrorReturn{Error: strings.TrimSpace(string(body))}
+	}
+	if errRet.Error == "" {
+		errRet.Error = http.StatusText(httpCode)
+	}
+	return errorStatus(httpCode, &errRet)
+}
+
+// grpcCode returns the gRPC code that corresponds to the HTTP status code used
+// by the JSON API.
+func grpcCode(httpCode int) codes.Code {
+	switch httpCode {
+	case http.StatusOK:
+		return codes.OK
+	case http.StatusBadRequest, http.StatusRequestEntityTooLarge:
+		return codes.InvalidArgument
+	case http.StatusUnauthorized:
+		return codes.Unauthenticated
+	case http.StatusForbidden:
+		return codes.PermissionDenied
+	case http.StatusNotFound:
+		return codes.NotFound
+	case http.StatusConflict:
+		return codes.AlreadyExists
+	case http.StatusPreconditionFailed, http.StatusUpgradeRequired:
+		return codes.FailedPrecondition
+	case http.StatusTooManyRequests:
+		return codes.ResourceExhausted
+	case http.StatusInternalServerError:
+		return codes.Internal
+	case http.StatusServiceUnavailable:
+		return codes.Unavailable
+	default:
+		return codes.Unknown
+	}
+}
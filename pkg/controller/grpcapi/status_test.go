@@ -0,0 +1,140 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package grpcapi
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/google/exposure-notifications-verification-server/pkg/api"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestErrorStatus(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name     string
+		httpCode int
+		errRet   *api.ErrorReturn
+		code     codes.Code
+		reason   string
+	}{
+		{
+			name:     "with_error_code",
+			httpCode: http.StatusBadRequest,
+			errRet:   api.Errorf("verification code expired").WithCode(api.ErrVerifyCodeExpired),
+			code:     codes.InvalidArgument,
+			reason:   api.ErrVerifyCodeExpired,
+		},
+		{
+			name:     "without_error_code",
+			httpCode: http.StatusInternalServerError,
+			errRet:   &api.ErrorReturn{Error: "internal error"},
+			code:     codes.Internal,
+		},
+		{
+			name:     "rate_limited",
+			httpCode: http.StatusTooManyRequests,
+			errRet:   api.Errorf("server is read-only for maintenance").WithCode(api.ErrMaintenanceMode),
+			code:     codes.ResourceExhausted,
+			reason:   api.ErrMaintenanceMode,
+		},
+		{
+			name:     "unknown",
+			httpCode: http.StatusTeapot,
+			errRet:   &api.ErrorReturn{Error: "teapot"},
+			code:     codes.Unknown,
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			st := status.Convert(errorStatus(tc.httpCode, tc.errRet))
+			if got, want := st.Code(), tc.code; got != want {
+				t.Errorf("expected %s to be %s", got, want)
+			}
+			if got, want := st.Message(), tc.errRet.Error; got != want {
+				t.Errorf("expected %q to be %q", got, want)
+			}
+
+			var reason string
+			for _, d := range st.Details() {
+				if info, ok := d.(*errdetails.ErrorInfo); ok {
+					reason = info.GetReason()
+				}
+			}
+			if got, want := reason, tc.reason; got != want {
+				t.Errorf("expected %q to be %q", got, want)
+			}
+		})
+	}
+}
+
+func TestResponseStatus(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name     string
+		httpCode int
+		body     string
+		code     codes.Code
+		message  string
+	}{
+		{
+			name:     "json",
+			httpCode: http.StatusUnauthorized,
+			body:     `{"error":"invalid API key","errorCode":"unauthorized"}`,
+			code:     codes.Unauthenticated,
+			message:  "invalid API key",
+		},
+		{
+			name:     "plain_text",
+			httpCode: http.StatusTooManyRequests,
+			body:     "Too Many Requests\n",
+			code:     codes.ResourceExhausted,
+			message:  "Too Many Requests",
+		},
+		{
+			name:     "empty",
+			httpCode: http.StatusUpgradeRequired,
+			code:     codes.FailedPrecondition,
+			message:  http.StatusText(http.StatusUpgradeRequired),
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			st := status.Convert(responseStatus(tc.httpCode, []byte(tc.body)))
+			if got, want := st.Code(), tc.code; got != want {
+				t.Errorf("expected %s to be %s", got, want)
+			}
+			if got, want := st.Message(), tc.message; got != want {
+				t.Errorf("expected %q to be %q", got, want)
+			}
+		})
+	}
+}
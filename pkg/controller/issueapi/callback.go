@@ -0,0 +1,67 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package issueapi
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/google/exposure-notifications-server/pkg/logging"
+	"github.com/google/exposure-notifications-verification-server/pkg/api"
+	"github.com/google/exposure-notifications-verification-server/pkg/database"
+)
+
+// enqueueBatchCompleted queues a completion notification for the batch to the
+// authorized app's callback URL, if one is configured. The notification is
+// delivered by the callbacks worker. Failures are logged, but never returned
+// to the caller.
+func (c *Controller) enqueueBatchCompleted(ctx context.Context, authApp *database.AuthorizedApp, resp *api.BatchIssueCodeResponse) {
+	if authApp == nil || authApp.CallbackURL == "" {
+		return
+	}
+
+	logger := logging.FromContext(ctx).Named("issueapi.enqueueBatchCompleted").
+		With("authorized_app", authApp.ID)
+
+	b, err := json.Marshal(buildBatchCompletedNotification(resp, time.Now().UTC()))
+	if err != nil {
+		logger.Errorw("failed to marshal callback notification", "error", err)
+		return
+	}
+
+	if _, err := c.db.EnqueueCallbackDelivery(authApp.ID, b); err != nil {
+		logger.Errorw("failed to enqueue callback notification", "error", err)
+	}
+}
+
+// buildBatchCompletedNotification summarizes the batch response. It never
+// includes verification codes.
+func buildBatchCompletedNotification(resp *api.BatchIssueCodeResponse, now time.Time) *api.CallbackNotification {
+	notification := &api.CallbackNotification{
+		Event:       api.CallbackEventBatchIssueCompleted,
+		CompletedAt: now.Unix(),
+		ErrorCode:   resp.ErrorCode,
+	}
+	for _, code := range resp.Codes {
+		if code.Error != "" {
+			notification.Failed++
+			continue
+		}
+		notification.Succeeded++
+		notification.UUIDs = append(notification.UUIDs, code.UUID)
+	}
+	return notification
+}
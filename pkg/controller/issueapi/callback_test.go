@@ -0,0 +1,66 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package issueapi
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/exposure-notifications-verification-server/pkg/api"
+)
+
+func TestBuildBatchCompletedNotification(t *testing.T) {
+	t.Parallel()
+
+	now := time.Unix(1667347200, 0)
+
+	notification := buildBatchCompletedNotification(&api.BatchIssueCodeResponse{
+		Codes: []*api.IssueCodeResponse{
+			{UUID: "a", VerificationCode: "12345678"},
+			{UUID: "b", VerificationCode: "87654321"},
+			{Error: "bad phone", ErrorCode: api.ErrSMSFailure},
+		},
+		ErrorCode: api.ErrSMSFailure,
+	}, now)
+
+	if got, want := notification.Event, api.CallbackEventBatchIssueCompleted; got != want {
+		t.Errorf("expected %q to be %q", got, want)
+	}
+	if got, want := notification.CompletedAt, now.Unix(); got != want {
+		t.Errorf("expected %d to be %d", got, want)
+	}
+	if got, want := notification.Succeeded, 2; got != want {
+		t.Errorf("expected %d to be %d", got, want)
+	}
+	if got, want := notification.Failed, 1; got != want {
+		t.Errorf("expected %d to be %d", got, want)
+	}
+	if got, want := len(notification.UUIDs), 2; got != want {
+		t.Errorf("expected %d to be %d", got, want)
+	}
+
+	// Codes must never be sent to the callback.
+	b, err := json.Marshal(notification)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, code := range []string{"12345678", "87654321"} {
+		if strings.Contains(string(b), code) {
+			t.Errorf("expected %s to not contain code %s", b, code)
+		}
+	}
+}
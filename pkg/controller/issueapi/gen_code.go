@@ -25,6 +25,7 @@ import (
 	"time"
 
 	enobs "github.com/google/exposure-notifications-server/pkg/observability"
+	"github.com/google/exposure-notifications-verification-server/internal/project"
 	"github.com/google/exposure-notifications-verification-server/pkg/api"
 	"github.com/google/exposure-notifications-verification-server/pkg/database"
 	"github.com/sethvargo/go-retry"
@@ -41,6 +42,12 @@ const (
 func (c *Controller) IssueCode(ctx context.Context, vCode *database.VerificationCode, realm *database.Realm) *IssueResult {
 	logger := logging.FromContext(ctx).Named("issueapi.IssueCode")
 
+	// Per-issuer limits are checked first, so a limited user or API key does
+	// not consume the realm's quota.
+	if result := c.CheckIssuerQuotas(ctx, realm, vCode); result != nil {
+		return result
+	}
+
 	// If we got this far, we're about to issue a code - take from the limiter
 	// to ensure this is permitted.
 	if realm.AbusePreventionEnabled {
@@ -65,6 +72,7 @@ func (c *Controller) IssueCode(ctx context.Context, vCode *database.Verification
 				"realm", realm.ID,
 				"limit", limit,
 				"reset", reset)
+			c.postQuotaNotification(ctx, realm, limit)
 
 			if c.config.IssueConfig().EnforceRealmQuotas {
 				return &IssueResult{
@@ -116,7 +124,7 @@ func (c *Controller) CommitCode(ctx context.Context, vCode *database.Verificatio
 	b := retry.NewConstant(50 * time.Millisecond)
 
 	if err := retry.Do(ctx, retry.WithMaxRetries(uint64(retryCount), b), func(ctx context.Context) error {
-		code, err := GenerateCode(realm.CodeLength)
+		code, err := GenerateRealmCode(realm)
 		if err != nil {
 			return err
 		}
@@ -135,11 +143,12 @@ func (c *Controller) CommitCode(ctx context.Context, vCode *database.Verificatio
 		switch {
 		case err == nil:
 			// These are stored encrypted, but here we need to tell the user about them.
-			vCode.Code = code
+			vCode.Code = realm.FormatCode(code)
 			vCode.LongCode = longCode
 			return nil // success
 		case strings.Contains(err.Error(), database.VerCodesCodeUniqueIndex),
 			strings.Contains(err.Error(), database.VerCodesLongCodeUniqueIndex):
+			c.recordCodeCollision(ctx, realm)
 			return retry.RetryableError(err)
 		default:
 			return err // err not retryable
@@ -151,6 +160,27 @@ func (c *Controller) CommitCode(ctx context.Context, vCode *database.Verificatio
 	return nil
 }
 
+// recordCodeCollision records that a generated code collided with an existing
+// code. A high collision rate means the realm's code space is too small for its
+// issuance volume.
+func (c *Controller) recordCodeCollision(ctx context.Context, realm *database.Realm) {
+	stats.Record(ctx, mCodeCollision.M(1))
+
+	if err := c.db.RecordCodeCollision(realm.ID); err != nil {
+		logger := logging.FromContext(ctx).Named("issueapi.recordCodeCollision")
+		logger.Warnw("failed to record code collision", "realm_id", realm.ID, "error", err)
+	}
+}
+
+// GenerateRealmCode creates a new short code using the realm's code charset.
+// The code is unformatted; use Realm.FormatCode to display it.
+func GenerateRealmCode(realm *database.Realm) (string, error) {
+	if realm.CodeCharset == database.CodeCharsetAlphanumeric {
+		return generateFromAlphabet(database.CodeAlphabetAlphanumeric, realm.CodeLength)
+	}
+	return GenerateCode(realm.CodeLength)
+}
+
 // GenerateCode creates a new OTP code.
 func GenerateCode(length uint) (string, error) {
 	limit := big.NewInt(0)
@@ -172,9 +202,15 @@ func GenerateCode(length uint) (string, error) {
 // base64 encode to that length string.
 // For example 16 character string requires 12 bytes.
 func GenerateAlphanumericCode(length uint) (string, error) {
+	return generateFromAlphabet(charset, length)
+}
+
+// generateFromAlphabet generates a random string of the given length from the
+// characters in alphabet.
+func generateFromAlphabet(alphabet string, length uint) (string, error) {
 	var result string
 	for i := uint(0); i < length; i++ {
-		ch, err := randomFromCharset()
+		ch, err := randomFromAlphabet(alphabet)
 		if err != nil {
 			return "", err
 		}
@@ -183,10 +219,38 @@ func GenerateAlphanumericCode(length uint) (string, error) {
 	return result, nil
 }
 
-func randomFromCharset() (string, error) {
-	n, err := rand.Int(rand.Reader, big.NewInt(int64(len(charset))))
+func randomFromAlphabet(alphabet string) (string, error) {
+	n, err := rand.Int(rand.Reader, big.NewInt(int64(len(alphabet))))
 	if err != nil {
 		return "", err
 	}
-	return string(charset[n.Int64()]), nil
+	return string(alphabet[n.Int64()]), nil
+}
+
+// postQuotaNotification tells the realm's admins that the realm exceeded the
+// daily quota configured by abuse prevention. It is posted at most once per UTC
+// day, and failures are only logged.
+func (c *Controller) postQuotaNotification(ctx context.Context, realm *database.Realm, limit uint64) {
+	logger := logging.FromContext(ctx).Named("issueapi.postQuotaNotification")
+
+	body := fmt.Sprintf("This realm issued its daily quota of %d codes configured by abuse prevention.", limit)
+	if c.config.IssueConfig().EnforceRealmQuotas {
+		body += " Additional codes are rejected until the quota resets."
+	} else {
+		body += " The quota is not enforced on this server, so codes are still being issued."
+	}
+	body += " If this is expected, for example because of a surge in cases, a system admin can raise the quota."
+
+	if _, err := c.db.PostRealmNotification(&database.RealmNotification{
+		RealmID:   realm.ID,
+		Kind:      database.RealmNotificationKindAbusePrevention,
+		DedupeKey: time.Now().UTC().Format(project.RFC3339Date),
+		Title:     "Daily issuance quota exceeded",
+		Body:      body,
+		Link:      "/realm/stats",
+		SendEmail: true,
+		SendSMS:   true,
+	}); err != nil {
+		logger.Errorw("failed to post quota notification", "realm", realm.ID, "error", err)
+	}
 }
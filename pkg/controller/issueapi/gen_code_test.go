@@ -16,6 +16,7 @@ package issueapi_test
 
 import (
 	"net/http"
+	"strings"
 	"testing"
 	"time"
 
@@ -71,6 +72,31 @@ func TestGenerateAlphanumericCode(t *testing.T) {
 	}
 }
 
+func TestGenerateRealmCode(t *testing.T) {
+	t.Parallel()
+
+	realm := database.NewRealmWithDefaults("test")
+	realm.CodeCharset = database.CodeCharsetAlphanumeric
+
+	// Run through a whole bunch of iterations.
+	for j := 0; j < 1000; j++ {
+		code, err := issueapi.GenerateRealmCode(realm)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if got, want := len(code), int(realm.CodeLength); got != want {
+			t.Fatalf("code is wrong length want %d, got %v", want, got)
+		}
+
+		for i, c := range code {
+			if !strings.ContainsRune(database.CodeAlphabetAlphanumeric, c) {
+				t.Errorf("code[%v]: %q outside expected alphabet", i, c)
+			}
+		}
+	}
+}
+
 func TestCommitCode(t *testing.T) {
 	t.Parallel()
 
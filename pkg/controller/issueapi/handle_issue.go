@@ -26,6 +26,7 @@ import (
 	"github.com/google/exposure-notifications-verification-server/pkg/rbac"
 
 	enobs "github.com/google/exposure-notifications-server/pkg/observability"
+	"golang.org/x/text/language"
 )
 
 // HandleIssueAPI responds to the /issue API for issuing verification codes
@@ -105,6 +106,12 @@ func (c *Controller) IssueWithUIAuth(w http.ResponseWriter, r *http.Request) *Is
 func (c *Controller) decodeAndIssue(ctx context.Context, w http.ResponseWriter, r *http.Request, result *IssueResult) {
 	var request api.IssueCodeRequest
 	if err := controller.BindJSON(w, r, &request); err != nil {
+		if errors.Is(err, controller.ErrBodyTooLarge) {
+			result.obsResult = enobs.ResultError("REQUEST_TOO_LARGE")
+			controller.RequestTooLarge(w, r, c.h, err)
+			return
+		}
+
 		result.obsResult = enobs.ResultError("FAILED_TO_PARSE_JSON_REQUEST")
 		c.h.RenderJSON(w, http.StatusBadRequest, api.Error(err).WithCode(api.ErrUnparsableRequest))
 		return
@@ -122,6 +129,10 @@ func (c *Controller) decodeAndIssue(ctx context.Context, w http.ResponseWriter,
 		request.SMSTemplateLabel = database.UserReportTemplateLabel
 	}
 
+	if request.Locale == "" {
+		request.Locale = acceptLanguage(r)
+	}
+
 	internalRequest := &IssueRequestInternal{
 		IssueRequest: &request,
 	}
@@ -150,3 +161,19 @@ func (c *Controller) decodeAndIssue(ctx context.Context, w http.ResponseWriter,
 		return
 	}
 }
+
+// acceptLanguage returns the most preferred language in the request's
+// Accept-Language header, or the empty string if there is none. The header is
+// only honored for API key requests; in the UI it describes the issuer's
+// browser, not the recipient.
+func acceptLanguage(r *http.Request) string {
+	if controller.AuthorizedAppFromContext(r.Context()) == nil {
+		return ""
+	}
+
+	tags, _, err := language.ParseAcceptLanguage(r.Header.Get("Accept-Language"))
+	if err != nil || len(tags) == 0 {
+		return ""
+	}
+	return tags[0].String()
+}
@@ -16,6 +16,8 @@ package issueapi
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"strings"
@@ -27,7 +29,9 @@ import (
 	"github.com/google/exposure-notifications-verification-server/pkg/rbac"
 )
 
-const maxBatchSize = 10
+// errBatchSizeExceeded is returned when a batch request contains more than the
+// maximum number of codes.
+var errBatchSizeExceeded = errors.New("batch size limit exceeded")
 
 // HandleBatchIssueAPI responds to the /batch-issue API for issuing verification codes
 func (c *Controller) HandleBatchIssueAPI() http.Handler {
@@ -101,25 +105,37 @@ func (c *Controller) decodeAndBulkIssue(ctx context.Context, w http.ResponseWrit
 	// Ensure bulk upload is enabled on this realm.
 	if currentRealm := controller.RealmFromContext(ctx); currentRealm == nil || !currentRealm.AllowBulkUpload {
 		result.obsResult = enobs.ResultError("BULK_ISSUE_NOT_ENABLED")
-		c.h.RenderJSON(w, http.StatusBadRequest, api.Errorf("bulk issuing is not enabled on this realm"))
+		c.h.RenderJSON(w, http.StatusBadRequest, api.Errorf("bulk issuing is not enabled on this realm").WithCode(api.ErrBulkIssueDisabled))
 		return
 	}
 
+	// Decode the codes one at a time so an oversized batch is rejected without
+	// buffering the entire request.
+	maxBatchSize := int(c.config.IssueConfig().BatchIssueMaxSize)
 	var request api.BatchIssueCodeRequest
-	if err := controller.BindJSON(w, r, &request); err != nil {
-		result.obsResult = enobs.ResultError("FAILED_TO_PARSE_JSON_REQUEST")
-		c.h.RenderJSON(w, http.StatusBadRequest, api.Error(err).WithCode(api.ErrUnparsableRequest))
-		return
-	}
-
-	if l := len(request.Codes); l > maxBatchSize {
-		result.obsResult = enobs.ResultError("BATCH_SIZE_LIMIT_EXCEEDED")
-		c.h.RenderJSON(w, http.StatusBadRequest, api.Errorf("batch size limit [%d] exceeded", maxBatchSize))
+	if err := controller.BindJSONStream(w, r, func(d *json.Decoder) error {
+		return decodeBatchIssueRequest(d, &request, maxBatchSize)
+	}); err != nil {
+		switch {
+		case errors.Is(err, controller.ErrBodyTooLarge):
+			result.obsResult = enobs.ResultError("REQUEST_TOO_LARGE")
+			controller.RequestTooLarge(w, r, c.h, err)
+		case errors.Is(err, errBatchSizeExceeded):
+			result.obsResult = enobs.ResultError("BATCH_SIZE_LIMIT_EXCEEDED")
+			c.h.RenderJSON(w, http.StatusBadRequest, api.Errorf("batch size limit [%d] exceeded", maxBatchSize).WithCode(api.ErrBatchSizeExceeded))
+		default:
+			result.obsResult = enobs.ResultError("FAILED_TO_PARSE_JSON_REQUEST")
+			c.h.RenderJSON(w, http.StatusBadRequest, api.Error(err).WithCode(api.ErrUnparsableRequest))
+		}
 		return
 	}
 
+	locale := acceptLanguage(r)
 	internalRequests := make([]*IssueRequestInternal, 0, len(request.Codes))
 	for _, c := range request.Codes {
+		if c.Locale == "" {
+			c.Locale = locale
+		}
 		internalRequests = append(internalRequests,
 			&IssueRequestInternal{
 				IssueRequest: c,
@@ -160,5 +176,34 @@ func (c *Controller) decodeAndBulkIssue(ctx context.Context, w http.ResponseWrit
 		batchResp.Error = sb.String()
 	}
 
+	// Queue a notification for the API key's callback URL, if any. Batches
+	// issued from the UI do not have an authorized app.
+	c.enqueueBatchCompleted(ctx, controller.AuthorizedAppFromContext(ctx), batchResp)
+
 	c.h.RenderJSON(w, HTTPCode, batchResp)
 }
+
+// decodeBatchIssueRequest decodes a BatchIssueCodeRequest from d, one code at
+// a time. It returns errBatchSizeExceeded as soon as more than max codes are
+// encountered.
+func decodeBatchIssueRequest(d *json.Decoder, request *api.BatchIssueCodeRequest, max int) error {
+	return controller.DecodeJSONObject(d, controller.JSONFieldDecoders{
+		"padding": func(d *json.Decoder) error {
+			return d.Decode(&request.Padding)
+		},
+		"codes": func(d *json.Decoder) error {
+			return controller.DecodeJSONArray(d, func(d *json.Decoder) error {
+				if len(request.Codes) >= max {
+					return errBatchSizeExceeded
+				}
+
+				var code api.IssueCodeRequest
+				if err := d.Decode(&code); err != nil {
+					return err
+				}
+				request.Codes = append(request.Codes, &code)
+				return nil
+			})
+		},
+	})
+}
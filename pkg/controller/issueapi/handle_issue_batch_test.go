@@ -206,4 +206,71 @@ func TestIssueBatch(t *testing.T) {
 			}
 		})
 	}
+	t.Run("configured_batch_size", func(t *testing.T) {
+		t.Parallel()
+
+		cfg := *harness.Config
+		cfg.Issue.BatchIssueMaxSize = 20
+		handler := issueapi.New(&cfg, harness.Database, harness.RateLimiter, harness.KeyManager, harness.Renderer).HandleBatchIssueAPI()
+
+		ctx := ctx
+		ctx = controller.WithRealm(ctx, realm)
+		ctx = controller.WithAuthorizedApp(ctx, authApp)
+
+		codes := make([]*api.IssueCodeRequest, 0, 15)
+		for i := 0; i < 15; i++ {
+			codes = append(codes, &api.IssueCodeRequest{
+				TestType:    "confirmed",
+				SymptomDate: symptomDate,
+			})
+		}
+
+		w, r := envstest.BuildJSONRequest(ctx, t, http.MethodPost, "/", &api.BatchIssueCodeRequest{
+			Codes: codes,
+		})
+		handler.ServeHTTP(w, r)
+
+		if got, want := w.Code, http.StatusOK; got != want {
+			t.Errorf("expected %d to be %d: %s", got, want, w.Body.String())
+		}
+
+		var apiResp api.BatchIssueCodeResponse
+		if err := json.NewDecoder(w.Body).Decode(&apiResp); err != nil {
+			t.Fatal(err)
+		}
+		if got, want := len(apiResp.Codes), 15; got != want {
+			t.Errorf("expected %d to be %d", got, want)
+		}
+	})
+
+	t.Run("request_too_large", func(t *testing.T) {
+		t.Parallel()
+
+		ctx := ctx
+		ctx = controller.WithRealm(ctx, realm)
+		ctx = controller.WithAuthorizedApp(ctx, authApp)
+		ctx = controller.WithMaxBodyBytes(ctx, 16)
+
+		w, r := envstest.BuildJSONRequest(ctx, t, http.MethodPost, "/", &api.BatchIssueCodeRequest{
+			Codes: []*api.IssueCodeRequest{
+				{
+					TestType:    "confirmed",
+					SymptomDate: symptomDate,
+				},
+			},
+		})
+		handler.ServeHTTP(w, r)
+
+		if got, want := w.Code, http.StatusRequestEntityTooLarge; got != want {
+			t.Errorf("expected %d to be %d: %s", got, want, w.Body.String())
+		}
+
+		var apiResp api.BatchIssueCodeResponse
+		if err := json.NewDecoder(w.Body).Decode(&apiResp); err != nil {
+			t.Fatal(err)
+		}
+		if got, want := apiResp.ErrorCode, api.ErrRequestTooLarge; got != want {
+			t.Errorf("expected %q to be %q", got, want)
+		}
+	})
 }
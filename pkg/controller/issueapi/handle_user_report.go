@@ -15,6 +15,7 @@
 package issueapi
 
 import (
+	"context"
 	"errors"
 	"net/http"
 	"time"
@@ -22,31 +23,32 @@ import (
 	"github.com/google/exposure-notifications-verification-server/pkg/api"
 	"github.com/google/exposure-notifications-verification-server/pkg/controller"
 	"github.com/google/exposure-notifications-verification-server/pkg/database"
+	"github.com/google/exposure-notifications-verification-server/pkg/realip"
 
 	"github.com/google/exposure-notifications-server/pkg/base64util"
 	"github.com/google/exposure-notifications-server/pkg/logging"
 	enobs "github.com/google/exposure-notifications-server/pkg/observability"
 )
 
+// UserReportResult is the outcome of a user report request. If ErrorReturn is
+// non-nil, the request failed and HTTPCode is the status code to return to the
+// client.
+type UserReportResult struct {
+	Response    *api.UserReportResponse
+	ErrorReturn *api.ErrorReturn
+	HTTPCode    int
+}
+
 func (c *Controller) HandleUserReport() http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if c.config.IsMaintenanceMode() {
-			c.h.RenderJSON(w, http.StatusTooManyRequests,
-				api.Errorf("server is read-only for maintenance").WithCode(api.ErrMaintenanceMode))
-			return
-		}
-
 		ctx := r.Context()
 		logger := logging.FromContext(ctx).Named("userreportapi.HandleUserReport")
 
-		blame := enobs.BlameNone
-		result := enobs.ResultOK
-		defer enobs.RecordLatency(ctx, time.Now(), mLatencyMs, &result, &blame)
-
 		authApp := controller.AuthorizedAppFromContext(ctx)
 		if authApp == nil {
-			blame = enobs.BlameClient
-			result = enobs.ResultError("MISSING_AUTHORIZED_APP")
+			blame := enobs.BlameClient
+			result := enobs.ResultError("MISSING_AUTHORIZED_APP")
+			enobs.RecordLatency(ctx, time.Now(), mLatencyMs, &result, &blame)
 			controller.MissingAuthorizedApp(w, r, c.h)
 			return
 		}
@@ -55,85 +57,156 @@ func (c *Controller) HandleUserReport() http.Handler {
 		var request api.UserReportRequest
 		if err := controller.BindJSON(w, r, &request); err != nil {
 			logger.Errorw("bad request", "error", err)
-			blame = enobs.BlameClient
-			result = enobs.ResultError("FAILED_TO_PARSE_JSON_REQUEST")
+			blame := enobs.BlameClient
+
+			if errors.Is(err, controller.ErrBodyTooLarge) {
+				result := enobs.ResultError("REQUEST_TOO_LARGE")
+				enobs.RecordLatency(ctx, time.Now(), mLatencyMs, &result, &blame)
+				controller.RequestTooLarge(w, r, c.h, err)
+				return
+			}
+
+			result := enobs.ResultError("FAILED_TO_PARSE_JSON_REQUEST")
+			enobs.RecordLatency(ctx, time.Now(), mLatencyMs, &result, &blame)
 
 			c.h.RenderJSON(w, http.StatusBadRequest, api.Error(err).WithCode(api.ErrUnparsableRequest))
 			return
 		}
 
-		// Ensure realm allows user report.
-		realm := controller.RealmFromContext(ctx)
-		if !realm.AllowsUserReport() {
-			logger.Warnw("realm is requesting user report, but disabled", "realmID", realm.ID)
-			blame = enobs.BlameClient
-			result = enobs.ResultError("USER_REPORT_NOT_ENABLED")
+		result := c.UserReport(ctx, realip.FromGoogleCloud(r), authApp, &request)
+		switch {
+		case result.HTTPCode == http.StatusInternalServerError:
+			controller.InternalError(w, r, c.h, errors.New(result.ErrorReturn.Error))
+		case result.ErrorReturn != nil:
+			c.h.RenderJSON(w, result.HTTPCode, result.ErrorReturn)
+		default:
+			c.h.RenderJSON(w, http.StatusOK, result.Response)
+		}
+	})
+}
 
-			c.h.RenderJSON(w, http.StatusBadRequest, api.Errorf("user initiated report is not enabled").WithCode(api.ErrUnsupportedTestType))
-			return
+// UserReport validates the user report request, applies the user report
+// limits, and issues a code to the provided phone number. It is shared by the
+// JSON and gRPC device APIs, which are responsible for authenticating the
+// authorized app and loading the realm into the context.
+func (c *Controller) UserReport(ctx context.Context, remoteIP string, authApp *database.AuthorizedApp, request *api.UserReportRequest) *UserReportResult {
+	if c.config.IsMaintenanceMode() {
+		return &UserReportResult{
+			HTTPCode:    http.StatusTooManyRequests,
+			ErrorReturn: api.Errorf("server is read-only for maintenance").WithCode(api.ErrMaintenanceMode),
 		}
+	}
 
-		nonce, err := base64util.DecodeString(request.Nonce)
-		if err != nil {
-			logger.Errorw("bad request", "error", err)
-			blame = enobs.BlameClient
-			result = enobs.ResultError("FAILED_TO_PARSE_JSON_REQUEST")
+	logger := logging.FromContext(ctx).Named("userreportapi.UserReport")
 
-			c.h.RenderJSON(w, http.StatusBadRequest, api.Error(err).WithCode(api.ErrUnparsableRequest))
-			return
-		}
-		if len(nonce) == 0 {
-			logger.Errorw("bad request", "error", err)
-			blame = enobs.BlameClient
-			result = enobs.ResultError("USER_REQUEST_MISSING_NONCE")
+	blame := enobs.BlameNone
+	result := enobs.ResultOK
+	defer enobs.RecordLatency(ctx, time.Now(), mLatencyMs, &result, &blame)
 
-			c.h.RenderJSON(w, http.StatusBadRequest, api.Errorf("nonce cannot be empty").WithCode(api.ErrMissingNonce))
-			return
+	// Ensure realm allows user report.
+	realm := controller.RealmFromContext(ctx)
+	if !realm.AllowsUserReport() {
+		logger.Warnw("realm is requesting user report, but disabled", "realmID", realm.ID)
+		blame = enobs.BlameClient
+		result = enobs.ResultError("USER_REPORT_NOT_ENABLED")
+
+		return &UserReportResult{
+			HTTPCode:    http.StatusBadRequest,
+			ErrorReturn: api.Errorf("user initiated report is not enabled").WithCode(api.ErrUnsupportedTestType),
 		}
+	}
 
-		if len(request.Phone) == 0 {
-			logger.Errorw("bad request", "error", err)
-			blame = enobs.BlameClient
-			result = enobs.ResultError("USER_REQUEST_MISSING_PHONE")
+	nonce, err := base64util.DecodeString(request.Nonce)
+	if err != nil {
+		logger.Errorw("bad request", "error", err)
+		blame = enobs.BlameClient
+		result = enobs.ResultError("FAILED_TO_PARSE_JSON_REQUEST")
 
-			c.h.RenderJSON(w, http.StatusBadRequest, api.Errorf("phone cannot be empty").WithCode(api.ErrMissingPhone))
-			return
+		return &UserReportResult{
+			HTTPCode:    http.StatusBadRequest,
+			ErrorReturn: api.Error(err).WithCode(api.ErrUnparsableRequest),
 		}
-
-		// Issue code and send text.
-		issueRequest := &IssueRequestInternal{
-			IssueRequest: &api.IssueCodeRequest{
-				SymptomDate:      request.SymptomDate,
-				TestDate:         request.TestDate,
-				TestType:         api.TestTypeUserReport, // Always test type of user report.
-				Phone:            request.Phone,
-				SMSTemplateLabel: database.UserReportTemplateLabel,
-			},
-			UserRequested: true,
-			Nonce:         nonce,
+	}
+	if len(nonce) == 0 {
+		logger.Errorw("bad request", "error", err)
+		blame = enobs.BlameClient
+		result = enobs.ResultError("USER_REQUEST_MISSING_NONCE")
+
+		return &UserReportResult{
+			HTTPCode:    http.StatusBadRequest,
+			ErrorReturn: api.Errorf("nonce cannot be empty").WithCode(api.ErrMissingNonce),
 		}
+	}
 
-		res := c.IssueOne(ctx, issueRequest)
+	if len(request.Phone) == 0 {
+		logger.Errorw("bad request", "error", err)
+		blame = enobs.BlameClient
+		result = enobs.ResultError("USER_REQUEST_MISSING_PHONE")
 
-		switch res.HTTPCode {
-		case http.StatusInternalServerError:
-			controller.InternalError(w, r, c.h, errors.New(res.ErrorReturn.Error))
-			return
-		case http.StatusConflict:
-			c.h.RenderJSON(w, http.StatusOK, &api.UserReportResponse{
-				ExpiresAt:          res.IssueCodeResponse().ExpiresAt,
-				ExpiresAtTimestamp: res.IssueCodeResponse().ExpiresAtTimestamp,
-			})
-			return
-		case http.StatusOK:
-			c.h.RenderJSON(w, http.StatusOK, &api.UserReportResponse{
+		return &UserReportResult{
+			HTTPCode:    http.StatusBadRequest,
+			ErrorReturn: api.Errorf("phone cannot be empty").WithCode(api.ErrMissingPhone),
+		}
+	}
+
+	// If the realm has published consent text, the user must have accepted the
+	// current version.
+	if realm.UserReportConsentVersion > 0 && request.ConsentVersion != realm.UserReportConsentVersion {
+		logger.Warnw("user report consent version mismatch",
+			"realmID", realm.ID,
+			"got", request.ConsentVersion,
+			"want", realm.UserReportConsentVersion)
+		blame = enobs.BlameClient
+		result = enobs.ResultError("USER_REPORT_CONSENT_MISMATCH")
+
+		return &UserReportResult{
+			HTTPCode: http.StatusPreconditionFailed,
+			ErrorReturn: api.Errorf("consent version %d is not the current version", request.ConsentVersion).
+				WithCode(api.ErrUserReportConsentMismatch),
+		}
+	}
+
+	// Apply the layered user report limits before issuing.
+	if res := c.CheckUserReportLimits(ctx, remoteIP, realm, authApp, request.Phone); res != nil {
+		blame = enobs.BlameClient
+		result = res.obsResult
+		return &UserReportResult{
+			HTTPCode:    res.HTTPCode,
+			ErrorReturn: res.ErrorReturn,
+		}
+	}
+
+	// Issue code and send text.
+	issueRequest := &IssueRequestInternal{
+		IssueRequest: &api.IssueCodeRequest{
+			SymptomDate:      request.SymptomDate,
+			TestDate:         request.TestDate,
+			TestType:         api.TestTypeUserReport, // Always test type of user report.
+			Phone:            request.Phone,
+			SMSTemplateLabel: database.UserReportTemplateLabel,
+			Locale:           request.ConsentLocale,
+		},
+		UserRequested:  true,
+		Nonce:          nonce,
+		ConsentVersion: request.ConsentVersion,
+		ConsentLocale:  request.ConsentLocale,
+	}
+
+	res := c.IssueOne(ctx, issueRequest)
+
+	switch res.HTTPCode {
+	case http.StatusOK, http.StatusConflict:
+		return &UserReportResult{
+			HTTPCode: http.StatusOK,
+			Response: &api.UserReportResponse{
 				ExpiresAt:          res.IssueCodeResponse().ExpiresAt,
 				ExpiresAtTimestamp: res.IssueCodeResponse().ExpiresAtTimestamp,
-			})
-			return
-		default:
-			c.h.RenderJSON(w, res.HTTPCode, res.ErrorReturn)
-			return
+			},
 		}
-	})
+	default:
+		return &UserReportResult{
+			HTTPCode:    res.HTTPCode,
+			ErrorReturn: res.ErrorReturn,
+		}
+	}
 }
@@ -0,0 +1,72 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package issueapi
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/google/exposure-notifications-verification-server/pkg/api"
+	"github.com/google/exposure-notifications-verification-server/pkg/controller"
+	"github.com/google/exposure-notifications-verification-server/pkg/database"
+
+	"github.com/google/exposure-notifications-server/pkg/logging"
+)
+
+// HandleUserReportConsent returns the realm's current user report consent text
+// in the requested locale. Apps display the text and send the returned version
+// with the user report.
+func (c *Controller) HandleUserReportConsent() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		logger := logging.FromContext(ctx).Named("userreportapi.HandleUserReportConsent")
+
+		var request api.UserReportConsentRequest
+		if err := controller.BindJSON(w, r, &request); err != nil {
+			logger.Errorw("bad request", "error", err)
+
+			if errors.Is(err, controller.ErrBodyTooLarge) {
+				controller.RequestTooLarge(w, r, c.h, err)
+				return
+			}
+
+			c.h.RenderJSON(w, http.StatusBadRequest, api.Error(err).WithCode(api.ErrUnparsableRequest))
+			return
+		}
+
+		realm := controller.RealmFromContext(ctx)
+		if !realm.AllowsUserReport() {
+			c.h.RenderJSON(w, http.StatusBadRequest, api.Errorf("user initiated report is not enabled").WithCode(api.ErrUnsupportedTestType))
+			return
+		}
+
+		consent, err := realm.CurrentUserReportConsent(c.db, request.Locale)
+		if err != nil {
+			if database.IsNotFound(err) {
+				c.h.RenderJSON(w, http.StatusOK, &api.UserReportConsentResponse{})
+				return
+			}
+
+			controller.InternalError(w, r, c.h, err)
+			return
+		}
+
+		c.h.RenderJSON(w, http.StatusOK, &api.UserReportConsentResponse{
+			Version: consent.Version,
+			Locale:  consent.Locale,
+			Text:    consent.Text,
+		})
+	})
+}
@@ -0,0 +1,99 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package issueapi
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/google/exposure-notifications-verification-server/pkg/api"
+	"github.com/google/exposure-notifications-verification-server/pkg/controller"
+	"github.com/google/exposure-notifications-verification-server/pkg/database"
+
+	"github.com/google/exposure-notifications-server/pkg/base64util"
+	"github.com/google/exposure-notifications-server/pkg/logging"
+)
+
+// HandleUserReportPurge deletes the data derived from a self-reporter's phone
+// number at their request. The request must include the nonce that was sent
+// with the user report. The response is a deletion receipt.
+func (c *Controller) HandleUserReportPurge() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if c.config.IsMaintenanceMode() {
+			c.h.RenderJSON(w, http.StatusTooManyRequests,
+				api.Errorf("server is read-only for maintenance").WithCode(api.ErrMaintenanceMode))
+			return
+		}
+
+		ctx := r.Context()
+		logger := logging.FromContext(ctx).Named("userreportapi.HandleUserReportPurge")
+
+		authApp := controller.AuthorizedAppFromContext(ctx)
+		if authApp == nil {
+			controller.MissingAuthorizedApp(w, r, c.h)
+			return
+		}
+
+		var request api.UserReportPurgeRequest
+		if err := controller.BindJSON(w, r, &request); err != nil {
+			logger.Errorw("bad request", "error", err)
+
+			if errors.Is(err, controller.ErrBodyTooLarge) {
+				controller.RequestTooLarge(w, r, c.h, err)
+				return
+			}
+
+			c.h.RenderJSON(w, http.StatusBadRequest, api.Error(err).WithCode(api.ErrUnparsableRequest))
+			return
+		}
+
+		if request.Phone == "" {
+			c.h.RenderJSON(w, http.StatusBadRequest, api.Errorf("phone cannot be empty").WithCode(api.ErrMissingPhone))
+			return
+		}
+
+		nonce, err := base64util.DecodeString(request.Nonce)
+		if err != nil {
+			c.h.RenderJSON(w, http.StatusBadRequest, api.Error(err).WithCode(api.ErrUnparsableRequest))
+			return
+		}
+		if len(nonce) == 0 {
+			c.h.RenderJSON(w, http.StatusBadRequest, api.Errorf("nonce cannot be empty").WithCode(api.ErrMissingNonce))
+			return
+		}
+
+		receipt, err := c.db.PurgeUserReport(request.Phone, nonce, authApp)
+		if err != nil {
+			if errors.Is(err, database.ErrUserReportPurgeMismatch) {
+				logger.Debugw("no user report matches purge request")
+				c.h.RenderJSON(w, http.StatusNotFound, api.Error(err).WithCode(api.ErrUserReportNotFound))
+				return
+			}
+
+			logger.Errorw("failed to purge user report", "error", err)
+			controller.InternalError(w, r, c.h, err)
+			return
+		}
+
+		c.h.RenderJSON(w, http.StatusOK, &api.UserReportPurgeResponse{
+			ReceiptID:         receipt.ReceiptID,
+			PurgedAt:          receipt.PurgedAt.UTC().Format(time.RFC1123),
+			PurgedAtTimestamp: receipt.PurgedAt.UTC().Unix(),
+			CodesExpired:      receipt.CodesExpired,
+			ConsentsUnlinked:  receipt.ConsentsUnlinked,
+		})
+	})
+}
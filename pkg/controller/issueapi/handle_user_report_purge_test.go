@@ -0,0 +1,193 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package issueapi_test
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/google/exposure-notifications-verification-server/internal/envstest"
+	"github.com/google/exposure-notifications-verification-server/internal/project"
+	"github.com/google/exposure-notifications-verification-server/pkg/api"
+	"github.com/google/exposure-notifications-verification-server/pkg/controller"
+	"github.com/google/exposure-notifications-verification-server/pkg/controller/issueapi"
+	"github.com/google/exposure-notifications-verification-server/pkg/database"
+)
+
+func TestUserReportPurge(t *testing.T) {
+	t.Parallel()
+
+	ctx := project.TestContext(t)
+	harness := envstest.NewServerConfig(t, testDatabaseInstance)
+
+	realm, err := harness.Database.FindRealm(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	realm.AddUserReportToAllowedTestTypes()
+	if err := harness.Database.SaveRealm(realm, database.SystemTest); err != nil {
+		t.Fatal(err)
+	}
+
+	authApp := &database.AuthorizedApp{
+		Name:       "Appy",
+		APIKeyType: database.APIKeyTypeDevice,
+	}
+	if _, err := realm.CreateAuthorizedApp(harness.Database, authApp, database.SystemTest); err != nil {
+		t.Fatal(err)
+	}
+
+	newNonce := func(tb testing.TB) []byte {
+		tb.Helper()
+
+		b := make([]byte, database.NonceLength)
+		if _, err := rand.Read(b); err != nil {
+			tb.Fatal(err)
+		}
+		return b
+	}
+
+	phone := "+12068675309"
+	nonce := newNonce(t)
+	verCode := &database.VerificationCode{
+		RealmID:        realm.ID,
+		Code:           "23456789",
+		LongCode:       "23456789",
+		TestType:       api.TestTypeUserReport,
+		PhoneNumber:    phone,
+		Nonce:          nonce,
+		NonceRequired:  true,
+		ConsentVersion: 1,
+		ConsentLocale:  "en",
+		ExpiresAt:      time.Now().Add(time.Hour),
+		LongExpiresAt:  time.Now().Add(time.Hour),
+	}
+	if err := realm.SaveVerificationCode(harness.Database, verCode); err != nil {
+		t.Fatal(err)
+	}
+
+	c := issueapi.New(harness.Config, harness.Database, harness.RateLimiter, harness.KeyManager, harness.Renderer)
+	handler := c.HandleUserReportPurge()
+
+	cases := []struct {
+		name           string
+		request        *api.UserReportPurgeRequest
+		httpStatusCode int
+		responseErr    string
+	}{
+		{
+			name: "missing_phone",
+			request: &api.UserReportPurgeRequest{
+				Nonce: base64.StdEncoding.EncodeToString(nonce),
+			},
+			httpStatusCode: http.StatusBadRequest,
+			responseErr:    api.ErrMissingPhone,
+		},
+		{
+			name: "missing_nonce",
+			request: &api.UserReportPurgeRequest{
+				Phone: phone,
+			},
+			httpStatusCode: http.StatusBadRequest,
+			responseErr:    api.ErrMissingNonce,
+		},
+		{
+			name: "unparsable_nonce",
+			request: &api.UserReportPurgeRequest{
+				Phone: phone,
+				Nonce: "..45",
+			},
+			httpStatusCode: http.StatusBadRequest,
+			responseErr:    api.ErrUnparsableRequest,
+		},
+		{
+			name: "nonce_mismatch",
+			request: &api.UserReportPurgeRequest{
+				Phone: phone,
+				Nonce: base64.StdEncoding.EncodeToString(newNonce(t)),
+			},
+			httpStatusCode: http.StatusNotFound,
+			responseErr:    api.ErrUserReportNotFound,
+		},
+		{
+			name: "success",
+			request: &api.UserReportPurgeRequest{
+				Phone: phone,
+				Nonce: base64.StdEncoding.EncodeToString(nonce),
+			},
+			httpStatusCode: http.StatusOK,
+		},
+		{
+			// Same request as the previous case, the user report is gone.
+			name: "already_purged",
+			request: &api.UserReportPurgeRequest{
+				Phone: phone,
+				Nonce: base64.StdEncoding.EncodeToString(nonce),
+			},
+			httpStatusCode: http.StatusNotFound,
+			responseErr:    api.ErrUserReportNotFound,
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			// not parallel, since the cases depend on the previous purges.
+
+			ctx := ctx
+			ctx = controller.WithRealm(ctx, realm)
+			ctx = controller.WithAuthorizedApp(ctx, authApp)
+
+			w, r := envstest.BuildJSONRequest(ctx, t, http.MethodPost, "/", tc.request)
+			handler.ServeHTTP(w, r)
+
+			if got, want := w.Code, tc.httpStatusCode; got != want {
+				t.Fatalf("expected %d to be %d: %s", got, want, w.Body.String())
+			}
+
+			var apiResp api.UserReportPurgeResponse
+			if err := json.NewDecoder(w.Body).Decode(&apiResp); err != nil {
+				t.Fatal(err)
+			}
+
+			if got, want := apiResp.ErrorCode, tc.responseErr; got != want {
+				t.Errorf("expected %q to be %q: %#v", got, want, apiResp)
+			}
+
+			if tc.httpStatusCode != http.StatusOK {
+				return
+			}
+
+			if apiResp.ReceiptID == "" {
+				t.Errorf("expected receipt id")
+			}
+			if apiResp.PurgedAtTimestamp == 0 {
+				t.Errorf("expected purged at timestamp")
+			}
+			if got, want := apiResp.CodesExpired, uint(1); got != want {
+				t.Errorf("expected %d to be %d", got, want)
+			}
+
+			if _, err := harness.Database.FindUserReport(phone); !database.IsNotFound(err) {
+				t.Errorf("expected user report to be deleted, got %v", err)
+			}
+		})
+	}
+}
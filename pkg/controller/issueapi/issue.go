@@ -39,6 +39,10 @@ type IssueRequestInternal struct {
 	UserRequested bool
 	// These files are for user initiated report
 	Nonce []byte
+	// ConsentVersion and ConsentLocale identify the consent text the user
+	// accepted, if the realm has published one.
+	ConsentVersion uint
+	ConsentLocale  string
 }
 
 // IssueResult is the response returned from IssueLogic.IssueOne or IssueMany.
@@ -84,6 +88,7 @@ func (c *Controller) IssueOne(ctx context.Context, request *IssueRequestInternal
 
 // IssueMany handles validating a list of IssueCodeRequest, issuing new codes, and sending SMS messages.
 func (c *Controller) IssueMany(ctx context.Context, requests []*IssueRequestInternal) []*IssueResult {
+	start := time.Now()
 	realm := controller.RealmFromContext(ctx)
 
 	logger := logging.FromContext(ctx).Named("issueapi.IssueMany").
@@ -101,10 +106,12 @@ func (c *Controller) IssueMany(ctx context.Context, requests []*IssueRequestInte
 		vCode.Nonce = req.Nonce
 		vCode.PhoneNumber = req.IssueRequest.Phone
 		vCode.NonceRequired = req.UserRequested
+		vCode.ConsentVersion = req.ConsentVersion
+		vCode.ConsentLocale = req.ConsentLocale
 		results[i] = c.IssueCode(ctx, vCode, realm)
 	}
 
-	defer c.recordStats(ctx, results)
+	defer c.recordStats(ctx, realm, start, results)
 
 	// Send SMS messages if there's an SMS provider.
 	smsProvider, err := c.smsProviderFor(ctx, realm)
@@ -176,15 +183,32 @@ func (c *Controller) IssueMany(ctx context.Context, requests []*IssueRequestInte
 	return results
 }
 
-// recordStats increments stats for successfully issued codes
-func (c *Controller) recordStats(ctx context.Context, results []*IssueResult) {
+// recordStats increments stats for successfully issued codes, and records the
+// failures and latency of the request against the issuer.
+func (c *Controller) recordStats(ctx context.Context, realm *database.Realm, start time.Time, results []*IssueResult) {
 	codes := make([]*database.VerificationCode, 0, len(results))
+	var failed uint
 	for _, result := range results {
 		if result.ErrorReturn == nil {
 			codes = append(codes, result.VerCode)
+		} else {
+			failed++
 		}
 	}
 	c.db.UpdateStats(ctx, codes...)
+
+	outcome := &database.IssuanceOutcome{
+		RealmID: realm.ID,
+		Failed:  failed,
+		Latency: time.Since(start),
+	}
+	if membership := controller.MembershipFromContext(ctx); membership != nil {
+		outcome.UserID = membership.UserID
+	}
+	if authApp := controller.AuthorizedAppFromContext(ctx); authApp != nil {
+		outcome.AuthorizedAppID = authApp.ID
+	}
+	c.db.RecordIssuanceOutcome(ctx, outcome)
 }
 
 // smsProviderFor returns the sms provider for the given realm. It pulls the
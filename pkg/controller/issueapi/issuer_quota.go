@@ -0,0 +1,126 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package issueapi
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/exposure-notifications-server/pkg/logging"
+	enobs "github.com/google/exposure-notifications-server/pkg/observability"
+	"github.com/google/exposure-notifications-verification-server/pkg/api"
+	"github.com/google/exposure-notifications-verification-server/pkg/database"
+	"go.opencensus.io/stats"
+	"go.opencensus.io/tag"
+)
+
+// issuerQuotaInterval is the window for the per-user and per-API key daily
+// issuance limits.
+const issuerQuotaInterval = 24 * time.Hour
+
+// issuerQuota is a single per-issuer daily issuance limit.
+type issuerQuota struct {
+	// name is used in the metric tag and result.
+	name string
+
+	// key is the limiter key. The quota is skipped if the key is empty.
+	key string
+
+	limit     uint
+	errorCode string
+	message   string
+}
+
+// CheckIssuerQuotas applies the realm's per-user and per-API key daily
+// issuance limits to the code. It returns nil if the code may be issued, or
+// the result to return to the caller otherwise. These limits are in addition to
+// the realm's daily quota, so a single compromised credential cannot exhaust
+// it. User reports are not counted against the API key limit, because they have
+// their own limits.
+func (c *Controller) CheckIssuerQuotas(ctx context.Context, realm *database.Realm, vCode *database.VerificationCode) *IssueResult {
+	logger := logging.FromContext(ctx).Named("issueapi.CheckIssuerQuotas")
+
+	hmacKey := c.config.GetRateLimitConfig().HMACKey
+
+	quotas := make([]*issuerQuota, 0, 2)
+	if realm.DailyUserIssueLimit > 0 && vCode.IssuingUserID != 0 {
+		key, err := realm.UserQuotaKey(vCode.IssuingUserID, hmacKey)
+		if err != nil {
+			return issuerQuotaInternalError(err)
+		}
+		quotas = append(quotas, &issuerQuota{
+			name:      "user",
+			key:       key,
+			limit:     realm.DailyUserIssueLimit,
+			errorCode: api.ErrUserQuotaExceeded,
+			message:   "exceeded your daily code issuance limit, please contact a realm administrator",
+		})
+	}
+	if realm.DailyAPIKeyIssueLimit > 0 && vCode.IssuingAppID != 0 && !vCode.IsUserReport() {
+		key, err := realm.APIKeyQuotaKey(vCode.IssuingAppID, hmacKey)
+		if err != nil {
+			return issuerQuotaInternalError(err)
+		}
+		quotas = append(quotas, &issuerQuota{
+			name:      "api_key",
+			key:       key,
+			limit:     realm.DailyAPIKeyIssueLimit,
+			errorCode: api.ErrAPIKeyQuotaExceeded,
+			message:   "exceeded the daily code issuance limit for this API key, please contact a realm administrator",
+		})
+	}
+
+	for _, quota := range quotas {
+		ok, err := c.takeConfiguredLimit(ctx, quota.key, uint64(quota.limit), issuerQuotaInterval)
+		if err != nil {
+			logger.Errorw("failed to take from issuer quota", "issuer", quota.name, "error", err)
+			return &IssueResult{
+				obsResult:   enobs.ResultError("FAILED_TO_TAKE_FROM_LIMITER"),
+				HTTPCode:    http.StatusInternalServerError,
+				ErrorReturn: api.Errorf("failed to issue code, please try again in a few seconds").WithCode(api.ErrInternal),
+			}
+		}
+		if !ok {
+			logger.Warnw("issuer has exceeded daily quota",
+				"issuer", quota.name,
+				"realm", realm.ID,
+				"user", vCode.IssuingUserID,
+				"app", vCode.IssuingAppID,
+				"limit", quota.limit)
+
+			ctx, _ := tag.New(ctx, tag.Upsert(issuerTagKey, quota.name))
+			stats.Record(ctx, mIssuerQuotaExceeded.M(1))
+
+			return &IssueResult{
+				obsResult:   enobs.ResultError(strings.ToUpper(quota.name) + "_QUOTA_EXCEEDED"),
+				HTTPCode:    http.StatusTooManyRequests,
+				ErrorReturn: api.Errorf("%s", quota.message).WithCode(quota.errorCode),
+			}
+		}
+	}
+
+	return nil
+}
+
+// issuerQuotaInternalError is the result when the quota key cannot be built.
+func issuerQuotaInternalError(err error) *IssueResult {
+	return &IssueResult{
+		obsResult:   enobs.ResultError("FAILED_TO_GENERATE_HMAC"),
+		HTTPCode:    http.StatusInternalServerError,
+		ErrorReturn: api.Error(err).WithCode(api.ErrInternal),
+	}
+}
@@ -21,6 +21,7 @@ import (
 	"go.opencensus.io/plugin/ochttp"
 	"go.opencensus.io/stats"
 	"go.opencensus.io/stats/view"
+	"go.opencensus.io/tag"
 )
 
 const metricPrefix = observability.MetricRoot + "/api/issue"
@@ -36,10 +37,24 @@ var (
 
 	mRealmTokenUsed = stats.Int64(metricPrefix+"/realm_token_used", "# of realm token used.", stats.UnitDimensionless)
 
+	mCodeCollision = stats.Int64(metricPrefix+"/code_collision", "# of generated codes that collided with an existing code", stats.UnitDimensionless)
+
 	// separate metrics related to user report API.
 	mUserReportLatencyMs = stats.Float64(userReportMetricPrefix+"/request", "verify requests latency", stats.UnitMilliseconds)
 
 	mUserReportColission = stats.Int64(userReportMetricPrefix+"/phone_collision", "# of attempts to use a phone number multiple times for self report", stats.UnitDimensionless)
+
+	mUserReportLimited = stats.Int64(userReportMetricPrefix+"/limited", "# of user reports rejected by a rate limit layer", stats.UnitDimensionless)
+
+	mIssuerQuotaExceeded = stats.Int64(metricPrefix+"/issuer_quota_exceeded", "# of codes rejected by a per-user or per-API key daily limit", stats.UnitDimensionless)
+
+	mResendLatencyMs = stats.Float64(metricPrefix+"/resend_request", "# of code resend requests", stats.UnitMilliseconds)
+
+	// layerTagKey is the user report rate limit layer (phone, ip, realm, app).
+	layerTagKey = tag.MustNewKey("layer")
+
+	// issuerTagKey is the issuer whose daily limit was exceeded (user, api_key).
+	issuerTagKey = tag.MustNewKey("issuer")
 )
 
 func init() {
@@ -86,6 +101,13 @@ func init() {
 			Measure:     mRealmTokenUsed,
 			Aggregation: view.Count(),
 		},
+		{
+			Name:        metricPrefix + "/code_collision_count",
+			Description: "The count of generated codes that collided with an existing code",
+			TagKeys:     observability.CommonTagKeys(),
+			Measure:     mCodeCollision,
+			Aggregation: view.Count(),
+		},
 		{
 			Name:        userReportMetricPrefix + "/request_count",
 			Measure:     mUserReportLatencyMs,
@@ -107,5 +129,26 @@ func init() {
 			Measure:     mUserReportColission,
 			Aggregation: view.Count(),
 		},
+		{
+			Name:        userReportMetricPrefix + "/limited_count",
+			Description: "The count of user reports rejected by each rate limit layer.",
+			TagKeys:     append(observability.CommonTagKeys(), layerTagKey),
+			Measure:     mUserReportLimited,
+			Aggregation: view.Count(),
+		},
+		{
+			Name:        metricPrefix + "/issuer_quota_exceeded_count",
+			Description: "The count of codes rejected by a per-user or per-API key daily limit.",
+			TagKeys:     append(observability.CommonTagKeys(), issuerTagKey),
+			Measure:     mIssuerQuotaExceeded,
+			Aggregation: view.Count(),
+		},
+		{
+			Name:        metricPrefix + "/resend_request_count",
+			Measure:     mResendLatencyMs,
+			Description: "Count of code resend requests",
+			TagKeys:     observability.APITagKeys(),
+			Aggregation: view.Count(),
+		},
 	}...)
 }
@@ -0,0 +1,342 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package issueapi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/exposure-notifications-server/pkg/logging"
+	enobs "github.com/google/exposure-notifications-server/pkg/observability"
+	"github.com/google/exposure-notifications-verification-server/internal/project"
+	"github.com/google/exposure-notifications-verification-server/pkg/api"
+	"github.com/google/exposure-notifications-verification-server/pkg/controller"
+	"github.com/google/exposure-notifications-verification-server/pkg/database"
+	"github.com/google/exposure-notifications-verification-server/pkg/rbac"
+	"github.com/google/exposure-notifications-verification-server/pkg/sms"
+	"github.com/gorilla/mux"
+	"github.com/sethvargo/go-retry"
+	"go.opencensus.io/tag"
+)
+
+// HandleResendAPI responds to the /resendcode API for resending unclaimed
+// verification codes by SMS.
+func (c *Controller) HandleResendAPI() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		startTime := time.Now()
+
+		authorizedApp := controller.AuthorizedAppFromContext(ctx)
+		if authorizedApp == nil {
+			controller.MissingAuthorizedApp(w, r, c.h)
+			return
+		}
+
+		realm := controller.RealmFromContext(ctx)
+		if c.config.IsMaintenanceMode() || realm.MaintenanceMode {
+			c.h.RenderJSON(w, http.StatusTooManyRequests,
+				api.Errorf("server is read-only for maintenance").WithCode(api.ErrMaintenanceMode))
+			return
+		}
+
+		var request api.ResendCodeRequest
+		if err := controller.BindJSON(w, r, &request); err != nil {
+			c.h.RenderJSON(w, http.StatusBadRequest, api.Error(err).WithCode(api.ErrUnparsableRequest))
+			return
+		}
+
+		result := c.ResendCode(ctx, realm, &request, authorizedApp)
+		defer recordResendObservability(ctx, startTime, result)
+
+		if result.ErrorReturn != nil {
+			if result.HTTPCode == http.StatusInternalServerError {
+				controller.InternalError(w, r, c.h, errors.New(result.ErrorReturn.Error))
+				return
+			}
+			c.h.RenderJSON(w, result.HTTPCode, result.ErrorReturn)
+			return
+		}
+
+		c.h.RenderJSON(w, http.StatusOK, &api.ResendCodeResponse{
+			UUID:                   result.VerCode.UUID,
+			ExpiresAtTimestamp:     result.VerCode.ExpiresAt.UTC().Unix(),
+			LongExpiresAtTimestamp: result.VerCode.LongExpiresAt.UTC().Unix(),
+			ResendCount:            result.VerCode.ResendCount,
+		})
+	})
+}
+
+// HandleResendPage handles the resend form on the code status page.
+func (c *Controller) HandleResendPage() http.Handler {
+	type FormData struct {
+		Phone string `form:"phone"`
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		startTime := time.Now()
+		vars := mux.Vars(r)
+
+		session := controller.SessionFromContext(ctx)
+		if session == nil {
+			controller.MissingSession(w, r, c.h)
+			return
+		}
+		flash := controller.Flash(session)
+
+		membership := controller.MembershipFromContext(ctx)
+		if membership == nil {
+			controller.MissingMembership(w, r, c.h)
+			return
+		}
+		if !membership.Can(rbac.CodeIssue) {
+			controller.Unauthorized(w, r, c.h)
+			return
+		}
+
+		realm := membership.Realm
+		showPath := fmt.Sprintf("/codes/%s", vars["uuid"])
+
+		if c.config.IsMaintenanceMode() || realm.MaintenanceMode {
+			flash.Error("Failed to resend code: server is read-only for maintenance.")
+			http.Redirect(w, r, showPath, http.StatusSeeOther)
+			return
+		}
+
+		var form FormData
+		if err := controller.BindForm(w, r, &form); err != nil {
+			flash.Error("Failed to process form: %v.", err)
+			http.Redirect(w, r, showPath, http.StatusSeeOther)
+			return
+		}
+
+		ctx = controller.WithRealm(ctx, realm)
+		result := c.ResendCode(ctx, realm, &api.ResendCodeRequest{
+			UUID:  vars["uuid"],
+			Phone: form.Phone,
+		}, membership.User)
+		defer recordResendObservability(ctx, startTime, result)
+
+		if result.ErrorReturn != nil {
+			if result.HTTPCode == http.StatusInternalServerError {
+				controller.InternalError(w, r, c.h, errors.New(result.ErrorReturn.Error))
+				return
+			}
+			flash.Error("Failed to resend code: %s.", result.ErrorReturn.Error)
+			http.Redirect(w, r, showPath, http.StatusSeeOther)
+			return
+		}
+
+		flash.Alert("Resent code by SMS. The previous code no longer works.")
+		http.Redirect(w, r, showPath, http.StatusSeeOther)
+	})
+}
+
+// ResendCode replaces the unclaimed code with the given UUID and sends the new
+// values by SMS. Only HMACs of codes are stored, so the original values cannot
+// be sent again. The code keeps its UUID and expiration and is not counted as
+// a new issuance, so resends do not skew the realm's statistics. Each code can
+// be resent up to the configured limit.
+func (c *Controller) ResendCode(ctx context.Context, realm *database.Realm, request *api.ResendCodeRequest, actor database.Auditable) *IssueResult {
+	logger := logging.FromContext(ctx).Named("issueapi.ResendCode")
+
+	maxResends := c.config.IssueConfig().CodeMaxResends
+	if maxResends == 0 {
+		return &IssueResult{
+			obsResult:   enobs.ResultError("RESEND_DISABLED"),
+			HTTPCode:    http.StatusBadRequest,
+			ErrorReturn: api.Errorf("code resends are disabled").WithCode(api.ErrCodeResendLimit),
+		}
+	}
+
+	uuid := project.TrimSpaceAndNonPrintable(request.UUID)
+	if uuid == "" {
+		return &IssueResult{
+			obsResult:   enobs.ResultError("MISSING_UUID"),
+			HTTPCode:    http.StatusBadRequest,
+			ErrorReturn: api.Errorf("missing uuid").WithCode(api.ErrUnparsableRequest),
+		}
+	}
+
+	if strings.TrimSpace(request.Phone) == "" {
+		return &IssueResult{
+			obsResult:   enobs.ResultError("MISSING_PHONE_NUMBER"),
+			HTTPCode:    http.StatusBadRequest,
+			ErrorReturn: api.Errorf("missing phone number").WithCode(api.ErrMissingPhone),
+		}
+	}
+
+	phone, result := c.validatePhone(ctx, realm, request.Phone)
+	if result != nil {
+		return result
+	}
+
+	smsProvider, err := c.smsProviderFor(ctx, realm)
+	if err != nil {
+		logger.Errorw("failed to get sms provider", "error", err)
+		return &IssueResult{
+			obsResult:   enobs.ResultError("FAILED_TO_GET_SMS_PROVIDER"),
+			HTTPCode:    http.StatusInternalServerError,
+			ErrorReturn: api.Errorf("failed to get sms provider").WithCode(api.ErrInternal),
+		}
+	}
+	if smsProvider == nil {
+		return &IssueResult{
+			obsResult:   enobs.ResultError("FAILED_TO_GET_SMS_PROVIDER"),
+			HTTPCode:    http.StatusBadRequest,
+			ErrorReturn: api.Errorf("no sms provider is configured").WithCode(api.ErrSMSNotConfigured),
+		}
+	}
+
+	if result := c.checkSMSSuppressed(ctx, realm, phone); result != nil {
+		return result
+	}
+
+	smsSigner, keyID, err := c.smsSignerFor(ctx, realm)
+	if err != nil {
+		logger.Errorw("failed to get sms signer", "error", err)
+		return &IssueResult{
+			obsResult:   enobs.ResultError("FAILED_TO_GET_SMS_SIGNER"),
+			HTTPCode:    http.StatusInternalServerError,
+			ErrorReturn: api.InternalError(),
+		}
+	}
+
+	// Replace the code, retrying if the new values collide with another code.
+	var vCode *database.VerificationCode
+	b := retry.NewConstant(50 * time.Millisecond)
+	if err := retry.Do(ctx, retry.WithMaxRetries(uint64(c.config.IssueConfig().CollisionRetryCount), b), func(ctx context.Context) error {
+		code, err := GenerateRealmCode(realm)
+		if err != nil {
+			return err
+		}
+		longCode := code
+		if realm.LongCodeLength > 0 {
+			longCode, err = GenerateAlphanumericCode(realm.LongCodeLength)
+			if err != nil {
+				return err
+			}
+		}
+
+		vCode, err = realm.ResendCode(c.db, uuid, code, longCode, maxResends, actor)
+		if errors.Is(err, database.ErrCodeResendConflict) {
+			c.recordCodeCollision(ctx, realm)
+			return retry.RetryableError(err)
+		}
+		return err
+	}); err != nil {
+		return resendErrorResult(ctx, err)
+	}
+
+	// Send the new values.
+	issueRequest := &api.IssueCodeRequest{
+		TestType:         vCode.TestType,
+		Phone:            phone,
+		SMSTemplateLabel: request.SMSTemplateLabel,
+	}
+	message, err := c.BuildSMS(ctx, realm, smsSigner, keyID, issueRequest, vCode)
+	if err != nil {
+		return &IssueResult{
+			obsResult:   enobs.ResultError("FAILED_TO_BUILD_SMS"),
+			HTTPCode:    http.StatusBadRequest,
+			ErrorReturn: api.Errorf("failed to build sms: %s", err).WithCode(api.ErrSMSFailure),
+		}
+	}
+
+	if err := smsProvider.SendSMS(ctx, phone, message); err != nil {
+		logger.Infow("failed to resend sms", "error", ScrubPhoneNumbers(err.Error()))
+		c.recordSMSError(ctx, realm, err)
+
+		errorReturn := api.Errorf("failed to send sms: %s", err).WithCode(api.ErrSMSFailure)
+		if sms.IsSMSQueueFull(err) {
+			errorReturn = api.Errorf("failed to send sms: queue is full: %s", err).WithCode(api.ErrSMSQueueFull)
+		}
+		return &IssueResult{
+			obsResult:   enobs.ResultError("FAILED_TO_SEND_SMS"),
+			HTTPCode:    http.StatusBadRequest,
+			ErrorReturn: errorReturn,
+		}
+	}
+
+	return &IssueResult{
+		VerCode:   vCode,
+		HTTPCode:  http.StatusOK,
+		obsResult: enobs.ResultOK,
+	}
+}
+
+// resendErrorResult converts an error from replacing a code into a result.
+func resendErrorResult(ctx context.Context, err error) *IssueResult {
+	switch {
+	case database.IsNotFound(err):
+		return &IssueResult{
+			obsResult:   enobs.ResultError("CODE_NOT_FOUND"),
+			HTTPCode:    http.StatusNotFound,
+			ErrorReturn: api.Errorf("code not found").WithCode(api.ErrVerifyCodeNotFound),
+		}
+	case errors.Is(err, database.ErrCodeAlreadyClaimed):
+		return &IssueResult{
+			obsResult:   enobs.ResultError("CODE_ALREADY_CLAIMED"),
+			HTTPCode:    http.StatusBadRequest,
+			ErrorReturn: api.Error(err).WithCode(api.ErrVerifyCodeInvalid),
+		}
+	case errors.Is(err, database.ErrCodeAlreadyExpired):
+		return &IssueResult{
+			obsResult:   enobs.ResultError("CODE_EXPIRED"),
+			HTTPCode:    http.StatusBadRequest,
+			ErrorReturn: api.Error(err).WithCode(api.ErrVerifyCodeExpired),
+		}
+	case errors.Is(err, database.ErrCodeResendUserReport):
+		return &IssueResult{
+			obsResult:   enobs.ResultError("RESEND_USER_REPORT"),
+			HTTPCode:    http.StatusBadRequest,
+			ErrorReturn: api.Error(err).WithCode(api.ErrInvalidTestType),
+		}
+	case errors.Is(err, database.ErrCodeResendLimit):
+		return &IssueResult{
+			obsResult:   enobs.ResultError("RESEND_LIMIT"),
+			HTTPCode:    http.StatusBadRequest,
+			ErrorReturn: api.Error(err).WithCode(api.ErrCodeResendLimit),
+		}
+	}
+
+	logger := logging.FromContext(ctx).Named("issueapi.resendErrorResult")
+	logger.Errorw("failed to resend code", "error", err)
+	return &IssueResult{
+		obsResult:   enobs.ResultError("FAILED_TO_RESEND_CODE"),
+		HTTPCode:    http.StatusInternalServerError,
+		ErrorReturn: api.Errorf("failed to resend code, please try again").WithCode(api.ErrInternal),
+	}
+}
+
+// recordResendObservability records the latency and result of a resend
+// request.
+func recordResendObservability(ctx context.Context, startTime time.Time, result *IssueResult) {
+	var blame tag.Mutator
+	switch result.HTTPCode {
+	case http.StatusOK:
+		blame = enobs.BlameNone
+	case http.StatusInternalServerError:
+		blame = enobs.BlameServer
+	default:
+		blame = enobs.BlameClient
+	}
+
+	enobs.RecordLatency(ctx, startTime, mResendLatencyMs, &blame, &result.obsResult)
+}
@@ -0,0 +1,170 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package issueapi_test
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/google/exposure-notifications-verification-server/internal/envstest"
+	"github.com/google/exposure-notifications-verification-server/internal/project"
+	"github.com/google/exposure-notifications-verification-server/pkg/api"
+	"github.com/google/exposure-notifications-verification-server/pkg/controller"
+	"github.com/google/exposure-notifications-verification-server/pkg/controller/issueapi"
+	"github.com/google/exposure-notifications-verification-server/pkg/database"
+	"github.com/google/exposure-notifications-verification-server/pkg/sms"
+)
+
+func TestResendCode(t *testing.T) {
+	t.Parallel()
+
+	ctx := project.TestContext(t)
+	harness := envstest.NewServerConfig(t, testDatabaseInstance)
+	db := harness.Database
+
+	realm, err := db.FindRealm(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx = controller.WithRealm(ctx, realm)
+
+	smsConfig := &database.SMSConfig{
+		RealmID:      realm.ID,
+		ProviderType: sms.ProviderTypeNoop,
+	}
+	if err := db.SaveSMSConfig(smsConfig); err != nil {
+		t.Fatal(err)
+	}
+
+	suppressedPhone := "+15005550007"
+	if err := realm.SuppressSMS(db, suppressedPhone, database.SMSSuppressionSourceTwilio); err != nil {
+		t.Fatal(err)
+	}
+
+	newCode := func(t *testing.T, code string) *database.VerificationCode {
+		t.Helper()
+
+		vc := &database.VerificationCode{
+			RealmID:       realm.ID,
+			Code:          code,
+			LongCode:      code + "ABC",
+			TestType:      "confirmed",
+			ExpiresAt:     time.Now().Add(time.Hour),
+			LongExpiresAt: time.Now().Add(24 * time.Hour),
+		}
+		if err := realm.SaveVerificationCode(db, vc); err != nil {
+			t.Fatal(err)
+		}
+		return vc
+	}
+
+	c := issueapi.New(harness.Config, db, harness.RateLimiter, harness.KeyManager, harness.Renderer)
+
+	cases := []struct {
+		name     string
+		code     string
+		uuid     string
+		phone    string
+		errCode  string
+		httpCode int
+	}{
+		{
+			name:     "missing_uuid",
+			phone:    "+15005550006",
+			errCode:  api.ErrUnparsableRequest,
+			httpCode: http.StatusBadRequest,
+		},
+		{
+			name:     "missing_phone",
+			code:     "10000001",
+			errCode:  api.ErrMissingPhone,
+			httpCode: http.StatusBadRequest,
+		},
+		{
+			name:     "invalid_phone",
+			code:     "10000002",
+			phone:    "not a phone number",
+			errCode:  api.ErrPhoneNumberInvalid,
+			httpCode: http.StatusBadRequest,
+		},
+		{
+			name:     "suppressed",
+			code:     "10000003",
+			phone:    suppressedPhone,
+			errCode:  api.ErrPhoneNumberSuppressed,
+			httpCode: http.StatusBadRequest,
+		},
+		{
+			name:     "not_found",
+			uuid:     "7a2d3b46-7ccb-4a5e-8d5a-bb1ad1a9b7b1",
+			phone:    "+15005550006",
+			errCode:  api.ErrVerifyCodeNotFound,
+			httpCode: http.StatusNotFound,
+		},
+		{
+			name:     "resends",
+			code:     "10000004",
+			phone:    "+15005550006",
+			httpCode: http.StatusOK,
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			uuid := tc.uuid
+			if tc.code != "" {
+				uuid = newCode(t, tc.code).UUID
+			}
+
+			result := c.ResendCode(ctx, realm, &api.ResendCodeRequest{
+				UUID:  uuid,
+				Phone: tc.phone,
+			}, database.SystemTest)
+
+			if got, want := result.HTTPCode, tc.httpCode; got != want {
+				t.Errorf("expected %d to be %d: %#v", got, want, result.ErrorReturn)
+			}
+
+			var errCode string
+			if result.ErrorReturn != nil {
+				errCode = result.ErrorReturn.ErrorCode
+			}
+			if got, want := errCode, tc.errCode; got != want {
+				t.Errorf("expected %q to be %q", got, want)
+			}
+
+			if tc.errCode != "" {
+				return
+			}
+
+			if got, want := result.VerCode.ResendCount, uint(1); got != want {
+				t.Errorf("expected %d to be %d", got, want)
+			}
+
+			// The previous code no longer works.
+			if _, err := realm.FindVerificationCode(db, tc.code); !database.IsNotFound(err) {
+				t.Errorf("expected previous code to not be found, got %v", err)
+			}
+			if _, err := realm.FindVerificationCode(db, result.VerCode.Code); err != nil {
+				t.Errorf("expected new code to be found, got %v", err)
+			}
+		})
+	}
+}
@@ -17,6 +17,7 @@ package issueapi
 import (
 	"context"
 	"crypto"
+	"errors"
 	"fmt"
 	"net/http"
 	"strings"
@@ -88,10 +89,11 @@ func (c *Controller) BuildSMS(ctx context.Context, realm *database.Realm, signer
 	logger := logging.FromContext(ctx).Named("issueapi.BuildSMS")
 	redirectDomain := c.config.IssueConfig().ENExpressRedirectDomain
 
-	message, err := realm.BuildSMSText(vercode.Code, vercode.LongCode, redirectDomain, request.SMSTemplateLabel)
+	message, err := realm.BuildLocalizedSMSText(vercode.Code, vercode.LongCode, redirectDomain, request.SMSTemplateLabel, request.Locale)
 	if err != nil {
 		logger.Errorw("failed to build sms text for realm",
 			"template", request.SMSTemplateLabel,
+			"locale", request.Locale,
 			"error", err)
 		return "", fmt.Errorf("failed to build sms message: %w", err)
 	}
@@ -147,9 +149,30 @@ func (c *Controller) doSend(ctx context.Context, realm *database.Realm, smsProvi
 		}
 
 		logger.Infow("failed to send sms", "error", ScrubPhoneNumbers(err.Error()))
+		c.recordSMSError(ctx, realm, err)
 		result.obsResult = enobs.ResultError("FAILED_TO_SEND_SMS")
 		return err
 	}
 
 	return nil
 }
+
+// recordSMSError records the provider's error code for a failed send in the
+// realm's SMS error statistics. Twilio errors are skipped, since Twilio reports
+// them through the alerts webhook.
+func (c *Controller) recordSMSError(ctx context.Context, realm *database.Realm, err error) {
+	var tErr *sms.TwilioError
+	if errors.As(err, &tErr) {
+		return
+	}
+
+	code, ok := sms.ErrorCode(err)
+	if !ok {
+		return
+	}
+
+	if err := c.db.InsertSMSErrorStat(time.Now().UTC(), realm.ID, code); err != nil {
+		logger := logging.FromContext(ctx).Named("issueapi.recordSMSError")
+		logger.Errorw("failed to record sms error", "error_code", code, "error", err)
+	}
+}
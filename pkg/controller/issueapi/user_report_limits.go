@@ -0,0 +1,175 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package issueapi
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/exposure-notifications-server/pkg/logging"
+	enobs "github.com/google/exposure-notifications-server/pkg/observability"
+	"github.com/google/exposure-notifications-verification-server/pkg/api"
+	"github.com/google/exposure-notifications-verification-server/pkg/database"
+	"github.com/google/exposure-notifications-verification-server/pkg/digest"
+	"go.opencensus.io/stats"
+	"go.opencensus.io/tag"
+)
+
+// userReportLimitLayer is a single layer of user report rate limiting.
+type userReportLimitLayer struct {
+	// name is used in the limiter key and the metric tag.
+	name string
+
+	// value is the attribute being limited, such as the phone number. The layer
+	// is skipped if the value is empty.
+	value string
+
+	tokens   uint64
+	interval time.Duration
+
+	errorCode string
+	message   string
+}
+
+// CheckUserReportLimits applies the layered user report rate limits (per phone
+// number, IP address, realm, and API key) to the request. It returns nil if the
+// request is permitted, or the result to return to the caller otherwise. The
+// remote IP is the client address, typically from realip. The authorized app
+// may be nil, for example for the web-based user report flow.
+//
+// These limits are in addition to the realm's daily issuance quota and exist so
+// that each attribute can have its own threshold instead of sharing a single
+// generic limit.
+func (c *Controller) CheckUserReportLimits(ctx context.Context, remoteIP string, realm *database.Realm, authApp *database.AuthorizedApp, phone string) *IssueResult {
+	logger := logging.FromContext(ctx).Named("issueapi.CheckUserReportLimits")
+
+	cfg := c.config.IssueConfig().UserReportLimits
+
+	var appID string
+	if authApp != nil {
+		appID = fmt.Sprintf("%d", authApp.ID)
+	}
+
+	layers := []*userReportLimitLayer{
+		{
+			name:      "phone",
+			value:     normalizePhoneForLimit(phone),
+			tokens:    cfg.PhoneTokens,
+			interval:  cfg.PhoneInterval,
+			errorCode: api.ErrUserReportPhoneLimited,
+			message:   "too many reports for this phone number, please try again later",
+		},
+		{
+			name:      "ip",
+			value:     remoteIP,
+			tokens:    cfg.IPTokens,
+			interval:  cfg.IPInterval,
+			errorCode: api.ErrUserReportIPLimited,
+			message:   "too many reports from this network, please try again later",
+		},
+		{
+			name:      "realm",
+			value:     fmt.Sprintf("%d", realm.ID),
+			tokens:    cfg.RealmTokens,
+			interval:  cfg.RealmInterval,
+			errorCode: api.ErrUserReportRealmLimited,
+			message:   "too many reports for this health authority, please try again later",
+		},
+		{
+			name:      "app",
+			value:     appID,
+			tokens:    cfg.AppTokens,
+			interval:  cfg.AppInterval,
+			errorCode: api.ErrUserReportAppLimited,
+			message:   "too many reports from this application, please try again later",
+		},
+	}
+
+	for _, layer := range layers {
+		if layer.value == "" || layer.tokens == 0 || layer.interval <= 0 {
+			continue
+		}
+
+		ok, err := c.takeUserReportLimit(ctx, layer)
+		if err != nil {
+			logger.Errorw("failed to take from user report limiter", "layer", layer.name, "error", err)
+			return &IssueResult{
+				obsResult:   enobs.ResultError("FAILED_TO_TAKE_FROM_LIMITER"),
+				HTTPCode:    http.StatusInternalServerError,
+				ErrorReturn: api.Errorf("failed to issue code, please try again in a few seconds").WithCode(api.ErrInternal),
+			}
+		}
+		if !ok {
+			logger.Warnw("user report rate limited", "layer", layer.name, "realm", realm.ID)
+
+			ctx, _ := tag.New(ctx, tag.Upsert(layerTagKey, layer.name))
+			stats.Record(ctx, mUserReportLimited.M(1))
+
+			return &IssueResult{
+				obsResult:   enobs.ResultError("USER_REPORT_" + strings.ToUpper(layer.name) + "_LIMITED"),
+				HTTPCode:    http.StatusTooManyRequests,
+				ErrorReturn: api.Errorf(layer.message).WithCode(layer.errorCode),
+			}
+		}
+	}
+
+	return nil
+}
+
+// takeUserReportLimit takes a token for the layer.
+func (c *Controller) takeUserReportLimit(ctx context.Context, layer *userReportLimitLayer) (bool, error) {
+	dig, err := digest.HMAC(layer.value, c.config.GetRateLimitConfig().HMACKey)
+	if err != nil {
+		return false, fmt.Errorf("failed to digest %s: %w", layer.name, err)
+	}
+	key := fmt.Sprintf("userreport:%s:%s", layer.name, dig)
+	return c.takeConfiguredLimit(ctx, key, layer.tokens, layer.interval)
+}
+
+// takeConfiguredLimit takes a token from the key. The limiter store is shared
+// with other limits, so the threshold is configured on the key before the
+// first take (or when the configuration changes).
+func (c *Controller) takeConfiguredLimit(ctx context.Context, key string, tokens uint64, interval time.Duration) (bool, error) {
+	limit, _, err := c.limiter.Get(ctx, key)
+	if err != nil {
+		return false, fmt.Errorf("failed to get limit: %w", err)
+	}
+	if limit != tokens {
+		if err := c.limiter.Set(ctx, key, tokens, interval); err != nil {
+			return false, fmt.Errorf("failed to set limit: %w", err)
+		}
+	}
+
+	_, _, _, ok, err := c.limiter.Take(ctx, key)
+	if err != nil {
+		return false, fmt.Errorf("failed to take: %w", err)
+	}
+	return ok, nil
+}
+
+// normalizePhoneForLimit strips formatting from the phone number so that the
+// same number written differently shares a limit.
+func normalizePhoneForLimit(phone string) string {
+	var sb strings.Builder
+	for _, r := range phone {
+		if r >= '0' && r <= '9' {
+			sb.WriteRune(r)
+		}
+	}
+	return sb.String()
+}
@@ -0,0 +1,84 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package issueapi_test
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/google/exposure-notifications-verification-server/internal/envstest"
+	"github.com/google/exposure-notifications-verification-server/internal/project"
+	"github.com/google/exposure-notifications-verification-server/pkg/api"
+	"github.com/google/exposure-notifications-verification-server/pkg/controller/issueapi"
+	"github.com/google/exposure-notifications-verification-server/pkg/database"
+)
+
+func TestCheckUserReportLimits(t *testing.T) {
+	t.Parallel()
+
+	ctx := project.TestContext(t)
+	harness := envstest.NewServerConfig(t, testDatabaseInstance)
+
+	cfg := *harness.Config
+	cfg.Issue.UserReportLimits.PhoneTokens = 1
+	cfg.Issue.UserReportLimits.PhoneInterval = time.Hour
+	cfg.Issue.UserReportLimits.AppTokens = 2
+	cfg.Issue.UserReportLimits.AppInterval = time.Hour
+
+	c := issueapi.New(&cfg, harness.Database, harness.RateLimiter, harness.KeyManager, harness.Renderer)
+
+	realm := &database.Realm{}
+	realm.ID = 1
+	authApp := &database.AuthorizedApp{}
+	authApp.ID = 1
+
+	remoteIP := "192.0.2.1"
+
+	// The first report for a phone number is allowed, including when formatted
+	// differently.
+	if res := c.CheckUserReportLimits(ctx, remoteIP, realm, authApp, "+1 (500) 555-0000"); res != nil {
+		t.Fatalf("expected no limit, got %#v", res.ErrorReturn)
+	}
+
+	res := c.CheckUserReportLimits(ctx, remoteIP, realm, authApp, "+15005550000")
+	if res == nil {
+		t.Fatal("expected phone limit")
+	}
+	if got, want := res.HTTPCode, http.StatusTooManyRequests; got != want {
+		t.Errorf("expected %d to be %d", got, want)
+	}
+	if got, want := res.ErrorReturn.ErrorCode, api.ErrUserReportPhoneLimited; got != want {
+		t.Errorf("expected %q to be %q", got, want)
+	}
+
+	// A different phone number is still allowed, and uses the last app token.
+	if res := c.CheckUserReportLimits(ctx, remoteIP, realm, authApp, "+15005550001"); res != nil {
+		t.Fatalf("expected no limit, got %#v", res.ErrorReturn)
+	}
+
+	res = c.CheckUserReportLimits(ctx, remoteIP, realm, authApp, "+15005550002")
+	if res == nil {
+		t.Fatal("expected app limit")
+	}
+	if got, want := res.ErrorReturn.ErrorCode, api.ErrUserReportAppLimited; got != want {
+		t.Errorf("expected %q to be %q", got, want)
+	}
+
+	// Without an API key, the app layer does not apply.
+	if res := c.CheckUserReportLimits(ctx, remoteIP, realm, nil, "+15005550003"); res != nil {
+		t.Fatalf("expected no limit, got %#v", res.ErrorReturn)
+	}
+}
@@ -39,6 +39,29 @@ func (c *Controller) BuildVerificationCode(ctx context.Context, internalRequest
 
 	now := time.Now().UTC()
 	request := internalRequest.IssueRequest
+
+	// Fill in any values from the preset before validation.
+	if request.Preset != "" {
+		preset, err := realm.FindIssuancePreset(c.db, request.Preset)
+		if err != nil {
+			if database.IsNotFound(err) {
+				return nil, &IssueResult{
+					obsResult:   enobs.ResultError("INVALID_PRESET"),
+					HTTPCode:    http.StatusBadRequest,
+					ErrorReturn: api.Errorf("unknown preset %q", request.Preset).WithCode(api.ErrInvalidPreset),
+				}
+			}
+
+			logger.Errorw("failed to lookup issuance preset", "error", err)
+			return nil, &IssueResult{
+				obsResult:   enobs.ResultError("FAILED_TO_LOOKUP_PRESET"),
+				HTTPCode:    http.StatusInternalServerError,
+				ErrorReturn: api.InternalError(),
+			}
+		}
+		preset.ApplyTo(request, now)
+	}
+
 	vCode := &database.VerificationCode{
 		RealmID:           realm.ID,
 		IssuingExternalID: request.ExternalIssuerID,
@@ -62,6 +85,19 @@ func (c *Controller) BuildVerificationCode(ctx context.Context, internalRequest
 		}
 	}
 
+	// Validate the external case ID, such as a scanned lab accession number.
+	// User reports are submitted by the public, who do not have one.
+	if !vCode.IsUserReport() {
+		vCode.ExternalCaseID = database.NormalizeExternalCaseID(request.ExternalCaseID)
+		if err := realm.ValidateExternalCaseID(vCode.ExternalCaseID); err != nil {
+			return nil, &IssueResult{
+				obsResult:   enobs.ResultError("INVALID_EXTERNAL_CASE_ID"),
+				HTTPCode:    http.StatusBadRequest,
+				ErrorReturn: api.Error(err).WithCode(api.ErrInvalidExternalCaseID),
+			}
+		}
+	}
+
 	// Parse SymptomDate and TestDate
 	var result *IssueResult
 	vCode.SymptomDate, result = c.parseDate(request.SymptomDate, int(request.TZOffset), &onsetSettings)
@@ -75,13 +111,9 @@ func (c *Controller) BuildVerificationCode(ctx context.Context, internalRequest
 
 	// Parse and canonicalize phone numbers.
 	if request.Phone != "" {
-		canonicalPhone, err := project.CanonicalPhoneNumber(request.Phone, realm.SMSCountry)
-		if err != nil {
-			return nil, &IssueResult{
-				obsResult:   enobs.ResultError("INVALID_PHONE"),
-				HTTPCode:    http.StatusBadRequest,
-				ErrorReturn: api.Error(err).WithCode(api.ErrPhoneNumberInvalid),
-			}
+		canonicalPhone, result := c.validatePhone(ctx, realm, request.Phone)
+		if result != nil {
+			return nil, result
 		}
 		request.Phone = canonicalPhone
 	}
@@ -100,7 +132,7 @@ func (c *Controller) BuildVerificationCode(ctx context.Context, internalRequest
 			return nil, &IssueResult{
 				obsResult:   enobs.ResultError("INVALID_GENERATE_SMS_REQUEST"),
 				HTTPCode:    http.StatusBadRequest,
-				ErrorReturn: api.Error(err),
+				ErrorReturn: api.Error(err).WithCode(api.ErrMissingPhone),
 			}
 		}
 	}
@@ -128,9 +160,15 @@ func (c *Controller) BuildVerificationCode(ctx context.Context, internalRequest
 			return nil, &IssueResult{
 				obsResult:   enobs.ResultError("FAILED_TO_GET_SMS_PROVIDER"),
 				HTTPCode:    http.StatusBadRequest,
-				ErrorReturn: api.Error(err),
+				ErrorReturn: api.Error(err).WithCode(api.ErrSMSNotConfigured),
 			}
 		}
+
+		// Never send to numbers that opted out of the realm's messages. This is
+		// checked before the code is generated, so it does not consume quota.
+		if result := c.checkSMSSuppressed(ctx, realm, request.Phone); result != nil {
+			return nil, result
+		}
 	}
 
 	if request.Phone == "" || (smsProvider == nil && !request.OnlyGenerateSMS) {
@@ -201,3 +239,70 @@ func (c *Controller) BuildVerificationCode(ctx context.Context, internalRequest
 
 	return vCode, nil
 }
+
+// validatePhone canonicalizes the phone number and checks that it is in one of
+// the realm's allowed SMS countries.
+func (c *Controller) validatePhone(ctx context.Context, realm *database.Realm, phone string) (string, *IssueResult) {
+	logger := logging.FromContext(ctx).Named("issueapi.validatePhone")
+
+	canonicalPhone, err := project.CanonicalPhoneNumber(phone, realm.SMSCountry)
+	if err != nil {
+		return "", &IssueResult{
+			obsResult:   enobs.ResultError("INVALID_PHONE"),
+			HTTPCode:    http.StatusBadRequest,
+			ErrorReturn: api.Error(err).WithCode(api.ErrPhoneNumberInvalid),
+		}
+	}
+
+	// Reject destinations outside of the realm's allowed countries, unless the
+	// realm only wants to be warned.
+	region, allowed, err := realm.SMSDestinationAllowed(canonicalPhone)
+	if err != nil {
+		return "", &IssueResult{
+			obsResult:   enobs.ResultError("INVALID_PHONE"),
+			HTTPCode:    http.StatusBadRequest,
+			ErrorReturn: api.Error(err).WithCode(api.ErrPhoneNumberInvalid),
+		}
+	}
+	if !allowed {
+		if !realm.SMSAllowedCountriesWarnOnly {
+			return "", &IssueResult{
+				obsResult:   enobs.ResultError("PHONE_COUNTRY_NOT_ALLOWED"),
+				HTTPCode:    http.StatusBadRequest,
+				ErrorReturn: api.Errorf("phone number country %q is not allowed for this realm", region).WithCode(api.ErrPhoneCountryNotAllowed),
+			}
+		}
+		logger.Warnw("issuing to phone number outside of allowed countries",
+			"realm", realm.ID,
+			"region", region)
+	}
+
+	return canonicalPhone, nil
+}
+
+// checkSMSSuppressed returns an error result if the canonical phone number
+// opted out of SMS messages from the realm.
+func (c *Controller) checkSMSSuppressed(ctx context.Context, realm *database.Realm, phone string) *IssueResult {
+	logger := logging.FromContext(ctx).Named("issueapi.checkSMSSuppressed")
+
+	suppressed, err := realm.IsSMSSuppressed(c.db, phone)
+	if err != nil {
+		logger.Errorw("failed to check sms suppression", "error", err)
+		return &IssueResult{
+			obsResult:   enobs.ResultError("FAILED_TO_CHECK_SMS_SUPPRESSION"),
+			HTTPCode:    http.StatusInternalServerError,
+			ErrorReturn: api.Errorf("failed to check sms suppression").WithCode(api.ErrInternal),
+		}
+	}
+	if suppressed {
+		if err := c.db.RecordSMSSuppressed(realm.ID); err != nil {
+			logger.Errorw("failed to record sms suppression", "error", err)
+		}
+		return &IssueResult{
+			obsResult:   enobs.ResultError("SMS_SUPPRESSED"),
+			HTTPCode:    http.StatusBadRequest,
+			ErrorReturn: api.Errorf("phone number has opted out of sms messages from this realm").WithCode(api.ErrPhoneNumberSuppressed),
+		}
+	}
+	return nil
+}
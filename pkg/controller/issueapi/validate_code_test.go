@@ -46,6 +46,7 @@ func TestValidate(t *testing.T) {
 	realm.AllowGeneratedSMS = true
 	realm.CodeDuration = database.FromDuration(15 * time.Minute)
 	realm.LongCodeDuration = database.FromDuration(24 * time.Hour)
+	realm.ExternalCaseIDPattern = `LAB-[0-9]{4}`
 	if err := db.SaveRealm(realm, database.SystemTest); err != nil {
 		t.Fatalf("failed to update realm: %v", err)
 	}
@@ -63,6 +64,14 @@ func TestValidate(t *testing.T) {
 		t.Fatal(err)
 	}
 
+	offsetDays := uint(2)
+	presets := []*database.IssuancePreset{
+		{Name: "Lab confirmed", TestType: "confirmed", SymptomDateOffsetDays: &offsetDays},
+	}
+	if err := realm.SaveIssuancePresets(db, presets, database.SystemTest); err != nil {
+		t.Fatal(err)
+	}
+
 	authApp := &database.AuthorizedApp{
 		Model: gorm.Model{ID: 123},
 	}
@@ -172,6 +181,57 @@ func TestValidate(t *testing.T) {
 			responseErr:    api.ErrUUIDAlreadyExists,
 			httpStatusCode: http.StatusConflict,
 		},
+		{
+			name: "preset",
+			request: api.IssueCodeRequest{
+				Preset: "lab confirmed",
+			},
+			httpStatusCode: http.StatusOK,
+			vcValidation: func(t testing.TB, vCode *database.VerificationCode) {
+				t.Helper()
+
+				if got, want := vCode.TestType, "confirmed"; got != want {
+					t.Errorf("expected test type %q to be %q", got, want)
+				}
+				if vCode.SymptomDate == nil {
+					t.Errorf("expected symptom date from preset")
+				}
+			},
+		},
+		{
+			name: "unknown_preset",
+			request: api.IssueCodeRequest{
+				Preset: "nope",
+			},
+			responseErr:    api.ErrInvalidPreset,
+			httpStatusCode: http.StatusBadRequest,
+		},
+		{
+			name: "external_case_id",
+			request: api.IssueCodeRequest{
+				TestType:       "confirmed",
+				SymptomDate:    symptomDate,
+				ExternalCaseID: " LAB-1234\r\n",
+			},
+			httpStatusCode: http.StatusOK,
+			vcValidation: func(t testing.TB, vCode *database.VerificationCode) {
+				t.Helper()
+
+				if got, want := vCode.ExternalCaseID, "LAB-1234"; got != want {
+					t.Errorf("expected external case ID %q to be %q", got, want)
+				}
+			},
+		},
+		{
+			name: "invalid_external_case_id",
+			request: api.IssueCodeRequest{
+				TestType:       "confirmed",
+				SymptomDate:    symptomDate,
+				ExternalCaseID: "LAB-12",
+			},
+			responseErr:    api.ErrInvalidExternalCaseID,
+			httpStatusCode: http.StatusBadRequest,
+		},
 		{
 			name: "only_generate_sms",
 			request: api.IssueCodeRequest{
@@ -227,3 +287,87 @@ func TestValidate(t *testing.T) {
 		})
 	}
 }
+
+func TestValidate_SMSAllowedCountries(t *testing.T) {
+	t.Parallel()
+
+	ctx := project.TestContext(t)
+	harness := envstest.NewServerConfig(t, testDatabaseInstance)
+	db := harness.Database
+
+	realm, err := db.FindRealm(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	realm.AllowedTestTypes = database.TestTypeConfirmed
+	realm.AllowGeneratedSMS = true
+	realm.SMSAllowedCountries = []string{"us"}
+	if err := db.SaveRealm(realm, database.SystemTest); err != nil {
+		t.Fatalf("failed to update realm: %v", err)
+	}
+
+	c := issueapi.New(harness.Config, db, harness.RateLimiter, harness.KeyManager, nil)
+
+	symptomDate := time.Now().UTC().Add(-48 * time.Hour).Format(project.RFC3339Date)
+
+	cases := []struct {
+		name     string
+		phone    string
+		warnOnly bool
+		err      string
+	}{
+		{
+			name:  "allowed",
+			phone: "+12068675309",
+		},
+		{
+			name:  "not_allowed",
+			phone: "+442071838750",
+			err:   api.ErrPhoneCountryNotAllowed,
+		},
+		{
+			name:     "not_allowed_warn_only",
+			phone:    "+442071838750",
+			warnOnly: true,
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			realm := *realm
+			realm.SMSAllowedCountriesWarnOnly = tc.warnOnly
+
+			request := &api.IssueCodeRequest{
+				TestType:        "confirmed",
+				SymptomDate:     symptomDate,
+				OnlyGenerateSMS: true,
+				Phone:           tc.phone,
+			}
+
+			verCode, result := c.BuildVerificationCode(ctx, &issueapi.IssueRequestInternal{IssueRequest: request}, &realm)
+			if tc.err == "" {
+				if result != nil {
+					t.Fatalf("expected success, got %#v", result.IssueCodeResponse())
+				}
+				if verCode == nil {
+					t.Fatal("expected verification code")
+				}
+				return
+			}
+
+			if result == nil {
+				t.Fatal("expected error")
+			}
+			if got, want := result.HTTPCode, http.StatusBadRequest; got != want {
+				t.Errorf("expected %d to be %d", got, want)
+			}
+			if got, want := result.IssueCodeResponse().ErrorCode, tc.err; got != want {
+				t.Errorf("expected %q to be %q", got, want)
+			}
+		})
+	}
+}
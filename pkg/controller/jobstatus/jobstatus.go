@@ -0,0 +1,189 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package jobstatus records and reports the freshness of scheduled jobs such
+// as cleanup and rotation. A job which is silently failing or not being
+// scheduled shows up as a growing "seconds since success" value.
+package jobstatus
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/google/exposure-notifications-server/pkg/logging"
+	enobs "github.com/google/exposure-notifications-server/pkg/observability"
+	"github.com/google/exposure-notifications-verification-server/pkg/controller"
+	"github.com/google/exposure-notifications-verification-server/pkg/database"
+	"github.com/google/exposure-notifications-verification-server/pkg/render"
+
+	"go.opencensus.io/stats"
+	"go.opencensus.io/tag"
+)
+
+// Names of the jobs whose status is recorded.
+const (
+	JobAppSync                = "appsync"
+	JobBulkIssue              = "bulk-issue"
+	JobCallbacks              = "callbacks"
+	JobCleanup                = "cleanup"
+	JobConsistency            = "consistency"
+	JobDualWriteVerify        = "dual-write-verify"
+	JobModeler                = "modeler"
+	JobRealmKPI               = "realm-kpi"
+	JobStatsPuller            = "stats-puller"
+	JobStatsPusher            = "stats-pusher"
+	JobRotateCookieKeys       = "rotate-cookie-keys"
+	JobRotateSecrets          = "rotate-secrets"
+	JobRotateTokenKeys        = "rotate-token-signing-key"
+	JobRotateVerificationKeys = "rotate-verification-keys"
+	JobUserImports            = "user-imports"
+)
+
+// Record records the outcome of a run of the named job. If jobErr is nil, the
+// run is considered successful and processed is the number of items it
+// handled. Failures to persist the status are logged, but otherwise ignored,
+// so that they do not change the outcome of the job.
+func Record(ctx context.Context, db *database.Database, name string, processed int64, jobErr error) {
+	logger := logging.FromContext(ctx).Named("jobstatus.Record")
+
+	ctx, err := tag.New(ctx, tag.Upsert(jobTagKey, name))
+	if err != nil {
+		logger.Errorw("failed to create job context", "error", err)
+		return
+	}
+
+	previous, err := db.FindJobStatus(name)
+	if err != nil && !database.IsNotFound(err) {
+		logger.Errorw("failed to lookup job status", "job", name, "error", err)
+	}
+
+	if jobErr != nil {
+		stats.RecordWithTags(ctx, []tag.Mutator{enobs.ResultError("FAILED")}, mRuns.M(1))
+		if err := db.RecordJobFailure(name, jobErr); err != nil {
+			logger.Errorw("failed to record job status", "job", name, "error", err)
+		}
+	} else {
+		stats.RecordWithTags(ctx, []tag.Mutator{enobs.ResultOK}, mRuns.M(1))
+		stats.Record(ctx, mProcessed.M(processed))
+		if err := db.RecordJobSuccess(name, processed); err != nil {
+			logger.Errorw("failed to record job status", "job", name, "error", err)
+		}
+	}
+
+	if err := recordRunEvent(db, name, previous, jobErr); err != nil {
+		logger.Errorw("failed to record job run event", "job", name, "error", err)
+	}
+
+	RecordFreshness(ctx, db, name)
+}
+
+// recordRunEvent records failed runs, and the first successful run after a
+// failure, on the system event timeline. Other successful runs are not
+// recorded, since jobs run every few minutes.
+func recordRunEvent(db *database.Database, name string, previous *database.JobStatus, jobErr error) error {
+	event := &database.SystemEvent{
+		Kind:   database.SystemEventWorkerRun,
+		Source: name,
+	}
+
+	switch {
+	case jobErr != nil:
+		event.Failed = true
+		event.Message = fmt.Sprintf("failed: %s", jobErr)
+	case previous.Failing():
+		event.Message = "recovered"
+	default:
+		return nil
+	}
+	return db.RecordSystemEvent(event)
+}
+
+// RecordFreshness records the time since the named job last succeeded. Jobs
+// call this even when they skip a run (e.g. because it is too early), so the
+// metric keeps advancing while a job is stuck.
+func RecordFreshness(ctx context.Context, db *database.Database, name string) {
+	logger := logging.FromContext(ctx).Named("jobstatus.RecordFreshness")
+
+	status, err := db.FindJobStatus(name)
+	if err != nil {
+		if !database.IsNotFound(err) {
+			logger.Errorw("failed to lookup job status", "job", name, "error", err)
+		}
+		return
+	}
+	if status.LastSuccessAt == nil {
+		return
+	}
+
+	ctx, err = tag.New(ctx, tag.Upsert(jobTagKey, name))
+	if err != nil {
+		logger.Errorw("failed to create job context", "error", err)
+		return
+	}
+	stats.Record(ctx, mSinceSuccess.M(status.SinceLastSuccess(time.Now()).Seconds()))
+}
+
+// jobStatusResponse is the JSON representation of a single job's status.
+type jobStatusResponse struct {
+	Name                string     `json:"name"`
+	LastRunAt           *time.Time `json:"last_run_at,omitempty"`
+	LastSuccessAt       *time.Time `json:"last_success_at,omitempty"`
+	SecondsSinceSuccess int64      `json:"seconds_since_success,omitempty"`
+	LastProcessed       int64      `json:"last_processed"`
+	LastFailureAt       *time.Time `json:"last_failure_at,omitempty"`
+	LastError           string     `json:"last_error,omitempty"`
+}
+
+// HandleStatus renders the status of the named jobs as JSON. Jobs which have
+// never run are included with only their name.
+func HandleStatus(db *database.Database, h *render.Renderer, names ...string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		now := time.Now()
+
+		resp := make([]*jobStatusResponse, 0, len(names))
+		for _, name := range names {
+			status, err := db.FindJobStatus(name)
+			if err != nil {
+				if database.IsNotFound(err) {
+					resp = append(resp, &jobStatusResponse{Name: name})
+					continue
+				}
+				controller.InternalError(w, r, h, err)
+				return
+			}
+
+			RecordFreshness(ctx, db, name)
+
+			var since int64
+			if status.LastSuccessAt != nil {
+				since = int64(status.SinceLastSuccess(now).Seconds())
+			}
+
+			resp = append(resp, &jobStatusResponse{
+				Name:                status.Name,
+				LastRunAt:           status.LastRunAt,
+				LastSuccessAt:       status.LastSuccessAt,
+				SecondsSinceSuccess: since,
+				LastProcessed:       status.LastProcessed,
+				LastFailureAt:       status.LastFailureAt,
+				LastError:           status.LastError,
+			})
+		}
+
+		h.RenderJSON(w, http.StatusOK, map[string]interface{}{"jobs": resp})
+	})
+}
@@ -0,0 +1,61 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package jobstatus
+
+import (
+	enobs "github.com/google/exposure-notifications-server/pkg/observability"
+	"github.com/google/exposure-notifications-verification-server/pkg/observability"
+
+	"go.opencensus.io/stats"
+	"go.opencensus.io/stats/view"
+	"go.opencensus.io/tag"
+)
+
+const metricPrefix = observability.MetricRoot + "/jobs"
+
+var (
+	mProcessed    = stats.Int64(metricPrefix+"/processed", "items processed by a job run", stats.UnitDimensionless)
+	mRuns         = stats.Int64(metricPrefix+"/runs", "job runs", stats.UnitDimensionless)
+	mSinceSuccess = stats.Float64(metricPrefix+"/seconds_since_success", "seconds since the last successful job run", stats.UnitSeconds)
+
+	// jobTagKey is the name of the job.
+	jobTagKey = tag.MustNewKey("job")
+)
+
+func init() {
+	enobs.CollectViews([]*view.View{
+		{
+			Name:        metricPrefix + "/processed",
+			Description: "Number of items processed by successful job runs",
+			TagKeys:     append(observability.CommonTagKeys(), jobTagKey),
+			Measure:     mProcessed,
+			Aggregation: view.Sum(),
+		},
+		{
+			Name:        metricPrefix + "/runs",
+			Description: "Number of job runs, by result",
+			TagKeys:     append(observability.CommonTagKeys(), jobTagKey, enobs.ResultTagKey),
+			Measure:     mRuns,
+			Aggregation: view.Count(),
+		},
+		{
+			Name:        metricPrefix + "/seconds_since_success",
+			Description: "Seconds since the last successful job run",
+			TagKeys:     append(observability.CommonTagKeys(), jobTagKey),
+			Measure:     mSinceSuccess,
+			Aggregation: view.LastValue(),
+		},
+	}...)
+}
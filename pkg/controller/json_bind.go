@@ -26,26 +26,49 @@ import (
 const (
 	// Max request size of 64KB. None of the current API requests for this
 	// server are near that limit. Prevents us from unnecessarily parsing JSON
-	// payloads that are much large than we anticipate.
+	// payloads that are much large than we anticipate. Routes that accept larger
+	// payloads can raise the limit with WithMaxBodyBytes.
 	maxBodyBytes = 64_000
 )
 
+// ErrBodyTooLarge is returned when the request body exceeds the maximum
+// allowed size. Callers should respond with a 413.
+var ErrBodyTooLarge = errors.New("request body too large")
+
 // BindJSON provides a common implementation of JSON unmarshaling with well defined error handling.
 func BindJSON(w http.ResponseWriter, r *http.Request, data interface{}) error {
+	return BindJSONStream(w, r, func(d *json.Decoder) error {
+		return d.Decode(&data)
+	})
+}
+
+// BindJSONStream provides the same content type checks, size limits, and error
+// handling as BindJSON, but hands the decoder to fn. This allows callers to
+// decode large payloads incrementally and abort early instead of buffering the
+// entire request. The decoder disallows unknown fields.
+func BindJSONStream(w http.ResponseWriter, r *http.Request, fn func(d *json.Decoder) error) error {
 	if !IsJSONContentType(r) {
 		return fmt.Errorf("content-type is not application/json")
 	}
 
+	limit := MaxBodyBytesFromContext(r.Context())
+	if limit <= 0 {
+		limit = maxBodyBytes
+	}
+
 	defer r.Body.Close()
-	r.Body = http.MaxBytesReader(w, r.Body, maxBodyBytes)
+	r.Body = http.MaxBytesReader(w, r.Body, limit)
 
 	d := json.NewDecoder(r.Body)
 	d.DisallowUnknownFields()
 
-	if err := d.Decode(&data); err != nil {
+	if err := fn(d); err != nil {
 		var syntaxErr *json.SyntaxError
 		var unmarshalError *json.UnmarshalTypeError
+		var maxBytesErr *http.MaxBytesError
 		switch {
+		case errors.As(err, &maxBytesErr):
+			return fmt.Errorf("%w: maximum size is %d bytes", ErrBodyTooLarge, limit)
 		case errors.As(err, &syntaxErr):
 			return fmt.Errorf("malformed json at position %d", syntaxErr.Offset)
 		case errors.Is(err, io.ErrUnexpectedEOF):
@@ -57,8 +80,6 @@ func BindJSON(w http.ResponseWriter, r *http.Request, data interface{}) error {
 			return fmt.Errorf("unknown field %q", fieldName)
 		case errors.Is(err, io.EOF):
 			return fmt.Errorf("body must not be empty")
-		case err.Error() == "http: request body too large":
-			return err
 		default:
 			return fmt.Errorf("failed to decode json: %w", err)
 		}
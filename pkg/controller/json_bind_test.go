@@ -0,0 +1,122 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestBindJSON(t *testing.T) {
+	t.Parallel()
+
+	type data struct {
+		Name string `json:"name"`
+	}
+
+	cases := []struct {
+		name  string
+		body  string
+		limit int64
+		err   string
+	}{
+		{
+			name: "valid",
+			body: `{"name":"foo"}`,
+		},
+		{
+			name: "empty",
+			body: ``,
+			err:  "body must not be empty",
+		},
+		{
+			name: "unknown_field",
+			body: `{"nope":"foo"}`,
+			err:  "unknown field",
+		},
+		{
+			name: "multiple_objects",
+			body: `{"name":"foo"}{"name":"bar"}`,
+			err:  "only one JSON object",
+		},
+		{
+			name:  "within_limit",
+			body:  `{"name":"foo"}`,
+			limit: 64,
+		},
+		{
+			name:  "too_large",
+			body:  `{"name":"` + strings.Repeat("a", 128) + `"}`,
+			limit: 64,
+			err:   "request body too large",
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			ctx := context.Background()
+			if tc.limit > 0 {
+				ctx = WithMaxBodyBytes(ctx, tc.limit)
+			}
+
+			r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(tc.body))
+			r = r.Clone(ctx)
+			r.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+
+			var d data
+			err := BindJSON(w, r, &d)
+			if tc.err == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got, want := d.Name, "foo"; got != want {
+					t.Errorf("expected %q to be %q", got, want)
+				}
+				return
+			}
+
+			if err == nil {
+				t.Fatal("expected error")
+			}
+			if got, want := err.Error(), tc.err; !strings.Contains(got, want) {
+				t.Errorf("expected %q to contain %q", got, want)
+			}
+		})
+	}
+
+	t.Run("too_large_is_typed", func(t *testing.T) {
+		t.Parallel()
+
+		ctx := WithMaxBodyBytes(context.Background(), 4)
+		r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"name":"foo"}`))
+		r = r.Clone(ctx)
+		r.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+
+		var d data
+		if err := BindJSON(w, r, &d); !errors.Is(err, ErrBodyTooLarge) {
+			t.Errorf("expected %v to be %v", err, ErrBodyTooLarge)
+		}
+	})
+}
@@ -0,0 +1,108 @@
+// Copyright 2020 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// JSONFieldDecoders maps the JSON keys of an object to functions that decode
+// the value for that key. Keys are matched case-insensitively, like
+// encoding/json does for struct fields.
+type JSONFieldDecoders map[string]func(d *json.Decoder) error
+
+// DecodeJSONObject decodes a JSON object from d, handing the value of each key
+// to the matching function in fields. Values are read one at a time, so large
+// objects are never buffered in full. Unknown keys are rejected, matching the
+// behavior of BindJSON.
+func DecodeJSONObject(d *json.Decoder, fields JSONFieldDecoders) error {
+	if err := expectJSONDelim(d, '{'); err != nil {
+		return err
+	}
+
+	for d.More() {
+		tok, err := d.Token()
+		if err != nil {
+			return err
+		}
+		key, ok := tok.(string)
+		if !ok {
+			return fmt.Errorf("expected object key, got %v", tok)
+		}
+
+		fn := lookupJSONField(fields, key)
+		if fn == nil {
+			return fmt.Errorf("json: unknown field %q", key)
+		}
+		if err := fn(d); err != nil {
+			return err
+		}
+	}
+
+	return expectJSONDelim(d, '}')
+}
+
+// DecodeJSONArray decodes a JSON array from d, calling fn once per element
+// with the decoder positioned at that element. A null value is treated as an
+// empty array.
+func DecodeJSONArray(d *json.Decoder, fn func(d *json.Decoder) error) error {
+	tok, err := d.Token()
+	if err != nil {
+		return err
+	}
+	if tok == nil {
+		return nil
+	}
+	if delim, ok := tok.(json.Delim); !ok || delim != '[' {
+		return fmt.Errorf("malformed json, expected array")
+	}
+
+	for d.More() {
+		if err := fn(d); err != nil {
+			return err
+		}
+	}
+
+	return expectJSONDelim(d, ']')
+}
+
+// lookupJSONField returns the decoder for key, preferring an exact match and
+// falling back to a case-insensitive one.
+func lookupJSONField(fields JSONFieldDecoders, key string) func(d *json.Decoder) error {
+	if fn, ok := fields[key]; ok {
+		return fn
+	}
+	for k, fn := range fields {
+		if strings.EqualFold(k, key) {
+			return fn
+		}
+	}
+	return nil
+}
+
+// expectJSONDelim reads the next token from d and returns an error if it is
+// not the given delimiter.
+func expectJSONDelim(d *json.Decoder, want json.Delim) error {
+	tok, err := d.Token()
+	if err != nil {
+		return err
+	}
+	if delim, ok := tok.(json.Delim); !ok || delim != want {
+		return fmt.Errorf("malformed json, expected %q", want)
+	}
+	return nil
+}
@@ -0,0 +1,113 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestDecodeJSONObject(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name  string
+		body  string
+		names []string
+		flag  bool
+		err   string
+	}{
+		{
+			name:  "valid",
+			body:  `{"names":["a","b"],"flag":true}`,
+			names: []string{"a", "b"},
+			flag:  true,
+		},
+		{
+			name:  "case_insensitive",
+			body:  `{"NAMES":["a"],"Flag":true}`,
+			names: []string{"a"},
+			flag:  true,
+		},
+		{
+			name: "null_array",
+			body: `{"names":null}`,
+		},
+		{
+			name: "unknown_field",
+			body: `{"nope":1}`,
+			err:  "unknown field",
+		},
+		{
+			name: "not_object",
+			body: `[]`,
+			err:  "malformed json",
+		},
+		{
+			name: "not_array",
+			body: `{"names":"a"}`,
+			err:  "expected array",
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			var names []string
+			var flag bool
+
+			d := json.NewDecoder(strings.NewReader(tc.body))
+			err := DecodeJSONObject(d, JSONFieldDecoders{
+				"names": func(d *json.Decoder) error {
+					return DecodeJSONArray(d, func(d *json.Decoder) error {
+						var s string
+						if err := d.Decode(&s); err != nil {
+							return err
+						}
+						names = append(names, s)
+						return nil
+					})
+				},
+				"flag": func(d *json.Decoder) error {
+					return d.Decode(&flag)
+				},
+			})
+
+			if tc.err != "" {
+				if err == nil {
+					t.Fatal("expected error")
+				}
+				if got, want := err.Error(), tc.err; !strings.Contains(got, want) {
+					t.Errorf("expected %q to contain %q", got, want)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got, want := strings.Join(names, ","), strings.Join(tc.names, ","); got != want {
+				t.Errorf("expected %q to be %q", got, want)
+			}
+			if got, want := flag, tc.flag; got != want {
+				t.Errorf("expected %t to be %t", got, want)
+			}
+		})
+	}
+}
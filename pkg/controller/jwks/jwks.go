@@ -28,6 +28,7 @@ import (
 	"github.com/google/exposure-notifications-verification-server/pkg/cache"
 	"github.com/google/exposure-notifications-verification-server/pkg/controller"
 	"github.com/google/exposure-notifications-verification-server/pkg/database"
+	"github.com/google/exposure-notifications-verification-server/pkg/jwthelper"
 	"github.com/google/exposure-notifications-verification-server/pkg/keyutils"
 	"github.com/google/exposure-notifications-verification-server/pkg/render"
 	"github.com/gorilla/mux"
@@ -94,7 +95,12 @@ func (c *Controller) HandleIndex() http.Handler {
 
 			encoded := make([]*jwk.JWK, len(keys))
 			for i, key := range keys {
-				pk, err := c.keyCache.GetPublicKey(ctx, key.KeyID, c.db.KeyManager())
+				pk, err := c.keyCache.GetManagedPublicKey(ctx, key.KeyID, key.PublicKey, c.db.KeyManager())
+				if err != nil {
+					return nil, err
+				}
+
+				alg, err := jwthelper.AlgorithmForKey(pk)
 				if err != nil {
 					return nil, err
 				}
@@ -102,6 +108,8 @@ func (c *Controller) HandleIndex() http.Handler {
 				// Encode it, and sent it off.
 				spec := jwk.NewSpec(pk)
 				spec.KeyID = key.GetKID()
+				spec.Algorithm = alg
+				spec.Use = "sig"
 				encoded[i], err = spec.ToJWK()
 				if err != nil {
 					return nil, err
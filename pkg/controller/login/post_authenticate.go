@@ -18,7 +18,9 @@ package login
 import (
 	"net/http"
 
+	"github.com/google/exposure-notifications-server/pkg/logging"
 	"github.com/google/exposure-notifications-verification-server/pkg/controller"
+	"github.com/google/exposure-notifications-verification-server/pkg/database"
 	"github.com/google/exposure-notifications-verification-server/pkg/rbac"
 )
 
@@ -38,6 +40,13 @@ func (c *Controller) HandlePostAuthenticate() http.Handler {
 			return
 		}
 
+		// Realm admins of new realms land on the onboarding checklist until the
+		// realm is set up.
+		if currentMembership.Can(rbac.SettingsWrite) && c.needsOnboarding(r, currentMembership.Realm) {
+			http.Redirect(w, r, "/realm/checklist", http.StatusSeeOther)
+			return
+		}
+
 		switch {
 		case currentMembership.Can(rbac.CodeIssue):
 			http.Redirect(w, r, "/codes/issue", http.StatusSeeOther)
@@ -62,3 +71,21 @@ func (c *Controller) HandlePostAuthenticate() http.Handler {
 		}
 	})
 }
+
+// needsOnboarding returns true if the realm has not issued any codes and still
+// has incomplete onboarding steps. Errors are logged and treated as not needing
+// onboarding so they never block login.
+func (c *Controller) needsOnboarding(r *http.Request, realm *database.Realm) bool {
+	logger := logging.FromContext(r.Context()).Named("login.needsOnboarding")
+
+	checklist, err := realm.Checklist(c.db)
+	if err != nil {
+		logger.Errorw("failed to build realm checklist", "error", err)
+		return false
+	}
+
+	if item := checklist.Item(database.RealmChecklistCodeIssued); item != nil && item.Done {
+		return false
+	}
+	return !checklist.Complete()
+}
@@ -59,6 +59,11 @@ func TestHandlePostAuthenticate(t *testing.T) {
 			perms: rbac.CodeIssue | rbac.SettingsRead,
 			exp:   "/codes/issue",
 		},
+		{
+			name:  "settings_write_onboarding",
+			perms: rbac.CodeIssue | rbac.SettingsWrite,
+			exp:   "/realm/checklist",
+		},
 		{
 			name:  "code_issue_fallback",
 			perms: 0,
@@ -0,0 +1,129 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package login
+
+import (
+	"net/http"
+
+	"github.com/google/exposure-notifications-verification-server/pkg/api"
+	"github.com/google/exposure-notifications-verification-server/pkg/controller"
+	"github.com/google/exposure-notifications-verification-server/pkg/database"
+	"github.com/google/exposure-notifications-verification-server/pkg/rbac"
+)
+
+// HandleListRealms lists the realms of which the current user is a member,
+// along with their effective permissions and last activity.
+func (c *Controller) HandleListRealms() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		session := controller.SessionFromContext(ctx)
+		if session == nil {
+			controller.MissingSession(w, r, c.h)
+			return
+		}
+
+		currentUser := controller.UserFromContext(ctx)
+		if currentUser == nil {
+			controller.MissingUser(w, r, c.h)
+			return
+		}
+		memberships := controller.MembershipsFromContext(ctx)
+
+		c.h.RenderJSON(w, http.StatusOK, &api.UserRealmsResponse{
+			Realms: buildUserRealms(currentUser, memberships, controller.RealmIDFromSession(session)),
+		})
+	})
+}
+
+// HandleSetDefaultRealm sets or clears the current user's default realm.
+func (c *Controller) HandleSetDefaultRealm() http.Handler {
+	type FormData struct {
+		RealmID uint `form:"realm"`
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		session := controller.SessionFromContext(ctx)
+		if session == nil {
+			controller.MissingSession(w, r, c.h)
+			return
+		}
+		flash := controller.Flash(session)
+
+		currentUser := controller.UserFromContext(ctx)
+		if currentUser == nil {
+			controller.MissingUser(w, r, c.h)
+			return
+		}
+
+		var form FormData
+		if err := controller.BindForm(w, r, &form); err != nil {
+			flash.Error("Failed to process form: %v", err)
+			http.Redirect(w, r, "/login/select-realm", http.StatusSeeOther)
+			return
+		}
+
+		if err := c.db.SetDefaultRealm(currentUser, form.RealmID, currentUser); err != nil {
+			if database.IsNotFound(err) {
+				flash.Error("Invalid realm selection.")
+				http.Redirect(w, r, "/login/select-realm", http.StatusSeeOther)
+				return
+			}
+
+			controller.InternalError(w, r, c.h, err)
+			return
+		}
+
+		if form.RealmID == 0 {
+			flash.Alert("Cleared default realm")
+		} else {
+			flash.Alert("Updated default realm")
+		}
+		http.Redirect(w, r, "/login/select-realm", http.StatusSeeOther)
+	})
+}
+
+// buildUserRealms converts the memberships into their API representation.
+func buildUserRealms(user *database.User, memberships []*database.Membership, currentRealmID uint) []*api.UserRealm {
+	realms := make([]*api.UserRealm, 0, len(memberships))
+	for _, m := range memberships {
+		if m.Realm == nil {
+			continue
+		}
+
+		var lastActiveAt int64
+		if m.LastActiveAt != nil {
+			lastActiveAt = m.LastActiveAt.UTC().Unix()
+		}
+
+		realms = append(realms, &api.UserRealm{
+			ID:           m.Realm.ID,
+			Name:         m.Realm.Name,
+			RegionCode:   m.Realm.RegionCode,
+			Permissions:  rbac.PermissionNames(rbac.AddImplied(m.Permissions)),
+			LastActiveAt: lastActiveAt,
+			Default:      isDefaultRealm(user, m.Realm.ID),
+			Current:      m.Realm.ID == currentRealmID,
+		})
+	}
+	return realms
+}
+
+// isDefaultRealm returns true if the given realm is the user's default realm.
+func isDefaultRealm(user *database.User, realmID uint) bool {
+	return user != nil && user.DefaultRealmID != nil && *user.DefaultRealmID == realmID
+}
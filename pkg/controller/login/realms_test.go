@@ -0,0 +1,109 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package login_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/google/exposure-notifications-verification-server/internal/envstest"
+	"github.com/google/exposure-notifications-verification-server/internal/project"
+	"github.com/google/exposure-notifications-verification-server/pkg/api"
+	"github.com/google/exposure-notifications-verification-server/pkg/controller"
+	"github.com/google/exposure-notifications-verification-server/pkg/controller/login"
+	"github.com/google/exposure-notifications-verification-server/pkg/database"
+	"github.com/google/exposure-notifications-verification-server/pkg/rbac"
+	"github.com/gorilla/sessions"
+	"github.com/jinzhu/gorm"
+)
+
+func TestHandleListRealms(t *testing.T) {
+	t.Parallel()
+
+	harness := envstest.NewServerConfig(t, testDatabaseInstance)
+
+	c := login.New(harness.AuthProvider, harness.Cacher, harness.Config, harness.Database, harness.Renderer)
+	handler := harness.WithCommonMiddlewares(c.HandleListRealms())
+
+	session := &sessions.Session{
+		Values: make(map[interface{}]interface{}),
+	}
+	defaultRealmID := uint(2)
+	user := &database.User{DefaultRealmID: &defaultRealmID}
+	lastActiveAt := time.Unix(1600000000, 0)
+
+	realm1 := &database.Realm{Model: gorm.Model{ID: 1}, Name: "Realm 1"}
+	realm2 := &database.Realm{Model: gorm.Model{ID: 2}, Name: "Realm 2"}
+	controller.StoreSessionRealm(session, realm1)
+
+	ctx := project.TestContext(t)
+	ctx = controller.WithSession(ctx, session)
+	ctx = controller.WithUser(ctx, user)
+	ctx = controller.WithMemberships(ctx, []*database.Membership{
+		{
+			User:         user,
+			Realm:        realm1,
+			Permissions:  rbac.CodeIssue,
+			LastActiveAt: &lastActiveAt,
+		},
+		{
+			User:        user,
+			Realm:       realm2,
+			Permissions: rbac.SettingsWrite,
+		},
+	})
+
+	w, r := envstest.BuildJSONRequest(ctx, t, http.MethodGet, "/", nil)
+	handler.ServeHTTP(w, r)
+
+	if got, want := w.Code, http.StatusOK; got != want {
+		t.Fatalf("expected %d to be %d: %s", got, want, w.Body.String())
+	}
+
+	var resp api.UserRealmsResponse
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(resp.Realms), 2; got != want {
+		t.Fatalf("expected %d realms, got %d", want, got)
+	}
+
+	first, second := resp.Realms[0], resp.Realms[1]
+	if !first.Current || first.Default {
+		t.Errorf("expected first realm to be current and not default: %#v", first)
+	}
+	if got, want := first.LastActiveAt, lastActiveAt.Unix(); got != want {
+		t.Errorf("expected last active %d to be %d", got, want)
+	}
+	if second.Current || !second.Default {
+		t.Errorf("expected second realm to be default and not current: %#v", second)
+	}
+	if got, want := second.LastActiveAt, int64(0); got != want {
+		t.Errorf("expected last active %d to be %d", got, want)
+	}
+
+	// SettingsWrite implies SettingsRead.
+	var hasSettingsRead bool
+	for _, p := range second.Permissions {
+		if p == rbac.SettingsRead.String() {
+			hasSettingsRead = true
+		}
+	}
+	if !hasSettingsRead {
+		t.Errorf("expected %v to include implied %s", second.Permissions, rbac.SettingsRead)
+	}
+}
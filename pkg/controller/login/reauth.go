@@ -17,6 +17,8 @@ package login
 
 import (
 	"net/http"
+	"net/url"
+	"strings"
 
 	"github.com/google/exposure-notifications-verification-server/pkg/controller"
 )
@@ -26,10 +28,27 @@ var allowedRedirects = map[string]struct{}{
 }
 
 // redirectAllowed ensures that someone trying to force a re-auth
-// is directing the user to a known page that requres re-auth.
+// is directing the user to a known page that requres re-auth, or to a path on
+// this server (used by middleware.RequireRecentAuth).
 func redirectAllowed(r string) bool {
-	_, ok := allowedRedirects[r]
-	return ok
+	if _, ok := allowedRedirects[r]; ok {
+		return true
+	}
+	return isLocalPath(r)
+}
+
+// isLocalPath returns true if r is an absolute path on this server, without a
+// scheme or host.
+func isLocalPath(r string) bool {
+	if !strings.HasPrefix(r, "/") || strings.HasPrefix(r, "//") || strings.HasPrefix(r, "/\\") {
+		return false
+	}
+
+	u, err := url.Parse(r)
+	if err != nil {
+		return false
+	}
+	return u.Scheme == "" && u.Host == ""
 }
 
 func (c *Controller) HandleReauth() http.Handler {
@@ -50,6 +50,21 @@ func TestHandleReauth_ShowLogin(t *testing.T) {
 			path:   "/?redir=google.com",
 			status: http.StatusSeeOther,
 		},
+		{
+			name:   "local_path",
+			path:   "/?redir=%2Frealm%2Fkeys",
+			status: http.StatusOK,
+		},
+		{
+			name:   "protocol_relative",
+			path:   "/?redir=%2F%2Fgoogle.com",
+			status: http.StatusSeeOther,
+		},
+		{
+			name:   "absolute_url",
+			path:   "/?redir=https%3A%2F%2Fgoogle.com%2F",
+			status: http.StatusSeeOther,
+		},
 		{
 			name:   "no_reauth",
 			path:   "/",
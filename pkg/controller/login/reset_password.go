@@ -100,7 +100,7 @@ func (c *Controller) HandleSubmitResetPassword() http.Handler {
 
 		// Build the emailer.
 		if membership != nil {
-			resetComposer, err = controller.SendPasswordResetEmailFunc(ctx, c.db, c.h, user.Email, membership.Realm)
+			resetComposer, err = controller.SendPasswordResetEmailFunc(ctx, c.db, c.h, user.Email, user.PreferredLanguage, membership.Realm)
 			if err != nil {
 				controller.InternalError(w, r, c.h, err)
 				return
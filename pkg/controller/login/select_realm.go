@@ -18,8 +18,11 @@ import (
 	"context"
 	"net/http"
 
+	"github.com/google/exposure-notifications-server/pkg/logging"
 	"github.com/google/exposure-notifications-verification-server/pkg/controller"
 	"github.com/google/exposure-notifications-verification-server/pkg/database"
+	"github.com/google/exposure-notifications-verification-server/pkg/rbac"
+	"github.com/gorilla/sessions"
 )
 
 func (c *Controller) HandleSelectRealm() http.Handler {
@@ -48,7 +51,7 @@ func (c *Controller) HandleSelectRealm() http.Handler {
 		case 0:
 			// If the user is a member of zero realms, it's possible they are an
 			// admin. If so, redirect them to the admin page.
-			if currentUser.SystemAdmin {
+			if currentUser.IsSystemAdmin() {
 				http.Redirect(w, r, "/admin", http.StatusSeeOther)
 				return
 			}
@@ -73,7 +76,7 @@ func (c *Controller) HandleSelectRealm() http.Handler {
 			// that they successfully logged in.
 			flash.Clear()
 
-			controller.StoreSessionRealm(session, realm)
+			c.selectMembership(ctx, session, memberships[0])
 			http.Redirect(w, r, "/login/post-authenticate", http.StatusSeeOther)
 			return
 		default:
@@ -82,7 +85,21 @@ func (c *Controller) HandleSelectRealm() http.Handler {
 
 		// Requested form, stop processing.
 		if r.Method == http.MethodGet {
-			c.renderSelect(ctx, w, memberships)
+			// If the user has not yet selected a realm in this session and has a
+			// default realm, select it automatically. Users that are switching realms
+			// already have a realm in the session and are shown the selector.
+			if controller.RealmIDFromSession(session) == 0 {
+				for _, membership := range memberships {
+					if membership.Realm != nil && isDefaultRealm(currentUser, membership.Realm.ID) {
+						flash.Clear()
+						c.selectMembership(ctx, session, membership)
+						http.Redirect(w, r, "/login/post-authenticate", http.StatusSeeOther)
+						return
+					}
+				}
+			}
+
+			c.renderSelect(ctx, w, currentUser, session, memberships)
 			return
 		}
 
@@ -90,7 +107,7 @@ func (c *Controller) HandleSelectRealm() http.Handler {
 		if err := controller.BindForm(w, r, &form); err != nil {
 			flash.Error(err.Error())
 			w.WriteHeader(http.StatusUnprocessableEntity)
-			c.renderSelect(ctx, w, memberships)
+			c.renderSelect(ctx, w, currentUser, session, memberships)
 			return
 		}
 
@@ -98,7 +115,7 @@ func (c *Controller) HandleSelectRealm() http.Handler {
 		if err != nil {
 			if database.IsNotFound(err) {
 				flash.Error("Invalid realm selection.")
-				c.renderSelect(ctx, w, memberships)
+				c.renderSelect(ctx, w, currentUser, session, memberships)
 				return
 			}
 
@@ -106,15 +123,52 @@ func (c *Controller) HandleSelectRealm() http.Handler {
 			return
 		}
 
-		controller.StoreSessionRealm(session, membership.Realm)
+		c.selectMembership(ctx, session, membership)
 		http.Redirect(w, r, "/login/post-authenticate", http.StatusSeeOther)
 	})
 }
 
+// selectMembership stores the membership's realm in the session and records
+// the realm as active. Failing to record the activity is logged, but does not
+// prevent the realm selection.
+func (c *Controller) selectMembership(ctx context.Context, session *sessions.Session, membership *database.Membership) {
+	controller.StoreSessionRealm(session, membership.Realm)
+
+	if err := c.db.TouchMembershipLastActive(membership); err != nil {
+		logger := logging.FromContext(ctx).Named("login.selectMembership")
+		logger.Errorw("failed to update membership last active time", "error", err)
+	}
+}
+
+// realmOption is a single entry in the realm selector.
+type realmOption struct {
+	Membership  *database.Membership
+	Permissions []string
+	Default     bool
+	Current     bool
+}
+
 // renderSelect renders the realm selection page.
-func (c *Controller) renderSelect(ctx context.Context, w http.ResponseWriter, memberships []*database.Membership) {
+func (c *Controller) renderSelect(ctx context.Context, w http.ResponseWriter, user *database.User, session *sessions.Session, memberships []*database.Membership) {
+	currentRealmID := controller.RealmIDFromSession(session)
+
+	options := make([]*realmOption, 0, len(memberships))
+	for _, membership := range memberships {
+		if membership.Realm == nil {
+			continue
+		}
+
+		options = append(options, &realmOption{
+			Membership:  membership,
+			Permissions: rbac.PermissionNames(rbac.AddImplied(membership.Permissions)),
+			Default:     isDefaultRealm(user, membership.Realm.ID),
+			Current:     membership.Realm.ID == currentRealmID,
+		})
+	}
+
 	m := controller.TemplateMapFromContext(ctx)
 	m.Title("Realm selector")
 	m["memberships"] = memberships
+	m["realmOptions"] = options
 	c.h.RenderHTML(w, "login/select-realm", m)
 }
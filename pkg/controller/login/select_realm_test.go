@@ -25,6 +25,7 @@ import (
 	"github.com/google/exposure-notifications-verification-server/pkg/controller/login"
 	"github.com/google/exposure-notifications-verification-server/pkg/database"
 	"github.com/gorilla/sessions"
+	"github.com/jinzhu/gorm"
 )
 
 func TestHandleSelectRealm_ShowSelectRealm(t *testing.T) {
@@ -161,4 +162,46 @@ func TestHandleSelectRealm_ShowSelectRealm(t *testing.T) {
 			t.Errorf("expected %q to contain %q", got, want)
 		}
 	})
+
+	t.Run("multi_realm_default", func(t *testing.T) {
+		t.Parallel()
+
+		harness := envstest.NewServerConfig(t, testDatabaseInstance)
+
+		c := login.New(harness.AuthProvider, harness.Cacher, harness.Config, harness.Database, harness.Renderer)
+		handler := harness.WithCommonMiddlewares(c.HandleSelectRealm())
+
+		session := &sessions.Session{
+			Values: make(map[interface{}]interface{}),
+		}
+		defaultRealmID := uint(2)
+		user := &database.User{DefaultRealmID: &defaultRealmID}
+
+		ctx := project.TestContext(t)
+		ctx = controller.WithSession(ctx, session)
+		ctx = controller.WithUser(ctx, user)
+		ctx = controller.WithMemberships(ctx, []*database.Membership{
+			{
+				User:  user,
+				Realm: &database.Realm{Model: gorm.Model{ID: 1}},
+			},
+			{
+				User:  user,
+				Realm: &database.Realm{Model: gorm.Model{ID: 2}},
+			},
+		})
+
+		w, r := envstest.BuildFormRequest(ctx, t, http.MethodGet, "/", nil)
+		handler.ServeHTTP(w, r)
+
+		if got, want := w.Code, http.StatusSeeOther; got != want {
+			t.Errorf("expected %d to be %d: %s", got, want, w.Body.String())
+		}
+		if got, want := w.Header().Get("Location"), "/login/post-authenticate"; got != want {
+			t.Errorf("expected %q to be %q", got, want)
+		}
+		if got, want := controller.RealmIDFromSession(session), defaultRealmID; got != want {
+			t.Errorf("expected realm %d to be %d", got, want)
+		}
+	})
 }
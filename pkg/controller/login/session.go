@@ -41,7 +41,7 @@ func (c *Controller) HandleCreateSession() http.Handler {
 		var form FormData
 		if err := controller.BindForm(w, r, &form); err != nil {
 			flash.Error("Failed to process form: %v", err)
-			c.h.RenderJSON(w, http.StatusBadRequest, api.Error(err))
+			c.h.RenderJSON(w, http.StatusBadRequest, api.Error(err).WithCode(api.ErrUnparsableRequest))
 			return
 		}
 
@@ -53,7 +53,7 @@ func (c *Controller) HandleCreateSession() http.Handler {
 			TTL: c.config.SessionDuration,
 		}); err != nil {
 			flash.Error("Failed to create session: %v", err)
-			c.h.RenderJSON(w, http.StatusUnauthorized, api.Error(err))
+			c.h.RenderJSON(w, http.StatusUnauthorized, api.Error(err).WithCode(api.ErrUnauthorized))
 			return
 		}
 
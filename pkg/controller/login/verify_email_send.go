@@ -70,7 +70,7 @@ func (c *Controller) HandleSubmitVerifyEmail() http.Handler {
 		}
 
 		// Build the email template.
-		verifyComposer, err := controller.SendEmailVerificationEmailFunc(ctx, c.db, c.h, currentUser.Email, membership.Realm)
+		verifyComposer, err := controller.SendEmailVerificationEmailFunc(ctx, c.db, c.h, currentUser.Email, currentUser.PreferredLanguage, membership.Realm)
 		if err != nil {
 			controller.InternalError(w, r, c.h, err)
 			return
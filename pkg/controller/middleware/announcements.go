@@ -0,0 +1,101 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package middleware
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/google/exposure-notifications-server/pkg/logging"
+	"github.com/google/exposure-notifications-verification-server/pkg/cache"
+	"github.com/google/exposure-notifications-verification-server/pkg/controller"
+	"github.com/google/exposure-notifications-verification-server/pkg/database"
+
+	"github.com/gorilla/mux"
+)
+
+// announcementsCacheTTL is how long the list of active announcements is cached.
+// Newly created announcements may take this long to appear.
+const announcementsCacheTTL = 5 * time.Minute
+
+// LoadAnnouncements loads the active announcements that target the current
+// user and realm, and that the user has not dismissed, into the template map.
+// Failures are logged, but do not prevent the request from being served.
+//
+// This must come after LoadCurrentMembership so the user and realm are on the
+// context.
+func LoadAnnouncements(cacher cache.Cacher, db *database.Database) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := r.Context()
+			logger := logging.FromContext(ctx).Named("middleware.LoadAnnouncements")
+
+			currentUser := controller.UserFromContext(ctx)
+			if currentUser == nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+			currentRealm := controller.RealmFromContext(ctx)
+
+			var announcements []*database.Announcement
+			cacheKey := &cache.Key{
+				Namespace: "announcements",
+				Key:       "active",
+			}
+			if err := cacher.Fetch(ctx, cacheKey, &announcements, announcementsCacheTTL, func() (interface{}, error) {
+				return db.ListActiveAnnouncements(time.Now().UTC())
+			}); err != nil {
+				logger.Errorw("failed to load announcements", "error", err)
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			// The cached list may be stale, so check the schedule again.
+			now := time.Now().UTC()
+			visible := make([]*database.Announcement, 0, len(announcements))
+			ids := make([]uint, 0, len(announcements))
+			for _, a := range announcements {
+				if a.IsActive(now) && a.VisibleTo(currentUser, currentRealm) {
+					visible = append(visible, a)
+					ids = append(ids, a.ID)
+				}
+			}
+
+			// Most of the time there are no announcements, so avoid the lookup.
+			if len(visible) > 0 {
+				read, err := db.ReadAnnouncementIDs(currentUser, ids)
+				if err != nil {
+					logger.Errorw("failed to load announcement reads", "error", err)
+					next.ServeHTTP(w, r)
+					return
+				}
+
+				unread := make([]*database.Announcement, 0, len(visible))
+				for _, a := range visible {
+					if _, ok := read[a.ID]; !ok {
+						unread = append(unread, a)
+					}
+				}
+
+				m := controller.TemplateMapFromContext(ctx)
+				m["announcements"] = unread
+				ctx = controller.WithTemplateMap(ctx, m)
+				r = r.Clone(ctx)
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
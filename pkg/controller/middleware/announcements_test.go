@@ -0,0 +1,111 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package middleware_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/exposure-notifications-verification-server/internal/envstest"
+	"github.com/google/exposure-notifications-verification-server/internal/project"
+	"github.com/google/exposure-notifications-verification-server/pkg/cache"
+	"github.com/google/exposure-notifications-verification-server/pkg/controller"
+	"github.com/google/exposure-notifications-verification-server/pkg/controller/middleware"
+	"github.com/google/exposure-notifications-verification-server/pkg/database"
+)
+
+func TestLoadAnnouncements(t *testing.T) {
+	t.Parallel()
+
+	ctx := project.TestContext(t)
+	harness := envstest.NewServerConfig(t, testDatabaseInstance)
+	db := harness.Database
+
+	realm, err := db.FindRealm(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	user := &database.User{
+		Email: "announcements@example.com",
+		Name:  "Announcements",
+	}
+	if err := db.SaveUser(user, database.SystemTest); err != nil {
+		t.Fatal(err)
+	}
+
+	cacher, err := cache.NewNoop()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	startsAt := time.Now().UTC().Add(-1 * time.Minute)
+	all := &database.Announcement{
+		Title:    "For everyone",
+		Body:     "New feature",
+		Audience: database.AnnouncementAudienceAll,
+		StartsAt: startsAt,
+	}
+	if err := db.SaveAnnouncement(all, database.SystemTest); err != nil {
+		t.Fatal(err)
+	}
+	admins := &database.Announcement{
+		Title:    "For admins",
+		Body:     "New admin feature",
+		Audience: database.AnnouncementAudienceSystemAdmins,
+		StartsAt: startsAt,
+	}
+	if err := db.SaveAnnouncement(admins, database.SystemTest); err != nil {
+		t.Fatal(err)
+	}
+
+	serve := func(tb testing.TB) []*database.Announcement {
+		tb.Helper()
+
+		var got []*database.Announcement
+		next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			m := controller.TemplateMapFromContext(r.Context())
+			if v, ok := m["announcements"]; ok {
+				got = v.([]*database.Announcement)
+			}
+		})
+
+		ctx := controller.WithUser(ctx, user)
+		ctx = controller.WithRealm(ctx, realm)
+		r := httptest.NewRequest(http.MethodGet, "/", nil).Clone(ctx)
+		w := httptest.NewRecorder()
+
+		middleware.LoadAnnouncements(cacher, db)(next).ServeHTTP(w, r)
+		return got
+	}
+
+	announcements := serve(t)
+	if got, want := len(announcements), 1; got != want {
+		t.Fatalf("expected %d announcements, got %d", want, got)
+	}
+	if got, want := announcements[0].ID, all.ID; got != want {
+		t.Errorf("expected %d to be %d", got, want)
+	}
+
+	if err := db.MarkAnnouncementRead(all, user); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := serve(t); len(got) != 0 {
+		t.Errorf("expected no announcements after dismissal, got %v", got)
+	}
+}
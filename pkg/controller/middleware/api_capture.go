@@ -0,0 +1,220 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/exposure-notifications-server/pkg/logging"
+	"github.com/google/exposure-notifications-verification-server/pkg/controller"
+	"github.com/google/exposure-notifications-verification-server/pkg/database"
+
+	"github.com/gorilla/mux"
+)
+
+// maxCaptureBodyBytes is the most of each request and response body that is
+// captured.
+const maxCaptureBodyBytes = 16 * 1024
+
+const (
+	captureRedacted     = "REDACTED"
+	captureNotJSON      = "(body is not JSON and was not captured)"
+	captureTruncatedFmt = "(body is larger than %d bytes and was not captured)"
+)
+
+// redactedCaptureHeaders are the headers whose values are never captured.
+var redactedCaptureHeaders = map[string]struct{}{
+	"Authorization":       {},
+	"Cookie":              {},
+	"Proxy-Authorization": {},
+	"Set-Cookie":          {},
+	APIKeyHeader:          {},
+}
+
+// redactedCaptureFields are the JSON fields, compared case-insensitively,
+// whose values are never captured because they hold codes, tokens, or
+// personal information.
+var redactedCaptureFields = map[string]struct{}{
+	"callbacksecret": {},
+	"certificate":    {},
+	"code":           {},
+	"ekeyhmac":       {},
+	"email":          {},
+	"generatedsms":   {},
+	"longcode":       {},
+	"message":        {},
+	"nonce":          {},
+	"padding":        {},
+	"password":       {},
+	"phone":          {},
+	"secret":         {},
+	"token":          {},
+}
+
+// CaptureAPIRequests saves a redacted copy of a sample of the requests made
+// with API keys that have request capture enabled, so realm admins can see
+// exactly what a partner sent and what the server returned. Failing to save a
+// capture is logged and never fails the request.
+//
+// This must come after RequireAPIKey.
+func CaptureAPIRequests(db *database.Database) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := r.Context()
+
+			logger := logging.FromContext(ctx).Named("middleware.CaptureAPIRequests")
+
+			authApp := controller.AuthorizedAppFromContext(ctx)
+			if authApp == nil || !authApp.IsCapturing(time.Now()) || !sampleCapture(authApp.CaptureSampleRate) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			body, err := io.ReadAll(io.LimitReader(r.Body, maxCaptureBodyBytes+1))
+			r.Body = io.NopCloser(io.MultiReader(bytes.NewReader(body), r.Body))
+			if err != nil {
+				// Let the handler see and report the same error.
+				logger.Debugw("failed to read request body, not capturing", "error", err)
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			start := time.Now()
+			rec := &captureRecorder{ResponseWriter: w, status: http.StatusOK}
+			next.ServeHTTP(rec, r)
+
+			capture := &database.APICapture{
+				RealmID:         authApp.RealmID,
+				AuthorizedAppID: authApp.ID,
+				RequestID:       controller.RequestIDFromContext(ctx),
+				Method:          r.Method,
+				Path:            r.URL.Path,
+				RequestHeaders:  captureAPIHeaders(r.Header),
+				ResponseHeaders: captureAPIHeaders(rec.Header()),
+				RequestBody:     captureAPIBody(body),
+				ResponseBody:    captureAPIBody(rec.body.Bytes()),
+				ResponseStatus:  rec.status,
+				DurationMs:      time.Since(start).Milliseconds(),
+			}
+			if err := db.SaveAPICapture(capture); err != nil {
+				logger.Errorw("failed to save api capture", "authorized_app_id", authApp.ID, "error", err)
+			}
+		})
+	}
+}
+
+// sampleCapture returns true if a request should be captured at the given
+// percentage rate.
+func sampleCapture(rate uint) bool {
+	if rate >= database.MaxAPICaptureSampleRate {
+		return true
+	}
+	return uint(rand.Intn(database.MaxAPICaptureSampleRate)) < rate //nolint:gosec // sampling does not need to be unpredictable
+}
+
+// captureAPIHeaders formats the headers for storage, one "Name: value" per
+// line, with credentials redacted.
+func captureAPIHeaders(h http.Header) string {
+	names := make([]string, 0, len(h))
+	for name := range h {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	for _, name := range names {
+		for _, v := range h[name] {
+			if _, ok := redactedCaptureHeaders[http.CanonicalHeaderKey(name)]; ok {
+				v = captureRedacted
+			}
+			fmt.Fprintf(&b, "%s: %s\n", name, v)
+		}
+	}
+	return b.String()
+}
+
+// captureAPIBody formats the body for storage with sensitive fields redacted.
+// Bodies that are too large or are not JSON are not captured, since they
+// cannot be reliably redacted.
+func captureAPIBody(b []byte) string {
+	if len(bytes.TrimSpace(b)) == 0 {
+		return ""
+	}
+	if len(b) > maxCaptureBodyBytes {
+		return fmt.Sprintf(captureTruncatedFmt, maxCaptureBodyBytes)
+	}
+
+	var v interface{}
+	if err := json.Unmarshal(b, &v); err != nil {
+		return captureNotJSON
+	}
+
+	out, err := json.MarshalIndent(redactCaptureValue(v), "", "  ")
+	if err != nil {
+		return captureNotJSON
+	}
+	return string(out)
+}
+
+// redactCaptureValue replaces the values of sensitive fields in the decoded
+// JSON value, at any depth.
+func redactCaptureValue(v interface{}) interface{} {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		for k, val := range t {
+			if _, ok := redactedCaptureFields[strings.ToLower(k)]; ok {
+				t[k] = captureRedacted
+				continue
+			}
+			t[k] = redactCaptureValue(val)
+		}
+		return t
+	case []interface{}:
+		for i, val := range t {
+			t[i] = redactCaptureValue(val)
+		}
+		return t
+	default:
+		return v
+	}
+}
+
+// captureRecorder captures the status and body written to a response, while
+// still writing them to the client.
+type captureRecorder struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (r *captureRecorder) WriteHeader(code int) {
+	r.status = code
+	r.ResponseWriter.WriteHeader(code)
+}
+
+func (r *captureRecorder) Write(b []byte) (int, error) {
+	if r.body.Len() <= maxCaptureBodyBytes {
+		r.body.Write(b)
+	}
+	return r.ResponseWriter.Write(b)
+}
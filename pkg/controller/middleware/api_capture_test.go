@@ -0,0 +1,121 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package middleware_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/exposure-notifications-verification-server/internal/project"
+	"github.com/google/exposure-notifications-verification-server/pkg/controller"
+	"github.com/google/exposure-notifications-verification-server/pkg/controller/middleware"
+	"github.com/google/exposure-notifications-verification-server/pkg/database"
+)
+
+func TestCaptureAPIRequests(t *testing.T) {
+	t.Parallel()
+
+	ctx := project.TestContext(t)
+	db, _ := testDatabaseInstance.NewDatabase(t, nil)
+
+	realm, err := db.FindRealm(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	until := time.Now().Add(time.Hour)
+	capturing := &database.AuthorizedApp{
+		RealmID:           realm.ID,
+		Name:              "capturing",
+		CaptureSampleRate: 100,
+		CaptureUntil:      &until,
+	}
+	if _, err := realm.CreateAuthorizedApp(db, capturing, database.SystemTest); err != nil {
+		t.Fatal(err)
+	}
+
+	notCapturing := &database.AuthorizedApp{
+		RealmID: realm.ID,
+		Name:    "not-capturing",
+	}
+	if _, err := realm.CreateAuthorizedApp(db, notCapturing, database.SystemTest); err != nil {
+		t.Fatal(err)
+	}
+
+	handler := middleware.CaptureAPIRequests(db)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write([]byte(`{"error":"verification code invalid","errorCode":"code_invalid","token":"abc123"}`))
+	}))
+
+	send := func(tb testing.TB, app *database.AuthorizedApp) {
+		tb.Helper()
+
+		ctx := controller.WithAuthorizedApp(ctx, app)
+		ctx = controller.WithRequestID(ctx, "request-1")
+
+		body := strings.NewReader(`{"code":"12345678","padding":"AAAA","nested":{"Phone":"+12065551234"},"accept":["confirmed"]}`)
+		r := httptest.NewRequest(http.MethodPost, "/api/verify", body)
+		r = r.Clone(ctx)
+		r.Header.Set("Content-Type", "application/json")
+		r.Header.Set(middleware.APIKeyHeader, "super-secret-key")
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+
+		if got, want := w.Code, http.StatusBadRequest; got != want {
+			tb.Errorf("Expected %d to be %d", got, want)
+		}
+	}
+
+	send(t, notCapturing)
+	send(t, capturing)
+
+	captures, _, err := realm.ListAPICaptures(db, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(captures), 1; got != want {
+		t.Fatalf("Expected %d captures to be %d", got, want)
+	}
+
+	capture := captures[0]
+	if got, want := capture.AuthorizedAppID, capturing.ID; got != want {
+		t.Errorf("Expected %d to be %d", got, want)
+	}
+	if got, want := capture.RequestID, "request-1"; got != want {
+		t.Errorf("Expected %q to be %q", got, want)
+	}
+	if got, want := capture.ResponseStatus, http.StatusBadRequest; got != want {
+		t.Errorf("Expected %d to be %d", got, want)
+	}
+
+	for _, secret := range []string{"super-secret-key", "12345678", "AAAA", "+12065551234", "abc123"} {
+		for _, s := range []string{capture.RequestHeaders, capture.RequestBody, capture.ResponseBody} {
+			if strings.Contains(s, secret) {
+				t.Errorf("Expected %q to be redacted from %q", secret, s)
+			}
+		}
+	}
+
+	for _, kept := range []string{"confirmed", "code_invalid"} {
+		if !strings.Contains(capture.RequestBody+capture.ResponseBody, kept) {
+			t.Errorf("Expected %q to be captured", kept)
+		}
+	}
+}
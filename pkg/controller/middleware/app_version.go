@@ -0,0 +1,107 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package middleware
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/google/exposure-notifications-server/pkg/logging"
+	"github.com/google/exposure-notifications-verification-server/pkg/api"
+	"github.com/google/exposure-notifications-verification-server/pkg/controller"
+	"github.com/google/exposure-notifications-verification-server/pkg/observability"
+	"github.com/google/exposure-notifications-verification-server/pkg/render"
+	"go.opencensus.io/stats"
+	"go.opencensus.io/tag"
+
+	"github.com/gorilla/mux"
+)
+
+// RequireMinimumAppVersion rejects requests from mobile apps that report a
+// version older than the realm's minimum app version. Apps report their
+// version in the app version header. Requests without the header, or with a
+// version that cannot be parsed, are allowed so apps that predate the header
+// keep working; they are counted as unknown.
+//
+// This must come after the realm has been loaded in the context, probably via
+// RequireAPIKey.
+func RequireMinimumAppVersion(h *render.Renderer) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := r.Context()
+
+			logger := logging.FromContext(ctx).Named("middleware.RequireMinimumAppVersion")
+
+			currentRealm := controller.RealmFromContext(ctx)
+			if currentRealm == nil {
+				controller.MissingMembership(w, r, h)
+				return
+			}
+
+			// If there's no minimum, all versions are allowed.
+			if currentRealm.MinimumAppVersion == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			ctx = observability.WithRealmID(ctx, uint64(currentRealm.ID))
+
+			minimum, err := api.ParseAppVersion(currentRealm.MinimumAppVersion)
+			if err != nil {
+				// This is validated on save, so this should never happen.
+				logger.Errorw("failed to parse realm minimum app version", "error", err)
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			reported := r.Header.Get(api.AppVersionHeader)
+			if reported == "" {
+				recordAppVersionCheck(ctx, "UNKNOWN")
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			version, err := api.ParseAppVersion(reported)
+			if err != nil {
+				logger.Debugw("failed to parse app version", "version", reported, "error", err)
+				recordAppVersionCheck(ctx, "UNKNOWN")
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if version.Compare(minimum) < 0 {
+				logger.Debugw("rejecting unsupported app version",
+					"version", version.String(),
+					"minimum", minimum.String())
+				recordAppVersionCheck(ctx, "REJECTED")
+				h.RenderJSON(w, http.StatusUpgradeRequired,
+					api.Errorf("app version %s is no longer supported, upgrade to version %s or later",
+						version, minimum).WithCode(api.ErrAppVersionUnsupported))
+				return
+			}
+
+			recordAppVersionCheck(ctx, "OK")
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// recordAppVersionCheck records the outcome of an app version check.
+func recordAppVersionCheck(ctx context.Context, result string) {
+	if err := stats.RecordWithTags(ctx, []tag.Mutator{tag.Upsert(appVersionResultTagKey, result)},
+		mAppVersionChecks.M(1)); err != nil {
+		logging.FromContext(ctx).Errorw("failed to record app version check", "error", err)
+	}
+}
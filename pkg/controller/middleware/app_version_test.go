@@ -0,0 +1,130 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package middleware_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/exposure-notifications-verification-server/internal/project"
+	"github.com/google/exposure-notifications-verification-server/pkg/api"
+	"github.com/google/exposure-notifications-verification-server/pkg/controller"
+	"github.com/google/exposure-notifications-verification-server/pkg/controller/middleware"
+	"github.com/google/exposure-notifications-verification-server/pkg/database"
+	"github.com/google/exposure-notifications-verification-server/pkg/render"
+)
+
+func TestRequireMinimumAppVersion(t *testing.T) {
+	t.Parallel()
+
+	ctx := project.TestContext(t)
+	h, err := render.New(ctx, nil, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	requireMinimumAppVersion := middleware.RequireMinimumAppVersion(h)(emptyHandler())
+
+	realmWithMinimum := controller.WithRealm(ctx, &database.Realm{
+		MinimumAppVersion: "1.4.0",
+	})
+
+	cases := []struct {
+		name    string
+		ctx     context.Context
+		version string
+		code    int
+		errCode string
+	}{
+		{
+			name: "no_realm",
+			ctx:  ctx,
+			code: http.StatusBadRequest,
+		},
+		{
+			name:    "no_minimum",
+			ctx:     controller.WithRealm(ctx, &database.Realm{}),
+			version: "0.1",
+			code:    http.StatusOK,
+		},
+		{
+			name: "missing_header",
+			ctx:  realmWithMinimum,
+			code: http.StatusOK,
+		},
+		{
+			name:    "unparsable_version",
+			ctx:     realmWithMinimum,
+			version: "banana",
+			code:    http.StatusOK,
+		},
+		{
+			name:    "equal_version",
+			ctx:     realmWithMinimum,
+			version: "1.4",
+			code:    http.StatusOK,
+		},
+		{
+			name:    "newer_version",
+			ctx:     realmWithMinimum,
+			version: "v1.10.0-beta",
+			code:    http.StatusOK,
+		},
+		{
+			name:    "older_version",
+			ctx:     realmWithMinimum,
+			version: "1.3.9",
+			code:    http.StatusUpgradeRequired,
+			errCode: api.ErrAppVersionUnsupported,
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			r := httptest.NewRequest(http.MethodPost, "/", nil)
+			r = r.Clone(tc.ctx)
+			r.Header.Set("Accept", "application/json")
+			if v := tc.version; v != "" {
+				r.Header.Set(api.AppVersionHeader, v)
+			}
+
+			w := httptest.NewRecorder()
+
+			requireMinimumAppVersion.ServeHTTP(w, r)
+			w.Flush()
+
+			if got, want := w.Code, tc.code; got != want {
+				t.Errorf("Expected %d to be %d", got, want)
+			}
+
+			if tc.errCode != "" {
+				var resp api.ErrorReturn
+				if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+					t.Fatal(err)
+				}
+				if got, want := resp.ErrorCode, tc.errCode; got != want {
+					t.Errorf("Expected %q to be %q", got, want)
+				}
+			}
+		})
+	}
+}
@@ -147,7 +147,7 @@ func RequireSystemAdmin(h *render.Renderer) mux.MiddlewareFunc {
 				return
 			}
 
-			if !currentUser.SystemAdmin {
+			if !currentUser.IsSystemAdmin() {
 				logger.Debugw("user is not an admin")
 				controller.Unauthorized(w, r, h)
 				return
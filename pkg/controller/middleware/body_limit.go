@@ -0,0 +1,40 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/google/exposure-notifications-verification-server/pkg/controller"
+
+	"github.com/gorilla/mux"
+)
+
+// LimitBody sets the maximum request body size for requests decoded with
+// controller.BindJSON. If n is not positive, the default limit is used.
+func LimitBody(n int64) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := r.Context()
+
+			if n > 0 {
+				ctx = controller.WithMaxBodyBytes(ctx, n)
+				r = r.Clone(ctx)
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,85 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package middleware
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/google/exposure-notifications-server/pkg/logging"
+	"github.com/google/exposure-notifications-verification-server/pkg/api"
+	"github.com/google/exposure-notifications-verification-server/pkg/controller"
+	"github.com/google/exposure-notifications-verification-server/pkg/observability"
+	"github.com/google/exposure-notifications-verification-server/pkg/render"
+	"go.opencensus.io/stats"
+	"go.opencensus.io/tag"
+
+	"github.com/gorilla/mux"
+)
+
+// CheckClientFingerprint compares the request's user agent and app package
+// header against the API key's allowed clients. Mismatches are logged and
+// counted, which can indicate a leaked device API key being replayed from a
+// script. If the API key enforces its fingerprint, mismatched requests are
+// rejected as unauthorized.
+//
+// This must come after RequireAPIKey.
+func CheckClientFingerprint(h *render.Renderer) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := r.Context()
+
+			logger := logging.FromContext(ctx).Named("middleware.CheckClientFingerprint")
+
+			authApp := controller.AuthorizedAppFromContext(ctx)
+			if authApp == nil {
+				controller.Unauthorized(w, r, h)
+				return
+			}
+
+			userAgent := r.UserAgent()
+			appPackage := r.Header.Get(api.AppPackageHeader)
+			if authApp.MatchesClientFingerprint(userAgent, appPackage) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			ctx = observability.WithRealmID(ctx, uint64(authApp.RealmID))
+			logger = logger.With(
+				"authorized_app_id", authApp.ID,
+				"user_agent", userAgent,
+				"app_package", appPackage)
+
+			if authApp.EnforceClientFingerprint {
+				logger.Warnw("rejecting request that does not match api key client fingerprint")
+				recordClientFingerprintMismatch(ctx, "REJECTED")
+				controller.Unauthorized(w, r, h)
+				return
+			}
+
+			logger.Warnw("request does not match api key client fingerprint")
+			recordClientFingerprintMismatch(ctx, "LOGGED")
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// recordClientFingerprintMismatch records a client fingerprint mismatch.
+func recordClientFingerprintMismatch(ctx context.Context, result string) {
+	if err := stats.RecordWithTags(ctx, []tag.Mutator{tag.Upsert(clientFingerprintResultTagKey, result)},
+		mClientFingerprintMismatches.M(1)); err != nil {
+		logging.FromContext(ctx).Errorw("failed to record client fingerprint mismatch", "error", err)
+	}
+}
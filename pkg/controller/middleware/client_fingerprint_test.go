@@ -0,0 +1,116 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package middleware_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/exposure-notifications-verification-server/internal/project"
+	"github.com/google/exposure-notifications-verification-server/pkg/api"
+	"github.com/google/exposure-notifications-verification-server/pkg/controller"
+	"github.com/google/exposure-notifications-verification-server/pkg/controller/middleware"
+	"github.com/google/exposure-notifications-verification-server/pkg/database"
+	"github.com/google/exposure-notifications-verification-server/pkg/render"
+)
+
+func TestCheckClientFingerprint(t *testing.T) {
+	t.Parallel()
+
+	ctx := project.TestContext(t)
+	h, err := render.New(ctx, nil, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	checkClientFingerprint := middleware.CheckClientFingerprint(h)(emptyHandler())
+
+	pinned := &database.AuthorizedApp{
+		AllowedUserAgents:  []string{"ExposureNotifications/"},
+		AllowedAppPackages: []string{"com.example.health"},
+	}
+	enforced := &database.AuthorizedApp{
+		AllowedUserAgents:        []string{"ExposureNotifications/"},
+		AllowedAppPackages:       []string{"com.example.health"},
+		EnforceClientFingerprint: true,
+	}
+
+	cases := []struct {
+		name       string
+		ctx        context.Context
+		userAgent  string
+		appPackage string
+		code       int
+	}{
+		{
+			name: "no_app",
+			ctx:  ctx,
+			code: http.StatusUnauthorized,
+		},
+		{
+			name:      "not_pinned",
+			ctx:       controller.WithAuthorizedApp(ctx, &database.AuthorizedApp{}),
+			userAgent: "curl/7.79.1",
+			code:      http.StatusOK,
+		},
+		{
+			name:       "match",
+			ctx:        controller.WithAuthorizedApp(ctx, enforced),
+			userAgent:  "ExposureNotifications/1.2",
+			appPackage: "com.example.health",
+			code:       http.StatusOK,
+		},
+		{
+			name:      "mismatch_logged",
+			ctx:       controller.WithAuthorizedApp(ctx, pinned),
+			userAgent: "curl/7.79.1",
+			code:      http.StatusOK,
+		},
+		{
+			name:       "mismatch_rejected",
+			ctx:        controller.WithAuthorizedApp(ctx, enforced),
+			userAgent:  "ExposureNotifications/1.2",
+			appPackage: "com.example.other",
+			code:       http.StatusUnauthorized,
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			r := httptest.NewRequest(http.MethodPost, "/", nil)
+			r = r.Clone(tc.ctx)
+			r.Header.Set("Accept", "application/json")
+			r.Header.Set("User-Agent", tc.userAgent)
+			if v := tc.appPackage; v != "" {
+				r.Header.Set(api.AppPackageHeader, v)
+			}
+
+			w := httptest.NewRecorder()
+
+			checkClientFingerprint.ServeHTTP(w, r)
+			w.Flush()
+
+			if got, want := w.Code, tc.code; got != want {
+				t.Errorf("Expected %d to be %d", got, want)
+			}
+		})
+	}
+}
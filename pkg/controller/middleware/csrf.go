@@ -20,9 +20,12 @@ import (
 	"fmt"
 	"html/template"
 	"net/http"
+	"net/url"
+	"strings"
 
 	"github.com/google/exposure-notifications-server/pkg/logging"
 	"github.com/google/exposure-notifications-verification-server/internal/project"
+	"github.com/google/exposure-notifications-verification-server/pkg/api"
 	"github.com/google/exposure-notifications-verification-server/pkg/controller"
 	"github.com/google/exposure-notifications-verification-server/pkg/render"
 	"github.com/gorilla/mux"
@@ -36,6 +39,10 @@ const (
 	CSRFFormField         = "csrf_token"
 	CSRFFormFieldTemplate = `<input type="hidden" name="%s" value="%s" />`
 
+	// CSRFCookieName is the name of the cookie holding the masked CSRF token for
+	// double-submit verification. It is readable by Javascript.
+	CSRFCookieName = "csrf_token"
+
 	// CSRFMetaTagName is the meta tag name (used by Javascript).
 	CSRFMetaTagName     = "csrf-token"
 	CSRFMetaTagTemplate = `<meta name="%s" content="%s">`
@@ -58,13 +65,27 @@ func (e Error) Error() string { return string(e) }
 // exists). Then, it generates a unique, per-request CSRF token and stores it in
 // the session. This must come after RequireSession to ensure the session has
 // been populated.
+//
+// It is the combination of ProcessCSRF and VerifyCSRF.
 func HandleCSRF(h *render.Renderer) mux.MiddlewareFunc {
+	processCSRF := ProcessCSRF(h)
+	verifyCSRF := VerifyCSRF(h)
+
+	return func(next http.Handler) http.Handler {
+		return processCSRF(verifyCSRF(next))
+	}
+}
+
+// ProcessCSRF ensures the session has a CSRF token, makes the masked token
+// available to templates, and sets it in a cookie for double-submit
+// verification by JSON endpoints. It never rejects a request; pair it with
+// VerifyCSRF or VerifyCSRFJSON. This must come after RequireSession to ensure
+// the session has been populated.
+func ProcessCSRF(h *render.Renderer) mux.MiddlewareFunc {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			ctx := r.Context()
 
-			logger := logging.FromContext(ctx).Named("middleware.HandleCSRF")
-
 			session := controller.SessionFromContext(ctx)
 			if session == nil {
 				controller.MissingSession(w, r, h)
@@ -75,7 +96,8 @@ func HandleCSRF(h *render.Renderer) mux.MiddlewareFunc {
 			existingToken := controller.CSRFTokenFromSession(session)
 
 			// If the existing token is invalid or missing, generate and store a new
-			// token on the session.
+			// token on the session. Verification happens against the session token,
+			// so a new token will correctly fail validation, if applicable.
 			if l := len(existingToken); l != TokenLength {
 				newToken, err := project.RandomBytes(TokenLength)
 				if err != nil {
@@ -83,12 +105,6 @@ func HandleCSRF(h *render.Renderer) mux.MiddlewareFunc {
 					return
 				}
 				controller.StoreSessionCSRFToken(session, newToken)
-
-				// Set the existing token to this new token. The only way we got into
-				// this block was if the existing token was missing or invalid. That
-				// means it's not safe to pass to the template or request helpers or
-				// else we risk future requests failing. The new token will fail
-				// validation, if applicable, below.
 				existingToken = newToken
 			}
 
@@ -107,12 +123,44 @@ func HandleCSRF(h *render.Renderer) mux.MiddlewareFunc {
 			ctx = controller.WithTemplateMap(ctx, m)
 			r = r.Clone(ctx)
 
+			// The cookie must be readable by Javascript so it can be echoed back in
+			// the request header.
+			http.SetCookie(w, &http.Cookie{
+				Name:     CSRFCookieName,
+				Value:    masked,
+				Path:     "/",
+				Secure:   isSecureRequest(r),
+				HttpOnly: false,
+				SameSite: http.SameSiteStrictMode,
+			})
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// VerifyCSRF rejects mutating requests that do not include the session's CSRF
+// token in the header or form. It must come after ProcessCSRF.
+func VerifyCSRF(h *render.Renderer) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := r.Context()
+
+			logger := logging.FromContext(ctx).Named("middleware.VerifyCSRF")
+
 			// Only mutating methods need CSRF verification.
 			if canSkipCSRFCheck(r) {
 				next.ServeHTTP(w, r)
 				return
 			}
 
+			session := controller.SessionFromContext(ctx)
+			if session == nil {
+				controller.MissingSession(w, r, h)
+				return
+			}
+			existingToken := controller.CSRFTokenFromSession(session)
+
 			// Grab the incoming token from the request.
 			incomingToken, err := tokenFromRequest(r)
 			if err != nil {
@@ -122,7 +170,7 @@ func HandleCSRF(h *render.Renderer) mux.MiddlewareFunc {
 				logger.Warnw("invalid csrf token from request", "error", err)
 			}
 
-			if subtle.ConstantTimeCompare(existingToken, incomingToken) != 1 {
+			if len(existingToken) != TokenLength || subtle.ConstantTimeCompare(existingToken, incomingToken) != 1 {
 				controller.Unauthorized(w, r, h)
 				return
 			}
@@ -132,6 +180,97 @@ func HandleCSRF(h *render.Renderer) mux.MiddlewareFunc {
 	}
 }
 
+// VerifyCSRFJSON protects same-origin JSON endpoints called by the UI. Mutating
+// requests must originate from the same origin, and must carry the CSRF token
+// in both the header and the cookie set by ProcessCSRF (double-submit). Form
+// values are not accepted. Both tokens must match the session's token. It must
+// come after ProcessCSRF.
+//
+// If relaxOrigin is true, the same-origin check is skipped. This is only for
+// local development behind a proxy on another host or port.
+func VerifyCSRFJSON(h *render.Renderer, relaxOrigin bool) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := r.Context()
+
+			logger := logging.FromContext(ctx).Named("middleware.VerifyCSRFJSON")
+
+			if canSkipCSRFCheck(r) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if !relaxOrigin && !isSameOrigin(r) {
+				logger.Warnw("cross-origin request to json endpoint",
+					"origin", r.Header.Get("Origin"),
+					"sec_fetch_site", r.Header.Get("Sec-Fetch-Site"))
+				h.RenderJSON(w, http.StatusUnauthorized,
+					api.Errorf("cross-origin requests are not allowed").WithCode(api.ErrInvalidCSRFToken))
+				return
+			}
+
+			session := controller.SessionFromContext(ctx)
+			if session == nil {
+				controller.MissingSession(w, r, h)
+				return
+			}
+			existingToken := controller.CSRFTokenFromSession(session)
+
+			headerToken, err := decodeToken(r.Header.Get(CSRFHeaderField))
+			if err != nil {
+				logger.Warnw("invalid csrf header token", "error", err)
+			}
+
+			var cookieToken []byte
+			if cookie, err := r.Cookie(CSRFCookieName); err == nil {
+				cookieToken, err = decodeToken(cookie.Value)
+				if err != nil {
+					logger.Warnw("invalid csrf cookie token", "error", err)
+				}
+			}
+
+			if len(existingToken) != TokenLength ||
+				subtle.ConstantTimeCompare(existingToken, headerToken) != 1 ||
+				subtle.ConstantTimeCompare(existingToken, cookieToken) != 1 {
+				h.RenderJSON(w, http.StatusUnauthorized,
+					api.Errorf("missing or invalid csrf token").WithCode(api.ErrInvalidCSRFToken))
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// HandleCSRFToken returns the masked CSRF token for the current session as JSON,
+// so Javascript clients do not need to scrape it from the page. It must come
+// after ProcessCSRF.
+func HandleCSRFToken(h *render.Renderer) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		session := controller.SessionFromContext(ctx)
+		if session == nil {
+			controller.MissingSession(w, r, h)
+			return
+		}
+
+		masked, err := mask(controller.CSRFTokenFromSession(session))
+		if err != nil {
+			controller.InternalError(w, r, h, err)
+			return
+		}
+
+		// Tokens are per-session; never let intermediaries cache them.
+		w.Header().Set("Cache-Control", "no-store")
+		h.RenderJSON(w, http.StatusOK, &api.CSRFTokenResponse{
+			Token:      masked,
+			HeaderName: CSRFHeaderField,
+			CookieName: CSRFCookieName,
+		})
+	})
+}
+
 // tokenFromRequest extracts the provided token from the request, then the form,
 // then the multi-part form. The token must be a valid base64 URL-encoded string
 // generated from the csrf middleware. If the token is missing, it returns
@@ -151,6 +290,13 @@ func tokenFromRequest(r *http.Request) ([]byte, error) {
 		}
 	}
 
+	return decodeToken(str)
+}
+
+// decodeToken decodes and unmasks a token generated from the csrf middleware.
+// If the token is empty, it returns ErrMissingIncomingToken. If the token cannot
+// be decoded, it returns ErrInvalidToken.
+func decodeToken(str string) ([]byte, error) {
 	if str == "" {
 		return nil, ErrMissingIncomingToken
 	}
@@ -167,6 +313,35 @@ func tokenFromRequest(r *http.Request) ([]byte, error) {
 	return raw, nil
 }
 
+// isSameOrigin returns true if the request was made by a page served from the
+// same origin. Browsers send Sec-Fetch-Site and Origin on fetch and XHR
+// requests; Referer is used as a fallback for clients that omit Origin.
+func isSameOrigin(r *http.Request) bool {
+	if site := r.Header.Get("Sec-Fetch-Site"); site != "" && site != "same-origin" {
+		return false
+	}
+
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		origin = r.Header.Get("Referer")
+	}
+	if origin == "" {
+		return false
+	}
+
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	return u.Host != "" && strings.EqualFold(u.Host, r.Host)
+}
+
+// isSecureRequest returns true if the request was served over TLS, either
+// directly or via a terminating load balancer.
+func isSecureRequest(r *http.Request) bool {
+	return r.TLS != nil || strings.EqualFold(r.Header.Get("X-Forwarded-Proto"), "https")
+}
+
 // canSkipCSRFCheck returns true if the request does not need CSRF validation,
 // false otherwise. Only mutating methods need CSRF verification.
 func canSkipCSRFCheck(r *http.Request) bool {
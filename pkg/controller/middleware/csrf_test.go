@@ -15,6 +15,8 @@
 package middleware_test
 
 import (
+	"fmt"
+	"html/template"
 	"net/http"
 	"net/http/httptest"
 	"testing"
@@ -23,6 +25,7 @@ import (
 	"github.com/google/exposure-notifications-verification-server/pkg/controller"
 	"github.com/google/exposure-notifications-verification-server/pkg/controller/middleware"
 	"github.com/google/exposure-notifications-verification-server/pkg/render"
+	"github.com/gorilla/sessions"
 )
 
 func TestConfigureCSRF(t *testing.T) {
@@ -63,3 +66,160 @@ func TestConfigureCSRF(t *testing.T) {
 		}
 	})).ServeHTTP(w, r)
 }
+
+func TestVerifyCSRFJSON(t *testing.T) {
+	t.Parallel()
+
+	ctx := project.TestContext(t)
+	h, err := render.New(ctx, nil, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	processCSRF := middleware.ProcessCSRF(h)
+	verifyCSRFJSON := middleware.VerifyCSRFJSON(h, false)
+	relaxedCSRFJSON := middleware.VerifyCSRFJSON(h, true)
+
+	session := &sessions.Session{Values: map[interface{}]interface{}{}}
+	ctx = controller.WithSession(ctx, session)
+
+	// Issue a token for the session.
+	var token string
+	r := httptest.NewRequest(http.MethodGet, "/", nil).Clone(ctx)
+	w := httptest.NewRecorder()
+	processCSRF(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		m := controller.TemplateMapFromContext(r.Context())
+		token = string(m["csrfToken"].(template.HTML))
+	})).ServeHTTP(w, r)
+	if token == "" {
+		t.Fatal("expected token to be issued")
+	}
+
+	var found bool
+	for _, c := range w.Result().Cookies() {
+		if c.Name == middleware.CSRFCookieName {
+			found = true
+			if c.HttpOnly {
+				t.Errorf("expected csrf cookie to be readable by javascript")
+			}
+			if got, want := c.SameSite, http.SameSiteStrictMode; got != want {
+				t.Errorf("expected %v to be %v", got, want)
+			}
+		}
+	}
+	if !found {
+		t.Errorf("expected %s cookie to be set", middleware.CSRFCookieName)
+	}
+
+	cases := []struct {
+		name    string
+		method  string
+		origin  string
+		header  string
+		cookie  string
+		relaxed bool
+		code    int
+	}{
+		{
+			name:   "get_skips",
+			method: http.MethodGet,
+			code:   http.StatusOK,
+		},
+		{
+			name:   "cross_origin",
+			method: http.MethodPost,
+			origin: "https://evil.example.com",
+			header: token,
+			cookie: token,
+			code:   http.StatusUnauthorized,
+		},
+		{
+			name:   "missing_origin",
+			method: http.MethodPost,
+			header: token,
+			cookie: token,
+			code:   http.StatusUnauthorized,
+		},
+		{
+			name:   "missing_cookie",
+			method: http.MethodPost,
+			origin: "http://example.com",
+			header: token,
+			code:   http.StatusUnauthorized,
+		},
+		{
+			name:   "missing_header",
+			method: http.MethodPost,
+			origin: "http://example.com",
+			cookie: token,
+			code:   http.StatusUnauthorized,
+		},
+		{
+			name:   "invalid_header",
+			method: http.MethodPost,
+			origin: "http://example.com",
+			header: "bad",
+			cookie: token,
+			code:   http.StatusUnauthorized,
+		},
+		{
+			name:   "valid",
+			method: http.MethodPost,
+			origin: "http://example.com",
+			header: token,
+			cookie: token,
+			code:   http.StatusOK,
+		},
+		{
+			name:    "relaxed_cross_origin",
+			method:  http.MethodPost,
+			origin:  "http://localhost:3000",
+			header:  token,
+			cookie:  token,
+			relaxed: true,
+			code:    http.StatusOK,
+		},
+		{
+			name:    "relaxed_missing_header",
+			method:  http.MethodPost,
+			origin:  "http://localhost:3000",
+			cookie:  token,
+			relaxed: true,
+			code:    http.StatusUnauthorized,
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			r := httptest.NewRequest(tc.method, "/", nil).Clone(ctx)
+			r.Header.Set("Content-Type", "application/json")
+			if tc.origin != "" {
+				r.Header.Set("Origin", tc.origin)
+			}
+			if tc.header != "" {
+				r.Header.Set(middleware.CSRFHeaderField, tc.header)
+			}
+			if tc.cookie != "" {
+				r.Header.Set("Cookie", fmt.Sprintf("%s=%s", middleware.CSRFCookieName, tc.cookie))
+			}
+
+			verify := verifyCSRFJSON
+			if tc.relaxed {
+				verify = relaxedCSRFJSON
+			}
+
+			w := httptest.NewRecorder()
+			verify(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			})).ServeHTTP(w, r)
+
+			if got, want := w.Code, tc.code; got != want {
+				t.Errorf("expected %d to be %d: %s", got, want, w.Body.String())
+			}
+		})
+	}
+}
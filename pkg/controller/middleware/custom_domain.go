@@ -0,0 +1,87 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package middleware
+
+import (
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/exposure-notifications-server/pkg/logging"
+	"github.com/google/exposure-notifications-verification-server/pkg/cache"
+	"github.com/google/exposure-notifications-verification-server/pkg/controller"
+	"github.com/google/exposure-notifications-verification-server/pkg/database"
+	"github.com/google/exposure-notifications-verification-server/pkg/render"
+
+	"github.com/gorilla/mux"
+)
+
+// ProcessCustomDomain looks up the realm bound to the request's hostname, if
+// any, and stores it on the context. LoadCurrentMembership uses that realm
+// instead of the realm selected in the session. Requests to hostnames that are
+// not bound to a realm are unaffected.
+func ProcessCustomDomain(cacher cache.Cacher, db *database.Database, h *render.Renderer) mux.MiddlewareFunc {
+	cacheTTL := 5 * time.Minute
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := r.Context()
+
+			logger := logging.FromContext(ctx).Named("middleware.ProcessCustomDomain")
+
+			host := strings.ToLower(r.Host)
+			if hostname, _, err := net.SplitHostPort(host); err == nil {
+				host = hostname
+			}
+			if host == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			// Most requests are for the shared domain, so cache misses too. A realm
+			// with an ID of 0 means no realm is bound to the hostname.
+			var realm database.Realm
+			cacheKey := &cache.Key{
+				Namespace: "realms:by_custom_domain",
+				Key:       host,
+			}
+			if err := cacher.Fetch(ctx, cacheKey, &realm, cacheTTL, func() (interface{}, error) {
+				found, err := db.FindRealmByCustomDomain(host)
+				if err != nil {
+					if database.IsNotFound(err) {
+						return &database.Realm{}, nil
+					}
+					return nil, err
+				}
+				return found, nil
+			}); err != nil {
+				logger.Errorw("failed to lookup realm by custom domain", "error", err)
+				controller.InternalError(w, r, h, err)
+				return
+			}
+
+			if realm.ID == 0 {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			ctx = controller.WithHostRealm(ctx, &realm)
+			r = r.Clone(ctx)
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,116 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package middleware_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/exposure-notifications-verification-server/internal/envstest"
+	"github.com/google/exposure-notifications-verification-server/internal/project"
+	"github.com/google/exposure-notifications-verification-server/pkg/cache"
+	"github.com/google/exposure-notifications-verification-server/pkg/controller"
+	"github.com/google/exposure-notifications-verification-server/pkg/controller/middleware"
+	"github.com/google/exposure-notifications-verification-server/pkg/database"
+)
+
+func TestProcessCustomDomain(t *testing.T) {
+	t.Parallel()
+
+	ctx := project.TestContext(t)
+	harness := envstest.NewServerConfig(t, testDatabaseInstance)
+
+	db := harness.Database
+	realm, err := db.FindRealm(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	realm.CustomDomain = "verify.health.example.gov"
+	if err := db.SaveRealm(realm, database.SystemTest); err != nil {
+		t.Fatal(err)
+	}
+
+	cacher, err := cache.NewNoop()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		name    string
+		host    string
+		db      *database.Database
+		code    int
+		realmID uint
+	}{
+		{
+			name: "shared_domain",
+			host: "encv.example.com",
+			db:   db,
+			code: http.StatusOK,
+		},
+		{
+			name:    "custom_domain",
+			host:    "verify.health.example.gov",
+			db:      db,
+			code:    http.StatusOK,
+			realmID: realm.ID,
+		},
+		{
+			name:    "custom_domain_with_port",
+			host:    "Verify.Health.Example.gov:443",
+			db:      db,
+			code:    http.StatusOK,
+			realmID: realm.ID,
+		},
+		{
+			name: "database_error",
+			host: "verify.health.example.gov",
+			db:   harness.BadDatabase,
+			code: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			processCustomDomain := middleware.ProcessCustomDomain(cacher, tc.db, harness.Renderer)
+
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r = r.Clone(ctx)
+			r.Host = tc.host
+			r.Header.Set("Accept", "application/json")
+
+			w := httptest.NewRecorder()
+
+			processCustomDomain(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				var got uint
+				if hostRealm := controller.HostRealmFromContext(r.Context()); hostRealm != nil {
+					got = hostRealm.ID
+				}
+				if want := tc.realmID; got != want {
+					t.Errorf("expected host realm %d to be %d", got, want)
+				}
+			})).ServeHTTP(w, r)
+
+			if got, want := w.Code, tc.code; got != want {
+				t.Errorf("Expected %d to be %d", got, want)
+			}
+		})
+	}
+}
@@ -23,6 +23,7 @@ import (
 
 	"github.com/google/exposure-notifications-verification-server/pkg/controller"
 	"github.com/google/exposure-notifications-verification-server/pkg/database"
+	"github.com/google/exposure-notifications-verification-server/pkg/rbac"
 	"github.com/google/exposure-notifications-verification-server/pkg/render"
 	"golang.org/x/text/cases"
 	"golang.org/x/text/language"
@@ -35,8 +36,12 @@ import (
 // but fails to load from the database/cache, it returns an error. Use
 // RequireMembership to enforce membership.
 //
+// A system admin with an active impersonation of the realm in the session is
+// given a membership with the impersonation's permissions, and every request
+// is recorded in the realm's audit log.
+//
 // This must come after RequireAuth so that the user is loaded onto the context.
-func LoadCurrentMembership(h *render.Renderer) mux.MiddlewareFunc {
+func LoadCurrentMembership(db *database.Database, h *render.Renderer) mux.MiddlewareFunc {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			ctx := r.Context()
@@ -54,8 +59,14 @@ func LoadCurrentMembership(h *render.Renderer) mux.MiddlewareFunc {
 				return
 			}
 
-			// Extract the current realm ID from the session.
+			// Extract the current realm ID from the session. On a custom domain, the
+			// realm is always the one bound to the domain.
 			realmID := controller.RealmIDFromSession(session)
+			hostRealm := controller.HostRealmFromContext(ctx)
+			if hostRealm != nil && realmID != hostRealm.ID {
+				realmID = hostRealm.ID
+				controller.StoreSessionRealm(session, hostRealm)
+			}
 			if realmID == 0 {
 				// No realm in session, continue serving
 				next.ServeHTTP(w, r)
@@ -74,7 +85,42 @@ func LoadCurrentMembership(h *render.Renderer) mux.MiddlewareFunc {
 					break
 				}
 			}
+
+			// A system admin may be impersonating the realm.
+			if membership == nil && currentUser.IsSystemAdmin() {
+				impersonation, err := currentUser.FindActiveRealmImpersonation(db, realmID)
+				if err != nil && !database.IsNotFound(err) {
+					controller.InternalError(w, r, h, err)
+					return
+				}
+
+				if impersonation != nil {
+					realm, err := db.FindRealm(realmID)
+					if err != nil {
+						controller.InternalError(w, r, h, err)
+						return
+					}
+
+					// The view must be recorded before the page is served.
+					if err := db.RecordRealmImpersonationView(impersonation, currentUser, r.Method, r.URL.Path); err != nil {
+						controller.InternalError(w, r, h, err)
+						return
+					}
+
+					membership = impersonation.Membership(currentUser, realm)
+					ctx = controller.WithImpersonation(ctx, impersonation)
+				}
+			}
+
 			if membership == nil {
+				// Users cannot use a custom domain for a realm of which they are not a
+				// member.
+				if hostRealm != nil {
+					controller.ClearSessionRealm(session)
+					controller.Unauthorized(w, r, h)
+					return
+				}
+
 				// There was a realm in the session, but it does not match a membership
 				// of the user. Clear and move along.
 				controller.ClearSessionRealm(session)
@@ -130,6 +176,31 @@ func RequireMembership(h *render.Renderer) mux.MiddlewareFunc {
 	}
 }
 
+// RequirePermissions requires the current membership to hold at least one of
+// the given permissions.
+//
+// This must come after RequireMembership so the membership is on the context.
+func RequirePermissions(h *render.Renderer, p rbac.Permission) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := r.Context()
+
+			membership := controller.MembershipFromContext(ctx)
+			if membership == nil {
+				controller.MissingMembership(w, r, h)
+				return
+			}
+
+			if !membership.Can(p) {
+				controller.Unauthorized(w, r, h)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
 func passwordRedirectRequired(ctx context.Context, user *database.User, realm *database.Realm) bool {
 	err := checkRealmPasswordAge(user, realm)
 	if err == nil {
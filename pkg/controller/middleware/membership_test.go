@@ -17,12 +17,15 @@ package middleware_test
 import (
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/google/exposure-notifications-verification-server/internal/project"
 	"github.com/google/exposure-notifications-verification-server/pkg/controller"
 	"github.com/google/exposure-notifications-verification-server/pkg/controller/middleware"
 	"github.com/google/exposure-notifications-verification-server/pkg/database"
+	"github.com/google/exposure-notifications-verification-server/pkg/rbac"
 	"github.com/google/exposure-notifications-verification-server/pkg/render"
 	"github.com/gorilla/sessions"
 	"github.com/jinzhu/gorm"
@@ -37,7 +40,8 @@ func TestLoadCurrentMembership(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	loadCurrentMembership := middleware.LoadCurrentMembership(h)
+	db, _ := testDatabaseInstance.NewDatabase(t, nil)
+	loadCurrentMembership := middleware.LoadCurrentMembership(db, h)
 
 	user := &database.User{
 		Model: gorm.Model{ID: 1},
@@ -49,10 +53,16 @@ func TestLoadCurrentMembership(t *testing.T) {
 		Name:  "Realmy",
 	}
 
+	otherRealm := &database.Realm{
+		Model: gorm.Model{ID: 2},
+		Name:  "Other",
+	}
+
 	cases := []struct {
 		name        string
 		user        *database.User
 		realm       *database.Realm
+		hostRealm   *database.Realm
 		memberships []*database.Membership
 		found       bool
 		code        int
@@ -94,6 +104,27 @@ func TestLoadCurrentMembership(t *testing.T) {
 			found: true,
 			code:  http.StatusOK,
 		},
+		{
+			name:      "host_realm_overrides_session",
+			user:      user,
+			realm:     otherRealm,
+			hostRealm: realm,
+			memberships: []*database.Membership{
+				{RealmID: otherRealm.ID, Realm: otherRealm},
+				{RealmID: realm.ID, Realm: realm},
+			},
+			found: true,
+			code:  http.StatusOK,
+		},
+		{
+			name:      "host_realm_not_member",
+			user:      user,
+			hostRealm: realm,
+			memberships: []*database.Membership{
+				{RealmID: otherRealm.ID, Realm: otherRealm},
+			},
+			code: http.StatusUnauthorized,
+		},
 	}
 
 	for _, tc := range cases {
@@ -109,6 +140,9 @@ func TestLoadCurrentMembership(t *testing.T) {
 			if tc.memberships != nil {
 				ctx = controller.WithMemberships(ctx, tc.memberships)
 			}
+			if tc.hostRealm != nil {
+				ctx = controller.WithHostRealm(ctx, tc.hostRealm)
+			}
 
 			session := &sessions.Session{
 				Values: map[interface{}]interface{}{},
@@ -128,8 +162,11 @@ func TestLoadCurrentMembership(t *testing.T) {
 				ctx := r.Context()
 
 				if tc.found {
-					if m := controller.MembershipFromContext(ctx); m == nil {
+					m := controller.MembershipFromContext(ctx)
+					if m == nil {
 						t.Errorf("expected membership in context")
+					} else if tc.hostRealm != nil && m.RealmID != tc.hostRealm.ID {
+						t.Errorf("expected membership for realm %d, got %d", tc.hostRealm.ID, m.RealmID)
 					}
 				} else {
 					if m := controller.MembershipFromContext(ctx); m != nil {
@@ -149,6 +186,101 @@ func TestLoadCurrentMembership(t *testing.T) {
 	}
 }
 
+func TestLoadCurrentMembership_impersonation(t *testing.T) {
+	t.Parallel()
+
+	ctx := project.TestContext(t)
+	h, err := render.New(ctx, nil, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db, _ := testDatabaseInstance.NewDatabase(t, nil)
+	loadCurrentMembership := middleware.LoadCurrentMembership(db, h)
+
+	realm, err := db.FindRealm(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	admin := &database.User{
+		Email:       "admin@example.com",
+		Name:        "Admin",
+		SystemAdmin: true,
+	}
+	if err := db.SaveUser(admin, database.SystemTest); err != nil {
+		t.Fatal(err)
+	}
+
+	impersonation, err := db.StartRealmImpersonation(realm, database.RealmImpersonationReadOnly, time.Hour, "debugging", admin)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx = controller.WithUser(ctx, admin)
+	session := &sessions.Session{
+		Values: map[interface{}]interface{}{},
+	}
+	controller.StoreSessionRealm(session, realm)
+	ctx = controller.WithSession(ctx, session)
+
+	r := httptest.NewRequest(http.MethodGet, "/realm/settings?q=secret", nil)
+	r = r.Clone(ctx)
+	r.Header.Set("Accept", "application/json")
+
+	w := httptest.NewRecorder()
+
+	loadCurrentMembership(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		m := controller.MembershipFromContext(ctx)
+		if m == nil {
+			t.Fatal("expected membership in context")
+		}
+		if got, want := m.Permissions, database.RealmImpersonationReadOnly; got != want {
+			t.Errorf("expected permissions %v to be %v", got, want)
+		}
+		if m.Can(rbac.SettingsWrite) {
+			t.Errorf("expected read-only membership")
+		}
+
+		if got := controller.ImpersonationFromContext(ctx); got == nil || got.ID != impersonation.ID {
+			t.Errorf("expected impersonation %d in context, got %#v", impersonation.ID, got)
+		}
+	})).ServeHTTP(w, r)
+
+	if got, want := w.Code, http.StatusOK; got != want {
+		t.Errorf("Expected %d to be %d", got, want)
+	}
+
+	audits, _, err := realm.ListAudits(db, nil, database.WithAuditAction("viewed page while impersonating realm"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(audits), 1; got != want {
+		t.Fatalf("expected %d audits, got %d", want, got)
+	}
+	if got, want := audits[0].Diff, "+GET /realm/settings\n"; !strings.HasSuffix(got, want) {
+		t.Errorf("expected diff %q to end with %q", got, want)
+	}
+
+	// Once the impersonation ends, the realm is cleared from the session.
+	if err := db.EndRealmImpersonation(impersonation, admin); err != nil {
+		t.Fatal(err)
+	}
+
+	w = httptest.NewRecorder()
+	loadCurrentMembership(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if m := controller.MembershipFromContext(r.Context()); m != nil {
+			t.Errorf("expected no membership in context")
+		}
+	})).ServeHTTP(w, r)
+
+	if id := controller.RealmIDFromSession(session); id != 0 {
+		t.Errorf("expected realm to be cleared from session")
+	}
+}
+
 func TestRequireMembership(t *testing.T) {
 	t.Parallel()
 
@@ -208,3 +340,72 @@ func TestRequireMembership(t *testing.T) {
 		})
 	}
 }
+
+func TestRequirePermissions(t *testing.T) {
+	t.Parallel()
+
+	ctx := project.TestContext(t)
+	h, err := render.New(ctx, nil, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	requirePermissions := middleware.RequirePermissions(h, rbac.StatsRead|rbac.UserRead)
+
+	cases := []struct {
+		name       string
+		membership *database.Membership
+		code       int
+	}{
+		{
+			name:       "missing",
+			membership: nil,
+			code:       http.StatusBadRequest,
+		},
+		{
+			name:       "no_permissions",
+			membership: &database.Membership{},
+			code:       http.StatusUnauthorized,
+		},
+		{
+			name:       "other_permissions",
+			membership: &database.Membership{Permissions: rbac.CodeIssue},
+			code:       http.StatusUnauthorized,
+		},
+		{
+			name:       "one_of",
+			membership: &database.Membership{Permissions: rbac.UserRead},
+			code:       http.StatusOK,
+		},
+		{
+			name:       "all",
+			membership: &database.Membership{Permissions: rbac.StatsRead | rbac.UserRead},
+			code:       http.StatusOK,
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			ctx := ctx
+			if tc.membership != nil {
+				ctx = controller.WithMembership(ctx, tc.membership)
+			}
+
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r = r.Clone(ctx)
+			r.Header.Set("Accept", "application/json")
+
+			w := httptest.NewRecorder()
+
+			requirePermissions(emptyHandler()).ServeHTTP(w, r)
+
+			if got, want := w.Code, tc.code; got != want {
+				t.Errorf("Expected %d to be %d", got, want)
+			}
+		})
+	}
+}
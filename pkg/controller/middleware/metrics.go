@@ -0,0 +1,59 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package middleware
+
+import (
+	enobs "github.com/google/exposure-notifications-server/pkg/observability"
+	"github.com/google/exposure-notifications-verification-server/pkg/observability"
+
+	"go.opencensus.io/stats"
+	"go.opencensus.io/stats/view"
+	"go.opencensus.io/tag"
+)
+
+const metricPrefix = observability.MetricRoot + "/middleware"
+
+var (
+	mAppVersionChecks = stats.Int64(metricPrefix+"/app_version_checks", "mobile app version checks", stats.UnitDimensionless)
+
+	// appVersionResultTagKey is the outcome of the app version check: "OK",
+	// "REJECTED", or "UNKNOWN" if the app did not report a parsable version.
+	appVersionResultTagKey = tag.MustNewKey("app_version_result")
+
+	mClientFingerprintMismatches = stats.Int64(metricPrefix+"/client_fingerprint_mismatches", "device API key client fingerprint mismatches", stats.UnitDimensionless)
+
+	// clientFingerprintResultTagKey is the outcome of a client fingerprint
+	// mismatch: "LOGGED" or "REJECTED".
+	clientFingerprintResultTagKey = tag.MustNewKey("client_fingerprint_result")
+)
+
+func init() {
+	enobs.CollectViews([]*view.View{
+		{
+			Name:        metricPrefix + "/app_version_checks",
+			Description: "Number of mobile app version checks against the realm's minimum app version",
+			TagKeys:     append(observability.CommonTagKeys(), appVersionResultTagKey),
+			Measure:     mAppVersionChecks,
+			Aggregation: view.Count(),
+		},
+		{
+			Name:        metricPrefix + "/client_fingerprint_mismatches",
+			Description: "Number of device API requests that did not match the API key's allowed clients",
+			TagKeys:     append(observability.CommonTagKeys(), clientFingerprintResultTagKey),
+			Measure:     mClientFingerprintMismatches,
+			Aggregation: view.Count(),
+		},
+	}...)
+}
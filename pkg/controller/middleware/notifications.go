@@ -0,0 +1,62 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/google/exposure-notifications-server/pkg/logging"
+	"github.com/google/exposure-notifications-verification-server/pkg/controller"
+	"github.com/google/exposure-notifications-verification-server/pkg/database"
+	"github.com/google/exposure-notifications-verification-server/pkg/rbac"
+
+	"github.com/gorilla/mux"
+)
+
+// LoadUnreadNotifications loads the number of the current realm's
+// notifications that the current user has not read into the template map. It
+// does nothing for members that cannot read realm settings. Failures are
+// logged, but do not prevent the request from being served.
+//
+// This must come after LoadCurrentMembership so the membership is on the
+// context.
+func LoadUnreadNotifications(db *database.Database) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := r.Context()
+			logger := logging.FromContext(ctx).Named("middleware.LoadUnreadNotifications")
+
+			membership := controller.MembershipFromContext(ctx)
+			if membership == nil || !membership.Can(rbac.SettingsRead) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			count, err := membership.Realm.CountUnreadRealmNotifications(db, membership.User)
+			if err != nil {
+				logger.Errorw("failed to count unread notifications", "error", err)
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			m := controller.TemplateMapFromContext(ctx)
+			m["unreadNotifications"] = count
+			ctx = controller.WithTemplateMap(ctx, m)
+			r = r.Clone(ctx)
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,105 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package middleware_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/exposure-notifications-verification-server/internal/envstest"
+	"github.com/google/exposure-notifications-verification-server/internal/project"
+	"github.com/google/exposure-notifications-verification-server/pkg/controller"
+	"github.com/google/exposure-notifications-verification-server/pkg/controller/middleware"
+	"github.com/google/exposure-notifications-verification-server/pkg/database"
+	"github.com/google/exposure-notifications-verification-server/pkg/rbac"
+)
+
+func TestLoadUnreadNotifications(t *testing.T) {
+	t.Parallel()
+
+	ctx := project.TestContext(t)
+	harness := envstest.NewServerConfig(t, testDatabaseInstance)
+	db := harness.Database
+
+	realm, err := db.FindRealm(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	user := &database.User{
+		Email: "notifications@example.com",
+		Name:  "Notifications",
+	}
+	if err := db.SaveUser(user, database.SystemTest); err != nil {
+		t.Fatal(err)
+	}
+
+	notification := &database.RealmNotification{
+		RealmID: realm.ID,
+		Kind:    database.RealmNotificationKindOperator,
+		Title:   "Hello",
+		Body:    "World",
+	}
+	if _, err := db.PostRealmNotification(notification); err != nil {
+		t.Fatal(err)
+	}
+
+	serve := func(tb testing.TB, permissions rbac.Permission) (int64, bool) {
+		tb.Helper()
+
+		var got int64
+		var ok bool
+		next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			m := controller.TemplateMapFromContext(r.Context())
+			var v interface{}
+			if v, ok = m["unreadNotifications"]; ok {
+				got = v.(int64)
+			}
+		})
+
+		ctx := controller.WithMembership(ctx, &database.Membership{
+			Realm:       realm,
+			User:        user,
+			Permissions: permissions,
+		})
+		r := httptest.NewRequest(http.MethodGet, "/", nil).Clone(ctx)
+		w := httptest.NewRecorder()
+
+		middleware.LoadUnreadNotifications(db)(next).ServeHTTP(w, r)
+		return got, ok
+	}
+
+	if _, ok := serve(t, rbac.CodeIssue); ok {
+		t.Errorf("expected no unread count without settings permissions")
+	}
+
+	count, ok := serve(t, rbac.SettingsRead)
+	if !ok {
+		t.Fatalf("expected unread count to be set")
+	}
+	if got, want := count, int64(1); got != want {
+		t.Errorf("expected %d to be %d", got, want)
+	}
+
+	if err := db.MarkRealmNotificationRead(notification, user); err != nil {
+		t.Fatal(err)
+	}
+
+	count, _ = serve(t, rbac.SettingsRead)
+	if got, want := count, int64(0); got != want {
+		t.Errorf("expected %d to be %d", got, want)
+	}
+}
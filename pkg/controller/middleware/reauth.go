@@ -0,0 +1,105 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/google/exposure-notifications-verification-server/internal/auth"
+	"github.com/google/exposure-notifications-verification-server/pkg/controller"
+	"github.com/google/exposure-notifications-verification-server/pkg/database"
+	"github.com/google/exposure-notifications-verification-server/pkg/render"
+
+	"github.com/gorilla/mux"
+)
+
+// RequireRecentAuth requires the user to have signed in or re-authenticated
+// within maxAge before performing a sensitive action. If they have not, they
+// are redirected to re-authenticate and returned to the page they came from.
+// The first request made with each re-authentication is audited. If maxAge is
+// 0, the check is disabled.
+//
+// MUST first run RequireAuth to populate the user.
+func RequireRecentAuth(authProvider auth.Provider, db *database.Database, h *render.Renderer, maxAge time.Duration) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if maxAge <= 0 {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			ctx := r.Context()
+
+			session := controller.SessionFromContext(ctx)
+			if session == nil {
+				controller.MissingSession(w, r, h)
+				return
+			}
+
+			currentUser := controller.UserFromContext(ctx)
+			if currentUser == nil {
+				controller.MissingUser(w, r, h)
+				return
+			}
+
+			authenticatedAt, err := authProvider.AuthenticatedAt(ctx, session)
+			if err != nil {
+				controller.InternalError(w, r, h, err)
+				return
+			}
+
+			if time.Since(authenticatedAt) > maxAge {
+				controller.RedirectToReauth(w, r, h, reauthRedirect(r))
+				return
+			}
+
+			// Audit the first use of this re-authentication.
+			if !controller.ElevationAuditedFromSession(session).Equal(authenticatedAt.Truncate(time.Second)) {
+				var realmID uint
+				if membership := controller.MembershipFromContext(ctx); membership != nil {
+					realmID = membership.RealmID
+				}
+
+				action := fmt.Sprintf("%s %s", r.Method, r.URL.Path)
+				if err := db.RecordUserElevation(currentUser, realmID, authenticatedAt, action); err != nil {
+					controller.InternalError(w, r, h, err)
+					return
+				}
+				controller.StoreSessionElevationAudited(session, authenticatedAt)
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// reauthRedirect returns the local path to return to after re-authenticating.
+// GET requests return to the requested page. Other requests cannot be
+// replayed, so they return to the referring page if it is on this host.
+func reauthRedirect(r *http.Request) string {
+	if r.Method == http.MethodGet {
+		return r.URL.RequestURI()
+	}
+
+	if ref := r.Header.Get("Referer"); ref != "" {
+		if u, err := url.Parse(ref); err == nil && (u.Host == "" || u.Host == r.Host) {
+			return u.RequestURI()
+		}
+	}
+	return "/"
+}
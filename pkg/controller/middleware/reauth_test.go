@@ -0,0 +1,179 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package middleware_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/exposure-notifications-verification-server/internal/auth"
+	"github.com/google/exposure-notifications-verification-server/internal/project"
+	"github.com/google/exposure-notifications-verification-server/pkg/controller"
+	"github.com/google/exposure-notifications-verification-server/pkg/controller/middleware"
+	"github.com/google/exposure-notifications-verification-server/pkg/database"
+	"github.com/google/exposure-notifications-verification-server/pkg/pagination"
+	"github.com/google/exposure-notifications-verification-server/pkg/render"
+	"github.com/gorilla/sessions"
+	"github.com/jinzhu/gorm"
+)
+
+func TestRequireRecentAuth(t *testing.T) {
+	t.Parallel()
+
+	ctx := project.TestContext(t)
+	h, err := render.New(ctx, nil, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	authProvider, err := auth.NewLocal(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		name            string
+		maxAge          time.Duration
+		authenticatedAt time.Time
+		method          string
+		referer         string
+		code            int
+		location        string
+		audited         bool
+	}{
+		{
+			name:            "disabled",
+			maxAge:          0,
+			authenticatedAt: time.Now().Add(-24 * time.Hour),
+			method:          http.MethodDelete,
+			code:            http.StatusOK,
+		},
+		{
+			name:            "recent",
+			maxAge:          15 * time.Minute,
+			authenticatedAt: time.Now().Add(-1 * time.Minute),
+			method:          http.MethodDelete,
+			code:            http.StatusOK,
+			audited:         true,
+		},
+		{
+			name:            "stale_get",
+			maxAge:          15 * time.Minute,
+			authenticatedAt: time.Now().Add(-1 * time.Hour),
+			method:          http.MethodGet,
+			code:            http.StatusSeeOther,
+			location:        "/login?redir=%2Frealm%2Fkeys%2F1",
+		},
+		{
+			name:            "stale_referer",
+			maxAge:          15 * time.Minute,
+			authenticatedAt: time.Now().Add(-1 * time.Hour),
+			method:          http.MethodDelete,
+			referer:         "http://example.com/realm/keys",
+			code:            http.StatusSeeOther,
+			location:        "/login?redir=%2Frealm%2Fkeys",
+		},
+		{
+			name:            "stale_foreign_referer",
+			maxAge:          15 * time.Minute,
+			authenticatedAt: time.Now().Add(-1 * time.Hour),
+			method:          http.MethodDelete,
+			referer:         "https://attacker.example.net/realm/keys",
+			code:            http.StatusSeeOther,
+			location:        "/login?redir=%2F",
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			db, _ := testDatabaseInstance.NewDatabase(t, nil)
+			requireRecentAuth := middleware.RequireRecentAuth(authProvider, db, h, tc.maxAge)
+
+			session := &sessions.Session{}
+			if err := authProvider.StoreSession(ctx, session, &auth.SessionInfo{
+				Data: map[string]interface{}{
+					"email":            "you@example.com",
+					"email_verified":   true,
+					"mfa_enabled":      true,
+					"revoked":          false,
+					"authenticated_at": tc.authenticatedAt,
+				},
+			}); err != nil {
+				t.Fatal(err)
+			}
+
+			ctx := ctx
+			ctx = controller.WithSession(ctx, session)
+			ctx = controller.WithUser(ctx, &database.User{
+				Model: gorm.Model{ID: 1},
+				Email: "you@example.com",
+			})
+
+			r := httptest.NewRequest(tc.method, "http://example.com/realm/keys/1", nil)
+			r = r.Clone(ctx)
+			r.Header.Set("Accept", "text/html")
+			if tc.referer != "" {
+				r.Header.Set("Referer", tc.referer)
+			}
+
+			w := httptest.NewRecorder()
+			requireRecentAuth(emptyHandler()).ServeHTTP(w, r)
+			w.Flush()
+
+			if got, want := w.Code, tc.code; got != want {
+				t.Errorf("Expected %d to be %d", got, want)
+			}
+			if got, want := w.Header().Get("Location"), tc.location; got != want {
+				t.Errorf("Expected location %q to be %q", got, want)
+			}
+
+			audits, _, err := db.ListAudits(pagination.UnlimitedResults)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var count int
+			for _, audit := range audits {
+				if audit.Action == "elevated session" {
+					count++
+				}
+			}
+			if got, want := count > 0, tc.audited; got != want {
+				t.Errorf("Expected audited to be %t, got %d audits", want, count)
+			}
+
+			// A second request with the same authentication is not audited again.
+			if tc.audited {
+				before := len(audits)
+
+				w := httptest.NewRecorder()
+				requireRecentAuth(emptyHandler()).ServeHTTP(w, r)
+
+				audits, _, err := db.ListAudits(pagination.UnlimitedResults)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got, want := len(audits), before; got != want {
+					t.Errorf("Expected %d audits, got %d", want, got)
+				}
+			}
+		})
+	}
+}
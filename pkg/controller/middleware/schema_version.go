@@ -0,0 +1,49 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/google/exposure-notifications-verification-server/pkg/api"
+	"github.com/google/exposure-notifications-verification-server/pkg/render"
+	"github.com/gorilla/mux"
+)
+
+// ProcessSchemaVersion advertises the current API schema version on every
+// response. If the client declared the schema version it expects via the
+// schema version header and the server does not support that version, the
+// request is rejected instead of risking a silent misinterpretation of the
+// payload. Clients that do not send the header are unaffected.
+func ProcessSchemaVersion(h *render.Renderer) mux.MiddlewareFunc {
+	current := strconv.FormatUint(uint64(api.SchemaVersion), 10)
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set(api.SchemaVersionHeader, current)
+
+			if v := r.Header.Get(api.SchemaVersionHeader); v != "" {
+				if _, err := api.ParseSchemaVersion(v); err != nil {
+					h.RenderJSON(w, http.StatusBadRequest,
+						api.Error(err).WithCode(api.ErrUnsupportedSchemaVersion))
+					return
+				}
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
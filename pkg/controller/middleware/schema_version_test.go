@@ -0,0 +1,99 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package middleware_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/google/exposure-notifications-verification-server/internal/project"
+	"github.com/google/exposure-notifications-verification-server/pkg/api"
+	"github.com/google/exposure-notifications-verification-server/pkg/controller/middleware"
+	"github.com/google/exposure-notifications-verification-server/pkg/render"
+)
+
+func TestProcessSchemaVersion(t *testing.T) {
+	t.Parallel()
+
+	ctx := project.TestContext(t)
+	h, err := render.New(ctx, nil, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	processSchemaVersion := middleware.ProcessSchemaVersion(h)
+
+	current := strconv.FormatUint(uint64(api.SchemaVersion), 10)
+
+	cases := []struct {
+		name   string
+		header string
+		code   int
+	}{
+		{
+			name:   "no_header",
+			header: "",
+			code:   http.StatusOK,
+		},
+		{
+			name:   "current",
+			header: current,
+			code:   http.StatusOK,
+		},
+		{
+			name:   "not_a_number",
+			header: "banana",
+			code:   http.StatusBadRequest,
+		},
+		{
+			name:   "too_new",
+			header: strconv.FormatUint(uint64(api.SchemaVersion+1), 10),
+			code:   http.StatusBadRequest,
+		},
+		{
+			name:   "too_old",
+			header: "0",
+			code:   http.StatusBadRequest,
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			r := httptest.NewRequest(http.MethodPost, "/", nil)
+			r = r.Clone(ctx)
+			r.Header.Set("Content-Type", "application/json")
+			if tc.header != "" {
+				r.Header.Set(api.SchemaVersionHeader, tc.header)
+			}
+
+			w := httptest.NewRecorder()
+			processSchemaVersion(emptyHandler()).ServeHTTP(w, r)
+			w.Flush()
+
+			if got, want := w.Code, tc.code; got != want {
+				t.Errorf("expected %d to be %d: %s", got, want, w.Body.String())
+			}
+			if got, want := w.Header().Get(api.SchemaVersionHeader), current; got != want {
+				t.Errorf("expected %q to be %q", got, want)
+			}
+		})
+	}
+}
@@ -15,12 +15,88 @@
 package middleware
 
 import (
+	"net/http"
+	"strings"
+
 	"github.com/gorilla/mux"
 	"github.com/unrolled/secure"
 )
 
+const (
+	headerCSP           = "Content-Security-Policy"
+	headerCSPReportOnly = "Content-Security-Policy-Report-Only"
+)
+
+// ContentSecurityPolicyConfig is a Content-Security-Policy to send with
+// responses.
+type ContentSecurityPolicyConfig struct {
+	// Policy is the policy, without a report-uri directive. If empty, no policy
+	// is sent.
+	Policy string
+
+	// ReportOnly sends the policy as Content-Security-Policy-Report-Only, so
+	// violations are reported but not blocked.
+	ReportOnly bool
+
+	// ReportURI is the optional path or URL to which browsers send violation
+	// reports.
+	ReportURI string
+}
+
+// header returns the header name and value for the policy.
+func (c *ContentSecurityPolicyConfig) header() (string, string) {
+	if c == nil || c.Policy == "" {
+		return "", ""
+	}
+
+	value := strings.TrimSuffix(strings.TrimSpace(c.Policy), ";")
+	if c.ReportURI != "" {
+		value += "; report-uri " + c.ReportURI
+	}
+
+	if c.ReportOnly {
+		return headerCSPReportOnly, value
+	}
+	return headerCSP, value
+}
+
+// ServerContentSecurityPolicy returns the default policy for the UI server.
+// Inline scripts and styles are still permitted because the templates rely on
+// them heavily.
+func ServerContentSecurityPolicy(firebaseAuthDomain string) string {
+	return strings.Join([]string{
+		"default-src 'self'",
+		"script-src 'self' 'unsafe-inline' https://www.gstatic.com https://cdnjs.cloudflare.com https://www.google.com https://apis.google.com",
+		"style-src 'self' 'unsafe-inline' https://www.gstatic.com https://cdnjs.cloudflare.com",
+		"img-src 'self' data: https://www.gstatic.com",
+		"font-src 'self' data: https://cdnjs.cloudflare.com",
+		"connect-src 'self' https://*.googleapis.com",
+		"frame-src https://" + firebaseAuthDomain + " https://www.google.com",
+		"frame-ancestors 'none'",
+		"object-src 'none'",
+		"base-uri 'self'",
+		"form-action 'self'",
+	}, "; ")
+}
+
+// SecureHeadersOption is an option to SecureHeaders.
+type SecureHeadersOption func(o *secure.Options)
+
+// WithContentSecurityPolicy sets the default Content-Security-Policy for all
+// routes. Individual routes can override it with ContentSecurityPolicy.
+func WithContentSecurityPolicy(csp *ContentSecurityPolicyConfig) SecureHeadersOption {
+	return func(o *secure.Options) {
+		switch name, value := csp.header(); name {
+		case headerCSP:
+			o.ContentSecurityPolicy = value
+		case headerCSPReportOnly:
+			o.ContentSecurityPolicyReportOnly = value
+		}
+	}
+}
+
 // SecureHeaders sets a bunch of default secure headers that our servers should have.
-func SecureHeaders(devMode bool, serverType string) mux.MiddlewareFunc {
+func SecureHeaders(devMode bool, serverType string, opts ...SecureHeadersOption) mux.MiddlewareFunc {
 	options := secure.Options{
 		BrowserXssFilter:     false,
 		ContentTypeNosniff:   true,
@@ -35,5 +111,28 @@ func SecureHeaders(devMode bool, serverType string) mux.MiddlewareFunc {
 		STSSeconds:           315360000,
 	}
 
+	for _, opt := range opts {
+		opt(&options)
+	}
+
 	return secure.New(options).Handler
 }
+
+// ContentSecurityPolicy replaces the Content-Security-Policy set by
+// SecureHeaders for the routes it wraps. A nil or empty policy removes the
+// policy for those routes.
+func ContentSecurityPolicy(csp *ContentSecurityPolicyConfig) mux.MiddlewareFunc {
+	name, value := csp.header()
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Del(headerCSP)
+			w.Header().Del(headerCSPReportOnly)
+			if name != "" {
+				w.Header().Set(name, value)
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,82 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package middleware_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/exposure-notifications-verification-server/pkg/controller/middleware"
+)
+
+func TestContentSecurityPolicy(t *testing.T) {
+	t.Parallel()
+
+	secureHeaders := middleware.SecureHeaders(true, "html",
+		middleware.WithContentSecurityPolicy(&middleware.ContentSecurityPolicyConfig{
+			Policy:     "default-src 'self'",
+			ReportOnly: true,
+			ReportURI:  "/csp-report",
+		}))
+
+	cases := []struct {
+		name       string
+		override   func(http.Handler) http.Handler
+		enforce    string
+		reportOnly string
+	}{
+		{
+			name:       "default",
+			reportOnly: "default-src 'self'; report-uri /csp-report",
+		},
+		{
+			name: "override",
+			override: middleware.ContentSecurityPolicy(&middleware.ContentSecurityPolicyConfig{
+				Policy: "default-src 'none';",
+			}),
+			enforce: "default-src 'none'",
+		},
+		{
+			name:     "removed",
+			override: middleware.ContentSecurityPolicy(nil),
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			handler := emptyHandler()
+			if tc.override != nil {
+				handler = tc.override(handler)
+			}
+
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			w := httptest.NewRecorder()
+
+			secureHeaders(handler).ServeHTTP(w, r)
+
+			if got, want := w.Header().Get("Content-Security-Policy"), tc.enforce; got != want {
+				t.Errorf("expected policy %q to be %q", got, want)
+			}
+			if got, want := w.Header().Get("Content-Security-Policy-Report-Only"), tc.reportOnly; got != want {
+				t.Errorf("expected report-only policy %q to be %q", got, want)
+			}
+		})
+	}
+}
@@ -22,6 +22,7 @@ import (
 
 	"github.com/google/exposure-notifications-server/pkg/logging"
 	"github.com/google/exposure-notifications-verification-server/pkg/controller"
+	"github.com/google/exposure-notifications-verification-server/pkg/cookiestore"
 	"github.com/google/exposure-notifications-verification-server/pkg/render"
 	"github.com/hashicorp/go-multierror"
 
@@ -103,6 +104,14 @@ func buildHandler(store sessions.Store, name string, splitValues []interface{},
 				splitSessions[key] = joinSplitSessionCookie(r, store, key, session)
 			}
 
+			// Sessions signed by a previous cookie key are re-issued under the
+			// current key when the session is saved below. This happens on every
+			// request, so sessions migrate gradually as users are active instead of
+			// being invalidated all at once when the previous key is retired.
+			if generation := cookiestore.KeyGeneration(session); generation > 0 {
+				logger.Debugw("reissuing session from previous cookie key", "generation", generation)
+			}
+
 			// Save the flash in the template map.
 			m := controller.TemplateMapFromContext(ctx)
 			m["flash"] = controller.Flash(session)
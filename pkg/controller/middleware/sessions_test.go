@@ -15,6 +15,7 @@
 package middleware_test
 
 import (
+	"bytes"
 	"net/http"
 	"net/http/httptest"
 	"testing"
@@ -22,6 +23,7 @@ import (
 	"github.com/google/exposure-notifications-verification-server/internal/project"
 	"github.com/google/exposure-notifications-verification-server/pkg/controller"
 	"github.com/google/exposure-notifications-verification-server/pkg/controller/middleware"
+	"github.com/google/exposure-notifications-verification-server/pkg/cookiestore"
 	"github.com/google/exposure-notifications-verification-server/pkg/render"
 	"github.com/gorilla/sessions"
 )
@@ -55,3 +57,70 @@ func TestRequireSession(t *testing.T) {
 		}
 	})).ServeHTTP(w, r)
 }
+
+func TestRequireSession_ReissuesPreviousKey(t *testing.T) {
+	t.Parallel()
+
+	ctx := project.TestContext(t)
+	h, err := render.New(ctx, nil, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	name := "test-session"
+	oldKey := bytes.Repeat([]byte{'a'}, 96)
+	newKey := bytes.Repeat([]byte{'b'}, 96)
+
+	storeFor := func(keys ...[]byte) sessions.Store {
+		return cookiestore.New(func() ([][]byte, error) {
+			return keys, nil
+		}, nil)
+	}
+
+	// Issue a session under the old key.
+	oldStore := storeFor(oldKey)
+	session := sessions.NewSession(oldStore, name)
+	session.Values["foo"] = "bar"
+	session.Options = &sessions.Options{Path: "/", MaxAge: 3600}
+
+	w := httptest.NewRecorder()
+	if err := oldStore.Save(httptest.NewRequest(http.MethodGet, "/", nil), w, session); err != nil {
+		t.Fatal(err)
+	}
+	oldCookies := w.Result().Cookies()
+	if len(oldCookies) != 1 {
+		t.Fatalf("expected 1 cookie, got %d", len(oldCookies))
+	}
+
+	// Serve a request with a store where the old key is a previous generation.
+	requireSession := middleware.RequireNamedSession(storeFor(newKey, oldKey), name, []interface{}{}, h)
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r = r.Clone(ctx)
+	r.Header.Set("Accept", "application/json")
+	r.AddCookie(oldCookies[0])
+
+	w = httptest.NewRecorder()
+	requireSession(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		session := controller.SessionFromContext(r.Context())
+		if got, want := cookiestore.KeyGeneration(session), 1; got != want {
+			t.Errorf("expected generation %d to be %d", got, want)
+		}
+	})).ServeHTTP(w, r)
+
+	// The re-issued cookie must be readable with only the new key.
+	r = httptest.NewRequest(http.MethodGet, "/", nil)
+	for _, c := range w.Result().Cookies() {
+		r.AddCookie(c)
+	}
+	reissued, err := storeFor(newKey).New(r, name)
+	if err != nil {
+		t.Fatalf("expected session to be re-issued under the new key: %s", err)
+	}
+	if got, want := reissued.Values["foo"], "bar"; got != want {
+		t.Errorf("expected %q to be %q", got, want)
+	}
+	if got, want := cookiestore.KeyGeneration(reissued), 0; got != want {
+		t.Errorf("expected generation %d to be %d", got, want)
+	}
+}
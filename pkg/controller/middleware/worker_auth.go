@@ -0,0 +1,116 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/google/exposure-notifications-server/pkg/logging"
+	"github.com/google/exposure-notifications-verification-server/pkg/config"
+	"github.com/google/exposure-notifications-verification-server/pkg/controller"
+	"github.com/google/exposure-notifications-verification-server/pkg/render"
+	"google.golang.org/api/idtoken"
+
+	"github.com/gorilla/mux"
+)
+
+// IDTokenValidator validates OIDC ID tokens. It is satisfied by
+// *idtoken.Validator.
+type IDTokenValidator interface {
+	Validate(ctx context.Context, token, audience string) (*idtoken.Payload, error)
+}
+
+// RequireWorkerAuth returns middleware that authenticates requests to an
+// internal worker with an OIDC ID token, as configured. If authentication is
+// not enabled, requests are passed through unchanged.
+func RequireWorkerAuth(ctx context.Context, cfg *config.WorkerAuthConfig, h *render.Renderer) (mux.MiddlewareFunc, error) {
+	if !cfg.Enabled() {
+		logger := logging.FromContext(ctx).Named("middleware.RequireWorkerAuth")
+		logger.Warnw("worker authentication is disabled, do not expose this service publicly")
+
+		return func(next http.Handler) http.Handler {
+			return next
+		}, nil
+	}
+
+	validator, err := idtoken.NewValidator(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create id token validator: %w", err)
+	}
+	return RequireIDToken(validator, cfg.Audience, cfg.AllowedEmails, h), nil
+}
+
+// RequireIDToken returns middleware that requires a bearer ID token for the
+// given audience in the Authorization header. If allowedEmails is not empty,
+// the token's verified email must be in the list.
+func RequireIDToken(v IDTokenValidator, audience string, allowedEmails []string, h *render.Renderer) mux.MiddlewareFunc {
+	allowed := make(map[string]struct{}, len(allowedEmails))
+	for _, email := range allowedEmails {
+		if email = strings.ToLower(strings.TrimSpace(email)); email != "" {
+			allowed[email] = struct{}{}
+		}
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := r.Context()
+			logger := logging.FromContext(ctx).Named("middleware.RequireIDToken")
+
+			token, ok := bearerToken(r)
+			if !ok {
+				logger.Warnw("missing bearer token on worker request", "path", r.URL.Path)
+				controller.Unauthorized(w, r, h)
+				return
+			}
+
+			payload, err := v.Validate(ctx, token, audience)
+			if err != nil {
+				logger.Warnw("invalid id token on worker request", "path", r.URL.Path, "error", err)
+				controller.Unauthorized(w, r, h)
+				return
+			}
+
+			if len(allowed) > 0 {
+				email, _ := payload.Claims["email"].(string)
+				verified, _ := payload.Claims["email_verified"].(bool)
+				if _, ok := allowed[strings.ToLower(email)]; !ok || !verified {
+					logger.Warnw("id token email is not allowed on worker request",
+						"path", r.URL.Path,
+						"email", email,
+						"email_verified", verified)
+					controller.Unauthorized(w, r, h)
+					return
+				}
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// bearerToken returns the token from an "Authorization: Bearer <token>"
+// header.
+func bearerToken(r *http.Request) (string, bool) {
+	auth := r.Header.Get("Authorization")
+	if len(auth) < 7 || !strings.EqualFold(auth[:7], "bearer ") {
+		return "", false
+	}
+
+	token := strings.TrimSpace(auth[7:])
+	return token, token != ""
+}
@@ -0,0 +1,135 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package middleware_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/exposure-notifications-verification-server/internal/project"
+	"github.com/google/exposure-notifications-verification-server/pkg/controller/middleware"
+	"github.com/google/exposure-notifications-verification-server/pkg/render"
+	"google.golang.org/api/idtoken"
+)
+
+// testIDTokenValidator accepts tokens that are keys in its map, for the
+// configured audience.
+type testIDTokenValidator struct {
+	audience string
+	tokens   map[string]map[string]interface{}
+}
+
+func (v *testIDTokenValidator) Validate(_ context.Context, token, audience string) (*idtoken.Payload, error) {
+	claims, ok := v.tokens[token]
+	if !ok {
+		return nil, fmt.Errorf("invalid token")
+	}
+	if audience != v.audience {
+		return nil, fmt.Errorf("wrong audience")
+	}
+	return &idtoken.Payload{Audience: audience, Claims: claims}, nil
+}
+
+func TestRequireIDToken(t *testing.T) {
+	t.Parallel()
+
+	ctx := project.TestContext(t)
+	h, err := render.New(ctx, nil, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	validator := &testIDTokenValidator{
+		audience: "https://worker.example.com",
+		tokens: map[string]map[string]interface{}{
+			"scheduler":  {"email": "Scheduler@example.iam.gserviceaccount.com", "email_verified": true},
+			"unverified": {"email": "scheduler@example.iam.gserviceaccount.com", "email_verified": false},
+			"other":      {"email": "other@example.iam.gserviceaccount.com", "email_verified": true},
+		},
+	}
+
+	cases := []struct {
+		name          string
+		auth          string
+		allowedEmails []string
+		code          int
+	}{
+		{
+			name: "missing",
+			code: http.StatusUnauthorized,
+		},
+		{
+			name: "not_bearer",
+			auth: "Basic scheduler",
+			code: http.StatusUnauthorized,
+		},
+		{
+			name: "invalid",
+			auth: "Bearer nope",
+			code: http.StatusUnauthorized,
+		},
+		{
+			name: "valid",
+			auth: "Bearer other",
+			code: http.StatusOK,
+		},
+		{
+			name:          "allowed_email",
+			auth:          "bearer scheduler",
+			allowedEmails: []string{"scheduler@example.iam.gserviceaccount.com"},
+			code:          http.StatusOK,
+		},
+		{
+			name:          "unverified_email",
+			auth:          "Bearer unverified",
+			allowedEmails: []string{"scheduler@example.iam.gserviceaccount.com"},
+			code:          http.StatusUnauthorized,
+		},
+		{
+			name:          "other_email",
+			auth:          "Bearer other",
+			allowedEmails: []string{"scheduler@example.iam.gserviceaccount.com"},
+			code:          http.StatusUnauthorized,
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			requireIDToken := middleware.RequireIDToken(validator, validator.audience, tc.allowedEmails, h)
+
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r = r.Clone(ctx)
+			r.Header.Set("Accept", "application/json")
+			if tc.auth != "" {
+				r.Header.Set("Authorization", tc.auth)
+			}
+
+			w := httptest.NewRecorder()
+
+			requireIDToken(emptyHandler()).ServeHTTP(w, r)
+
+			if got, want := w.Code, tc.code; got != want {
+				t.Errorf("Expected %d to be %d", got, want)
+			}
+		})
+	}
+}
@@ -23,7 +23,9 @@ import (
 	"time"
 
 	"github.com/google/exposure-notifications-server/pkg/logging"
+	"github.com/google/exposure-notifications-verification-server/internal/project"
 	"github.com/google/exposure-notifications-verification-server/pkg/config"
+	"github.com/google/exposure-notifications-verification-server/pkg/controller/jobstatus"
 	"github.com/google/exposure-notifications-verification-server/pkg/database"
 	"github.com/google/exposure-notifications-verification-server/pkg/observability"
 	"github.com/google/exposure-notifications-verification-server/pkg/pagination"
@@ -72,6 +74,7 @@ func (c *Controller) HandleModel() http.Handler {
 		}
 		if !ok {
 			logger.Debugw("skipping (too early)")
+			jobstatus.RecordFreshness(ctx, c.db, jobstatus.JobModeler)
 			c.h.RenderJSON(w, http.StatusOK, fmt.Errorf("too early"))
 			return
 		}
@@ -80,6 +83,7 @@ func (c *Controller) HandleModel() http.Handler {
 		realms, _, err := c.db.ListRealms(pagination.UnlimitedResults)
 		if err != nil {
 			logger.Errorw("failed to list realms", "error", err)
+			jobstatus.Record(ctx, c.db, jobstatus.JobModeler, 0, err)
 			c.h.RenderJSON(w, http.StatusInternalServerError, err)
 			return
 		}
@@ -98,10 +102,12 @@ func (c *Controller) HandleModel() http.Handler {
 
 		if errs := merr.WrappedErrors(); len(errs) > 0 {
 			logger.Errorw("failed to rebuild models", "errors", errs)
+			jobstatus.Record(ctx, c.db, jobstatus.JobModeler, 0, merr)
 			c.h.RenderJSON(w, http.StatusInternalServerError, errs)
 			return
 		}
 
+		jobstatus.Record(ctx, c.db, jobstatus.JobModeler, int64(len(realms)), nil)
 		stats.Record(ctx, mSuccess.M(1))
 		c.h.RenderJSON(w, http.StatusOK, nil)
 	})
@@ -244,9 +250,9 @@ func (c *Controller) rebuildAnomaliesModel(ctx context.Context, realm *database.
 		return nil
 	}
 
-	// Remove the first entry - that's the most recent date which is likely an
-	// incomplete UTC day. Also remove the second entry, since that's the first
-	// full UTC day and it's what we'll use to compute the "current" ratio.
+	// Remove the first entry - that's today in the realm's stats timezone, which
+	// is incomplete. Also remove the second entry, since that's the first full
+	// day and it's what we'll use to compute the "current" ratio.
 	lastCompleteDay, realmStats := realmStats[1], realmStats[2:]
 
 	// Get the last 30 days of stats in which codes have been issued, ignoring any
@@ -315,6 +321,19 @@ func (c *Controller) rebuildAnomaliesModel(ctx context.Context, realm *database.
 	if realm.CodesClaimedRatioAnomalous() {
 		ctx = observability.WithRealmID(ctx, uint64(realm.ID))
 		stats.Record(ctx, mCodesClaimedRatioAnomaly.M(1))
+
+		if _, err := c.db.PostRealmNotification(&database.RealmNotification{
+			RealmID:   realm.ID,
+			Kind:      database.RealmNotificationKindCodesClaimedRatio,
+			DedupeKey: lastCompleteDay.Date.Format(project.RFC3339Date),
+			Title:     "Anomalous codes claimed ratio",
+			Body: fmt.Sprintf("On %s, %.1f%% of issued codes were claimed, compared to an average of %.1f%%. "+
+				"This can indicate a problem with code delivery, such as SMS errors, or with the mobile app.",
+				lastCompleteDay.Date.Format(project.RFC3339Date), lastCodes*100, codesMean*100),
+			Link: "/realm/stats",
+		}); err != nil {
+			logger.Errorw("failed to post anomaly notification", "error", err)
+		}
 	}
 
 	return nil
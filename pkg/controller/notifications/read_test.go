@@ -0,0 +1,187 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package notifications_test
+
+import (
+	"net/http"
+	"strconv"
+	"testing"
+
+	"github.com/google/exposure-notifications-verification-server/internal/envstest"
+	"github.com/google/exposure-notifications-verification-server/internal/project"
+	"github.com/google/exposure-notifications-verification-server/pkg/controller"
+	"github.com/google/exposure-notifications-verification-server/pkg/controller/notifications"
+	"github.com/google/exposure-notifications-verification-server/pkg/database"
+	"github.com/google/exposure-notifications-verification-server/pkg/rbac"
+	"github.com/gorilla/mux"
+	"github.com/gorilla/sessions"
+)
+
+func TestHandleMarkRead(t *testing.T) {
+	t.Parallel()
+
+	ctx := project.TestContext(t)
+	harness := envstest.NewServerConfig(t, testDatabaseInstance)
+	db := harness.Database
+
+	realm := database.NewRealmWithDefaults("notifications")
+	if err := db.SaveRealm(realm, database.SystemTest); err != nil {
+		t.Fatal(err)
+	}
+
+	user := &database.User{
+		Email: "notifications@example.com",
+		Name:  "Notifications",
+	}
+	if err := db.SaveUser(user, database.SystemTest); err != nil {
+		t.Fatal(err)
+	}
+
+	notification := &database.RealmNotification{
+		RealmID: realm.ID,
+		Kind:    database.RealmNotificationKindOperator,
+		Title:   "Hello",
+		Body:    "World",
+	}
+	if _, err := db.PostRealmNotification(notification); err != nil {
+		t.Fatal(err)
+	}
+
+	c := notifications.New(db, harness.Renderer)
+	handler := harness.WithCommonMiddlewares(c.HandleMarkRead())
+
+	t.Run("middleware", func(t *testing.T) {
+		t.Parallel()
+
+		envstest.ExerciseSessionMissing(t, handler)
+		envstest.ExerciseMembershipMissing(t, handler)
+		envstest.ExercisePermissionMissing(t, handler)
+	})
+
+	t.Run("not_found", func(t *testing.T) {
+		t.Parallel()
+
+		ctx := controller.WithSession(ctx, &sessions.Session{})
+		ctx = controller.WithMembership(ctx, &database.Membership{
+			Realm:       realm,
+			User:        user,
+			Permissions: rbac.SettingsRead,
+		})
+
+		w, r := envstest.BuildFormRequest(ctx, t, http.MethodPost, "/", nil)
+		r = mux.SetURLVars(r, map[string]string{"id": "123456"})
+		handler.ServeHTTP(w, r)
+
+		if got, want := w.Code, http.StatusNotFound; got != want {
+			t.Errorf("Expected %d to be %d", got, want)
+		}
+	})
+
+	t.Run("marks_read", func(t *testing.T) {
+		t.Parallel()
+
+		ctx := controller.WithSession(ctx, &sessions.Session{})
+		ctx = controller.WithMembership(ctx, &database.Membership{
+			Realm:       realm,
+			User:        user,
+			Permissions: rbac.SettingsRead,
+		})
+
+		w, r := envstest.BuildFormRequest(ctx, t, http.MethodPost, "/", nil)
+		r = mux.SetURLVars(r, map[string]string{"id": strconv.FormatUint(uint64(notification.ID), 10)})
+		handler.ServeHTTP(w, r)
+
+		if got, want := w.Code, http.StatusSeeOther; got != want {
+			t.Errorf("Expected %d to be %d", got, want)
+		}
+
+		count, err := realm.CountUnreadRealmNotifications(db, user)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := count, int64(0); got != want {
+			t.Errorf("expected %d to be %d", got, want)
+		}
+	})
+}
+
+func TestHandleMarkAllRead(t *testing.T) {
+	t.Parallel()
+
+	ctx := project.TestContext(t)
+	harness := envstest.NewServerConfig(t, testDatabaseInstance)
+	db := harness.Database
+
+	realm := database.NewRealmWithDefaults("notifications")
+	if err := db.SaveRealm(realm, database.SystemTest); err != nil {
+		t.Fatal(err)
+	}
+
+	user := &database.User{
+		Email: "notifications@example.com",
+		Name:  "Notifications",
+	}
+	if err := db.SaveUser(user, database.SystemTest); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, title := range []string{"One", "Two"} {
+		if _, err := db.PostRealmNotification(&database.RealmNotification{
+			RealmID: realm.ID,
+			Kind:    database.RealmNotificationKindOperator,
+			Title:   title,
+			Body:    title,
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	c := notifications.New(db, harness.Renderer)
+	handler := harness.WithCommonMiddlewares(c.HandleMarkAllRead())
+
+	t.Run("middleware", func(t *testing.T) {
+		t.Parallel()
+
+		envstest.ExerciseSessionMissing(t, handler)
+		envstest.ExerciseMembershipMissing(t, handler)
+		envstest.ExercisePermissionMissing(t, handler)
+	})
+
+	t.Run("marks_read", func(t *testing.T) {
+		t.Parallel()
+
+		ctx := controller.WithSession(ctx, &sessions.Session{})
+		ctx = controller.WithMembership(ctx, &database.Membership{
+			Realm:       realm,
+			User:        user,
+			Permissions: rbac.SettingsRead,
+		})
+
+		w, r := envstest.BuildFormRequest(ctx, t, http.MethodPost, "/", nil)
+		handler.ServeHTTP(w, r)
+
+		if got, want := w.Code, http.StatusSeeOther; got != want {
+			t.Errorf("Expected %d to be %d", got, want)
+		}
+
+		count, err := realm.CountUnreadRealmNotifications(db, user)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := count, int64(0); got != want {
+			t.Errorf("expected %d to be %d", got, want)
+		}
+	})
+}
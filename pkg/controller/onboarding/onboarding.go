@@ -0,0 +1,138 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package onboarding contains the web controller for the realm invitation
+// setup wizard, which lets an invited public health authority admin complete
+// the settings of a pending realm.
+package onboarding
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/google/exposure-notifications-verification-server/pkg/controller"
+	"github.com/google/exposure-notifications-verification-server/pkg/database"
+	"github.com/google/exposure-notifications-verification-server/pkg/render"
+	"github.com/gorilla/mux"
+)
+
+const (
+	// StepRegion, StepSMS, StepCertificate, and StepReview are the wizard
+	// steps, in order.
+	StepRegion      = "region"
+	StepSMS         = "sms"
+	StepCertificate = "certificate"
+	StepReview      = "review"
+)
+
+// Steps are the wizard steps, in order.
+var Steps = []string{StepRegion, StepSMS, StepCertificate, StepReview}
+
+type Controller struct {
+	db *database.Database
+	h  *render.Renderer
+}
+
+func New(db *database.Database, h *render.Renderer) *Controller {
+	return &Controller{
+		db: db,
+		h:  h,
+	}
+}
+
+// nextStep returns the step after the given step, or the last step.
+func nextStep(step string) string {
+	for i, s := range Steps {
+		if s == step && i+1 < len(Steps) {
+			return Steps[i+1]
+		}
+	}
+	return StepReview
+}
+
+// validStep returns the step if it is a wizard step, or the first step.
+func validStep(step string) string {
+	for _, s := range Steps {
+		if s == step {
+			return s
+		}
+	}
+	return StepRegion
+}
+
+// loadInvitation finds the invitation for the token in the URL. The
+// invitation is only returned if it belongs to the signed in user; otherwise
+// the response is written and false is returned.
+func (c *Controller) loadInvitation(w http.ResponseWriter, r *http.Request) (*database.RealmInvitation, *database.Realm, bool) {
+	ctx := r.Context()
+
+	currentUser := controller.UserFromContext(ctx)
+	if currentUser == nil {
+		controller.MissingUser(w, r, c.h)
+		return nil, nil, false
+	}
+
+	invitation, err := c.db.FindRealmInvitationByToken(mux.Vars(r)["token"])
+	if err != nil {
+		if database.IsNotFound(err) {
+			controller.NotFound(w, r, c.h)
+			return nil, nil, false
+		}
+
+		controller.InternalError(w, r, c.h, err)
+		return nil, nil, false
+	}
+
+	// The link alone is not enough; the invitee must be signed in.
+	if !strings.EqualFold(currentUser.Email, invitation.Email) {
+		controller.Unauthorized(w, r, c.h)
+		return nil, nil, false
+	}
+
+	realm, err := invitation.Realm(c.db)
+	if err != nil {
+		controller.InternalError(w, r, c.h, err)
+		return nil, nil, false
+	}
+	return invitation, realm, true
+}
+
+// render renders the wizard at the given step.
+func (c *Controller) render(ctx context.Context, w http.ResponseWriter, r *http.Request,
+	invitation *database.RealmInvitation, realm *database.Realm, smsConfig *database.SMSConfig, step string,
+) {
+	// The system SMS configuration's from numbers are only offered if the
+	// realm is allowed to use it.
+	var smsFromNumbers []*database.SMSFromNumber
+	if realm.CanUseSystemSMSConfig {
+		var err error
+		smsFromNumbers, err = c.db.SMSFromNumbers()
+		if err != nil {
+			controller.InternalError(w, r, c.h, err)
+			return
+		}
+	}
+
+	m := controller.TemplateMapFromContext(ctx)
+	m.Title("Set up %s", realm.Name)
+	m["invitation"] = invitation
+	m["realm"] = realm
+	m["smsConfig"] = smsConfig
+	m["smsFromNumbers"] = smsFromNumbers
+	m["token"] = mux.Vars(r)["token"]
+	m["steps"] = Steps
+	m["step"] = step
+	c.h.RenderHTML(w, "onboarding/wizard", m)
+}
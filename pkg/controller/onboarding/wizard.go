@@ -0,0 +1,200 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package onboarding
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/google/exposure-notifications-verification-server/internal/project"
+	"github.com/google/exposure-notifications-verification-server/pkg/controller"
+	"github.com/google/exposure-notifications-verification-server/pkg/database"
+	"github.com/google/exposure-notifications-verification-server/pkg/sms"
+)
+
+// HandleShow renders the wizard. The step is chosen with the "step" query
+// parameter. Once the invitation has been submitted, only the review step is
+// shown.
+func (c *Controller) HandleShow() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		invitation, realm, ok := c.loadInvitation(w, r)
+		if !ok {
+			return
+		}
+
+		if invitation.Status() == database.RealmInvitationStatusApproved {
+			http.Redirect(w, r, "/login/select-realm", http.StatusSeeOther)
+			return
+		}
+
+		smsConfig, err := c.realmSMSConfig(realm)
+		if err != nil {
+			controller.InternalError(w, r, c.h, err)
+			return
+		}
+
+		step := validStep(r.FormValue("step"))
+		if invitation.Status() != database.RealmInvitationStatusPending {
+			step = StepReview
+		}
+		c.render(ctx, w, r, invitation, realm, smsConfig, step)
+	})
+}
+
+// HandleUpdate saves one step of the wizard and moves to the next step. The
+// review step submits the invitation for approval.
+func (c *Controller) HandleUpdate() http.Handler {
+	type FormData struct {
+		Step string `form:"step"`
+
+		RegionCode string `form:"region_code"`
+
+		UseSystemSMSConfig bool   `form:"use_system_sms_config"`
+		SMSFromNumberID    uint   `form:"sms_from_number_id"`
+		TwilioAccountSid   string `form:"twilio_account_sid"`
+		TwilioAuthToken    string `form:"twilio_auth_token"`
+		TwilioFromNumber   string `form:"twilio_from_number"`
+
+		CertificateIssuer   string `form:"certificate_issuer"`
+		CertificateAudience string `form:"certificate_audience"`
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		session := controller.SessionFromContext(ctx)
+		if session == nil {
+			controller.MissingSession(w, r, c.h)
+			return
+		}
+		flash := controller.Flash(session)
+
+		invitation, realm, ok := c.loadInvitation(w, r)
+		if !ok {
+			return
+		}
+
+		smsConfig, err := c.realmSMSConfig(realm)
+		if err != nil {
+			controller.InternalError(w, r, c.h, err)
+			return
+		}
+
+		var form FormData
+		if err := controller.BindForm(w, r, &form); err != nil {
+			flash.Error("Failed to process form: %v", err)
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			c.render(ctx, w, r, invitation, realm, smsConfig, validStep(form.Step))
+			return
+		}
+		step := validStep(form.Step)
+
+		switch status := invitation.Status(); status {
+		case database.RealmInvitationStatusPending:
+		case database.RealmInvitationStatusExpired:
+			flash.Error("This invitation has expired. Contact your server operator for a new invitation.")
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			c.render(ctx, w, r, invitation, realm, smsConfig, step)
+			return
+		default:
+			flash.Error("This invitation has already been submitted.")
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			c.render(ctx, w, r, invitation, realm, smsConfig, StepReview)
+			return
+		}
+
+		switch step {
+		case StepRegion:
+			realm.RegionCode = form.RegionCode
+		case StepSMS:
+			realm.UseSystemSMSConfig = realm.CanUseSystemSMSConfig && form.UseSystemSMSConfig
+			if realm.UseSystemSMSConfig {
+				realm.SMSFromNumberID = form.SMSFromNumberID
+			} else {
+				if smsConfig == nil {
+					smsConfig = &database.SMSConfig{RealmID: realm.ID}
+				}
+				smsConfig.ProviderType = sms.ProviderTypeTwilio
+				smsConfig.TwilioAccountSid = form.TwilioAccountSid
+				if form.TwilioAuthToken != project.PasswordSentinel {
+					smsConfig.TwilioAuthToken = form.TwilioAuthToken
+				}
+				smsConfig.TwilioFromNumber = form.TwilioFromNumber
+
+				if err := c.db.SaveSMSConfig(smsConfig); err != nil {
+					if database.IsValidationError(err) {
+						w.WriteHeader(http.StatusUnprocessableEntity)
+						c.render(ctx, w, r, invitation, realm, smsConfig, step)
+						return
+					}
+
+					controller.InternalError(w, r, c.h, err)
+					return
+				}
+			}
+		case StepCertificate:
+			realm.CertificateIssuer = form.CertificateIssuer
+			realm.CertificateAudience = form.CertificateAudience
+		case StepReview:
+			if err := c.db.SubmitRealmInvitation(invitation, invitation); err != nil {
+				if errors.Is(err, database.ErrRealmInvitationExpired) || errors.Is(err, database.ErrRealmInvitationSubmitted) {
+					flash.Error("Failed to submit: %v", err)
+					w.WriteHeader(http.StatusUnprocessableEntity)
+					c.render(ctx, w, r, invitation, realm, smsConfig, step)
+					return
+				}
+
+				controller.InternalError(w, r, c.h, err)
+				return
+			}
+
+			flash.Alert("Submitted %q for approval. You will have access to the realm once a system administrator approves it.", realm.Name)
+			http.Redirect(w, r, r.URL.Path, http.StatusSeeOther)
+			return
+		}
+
+		if err := c.db.SaveRealm(realm, invitation); err != nil {
+			if database.IsValidationError(err) {
+				w.WriteHeader(http.StatusUnprocessableEntity)
+				c.render(ctx, w, r, invitation, realm, smsConfig, step)
+				return
+			}
+
+			controller.InternalError(w, r, c.h, err)
+			return
+		}
+
+		http.Redirect(w, r, fmt.Sprintf("%s?step=%s", r.URL.Path, nextStep(step)), http.StatusSeeOther)
+	})
+}
+
+// realmSMSConfig returns the realm's own SMS configuration, or nil if it uses
+// the system configuration or has none.
+func (c *Controller) realmSMSConfig(realm *database.Realm) (*database.SMSConfig, error) {
+	smsConfig, err := realm.SMSConfig(c.db)
+	if err != nil {
+		if database.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if smsConfig.IsSystem {
+		return nil, nil
+	}
+	return smsConfig, nil
+}
@@ -0,0 +1,227 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/google/exposure-notifications-server/pkg/keys"
+	"github.com/google/exposure-notifications-server/pkg/logging"
+
+	"github.com/google/exposure-notifications-verification-server/pkg/cache"
+	"github.com/google/exposure-notifications-verification-server/pkg/database"
+	"github.com/google/exposure-notifications-verification-server/pkg/render"
+
+	"github.com/sethvargo/go-limiter"
+)
+
+const (
+	// ReadinessOK, ReadinessError, and ReadinessTimeout are the statuses of a
+	// dependency in the readiness response.
+	ReadinessOK      = "ok"
+	ReadinessError   = "error"
+	ReadinessTimeout = "timeout"
+
+	// ReadinessUnavailable is the overall status when any dependency is not ok.
+	ReadinessUnavailable = "unavailable"
+)
+
+// ReadinessProbe checks that a single dependency of the service is available.
+type ReadinessProbe struct {
+	// Name is the name of the dependency in the response and in the
+	// READINESS_TIMEOUTS configuration.
+	Name string
+
+	// Check returns an error if the dependency is unavailable.
+	Check func(ctx context.Context) error
+}
+
+// ReadinessResponse is the response of HandleReadyz.
+type ReadinessResponse struct {
+	Status       string                          `json:"status"`
+	Dependencies map[string]*ReadinessDependency `json:"dependencies"`
+}
+
+// ReadinessDependency is the status of a single dependency.
+type ReadinessDependency struct {
+	Status    string `json:"status"`
+	LatencyMs int64  `json:"latency_ms"`
+}
+
+// HandleReadyz runs all probes concurrently, each bounded by the timeout
+// timeoutFor returns for its name, and responds with the status of
+// each dependency. If any dependency is unavailable, it responds with a 503 so
+// load balancers stop routing to this instance. Probe errors are logged, but
+// not returned, since the endpoint is unauthenticated.
+func HandleReadyz(h *render.Renderer, timeoutFor func(name string) time.Duration, probes ...*ReadinessProbe) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		logger := logging.FromContext(ctx).Named("controller.HandleReadyz")
+
+		resp := &ReadinessResponse{
+			Status:       ReadinessOK,
+			Dependencies: make(map[string]*ReadinessDependency, len(probes)),
+		}
+
+		var mu sync.Mutex
+		var wg sync.WaitGroup
+		for _, probe := range probes {
+			probe := probe
+
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+
+				start := time.Now()
+				err := runProbe(ctx, timeoutFor(probe.Name), probe)
+				dep := &ReadinessDependency{
+					Status:    ReadinessOK,
+					LatencyMs: time.Since(start).Milliseconds(),
+				}
+				if err != nil {
+					dep.Status = ReadinessError
+					if errors.Is(err, context.DeadlineExceeded) {
+						dep.Status = ReadinessTimeout
+					}
+					logger.Warnw("readiness probe failed", "dependency", probe.Name, "error", err)
+				}
+
+				mu.Lock()
+				defer mu.Unlock()
+				resp.Dependencies[probe.Name] = dep
+				if dep.Status != ReadinessOK {
+					resp.Status = ReadinessUnavailable
+				}
+			}()
+		}
+		wg.Wait()
+
+		code := http.StatusOK
+		if resp.Status != ReadinessOK {
+			code = http.StatusServiceUnavailable
+		}
+		h.RenderJSON(w, code, resp)
+	})
+}
+
+// runProbe runs the probe's check with the given timeout. A check that ignores its
+// context is abandoned when the timeout expires.
+func runProbe(ctx context.Context, timeout time.Duration, probe *ReadinessProbe) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- probe.Check(ctx)
+	}()
+
+	select {
+	case err := <-errCh:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// DatabaseProbe checks that the database accepts connections.
+func DatabaseProbe(db *database.Database) *ReadinessProbe {
+	return &ReadinessProbe{
+		Name:  "database",
+		Check: db.Ping,
+	}
+}
+
+// CacheProbe checks that values can be written to and read from the cache.
+func CacheProbe(cacher cache.Cacher) *ReadinessProbe {
+	return &ReadinessProbe{
+		Name: "cache",
+		Check: func(ctx context.Context) error {
+			b := make([]byte, 8)
+			if _, err := rand.Read(b); err != nil {
+				return fmt.Errorf("failed to generate value: %w", err)
+			}
+			want := hex.EncodeToString(b)
+
+			key := &cache.Key{Namespace: "readyz", Key: want}
+			if err := cacher.Write(ctx, key, want, 30*time.Second); err != nil {
+				return fmt.Errorf("failed to write: %w", err)
+			}
+			defer func() { _ = cacher.Delete(context.Background(), key) }()
+
+			var got string
+			if err := cacher.Read(ctx, key, &got); err != nil {
+				return fmt.Errorf("failed to read: %w", err)
+			}
+			if got != want {
+				return fmt.Errorf("read value does not match")
+			}
+			return nil
+		},
+	}
+}
+
+// RateLimiterProbe checks that the rate limiter store can be read. It does not
+// consume any tokens.
+func RateLimiterProbe(store limiter.Store) *ReadinessProbe {
+	return &ReadinessProbe{
+		Name: "rate_limiter",
+		Check: func(ctx context.Context) error {
+			if _, _, err := store.Get(ctx, "readyz"); err != nil {
+				return fmt.Errorf("failed to read: %w", err)
+			}
+			return nil
+		},
+	}
+}
+
+// KeyManagerProbe checks that the key manager can encrypt and decrypt with the
+// database encryption key.
+func KeyManagerProbe(db *database.Database) *ReadinessProbe {
+	return &ReadinessProbe{
+		Name:  "key_manager",
+		Check: db.PingKeyManager,
+	}
+}
+
+// TokenSigningProbe checks that the active token signing key exists and its
+// public key can be loaded from the key manager.
+func TokenSigningProbe(db *database.Database, cacher cache.Cacher, kms keys.KeyManager) *ReadinessProbe {
+	return &ReadinessProbe{
+		Name: "token_signing_key",
+		Check: func(ctx context.Context) error {
+			key, err := db.ActiveTokenSigningKeyCached(ctx, cacher)
+			if err != nil {
+				return fmt.Errorf("failed to find active token signing key: %w", err)
+			}
+
+			signer, err := kms.NewSigner(ctx, key.KeyVersionID)
+			if err != nil {
+				return fmt.Errorf("failed to load token signing key: %w", err)
+			}
+			if signer.Public() == nil {
+				return fmt.Errorf("token signing key has no public key")
+			}
+			return nil
+		},
+	}
+}
@@ -0,0 +1,137 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/exposure-notifications-verification-server/internal/project"
+	"github.com/google/exposure-notifications-verification-server/pkg/render"
+)
+
+func TestHandleReadyz(t *testing.T) {
+	t.Parallel()
+
+	ctx := project.TestContext(t)
+
+	h, err := render.New(ctx, nil, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	timeoutFor := func(name string) time.Duration {
+		if name == "slow" {
+			return 10 * time.Millisecond
+		}
+		return time.Second
+	}
+
+	ok := &ReadinessProbe{
+		Name:  "ok",
+		Check: func(ctx context.Context) error { return nil },
+	}
+	failing := &ReadinessProbe{
+		Name:  "failing",
+		Check: func(ctx context.Context) error { return fmt.Errorf("secret connection string") },
+	}
+	slow := &ReadinessProbe{
+		Name: "slow",
+		Check: func(ctx context.Context) error {
+			<-ctx.Done()
+			return ctx.Err()
+		},
+	}
+
+	cases := []struct {
+		name   string
+		probes []*ReadinessProbe
+		code   int
+		status string
+		deps   map[string]string
+	}{
+		{
+			name:   "no_probes",
+			code:   http.StatusOK,
+			status: ReadinessOK,
+			deps:   map[string]string{},
+		},
+		{
+			name:   "all_ok",
+			probes: []*ReadinessProbe{ok},
+			code:   http.StatusOK,
+			status: ReadinessOK,
+			deps:   map[string]string{"ok": ReadinessOK},
+		},
+		{
+			name:   "error",
+			probes: []*ReadinessProbe{ok, failing},
+			code:   http.StatusServiceUnavailable,
+			status: ReadinessUnavailable,
+			deps:   map[string]string{"ok": ReadinessOK, "failing": ReadinessError},
+		},
+		{
+			name:   "timeout",
+			probes: []*ReadinessProbe{ok, slow},
+			code:   http.StatusServiceUnavailable,
+			status: ReadinessUnavailable,
+			deps:   map[string]string{"ok": ReadinessOK, "slow": ReadinessTimeout},
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			r := httptest.NewRequest(http.MethodGet, "/readyz", nil)
+			r = r.Clone(ctx)
+			w := httptest.NewRecorder()
+
+			HandleReadyz(h, timeoutFor, tc.probes...).ServeHTTP(w, r)
+
+			if got, want := w.Code, tc.code; got != want {
+				t.Errorf("expected %d to be %d: %s", got, want, w.Body.String())
+			}
+
+			var resp ReadinessResponse
+			if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+				t.Fatal(err)
+			}
+			if got, want := resp.Status, tc.status; got != want {
+				t.Errorf("expected %q to be %q", got, want)
+			}
+			if got, want := len(resp.Dependencies), len(tc.deps); got != want {
+				t.Errorf("expected %d dependencies to be %d", got, want)
+			}
+			for name, want := range tc.deps {
+				dep, ok := resp.Dependencies[name]
+				if !ok {
+					t.Errorf("missing dependency %q", name)
+					continue
+				}
+				if got := dep.Status; got != want {
+					t.Errorf("expected %q status %q to be %q", name, got, want)
+				}
+			}
+		})
+	}
+}
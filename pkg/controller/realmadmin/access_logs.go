@@ -0,0 +1,87 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package realmadmin
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/google/exposure-notifications-verification-server/pkg/controller"
+	"github.com/google/exposure-notifications-verification-server/pkg/database"
+	"github.com/google/exposure-notifications-verification-server/pkg/pagination"
+	"github.com/google/exposure-notifications-verification-server/pkg/rbac"
+)
+
+// QueryActorSearch is the query key for filtering by actor.
+const QueryActorSearch = "actor"
+
+// HandleAccessLogs renders the reads of PII in the realm.
+func (c *Controller) HandleAccessLogs() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		session := controller.SessionFromContext(ctx)
+		if session == nil {
+			controller.MissingSession(w, r, c.h)
+			return
+		}
+
+		membership := controller.MembershipFromContext(ctx)
+		if membership == nil {
+			controller.MissingMembership(w, r, c.h)
+			return
+		}
+		if !membership.Can(rbac.AuditRead) {
+			controller.Unauthorized(w, r, c.h)
+			return
+		}
+		currentRealm := membership.Realm
+
+		from := r.FormValue(QueryFromSearch)
+		to := r.FormValue(QueryToSearch)
+		actor := r.FormValue(QueryActorSearch)
+		scopes := []database.Scope{
+			database.WithDataAccessTime(from, to),
+			database.WithDataAccessActorID(actor),
+		}
+
+		pageParams, err := pagination.FromRequest(r)
+		if err != nil {
+			controller.BadRequest(w, r, c.h)
+			return
+		}
+
+		logs, paginator, err := currentRealm.ListDataAccessLogs(c.db, pageParams, scopes...)
+		if err != nil {
+			controller.InternalError(w, r, c.h, err)
+			return
+		}
+
+		c.renderAccessLogs(ctx, w, logs, paginator, from, to, actor)
+	})
+}
+
+func (c *Controller) renderAccessLogs(ctx context.Context, w http.ResponseWriter,
+	logs []*database.DataAccessLog, paginator *pagination.Paginator, from, to, actor string,
+) {
+	m := controller.TemplateMapFromContext(ctx)
+	m.Title("Access log")
+	m["logs"] = logs
+	m["paginator"] = paginator
+	m[QueryFromSearch] = from
+	m[QueryToSearch] = to
+	m[QueryActorSearch] = actor
+	c.h.RenderHTML(w, "realmadmin/access-logs", m)
+}
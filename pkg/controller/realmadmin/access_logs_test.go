@@ -0,0 +1,140 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package realmadmin_test
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/google/exposure-notifications-verification-server/internal/envstest"
+	"github.com/google/exposure-notifications-verification-server/internal/project"
+	"github.com/google/exposure-notifications-verification-server/pkg/controller"
+	"github.com/google/exposure-notifications-verification-server/pkg/controller/middleware"
+	"github.com/google/exposure-notifications-verification-server/pkg/controller/realmadmin"
+	"github.com/google/exposure-notifications-verification-server/pkg/database"
+	"github.com/google/exposure-notifications-verification-server/pkg/rbac"
+	"github.com/gorilla/sessions"
+	"github.com/jinzhu/gorm"
+)
+
+func TestHandleAccessLogs(t *testing.T) {
+	t.Parallel()
+
+	ctx := project.TestContext(t)
+	harness := envstest.NewServerConfig(t, testDatabaseInstance)
+
+	c := realmadmin.New(harness.Config, harness.Database, harness.RateLimiter, harness.Renderer, harness.Cacher)
+	handler := harness.WithCommonMiddlewares(c.HandleAccessLogs())
+
+	realm, err := harness.Database.FindRealm(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reader := &database.User{Model: gorm.Model{ID: 42}, Name: "Privacy Reader", Email: "reader@example.com"}
+	if err := harness.Database.RecordDataAccess(reader, "exported users", realm, realm.ID, 3,
+		database.DataAccessFieldEmail); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("middleware", func(t *testing.T) {
+		t.Parallel()
+
+		envstest.ExerciseSessionMissing(t, handler)
+		envstest.ExerciseMembershipMissing(t, handler)
+		envstest.ExercisePermissionMissing(t, handler)
+		envstest.ExerciseBadPagination(t, &database.Membership{
+			Realm:       &database.Realm{},
+			User:        &database.User{},
+			Permissions: rbac.AuditRead,
+		}, handler)
+	})
+
+	t.Run("internal_error", func(t *testing.T) {
+		t.Parallel()
+
+		c := realmadmin.New(harness.Config, harness.BadDatabase, harness.RateLimiter, harness.Renderer, harness.Cacher)
+		handler := middleware.InjectCurrentPath()(c.HandleAccessLogs())
+
+		ctx := ctx
+		ctx = controller.WithSession(ctx, &sessions.Session{})
+		ctx = controller.WithMembership(ctx, &database.Membership{
+			Realm:       &database.Realm{},
+			User:        &database.User{},
+			Permissions: rbac.AuditRead,
+		})
+
+		w, r := envstest.BuildFormRequest(ctx, t, http.MethodGet, "/", nil)
+		handler.ServeHTTP(w, r)
+
+		if got, want := w.Code, http.StatusInternalServerError; got != want {
+			t.Errorf("Expected %d to be %d: %s", got, want, w.Body.String())
+		}
+	})
+
+	cases := []struct {
+		name  string
+		path  string
+		found bool
+	}{
+		{
+			name:  "lists",
+			path:  "/",
+			found: true,
+		},
+		{
+			name:  "filters_actor",
+			path:  "/?actor=users:42",
+			found: true,
+		},
+		{
+			name:  "filters_other_actor",
+			path:  "/?actor=users:43",
+			found: false,
+		},
+		{
+			name:  "filters_time",
+			path:  "/?from=2020-01-01&to=2020-12-31",
+			found: false,
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			ctx := ctx
+			ctx = controller.WithSession(ctx, &sessions.Session{})
+			ctx = controller.WithMembership(ctx, &database.Membership{
+				Realm:       realm,
+				User:        &database.User{},
+				Permissions: rbac.AuditRead,
+			})
+
+			w, r := envstest.BuildFormRequest(ctx, t, http.MethodGet, tc.path, nil)
+			handler.ServeHTTP(w, r)
+
+			if got, want := w.Code, http.StatusOK; got != want {
+				t.Fatalf("Expected %d to be %d: %s", got, want, w.Body.String())
+			}
+			if got, want := strings.Contains(w.Body.String(), "Privacy Reader"), tc.found; got != want {
+				t.Errorf("Expected found to be %t: %s", want, w.Body.String())
+			}
+		})
+	}
+}
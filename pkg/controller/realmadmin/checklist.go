@@ -0,0 +1,72 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package realmadmin
+
+import (
+	"net/http"
+
+	"github.com/google/exposure-notifications-verification-server/pkg/controller"
+	"github.com/google/exposure-notifications-verification-server/pkg/rbac"
+)
+
+// HandleChecklist renders the onboarding checklist for the current realm.
+func (c *Controller) HandleChecklist() http.Handler {
+	return c.handleChecklist(false)
+}
+
+// HandleChecklistJSON returns the onboarding checklist for the current realm as
+// JSON.
+func (c *Controller) HandleChecklistJSON() http.Handler {
+	return c.handleChecklist(true)
+}
+
+func (c *Controller) handleChecklist(asJSON bool) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		session := controller.SessionFromContext(ctx)
+		if session == nil {
+			controller.MissingSession(w, r, c.h)
+			return
+		}
+
+		membership := controller.MembershipFromContext(ctx)
+		if membership == nil {
+			controller.MissingMembership(w, r, c.h)
+			return
+		}
+		if !membership.Can(rbac.SettingsRead) {
+			controller.Unauthorized(w, r, c.h)
+			return
+		}
+		currentRealm := membership.Realm
+
+		checklist, err := currentRealm.Checklist(c.db)
+		if err != nil {
+			controller.InternalError(w, r, c.h, err)
+			return
+		}
+
+		if asJSON {
+			c.h.RenderJSON(w, http.StatusOK, checklist)
+			return
+		}
+
+		m := controller.TemplateMapFromContext(ctx)
+		m.Title("Getting started")
+		m["checklist"] = checklist
+		c.h.RenderHTML(w, "realmadmin/checklist", m)
+	})
+}
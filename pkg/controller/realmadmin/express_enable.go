@@ -57,6 +57,8 @@ func (c *Controller) HandleEnableExpress() http.Handler {
 		enxSettings := database.NewRealmWithDefaults("--")
 		currentRealm.EnableENExpress = true
 		currentRealm.CodeLength = enxSettings.CodeLength
+		currentRealm.CodeCharset = enxSettings.CodeCharset
+		currentRealm.CodeGroupSize = enxSettings.CodeGroupSize
 		currentRealm.CodeDuration = enxSettings.CodeDuration
 		currentRealm.LongCodeLength = enxSettings.LongCodeLength
 		currentRealm.LongCodeDuration = enxSettings.LongCodeDuration
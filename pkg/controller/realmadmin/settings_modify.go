@@ -18,6 +18,7 @@ import (
 	"fmt"
 	"net/http"
 	"sort"
+	"strconv"
 	"strings"
 	"time"
 
@@ -32,11 +33,15 @@ import (
 
 var (
 	shortCodeLengths            = []int{6, 7, 8}
+	codeCharsets                = []string{database.CodeCharsetNumeric, database.CodeCharsetAlphanumeric}
+	codeGroupSizes              = []int{0, 2, 3, 4}
 	longCodeLengths             = []int{12, 13, 14, 15, 16}
 	longCodeHours               = []int{}
 	mfaGracePeriod              = []int64{0, 1, 7, 30}
+	apiRateLimitBanMinutes      = []int64{0, 1, 5, 15, 60, 360, 1440}
 	passwordRotationPeriodDays  = []int{0, 30, 60, 90, 365}
 	passwordRotationWarningDays = []int{0, 1, 3, 5, 7, 30}
+	apiKeyInactivityDays        = []int{0, 30, 60, 90, 180, 365}
 )
 
 const (
@@ -44,6 +49,17 @@ const (
 
 	labelPrefix    = "sms_text_label_"
 	templatePrefix = "sms_text_template_"
+
+	// Localized email template variants are submitted as pairs of
+	// <prefix>locale_<i> and <prefix>text_<i>.
+	emailInvitePrefix        = "email_invite_"
+	emailPasswordResetPrefix = "email_password_reset_"
+	emailVerifyPrefix        = "email_verify_"
+
+	// Localized SMS templates and web strings are submitted as triples of
+	// <prefix>name_<i>, <prefix>locale_<i>, and <prefix>text_<i>.
+	smsLocalizedPrefix = "sms_localized_"
+	webStringPrefix    = "web_string_"
 )
 
 func init() {
@@ -59,10 +75,19 @@ type formData struct {
 	WelcomeMessage        string `form:"welcome_message"`
 	ContactEmailAddresses string `form:"contact_email_addresses"`
 
+	NotificationPhoneNumbers string `form:"notification_phone_numbers"`
+
 	AllowKeyServerStats       bool   `form:"allow_key_server_stats"`
 	KeyServerURLOverride      string `form:"key_server_url"`
 	KeyServerAudienceOverride string `form:"key_server_audience"`
 
+	StatsPrivacyMode      string  `form:"stats_privacy_mode"`
+	StatsPrivacyThreshold uint    `form:"stats_privacy_threshold"`
+	StatsPrivacyEpsilon   float64 `form:"stats_privacy_epsilon"`
+	StatsTimezone         string  `form:"stats_timezone"`
+
+	EnableStatusPage bool `form:"enable_status_page"`
+
 	Codes                   bool              `form:"codes"`
 	AllowedTestTypes        database.TestType `form:"allowed_test_types"`
 	AllowUserReport         bool              `form:"allow_user_report"`
@@ -72,22 +97,34 @@ type formData struct {
 	UserReportWebhookSecret string            `form:"user_report_webhook_secret"`
 	AllowBulkUpload         bool              `form:"allow_bulk"`
 	RequireDate             bool              `form:"require_date"`
+	ExternalCaseIDPattern   string            `form:"external_case_id_pattern"`
+	RequireExternalCaseID   bool              `form:"require_external_case_id"`
 	CodeLength              uint              `form:"code_length"`
+	CodeCharset             string            `form:"code_charset"`
+	CodeGroupSize           uint              `form:"code_group_size"`
 	CodeDurationMinutes     int64             `form:"code_duration"`
 	LongCodeLength          uint              `form:"long_code_length"`
 	LongCodeDurationHours   int64             `form:"long_code_duration"`
 
-	SMS                        bool               `form:"sms"`
-	UseSystemSMSConfig         bool               `form:"use_system_sms_config"`
-	SMSCountry                 string             `form:"sms_country"`
-	SMSFromNumberID            uint               `form:"sms_from_number_id"`
-	TwilioAccountSid           string             `form:"twilio_account_sid"`
-	TwilioAuthToken            string             `form:"twilio_auth_token"`
-	TwilioFromNumber           string             `form:"twilio_from_number"`
-	TwilioUserReportFromNumber string             `form:"twilio_user_report_from_number"`
-	SMSTextTemplate            string             `form:"-"`
-	SMSTextAlternateTemplates  map[string]*string `form:"-"`
-	SMSTextUserReportAppend    string             `form:"sms_text_user_report_append"`
+	IssuancePresets []*issuancePresetFormData `form:"issuance_presets"`
+
+	SMS                         bool               `form:"sms"`
+	UseSystemSMSConfig          bool               `form:"use_system_sms_config"`
+	SMSCountry                  string             `form:"sms_country"`
+	SMSAllowedCountries         string             `form:"sms_allowed_countries"`
+	SMSAllowedCountriesWarnOnly bool               `form:"sms_allowed_countries_warn_only"`
+	SMSFromNumberID             uint               `form:"sms_from_number_id"`
+	SMSProviderType             sms.ProviderType   `form:"sms_provider_type"`
+	TwilioAccountSid            string             `form:"twilio_account_sid"`
+	TwilioAuthToken             string             `form:"twilio_auth_token"`
+	TwilioFromNumber            string             `form:"twilio_from_number"`
+	TwilioUserReportFromNumber  string             `form:"twilio_user_report_from_number"`
+	MessageBirdAccessKey        string             `form:"message_bird_access_key"`
+	MessageBirdOriginator       string             `form:"message_bird_originator"`
+	SMSTextTemplate             string             `form:"-"`
+	SMSTextAlternateTemplates   map[string]*string `form:"-"`
+	SMSTextUserReportAppend     string             `form:"sms_text_user_report_append"`
+	ENXLinkParams               string             `form:"enx_link_params"`
 
 	Email                      bool   `form:"email"`
 	UseSystemEmailConfig       bool   `form:"use_system_email_config"`
@@ -99,6 +136,14 @@ type formData struct {
 	EmailPasswordResetTemplate string `form:"password_reset_template"`
 	EmailVerifyTemplate        string `form:"email_verify_template"`
 
+	EmailInviteTemplates        map[string]*string `form:"-"`
+	EmailPasswordResetTemplates map[string]*string `form:"-"`
+	EmailVerifyTemplates        map[string]*string `form:"-"`
+
+	Localization              bool               `form:"localization"`
+	SMSTextLocalizedTemplates map[string]*string `form:"-"`
+	LocalizedWebStrings       map[string]*string `form:"-"`
+
 	Security                    bool   `form:"security"`
 	MFAMode                     int16  `form:"mfa_mode"`
 	MFARequiredGracePeriod      int64  `form:"mfa_grace_period"`
@@ -108,11 +153,27 @@ type formData struct {
 	AllowedCIDRsAdminAPI        string `form:"allowed_cidrs_adminapi"`
 	AllowedCIDRsAPIServer       string `form:"allowed_cidrs_apiserver"`
 	AllowedCIDRsServer          string `form:"allowed_cidrs_server"`
+	MinimumAppVersion           string `form:"minimum_app_version"`
+	APIRateLimitKeyMode         int16  `form:"api_rate_limit_key_mode"`
+	APIRateLimitBurst           uint   `form:"api_rate_limit_burst"`
+	APIRateLimitBanMinutes      int64  `form:"api_rate_limit_ban_minutes"`
+	APIKeyInactivityDays        uint   `form:"api_key_inactivity_days"`
 
 	AbusePrevention            bool    `form:"abuse_prevention"`
 	AbusePreventionEnabled     bool    `form:"abuse_prevention_enabled"`
 	AbusePreventionLimitFactor float32 `form:"abuse_prevention_limit_factor"`
 	AbusePreventionBurst       uint64  `form:"abuse_prevention_burst"`
+	DailyUserIssueLimit        uint    `form:"daily_user_issue_limit"`
+	DailyAPIKeyIssueLimit      uint    `form:"daily_api_key_issue_limit"`
+}
+
+// issuancePresetFormData is a single issuance preset row in the codes form.
+type issuancePresetFormData struct {
+	ID                    uint   `form:"id"`
+	Name                  string `form:"name"`
+	TestType              string `form:"test_type"`
+	SymptomDateOffsetDays string `form:"symptom_date_offset_days"`
+	SMSTemplateLabel      string `form:"sms_template_label"`
 }
 
 func (c *Controller) HandleSettings() http.Handler {
@@ -199,8 +260,15 @@ func (c *Controller) HandleSettings() http.Handler {
 
 			if c.config.Features.EnableEmailer {
 				currentRealm.ContactEmailAddresses = explodeSortAndDedupe(form.ContactEmailAddresses)
+				currentRealm.NotificationPhoneNumbers = explodeSortAndDedupe(form.NotificationPhoneNumbers)
 			}
 
+			currentRealm.StatsPrivacyMode = form.StatsPrivacyMode
+			currentRealm.StatsPrivacyThreshold = form.StatsPrivacyThreshold
+			currentRealm.StatsPrivacyEpsilon = form.StatsPrivacyEpsilon
+			currentRealm.StatsTimezone = form.StatsTimezone
+			currentRealm.EnableStatusPage = form.EnableStatusPage
+
 			if form.AllowKeyServerStats {
 				if statsConfig == nil {
 					// There's no record or the existing record was the system config so we
@@ -237,6 +305,8 @@ func (c *Controller) HandleSettings() http.Handler {
 		if form.Codes {
 			currentRealm.AllowedTestTypes = form.AllowedTestTypes
 			currentRealm.RequireDate = form.RequireDate
+			currentRealm.ExternalCaseIDPattern = form.ExternalCaseIDPattern
+			currentRealm.RequireExternalCaseID = form.RequireExternalCaseID
 			currentRealm.AllowBulkUpload = form.AllowBulkUpload
 
 			currentRealm.UserReportWebhookURL = form.UserReportWebhookURL
@@ -255,6 +325,8 @@ func (c *Controller) HandleSettings() http.Handler {
 			// These fields can only be set if ENX is disabled
 			if !currentRealm.EnableENExpress {
 				currentRealm.CodeLength = form.CodeLength
+				currentRealm.CodeCharset = form.CodeCharset
+				currentRealm.CodeGroupSize = form.CodeGroupSize
 				currentRealm.CodeDuration.Duration = time.Duration(form.CodeDurationMinutes) * time.Minute
 				currentRealm.LongCodeLength = form.LongCodeLength
 				currentRealm.LongCodeDuration.Duration = time.Duration(form.LongCodeDurationHours) * time.Hour
@@ -271,9 +343,22 @@ func (c *Controller) HandleSettings() http.Handler {
 			parseSMSTextTemplates(r, &form)
 			currentRealm.UseSystemSMSConfig = form.UseSystemSMSConfig
 			currentRealm.SMSCountry = form.SMSCountry
+			currentRealm.SMSAllowedCountries = database.ToCountryList(form.SMSAllowedCountries)
+			currentRealm.SMSAllowedCountriesWarnOnly = form.SMSAllowedCountriesWarnOnly
 			currentRealm.SMSFromNumberID = form.SMSFromNumberID
 			currentRealm.SMSTextTemplate = form.SMSTextTemplate
 			currentRealm.SMSTextAlternateTemplates = postgres.Hstore(form.SMSTextAlternateTemplates)
+			if currentRealm.EnableENExpress {
+				currentRealm.ENXLinkParams = parseENXLinkParams(form.ENXLinkParams)
+			}
+
+			// Drop the localized variants of any templates that were removed.
+			for k := range currentRealm.SMSTextLocalizedTemplates {
+				_, label, _ := database.SplitLocalizedKey(k)
+				if _, ok := form.SMSTextAlternateTemplates[label]; !ok && label != database.DefaultTemplateLabel {
+					delete(currentRealm.SMSTextLocalizedTemplates, k)
+				}
+			}
 		}
 
 		// Email
@@ -282,6 +367,21 @@ func (c *Controller) HandleSettings() http.Handler {
 			currentRealm.EmailInviteTemplate = form.EmailInviteTemplate
 			currentRealm.EmailPasswordResetTemplate = form.EmailPasswordResetTemplate
 			currentRealm.EmailVerifyTemplate = form.EmailVerifyTemplate
+
+			form.EmailInviteTemplates = parseLocaleTemplates(r, emailInvitePrefix)
+			form.EmailPasswordResetTemplates = parseLocaleTemplates(r, emailPasswordResetPrefix)
+			form.EmailVerifyTemplates = parseLocaleTemplates(r, emailVerifyPrefix)
+			currentRealm.EmailInviteTemplates = postgres.Hstore(form.EmailInviteTemplates)
+			currentRealm.EmailPasswordResetTemplates = postgres.Hstore(form.EmailPasswordResetTemplates)
+			currentRealm.EmailVerifyTemplates = postgres.Hstore(form.EmailVerifyTemplates)
+		}
+
+		// Localization
+		if form.Localization {
+			form.SMSTextLocalizedTemplates = parseLocalizedValues(r, smsLocalizedPrefix)
+			form.LocalizedWebStrings = parseLocalizedValues(r, webStringPrefix)
+			currentRealm.SMSTextLocalizedTemplates = postgres.Hstore(form.SMSTextLocalizedTemplates)
+			currentRealm.LocalizedWebStrings = postgres.Hstore(form.LocalizedWebStrings)
 		}
 
 		// Security
@@ -318,6 +418,12 @@ func (c *Controller) HandleSettings() http.Handler {
 				return
 			}
 			currentRealm.AllowedCIDRsServer = allowedCIDRsServer
+
+			currentRealm.MinimumAppVersion = form.MinimumAppVersion
+			currentRealm.APIRateLimitKeyMode = database.RateLimitKeyMode(form.APIRateLimitKeyMode)
+			currentRealm.APIRateLimitBurst = form.APIRateLimitBurst
+			currentRealm.APIRateLimitBanDuration = database.FromDuration(time.Duration(form.APIRateLimitBanMinutes) * time.Minute)
+			currentRealm.APIKeyInactivityDays = form.APIKeyInactivityDays
 		}
 
 		// Abuse prevention
@@ -327,6 +433,8 @@ func (c *Controller) HandleSettings() http.Handler {
 
 			currentRealm.AbusePreventionEnabled = form.AbusePreventionEnabled
 			currentRealm.AbusePreventionLimitFactor = form.AbusePreventionLimitFactor
+			currentRealm.DailyUserIssueLimit = form.DailyUserIssueLimit
+			currentRealm.DailyAPIKeyIssueLimit = form.DailyAPIKeyIssueLimit
 		}
 
 		// If abuse prevention was just enabled, create the initial bucket so
@@ -364,29 +472,90 @@ func (c *Controller) HandleSettings() http.Handler {
 			return
 		}
 
+		// Issuance presets
+		if form.Codes {
+			presets := make([]*database.IssuancePreset, 0, len(form.IssuancePresets))
+			for _, v := range form.IssuancePresets {
+				// People do weird things in multi-forms. Skip rows without a name.
+				if v == nil || project.TrimSpace(v.Name) == "" {
+					continue
+				}
+
+				preset := &database.IssuancePreset{
+					ID:               v.ID,
+					Name:             v.Name,
+					TestType:         v.TestType,
+					SMSTemplateLabel: v.SMSTemplateLabel,
+				}
+				if raw := project.TrimSpace(v.SymptomDateOffsetDays); raw != "" {
+					days, err := strconv.ParseUint(raw, 10, 16)
+					if err != nil {
+						currentRealm.AddError("issuancePresets", fmt.Sprintf("%s: symptom date offset must be a number of days", v.Name))
+						w.WriteHeader(http.StatusUnprocessableEntity)
+						c.renderSettings(ctx, w, r, currentRealm, smsConfig, emailConfig, statsConfig, quotaLimit, quotaRemaining)
+						return
+					}
+					offset := uint(days)
+					preset.SymptomDateOffsetDays = &offset
+				}
+				presets = append(presets, preset)
+			}
+
+			if err := currentRealm.SaveIssuancePresets(c.db, presets, currentUser); err != nil {
+				if database.IsValidationError(err) {
+					for _, preset := range presets {
+						for _, msg := range preset.ErrorMessages() {
+							currentRealm.AddError("issuancePresets", fmt.Sprintf("%s: %s", preset.Name, msg))
+						}
+					}
+					w.WriteHeader(http.StatusUnprocessableEntity)
+					c.renderSettings(ctx, w, r, currentRealm, smsConfig, emailConfig, statsConfig, quotaLimit, quotaRemaining)
+					return
+				}
+
+				controller.InternalError(w, r, c.h, err)
+				return
+			}
+		}
+
 		// SMS
 		if form.SMS && !form.UseSystemSMSConfig {
-			if smsConfig != nil && !smsConfig.IsSystem {
-				// We have an existing record and the existing record is NOT the system
-				// record.
-				smsConfig.ProviderType = sms.ProviderTypeTwilio
+			providerType := form.SMSProviderType
+			if providerType != sms.ProviderTypeMessageBird {
+				providerType = sms.ProviderTypeTwilio
+			}
+
+			if smsConfig == nil || smsConfig.IsSystem {
+				// There's no record or the existing record was the system config so we
+				// want to create our own.
+				smsConfig = &database.SMSConfig{
+					RealmID: currentRealm.ID,
+				}
+			}
+
+			// Only the selected provider's credentials are kept.
+			smsConfig.ProviderType = providerType
+			switch providerType {
+			case sms.ProviderTypeMessageBird:
+				smsConfig.TwilioAccountSid = ""
+				smsConfig.TwilioAuthToken = ""
+				smsConfig.TwilioFromNumber = ""
+				smsConfig.TwilioUserReportFromNumber = ""
+
+				if form.MessageBirdAccessKey != project.PasswordSentinel {
+					smsConfig.MessageBirdAccessKey = form.MessageBirdAccessKey
+				}
+				smsConfig.MessageBirdOriginator = form.MessageBirdOriginator
+			default:
+				smsConfig.MessageBirdAccessKey = ""
+				smsConfig.MessageBirdOriginator = ""
+
 				smsConfig.TwilioAccountSid = form.TwilioAccountSid
 				if form.TwilioAuthToken != project.PasswordSentinel {
 					smsConfig.TwilioAuthToken = form.TwilioAuthToken
 				}
 				smsConfig.TwilioFromNumber = form.TwilioFromNumber
 				smsConfig.TwilioUserReportFromNumber = form.TwilioUserReportFromNumber
-			} else {
-				// There's no record or the existing record was the system config so we
-				// want to create our own.
-				smsConfig = &database.SMSConfig{
-					RealmID:                    currentRealm.ID,
-					ProviderType:               sms.ProviderTypeTwilio,
-					TwilioAccountSid:           form.TwilioAccountSid,
-					TwilioAuthToken:            form.TwilioAuthToken,
-					TwilioFromNumber:           form.TwilioFromNumber,
-					TwilioUserReportFromNumber: form.TwilioUserReportFromNumber,
-				}
 			}
 
 			if !smsConfig.IsSystem {
@@ -509,6 +678,93 @@ func parseSMSTextTemplates(r *http.Request, form *formData) {
 	}
 }
 
+// parseLocaleTemplates parses the localized template variants submitted with
+// the given prefix, keyed by locale. Rows without a locale or template are
+// skipped, so clearing either removes the variant.
+func parseLocaleTemplates(r *http.Request, prefix string) map[string]*string {
+	localePrefix := prefix + "locale_"
+	textPrefix := prefix + "text_"
+
+	// Associate by index
+	locales := make(map[string]string)
+	texts := make(map[string]string)
+	for k, v := range r.PostForm {
+		s := v[0]
+		if strings.HasPrefix(k, localePrefix) {
+			locales[k[len(localePrefix):]] = project.TrimSpace(s)
+		}
+		if strings.HasPrefix(k, textPrefix) {
+			texts[k[len(textPrefix):]] = s
+		}
+	}
+
+	templates := make(map[string]*string, len(locales))
+	for i, locale := range locales {
+		text := texts[i]
+		if locale == "" || project.TrimSpace(text) == "" {
+			continue
+		}
+		templates[locale] = &text
+	}
+	return templates
+}
+
+// parseLocalizedValues parses the localized values submitted with the given
+// prefix, keyed by database.LocalizedKey. Rows without a name, locale, or text
+// are skipped, so clearing any of them removes the value.
+func parseLocalizedValues(r *http.Request, prefix string) map[string]*string {
+	namePrefix := prefix + "name_"
+	localePrefix := prefix + "locale_"
+	textPrefix := prefix + "text_"
+
+	// Associate by index
+	names := make(map[string]string)
+	locales := make(map[string]string)
+	texts := make(map[string]string)
+	for k, v := range r.PostForm {
+		s := v[0]
+		switch {
+		case strings.HasPrefix(k, namePrefix):
+			names[k[len(namePrefix):]] = s
+		case strings.HasPrefix(k, localePrefix):
+			locales[k[len(localePrefix):]] = project.TrimSpace(s)
+		case strings.HasPrefix(k, textPrefix):
+			texts[k[len(textPrefix):]] = s
+		}
+	}
+
+	values := make(map[string]*string, len(names))
+	for i, name := range names {
+		locale, text := locales[i], texts[i]
+		if name == "" || locale == "" || project.TrimSpace(text) == "" {
+			continue
+		}
+		values[database.LocalizedKey(locale, name)] = &text
+	}
+	return values
+}
+
+// parseENXLinkParams parses ENX link parameters submitted as one name=value
+// pair per line. A line without "=" is kept with an empty value so that it
+// fails validation instead of being silently dropped.
+func parseENXLinkParams(in string) map[string]*string {
+	params := make(map[string]*string)
+	for _, line := range strings.Split(in, "\n") {
+		line = project.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+
+		parts := strings.SplitN(line, "=", 2)
+		var value string
+		if len(parts) == 2 {
+			value = parts[1]
+		}
+		params[project.TrimSpace(parts[0])] = &value
+	}
+	return params
+}
+
 // explodeSortAndDedupe explodes the given string on commas and newlines,
 // iterates over each result and removes spaces and commas, removes duplicates,
 // and returns a sorted result.
@@ -177,6 +177,56 @@ func TestHandleSettings(t *testing.T) {
 		}
 	})
 
+	t.Run("localization", func(t *testing.T) {
+		t.Parallel()
+
+		realm := database.NewRealmWithDefaults("localization")
+		realm.EnableENExpress = false
+		if err := harness.Database.SaveRealm(realm, database.SystemTest); err != nil {
+			t.Fatal(err)
+		}
+
+		ctx := ctx
+		ctx = controller.WithSession(ctx, &sessions.Session{})
+		ctx = controller.WithMembership(ctx, &database.Membership{
+			Realm:       realm,
+			User:        &database.User{},
+			Permissions: rbac.SettingsRead | rbac.SettingsWrite,
+		})
+
+		w, r := envstest.BuildFormRequest(ctx, t, http.MethodPut, "/", &url.Values{
+			"localization":           []string{"1"},
+			"sms_localized_name_0":   []string{database.DefaultTemplateLabel},
+			"sms_localized_locale_0": []string{"es_MX"},
+			"sms_localized_text_0":   []string{"Su código: [longcode] Vence en [longexpires] horas"},
+			"sms_localized_name_1":   []string{database.DefaultTemplateLabel},
+			"sms_localized_locale_1": []string{""},
+			"sms_localized_text_1":   []string{""},
+			"web_string_name_0":      []string{database.WebStringReportIntro},
+			"web_string_locale_0":    []string{"es"},
+			"web_string_text_0":      []string{"Informe su resultado"},
+		})
+		handler.ServeHTTP(w, r)
+
+		if got, want := w.Code, http.StatusSeeOther; got != want {
+			t.Errorf("Expected %d to be %d: %s", got, want, w.Body.String())
+		}
+
+		realm, err := harness.Database.FindRealm(realm.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := len(realm.SMSTextLocalizedTemplates), 1; got != want {
+			t.Fatalf("Expected %d to be %d", got, want)
+		}
+		if got, want := realm.SMSTextLocalizedTemplates[database.LocalizedKey("es-mx", database.DefaultTemplateLabel)], "Su código: [longcode] Vence en [longexpires] horas"; got == nil || *got != want {
+			t.Errorf("Expected %v to be %q", got, want)
+		}
+		if got, want := realm.LocalizedWebStrings[database.LocalizedKey("es", database.WebStringReportIntro)], "Informe su resultado"; got == nil || *got != want {
+			t.Errorf("Expected %v to be %q", got, want)
+		}
+	})
+
 	t.Run("security", func(t *testing.T) {
 		t.Parallel()
 
@@ -204,6 +254,10 @@ func TestHandleSettings(t *testing.T) {
 			"allowed_cidrs_adminapi":         []string{"0.0.0.0/0\n1.1.1.1/0"},
 			"allowed_cidrs_apiserver":        []string{"0.0.0.0/0\n2.2.2.2/0"},
 			"allowed_cidrs_server":           []string{"0.0.0.0/0\n3.3.3.3/0"},
+			"minimum_app_version":            []string{"1.4.0"},
+			"api_rate_limit_key_mode":        []string{"1"},
+			"api_rate_limit_burst":           []string{"500"},
+			"api_rate_limit_ban_minutes":     []string{"15"},
 		})
 		handler.ServeHTTP(w, r)
 
@@ -239,6 +293,18 @@ func TestHandleSettings(t *testing.T) {
 		if got, want := realm.AllowedCIDRsServer, pq.StringArray([]string{"0.0.0.0/0", "3.3.3.3/0"}); !reflect.DeepEqual(got, want) {
 			t.Errorf("Expected %q to be %q", got, want)
 		}
+		if got, want := realm.MinimumAppVersion, "1.4.0"; got != want {
+			t.Errorf("Expected %q to be %q", got, want)
+		}
+		if got, want := realm.APIRateLimitKeyMode, database.RateLimitKeyModeAPIKey; got != want {
+			t.Errorf("Expected %q to be %q", got, want)
+		}
+		if got, want := realm.APIRateLimitBurst, uint(500); got != want {
+			t.Errorf("Expected %d to be %d", got, want)
+		}
+		if got, want := realm.APIRateLimitBanDuration.Duration, 15*time.Minute; got != want {
+			t.Errorf("Expected %q to be %q", got, want)
+		}
 	})
 
 	t.Run("security/bad_cidrs", func(t *testing.T) {
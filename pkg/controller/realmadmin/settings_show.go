@@ -17,12 +17,22 @@ package realmadmin
 import (
 	"context"
 	"net/http"
+	"sort"
+	"strings"
+	"time"
 
 	"github.com/google/exposure-notifications-verification-server/pkg/controller"
 	"github.com/google/exposure-notifications-verification-server/pkg/database"
+	"github.com/jinzhu/gorm/dialects/postgres"
 )
 
-const defaultSMSTemplateLabel = "Default SMS template"
+const (
+	defaultSMSTemplateLabel = "Default SMS template"
+
+	// codeCollisionWindow is how far back code collisions are counted for the
+	// code length recommendation.
+	codeCollisionWindow = 7 * 24 * time.Hour
+)
 
 type TemplateData struct {
 	Label string
@@ -30,6 +40,15 @@ type TemplateData struct {
 	Index int
 }
 
+// LocalizedData is a single localized SMS template or web string row.
+type LocalizedData struct {
+	Key    string
+	Name   string
+	Locale string
+	Value  string
+	Index  int
+}
+
 func (c *Controller) renderSettings(
 	ctx context.Context, w http.ResponseWriter, r *http.Request, realm *database.Realm,
 	smsConfig *database.SMSConfig, emailConfig *database.EmailConfig, keyServerStats *database.KeyServerStats,
@@ -107,6 +126,18 @@ func (c *Controller) renderSettings(
 		}
 	}
 
+	issuancePresets, err := realm.ListIssuancePresets(c.db)
+	if err != nil {
+		controller.InternalError(w, r, c.h, err)
+		return
+	}
+
+	codeCollisions, err := realm.CodeCollisionStats(c.db, time.Now().UTC().Add(-codeCollisionWindow))
+	if err != nil {
+		controller.InternalError(w, r, c.h, err)
+		return
+	}
+
 	templates := map[int]TemplateData{
 		0: {
 			Label: defaultSMSTemplateLabel,
@@ -126,10 +157,18 @@ func (c *Controller) renderSettings(
 
 	m := c.config.Features.AddToTemplate(controller.TemplateMapFromContext(ctx))
 	m.Title("Realm settings")
+	m["emailInviteTemplates"] = localeTemplates(realm.EmailInviteTemplates)
+	m["emailPasswordResetTemplates"] = localeTemplates(realm.EmailPasswordResetTemplates)
+	m["emailVerifyTemplates"] = localeTemplates(realm.EmailVerifyTemplates)
+	m["smsTemplateLabels"] = realm.SMSTemplateLabels()
+	m["smsLocalizedTemplates"] = localizedValues(realm.SMSTextLocalizedTemplates)
+	m["webStringIDs"] = database.LocalizableWebStrings
+	m["localizedWebStrings"] = localizedValues(realm.LocalizedWebStrings)
 	m["realm"] = realm
 	m["smsConfig"] = smsConfig
 	m["smsFromNumbers"] = smsFromNumbers
 	m["smsTemplates"] = templates
+	m["issuancePresets"] = issuancePresets
 	m["emailConfig"] = emailConfig
 	m["statsConfig"] = keyServerStats
 	m["countries"] = database.Countries
@@ -144,8 +183,15 @@ func (c *Controller) renderSettings(
 	m["mfaGracePeriod"] = mfaGracePeriod
 	m["passwordRotateDays"] = passwordRotationPeriodDays
 	m["passwordWarnDays"] = passwordRotationWarningDays
+	// Valid settings for device API rate limits.
+	m["apiRateLimitBanMinutes"] = apiRateLimitBanMinutes
+	// Valid settings for API key inactivity.
+	m["apiKeyInactivityDays"] = apiKeyInactivityDays
+	m["apiKeyInactivityGraceDays"] = int(database.APIKeyInactivityGracePeriod.Hours() / 24)
 	// Valid settings for code parameters.
 	m["shortCodeLengths"] = shortCodeLengths
+	m["codeCharsets"] = codeCharsets
+	m["codeGroupSizes"] = codeGroupSizes
 	m["maxShortCodeMinutes"] = maxShortCodeMinutes
 	// Generate possible values for short code expiration minutes.
 	realmShortCodeMinutes := make([]int, 0, realm.ShortCodeMaxMinutes-5)
@@ -153,14 +199,90 @@ func (c *Controller) renderSettings(
 		realmShortCodeMinutes = append(realmShortCodeMinutes, i)
 	}
 	m["shortCodeMinutes"] = realmShortCodeMinutes
+	// Recommend a longer short code when collisions indicate the code space is
+	// too small for the realm's issuance volume.
+	m["codeCollisionRate"] = codeCollisions.Rate()
+	m["codeCollisionWarning"] = codeCollisions.Rate() > c.config.IssueConfig().CodeCollisionWarnRate
+	m["canIncreaseCodeLength"] = int(realm.CodeLength) < shortCodeLengths[len(shortCodeLengths)-1]
 	m["longCodeLengths"] = longCodeLengths
 	m["longCodeHours"] = longCodeHours
 	m["enxRedirectDomain"] = c.config.IssueConfig().ENExpressRedirectDomain
 
 	m["maxSMSTemplate"] = database.SMSTemplateMaxLength
+	m["enxLinkParams"] = enxLinkParamsString(realm.ENXLinkParams)
+	m["enxLinkParamsMax"] = database.ENXLinkParamsMax
 
 	m["quotaLimit"] = quotaLimit
 	m["quotaRemaining"] = quotaRemaining
 
 	c.h.RenderHTML(w, "realmadmin/edit", m)
 }
+
+// localeTemplates returns the localized template variants sorted by locale,
+// followed by a blank row for adding a new locale.
+func localeTemplates(variants postgres.Hstore) []TemplateData {
+	locales := make([]string, 0, len(variants))
+	for l := range variants {
+		locales = append(locales, l)
+	}
+	sort.Strings(locales)
+
+	templates := make([]TemplateData, 0, len(locales)+1)
+	for i, l := range locales {
+		var value string
+		if v := variants[l]; v != nil {
+			value = *v
+		}
+		templates = append(templates, TemplateData{Label: l, Value: value, Index: i})
+	}
+	return append(templates, TemplateData{Index: len(locales)})
+}
+
+// localizedValues returns the localized values sorted by name and locale,
+// followed by a blank row for adding a new value.
+func localizedValues(h postgres.Hstore) []LocalizedData {
+	values := make([]LocalizedData, 0, len(h)+1)
+	for k, v := range h {
+		locale, name, ok := database.SplitLocalizedKey(k)
+		if !ok {
+			continue
+		}
+
+		var value string
+		if v != nil {
+			value = *v
+		}
+		values = append(values, LocalizedData{Key: k, Name: name, Locale: locale, Value: value})
+	}
+	sort.Slice(values, func(i, j int) bool {
+		if values[i].Name != values[j].Name {
+			return values[i].Name < values[j].Name
+		}
+		return values[i].Locale < values[j].Locale
+	})
+
+	for i := range values {
+		values[i].Index = i
+	}
+	return append(values, LocalizedData{Index: len(values)})
+}
+
+// enxLinkParamsString renders the ENX link parameters as name=value lines,
+// sorted by name.
+func enxLinkParamsString(h postgres.Hstore) string {
+	names := make([]string, 0, len(h))
+	for k := range h {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
+	lines := make([]string, 0, len(names))
+	for _, k := range names {
+		var v string
+		if p := h[k]; p != nil {
+			v = *p
+		}
+		lines = append(lines, k+"="+v)
+	}
+	return strings.Join(lines, "\n")
+}
@@ -17,6 +17,7 @@ package realmadmin
 import (
 	"net/http"
 
+	"github.com/google/exposure-notifications-verification-server/pkg/api"
 	"github.com/google/exposure-notifications-verification-server/pkg/controller"
 	"github.com/google/exposure-notifications-verification-server/pkg/database"
 	"github.com/google/exposure-notifications-verification-server/pkg/rbac"
@@ -50,18 +51,54 @@ func (c *Controller) HandleStats() http.Handler {
 		}
 		hasKeyServerStats := err == nil && s != nil
 
+		var keyServerSources []string
+		if hasKeyServerStats {
+			keyServerSources, err = c.db.ListKeyServerStatsSources(membership.RealmID)
+			if err != nil {
+				controller.InternalError(w, r, c.h, err)
+				return
+			}
+		}
+
 		hasSMSConfig, err := currentRealm.HasSMSConfig(c.db)
 		if err != nil {
 			controller.InternalError(w, r, c.h, err)
 			return
 		}
 
+		annotations, err := currentRealm.ListStatsAnnotations(c.db)
+		if err != nil {
+			controller.InternalError(w, r, c.h, err)
+			return
+		}
+
+		corrections, err := currentRealm.ListStatsCorrections(c.db)
+		if err != nil {
+			controller.InternalError(w, r, c.h, err)
+			return
+		}
+
+		issuerSLOStats, err := currentRealm.IssuerSLOStatsCached(ctx, c.db, c.cacher)
+		if err != nil {
+			controller.InternalError(w, r, c.h, err)
+			return
+		}
+
 		m := controller.TemplateMapFromContext(ctx)
 		m["hasKeyServerStats"] = hasKeyServerStats
 		if hasKeyServerStats && membership.Can(rbac.SettingsRead) {
 			m["keyServerOverride"] = s.KeyServerURLOverride
 		}
+		if len(keyServerSources) > 1 {
+			m["keyServerSources"] = keyServerSources
+		}
 		m["hasSMSConfig"] = hasSMSConfig
+		m["annotations"] = annotations
+		m["statsCorrections"] = corrections
+		m["issuerSLOStats"] = issuerSLOStats
+		m["issuerSLOStatsDays"] = database.IssuerSLOStatsDays
+		m["statsMetrics"] = api.StatsMetricsByName()
+		m["canWriteAnnotations"] = membership.Can(rbac.SettingsWrite)
 		m.Title("Realm stats")
 		c.h.RenderHTML(w, "realmadmin/stats", m)
 	})
@@ -0,0 +1,138 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package realmadmin
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/exposure-notifications-verification-server/internal/project"
+	"github.com/google/exposure-notifications-verification-server/pkg/controller"
+	"github.com/google/exposure-notifications-verification-server/pkg/database"
+	"github.com/google/exposure-notifications-verification-server/pkg/rbac"
+	"github.com/gorilla/mux"
+)
+
+// HandleStatsAnnotationCreate adds a dated annotation to the realm's stats.
+func (c *Controller) HandleStatsAnnotationCreate() http.Handler {
+	type FormData struct {
+		Date    string `form:"date"`
+		Message string `form:"message"`
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		session := controller.SessionFromContext(ctx)
+		if session == nil {
+			controller.MissingSession(w, r, c.h)
+			return
+		}
+		flash := controller.Flash(session)
+
+		membership := controller.MembershipFromContext(ctx)
+		if membership == nil {
+			controller.MissingMembership(w, r, c.h)
+			return
+		}
+		if !membership.Can(rbac.SettingsWrite) {
+			controller.Unauthorized(w, r, c.h)
+			return
+		}
+		currentRealm := membership.Realm
+		currentUser := membership.User
+
+		var form FormData
+		if err := controller.BindForm(w, r, &form); err != nil {
+			flash.Error("Failed to process form: %v", err)
+			http.Redirect(w, r, "/realm/stats", http.StatusSeeOther)
+			return
+		}
+
+		date, err := time.Parse(project.RFC3339Date, project.TrimSpace(form.Date))
+		if err != nil {
+			flash.Error("Failed to add annotation: date must be in the format YYYY-MM-DD")
+			http.Redirect(w, r, "/realm/stats", http.StatusSeeOther)
+			return
+		}
+
+		annotation := &database.RealmStatsAnnotation{
+			RealmID: currentRealm.ID,
+			Date:    date,
+			Message: form.Message,
+		}
+		if err := c.db.SaveRealmStatsAnnotation(annotation, currentUser); err != nil {
+			if database.IsValidationError(err) {
+				flash.Error("Failed to add annotation: %s", strings.Join(annotation.ErrorMessages(), ", "))
+				http.Redirect(w, r, "/realm/stats", http.StatusSeeOther)
+				return
+			}
+
+			controller.InternalError(w, r, c.h, err)
+			return
+		}
+
+		flash.Alert("Successfully added annotation")
+		http.Redirect(w, r, "/realm/stats", http.StatusSeeOther)
+	})
+}
+
+// HandleStatsAnnotationDelete deletes an annotation from the realm's stats.
+func (c *Controller) HandleStatsAnnotationDelete() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		vars := mux.Vars(r)
+
+		session := controller.SessionFromContext(ctx)
+		if session == nil {
+			controller.MissingSession(w, r, c.h)
+			return
+		}
+		flash := controller.Flash(session)
+
+		membership := controller.MembershipFromContext(ctx)
+		if membership == nil {
+			controller.MissingMembership(w, r, c.h)
+			return
+		}
+		if !membership.Can(rbac.SettingsWrite) {
+			controller.Unauthorized(w, r, c.h)
+			return
+		}
+		currentRealm := membership.Realm
+		currentUser := membership.User
+
+		annotation, err := currentRealm.FindStatsAnnotation(c.db, vars["id"])
+		if err != nil {
+			if database.IsNotFound(err) {
+				controller.Unauthorized(w, r, c.h)
+				return
+			}
+
+			controller.InternalError(w, r, c.h, err)
+			return
+		}
+
+		if err := c.db.DeleteRealmStatsAnnotation(annotation, currentUser); err != nil {
+			flash.Error("Failed to delete annotation: %v", err)
+			http.Redirect(w, r, "/realm/stats", http.StatusSeeOther)
+			return
+		}
+
+		flash.Alert("Successfully deleted annotation")
+		http.Redirect(w, r, "/realm/stats", http.StatusSeeOther)
+	})
+}
@@ -0,0 +1,67 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package realmadmin
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/google/exposure-notifications-verification-server/pkg/api"
+	"github.com/google/exposure-notifications-verification-server/pkg/controller"
+	"github.com/google/exposure-notifications-verification-server/pkg/rbac"
+)
+
+// HandleTemplatePreview renders a draft SMS or email template with sample data
+// for the current realm and returns the result with any validation warnings.
+// The realm is not changed.
+func (c *Controller) HandleTemplatePreview() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		membership := controller.MembershipFromContext(ctx)
+		if membership == nil {
+			controller.MissingMembership(w, r, c.h)
+			return
+		}
+		if !membership.Can(rbac.SettingsWrite) {
+			controller.Unauthorized(w, r, c.h)
+			return
+		}
+		currentRealm := membership.Realm
+
+		var request api.TemplatePreviewRequest
+		if err := controller.BindJSON(w, r, &request); err != nil {
+			if errors.Is(err, controller.ErrBodyTooLarge) {
+				controller.RequestTooLarge(w, r, c.h, err)
+				return
+			}
+			c.h.RenderJSON(w, http.StatusBadRequest, api.Error(err).WithCode(api.ErrUnparsableRequest))
+			return
+		}
+
+		preview, err := currentRealm.PreviewTemplate(request.Kind, request.Label, request.Template, c.config.ServerEndpoint)
+		if err != nil {
+			c.h.RenderJSON(w, http.StatusBadRequest, api.Error(err).WithCode(api.ErrUnparsableRequest))
+			return
+		}
+
+		c.h.RenderJSON(w, http.StatusOK, &api.TemplatePreviewResponse{
+			Rendered: preview.Rendered,
+			Length:   preview.Length,
+			Segments: preview.Segments,
+			Warnings: preview.Warnings,
+		})
+	})
+}
@@ -0,0 +1,122 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package realmadmin_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/google/exposure-notifications-verification-server/internal/envstest"
+	"github.com/google/exposure-notifications-verification-server/internal/project"
+	"github.com/google/exposure-notifications-verification-server/pkg/api"
+	"github.com/google/exposure-notifications-verification-server/pkg/controller"
+	"github.com/google/exposure-notifications-verification-server/pkg/controller/realmadmin"
+	"github.com/google/exposure-notifications-verification-server/pkg/database"
+	"github.com/google/exposure-notifications-verification-server/pkg/rbac"
+	"github.com/gorilla/sessions"
+)
+
+func TestHandleTemplatePreview(t *testing.T) {
+	t.Parallel()
+
+	ctx := project.TestContext(t)
+	harness := envstest.NewServerConfig(t, testDatabaseInstance)
+
+	c := realmadmin.New(harness.Config, harness.Database, harness.RateLimiter, harness.Renderer, harness.Cacher)
+	handler := harness.WithCommonMiddlewares(c.HandleTemplatePreview())
+
+	t.Run("middleware", func(t *testing.T) {
+		t.Parallel()
+
+		envstest.ExerciseMembershipMissing(t, handler)
+		envstest.ExercisePermissionMissing(t, handler)
+	})
+
+	realm := database.NewRealmWithDefaults("Preview Realm")
+
+	ctx = controller.WithSession(ctx, &sessions.Session{})
+	ctx = controller.WithMembership(ctx, &database.Membership{
+		Realm:       realm,
+		User:        &database.User{},
+		Permissions: rbac.SettingsWrite,
+	})
+
+	cases := []struct {
+		name     string
+		request  *api.TemplatePreviewRequest
+		code     int
+		rendered string
+		warning  string
+	}{
+		{
+			name:    "unknown_kind",
+			request: &api.TemplatePreviewRequest{Kind: "fax", Template: "hello"},
+			code:    http.StatusBadRequest,
+		},
+		{
+			name:     "sms",
+			request:  &api.TemplatePreviewRequest{Kind: database.TemplatePreviewSMS, Template: "Code [code]"},
+			code:     http.StatusOK,
+			rendered: "Code ",
+		},
+		{
+			name:    "sms_invalid",
+			request: &api.TemplatePreviewRequest{Kind: database.TemplatePreviewSMS, Template: "Visit [enslink]"},
+			code:    http.StatusOK,
+			warning: database.SMSENExpressLink,
+		},
+		{
+			name:     "email",
+			request:  &api.TemplatePreviewRequest{Kind: database.TemplatePreviewEmailInvite, Template: "Join [realmname] at [invitelink]"},
+			code:     http.StatusOK,
+			rendered: "Join Preview Realm at ",
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			w, r := envstest.BuildJSONRequest(ctx, t, http.MethodPost, "/", tc.request)
+			handler.ServeHTTP(w, r)
+
+			if got, want := w.Code, tc.code; got != want {
+				t.Fatalf("expected %d to be %d: %s", got, want, w.Body.String())
+			}
+			if tc.code != http.StatusOK {
+				return
+			}
+
+			var resp api.TemplatePreviewResponse
+			if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+				t.Fatal(err)
+			}
+			if got, want := resp.Rendered, tc.rendered; !strings.HasPrefix(got, want) {
+				t.Errorf("expected %q to start with %q", got, want)
+			}
+			if tc.warning != "" {
+				if got, want := strings.Join(resp.Warnings, "\n"), tc.warning; !strings.Contains(got, want) {
+					t.Errorf("expected %q to contain %q", got, want)
+				}
+			} else if len(resp.Warnings) > 0 {
+				t.Errorf("expected no warnings, got %q", resp.Warnings)
+			}
+		})
+	}
+}
@@ -0,0 +1,201 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package realmadmin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/google/exposure-notifications-verification-server/internal/project"
+	"github.com/google/exposure-notifications-verification-server/pkg/controller"
+	"github.com/google/exposure-notifications-verification-server/pkg/database"
+	"github.com/google/exposure-notifications-verification-server/pkg/rbac"
+)
+
+// consentAcceptancesWindow is the default range of acceptances exported when
+// no dates are given.
+const consentAcceptancesWindow = 30 * 24 * time.Hour
+
+// consentFormData is a single locale row in the consent form.
+type consentFormData struct {
+	Locale string `form:"locale"`
+	Text   string `form:"text"`
+}
+
+// HandleUserReportConsent displays the realm's published user report consent
+// versions and publishes a new version.
+func (c *Controller) HandleUserReportConsent() http.Handler {
+	type FormData struct {
+		Texts []*consentFormData `form:"texts"`
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		session := controller.SessionFromContext(ctx)
+		if session == nil {
+			controller.MissingSession(w, r, c.h)
+			return
+		}
+		flash := controller.Flash(session)
+
+		membership := controller.MembershipFromContext(ctx)
+		if membership == nil {
+			controller.MissingMembership(w, r, c.h)
+			return
+		}
+		if !membership.Can(rbac.SettingsRead) {
+			controller.Unauthorized(w, r, c.h)
+			return
+		}
+		currentRealm := membership.Realm
+		currentUser := membership.User
+
+		if r.Method == http.MethodGet {
+			c.renderUserReportConsent(ctx, w, r, currentRealm, nil)
+			return
+		}
+
+		if !membership.Can(rbac.SettingsWrite) {
+			controller.Unauthorized(w, r, c.h)
+			return
+		}
+
+		var form FormData
+		if err := controller.BindForm(w, r, &form); err != nil {
+			currentRealm.AddError("", err.Error())
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			c.renderUserReportConsent(ctx, w, r, currentRealm, nil)
+			return
+		}
+
+		texts := make([]*database.UserReportConsent, 0, len(form.Texts))
+		for _, v := range form.Texts {
+			// Skip blank rows.
+			if v == nil || (project.TrimSpace(v.Locale) == "" && project.TrimSpace(v.Text) == "") {
+				continue
+			}
+			texts = append(texts, &database.UserReportConsent{
+				Locale: v.Locale,
+				Text:   v.Text,
+			})
+		}
+
+		if err := currentRealm.PublishUserReportConsent(c.db, texts, currentUser); err != nil {
+			if database.IsValidationError(err) {
+				w.WriteHeader(http.StatusUnprocessableEntity)
+				c.renderUserReportConsent(ctx, w, r, currentRealm, texts)
+				return
+			}
+
+			controller.InternalError(w, r, c.h, err)
+			return
+		}
+
+		flash.Alert("Published user report consent version %d", currentRealm.UserReportConsentVersion)
+		http.Redirect(w, r, "/realm/user-report-consent", http.StatusSeeOther)
+	})
+}
+
+// HandleUserReportConsentAcceptances exports the realm's user report consent
+// acceptances as CSV, for audits.
+func (c *Controller) HandleUserReportConsentAcceptances() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		membership := controller.MembershipFromContext(ctx)
+		if membership == nil {
+			controller.MissingMembership(w, r, c.h)
+			return
+		}
+		if !membership.Can(rbac.AuditRead) {
+			controller.Unauthorized(w, r, c.h)
+			return
+		}
+		currentRealm := membership.Realm
+
+		end := time.Now().UTC()
+		start := end.Add(-consentAcceptancesWindow)
+		if v := r.FormValue(QueryFromSearch); v != "" {
+			t, err := time.Parse(project.RFC3339Date, v)
+			if err != nil {
+				controller.BadRequest(w, r, c.h)
+				return
+			}
+			start = t
+		}
+		if v := r.FormValue(QueryToSearch); v != "" {
+			t, err := time.Parse(project.RFC3339Date, v)
+			if err != nil {
+				controller.BadRequest(w, r, c.h)
+				return
+			}
+			end = t.Add(24*time.Hour - time.Nanosecond)
+		}
+
+		acceptances, err := currentRealm.ListUserReportConsentAcceptances(c.db, start, end)
+		if err != nil {
+			if errors.Is(err, database.ErrBadDateRange) {
+				controller.BadRequest(w, r, c.h)
+				return
+			}
+
+			controller.InternalError(w, r, c.h, err)
+			return
+		}
+
+		filename := fmt.Sprintf("user-report-consent-acceptances-%s.csv", time.Now().UTC().Format(project.RFC3339Date))
+		c.h.RenderCSV(w, http.StatusOK, filename, database.UserReportConsentAcceptances(acceptances))
+	})
+}
+
+// renderUserReportConsent renders the consent page. If texts is nil, the
+// form is prefilled with the current version.
+func (c *Controller) renderUserReportConsent(ctx context.Context, w http.ResponseWriter, r *http.Request,
+	realm *database.Realm, texts []*database.UserReportConsent,
+) {
+	consents, err := realm.ListUserReportConsents(c.db)
+	if err != nil {
+		controller.InternalError(w, r, c.h, err)
+		return
+	}
+
+	// Group the consents by version, newest first.
+	var versions [][]*database.UserReportConsent
+	for _, consent := range consents {
+		if n := len(versions); n == 0 || versions[n-1][0].Version != consent.Version {
+			versions = append(versions, nil)
+		}
+		versions[len(versions)-1] = append(versions[len(versions)-1], consent)
+	}
+
+	if texts == nil && len(versions) > 0 && versions[0][0].Version == realm.UserReportConsentVersion {
+		texts = versions[0]
+	}
+	// Always offer a blank row for a new locale.
+	texts = append(texts, &database.UserReportConsent{})
+
+	m := controller.TemplateMapFromContext(ctx)
+	m.Title("User report consent")
+	m["realm"] = realm
+	m["texts"] = texts
+	m["versions"] = versions
+	m[QueryFromSearch] = time.Now().UTC().Add(-consentAcceptancesWindow).Format(project.RFC3339Date)
+	m[QueryToSearch] = time.Now().UTC().Format(project.RFC3339Date)
+	c.h.RenderHTML(w, "realmadmin/user-report-consent", m)
+}
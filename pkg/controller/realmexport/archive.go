@@ -0,0 +1,262 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package realmexport
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/exposure-notifications-verification-server/internal/project"
+	"github.com/google/exposure-notifications-verification-server/pkg/database"
+)
+
+// manifest describes the contents of an export archive. It is always the last
+// file in the archive.
+type manifest struct {
+	RealmID   uint              `json:"realm_id"`
+	RealmName string            `json:"realm_name"`
+	ExportID  uint              `json:"export_id"`
+	CreatedAt time.Time         `json:"created_at"`
+	Files     map[string]string `json:"files"`
+}
+
+// exportRealm is the realm configuration included in the export. Fields are
+// listed explicitly so that secrets and personal information (webhook
+// secrets, contact addresses, etc) are never exported.
+type exportRealm struct {
+	ID                          uint      `json:"id"`
+	Name                        string    `json:"name"`
+	RegionCode                  string    `json:"region_code"`
+	CreatedAt                   time.Time `json:"created_at"`
+	AllowedTestTypes            uint      `json:"allowed_test_types"`
+	RequireDate                 bool      `json:"require_date"`
+	CodeLength                  uint      `json:"code_length"`
+	CodeCharset                 string    `json:"code_charset"`
+	CodeGroupSize               uint      `json:"code_group_size"`
+	CodeDuration                string    `json:"code_duration"`
+	LongCodeLength              uint      `json:"long_code_length"`
+	LongCodeDuration            string    `json:"long_code_duration"`
+	EnableENExpress             bool      `json:"enable_en_express"`
+	AllowBulkUpload             bool      `json:"allow_bulk_upload"`
+	AllowUserReportWebView      bool      `json:"allow_user_report_web_view"`
+	AllowAdminUserReport        bool      `json:"allow_admin_user_report"`
+	UseAuthenticatedSMS         bool      `json:"use_authenticated_sms"`
+	AbusePreventionEnabled      bool      `json:"abuse_prevention_enabled"`
+	MFAMode                     string    `json:"mfa_mode"`
+	EmailVerifiedMode           string    `json:"email_verified_mode"`
+	UseRealmCertificateKey      bool      `json:"use_realm_certificate_key"`
+	CertificateIssuer           string    `json:"certificate_issuer"`
+	CertificateAudience         string    `json:"certificate_audience"`
+	CertificateDuration         string    `json:"certificate_duration"`
+	CertificateSigningAlgorithm string    `json:"certificate_signing_algorithm"`
+}
+
+// exportAuditEntry is an audit entry included in the export.
+type exportAuditEntry struct {
+	ID            uint      `json:"id"`
+	ActorID       string    `json:"actor_id"`
+	ActorDisplay  string    `json:"actor_display"`
+	Action        string    `json:"action"`
+	TargetID      string    `json:"target_id"`
+	TargetDisplay string    `json:"target_display"`
+	Diff          string    `json:"diff,omitempty"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// piiAuditPrefixes are audit actor and target ID prefixes whose display names
+// and diffs contain personal information (names, emails, phone numbers).
+var piiAuditPrefixes = []string{"users:", "user_report:"}
+
+func isPIIAuditID(id string) bool {
+	for _, prefix := range piiAuditPrefixes {
+		if strings.HasPrefix(id, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// buildArchive collects the realm's configuration, audit log, and statistics
+// into a gzipped tarball.
+func (c *Controller) buildArchive(realm *database.Realm, export *database.RealmExport) ([]byte, error) {
+	audits, err := realm.ListAllAudits(c.db)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list audit entries: %w", err)
+	}
+
+	stats, err := realm.ListAllStats(c.db)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list stats: %w", err)
+	}
+
+	annotations, err := realm.ListAllStatsAnnotations(c.db)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list stats annotations: %w", err)
+	}
+
+	statsCSV, err := stats.MarshalCSV()
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal stats csv: %w", err)
+	}
+
+	files := []struct {
+		name string
+		data interface{}
+	}{
+		{"realm.json", buildExportRealm(realm)},
+		{"audit_entries.json", buildExportAuditEntries(audits)},
+		{"stats.json", stats},
+		{"stats.csv", statsCSV},
+		{"stats_annotations.json", buildExportAnnotations(annotations)},
+	}
+
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+
+	now := time.Now().UTC()
+	m := &manifest{
+		RealmID:   realm.ID,
+		RealmName: realm.Name,
+		ExportID:  export.ID,
+		CreatedAt: now,
+		Files:     make(map[string]string, len(files)),
+	}
+
+	for _, f := range files {
+		b, ok := f.data.([]byte)
+		if !ok {
+			b, err = json.MarshalIndent(f.data, "", "  ")
+			if err != nil {
+				return nil, fmt.Errorf("failed to marshal %s: %w", f.name, err)
+			}
+		}
+
+		if err := writeTarFile(tw, f.name, b, now); err != nil {
+			return nil, err
+		}
+
+		sum := sha256.Sum256(b)
+		m.Files[f.name] = hex.EncodeToString(sum[:])
+	}
+
+	b, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal manifest: %w", err)
+	}
+	if err := writeTarFile(tw, "manifest.json", b, now); err != nil {
+		return nil, err
+	}
+
+	if err := tw.Close(); err != nil {
+		return nil, fmt.Errorf("failed to close tar: %w", err)
+	}
+	if err := gz.Close(); err != nil {
+		return nil, fmt.Errorf("failed to close gzip: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+func writeTarFile(tw *tar.Writer, name string, b []byte, modTime time.Time) error {
+	if err := tw.WriteHeader(&tar.Header{
+		Name:    name,
+		Mode:    0o600,
+		Size:    int64(len(b)),
+		ModTime: modTime,
+	}); err != nil {
+		return fmt.Errorf("failed to write header for %s: %w", name, err)
+	}
+	if _, err := tw.Write(b); err != nil {
+		return fmt.Errorf("failed to write %s: %w", name, err)
+	}
+	return nil
+}
+
+func buildExportRealm(r *database.Realm) *exportRealm {
+	return &exportRealm{
+		ID:                          r.ID,
+		Name:                        r.Name,
+		RegionCode:                  r.RegionCode,
+		CreatedAt:                   r.CreatedAt,
+		AllowedTestTypes:            uint(r.AllowedTestTypes),
+		RequireDate:                 r.RequireDate,
+		CodeLength:                  r.CodeLength,
+		CodeCharset:                 r.CodeCharset,
+		CodeGroupSize:               r.CodeGroupSize,
+		CodeDuration:                r.CodeDuration.Duration.String(),
+		LongCodeLength:              r.LongCodeLength,
+		LongCodeDuration:            r.LongCodeDuration.Duration.String(),
+		EnableENExpress:             r.EnableENExpress,
+		AllowBulkUpload:             r.AllowBulkUpload,
+		AllowUserReportWebView:      r.AllowUserReportWebView,
+		AllowAdminUserReport:        r.AllowAdminUserReport,
+		UseAuthenticatedSMS:         r.UseAuthenticatedSMS,
+		AbusePreventionEnabled:      r.AbusePreventionEnabled,
+		MFAMode:                     r.MFAMode.String(),
+		EmailVerifiedMode:           r.EmailVerifiedMode.String(),
+		UseRealmCertificateKey:      r.UseRealmCertificateKey,
+		CertificateIssuer:           r.CertificateIssuer,
+		CertificateAudience:         r.CertificateAudience,
+		CertificateDuration:         r.CertificateDuration.Duration.String(),
+		CertificateSigningAlgorithm: r.CertificateSigningAlgorithm,
+	}
+}
+
+// buildExportAuditEntries converts the audit entries for export. Users are
+// referenced only by their ID, and diffs on user targets are dropped.
+func buildExportAuditEntries(audits []*database.AuditEntry) []*exportAuditEntry {
+	entries := make([]*exportAuditEntry, 0, len(audits))
+	for _, a := range audits {
+		entry := &exportAuditEntry{
+			ID:            a.ID,
+			ActorID:       a.ActorID,
+			ActorDisplay:  a.ActorDisplay,
+			Action:        a.Action,
+			TargetID:      a.TargetID,
+			TargetDisplay: a.TargetDisplay,
+			Diff:          a.Diff,
+			CreatedAt:     a.CreatedAt,
+		}
+
+		if isPIIAuditID(entry.ActorID) {
+			entry.ActorDisplay = entry.ActorID
+		}
+		if isPIIAuditID(entry.TargetID) {
+			entry.TargetDisplay = entry.TargetID
+			entry.Diff = ""
+		}
+
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+func buildExportAnnotations(annotations database.RealmStatsAnnotations) []map[string]string {
+	result := make([]map[string]string, 0, len(annotations))
+	for _, a := range annotations {
+		result = append(result, map[string]string{
+			"date":    a.Date.Format(project.RFC3339Date),
+			"message": a.Message,
+		})
+	}
+	return result
+}
@@ -0,0 +1,129 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package realmexport
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+
+	"github.com/google/exposure-notifications-server/pkg/logging"
+	"github.com/google/exposure-notifications-verification-server/pkg/database"
+	"github.com/hashicorp/go-multierror"
+	"go.opencensus.io/stats"
+)
+
+// HandleExport processes all pending realm exports. Each export is written as
+// a gzipped tarball alongside a detached signature of its SHA-256 digest.
+func (c *Controller) HandleExport() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		logger := logging.FromContext(ctx).Named("realmexport.HandleExport")
+		logger.Debugw("starting")
+		defer logger.Debugw("finishing")
+
+		ok, err := c.db.TryLock(ctx, lockName, c.config.MinPeriod)
+		if err != nil {
+			logger.Errorw("failed to acquire lock", "error", err)
+			c.h.RenderJSON(w, http.StatusInternalServerError, err)
+			return
+		}
+		if !ok {
+			logger.Debugw("skipping (too early)")
+			c.h.RenderJSON(w, http.StatusOK, fmt.Errorf("too early"))
+			return
+		}
+
+		exports, err := c.db.ListPendingRealmExports()
+		if err != nil {
+			logger.Errorw("failed to list pending exports", "error", err)
+			c.h.RenderJSON(w, http.StatusInternalServerError, err)
+			return
+		}
+
+		// If one export fails, still attempt the others.
+		var merr *multierror.Error
+		for _, export := range exports {
+			if err := c.processExport(ctx, export); err != nil {
+				merr = multierror.Append(merr, fmt.Errorf("failed to export realm %d: %w", export.RealmID, err))
+
+				if err := c.db.FailRealmExport(export, err); err != nil {
+					merr = multierror.Append(merr, fmt.Errorf("failed to mark export %d as failed: %w", export.ID, err))
+				}
+				continue
+			}
+
+			logger.Infow("exported realm", "realm", export.RealmID, "object", export.ObjectName)
+			stats.Record(ctx, mExports.M(1))
+		}
+
+		if err := merr.ErrorOrNil(); err != nil {
+			logger.Errorw("failed to export realms", "error", err)
+			c.h.RenderJSON(w, http.StatusInternalServerError, err)
+			return
+		}
+
+		stats.Record(ctx, mSuccess.M(1))
+		c.h.RenderJSON(w, http.StatusOK, nil)
+	})
+}
+
+// processExport builds, signs, and writes the archive for the export, then
+// marks it as completed.
+func (c *Controller) processExport(ctx context.Context, export *database.RealmExport) error {
+	realm, err := c.db.FindRealm(export.RealmID)
+	if err != nil {
+		return fmt.Errorf("failed to find realm: %w", err)
+	}
+
+	archive, err := c.buildArchive(realm, export)
+	if err != nil {
+		return fmt.Errorf("failed to build archive: %w", err)
+	}
+
+	digest := sha256.Sum256(archive)
+	sig, err := c.signer.Sign(rand.Reader, digest[:], crypto.SHA256)
+	if err != nil {
+		return fmt.Errorf("failed to sign archive: %w", err)
+	}
+
+	objectName := fmt.Sprintf("realm-%d-export-%d.tar.gz", realm.ID, export.ID)
+	if err := c.writeObject(ctx, objectName, archive, "application/gzip"); err != nil {
+		return err
+	}
+	if err := c.writeObject(ctx, objectName+".sig", sig, "application/octet-stream"); err != nil {
+		return err
+	}
+
+	if err := c.db.CompleteRealmExport(export, objectName, hex.EncodeToString(digest[:])); err != nil {
+		return fmt.Errorf("failed to mark export as completed: %w", err)
+	}
+	return nil
+}
+
+// writeObject writes the contents to the export bucket. The export is only
+// marked as completed after both the archive and its signature are written, so
+// a failed run is retried in full.
+func (c *Controller) writeObject(ctx context.Context, name string, contents []byte, contentType string) error {
+	if err := c.blobstore.CreateObject(ctx, c.config.Bucket, name, contents, false, contentType); err != nil {
+		return fmt.Errorf("failed to write %s: %w", name, err)
+	}
+	return nil
+}
@@ -0,0 +1,48 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package realmexport writes signed archives of a realm's records for
+// retention when the realm is offboarded.
+package realmexport
+
+import (
+	"crypto"
+
+	"github.com/google/exposure-notifications-verification-server/pkg/config"
+	"github.com/google/exposure-notifications-verification-server/pkg/database"
+	"github.com/google/exposure-notifications-verification-server/pkg/render"
+	"github.com/google/exposure-notifications-verification-server/pkg/storage"
+)
+
+const lockName = "realmExportLock"
+
+// Controller is a controller for the realm export service.
+type Controller struct {
+	config    *config.RealmExportConfig
+	db        *database.Database
+	blobstore storage.Blobstore
+	signer    crypto.Signer
+	h         *render.Renderer
+}
+
+// New creates a new realm export controller.
+func New(cfg *config.RealmExportConfig, db *database.Database, blobstore storage.Blobstore, signer crypto.Signer, h *render.Renderer) *Controller {
+	return &Controller{
+		config:    cfg,
+		db:        db,
+		blobstore: blobstore,
+		signer:    signer,
+		h:         h,
+	}
+}
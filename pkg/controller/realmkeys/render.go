@@ -18,10 +18,14 @@ import (
 	"context"
 	"fmt"
 	"net/http"
+	"sort"
+	"strings"
 
 	"github.com/google/exposure-notifications-verification-server/pkg/controller"
 	"github.com/google/exposure-notifications-verification-server/pkg/database"
+	"github.com/google/exposure-notifications-verification-server/pkg/jwthelper"
 	"github.com/google/exposure-notifications-verification-server/pkg/keyutils"
+	"github.com/jinzhu/gorm/dialects/postgres"
 )
 
 func (c *Controller) redirectShow(ctx context.Context, w http.ResponseWriter, r *http.Request) {
@@ -32,8 +36,10 @@ func (c *Controller) renderShow(ctx context.Context, w http.ResponseWriter, r *h
 	m := controller.TemplateMapFromContext(ctx)
 	m.Title("Realm keys")
 	m["realm"] = realm
+	m["certificateClaims"] = certificateClaimsString(realm.CertificateClaims)
 
 	m["supportsPerRealmSigning"] = c.db.SupportsPerRealmSigning()
+	m["signingAlgorithms"] = c.db.SupportedSigningAlgorithms()
 	if c.db.SupportsPerRealmSigning() {
 		keys, err := realm.ListSigningKeys(c.db)
 		if err != nil {
@@ -47,16 +53,21 @@ func (c *Controller) renderShow(ctx context.Context, w http.ResponseWriter, r *h
 		m["maximumKeyVersions"] = maximumKeyVersions
 
 		publicKeys := make(map[string]string)
+		keyAlgorithms := make(map[string]string)
 		// Go through and load / parse all of the public keys for the realm.
 		for _, k := range keys {
 			if k.Active {
 				m["activeRealmKey"] = k.GetKID()
 				m["activePublicKey"] = ""
 			}
-			pk, err := c.publicKeyCache.GetPublicKey(ctx, k.KeyID, c.db.KeyManager())
+			pk, err := c.publicKeyCache.GetManagedPublicKey(ctx, k.KeyID, k.PublicKey, c.db.KeyManager())
 			if err != nil {
 				publicKeys[k.GetKID()] = fmt.Errorf("error loading public key: %w", err).Error()
 			} else {
+				if alg, err := jwthelper.AlgorithmForKey(pk); err == nil {
+					keyAlgorithms[k.GetKID()] = alg
+				}
+
 				pem, err := keyutils.EncodePublicKey(pk)
 				if err != nil {
 					publicKeys[k.GetKID()] = fmt.Errorf("error decoding public key: %w", err).Error()
@@ -69,6 +80,7 @@ func (c *Controller) renderShow(ctx context.Context, w http.ResponseWriter, r *h
 			}
 		}
 		m["publicKeys"] = publicKeys
+		m["keyAlgorithms"] = keyAlgorithms
 	}
 
 	// Fallback to the system signing keys and present them in the UI.
@@ -98,3 +110,23 @@ func (c *Controller) renderShow(ctx context.Context, w http.ResponseWriter, r *h
 
 	c.h.RenderHTML(w, "realmadmin/keys", m)
 }
+
+// certificateClaimsString renders the static certificate claims as name=value
+// lines, sorted by name.
+func certificateClaimsString(h postgres.Hstore) string {
+	names := make([]string, 0, len(h))
+	for k := range h {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
+	lines := make([]string, 0, len(names))
+	for _, k := range names {
+		var v string
+		if p := h[k]; p != nil {
+			v = *p
+		}
+		lines = append(lines, k+"="+v)
+	}
+	return strings.Join(lines, "\n")
+}
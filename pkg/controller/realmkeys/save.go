@@ -16,9 +16,12 @@ package realmkeys
 
 import (
 	"net/http"
+	"strings"
 
+	"github.com/google/exposure-notifications-verification-server/internal/project"
 	"github.com/google/exposure-notifications-verification-server/pkg/controller"
 	"github.com/google/exposure-notifications-verification-server/pkg/rbac"
+	"github.com/jinzhu/gorm/dialects/postgres"
 )
 
 // HandleSave handles saving certificate settings to the current realm.
@@ -27,6 +30,8 @@ func (c *Controller) HandleSave() http.Handler {
 		Issuer         string `form:"certificateIssuer"`
 		Audience       string `form:"certificateAudience"`
 		DurationString string `form:"certificateDuration"`
+		Algorithm      string `form:"certificateSigningAlgorithm"`
+		Claims         string `form:"certificateClaims"`
 	}
 
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -69,6 +74,24 @@ func (c *Controller) HandleSave() http.Handler {
 		// AsString delgates the duration parsing and validation to the model.
 		currentRealm.CertificateDuration.AsString = form.DurationString
 
+		// Static claims can only use names approved by a system administrator,
+		// which the model validates.
+		if len(currentRealm.AllowedCertificateClaims) > 0 {
+			currentRealm.CertificateClaims = parseCertificateClaims(form.Claims)
+		}
+
+		// The algorithm only applies to newly created keys. It must be supported by
+		// the configured key manager; the model validates the value itself.
+		if form.Algorithm != "" && form.Algorithm != currentRealm.CertificateSigningAlgorithm {
+			if !c.db.SupportsSigningAlgorithm(form.Algorithm) {
+				currentRealm.AddError("certificateSigningAlgorithm", "is not supported by the configured key manager")
+				w.WriteHeader(http.StatusUnprocessableEntity)
+				c.renderShow(ctx, w, r, currentRealm)
+				return
+			}
+			currentRealm.CertificateSigningAlgorithm = form.Algorithm
+		}
+
 		if err := c.db.SaveRealm(currentRealm, currentUser); err != nil {
 			currentRealm.AddError("", err.Error())
 			w.WriteHeader(http.StatusUnprocessableEntity)
@@ -80,3 +103,24 @@ func (c *Controller) HandleSave() http.Handler {
 		c.redirectShow(ctx, w, r)
 	})
 }
+
+// parseCertificateClaims parses static certificate claims submitted as one
+// name=value pair per line. A line without "=" is kept with an empty value so
+// that it fails validation instead of being silently dropped.
+func parseCertificateClaims(in string) postgres.Hstore {
+	claims := make(postgres.Hstore)
+	for _, line := range strings.Split(in, "\n") {
+		line = project.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+
+		parts := strings.SplitN(line, "=", 2)
+		var value string
+		if len(parts) == 2 {
+			value = parts[1]
+		}
+		claims[project.TrimSpace(parts[0])] = &value
+	}
+	return claims
+}
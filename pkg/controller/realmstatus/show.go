@@ -0,0 +1,64 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package realmstatus
+
+import (
+	"net/http"
+
+	"github.com/google/exposure-notifications-verification-server/pkg/controller"
+	"github.com/gorilla/mux"
+)
+
+// HandleShow renders the status page of the realm.
+func (c *Controller) HandleShow() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		status, err := c.realmStatus(ctx, mux.Vars(r)["realm_id"])
+		if err != nil {
+			controller.InternalError(w, r, c.h, err)
+			return
+		}
+		if status == nil {
+			controller.NotFound(w, r, c.h)
+			return
+		}
+
+		m := controller.TemplateMapFromContext(ctx)
+		m.Title("%s status", status.Realm)
+		m["status"] = status
+		m["jsonPath"] = r.URL.Path + ".json"
+		c.h.RenderHTML(w, "status/show", m)
+	})
+}
+
+// HandleShowJSON returns the status of the realm as JSON.
+func (c *Controller) HandleShowJSON() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		status, err := c.realmStatus(ctx, mux.Vars(r)["realm_id"])
+		if err != nil {
+			controller.InternalError(w, r, c.h, err)
+			return
+		}
+		if status == nil {
+			c.h.RenderJSON(w, http.StatusNotFound, http.StatusText(http.StatusNotFound))
+			return
+		}
+
+		c.h.RenderJSON(w, http.StatusOK, status)
+	})
+}
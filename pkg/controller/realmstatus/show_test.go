@@ -0,0 +1,147 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package realmstatus_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/google/exposure-notifications-verification-server/internal/envstest"
+	"github.com/google/exposure-notifications-verification-server/internal/project"
+	"github.com/google/exposure-notifications-verification-server/pkg/controller/realmstatus"
+	"github.com/google/exposure-notifications-verification-server/pkg/database"
+	"github.com/google/exposure-notifications-verification-server/pkg/sms"
+	"github.com/gorilla/mux"
+)
+
+func TestHandleShow(t *testing.T) {
+	t.Parallel()
+
+	ctx := project.TestContext(t)
+	harness := envstest.NewServerConfig(t, testDatabaseInstance)
+	db := harness.Database
+
+	realm, err := db.FindRealm(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	realmID := strconv.FormatUint(uint64(realm.ID), 10)
+
+	c := realmstatus.New(harness.Config, harness.Cacher, db, harness.Renderer)
+	showHandler := harness.WithCommonMiddlewares(c.HandleShow())
+	jsonHandler := harness.WithCommonMiddlewares(c.HandleShowJSON())
+
+	getStatus := func(t *testing.T, id string) (int, *realmstatus.Status) {
+		t.Helper()
+
+		w, r := envstest.BuildJSONRequest(ctx, t, http.MethodGet, "/", nil)
+		r = mux.SetURLVars(r, map[string]string{"realm_id": id})
+		jsonHandler.ServeHTTP(w, r)
+
+		if w.Code != http.StatusOK {
+			return w.Code, nil
+		}
+
+		var status realmstatus.Status
+		if err := json.NewDecoder(w.Body).Decode(&status); err != nil {
+			t.Fatal(err)
+		}
+		return w.Code, &status
+	}
+
+	t.Run("not_found", func(t *testing.T) {
+		if code, _ := getStatus(t, "123456"); code != http.StatusNotFound {
+			t.Errorf("expected %d to be %d", code, http.StatusNotFound)
+		}
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		if code, _ := getStatus(t, realmID); code != http.StatusNotFound {
+			t.Errorf("expected %d to be %d", code, http.StatusNotFound)
+		}
+	})
+
+	realm.EnableStatusPage = true
+	if err := db.SaveRealm(realm, database.SystemTest); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("operational", func(t *testing.T) {
+		code, status := getStatus(t, realmID)
+		if code != http.StatusOK {
+			t.Fatalf("expected %d to be %d", code, http.StatusOK)
+		}
+
+		if got, want := status.Realm, realm.Name; got != want {
+			t.Errorf("expected %q to be %q", got, want)
+		}
+		if got, want := status.API, realmstatus.StatusOperational; got != want {
+			t.Errorf("expected %q to be %q", got, want)
+		}
+		if got, want := status.SMS, realmstatus.StatusNotConfigured; got != want {
+			t.Errorf("expected %q to be %q", got, want)
+		}
+		if got, want := len(status.Banners), 0; got != want {
+			t.Errorf("expected %d banners to be %d", got, want)
+		}
+	})
+
+	if err := db.SaveSMSConfig(&database.SMSConfig{
+		RealmID:      realm.ID,
+		ProviderType: sms.ProviderTypeNoop,
+	}); err != nil {
+		t.Fatal(err)
+	}
+	realm.MaintenanceMode = true
+	if err := db.SaveRealm(realm, database.SystemTest); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("maintenance", func(t *testing.T) {
+		code, status := getStatus(t, realmID)
+		if code != http.StatusOK {
+			t.Fatalf("expected %d to be %d", code, http.StatusOK)
+		}
+
+		if got, want := status.API, realmstatus.StatusMaintenance; got != want {
+			t.Errorf("expected %q to be %q", got, want)
+		}
+		if got, want := status.SMS, realmstatus.StatusOperational; got != want {
+			t.Errorf("expected %q to be %q", got, want)
+		}
+		if got, want := len(status.Banners), 1; got != want {
+			t.Fatalf("expected %d banners to be %d", got, want)
+		}
+		if got, want := status.Banners[0].Title, "Maintenance"; got != want {
+			t.Errorf("expected %q to be %q", got, want)
+		}
+	})
+
+	t.Run("html", func(t *testing.T) {
+		w, r := envstest.BuildFormRequest(ctx, t, http.MethodGet, "/", nil)
+		r = mux.SetURLVars(r, map[string]string{"realm_id": realmID})
+		showHandler.ServeHTTP(w, r)
+
+		if got, want := w.Code, http.StatusOK; got != want {
+			t.Fatalf("expected %d to be %d", got, want)
+		}
+		if got, want := w.Body.String(), "Code issuance is temporarily paused"; !strings.Contains(got, want) {
+			t.Errorf("expected %q to contain %q", got, want)
+		}
+	})
+}
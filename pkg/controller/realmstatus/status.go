@@ -0,0 +1,168 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package realmstatus
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"time"
+
+	"github.com/google/exposure-notifications-verification-server/pkg/cache"
+	"github.com/google/exposure-notifications-verification-server/pkg/database"
+)
+
+// Service statuses.
+const (
+	StatusOperational   = "operational"
+	StatusDegraded      = "degraded"
+	StatusMaintenance   = "maintenance"
+	StatusNotConfigured = "not_configured"
+)
+
+const (
+	// degradedMinFailures is the number of failures required before a service
+	// is reported as degraded, so one failure on a quiet day is not an outage.
+	degradedMinFailures = 5
+
+	// degradedRate is the failure rate above which a service is reported as
+	// degraded.
+	degradedRate = 0.1
+
+	// announcementsCacheTTL matches the cache used for announcements in the
+	// realm UI.
+	announcementsCacheTTL = 5 * time.Minute
+)
+
+// Status is the public status of a realm.
+type Status struct {
+	Realm   string    `json:"realm"`
+	API     string    `json:"api"`
+	SMS     string    `json:"sms"`
+	Banners []*Banner `json:"banners"`
+
+	// CheckedAt is when the status was computed. Health counts may be cached
+	// for up to a minute.
+	CheckedAt time.Time `json:"checked_at"`
+}
+
+// Operational returns true if all services are operational or not configured.
+func (s *Status) Operational() bool {
+	return s.API == StatusOperational &&
+		(s.SMS == StatusOperational || s.SMS == StatusNotConfigured)
+}
+
+// Banner is a maintenance or incident notice shown on the status page.
+type Banner struct {
+	Title string `json:"title,omitempty"`
+
+	// Message supports markdown syntax.
+	Message string `json:"message"`
+
+	messageHTML string
+}
+
+// MessageHTML returns the sanitized HTML of the message.
+func (b *Banner) MessageHTML() string {
+	return b.messageHTML
+}
+
+// realmStatus computes the status of the realm. It returns nil if the realm
+// does not exist or has not enabled its status page.
+func (c *Controller) realmStatus(ctx context.Context, realmID string) (*Status, error) {
+	realm, err := c.db.FindRealm(realmID)
+	if err != nil {
+		if database.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to find realm: %w", err)
+	}
+	if !realm.EnableStatusPage {
+		return nil, nil
+	}
+
+	health, err := realm.HealthCached(ctx, c.db, c.cacher, c.config.SMSIgnoredErrorCodes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get realm health: %w", err)
+	}
+
+	status := &Status{
+		Realm:     realm.Name,
+		API:       StatusOperational,
+		SMS:       StatusOperational,
+		Banners:   make([]*Banner, 0, 2),
+		CheckedAt: time.Now().UTC(),
+	}
+
+	switch {
+	case c.config.IsMaintenanceMode() || realm.MaintenanceMode:
+		status.API = StatusMaintenance
+	case health.CodesFailed >= degradedMinFailures && health.IssueErrorRate() > degradedRate:
+		status.API = StatusDegraded
+	}
+
+	hasSMSConfig, err := realm.HasSMSConfig(c.db)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check sms config: %w", err)
+	}
+	switch {
+	case !hasSMSConfig:
+		status.SMS = StatusNotConfigured
+	case health.SMSErrors >= degradedMinFailures && health.SMSErrorRate() > degradedRate:
+		status.SMS = StatusDegraded
+	}
+
+	// The system notice includes the server maintenance message.
+	if v := c.config.ParsedSystemNotice(); v != "" {
+		status.Banners = append(status.Banners, &Banner{
+			Message:     c.config.SystemNotice,
+			messageHTML: v,
+		})
+	}
+
+	if realm.MaintenanceMode {
+		msg := "Code issuance is temporarily paused for this realm."
+		status.Banners = append(status.Banners, &Banner{
+			Title:       "Maintenance",
+			Message:     msg,
+			messageHTML: html.EscapeString(msg),
+		})
+	}
+
+	var announcements []*database.Announcement
+	cacheKey := &cache.Key{
+		Namespace: "announcements",
+		Key:       "active",
+	}
+	if err := c.cacher.Fetch(ctx, cacheKey, &announcements, announcementsCacheTTL, func() (interface{}, error) {
+		return c.db.ListActiveAnnouncements(time.Now().UTC())
+	}); err != nil {
+		return nil, fmt.Errorf("failed to load announcements: %w", err)
+	}
+
+	// The cached list may be stale, so check the schedule again. Announcements
+	// for system admins are never shown.
+	for _, a := range announcements {
+		if a.IsActive(status.CheckedAt) && a.VisibleTo(nil, realm) {
+			status.Banners = append(status.Banners, &Banner{
+				Title:       a.Title,
+				Message:     a.Body,
+				messageHTML: a.BodyHTML(),
+			})
+		}
+	}
+
+	return status, nil
+}
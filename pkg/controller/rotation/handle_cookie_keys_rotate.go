@@ -0,0 +1,88 @@
+// Copyright 2021 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rotation
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/google/exposure-notifications-server/pkg/logging"
+	"github.com/google/exposure-notifications-verification-server/pkg/controller/jobstatus"
+	"github.com/google/exposure-notifications-verification-server/pkg/database"
+	"go.opencensus.io/stats"
+)
+
+// cookieKeyNumBytes is the size of a cookie key: 32 bytes of encryption key
+// followed by 64 bytes of HMAC key.
+const cookieKeyNumBytes = 32 + 64
+
+// HandleRotateCookieKeys handles cookie key rotation.
+func (c *Controller) HandleRotateCookieKeys() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		logger := logging.FromContext(ctx).Named("rotation.HandleRotateCookieKeys")
+		logger.Debugw("starting")
+		defer logger.Debugw("finishing")
+
+		ok, err := c.db.TryLock(ctx, cookieKeysRotationLock, c.config.MinTTL)
+		if err != nil {
+			logger.Errorw("failed to acquire lock", "error", err)
+			c.h.RenderJSON(w, http.StatusInternalServerError, err)
+			return
+		}
+		if !ok {
+			logger.Debugw("skipping (too early)")
+			jobstatus.RecordFreshness(ctx, c.db, jobstatus.JobRotateCookieKeys)
+			c.h.RenderJSON(w, http.StatusOK, fmt.Errorf("too early"))
+			return
+		}
+
+		if err := c.RotateCookieKeys(ctx); err != nil {
+			logger.Errorw("failed to rotate cookie keys", "error", err)
+			jobstatus.Record(ctx, c.db, jobstatus.JobRotateCookieKeys, 0, err)
+			c.h.RenderJSON(w, http.StatusInternalServerError, err)
+			return
+		}
+
+		jobstatus.Record(ctx, c.db, jobstatus.JobRotateCookieKeys, 0, nil)
+		stats.Record(ctx, mCookieKeysSuccess.M(1))
+		c.h.RenderJSON(w, http.StatusOK, nil)
+	})
+}
+
+// RotateCookieKeys rotates the keys used to encrypt and sign session cookies.
+// It does not take out a lock nor does it return an HTTP response.
+//
+// A new key is created once the newest key is older than CookieKeyMinAge and
+// becomes the current key after the activation TTL. Previous keys stay
+// available to the session store until CookieKeyMaxAge, so sessions signed by
+// them are re-issued under the current key as users make requests instead of
+// being invalidated all at once.
+func (c *Controller) RotateCookieKeys(ctx context.Context) error {
+	logger := logging.FromContext(ctx).Named("rotation.RotateCookieKeys")
+	logger.Debugw("rotating cookie keys")
+	defer logger.Debugw("finished rotating cookie keys")
+
+	typ := database.SecretTypeCookieKeys
+	parent := "cookie-keys"
+	minTTL := c.config.CookieKeyMinAge
+	maxTTL := c.config.CookieKeyMaxAge
+	if err := c.rotateSecret(ctx, typ, parent, cookieKeyNumBytes, minTTL, maxTTL); err != nil {
+		return fmt.Errorf("failed to rotate cookie key: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,126 @@
+// Copyright 2021 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rotation
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/google/exposure-notifications-server/pkg/secrets"
+	"github.com/google/exposure-notifications-verification-server/internal/envstest"
+	"github.com/google/exposure-notifications-verification-server/internal/project"
+	"github.com/google/exposure-notifications-verification-server/pkg/config"
+	"github.com/google/exposure-notifications-verification-server/pkg/database"
+	"github.com/google/exposure-notifications-verification-server/pkg/render"
+)
+
+func TestHandleRotateCookieKeys(t *testing.T) {
+	t.Parallel()
+
+	ctx := project.TestContext(t)
+
+	secretManager, err := secrets.NewInMemory(ctx, &secrets.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	secretManagerTyp, ok := secretManager.(secrets.SecretVersionManager)
+	if !ok {
+		t.Fatal("secret manager cannot manage versions")
+	}
+
+	h, err := render.New(ctx, nil, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("too_early", func(t *testing.T) {
+		t.Parallel()
+
+		db, _ := testDatabaseInstance.NewDatabase(t, nil)
+
+		cfg := &config.RotationConfig{
+			MinTTL: 5 * time.Minute,
+		}
+
+		c := New(cfg, db, nil, secretManagerTyp, h)
+
+		w, r := envstest.BuildJSONRequest(ctx, t, http.MethodGet, "/", nil)
+
+		c.HandleRotateCookieKeys().ServeHTTP(w, r)
+		if got, want := w.Code, http.StatusOK; got != want {
+			t.Errorf("Expected %d to be %d", got, want)
+		}
+
+		// again
+		c.HandleRotateCookieKeys().ServeHTTP(w, r)
+		if got, want := w.Code, http.StatusOK; got != want {
+			t.Errorf("Expected %d to be %d", got, want)
+		}
+	})
+
+	t.Run("database_error", func(t *testing.T) {
+		t.Parallel()
+
+		db, _ := testDatabaseInstance.NewDatabase(t, nil)
+		db.SetRawDB(envstest.NewFailingDatabase())
+
+		cfg := &config.RotationConfig{}
+
+		c := New(cfg, db, nil, secretManagerTyp, h)
+
+		w, r := envstest.BuildJSONRequest(ctx, t, http.MethodGet, "/", nil)
+		c.HandleRotateCookieKeys().ServeHTTP(w, r)
+
+		if got, want := w.Code, http.StatusInternalServerError; got != want {
+			t.Errorf("Expected %d to be %d", got, want)
+		}
+	})
+
+	t.Run("rotates", func(t *testing.T) {
+		t.Parallel()
+
+		db, _ := testDatabaseInstance.NewDatabase(t, nil)
+
+		// Clear secrets created by bootstrap
+		if err := db.RawDB().Unscoped().Delete(&database.Secret{}).Error; err != nil {
+			t.Fatal(err)
+		}
+
+		cfg := &config.RotationConfig{
+			SecretsParent:   "test/rotation",
+			CookieKeyMinAge: 1 * time.Nanosecond,
+		}
+
+		c := New(cfg, db, nil, secretManagerTyp, h)
+
+		w, r := envstest.BuildJSONRequest(ctx, t, http.MethodGet, "/", nil)
+		c.HandleRotateCookieKeys().ServeHTTP(w, r)
+		if got, want := w.Code, http.StatusOK; got != want {
+			t.Errorf("Expected %d to be %d", got, want)
+		}
+
+		secrets, err := db.ListSecretsForType(database.SecretTypeCookieKeys)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := len(secrets), 1; got != want {
+			t.Fatalf("expected %d secret, got %d: %#v", want, got, secrets)
+		}
+		if got, want := secrets[0].Active, true; got != want {
+			t.Errorf("expected %t to be %t: %#v", got, want, secrets[0])
+		}
+	})
+}
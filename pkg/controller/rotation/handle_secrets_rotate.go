@@ -23,6 +23,7 @@ import (
 
 	"github.com/google/exposure-notifications-server/pkg/logging"
 	"github.com/google/exposure-notifications-verification-server/internal/project"
+	"github.com/google/exposure-notifications-verification-server/pkg/controller/jobstatus"
 	"github.com/google/exposure-notifications-verification-server/pkg/database"
 	"github.com/hashicorp/go-multierror"
 	"go.opencensus.io/stats"
@@ -45,6 +46,7 @@ func (c *Controller) HandleRotateSecrets() http.Handler {
 		}
 		if !ok {
 			logger.Debugw("skipping (too early)")
+			jobstatus.RecordFreshness(ctx, c.db, jobstatus.JobRotateSecrets)
 			c.h.RenderJSON(w, http.StatusOK, fmt.Errorf("too early"))
 			return
 		}
@@ -52,10 +54,12 @@ func (c *Controller) HandleRotateSecrets() http.Handler {
 		// If there are any errors, return them
 		if err := c.RotateSecrets(ctx); err != nil {
 			logger.Errorw("failed to rotate secrets", "error", err)
+			jobstatus.Record(ctx, c.db, jobstatus.JobRotateSecrets, 0, err)
 			c.h.RenderJSON(w, http.StatusInternalServerError, err)
 			return
 		}
 
+		jobstatus.Record(ctx, c.db, jobstatus.JobRotateSecrets, 0, nil)
 		stats.Record(ctx, mSecretsSuccess.M(1))
 		c.h.RenderJSON(w, http.StatusOK, nil)
 	})
@@ -63,7 +67,8 @@ func (c *Controller) HandleRotateSecrets() http.Handler {
 
 // RotateSecrets triggers a secret rotation. It does not take out a lock nor
 // does it return an HTTP response. This is primarily used so other functions
-// can perform initials ecrets bootstrapping.
+// can perform initials ecrets bootstrapping. Cookie keys are rotated separately
+// by RotateCookieKeys.
 func (c *Controller) RotateSecrets(ctx context.Context) error {
 	logger := logging.FromContext(ctx).Named("rotation.RotateSecrets")
 
@@ -99,21 +104,6 @@ func (c *Controller) RotateSecrets(ctx context.Context) error {
 		}
 	}()
 
-	// Cookie key rotation
-	func() {
-		logger.Debugw("rotating cookie keys")
-		defer logger.Debugw("finished rotating cookie keys")
-
-		typ := database.SecretTypeCookieKeys
-		parent := "cookie-keys"
-		minTTL := c.config.CookieKeyMinAge
-		maxTTL := c.config.CookieKeyMaxAge
-		if err := c.rotateSecret(ctx, typ, parent, 32+64, minTTL, maxTTL); err != nil {
-			merr = multierror.Append(merr, fmt.Errorf("failed to rotate cookie key: %w", err))
-			return
-		}
-	}()
-
 	// Phone number database HMAC
 	func() {
 		logger.Debugw("rotating phone number database HMAC keys")
@@ -129,6 +119,21 @@ func (c *Controller) RotateSecrets(ctx context.Context) error {
 		}
 	}()
 
+	// SMS suppression HMAC
+	func() {
+		logger.Debugw("rotating sms suppression HMAC keys")
+		defer logger.Debugw("finished rotating sms suppression HMAC keys")
+
+		typ := database.SecretTypeSMSSuppressionHMAC
+		parent := "db-sms-suppression-hmac"
+		minTTL := c.config.SMSSuppressionHMACKeyMinAge
+		maxTTL := time.Duration(0)
+		if err := c.rotateSecret(ctx, typ, parent, 128, minTTL, maxTTL); err != nil {
+			merr = multierror.Append(merr, fmt.Errorf("failed to rotate sms suppression hmac key: %w", err))
+			return
+		}
+	}()
+
 	// Verification code database HMAC
 	func() {
 		logger.Debugw("rotating verification code database HMAC keys")
@@ -198,6 +203,7 @@ func (c *Controller) rotateSecret(ctx context.Context, typ database.SecretType,
 			return fmt.Errorf("failed to save new secret %s: %w", ref, err)
 		}
 		existing = append(existing, secret)
+		c.recordRotation(ctx, string(typ), 0, fmt.Sprintf("created secret %d", secret.ID))
 	}
 
 	logger.Debugw("activating existing secrets")
@@ -224,6 +230,7 @@ func (c *Controller) rotateSecret(ctx context.Context, typ database.SecretType,
 			if err := c.db.SaveSecret(secret, RotationActor); err != nil {
 				return fmt.Errorf("failed to activate secret %d: %w", secret.ID, err)
 			}
+			c.recordRotation(ctx, string(typ), 0, fmt.Sprintf("activated secret %d", secret.ID))
 		}
 
 		// If a maximum TTL was given, check for expirations.
@@ -253,12 +260,27 @@ func (c *Controller) rotateSecret(ctx context.Context, typ database.SecretType,
 			//
 			// Without this check, a secret with a low TTL could be marked for
 			// deletion before activation.
+			//
+			// Secrets that still protect outstanding values are kept (inactive, but
+			// still used for verification) until those values are no longer needed.
 			if !secret.Active && modified && updatedTTL >= c.config.SecretActivationTTL {
+				outstanding, err := c.outstandingSecretValues(ctx, secret)
+				if err != nil {
+					return fmt.Errorf("failed to check secret %d for outstanding values: %w", secret.ID, err)
+				}
+				if outstanding > 0 {
+					logger.Infow("deferring secret deletion, secret still protects outstanding values",
+						"secret", secret,
+						"outstanding", outstanding)
+					continue
+				}
+
 				logger.Infow("marking secret for deletion", "secret", secret)
 
 				if err := c.db.DeleteSecret(secret, RotationActor); err != nil {
 					return fmt.Errorf("failed to mark secret %d for deletion: %w", secret.ID, err)
 				}
+				c.recordRotation(ctx, string(typ), 0, fmt.Sprintf("retired secret %d", secret.ID))
 			}
 		}
 	}
@@ -294,6 +316,22 @@ func (c *Controller) rotateSecret(ctx context.Context, typ database.SecretType,
 	return nil
 }
 
+// outstandingSecretValues returns the number of stored values that can only be
+// verified with the given secret. Codes are stored only as HMACs and cannot be
+// re-HMACed with a newer key, so a verification code HMAC key is retained until
+// every code HMACed with it has been claimed or has expired.
+func (c *Controller) outstandingSecretValues(ctx context.Context, secret *database.Secret) (int64, error) {
+	if secret.Type != database.SecretTypeVerificationCodeDatabaseHMAC {
+		return 0, nil
+	}
+
+	value, err := c.secretManager.GetSecretValue(ctx, secret.Reference)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get secret value: %w", err)
+	}
+	return c.db.CountOutstandingVerificationCodesForHMACKey(database.VerificationCodeHMACKeyID([]byte(value)))
+}
+
 // createUpstreamSecretVersion creates an upstream secret version in the secret
 // manager.
 func (c *Controller) createUpstreamSecretVersion(ctx context.Context, name string, numBytes int) (string, error) {
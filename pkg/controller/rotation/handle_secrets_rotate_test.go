@@ -302,4 +302,116 @@ func TestRotateSecret(t *testing.T) {
 			}
 		}
 	})
+
+	t.Run("retains_verification_code_hmac_key", func(t *testing.T) {
+		t.Parallel()
+
+		typ := database.SecretTypeVerificationCodeDatabaseHMAC
+		parent := "my-hmac-secret"
+		numBytes := 128
+
+		db, _ := testDatabaseInstance.NewDatabase(t, nil)
+
+		c := New(cfg, db, nil, secretManagerTyp, h)
+
+		// Clear secrets created by bootstrap
+		if err := db.RawDB().Unscoped().Delete(&database.Secret{}).Error; err != nil {
+			t.Fatal(err)
+		}
+
+		// Create the key.
+		if err := c.rotateSecret(ctx, typ, parent, numBytes, 1*time.Nanosecond, 0); err != nil {
+			t.Fatal(err)
+		}
+		secrets, err := db.ListSecrets()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := len(secrets), 1; got != want {
+			t.Fatalf("expected %d secret, got %d: %#v", want, got, secrets)
+		}
+		value, err := secretManagerTyp.GetSecretValue(ctx, secrets[0].Reference)
+		if err != nil {
+			t.Fatal(err)
+		}
+		keyID := database.VerificationCodeHMACKeyID([]byte(value))
+
+		// Issue two codes that were HMACed with the key.
+		realm, err := db.FindRealm(1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		codes := make([]*database.VerificationCode, 0, 2)
+		for _, code := range []string{"11111111", "22222222"} {
+			vc := &database.VerificationCode{
+				RealmID:       realm.ID,
+				Code:          code,
+				LongCode:      code + "11",
+				TestType:      "confirmed",
+				ExpiresAt:     time.Now().Add(time.Hour),
+				LongExpiresAt: time.Now().Add(time.Hour),
+			}
+			if err := realm.SaveVerificationCode(db, vc); err != nil {
+				t.Fatal(err)
+			}
+			if err := db.RawDB().
+				Model(&database.VerificationCode{}).
+				Where("id = ?", vc.ID).
+				UpdateColumn("hmac_key_id", keyID).
+				Error; err != nil {
+				t.Fatal(err)
+			}
+			codes = append(codes, vc)
+		}
+
+		// Deactivate the key.
+		if err := c.rotateSecret(ctx, typ, parent, numBytes, 0, 1*time.Nanosecond); err != nil {
+			t.Fatal(err)
+		}
+
+		// checkSecrets rotates once the key would otherwise be marked for deletion
+		// and checks the number of secrets that remain.
+		checkSecrets := func(tb testing.TB, want int) {
+			tb.Helper()
+
+			time.Sleep(cfg.SecretActivationTTL + 100*time.Millisecond)
+			if err := c.rotateSecret(ctx, typ, parent, numBytes, 0, 1*time.Nanosecond); err != nil {
+				tb.Fatal(err)
+			}
+			secrets, err := db.ListSecrets()
+			if err != nil {
+				tb.Fatal(err)
+			}
+			if got := len(secrets); got != want {
+				tb.Fatalf("expected %d secret, got %d: %#v", want, got, secrets)
+			}
+		}
+
+		// Both codes are outstanding, so the inactive key is kept.
+		checkSecrets(t, 1)
+
+		// One code is used, but the other is still outstanding.
+		if err := db.RawDB().
+			Model(&database.VerificationCode{}).
+			Where("id = ?", codes[0].ID).
+			UpdateColumn("claimed", true).
+			Error; err != nil {
+			t.Fatal(err)
+		}
+		checkSecrets(t, 1)
+
+		// Once the last code expires, the key is marked for deletion.
+		past := time.Now().Add(-1 * time.Minute)
+		if err := db.RawDB().
+			Model(&database.VerificationCode{}).
+			Where("id = ?", codes[1].ID).
+			UpdateColumns(map[string]interface{}{
+				"expires_at":      past,
+				"long_expires_at": past,
+			}).
+			Error; err != nil {
+			t.Fatal(err)
+		}
+		checkSecrets(t, 0)
+	})
 }
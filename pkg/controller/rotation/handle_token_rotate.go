@@ -21,6 +21,7 @@ import (
 	"time"
 
 	"github.com/google/exposure-notifications-server/pkg/logging"
+	"github.com/google/exposure-notifications-verification-server/pkg/controller/jobstatus"
 	"github.com/google/exposure-notifications-verification-server/pkg/database"
 	"github.com/hashicorp/go-multierror"
 	"go.opencensus.io/stats"
@@ -43,6 +44,7 @@ func (c *Controller) HandleRotateTokenSigningKey() http.Handler {
 		}
 		if !ok {
 			logger.Debugw("skipping (too early)")
+			jobstatus.RecordFreshness(ctx, c.db, jobstatus.JobRotateTokenKeys)
 			c.h.RenderJSON(w, http.StatusOK, fmt.Errorf("too early"))
 			return
 		}
@@ -50,10 +52,12 @@ func (c *Controller) HandleRotateTokenSigningKey() http.Handler {
 		// If there are any errors, return them
 		if err := c.RotateTokenSigningKey(ctx); err != nil {
 			logger.Errorw("failed to rotate", "error", err)
+			jobstatus.Record(ctx, c.db, jobstatus.JobRotateTokenKeys, 0, err)
 			c.h.RenderJSON(w, http.StatusInternalServerError, err)
 			return
 		}
 
+		jobstatus.Record(ctx, c.db, jobstatus.JobRotateTokenKeys, 0, nil)
 		stats.Record(ctx, mTokenSuccess.M(1))
 		c.h.RenderJSON(w, http.StatusOK, nil)
 	})
@@ -88,6 +92,7 @@ func (c *Controller) RotateTokenSigningKey(ctx context.Context) error {
 			return
 		}
 		logger.Infow("rotated token signing key", "new", key)
+		c.recordRotation(ctx, "token signing key", 0, "rotated token signing key")
 	}()
 
 	return merr.ErrorOrNil()
@@ -20,6 +20,8 @@ import (
 	"net/http"
 	"time"
 
+	"github.com/dustin/go-humanize"
+	"github.com/google/exposure-notifications-verification-server/pkg/controller/jobstatus"
 	"github.com/google/exposure-notifications-verification-server/pkg/database"
 	"github.com/google/exposure-notifications-verification-server/pkg/pagination"
 	"go.opencensus.io/stats"
@@ -48,6 +50,7 @@ func (c *Controller) HandleRotateVerificationKeys() http.Handler {
 		}
 		if !ok {
 			logger.Debugw("skipping (too early)")
+			jobstatus.RecordFreshness(ctx, c.db, jobstatus.JobRotateVerificationKeys)
 			c.h.RenderJSON(w, http.StatusOK, fmt.Errorf("too early"))
 			return
 		}
@@ -55,10 +58,12 @@ func (c *Controller) HandleRotateVerificationKeys() http.Handler {
 		// If there are any errors, return them
 		if err := c.RotateVerificationKeys(ctx); err != nil {
 			logger.Errorw("failed to rotate verification keys", "error", err)
+			jobstatus.Record(ctx, c.db, jobstatus.JobRotateVerificationKeys, 0, err)
 			c.h.RenderJSON(w, http.StatusInternalServerError, err)
 			return
 		}
 
+		jobstatus.Record(ctx, c.db, jobstatus.JobRotateVerificationKeys, 0, nil)
 		stats.Record(ctx, mVerificationSuccess.M(1))
 		c.h.RenderJSON(w, http.StatusOK, nil)
 	})
@@ -101,17 +106,45 @@ func (c *Controller) createNewKeys(ctx context.Context, realms []*database.Realm
 		}
 		// if there isn't a key, or the most recently created key is "too old" - create a new key.
 		if len(keys) == 0 || (keys[0].Active && keys[0].CreatedAt.Add(c.config.VerificationSigningKeyMaxAge).Before(now)) {
-			if _, err := realm.CreateSigningKeyVersion(ctx, c.db, RotationActor); err != nil {
+			version, err := realm.CreateSigningKeyVersion(ctx, c.db, RotationActor)
+			if err != nil {
 				merr = multierror.Append(merr, fmt.Errorf("unable to create signing key for realm %d: %w", realm.ID, err))
 				continue
 			}
 			logger.Infow("created new verification signing key", "realm", realm.ID)
+			c.recordRotation(ctx, "verification signing key", realm.ID, "created verification signing key")
+
+			// The first key is active immediately, so only rotations are posted.
+			if len(keys) > 0 {
+				c.postRotationNotification(ctx, realm.ID, version)
+			}
 		}
 	}
 
 	return nil
 }
 
+// postRotationNotification tells the realm's admins that a new signing key
+// will become active after the activation delay. Failures are logged, since
+// the rotation itself succeeded.
+func (c *Controller) postRotationNotification(ctx context.Context, realmID uint, version string) {
+	logger := logging.FromContext(ctx)
+
+	if _, err := c.db.PostRealmNotification(&database.RealmNotification{
+		RealmID:   realmID,
+		Kind:      database.RealmNotificationKindSigningKeyRotation,
+		DedupeKey: version,
+		Title:     "Certificate signing key rotation scheduled",
+		Body: fmt.Sprintf("A new certificate signing key was created and will become active %s. "+
+			"If your key server does not load this realm's public keys automatically, add the new public key "+
+			"to your key server before then.", humanize.Time(time.Now().Add(c.config.VerificationActivationDelay))),
+		Link:      "/realm/keys",
+		SendEmail: true,
+	}); err != nil {
+		logger.Errorw("failed to post rotation notification", "realm", realmID, "error", err)
+	}
+}
+
 func (c *Controller) activateKeys(ctx context.Context, realms []*database.Realm) error {
 	logger := logging.FromContext(ctx)
 	now := time.Now().UTC()
@@ -138,12 +171,15 @@ func (c *Controller) activateKeys(ctx context.Context, realms []*database.Realm)
 			}
 
 			logger.Infow("activated new realm signing key", "realm", realm.ID, "kid", keys[0].GetKID())
+			c.recordRotation(ctx, "verification signing key", realm.ID,
+				fmt.Sprintf("activated verification signing key %s", keys[0].GetKID()))
 		}
 
-		// Destroy any keys that are eligible for destruction.
+		// Destroy any keys that are eligible for destruction. Keys migrated to a
+		// different key manager are destroyed by the migrate-keys command instead.
 		if len(keys) > 1 {
 			for i := 1; i < len(keys); i++ {
-				if !keys[i].Active && keys[i].UpdatedAt.Add(c.config.VerificationActivationDelay).Before(now) {
+				if !keys[i].Active && keys[i].DestroyAfter == nil && keys[i].UpdatedAt.Add(c.config.VerificationActivationDelay).Before(now) {
 					if err := realm.DestroySigningKeyVersion(ctx, c.db, keys[i].ID, RotationActor); err != nil {
 						logger.Errorw("failed to destroy signing key", "realm", realm.ID, "error", err)
 						merr = multierror.Append(merr, err)
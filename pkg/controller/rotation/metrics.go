@@ -29,6 +29,7 @@ const metricPrefix = observability.MetricRoot + "/rotation"
 var (
 	mClaimRequests       = stats.Int64(metricPrefix+"/claim_requests", "The number of rotation claim requests.", stats.UnitDimensionless)
 	mLatencyMs           = stats.Float64(metricPrefix+"/requests", "The number of rotation requests.", stats.UnitMilliseconds)
+	mCookieKeysSuccess   = stats.Int64(metricPrefix+"/cookie_keys_success", "successful cookie keys rotation", stats.UnitDimensionless)
 	mSecretsSuccess      = stats.Int64(metricPrefix+"/secrets_success", "successful secrets rotation", stats.UnitDimensionless)
 	mTokenSuccess        = stats.Int64(metricPrefix+"/token_success", "successful token rotation", stats.UnitDimensionless)
 	mVerificationSuccess = stats.Int64(metricPrefix+"/verification_success", "successful verification rotation", stats.UnitDimensionless)
@@ -59,6 +60,13 @@ func init() {
 			TagKeys:     append(observability.CommonTagKeys(), enobs.ResultTagKey),
 			Aggregation: view.Count(),
 		},
+		{
+			Name:        metricPrefix + "/cookie_keys/success",
+			Description: "Number of cookie keys rotation successes",
+			TagKeys:     observability.CommonTagKeys(),
+			Measure:     mCookieKeysSuccess,
+			Aggregation: view.Count(),
+		},
 		{
 			Name:        metricPrefix + "/secrets/success",
 			Description: "Number of secrets rotation successes",
@@ -16,7 +16,10 @@
 package rotation
 
 import (
+	"context"
+
 	"github.com/google/exposure-notifications-server/pkg/keys"
+	"github.com/google/exposure-notifications-server/pkg/logging"
 	"github.com/google/exposure-notifications-server/pkg/secrets"
 	"github.com/google/exposure-notifications-verification-server/pkg/config"
 	"github.com/google/exposure-notifications-verification-server/pkg/database"
@@ -24,6 +27,7 @@ import (
 )
 
 const (
+	cookieKeysRotationLock   = "cookieKeysRotationLock"
 	secretsRotationLock      = "secretsRotationLock"
 	tokenRotationLock        = "tokenRotationLock"
 	verificationRotationLock = "verificationRotationLock"
@@ -59,3 +63,18 @@ func (s *rotationActor) AuditID() string {
 func (s *rotationActor) AuditDisplay() string {
 	return "Rotation"
 }
+
+// recordRotation records a key rotation on the system event timeline. Failures
+// are only logged, because the rotation itself has already happened.
+func (c *Controller) recordRotation(ctx context.Context, source string, realmID uint, message string) {
+	logger := logging.FromContext(ctx).Named("rotation.recordRotation")
+
+	if err := c.db.RecordSystemEvent(&database.SystemEvent{
+		Kind:    database.SystemEventKeyRotation,
+		Source:  source,
+		RealmID: realmID,
+		Message: message,
+	}); err != nil {
+		logger.Errorw("failed to record key rotation", "source", source, "error", err)
+	}
+}
@@ -0,0 +1,95 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scheduler
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/google/exposure-notifications-verification-server/pkg/controller"
+)
+
+// runHistoryLimit is the number of runs returned by HandleRuns.
+const runHistoryLimit = 100
+
+type jobResponse struct {
+	Name       string     `json:"name"`
+	URL        string     `json:"url"`
+	Interval   string     `json:"interval"`
+	LastRunAt  *time.Time `json:"last_run_at,omitempty"`
+	NextRunAt  *time.Time `json:"next_run_at,omitempty"`
+	LastStatus int        `json:"last_status,omitempty"`
+	LastError  string     `json:"last_error,omitempty"`
+}
+
+type runResponse struct {
+	Job        string    `json:"job"`
+	Leader     string    `json:"leader"`
+	StartedAt  time.Time `json:"started_at"`
+	DurationMs int64     `json:"duration_ms"`
+	StatusCode int       `json:"status_code"`
+	Error      string    `json:"error,omitempty"`
+}
+
+// HandleRuns returns the configured jobs and the recent run history as JSON.
+func (c *Controller) HandleRuns() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		latest, err := c.db.LatestSchedulerRuns()
+		if err != nil {
+			controller.InternalError(w, r, c.h, err)
+			return
+		}
+
+		history, err := c.db.ListSchedulerRuns(runHistoryLimit)
+		if err != nil {
+			controller.InternalError(w, r, c.h, err)
+			return
+		}
+
+		jobs := make([]*jobResponse, 0, len(latest))
+		for _, job := range c.config.Jobs() {
+			resp := &jobResponse{
+				Name:     job.Name,
+				URL:      job.URL,
+				Interval: job.Interval.String(),
+			}
+			if last, ok := latest[job.Name]; ok {
+				resp.LastRunAt = &last.StartedAt
+				resp.NextRunAt = &last.NextRunAt
+				resp.LastStatus = last.StatusCode
+				resp.LastError = last.Error
+			}
+			jobs = append(jobs, resp)
+		}
+
+		runs := make([]*runResponse, 0, len(history))
+		for _, run := range history {
+			runs = append(runs, &runResponse{
+				Job:        run.Job,
+				Leader:     run.Leader,
+				StartedAt:  run.StartedAt,
+				DurationMs: run.FinishedAt.Sub(run.StartedAt).Milliseconds(),
+				StatusCode: run.StatusCode,
+				Error:      run.Error,
+			})
+		}
+
+		c.h.RenderJSON(w, http.StatusOK, map[string]interface{}{
+			"identity": c.identity,
+			"jobs":     jobs,
+			"runs":     runs,
+		})
+	})
+}
@@ -0,0 +1,61 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scheduler
+
+import (
+	enobs "github.com/google/exposure-notifications-server/pkg/observability"
+	"github.com/google/exposure-notifications-verification-server/pkg/observability"
+
+	"go.opencensus.io/stats"
+	"go.opencensus.io/stats/view"
+	"go.opencensus.io/tag"
+)
+
+const metricPrefix = observability.MetricRoot + "/scheduler"
+
+var (
+	mRuns      = stats.Int64(metricPrefix+"/runs", "scheduled job triggers", stats.UnitDimensionless)
+	mLatencyMs = stats.Float64(metricPrefix+"/latency", "scheduled job latency", stats.UnitMilliseconds)
+	mLeader    = stats.Int64(metricPrefix+"/leader", "whether this instance is the leader", stats.UnitDimensionless)
+
+	// jobTagKey is the name of the job.
+	jobTagKey = tag.MustNewKey("job")
+)
+
+func init() {
+	enobs.CollectViews([]*view.View{
+		{
+			Name:        metricPrefix + "/runs",
+			Description: "Number of scheduled job triggers, by result",
+			TagKeys:     append(observability.CommonTagKeys(), jobTagKey, enobs.ResultTagKey),
+			Measure:     mRuns,
+			Aggregation: view.Count(),
+		},
+		{
+			Name:        metricPrefix + "/latency",
+			Description: "Distribution of scheduled job latency",
+			TagKeys:     append(observability.CommonTagKeys(), jobTagKey),
+			Measure:     mLatencyMs,
+			Aggregation: view.Distribution(1000, 5000, 15000, 30000, 60000, 120000, 300000, 600000),
+		},
+		{
+			Name:        metricPrefix + "/leader",
+			Description: "Whether this instance is the scheduler leader",
+			TagKeys:     observability.CommonTagKeys(),
+			Measure:     mLeader,
+			Aggregation: view.LastValue(),
+		},
+	}...)
+}
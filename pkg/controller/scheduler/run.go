@@ -0,0 +1,246 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"time"
+
+	"github.com/google/exposure-notifications-server/pkg/logging"
+	enobs "github.com/google/exposure-notifications-server/pkg/observability"
+	"github.com/google/exposure-notifications-verification-server/pkg/config"
+	"github.com/google/exposure-notifications-verification-server/pkg/database"
+
+	"go.opencensus.io/stats"
+	"go.opencensus.io/tag"
+	"google.golang.org/api/idtoken"
+)
+
+// purgeInterval is how often old run history is purged.
+const purgeInterval = time.Hour
+
+// maxErrorBodyBytes is the maximum number of bytes of a failed job's response
+// recorded in the run history.
+const maxErrorBodyBytes = 1024
+
+// Run checks for due jobs every tick until the context is canceled. Only the
+// leader triggers jobs; the other instances keep trying to claim the lease so
+// they can take over if the leader stops.
+func (c *Controller) Run(ctx context.Context) error {
+	logger := logging.FromContext(ctx).Named("scheduler.Run")
+	logger.Infow("starting scheduler", "identity", c.identity, "jobs", len(c.config.Jobs()))
+
+	ticker := time.NewTicker(c.config.TickInterval)
+	defer ticker.Stop()
+
+	for {
+		if err := c.tick(ctx); err != nil {
+			logger.Errorw("scheduler tick failed", "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// tick claims leadership and triggers any due jobs.
+func (c *Controller) tick(ctx context.Context) error {
+	logger := logging.FromContext(ctx).Named("scheduler.tick")
+
+	leader, err := c.db.ClaimSchedulerLeadership(leaseName, c.identity, c.config.LeaseDuration)
+	if err != nil {
+		return err
+	}
+	stats.Record(ctx, mLeader.M(boolToInt64(leader)))
+	if !leader {
+		return nil
+	}
+
+	latest, err := c.db.LatestSchedulerRuns()
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().UTC()
+	for _, job := range c.config.Jobs() {
+		if !isDue(now, latest[job.Name]) {
+			continue
+		}
+		if !c.claimRunning(job.Name) {
+			logger.Debugw("job is still running", "job", job.Name)
+			continue
+		}
+
+		go func(job *config.ScheduledJob) {
+			defer c.releaseRunning(job.Name)
+			c.runJob(ctx, job)
+		}(job)
+	}
+
+	if now.Sub(c.lastPurge) > purgeInterval {
+		c.lastPurge = now
+		count, err := c.db.PurgeSchedulerRuns(c.config.RunHistoryMaxAge)
+		if err != nil {
+			return fmt.Errorf("failed to purge scheduler runs: %w", err)
+		}
+		logger.Debugw("purged scheduler runs", "count", count)
+	}
+	return nil
+}
+
+// runJob triggers the job and records the run.
+func (c *Controller) runJob(ctx context.Context, job *config.ScheduledJob) {
+	logger := logging.FromContext(ctx).Named("scheduler.runJob").With("job", job.Name)
+
+	run := c.trigger(ctx, job)
+
+	result := enobs.ResultOK
+	if run.Succeeded() {
+		logger.Debugw("job succeeded", "status", run.StatusCode)
+	} else {
+		result = enobs.ResultError("FAILED")
+		logger.Warnw("job failed", "status", run.StatusCode, "error", run.Error)
+	}
+
+	ctx, _ = tag.New(ctx, tag.Upsert(jobTagKey, job.Name))
+	stats.RecordWithTags(ctx, []tag.Mutator{result}, mRuns.M(1))
+	stats.Record(ctx, mLatencyMs.M(float64(run.FinishedAt.Sub(run.StartedAt).Milliseconds())))
+
+	if err := c.db.SaveSchedulerRun(run); err != nil {
+		logger.Errorw("failed to save scheduler run", "error", err)
+	}
+}
+
+// trigger makes the request to the job and returns the run. The run is not
+// saved.
+func (c *Controller) trigger(ctx context.Context, job *config.ScheduledJob) *database.SchedulerRun {
+	run := &database.SchedulerRun{
+		Job:       job.Name,
+		Leader:    c.identity,
+		StartedAt: time.Now().UTC(),
+	}
+	defer func() {
+		run.FinishedAt = time.Now().UTC()
+		run.NextRunAt = nextRunAt(run.StartedAt, job.Interval, c.config.MaxJitter)
+	}()
+
+	client, err := c.clientFor(job.Audience)
+	if err != nil {
+		run.Error = fmt.Sprintf("failed to create client: %s", err)
+		return run
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, c.config.RequestTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, job.Method, job.URL, nil)
+	if err != nil {
+		run.Error = fmt.Sprintf("failed to build request: %s", err)
+		return run
+	}
+	req.Header.Set("User-Agent", "verification-server-scheduler")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		run.Error = fmt.Sprintf("request failed: %s", err)
+		return run
+	}
+	defer resp.Body.Close()
+
+	run.StatusCode = resp.StatusCode
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		b, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBodyBytes))
+		run.Error = fmt.Sprintf("unexpected response %d: %s", resp.StatusCode, b)
+	}
+	return run
+}
+
+// defaultClientFor returns the client for the audience. When ID tokens are
+// enabled, each audience gets a client which attaches a Google-signed ID
+// token.
+func (c *Controller) defaultClientFor(audience string) (*http.Client, error) {
+	if !c.config.UseIDTokens {
+		return c.client, nil
+	}
+
+	c.idTokenClientsLock.Lock()
+	defer c.idTokenClientsLock.Unlock()
+
+	if client, ok := c.idTokenClients[audience]; ok {
+		return client, nil
+	}
+
+	client, err := idtoken.NewClient(context.Background(), audience)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create id token client for %s: %w", audience, err)
+	}
+	client.Timeout = c.config.RequestTimeout
+	c.idTokenClients[audience] = client
+	return client, nil
+}
+
+// claimRunning marks the job as running. It returns false if the job is
+// already running.
+func (c *Controller) claimRunning(name string) bool {
+	c.runningLock.Lock()
+	defer c.runningLock.Unlock()
+
+	if _, ok := c.running[name]; ok {
+		return false
+	}
+	c.running[name] = struct{}{}
+	return true
+}
+
+// releaseRunning marks the job as no longer running.
+func (c *Controller) releaseRunning(name string) {
+	c.runningLock.Lock()
+	defer c.runningLock.Unlock()
+
+	delete(c.running, name)
+}
+
+// isDue returns true if a job whose latest run is last should run at now. Jobs
+// which have never run are always due.
+func isDue(now time.Time, last *database.SchedulerRun) bool {
+	if last == nil {
+		return true
+	}
+	return !now.Before(last.NextRunAt)
+}
+
+// nextRunAt returns when a job started at started should next run, with up to
+// maxJitter of random delay.
+func nextRunAt(started time.Time, interval, maxJitter time.Duration) time.Time {
+	next := started.Add(interval)
+	if maxJitter > 0 {
+		next = next.Add(time.Duration(rand.Int63n(int64(maxJitter))))
+	}
+	return next
+}
+
+func boolToInt64(b bool) int64 {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,186 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scheduler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/exposure-notifications-verification-server/internal/project"
+	"github.com/google/exposure-notifications-verification-server/pkg/config"
+	"github.com/google/exposure-notifications-verification-server/pkg/database"
+)
+
+func TestIsDue(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now().UTC()
+
+	cases := []struct {
+		name string
+		last *database.SchedulerRun
+		exp  bool
+	}{
+		{
+			name: "never_run",
+			last: nil,
+			exp:  true,
+		},
+		{
+			name: "not_yet",
+			last: &database.SchedulerRun{NextRunAt: now.Add(time.Minute)},
+			exp:  false,
+		},
+		{
+			name: "exactly_now",
+			last: &database.SchedulerRun{NextRunAt: now},
+			exp:  true,
+		},
+		{
+			name: "overdue",
+			last: &database.SchedulerRun{NextRunAt: now.Add(-time.Hour)},
+			exp:  true,
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			if got, want := isDue(now, tc.last), tc.exp; got != want {
+				t.Errorf("expected %t to be %t", got, want)
+			}
+		})
+	}
+}
+
+func TestNextRunAt(t *testing.T) {
+	t.Parallel()
+
+	started := time.Now().UTC()
+	interval := 5 * time.Minute
+
+	if got, want := nextRunAt(started, interval, 0), started.Add(interval); !got.Equal(want) {
+		t.Errorf("expected %s to be %s", got, want)
+	}
+
+	maxJitter := 30 * time.Second
+	for i := 0; i < 100; i++ {
+		got := nextRunAt(started, interval, maxJitter)
+		if earliest := started.Add(interval); got.Before(earliest) {
+			t.Fatalf("expected %s to be at or after %s", got, earliest)
+		}
+		if latest := started.Add(interval + maxJitter); !got.Before(latest) {
+			t.Fatalf("expected %s to be before %s", got, latest)
+		}
+	}
+}
+
+func TestTrigger(t *testing.T) {
+	t.Parallel()
+
+	ctx := project.TestContext(t)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ok", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	mux.HandleFunc("/post", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	})
+	mux.HandleFunc("/fail", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "database is down", http.StatusInternalServerError)
+	})
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+
+	c := &Controller{
+		config: &config.SchedulerConfig{
+			RequestTimeout: 5 * time.Second,
+		},
+		identity: "test",
+		client:   srv.Client(),
+	}
+	c.clientFor = func(string) (*http.Client, error) { return c.client, nil }
+
+	cases := []struct {
+		name   string
+		path   string
+		method string
+		status int
+		err    string
+	}{
+		{
+			name:   "ok",
+			path:   "/ok",
+			method: http.MethodGet,
+			status: http.StatusOK,
+		},
+		{
+			name:   "post",
+			path:   "/post",
+			method: http.MethodPost,
+			status: http.StatusOK,
+		},
+		{
+			name:   "failure",
+			path:   "/fail",
+			method: http.MethodGet,
+			status: http.StatusInternalServerError,
+			err:    "database is down",
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			job := &config.ScheduledJob{
+				Name:     tc.name,
+				URL:      srv.URL + tc.path,
+				Method:   tc.method,
+				Interval: time.Minute,
+			}
+
+			run := c.trigger(ctx, job)
+			if got, want := run.StatusCode, tc.status; got != want {
+				t.Errorf("expected status %d to be %d", got, want)
+			}
+			if got, want := run.Succeeded(), tc.err == ""; got != want {
+				t.Errorf("expected succeeded %t to be %t", got, want)
+			}
+			if !strings.Contains(run.Error, tc.err) {
+				t.Errorf("expected %q to contain %q", run.Error, tc.err)
+			}
+			if got, want := run.Leader, "test"; got != want {
+				t.Errorf("expected leader %q to be %q", got, want)
+			}
+			if got, want := run.NextRunAt, run.StartedAt.Add(time.Minute); !got.Equal(want) {
+				t.Errorf("expected next run %s to be %s", got, want)
+			}
+		})
+	}
+}
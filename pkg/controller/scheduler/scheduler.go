@@ -0,0 +1,95 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package scheduler triggers the worker services on a schedule. It is an
+// alternative to Cloud Scheduler for small deployments. Multiple instances may
+// run for availability; a database lease elects a single leader which
+// triggers the jobs, and the run history is stored in the database so a new
+// leader continues the schedule.
+package scheduler
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/google/exposure-notifications-verification-server/internal/project"
+	"github.com/google/exposure-notifications-verification-server/pkg/config"
+	"github.com/google/exposure-notifications-verification-server/pkg/database"
+	"github.com/google/exposure-notifications-verification-server/pkg/render"
+)
+
+// leaseName is the name of the leadership lease in the database.
+const leaseName = "scheduler"
+
+// Controller is a controller for the scheduler service.
+type Controller struct {
+	config *config.SchedulerConfig
+	db     *database.Database
+	h      *render.Renderer
+
+	// identity is the unique identity of this instance for leader election.
+	identity string
+
+	// client makes requests to jobs. clientFor may return a different client
+	// per audience when ID tokens are enabled.
+	client    *http.Client
+	clientFor func(audience string) (*http.Client, error)
+
+	idTokenClients     map[string]*http.Client
+	idTokenClientsLock sync.Mutex
+
+	// running is the set of jobs with a request in flight, so a slow job is not
+	// triggered again before it finishes.
+	running     map[string]struct{}
+	runningLock sync.Mutex
+
+	lastPurge time.Time
+}
+
+// New creates a new scheduler controller.
+func New(cfg *config.SchedulerConfig, db *database.Database, h *render.Renderer) (*Controller, error) {
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "scheduler"
+	}
+	suffix, err := project.RandomHexString(8)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate scheduler identity: %w", err)
+	}
+
+	c := &Controller{
+		config: cfg,
+		db:     db,
+		h:      h,
+
+		identity: hostname + "-" + suffix,
+
+		client: &http.Client{
+			Timeout: cfg.RequestTimeout,
+		},
+
+		idTokenClients: make(map[string]*http.Client),
+		running:        make(map[string]struct{}),
+	}
+	c.clientFor = c.defaultClientFor
+	return c, nil
+}
+
+// Identity returns the identity of this instance for leader election.
+func (c *Controller) Identity() string {
+	return c.identity
+}
@@ -0,0 +1,35 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"net/http"
+
+	"github.com/google/exposure-notifications-verification-server/pkg/api"
+	"github.com/google/exposure-notifications-verification-server/pkg/render"
+)
+
+// HandleSchema renders the current and minimum supported API schema versions
+// and the schema changelog, so client teams can check for breaking changes
+// before upgrading.
+func HandleSchema(h *render.Renderer) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h.RenderJSON(w, http.StatusOK, &api.SchemaChangelogResponse{
+			CurrentVersion: api.SchemaVersion,
+			MinVersion:     api.MinSchemaVersion,
+			Changelog:      api.SchemaChangelog,
+		})
+	})
+}
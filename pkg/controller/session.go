@@ -32,6 +32,7 @@ const (
 	passwordExpireWarned              = sessionKey("passwordExpireWarned")
 	sessionKeyAPIKey                  = sessionKey("apiKey")
 	sessionKeyCSRFToken               = sessionKey("csrfToken")
+	sessionKeyElevationAudited        = sessionKey("elevationAudited")
 	sessionKeyLastActivity            = sessionKey("lastActivity")
 	sessionKeyRealmID                 = sessionKey("realmID")
 	sessionKeyWelcomeMessageDisplayed = sessionKey("welcomeMessageDisplayed")
@@ -236,6 +237,33 @@ func LastActivityFromSession(session *sessions.Session) time.Time {
 	return time.Unix(i, 0)
 }
 
+// StoreSessionElevationAudited stores the authentication time for which an
+// elevation audit entry has already been recorded, so each re-authentication
+// is only audited once.
+func StoreSessionElevationAudited(session *sessions.Session, t time.Time) {
+	if session == nil {
+		return
+	}
+	session.Values[sessionKeyElevationAudited] = t.Unix()
+}
+
+// ElevationAuditedFromSession extracts the authentication time for which an
+// elevation audit entry was last recorded.
+func ElevationAuditedFromSession(session *sessions.Session) time.Time {
+	v := sessionGet(session, sessionKeyElevationAudited)
+	if v == nil {
+		return time.Time{}
+	}
+
+	i, ok := v.(int64)
+	if !ok || i == 0 {
+		delete(session.Values, sessionKeyElevationAudited)
+		return time.Time{}
+	}
+
+	return time.Unix(i, 0)
+}
+
 // StoreSessionEmailVerificationPrompted stores if the user was prompted for email verification.
 func StoreSessionEmailVerificationPrompted(session *sessions.Session, prompted bool) {
 	if session == nil {
@@ -51,7 +51,7 @@ func (c *Controller) renderShow(ctx context.Context, w http.ResponseWriter, r *h
 			m["activeRealmKey"] = k.GetKID()
 			m["activePublicKey"] = ""
 		}
-		pk, err := c.publicKeyCache.GetPublicKey(ctx, k.KeyID, c.db.KeyManager())
+		pk, err := c.publicKeyCache.GetManagedPublicKey(ctx, k.KeyID, k.PublicKey, c.db.KeyManager())
 		if err != nil {
 			publicKeys[k.GetKID()] = fmt.Errorf("error loading public key: %w", err).Error()
 		} else {
@@ -19,6 +19,7 @@ import (
 	"sort"
 	"time"
 
+	"github.com/google/exposure-notifications-verification-server/internal/project"
 	"github.com/google/exposure-notifications-verification-server/pkg/controller"
 	"github.com/google/exposure-notifications-verification-server/pkg/database"
 	"github.com/google/exposure-notifications-verification-server/pkg/rbac"
@@ -43,6 +44,12 @@ func (c *Controller) HandleComposite(typ Type) http.Handler {
 			return
 		}
 
+		// Statistics requested via API key may be published, so apply the realm's
+		// small-count privacy settings.
+		if controller.MembershipFromContext(ctx) == nil {
+			realmStats = realmStats.WithPrivacy(currentRealm)
+		}
+
 		stats := database.CompositeStats(make([]*database.CompositeDay, 0, len(realmStats)))
 		statsMap := make(map[time.Time]*database.CompositeDay, len(realmStats))
 		for _, rs := range realmStats {
@@ -83,6 +90,25 @@ func (c *Controller) HandleComposite(typ Type) http.Handler {
 			})
 		}
 
+		// Attach annotations. These are not cached so that changes are visible
+		// immediately.
+		annotations, err := currentRealm.ListStatsAnnotations(c.db)
+		if err != nil {
+			controller.InternalError(w, r, c.h, err)
+			return
+		}
+
+		corrections, err := currentRealm.ListStatsCorrections(c.db)
+		if err != nil {
+			controller.InternalError(w, r, c.h, err)
+			return
+		}
+		annotations = append(annotations, corrections.Annotations()...)
+		annotationsByDate := annotations.ByDate()
+		for _, day := range stats {
+			day.Annotations = annotationsByDate[day.Day.Format(project.RFC3339Date)]
+		}
+
 		// Trim empty days.
 		trimIdx := 0
 		for i, v := range stats {
@@ -0,0 +1,187 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stats
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/google/exposure-notifications-verification-server/internal/icsv"
+	"github.com/google/exposure-notifications-verification-server/internal/project"
+	"github.com/google/exposure-notifications-verification-server/pkg/api"
+	"github.com/google/exposure-notifications-verification-server/pkg/controller"
+	"github.com/google/exposure-notifications-verification-server/pkg/database"
+)
+
+const (
+	// QueryKeyStart, QueryKeyEnd, and QueryKeyDataset are the query keys for
+	// the export date range (YYYY-MM-DD, inclusive) and dataset.
+	QueryKeyStart   = "start"
+	QueryKeyEnd     = "end"
+	QueryKeyDataset = "dataset"
+
+	// DatasetComposite is the realm and key-server statistics by day.
+	// DatasetSMSErrors is the SMS error statistics by day and error code.
+	// DatasetEpiWeekly is the epidemiological statistics by week and test type.
+	DatasetComposite = "composite"
+	DatasetSMSErrors = "sms-errors"
+	DatasetEpiWeekly = "epi-weekly"
+
+	// maxExportDays is the largest date range that can be exported in one
+	// request.
+	maxExportDays = 366
+)
+
+// HandleExport exports the realm's statistics for a date range. Unlike the
+// other stats handlers, it is not limited to the stats display period and the
+// results are not cached. It is only available via an admin API key.
+func (c *Controller) HandleExport(typ Type) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		authorizedApp := controller.AuthorizedAppFromContext(ctx)
+		if authorizedApp == nil {
+			controller.Unauthorized(w, r, c.h)
+			return
+		}
+		currentRealm, err := authorizedApp.Realm(c.db)
+		if err != nil {
+			controller.InternalError(w, r, c.h, err)
+			return
+		}
+
+		start, end, err := exportDateRange(r, currentRealm.StatsToday())
+		if err != nil {
+			c.h.RenderJSON(w, http.StatusBadRequest, api.Error(err).WithCode(api.ErrInvalidDate))
+			return
+		}
+
+		var stats icsv.Marshaler
+		var filename string
+		switch dataset := r.FormValue(QueryKeyDataset); dataset {
+		case "", DatasetComposite:
+			composite, err := c.exportCompositeStats(currentRealm, start, end)
+			if err != nil {
+				controller.InternalError(w, r, c.h, err)
+				return
+			}
+			stats, filename = composite, "composite-stats"
+		case DatasetSMSErrors:
+			smsErrors, err := currentRealm.SMSErrorStatsBetween(c.db, start, end)
+			if err != nil {
+				controller.InternalError(w, r, c.h, err)
+				return
+			}
+			stats, filename = smsErrors, "sms-error-stats"
+		case DatasetEpiWeekly:
+			epiWeekly, err := currentRealm.EpiWeeklyStatsBetween(c.db, start, end)
+			if err != nil {
+				controller.InternalError(w, r, c.h, err)
+				return
+			}
+			stats, filename = epiWeekly.WithPrivacy(currentRealm), "epi-weekly-stats"
+		default:
+			c.h.RenderJSON(w, http.StatusBadRequest, api.Errorf("unknown dataset %q", dataset).WithCode(api.ErrInvalidRequest))
+			return
+		}
+
+		switch typ {
+		case TypeCSV:
+			c.h.RenderCSV(w, http.StatusOK, csvFilename(filename), stats)
+			return
+		case TypeJSON:
+			c.h.RenderJSON(w, http.StatusOK, stats)
+			return
+		default:
+			controller.NotFound(w, r, c.h)
+			return
+		}
+	})
+}
+
+// exportDateRange parses the export date range from the request. The range
+// defaults to the stats display period ending today, the current day in the
+// realm's stats timezone.
+func exportDateRange(r *http.Request, today time.Time) (time.Time, time.Time, error) {
+	end := today
+	if v := r.FormValue(QueryKeyEnd); v != "" {
+		t, err := time.Parse(project.RFC3339Date, v)
+		if err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("end must be a date in the format YYYY-MM-DD")
+		}
+		end = t
+	}
+
+	start := end.Add(project.StatsDisplayDays * -24 * time.Hour)
+	if v := r.FormValue(QueryKeyStart); v != "" {
+		t, err := time.Parse(project.RFC3339Date, v)
+		if err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("start must be a date in the format YYYY-MM-DD")
+		}
+		start = t
+	}
+
+	if start.After(end) {
+		return time.Time{}, time.Time{}, fmt.Errorf("start must be on or before end")
+	}
+	if end.Sub(start) >= maxExportDays*24*time.Hour {
+		return time.Time{}, time.Time{}, fmt.Errorf("date range cannot be more than %d days", maxExportDays)
+	}
+	return start, end, nil
+}
+
+// exportCompositeStats builds the composite stats for the date range, oldest
+// first. The realm's privacy settings are always applied because exports are
+// requested via API key.
+func (c *Controller) exportCompositeStats(realm *database.Realm, start, end time.Time) (database.CompositeStats, error) {
+	realmStats, err := realm.StatsBetween(c.db, start, end)
+	if err != nil {
+		return nil, err
+	}
+	realmStats = realmStats.WithPrivacy(realm)
+
+	stats := database.CompositeStats(make([]*database.CompositeDay, 0, len(realmStats)))
+	statsMap := make(map[time.Time]*database.CompositeDay, len(realmStats))
+	for _, rs := range realmStats {
+		day := &database.CompositeDay{
+			Day:        rs.Date,
+			RealmStats: rs,
+		}
+		stats = append(stats, day)
+		statsMap[rs.Date] = day
+	}
+
+	days, err := c.db.ListKeyServerStatsDaysBetween(realm.ID, start, end)
+	if err != nil {
+		return nil, err
+	}
+	for _, ksDay := range days {
+		compDay, ok := statsMap[ksDay.Day]
+		if !ok {
+			compDay = &database.CompositeDay{
+				Day: ksDay.Day,
+			}
+			stats = append(stats, compDay)
+		}
+		compDay.KeyServerStats = ksDay.ToResponse()
+	}
+
+	sort.Slice(stats, func(i, j int) bool {
+		return stats[i].Day.Before(stats[j].Day)
+	})
+	return stats, nil
+}
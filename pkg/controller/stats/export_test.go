@@ -0,0 +1,102 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stats
+
+import (
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/google/exposure-notifications-server/pkg/timeutils"
+	"github.com/google/exposure-notifications-verification-server/internal/project"
+)
+
+func TestExportDateRange(t *testing.T) {
+	t.Parallel()
+
+	today := timeutils.UTCMidnight(time.Now())
+
+	cases := []struct {
+		name      string
+		query     url.Values
+		start     time.Time
+		end       time.Time
+		expectErr bool
+	}{
+		{
+			name:  "default",
+			query: url.Values{},
+			start: today.Add(project.StatsDisplayDays * -24 * time.Hour),
+			end:   today,
+		},
+		{
+			name:  "range",
+			query: url.Values{"start": {"2022-01-01"}, "end": {"2022-03-31"}},
+			start: time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC),
+			end:   time.Date(2022, 3, 31, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name:  "end_only",
+			query: url.Values{"end": {"2022-03-31"}},
+			start: time.Date(2022, 3, 31, 0, 0, 0, 0, time.UTC).Add(project.StatsDisplayDays * -24 * time.Hour),
+			end:   time.Date(2022, 3, 31, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name:      "bad_start",
+			query:     url.Values{"start": {"01/01/2022"}},
+			expectErr: true,
+		},
+		{
+			name:      "bad_end",
+			query:     url.Values{"end": {"nope"}},
+			expectErr: true,
+		},
+		{
+			name:      "start_after_end",
+			query:     url.Values{"start": {"2022-02-01"}, "end": {"2022-01-01"}},
+			expectErr: true,
+		},
+		{
+			name:      "too_long",
+			query:     url.Values{"start": {"2020-01-01"}, "end": {"2022-01-01"}},
+			expectErr: true,
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			r := httptest.NewRequest("GET", "/api/stats/export.json?"+tc.query.Encode(), nil)
+			start, end, err := exportDateRange(r, today)
+			if (err != nil) != tc.expectErr {
+				t.Fatalf("expected error: %t, got: %v", tc.expectErr, err)
+			}
+			if err != nil {
+				return
+			}
+
+			if !start.Equal(tc.start) {
+				t.Errorf("expected start %s to be %s", start, tc.start)
+			}
+			if !end.Equal(tc.end) {
+				t.Errorf("expected end %s to be %s", end, tc.end)
+			}
+		})
+	}
+}
@@ -57,3 +57,34 @@ func (c *Controller) HandleKeyServerStats(typ Type) http.Handler {
 		}
 	})
 }
+
+// HandleKeyServerSourceStats renders the current realm's key server publish
+// requests, broken out by key server source.
+func (c *Controller) HandleKeyServerSourceStats(typ Type) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		currentRealm, ok := authorizeFromContext(ctx, rbac.StatsRead, rbac.UserRead)
+		if !ok {
+			controller.Unauthorized(w, r, c.h)
+			return
+		}
+
+		stats, err := c.db.ListKeyServerSourceStatsCached(ctx, currentRealm.ID, c.cacher)
+		if err != nil {
+			controller.InternalError(w, r, c.h, err)
+			return
+		}
+
+		switch typ {
+		case TypeCSV:
+			c.h.RenderCSV(w, http.StatusOK, csvFilename("key-server-source-stats"), stats)
+			return
+		case TypeJSON:
+			c.h.RenderJSON(w, http.StatusOK, stats)
+			return
+		default:
+			controller.NotFound(w, r, c.h)
+			return
+		}
+	})
+}
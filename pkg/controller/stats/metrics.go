@@ -0,0 +1,54 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stats
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/google/exposure-notifications-verification-server/pkg/api"
+	"github.com/google/exposure-notifications-verification-server/pkg/controller"
+	"github.com/google/exposure-notifications-verification-server/pkg/rbac"
+)
+
+// HandleMetrics renders the definitions of the metrics in the statistics APIs.
+func (c *Controller) HandleMetrics() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		if _, ok := authorizeFromContext(ctx, rbac.StatsRead); !ok {
+			controller.Unauthorized(w, r, c.h)
+			return
+		}
+
+		c.h.RenderJSON(w, http.StatusOK, &api.StatsMetricsResponse{
+			Metrics: api.StatsMetrics,
+		})
+	})
+}
+
+// LinkMetrics is middleware that adds a Link header pointing to the metric
+// definitions at target, so every statistics response carries the definitions
+// of its metrics alongside it.
+func LinkMetrics(target string) func(http.Handler) http.Handler {
+	link := fmt.Sprintf(`<%s>; rel="describedby"`, target)
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Add("Link", link)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
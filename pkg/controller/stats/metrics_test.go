@@ -0,0 +1,37 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stats
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestLinkMetrics(t *testing.T) {
+	t.Parallel()
+
+	h := LinkMetrics("/api/stats/metrics.json")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/api/stats/realm.json", nil)
+	h.ServeHTTP(w, r)
+
+	if got, want := w.Header().Get("Link"), `</api/stats/metrics.json>; rel="describedby"`; got != want {
+		t.Errorf("expected Link header %q to be %q", got, want)
+	}
+}
@@ -39,6 +39,26 @@ func (c *Controller) HandleRealmStats(typ Type) http.Handler {
 			return
 		}
 
+		annotations, err := currentRealm.ListStatsAnnotations(c.db)
+		if err != nil {
+			controller.InternalError(w, r, c.h, err)
+			return
+		}
+
+		corrections, err := currentRealm.ListStatsCorrections(c.db)
+		if err != nil {
+			controller.InternalError(w, r, c.h, err)
+			return
+		}
+		annotations = append(annotations, corrections.Annotations()...)
+		stats = stats.WithAnnotations(annotations)
+
+		// Statistics requested via API key may be published, so apply the realm's
+		// small-count privacy settings.
+		if controller.MembershipFromContext(ctx) == nil {
+			stats = stats.WithPrivacy(currentRealm)
+		}
+
 		switch typ {
 		case TypeCSV:
 			c.h.RenderCSV(w, http.StatusOK, csvFilename("realm-stats"), stats)
@@ -18,10 +18,17 @@ package stats
 import (
 	"net/http"
 
+	"github.com/google/exposure-notifications-verification-server/internal/project"
+	"github.com/google/exposure-notifications-verification-server/pkg/api"
 	"github.com/google/exposure-notifications-verification-server/pkg/controller"
+	"github.com/google/exposure-notifications-verification-server/pkg/pagination"
 	"github.com/google/exposure-notifications-verification-server/pkg/rbac"
 )
 
+// QueryKeyIssuer is the query key that filters external issuer issuance to a
+// single external issuer ID.
+const QueryKeyIssuer = "issuer"
+
 // HandleRealmExternalIssuersStats renders statistics for the current realm.
 func (c *Controller) HandleRealmExternalIssuersStats(typ Type) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -52,3 +59,63 @@ func (c *Controller) HandleRealmExternalIssuersStats(typ Type) http.Handler {
 		}
 	})
 }
+
+// HandleRealmExternalIssuersIssuance returns the code issuance of the current
+// realm's external issuers as JSON. Unlike HandleRealmExternalIssuersStats, it
+// accepts a date range, is paginated by issuer, and is not cached, so callers
+// can reconcile issuance against their own records.
+func (c *Controller) HandleRealmExternalIssuersIssuance() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		currentRealm, ok := authorizeFromContext(ctx, rbac.StatsRead)
+		if !ok {
+			controller.Unauthorized(w, r, c.h)
+			return
+		}
+
+		start, end, err := exportDateRange(r, currentRealm.StatsToday())
+		if err != nil {
+			c.h.RenderJSON(w, http.StatusBadRequest, api.Error(err).WithCode(api.ErrInvalidDate))
+			return
+		}
+
+		pageParams, err := pagination.FromRequest(r)
+		if err != nil {
+			c.h.RenderJSON(w, http.StatusBadRequest, api.Error(err).WithCode(api.ErrUnparsableRequest))
+			return
+		}
+
+		issuance, paginator, err := currentRealm.ExternalIssuerIssuanceBetween(c.db,
+			start, end, project.TrimSpace(r.FormValue(QueryKeyIssuer)), pageParams)
+		if err != nil {
+			controller.InternalError(w, r, c.h, err)
+			return
+		}
+
+		resp := &api.ExternalIssuerIssuanceResponse{
+			StartDate: start.Format(project.RFC3339Date),
+			EndDate:   end.Format(project.RFC3339Date),
+			Issuers:   make([]*api.ExternalIssuerIssuance, 0, len(issuance)),
+		}
+		for _, v := range issuance {
+			issuer := &api.ExternalIssuerIssuance{
+				IssuerID:    v.IssuerID,
+				CodesIssued: v.CodesIssued,
+				Days:        make([]*api.ExternalIssuerIssuanceDay, 0, len(v.Days)),
+			}
+			for _, day := range v.Days {
+				issuer.Days = append(issuer.Days, &api.ExternalIssuerIssuanceDay{
+					Date:        day.Date.Format(project.RFC3339Date),
+					CodesIssued: day.CodesIssued,
+				})
+			}
+			resp.Issuers = append(resp.Issuers, issuer)
+		}
+		if paginator != nil && paginator.NextPage != nil {
+			resp.NextPage = paginator.NextPage.Number
+		}
+
+		c.h.RenderJSON(w, http.StatusOK, resp)
+	})
+}
@@ -19,6 +19,7 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"sort"
 	"sync"
 	"time"
 
@@ -28,6 +29,7 @@ import (
 	"github.com/google/exposure-notifications-verification-server/internal/clients"
 	"github.com/google/exposure-notifications-verification-server/pkg/controller"
 	"github.com/google/exposure-notifications-verification-server/pkg/controller/certapi"
+	"github.com/google/exposure-notifications-verification-server/pkg/controller/jobstatus"
 	"github.com/google/exposure-notifications-verification-server/pkg/database"
 	"github.com/google/exposure-notifications-verification-server/pkg/jwthelper"
 	"github.com/hashicorp/go-multierror"
@@ -57,6 +59,7 @@ func (c *Controller) HandlePullStats() http.Handler {
 		}
 		if !ok {
 			logger.Debugw("skipping (too early)")
+			jobstatus.RecordFreshness(ctx, c.db, jobstatus.JobStatsPuller)
 			c.h.RenderJSON(w, http.StatusOK, fmt.Errorf("too early"))
 			return
 		}
@@ -64,6 +67,7 @@ func (c *Controller) HandlePullStats() http.Handler {
 		// Get all of the realms with stats configured
 		statsConfigs, err := c.db.ListKeyServerStats()
 		if err != nil {
+			jobstatus.Record(ctx, c.db, jobstatus.JobStatsPuller, 0, err)
 			controller.InternalError(w, r, c.h, err)
 			return
 		}
@@ -93,10 +97,12 @@ func (c *Controller) HandlePullStats() http.Handler {
 
 		if errs := merr.WrappedErrors(); len(errs) > 0 {
 			logger.Errorw("failed to pull stats", "errors", errs)
+			jobstatus.Record(ctx, c.db, jobstatus.JobStatsPuller, 0, merr)
 			c.h.RenderJSON(w, http.StatusInternalServerError, errs)
 			return
 		}
 
+		jobstatus.Record(ctx, c.db, jobstatus.JobStatsPuller, int64(len(statsConfigs)), nil)
 		stats.Record(ctx, mSuccess.M(1))
 		c.h.RenderJSON(w, http.StatusOK, nil)
 	})
@@ -105,11 +111,22 @@ func (c *Controller) HandlePullStats() http.Handler {
 func (c *Controller) pullOneStat(ctx context.Context, realmStat *database.KeyServerStats) error {
 	realmID := realmStat.RealmID
 
+	// The realm's associated key server takes precedence over the system
+	// default, and explicit per-realm overrides take precedence over both.
+	keyServer, err := c.db.FindKeyServerForRealm(realmID)
+	if err != nil {
+		return fmt.Errorf("failed to find key server for realm %d: %w", realmID, err)
+	}
+
+	keyServerURL := realmStat.KeyServerURLOverride
+	if keyServerURL == "" && keyServer != nil {
+		keyServerURL = keyServer.URL
+	}
+
 	client := c.defaultKeyServerClient
-	if realmStat.KeyServerURLOverride != "" {
-		var err error
+	if keyServerURL != "" {
 		client, err = clients.NewKeyServerClient(
-			realmStat.KeyServerURLOverride,
+			keyServerURL,
 			clients.WithTimeout(c.config.DownloadTimeout),
 			clients.WithMaxBodySize(c.config.FileSizeLimitBytes))
 		if err != nil {
@@ -117,16 +134,45 @@ func (c *Controller) pullOneStat(ctx context.Context, realmStat *database.KeySer
 		}
 	}
 
+	// For realms using the system signing key, the signer uses the associated
+	// key server's credentials when it has them.
 	s, err := certapi.GetSignerForRealm(ctx, realmID, c.config.CertificateSigning, c.signerCache, c.db, c.kms)
 	if err != nil {
 		return fmt.Errorf("failed to retrieve signer for realm %d: %w", realmID, err)
 	}
 
 	audience := c.config.KeyServerStatsAudience
+	if keyServer != nil {
+		audience = keyServer.StatsAudience
+	}
 	if realmStat.KeyServerAudienceOverride != "" {
 		audience = realmStat.KeyServerAudienceOverride
 	}
 
+	if err := c.pullFromSource(ctx, realmID, database.KeyServerStatsSourcePrimary, client, s, audience); err != nil {
+		return err
+	}
+
+	// Pull from any additional key servers configured for the deployment. Stats
+	// are stored per source and merged when read.
+	labels := make([]string, 0, len(c.sourceClients))
+	for label := range c.sourceClients {
+		labels = append(labels, label)
+	}
+	sort.Strings(labels)
+
+	var merr *multierror.Error
+	for _, label := range labels {
+		if err := c.pullFromSource(ctx, realmID, label, c.sourceClients[label], s, c.config.KeyServerStatsAudience); err != nil {
+			merr = multierror.Append(merr, fmt.Errorf("source %q: %w", label, err))
+		}
+	}
+	return merr.ErrorOrNil()
+}
+
+// pullFromSource pulls the stats for the realm from a single key server and
+// saves them with the given source label.
+func (c *Controller) pullFromSource(ctx context.Context, realmID uint, source string, client *clients.KeyServerClient, s *certapi.SignerInfo, audience string) error {
 	now := time.Now().UTC()
 	claims := &jwt.StandardClaims{
 		Audience:  audience,
@@ -134,7 +180,10 @@ func (c *Controller) pullOneStat(ctx context.Context, realmStat *database.KeySer
 		IssuedAt:  now.Unix(),
 		Issuer:    s.Issuer,
 	}
-	token := jwt.NewWithClaims(jwt.SigningMethodES256, claims)
+	token, err := jwthelper.NewWithClaims(s.Signer, claims)
+	if err != nil {
+		return fmt.Errorf("failed to create stat-pull token: %w", err)
+	}
 	token.Header["kid"] = s.KeyID
 
 	signedJWT, err := jwthelper.SignJWT(token, s.Signer)
@@ -164,7 +213,8 @@ func (c *Controller) pullOneStat(ctx context.Context, realmStat *database.KeySer
 			continue
 		}
 		day := database.MakeKeyServerStatsDay(realmID, d)
-		if err = c.db.SaveKeyServerStatsDay(day); err != nil {
+		day.Source = source
+		if err := c.db.SaveKeyServerStatsDay(day); err != nil {
 			return fmt.Errorf("failed to save stats day: %w", err)
 		}
 	}
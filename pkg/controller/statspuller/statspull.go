@@ -35,6 +35,10 @@ type Controller struct {
 	h                      *render.Renderer
 	kms                    keys.KeyManager
 	signerCache            *cache.Cache[*certapi.SignerInfo]
+
+	// sourceClients are the clients for the additional key server sources,
+	// keyed by source label.
+	sourceClients map[string]*clients.KeyServerClient
 }
 
 // New creates a new stats-pull controller.
@@ -45,12 +49,24 @@ func New(cfg *config.StatsPullerConfig, db *database.Database, client *clients.K
 		return nil, fmt.Errorf("cannot create signer cache, likely invalid duration: %w", err)
 	}
 
+	sourceClients := make(map[string]*clients.KeyServerClient, len(cfg.KeyServerSources))
+	for label, u := range cfg.KeyServerSources {
+		client, err := clients.NewKeyServerClient(u,
+			clients.WithTimeout(cfg.DownloadTimeout),
+			clients.WithMaxBodySize(cfg.FileSizeLimitBytes))
+		if err != nil {
+			return nil, fmt.Errorf("failed to create key server client for source %q: %w", label, err)
+		}
+		sourceClients[label] = client
+	}
+
 	return &Controller{
 		config:                 cfg,
 		db:                     db,
 		defaultKeyServerClient: client,
 		kms:                    kms,
 		signerCache:            signerCache,
+		sourceClients:          sourceClients,
 		h:                      h,
 	}, nil
 }
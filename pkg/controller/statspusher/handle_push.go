@@ -0,0 +1,114 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package statspusher
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/google/exposure-notifications-server/pkg/logging"
+	"github.com/google/exposure-notifications-server/pkg/timeutils"
+	"github.com/google/exposure-notifications-verification-server/pkg/controller"
+	"github.com/google/exposure-notifications-verification-server/pkg/controller/jobstatus"
+	"github.com/google/exposure-notifications-verification-server/pkg/pagination"
+	"go.opencensus.io/stats"
+)
+
+const statsPusherLock = "statsPusherLock"
+
+// HandlePush pushes the most recent days of statistics for all realms to the
+// aggregation service. It does nothing if pushing is not enabled.
+func (c *Controller) HandlePush() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		logger := logging.FromContext(ctx).Named("statspusher.HandlePush")
+		logger.Debugw("starting")
+		defer logger.Debugw("finishing")
+
+		if !c.config.Enabled() {
+			logger.Debugw("skipping (not enabled)")
+			c.h.RenderJSON(w, http.StatusOK, fmt.Errorf("not enabled"))
+			return
+		}
+
+		ok, err := c.db.TryLock(ctx, statsPusherLock, c.config.MinPeriod)
+		if err != nil {
+			logger.Errorw("failed to acquire lock", "error", err)
+			c.h.RenderJSON(w, http.StatusInternalServerError, err)
+			return
+		}
+		if !ok {
+			logger.Debugw("skipping (too early)")
+			jobstatus.RecordFreshness(ctx, c.db, jobstatus.JobStatsPusher)
+			c.h.RenderJSON(w, http.StatusOK, fmt.Errorf("too early"))
+			return
+		}
+
+		payload, err := c.buildPayload(time.Now().UTC())
+		if err != nil {
+			jobstatus.Record(ctx, c.db, jobstatus.JobStatsPusher, 0, err)
+			controller.InternalError(w, r, c.h, err)
+			return
+		}
+
+		body, err := json.Marshal(payload)
+		if err != nil {
+			jobstatus.Record(ctx, c.db, jobstatus.JobStatsPusher, 0, err)
+			controller.InternalError(w, r, c.h, fmt.Errorf("failed to marshal payload: %w", err))
+			return
+		}
+
+		if err := c.send(ctx, body); err != nil {
+			logger.Errorw("failed to push stats", "error", err)
+			stats.Record(ctx, mFailed.M(1))
+			jobstatus.Record(ctx, c.db, jobstatus.JobStatsPusher, 0, err)
+			c.h.RenderJSON(w, http.StatusInternalServerError, err)
+			return
+		}
+
+		jobstatus.Record(ctx, c.db, jobstatus.JobStatsPusher, int64(len(payload.Realms)), nil)
+		stats.Record(ctx, mSuccess.M(1))
+		c.h.RenderJSON(w, http.StatusOK, nil)
+	})
+}
+
+// buildPayload builds the payload for all realms, covering the configured
+// number of days up to and including today.
+func (c *Controller) buildPayload(now time.Time) (*Payload, error) {
+	stop := timeutils.UTCMidnight(now)
+	start := stop.Add(time.Duration(c.config.Days-1) * -24 * time.Hour)
+
+	realms, _, err := c.db.ListRealms(pagination.UnlimitedResults)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list realms: %w", err)
+	}
+
+	payload := &Payload{
+		Source:      c.config.Source,
+		GeneratedAt: now,
+		Realms:      make([]*RealmPayload, 0, len(realms)),
+	}
+	for _, realm := range realms {
+		realmStats, err := realm.StatsBetween(c.db, start, stop)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get stats for realm %d: %w", realm.ID, err)
+		}
+		payload.Realms = append(payload.Realms, buildRealmPayload(realm, realmStats))
+	}
+	return payload, nil
+}
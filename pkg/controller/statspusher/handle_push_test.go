@@ -0,0 +1,187 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package statspusher
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/exposure-notifications-server/pkg/timeutils"
+	"github.com/google/exposure-notifications-verification-server/internal/envstest"
+	"github.com/google/exposure-notifications-verification-server/internal/project"
+	"github.com/google/exposure-notifications-verification-server/pkg/config"
+	"github.com/google/exposure-notifications-verification-server/pkg/database"
+	"github.com/google/exposure-notifications-verification-server/pkg/render"
+)
+
+func TestHandlePush(t *testing.T) {
+	t.Parallel()
+
+	ctx := project.TestContext(t)
+
+	h, err := render.New(ctx, nil, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// setup creates a controller that pushes to a server which responds with
+	// the given status. Received payloads are sent on the returned channel.
+	setup := func(tb testing.TB, status int) (*Controller, *database.Database, *int32, chan *Payload) {
+		tb.Helper()
+
+		db, _ := testDatabaseInstance.NewDatabase(tb, nil)
+
+		var calls int32
+		payloads := make(chan *Payload, 10)
+		srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(&calls, 1)
+
+			body, err := io.ReadAll(r.Body)
+			if err != nil {
+				tb.Error(err)
+			}
+			timestamp := r.Header.Get(HeaderTimestamp)
+			if got, want := r.Header.Get(HeaderSignature), signPayload("super-secret-value", timestamp, body); got != want {
+				tb.Errorf("expected signature %q to be %q", got, want)
+			}
+			if got, want := r.Header.Get(HeaderSource), "test-source"; got != want {
+				tb.Errorf("expected source %q to be %q", got, want)
+			}
+
+			var payload Payload
+			if err := json.Unmarshal(body, &payload); err != nil {
+				tb.Error(err)
+			}
+			payloads <- &payload
+
+			w.WriteHeader(status)
+		}))
+		tb.Cleanup(func() {
+			srv.Close()
+		})
+
+		c := New(&config.StatsPushConfig{
+			URL:          srv.URL,
+			Secret:       "super-secret-value",
+			Source:       "test-source",
+			Days:         3,
+			Timeout:      2 * time.Second,
+			MaxRetries:   2,
+			RetryBackoff: 10 * time.Millisecond,
+			MinPeriod:    time.Second,
+		}, db, h)
+		c.httpClient = srv.Client()
+		return c, db, &calls, payloads
+	}
+
+	invoke := func(tb testing.TB, c *Controller, code int) {
+		tb.Helper()
+
+		w, r := envstest.BuildJSONRequest(ctx, tb, http.MethodGet, "/", nil)
+		c.HandlePush().ServeHTTP(w, r)
+		if got, want := w.Code, code; got != want {
+			tb.Fatalf("expected %d to be %d: %s", got, want, w.Body.String())
+		}
+	}
+
+	t.Run("not_enabled", func(t *testing.T) {
+		t.Parallel()
+
+		db, _ := testDatabaseInstance.NewDatabase(t, nil)
+		c := New(&config.StatsPushConfig{}, db, h)
+		invoke(t, c, http.StatusOK)
+	})
+
+	t.Run("pushes", func(t *testing.T) {
+		t.Parallel()
+
+		c, db, calls, payloads := setup(t, http.StatusOK)
+
+		realm, err := db.FindRealm(1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		today := timeutils.UTCMidnight(time.Now())
+		for _, date := range []time.Time{today, today.Add(-10 * 24 * time.Hour)} {
+			if err := db.RawDB().Create(&database.RealmStat{
+				Date:        date,
+				RealmID:     realm.ID,
+				CodesIssued: 7,
+			}).Error; err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		invoke(t, c, http.StatusOK)
+
+		if got, want := atomic.LoadInt32(calls), int32(1); got != want {
+			t.Fatalf("expected %d calls, got %d", want, got)
+		}
+
+		payload := <-payloads
+		if got, want := payload.Source, "test-source"; got != want {
+			t.Errorf("expected %q to be %q", got, want)
+		}
+		if got, want := len(payload.Realms), 1; got != want {
+			t.Fatalf("expected %d realms, got %d", want, got)
+		}
+
+		days := payload.Realms[0].Days
+		if got, want := len(days), 3; got != want {
+			t.Fatalf("expected %d days, got %d", want, got)
+		}
+
+		// Days are oldest first, and stats older than the configured days are
+		// not sent.
+		last := days[len(days)-1]
+		if got, want := last.Date, today.Format(project.RFC3339Date); got != want {
+			t.Errorf("expected %q to be %q", got, want)
+		}
+		var issued uint
+		for _, day := range days {
+			issued += day.CodesIssued
+		}
+		if got, want := issued, uint(7); got != want {
+			t.Errorf("expected %d to be %d", got, want)
+		}
+	})
+
+	t.Run("retries", func(t *testing.T) {
+		t.Parallel()
+
+		c, _, calls, _ := setup(t, http.StatusServiceUnavailable)
+		invoke(t, c, http.StatusInternalServerError)
+
+		if got, want := atomic.LoadInt32(calls), int32(3); got != want {
+			t.Errorf("expected %d calls, got %d", want, got)
+		}
+	})
+
+	t.Run("does_not_retry_client_errors", func(t *testing.T) {
+		t.Parallel()
+
+		c, _, calls, _ := setup(t, http.StatusBadRequest)
+		invoke(t, c, http.StatusInternalServerError)
+
+		if got, want := atomic.LoadInt32(calls), int32(1); got != want {
+			t.Errorf("expected %d calls, got %d", want, got)
+		}
+	})
+}
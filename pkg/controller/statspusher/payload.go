@@ -0,0 +1,82 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package statspusher
+
+import (
+	"time"
+
+	"github.com/google/exposure-notifications-verification-server/internal/project"
+	"github.com/google/exposure-notifications-verification-server/pkg/database"
+)
+
+// Payload is the body of a push to the aggregation service. The same days are
+// sent on multiple pushes, so receivers should replace any existing values for
+// a (source, realm, date).
+type Payload struct {
+	Source      string          `json:"source"`
+	GeneratedAt time.Time       `json:"generatedAt"`
+	Realms      []*RealmPayload `json:"realms"`
+}
+
+// RealmPayload is the statistics for a single realm.
+type RealmPayload struct {
+	RealmID    uint          `json:"realmID"`
+	RealmName  string        `json:"realmName"`
+	RegionCode string        `json:"regionCode"`
+	Days       []*DayPayload `json:"days"`
+}
+
+// DayPayload is a realm's aggregate statistics for a single UTC day.
+type DayPayload struct {
+	Date                    string `json:"date"`
+	CodesIssued             uint   `json:"codesIssued"`
+	CodesClaimed            uint   `json:"codesClaimed"`
+	CodesInvalid            uint   `json:"codesInvalid"`
+	TokensClaimed           uint   `json:"tokensClaimed"`
+	TokensInvalid           uint   `json:"tokensInvalid"`
+	UserReportsIssued       uint   `json:"userReportsIssued"`
+	UserReportsClaimed      uint   `json:"userReportsClaimed"`
+	UserReportTokensClaimed uint   `json:"userReportTokensClaimed"`
+}
+
+// buildRealmPayload converts the realm's stats, oldest day first. The realm's
+// small-count privacy settings are applied because the aggregation service is
+// outside this system.
+func buildRealmPayload(realm *database.Realm, stats database.RealmStats) *RealmPayload {
+	stats = stats.WithPrivacy(realm)
+
+	days := make([]*DayPayload, 0, len(stats))
+	for i := len(stats) - 1; i >= 0; i-- {
+		s := stats[i]
+		days = append(days, &DayPayload{
+			Date:                    s.Date.Format(project.RFC3339Date),
+			CodesIssued:             s.CodesIssued,
+			CodesClaimed:            s.CodesClaimed,
+			CodesInvalid:            s.CodesInvalid,
+			TokensClaimed:           s.TokensClaimed,
+			TokensInvalid:           s.TokensInvalid,
+			UserReportsIssued:       s.UserReportsIssued,
+			UserReportsClaimed:      s.UserReportsClaimed,
+			UserReportTokensClaimed: s.UserReportTokensClaimed,
+		})
+	}
+
+	return &RealmPayload{
+		RealmID:    realm.ID,
+		RealmName:  realm.Name,
+		RegionCode: realm.RegionCode,
+		Days:       days,
+	}
+}
@@ -0,0 +1,125 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package statspusher
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha512"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/sethvargo/go-retry"
+)
+
+const (
+	// HeaderSignature is the hex-encoded SHA-512 HMAC of the timestamp and body.
+	HeaderSignature = "X-Signature"
+
+	// HeaderTimestamp is the unix timestamp at which the request was signed.
+	HeaderTimestamp = "X-Signature-Timestamp"
+
+	// HeaderSource is the configured source of the statistics.
+	HeaderSource = "X-Stats-Source"
+
+	// maxErrorBody is the maximum number of response body bytes included in
+	// errors.
+	maxErrorBody = 1024
+)
+
+// statusError is returned when the aggregation service responds with a
+// non-2xx status.
+type statusError struct {
+	code int
+	body []byte
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("unsuccessful response from aggregation service (%d): %s", e.code, e.body)
+}
+
+// retryable returns true if the push should be attempted again after err.
+// Client errors other than timeouts and rate limits are not retried, since
+// they will not succeed without a configuration change.
+func retryable(err error) bool {
+	serr, ok := err.(*statusError)
+	if !ok {
+		return true
+	}
+	switch serr.code {
+	case http.StatusRequestTimeout, http.StatusTooManyRequests:
+		return true
+	}
+	return serr.code >= 500
+}
+
+// send posts the body to the aggregation service, retrying with exponential
+// backoff. Each attempt is signed with a fresh timestamp.
+func (c *Controller) send(ctx context.Context, body []byte) error {
+	b := retry.NewExponential(c.config.RetryBackoff)
+	b = retry.WithMaxRetries(c.config.MaxRetries, b)
+
+	return retry.Do(ctx, b, func(ctx context.Context) error {
+		if err := c.sendOnce(ctx, body, time.Now()); err != nil {
+			if retryable(err) {
+				return retry.RetryableError(err)
+			}
+			return err
+		}
+		return nil
+	})
+}
+
+// sendOnce makes a single signed request to the aggregation service.
+func (c *Controller) sendOnce(ctx context.Context, body []byte, now time.Time) error {
+	timestamp := strconv.FormatInt(now.Unix(), 10)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.config.URL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to build push request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderSource, c.config.Source)
+	req.Header.Set(HeaderTimestamp, timestamp)
+	req.Header.Set(HeaderSignature, signPayload(c.config.Secret, timestamp, body))
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to issue push request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if code := resp.StatusCode; code < 200 || code > 299 {
+		b, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+		return &statusError{code: code, body: b}
+	}
+	return nil
+}
+
+// signPayload returns the hex-encoded SHA-512 HMAC of "<timestamp>.<body>"
+// using the given secret. Including the timestamp lets receivers reject
+// replayed requests.
+func signPayload(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha512.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
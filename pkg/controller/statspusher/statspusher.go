@@ -0,0 +1,51 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package statspusher pushes daily aggregate statistics to a national or
+// central aggregation service.
+package statspusher
+
+import (
+	"net/http"
+
+	"github.com/google/exposure-notifications-verification-server/internal/project"
+	"github.com/google/exposure-notifications-verification-server/pkg/config"
+	"github.com/google/exposure-notifications-verification-server/pkg/database"
+	"github.com/google/exposure-notifications-verification-server/pkg/render"
+)
+
+// Controller is a controller for the stats pusher.
+type Controller struct {
+	config     *config.StatsPushConfig
+	db         *database.Database
+	httpClient *http.Client
+	h          *render.Renderer
+}
+
+// New creates a new stats push controller.
+func New(cfg *config.StatsPushConfig, db *database.Database, h *render.Renderer) *Controller {
+	// The aggregation service is operated by a third party, so do not propagate
+	// trace headers.
+	httpClient := &http.Client{
+		Timeout:   cfg.Timeout,
+		Transport: project.DefaultHTTPTransport(),
+	}
+
+	return &Controller{
+		config:     cfg,
+		db:         db,
+		httpClient: httpClient,
+		h:          h,
+	}
+}
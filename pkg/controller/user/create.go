@@ -98,7 +98,7 @@ func (c *Controller) HandleCreate() http.Handler {
 		}
 
 		// Ensure the user exists in the upstream auth provider.
-		inviteComposer, err := controller.SendInviteEmailFunc(ctx, c.db, c.h, user.Email, currentRealm)
+		inviteComposer, err := controller.SendInviteEmailFunc(ctx, c.db, c.h, user.Email, user.PreferredLanguage, currentRealm)
 		if err != nil {
 			controller.InternalError(w, r, c.h, err)
 			return
@@ -118,15 +118,20 @@ func (c *Controller) HandleCreate() http.Handler {
 
 func bindCreateForm(r *http.Request, currentMembership *database.Membership, user *database.User, membership *database.Membership) error {
 	type FormData struct {
-		Email       string            `form:"email"`
-		Name        string            `form:"name"`
-		Permissions []rbac.Permission `form:"permissions"`
+		Email             string            `form:"email"`
+		Name              string            `form:"name"`
+		PreferredLanguage string            `form:"preferred_language"`
+		Permissions       []rbac.Permission `form:"permissions"`
+
+		UnclaimedCodesReport bool `form:"unclaimed_codes_report"`
 	}
 
 	var form FormData
 	formErr := controller.BindForm(nil, r, &form)
 	user.Email = form.Email
 	user.Name = form.Name
+	user.PreferredLanguage = form.PreferredLanguage
+	user.UnclaimedCodesReport = form.UnclaimedCodesReport
 
 	permissions, rbacErr := rbac.CompileAndAuthorize(currentMembership.Permissions, form.Permissions)
 	membership.Permissions = permissions
@@ -59,6 +59,14 @@ func (c *Controller) HandleExport() http.Handler {
 			return
 		}
 
+		if len(memberships) > 0 {
+			if err := c.db.RecordDataAccess(membership.User, "exported users", currentRealm, currentRealm.ID,
+				uint(len(memberships)), database.DataAccessFieldEmail); err != nil {
+				controller.InternalError(w, r, c.h, err)
+				return
+			}
+		}
+
 		filename := fmt.Sprintf("%s-users.csv", time.Now().Format(project.RFC3339Squish))
 		c.h.RenderCSV(w, http.StatusOK, filename, membershipsCSV(memberships))
 	})
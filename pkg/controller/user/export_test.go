@@ -101,5 +101,19 @@ System admin,super@example.com,%s
 		if got, want := w.Body.String(), exp; !strings.Contains(got, want) {
 			t.Errorf("Expected %q to contain %q", got, want)
 		}
+
+		logs, _, err := realm.ListDataAccessLogs(harness.Database, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := len(logs), 1; got != want {
+			t.Fatalf("Expected %d data access logs, got %d", want, got)
+		}
+		if got, want := logs[0].ActorID, admin.AuditID(); got != want {
+			t.Errorf("Expected %q to be %q", got, want)
+		}
+		if got, want := logs[0].Action, "exported users"; got != want {
+			t.Errorf("Expected %q to be %q", got, want)
+		}
 	})
 }
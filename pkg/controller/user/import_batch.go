@@ -16,6 +16,8 @@ package user
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 
@@ -43,10 +45,18 @@ func (c *Controller) HandleImportBatch() http.Handler {
 			return
 		}
 
+		// Decode the users one at a time so an oversized import is rejected
+		// without buffering the entire request.
 		var request api.UserBatchRequest
-		if err := controller.BindJSON(w, r, &request); err != nil {
+		if err := controller.BindJSONStream(w, r, func(d *json.Decoder) error {
+			return decodeUserBatchRequest(d, &request)
+		}); err != nil {
 			logger.Errorw("error decoding request", "error", err)
-			c.h.RenderJSON(w, http.StatusBadRequest, api.Error(err))
+			if errors.Is(err, controller.ErrBodyTooLarge) {
+				controller.RequestTooLarge(w, r, c.h, err)
+				return
+			}
+			c.h.RenderJSON(w, http.StatusBadRequest, api.Error(err).WithCode(api.ErrUnparsableRequest))
 			return
 		}
 
@@ -76,6 +86,25 @@ func (c *Controller) HandleImportBatch() http.Handler {
 	})
 }
 
+// decodeUserBatchRequest decodes a UserBatchRequest from d, one user at a time.
+func decodeUserBatchRequest(d *json.Decoder, request *api.UserBatchRequest) error {
+	return controller.DecodeJSONObject(d, controller.JSONFieldDecoders{
+		"users": func(d *json.Decoder) error {
+			return controller.DecodeJSONArray(d, func(d *json.Decoder) error {
+				var user api.BatchUser
+				if err := d.Decode(&user); err != nil {
+					return err
+				}
+				request.Users = append(request.Users, user)
+				return nil
+			})
+		},
+		"sendInvites": func(d *json.Decoder) error {
+			return d.Decode(&request.SendInvites)
+		},
+	})
+}
+
 func (c *Controller) importUsers(ctx context.Context,
 	realm *database.Realm, realmMemberships map[uint]rbac.Permission, actor database.Auditable,
 	users []api.BatchUser, sendInvites bool,
@@ -121,7 +150,7 @@ func (c *Controller) importUsers(ctx context.Context,
 		}
 
 		// Create the invitation email composer.
-		inviteComposer, err := controller.SendInviteEmailFunc(ctx, c.db, c.h, user.Email, realm)
+		inviteComposer, err := controller.SendInviteEmailFunc(ctx, c.db, c.h, user.Email, user.PreferredLanguage, realm)
 		if err != nil {
 			batchErr = multierror.Append(batchErr, err)
 			continue
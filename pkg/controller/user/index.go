@@ -75,6 +75,14 @@ func (c *Controller) HandleIndex() http.Handler {
 			return
 		}
 
+		if len(memberships) > 0 {
+			if err := c.db.RecordDataAccess(membership.User, "listed users", currentRealm, currentRealm.ID,
+				uint(len(memberships)), database.DataAccessFieldEmail); err != nil {
+				controller.InternalError(w, r, c.h, err)
+				return
+			}
+		}
+
 		c.renderIndex(ctx, w, memberships, paginator, q)
 	})
 }
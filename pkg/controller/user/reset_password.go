@@ -68,7 +68,7 @@ func (c *Controller) HandleResetPassword() http.Handler {
 		}
 
 		// Build the emailer.
-		resetComposer, err := controller.SendPasswordResetEmailFunc(ctx, c.db, c.h, user.Email, currentRealm)
+		resetComposer, err := controller.SendPasswordResetEmailFunc(ctx, c.db, c.h, user.Email, user.PreferredLanguage, currentRealm)
 		if err != nil {
 			controller.InternalError(w, r, c.h, err)
 			return
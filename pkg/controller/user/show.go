@@ -60,15 +60,32 @@ func (c *Controller) HandleShow() http.Handler {
 			return
 		}
 
-		c.renderShow(ctx, w, user, userMembership)
+		if err := c.db.RecordDataAccess(currentUser, "viewed user", user, currentRealm.ID, 1,
+			database.DataAccessFieldEmail); err != nil {
+			controller.InternalError(w, r, c.h, err)
+			return
+		}
+
+		// Only look up today's issuance when there's a limit to compare it to.
+		var codesIssuedToday uint
+		if currentRealm.DailyUserIssueLimit > 0 {
+			codesIssuedToday, err = user.CodesIssuedToday(c.db, currentRealm)
+			if err != nil {
+				controller.InternalError(w, r, c.h, err)
+				return
+			}
+		}
+
+		c.renderShow(ctx, w, user, userMembership, codesIssuedToday)
 	})
 }
 
-func (c *Controller) renderShow(ctx context.Context, w http.ResponseWriter, user *database.User, membership *database.Membership) {
+func (c *Controller) renderShow(ctx context.Context, w http.ResponseWriter, user *database.User, membership *database.Membership, codesIssuedToday uint) {
 	m := controller.TemplateMapFromContext(ctx)
 	m.Title("User: %s", user.Name)
 	m["user"] = user
 	m["userMembership"] = membership
+	m["codesIssuedToday"] = codesIssuedToday
 	m["permissions"] = rbac.NamePermissionMap
 	c.h.RenderHTML(w, "users/show", m)
 }
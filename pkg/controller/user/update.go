@@ -107,13 +107,18 @@ func (c *Controller) HandleUpdate() http.Handler {
 
 func bindUpdateForm(r *http.Request, currentMembership *database.Membership, user *database.User, membership *database.Membership) error {
 	type FormData struct {
-		Name        string            `form:"name"`
-		Permissions []rbac.Permission `form:"permissions"`
+		Name              string            `form:"name"`
+		PreferredLanguage string            `form:"preferred_language"`
+		Permissions       []rbac.Permission `form:"permissions"`
+
+		UnclaimedCodesReport bool `form:"unclaimed_codes_report"`
 	}
 
 	var form FormData
 	formErr := controller.BindForm(nil, r, &form)
 	user.Name = form.Name
+	user.PreferredLanguage = form.PreferredLanguage
+	user.UnclaimedCodesReport = form.UnclaimedCodesReport
 
 	permissions, rbacErr := rbac.CompileAndAuthorize(currentMembership.Permissions, form.Permissions)
 	membership.Permissions = permissions
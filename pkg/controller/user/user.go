@@ -43,7 +43,7 @@ func (c *Controller) findUser(currentUser *database.User, realm *database.Realm,
 	var err error
 
 	// Look up the user.
-	if currentUser.SystemAdmin {
+	if currentUser.IsSystemAdmin() {
 		user, err = c.db.FindUser(id)
 	} else {
 		user, err = realm.FindUser(c.db, id)
@@ -0,0 +1,139 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package userimport
+
+import (
+	"net/http"
+
+	"github.com/google/exposure-notifications-verification-server/pkg/api"
+	"github.com/google/exposure-notifications-verification-server/pkg/controller"
+	"github.com/google/exposure-notifications-verification-server/pkg/database"
+)
+
+// HandleCreate stores a user import for the calling API key's realm. The
+// import is validated before it is stored, but users are not created until the
+// worker processes it.
+func (c *Controller) HandleCreate() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		authorizedApp := controller.AuthorizedAppFromContext(ctx)
+		if authorizedApp == nil {
+			controller.Unauthorized(w, r, c.h)
+			return
+		}
+
+		var request api.UserImportRequest
+		if err := controller.BindJSON(w, r, &request); err != nil {
+			c.h.RenderJSON(w, http.StatusBadRequest, api.Error(err).WithCode(api.ErrUnparsableRequest))
+			return
+		}
+
+		userImport := &database.UserImport{
+			RealmID:         authorizedApp.RealmID,
+			AuthorizedAppID: authorizedApp.ID,
+			Entries:         make([]*database.UserImportEntry, 0, len(request.Users)),
+		}
+		for _, u := range request.Users {
+			if u == nil {
+				continue
+			}
+			userImport.Entries = append(userImport.Entries, &database.UserImportEntry{
+				Email:       u.Email,
+				Name:        u.Name,
+				Permissions: u.Permissions,
+			})
+		}
+
+		if err := c.db.CreateUserImport(userImport, authorizedApp); err != nil {
+			if verr := userImport.ErrorOrNil(); verr != nil {
+				c.h.RenderJSON(w, http.StatusBadRequest, api.Error(verr).WithCode(api.ErrInvalidRequest))
+				return
+			}
+
+			controller.InternalError(w, r, c.h, err)
+			return
+		}
+
+		c.h.RenderJSON(w, http.StatusAccepted, buildResponse(userImport))
+	})
+}
+
+// HandleStatus returns the status of a user import in the calling API key's
+// realm.
+func (c *Controller) HandleStatus() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		authorizedApp := controller.AuthorizedAppFromContext(ctx)
+		if authorizedApp == nil {
+			controller.Unauthorized(w, r, c.h)
+			return
+		}
+		realm, err := authorizedApp.Realm(c.db)
+		if err != nil {
+			controller.InternalError(w, r, c.h, err)
+			return
+		}
+
+		var request api.UserImportStatusRequest
+		if err := controller.BindJSON(w, r, &request); err != nil {
+			c.h.RenderJSON(w, http.StatusBadRequest, api.Error(err).WithCode(api.ErrUnparsableRequest))
+			return
+		}
+
+		if request.ID == 0 {
+			c.h.RenderJSON(w, http.StatusBadRequest, api.Errorf("missing id").WithCode(api.ErrUnparsableRequest))
+			return
+		}
+
+		userImport, err := realm.FindUserImport(c.db, request.ID)
+		if err != nil {
+			if database.IsNotFound(err) {
+				c.h.RenderJSON(w, http.StatusNotFound, api.Errorf("user import not found").WithCode(api.ErrNotFound))
+				return
+			}
+
+			controller.InternalError(w, r, c.h, err)
+			return
+		}
+
+		c.h.RenderJSON(w, http.StatusOK, buildResponse(userImport))
+	})
+}
+
+// buildResponse converts the import to its API representation.
+func buildResponse(i *database.UserImport) *api.UserImportResponse {
+	resp := &api.UserImportResponse{
+		ID:                 i.ID,
+		Status:             i.Status,
+		Total:              len(i.Entries),
+		Created:            int(i.Created),
+		Updated:            int(i.Updated),
+		Failed:             int(i.Failed),
+		CreatedAtTimestamp: i.CreatedAt.UTC().Unix(),
+		Error:              i.Error,
+	}
+	if i.CompletedAt != nil {
+		resp.CompletedAtTimestamp = i.CompletedAt.UTC().Unix()
+	}
+	for _, f := range i.Failures {
+		resp.Failures = append(resp.Failures, &api.UserImportFailure{
+			Email: f.Email,
+			Error: f.Error,
+		})
+	}
+	return resp
+}
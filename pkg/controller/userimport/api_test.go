@@ -0,0 +1,63 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package userimport
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/exposure-notifications-verification-server/pkg/api"
+	"github.com/google/exposure-notifications-verification-server/pkg/database"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestBuildResponse(t *testing.T) {
+	t.Parallel()
+
+	createdAt := time.Unix(1667347200, 0).UTC()
+	completedAt := createdAt.Add(time.Minute)
+
+	got := buildResponse(&database.UserImport{
+		ID:     12,
+		Status: database.UserImportStatusCompleted,
+		Entries: []*database.UserImportEntry{
+			{Email: "a@example.com", Name: "A"},
+			{Email: "b@example.com", Name: "B"},
+		},
+		Created: 1,
+		Failed:  1,
+		Failures: []*database.UserImportFailure{
+			{Email: "b@example.com", Error: "oops"},
+		},
+		CreatedAt:   createdAt,
+		CompletedAt: &completedAt,
+	})
+
+	want := &api.UserImportResponse{
+		ID:      12,
+		Status:  database.UserImportStatusCompleted,
+		Total:   2,
+		Created: 1,
+		Failed:  1,
+		Failures: []*api.UserImportFailure{
+			{Email: "b@example.com", Error: "oops"},
+		},
+		CreatedAtTimestamp:   1667347200,
+		CompletedAtTimestamp: 1667347260,
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("mismatch (-want, +got):\n%s", diff)
+	}
+}
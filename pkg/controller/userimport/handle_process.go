@@ -0,0 +1,155 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package userimport
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/google/exposure-notifications-server/pkg/logging"
+	"github.com/google/exposure-notifications-verification-server/pkg/api"
+	"github.com/google/exposure-notifications-verification-server/pkg/controller/jobstatus"
+	"github.com/google/exposure-notifications-verification-server/pkg/database"
+	"github.com/google/exposure-notifications-verification-server/pkg/observability"
+	"github.com/hashicorp/go-multierror"
+	"go.opencensus.io/stats"
+)
+
+// HandleProcess processes pending user imports, oldest first.
+func (c *Controller) HandleProcess() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		logger := logging.FromContext(ctx).Named("userimport.HandleProcess")
+		logger.Debugw("starting")
+		defer logger.Debugw("finishing")
+
+		ok, err := c.db.TryLock(ctx, lockName, c.config.MinPeriod)
+		if err != nil {
+			logger.Errorw("failed to acquire lock", "error", err)
+			c.h.RenderJSON(w, http.StatusInternalServerError, err)
+			return
+		}
+		if !ok {
+			logger.Debugw("skipping (too early)")
+			c.h.RenderJSON(w, http.StatusOK, fmt.Errorf("too early"))
+			return
+		}
+
+		imports, err := c.db.ListPendingUserImports(c.config.BatchSize)
+		if err != nil {
+			logger.Errorw("failed to list pending imports", "error", err)
+			jobstatus.Record(ctx, c.db, jobstatus.JobUserImports, 0, err)
+			c.h.RenderJSON(w, http.StatusInternalServerError, err)
+			return
+		}
+
+		// If one import fails, still attempt the others.
+		var merr *multierror.Error
+		var processed int64
+		for _, userImport := range imports {
+			if err := c.processImport(ctx, userImport); err != nil {
+				merr = multierror.Append(merr, fmt.Errorf("failed to process import %d: %w", userImport.ID, err))
+
+				if err := c.db.FailUserImport(userImport, err); err != nil {
+					merr = multierror.Append(merr, fmt.Errorf("failed to mark import %d as failed: %w", userImport.ID, err))
+				}
+				continue
+			}
+			processed++
+		}
+
+		if err := merr.ErrorOrNil(); err != nil {
+			logger.Errorw("failed to process user imports", "error", err)
+			jobstatus.Record(ctx, c.db, jobstatus.JobUserImports, processed, err)
+			c.h.RenderJSON(w, http.StatusInternalServerError, err)
+			return
+		}
+
+		jobstatus.Record(ctx, c.db, jobstatus.JobUserImports, processed, nil)
+		c.h.RenderJSON(w, http.StatusOK, map[string]interface{}{
+			"processed": processed,
+		})
+	})
+}
+
+// processImport applies the import as the API key that requested it, then
+// notifies the API key's callback URL, if one is configured.
+func (c *Controller) processImport(ctx context.Context, userImport *database.UserImport) error {
+	logger := logging.FromContext(ctx).Named("userimport.processImport").
+		With("user_import", userImport.ID).
+		With("realm", userImport.RealmID)
+	ctx = observability.WithRealmID(ctx, uint64(userImport.RealmID))
+
+	// The API key may have been deleted since the import was requested. The
+	// import is still applied, but audited as the system.
+	var actor database.Auditable = database.System
+	authApp, err := c.db.FindAuthorizedApp(userImport.AuthorizedAppID)
+	if err != nil {
+		if !database.IsNotFound(err) {
+			return fmt.Errorf("failed to find authorized app: %w", err)
+		}
+		authApp = nil
+	} else {
+		actor = authApp
+	}
+
+	if err := c.db.ApplyUserImport(userImport, actor); err != nil {
+		return err
+	}
+
+	logger.Infow("processed user import",
+		"created", userImport.Created,
+		"updated", userImport.Updated,
+		"failed", userImport.Failed)
+	stats.Record(ctx,
+		mImports.M(1),
+		mUsers.M(int64(userImport.Created+userImport.Updated)),
+		mFailed.M(int64(userImport.Failed)))
+
+	c.enqueueCompleted(ctx, authApp, userImport)
+	return nil
+}
+
+// enqueueCompleted queues a completion notification for the import to the
+// authorized app's callback URL, if one is configured. Failures are logged,
+// but never returned.
+func (c *Controller) enqueueCompleted(ctx context.Context, authApp *database.AuthorizedApp, userImport *database.UserImport) {
+	if authApp == nil || authApp.CallbackURL == "" {
+		return
+	}
+
+	logger := logging.FromContext(ctx).Named("userimport.enqueueCompleted").
+		With("authorized_app", authApp.ID)
+
+	b, err := json.Marshal(&api.CallbackNotification{
+		Event:       api.CallbackEventUserImportCompleted,
+		CompletedAt: time.Now().UTC().Unix(),
+		Succeeded:   int(userImport.Created + userImport.Updated),
+		Failed:      int(userImport.Failed),
+		ImportID:    userImport.ID,
+	})
+	if err != nil {
+		logger.Errorw("failed to marshal callback notification", "error", err)
+		return
+	}
+
+	if _, err := c.db.EnqueueCallbackDelivery(authApp.ID, b); err != nil {
+		logger.Errorw("failed to enqueue callback notification", "error", err)
+	}
+}
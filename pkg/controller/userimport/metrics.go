@@ -0,0 +1,57 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package userimport
+
+import (
+	enobs "github.com/google/exposure-notifications-server/pkg/observability"
+	"github.com/google/exposure-notifications-verification-server/pkg/observability"
+
+	"go.opencensus.io/stats"
+	"go.opencensus.io/stats/view"
+)
+
+const metricPrefix = observability.MetricRoot + "/user_import"
+
+var (
+	mImports = stats.Int64(metricPrefix+"/imports", "user imports processed", stats.UnitDimensionless)
+	mUsers   = stats.Int64(metricPrefix+"/users", "users imported", stats.UnitDimensionless)
+	mFailed  = stats.Int64(metricPrefix+"/failed", "users that could not be imported", stats.UnitDimensionless)
+)
+
+func init() {
+	enobs.CollectViews([]*view.View{
+		{
+			Name:        metricPrefix + "/imports",
+			Description: "Number of user imports processed",
+			TagKeys:     observability.CommonTagKeys(),
+			Measure:     mImports,
+			Aggregation: view.Sum(),
+		},
+		{
+			Name:        metricPrefix + "/users",
+			Description: "Number of users created or updated by imports",
+			TagKeys:     observability.CommonTagKeys(),
+			Measure:     mUsers,
+			Aggregation: view.Sum(),
+		},
+		{
+			Name:        metricPrefix + "/failed",
+			Description: "Number of users that could not be imported",
+			TagKeys:     observability.CommonTagKeys(),
+			Measure:     mFailed,
+			Aggregation: view.Sum(),
+		},
+	}...)
+}
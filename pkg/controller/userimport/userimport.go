@@ -0,0 +1,50 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package userimport creates and updates realm memberships in bulk from the
+// admin API. Imports are stored when they are requested and processed by a
+// scheduled worker.
+package userimport
+
+import (
+	"github.com/google/exposure-notifications-verification-server/pkg/config"
+	"github.com/google/exposure-notifications-verification-server/pkg/database"
+	"github.com/google/exposure-notifications-verification-server/pkg/render"
+)
+
+const lockName = "userImportLock"
+
+// Controller is a controller for user imports.
+type Controller struct {
+	config *config.UserImportConfig
+	db     *database.Database
+	h      *render.Renderer
+}
+
+// New creates a new user import worker controller.
+func New(cfg *config.UserImportConfig, db *database.Database, h *render.Renderer) *Controller {
+	return &Controller{
+		config: cfg,
+		db:     db,
+		h:      h,
+	}
+}
+
+// NewAPI creates a new user import controller for the admin API.
+func NewAPI(db *database.Database, h *render.Renderer) *Controller {
+	return &Controller{
+		db: db,
+		h:  h,
+	}
+}
@@ -32,6 +32,7 @@ import (
 	"github.com/google/exposure-notifications-server/pkg/keys"
 
 	"github.com/sethvargo/go-limiter"
+	"golang.org/x/text/language"
 )
 
 type Controller struct {
@@ -82,6 +83,42 @@ func (c *Controller) addDynamicTranslations(realm *database.Realm, m controller.
 	return m
 }
 
+// addUserReportConsent adds the realm's current consent text, in the best
+// locale for the request, to the template map. If the realm has not published
+// consent text, the default agreement is shown instead.
+func (c *Controller) addUserReportConsent(realm *database.Realm, m controller.TemplateMap) error {
+	accept, _ := m["acceptLanguage"].([]string)
+
+	var locales []string
+	for i, v := range accept {
+		if v == "" {
+			continue
+		}
+		// The first value is the query parameter, the rest are headers.
+		if i == 0 {
+			locales = append(locales, v)
+			continue
+		}
+		tags, _, err := language.ParseAcceptLanguage(v)
+		if err != nil {
+			continue
+		}
+		for _, tag := range tags {
+			locales = append(locales, tag.String())
+		}
+	}
+
+	consent, err := realm.CurrentUserReportConsent(c.db, locales...)
+	if err != nil {
+		if database.IsNotFound(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to load user report consent: %w", err)
+	}
+	m["consent"] = consent
+	return nil
+}
+
 func addError(message string, errors []string) []string {
 	if len(errors) == 0 {
 		return []string{message}
@@ -65,6 +65,11 @@ func (c *Controller) HandleIndex() http.Handler {
 			return
 		}
 
+		if err := c.addUserReportConsent(realm, m); err != nil {
+			controller.InternalError(w, r, c.h, err)
+			return
+		}
+
 		now := time.Now().UTC()
 		pastDaysDuration := -1 * c.config.IssueConfig().AllowedSymptomAge
 		displayAllowedDays := fmt.Sprintf("%.0f", c.config.IssueConfig().AllowedSymptomAge.Hours()/24.0)
@@ -27,10 +27,12 @@ import (
 
 	"github.com/google/exposure-notifications-server/pkg/base64util"
 	"github.com/google/exposure-notifications-server/pkg/logging"
+	"github.com/google/exposure-notifications-verification-server/internal/i18n"
 	"github.com/google/exposure-notifications-verification-server/pkg/api"
 	"github.com/google/exposure-notifications-verification-server/pkg/controller"
 	"github.com/google/exposure-notifications-verification-server/pkg/controller/issueapi"
 	"github.com/google/exposure-notifications-verification-server/pkg/database"
+	"github.com/google/exposure-notifications-verification-server/pkg/realip"
 	"go.opencensus.io/stats"
 )
 
@@ -39,6 +41,9 @@ func (c *Controller) HandleSend() http.Handler {
 		TestDate  string `form:"testDate"`
 		Phone     string `form:"phone"`
 		Agreement bool   `form:"agreement"`
+
+		ConsentVersion uint   `form:"consent_version"`
+		ConsentLocale  string `form:"consent_locale"`
 	}
 
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -80,6 +85,11 @@ func (c *Controller) HandleSend() http.Handler {
 			return
 		}
 
+		if err := c.addUserReportConsent(realm, m); err != nil {
+			controller.InternalError(w, r, c.h, err)
+			return
+		}
+
 		var form FormData
 		if err := controller.BindForm(w, r, &form); err != nil {
 			logger.Warn("error binding form", "error", err)
@@ -118,6 +128,30 @@ func (c *Controller) HandleSend() http.Handler {
 			return
 		}
 
+		// If the consent text changed after the page was loaded, the user must
+		// agree to the current version.
+		if realm.UserReportConsentVersion > 0 && form.ConsentVersion != realm.UserReportConsentVersion {
+			stats.Record(ctx, mMissingAgreement.M(1))
+			msg := locale.Get("user-report.missing-agreement")
+			m["error"] = []string{msg}
+			m["termsError"] = msg
+			m["agreement"] = false
+			c.renderIndex(w, realm, m)
+			return
+		}
+
+		// Apply the layered user report limits. There is no API key in the web
+		// flow, so that layer does not apply.
+		if res := c.issueController.CheckUserReportLimits(ctx, realip.FromGoogleCloud(r), realm, nil, form.Phone); res != nil {
+			if res.HTTPCode == http.StatusTooManyRequests {
+				m["error"] = []string{locale.Get("user-report.quota-exceeded")}
+			} else {
+				m["error"] = []string{locale.Get("user-report.internal-error")}
+			}
+			c.renderIndex(w, realm, m)
+			return
+		}
+
 		// Attempt to send the code.
 		issueRequest := &issueapi.IssueRequestInternal{
 			IssueRequest: &api.IssueCodeRequest{
@@ -125,9 +159,12 @@ func (c *Controller) HandleSend() http.Handler {
 				TestType:         api.TestTypeUserReport, // Always test type of user report.
 				Phone:            form.Phone,
 				SMSTemplateLabel: database.UserReportTemplateLabel,
+				Locale:           i18n.TranslatorLanguage(locale),
 			},
-			UserRequested: true,
-			Nonce:         nonce,
+			UserRequested:  true,
+			Nonce:          nonce,
+			ConsentVersion: form.ConsentVersion,
+			ConsentLocale:  form.ConsentLocale,
 		}
 
 		// If the realm has configured a custom webhook URL, do not send the message
@@ -21,6 +21,7 @@
 package verifyapi
 
 import (
+	"context"
 	"errors"
 	"net/http"
 	"time"
@@ -38,27 +39,25 @@ import (
 	"github.com/golang-jwt/jwt"
 )
 
+// VerifyResult is the outcome of exchanging a verification code for a
+// verification token. If ErrorReturn is non-nil, the exchange failed and
+// HTTPCode is the status code to return to the client.
+type VerifyResult struct {
+	Response    *api.VerifyCodeResponse
+	ErrorReturn *api.ErrorReturn
+	HTTPCode    int
+}
+
 func (c *Controller) HandleVerify() http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if c.config.MaintenanceMode {
-			c.h.RenderJSON(w, http.StatusTooManyRequests,
-				api.Errorf("server is read-only for maintenance").WithCode(api.ErrMaintenanceMode))
-			return
-		}
-
 		ctx := r.Context()
 		logger := logging.FromContext(ctx).Named("verifyapi.HandleVerify")
 
-		now := time.Now().UTC()
-
-		blame := enobs.BlameNone
-		result := enobs.ResultOK
-		defer enobs.RecordLatency(ctx, time.Now(), mLatencyMs, &result, &blame)
-
 		authApp := controller.AuthorizedAppFromContext(ctx)
 		if authApp == nil {
-			blame = enobs.BlameClient
-			result = enobs.ResultError("MISSING_AUTHORIZED_APP")
+			blame := enobs.BlameClient
+			result := enobs.ResultError("MISSING_AUTHORIZED_APP")
+			enobs.RecordLatency(ctx, time.Now(), mLatencyMs, &result, &blame)
 			logger.Debugw("no authorized app detected", "blame", blame, "result", result)
 			controller.MissingAuthorizedApp(w, r, c.h)
 			return
@@ -67,134 +66,220 @@ func (c *Controller) HandleVerify() http.Handler {
 		var request api.VerifyCodeRequest
 		if err := controller.BindJSON(w, r, &request); err != nil {
 			logger.Errorw("bad request", "error", err)
-			blame = enobs.BlameClient
-			result = enobs.ResultError("FAILED_TO_PARSE_JSON_REQUEST")
+			blame := enobs.BlameClient
+			result := enobs.ResultError("FAILED_TO_PARSE_JSON_REQUEST")
+			enobs.RecordLatency(ctx, time.Now(), mLatencyMs, &result, &blame)
 
 			c.h.RenderJSON(w, http.StatusBadRequest, api.Error(err).WithCode(api.ErrUnparsableRequest))
 			return
 		}
 
-		// Get the currently active key.
-		activeTokenSigningKey, err := c.db.ActiveTokenSigningKeyCached(ctx, c.cacher)
-		if err != nil {
-			logger.Errorw("failed to get active token signing key", "error", err)
-			blame = enobs.BlameServer
-			result = enobs.ResultError("FAILED_TO_GET_ACTIVE_TOKEN_SIGNING_KEY")
-
-			c.h.RenderJSON(w, http.StatusInternalServerError, api.InternalError())
+		result := c.Verify(ctx, authApp, &request)
+		if result.ErrorReturn != nil {
+			c.h.RenderJSON(w, result.HTTPCode, result.ErrorReturn)
 			return
 		}
+		c.h.RenderJSON(w, http.StatusOK, result.Response)
+	})
+}
 
-		// Get the signer based on the key configuration.
-		signer, err := c.kms.NewSigner(ctx, activeTokenSigningKey.KeyVersionID)
-		if err != nil {
-			logger.Errorw("failed to get signer", "error", err)
-			blame = enobs.BlameServer
-			result = enobs.ResultError("FAILED_TO_GET_SIGNER")
-
-			c.h.RenderJSON(w, http.StatusInternalServerError, api.InternalError())
-			return
+// Verify exchanges the verification code in the request for a signed
+// verification token. It is shared by the JSON and gRPC device APIs, which are
+// responsible for authenticating the authorized app.
+func (c *Controller) Verify(ctx context.Context, authApp *database.AuthorizedApp, request *api.VerifyCodeRequest) *VerifyResult {
+	if c.config.MaintenanceMode {
+		return &VerifyResult{
+			HTTPCode:    http.StatusTooManyRequests,
+			ErrorReturn: api.Errorf("server is read-only for maintenance").WithCode(api.ErrMaintenanceMode),
 		}
+	}
 
-		// Process and validate the requested acceptable test types.
-		acceptTypes, err := request.GetAcceptedTestTypes()
-		if err != nil {
-			logger.Errorf("invalid accept test types", "error", err)
-			blame = enobs.BlameClient
-			result = enobs.ResultError("INVALID_ACCEPT_TEST_TYPES")
+	logger := logging.FromContext(ctx).Named("verifyapi.Verify")
 
-			c.h.RenderJSON(w, http.StatusBadRequest, api.Error(err).WithCode(api.ErrInvalidTestType))
-			return
+	now := time.Now().UTC()
+
+	blame := enobs.BlameNone
+	result := enobs.ResultOK
+	defer enobs.RecordLatency(ctx, time.Now(), mLatencyMs, &result, &blame)
+
+	// Get the currently active key.
+	activeTokenSigningKey, err := c.db.ActiveTokenSigningKeyCached(ctx, c.cacher)
+	if err != nil {
+		logger.Errorw("failed to get active token signing key", "error", err)
+		blame = enobs.BlameServer
+		result = enobs.ResultError("FAILED_TO_GET_ACTIVE_TOKEN_SIGNING_KEY")
+
+		return &VerifyResult{
+			HTTPCode:    http.StatusInternalServerError,
+			ErrorReturn: api.InternalError(),
 		}
+	}
 
-		nonce := []byte{}
-		if request.Nonce != "" {
-			nonce, err = base64util.DecodeString(request.Nonce)
-			if err != nil {
-				blame = enobs.BlameClient
-				result = enobs.ResultError("BAD_NONCE")
-				logger.Errorw("bad request", "error", err, "blame", blame, "result", result)
-				c.h.RenderJSON(w, http.StatusBadRequest, api.Error(err).WithCode(api.ErrUnparsableRequest))
-				return
-			}
+	// Get the signer based on the key configuration.
+	signer, err := c.kms.NewSigner(ctx, activeTokenSigningKey.KeyVersionID)
+	if err != nil {
+		logger.Errorw("failed to get signer", "error", err)
+		blame = enobs.BlameServer
+		result = enobs.ResultError("FAILED_TO_GET_SIGNER")
+
+		return &VerifyResult{
+			HTTPCode:    http.StatusInternalServerError,
+			ErrorReturn: api.InternalError(),
 		}
+	}
 
-		tokenRequest := &database.IssueTokenRequest{
-			Time:        now,
-			AuthApp:     authApp,
-			VerCode:     request.VerificationCode,
-			AcceptTypes: acceptTypes,
-			ExpireAfter: c.config.VerificationTokenDuration,
-			Nonce:       nonce,
-			OS:          controller.OperatingSystemFromContext(ctx),
+	// Process and validate the requested acceptable test types.
+	acceptTypes, err := request.GetAcceptedTestTypes()
+	if err != nil {
+		logger.Errorf("invalid accept test types", "error", err)
+		blame = enobs.BlameClient
+		result = enobs.ResultError("INVALID_ACCEPT_TEST_TYPES")
+
+		return &VerifyResult{
+			HTTPCode:    http.StatusBadRequest,
+			ErrorReturn: api.Error(err).WithCode(api.ErrInvalidTestType),
 		}
-		// Exchange the short term verification code for a long term verification token.
-		// The token can be used to sign TEKs later.
-		verificationToken, err := c.db.VerifyCodeAndIssueToken(tokenRequest)
+	}
+
+	nonce := []byte{}
+	if request.Nonce != "" {
+		nonce, err = base64util.DecodeString(request.Nonce)
 		if err != nil {
 			blame = enobs.BlameClient
-			switch {
-			case errors.Is(err, database.ErrVerificationCodeExpired):
-				result = enobs.ResultError("VERIFICATION_CODE_EXPIRED")
-				apiErr := api.Errorf("verification code expired").WithCode(api.ErrVerifyCodeExpired)
-				logger.Debugw("verify failed: verification code expired", "error", err, "api-error", apiErr)
-				c.h.RenderJSON(w, http.StatusBadRequest, apiErr)
-				return
-			case errors.Is(err, database.ErrVerificationCodeUsed):
-				result = enobs.ResultError("VERIFICATION_CODE_INVALID")
-				apiErr := api.Errorf("verification code invalid").WithCode(api.ErrVerifyCodeInvalid)
-				logger.Debugw("verify failed: verification code invalid", "error", err, "api-error", apiErr)
-				c.h.RenderJSON(w, http.StatusBadRequest, apiErr)
-				return
-			case errors.Is(err, database.ErrVerificationCodeNotFound):
-				result = enobs.ResultError("VERIFICATION_CODE_NOT_FOUND")
-				apiErr := api.Errorf("verification code invalid").WithCode(api.ErrVerifyCodeInvalid)
-				logger.Debugw("verify failed: verification code not found", "error", err, "api-error", apiErr)
-				c.h.RenderJSON(w, http.StatusBadRequest, apiErr)
-				return
-			case errors.Is(err, database.ErrUnsupportedTestType):
-				result = enobs.ResultError("VERIFICATION_CODE_UNSUPPORTED_TEST_TYPE")
-				apiErr := api.Errorf("verification code has unsupported test type").WithCode(api.ErrUnsupportedTestType)
-				logger.Debugw("verify failed: unsupported test type", "error", err, "api-error", apiErr)
-				c.h.RenderJSON(w, http.StatusPreconditionFailed, apiErr)
-				return
-			default:
-				logger.Errorw("failed to issue verification token", "error", err)
-				result = enobs.ResultError("UNKNOWN_ERROR")
-				c.h.RenderJSON(w, http.StatusInternalServerError, api.InternalError())
-				return
+			result = enobs.ResultError("BAD_NONCE")
+			logger.Errorw("bad request", "error", err, "blame", blame, "result", result)
+			return &VerifyResult{
+				HTTPCode:    http.StatusBadRequest,
+				ErrorReturn: api.Error(err).WithCode(api.ErrUnparsableRequest),
 			}
 		}
+	}
 
-		subject := verificationToken.Subject()
-		claims := &jwt.StandardClaims{
-			Audience:  c.config.TokenSigning.TokenIssuer,
-			ExpiresAt: now.Add(c.config.VerificationTokenDuration).Unix(),
-			Id:        verificationToken.TokenID,
-			IssuedAt:  now.Unix(),
-			Issuer:    c.config.TokenSigning.TokenIssuer,
-			Subject:   subject.String(),
+	tokenRequest := &database.IssueTokenRequest{
+		Time:        now,
+		AuthApp:     authApp,
+		VerCode:     database.NormalizeCode(request.VerificationCode),
+		AcceptTypes: acceptTypes,
+		ExpireAfter: c.config.VerificationTokenDuration,
+		Nonce:       nonce,
+		OS:          controller.OperatingSystemFromContext(ctx),
+		DryRun:      c.config.Shadow.DryRun,
+	}
+	// Exchange the short term verification code for a long term verification token.
+	// The token can be used to sign TEKs later.
+	verificationToken, err := c.db.VerifyCodeAndIssueToken(tokenRequest)
+	if err != nil {
+		blame = enobs.BlameClient
+		switch {
+		case errors.Is(err, database.ErrVerificationCodeExpired):
+			result = enobs.ResultError("VERIFICATION_CODE_EXPIRED")
+			c.recordClaimFailure(ctx, authApp, database.ClaimFailureExpired)
+			apiErr := api.Errorf("verification code expired").WithCode(api.ErrVerifyCodeExpired)
+			logger.Debugw("verify failed: verification code expired", "error", err, "api-error", apiErr)
+			return &VerifyResult{HTTPCode: http.StatusBadRequest, ErrorReturn: apiErr}
+		case errors.Is(err, database.ErrVerificationCodeUsed):
+			result = enobs.ResultError("VERIFICATION_CODE_INVALID")
+			c.recordClaimFailure(ctx, authApp, database.ClaimFailureInvalidCode)
+			apiErr := api.Errorf("verification code invalid").WithCode(api.ErrVerifyCodeInvalid)
+			logger.Debugw("verify failed: verification code invalid", "error", err, "api-error", apiErr)
+			return &VerifyResult{HTTPCode: http.StatusBadRequest, ErrorReturn: apiErr}
+		case errors.Is(err, database.ErrVerificationCodeNonceMismatch):
+			result = enobs.ResultError("VERIFICATION_CODE_NONCE_MISMATCH")
+			c.recordClaimFailure(ctx, authApp, database.ClaimFailureNonceMismatch)
+			apiErr := api.Errorf("verification code invalid").WithCode(api.ErrVerifyCodeInvalid)
+			logger.Debugw("verify failed: nonce mismatch", "error", err, "api-error", apiErr)
+			return &VerifyResult{HTTPCode: http.StatusBadRequest, ErrorReturn: apiErr}
+		case errors.Is(err, database.ErrVerificationCodeNotFound):
+			result = enobs.ResultError("VERIFICATION_CODE_NOT_FOUND")
+			c.recordClaimFailure(ctx, authApp, database.ClaimFailureInvalidCode)
+			apiErr := api.Errorf("verification code invalid").WithCode(api.ErrVerifyCodeInvalid)
+			logger.Debugw("verify failed: verification code not found", "error", err, "api-error", apiErr)
+			return &VerifyResult{HTTPCode: http.StatusBadRequest, ErrorReturn: apiErr}
+		case errors.Is(err, database.ErrUnsupportedTestType):
+			result = enobs.ResultError("VERIFICATION_CODE_UNSUPPORTED_TEST_TYPE")
+			c.recordClaimFailure(ctx, authApp, database.ClaimFailureWrongTestType)
+			apiErr := api.Errorf("verification code has unsupported test type").WithCode(api.ErrUnsupportedTestType)
+			logger.Debugw("verify failed: unsupported test type", "error", err, "api-error", apiErr)
+			return &VerifyResult{HTTPCode: http.StatusPreconditionFailed, ErrorReturn: apiErr}
+		default:
+			logger.Errorw("failed to issue verification token", "error", err)
+			result = enobs.ResultError("UNKNOWN_ERROR")
+			return &VerifyResult{
+				HTTPCode:    http.StatusInternalServerError,
+				ErrorReturn: api.InternalError(),
+			}
 		}
-		token := jwt.NewWithClaims(jwt.SigningMethodES256, claims)
+	}
 
-		// Set the JWT kid to the database record ID. We will use this to lookup the
-		// appropriate record to verify.
-		token.Header[verifyapi.KeyIDHeader] = activeTokenSigningKey.UUID
+	subject := verificationToken.Subject()
+	claims := &jwt.StandardClaims{
+		Audience:  c.config.TokenSigning.TokenIssuer,
+		ExpiresAt: now.Add(c.config.VerificationTokenDuration).Unix(),
+		Id:        verificationToken.TokenID,
+		IssuedAt:  now.Unix(),
+		Issuer:    c.config.TokenSigning.TokenIssuer,
+		Subject:   subject.String(),
+	}
+	token, err := jwthelper.NewWithClaims(signer, claims)
+	if err != nil {
+		logger.Errorw("failed to create token", "error", err)
+		blame = enobs.BlameServer
+		result = enobs.ResultError("FAILED_TO_SIGN_TOKEN")
+		return &VerifyResult{
+			HTTPCode:    http.StatusInternalServerError,
+			ErrorReturn: api.Error(err).WithCode(api.ErrInternal),
+		}
+	}
 
-		signedJWT, err := jwthelper.SignJWT(token, signer)
-		if err != nil {
-			logger.Errorw("failed to sign token", "error", err)
-			c.h.RenderJSON(w, http.StatusBadRequest, api.Error(err).WithCode(api.ErrInternal))
-			blame = enobs.BlameServer
-			result = enobs.ResultError("FAILED_TO_SIGN_TOKEN")
-			return
+	// Set the JWT kid to the database record ID. We will use this to lookup the
+	// appropriate record to verify.
+	token.Header[verifyapi.KeyIDHeader] = activeTokenSigningKey.UUID
+
+	signedJWT, err := jwthelper.SignJWT(token, signer)
+	if err != nil {
+		logger.Errorw("failed to sign token", "error", err)
+		blame = enobs.BlameServer
+		result = enobs.ResultError("FAILED_TO_SIGN_TOKEN")
+		return &VerifyResult{
+			HTTPCode:    http.StatusBadRequest,
+			ErrorReturn: api.Error(err).WithCode(api.ErrInternal),
 		}
+	}
 
-		c.h.RenderJSON(w, http.StatusOK, api.VerifyCodeResponse{
+	return &VerifyResult{
+		HTTPCode: http.StatusOK,
+		Response: &api.VerifyCodeResponse{
 			TestType:          verificationToken.TestType,
 			SymptomDate:       verificationToken.FormatSymptomDate(),
 			TestDate:          verificationToken.FormatTestDate(),
 			VerificationToken: signedJWT,
-		})
-	})
+		},
+	}
+}
+
+// recordClaimFailure records a failed claim for diagnostics. Failures to record
+// are logged, but do not fail the request.
+func (c *Controller) recordClaimFailure(ctx context.Context, authApp *database.AuthorizedApp, reason string) {
+	// Shadow deployments see the same requests as production, so recording
+	// their failures would count each failure twice.
+	if c.config.Shadow.DryRun {
+		return
+	}
+
+	if err := c.db.RecordClaimFailure(authApp, reason); err != nil {
+		logger := logging.FromContext(ctx).Named("verifyapi.recordClaimFailure")
+		logger.Errorw("failed to record claim failure", "reason", reason, "error", err)
+	}
+}
+
+// RecordRateLimited records a claim failure for a request that was rejected by
+// rate limiting. It is intended to be used as a rate limiter callback.
+func (c *Controller) RecordRateLimited(r *http.Request) {
+	ctx := r.Context()
+
+	authApp := controller.AuthorizedAppFromContext(ctx)
+	if authApp == nil {
+		return
+	}
+	c.recordClaimFailure(ctx, authApp, database.ClaimFailureQuota)
 }
@@ -34,6 +34,7 @@ import (
 	"github.com/google/exposure-notifications-verification-server/pkg/observability"
 	"github.com/gorilla/mux"
 	"go.opencensus.io/stats"
+	"go.uber.org/zap"
 )
 
 type TwilioWebhookPayload struct {
@@ -47,7 +48,6 @@ func (c *Controller) HandleTwilio() http.Handler {
 
 		ctx := r.Context()
 		logger := logging.FromContext(ctx).Named("webhooks.HandleTwilio")
-		vars := mux.Vars(r)
 
 		// Ensure the header is present. We'll validate it later, but validating it
 		// requires us to lookup the realm and decrypt the authToken, which is an
@@ -99,75 +99,90 @@ func (c *Controller) HandleTwilio() http.Handler {
 		}
 
 		// If we got this far, this is a webhook request for which we should
-		// increment a metric. Find the realm based on the URL param.
-		logger = logger.With("realm_id", vars["realm_id"])
-		realm, err := c.db.FindRealm(vars["realm_id"])
-		if err != nil {
-			logger.Warnw("failed to lookup realm", "error", err)
+		// increment a metric. Find and authenticate the realm based on the URL
+		// param.
+		realm, ok := c.authenticateTwilio(w, r, logger, givenSignature)
+		if !ok {
+			return
+		}
 
-			if database.IsNotFound(err) {
-				controller.BadRequest(w, r, c.h)
-				return
-			}
+		// If we got this far, the message passed the signature check.
+		ctx = observability.WithRealmID(ctx, uint64(realm.ID))
+		ctx = observability.WithErrorCode(ctx, payload.ErrorCode)
+		defer stats.Record(ctx, mTwilioErrors.M(1))
 
+		if err := c.db.InsertSMSErrorStat(now, realm.ID, payload.ErrorCode); err != nil {
 			controller.InternalError(w, r, c.h, err)
 			return
 		}
 
-		// Look up the sms configuration for the realm. This is necessary because
-		// Twilio uses the auth token as the HMAC key.
-		smsConfig, err := realm.SMSConfig(c.db)
-		if err != nil {
-			logger.Warnw("failed to lookup realm sms config", "error", err)
+		c.h.RenderJSON(w, http.StatusOK, nil)
+		return
+	})
+}
 
-			if database.IsNotFound(err) {
-				controller.BadRequest(w, r, c.h)
-				return
-			}
+// authenticateTwilio looks up the realm from the URL and verifies that the
+// request was signed by that realm's Twilio auth token. If authentication
+// fails, it renders the appropriate error and returns false.
+func (c *Controller) authenticateTwilio(w http.ResponseWriter, r *http.Request, logger *zap.SugaredLogger, givenSignature string) (*database.Realm, bool) {
+	vars := mux.Vars(r)
 
-			controller.InternalError(w, r, c.h, err)
-			return
-		}
+	logger = logger.With("realm_id", vars["realm_id"])
+	realm, err := c.db.FindRealm(vars["realm_id"])
+	if err != nil {
+		logger.Warnw("failed to lookup realm", "error", err)
 
-		// Sanity check account sids.
-		if got, want := r.Form.Get("AccountSid"), smsConfig.TwilioAccountSid; got != want {
-			logger.Warnw("twilio account sid mismatch",
-				"got", got,
-				"want", want)
+		if database.IsNotFound(err) {
 			controller.BadRequest(w, r, c.h)
-			return
+			return nil, false
 		}
 
-		// Calculate the expected signature.
-		expSignature, err := ComputeSignature(r, smsConfig.TwilioAuthToken)
-		if err != nil {
-			logger.Errorw("failed to compute twilio signature", "error", err)
-			controller.InternalError(w, r, c.h, err)
-			return
-		}
+		controller.InternalError(w, r, c.h, err)
+		return nil, false
+	}
 
-		// Compare the expected signature with the given signature.
-		if subtle.ConstantTimeCompare([]byte(givenSignature), []byte(expSignature)) != 1 {
-			logger.Debugw("signature mismatch",
-				"given", givenSignature,
-				"expected", expSignature)
+	// Look up the sms configuration for the realm. This is necessary because
+	// Twilio uses the auth token as the HMAC key.
+	smsConfig, err := realm.SMSConfig(c.db)
+	if err != nil {
+		logger.Warnw("failed to lookup realm sms config", "error", err)
+
+		if database.IsNotFound(err) {
 			controller.BadRequest(w, r, c.h)
-			return
+			return nil, false
 		}
 
-		// If we got this far, the message passed the signature check.
-		ctx = observability.WithRealmID(ctx, uint64(realm.ID))
-		ctx = observability.WithErrorCode(ctx, payload.ErrorCode)
-		defer stats.Record(ctx, mTwilioErrors.M(1))
+		controller.InternalError(w, r, c.h, err)
+		return nil, false
+	}
 
-		if err := c.db.InsertSMSErrorStat(now, realm.ID, payload.ErrorCode); err != nil {
-			controller.InternalError(w, r, c.h, err)
-			return
-		}
+	// Sanity check account sids.
+	if got, want := r.Form.Get("AccountSid"), smsConfig.TwilioAccountSid; got != want {
+		logger.Warnw("twilio account sid mismatch",
+			"got", got,
+			"want", want)
+		controller.BadRequest(w, r, c.h)
+		return nil, false
+	}
 
-		c.h.RenderJSON(w, http.StatusOK, nil)
-		return
-	})
+	// Calculate the expected signature.
+	expSignature, err := ComputeSignature(r, smsConfig.TwilioAuthToken)
+	if err != nil {
+		logger.Errorw("failed to compute twilio signature", "error", err)
+		controller.InternalError(w, r, c.h, err)
+		return nil, false
+	}
+
+	// Compare the expected signature with the given signature.
+	if subtle.ConstantTimeCompare([]byte(givenSignature), []byte(expSignature)) != 1 {
+		logger.Debugw("signature mismatch",
+			"given", givenSignature,
+			"expected", expSignature)
+		controller.BadRequest(w, r, c.h)
+		return nil, false
+	}
+
+	return realm, true
 }
 
 // ComputeSignature builds the expected webhook signature from a Twilio request
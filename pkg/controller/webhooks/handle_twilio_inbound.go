@@ -0,0 +1,143 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhooks
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/google/exposure-notifications-server/pkg/logging"
+	"github.com/google/exposure-notifications-verification-server/internal/project"
+	"github.com/google/exposure-notifications-verification-server/pkg/controller"
+	"github.com/google/exposure-notifications-verification-server/pkg/database"
+	"github.com/google/exposure-notifications-verification-server/pkg/observability"
+	"github.com/nyaruka/phonenumbers"
+	"go.opencensus.io/stats"
+	"go.opencensus.io/tag"
+)
+
+// emptyTwiML is the response to an inbound message. It tells Twilio not to
+// send a reply; Twilio sends its own confirmation for opt-out keywords.
+const emptyTwiML = `<?xml version="1.0" encoding="UTF-8"?><Response></Response>`
+
+// twilioOptOutKeywords and twilioOptInKeywords are Twilio's default opt-out
+// and opt-in keywords. They are only used when Twilio does not send the
+// OptOutType field.
+var (
+	twilioOptOutKeywords = []string{"STOP", "STOPALL", "UNSUBSCRIBE", "CANCEL", "END", "QUIT"}
+	twilioOptInKeywords  = []string{"START", "YES", "UNSTOP"}
+)
+
+// HandleTwilioInbound handles inbound messages to a realm's Twilio number. When
+// the sender opts out (e.g. replies STOP), their number is added to the
+// realm's SMS suppression list. When they opt back in (e.g. START), it is
+// removed. All other messages are ignored.
+func (c *Controller) HandleTwilioInbound() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		logger := logging.FromContext(ctx).Named("webhooks.HandleTwilioInbound")
+
+		// Ensure the header is present before doing any I/O.
+		givenSignature := r.Header.Get("X-Twilio-Signature")
+		if givenSignature == "" {
+			logger.Debug("request is missing signature header")
+			controller.BadRequest(w, r, c.h)
+			return
+		}
+
+		if err := r.ParseForm(); err != nil {
+			logger.Errorw("failed to parse form", "error", err)
+			controller.BadRequest(w, r, c.h)
+			return
+		}
+
+		action := twilioOptOutAction(r.Form.Get("OptOutType"), r.Form.Get("Body"))
+		if action == "" {
+			logger.Debugw("inbound message is not an opt-out or opt-in")
+			renderTwiML(w)
+			return
+		}
+
+		realm, ok := c.authenticateTwilio(w, r, logger, givenSignature)
+		if !ok {
+			return
+		}
+
+		// If we got this far, the message passed the signature check.
+		from, err := project.CanonicalPhoneNumber(r.Form.Get("From"), phonenumbers.UNKNOWN_REGION)
+		if err != nil {
+			logger.Warnw("failed to parse From number", "error", err)
+			controller.BadRequest(w, r, c.h)
+			return
+		}
+
+		switch action {
+		case "STOP":
+			err = realm.SuppressSMS(c.db, from, database.SMSSuppressionSourceTwilio)
+		case "START":
+			err = realm.UnsuppressSMS(c.db, from)
+		}
+		if err != nil {
+			logger.Errorw("failed to update sms suppression list", "error", err)
+			controller.InternalError(w, r, c.h, err)
+			return
+		}
+
+		ctx = observability.WithRealmID(ctx, uint64(realm.ID))
+		if err := stats.RecordWithTags(ctx, []tag.Mutator{tag.Upsert(optOutTypeTagKey, action)}, mTwilioOptOuts.M(1)); err != nil {
+			logger.Errorw("failed to record opt-out metric", "error", err)
+		}
+
+		renderTwiML(w)
+	})
+}
+
+// twilioOptOutAction returns "STOP" if the inbound message opts out, "START" if
+// it opts back in, and the empty string otherwise. Twilio sends OptOutType when
+// Advanced Opt-Out is enabled on the messaging service; otherwise the message
+// body is matched against the default keywords.
+func twilioOptOutAction(optOutType, body string) string {
+	switch strings.ToUpper(strings.TrimSpace(optOutType)) {
+	case "STOP":
+		return "STOP"
+	case "START":
+		return "START"
+	case "":
+	default:
+		// HELP and any other types are informational.
+		return ""
+	}
+
+	keyword := strings.ToUpper(strings.TrimSpace(body))
+	for _, k := range twilioOptOutKeywords {
+		if keyword == k {
+			return "STOP"
+		}
+	}
+	for _, k := range twilioOptInKeywords {
+		if keyword == k {
+			return "START"
+		}
+	}
+	return ""
+}
+
+// renderTwiML renders an empty TwiML response.
+func renderTwiML(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "text/xml")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprint(w, emptyTwiML)
+}
@@ -0,0 +1,157 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhooks_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/google/exposure-notifications-verification-server/internal/envstest"
+	"github.com/google/exposure-notifications-verification-server/internal/project"
+	"github.com/google/exposure-notifications-verification-server/pkg/controller/webhooks"
+	"github.com/google/exposure-notifications-verification-server/pkg/database"
+	"github.com/google/exposure-notifications-verification-server/pkg/sms"
+	"github.com/gorilla/mux"
+)
+
+func TestHandleTwilioInbound(t *testing.T) {
+	t.Parallel()
+
+	ctx := project.TestContext(t)
+	harness := envstest.NewServerConfig(t, testDatabaseInstance)
+
+	realm := database.NewRealmWithDefaults("realm-with-sms")
+	if err := harness.Database.SaveRealm(realm, database.SystemTest); err != nil {
+		t.Fatal(err)
+	}
+
+	smsConfig := &database.SMSConfig{
+		RealmID:          realm.ID,
+		ProviderType:     sms.ProviderTypeTwilio,
+		TwilioAccountSid: "abc123",
+		TwilioFromNumber: "+15005550006",
+		TwilioAuthToken:  "abc123",
+	}
+	if err := harness.Database.SaveSMSConfig(smsConfig); err != nil {
+		t.Fatal(err)
+	}
+
+	c := webhooks.New(harness.Cacher, harness.Database, harness.Renderer)
+
+	const phone = "+12068675309"
+
+	// send sends an inbound message with the given fields. If authToken is
+	// not empty, the request is signed with it.
+	send := func(t *testing.T, vals url.Values, authToken string) *httptest.ResponseRecorder {
+		t.Helper()
+
+		r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(vals.Encode()))
+		r = r.Clone(ctx)
+		r = mux.SetURLVars(r, map[string]string{"realm_id": fmt.Sprintf("%d", realm.ID)})
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+		signature := "abc123"
+		if authToken != "" {
+			sig, err := webhooks.ComputeSignature(r, authToken)
+			if err != nil {
+				t.Fatal(err)
+			}
+			signature = sig
+		}
+		r.Header.Set("X-Twilio-Signature", signature)
+
+		w := httptest.NewRecorder()
+		c.HandleTwilioInbound().ServeHTTP(w, r)
+		return w
+	}
+
+	suppressed := func(t *testing.T) bool {
+		t.Helper()
+
+		ok, err := realm.IsSMSSuppressed(harness.Database, phone)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return ok
+	}
+
+	t.Run("missing_signature", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader("Body=STOP"))
+		r = r.Clone(ctx)
+		w := httptest.NewRecorder()
+		c.HandleTwilioInbound().ServeHTTP(w, r)
+
+		if got, want := w.Code, http.StatusBadRequest; got != want {
+			t.Errorf("expected %d to be %d", got, want)
+		}
+	})
+
+	t.Run("bad_signature", func(t *testing.T) {
+		w := send(t, url.Values{"AccountSid": {"abc123"}, "From": {phone}, "Body": {"STOP"}}, "")
+		if got, want := w.Code, http.StatusBadRequest; got != want {
+			t.Errorf("expected %d to be %d", got, want)
+		}
+		if suppressed(t) {
+			t.Errorf("expected number to not be suppressed")
+		}
+	})
+
+	t.Run("not_opt_out", func(t *testing.T) {
+		w := send(t, url.Values{"AccountSid": {"abc123"}, "From": {phone}, "Body": {"please stop"}}, "abc123")
+		if got, want := w.Code, http.StatusOK; got != want {
+			t.Errorf("expected %d to be %d", got, want)
+		}
+		if suppressed(t) {
+			t.Errorf("expected number to not be suppressed")
+		}
+	})
+
+	t.Run("stop_keyword", func(t *testing.T) {
+		w := send(t, url.Values{"AccountSid": {"abc123"}, "From": {phone}, "Body": {" Unsubscribe "}}, "abc123")
+		if got, want := w.Code, http.StatusOK; got != want {
+			t.Errorf("expected %d to be %d", got, want)
+		}
+		if got, want := w.Header().Get("Content-Type"), "text/xml"; got != want {
+			t.Errorf("expected %q to be %q", got, want)
+		}
+		if !suppressed(t) {
+			t.Errorf("expected number to be suppressed")
+		}
+	})
+
+	t.Run("start_opt_out_type", func(t *testing.T) {
+		w := send(t, url.Values{"AccountSid": {"abc123"}, "From": {phone}, "Body": {"go"}, "OptOutType": {"START"}}, "abc123")
+		if got, want := w.Code, http.StatusOK; got != want {
+			t.Errorf("expected %d to be %d", got, want)
+		}
+		if suppressed(t) {
+			t.Errorf("expected number to not be suppressed")
+		}
+	})
+
+	t.Run("stop_opt_out_type", func(t *testing.T) {
+		w := send(t, url.Values{"AccountSid": {"abc123"}, "From": {phone}, "Body": {"halt"}, "OptOutType": {"STOP"}}, "abc123")
+		if got, want := w.Code, http.StatusOK; got != want {
+			t.Errorf("expected %d to be %d", got, want)
+		}
+		if !suppressed(t) {
+			t.Errorf("expected number to be suppressed")
+		}
+	})
+}
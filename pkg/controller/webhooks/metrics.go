@@ -20,11 +20,18 @@ import (
 
 	"go.opencensus.io/stats"
 	"go.opencensus.io/stats/view"
+	"go.opencensus.io/tag"
 )
 
 const metricPrefix = observability.MetricRoot + "/webhooks"
 
-var mTwilioErrors = stats.Int64(metricPrefix+"/twilio_errors", "The number of Twilio errors.", stats.UnitDimensionless)
+var (
+	mTwilioErrors  = stats.Int64(metricPrefix+"/twilio_errors", "The number of Twilio errors.", stats.UnitDimensionless)
+	mTwilioOptOuts = stats.Int64(metricPrefix+"/twilio_opt_outs", "The number of Twilio opt-out and opt-in messages.", stats.UnitDimensionless)
+
+	// optOutTypeTagKey is either STOP or START.
+	optOutTypeTagKey = tag.MustNewKey("opt_out_type")
+)
 
 func init() {
 	enobs.CollectViews([]*view.View{
@@ -35,5 +42,12 @@ func init() {
 			TagKeys:     observability.CommonTagKeys(),
 			Aggregation: view.Count(),
 		},
+		{
+			Name:        metricPrefix + "/twilio_opt_outs",
+			Measure:     mTwilioOptOuts,
+			Description: "The count of Twilio opt-out and opt-in messages, tagged by realm and opt_out_type.",
+			TagKeys:     append(observability.CommonTagKeys(), optOutTypeTagKey),
+			Aggregation: view.Count(),
+		},
 	}...)
 }
@@ -46,11 +46,32 @@ func (c *HotCodec) Encode(name string, value interface{}) (string, error) {
 
 // Decode implements securecookie Codec.
 func (c *HotCodec) Decode(name, value string, dst interface{}) error {
+	_, err := c.DecodeGeneration(name, value, dst)
+	return err
+}
+
+// DecodeGeneration decodes the value like Decode and returns the generation of
+// the key that decoded it. Generation 0 is the current key, which is used to
+// encode new values. Higher generations are previous keys that are still
+// accepted while sessions are re-issued under the current key.
+func (c *HotCodec) DecodeGeneration(name, value string, dst interface{}) (int, error) {
 	cs, err := c.newSecureCookies()
 	if err != nil {
-		return fmt.Errorf("failed to make secure cookie: %w", err)
+		return -1, fmt.Errorf("failed to make secure cookie: %w", err)
+	}
+	if len(cs) == 0 {
+		return -1, fmt.Errorf("no cookie keys are available")
+	}
+
+	var errs securecookie.MultiError
+	for i, codec := range cs {
+		if err := codec.Decode(name, value, dst); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		return i, nil
 	}
-	return securecookie.DecodeMulti(name, value, dst, cs...)
+	return -1, errs
 }
 
 // newSecureCookies creates a new collection of secure cookies from the data
@@ -14,13 +14,33 @@
 
 // Package cookiestore provies an abstraction on top of the existing cookie
 // store to handle hot-reloading of cookie HMAC and encryption keys.
+//
+// Multiple key generations are accepted at once. Sessions decoded with a
+// previous generation are marked so they can be re-issued under the current
+// key, which lets keys rotate without signing out every user at once.
 package cookiestore
 
 import (
-	"github.com/gorilla/securecookie"
+	"net"
+	"net/http"
+	"strings"
+
 	"github.com/gorilla/sessions"
 )
 
+// generationKey is the session value key under which the key generation of a
+// decoded session is recorded. It is removed before the session is saved.
+type generationKey struct{}
+
+var _ sessions.Store = (*Store)(nil)
+
+// Store is a cookie-based session store that accepts multiple generations of
+// cookie keys and always encodes with the current generation.
+type Store struct {
+	codec   *HotCodec
+	options *sessions.Options
+}
+
 // New returns a new session store
 func New(fn EntropyFunc, opts *sessions.Options) sessions.Store {
 	if opts == nil {
@@ -38,9 +58,80 @@ func New(fn EntropyFunc, opts *sessions.Options) sessions.Store {
 		maxAge:      opts.MaxAge,
 	}
 
-	cs := &sessions.CookieStore{
-		Codecs:  []securecookie.Codec{codec},
-		Options: opts,
+	return &Store{
+		codec:   codec,
+		options: opts,
+	}
+}
+
+// Get returns a session for the given name after adding it to the registry.
+func (s *Store) Get(r *http.Request, name string) (*sessions.Session, error) {
+	return sessions.GetRegistry(r).Get(s, name)
+}
+
+// New returns a session for the given name without adding it to the registry.
+// If the session was encoded with a previous key generation, it is marked for
+// re-issuance. See KeyGeneration.
+func (s *Store) New(r *http.Request, name string) (*sessions.Session, error) {
+	session := sessions.NewSession(s, name)
+	opts := *s.options
+	if opts.Domain != "" && !hostInDomain(r.Host, opts.Domain) {
+		// Realms on a custom domain get host-only cookies. Browsers reject cookies
+		// for a domain that does not match the request's host.
+		opts.Domain = ""
+	}
+	session.Options = &opts
+	session.IsNew = true
+
+	c, err := r.Cookie(name)
+	if err != nil {
+		return session, nil
+	}
+
+	generation, err := s.codec.DecodeGeneration(name, c.Value, &session.Values)
+	if err != nil {
+		return session, err
+	}
+	session.IsNew = false
+
+	if generation > 0 {
+		session.Values[generationKey{}] = generation
+	}
+	return session, nil
+}
+
+// Save encodes the session with the current key generation and adds it to the
+// response.
+func (s *Store) Save(r *http.Request, w http.ResponseWriter, session *sessions.Session) error {
+	delete(session.Values, generationKey{})
+
+	encoded, err := s.codec.Encode(session.Name(), session.Values)
+	if err != nil {
+		return err
+	}
+	http.SetCookie(w, sessions.NewCookie(session.Name(), encoded, session.Options))
+	return nil
+}
+
+// KeyGeneration returns the generation of the cookie key that decoded the
+// session. Generation 0 is the current key. Sessions from a previous generation
+// should be saved so they are re-issued under the current key before the
+// previous key is retired.
+func KeyGeneration(session *sessions.Session) int {
+	if session == nil || session.Values == nil {
+		return 0
+	}
+	generation, _ := session.Values[generationKey{}].(int)
+	return generation
+}
+
+// hostInDomain returns true if the host, which may include a port, is the
+// cookie domain or a subdomain of it.
+func hostInDomain(host, domain string) bool {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
 	}
-	return cs
+	host = strings.ToLower(host)
+	domain = strings.ToLower(strings.TrimPrefix(domain, "."))
+	return host == domain || strings.HasSuffix(host, "."+domain)
 }
@@ -0,0 +1,76 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cookiestore
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/sessions"
+)
+
+func TestStore_New_CustomDomain(t *testing.T) {
+	t.Parallel()
+
+	store := New(func() ([][]byte, error) {
+		return nil, nil
+	}, &sessions.Options{Domain: "encv.example.com"})
+
+	cases := []struct {
+		name   string
+		host   string
+		domain string
+	}{
+		{
+			name:   "cookie_domain",
+			host:   "encv.example.com",
+			domain: "encv.example.com",
+		},
+		{
+			name:   "subdomain_with_port",
+			host:   "admin.encv.example.com:8080",
+			domain: "encv.example.com",
+		},
+		{
+			name:   "custom_domain",
+			host:   "verify.health.example.gov",
+			domain: "",
+		},
+		{
+			name:   "suffix_is_not_subdomain",
+			host:   "notencv.example.com",
+			domain: "",
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			r := httptest.NewRequest("GET", "/", nil)
+			r.Host = tc.host
+
+			session, err := store.New(r, "session")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got, want := session.Options.Domain, tc.domain; got != want {
+				t.Errorf("expected %q to be %q", got, want)
+			}
+		})
+	}
+}
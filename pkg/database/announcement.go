@@ -0,0 +1,343 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package database
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/exposure-notifications-verification-server/internal/project"
+	"github.com/jinzhu/gorm"
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/russross/blackfriday/v2"
+)
+
+// AnnouncementAudience is the set of users to whom an announcement is shown.
+type AnnouncementAudience string
+
+const (
+	// AnnouncementAudienceAll shows the announcement to members of all realms.
+	AnnouncementAudienceAll AnnouncementAudience = "all"
+
+	// AnnouncementAudienceENX shows the announcement to members of realms with
+	// EN Express enabled.
+	AnnouncementAudienceENX AnnouncementAudience = "enx"
+
+	// AnnouncementAudienceSystemAdmins shows the announcement to system admins.
+	AnnouncementAudienceSystemAdmins AnnouncementAudience = "system_admins"
+)
+
+// AnnouncementAudiences are the valid audiences, in display order.
+var AnnouncementAudiences = []AnnouncementAudience{
+	AnnouncementAudienceAll,
+	AnnouncementAudienceENX,
+	AnnouncementAudienceSystemAdmins,
+}
+
+// Display is the human-readable audience.
+func (a AnnouncementAudience) Display() string {
+	switch a {
+	case AnnouncementAudienceAll:
+		return "All realms"
+	case AnnouncementAudienceENX:
+		return "EN Express realms"
+	case AnnouncementAudienceSystemAdmins:
+		return "System admins"
+	default:
+		return "Unknown"
+	}
+}
+
+var _ Auditable = (*Announcement)(nil)
+
+// Announcement is a system-admin managed notice, such as rollout notes for a
+// new capability, that is shown as a dismissible banner in the realm UI.
+type Announcement struct {
+	Errorable
+
+	// ID is the announcement's ID.
+	ID uint `gorm:"primary_key;"`
+
+	// Title is the short headline of the announcement.
+	Title string `gorm:"column:title; type:text; not null;"`
+
+	// Body is the announcement text. It supports markdown syntax.
+	Body string `gorm:"column:body; type:text; not null;"`
+
+	// Audience determines who sees the announcement.
+	Audience AnnouncementAudience `gorm:"column:audience; type:text; not null;"`
+
+	// StartsAt and EndsAt bound when the announcement is shown. If EndsAt is
+	// nil, the announcement is shown until every user dismisses it.
+	StartsAt time.Time  `gorm:"column:starts_at; type:timestamp with time zone; not null;"`
+	EndsAt   *time.Time `gorm:"column:ends_at; type:timestamp with time zone;"`
+
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// TableName sets the table name.
+func (Announcement) TableName() string {
+	return "announcements"
+}
+
+// BeforeSave runs validations. If there are errors, the save fails.
+func (a *Announcement) BeforeSave(tx *gorm.DB) error {
+	a.Title = project.TrimSpace(a.Title)
+	if a.Title == "" {
+		a.AddError("title", "cannot be blank")
+	}
+
+	a.Body = project.TrimSpace(a.Body)
+	if a.Body == "" {
+		a.AddError("body", "cannot be blank")
+	}
+
+	switch a.Audience {
+	case AnnouncementAudienceAll, AnnouncementAudienceENX, AnnouncementAudienceSystemAdmins:
+	default:
+		a.AddError("audience", "is invalid")
+	}
+
+	if a.StartsAt.IsZero() {
+		a.StartsAt = time.Now().UTC()
+	}
+	if a.EndsAt != nil && !a.EndsAt.After(a.StartsAt) {
+		a.AddError("endsAt", "must be after the start time")
+	}
+
+	return a.ErrorOrNil()
+}
+
+// BodyHTML returns the body rendered from markdown and sanitized.
+func (a *Announcement) BodyHTML() string {
+	raw := blackfriday.Run([]byte(strings.TrimSpace(a.Body)))
+	return string(bluemonday.UGCPolicy().SanitizeBytes(raw))
+}
+
+// IsActive returns true if the announcement should be shown at the given time.
+func (a *Announcement) IsActive(now time.Time) bool {
+	if now.Before(a.StartsAt) {
+		return false
+	}
+	return a.EndsAt == nil || now.Before(*a.EndsAt)
+}
+
+// VisibleTo returns true if the announcement targets the given user. The realm
+// is the user's currently-selected realm, which may be nil.
+func (a *Announcement) VisibleTo(user *User, realm *Realm) bool {
+	switch a.Audience {
+	case AnnouncementAudienceAll:
+		return realm != nil
+	case AnnouncementAudienceENX:
+		return realm != nil && realm.EnableENExpress
+	case AnnouncementAudienceSystemAdmins:
+		return user != nil && user.IsSystemAdmin()
+	default:
+		return false
+	}
+}
+
+// AuditID is how the announcement is stored in the audit entry.
+func (a *Announcement) AuditID() string {
+	return fmt.Sprintf("announcements:%d", a.ID)
+}
+
+// AuditDisplay is how the announcement will be displayed in audit entries.
+func (a *Announcement) AuditDisplay() string {
+	return a.Title
+}
+
+// ListAnnouncements lists all announcements, newest first.
+func (db *Database) ListAnnouncements() ([]*Announcement, error) {
+	var announcements []*Announcement
+	if err := db.db.
+		Model(&Announcement{}).
+		Order("starts_at DESC, id DESC").
+		Find(&announcements).
+		Error; err != nil {
+		if IsNotFound(err) {
+			return announcements, nil
+		}
+		return nil, err
+	}
+	return announcements, nil
+}
+
+// ListActiveAnnouncements lists the announcements that should be shown at the
+// given time, oldest first.
+func (db *Database) ListActiveAnnouncements(now time.Time) ([]*Announcement, error) {
+	var announcements []*Announcement
+	if err := db.db.
+		Model(&Announcement{}).
+		Where("starts_at <= ?", now).
+		Where("ends_at IS NULL OR ends_at > ?", now).
+		Order("starts_at ASC, id ASC").
+		Find(&announcements).
+		Error; err != nil {
+		if IsNotFound(err) {
+			return announcements, nil
+		}
+		return nil, err
+	}
+	return announcements, nil
+}
+
+// FindAnnouncement finds the announcement by the given id.
+func (db *Database) FindAnnouncement(id interface{}) (*Announcement, error) {
+	var announcement Announcement
+	if err := db.db.
+		Model(&Announcement{}).
+		Where("id = ?", id).
+		First(&announcement).
+		Error; err != nil {
+		return nil, err
+	}
+	return &announcement, nil
+}
+
+// ReadAnnouncementIDs returns the subset of the given announcement IDs that the
+// user has dismissed.
+func (db *Database) ReadAnnouncementIDs(user *User, ids []uint) (map[uint]struct{}, error) {
+	result := make(map[uint]struct{}, len(ids))
+	if user == nil || len(ids) == 0 {
+		return result, nil
+	}
+
+	var readIDs []uint
+	if err := db.db.
+		Table("announcement_reads").
+		Where("user_id = ?", user.ID).
+		Where("announcement_id IN (?)", ids).
+		Pluck("announcement_id", &readIDs).
+		Error; err != nil {
+		if IsNotFound(err) {
+			return result, nil
+		}
+		return nil, err
+	}
+
+	for _, id := range readIDs {
+		result[id] = struct{}{}
+	}
+	return result, nil
+}
+
+// MarkAnnouncementRead records that the user dismissed the announcement. It is
+// safe to call multiple times.
+func (db *Database) MarkAnnouncementRead(a *Announcement, user *User) error {
+	if a == nil {
+		return fmt.Errorf("provided announcement is nil")
+	}
+	if user == nil {
+		return ErrMissingActor
+	}
+
+	sql := `
+		INSERT INTO announcement_reads (announcement_id, user_id, read_at)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (announcement_id, user_id) DO NOTHING`
+	if err := db.db.Exec(sql, a.ID, user.ID, time.Now().UTC()).Error; err != nil {
+		return fmt.Errorf("failed to mark announcement read: %w", err)
+	}
+	return nil
+}
+
+// CountAnnouncementReads returns the number of users who dismissed the
+// announcement.
+func (db *Database) CountAnnouncementReads(a *Announcement) (int64, error) {
+	var count int64
+	if err := db.db.
+		Table("announcement_reads").
+		Where("announcement_id = ?", a.ID).
+		Count(&count).
+		Error; err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// SaveAnnouncement creates or updates the announcement.
+func (db *Database) SaveAnnouncement(a *Announcement, actor Auditable) error {
+	if a == nil {
+		return fmt.Errorf("provided announcement is nil")
+	}
+
+	if actor == nil {
+		return ErrMissingActor
+	}
+
+	return db.db.Transaction(func(tx *gorm.DB) error {
+		var audits []*AuditEntry
+
+		var existing Announcement
+		if err := tx.
+			Model(&Announcement{}).
+			Where("id = ?", a.ID).
+			First(&existing).
+			Error; err != nil && !IsNotFound(err) {
+			return fmt.Errorf("failed to get existing announcement: %w", err)
+		}
+
+		if err := tx.Save(a).Error; err != nil {
+			return err
+		}
+
+		if existing.ID == 0 {
+			audit := BuildAuditEntry(actor, "created announcement", a, 0)
+			audits = append(audits, audit)
+		} else {
+			if existing.Title != a.Title {
+				audit := BuildAuditEntry(actor, "updated announcement title", a, 0)
+				audit.Diff = stringDiff(existing.Title, a.Title)
+				audits = append(audits, audit)
+			}
+
+			if existing.Body != a.Body {
+				audit := BuildAuditEntry(actor, "updated announcement body", a, 0)
+				audit.Diff = stringDiff(existing.Body, a.Body)
+				audits = append(audits, audit)
+			}
+
+			if existing.Audience != a.Audience {
+				audit := BuildAuditEntry(actor, "updated announcement audience", a, 0)
+				audit.Diff = stringDiff(string(existing.Audience), string(a.Audience))
+				audits = append(audits, audit)
+			}
+
+			if !existing.StartsAt.Equal(a.StartsAt) || !timePtrEqual(existing.EndsAt, a.EndsAt) {
+				audit := BuildAuditEntry(actor, "updated announcement schedule", a, 0)
+				audits = append(audits, audit)
+			}
+		}
+
+		for _, audit := range audits {
+			if err := tx.Save(audit).Error; err != nil {
+				return fmt.Errorf("failed to save audits: %w", err)
+			}
+		}
+		return nil
+	})
+}
+
+// timePtrEqual returns true if both times are nil or represent the same
+// instant.
+func timePtrEqual(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Equal(*b)
+}
@@ -0,0 +1,197 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package database
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAnnouncement_BeforeSave(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now().UTC()
+	past := now.Add(-1 * time.Hour)
+
+	cases := []struct {
+		name         string
+		announcement *Announcement
+		errs         []string
+	}{
+		{
+			name:         "empty",
+			announcement: &Announcement{},
+			errs:         []string{"title", "body", "audience"},
+		},
+		{
+			name: "ends_before_start",
+			announcement: &Announcement{
+				Title:    "New",
+				Body:     "Something new",
+				Audience: AnnouncementAudienceAll,
+				StartsAt: now,
+				EndsAt:   &past,
+			},
+			errs: []string{"endsAt"},
+		},
+		{
+			name: "valid",
+			announcement: &Announcement{
+				Title:    " New ",
+				Body:     "Something **new**",
+				Audience: AnnouncementAudienceENX,
+			},
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			_ = tc.announcement.BeforeSave(nil)
+			for _, field := range tc.errs {
+				if errs := tc.announcement.ErrorsFor(field); len(errs) < 1 {
+					t.Errorf("expected errors for %s", field)
+				}
+			}
+			if len(tc.errs) == 0 {
+				if errs := tc.announcement.ErrorMessages(); len(errs) > 0 {
+					t.Errorf("expected no errors, got %v", errs)
+				}
+			}
+		})
+	}
+}
+
+func TestAnnouncement_VisibleTo(t *testing.T) {
+	t.Parallel()
+
+	user := &User{}
+	admin := &User{SystemAdmin: true}
+	realm := &Realm{}
+	enxRealm := &Realm{EnableENExpress: true}
+
+	cases := []struct {
+		name     string
+		audience AnnouncementAudience
+		user     *User
+		realm    *Realm
+		exp      bool
+	}{
+		{"all_realm", AnnouncementAudienceAll, user, realm, true},
+		{"all_no_realm", AnnouncementAudienceAll, user, nil, false},
+		{"enx_realm", AnnouncementAudienceENX, user, enxRealm, true},
+		{"enx_non_enx_realm", AnnouncementAudienceENX, user, realm, false},
+		{"admins_admin", AnnouncementAudienceSystemAdmins, admin, nil, true},
+		{"admins_user", AnnouncementAudienceSystemAdmins, user, realm, false},
+		{"unknown", AnnouncementAudience("nope"), admin, enxRealm, false},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			a := &Announcement{Audience: tc.audience}
+			if got, want := a.VisibleTo(tc.user, tc.realm), tc.exp; got != want {
+				t.Errorf("expected %t to be %t", got, want)
+			}
+		})
+	}
+}
+
+func TestDatabase_Announcements(t *testing.T) {
+	t.Parallel()
+
+	db, _ := testDatabaseInstance.NewDatabase(t, nil)
+
+	user := &User{
+		Email: "announcements@example.com",
+		Name:  "Announcements",
+	}
+	if err := db.SaveUser(user, SystemTest); err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now().UTC()
+	ended := now.Add(-1 * time.Hour)
+
+	active := &Announcement{
+		Title:    "Active",
+		Body:     "This is active",
+		Audience: AnnouncementAudienceAll,
+		StartsAt: now.Add(-2 * time.Hour),
+	}
+	if err := db.SaveAnnouncement(active, SystemTest); err != nil {
+		t.Fatal(err)
+	}
+
+	expired := &Announcement{
+		Title:    "Expired",
+		Body:     "This has ended",
+		Audience: AnnouncementAudienceAll,
+		StartsAt: now.Add(-2 * time.Hour),
+		EndsAt:   &ended,
+	}
+	if err := db.SaveAnnouncement(expired, SystemTest); err != nil {
+		t.Fatal(err)
+	}
+
+	announcements, err := db.ListActiveAnnouncements(now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(announcements), 1; got != want {
+		t.Fatalf("expected %d announcements, got %d: %v", want, got, announcements)
+	}
+	if got, want := announcements[0].ID, active.ID; got != want {
+		t.Errorf("expected %d to be %d", got, want)
+	}
+
+	ids := []uint{active.ID, expired.ID}
+	read, err := db.ReadAnnouncementIDs(user, ids)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(read), 0; got != want {
+		t.Errorf("expected %d read, got %d", want, got)
+	}
+
+	// Marking read twice is not an error.
+	for i := 0; i < 2; i++ {
+		if err := db.MarkAnnouncementRead(active, user); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	read, err = db.ReadAnnouncementIDs(user, ids)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := read[active.ID]; !ok || len(read) != 1 {
+		t.Errorf("expected only %d to be read, got %v", active.ID, read)
+	}
+
+	count, err := db.CountAnnouncementReads(active)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := count, int64(1); got != want {
+		t.Errorf("expected %d reads, got %d", want, got)
+	}
+}
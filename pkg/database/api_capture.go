@@ -0,0 +1,154 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package database
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/google/exposure-notifications-verification-server/pkg/pagination"
+	"github.com/jinzhu/gorm"
+)
+
+const (
+	// MaxAPICaptureSampleRate is the largest percentage of an API key's
+	// requests that can be captured.
+	MaxAPICaptureSampleRate = 100
+
+	// MaxAPICaptureDuration is the longest request capture can be enabled on an
+	// API key at once, so capture is never left on by accident.
+	MaxAPICaptureDuration = 7 * 24 * time.Hour
+)
+
+// APICapture is a sampled capture of a request made with an API key that has
+// request capture enabled. Captures are redacted before they are saved:
+// credentials, codes, tokens, and personal information are removed and bodies
+// are truncated. They are purged after a short retention period.
+type APICapture struct {
+	// ID is the capture's ID.
+	ID uint `gorm:"primary_key;"`
+
+	// RealmID and AuthorizedAppID identify the API key that made the request.
+	RealmID         uint `gorm:"column:realm_id; type:integer; not null;"`
+	AuthorizedAppID uint `gorm:"column:authorized_app_id; type:integer; not null;"`
+
+	// RequestID is the request ID that was returned to the caller, so a capture
+	// can be matched to the partner's logs.
+	RequestID string `gorm:"column:request_id; type:text;"`
+
+	// Method and Path are the HTTP method and URL path of the request.
+	Method string `gorm:"column:method; type:text; not null;"`
+	Path   string `gorm:"column:path; type:text; not null;"`
+
+	// RequestHeaders and ResponseHeaders are the redacted headers, one
+	// "Name: value" per line.
+	RequestHeaders  string `gorm:"column:request_headers; type:text;"`
+	ResponseHeaders string `gorm:"column:response_headers; type:text;"`
+
+	// RequestBody and ResponseBody are the redacted, truncated bodies.
+	RequestBody  string `gorm:"column:request_body; type:text;"`
+	ResponseBody string `gorm:"column:response_body; type:text;"`
+
+	// ResponseStatus is the HTTP status code returned to the caller.
+	ResponseStatus int `gorm:"column:response_status; type:integer; not null; default:0;"`
+
+	// DurationMs is how long the request took to serve, in milliseconds.
+	DurationMs int64 `gorm:"column:duration_ms; type:integer; not null; default:0;"`
+
+	CreatedAt time.Time
+}
+
+// TableName sets the table name.
+func (APICapture) TableName() string {
+	return "api_captures"
+}
+
+// Succeeded returns true if the request received a 2xx response.
+func (c *APICapture) Succeeded() bool {
+	return c.ResponseStatus >= 200 && c.ResponseStatus <= 299
+}
+
+// SaveAPICapture saves the capture.
+func (db *Database) SaveAPICapture(c *APICapture) error {
+	if err := db.db.Save(c).Error; err != nil {
+		return fmt.Errorf("failed to save api capture: %w", err)
+	}
+	return nil
+}
+
+// ListAPICaptures lists the realm's request captures, newest first.
+func (r *Realm) ListAPICaptures(db *Database, p *pagination.PageParams, scopes ...Scope) ([]*APICapture, *pagination.Paginator, error) {
+	var captures []*APICapture
+	query := db.db.
+		Model(&APICapture{}).
+		Scopes(scopes...).
+		Where("api_captures.realm_id = ?", r.ID).
+		Order("api_captures.created_at DESC, api_captures.id DESC")
+
+	if p == nil {
+		p = new(pagination.PageParams)
+	}
+
+	paginator, err := Paginate(query, &captures, p.Page, p.Limit)
+	if err != nil {
+		if IsNotFound(err) {
+			return captures, nil, nil
+		}
+		return nil, nil, err
+	}
+	return captures, paginator, nil
+}
+
+// FindAPICapture finds the request capture by ID, scoped to the realm.
+func (r *Realm) FindAPICapture(db *Database, id interface{}) (*APICapture, error) {
+	var capture APICapture
+	if err := db.db.
+		Model(&APICapture{}).
+		Where("api_captures.realm_id = ?", r.ID).
+		Where("api_captures.id = ?", id).
+		First(&capture).
+		Error; err != nil {
+		return nil, err
+	}
+	return &capture, nil
+}
+
+// WithAPICaptureAuthorizedAppID returns a scope that filters captures to those
+// for the given API key. An ID of 0 does not filter.
+func WithAPICaptureAuthorizedAppID(id uint) Scope {
+	return func(db *gorm.DB) *gorm.DB {
+		if id == 0 {
+			return db
+		}
+		return db.Where("api_captures.authorized_app_id = ?", id)
+	}
+}
+
+// PurgeAPICaptures deletes request captures created before maxAge.
+func (db *Database) PurgeAPICaptures(maxAge time.Duration) (int64, error) {
+	if maxAge > 0 {
+		maxAge = -1 * maxAge
+	}
+	deleteBefore := time.Now().UTC().Add(maxAge)
+
+	result := db.db.
+		Unscoped().
+		Where("created_at < ?", deleteBefore).
+		Delete(&APICapture{})
+	if err := result.Error; err != nil {
+		return 0, fmt.Errorf("failed to purge api captures: %w", err)
+	}
+	return result.RowsAffected, nil
+}
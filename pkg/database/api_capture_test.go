@@ -0,0 +1,157 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package database
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAuthorizedApp_Capture(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now()
+	future := now.Add(time.Hour)
+	tooFar := now.Add(MaxAPICaptureDuration + time.Hour)
+
+	cases := []struct {
+		name      string
+		rate      uint
+		until     *time.Time
+		capturing bool
+		errKey    string
+	}{
+		{name: "off", rate: 0, until: &future},
+		{name: "on", rate: 10, until: &future, capturing: true},
+		{name: "rate_too_high", rate: MaxAPICaptureSampleRate + 1, until: &future, errKey: "captureSampleRate"},
+		{name: "missing_until", rate: 10, errKey: "captureUntil"},
+		{name: "until_too_far", rate: 10, until: &tooFar, errKey: "captureUntil"},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			app := &AuthorizedApp{
+				Name:              "capture",
+				CaptureSampleRate: tc.rate,
+				CaptureUntil:      tc.until,
+			}
+			_ = app.BeforeSave(nil)
+
+			errs := app.ErrorsFor(tc.errKey)
+			if tc.errKey != "" && len(errs) == 0 {
+				t.Errorf("expected errors for %s, got %v", tc.errKey, app.Errors())
+			}
+			if tc.errKey == "" && len(app.Errors()) > 0 {
+				t.Errorf("expected no errors, got %v", app.Errors())
+			}
+
+			if tc.errKey == "" {
+				if got, want := app.IsCapturing(now), tc.capturing; got != want {
+					t.Errorf("expected %t to be %t", got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestDatabase_APICaptures(t *testing.T) {
+	t.Parallel()
+
+	db, _ := testDatabaseInstance.NewDatabase(t, nil)
+
+	realm, err := db.FindRealm(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	app := &AuthorizedApp{
+		Name: "captures",
+	}
+	if _, err := realm.CreateAuthorizedApp(db, app, SystemTest); err != nil {
+		t.Fatal(err)
+	}
+
+	otherApp := &AuthorizedApp{
+		Name: "captures-other",
+	}
+	if _, err := realm.CreateAuthorizedApp(db, otherApp, SystemTest); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, a := range []*AuthorizedApp{app, app, otherApp} {
+		if err := db.SaveAPICapture(&APICapture{
+			RealmID:         realm.ID,
+			AuthorizedAppID: a.ID,
+			Method:          "POST",
+			Path:            "/api/verify",
+			ResponseStatus:  200,
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	captures, _, err := realm.ListAPICaptures(db, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(captures), 3; got != want {
+		t.Fatalf("expected %d captures to be %d", got, want)
+	}
+	if captures[0].ID < captures[1].ID {
+		t.Errorf("expected captures to be newest first")
+	}
+
+	captures, _, err = realm.ListAPICaptures(db, nil, WithAPICaptureAuthorizedAppID(app.ID))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(captures), 2; got != want {
+		t.Errorf("expected %d captures to be %d", got, want)
+	}
+
+	if _, err := realm.FindAPICapture(db, captures[0].ID); err != nil {
+		t.Fatal(err)
+	}
+
+	otherRealm := NewRealmWithDefaults("captures-other")
+	if err := db.SaveRealm(otherRealm, SystemTest); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := otherRealm.FindAPICapture(db, captures[0].ID); !IsNotFound(err) {
+		t.Errorf("expected not found from other realm, got %v", err)
+	}
+
+	// Nothing is old enough to purge.
+	purged, err := db.PurgeAPICaptures(time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := purged, int64(0); got != want {
+		t.Errorf("expected %d to be %d", got, want)
+	}
+
+	// Everything is old enough to purge.
+	purged, err = db.PurgeAPICaptures(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := purged, int64(3); got != want {
+		t.Errorf("expected %d to be %d", got, want)
+	}
+}
@@ -0,0 +1,272 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package database
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/google/exposure-notifications-verification-server/pkg/rbac"
+	"github.com/jinzhu/gorm"
+	"github.com/lib/pq"
+)
+
+// APIKeyInactivityGracePeriod is how long an API key that was flagged as
+// inactive may remain unused before it is automatically disabled.
+const APIKeyInactivityGracePeriod = 7 * 24 * time.Hour
+
+// apiKeyLastActivitySQL is the SQL expression for the last time an API key was
+// used, edited, or re-enabled.
+const apiKeyLastActivitySQL = "GREATEST(COALESCE(authorized_apps.last_used_at, authorized_apps.created_at), authorized_apps.updated_at)"
+
+// APIKeyInactivityAction is the action taken on an inactive API key.
+type APIKeyInactivityAction string
+
+const (
+	// APIKeyInactivityActionFlagged indicates the API key was flagged as inactive
+	// and will be disabled if it remains unused.
+	APIKeyInactivityActionFlagged APIKeyInactivityAction = "flagged"
+
+	// APIKeyInactivityActionDisabled indicates the API key was automatically
+	// disabled.
+	APIKeyInactivityActionDisabled APIKeyInactivityAction = "disabled"
+)
+
+// APIKeyInactivityEvent records that an API key was flagged or disabled for
+// inactivity, and whether the realm's admins have been notified.
+type APIKeyInactivityEvent struct {
+	ID uint `gorm:"primary_key;"`
+
+	// RealmID is the realm that owns the API key.
+	RealmID uint `gorm:"column:realm_id; type:integer; not null;"`
+
+	// AuthorizedAppID is the API key that was flagged or disabled.
+	AuthorizedAppID uint `gorm:"column:authorized_app_id; type:integer; not null;"`
+
+	// Name is the name of the API key at the time of the event.
+	Name string `gorm:"column:name; type:text; not null;"`
+
+	// Action is the action that was taken.
+	Action APIKeyInactivityAction `gorm:"column:action; type:text; not null;"`
+
+	// Recipients are the addresses that were sent the notification. It is empty
+	// if the realm had no admins when the notification was processed.
+	Recipients pq.StringArray `gorm:"column:recipients; type:text[];"`
+
+	// NotifiedAt is when the notification was processed. It is nil while the
+	// notification is pending.
+	NotifiedAt *time.Time `gorm:"column:notified_at; type:timestamp with time zone;"`
+
+	CreatedAt time.Time
+}
+
+// TableName sets the table name.
+func (APIKeyInactivityEvent) TableName() string {
+	return "api_key_inactivity_events"
+}
+
+// IsFlagged returns true if the event is for a key that was flagged.
+func (e *APIKeyInactivityEvent) IsFlagged() bool {
+	return e.Action == APIKeyInactivityActionFlagged
+}
+
+// IsDisabled returns true if the event is for a key that was disabled.
+func (e *APIKeyInactivityEvent) IsDisabled() bool {
+	return e.Action == APIKeyInactivityActionDisabled
+}
+
+// LastActiveAt returns the last time the API key was used, edited, or
+// re-enabled.
+func (a *AuthorizedApp) LastActiveAt() time.Time {
+	t := a.CreatedAt
+	if a.LastUsedAt != nil && a.LastUsedAt.After(t) {
+		t = *a.LastUsedAt
+	}
+	if a.UpdatedAt.After(t) {
+		t = a.UpdatedAt
+	}
+	return t
+}
+
+// IsFlaggedInactive returns true if the API key is enabled, was flagged as
+// inactive, and has not been active since it was flagged.
+func (a *AuthorizedApp) IsFlaggedInactive() bool {
+	return a.DeletedAt == nil &&
+		a.InactiveFlaggedAt != nil &&
+		!a.InactiveFlaggedAt.Before(a.LastActiveAt())
+}
+
+// InactiveDisableAt returns the time at which a flagged API key will be
+// disabled if it remains unused. It returns nil if the key is not flagged.
+func (a *AuthorizedApp) InactiveDisableAt() *time.Time {
+	if !a.IsFlaggedInactive() {
+		return nil
+	}
+	t := a.InactiveFlaggedAt.Add(APIKeyInactivityGracePeriod)
+	return &t
+}
+
+// IsDisabledForInactivity returns true if the API key is disabled and it was
+// disabled automatically for inactivity.
+func (a *AuthorizedApp) IsDisabledForInactivity() bool {
+	return a.DeletedAt != nil && a.DisabledForInactivityAt != nil
+}
+
+// FlagInactiveAuthorizedApps flags enabled API keys that have not been active
+// for their realm's configured inactivity period, and records a pending event
+// for each one. Keys that were already flagged are only flagged again if they
+// were active after the previous flag. It returns the number of keys flagged.
+func (db *Database) FlagInactiveAuthorizedApps() (int64, error) {
+	result := db.db.Exec(`
+		WITH flagged AS (
+			UPDATE authorized_apps
+			SET inactive_flagged_at = NOW()
+			FROM realms
+			WHERE authorized_apps.realm_id = realms.id
+				AND realms.api_key_inactivity_days > 0
+				AND authorized_apps.deleted_at IS NULL
+				AND `+apiKeyLastActivitySQL+` < NOW() - (realms.api_key_inactivity_days * INTERVAL '1 day')
+				AND (authorized_apps.inactive_flagged_at IS NULL OR authorized_apps.inactive_flagged_at < `+apiKeyLastActivitySQL+`)
+			RETURNING authorized_apps.id, authorized_apps.realm_id, authorized_apps.name
+		)
+		INSERT INTO api_key_inactivity_events (realm_id, authorized_app_id, name, action, created_at)
+		SELECT realm_id, id, name, ?, NOW()
+		FROM flagged`,
+		APIKeyInactivityActionFlagged)
+	if err := result.Error; err != nil {
+		return 0, fmt.Errorf("failed to flag inactive api keys: %w", err)
+	}
+	return result.RowsAffected, nil
+}
+
+// DisableInactiveAuthorizedApps disables API keys that were flagged as inactive
+// more than APIKeyInactivityGracePeriod ago and have not been active since. Each
+// key gets an audit entry attributed to the given actor and a pending event. It
+// returns the number of keys disabled.
+func (db *Database) DisableInactiveAuthorizedApps(actor Auditable) (int64, error) {
+	if actor == nil {
+		return 0, ErrMissingActor
+	}
+
+	flaggedBefore := time.Now().UTC().Add(-APIKeyInactivityGracePeriod)
+
+	var apps []*AuthorizedApp
+	if err := db.db.
+		Model(&AuthorizedApp{}).
+		Joins("JOIN realms ON realms.id = authorized_apps.realm_id").
+		Where("realms.api_key_inactivity_days > 0").
+		Where("authorized_apps.inactive_flagged_at IS NOT NULL").
+		Where("authorized_apps.inactive_flagged_at < ?", flaggedBefore).
+		Where(apiKeyLastActivitySQL + " < authorized_apps.inactive_flagged_at").
+		Find(&apps).
+		Error; err != nil && !IsNotFound(err) {
+		return 0, fmt.Errorf("failed to list inactive api keys: %w", err)
+	}
+
+	var count int64
+	for _, app := range apps {
+		if err := db.db.Transaction(func(tx *gorm.DB) error {
+			now := time.Now().UTC()
+			if err := tx.
+				Model(app).
+				UpdateColumns(map[string]interface{}{
+					"deleted_at":                 now,
+					"disabled_for_inactivity_at": now,
+				}).
+				Error; err != nil {
+				return fmt.Errorf("failed to disable api key: %w", err)
+			}
+
+			audit := BuildAuditEntry(actor, "disabled inactive API key", app, app.RealmID)
+			audit.Diff = boolDiff(true, false)
+			if err := tx.Save(audit).Error; err != nil {
+				return fmt.Errorf("failed to save audit: %w", err)
+			}
+
+			if err := tx.Create(&APIKeyInactivityEvent{
+				RealmID:         app.RealmID,
+				AuthorizedAppID: app.ID,
+				Name:            app.Name,
+				Action:          APIKeyInactivityActionDisabled,
+			}).Error; err != nil {
+				return fmt.Errorf("failed to record event: %w", err)
+			}
+			return nil
+		}); err != nil {
+			return count, fmt.Errorf("failed to disable api key %d: %w", app.ID, err)
+		}
+		count++
+	}
+	return count, nil
+}
+
+// ListPendingAPIKeyInactivityEvents lists the events whose realms have not yet
+// been notified, ordered by realm.
+func (db *Database) ListPendingAPIKeyInactivityEvents() ([]*APIKeyInactivityEvent, error) {
+	var events []*APIKeyInactivityEvent
+	if err := db.db.
+		Model(&APIKeyInactivityEvent{}).
+		Where("notified_at IS NULL").
+		Order("realm_id ASC, id ASC").
+		Find(&events).
+		Error; err != nil {
+		if IsNotFound(err) {
+			return events, nil
+		}
+		return nil, err
+	}
+	return events, nil
+}
+
+// MarkAPIKeyInactivityEventsNotified marks the events as notified, recording
+// the addresses that received the notification.
+func (db *Database) MarkAPIKeyInactivityEventsNotified(ids []uint, recipients []string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	if err := db.db.
+		Model(&APIKeyInactivityEvent{}).
+		Where("id IN (?)", ids).
+		UpdateColumns(map[string]interface{}{
+			"notified_at": time.Now().UTC(),
+			"recipients":  pq.StringArray(recipients),
+		}).
+		Error; err != nil {
+		return fmt.Errorf("failed to mark api key inactivity events notified: %w", err)
+	}
+	return nil
+}
+
+// ListUserEmailsWithPermission lists the email addresses of the realm's users
+// that have the given permission.
+func (r *Realm) ListUserEmailsWithPermission(db *Database, p rbac.Permission) ([]string, error) {
+	var emails []string
+	if err := db.db.
+		Model(&Membership{}).
+		Scopes(WithPermissionSearch(p)).
+		Joins("JOIN users ON users.id = memberships.user_id").
+		Where("memberships.realm_id = ?", r.ID).
+		Where("users.deleted_at IS NULL").
+		Order("users.email ASC").
+		Pluck("users.email", &emails).
+		Error; err != nil {
+		if IsNotFound(err) {
+			return emails, nil
+		}
+		return nil, fmt.Errorf("failed to list user emails: %w", err)
+	}
+	return emails, nil
+}
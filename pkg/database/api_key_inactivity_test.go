@@ -0,0 +1,185 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package database
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAuthorizedApp_IsFlaggedInactive(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now().UTC()
+	before := now.Add(-time.Hour)
+
+	cases := []struct {
+		name string
+		app  *AuthorizedApp
+		exp  bool
+	}{
+		{
+			name: "not_flagged",
+			app:  &AuthorizedApp{},
+			exp:  false,
+		},
+		{
+			name: "flagged",
+			app: func() *AuthorizedApp {
+				a := &AuthorizedApp{InactiveFlaggedAt: &now}
+				a.CreatedAt = before
+				a.UpdatedAt = before
+				return a
+			}(),
+			exp: true,
+		},
+		{
+			name: "used_after_flag",
+			app: func() *AuthorizedApp {
+				a := &AuthorizedApp{InactiveFlaggedAt: &before, LastUsedAt: &now}
+				a.CreatedAt = before
+				a.UpdatedAt = before
+				return a
+			}(),
+			exp: false,
+		},
+		{
+			name: "disabled",
+			app: func() *AuthorizedApp {
+				a := &AuthorizedApp{InactiveFlaggedAt: &now}
+				a.CreatedAt = before
+				a.UpdatedAt = before
+				a.DeletedAt = &now
+				return a
+			}(),
+			exp: false,
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			if got, want := tc.app.IsFlaggedInactive(), tc.exp; got != want {
+				t.Errorf("expected %t to be %t", got, want)
+			}
+		})
+	}
+}
+
+func TestDatabase_FlagDisableInactiveAuthorizedApps(t *testing.T) {
+	t.Parallel()
+
+	db, _ := testDatabaseInstance.NewDatabase(t, nil)
+
+	realm := NewRealmWithDefaults("inactivity")
+	realm.APIKeyInactivityDays = 30
+	if err := db.SaveRealm(realm, SystemTest); err != nil {
+		t.Fatal(err)
+	}
+
+	createApp := func(tb testing.TB, name string, lastActive time.Time) *AuthorizedApp {
+		tb.Helper()
+
+		app := &AuthorizedApp{Name: name, APIKeyType: APIKeyTypeAdmin}
+		if _, err := realm.CreateAuthorizedApp(db, app, SystemTest); err != nil {
+			tb.Fatal(err)
+		}
+		if err := db.db.
+			Model(app).
+			UpdateColumns(map[string]interface{}{
+				"created_at":   lastActive,
+				"updated_at":   lastActive,
+				"last_used_at": lastActive,
+			}).
+			Error; err != nil {
+			tb.Fatal(err)
+		}
+		return app
+	}
+
+	now := time.Now().UTC()
+	inactive := createApp(t, "inactive", now.Add(-40*24*time.Hour))
+	active := createApp(t, "active", now.Add(-1*time.Hour))
+
+	// Only the inactive key is flagged, and only once.
+	for i, want := range []int64{1, 0} {
+		n, err := db.FlagInactiveAuthorizedApps()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := n; got != want {
+			t.Errorf("run %d: expected %d flagged, got %d", i, want, got)
+		}
+	}
+
+	// Not disabled before the grace period elapses.
+	if n, err := db.DisableInactiveAuthorizedApps(SystemTest); err != nil {
+		t.Fatal(err)
+	} else if got, want := n, int64(0); got != want {
+		t.Errorf("expected %d disabled, got %d", want, got)
+	}
+
+	flaggedAt := now.Add(-APIKeyInactivityGracePeriod - time.Hour)
+	if err := db.db.
+		Model(&AuthorizedApp{}).
+		Where("id IN (?)", []uint{inactive.ID, active.ID}).
+		UpdateColumn("inactive_flagged_at", flaggedAt).
+		Error; err != nil {
+		t.Fatal(err)
+	}
+
+	// The active key was used after the flag, so only the inactive key is
+	// disabled.
+	if n, err := db.DisableInactiveAuthorizedApps(SystemTest); err != nil {
+		t.Fatal(err)
+	} else if got, want := n, int64(1); got != want {
+		t.Errorf("expected %d disabled, got %d", want, got)
+	}
+
+	got, err := realm.FindAuthorizedApp(db, inactive.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !got.IsDisabledForInactivity() {
+		t.Errorf("expected %#v to be disabled for inactivity", got)
+	}
+
+	events, err := db.ListPendingAPIKeyInactivityEvents()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(events), 2; got != want {
+		t.Fatalf("expected %d events, got %d", want, got)
+	}
+	if !events[0].IsFlagged() || !events[1].IsDisabled() {
+		t.Errorf("expected flagged then disabled events, got %#v", events)
+	}
+
+	ids := []uint{events[0].ID, events[1].ID}
+	if err := db.MarkAPIKeyInactivityEventsNotified(ids, []string{"admin@example.com"}); err != nil {
+		t.Fatal(err)
+	}
+
+	events, err = db.ListPendingAPIKeyInactivityEvents()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(events), 0; got != want {
+		t.Errorf("expected %d pending events, got %d", want, got)
+	}
+}
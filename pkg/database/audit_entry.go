@@ -15,6 +15,11 @@
 package database
 
 import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"strconv"
 	"time"
 
 	"github.com/google/exposure-notifications-verification-server/pkg/pagination"
@@ -93,18 +98,115 @@ func (db *Database) SaveAuditEntry(a *AuditEntry) error {
 }
 
 // PurgeAuditEntries will delete audit entries which were created longer than
-// maxAge ago.
+// maxAge ago. Realms with an audit retention period purge their entries after
+// that period instead. Entries for realms with a pending export are retained.
 func (db *Database) PurgeAuditEntries(maxAge time.Duration) (int64, error) {
-	if maxAge > 0 {
-		maxAge = -1 * maxAge
+	total, err := db.purgeWithRealmRetention(&AuditEntry{}, "created_at", "audit_entries.realm_id",
+		"audit_retention_days", maxAge, withoutPendingRealmExports)
+	if err != nil {
+		return total, fmt.Errorf("failed to purge audit entries: %w", err)
+	}
+	return total, nil
+}
+
+// ExportAudits returns up to limit audit entries which match the given
+// criteria, oldest first. Use WithAuditAfterID to page through the results.
+func (db *Database) ExportAudits(limit uint, scopes ...Scope) (AuditEntries, error) {
+	var entries AuditEntries
+	if err := db.db.
+		Model(&AuditEntry{}).
+		Scopes(scopes...).
+		Order("audit_entries.id ASC").
+		Limit(limit).
+		Find(&entries).
+		Error; err != nil {
+		if IsNotFound(err) {
+			return entries, nil
+		}
+		return nil, fmt.Errorf("failed to export audit entries: %w", err)
+	}
+	return entries, nil
+}
+
+// AuditEntries is a list of audit entries for export.
+type AuditEntries []*AuditEntry
+
+// exportAuditEntry is the exported representation of an audit entry.
+type exportAuditEntry struct {
+	ID            uint      `json:"id"`
+	RealmID       uint      `json:"realm_id"`
+	ActorID       string    `json:"actor_id"`
+	ActorDisplay  string    `json:"actor_display"`
+	Action        string    `json:"action"`
+	TargetID      string    `json:"target_id"`
+	TargetDisplay string    `json:"target_display"`
+	Diff          string    `json:"diff,omitempty"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// MarshalCSV returns bytes in CSV format.
+func (a AuditEntries) MarshalCSV() ([]byte, error) {
+	// Do nothing if there's no records
+	if len(a) == 0 {
+		return nil, nil
 	}
-	createdBefore := time.Now().UTC().Add(maxAge)
 
-	result := db.db.
-		Unscoped().
-		Where("created_at < ?", createdBefore).
-		Delete(&AuditEntry{})
-	return result.RowsAffected, result.Error
+	var b bytes.Buffer
+	w := csv.NewWriter(&b)
+
+	if err := w.Write([]string{
+		"id", "realm_id", "actor_id", "actor_display", "action",
+		"target_id", "target_display", "diff", "created_at",
+	}); err != nil {
+		return nil, fmt.Errorf("failed to write CSV header: %w", err)
+	}
+
+	for i, entry := range a {
+		if err := w.Write([]string{
+			strconv.FormatUint(uint64(entry.ID), 10),
+			strconv.FormatUint(uint64(entry.RealmID), 10),
+			entry.ActorID,
+			entry.ActorDisplay,
+			entry.Action,
+			entry.TargetID,
+			entry.TargetDisplay,
+			entry.Diff,
+			entry.CreatedAt.UTC().Format(time.RFC3339),
+		}); err != nil {
+			return nil, fmt.Errorf("failed to write CSV entry %d: %w", i, err)
+		}
+	}
+
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return nil, fmt.Errorf("failed to create CSV: %w", err)
+	}
+
+	return b.Bytes(), nil
+}
+
+// MarshalNDJSON returns the entries as newline-delimited JSON, one entry per
+// line.
+func (a AuditEntries) MarshalNDJSON() ([]byte, error) {
+	var b bytes.Buffer
+	enc := json.NewEncoder(&b)
+
+	for i, entry := range a {
+		if err := enc.Encode(&exportAuditEntry{
+			ID:            entry.ID,
+			RealmID:       entry.RealmID,
+			ActorID:       entry.ActorID,
+			ActorDisplay:  entry.ActorDisplay,
+			Action:        entry.Action,
+			TargetID:      entry.TargetID,
+			TargetDisplay: entry.TargetDisplay,
+			Diff:          entry.Diff,
+			CreatedAt:     entry.CreatedAt.UTC(),
+		}); err != nil {
+			return nil, fmt.Errorf("failed to encode entry %d: %w", i, err)
+		}
+	}
+	return b.Bytes(), nil
 }
 
 // ListAudits returns the list audit events which match the given criteria.
@@ -15,6 +15,7 @@
 package database
 
 import (
+	"fmt"
 	"testing"
 	"time"
 
@@ -128,3 +129,163 @@ func TestDatabase_ListAudits(t *testing.T) {
 		}
 	})
 }
+
+func TestDatabase_PurgeAuditEntries_realmRetention(t *testing.T) {
+	t.Parallel()
+
+	db, _ := testDatabaseInstance.NewDatabase(t, nil)
+
+	realm := NewRealmWithDefaults("retention")
+	realm.AuditRetentionDays = 30
+	if err := db.SaveRealm(realm, SystemTest); err != nil {
+		t.Fatal(err)
+	}
+
+	// Created 10 days ago: purged under the system default of 1 day, but kept
+	// by the realm's 30 day retention.
+	createdAt := time.Now().UTC().Add(-10 * 24 * time.Hour)
+	for _, realmID := range []uint{0, realm.ID} {
+		if err := db.SaveAuditEntry(&AuditEntry{
+			RealmID:       realmID,
+			ActorID:       "actor:1",
+			ActorDisplay:  "Actor",
+			Action:        "created",
+			TargetID:      "target:1",
+			TargetDisplay: "Target",
+			CreatedAt:     createdAt,
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	n, err := db.PurgeAuditEntries(24 * time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := n, int64(1); got != want {
+		t.Errorf("expected %d to purge, got %d", want, got)
+	}
+
+	// Saving the realm also audits, so only look at the test entries.
+	entries, err := db.ExportAudits(10, WithAuditActorID("actor:1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(entries), 1; got != want {
+		t.Fatalf("expected %d entries, got %d", want, got)
+	}
+	if got, want := entries[0].RealmID, realm.ID; got != want {
+		t.Errorf("expected %d to be %d", got, want)
+	}
+
+	// Shortening the realm's retention purges the entry.
+	realm.AuditRetentionDays = 7
+	if err := db.SaveRealm(realm, SystemTest); err != nil {
+		t.Fatal(err)
+	}
+
+	n, err = db.PurgeAuditEntries(24 * time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := n, int64(1); got != want {
+		t.Errorf("expected %d to purge, got %d", want, got)
+	}
+}
+
+func TestDatabase_ExportAudits(t *testing.T) {
+	t.Parallel()
+
+	db, _ := testDatabaseInstance.NewDatabase(t, nil)
+	for i, action := range []string{"created", "updated", "created", "deleted", "created"} {
+		if err := db.SaveAuditEntry(&AuditEntry{
+			RealmID:       1,
+			ActorID:       fmt.Sprintf("actor:%d", i%2),
+			ActorDisplay:  "Actor",
+			Action:        action,
+			TargetID:      "target:1",
+			TargetDisplay: "Target",
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	entries, err := db.ExportAudits(10, WithAuditAction("created"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(entries), 3; got != want {
+		t.Errorf("expected %d entries, got %d", want, got)
+	}
+
+	entries, err = db.ExportAudits(10, WithAuditActorID("actor:1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(entries), 2; got != want {
+		t.Errorf("expected %d entries, got %d", want, got)
+	}
+
+	// Pages through the entries, oldest first.
+	var ids []uint
+	var after uint
+	for {
+		page, err := db.ExportAudits(2, WithAuditAfterID(after))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(page) == 0 {
+			break
+		}
+		for _, entry := range page {
+			ids = append(ids, entry.ID)
+		}
+		after = page[len(page)-1].ID
+	}
+	if got, want := len(ids), 5; got != want {
+		t.Fatalf("expected %d entries, got %d", want, got)
+	}
+	for i := 1; i < len(ids); i++ {
+		if ids[i] <= ids[i-1] {
+			t.Errorf("expected ids to be ascending: %v", ids)
+		}
+	}
+}
+
+func TestAuditEntries_Marshal(t *testing.T) {
+	t.Parallel()
+
+	createdAt := time.Date(2022, 3, 4, 5, 6, 7, 0, time.UTC)
+	entries := AuditEntries{
+		{
+			ID:            1,
+			RealmID:       2,
+			ActorID:       "users:1",
+			ActorDisplay:  "Alice",
+			Action:        "updated realm",
+			TargetID:      "realms:2",
+			TargetDisplay: "Realm, Two",
+			CreatedAt:     createdAt,
+		},
+	}
+
+	b, err := entries.MarshalCSV()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expCSV := "id,realm_id,actor_id,actor_display,action,target_id,target_display,diff,created_at\n" +
+		"1,2,users:1,Alice,updated realm,realms:2,\"Realm, Two\",,2022-03-04T05:06:07Z\n"
+	if got, want := string(b), expCSV; got != want {
+		t.Errorf("expected %q to be %q", got, want)
+	}
+
+	b, err = entries.MarshalNDJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expNDJSON := `{"id":1,"realm_id":2,"actor_id":"users:1","actor_display":"Alice","action":"updated realm",` +
+		`"target_id":"realms:2","target_display":"Realm, Two","created_at":"2022-03-04T05:06:07Z"}` + "\n"
+	if got, want := string(b), expNDJSON; got != want {
+		t.Errorf("expected %q to be %q", got, want)
+	}
+}
@@ -0,0 +1,113 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package database
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/jinzhu/gorm"
+)
+
+const (
+	// auditTagName is the struct tag that controls how a field appears in audit
+	// entries.
+	auditTagName = "audit"
+
+	// auditTagRedact marks a field whose value must never be stored in an audit
+	// diff. Sensitive fields (webhook secrets, provider credentials, etc.) must
+	// carry `audit:"redact"`.
+	auditTagRedact = "redact"
+
+	// auditRedactedValue replaces the value of a redacted field in audit diffs.
+	auditRedactedValue = "[REDACTED]"
+)
+
+// redactedAuditActions are the audit actions whose diff holds the value of a
+// field tagged `audit:"redact"`, with the model and field they record. Entries
+// saved with these actions before redaction existed are scrubbed by a
+// migration.
+var redactedAuditActions = []struct {
+	action string
+	model  interface{}
+	field  string
+}{
+	{action: "updated API key callback secret", model: &AuthorizedApp{}, field: "CallbackSecret"},
+	{action: "updated user report webhook secret", model: &Realm{}, field: "UserReportWebhookSecret"},
+}
+
+// redactedAuditActionNames returns the names of the redactedAuditActions.
+func redactedAuditActionNames() []string {
+	names := make([]string, 0, len(redactedAuditActions))
+	for _, a := range redactedAuditActions {
+		names = append(names, a.action)
+	}
+	return names
+}
+
+// redactAuditEntries replaces every value in the diffs of the redacted audit
+// actions with the placeholder. Empty values are kept, so the diff still shows
+// whether the value was added or removed, and already redacted diffs are left
+// unchanged.
+func redactAuditEntries(tx *gorm.DB) error {
+	if err := tx.Exec(`
+		UPDATE audit_entries
+		SET diff = regexp_replace(diff, '^([-+]).+$', '\1' || ?, 'gn')
+		WHERE action IN (?) AND diff IS NOT NULL`,
+		auditRedactedValue, redactedAuditActionNames()).Error; err != nil {
+		return fmt.Errorf("failed to redact audit entries: %w", err)
+	}
+	return nil
+}
+
+// isAuditRedacted returns true if the named field on the given model is tagged
+// `audit:"redact"`. It panics if the field does not exist, since that is always
+// a programming error.
+func isAuditRedacted(model interface{}, field string) bool {
+	typ := reflect.TypeOf(model)
+	for typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+
+	f, ok := typ.FieldByName(field)
+	if !ok {
+		panic(fmt.Sprintf("%s has no field %q", typ.Name(), field))
+	}
+	return f.Tag.Get(auditTagName) == auditTagRedact
+}
+
+// auditFieldDiff builds a diff of the string values of the named field on the
+// model. If the field is tagged `audit:"redact"`, the values are replaced with
+// a placeholder so the diff only records whether the value was set.
+func auditFieldDiff(model interface{}, field, then, now string) string {
+	if isAuditRedacted(model, field) {
+		return redactedDiff(then, now)
+	}
+	return stringDiff(then, now)
+}
+
+// redactedDiff builds a diff of the string values without including either
+// value. Empty values remain empty so the diff shows when a value was added or
+// removed.
+func redactedDiff(then, now string) string {
+	return stringDiff(redactAuditValue(then), redactAuditValue(now))
+}
+
+func redactAuditValue(s string) string {
+	if s == "" {
+		return ""
+	}
+	return auditRedactedValue
+}
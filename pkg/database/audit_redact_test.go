@@ -0,0 +1,268 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package database
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/google/exposure-notifications-verification-server/pkg/pagination"
+)
+
+// sensitiveFieldSuffixes are the field name suffixes which indicate a field
+// holds a secret value.
+var sensitiveFieldSuffixes = []string{"Secret", "Password", "AuthToken", "Salt", "Hash", "APIKey"}
+
+// sensitiveFieldCacheSuffixes are the suffixes used by the encryption callbacks
+// and pointer columns, which hold the same secret value.
+var sensitiveFieldCacheSuffixes = []string{"PlaintextCache", "CiphertextCache", "Ptr"}
+
+// testRequireAuditRedaction fails if any field on the given models looks like
+// it holds a secret but is not tagged `audit:"redact"`. Add new models here so
+// new sensitive fields cannot be added without a redaction tag.
+func testRequireAuditRedaction(tb testing.TB, models ...interface{}) {
+	tb.Helper()
+
+	for _, model := range models {
+		typ := reflect.TypeOf(model)
+		for typ.Kind() == reflect.Ptr {
+			typ = typ.Elem()
+		}
+
+		for i := 0; i < typ.NumField(); i++ {
+			field := typ.Field(i)
+			if field.Anonymous {
+				continue
+			}
+
+			name := field.Name
+			for _, suffix := range sensitiveFieldCacheSuffixes {
+				name = strings.TrimSuffix(name, suffix)
+			}
+
+			for _, suffix := range sensitiveFieldSuffixes {
+				if !strings.HasSuffix(name, suffix) {
+					continue
+				}
+
+				if !isAuditRedacted(model, field.Name) {
+					tb.Errorf("%s.%s looks sensitive, but is not tagged `%s:%q`",
+						typ.Name(), field.Name, auditTagName, auditTagRedact)
+				}
+				break
+			}
+		}
+	}
+}
+
+func TestAuditRedactionTags(t *testing.T) {
+	t.Parallel()
+
+	testRequireAuditRedaction(t,
+		&Announcement{},
+		&AuthorizedApp{},
+		&EmailConfig{},
+		&IssuancePreset{},
+		&KeyServer{},
+		&Membership{},
+		&MobileApp{},
+		&Realm{},
+		&RealmStatCorrection{},
+		&RealmStatsAnnotation{},
+		&Secret{},
+		&SMSConfig{},
+		&SMSFromNumber{},
+		&Token{},
+		&User{},
+		&UserReport{},
+		&VerificationCode{},
+	)
+}
+
+func TestAuditFieldDiff(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name  string
+		field string
+		then  string
+		now   string
+		exp   string
+	}{
+		{
+			name:  "not_redacted",
+			field: "CallbackURL",
+			then:  "https://a.example.com",
+			now:   "https://b.example.com",
+			exp:   "-https://a.example.com\n+https://b.example.com\n",
+		},
+		{
+			name:  "redacted_added",
+			field: "CallbackSecret",
+			then:  "",
+			now:   "super-secret-value",
+			exp:   "-\n+[REDACTED]\n",
+		},
+		{
+			name:  "redacted_changed",
+			field: "CallbackSecret",
+			then:  "super-secret-value",
+			now:   "another-secret-value",
+			exp:   "-[REDACTED]\n+[REDACTED]\n",
+		},
+		{
+			name:  "redacted_removed",
+			field: "CallbackSecret",
+			then:  "super-secret-value",
+			now:   "",
+			exp:   "-[REDACTED]\n+\n",
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			if got, want := auditFieldDiff(&AuthorizedApp{}, tc.field, tc.then, tc.now), tc.exp; got != want {
+				t.Errorf("expected %q to be %q", got, want)
+			}
+		})
+	}
+}
+
+func TestAuditRedaction_Saved(t *testing.T) {
+	t.Parallel()
+
+	db, _ := testDatabaseInstance.NewDatabase(t, nil)
+	realm, err := db.FindRealm(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	authorizedApp := &AuthorizedApp{
+		Name: "Appy",
+	}
+	if _, err := realm.CreateAuthorizedApp(db, authorizedApp, SystemTest); err != nil {
+		t.Fatal(err)
+	}
+
+	appSecret := "this-is-a-long-app-secret"
+	authorizedApp.CallbackURL = "https://example.com/callback"
+	authorizedApp.CallbackSecret = appSecret
+	if err := db.SaveAuthorizedApp(authorizedApp, SystemTest); err != nil {
+		t.Fatalf("%v, %v", err, authorizedApp.errors)
+	}
+
+	realmSecret := "this-is-a-long-realm-secret"
+	realm.UserReportWebhookURL = "https://example.com/webhook"
+	realm.UserReportWebhookSecret = realmSecret
+	if err := db.SaveRealm(realm, SystemTest); err != nil {
+		t.Fatalf("%v, %v", err, realm.errors)
+	}
+
+	audits, _, err := db.ListAudits(&pagination.PageParams{Limit: 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	redacted := make(map[string]bool)
+	for _, audit := range audits {
+		if strings.Contains(audit.Diff, appSecret) || strings.Contains(audit.Diff, realmSecret) {
+			t.Errorf("expected %q (%s) to be redacted", audit.Diff, audit.Action)
+		}
+		if audit.Diff == "-\n+[REDACTED]\n" {
+			redacted[audit.Action] = true
+		}
+	}
+
+	for _, action := range []string{"updated API key callback secret", "updated user report webhook secret"} {
+		if !redacted[action] {
+			t.Errorf("expected redacted audit for %q", action)
+		}
+	}
+}
+
+func TestRedactedAuditActions(t *testing.T) {
+	t.Parallel()
+
+	for _, a := range redactedAuditActions {
+		if !isAuditRedacted(a.model, a.field) {
+			t.Errorf("%q records %T.%s, which is not tagged redact", a.action, a.model, a.field)
+		}
+	}
+}
+
+func TestRedactAuditEntries(t *testing.T) {
+	t.Parallel()
+
+	db, _ := testDatabaseInstance.NewDatabase(t, nil)
+
+	cases := []struct {
+		action string
+		diff   string
+		want   string
+	}{
+		{
+			action: "updated API key callback secret",
+			diff:   "-old-secret\n+new-secret\n",
+			want:   "-[REDACTED]\n+[REDACTED]\n",
+		},
+		{
+			action: "updated user report webhook secret",
+			diff:   "-\n+new-secret\n",
+			want:   "-\n+[REDACTED]\n",
+		},
+		{
+			action: "updated user report webhook secret",
+			diff:   "-[REDACTED]\n+\n",
+			want:   "-[REDACTED]\n+\n",
+		},
+		{
+			action: "updated API key callback URL",
+			diff:   "-https://example.com/a\n+https://example.com/b\n",
+			want:   "-https://example.com/a\n+https://example.com/b\n",
+		},
+	}
+
+	entries := make([]*AuditEntry, 0, len(cases))
+	for _, tc := range cases {
+		entry := BuildAuditEntry(SystemTest, tc.action, &AuthorizedApp{}, 1)
+		entry.Diff = tc.diff
+		if err := db.db.Save(entry).Error; err != nil {
+			t.Fatal(err)
+		}
+		entries = append(entries, entry)
+	}
+
+	// Redacting twice leaves the entries unchanged.
+	for i := 0; i < 2; i++ {
+		if err := redactAuditEntries(db.db); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for i, tc := range cases {
+		var got AuditEntry
+		if err := db.db.Where("id = ?", entries[i].ID).First(&got).Error; err != nil {
+			t.Fatal(err)
+		}
+		if got.Diff != tc.want {
+			t.Errorf("%s: expected %q to be %q", tc.action, got.Diff, tc.want)
+		}
+	}
+}
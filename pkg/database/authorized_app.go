@@ -21,19 +21,28 @@ import (
 	"crypto/sha512"
 	"encoding/base64"
 	"fmt"
+	"net/url"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/google/exposure-notifications-server/pkg/base64util"
-	"github.com/google/exposure-notifications-server/pkg/timeutils"
 	"github.com/google/exposure-notifications-verification-server/internal/project"
 	"github.com/google/exposure-notifications-verification-server/pkg/cache"
 	"github.com/jinzhu/gorm"
+	"github.com/lib/pq"
 )
 
 const (
 	apiKeyBytes = 64 // 64 bytes is 86 chararacters in non-padded base64.
+
+	// maxClientFingerprints is the maximum number of allowed user agents or app
+	// packages on an API key.
+	maxClientFingerprints = 20
+
+	// MaxAPIKeyRotationOverlap is the longest the previous API key remains valid
+	// after a rotation.
+	MaxAPIKeyRotationOverlap = 30 * 24 * time.Hour
 )
 
 type APIKeyType int
@@ -84,15 +93,67 @@ type AuthorizedApp struct {
 	APIKeyPreview string `gorm:"type:varchar(32)"`
 
 	// APIKey is the HMACed API key.
-	APIKey string `gorm:"type:varchar(512);unique_index"`
+	APIKey string `gorm:"type:varchar(512);unique_index" audit:"redact"`
 
 	// APIKeyType is the API key type.
 	APIKeyType APIKeyType `gorm:"column:api_key_type; type:integer; not null;"`
 
+	// PreviousAPIKey is the HMACed API key that was replaced by the last
+	// rotation. It remains valid until PreviousAPIKeyExpiresAt so callers can
+	// move to the new key without downtime. APIKeyRotatedAt is when the key was
+	// last rotated.
+	PreviousAPIKey          *string    `gorm:"column:previous_api_key; type:varchar(512);" audit:"redact"`
+	PreviousAPIKeyPreview   *string    `gorm:"column:previous_api_key_preview; type:varchar(32);"`
+	PreviousAPIKeyExpiresAt *time.Time `gorm:"column:previous_api_key_expires_at; type:timestamp with time zone;"`
+	APIKeyRotatedAt         *time.Time `gorm:"column:api_key_rotated_at; type:timestamp with time zone;"`
+
 	// LastUsedAt is the estimated time at which the API key was last used. For
 	// performance reasons, this not incremented on each use but rather in short
 	// buckets to avoid a write on every read.
 	LastUsedAt *time.Time `gorm:"column:last_used_at; type:timestamp with time zone;"`
+
+	// CallbackURL and CallbackSecret are used to notify the owner of the API key
+	// when a long-running operation, such as a batch issuance, completes. The
+	// secret is encrypted/decrypted automatically by callbacks.
+	CallbackURL                   string  `gorm:"-"`
+	CallbackURLPtr                *string `gorm:"column:callback_url; type:text;"`
+	CallbackSecret                string  `gorm:"-" json:"-" audit:"redact"`
+	CallbackSecretPtr             *string `gorm:"column:callback_secret; type:text;" json:"-" audit:"redact"`
+	CallbackSecretPlaintextCache  string  `gorm:"-" json:"-" audit:"redact"`
+	CallbackSecretCiphertextCache string  `gorm:"-" json:"-" audit:"redact"`
+
+	// UnclaimedCodesReport opts the API key in to a daily notification, sent to
+	// the callback URL, listing the codes it issued that have not been claimed.
+	UnclaimedCodesReport bool `gorm:"column:unclaimed_codes_report; type:bool; not null; default:false;"`
+
+	// AllowedUserAgents and AllowedAppPackages optionally pin a device API key
+	// to the expected clients. Requests must have a User-Agent that starts with
+	// one of the AllowedUserAgents and an app package header that is one of the
+	// AllowedAppPackages. An empty list allows any value. Mismatches are logged,
+	// and are rejected if EnforceClientFingerprint is true.
+	AllowedUserAgents        pq.StringArray `gorm:"column:allowed_user_agents; type:text[];"`
+	AllowedAppPackages       pq.StringArray `gorm:"column:allowed_app_packages; type:text[];"`
+	EnforceClientFingerprint bool           `gorm:"column:enforce_client_fingerprint; type:bool; not null; default:false;"`
+
+	// InactiveFlaggedAt is when the API key was last flagged as inactive under
+	// the realm's API key inactivity policy. DisabledForInactivityAt is when the
+	// key was automatically disabled for inactivity. Both are cleared when the
+	// key is re-enabled.
+	InactiveFlaggedAt       *time.Time `gorm:"column:inactive_flagged_at; type:timestamp with time zone;"`
+	DisabledForInactivityAt *time.Time `gorm:"column:disabled_for_inactivity_at; type:timestamp with time zone;"`
+
+	// CaptureSampleRate is the percentage of requests made with the API key
+	// that are captured for debugging, until CaptureUntil. Capture is off when
+	// the rate is 0 or CaptureUntil has passed.
+	CaptureSampleRate uint       `gorm:"column:capture_sample_rate; type:integer; not null; default:0;"`
+	CaptureUntil      *time.Time `gorm:"column:capture_until; type:timestamp with time zone;"`
+}
+
+// AfterFind runs after an authorized app is found.
+func (a *AuthorizedApp) AfterFind(tx *gorm.DB) error {
+	a.CallbackURL = stringValue(a.CallbackURLPtr)
+	a.CallbackSecret = stringValue(a.CallbackSecretPtr)
+	return nil
 }
 
 // BeforeSave runs validations. If there are errors, the save fails.
@@ -107,9 +168,145 @@ func (a *AuthorizedApp) BeforeSave(tx *gorm.DB) error {
 		a.AddError("type", "is invalid")
 	}
 
+	a.CallbackSecret = project.TrimSpace(a.CallbackSecret)
+	a.CallbackSecretPtr = stringPtr(a.CallbackSecret)
+
+	a.CallbackURL = project.TrimSpace(a.CallbackURL)
+	if v := a.CallbackURL; v != "" {
+		u, err := url.Parse(v)
+		if err != nil || u.Scheme != "https" {
+			a.AddError("callbackURL", "must be a valid https:// URL")
+		}
+
+		// A callback secret is required if a URL was provided.
+		if want := 12; len(a.CallbackSecret) < want {
+			a.AddError("callbackSecret", fmt.Sprintf("must be at least %d characters", want))
+		}
+	}
+	a.CallbackURLPtr = stringPtr(a.CallbackURL)
+
+	if a.UnclaimedCodesReport && a.CallbackURL == "" {
+		a.AddError("unclaimedCodesReport", "requires a callback URL")
+	}
+
+	a.AllowedUserAgents = ToLineList(strings.Join(a.AllowedUserAgents, "\n"))
+	a.AllowedAppPackages = ToLineList(strings.Join(a.AllowedAppPackages, "\n"))
+	if a.HasClientFingerprint() || a.EnforceClientFingerprint {
+		if !a.IsDeviceType() {
+			a.AddError("allowedUserAgents", "can only be set on device API keys")
+		}
+		if !a.HasClientFingerprint() {
+			a.AddError("allowedUserAgents", "must be set to enforce client fingerprints")
+		}
+	}
+	if len(a.AllowedUserAgents) > maxClientFingerprints {
+		a.AddError("allowedUserAgents", fmt.Sprintf("cannot have more than %d entries", maxClientFingerprints))
+	}
+	if len(a.AllowedAppPackages) > maxClientFingerprints {
+		a.AddError("allowedAppPackages", fmt.Sprintf("cannot have more than %d entries", maxClientFingerprints))
+	}
+
+	if a.CaptureSampleRate > MaxAPICaptureSampleRate {
+		a.AddError("captureSampleRate", fmt.Sprintf("must be between 0 and %d", MaxAPICaptureSampleRate))
+	}
+	if a.CaptureSampleRate == 0 {
+		a.CaptureUntil = nil
+	} else if a.CaptureUntil == nil {
+		a.AddError("captureUntil", "is required to capture requests")
+	} else if a.CaptureUntil.After(time.Now().Add(MaxAPICaptureDuration + time.Minute)) {
+		a.AddError("captureUntil", "must be less than 7 days from now")
+	}
+
 	return a.ErrorOrNil()
 }
 
+// IsCapturing returns true if requests made with the API key are being
+// captured at the given time.
+func (a *AuthorizedApp) IsCapturing(now time.Time) bool {
+	return a.CaptureSampleRate > 0 && a.CaptureUntil != nil && now.Before(*a.CaptureUntil)
+}
+
+// CaptureDescription describes the API key's request capture for audit
+// entries.
+func (a *AuthorizedApp) CaptureDescription() string {
+	if a.CaptureSampleRate == 0 || a.CaptureUntil == nil {
+		return "off"
+	}
+	return fmt.Sprintf("%d%% until %s", a.CaptureSampleRate, a.CaptureUntil.UTC().Format(time.RFC3339))
+}
+
+// HasPreviousAPIKey returns true if the API key was rotated and the previous
+// key is still valid.
+func (a *AuthorizedApp) HasPreviousAPIKey() bool {
+	return a.PreviousAPIKey != nil && a.PreviousAPIKeyExpiresAt != nil &&
+		time.Now().Before(*a.PreviousAPIKeyExpiresAt)
+}
+
+// PreviousAPIKeyPreviewString returns the preview of the previous API key, or
+// the empty string if there is none.
+func (a *AuthorizedApp) PreviousAPIKeyPreviewString() string {
+	return stringValue(a.PreviousAPIKeyPreview)
+}
+
+// HasClientFingerprint returns true if the API key is pinned to any user agents
+// or app packages.
+func (a *AuthorizedApp) HasClientFingerprint() bool {
+	return len(a.AllowedUserAgents) > 0 || len(a.AllowedAppPackages) > 0
+}
+
+// MatchesClientFingerprint returns true if the user agent and app package match
+// the API key's allowed clients. User agents match by prefix and app packages
+// match case-insensitively. It always returns true if the API key is not
+// pinned.
+func (a *AuthorizedApp) MatchesClientFingerprint(userAgent, appPackage string) bool {
+	if len(a.AllowedUserAgents) > 0 {
+		var ok bool
+		for _, prefix := range a.AllowedUserAgents {
+			if strings.HasPrefix(userAgent, prefix) {
+				ok = true
+				break
+			}
+		}
+		if !ok {
+			return false
+		}
+	}
+
+	if len(a.AllowedAppPackages) > 0 {
+		var ok bool
+		for _, pkg := range a.AllowedAppPackages {
+			if strings.EqualFold(appPackage, pkg) {
+				ok = true
+				break
+			}
+		}
+		if !ok {
+			return false
+		}
+	}
+
+	return true
+}
+
+// ToLineList converts the newline-separated list into an array of trimmed,
+// de-duplicated, non-blank strings, in their original order.
+func ToLineList(s string) []string {
+	var list []string
+	seen := make(map[string]struct{})
+	for _, line := range strings.Split(s, "\n") {
+		line = project.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		if _, ok := seen[line]; ok {
+			continue
+		}
+		seen[line] = struct{}{}
+		list = append(list, line)
+	}
+	return list
+}
+
 func (a *AuthorizedApp) IsAdminType() bool {
 	return a.APIKeyType == APIKeyTypeAdmin
 }
@@ -173,7 +370,7 @@ func (db *Database) FindAuthorizedAppByAPIKey(apiKey string) (*AuthorizedApp, er
 		// Find the API key that matches the constraints.
 		var app AuthorizedApp
 		if err := db.db.
-			Where("api_key IN (?)", hmacedKeys).
+			Scopes(withAPIKeyHMACs(hmacedKeys)).
 			Where("realm_id = ?", realmID).
 			First(&app).
 			Error; err != nil {
@@ -191,7 +388,7 @@ func (db *Database) FindAuthorizedAppByAPIKey(apiKey string) (*AuthorizedApp, er
 
 	var app AuthorizedApp
 	if err := db.db.
-		Or("api_key IN (?)", hmacedKeys).
+		Scopes(withAPIKeyHMACs(hmacedKeys)).
 		First(&app).
 		Error; err != nil {
 		return nil, err
@@ -199,10 +396,20 @@ func (db *Database) FindAuthorizedAppByAPIKey(apiKey string) (*AuthorizedApp, er
 	return &app, nil
 }
 
+// withAPIKeyHMACs is a scope that matches authorized apps whose current API
+// key, or previous API key within its rotation overlap, is one of the given
+// HMACs.
+func withAPIKeyHMACs(hmacedKeys []string) Scope {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where("api_key IN (?) OR (previous_api_key IN (?) AND previous_api_key_expires_at > ?)",
+			hmacedKeys, hmacedKeys, time.Now().UTC())
+	}
+}
+
 // Stats returns the usage statistics for this app. If no stats exist, it
 // returns an empty array.
 func (a *AuthorizedApp) Stats(db *Database) (AuthorizedAppStats, error) {
-	stop := timeutils.UTCMidnight(time.Now())
+	stop := db.realmStatsMidnight(a.RealmID, time.Now())
 	start := stop.Add(project.StatsDisplayDays * -24 * time.Hour)
 	if start.After(stop) {
 		return nil, ErrBadDateRange
@@ -300,6 +507,30 @@ func (db *Database) SaveAuthorizedApp(a *AuthorizedApp, actor Auditable) error {
 				audits = append(audits, audit)
 			}
 
+			if existing.CallbackURL != a.CallbackURL {
+				audit := BuildAuditEntry(actor, "updated API key callback URL", a, a.RealmID)
+				audit.Diff = stringDiff(existing.CallbackURL, a.CallbackURL)
+				audits = append(audits, audit)
+			}
+
+			if existing.CallbackSecret != a.CallbackSecret {
+				audit := BuildAuditEntry(actor, "updated API key callback secret", a, a.RealmID)
+				audit.Diff = auditFieldDiff(a, "CallbackSecret", existing.CallbackSecret, a.CallbackSecret)
+				audits = append(audits, audit)
+			}
+
+			if existing.CaptureDescription() != a.CaptureDescription() {
+				audit := BuildAuditEntry(actor, "updated API key request capture", a, a.RealmID)
+				audit.Diff = stringDiff(existing.CaptureDescription(), a.CaptureDescription())
+				audits = append(audits, audit)
+			}
+
+			if existing.UnclaimedCodesReport != a.UnclaimedCodesReport {
+				audit := BuildAuditEntry(actor, "updated API key unclaimed codes report", a, a.RealmID)
+				audit.Diff = boolDiff(existing.UnclaimedCodesReport, a.UnclaimedCodesReport)
+				audits = append(audits, audit)
+			}
+
 			if existing.DeletedAt != a.DeletedAt {
 				audit := BuildAuditEntry(actor, "updated API key enabled", a, a.RealmID)
 				audit.Diff = boolDiff(existing.DeletedAt == nil, a.DeletedAt == nil)
@@ -318,6 +549,139 @@ func (db *Database) SaveAuthorizedApp(a *AuthorizedApp, actor Auditable) error {
 	})
 }
 
+// RotateAuthorizedApp replaces the app's API key with a new one and returns the
+// new key. The previous key remains valid for the overlap, after which it is
+// rejected. An overlap of 0 invalidates the previous key immediately. If the
+// app was already rotated, the key from that earlier rotation stops working.
+func (db *Database) RotateAuthorizedApp(a *AuthorizedApp, overlap time.Duration, actor Auditable) (string, error) {
+	if a == nil {
+		return "", fmt.Errorf("provided API key is nil")
+	}
+
+	if actor == nil {
+		return "", ErrMissingActor
+	}
+
+	if a.DeletedAt != nil {
+		a.AddError("", "cannot rotate a disabled API key")
+		return "", ErrValidationFailed
+	}
+	if overlap < 0 || overlap > MaxAPIKeyRotationOverlap {
+		a.AddError("overlap", fmt.Sprintf("must be between 0 and %s", MaxAPIKeyRotationOverlap))
+		return "", ErrValidationFailed
+	}
+
+	fullAPIKey, hmacedKey, preview, err := db.generateAuthorizedAppAPIKey(a.RealmID)
+	if err != nil {
+		return "", err
+	}
+
+	if err := db.db.Transaction(func(tx *gorm.DB) error {
+		now := time.Now().UTC()
+		previousPreview := a.APIKeyPreview
+
+		if overlap > 0 {
+			expiresAt := now.Add(overlap)
+			a.PreviousAPIKey = stringPtr(a.APIKey)
+			a.PreviousAPIKeyPreview = stringPtr(a.APIKeyPreview)
+			a.PreviousAPIKeyExpiresAt = &expiresAt
+		} else {
+			a.PreviousAPIKey = nil
+			a.PreviousAPIKeyPreview = nil
+			a.PreviousAPIKeyExpiresAt = nil
+		}
+		a.APIKey = hmacedKey
+		a.APIKeyPreview = preview
+		a.APIKeyRotatedAt = &now
+
+		if err := tx.Unscoped().Save(a).Error; err != nil {
+			return fmt.Errorf("failed to save API key: %w", err)
+		}
+
+		audit := BuildAuditEntry(actor, "rotated API key", a, a.RealmID)
+		audit.Diff = stringDiff(previousPreview, a.APIKeyPreview)
+		if err := tx.Save(audit).Error; err != nil {
+			return fmt.Errorf("failed to save audits: %w", err)
+		}
+		return nil
+	}); err != nil {
+		return "", err
+	}
+	return fullAPIKey, nil
+}
+
+// ExpireRotatedAPIKeys removes previous API keys whose rotation overlap has
+// ended. Those keys are already rejected by FindAuthorizedAppByAPIKey; this
+// deletes their HMACs and audits the expiration. It returns the number of keys
+// expired.
+func (db *Database) ExpireRotatedAPIKeys() (int64, error) {
+	var expired int64
+	if err := db.db.Transaction(func(tx *gorm.DB) error {
+		var apps []*AuthorizedApp
+		if err := tx.
+			Unscoped().
+			Set("gorm:query_option", "FOR UPDATE SKIP LOCKED").
+			Model(&AuthorizedApp{}).
+			Where("previous_api_key IS NOT NULL AND previous_api_key_expires_at <= ?", time.Now().UTC()).
+			Find(&apps).
+			Error; err != nil {
+			if IsNotFound(err) {
+				return nil
+			}
+			return fmt.Errorf("failed to list rotated API keys: %w", err)
+		}
+
+		for _, app := range apps {
+			if err := tx.
+				Unscoped().
+				Model(&AuthorizedApp{}).
+				Where("id = ?", app.ID).
+				UpdateColumns(map[string]interface{}{
+					"previous_api_key":            gorm.Expr("NULL"),
+					"previous_api_key_preview":    gorm.Expr("NULL"),
+					"previous_api_key_expires_at": gorm.Expr("NULL"),
+				}).
+				Error; err != nil {
+				return fmt.Errorf("failed to expire previous API key for %d: %w", app.ID, err)
+			}
+
+			audit := BuildAuditEntry(System, "expired previous API key", app, app.RealmID)
+			audit.Diff = stringDiff(stringValue(app.PreviousAPIKeyPreview), "")
+			if err := tx.Save(audit).Error; err != nil {
+				return fmt.Errorf("failed to save audits: %w", err)
+			}
+			expired++
+		}
+		return nil
+	}); err != nil {
+		return 0, err
+	}
+	return expired, nil
+}
+
+// generateAuthorizedAppAPIKey generates a new API key for the realm. It
+// returns the full key to give to the caller, the HMAC to store, and the
+// preview to display.
+func (db *Database) generateAuthorizedAppAPIKey(realmID uint) (string, string, string, error) {
+	fullAPIKey, err := db.GenerateAPIKey(realmID)
+	if err != nil {
+		return "", "", "", fmt.Errorf("failed to generate API key: %w", err)
+	}
+
+	parts := strings.SplitN(fullAPIKey, ".", 3)
+	if len(parts) != 3 {
+		return "", "", "", fmt.Errorf("internal error, key is invalid")
+	}
+	apiKey := parts[0]
+
+	hmacedKey, err := db.GenerateAPIKeyHMAC(apiKey)
+	if err != nil {
+		return "", "", "", fmt.Errorf("failed to create hmac: %w", err)
+	}
+
+	return fullAPIKey, hmacedKey, apiKey[:6], nil
+}
+
 // GenerateAPIKeyHMAC generates the HMAC of the provided API key using the
 // latest HMAC key.
 func (db *Database) GenerateAPIKeyHMAC(apiKey string) (string, error) {
@@ -478,6 +842,89 @@ func (db *Database) VerifyAPIKeySignature(key string) (string, uint64, error) {
 	return apiKey, realmID, nil
 }
 
+// RevokeAuthorizedApp expires all unclaimed verification codes issued by the
+// authorized app between start and end, and disables the authorized app. This
+// happens in a single transaction and is intended for containing a leaked API
+// key. It returns the number of codes that were expired.
+func (db *Database) RevokeAuthorizedApp(a *AuthorizedApp, start, end time.Time, actor Auditable) (int64, error) {
+	if a == nil {
+		return 0, fmt.Errorf("provided API key is nil")
+	}
+
+	if actor == nil {
+		return 0, ErrMissingActor
+	}
+
+	if end.Before(start) {
+		return 0, ErrBadDateRange
+	}
+
+	var expired int64
+	if err := db.db.Transaction(func(tx *gorm.DB) error {
+		var existing AuthorizedApp
+		if err := tx.
+			Unscoped().
+			Set("gorm:query_option", "FOR UPDATE").
+			Model(&AuthorizedApp{}).
+			Where("id = ? AND realm_id = ?", a.ID, a.RealmID).
+			First(&existing).
+			Error; err != nil {
+			return fmt.Errorf("failed to get existing API key: %w", err)
+		}
+
+		now := time.Now().UTC()
+		result := tx.
+			Model(&VerificationCode{}).
+			Where("realm_id = ? AND issuing_app_id = ?", existing.RealmID, existing.ID).
+			Where("claimed = ?", false).
+			Where("created_at >= ? AND created_at <= ?", start, end).
+			Where("(expires_at > ? OR long_expires_at > ?)", now, now).
+			UpdateColumns(map[string]interface{}{
+				"expires_at":      now,
+				"long_expires_at": now,
+			})
+		if err := result.Error; err != nil {
+			return fmt.Errorf("failed to expire verification codes: %w", err)
+		}
+		expired = result.RowsAffected
+
+		audits := make([]*AuditEntry, 0, 2)
+
+		audit := BuildAuditEntry(actor, "expired verification codes issued by API key", &existing, existing.RealmID)
+		audit.Diff = stringDiff(
+			revokedCodesAuditState(start, end, expired),
+			revokedCodesAuditState(start, end, 0))
+		audits = append(audits, audit)
+
+		if existing.DeletedAt == nil {
+			if err := tx.
+				Unscoped().
+				Model(&existing).
+				UpdateColumn("deleted_at", now).
+				Error; err != nil {
+				return fmt.Errorf("failed to disable API key: %w", err)
+			}
+			existing.DeletedAt = &now
+
+			audit := BuildAuditEntry(actor, "updated API key enabled", &existing, existing.RealmID)
+			audit.Diff = boolDiff(true, false)
+			audits = append(audits, audit)
+		}
+
+		for _, audit := range audits {
+			if err := tx.Save(audit).Error; err != nil {
+				return fmt.Errorf("failed to save audits: %w", err)
+			}
+		}
+
+		*a = existing
+		return nil
+	}); err != nil {
+		return 0, err
+	}
+	return expired, nil
+}
+
 func (a *AuthorizedApp) AuditID() string {
 	return fmt.Sprintf("authorized_apps:%d", a.ID)
 }
@@ -487,13 +934,15 @@ func (a *AuthorizedApp) AuditDisplay() string {
 }
 
 // TouchLastUsedAt updates the timestamp at which the authorized app was last
-// used. It does not write an audit entry.
+// used. It does not write an audit entry. Only last_used_at is written, since
+// the app may be a stale cached copy that predates a key rotation.
 func (a *AuthorizedApp) TouchLastUsedAt(db *Database) error {
 	now := time.Now().UTC()
 	a.LastUsedAt = &now
 	if err := db.db.
 		Model(&AuthorizedApp{}).
-		Update(a).
+		Where("id = ?", a.ID).
+		UpdateColumn("last_used_at", now).
 		Error; err != nil {
 		return fmt.Errorf("failed to update last_used_at: %w", err)
 	}
@@ -514,3 +963,10 @@ func (db *Database) PurgeAuthorizedApps(maxAge time.Duration) (int64, error) {
 		Delete(&AuthorizedApp{})
 	return result.RowsAffected, result.Error
 }
+
+// revokedCodesAuditState describes the unexpired codes issued by an API key in
+// the time range, for the audit diff of revoking the key.
+func revokedCodesAuditState(start, end time.Time, unexpired int64) string {
+	return fmt.Sprintf("issued_after: %s\nissued_before: %s\nunexpired_codes: %d",
+		start.UTC().Format(time.RFC3339), end.UTC().Format(time.RFC3339), unexpired)
+}
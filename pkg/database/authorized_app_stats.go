@@ -51,6 +51,14 @@ type AuthorizedAppStat struct {
 	TokensClaimed uint `gorm:"column:tokens_claimed; type:integer; not null; default:0;"`
 	TokensInvalid uint `gorm:"column:tokens_invalid; type:integer; not null; default:0;"`
 
+	// CodesFailed, IssueRequests, and IssueLatencyMs track the API key's
+	// issuance SLO: the number of codes which failed to issue, the number of
+	// issue requests, and the total latency of those requests. These fields are
+	// only valid for "admin" API keys.
+	CodesFailed    uint   `gorm:"column:codes_failed; type:integer; not null; default:0;"`
+	IssueRequests  uint   `gorm:"column:issue_requests; type:integer; not null; default:0;"`
+	IssueLatencyMs uint64 `gorm:"column:issue_latency_ms; type:bigint; not null; default:0;"`
+
 	// Non-database fields, these are added via the stats lookup using the join
 	// table.
 	AuthorizedAppName string `gorm:"-"`
@@ -188,14 +196,8 @@ func (s *AuthorizedAppStats) UnmarshalJSON(b []byte) error {
 // PurgeAuthorizedAppStats will delete stats that were created longer than
 // maxAge ago.
 func (db *Database) PurgeAuthorizedAppStats(maxAge time.Duration) (int64, error) {
-	if maxAge > 0 {
-		maxAge = -1 * maxAge
-	}
-	createdBefore := time.Now().UTC().Add(maxAge)
-
-	result := db.db.
-		Unscoped().
-		Where("date < ?", createdBefore).
-		Delete(&AuthorizedAppStat{})
-	return result.RowsAffected, result.Error
+	return db.purgeWithRealmRetention(&AuthorizedAppStat{}, "authorized_app_stats.date", `COALESCE((
+			SELECT realm_id FROM authorized_apps
+			WHERE authorized_apps.id = authorized_app_stats.authorized_app_id), 0)`,
+		"stats_retention_days", maxAge)
 }
@@ -21,6 +21,7 @@ import (
 	"time"
 
 	"github.com/google/exposure-notifications-verification-server/pkg/pagination"
+	"github.com/google/go-cmp/cmp"
 	"github.com/jinzhu/gorm"
 )
 
@@ -117,6 +118,161 @@ func TestAuthorizedApp_BeforeSave(t *testing.T) {
 			}
 		}
 	})
+
+	t.Run("callback", func(t *testing.T) {
+		t.Parallel()
+
+		{
+			var m AuthorizedApp
+			m.CallbackURL = "http://example.com/callback"
+			_ = m.BeforeSave(&gorm.DB{})
+			if errs := m.ErrorsFor("callbackURL"); len(errs) < 1 {
+				t.Errorf("expected errors for callbackURL")
+			}
+			if errs := m.ErrorsFor("callbackSecret"); len(errs) < 1 {
+				t.Errorf("expected errors for callbackSecret")
+			}
+		}
+
+		{
+			var m AuthorizedApp
+			m.CallbackURL = "https://example.com/callback"
+			m.CallbackSecret = "this-is-a-long-secret"
+			_ = m.BeforeSave(&gorm.DB{})
+			if errs := m.ErrorsFor("callbackURL"); len(errs) != 0 {
+				t.Errorf("expected no errors for callbackURL, got %v", errs)
+			}
+			if errs := m.ErrorsFor("callbackSecret"); len(errs) != 0 {
+				t.Errorf("expected no errors for callbackSecret, got %v", errs)
+			}
+		}
+	})
+
+	t.Run("client_fingerprint", func(t *testing.T) {
+		t.Parallel()
+
+		{
+			var m AuthorizedApp
+			m.APIKeyType = APIKeyTypeAdmin
+			m.AllowedUserAgents = []string{"ExposureNotifications/"}
+			_ = m.BeforeSave(&gorm.DB{})
+			if errs := m.ErrorsFor("allowedUserAgents"); len(errs) < 1 {
+				t.Errorf("expected errors for allowedUserAgents")
+			}
+		}
+
+		{
+			var m AuthorizedApp
+			m.APIKeyType = APIKeyTypeDevice
+			m.EnforceClientFingerprint = true
+			_ = m.BeforeSave(&gorm.DB{})
+			if errs := m.ErrorsFor("allowedUserAgents"); len(errs) < 1 {
+				t.Errorf("expected errors for allowedUserAgents")
+			}
+		}
+
+		{
+			var m AuthorizedApp
+			m.APIKeyType = APIKeyTypeDevice
+			for i := 0; i <= maxClientFingerprints; i++ {
+				m.AllowedAppPackages = append(m.AllowedAppPackages, fmt.Sprintf("com.example.app%d", i))
+			}
+			_ = m.BeforeSave(&gorm.DB{})
+			if errs := m.ErrorsFor("allowedAppPackages"); len(errs) < 1 {
+				t.Errorf("expected errors for allowedAppPackages")
+			}
+		}
+
+		{
+			var m AuthorizedApp
+			m.APIKeyType = APIKeyTypeDevice
+			m.AllowedUserAgents = []string{" ExposureNotifications/ ", "", "ExposureNotifications/"}
+			m.EnforceClientFingerprint = true
+			_ = m.BeforeSave(&gorm.DB{})
+			if errs := m.ErrorsFor("allowedUserAgents"); len(errs) != 0 {
+				t.Errorf("expected no errors for allowedUserAgents, got %v", errs)
+			}
+			if got, want := len(m.AllowedUserAgents), 1; got != want {
+				t.Errorf("expected %d to be %d", got, want)
+			}
+		}
+	})
+}
+
+func TestAuthorizedApp_MatchesClientFingerprint(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name       string
+		app        *AuthorizedApp
+		userAgent  string
+		appPackage string
+		exp        bool
+	}{
+		{
+			name: "not_pinned",
+			app:  &AuthorizedApp{},
+			exp:  true,
+		},
+		{
+			name:      "user_agent_prefix",
+			app:       &AuthorizedApp{AllowedUserAgents: []string{"ExposureNotifications/"}},
+			userAgent: "ExposureNotifications/1.2 (Android 12)",
+			exp:       true,
+		},
+		{
+			name:      "user_agent_mismatch",
+			app:       &AuthorizedApp{AllowedUserAgents: []string{"ExposureNotifications/"}},
+			userAgent: "curl/7.79.1",
+			exp:       false,
+		},
+		{
+			name:       "app_package_case_insensitive",
+			app:        &AuthorizedApp{AllowedAppPackages: []string{"com.example.health"}},
+			appPackage: "com.example.Health",
+			exp:        true,
+		},
+		{
+			name: "app_package_missing",
+			app:  &AuthorizedApp{AllowedAppPackages: []string{"com.example.health"}},
+			exp:  false,
+		},
+		{
+			name: "both_must_match",
+			app: &AuthorizedApp{
+				AllowedUserAgents:  []string{"ExposureNotifications/"},
+				AllowedAppPackages: []string{"com.example.health"},
+			},
+			userAgent:  "ExposureNotifications/1.2",
+			appPackage: "com.example.other",
+			exp:        false,
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			if got, want := tc.app.MatchesClientFingerprint(tc.userAgent, tc.appPackage), tc.exp; got != want {
+				t.Errorf("expected %t to be %t", got, want)
+			}
+		})
+	}
+}
+
+func TestToLineList(t *testing.T) {
+	t.Parallel()
+
+	got := ToLineList("b\n a \r\n\n\nb\nc")
+	if diff := cmp.Diff([]string{"b", "a", "c"}, got); diff != "" {
+		t.Errorf("mismatch (-want, +got):\n%s", diff)
+	}
+
+	if got := ToLineList(""); len(got) != 0 {
+		t.Errorf("expected %v to be empty", got)
+	}
 }
 
 func TestAuthorizedApp_Realm(t *testing.T) {
@@ -226,6 +382,109 @@ func TestDatabase_CreateFindAPIKey(t *testing.T) {
 	}
 }
 
+func TestDatabase_RotateAuthorizedApp(t *testing.T) {
+	t.Parallel()
+
+	db, _ := testDatabaseInstance.NewDatabase(t, nil)
+
+	realm := NewRealmWithDefaults("foo")
+	if err := db.SaveRealm(realm, SystemTest); err != nil {
+		t.Fatal(err)
+	}
+
+	authApp := &AuthorizedApp{
+		Name:       "University System Health Org",
+		APIKeyType: APIKeyTypeAdmin,
+	}
+	oldKey, err := realm.CreateAuthorizedApp(db, authApp, SystemTest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	oldPreview := authApp.APIKeyPreview
+
+	if _, err := db.RotateAuthorizedApp(authApp, MaxAPIKeyRotationOverlap+time.Hour, SystemTest); !IsValidationError(err) {
+		t.Errorf("expected validation error, got %v", err)
+	}
+
+	newKey, err := db.RotateAuthorizedApp(authApp, time.Hour, SystemTest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if newKey == oldKey {
+		t.Fatalf("expected a new API key")
+	}
+	if !authApp.HasPreviousAPIKey() {
+		t.Errorf("expected previous API key to be valid")
+	}
+	if got, want := authApp.PreviousAPIKeyPreviewString(), oldPreview; got != want {
+		t.Errorf("expected %q to be %q", got, want)
+	}
+
+	// Both keys are valid during the overlap.
+	for _, key := range []string{oldKey, newKey} {
+		got, err := db.FindAuthorizedAppByAPIKey(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := got.ID, authApp.ID; got != want {
+			t.Errorf("expected %d to be %d", got, want)
+		}
+	}
+
+	// Nothing has expired yet.
+	expired, err := db.ExpireRotatedAPIKeys()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := expired, int64(0); got != want {
+		t.Errorf("expected %d to be %d", got, want)
+	}
+
+	// End the overlap.
+	if err := db.db.
+		Model(&AuthorizedApp{}).
+		Where("id = ?", authApp.ID).
+		UpdateColumn("previous_api_key_expires_at", time.Now().UTC().Add(-time.Minute)).
+		Error; err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := db.FindAuthorizedAppByAPIKey(oldKey); !IsNotFound(err) {
+		t.Errorf("expected old API key to be rejected, got %v", err)
+	}
+	if _, err := db.FindAuthorizedAppByAPIKey(newKey); err != nil {
+		t.Errorf("expected new API key to be valid, got %v", err)
+	}
+
+	expired, err = db.ExpireRotatedAPIKeys()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := expired, int64(1); got != want {
+		t.Errorf("expected %d to be %d", got, want)
+	}
+
+	got, err := db.FindAuthorizedApp(authApp.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.PreviousAPIKey != nil || got.PreviousAPIKeyExpiresAt != nil {
+		t.Errorf("expected previous API key to be cleared")
+	}
+
+	// A rotation without overlap invalidates the key immediately.
+	newestKey, err := db.RotateAuthorizedApp(got, 0, SystemTest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.FindAuthorizedAppByAPIKey(newKey); !IsNotFound(err) {
+		t.Errorf("expected rotated API key to be rejected, got %v", err)
+	}
+	if _, err := db.FindAuthorizedAppByAPIKey(newestKey); err != nil {
+		t.Errorf("expected newest API key to be valid, got %v", err)
+	}
+}
+
 func TestDatabase_GenerateAPIKey(t *testing.T) {
 	t.Parallel()
 
@@ -350,3 +609,96 @@ func TestAuthorizedApp_Audits(t *testing.T) {
 		t.Errorf("expected %d audits, got %d: %v", want, got, audits)
 	}
 }
+
+func TestDatabase_RevokeAuthorizedApp(t *testing.T) {
+	t.Parallel()
+
+	db, _ := testDatabaseInstance.NewDatabase(t, nil)
+	realm, err := db.FindRealm(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	authorizedApp := &AuthorizedApp{
+		Name:       "Appy",
+		APIKeyType: APIKeyTypeAdmin,
+	}
+	if _, err := realm.CreateAuthorizedApp(db, authorizedApp, SystemTest); err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now().UTC()
+	codes := []*VerificationCode{
+		{
+			// Unclaimed, issued by the app - should be expired.
+			Code:         "10000001",
+			LongCode:     "10000001ABC",
+			IssuingAppID: authorizedApp.ID,
+		},
+		{
+			// Claimed, issued by the app - should not be changed.
+			Code:         "10000002",
+			LongCode:     "10000002ABC",
+			Claimed:      true,
+			IssuingAppID: authorizedApp.ID,
+		},
+		{
+			// Unclaimed, not issued by the app - should not be changed.
+			Code:     "10000003",
+			LongCode: "10000003ABC",
+		},
+	}
+	for _, vc := range codes {
+		vc.RealmID = realm.ID
+		vc.TestType = "confirmed"
+		vc.ExpiresAt = now.Add(time.Hour)
+		vc.LongExpiresAt = now.Add(time.Hour)
+		if err := realm.SaveVerificationCode(db, vc); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	t.Run("bad_range", func(t *testing.T) {
+		t.Parallel()
+
+		if _, err := db.RevokeAuthorizedApp(authorizedApp, now, now.Add(-time.Hour), SystemTest); err != ErrBadDateRange {
+			t.Errorf("expected %v to be %v", err, ErrBadDateRange)
+		}
+	})
+
+	start, end := now.Add(-time.Hour), now.Add(time.Hour)
+	expired, err := db.RevokeAuthorizedApp(authorizedApp, start, end, SystemTest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := expired, int64(1); got != want {
+		t.Errorf("expected %d to be %d", got, want)
+	}
+
+	var audit AuditEntry
+	if err := db.db.
+		Where("action = ?", "expired verification codes issued by API key").
+		Where("target_id = ?", authorizedApp.AuditID()).
+		First(&audit).
+		Error; err != nil {
+		t.Fatal(err)
+	}
+	if got, want := audit.Diff, stringDiff(
+		revokedCodesAuditState(start, end, 1),
+		revokedCodesAuditState(start, end, 0)); got != want {
+		t.Errorf("expected %q to be %q", got, want)
+	}
+	if authorizedApp.DeletedAt == nil {
+		t.Errorf("expected API key to be disabled")
+	}
+
+	for i, want := range []bool{true, false, false} {
+		vc, err := realm.FindVerificationCodeByUUID(db, codes[i].UUID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := !vc.ExpiresAt.After(time.Now().UTC()); got != want {
+			t.Errorf("code %d: expected expired to be %t", i, want)
+		}
+	}
+}
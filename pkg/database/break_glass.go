@@ -0,0 +1,534 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package database
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/exposure-notifications-verification-server/internal/project"
+	"github.com/jinzhu/gorm"
+)
+
+const (
+	// BreakGlassRequestTTL is how long a break-glass activation request can be
+	// approved. Requests that are not approved in time lapse.
+	BreakGlassRequestTTL = 1 * time.Hour
+
+	// BreakGlassMaxDuration is the maximum length of a break-glass activation.
+	BreakGlassMaxDuration = 24 * time.Hour
+)
+
+const (
+	// BreakGlassStatusPending, BreakGlassStatusLapsed, BreakGlassStatusActive,
+	// BreakGlassStatusExpired, and BreakGlassStatusRevoked are the states of a
+	// break-glass activation.
+	BreakGlassStatusPending = "pending"
+	BreakGlassStatusLapsed  = "lapsed"
+	BreakGlassStatusActive  = "active"
+	BreakGlassStatusExpired = "expired"
+	BreakGlassStatusRevoked = "revoked"
+)
+
+var (
+	// ErrNotBreakGlassAccount is returned when activating a user that is not a
+	// break-glass account.
+	ErrNotBreakGlassAccount = errors.New("user is not a break-glass account")
+
+	// ErrBreakGlassOperator is returned when the actor may not request or
+	// approve a break-glass activation. Only system admins that are not
+	// break-glass accounts may do so, and never for themselves.
+	ErrBreakGlassOperator = errors.New("only other system admins can request or approve break-glass activations")
+
+	// ErrBreakGlassSameApprover is returned when the system admin who requested
+	// an activation tries to approve it.
+	ErrBreakGlassSameApprover = errors.New("break-glass activations must be approved by a second system admin")
+
+	// ErrBreakGlassOpen is returned when requesting an activation for an account
+	// that already has a pending or active activation.
+	ErrBreakGlassOpen = errors.New("break-glass account already has a pending or active activation")
+
+	// ErrBreakGlassNotPending is returned when approving an activation that is
+	// not pending.
+	ErrBreakGlassNotPending = errors.New("break-glass activation is not pending approval")
+
+	// ErrBreakGlassNotOpen is returned when revoking an activation that is not
+	// pending or active.
+	ErrBreakGlassNotOpen = errors.New("break-glass activation is not pending or active")
+
+	// ErrBreakGlassDuration is returned when approving an activation for longer
+	// than BreakGlassMaxDuration.
+	ErrBreakGlassDuration = fmt.Errorf("break-glass activations must last between 1 minute and %s", BreakGlassMaxDuration)
+)
+
+// BreakGlassActivation is a request to temporarily make a break-glass account a
+// system admin. It is requested by one system admin, approved by a second,
+// and expires automatically. Every step is audited and recorded on the system
+// event timeline.
+type BreakGlassActivation struct {
+	Errorable
+
+	// ID is the activation's ID.
+	ID uint `gorm:"primary_key;"`
+
+	// UserID is the break-glass account being activated.
+	UserID uint `gorm:"column:user_id; type:integer; not null;"`
+
+	// RequestedByID is the system admin that requested the activation, and
+	// Reason is why.
+	RequestedByID uint   `gorm:"column:requested_by_id; type:integer; not null;"`
+	Reason        string `gorm:"column:reason; type:text; not null;"`
+
+	// ApprovedByID is the second system admin that approved the activation.
+	// ExpiresAt is when the approved activation ends.
+	ApprovedByID *uint      `gorm:"column:approved_by_id; type:integer;"`
+	ApprovedAt   *time.Time `gorm:"column:approved_at; type:timestamp with time zone;"`
+	ExpiresAt    *time.Time `gorm:"column:expires_at; type:timestamp with time zone;"`
+
+	// RevokedByID is the system admin that ended or cancelled the activation
+	// early.
+	RevokedByID *uint      `gorm:"column:revoked_by_id; type:integer;"`
+	RevokedAt   *time.Time `gorm:"column:revoked_at; type:timestamp with time zone;"`
+
+	// ExpiryRecordedAt is when the expiration of the activation was audited.
+	ExpiryRecordedAt *time.Time `gorm:"column:expiry_recorded_at; type:timestamp with time zone;"`
+
+	// RequestedBy, ApprovedBy, and RevokedBy are populated by
+	// LoadBreakGlassOperators for display. They are never saved.
+	RequestedBy *User `gorm:"-"`
+	ApprovedBy  *User `gorm:"-"`
+	RevokedBy   *User `gorm:"-"`
+
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// TableName sets the table name.
+func (BreakGlassActivation) TableName() string {
+	return "break_glass_activations"
+}
+
+// BeforeSave runs validations. If there are errors, the save fails.
+func (a *BreakGlassActivation) BeforeSave(tx *gorm.DB) error {
+	if a.UserID == 0 {
+		a.AddError("userID", "is required")
+	}
+	if a.RequestedByID == 0 {
+		a.AddError("requestedByID", "is required")
+	}
+
+	a.Reason = project.TrimSpace(a.Reason)
+	if a.Reason == "" {
+		a.AddError("reason", "cannot be blank")
+	}
+
+	return a.ErrorOrNil()
+}
+
+// Status returns the state of the activation.
+func (a *BreakGlassActivation) Status() string {
+	now := time.Now()
+	switch {
+	case a.RevokedAt != nil:
+		return BreakGlassStatusRevoked
+	case a.ApprovedAt == nil && now.Sub(a.CreatedAt) >= BreakGlassRequestTTL:
+		return BreakGlassStatusLapsed
+	case a.ApprovedAt == nil:
+		return BreakGlassStatusPending
+	case a.ExpiresAt != nil && now.Before(*a.ExpiresAt):
+		return BreakGlassStatusActive
+	default:
+		return BreakGlassStatusExpired
+	}
+}
+
+// IsOpen returns true if the activation is pending or active.
+func (a *BreakGlassActivation) IsOpen() bool {
+	switch a.Status() {
+	case BreakGlassStatusPending, BreakGlassStatusActive:
+		return true
+	default:
+		return false
+	}
+}
+
+// AuditID is how the activation is stored in the audit entry.
+func (a *BreakGlassActivation) AuditID() string {
+	return fmt.Sprintf("break_glass_activations:%d", a.ID)
+}
+
+// AuditDisplay is how the activation will be displayed in audit entries.
+func (a *BreakGlassActivation) AuditDisplay() string {
+	return fmt.Sprintf("break-glass activation %d", a.ID)
+}
+
+// ListBreakGlassActivations lists the activations of the break-glass account,
+// newest first.
+func (u *User) ListBreakGlassActivations(db *Database) ([]*BreakGlassActivation, error) {
+	var activations []*BreakGlassActivation
+	if err := db.db.
+		Model(&BreakGlassActivation{}).
+		Where("user_id = ?", u.ID).
+		Order("created_at DESC, id DESC").
+		Find(&activations).
+		Error; err != nil {
+		if IsNotFound(err) {
+			return activations, nil
+		}
+		return nil, err
+	}
+	return activations, nil
+}
+
+// FindBreakGlassActivation finds the break-glass account's activation by ID.
+func (u *User) FindBreakGlassActivation(db *Database, id interface{}) (*BreakGlassActivation, error) {
+	var activation BreakGlassActivation
+	if err := db.db.
+		Model(&BreakGlassActivation{}).
+		Where("user_id = ? AND id = ?", u.ID, id).
+		First(&activation).
+		Error; err != nil {
+		return nil, err
+	}
+	return &activation, nil
+}
+
+// LoadBreakGlassOperators populates the system admins that requested,
+// approved, or revoked the activations.
+func (db *Database) LoadBreakGlassOperators(activations []*BreakGlassActivation) error {
+	idsMap := make(map[uint]struct{}, len(activations))
+	for _, a := range activations {
+		idsMap[a.RequestedByID] = struct{}{}
+		if a.ApprovedByID != nil {
+			idsMap[*a.ApprovedByID] = struct{}{}
+		}
+		if a.RevokedByID != nil {
+			idsMap[*a.RevokedByID] = struct{}{}
+		}
+	}
+
+	if len(idsMap) == 0 {
+		return nil
+	}
+
+	ids := make([]uint, 0, len(idsMap))
+	for id := range idsMap {
+		ids = append(ids, id)
+	}
+
+	var users []*User
+	if err := db.db.
+		Model(&User{}).
+		Where("id IN (?)", ids).
+		Find(&users).
+		Error; err != nil && !IsNotFound(err) {
+		return err
+	}
+
+	operators := make(map[uint]*User, len(users))
+	for _, u := range users {
+		operators[u.ID] = u
+	}
+
+	for _, a := range activations {
+		a.RequestedBy = operators[a.RequestedByID]
+		if a.ApprovedByID != nil {
+			a.ApprovedBy = operators[*a.ApprovedByID]
+		}
+		if a.RevokedByID != nil {
+			a.RevokedBy = operators[*a.RevokedByID]
+		}
+	}
+	return nil
+}
+
+// RequestBreakGlassActivation records a request by the actor to activate the
+// break-glass account. The request must be approved by a second system admin
+// within BreakGlassRequestTTL.
+func (db *Database) RequestBreakGlassActivation(user *User, reason string, actor *User) (*BreakGlassActivation, error) {
+	if actor == nil {
+		return nil, ErrMissingActor
+	}
+	if !user.BreakGlass {
+		return nil, ErrNotBreakGlassAccount
+	}
+	if !canOperateBreakGlass(user, actor) {
+		return nil, ErrBreakGlassOperator
+	}
+
+	activation := &BreakGlassActivation{
+		UserID:        user.ID,
+		RequestedByID: actor.ID,
+		Reason:        reason,
+	}
+
+	if err := db.db.Transaction(func(tx *gorm.DB) error {
+		// Lock the account so concurrent requests cannot both be opened.
+		if err := tx.
+			Set("gorm:query_option", "FOR UPDATE").
+			Model(&User{}).
+			Where("id = ?", user.ID).
+			First(&User{}).
+			Error; err != nil {
+			return fmt.Errorf("failed to lock break-glass account: %w", err)
+		}
+
+		var existing []*BreakGlassActivation
+		if err := tx.
+			Model(&BreakGlassActivation{}).
+			Where("user_id = ? AND revoked_at IS NULL", user.ID).
+			Where("created_at > ?", time.Now().UTC().Add(-BreakGlassRequestTTL-BreakGlassMaxDuration)).
+			Find(&existing).
+			Error; err != nil && !IsNotFound(err) {
+			return fmt.Errorf("failed to list activations: %w", err)
+		}
+		for _, e := range existing {
+			if e.IsOpen() {
+				return ErrBreakGlassOpen
+			}
+		}
+
+		if err := tx.Create(activation).Error; err != nil {
+			return err
+		}
+
+		audit := BuildAuditEntry(actor, "requested break-glass activation", user, 0)
+		audit.Diff = stringDiff("", activation.Reason)
+		if err := tx.Save(audit).Error; err != nil {
+			return fmt.Errorf("failed to save audits: %w", err)
+		}
+
+		return recordSystemEvent(tx, &SystemEvent{
+			Kind:    SystemEventBreakGlass,
+			Source:  user.Email,
+			Message: fmt.Sprintf("activation requested by %s: %s", actor.AuditDisplay(), activation.Reason),
+		})
+	}); err != nil {
+		return nil, err
+	}
+	return activation, nil
+}
+
+// ApproveBreakGlassActivation approves the pending activation, making the
+// break-glass account a system admin for the given duration. The actor must be
+// a different system admin than the one that requested the activation.
+func (db *Database) ApproveBreakGlassActivation(activation *BreakGlassActivation, duration time.Duration, actor *User) error {
+	if actor == nil {
+		return ErrMissingActor
+	}
+	if duration < time.Minute || duration > BreakGlassMaxDuration {
+		return ErrBreakGlassDuration
+	}
+
+	return db.db.Transaction(func(tx *gorm.DB) error {
+		user, err := lockBreakGlassActivation(tx, activation)
+		if err != nil {
+			return err
+		}
+
+		if !canOperateBreakGlass(user, actor) {
+			return ErrBreakGlassOperator
+		}
+		if actor.ID == activation.RequestedByID {
+			return ErrBreakGlassSameApprover
+		}
+		if activation.Status() != BreakGlassStatusPending {
+			return ErrBreakGlassNotPending
+		}
+
+		now := time.Now().UTC()
+		expiresAt := now.Add(duration)
+		activation.ApprovedByID = &actor.ID
+		activation.ApprovedAt = &now
+		activation.ExpiresAt = &expiresAt
+		if err := tx.Save(activation).Error; err != nil {
+			return fmt.Errorf("failed to save activation: %w", err)
+		}
+
+		if err := tx.
+			Model(&User{}).
+			Where("id = ?", user.ID).
+			UpdateColumn("break_glass_expires_at", expiresAt).
+			Error; err != nil {
+			return fmt.Errorf("failed to activate break-glass account: %w", err)
+		}
+
+		audit := BuildAuditEntry(actor, "approved break-glass activation", user, 0)
+		audit.Diff = stringDiff("", fmt.Sprintf("active until %s", expiresAt.Format(time.RFC3339)))
+		if err := tx.Save(audit).Error; err != nil {
+			return fmt.Errorf("failed to save audits: %w", err)
+		}
+
+		return recordSystemEvent(tx, &SystemEvent{
+			Kind:    SystemEventBreakGlass,
+			Source:  user.Email,
+			Message: fmt.Sprintf("activation approved by %s until %s", actor.AuditDisplay(), expiresAt.Format(time.RFC3339)),
+		})
+	})
+}
+
+// RevokeBreakGlassActivation cancels a pending activation or ends an active
+// one. Any system admin, including the activated break-glass account, can
+// revoke an activation.
+func (db *Database) RevokeBreakGlassActivation(activation *BreakGlassActivation, actor *User) error {
+	if actor == nil {
+		return ErrMissingActor
+	}
+	if !actor.IsSystemAdmin() {
+		return ErrBreakGlassOperator
+	}
+
+	return db.db.Transaction(func(tx *gorm.DB) error {
+		user, err := lockBreakGlassActivation(tx, activation)
+		if err != nil {
+			return err
+		}
+
+		if !activation.IsOpen() {
+			return ErrBreakGlassNotOpen
+		}
+
+		now := time.Now().UTC()
+		activation.RevokedByID = &actor.ID
+		activation.RevokedAt = &now
+		if err := tx.Save(activation).Error; err != nil {
+			return fmt.Errorf("failed to save activation: %w", err)
+		}
+
+		if activation.ApprovedAt != nil {
+			if err := tx.
+				Model(&User{}).
+				Where("id = ?", user.ID).
+				UpdateColumn("break_glass_expires_at", gorm.Expr("NULL")).
+				Error; err != nil {
+				return fmt.Errorf("failed to deactivate break-glass account: %w", err)
+			}
+		}
+
+		audit := BuildAuditEntry(actor, "revoked break-glass activation", user, 0)
+		if err := tx.Save(audit).Error; err != nil {
+			return fmt.Errorf("failed to save audits: %w", err)
+		}
+
+		return recordSystemEvent(tx, &SystemEvent{
+			Kind:    SystemEventBreakGlass,
+			Source:  user.Email,
+			Message: fmt.Sprintf("activation revoked by %s", actor.AuditDisplay()),
+		})
+	})
+}
+
+// RecordExpiredBreakGlassActivations audits the activations that expired since
+// the last call and deactivates their accounts. Accounts stop being system
+// admins as soon as their activation expires, whether or not this has run. It
+// returns the number of expirations recorded.
+func (db *Database) RecordExpiredBreakGlassActivations() (int, error) {
+	var count int
+	if err := db.db.Transaction(func(tx *gorm.DB) error {
+		now := time.Now().UTC()
+
+		var activations []*BreakGlassActivation
+		if err := tx.
+			Set("gorm:query_option", "FOR UPDATE SKIP LOCKED").
+			Model(&BreakGlassActivation{}).
+			Where("approved_at IS NOT NULL AND revoked_at IS NULL AND expiry_recorded_at IS NULL").
+			Where("expires_at <= ?", now).
+			Order("expires_at ASC").
+			Find(&activations).
+			Error; err != nil {
+			if IsNotFound(err) {
+				return nil
+			}
+			return fmt.Errorf("failed to list expired activations: %w", err)
+		}
+
+		for _, activation := range activations {
+			var user User
+			if err := tx.
+				Model(&User{}).
+				Where("id = ?", activation.UserID).
+				First(&user).
+				Error; err != nil {
+				return fmt.Errorf("failed to find break-glass account %d: %w", activation.UserID, err)
+			}
+
+			if err := tx.
+				Model(&BreakGlassActivation{}).
+				Where("id = ?", activation.ID).
+				UpdateColumn("expiry_recorded_at", now).
+				Error; err != nil {
+				return fmt.Errorf("failed to record expiry: %w", err)
+			}
+
+			// Only clear the expiry if a newer activation has not replaced it.
+			if err := tx.
+				Model(&User{}).
+				Where("id = ? AND break_glass_expires_at <= ?", user.ID, now).
+				UpdateColumn("break_glass_expires_at", gorm.Expr("NULL")).
+				Error; err != nil {
+				return fmt.Errorf("failed to deactivate break-glass account: %w", err)
+			}
+
+			audit := BuildAuditEntry(System, "break-glass activation expired", &user, 0)
+			if err := tx.Save(audit).Error; err != nil {
+				return fmt.Errorf("failed to save audits: %w", err)
+			}
+
+			if err := recordSystemEvent(tx, &SystemEvent{
+				Kind:    SystemEventBreakGlass,
+				Source:  user.Email,
+				Message: "activation expired",
+			}); err != nil {
+				return err
+			}
+			count++
+		}
+		return nil
+	}); err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// lockBreakGlassActivation reloads the activation and locks it for update in
+// the transaction, returning its break-glass account.
+func lockBreakGlassActivation(tx *gorm.DB, activation *BreakGlassActivation) (*User, error) {
+	if err := tx.
+		Set("gorm:query_option", "FOR UPDATE").
+		Model(&BreakGlassActivation{}).
+		Where("id = ?", activation.ID).
+		First(activation).
+		Error; err != nil {
+		return nil, fmt.Errorf("failed to lock activation: %w", err)
+	}
+
+	var user User
+	if err := tx.
+		Model(&User{}).
+		Where("id = ?", activation.UserID).
+		First(&user).
+		Error; err != nil {
+		return nil, fmt.Errorf("failed to find break-glass account: %w", err)
+	}
+	return &user, nil
+}
+
+// canOperateBreakGlass returns true if the actor may request or approve an
+// activation of the break-glass account. Only system admins that are not
+// break-glass accounts may, and never for their own account.
+func canOperateBreakGlass(user, actor *User) bool {
+	return actor.SystemAdmin && !actor.BreakGlass && actor.ID != user.ID
+}
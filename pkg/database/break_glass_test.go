@@ -0,0 +1,278 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package database
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/exposure-notifications-verification-server/pkg/pagination"
+)
+
+func TestBreakGlassActivation_Status(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now().UTC()
+	past := now.Add(-time.Minute)
+	future := now.Add(time.Hour)
+
+	cases := []struct {
+		name       string
+		activation *BreakGlassActivation
+		exp        string
+	}{
+		{
+			name:       "pending",
+			activation: &BreakGlassActivation{CreatedAt: now},
+			exp:        BreakGlassStatusPending,
+		},
+		{
+			name:       "lapsed",
+			activation: &BreakGlassActivation{CreatedAt: now.Add(-2 * BreakGlassRequestTTL)},
+			exp:        BreakGlassStatusLapsed,
+		},
+		{
+			name:       "active",
+			activation: &BreakGlassActivation{CreatedAt: now, ApprovedAt: &now, ExpiresAt: &future},
+			exp:        BreakGlassStatusActive,
+		},
+		{
+			name:       "expired",
+			activation: &BreakGlassActivation{CreatedAt: now, ApprovedAt: &now, ExpiresAt: &past},
+			exp:        BreakGlassStatusExpired,
+		},
+		{
+			name:       "revoked",
+			activation: &BreakGlassActivation{CreatedAt: now, ApprovedAt: &now, ExpiresAt: &future, RevokedAt: &now},
+			exp:        BreakGlassStatusRevoked,
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			if got, want := tc.activation.Status(), tc.exp; got != want {
+				t.Errorf("expected %q to be %q", got, want)
+			}
+		})
+	}
+}
+
+func TestDatabase_BreakGlassActivation(t *testing.T) {
+	t.Parallel()
+
+	db, _ := testDatabaseInstance.NewDatabase(t, nil)
+
+	account := &User{Email: "break-glass@example.com", Name: "Break glass", BreakGlass: true}
+	if err := db.SaveUser(account, SystemTest); err != nil {
+		t.Fatal(err)
+	}
+	requester := &User{Email: "requester@example.com", Name: "Requester", SystemAdmin: true}
+	if err := db.SaveUser(requester, SystemTest); err != nil {
+		t.Fatal(err)
+	}
+	approver := &User{Email: "approver@example.com", Name: "Approver", SystemAdmin: true}
+	if err := db.SaveUser(approver, SystemTest); err != nil {
+		t.Fatal(err)
+	}
+	regular := &User{Email: "regular@example.com", Name: "Regular"}
+	if err := db.SaveUser(regular, SystemTest); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := db.RequestBreakGlassActivation(regular, "outage", requester); !errors.Is(err, ErrNotBreakGlassAccount) {
+		t.Errorf("expected %v to be %v", err, ErrNotBreakGlassAccount)
+	}
+	if _, err := db.RequestBreakGlassActivation(account, "outage", regular); !errors.Is(err, ErrBreakGlassOperator) {
+		t.Errorf("expected %v to be %v", err, ErrBreakGlassOperator)
+	}
+
+	activation, err := db.RequestBreakGlassActivation(account, "outage", requester)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := activation.Status(), BreakGlassStatusPending; got != want {
+		t.Errorf("expected %q to be %q", got, want)
+	}
+
+	if _, err := db.RequestBreakGlassActivation(account, "again", approver); !errors.Is(err, ErrBreakGlassOpen) {
+		t.Errorf("expected %v to be %v", err, ErrBreakGlassOpen)
+	}
+	if err := db.ApproveBreakGlassActivation(activation, time.Hour, requester); !errors.Is(err, ErrBreakGlassSameApprover) {
+		t.Errorf("expected %v to be %v", err, ErrBreakGlassSameApprover)
+	}
+	if err := db.ApproveBreakGlassActivation(activation, 2*BreakGlassMaxDuration, approver); !errors.Is(err, ErrBreakGlassDuration) {
+		t.Errorf("expected %v to be %v", err, ErrBreakGlassDuration)
+	}
+
+	if err := db.ApproveBreakGlassActivation(activation, time.Hour, approver); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := activation.Status(), BreakGlassStatusActive; got != want {
+		t.Errorf("expected %q to be %q", got, want)
+	}
+
+	account, err = db.FindUser(account.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !account.IsSystemAdmin() {
+		t.Errorf("expected activated break-glass account to be a system admin")
+	}
+	if account.SystemAdmin {
+		t.Errorf("expected system_admin to remain false")
+	}
+
+	// The active account can end its own activation.
+	if err := db.RevokeBreakGlassActivation(activation, account); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := activation.Status(), BreakGlassStatusRevoked; got != want {
+		t.Errorf("expected %q to be %q", got, want)
+	}
+	if err := db.RevokeBreakGlassActivation(activation, approver); !errors.Is(err, ErrBreakGlassNotOpen) {
+		t.Errorf("expected %v to be %v", err, ErrBreakGlassNotOpen)
+	}
+
+	account, err = db.FindUser(account.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if account.IsSystemAdmin() {
+		t.Errorf("expected revoked break-glass account to not be a system admin")
+	}
+
+	// Any actions require a new request.
+	if err := db.ApproveBreakGlassActivation(activation, time.Hour, approver); !errors.Is(err, ErrBreakGlassNotPending) {
+		t.Errorf("expected %v to be %v", err, ErrBreakGlassNotPending)
+	}
+
+	activations, err := account.ListBreakGlassActivations(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(activations), 1; got != want {
+		t.Fatalf("expected %d activations, got %d", want, got)
+	}
+
+	if err := db.LoadBreakGlassOperators(activations); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := activations[0].RequestedBy.ID, requester.ID; got != want {
+		t.Errorf("expected requested by %d to be %d", got, want)
+	}
+	if got, want := activations[0].ApprovedBy.ID, approver.ID; got != want {
+		t.Errorf("expected approved by %d to be %d", got, want)
+	}
+	if got, want := activations[0].RevokedBy.ID, account.ID; got != want {
+		t.Errorf("expected revoked by %d to be %d", got, want)
+	}
+
+	audits, _, err := db.ListAudits(&pagination.PageParams{Limit: 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var found int
+	for _, audit := range audits {
+		switch audit.Action {
+		case "requested break-glass activation", "approved break-glass activation", "revoked break-glass activation":
+			found++
+		}
+	}
+	if got, want := found, 3; got != want {
+		t.Errorf("expected %d break-glass audits, got %d", want, got)
+	}
+}
+
+func TestDatabase_RecordExpiredBreakGlassActivations(t *testing.T) {
+	t.Parallel()
+
+	db, _ := testDatabaseInstance.NewDatabase(t, nil)
+
+	account := &User{Email: "break-glass@example.com", Name: "Break glass", BreakGlass: true}
+	if err := db.SaveUser(account, SystemTest); err != nil {
+		t.Fatal(err)
+	}
+	requester := &User{Email: "requester@example.com", Name: "Requester", SystemAdmin: true}
+	if err := db.SaveUser(requester, SystemTest); err != nil {
+		t.Fatal(err)
+	}
+	approver := &User{Email: "approver@example.com", Name: "Approver", SystemAdmin: true}
+	if err := db.SaveUser(approver, SystemTest); err != nil {
+		t.Fatal(err)
+	}
+
+	activation, err := db.RequestBreakGlassActivation(account, "outage", requester)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.ApproveBreakGlassActivation(activation, time.Hour, approver); err != nil {
+		t.Fatal(err)
+	}
+
+	// Nothing has expired yet.
+	count, err := db.RecordExpiredBreakGlassActivations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := count, 0; got != want {
+		t.Errorf("expected %d expirations, got %d", want, got)
+	}
+
+	past := time.Now().UTC().Add(-time.Minute)
+	if err := db.db.Model(&BreakGlassActivation{}).Where("id = ?", activation.ID).UpdateColumn("expires_at", past).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := db.db.Model(&User{}).Where("id = ?", account.ID).UpdateColumn("break_glass_expires_at", past).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	count, err = db.RecordExpiredBreakGlassActivations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := count, 1; got != want {
+		t.Errorf("expected %d expirations, got %d", want, got)
+	}
+
+	// Expirations are only recorded once.
+	count, err = db.RecordExpiredBreakGlassActivations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := count, 0; got != want {
+		t.Errorf("expected %d expirations, got %d", want, got)
+	}
+
+	account, err = db.FindUser(account.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if account.BreakGlassExpiresAt != nil {
+		t.Errorf("expected break-glass expiry to be cleared, got %v", account.BreakGlassExpiresAt)
+	}
+	if account.IsSystemAdmin() {
+		t.Errorf("expected expired break-glass account to not be a system admin")
+	}
+
+	// A new activation can be requested after expiry.
+	if _, err := db.RequestBreakGlassActivation(account, "another outage", requester); err != nil {
+		t.Fatal(err)
+	}
+}
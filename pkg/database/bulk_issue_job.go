@@ -0,0 +1,434 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package database
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+const (
+	// BulkIssueJobStatusPending indicates the job has been uploaded, but no rows
+	// have been processed.
+	BulkIssueJobStatusPending = "pending"
+
+	// BulkIssueJobStatusProcessing indicates some, but not all, rows have been
+	// processed.
+	BulkIssueJobStatusProcessing = "processing"
+
+	// BulkIssueJobStatusCompleted indicates every row was processed. Individual
+	// rows may still have failed.
+	BulkIssueJobStatusCompleted = "completed"
+
+	// BulkIssueJobStatusFailed indicates the job could not be processed at all.
+	BulkIssueJobStatusFailed = "failed"
+
+	// BulkIssueRowPending, BulkIssueRowIssued, and BulkIssueRowFailed are the
+	// statuses of a single row in a bulk issue job.
+	BulkIssueRowPending = "pending"
+	BulkIssueRowIssued  = "issued"
+	BulkIssueRowFailed  = "failed"
+)
+
+var _ Auditable = (*BulkIssueJob)(nil)
+
+// BulkIssueJobRow is a single row of a bulk issue upload and the outcome of
+// issuing a code for it.
+type BulkIssueJobRow struct {
+	// Line is the line number in the uploaded file.
+	Line int `json:"line"`
+
+	Phone       string `json:"phone"`
+	TestDate    string `json:"testDate,omitempty"`
+	SymptomDate string `json:"symptomDate,omitempty"`
+	TestType    string `json:"testType,omitempty"`
+
+	// UUID is assigned when the job is created, so a retried row cannot issue a
+	// second code.
+	UUID string `json:"uuid"`
+
+	Status    string `json:"status"`
+	Attempts  uint   `json:"attempts,omitempty"`
+	ErrorCode string `json:"errorCode,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+// IsPending returns true if a code has not yet been issued for the row, and it
+// has not permanently failed.
+func (r *BulkIssueJobRow) IsPending() bool {
+	return r.Status == BulkIssueRowPending
+}
+
+// clearPatientData removes the phone number, dates, and test type from the row.
+// They are only needed until a code is issued for the row.
+func (r *BulkIssueJobRow) clearPatientData() {
+	r.Phone = ""
+	r.TestDate = ""
+	r.SymptomDate = ""
+	r.TestType = ""
+}
+
+// BulkIssueJobRows is the results report of a bulk issue job.
+type BulkIssueJobRows []*BulkIssueJobRow
+
+// MarshalCSV returns bytes in CSV format. The report has the line number of
+// each row in the upload, the tracking UUID, and the error code, or "success"
+// for issued rows. It does not include phone numbers or other patient data.
+func (r BulkIssueJobRows) MarshalCSV() ([]byte, error) {
+	// Do nothing if there's no records
+	if len(r) == 0 {
+		return nil, nil
+	}
+
+	var b bytes.Buffer
+	w := csv.NewWriter(&b)
+
+	if err := w.Write([]string{"line", "attempts", "uuid", "errorCode", "error"}); err != nil {
+		return nil, fmt.Errorf("failed to write CSV header: %w", err)
+	}
+
+	for i, row := range r {
+		errorCode := row.ErrorCode
+		switch row.Status {
+		case BulkIssueRowIssued:
+			errorCode = "success"
+		case BulkIssueRowPending:
+			errorCode = BulkIssueRowPending
+		}
+
+		if err := w.Write([]string{
+			strconv.Itoa(row.Line),
+			strconv.FormatUint(uint64(row.Attempts), 10),
+			row.UUID,
+			errorCode,
+			row.Error,
+		}); err != nil {
+			return nil, fmt.Errorf("failed to write CSV entry %d: %w", i, err)
+		}
+	}
+
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return nil, fmt.Errorf("failed to create CSV: %w", err)
+	}
+
+	return b.Bytes(), nil
+}
+
+// BulkIssueJob is a CSV upload of many codes to issue. Jobs are processed in
+// the background by a scheduled worker, so an upload completes even if the
+// user who uploaded it closes their browser.
+type BulkIssueJob struct {
+	Errorable
+
+	// ID is the job's ID.
+	ID uint `gorm:"primary_key;"`
+
+	// RealmID is the realm the codes are issued in.
+	RealmID uint `gorm:"column:realm_id; type:integer; not null;"`
+
+	// UserID is the user who uploaded the file. Codes are issued as this user.
+	UserID uint `gorm:"column:user_id; type:integer; not null;"`
+
+	// Filename is the name of the uploaded file.
+	Filename string `gorm:"column:filename; type:text; not null; default:'';"`
+
+	// SMSTemplateLabel and TZOffset apply to every row.
+	SMSTemplateLabel string  `gorm:"column:sms_template_label; type:text; not null; default:'';"`
+	TZOffset         float32 `gorm:"column:tz_offset; type:real; not null; default:0;"`
+
+	// Status is the job status.
+	Status string `gorm:"column:status; type:text; not null; default:'pending';"`
+
+	// Total, Issued, and Failed are the number of rows in the job, for which a
+	// code was issued, and which permanently failed. They are computed from the
+	// rows when the job is saved.
+	Total  uint `gorm:"column:total; type:integer; not null; default:0;"`
+	Issued uint `gorm:"column:issued; type:integer; not null; default:0;"`
+	Failed uint `gorm:"column:failed; type:integer; not null; default:0;"`
+
+	// Rows are the rows of the upload, stored as JSON encrypted with the
+	// database encryption key. Patient data is removed from each row once it is
+	// processed, and from every row once the job is finished.
+	Rows        []*BulkIssueJobRow `gorm:"-"`
+	RowsPayload string             `gorm:"column:rows; type:text; not null;"`
+
+	// Error is the reason the job failed, if any.
+	Error string `gorm:"column:error; type:text;"`
+
+	// CompletedAt is when the job finished, successfully or not.
+	CompletedAt *time.Time `gorm:"column:completed_at; type:timestamp with time zone;"`
+
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// TableName sets the table name.
+func (BulkIssueJob) TableName() string {
+	return "bulk_issue_jobs"
+}
+
+// AfterFind decodes the rows. Rows are not selected when listing jobs. The
+// payload is decrypted by a callback before this runs.
+func (j *BulkIssueJob) AfterFind(tx *gorm.DB) error {
+	if j.RowsPayload != "" {
+		if err := json.Unmarshal([]byte(j.RowsPayload), &j.Rows); err != nil {
+			return fmt.Errorf("failed to decode rows: %w", err)
+		}
+	}
+	return nil
+}
+
+// BeforeSave runs validations and updates the row counts. If there are errors,
+// the save fails.
+func (j *BulkIssueJob) BeforeSave(tx *gorm.DB) error {
+	if j.RealmID == 0 {
+		j.AddError("realm_id", "is required")
+	}
+	if j.UserID == 0 {
+		j.AddError("user_id", "is required")
+	}
+
+	if j.Status == "" {
+		j.Status = BulkIssueJobStatusPending
+	}
+	switch j.Status {
+	case BulkIssueJobStatusPending, BulkIssueJobStatusProcessing, BulkIssueJobStatusCompleted, BulkIssueJobStatusFailed:
+	default:
+		j.AddError("status", fmt.Sprintf("is not a valid status %q", j.Status))
+	}
+
+	if len(j.Rows) == 0 {
+		j.AddError("rows", "file has no rows")
+	}
+
+	finished := j.IsFinished()
+	j.Total, j.Issued, j.Failed = 0, 0, 0
+	for idx, row := range j.Rows {
+		if finished || !row.IsPending() {
+			row.clearPatientData()
+		}
+
+		j.Total++
+		switch row.Status {
+		case BulkIssueRowPending:
+		case BulkIssueRowIssued:
+			j.Issued++
+		case BulkIssueRowFailed:
+			j.Failed++
+		default:
+			j.AddError("rows", fmt.Sprintf("row %d: is not a valid status %q", idx, row.Status))
+		}
+	}
+
+	if err := j.ErrorOrNil(); err != nil {
+		return err
+	}
+
+	b, err := json.Marshal(j.Rows)
+	if err != nil {
+		return fmt.Errorf("failed to encode rows: %w", err)
+	}
+	j.RowsPayload = string(b)
+	return nil
+}
+
+// IsFinished returns true if the job will not be processed further.
+func (j *BulkIssueJob) IsFinished() bool {
+	return j.Status == BulkIssueJobStatusCompleted || j.Status == BulkIssueJobStatusFailed
+}
+
+// Processed returns the number of rows that were issued or failed.
+func (j *BulkIssueJob) Processed() uint {
+	return j.Issued + j.Failed
+}
+
+// Percent returns the percentage of rows that were processed.
+func (j *BulkIssueJob) Percent() uint {
+	if j.Total == 0 {
+		return 100
+	}
+	return j.Processed() * 100 / j.Total
+}
+
+// PendingRows returns up to max rows that have not yet been processed.
+func (j *BulkIssueJob) PendingRows(max int) []*BulkIssueJobRow {
+	rows := make([]*BulkIssueJobRow, 0, max)
+	for _, row := range j.Rows {
+		if len(rows) >= max {
+			break
+		}
+		if row.IsPending() {
+			rows = append(rows, row)
+		}
+	}
+	return rows
+}
+
+// AuditID is how the job is stored in the audit entry.
+func (j *BulkIssueJob) AuditID() string {
+	return fmt.Sprintf("bulk_issue_jobs:%d", j.ID)
+}
+
+// AuditDisplay is how the job will be displayed in audit entries.
+func (j *BulkIssueJob) AuditDisplay() string {
+	return fmt.Sprintf("bulk issue job %d", j.ID)
+}
+
+// CreateBulkIssueJob saves a new pending job.
+func (db *Database) CreateBulkIssueJob(j *BulkIssueJob, actor Auditable) error {
+	if j == nil {
+		return fmt.Errorf("provided job is nil")
+	}
+
+	if actor == nil {
+		return ErrMissingActor
+	}
+
+	return db.db.Transaction(func(tx *gorm.DB) error {
+		j.Status = BulkIssueJobStatusPending
+		if err := tx.Save(j).Error; err != nil {
+			return err
+		}
+
+		audit := BuildAuditEntry(actor, "created bulk issue job", j, j.RealmID)
+		audit.Diff = stringDiff("", fmt.Sprintf("%s (%d rows)", j.Filename, j.Total))
+		if err := tx.Save(audit).Error; err != nil {
+			return fmt.Errorf("failed to save audit: %w", err)
+		}
+		return nil
+	})
+}
+
+// FindBulkIssueJob finds the job in the realm by its ID.
+func (r *Realm) FindBulkIssueJob(db *Database, id interface{}) (*BulkIssueJob, error) {
+	var j BulkIssueJob
+	if err := db.db.
+		Model(&BulkIssueJob{}).
+		Where("realm_id = ?", r.ID).
+		Where("id = ?", id).
+		First(&j).
+		Error; err != nil {
+		return nil, err
+	}
+	return &j, nil
+}
+
+// ListBulkIssueJobs lists up to limit of the realm's most recent jobs, without
+// their rows.
+func (r *Realm) ListBulkIssueJobs(db *Database, limit uint64) ([]*BulkIssueJob, error) {
+	var jobs []*BulkIssueJob
+	if err := db.db.
+		Model(&BulkIssueJob{}).
+		Select("id, realm_id, user_id, filename, status, total, issued, failed, error, completed_at, created_at, updated_at").
+		Where("realm_id = ?", r.ID).
+		Order("created_at DESC, id DESC").
+		Limit(limit).
+		Find(&jobs).
+		Error; err != nil {
+		if IsNotFound(err) {
+			return jobs, nil
+		}
+		return nil, err
+	}
+	return jobs, nil
+}
+
+// ListPendingBulkIssueJobs lists up to limit unfinished jobs across all
+// realms, oldest first.
+func (db *Database) ListPendingBulkIssueJobs(limit uint64) ([]*BulkIssueJob, error) {
+	var ids []uint
+	if err := db.db.
+		Model(&BulkIssueJob{}).
+		Where("status IN (?)", []string{BulkIssueJobStatusPending, BulkIssueJobStatusProcessing}).
+		Order("created_at ASC, id ASC").
+		Limit(limit).
+		Pluck("id", &ids).
+		Error; err != nil {
+		if IsNotFound(err) {
+			return []*BulkIssueJob{}, nil
+		}
+		return nil, err
+	}
+
+	// Jobs are loaded one at a time, because the rows are only decrypted when a
+	// single record is queried.
+	jobs := make([]*BulkIssueJob, 0, len(ids))
+	for _, id := range ids {
+		var j BulkIssueJob
+		if err := db.db.
+			Model(&BulkIssueJob{}).
+			Where("id = ?", id).
+			First(&j).
+			Error; err != nil {
+			if IsNotFound(err) {
+				continue
+			}
+			return nil, fmt.Errorf("failed to load job %d: %w", id, err)
+		}
+		jobs = append(jobs, &j)
+	}
+	return jobs, nil
+}
+
+// SaveBulkIssueJobProgress saves the outcome of processed rows. The job is
+// marked as completed once no rows are pending.
+func (db *Database) SaveBulkIssueJobProgress(j *BulkIssueJob) error {
+	j.Status = BulkIssueJobStatusCompleted
+	for _, row := range j.Rows {
+		if row.IsPending() {
+			j.Status = BulkIssueJobStatusProcessing
+			break
+		}
+	}
+
+	if j.Status == BulkIssueJobStatusCompleted {
+		now := time.Now().UTC()
+		j.CompletedAt = &now
+	}
+	return db.db.Save(j).Error
+}
+
+// FailBulkIssueJob marks the job as failed with the given reason. Rows that
+// were already processed keep their outcome, and patient data is removed from
+// the rows that were not.
+func (db *Database) FailBulkIssueJob(j *BulkIssueJob, reason error) error {
+	now := time.Now().UTC()
+	j.Status = BulkIssueJobStatusFailed
+	j.Error = reason.Error()
+	j.CompletedAt = &now
+	return db.db.Save(j).Error
+}
+
+// PurgeBulkIssueJobs deletes jobs, including any remaining phone numbers and
+// their results, that were created more than maxAge ago.
+func (db *Database) PurgeBulkIssueJobs(maxAge time.Duration) (int64, error) {
+	if maxAge > 0 {
+		maxAge = -1 * maxAge
+	}
+	deleteBefore := time.Now().UTC().Add(maxAge)
+
+	result := db.db.
+		Unscoped().
+		Where("created_at < ?", deleteBefore).
+		Delete(&BulkIssueJob{})
+	return result.RowsAffected, result.Error
+}
@@ -0,0 +1,306 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package database
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/jinzhu/gorm"
+)
+
+func TestBulkIssueJob_BeforeSave(t *testing.T) {
+	t.Parallel()
+
+	{
+		var j BulkIssueJob
+		_ = j.BeforeSave(&gorm.DB{})
+		if errs := j.ErrorsFor("rows"); len(errs) < 1 {
+			t.Errorf("expected errors for rows")
+		}
+		if errs := j.ErrorsFor("user_id"); len(errs) < 1 {
+			t.Errorf("expected errors for user_id")
+		}
+	}
+
+	{
+		j := &BulkIssueJob{
+			RealmID: 1,
+			UserID:  1,
+			Rows: []*BulkIssueJobRow{
+				{Line: 1, Status: "banana"},
+			},
+		}
+		_ = j.BeforeSave(&gorm.DB{})
+		if errs := j.ErrorsFor("rows"); len(errs) < 1 {
+			t.Errorf("expected errors for rows")
+		}
+	}
+
+	{
+		j := &BulkIssueJob{
+			RealmID: 1,
+			UserID:  1,
+			Rows: []*BulkIssueJobRow{
+				{Line: 1, Phone: "+12065550100", TestDate: "2022-11-01", Status: BulkIssueRowIssued},
+				{Line: 2, Phone: "+12065550101", TestDate: "2022-11-01", Status: BulkIssueRowFailed},
+				{Line: 3, Phone: "+12065550102", TestDate: "2022-11-01", Status: BulkIssueRowPending},
+				{Line: 4, Phone: "+12065550103", TestDate: "2022-11-01", Status: BulkIssueRowPending},
+			},
+		}
+		if err := j.BeforeSave(&gorm.DB{}); err != nil {
+			t.Fatal(err)
+		}
+		if got, want := j.Total, uint(4); got != want {
+			t.Errorf("expected %d to be %d", got, want)
+		}
+		if got, want := j.Issued, uint(1); got != want {
+			t.Errorf("expected %d to be %d", got, want)
+		}
+		if got, want := j.Failed, uint(1); got != want {
+			t.Errorf("expected %d to be %d", got, want)
+		}
+		if got, want := j.Percent(), uint(50); got != want {
+			t.Errorf("expected %d to be %d", got, want)
+		}
+		if got, want := len(j.PendingRows(1)), 1; got != want {
+			t.Errorf("expected %d to be %d", got, want)
+		}
+		if j.RowsPayload == "" {
+			t.Errorf("expected rows to be encoded")
+		}
+
+		// Patient data is only kept for pending rows.
+		for _, row := range j.Rows {
+			if hasData := row.Phone != "" || row.TestDate != ""; hasData != row.IsPending() {
+				t.Errorf("row %d: expected patient data only if pending, got %#v", row.Line, row)
+			}
+		}
+
+		// Once the job is finished, it is removed from every row.
+		j.Status = BulkIssueJobStatusFailed
+		if err := j.BeforeSave(&gorm.DB{}); err != nil {
+			t.Fatal(err)
+		}
+		for _, row := range j.Rows {
+			if row.Phone != "" || row.TestDate != "" {
+				t.Errorf("row %d: expected patient data to be removed, got %#v", row.Line, row)
+			}
+		}
+	}
+}
+
+func TestBulkIssueJobRows_MarshalCSV(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name string
+		rows BulkIssueJobRows
+		exp  string
+	}{
+		{
+			name: "empty",
+			rows: nil,
+			exp:  "",
+		},
+		{
+			name: "multi",
+			rows: []*BulkIssueJobRow{
+				{
+					Line:     2,
+					Phone:    "+12065550100",
+					TestDate: "2022-11-01",
+					TestType: "confirmed",
+					UUID:     "a",
+					Status:   BulkIssueRowIssued,
+					Attempts: 1,
+				},
+				{
+					Line:        3,
+					Phone:       "+12065550101",
+					TestDate:    "2022-11-02",
+					SymptomDate: "2022-11-01",
+					TestType:    "likely",
+					UUID:        "b",
+					Status:      BulkIssueRowFailed,
+					Attempts:    1,
+					ErrorCode:   "invalid_date",
+					Error:       "bad, date",
+				},
+				{
+					Line:     4,
+					Phone:    "+12065550102",
+					TestType: "confirmed",
+					UUID:     "c",
+					Status:   BulkIssueRowPending,
+				},
+			},
+			exp: `line,attempts,uuid,errorCode,error
+2,1,a,success,
+3,1,b,invalid_date,"bad, date"
+4,0,c,pending,
+`,
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			b, err := tc.rows.MarshalCSV()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(string(b), tc.exp); diff != "" {
+				t.Errorf("bad csv (+got, -want): %s", diff)
+			}
+		})
+	}
+}
+
+func TestDatabase_BulkIssueJob(t *testing.T) {
+	t.Parallel()
+
+	db, _ := testDatabaseInstance.NewDatabase(t, nil)
+
+	realm, err := db.FindRealm(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rows := make([]*BulkIssueJobRow, 0, 3)
+	for i := 1; i <= 3; i++ {
+		rows = append(rows, &BulkIssueJobRow{
+			Line:   i,
+			Phone:  fmt.Sprintf("+1206555010%d", i),
+			UUID:   fmt.Sprintf("00000000-0000-0000-0000-00000000000%d", i),
+			Status: BulkIssueRowPending,
+		})
+	}
+
+	job := &BulkIssueJob{
+		RealmID:  realm.ID,
+		UserID:   1,
+		Filename: "codes.csv",
+		Rows:     rows,
+	}
+
+	if err := db.CreateBulkIssueJob(job, nil); err != ErrMissingActor {
+		t.Errorf("expected %v to be %v", err, ErrMissingActor)
+	}
+	if err := db.CreateBulkIssueJob(job, SystemTest); err != nil {
+		t.Fatal(err)
+	}
+
+	pending, err := db.ListPendingBulkIssueJobs(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(pending), 1; got != want {
+		t.Fatalf("expected %d to be %d", got, want)
+	}
+	if got, want := len(pending[0].Rows), 3; got != want {
+		t.Fatalf("expected %d to be %d", got, want)
+	}
+	if got, want := pending[0].Rows[0].Phone, "+12065550101"; got != want {
+		t.Errorf("expected %q to be %q", got, want)
+	}
+
+	// Rows are encrypted at rest.
+	var payload string
+	if err := db.db.Raw(`SELECT rows FROM bulk_issue_jobs WHERE id = ?`, job.ID).Row().Scan(&payload); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(payload, "+1206555010") {
+		t.Errorf("expected rows to be encrypted, got %q", payload)
+	}
+
+	// Process the first row.
+	pending[0].Rows[0].Status = BulkIssueRowIssued
+	if err := db.SaveBulkIssueJobProgress(pending[0]); err != nil {
+		t.Fatal(err)
+	}
+
+	found, err := realm.FindBulkIssueJob(db, job.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := found.Status, BulkIssueJobStatusProcessing; got != want {
+		t.Errorf("expected %q to be %q", got, want)
+	}
+	if got, want := found.Issued, uint(1); got != want {
+		t.Errorf("expected %d to be %d", got, want)
+	}
+
+	// Process the remaining rows.
+	found.Rows[1].Status = BulkIssueRowIssued
+	found.Rows[2].Status = BulkIssueRowFailed
+	if err := db.SaveBulkIssueJobProgress(found); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := found.Status, BulkIssueJobStatusCompleted; got != want {
+		t.Errorf("expected %q to be %q", got, want)
+	}
+	if found.CompletedAt == nil {
+		t.Errorf("expected completed at to be set")
+	}
+
+	// Patient data is removed once the job is complete.
+	found, err = realm.FindBulkIssueJob(db, job.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, row := range found.Rows {
+		if row.Phone != "" {
+			t.Errorf("row %d: expected phone to be removed, got %q", row.Line, row.Phone)
+		}
+	}
+
+	pending, err = db.ListPendingBulkIssueJobs(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(pending), 0; got != want {
+		t.Errorf("expected %d to be %d", got, want)
+	}
+
+	// Listing does not load rows.
+	jobs, err := realm.ListBulkIssueJobs(db, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(jobs), 1; got != want {
+		t.Fatalf("expected %d to be %d", got, want)
+	}
+	if got, want := jobs[0].Failed, uint(1); got != want {
+		t.Errorf("expected %d to be %d", got, want)
+	}
+	if got := len(jobs[0].Rows); got != 0 {
+		t.Errorf("expected no rows, got %d", got)
+	}
+
+	// Jobs are purged with their results.
+	count, err := db.PurgeBulkIssueJobs(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := count, int64(1); got != want {
+		t.Errorf("expected %d to be %d", got, want)
+	}
+}
@@ -0,0 +1,361 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package database
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/google/exposure-notifications-verification-server/pkg/pagination"
+	"github.com/jinzhu/gorm"
+)
+
+const (
+	// CallbackDeliveryMaxAttempts is the maximum number of times a callback is
+	// attempted before it is marked as failed.
+	CallbackDeliveryMaxAttempts = 8
+
+	// callbackDeliveryBaseBackoff and callbackDeliveryMaxBackoff bound the delay
+	// between delivery attempts. The delay doubles after each failure.
+	callbackDeliveryBaseBackoff = 1 * time.Minute
+	callbackDeliveryMaxBackoff  = 6 * time.Hour
+)
+
+const (
+	// CallbackDeliveryStatusPending, CallbackDeliveryStatusDelivered, and
+	// CallbackDeliveryStatusFailed are the states of a delivery.
+	CallbackDeliveryStatusPending   = "pending"
+	CallbackDeliveryStatusDelivered = "delivered"
+	CallbackDeliveryStatusFailed    = "failed"
+)
+
+// CallbackDelivery is a notification queued for delivery to an authorized
+// app's callback URL. Deliveries are sent by a scheduled worker and retried
+// with exponential backoff until they succeed or run out of attempts.
+type CallbackDelivery struct {
+	Errorable
+
+	// ID is the delivery's ID. It is sent to the receiver so retried deliveries
+	// can be deduplicated.
+	ID uint `gorm:"primary_key;"`
+
+	// AuthorizedAppID is the API key whose callback URL receives the delivery.
+	AuthorizedAppID uint `gorm:"column:authorized_app_id; type:integer; not null;"`
+
+	// Payload is the JSON body to send.
+	Payload string `gorm:"column:payload; type:text; not null;"`
+
+	// ReplayOfID is the ID of the delivery this delivery was manually replayed
+	// from, if any.
+	ReplayOfID *uint `gorm:"column:replay_of_id; type:integer;"`
+
+	// Attempts is the number of delivery attempts made so far.
+	Attempts int `gorm:"column:attempts; type:integer; not null; default:0;"`
+
+	// NextAttemptAt is the earliest time the next attempt may be made.
+	NextAttemptAt time.Time `gorm:"column:next_attempt_at; type:timestamp with time zone; not null;"`
+
+	// LastError is the reason the most recent attempt failed, if any.
+	LastError string `gorm:"column:last_error; type:text;"`
+
+	// DeliveredAt is when the delivery succeeded. FailedAt is when the delivery
+	// was abandoned. At most one is set.
+	DeliveredAt *time.Time `gorm:"column:delivered_at; type:timestamp with time zone;"`
+	FailedAt    *time.Time `gorm:"column:failed_at; type:timestamp with time zone;"`
+
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// TableName sets the table name.
+func (CallbackDelivery) TableName() string {
+	return "callback_deliveries"
+}
+
+// Status returns the state of the delivery.
+func (d *CallbackDelivery) Status() string {
+	switch {
+	case d.DeliveredAt != nil:
+		return CallbackDeliveryStatusDelivered
+	case d.FailedAt != nil:
+		return CallbackDeliveryStatusFailed
+	default:
+		return CallbackDeliveryStatusPending
+	}
+}
+
+// BeforeSave runs validations. If there are errors, the save fails.
+func (d *CallbackDelivery) BeforeSave(tx *gorm.DB) error {
+	if d.AuthorizedAppID == 0 {
+		d.AddError("authorized_app_id", "is required")
+	}
+	if d.Payload == "" {
+		d.AddError("payload", "is required")
+	}
+	if d.NextAttemptAt.IsZero() {
+		d.NextAttemptAt = time.Now().UTC()
+	}
+	return d.ErrorOrNil()
+}
+
+// EnqueueCallbackDelivery queues the payload for delivery to the authorized
+// app's callback URL. The first attempt is made on the next worker run.
+func (db *Database) EnqueueCallbackDelivery(authorizedAppID uint, payload []byte) (*CallbackDelivery, error) {
+	d := &CallbackDelivery{
+		AuthorizedAppID: authorizedAppID,
+		Payload:         string(payload),
+		NextAttemptAt:   time.Now().UTC(),
+	}
+	if err := db.db.Save(d).Error; err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// ClaimCallbackDeliveries returns up to limit deliveries that are due, oldest
+// first. Claimed deliveries have their next attempt pushed out by lease so
+// concurrent workers do not send them at the same time. Workers must record
+// the outcome with CompleteCallbackDelivery or FailCallbackDelivery.
+func (db *Database) ClaimCallbackDeliveries(limit uint64, lease time.Duration) ([]*CallbackDelivery, error) {
+	var deliveries []*CallbackDelivery
+	if err := db.db.Transaction(func(tx *gorm.DB) error {
+		now := time.Now().UTC()
+
+		if err := tx.
+			Set("gorm:query_option", "FOR UPDATE SKIP LOCKED").
+			Model(&CallbackDelivery{}).
+			Where("delivered_at IS NULL AND failed_at IS NULL").
+			Where("next_attempt_at <= ?", now).
+			Order("next_attempt_at ASC, id ASC").
+			Limit(limit).
+			Find(&deliveries).
+			Error; err != nil {
+			if IsNotFound(err) {
+				return nil
+			}
+			return fmt.Errorf("failed to list due deliveries: %w", err)
+		}
+
+		if len(deliveries) == 0 {
+			return nil
+		}
+
+		ids := make([]uint, 0, len(deliveries))
+		for _, d := range deliveries {
+			ids = append(ids, d.ID)
+		}
+
+		if err := tx.
+			Model(&CallbackDelivery{}).
+			Where("id IN (?)", ids).
+			UpdateColumn("next_attempt_at", now.Add(lease)).
+			Error; err != nil {
+			return fmt.Errorf("failed to lease deliveries: %w", err)
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return deliveries, nil
+}
+
+// CompleteCallbackDelivery records a successful delivery. If attempt is not
+// nil, it is saved as the capture of the delivery attempt.
+func (db *Database) CompleteCallbackDelivery(d *CallbackDelivery, attempt *CallbackDeliveryAttempt) error {
+	now := time.Now().UTC()
+	d.Attempts++
+	d.DeliveredAt = &now
+	d.LastError = ""
+	return db.saveCallbackDeliveryOutcome(d, attempt)
+}
+
+// FailCallbackDelivery records a failed delivery attempt. If retry is true and
+// attempts remain, the next attempt is scheduled with exponential backoff.
+// Otherwise the delivery is abandoned. If attempt is not nil, it is saved as
+// the capture of the delivery attempt.
+func (db *Database) FailCallbackDelivery(d *CallbackDelivery, attempt *CallbackDeliveryAttempt, cause error, retry bool) error {
+	now := time.Now().UTC()
+	d.Attempts++
+	d.LastError = cause.Error()
+
+	if retry && d.Attempts < CallbackDeliveryMaxAttempts {
+		d.NextAttemptAt = now.Add(callbackDeliveryBackoff(d.Attempts))
+	} else {
+		d.FailedAt = &now
+	}
+	return db.saveCallbackDeliveryOutcome(d, attempt)
+}
+
+// saveCallbackDeliveryOutcome saves the delivery and the capture of the attempt
+// in a single transaction.
+func (db *Database) saveCallbackDeliveryOutcome(d *CallbackDelivery, attempt *CallbackDeliveryAttempt) error {
+	return db.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(d).Error; err != nil {
+			return fmt.Errorf("failed to save delivery: %w", err)
+		}
+
+		if attempt == nil {
+			return nil
+		}
+		attempt.CallbackDeliveryID = d.ID
+		attempt.Attempt = d.Attempts
+		if attempt.Error == "" && d.LastError != "" {
+			attempt.Error = d.LastError
+		}
+		if err := tx.Save(attempt).Error; err != nil {
+			return fmt.Errorf("failed to save delivery attempt: %w", err)
+		}
+		return nil
+	})
+}
+
+// ReplayCallbackDelivery queues a new delivery with the same payload and API
+// key as d. Replays are new deliveries with their own ID, so receivers that
+// deduplicate by delivery ID will process them again.
+func (db *Database) ReplayCallbackDelivery(d *CallbackDelivery) (*CallbackDelivery, error) {
+	replay := &CallbackDelivery{
+		AuthorizedAppID: d.AuthorizedAppID,
+		Payload:         d.Payload,
+		ReplayOfID:      &d.ID,
+		NextAttemptAt:   time.Now().UTC(),
+	}
+	if err := db.db.Save(replay).Error; err != nil {
+		return nil, err
+	}
+	return replay, nil
+}
+
+// ListCallbackDeliveries lists the callback deliveries for the realm's API
+// keys, newest first.
+func (r *Realm) ListCallbackDeliveries(db *Database, p *pagination.PageParams, scopes ...Scope) ([]*CallbackDelivery, *pagination.Paginator, error) {
+	var deliveries []*CallbackDelivery
+
+	query := db.db.
+		Model(&CallbackDelivery{}).
+		Scopes(withCallbackDeliveryRealmID(r.ID)).
+		Scopes(scopes...).
+		Order("callback_deliveries.created_at DESC, callback_deliveries.id DESC")
+
+	if p == nil {
+		p = new(pagination.PageParams)
+	}
+
+	paginator, err := Paginate(query, &deliveries, p.Page, p.Limit)
+	if err != nil {
+		if IsNotFound(err) {
+			return deliveries, nil, nil
+		}
+		return nil, nil, err
+	}
+	return deliveries, paginator, nil
+}
+
+// FindCallbackDelivery finds the callback delivery by ID, scoped to the
+// realm's API keys.
+func (r *Realm) FindCallbackDelivery(db *Database, id interface{}) (*CallbackDelivery, error) {
+	var delivery CallbackDelivery
+	if err := db.db.
+		Model(&CallbackDelivery{}).
+		Scopes(withCallbackDeliveryRealmID(r.ID)).
+		Where("callback_deliveries.id = ?", id).
+		First(&delivery).
+		Error; err != nil {
+		return nil, err
+	}
+	return &delivery, nil
+}
+
+// CountFailedCallbackDeliveries returns the number of the realm's deliveries
+// that were abandoned since the given time.
+func (r *Realm) CountFailedCallbackDeliveries(db *Database, since time.Time) (uint64, error) {
+	var count uint64
+	if err := db.db.
+		Model(&CallbackDelivery{}).
+		Scopes(withCallbackDeliveryRealmID(r.ID)).
+		Where("callback_deliveries.failed_at >= ?", since).
+		Count(&count).
+		Error; err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// WithCallbackDeliveryStatus returns a scope that filters deliveries by status.
+// An unknown or blank status does not filter.
+func WithCallbackDeliveryStatus(status string) Scope {
+	return func(db *gorm.DB) *gorm.DB {
+		switch status {
+		case CallbackDeliveryStatusPending:
+			return db.Where("callback_deliveries.delivered_at IS NULL AND callback_deliveries.failed_at IS NULL")
+		case CallbackDeliveryStatusDelivered:
+			return db.Where("callback_deliveries.delivered_at IS NOT NULL")
+		case CallbackDeliveryStatusFailed:
+			return db.Where("callback_deliveries.failed_at IS NOT NULL")
+		default:
+			return db
+		}
+	}
+}
+
+// WithCallbackDeliveryAuthorizedAppID returns a scope that filters deliveries
+// to those for the given API key. An ID of 0 does not filter.
+func WithCallbackDeliveryAuthorizedAppID(id uint) Scope {
+	return func(db *gorm.DB) *gorm.DB {
+		if id == 0 {
+			return db
+		}
+		return db.Where("callback_deliveries.authorized_app_id = ?", id)
+	}
+}
+
+func withCallbackDeliveryRealmID(realmID uint) Scope {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.
+			Select("callback_deliveries.*").
+			Joins("JOIN authorized_apps ON authorized_apps.id = callback_deliveries.authorized_app_id").
+			Where("authorized_apps.realm_id = ?", realmID)
+	}
+}
+
+// callbackDeliveryBackoff returns the delay before the next attempt, given the
+// number of attempts already made.
+func callbackDeliveryBackoff(attempts int) time.Duration {
+	backoff := callbackDeliveryBaseBackoff
+	for i := 1; i < attempts; i++ {
+		backoff *= 2
+		if backoff >= callbackDeliveryMaxBackoff {
+			return callbackDeliveryMaxBackoff
+		}
+	}
+	return backoff
+}
+
+// PurgeCallbackDeliveries deletes deliveries created before maxAge, whether or
+// not they were delivered.
+func (db *Database) PurgeCallbackDeliveries(maxAge time.Duration) (int64, error) {
+	if maxAge > 0 {
+		maxAge = -1 * maxAge
+	}
+	deleteBefore := time.Now().UTC().Add(maxAge)
+
+	result := db.db.
+		Unscoped().
+		Where("created_at < ?", deleteBefore).
+		Delete(&CallbackDelivery{})
+	if err := result.Error; err != nil {
+		return 0, fmt.Errorf("failed to purge callback deliveries: %w", err)
+	}
+	return result.RowsAffected, nil
+}
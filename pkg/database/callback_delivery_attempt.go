@@ -0,0 +1,84 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package database
+
+import (
+	"time"
+)
+
+// CallbackDeliveryAttempt is a capture of a single attempt to send a callback
+// delivery. Captures are redacted before they are saved: signatures and
+// credentials are removed and response bodies are truncated. They are deleted
+// with their delivery.
+type CallbackDeliveryAttempt struct {
+	// ID is the attempt's ID.
+	ID uint `gorm:"primary_key;"`
+
+	// CallbackDeliveryID is the delivery that was attempted.
+	CallbackDeliveryID uint `gorm:"column:callback_delivery_id; type:integer; not null;"`
+
+	// Attempt is the attempt number, starting at 1.
+	Attempt int `gorm:"column:attempt; type:integer; not null;"`
+
+	// URL is the callback URL at the time of the attempt.
+	URL string `gorm:"column:url; type:text; not null;"`
+
+	// RequestHeaders and ResponseHeaders are the redacted headers, one
+	// "Name: value" per line.
+	RequestHeaders  string `gorm:"column:request_headers; type:text;"`
+	ResponseHeaders string `gorm:"column:response_headers; type:text;"`
+
+	// ResponseStatus is the HTTP status code, or 0 if no response was received.
+	ResponseStatus int `gorm:"column:response_status; type:integer; not null; default:0;"`
+
+	// ResponseBody is the beginning of the response body.
+	ResponseBody string `gorm:"column:response_body; type:text;"`
+
+	// Error is the reason the attempt failed, if any.
+	Error string `gorm:"column:error; type:text;"`
+
+	// DurationMs is how long the request took, in milliseconds.
+	DurationMs int64 `gorm:"column:duration_ms; type:integer; not null; default:0;"`
+
+	CreatedAt time.Time
+}
+
+// TableName sets the table name.
+func (CallbackDeliveryAttempt) TableName() string {
+	return "callback_delivery_attempts"
+}
+
+// Succeeded returns true if the attempt received a 2xx response.
+func (a *CallbackDeliveryAttempt) Succeeded() bool {
+	return a.ResponseStatus >= 200 && a.ResponseStatus <= 299
+}
+
+// ListCallbackDeliveryAttempts returns the captured attempts for the delivery,
+// oldest first.
+func (db *Database) ListCallbackDeliveryAttempts(deliveryID uint) ([]*CallbackDeliveryAttempt, error) {
+	var attempts []*CallbackDeliveryAttempt
+	if err := db.db.
+		Model(&CallbackDeliveryAttempt{}).
+		Where("callback_delivery_id = ?", deliveryID).
+		Order("attempt ASC, id ASC").
+		Find(&attempts).
+		Error; err != nil {
+		if IsNotFound(err) {
+			return attempts, nil
+		}
+		return nil, err
+	}
+	return attempts, nil
+}
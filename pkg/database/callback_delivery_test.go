@@ -0,0 +1,236 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package database
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestCallbackDeliveryBackoff(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{attempts: 1, want: 1 * time.Minute},
+		{attempts: 2, want: 2 * time.Minute},
+		{attempts: 5, want: 16 * time.Minute},
+		{attempts: 20, want: 6 * time.Hour},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(fmt.Sprintf("%d", tc.attempts), func(t *testing.T) {
+			t.Parallel()
+
+			if got, want := callbackDeliveryBackoff(tc.attempts), tc.want; got != want {
+				t.Errorf("expected %s to be %s", got, want)
+			}
+		})
+	}
+}
+
+func TestDatabase_CallbackDeliveries(t *testing.T) {
+	t.Parallel()
+
+	db, _ := testDatabaseInstance.NewDatabase(t, nil)
+
+	realm, err := db.FindRealm(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	app := &AuthorizedApp{
+		Name:       "callbacks",
+		APIKeyType: APIKeyTypeAdmin,
+	}
+	if _, err := realm.CreateAuthorizedApp(db, app, SystemTest); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := db.EnqueueCallbackDelivery(app.ID, nil); err == nil {
+		t.Errorf("expected error for empty payload")
+	}
+
+	first, err := db.EnqueueCallbackDelivery(app.ID, []byte(`{"n":1}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := db.EnqueueCallbackDelivery(app.ID, []byte(`{"n":2}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Both are due.
+	claimed, err := db.ClaimCallbackDeliveries(10, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(claimed), 2; got != want {
+		t.Fatalf("expected %d claimed, got %d", want, got)
+	}
+	if got, want := claimed[0].ID, first.ID; got != want {
+		t.Errorf("expected %d to be %d", got, want)
+	}
+
+	// Claimed deliveries are leased and cannot be claimed again.
+	if again, err := db.ClaimCallbackDeliveries(10, time.Minute); err != nil {
+		t.Fatal(err)
+	} else if len(again) != 0 {
+		t.Errorf("expected no deliveries, got %d", len(again))
+	}
+
+	if err := db.CompleteCallbackDelivery(claimed[0], &CallbackDeliveryAttempt{
+		URL:            "https://example.com/callback",
+		ResponseStatus: 204,
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if claimed[0].DeliveredAt == nil {
+		t.Errorf("expected delivered_at to be set")
+	}
+
+	// A retryable failure schedules another attempt.
+	if err := db.FailCallbackDelivery(claimed[1], &CallbackDeliveryAttempt{
+		URL:            "https://example.com/callback",
+		ResponseStatus: 500,
+	}, fmt.Errorf("oops"), true); err != nil {
+		t.Fatal(err)
+	}
+	if claimed[1].FailedAt != nil {
+		t.Errorf("expected failed_at to be nil")
+	}
+	if got, want := claimed[1].Attempts, 1; got != want {
+		t.Errorf("expected %d to be %d", got, want)
+	}
+	if got, want := claimed[1].LastError, "oops"; got != want {
+		t.Errorf("expected %q to be %q", got, want)
+	}
+	if !claimed[1].NextAttemptAt.After(time.Now()) {
+		t.Errorf("expected next attempt to be in the future")
+	}
+
+	// A permanent failure abandons the delivery.
+	if err := db.FailCallbackDelivery(second, nil, fmt.Errorf("gone"), false); err != nil {
+		t.Fatal(err)
+	}
+	if second.FailedAt == nil {
+		t.Errorf("expected failed_at to be set")
+	}
+
+	// Running out of attempts abandons the delivery.
+	third, err := db.EnqueueCallbackDelivery(app.ID, []byte(`{"n":3}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	third.Attempts = CallbackDeliveryMaxAttempts - 1
+	if err := db.FailCallbackDelivery(third, nil, fmt.Errorf("oops"), true); err != nil {
+		t.Fatal(err)
+	}
+	if third.FailedAt == nil {
+		t.Errorf("expected failed_at to be set")
+	}
+
+	// Attempts are captured with their number and error.
+	attempts, err := db.ListCallbackDeliveryAttempts(claimed[1].ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(attempts), 1; got != want {
+		t.Fatalf("expected %d attempts, got %d", want, got)
+	}
+	if got, want := attempts[0].Attempt, 1; got != want {
+		t.Errorf("expected %d to be %d", got, want)
+	}
+	if got, want := attempts[0].Error, "oops"; got != want {
+		t.Errorf("expected %q to be %q", got, want)
+	}
+	if attempts[0].Succeeded() {
+		t.Errorf("expected attempt to not have succeeded")
+	}
+
+	// Replays are new deliveries.
+	replay, err := db.ReplayCallbackDelivery(second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if replay.ID == second.ID {
+		t.Errorf("expected replay to have a new id")
+	}
+	if replay.ReplayOfID == nil || *replay.ReplayOfID != second.ID {
+		t.Errorf("expected replay of %d, got %v", second.ID, replay.ReplayOfID)
+	}
+	if got, want := replay.Status(), CallbackDeliveryStatusPending; got != want {
+		t.Errorf("expected %q to be %q", got, want)
+	}
+
+	// Listing is scoped to the realm and filterable by status.
+	deliveries, _, err := realm.ListCallbackDeliveries(db, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(deliveries), 4; got != want {
+		t.Errorf("expected %d deliveries, got %d", want, got)
+	}
+	failed, _, err := realm.ListCallbackDeliveries(db, nil, WithCallbackDeliveryStatus(CallbackDeliveryStatusFailed))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(failed), 2; got != want {
+		t.Errorf("expected %d failed deliveries, got %d", want, got)
+	}
+	count, err := realm.CountFailedCallbackDeliveries(db, time.Now().Add(-1*time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := count, uint64(2); got != want {
+		t.Errorf("expected %d to be %d", got, want)
+	}
+
+	otherRealm := NewRealmWithDefaults("other")
+	if err := db.SaveRealm(otherRealm, SystemTest); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := otherRealm.FindCallbackDelivery(db, first.ID); !IsNotFound(err) {
+		t.Errorf("expected not found, got %v", err)
+	}
+	if found, err := realm.FindCallbackDelivery(db, first.ID); err != nil {
+		t.Fatal(err)
+	} else if got, want := found.Status(), CallbackDeliveryStatusDelivered; got != want {
+		t.Errorf("expected %q to be %q", got, want)
+	}
+
+	// Purge everything.
+	purged, err := db.PurgeCallbackDeliveries(1 * time.Nanosecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := purged, int64(4); got != want {
+		t.Errorf("expected %d to be %d", got, want)
+	}
+
+	// Attempts are deleted with their delivery.
+	attempts, err = db.ListCallbackDeliveryAttempts(claimed[1].ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(attempts), 0; got != want {
+		t.Errorf("expected %d attempts, got %d", want, got)
+	}
+}
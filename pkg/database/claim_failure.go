@@ -0,0 +1,145 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package database
+
+import (
+	"fmt"
+	"time"
+)
+
+const (
+	// ClaimFailureInvalidCode is a claim of a code that does not exist or was
+	// already claimed.
+	ClaimFailureInvalidCode = "invalid_code"
+
+	// ClaimFailureExpired is a claim of an expired code.
+	ClaimFailureExpired = "expired"
+
+	// ClaimFailureWrongTestType is a claim of a code whose test type the client
+	// does not accept.
+	ClaimFailureWrongTestType = "wrong_test_type"
+
+	// ClaimFailureNonceMismatch is a claim of a user report code with a nonce
+	// that does not match the one provided at issue time.
+	ClaimFailureNonceMismatch = "nonce_mismatch"
+
+	// ClaimFailureQuota is a claim that was rejected by rate limiting.
+	ClaimFailureQuota = "quota"
+)
+
+// ClaimFailureReasons is the list of all claim failure reasons.
+var ClaimFailureReasons = []string{
+	ClaimFailureInvalidCode,
+	ClaimFailureExpired,
+	ClaimFailureWrongTestType,
+	ClaimFailureNonceMismatch,
+	ClaimFailureQuota,
+}
+
+// ClaimFailure is a single failed attempt to claim a verification code. These
+// are only retained for a short period of time to support near-real-time
+// diagnostics.
+type ClaimFailure struct {
+	// ID is the event's ID.
+	ID uint `gorm:"primary_key;"`
+
+	// RealmID and AuthorizedAppID identify the API key that made the request.
+	RealmID         uint `gorm:"column:realm_id; type:integer; not null;"`
+	AuthorizedAppID uint `gorm:"column:authorized_app_id; type:integer; not null;"`
+
+	// Reason is the reason the claim failed, one of the ClaimFailure* constants.
+	Reason string `gorm:"column:reason; type:text; not null;"`
+
+	// CreatedAt is when the failure occurred.
+	CreatedAt time.Time
+}
+
+// TableName sets the table name.
+func (ClaimFailure) TableName() string {
+	return "claim_failures"
+}
+
+// ClaimFailureSummary is the number of claim failures for a single API key and
+// reason.
+type ClaimFailureSummary struct {
+	RealmID           uint      `json:"realmID"`
+	RealmName         string    `json:"realmName"`
+	AuthorizedAppID   uint      `json:"authorizedAppID"`
+	AuthorizedAppName string    `json:"authorizedAppName"`
+	Reason            string    `json:"reason"`
+	Count             int64     `json:"count"`
+	LastSeenAt        time.Time `json:"lastSeenAt"`
+}
+
+// RecordClaimFailure records a failed claim by the given API key.
+func (db *Database) RecordClaimFailure(a *AuthorizedApp, reason string) error {
+	if a == nil {
+		return fmt.Errorf("provided API key is nil")
+	}
+
+	if err := db.db.Create(&ClaimFailure{
+		RealmID:         a.RealmID,
+		AuthorizedAppID: a.ID,
+		Reason:          reason,
+	}).Error; err != nil {
+		return fmt.Errorf("failed to record claim failure: %w", err)
+	}
+	return nil
+}
+
+// SummarizeClaimFailures returns the number of claim failures since the given
+// time, grouped by API key and reason, with the most frequent first.
+func (db *Database) SummarizeClaimFailures(since time.Time) ([]*ClaimFailureSummary, error) {
+	sql := `
+		SELECT
+			f.realm_id AS realm_id,
+			COALESCE(r.name, '') AS realm_name,
+			f.authorized_app_id AS authorized_app_id,
+			COALESCE(a.name, '') AS authorized_app_name,
+			f.reason AS reason,
+			COUNT(*) AS count,
+			MAX(f.created_at) AS last_seen_at
+		FROM claim_failures f
+		LEFT JOIN realms r ON r.id = f.realm_id
+		LEFT JOIN authorized_apps a ON a.id = f.authorized_app_id
+		WHERE f.created_at >= $1
+		GROUP BY f.realm_id, r.name, f.authorized_app_id, a.name, f.reason
+		ORDER BY count DESC, realm_id, authorized_app_id, reason`
+
+	var summaries []*ClaimFailureSummary
+	if err := db.db.Raw(sql, since).Scan(&summaries).Error; err != nil {
+		if IsNotFound(err) {
+			return summaries, nil
+		}
+		return nil, err
+	}
+	return summaries, nil
+}
+
+// PurgeClaimFailures deletes claim failures older than maxAge.
+func (db *Database) PurgeClaimFailures(maxAge time.Duration) (int64, error) {
+	if maxAge > 0 {
+		maxAge = -1 * maxAge
+	}
+	deleteBefore := time.Now().UTC().Add(maxAge)
+
+	result := db.db.
+		Where("created_at < ?", deleteBefore).
+		Delete(&ClaimFailure{})
+	if err := result.Error; err != nil {
+		return 0, fmt.Errorf("failed to purge claim failures: %w", err)
+	}
+	return result.RowsAffected, nil
+}
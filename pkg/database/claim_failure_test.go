@@ -0,0 +1,119 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package database
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDatabase_SummarizeClaimFailures(t *testing.T) {
+	t.Parallel()
+
+	db, _ := testDatabaseInstance.NewDatabase(t, nil)
+
+	realm, err := db.FindRealm(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	app := &AuthorizedApp{
+		RealmID:    realm.ID,
+		Name:       "Appy",
+		APIKeyType: APIKeyTypeDevice,
+	}
+	if _, err := realm.CreateAuthorizedApp(db, app, SystemTest); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := db.RecordClaimFailure(nil, ClaimFailureExpired); err == nil {
+		t.Errorf("expected error for nil API key")
+	}
+
+	for i := 0; i < 3; i++ {
+		if err := db.RecordClaimFailure(app, ClaimFailureExpired); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := db.RecordClaimFailure(app, ClaimFailureNonceMismatch); err != nil {
+		t.Fatal(err)
+	}
+
+	summaries, err := db.SummarizeClaimFailures(time.Now().Add(-1 * time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(summaries), 2; got != want {
+		t.Fatalf("expected %d summaries, got %d", want, got)
+	}
+
+	first := summaries[0]
+	if got, want := first.Reason, ClaimFailureExpired; got != want {
+		t.Errorf("expected %q to be %q", got, want)
+	}
+	if got, want := first.Count, int64(3); got != want {
+		t.Errorf("expected %d to be %d", got, want)
+	}
+	if got, want := first.AuthorizedAppName, "Appy"; got != want {
+		t.Errorf("expected %q to be %q", got, want)
+	}
+	if got, want := first.RealmName, realm.Name; got != want {
+		t.Errorf("expected %q to be %q", got, want)
+	}
+
+	summaries, err = db.SummarizeClaimFailures(time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(summaries), 0; got != want {
+		t.Errorf("expected %d summaries, got %d", want, got)
+	}
+}
+
+func TestDatabase_PurgeClaimFailures(t *testing.T) {
+	t.Parallel()
+
+	db, _ := testDatabaseInstance.NewDatabase(t, nil)
+
+	app := &AuthorizedApp{RealmID: 1}
+	app.ID = 1
+	for i := 0; i < 5; i++ {
+		if err := db.RecordClaimFailure(app, ClaimFailureInvalidCode); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Should not purge entries (too young).
+	{
+		n, err := db.PurgeClaimFailures(24 * time.Hour)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := n, int64(0); got != want {
+			t.Errorf("expected %d to purge, got %d", want, got)
+		}
+	}
+
+	// Purges entries.
+	{
+		n, err := db.PurgeClaimFailures(1 * time.Nanosecond)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := n, int64(5); got != want {
+			t.Errorf("expected %d to purge, got %d", want, got)
+		}
+	}
+}
@@ -0,0 +1,175 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package database
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jinzhu/gorm"
+)
+
+// codeInfoTokenWindow is how close to the claim time a token must have been
+// created to be reported as a candidate token for a claimed code.
+const codeInfoTokenWindow = time.Minute
+
+// CodeInfo is everything the database knows about a single verification code,
+// gathered for investigating a complaint about it. It is assembled directly
+// from the database and is not scoped to a realm, so it must only be exposed
+// to system administrators.
+type CodeInfo struct {
+	// Code is the verification code. Code and LongCode are HMACs and must never
+	// be shown.
+	Code *VerificationCode
+
+	// Realm is the realm that currently owns the code.
+	Realm *Realm
+
+	// IssuingUser, IssuingApp, and UserReport describe how the code was issued.
+	// They are nil when they do not apply or the record no longer exists.
+	IssuingUser *User
+	IssuingApp  *AuthorizedApp
+	UserReport  *UserReport
+
+	// CandidateTokens are the tokens that may have been issued for the code.
+	// Tokens do not reference the code they were exchanged for, so these are
+	// the tokens in the same realm, with the same test metadata, that were
+	// created within a minute of the code being claimed.
+	CandidateTokens []*Token
+
+	// Audits are the audit entries that target the code, oldest first. This
+	// includes resends, early expirations, and transfers.
+	Audits []*AuditEntry
+
+	// Transfers are the transfers of the code between realms, oldest first.
+	Transfers []*VerificationCodeTransfer
+
+	// Captures are the sampled API request captures that mention the code's
+	// UUID, oldest first. They only exist if capture was enabled on the API key
+	// that made the request.
+	Captures []*APICapture
+}
+
+// FindCodeInfo gathers the CodeInfo for the code with the given UUID in any
+// realm, including codes that were soft deleted. It returns NotFound if the
+// UUID is invalid or there is no such code.
+func (db *Database) FindCodeInfo(uuidStr string) (*CodeInfo, error) {
+	// Postgres returns an error if the provided input is not a valid UUID.
+	parsed, err := uuid.Parse(uuidStr)
+	if err != nil {
+		return nil, gorm.ErrRecordNotFound
+	}
+	uuidStr = parsed.String()
+
+	var vc VerificationCode
+	if err := db.db.
+		Unscoped().
+		Where("uuid = ?", uuidStr).
+		First(&vc).
+		Error; err != nil {
+		return nil, err
+	}
+
+	info := &CodeInfo{Code: &vc}
+
+	realm, err := db.FindRealm(vc.RealmID)
+	if err != nil && !IsNotFound(err) {
+		return nil, fmt.Errorf("failed to find realm: %w", err)
+	}
+	info.Realm = realm
+
+	if vc.IssuingUserID != 0 {
+		user, err := db.FindUser(vc.IssuingUserID)
+		if err != nil && !IsNotFound(err) {
+			return nil, fmt.Errorf("failed to find issuing user: %w", err)
+		}
+		info.IssuingUser = user
+	}
+
+	if vc.IssuingAppID != 0 {
+		app, err := db.FindAuthorizedApp(vc.IssuingAppID)
+		if err != nil && !IsNotFound(err) {
+			return nil, fmt.Errorf("failed to find issuing app: %w", err)
+		}
+		info.IssuingApp = app
+	}
+
+	if vc.UserReportID != nil {
+		var ur UserReport
+		if err := db.db.
+			Where("id = ?", *vc.UserReportID).
+			First(&ur).
+			Error; err != nil {
+			if !IsNotFound(err) {
+				return nil, fmt.Errorf("failed to find user report: %w", err)
+			}
+		} else {
+			info.UserReport = &ur
+		}
+	}
+
+	if vc.Claimed {
+		q := db.db.
+			Model(&Token{}).
+			Where("realm_id = ? AND test_type = ?", vc.RealmID, vc.TestType).
+			Where("created_at BETWEEN ? AND ?", vc.UpdatedAt.Add(-codeInfoTokenWindow), vc.UpdatedAt.Add(codeInfoTokenWindow))
+		if vc.SymptomDate != nil {
+			q = q.Where("symptom_date = ?", vc.SymptomDate)
+		} else {
+			q = q.Where("symptom_date IS NULL")
+		}
+		if vc.TestDate != nil {
+			q = q.Where("test_date = ?", vc.TestDate)
+		} else {
+			q = q.Where("test_date IS NULL")
+		}
+		if err := q.
+			Order("created_at ASC, id ASC").
+			Find(&info.CandidateTokens).
+			Error; err != nil && !IsNotFound(err) {
+			return nil, fmt.Errorf("failed to find candidate tokens: %w", err)
+		}
+	}
+
+	if err := db.db.
+		Model(&AuditEntry{}).
+		Where("target_id = ?", vc.AuditID()).
+		Order("created_at ASC, id ASC").
+		Find(&info.Audits).
+		Error; err != nil && !IsNotFound(err) {
+		return nil, fmt.Errorf("failed to list audit entries: %w", err)
+	}
+
+	transfers, err := db.ListVerificationCodeTransfers(uuidStr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list transfers: %w", err)
+	}
+	info.Transfers = transfers
+
+	// Captures are purged after a short retention period, so searching the
+	// redacted bodies is cheap enough for an occasional investigation.
+	like := "%" + uuidStr + "%"
+	if err := db.db.
+		Model(&APICapture{}).
+		Where("request_body LIKE ? OR response_body LIKE ?", like, like).
+		Order("created_at ASC, id ASC").
+		Find(&info.Captures).
+		Error; err != nil && !IsNotFound(err) {
+		return nil, fmt.Errorf("failed to list api captures: %w", err)
+	}
+
+	return info, nil
+}
@@ -0,0 +1,89 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package database
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDatabase_FindCodeInfo(t *testing.T) {
+	t.Parallel()
+
+	db, _ := testDatabaseInstance.NewDatabase(t, nil)
+	realm, err := db.FindRealm(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("invalid_uuid", func(t *testing.T) {
+		t.Parallel()
+
+		if _, err := db.FindCodeInfo("not-a-uuid"); !IsNotFound(err) {
+			t.Errorf("expected %v to be not found", err)
+		}
+	})
+
+	t.Run("unknown_uuid", func(t *testing.T) {
+		t.Parallel()
+
+		if _, err := db.FindCodeInfo("e4d2a3b1-8c8c-4b8e-9f1a-0d7c7a3f1e55"); !IsNotFound(err) {
+			t.Errorf("expected %v to be not found", err)
+		}
+	})
+
+	t.Run("found", func(t *testing.T) {
+		t.Parallel()
+
+		vc := &VerificationCode{
+			RealmID:       realm.ID,
+			Code:          "18329473",
+			LongCode:      "18329473abcdef",
+			TestType:      "confirmed",
+			ExpiresAt:     time.Now().Add(time.Hour),
+			LongExpiresAt: time.Now().Add(2 * time.Hour),
+		}
+		if err := realm.SaveVerificationCode(db, vc); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := realm.ExpireCode(db, vc.UUID, SystemTest); err != nil {
+			t.Fatal(err)
+		}
+
+		info, err := db.FindCodeInfo(vc.UUID)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if got, want := info.Code.ID, vc.ID; got != want {
+			t.Errorf("expected code %d to be %d", got, want)
+		}
+		if info.Realm == nil || info.Realm.ID != realm.ID {
+			t.Errorf("expected realm %d, got %#v", realm.ID, info.Realm)
+		}
+		if info.IssuingUser != nil || info.IssuingApp != nil || info.UserReport != nil {
+			t.Errorf("expected no issuer, got %#v", info)
+		}
+		if got := len(info.CandidateTokens); got != 0 {
+			t.Errorf("expected no candidate tokens, got %d", got)
+		}
+		if got, want := len(info.Audits), 1; got != want {
+			t.Fatalf("expected %d audits, got %d", want, got)
+		}
+		if got, want := info.Audits[0].Action, "expired verification code"; got != want {
+			t.Errorf("expected %q to be %q", got, want)
+		}
+	})
+}
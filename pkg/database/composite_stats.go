@@ -43,10 +43,13 @@ type CompositeDay struct {
 	Day            time.Time
 	RealmStats     *RealmStat
 	KeyServerStats *keyserver.StatsDay
+
+	// Annotations are the realm admin provided notes for this day.
+	Annotations []string
 }
 
 func (c *CompositeDay) IsEmpty() bool {
-	return c.RealmStats.IsEmpty() && c.KeyServerStats.IsEmpty()
+	return c.RealmStats.IsEmpty() && c.KeyServerStats.IsEmpty() && len(c.Annotations) == 0
 }
 
 type jsonCompositeStat struct {
@@ -56,8 +59,9 @@ type jsonCompositeStat struct {
 }
 
 type jsonCompositeStatStats struct {
-	Date time.Time                   `json:"date"`
-	Data *jsonCompositeStatStatsData `json:"data"`
+	Date        time.Time                   `json:"date"`
+	Data        *jsonCompositeStatStatsData `json:"data"`
+	Annotations []string                    `json:"annotations,omitempty"`
 }
 
 type jsonCompositeStatStatsData struct {
@@ -119,8 +123,9 @@ func (c CompositeStats) MarshalJSON() ([]byte, error) {
 		}
 
 		stats = append(stats, &jsonCompositeStatStats{
-			Date: stat.Day,
-			Data: data,
+			Date:        stat.Day,
+			Data:        data,
+			Annotations: stat.Annotations,
 		})
 	}
 
@@ -168,6 +173,7 @@ func (c CompositeStats) MarshalCSV() ([]byte, error) {
 		"user_reports_issued", "user_reports_claimed", "user_report_tokens_claimed",
 		"codes_invalid_unknown_os", "codes_invalid_ios", "codes_invalid_android",
 		"user_reports_invalid_nonce", "user_reports_invalid_nonce_unknown_os", "user_reports_invalid_nonce_ios", "user_reports_invalid_nonce_android",
+		"annotations",
 	}); err != nil {
 		return nil, fmt.Errorf("failed to write CSV header: %w", err)
 	}
@@ -237,6 +243,8 @@ func (c CompositeStats) MarshalCSV() ([]byte, error) {
 			row = append(row, strconv.FormatUint(uint64(stat.RealmStats.UserReportsInvalidNonceByOS[OSTypeAndroid]), 10))
 		}
 
+		row = append(row, strings.Join(stat.Annotations, "|"))
+
 		// New stats should always be added to the end to preserve existing external user applications.
 
 		if err := w.Write(row); err != nil {
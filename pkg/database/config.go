@@ -21,6 +21,7 @@ import (
 
 	"github.com/google/exposure-notifications-server/pkg/keys"
 	"github.com/google/exposure-notifications-server/pkg/secrets"
+	"github.com/google/exposure-notifications-verification-server/pkg/workloadidentity"
 )
 
 // Config represents the env var based configuration for database connections.
@@ -46,6 +47,11 @@ type Config struct {
 	// commands.
 	Debug bool `env:"DB_DEBUG,default=false"`
 
+	// FakeSMS allows the NOOP_INSPECT SMS provider, which persists message
+	// bodies instead of sending them. It is refused unless this is set. Do not
+	// enable in production environments.
+	FakeSMS bool `env:"DEV_FAKE_SMS"`
+
 	// Keys is the key management configuration. This is used to resolve values
 	// that are encrypted via a KMS.
 	Keys keys.Config `env:",prefix=DB_"`
@@ -62,11 +68,21 @@ type Config struct {
 	// database.
 	EncryptionKey string `env:"DB_ENCRYPTION_KEY,required" json:"-"`
 
+	// DualWriteDSN is the connection string for a secondary database. When set,
+	// every write made against this database is mirrored to the secondary. This
+	// should only be set while moving between database instances. See
+	// docs/production.md for the full procedure.
+	DualWriteDSN string `env:"DB_DUAL_WRITE_DSN" json:"-"`
+
 	// Secrets is the secret configuration. This is used to resolve values that
 	// are actually pointers to secrets before returning them to the caller. The
 	// table implementation is the source of truth for which values are secrets
 	// and which are plaintext.
 	Secrets secrets.Config
+
+	// WorkloadIdentity configures workload identity federation for the secret
+	// manager and key manager when they are hosted on Google Cloud.
+	WorkloadIdentity workloadidentity.Config
 }
 
 // ConnectionString returns the postgresql connection string based on this config.
@@ -100,6 +116,7 @@ func (c *Config) clone() *Config {
 		MaxConnectionLifetime: c.MaxConnectionLifetime,
 		MaxConnectionIdleTime: c.MaxConnectionIdleTime,
 		Debug:                 c.Debug,
+		FakeSMS:               c.FakeSMS,
 		Keys: keys.Config{
 			Type:           c.Keys.Type,
 			CreateHSMKeys:  c.Keys.CreateHSMKeys,
@@ -108,12 +125,27 @@ func (c *Config) clone() *Config {
 		KeyRing:        c.KeyRing,
 		MaxKeyVersions: c.MaxKeyVersions,
 		EncryptionKey:  c.EncryptionKey,
+		DualWriteDSN:   c.DualWriteDSN,
 		Secrets: secrets.Config{
 			Type:            c.Secrets.Type,
 			SecretsDir:      c.Secrets.SecretsDir,
 			SecretCacheTTL:  c.Secrets.SecretCacheTTL,
 			SecretExpansion: c.Secrets.SecretExpansion,
 		},
+		WorkloadIdentity: workloadidentity.Config{
+			Audience:       c.WorkloadIdentity.Audience,
+			TokenFile:      c.WorkloadIdentity.TokenFile,
+			TokenURL:       c.WorkloadIdentity.TokenURL,
+			TokenField:     c.WorkloadIdentity.TokenField,
+			ServiceAccount: c.WorkloadIdentity.ServiceAccount,
+		},
+	}
+
+	if h := c.WorkloadIdentity.TokenHeaders; h != nil {
+		cfg.WorkloadIdentity.TokenHeaders = make(map[string]string, len(h))
+		for k, v := range h {
+			cfg.WorkloadIdentity.TokenHeaders[k] = v
+		}
 	}
 
 	return cfg
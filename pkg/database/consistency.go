@@ -0,0 +1,200 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package database
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/exposure-notifications-server/pkg/keys"
+	"github.com/jinzhu/gorm"
+)
+
+const (
+	// ConsistencyOrphanedTokens are unexpired tokens whose realm was deleted.
+	// Tokens do not reference the code from which they were issued, so the realm
+	// is the only relationship that can be checked.
+	ConsistencyOrphanedTokens = "orphaned_tokens"
+
+	// ConsistencyOrphanedMemberships are memberships whose realm was deleted.
+	ConsistencyOrphanedMemberships = "orphaned_memberships"
+
+	// ConsistencyOrphanedStats are statistics rows whose realm was deleted.
+	ConsistencyOrphanedStats = "orphaned_stats"
+
+	// ConsistencyMissingRealmSigningKey are realms configured to use a
+	// realm-specific certificate signing key without an active key.
+	ConsistencyMissingRealmSigningKey = "missing_realm_signing_key"
+
+	// ConsistencyMissingTokenSigningKey is reported when there is no active
+	// token signing key, or the active key cannot be loaded from the KMS.
+	ConsistencyMissingTokenSigningKey = "missing_token_signing_key"
+)
+
+// ConsistencyChecks is the list of all consistency checks.
+var ConsistencyChecks = []string{
+	ConsistencyOrphanedTokens,
+	ConsistencyOrphanedMemberships,
+	ConsistencyOrphanedStats,
+	ConsistencyMissingRealmSigningKey,
+	ConsistencyMissingTokenSigningKey,
+}
+
+// consistencyStatsTables are the statistics tables that are keyed by realm.
+var consistencyStatsTables = []string{
+	"external_issuer_stats",
+	"realm_stats",
+	"sms_error_stats",
+	"user_stats",
+}
+
+// ConsistencyFinding is a single problem found by a consistency check.
+type ConsistencyFinding struct {
+	// Check is the name of the check, one of the Consistency* constants.
+	Check string `json:"check"`
+
+	// Count is the number of affected rows.
+	Count int64 `json:"count"`
+
+	// Message is a human-readable description of the finding.
+	Message string `json:"message"`
+}
+
+// CheckConsistency runs the database consistency checks and returns any
+// findings. It does not modify any data.
+func (db *Database) CheckConsistency() ([]*ConsistencyFinding, error) {
+	var findings []*ConsistencyFinding
+
+	add := func(check string, count int64, msg string, args ...interface{}) {
+		if count > 0 {
+			findings = append(findings, &ConsistencyFinding{
+				Check:   check,
+				Count:   count,
+				Message: fmt.Sprintf(msg, args...),
+			})
+		}
+	}
+
+	// Tokens for deleted realms.
+	{
+		var count int64
+		if err := db.db.
+			Table("tokens").
+			Joins("LEFT JOIN realms ON realms.id = tokens.realm_id").
+			Where("tokens.deleted_at IS NULL").
+			Where("tokens.expires_at > NOW()").
+			Where("(realms.id IS NULL OR realms.deleted_at IS NOT NULL)").
+			Count(&count).
+			Error; err != nil {
+			return nil, fmt.Errorf("failed to check tokens: %w", err)
+		}
+		add(ConsistencyOrphanedTokens, count, "%d unexpired tokens belong to deleted realms", count)
+	}
+
+	// Memberships for deleted realms.
+	{
+		var count int64
+		if err := db.db.
+			Table("memberships").
+			Joins("LEFT JOIN realms ON realms.id = memberships.realm_id").
+			Where("(realms.id IS NULL OR realms.deleted_at IS NOT NULL)").
+			Count(&count).
+			Error; err != nil {
+			return nil, fmt.Errorf("failed to check memberships: %w", err)
+		}
+		add(ConsistencyOrphanedMemberships, count, "%d memberships belong to deleted realms", count)
+	}
+
+	// Statistics for deleted realms.
+	for _, table := range consistencyStatsTables {
+		var count int64
+		if err := db.db.
+			Table(table).
+			Joins(fmt.Sprintf("LEFT JOIN realms ON realms.id = %s.realm_id", table)).
+			Where(fmt.Sprintf("%s.realm_id != 0", table)).
+			Where("(realms.id IS NULL OR realms.deleted_at IS NOT NULL)").
+			Count(&count).
+			Error; err != nil {
+			return nil, fmt.Errorf("failed to check %s: %w", table, err)
+		}
+		add(ConsistencyOrphanedStats, count, "%d %s rows belong to deleted realms", count, table)
+	}
+
+	// Realms using realm-specific keys without an active key.
+	{
+		var count int64
+		if err := db.db.
+			Model(&Realm{}).
+			Where("use_realm_certificate_key = ?", true).
+			Where(`NOT EXISTS (
+				SELECT 1 FROM signing_keys
+				WHERE signing_keys.realm_id = realms.id
+				AND signing_keys.active = true
+				AND signing_keys.deleted_at IS NULL
+			)`).
+			Count(&count).
+			Error; err != nil {
+			return nil, fmt.Errorf("failed to check realm signing keys: %w", err)
+		}
+		add(ConsistencyMissingRealmSigningKey, count, "%d realms use realm-specific certificate keys without an active signing key", count)
+	}
+
+	return findings, nil
+}
+
+// CheckTokenSigningKeyConsistency verifies that there is an active token
+// signing key and that it can be loaded from the given key manager. It returns
+// nil if the key is healthy.
+func (db *Database) CheckTokenSigningKeyConsistency(ctx context.Context, kms keys.KeyManager) (*ConsistencyFinding, error) {
+	key, err := db.ActiveTokenSigningKey()
+	if err != nil {
+		if IsNotFound(err) {
+			return &ConsistencyFinding{
+				Check:   ConsistencyMissingTokenSigningKey,
+				Count:   1,
+				Message: "there is no active token signing key",
+			}, nil
+		}
+		return nil, fmt.Errorf("failed to get active token signing key: %w", err)
+	}
+
+	if _, err := kms.NewSigner(ctx, key.KeyVersionID); err != nil {
+		return &ConsistencyFinding{
+			Check:   ConsistencyMissingTokenSigningKey,
+			Count:   1,
+			Message: fmt.Sprintf("active token signing key %s is not available in the KMS: %s", key.UUID, err),
+		}, nil
+	}
+	return nil, nil
+}
+
+// RecordConsistencyFindings records the findings in the system audit log, which
+// is visible to system admins.
+func (db *Database) RecordConsistencyFindings(findings []*ConsistencyFinding) error {
+	if len(findings) == 0 {
+		return nil
+	}
+
+	return db.db.Transaction(func(tx *gorm.DB) error {
+		for _, finding := range findings {
+			audit := BuildAuditEntry(System, "consistency check found "+finding.Check, System, 0)
+			audit.Diff = finding.Message
+			if err := tx.Save(audit).Error; err != nil {
+				return fmt.Errorf("failed to save audits: %w", err)
+			}
+		}
+		return nil
+	})
+}
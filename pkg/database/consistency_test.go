@@ -0,0 +1,105 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package database
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDatabase_CheckConsistency(t *testing.T) {
+	t.Parallel()
+
+	db, _ := testDatabaseInstance.NewDatabase(t, nil)
+
+	// A fresh database has no findings.
+	findings, err := db.CheckConsistency()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(findings), 0; got != want {
+		t.Fatalf("expected %d findings, got %d: %#v", want, got, findings)
+	}
+
+	realm := NewRealmWithDefaults("deleted")
+	if err := db.SaveRealm(realm, SystemTest); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := db.db.Create(&Token{
+		RealmID:   realm.ID,
+		TokenID:   "orphaned-token",
+		TestType:  "confirmed",
+		ExpiresAt: time.Now().UTC().Add(time.Hour),
+	}).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := db.db.Exec(`INSERT INTO realm_stats(date, realm_id, codes_issued) VALUES (CURRENT_DATE, ?, 1)`, realm.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	// Soft-delete the realm.
+	if err := db.db.Delete(realm).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	// A realm that requires its own signing key but has none.
+	keyless := NewRealmWithDefaults("keyless")
+	if err := db.SaveRealm(keyless, SystemTest); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.db.Model(keyless).UpdateColumn("use_realm_certificate_key", true).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	findings, err = db.CheckConsistency()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := make(map[string]int64, len(findings))
+	for _, f := range findings {
+		got[f.Check] += f.Count
+	}
+
+	for check, want := range map[string]int64{
+		ConsistencyOrphanedTokens:         1,
+		ConsistencyOrphanedStats:          1,
+		ConsistencyMissingRealmSigningKey: 1,
+	} {
+		if got[check] != want {
+			t.Errorf("expected %s to have %d findings, got %d", check, want, got[check])
+		}
+	}
+
+	if err := db.RecordConsistencyFindings(findings); err != nil {
+		t.Fatal(err)
+	}
+
+	audits, _, err := db.ListAudits(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var found int
+	for _, audit := range audits {
+		if audit.RealmID == 0 && audit.Action == "consistency check found "+ConsistencyOrphanedTokens {
+			found++
+		}
+	}
+	if found != 1 {
+		t.Errorf("expected 1 audit entry for orphaned tokens, got %d", found)
+	}
+}
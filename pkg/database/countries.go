@@ -14,6 +14,13 @@
 
 package database
 
+import (
+	"sort"
+	"strings"
+
+	"github.com/google/exposure-notifications-verification-server/internal/project"
+)
+
 var Countries = map[string]string{
 	"Afghanistan":                      "af",
 	"Aland Islands":                    "ax",
@@ -258,3 +265,34 @@ var Countries = map[string]string{
 	"Zambia":                           "zm",
 	"Zimbabwe":                         "zw",
 }
+
+// isKnownCountryCode returns true if the given lowercase code is one of the
+// values in Countries.
+func isKnownCountryCode(code string) bool {
+	for _, v := range Countries {
+		if v == code {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizeCountryList lowercases, trims, de-duplicates, and sorts the given
+// country codes. Blank entries are removed.
+func normalizeCountryList(in []string) []string {
+	seen := make(map[string]struct{}, len(in))
+	out := make([]string, 0, len(in))
+	for _, v := range in {
+		v = strings.ToLower(project.TrimSpace(v))
+		if v == "" {
+			continue
+		}
+		if _, ok := seen[v]; ok {
+			continue
+		}
+		seen[v] = struct{}{}
+		out = append(out, v)
+	}
+	sort.Strings(out)
+	return out
+}
@@ -0,0 +1,214 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package database
+
+import (
+	"strings"
+	"time"
+
+	"github.com/google/exposure-notifications-verification-server/internal/project"
+	"github.com/google/exposure-notifications-verification-server/pkg/pagination"
+	"github.com/jinzhu/gorm"
+)
+
+const (
+	// DataAccessFieldEmail indicates a user's email address was read.
+	DataAccessFieldEmail = "email"
+
+	// DataAccessFieldPhone indicates a phone number was read.
+	DataAccessFieldPhone = "phone_number"
+)
+
+// DataAccessLog records a read of personally identifiable information. Audit
+// entries only capture changes, but privacy officers also need to know who
+// viewed PII. Like AuditEntry, it does NOT make use of foreign keys so that the
+// record survives changes to the data which was read. These records should be
+// considered immutable.
+type DataAccessLog struct {
+	Errorable
+
+	// ID is the entry's ID.
+	ID uint `gorm:"primary_key;"`
+
+	// RealmID is the ID of the realm whose data was read.
+	RealmID uint `gorm:"column:realm_id; type:integer; not null;"`
+
+	// ActorID and ActorDisplay identify who read the data. See AuditEntry.
+	ActorID      string `gorm:"column:actor_id; type:text; not null;"`
+	ActorDisplay string `gorm:"column:actor_display; type:text; not null;"`
+
+	// Action describes how the data was read (e.g. "exported users").
+	Action string `gorm:"column:action; type:text; not null;"`
+
+	// TargetID and TargetDisplay identify the record that was read. When many
+	// records are read at once, the target is the realm.
+	TargetID      string `gorm:"column:target_id; type:text; not null;"`
+	TargetDisplay string `gorm:"column:target_display; type:text; not null;"`
+
+	// Fields is the comma-separated list of PII fields that were read.
+	Fields string `gorm:"column:fields; type:text; not null;"`
+
+	// Records is the number of records that were read.
+	Records uint `gorm:"column:records; type:integer; not null;"`
+
+	// CreatedAt is when the data was read.
+	CreatedAt time.Time
+}
+
+// BuildDataAccessLog builds a DataAccessLog from the given parameters.
+func BuildDataAccessLog(actor Auditable, action string, target Auditable, realmID uint, records uint, fields ...string) *DataAccessLog {
+	var l DataAccessLog
+	l.RealmID = realmID
+	l.ActorID = actor.AuditID()
+	l.ActorDisplay = actor.AuditDisplay()
+	l.Action = action
+	l.TargetID = target.AuditID()
+	l.TargetDisplay = target.AuditDisplay()
+	l.Fields = strings.Join(fields, ",")
+	l.Records = records
+	return &l
+}
+
+// FieldsList returns the fields that were read.
+func (l *DataAccessLog) FieldsList() []string {
+	if l.Fields == "" {
+		return nil
+	}
+	return strings.Split(l.Fields, ",")
+}
+
+// BeforeSave runs validations. If there are errors, the save fails.
+func (l *DataAccessLog) BeforeSave(tx *gorm.DB) error {
+	if l.RealmID == 0 {
+		l.AddError("realm_id", "cannot be blank")
+	}
+
+	if l.ActorID == "" {
+		l.AddError("actor_id", "cannot be blank")
+	}
+	if l.ActorDisplay == "" {
+		l.AddError("actor_display", "cannot be blank")
+	}
+
+	if l.Action == "" {
+		l.AddError("action", "cannot be blank")
+	}
+
+	if l.TargetID == "" {
+		l.AddError("target_id", "cannot be blank")
+	}
+	if l.TargetDisplay == "" {
+		l.AddError("target_display", "cannot be blank")
+	}
+
+	if l.Fields == "" {
+		l.AddError("fields", "cannot be blank")
+	}
+
+	return l.ErrorOrNil()
+}
+
+// SaveDataAccessLog saves the data access log.
+func (db *Database) SaveDataAccessLog(l *DataAccessLog) error {
+	return db.db.Save(l).Error
+}
+
+// RecordDataAccess builds and saves a data access log. Callers must record the
+// access before returning the data, and must not return the data if recording
+// fails.
+func (db *Database) RecordDataAccess(actor Auditable, action string, target Auditable, realmID uint, records uint, fields ...string) error {
+	return db.SaveDataAccessLog(BuildDataAccessLog(actor, action, target, realmID, records, fields...))
+}
+
+// PurgeDataAccessLogs will delete data access logs which were created longer
+// than maxAge ago.
+func (db *Database) PurgeDataAccessLogs(maxAge time.Duration) (int64, error) {
+	if maxAge > 0 {
+		maxAge = -1 * maxAge
+	}
+	createdBefore := time.Now().UTC().Add(maxAge)
+
+	result := db.db.
+		Unscoped().
+		Where("created_at < ?", createdBefore).
+		Delete(&DataAccessLog{})
+	return result.RowsAffected, result.Error
+}
+
+// ListDataAccessLogs returns the data access logs which match the given
+// criteria. Use Realm.ListDataAccessLogs to scope the list to a realm.
+func (db *Database) ListDataAccessLogs(p *pagination.PageParams, scopes ...Scope) ([]*DataAccessLog, *pagination.Paginator, error) {
+	var logs []*DataAccessLog
+
+	query := db.db.
+		Model(&DataAccessLog{}).
+		Scopes(scopes...).
+		Order("created_at DESC")
+
+	if p == nil {
+		p = new(pagination.PageParams)
+	}
+
+	paginator, err := Paginate(query, &logs, p.Page, p.Limit)
+	if err != nil {
+		if IsNotFound(err) {
+			return logs, nil, nil
+		}
+		return nil, nil, err
+	}
+
+	return logs, paginator, nil
+}
+
+// ListDataAccessLogs returns the data access logs for the realm.
+func (r *Realm) ListDataAccessLogs(db *Database, p *pagination.PageParams, scopes ...Scope) ([]*DataAccessLog, *pagination.Paginator, error) {
+	scopes = append(scopes, withDataAccessRealmID(r.ID))
+	return db.ListDataAccessLogs(p, scopes...)
+}
+
+// WithDataAccessTime returns a scope that filters data access logs to those
+// created between from and to. Either may be blank.
+func WithDataAccessTime(from, to string) Scope {
+	return func(db *gorm.DB) *gorm.DB {
+		from = project.TrimSpace(from)
+		if from != "" {
+			db = db.Where("data_access_logs.created_at >= ?", from)
+		}
+
+		to = project.TrimSpace(to)
+		if to != "" {
+			db = db.Where("data_access_logs.created_at <= ?", to)
+		}
+		return db
+	}
+}
+
+// WithDataAccessActorID returns a scope that filters data access logs to those
+// made by the given actor.
+func WithDataAccessActorID(actorID string) Scope {
+	return func(db *gorm.DB) *gorm.DB {
+		actorID = project.TrimSpace(actorID)
+		if actorID == "" {
+			return db
+		}
+		return db.Where("data_access_logs.actor_id = ?", actorID)
+	}
+}
+
+func withDataAccessRealmID(id uint) Scope {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where("data_access_logs.realm_id = ?", id)
+	}
+}
@@ -0,0 +1,161 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package database
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/exposure-notifications-verification-server/pkg/pagination"
+	"github.com/google/go-cmp/cmp"
+	"github.com/jinzhu/gorm"
+)
+
+func TestDataAccessLog_BeforeSave(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		structField string
+		field       string
+	}{
+		{"RealmID", "realm_id"},
+		{"ActorID", "actor_id"},
+		{"ActorDisplay", "actor_display"},
+		{"Action", "action"},
+		{"TargetID", "target_id"},
+		{"TargetDisplay", "target_display"},
+		{"Fields", "fields"},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.field, func(t *testing.T) {
+			t.Parallel()
+			exerciseValidation(t, &DataAccessLog{}, tc.structField, tc.field)
+		})
+	}
+}
+
+func TestBuildDataAccessLog(t *testing.T) {
+	t.Parallel()
+
+	user := &User{Model: gorm.Model{ID: 3}, Name: "Tester", Email: "tester@example.com"}
+	realm := &Realm{Model: gorm.Model{ID: 7}, Name: "Realmy"}
+
+	l := BuildDataAccessLog(user, "exported users", realm, realm.ID, 12, DataAccessFieldEmail, DataAccessFieldPhone)
+
+	if got, want := l.ActorID, "users:3"; got != want {
+		t.Errorf("expected %q to be %q", got, want)
+	}
+	if got, want := l.TargetID, "realms:7"; got != want {
+		t.Errorf("expected %q to be %q", got, want)
+	}
+	if got, want := l.RealmID, uint(7); got != want {
+		t.Errorf("expected %d to be %d", got, want)
+	}
+	if got, want := l.Records, uint(12); got != want {
+		t.Errorf("expected %d to be %d", got, want)
+	}
+	if got, want := l.FieldsList(), []string{"email", "phone_number"}; !cmp.Equal(got, want) {
+		t.Errorf("expected %q to be %q", got, want)
+	}
+}
+
+func TestDatabase_RecordDataAccess(t *testing.T) {
+	t.Parallel()
+
+	db, _ := testDatabaseInstance.NewDatabase(t, nil)
+
+	realm := NewRealmWithDefaults("Realmy")
+	if err := db.SaveRealm(realm, SystemTest); err != nil {
+		t.Fatal(err)
+	}
+
+	user := &User{Model: gorm.Model{ID: 3}, Name: "Tester", Email: "tester@example.com"}
+	if err := db.RecordDataAccess(user, "viewed user", user, realm.ID, 1, DataAccessFieldEmail); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.RecordDataAccess(user, "viewed user", user, realm.ID+1, 1, DataAccessFieldEmail); err != nil {
+		t.Fatal(err)
+	}
+
+	// Fields are required.
+	if err := db.RecordDataAccess(user, "viewed user", user, realm.ID, 1); err == nil {
+		t.Errorf("expected error")
+	}
+
+	logs, _, err := realm.ListDataAccessLogs(db, &pagination.PageParams{Limit: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(logs), 1; got != want {
+		t.Fatalf("expected %d logs, got %d: %#v", want, got, logs)
+	}
+	if got, want := logs[0].ActorDisplay, "Tester (tester@example.com)"; got != want {
+		t.Errorf("expected %q to be %q", got, want)
+	}
+
+	// Filters by actor.
+	logs, _, err = realm.ListDataAccessLogs(db, nil, WithDataAccessActorID("users:4"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(logs), 0; got != want {
+		t.Errorf("expected %d logs, got %d: %#v", want, got, logs)
+	}
+}
+
+func TestDatabase_PurgeDataAccessLogs(t *testing.T) {
+	t.Parallel()
+
+	db, _ := testDatabaseInstance.NewDatabase(t, nil)
+	for i := 0; i < 5; i++ {
+		if err := db.SaveDataAccessLog(&DataAccessLog{
+			RealmID:       1,
+			ActorID:       "actor:1",
+			ActorDisplay:  "Actor",
+			Action:        "viewed",
+			TargetID:      "target:1",
+			TargetDisplay: "Target",
+			Fields:        DataAccessFieldEmail,
+			Records:       1,
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Should not purge entries (too young).
+	{
+		n, err := db.PurgeDataAccessLogs(24 * time.Hour)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := n, int64(0); got != want {
+			t.Errorf("expected %d to purge, got %d", want, got)
+		}
+	}
+
+	// Purges entries.
+	{
+		n, err := db.PurgeDataAccessLogs(1 * time.Nanosecond)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := n, int64(5); got != want {
+			t.Errorf("expected %d to purge, got %d", want, got)
+		}
+	}
+}
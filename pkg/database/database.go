@@ -16,6 +16,7 @@
 package database
 
 import (
+	"bytes"
 	"context"
 	"crypto/hmac"
 	"crypto/sha512"
@@ -38,7 +39,9 @@ import (
 	"github.com/google/exposure-notifications-server/pkg/secrets"
 	"github.com/google/exposure-notifications-verification-server/internal/buildinfo"
 	"github.com/google/exposure-notifications-verification-server/pkg/cache"
+	"github.com/google/exposure-notifications-verification-server/pkg/jwthelper"
 	"github.com/google/exposure-notifications-verification-server/pkg/observability"
+	"github.com/google/exposure-notifications-verification-server/pkg/workloadidentity"
 	"github.com/jinzhu/gorm"
 	"github.com/sethvargo/go-retry"
 	"go.opencensus.io/stats"
@@ -56,12 +59,57 @@ const (
 	// pgCodeUniqueViolation is the error code for uniquess violations
 	// (constraints/indexes).
 	pgCodeUniqueViolation = "23505"
+
+	// fakeSMSKey is the gorm setting under which the database's fake SMS
+	// setting is stored for model callbacks.
+	fakeSMSKey = "verification:fake_sms"
+
+	// signingAlgorithmsKey is the gorm setting under which the signing
+	// algorithms supported by the key manager are stored for model callbacks.
+	signingAlgorithmsKey = "verification:signing_algorithms"
 )
 
 // callbackLock prevents multiple callbacks from being registered
 // simultaneously because that's a data race in gorm.
 var callbackLock sync.Mutex
 
+// allowsFakeSMS returns true if the database handle was opened with fake SMS
+// enabled.
+func allowsFakeSMS(tx *gorm.DB) bool {
+	if tx == nil {
+		return false
+	}
+	v, ok := tx.Get(fakeSMSKey)
+	if !ok {
+		return false
+	}
+	fakeSMS, _ := v.(bool)
+	return fakeSMS
+}
+
+// supportsSigningAlgorithm returns true if the key manager of the database
+// handle can create signing keys for the algorithm. ES256 is always accepted
+// since it is the default, even when per-realm signing is not configured.
+func supportsSigningAlgorithm(tx *gorm.DB, alg string) bool {
+	if alg == jwthelper.AlgorithmES256 {
+		return true
+	}
+	if tx == nil {
+		return false
+	}
+	v, ok := tx.Get(signingAlgorithmsKey)
+	if !ok {
+		return false
+	}
+	algs, _ := v.([]string)
+	for _, a := range algs {
+		if a == alg {
+			return true
+		}
+	}
+	return false
+}
+
 // Database is a handle to the database layer for the Exposure Notifications
 // Verification Server.
 type Database struct {
@@ -105,6 +153,38 @@ func (db *Database) SupportsPerRealmSigning() bool {
 	return db.signingKeyManager != nil
 }
 
+// AlgorithmSigningKeyCreator is implemented by signing key managers that can
+// create signing keys for algorithms other than ES256. The algorithm is one of
+// jwthelper.SupportedAlgorithms. Like CreateSigningKey, it returns the key's id
+// and does not return an error if the key already exists.
+type AlgorithmSigningKeyCreator interface {
+	CreateSigningKeyWithAlgorithm(ctx context.Context, parent, name, algorithm string) (string, error)
+}
+
+// SupportedSigningAlgorithms returns the JWT algorithms for which the
+// configured key manager can create per-realm signing keys. ES256 is always
+// supported when per-realm signing is supported.
+func (db *Database) SupportedSigningAlgorithms() []string {
+	if db.signingKeyManager == nil {
+		return nil
+	}
+	if _, ok := db.signingKeyManager.(AlgorithmSigningKeyCreator); ok {
+		return jwthelper.SupportedAlgorithms
+	}
+	return []string{jwthelper.AlgorithmES256}
+}
+
+// SupportsSigningAlgorithm returns true if the configured key manager can
+// create per-realm signing keys for the given algorithm.
+func (db *Database) SupportsSigningAlgorithm(alg string) bool {
+	for _, v := range db.SupportedSigningAlgorithms() {
+		if v == alg {
+			return true
+		}
+	}
+	return false
+}
+
 // MaxKeyVersions returns the configured maximum.
 func (db *Database) MaxKeyVersions() int64 {
 	return db.config.MaxKeyVersions
@@ -171,6 +251,20 @@ func (db *Database) GetPhoneNumberDatabaseHMAC() ([][]byte, error) {
 	return results, nil
 }
 
+// GetSMSSuppressionHMAC returns the HMAC keys for storing suppressed phone
+// numbers in the database.
+func (db *Database) GetSMSSuppressionHMAC() ([][]byte, error) {
+	ctx, done := context.WithTimeout(context.Background(), 5*time.Second)
+	defer done()
+
+	results, err := db.secretResolver.Resolve(ctx, db, db.secretManager, SecretTypeSMSSuppressionHMAC)
+	if err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
+
 // GetVerificationCodeDatabaseHMAC returns the HMAC keys for storing verification
 // codes in the database.
 func (db *Database) GetVerificationCodeDatabaseHMAC() ([][]byte, error) {
@@ -191,14 +285,14 @@ func (c *Config) Load(ctx context.Context) (*Database, error) {
 	logger := logging.FromContext(ctx).Named("database")
 
 	// Create the secret manager.
-	secretManager, err := secrets.SecretManagerFor(ctx, &c.Secrets)
+	secretManager, err := workloadidentity.SecretManagerFor(ctx, &c.WorkloadIdentity, &c.Secrets)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create secret manager: %w", err)
 	}
 	secretResolver := NewSecretResolver()
 
 	// Create the key manager.
-	keyManager, err := keys.KeyManagerFor(ctx, &c.Keys)
+	keyManager, err := workloadidentity.KeyManagerFor(ctx, &c.WorkloadIdentity, &c.Keys)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create key manager: %w", err)
 	}
@@ -234,9 +328,17 @@ func (db *Database) OpenWithCacher(ctx context.Context, cacher cache.Cacher) err
 
 	// Establish a connection to the database. We use this later to register
 	// opencenusus stats.
-	rawSQL, err := sql.Open("ocsql", c.ConnectionString())
-	if err != nil {
-		return fmt.Errorf("failed to open sql connection: %w", err)
+	var rawSQL *sql.DB
+	if c.DualWriteDSN != "" {
+		logger.Warnw("dual-write is enabled, writes will be mirrored to the secondary database")
+		rawSQL = sql.OpenDB(newDualWriteConnector(logger,
+			ocsql.Wrap(&pq.Driver{}), c.ConnectionString(), &pq.Driver{}, c.DualWriteDSN))
+	} else {
+		var err error
+		rawSQL, err = sql.Open("ocsql", c.ConnectionString())
+		if err != nil {
+			return fmt.Errorf("failed to open sql connection: %w", err)
+		}
 	}
 	if err := withRetries(ctx, func(ctx context.Context) error {
 		if err := rawSQL.Ping(); err != nil {
@@ -287,6 +389,10 @@ func (db *Database) OpenWithCacher(ctx context.Context, cacher cache.Cacher) err
 	// Enable auto-preloading.
 	rawDB = rawDB.Set("gorm:auto_preload", true)
 
+	// Make fake SMS and key manager capabilities available to model callbacks.
+	rawDB = rawDB.Set(fakeSMSKey, c.FakeSMS)
+	rawDB = rawDB.Set(signingAlgorithmsKey, db.SupportedSigningAlgorithms())
+
 	// Prevent multiple simultaneous callback registrations due to a data race in
 	// gorm.
 	callbackLock.Lock()
@@ -308,6 +414,14 @@ func (db *Database) OpenWithCacher(ctx context.Context, cacher cache.Cacher) err
 
 	rawDB.Callback().Query().After("gorm:after_query").Register("sms_configs:decrypt", callbackKMSDecrypt(ctx, db.keyManager, c.EncryptionKey, "sms_configs", "TwilioAuthToken"))
 
+	rawDB.Callback().Create().Before("gorm:create").Register("sms_configs:encrypt_message_bird", callbackKMSEncrypt(ctx, db.keyManager, c.EncryptionKey, "sms_configs", "MessageBirdAccessKey"))
+	rawDB.Callback().Create().After("gorm:create").Register("sms_configs:decrypt_message_bird", callbackKMSDecrypt(ctx, db.keyManager, c.EncryptionKey, "sms_configs", "MessageBirdAccessKey"))
+
+	rawDB.Callback().Update().Before("gorm:update").Register("sms_configs:encrypt_message_bird", callbackKMSEncrypt(ctx, db.keyManager, c.EncryptionKey, "sms_configs", "MessageBirdAccessKey"))
+	rawDB.Callback().Update().After("gorm:update").Register("sms_configs:decrypt_message_bird", callbackKMSDecrypt(ctx, db.keyManager, c.EncryptionKey, "sms_configs", "MessageBirdAccessKey"))
+
+	rawDB.Callback().Query().After("gorm:after_query").Register("sms_configs:decrypt_message_bird", callbackKMSDecrypt(ctx, db.keyManager, c.EncryptionKey, "sms_configs", "MessageBirdAccessKey"))
+
 	// Email configs
 	rawDB.Callback().Create().Before("gorm:create").Register("email_configs:encrypt", callbackKMSEncrypt(ctx, db.keyManager, c.EncryptionKey, "email_configs", "SMTPPassword"))
 	rawDB.Callback().Create().After("gorm:create").Register("email_configs:decrypt", callbackKMSDecrypt(ctx, db.keyManager, c.EncryptionKey, "email_configs", "SMTPPassword"))
@@ -326,7 +440,27 @@ func (db *Database) OpenWithCacher(ctx context.Context, cacher cache.Cacher) err
 
 	rawDB.Callback().Query().After("gorm:after_query").Register("realms:decrypt", callbackKMSDecrypt(ctx, db.keyManager, c.EncryptionKey, "realms", "UserReportWebhookSecret"))
 
+	// Authorized apps
+	rawDB.Callback().Create().Before("gorm:create").Register("authorized_apps:encrypt", callbackKMSEncrypt(ctx, db.keyManager, c.EncryptionKey, "authorized_apps", "CallbackSecret"))
+	rawDB.Callback().Create().After("gorm:create").Register("authorized_apps:decrypt", callbackKMSDecrypt(ctx, db.keyManager, c.EncryptionKey, "authorized_apps", "CallbackSecret"))
+
+	rawDB.Callback().Update().Before("gorm:update").Register("authorized_apps:encrypt", callbackKMSEncrypt(ctx, db.keyManager, c.EncryptionKey, "authorized_apps", "CallbackSecret"))
+	rawDB.Callback().Update().After("gorm:update").Register("authorized_apps:decrypt", callbackKMSDecrypt(ctx, db.keyManager, c.EncryptionKey, "authorized_apps", "CallbackSecret"))
+
+	rawDB.Callback().Query().After("gorm:after_query").Register("authorized_apps:decrypt", callbackKMSDecrypt(ctx, db.keyManager, c.EncryptionKey, "authorized_apps", "CallbackSecret"))
+
+	// Bulk issue jobs. Rows are decoded in AfterFind, so they must be decrypted
+	// before it runs.
+	rawDB.Callback().Create().Before("gorm:create").Register("bulk_issue_jobs:encrypt", callbackKMSEncrypt(ctx, db.keyManager, c.EncryptionKey, "bulk_issue_jobs", "RowsPayload"))
+	rawDB.Callback().Create().After("gorm:create").Register("bulk_issue_jobs:decrypt", callbackKMSDecrypt(ctx, db.keyManager, c.EncryptionKey, "bulk_issue_jobs", "RowsPayload"))
+
+	rawDB.Callback().Update().Before("gorm:update").Register("bulk_issue_jobs:encrypt", callbackKMSEncrypt(ctx, db.keyManager, c.EncryptionKey, "bulk_issue_jobs", "RowsPayload"))
+	rawDB.Callback().Update().After("gorm:update").Register("bulk_issue_jobs:decrypt", callbackKMSDecrypt(ctx, db.keyManager, c.EncryptionKey, "bulk_issue_jobs", "RowsPayload"))
+
+	rawDB.Callback().Query().Before("gorm:after_query").Register("bulk_issue_jobs:decrypt", callbackKMSDecrypt(ctx, db.keyManager, c.EncryptionKey, "bulk_issue_jobs", "RowsPayload"))
+
 	// Verification codes
+	rawDB.Callback().Create().Before("gorm:create").Register("verification_codes:hmac_key_id", callbackHMACKeyID(ctx, db.GetVerificationCodeDatabaseHMAC, "verification_codes", "hmac_key_id"))
 	rawDB.Callback().Create().Before("gorm:create").Register("verification_codes:hmac_code", callbackHMAC(ctx, db.GenerateVerificationCodeHMAC, "verification_codes", "code"))
 	rawDB.Callback().Create().Before("gorm:create").Register("verification_codes:hmac_long_code", callbackHMAC(ctx, db.GenerateVerificationCodeHMAC, "verification_codes", "long_code"))
 
@@ -371,6 +505,26 @@ func (db *Database) Ping(ctx context.Context) error {
 	return db.db.DB().PingContext(ctx)
 }
 
+// PingKeyManager encrypts and decrypts a short value with the database
+// encryption key to check that the key manager is available.
+func (db *Database) PingKeyManager(ctx context.Context) error {
+	plaintext := []byte("ping")
+
+	ciphertext, err := db.keyManager.Encrypt(ctx, db.config.EncryptionKey, plaintext, nil)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt: %w", err)
+	}
+
+	got, err := db.keyManager.Decrypt(ctx, db.config.EncryptionKey, ciphertext, nil)
+	if err != nil {
+		return fmt.Errorf("failed to decrypt: %w", err)
+	}
+	if !bytes.Equal(got, plaintext) {
+		return fmt.Errorf("decrypted value does not match")
+	}
+	return nil
+}
+
 // RawDB returns the underlying gorm database. This is publicly exposed for
 // tests.
 func (db *Database) RawDB() *gorm.DB {
@@ -709,6 +863,37 @@ func callbackHMAC(ctx context.Context, hashFunc func(string) (string, error), ta
 	}
 }
 
+// callbackHMACKeyID records the identifier of the primary HMAC key, the key
+// used by callbackHMAC, on the given column.
+func callbackHMACKeyID(ctx context.Context, keysFunc func() ([][]byte, error), table, column string) func(scope *gorm.Scope) {
+	return func(scope *gorm.Scope) {
+		// Do nothing if not the target table
+		if scope.TableName() != table {
+			return
+		}
+
+		// Do nothing if there are errors
+		if scope.HasError() {
+			return
+		}
+
+		keys, err := keysFunc()
+		if err != nil {
+			_ = scope.Err(fmt.Errorf("failed to get HMAC keys for column %s: %w", column, err))
+			return
+		}
+		if len(keys) < 1 {
+			_ = scope.Err(fmt.Errorf("expected at least 1 hmac key for column %s", column))
+			return
+		}
+
+		if err := scope.SetColumn(column, VerificationCodeHMACKeyID(keys[0])); err != nil {
+			_ = scope.Err(fmt.Errorf("failed to set column %s: %w", column, err))
+			return
+		}
+	}
+}
+
 func getFieldString(scope *gorm.Scope, name string) (*gorm.Field, string, bool) {
 	field, ok := scope.FieldByName(name)
 	if !ok {
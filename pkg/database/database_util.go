@@ -410,6 +410,7 @@ func createSecrets(ctx context.Context, db *Database) error {
 		{SecretTypeAPIKeySignatureHMAC, 128},
 		{SecretTypeCookieKeys, 64 + 32},
 		{SecretTypePhoneNumberDatabaseHMAC, 128},
+		{SecretTypeSMSSuppressionHMAC, 128},
 		{SecretTypeVerificationCodeDatabaseHMAC, 128},
 	}
 
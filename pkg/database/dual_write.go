@@ -0,0 +1,593 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package database
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"sync"
+
+	"go.opencensus.io/stats"
+	"go.uber.org/zap"
+)
+
+// dualWriteQueryRe matches queries that modify data. Writes are usually sent
+// with Exec, but gorm issues INSERT ... RETURNING statements as queries, so
+// those need to be mirrored too.
+var dualWriteQueryRe = regexp.MustCompile(`(?is)^\s*(INSERT|UPDATE|DELETE|WITH\b.*\b(INSERT|UPDATE|DELETE))\b`)
+
+// isDualWriteQuery returns true if the query modifies data and must be
+// mirrored to the secondary database.
+func isDualWriteQuery(query string) bool {
+	return dualWriteQueryRe.MatchString(query)
+}
+
+// dualWriteInsertRe matches INSERT statements that return a single column, like
+// the ones gorm issues on create. The groups are the table, the column list,
+// the values, and the returned column.
+var dualWriteInsertRe = regexp.MustCompile(`(?is)^\s*INSERT\s+INTO\s+(\S+)\s*(?:\(([^)]*)\)\s*VALUES\s*\((.*)\)|DEFAULT\s+VALUES)\s*RETURNING\s+(?:\S+\.)?("[^"]+"|\w+)\s*;?\s*$`)
+
+// dualWriteInsert is an INSERT whose returned column is generated by the
+// database, such as a serial ID.
+type dualWriteInsert struct {
+	table   string
+	columns string
+	values  string
+	column  string
+}
+
+// parseDualWriteInsert returns the parsed insert if the query inserts a row and
+// returns a column it did not set. Replaying such a statement as-is would let
+// the secondary generate a different value, so the value returned by the
+// primary must be sent explicitly instead.
+func parseDualWriteInsert(query string) *dualWriteInsert {
+	m := dualWriteInsertRe.FindStringSubmatch(query)
+	if m == nil {
+		return nil
+	}
+
+	ins := &dualWriteInsert{
+		table:   m[1],
+		columns: strings.TrimSpace(m[2]),
+		values:  strings.TrimSpace(m[3]),
+		column:  m[4],
+	}
+
+	// Upserts may not insert a row at all, so they are replayed as-is.
+	if strings.Contains(strings.ToUpper(ins.values), "ON CONFLICT") {
+		return nil
+	}
+
+	// The value was provided explicitly, so replaying as-is is safe.
+	unquoted := strings.Trim(ins.column, `"`)
+	for _, col := range strings.Split(ins.columns, ",") {
+		if strings.Trim(strings.TrimSpace(col), `"`) == unquoted {
+			return nil
+		}
+	}
+	return ins
+}
+
+// statement returns the insert with the generated value set explicitly as the
+// next positional argument.
+func (i *dualWriteInsert) statement(args []driver.NamedValue, value driver.Value) (string, []driver.NamedValue) {
+	placeholder := fmt.Sprintf("$%d", len(args)+1)
+
+	columns, values := i.column, placeholder
+	if i.columns != "" {
+		columns = i.columns + "," + i.column
+		values = i.values + "," + placeholder
+	}
+
+	query := fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)", i.table, columns, values)
+
+	withValue := make([]driver.NamedValue, 0, len(args)+1)
+	withValue = append(withValue, args...)
+	withValue = append(withValue, driver.NamedValue{Ordinal: len(args) + 1, Value: value})
+	return query, withValue
+}
+
+// dualWriteConnector is a driver.Connector that mirrors writes made against
+// the primary database to a secondary database. It is used to move between
+// database instances without downtime: the secondary is kept up to date with
+// the primary until it is ready to take over.
+//
+// Reads are only ever served by the primary, and failures on the secondary
+// never fail the caller. Instead they are logged and recorded as metrics, and
+// VerifyDualWrite can be used to find tables that have diverged.
+type dualWriteConnector struct {
+	primary      driver.Driver
+	primaryDSN   string
+	secondary    driver.Driver
+	secondaryDSN string
+	logger       *zap.SugaredLogger
+}
+
+// newDualWriteConnector creates a new connector that mirrors writes from the
+// primary to the secondary.
+func newDualWriteConnector(logger *zap.SugaredLogger, primary driver.Driver, primaryDSN string, secondary driver.Driver, secondaryDSN string) *dualWriteConnector {
+	return &dualWriteConnector{
+		primary:      primary,
+		primaryDSN:   primaryDSN,
+		secondary:    secondary,
+		secondaryDSN: secondaryDSN,
+		logger:       logger.Named("dualwrite"),
+	}
+}
+
+// Connect implements driver.Connector.
+func (c *dualWriteConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	primary, err := connectDriver(ctx, c.primary, c.primaryDSN)
+	if err != nil {
+		return nil, err
+	}
+
+	// Failing to reach the secondary must never take down the primary. The
+	// connection is still returned, but it is discarded by the pool at the
+	// next session reset so a new secondary connection is attempted.
+	secondary, err := connectDriver(ctx, c.secondary, c.secondaryDSN)
+	if err != nil {
+		c.logger.Errorw("failed to connect to secondary", "error", err)
+		stats.Record(ctx, mDualWriteErrors.M(1))
+		secondary = nil
+	}
+
+	return &dualWriteConn{
+		primary:   primary,
+		secondary: secondary,
+		logger:    c.logger,
+	}, nil
+}
+
+// Driver implements driver.Connector.
+func (c *dualWriteConnector) Driver() driver.Driver {
+	return c.primary
+}
+
+// connectDriver opens a new connection using the given driver.
+func connectDriver(ctx context.Context, d driver.Driver, dsn string) (driver.Conn, error) {
+	if dc, ok := d.(driver.DriverContext); ok {
+		connector, err := dc.OpenConnector(dsn)
+		if err != nil {
+			return nil, err
+		}
+		return connector.Connect(ctx)
+	}
+	return d.Open(dsn)
+}
+
+// dualWriteConn is a connection to both the primary and secondary databases.
+type dualWriteConn struct {
+	primary driver.Conn
+
+	// secondary is nil if the connection to the secondary failed.
+	secondaryLock sync.Mutex
+	secondary     driver.Conn
+
+	logger *zap.SugaredLogger
+}
+
+var (
+	_ driver.Conn               = (*dualWriteConn)(nil)
+	_ driver.ConnBeginTx        = (*dualWriteConn)(nil)
+	_ driver.ConnPrepareContext = (*dualWriteConn)(nil)
+	_ driver.ExecerContext      = (*dualWriteConn)(nil)
+	_ driver.QueryerContext     = (*dualWriteConn)(nil)
+	_ driver.Pinger             = (*dualWriteConn)(nil)
+	_ driver.SessionResetter    = (*dualWriteConn)(nil)
+)
+
+// Prepare implements driver.Conn.
+func (c *dualWriteConn) Prepare(query string) (driver.Stmt, error) {
+	return c.PrepareContext(context.Background(), query)
+}
+
+// PrepareContext implements driver.ConnPrepareContext.
+func (c *dualWriteConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	var stmt driver.Stmt
+	var err error
+	if p, ok := c.primary.(driver.ConnPrepareContext); ok {
+		stmt, err = p.PrepareContext(ctx, query)
+	} else {
+		stmt, err = c.primary.Prepare(query)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &dualWriteStmt{
+		Stmt:  stmt,
+		conn:  c,
+		query: query,
+	}, nil
+}
+
+// Close implements driver.Conn.
+func (c *dualWriteConn) Close() error {
+	c.secondaryLock.Lock()
+	if c.secondary != nil {
+		if err := c.secondary.Close(); err != nil {
+			c.logger.Warnw("failed to close secondary connection", "error", err)
+		}
+		c.secondary = nil
+	}
+	c.secondaryLock.Unlock()
+
+	return c.primary.Close()
+}
+
+// Begin implements driver.Conn.
+func (c *dualWriteConn) Begin() (driver.Tx, error) {
+	return c.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+// BeginTx implements driver.ConnBeginTx. Transactions are started on both
+// databases so the secondary commits or rolls back with the primary.
+func (c *dualWriteConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	primary, err := beginTx(ctx, c.primary, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	c.secondaryLock.Lock()
+	defer c.secondaryLock.Unlock()
+
+	var secondary driver.Tx
+	if c.secondary != nil {
+		secondary, err = beginTx(ctx, c.secondary, opts)
+		if err != nil {
+			c.secondaryFailedLocked(ctx, "begin", err)
+		}
+	}
+
+	return &dualWriteTx{
+		conn:      c,
+		primary:   primary,
+		secondary: secondary,
+	}, nil
+}
+
+func beginTx(ctx context.Context, conn driver.Conn, opts driver.TxOptions) (driver.Tx, error) {
+	if b, ok := conn.(driver.ConnBeginTx); ok {
+		return b.BeginTx(ctx, opts)
+	}
+	return conn.Begin() //nolint:staticcheck // fallback for drivers without BeginTx
+}
+
+// ExecContext implements driver.ExecerContext. All statements sent via Exec are
+// mirrored, including schema changes from migrations.
+func (c *dualWriteConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	execer, ok := c.primary.(driver.ExecerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+
+	result, err := execer.ExecContext(ctx, query, args)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mirror(ctx, query, args)
+	return result, nil
+}
+
+// QueryContext implements driver.QueryerContext. Only queries which modify
+// data are mirrored, and only once their rows have been read.
+func (c *dualWriteConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	queryer, ok := c.primary.(driver.QueryerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+
+	rows, err := queryer.QueryContext(ctx, query, args)
+	if err != nil {
+		return nil, err
+	}
+
+	if isDualWriteQuery(query) {
+		return newDualWriteRows(ctx, c, rows, query, args), nil
+	}
+	return rows, nil
+}
+
+// Ping implements driver.Pinger. Only the primary is checked.
+func (c *dualWriteConn) Ping(ctx context.Context) error {
+	if p, ok := c.primary.(driver.Pinger); ok {
+		return p.Ping(ctx)
+	}
+	return nil
+}
+
+// ResetSession implements driver.SessionResetter. Connections which lost their
+// secondary are discarded so the pool reconnects to both databases.
+func (c *dualWriteConn) ResetSession(ctx context.Context) error {
+	c.secondaryLock.Lock()
+	lost := c.secondary == nil
+	c.secondaryLock.Unlock()
+	if lost {
+		return driver.ErrBadConn
+	}
+
+	if r, ok := c.primary.(driver.SessionResetter); ok {
+		return r.ResetSession(ctx)
+	}
+	return nil
+}
+
+// mirror executes the statement on the secondary database. Errors are logged
+// and recorded, but never returned.
+func (c *dualWriteConn) mirror(ctx context.Context, query string, args []driver.NamedValue) {
+	c.secondaryLock.Lock()
+	defer c.secondaryLock.Unlock()
+
+	if c.secondary == nil {
+		stats.Record(ctx, mDualWriteErrors.M(1))
+		return
+	}
+
+	execer, ok := c.secondary.(driver.ExecerContext)
+	if !ok {
+		c.secondaryFailedLocked(ctx, "exec", driver.ErrSkip)
+		return
+	}
+
+	if _, err := execer.ExecContext(ctx, query, args); err != nil {
+		c.logger.Warnw("failed to mirror statement to secondary", "error", err)
+		stats.Record(ctx, mDualWriteErrors.M(1))
+		return
+	}
+	stats.Record(ctx, mDualWriteMirrored.M(1))
+}
+
+// secondaryFailedLocked drops the secondary connection after an unrecoverable
+// error. The caller must hold secondaryLock.
+func (c *dualWriteConn) secondaryFailedLocked(ctx context.Context, op string, err error) {
+	c.logger.Errorw("secondary connection failed", "op", op, "error", err)
+	stats.Record(ctx, mDualWriteErrors.M(1))
+
+	if c.secondary != nil {
+		_ = c.secondary.Close()
+		c.secondary = nil
+	}
+}
+
+// dualWriteTx is a transaction on both databases.
+type dualWriteTx struct {
+	conn      *dualWriteConn
+	primary   driver.Tx
+	secondary driver.Tx
+}
+
+// Commit implements driver.Tx. The secondary is only committed if the primary
+// committed successfully.
+func (t *dualWriteTx) Commit() error {
+	if err := t.primary.Commit(); err != nil {
+		t.rollbackSecondary()
+		return err
+	}
+
+	if t.secondary != nil {
+		if err := t.secondary.Commit(); err != nil {
+			t.conn.secondaryLock.Lock()
+			t.conn.secondaryFailedLocked(context.Background(), "commit", err)
+			t.conn.secondaryLock.Unlock()
+		}
+	}
+	return nil
+}
+
+// Rollback implements driver.Tx.
+func (t *dualWriteTx) Rollback() error {
+	t.rollbackSecondary()
+	return t.primary.Rollback()
+}
+
+func (t *dualWriteTx) rollbackSecondary() {
+	if t.secondary == nil {
+		return
+	}
+	if err := t.secondary.Rollback(); err != nil {
+		t.conn.secondaryLock.Lock()
+		t.conn.secondaryFailedLocked(context.Background(), "rollback", err)
+		t.conn.secondaryLock.Unlock()
+	}
+}
+
+// dualWriteStmt is a prepared statement on the primary that is mirrored to the
+// secondary when executed.
+type dualWriteStmt struct {
+	driver.Stmt
+	conn  *dualWriteConn
+	query string
+}
+
+var (
+	_ driver.StmtExecContext  = (*dualWriteStmt)(nil)
+	_ driver.StmtQueryContext = (*dualWriteStmt)(nil)
+)
+
+// Exec implements driver.Stmt.
+func (s *dualWriteStmt) Exec(args []driver.Value) (driver.Result, error) {
+	return s.ExecContext(context.Background(), valuesToNamedValues(args))
+}
+
+// ExecContext implements driver.StmtExecContext.
+func (s *dualWriteStmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	var result driver.Result
+	var err error
+	if e, ok := s.Stmt.(driver.StmtExecContext); ok {
+		result, err = e.ExecContext(ctx, args)
+	} else {
+		result, err = s.Stmt.Exec(namedValuesToValues(args)) //nolint:staticcheck // fallback for drivers without ExecContext
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	s.conn.mirror(ctx, s.query, args)
+	return result, nil
+}
+
+// Query implements driver.Stmt.
+func (s *dualWriteStmt) Query(args []driver.Value) (driver.Rows, error) {
+	return s.QueryContext(context.Background(), valuesToNamedValues(args))
+}
+
+// QueryContext implements driver.StmtQueryContext.
+func (s *dualWriteStmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	var rows driver.Rows
+	var err error
+	if q, ok := s.Stmt.(driver.StmtQueryContext); ok {
+		rows, err = q.QueryContext(ctx, args)
+	} else {
+		rows, err = s.Stmt.Query(namedValuesToValues(args)) //nolint:staticcheck // fallback for drivers without QueryContext
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if isDualWriteQuery(s.query) {
+		return newDualWriteRows(ctx, s.conn, rows, s.query, args), nil
+	}
+	return rows, nil
+}
+
+// dualWriteRows wraps the rows of a query that modifies data. Postgres may
+// report errors for such statements (for example, constraint violations) while
+// the rows are read, so the statement is only mirrored when the rows are
+// closed, and only if every row was read without error.
+type dualWriteRows struct {
+	driver.Rows
+
+	ctx   context.Context
+	conn  *dualWriteConn
+	query string
+	args  []driver.NamedValue
+
+	// insert is set if the statement returns a generated value. The values of
+	// the returned column are captured so they can be mirrored explicitly.
+	insert   *dualWriteInsert
+	returned []driver.Value
+	count    int
+
+	done   bool
+	err    error
+	closed bool
+}
+
+func newDualWriteRows(ctx context.Context, conn *dualWriteConn, rows driver.Rows, query string, args []driver.NamedValue) *dualWriteRows {
+	return &dualWriteRows{
+		Rows:   rows,
+		ctx:    ctx,
+		conn:   conn,
+		query:  query,
+		args:   args,
+		insert: parseDualWriteInsert(query),
+	}
+}
+
+// Next implements driver.Rows.
+func (r *dualWriteRows) Next(dest []driver.Value) error {
+	err := r.Rows.Next(dest)
+	switch {
+	case err == nil:
+		r.count++
+		if r.insert != nil && r.count == 1 && len(dest) == 1 {
+			r.returned = append(r.returned, copyDriverValue(dest[0]))
+		}
+	case err == io.EOF:
+		r.done = true
+	default:
+		r.err = err
+	}
+	return err
+}
+
+// Close implements driver.Rows. This is where the statement is mirrored.
+func (r *dualWriteRows) Close() error {
+	if r.closed {
+		return r.Rows.Close()
+	}
+	r.closed = true
+
+	// Callers like QueryRow stop after the first row, so read the rest to learn
+	// whether the statement succeeded.
+	if !r.done && r.err == nil {
+		dest := make([]driver.Value, len(r.Rows.Columns()))
+		for {
+			if err := r.Next(dest); err != nil {
+				break
+			}
+		}
+	}
+	err := r.Rows.Close()
+
+	logger := r.conn.logger
+
+	// The statement failed on the primary, so it must not be applied on the
+	// secondary.
+	if err != nil || r.err != nil || !r.done {
+		logger.Warnw("not mirroring statement that failed on primary", "rows_error", r.err, "close_error", err)
+		stats.Record(r.ctx, mDualWriteErrors.M(1))
+		return err
+	}
+
+	if r.insert == nil {
+		r.conn.mirror(r.ctx, r.query, r.args)
+		return nil
+	}
+
+	// The generated value can only be set explicitly for a single row.
+	if r.count != 1 || len(r.returned) != 1 {
+		logger.Errorw("not mirroring insert with generated values", "rows", r.count)
+		stats.Record(r.ctx, mDualWriteErrors.M(1))
+		return nil
+	}
+
+	query, args := r.insert.statement(r.args, r.returned[0])
+	r.conn.mirror(r.ctx, query, args)
+	return nil
+}
+
+// copyDriverValue copies values the driver may reuse after the next call to
+// Next.
+func copyDriverValue(v driver.Value) driver.Value {
+	if b, ok := v.([]byte); ok {
+		c := make([]byte, len(b))
+		copy(c, b)
+		return c
+	}
+	return v
+}
+
+func valuesToNamedValues(args []driver.Value) []driver.NamedValue {
+	named := make([]driver.NamedValue, 0, len(args))
+	for i, v := range args {
+		named = append(named, driver.NamedValue{Ordinal: i + 1, Value: v})
+	}
+	return named
+}
+
+func namedValuesToValues(args []driver.NamedValue) []driver.Value {
+	values := make([]driver.Value, 0, len(args))
+	for _, v := range args {
+		values = append(values, v.Value)
+	}
+	return values
+}
@@ -0,0 +1,347 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package database
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+
+	"github.com/google/exposure-notifications-verification-server/internal/project"
+	"github.com/jinzhu/gorm"
+	"go.uber.org/zap"
+)
+
+func TestIsDualWriteQuery(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		query string
+		exp   bool
+	}{
+		{`SELECT * FROM realms`, false},
+		{`SELECT * FROM realms WHERE id = $1 FOR UPDATE`, false},
+		{`INSERT INTO "realms" ("name") VALUES ($1) RETURNING "realms"."id"`, true},
+		{`  insert into realms (name) values ($1)`, true},
+		{`UPDATE realms SET name = $1 RETURNING id`, true},
+		{"\n\t\tDELETE FROM realms WHERE id = $1 RETURNING id", true},
+		{`WITH deleted AS (DELETE FROM realms RETURNING id) SELECT COUNT(*) FROM deleted`, true},
+		{`WITH recent AS (SELECT id FROM realms) SELECT * FROM recent`, false},
+	}
+
+	for _, tc := range cases {
+		if got, want := isDualWriteQuery(tc.query), tc.exp; got != want {
+			t.Errorf("expected %q to be %t", tc.query, want)
+		}
+	}
+}
+
+func TestParseDualWriteInsert(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name  string
+		query string
+		exp   *dualWriteInsert
+	}{
+		{
+			name:  "gorm_create",
+			query: `INSERT INTO "realms" ("name","region_code") VALUES ($1,$2) RETURNING "realms"."id"`,
+			exp: &dualWriteInsert{
+				table:   `"realms"`,
+				columns: `"name","region_code"`,
+				values:  `$1,$2`,
+				column:  `"id"`,
+			},
+		},
+		{
+			name:  "default_values",
+			query: `INSERT INTO "things" DEFAULT VALUES RETURNING "things"."id"`,
+			exp: &dualWriteInsert{
+				table:  `"things"`,
+				column: `"id"`,
+			},
+		},
+		{
+			name:  "explicit_value",
+			query: `INSERT INTO "realms" ("id","name") VALUES ($1,$2) RETURNING "realms"."id"`,
+		},
+		{
+			name:  "upsert",
+			query: `INSERT INTO lock_statuses (type) VALUES ($1) ON CONFLICT (type) DO UPDATE SET type = EXCLUDED.type RETURNING id`,
+		},
+		{
+			name:  "returning_all",
+			query: `INSERT INTO lock_statuses (type) VALUES ($1) RETURNING *`,
+		},
+		{
+			name:  "update",
+			query: `UPDATE realms SET name = $1 RETURNING id`,
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			if got, want := parseDualWriteInsert(tc.query), tc.exp; !reflect.DeepEqual(got, want) {
+				t.Errorf("expected %#v to be %#v", got, want)
+			}
+		})
+	}
+}
+
+// fakeDualWriteRows returns the given rows, then err (or io.EOF).
+type fakeDualWriteRows struct {
+	rows [][]driver.Value
+	err  error
+	i    int
+}
+
+func (r *fakeDualWriteRows) Columns() []string {
+	return []string{"id"}
+}
+
+func (r *fakeDualWriteRows) Close() error {
+	return nil
+}
+
+func (r *fakeDualWriteRows) Next(dest []driver.Value) error {
+	if r.i < len(r.rows) {
+		copy(dest, r.rows[r.i])
+		r.i++
+		return nil
+	}
+	if r.err != nil {
+		return r.err
+	}
+	return io.EOF
+}
+
+// recordingConn records the statements executed against it.
+type recordingConn struct {
+	driver.Conn
+	queries []string
+	args    [][]driver.NamedValue
+}
+
+func (c *recordingConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	c.queries = append(c.queries, query)
+	c.args = append(c.args, args)
+	return driver.RowsAffected(1), nil
+}
+
+func TestDualWriteRows(t *testing.T) {
+	t.Parallel()
+
+	ctx := project.TestContext(t)
+
+	args := []driver.NamedValue{{Ordinal: 1, Value: "name"}}
+
+	cases := []struct {
+		name     string
+		query    string
+		rows     *fakeDualWriteRows
+		readAll  bool
+		expQuery string
+		expArgs  []driver.NamedValue
+		mirrored bool
+	}{
+		{
+			name:     "mirrors_after_rows_read",
+			query:    `UPDATE realms SET name = $1 RETURNING id`,
+			rows:     &fakeDualWriteRows{rows: [][]driver.Value{{int64(1)}, {int64(2)}}},
+			readAll:  true,
+			expQuery: `UPDATE realms SET name = $1 RETURNING id`,
+			expArgs:  args,
+			mirrored: true,
+		},
+		{
+			name:     "drains_partially_read_rows",
+			query:    `UPDATE realms SET name = $1 RETURNING id`,
+			rows:     &fakeDualWriteRows{rows: [][]driver.Value{{int64(1)}, {int64(2)}}},
+			expQuery: `UPDATE realms SET name = $1 RETURNING id`,
+			expArgs:  args,
+			mirrored: true,
+		},
+		{
+			name:    "not_mirrored_on_row_error",
+			query:   `UPDATE realms SET name = $1 RETURNING id`,
+			rows:    &fakeDualWriteRows{rows: [][]driver.Value{{int64(1)}}, err: errors.New("unique violation")},
+			readAll: true,
+		},
+		{
+			name:  "not_mirrored_on_drain_error",
+			query: `UPDATE realms SET name = $1 RETURNING id`,
+			rows:  &fakeDualWriteRows{rows: [][]driver.Value{{int64(1)}}, err: errors.New("unique violation")},
+		},
+		{
+			name:     "sends_generated_value",
+			query:    `INSERT INTO "realms" ("name") VALUES ($1) RETURNING "realms"."id"`,
+			rows:     &fakeDualWriteRows{rows: [][]driver.Value{{int64(42)}}},
+			expQuery: `INSERT INTO "realms" ("name","id") VALUES ($1,$2)`,
+			expArgs: []driver.NamedValue{
+				{Ordinal: 1, Value: "name"},
+				{Ordinal: 2, Value: int64(42)},
+			},
+			mirrored: true,
+		},
+		{
+			name:    "not_mirrored_multiple_generated_values",
+			query:   `INSERT INTO "realms" ("name") VALUES ($1),($1) RETURNING "realms"."id"`,
+			rows:    &fakeDualWriteRows{rows: [][]driver.Value{{int64(42)}, {int64(43)}}},
+			readAll: true,
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			secondary := &recordingConn{}
+			conn := &dualWriteConn{
+				secondary: secondary,
+				logger:    zap.NewNop().Sugar(),
+			}
+
+			rows := newDualWriteRows(ctx, conn, tc.rows, tc.query, args)
+
+			dest := make([]driver.Value, 1)
+			if err := rows.Next(dest); err != nil {
+				t.Fatal(err)
+			}
+			if tc.readAll {
+				for {
+					if err := rows.Next(dest); err != nil {
+						break
+					}
+				}
+			}
+
+			// Nothing is mirrored until the rows are closed.
+			if got := len(secondary.queries); got != 0 {
+				t.Fatalf("expected no statements before close, got %d", got)
+			}
+
+			if err := rows.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			if !tc.mirrored {
+				if got := len(secondary.queries); got != 0 {
+					t.Errorf("expected no statements, got %q", secondary.queries)
+				}
+				return
+			}
+
+			if got, want := len(secondary.queries), 1; got != want {
+				t.Fatalf("expected %d statements, got %d", want, got)
+			}
+			if got, want := secondary.queries[0], tc.expQuery; got != want {
+				t.Errorf("expected %q to be %q", got, want)
+			}
+			if got, want := secondary.args[0], tc.expArgs; !reflect.DeepEqual(got, want) {
+				t.Errorf("expected %#v to be %#v", got, want)
+			}
+		})
+	}
+}
+
+func TestDualWrite(t *testing.T) {
+	t.Parallel()
+
+	ctx := project.TestContext(t)
+
+	secondary, secondaryConfig := testDatabaseInstance.NewDatabase(t, nil)
+	db, _ := testDatabaseInstance.NewDatabase(t, nil, func(db *Database, c *Config) (*Database, *Config) {
+		c.DualWriteDSN = secondaryConfig.ConnectionString()
+		return db, c
+	})
+
+	// Verification requires a secondary.
+	if _, err := secondary.VerifyDualWrite(ctx); !errors.Is(err, ErrDualWriteDisabled) {
+		t.Errorf("expected %v to be %v", err, ErrDualWriteDisabled)
+	}
+
+	// Advance the secondary's sequence so that IDs it generates itself would
+	// not match the primary's.
+	if err := secondary.RawDB().Exec(`SELECT setval('realms_id_seq', 1000)`).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	// Writes are mirrored, including generated IDs.
+	realm := NewRealmWithDefaults("dualwrite")
+	realm.RegionCode = "DW"
+	if err := db.SaveRealm(realm, SystemTest); err != nil {
+		t.Fatal(err, realm.ErrorMessages())
+	}
+
+	mirrored, err := secondary.FindRealm(realm.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := mirrored.Name, realm.Name; got != want {
+		t.Errorf("expected %q to be %q", got, want)
+	}
+
+	// Rolled back transactions are not applied.
+	if err := db.RawDB().Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec(`UPDATE realms SET name = 'rolled-back' WHERE id = ?`, realm.ID).Error; err != nil {
+			return err
+		}
+		return errors.New("rollback")
+	}); err == nil {
+		t.Fatal("expected error")
+	}
+
+	mirrored, err = secondary.FindRealm(realm.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := mirrored.Name, realm.Name; got != want {
+		t.Errorf("expected %q to be %q", got, want)
+	}
+
+	results, err := db.VerifyDualWrite(ctx, "realms")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(results), 1; got != want {
+		t.Fatalf("expected %d results to be %d", got, want)
+	}
+	if results[0].Diverged() {
+		t.Errorf("expected realms to match: %#v", results[0])
+	}
+
+	// Writes made only on the secondary are detected.
+	if err := secondary.RawDB().Exec(`UPDATE realms SET name = 'diverged' WHERE id = ?`, realm.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	results, err = db.VerifyDualWrite(ctx, "realms")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !results[0].Diverged() {
+		t.Errorf("expected realms to diverge: %#v", results[0])
+	}
+}
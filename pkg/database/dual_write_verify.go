@@ -0,0 +1,146 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/lib/pq"
+	"go.opencensus.io/stats"
+)
+
+// ErrDualWriteDisabled is returned when verifying dual-write without a
+// secondary database configured.
+var ErrDualWriteDisabled = errors.New("dual-write is not enabled")
+
+// DualWriteEnabled returns true if writes are mirrored to a secondary
+// database.
+func (db *Database) DualWriteEnabled() bool {
+	return db.config.DualWriteDSN != ""
+}
+
+// DualWriteTableResult is the comparison of a single table between the primary
+// and secondary databases.
+type DualWriteTableResult struct {
+	Table string `json:"table"`
+
+	PrimaryRows       int64  `json:"primaryRows"`
+	SecondaryRows     int64  `json:"secondaryRows"`
+	PrimaryChecksum   string `json:"primaryChecksum"`
+	SecondaryChecksum string `json:"secondaryChecksum"`
+}
+
+// Diverged returns true if the table contents differ between the databases.
+func (r *DualWriteTableResult) Diverged() bool {
+	return r.PrimaryRows != r.SecondaryRows || r.PrimaryChecksum != r.SecondaryChecksum
+}
+
+// VerifyDualWrite compares the contents of the given tables between the
+// primary and secondary databases. If no tables are given, all tables in the
+// public schema are compared. The comparison reads every row of every table,
+// so it can be expensive on large databases.
+//
+// The number of tables that differ is recorded as a metric. Diverged tables
+// are returned as results, not as an error.
+func (db *Database) VerifyDualWrite(ctx context.Context, tables ...string) ([]*DualWriteTableResult, error) {
+	if !db.DualWriteEnabled() {
+		return nil, ErrDualWriteDisabled
+	}
+
+	secondary, err := sql.Open("postgres", db.config.DualWriteDSN)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open secondary database: %w", err)
+	}
+	defer secondary.Close()
+
+	primary := db.db.DB()
+
+	if len(tables) == 0 {
+		tables, err = listPublicTables(ctx, primary)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list tables: %w", err)
+		}
+	}
+
+	results := make([]*DualWriteTableResult, 0, len(tables))
+	var diverged int64
+	for _, table := range tables {
+		result := &DualWriteTableResult{Table: table}
+
+		result.PrimaryRows, result.PrimaryChecksum, err = tableChecksum(ctx, primary, table)
+		if err != nil {
+			return nil, fmt.Errorf("failed to checksum %s on primary: %w", table, err)
+		}
+
+		result.SecondaryRows, result.SecondaryChecksum, err = tableChecksum(ctx, secondary, table)
+		if err != nil {
+			return nil, fmt.Errorf("failed to checksum %s on secondary: %w", table, err)
+		}
+
+		if result.Diverged() {
+			diverged++
+		}
+		results = append(results, result)
+	}
+
+	stats.Record(ctx, mDualWriteDivergedTables.M(diverged))
+	return results, nil
+}
+
+// listPublicTables returns the names of all tables in the public schema.
+func listPublicTables(ctx context.Context, db *sql.DB) ([]string, error) {
+	rows, err := db.QueryContext(ctx, `
+		SELECT table_name
+		FROM information_schema.tables
+		WHERE table_schema = 'public' AND table_type = 'BASE TABLE'
+		ORDER BY table_name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tables []string
+	for rows.Next() {
+		var table string
+		if err := rows.Scan(&table); err != nil {
+			return nil, err
+		}
+		tables = append(tables, table)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return tables, nil
+}
+
+// tableChecksum returns the number of rows in the table and a checksum of their
+// contents that does not depend on row order.
+func tableChecksum(ctx context.Context, db *sql.DB, table string) (int64, string, error) {
+	q := fmt.Sprintf(`
+		SELECT
+			COUNT(*),
+			COALESCE(MD5(STRING_AGG(MD5(t::text), '' ORDER BY MD5(t::text))), '')
+		FROM %s t`, pq.QuoteIdentifier(table))
+
+	var count int64
+	var checksum string
+	if err := db.QueryRowContext(ctx, q).Scan(&count, &checksum); err != nil {
+		return 0, "", err
+	}
+	return count, checksum, nil
+}
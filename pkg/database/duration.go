@@ -46,6 +46,10 @@ func (d *DurationSeconds) Days() int64 {
 	return int64(d.Duration.Hours() / 24.0)
 }
 
+func (d *DurationSeconds) Minutes() int64 {
+	return int64(d.Duration.Minutes())
+}
+
 // Update attempts to parse the AsString value and set is as the duration
 func (d *DurationSeconds) Update() error {
 	newDuration, err := time.ParseDuration(d.AsString)
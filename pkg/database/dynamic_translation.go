@@ -17,6 +17,7 @@ package database
 import (
 	"context"
 	"fmt"
+	"sort"
 	"strings"
 	"time"
 
@@ -78,7 +79,9 @@ func (db *Database) ListDynamicTranslationsCached(ctx context.Context, cacher ca
 }
 
 // ListDynamicTranslations returns all of the dynamic translations for all realms.
-// The result of this read should be cached for some period of time.
+// Strings localized by realm admins take precedence over synced translations
+// for the same realm, locale, and message. The result of this read should be
+// cached for some period of time.
 func (db *Database) ListDynamicTranslations() ([]*DynamicTranslation, error) {
 	var translations []*DynamicTranslation
 	if err := db.db.
@@ -88,7 +91,46 @@ func (db *Database) ListDynamicTranslations() ([]*DynamicTranslation, error) {
 		Error; err != nil {
 		return nil, err
 	}
-	return translations, nil
+
+	var realms []*Realm
+	if err := db.db.
+		Model(&Realm{}).
+		Select("id, localized_web_strings").
+		Where("localized_web_strings IS NOT NULL").
+		Find(&realms).
+		Error; err != nil {
+		return nil, err
+	}
+	if len(realms) == 0 {
+		return translations, nil
+	}
+
+	overrides := make(map[string]*DynamicTranslation)
+	for _, r := range realms {
+		for _, t := range r.localizedWebStringTranslations() {
+			overrides[t.Key()] = t
+		}
+	}
+
+	merged := make([]*DynamicTranslation, 0, len(translations)+len(overrides))
+	for _, t := range translations {
+		if _, ok := overrides[t.Key()]; !ok {
+			merged = append(merged, t)
+		}
+	}
+	for _, t := range overrides {
+		merged = append(merged, t)
+	}
+	sort.Slice(merged, func(i, j int) bool {
+		if merged[i].RealmID != merged[j].RealmID {
+			return merged[i].RealmID < merged[j].RealmID
+		}
+		if merged[i].Locale != merged[j].Locale {
+			return merged[i].Locale < merged[j].Locale
+		}
+		return merged[i].MessageID < merged[j].MessageID
+	})
+	return merged, nil
 }
 
 // localeToLangauge covers things like "en_US" to just "en" to match
@@ -21,6 +21,7 @@ import (
 	"github.com/google/exposure-notifications-verification-server/internal/appsync"
 	"github.com/google/go-cmp/cmp"
 	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/jinzhu/gorm/dialects/postgres"
 )
 
 func TestDynamicTranslations(t *testing.T) {
@@ -188,3 +189,76 @@ func TestDynamicTranslations(t *testing.T) {
 		}
 	}
 }
+
+func TestListDynamicTranslations_localizedWebStrings(t *testing.T) {
+	t.Parallel()
+
+	db, _ := testDatabaseInstance.NewDatabase(t, nil)
+	realm, err := db.FindRealm(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	localizations := []*appsync.Localization{
+		{
+			MessageID: WebStringReportIntro,
+			Translations: []*appsync.Translation{
+				{
+					Language: "en_US",
+					Message:  "Synced intro",
+				},
+				{
+					Language: "es_US",
+					Message:  "Introducción sincronizada",
+				},
+			},
+		},
+	}
+	if _, err := db.SyncRealmTranslations(realm.ID, localizations); err != nil {
+		t.Fatal(err)
+	}
+
+	intro := "Introducción del reino"
+	success := "Éxito"
+	realm.LocalizedWebStrings = postgres.Hstore{
+		LocalizedKey("es", WebStringReportIntro):      &intro,
+		LocalizedKey("es-mx", WebStringReportSuccess): &success,
+	}
+	if err := db.SaveRealm(realm, SystemTest); err != nil {
+		t.Fatalf("failed to save realm: %v: %v", err, realm.ErrorMessages())
+	}
+
+	got, err := db.ListDynamicTranslations()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []*DynamicTranslation{
+		{
+			RealmID:   realm.ID,
+			MessageID: WebStringReportIntro,
+			Locale:    "en",
+			Message:   "Synced intro",
+		},
+		{
+			RealmID:   realm.ID,
+			MessageID: WebStringReportIntro,
+			Locale:    "es",
+			Message:   intro,
+		},
+		{
+			RealmID:   realm.ID,
+			MessageID: WebStringReportSuccess,
+			Locale:    "es-mx",
+			Message:   success,
+		},
+	}
+
+	opts := []cmp.Option{
+		cmpopts.IgnoreFields(DynamicTranslation{}, "ID", "CreatedAt", "UpdatedAt"),
+		cmpopts.IgnoreUnexported(Errorable{}),
+	}
+	if diff := cmp.Diff(want, got, opts...); diff != "" {
+		t.Errorf("mismatch (-want, +got):\n%s", diff)
+	}
+}
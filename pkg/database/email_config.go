@@ -39,9 +39,9 @@ type EmailConfig struct {
 
 	// SMTPPassword is encrypted/decrypted automatically by callbacks. The
 	// cache fields exist as optimizations.
-	SMTPPassword                string `gorm:"type:varchar(250)" json:"-"` // ignored by zap's JSON formatter
-	SMTPPasswordPlaintextCache  string `gorm:"-"`
-	SMTPPasswordCiphertextCache string `gorm:"-"`
+	SMTPPassword                string `gorm:"type:varchar(250)" json:"-" audit:"redact"` // ignored by zap's JSON formatter
+	SMTPPasswordPlaintextCache  string `gorm:"-" audit:"redact"`
+	SMTPPasswordCiphertextCache string `gorm:"-" audit:"redact"`
 
 	// IsSystem determines if this is a system-level email configuration. There can
 	// only be one system-level email configuration.
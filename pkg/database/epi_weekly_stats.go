@@ -0,0 +1,333 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package database
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/exposure-notifications-server/pkg/timeutils"
+	"github.com/google/exposure-notifications-verification-server/internal/icsv"
+	"github.com/google/exposure-notifications-verification-server/internal/project"
+	"github.com/google/exposure-notifications-verification-server/pkg/cache"
+	"github.com/lib/pq"
+)
+
+var _ icsv.Marshaler = (EpiWeeklyStats)(nil)
+
+// onsetToClaimBuckets are the inclusive upper bounds, in days, of the
+// onset-to-claim distribution. Claims after the last bucket are counted in a
+// final overflow bucket.
+var onsetToClaimBuckets = []uint{2, 5, 8, 14}
+
+// EpiWeeklyStats is a collection of weekly epidemiological stats.
+type EpiWeeklyStats []*EpiWeeklyStat
+
+// EpiWeeklyStat is the de-identified aggregate of the codes of one test type
+// issued in a realm during one MMWR week (Sunday through Saturday).
+type EpiWeeklyStat struct {
+	WeekStart    time.Time `gorm:"column:week_start; type:date;"`
+	RealmID      uint      `gorm:"column:realm_id; type:integer;"`
+	TestType     string    `gorm:"column:test_type; type:text;"`
+	CodesIssued  uint      `gorm:"column:codes_issued; type:integer;"`
+	CodesClaimed uint      `gorm:"column:codes_claimed; type:integer;"`
+
+	// OnsetToClaimDistribution is the number of claimed codes in each
+	// onsetToClaimBuckets bucket, followed by the overflow bucket.
+	OnsetToClaimDistribution pq.Int64Array `gorm:"column:onset_to_claim_distribution; type:bigint[];"`
+
+	// OnsetToClaimUnknown is the number of claimed codes that had neither a
+	// symptom onset date nor a test date.
+	OnsetToClaimUnknown uint `gorm:"column:onset_to_claim_unknown; type:integer;"`
+}
+
+// MMWRWeek returns the MMWR (epidemiological) year and week number of the
+// week. Week 1 is the first week of the year that has at least four days in
+// that calendar year.
+func (s *EpiWeeklyStat) MMWRWeek() (int, int) {
+	return MMWRWeek(s.WeekStart)
+}
+
+// MMWRWeekStart returns the Sunday at UTC midnight that starts the MMWR week
+// containing t.
+func MMWRWeekStart(t time.Time) time.Time {
+	day := timeutils.UTCMidnight(t)
+	return day.AddDate(0, 0, -int(day.Weekday()))
+}
+
+// MMWRWeek returns the MMWR year and week number of the week containing t.
+func MMWRWeek(t time.Time) (int, int) {
+	// The week belongs to the year that contains its Wednesday.
+	wednesday := MMWRWeekStart(t).AddDate(0, 0, 3)
+	return wednesday.Year(), (wednesday.YearDay()-1)/7 + 1
+}
+
+// OnsetToClaimBucketLabels returns the labels of the onset-to-claim
+// distribution buckets, in the form "0_2" for claims zero to two days after
+// onset, ending with the overflow bucket (e.g. "15_plus").
+func OnsetToClaimBucketLabels() []string {
+	labels := make([]string, 0, len(onsetToClaimBuckets)+1)
+	var lower uint
+	for _, upper := range onsetToClaimBuckets {
+		labels = append(labels, fmt.Sprintf("%d_%d", lower, upper))
+		lower = upper + 1
+	}
+	return append(labels, fmt.Sprintf("%d_plus", lower))
+}
+
+// EpiWeeklyStats returns the weekly epidemiological stats for the complete
+// weeks in the stats display period.
+func (r *Realm) EpiWeeklyStats(db *Database) (EpiWeeklyStats, error) {
+	stop := MMWRWeekStart(time.Now()).AddDate(0, 0, -1)
+	start := stop.Add(project.StatsDisplayDays * -24 * time.Hour)
+	return r.EpiWeeklyStatsBetween(db, start, stop)
+}
+
+// EpiWeeklyStatsBetween returns the weekly epidemiological stats for every
+// week that contains a day from start to stop, inclusive. Codes that are
+// still in the verification codes table and codes that have been purged to
+// the code history are both included.
+func (r *Realm) EpiWeeklyStatsBetween(db *Database, start, stop time.Time) (EpiWeeklyStats, error) {
+	if start.After(stop) {
+		return nil, ErrBadDateRange
+	}
+
+	from := MMWRWeekStart(start)
+	to := MMWRWeekStart(stop).AddDate(0, 0, 7)
+
+	var buckets strings.Builder
+	var lower uint
+	for _, upper := range onsetToClaimBuckets {
+		fmt.Fprintf(&buckets, "COUNT(*) FILTER (WHERE outcome = '%s' AND onset_claim_days BETWEEN %d AND %d), ",
+			CodeOutcomeClaimed, lower, upper)
+		lower = upper + 1
+	}
+	fmt.Fprintf(&buckets, "COUNT(*) FILTER (WHERE outcome = '%s' AND onset_claim_days >= %d)",
+		CodeOutcomeClaimed, lower)
+
+	sql := `
+		WITH codes AS (
+			SELECT issue_date, test_type, outcome, onset_claim_days
+			FROM verification_code_history
+			WHERE realm_id = $1 AND issue_date >= $2 AND issue_date < $3
+			UNION ALL
+			SELECT c.issue_date, c.test_type, c.outcome, c.onset_claim_days
+			FROM (
+				SELECT ` + verificationCodeHistorySelect() + `
+				FROM verification_codes
+				WHERE realm_id = $1 AND created_at >= $2 AND created_at < $3
+			) AS c(realm_id, issue_date, issuer_type, test_type, outcome, claim_latency_minutes, onset_claim_days)
+		)
+		SELECT
+			issue_date - EXTRACT(DOW FROM issue_date)::integer AS week_start,
+			$1 AS realm_id,
+			test_type,
+			COUNT(*) AS codes_issued,
+			COUNT(*) FILTER (WHERE outcome = '` + CodeOutcomeClaimed + `') AS codes_claimed,
+			ARRAY[` + buckets.String() + `] AS onset_to_claim_distribution,
+			COUNT(*) FILTER (WHERE outcome = '` + CodeOutcomeClaimed + `' AND onset_claim_days IS NULL) AS onset_to_claim_unknown
+		FROM codes
+		GROUP BY 1, test_type
+		ORDER BY 1 ASC, test_type ASC`
+
+	var stats []*EpiWeeklyStat
+	if err := db.db.Raw(sql, r.ID, from, to).Scan(&stats).Error; err != nil {
+		if IsNotFound(err) {
+			return stats, nil
+		}
+		return nil, err
+	}
+	return stats, nil
+}
+
+// EpiWeeklyStatsCached is stats, but cached.
+func (r *Realm) EpiWeeklyStatsCached(ctx context.Context, db *Database, cacher cache.Cacher) (EpiWeeklyStats, error) {
+	if cacher == nil {
+		return nil, fmt.Errorf("cacher cannot be nil")
+	}
+
+	var stats EpiWeeklyStats
+	cacheKey := &cache.Key{
+		Namespace: "stats:realm:epi_weekly",
+		Key:       strconv.FormatUint(uint64(r.ID), 10),
+	}
+	if err := cacher.Fetch(ctx, cacheKey, &stats, time.Hour, func() (interface{}, error) {
+		return r.EpiWeeklyStats(db)
+	}); err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
+
+// WithPrivacy returns a copy of the stats with the realm's stats privacy mode
+// applied to small counts, as RealmStats.WithPrivacy. The receiver is not
+// modified, since it may be shared via the cache.
+func (s EpiWeeklyStats) WithPrivacy(r *Realm) EpiWeeklyStats {
+	if r == nil || r.StatsPrivacyMode == "" || r.StatsPrivacyMode == StatsPrivacyModeOff {
+		return s
+	}
+
+	result := make(EpiWeeklyStats, 0, len(s))
+	for _, stat := range s {
+		copied := *stat
+		p := &statsPrivatizer{realm: r, date: stat.WeekStart.Format(project.RFC3339Date)}
+		prefix := "epi_weekly:" + stat.TestType + ":"
+
+		copied.CodesIssued = p.count(prefix+"codes_issued", stat.CodesIssued)
+		copied.CodesClaimed = p.count(prefix+"codes_claimed", stat.CodesClaimed)
+		copied.OnsetToClaimUnknown = p.count(prefix+"onset_to_claim_unknown", stat.OnsetToClaimUnknown)
+
+		copied.OnsetToClaimDistribution = make([]int64, len(stat.OnsetToClaimDistribution))
+		for i, v := range stat.OnsetToClaimDistribution {
+			copied.OnsetToClaimDistribution[i] = p.value(fmt.Sprintf("%sonset_to_claim_distribution:%d", prefix, i), v)
+		}
+
+		result = append(result, &copied)
+	}
+	return result
+}
+
+// MarshalCSV returns bytes in CSV format, one row per week and test type.
+func (s EpiWeeklyStats) MarshalCSV() ([]byte, error) {
+	// Do nothing if there's no records
+	if len(s) == 0 {
+		return nil, nil
+	}
+
+	var b bytes.Buffer
+	w := csv.NewWriter(&b)
+
+	header := []string{
+		"week_start", "mmwr_year", "mmwr_week", "test_type",
+		"codes_issued", "codes_claimed",
+	}
+	for _, label := range OnsetToClaimBucketLabels() {
+		header = append(header, "onset_to_claim_days_"+label)
+	}
+	header = append(header, "onset_to_claim_days_unknown")
+	if err := w.Write(header); err != nil {
+		return nil, fmt.Errorf("failed to write CSV header: %w", err)
+	}
+
+	for i, stat := range s {
+		year, week := stat.MMWRWeek()
+		row := []string{
+			stat.WeekStart.Format(project.RFC3339Date),
+			strconv.Itoa(year),
+			strconv.Itoa(week),
+			stat.TestType,
+			strconv.FormatUint(uint64(stat.CodesIssued), 10),
+			strconv.FormatUint(uint64(stat.CodesClaimed), 10),
+		}
+		for _, v := range stat.OnsetToClaimDistribution {
+			row = append(row, strconv.FormatInt(v, 10))
+		}
+		row = append(row, strconv.FormatUint(uint64(stat.OnsetToClaimUnknown), 10))
+
+		if err := w.Write(row); err != nil {
+			return nil, fmt.Errorf("failed to write CSV entry %d: %w", i, err)
+		}
+	}
+
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return nil, fmt.Errorf("failed to create CSV: %w", err)
+	}
+
+	return b.Bytes(), nil
+}
+
+type jsonEpiWeeklyStat struct {
+	RealmID             uint                      `json:"realm_id"`
+	OnsetToClaimBuckets []string                  `json:"onset_to_claim_buckets"`
+	Stats               []*jsonEpiWeeklyStatStats `json:"statistics"`
+}
+
+type jsonEpiWeeklyStatStats struct {
+	WeekStart                time.Time `json:"week_start"`
+	MMWRYear                 int       `json:"mmwr_year"`
+	MMWRWeek                 int       `json:"mmwr_week"`
+	TestType                 string    `json:"test_type"`
+	CodesIssued              uint      `json:"codes_issued"`
+	CodesClaimed             uint      `json:"codes_claimed"`
+	OnsetToClaimDistribution []int64   `json:"onset_to_claim_distribution"`
+	OnsetToClaimUnknown      uint      `json:"onset_to_claim_unknown"`
+}
+
+// MarshalJSON is a custom JSON marshaller.
+func (s EpiWeeklyStats) MarshalJSON() ([]byte, error) {
+	// Do nothing if there's no records
+	if len(s) == 0 {
+		return json.Marshal(struct{}{})
+	}
+
+	stats := make([]*jsonEpiWeeklyStatStats, 0, len(s))
+	for _, stat := range s {
+		year, week := stat.MMWRWeek()
+		stats = append(stats, &jsonEpiWeeklyStatStats{
+			WeekStart:                stat.WeekStart,
+			MMWRYear:                 year,
+			MMWRWeek:                 week,
+			TestType:                 stat.TestType,
+			CodesIssued:              stat.CodesIssued,
+			CodesClaimed:             stat.CodesClaimed,
+			OnsetToClaimDistribution: stat.OnsetToClaimDistribution,
+			OnsetToClaimUnknown:      stat.OnsetToClaimUnknown,
+		})
+	}
+
+	var result jsonEpiWeeklyStat
+	result.RealmID = s[0].RealmID
+	result.OnsetToClaimBuckets = OnsetToClaimBucketLabels()
+	result.Stats = stats
+
+	b, err := json.Marshal(result)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal json: %w", err)
+	}
+	return b, nil
+}
+
+// UnmarshalJSON is a custom JSON unmarshaller.
+func (s *EpiWeeklyStats) UnmarshalJSON(b []byte) error {
+	// Do nothing if there's no records
+	if len(b) == 0 {
+		return nil
+	}
+
+	var result jsonEpiWeeklyStat
+	if err := json.Unmarshal(b, &result); err != nil {
+		return err
+	}
+
+	for _, stat := range result.Stats {
+		*s = append(*s, &EpiWeeklyStat{
+			WeekStart:                stat.WeekStart,
+			RealmID:                  result.RealmID,
+			TestType:                 stat.TestType,
+			CodesIssued:              stat.CodesIssued,
+			CodesClaimed:             stat.CodesClaimed,
+			OnsetToClaimDistribution: stat.OnsetToClaimDistribution,
+			OnsetToClaimUnknown:      stat.OnsetToClaimUnknown,
+		})
+	}
+	return nil
+}
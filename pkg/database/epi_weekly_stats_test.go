@@ -0,0 +1,177 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package database
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestMMWRWeek(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name  string
+		date  string
+		start string
+		year  int
+		week  int
+	}{
+		{"first_week", "2021-01-06", "2021-01-03", 2021, 1},
+		{"previous_year", "2021-01-01", "2020-12-27", 2020, 53},
+		{"last_week", "2022-01-01", "2021-12-26", 2021, 52},
+		{"sunday", "2021-06-13", "2021-06-13", 2021, 24},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			date, err := time.Parse("2006-01-02", tc.date)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got, want := MMWRWeekStart(date).Format("2006-01-02"), tc.start; got != want {
+				t.Errorf("expected %q to be %q", got, want)
+			}
+
+			year, week := MMWRWeek(date)
+			if got, want := year, tc.year; got != want {
+				t.Errorf("expected %d to be %d", got, want)
+			}
+			if got, want := week, tc.week; got != want {
+				t.Errorf("expected %d to be %d", got, want)
+			}
+		})
+	}
+}
+
+func TestOnsetToClaimBucketLabels(t *testing.T) {
+	t.Parallel()
+
+	want := []string{"0_2", "3_5", "6_8", "9_14", "15_plus"}
+	if diff := cmp.Diff(want, OnsetToClaimBucketLabels()); diff != "" {
+		t.Errorf("mismatch (-want, +got):\n%s", diff)
+	}
+}
+
+func TestEpiWeeklyStats_MarshalCSV(t *testing.T) {
+	t.Parallel()
+
+	stats := EpiWeeklyStats{
+		{
+			WeekStart:                time.Date(2021, 1, 3, 0, 0, 0, 0, time.UTC),
+			RealmID:                  1,
+			TestType:                 "confirmed",
+			CodesIssued:              20,
+			CodesClaimed:             12,
+			OnsetToClaimDistribution: []int64{3, 4, 2, 1, 1},
+			OnsetToClaimUnknown:      1,
+		},
+	}
+
+	b, err := stats.MarshalCSV()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := strings.Join([]string{
+		"week_start,mmwr_year,mmwr_week,test_type,codes_issued,codes_claimed," +
+			"onset_to_claim_days_0_2,onset_to_claim_days_3_5,onset_to_claim_days_6_8," +
+			"onset_to_claim_days_9_14,onset_to_claim_days_15_plus,onset_to_claim_days_unknown",
+		"2021-01-03,2021,1,confirmed,20,12,3,4,2,1,1,1",
+		"",
+	}, "\n")
+	if got := string(b); got != want {
+		t.Errorf("expected %q to be %q", got, want)
+	}
+}
+
+func TestDatabase_EpiWeeklyStatsBetween(t *testing.T) {
+	t.Parallel()
+
+	db, _ := testDatabaseInstance.NewDatabase(t, nil)
+
+	realm, err := db.FindRealm(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	days := func(n uint) *uint { return &n }
+	weekStart := MMWRWeekStart(time.Now()).AddDate(0, 0, -14)
+
+	history := []*VerificationCodeHistory{
+		{IssueDate: weekStart, TestType: "confirmed", Outcome: CodeOutcomeClaimed, OnsetClaimDays: days(1)},
+		{IssueDate: weekStart.AddDate(0, 0, 2), TestType: "confirmed", Outcome: CodeOutcomeClaimed, OnsetClaimDays: days(4)},
+		{IssueDate: weekStart.AddDate(0, 0, 6), TestType: "confirmed", Outcome: CodeOutcomeClaimed, OnsetClaimDays: days(20)},
+		{IssueDate: weekStart.AddDate(0, 0, 6), TestType: "confirmed", Outcome: CodeOutcomeClaimed},
+		{IssueDate: weekStart.AddDate(0, 0, 3), TestType: "confirmed", Outcome: CodeOutcomeExpired},
+		{IssueDate: weekStart.AddDate(0, 0, 3), TestType: "likely", Outcome: CodeOutcomeExpired},
+		{IssueDate: weekStart.AddDate(0, 0, 7), TestType: "confirmed", Outcome: CodeOutcomeClaimed, OnsetClaimDays: days(9)},
+	}
+	for _, h := range history {
+		h.RealmID = realm.ID
+		h.IssuerType = CodeIssuerTypeAPI
+		if err := db.db.Create(h).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	stats, err := realm.EpiWeeklyStatsBetween(db, weekStart, weekStart.AddDate(0, 0, 13))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := EpiWeeklyStats{
+		{
+			WeekStart:                weekStart,
+			RealmID:                  realm.ID,
+			TestType:                 "confirmed",
+			CodesIssued:              5,
+			CodesClaimed:             4,
+			OnsetToClaimDistribution: []int64{1, 1, 0, 0, 1},
+			OnsetToClaimUnknown:      1,
+		},
+		{
+			WeekStart:                weekStart,
+			RealmID:                  realm.ID,
+			TestType:                 "likely",
+			CodesIssued:              1,
+			OnsetToClaimDistribution: []int64{0, 0, 0, 0, 0},
+		},
+		{
+			WeekStart:                weekStart.AddDate(0, 0, 7),
+			RealmID:                  realm.ID,
+			TestType:                 "confirmed",
+			CodesIssued:              1,
+			CodesClaimed:             1,
+			OnsetToClaimDistribution: []int64{0, 0, 0, 1, 0},
+		},
+	}
+	opts := cmp.Comparer(func(a, b time.Time) bool { return a.Equal(b) })
+	if diff := cmp.Diff(want, stats, opts); diff != "" {
+		t.Errorf("mismatch (-want, +got):\n%s", diff)
+	}
+
+	if _, err := realm.EpiWeeklyStatsBetween(db, weekStart, weekStart.AddDate(0, 0, -1)); err != ErrBadDateRange {
+		t.Errorf("expected %v to be %v", err, ErrBadDateRange)
+	}
+}
@@ -25,6 +25,14 @@ var (
 	// used as a SigningKeyManager.
 	ErrNoSigningKeyManager = errors.New("configured key manager cannot be used to manage per-realm keys")
 
+	// ErrUnsupportedSigningAlgorithm is the error returned when the key manager
+	// cannot create signing keys for the requested algorithm.
+	ErrUnsupportedSigningAlgorithm = errors.New("configured key manager cannot create keys for the signing algorithm")
+
+	// ErrNoSigningKeysToMigrate is the error returned when a realm has no signing
+	// keys that are still held by the source key manager.
+	ErrNoSigningKeysToMigrate = errors.New("no signing keys to migrate")
+
 	// ErrValidationFailed is the error returned when validation failed. This
 	// should always be considered user error.
 	ErrValidationFailed = errors.New("validation failed")
@@ -23,8 +23,11 @@ import (
 	"strconv"
 	"time"
 
+	"github.com/google/exposure-notifications-server/pkg/timeutils"
 	"github.com/google/exposure-notifications-verification-server/internal/icsv"
 	"github.com/google/exposure-notifications-verification-server/internal/project"
+	"github.com/google/exposure-notifications-verification-server/pkg/pagination"
+	"github.com/jinzhu/gorm"
 )
 
 var _ icsv.Marshaler = (ExternalIssuerStats)(nil)
@@ -155,17 +158,96 @@ func (s *ExternalIssuerStats) UnmarshalJSON(b []byte) error {
 	return nil
 }
 
+// ExternalIssuerIssuance is the code issuance of a single external issuer over
+// a date range.
+type ExternalIssuerIssuance struct {
+	IssuerID string
+
+	// CodesIssued is the total number of codes issued in the range.
+	CodesIssued uint
+
+	// Days is the issuance on each day of the range, oldest first. Days without
+	// issuance are included with a count of 0.
+	Days []*ExternalIssuerStat
+}
+
+// ExternalIssuerIssuanceBetween returns the code issuance of the realm's
+// external issuers between the start and end dates, inclusive. Results are
+// ordered by issuer ID and paginated by issuer. If issuerID is not blank, only
+// that issuer is returned.
+func (r *Realm) ExternalIssuerIssuanceBetween(db *Database, start, end time.Time, issuerID string, p *pagination.PageParams) ([]*ExternalIssuerIssuance, *pagination.Paginator, error) {
+	start = timeutils.UTCMidnight(start)
+	end = timeutils.UTCMidnight(end)
+	if start.After(end) {
+		return nil, nil, ErrBadDateRange
+	}
+
+	query := db.db.
+		Model(&ExternalIssuerStat{}).
+		Select("issuer_id").
+		Where("realm_id = ?", r.ID).
+		Where("date >= ? AND date <= ?", start, end).
+		Group("issuer_id")
+	if issuerID != "" {
+		query = query.Where("issuer_id = ?", issuerID)
+	}
+
+	var issuerIDs []string
+	paginator, err := PaginateFn(query, p.Page, p.Limit, func(query *gorm.DB, offset uint64) error {
+		return query.
+			Order("issuer_id ASC").
+			Limit(p.Limit).
+			Offset(offset).
+			Pluck("issuer_id", &issuerIDs).
+			Error
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to list external issuers: %w", err)
+	}
+	if len(issuerIDs) == 0 {
+		return []*ExternalIssuerIssuance{}, paginator, nil
+	}
+
+	var stats []*ExternalIssuerStat
+	if err := db.db.
+		Model(&ExternalIssuerStat{}).
+		Where("realm_id = ?", r.ID).
+		Where("issuer_id IN (?)", issuerIDs).
+		Where("date >= ? AND date <= ?", start, end).
+		Find(&stats).
+		Error; err != nil {
+		return nil, nil, fmt.Errorf("failed to list external issuer stats: %w", err)
+	}
+
+	counts := make(map[string]map[time.Time]uint, len(issuerIDs))
+	for _, stat := range stats {
+		if counts[stat.IssuerID] == nil {
+			counts[stat.IssuerID] = make(map[time.Time]uint)
+		}
+		counts[stat.IssuerID][timeutils.UTCMidnight(stat.Date)] += stat.CodesIssued
+	}
+
+	result := make([]*ExternalIssuerIssuance, 0, len(issuerIDs))
+	for _, id := range issuerIDs {
+		issuance := &ExternalIssuerIssuance{IssuerID: id}
+		for day := start; !day.After(end); day = day.AddDate(0, 0, 1) {
+			count := counts[id][day]
+			issuance.CodesIssued += count
+			issuance.Days = append(issuance.Days, &ExternalIssuerStat{
+				Date:        day,
+				RealmID:     r.ID,
+				IssuerID:    id,
+				CodesIssued: count,
+			})
+		}
+		result = append(result, issuance)
+	}
+	return result, paginator, nil
+}
+
 // PurgeExternalIssuerStats will delete stats that were created longer than
 // maxAge ago.
 func (db *Database) PurgeExternalIssuerStats(maxAge time.Duration) (int64, error) {
-	if maxAge > 0 {
-		maxAge = -1 * maxAge
-	}
-	createdBefore := time.Now().UTC().Add(maxAge)
-
-	result := db.db.
-		Unscoped().
-		Where("date < ?", createdBefore).
-		Delete(&ExternalIssuerStat{})
-	return result.RowsAffected, result.Error
+	return db.purgeWithRealmRetention(&ExternalIssuerStat{}, "external_issuer_stats.date", "external_issuer_stats.realm_id",
+		"stats_retention_days", maxAge)
 }
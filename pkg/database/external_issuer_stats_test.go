@@ -15,10 +15,12 @@
 package database
 
 import (
+	"errors"
 	"testing"
 	"time"
 
 	"github.com/google/exposure-notifications-server/pkg/timeutils"
+	"github.com/google/exposure-notifications-verification-server/pkg/pagination"
 	"github.com/google/go-cmp/cmp"
 )
 
@@ -108,6 +110,102 @@ func TestExternalIssuerStats_MarshalCSV(t *testing.T) {
 	}
 }
 
+func TestRealm_ExternalIssuerIssuanceBetween(t *testing.T) {
+	t.Parallel()
+
+	db, _ := testDatabaseInstance.NewDatabase(t, nil)
+
+	realm, err := db.FindRealm(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	today := timeutils.UTCMidnight(time.Now().UTC())
+	yesterday := today.Add(-24 * time.Hour)
+	for _, stat := range []*ExternalIssuerStat{
+		{Date: yesterday, RealmID: realm.ID, IssuerID: "hospital-a", CodesIssued: 3},
+		{Date: today, RealmID: realm.ID, IssuerID: "hospital-a", CodesIssued: 2},
+		{Date: today, RealmID: realm.ID, IssuerID: "hospital-b", CodesIssued: 7},
+		{Date: today, RealmID: realm.ID + 1, IssuerID: "hospital-c", CodesIssued: 9},
+	} {
+		if err := db.RawDB().Create(stat).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	t.Run("all", func(t *testing.T) {
+		t.Parallel()
+
+		issuance, _, err := realm.ExternalIssuerIssuanceBetween(db, yesterday, today, "", pagination.UnlimitedResults)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := len(issuance), 2; got != want {
+			t.Fatalf("expected %d to be %d", got, want)
+		}
+
+		a := issuance[0]
+		if got, want := a.IssuerID, "hospital-a"; got != want {
+			t.Errorf("expected %q to be %q", got, want)
+		}
+		if got, want := a.CodesIssued, uint(5); got != want {
+			t.Errorf("expected %d to be %d", got, want)
+		}
+
+		b := issuance[1]
+		if got, want := len(b.Days), 2; got != want {
+			t.Fatalf("expected %d to be %d", got, want)
+		}
+		if got, want := b.Days[0].CodesIssued, uint(0); got != want {
+			t.Errorf("expected %d to be %d", got, want)
+		}
+		if got, want := b.Days[1].CodesIssued, uint(7); got != want {
+			t.Errorf("expected %d to be %d", got, want)
+		}
+	})
+
+	t.Run("paginated", func(t *testing.T) {
+		t.Parallel()
+
+		issuance, paginator, err := realm.ExternalIssuerIssuanceBetween(db, yesterday, today, "", &pagination.PageParams{Page: 1, Limit: 1})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := len(issuance), 1; got != want {
+			t.Fatalf("expected %d to be %d", got, want)
+		}
+		if paginator.NextPage == nil {
+			t.Fatalf("expected next page")
+		}
+		if got, want := paginator.NextPage.Number, uint64(2); got != want {
+			t.Errorf("expected %d to be %d", got, want)
+		}
+	})
+
+	t.Run("issuer", func(t *testing.T) {
+		t.Parallel()
+
+		issuance, _, err := realm.ExternalIssuerIssuanceBetween(db, today, today, "hospital-b", pagination.UnlimitedResults)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := len(issuance), 1; got != want {
+			t.Fatalf("expected %d to be %d", got, want)
+		}
+		if got, want := issuance[0].CodesIssued, uint(7); got != want {
+			t.Errorf("expected %d to be %d", got, want)
+		}
+	})
+
+	t.Run("bad_range", func(t *testing.T) {
+		t.Parallel()
+
+		if _, _, err := realm.ExternalIssuerIssuanceBetween(db, today, yesterday, "", pagination.UnlimitedResults); !errors.Is(err, ErrBadDateRange) {
+			t.Errorf("expected %v to be %v", err, ErrBadDateRange)
+		}
+	})
+}
+
 func TestDatabase_PurgeExternalIssuerStats(t *testing.T) {
 	t.Parallel()
 
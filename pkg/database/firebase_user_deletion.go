@@ -0,0 +1,196 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package database
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+var _ Auditable = (*FirebaseUserDeletion)(nil)
+
+// FirebaseUserDeletion is a pending deletion of the Firebase account of a user
+// who was deleted or lost their last realm membership. The cleanup service
+// first disables the account and later deletes it. The deletion is cancelled if
+// the user is added to a realm or becomes a system admin before it completes.
+type FirebaseUserDeletion struct {
+	// ID is the pending deletion's ID.
+	ID uint `gorm:"primary_key;"`
+
+	// Email is the email address of the Firebase account.
+	Email string `gorm:"column:email; type:text; not null;"`
+
+	// RequestedAt is when the user lost their last membership or was deleted.
+	RequestedAt time.Time `gorm:"column:requested_at; type:timestamp with time zone; not null;"`
+
+	// DisabledAt is when the Firebase account was disabled. It is nil until
+	// then.
+	DisabledAt *time.Time `gorm:"column:disabled_at; type:timestamp with time zone;"`
+}
+
+// TableName sets the table name.
+func (FirebaseUserDeletion) TableName() string {
+	return "firebase_user_deletions"
+}
+
+// AuditID is how the pending deletion is stored in the audit entry.
+func (d *FirebaseUserDeletion) AuditID() string {
+	return fmt.Sprintf("firebase_user_deletions:%d", d.ID)
+}
+
+// AuditDisplay is how the pending deletion will be displayed in audit entries.
+func (d *FirebaseUserDeletion) AuditDisplay() string {
+	return d.Email
+}
+
+// firebaseUserDeletionEligible restricts pending deletions to emails that do
+// not belong to a system admin, a break-glass account, or a user with a realm
+// membership.
+const firebaseUserDeletionEligible = `NOT EXISTS (
+	SELECT 1 FROM users
+	WHERE LOWER(users.email) = firebase_user_deletions.email
+		AND users.deleted_at IS NULL
+		AND (users.system_admin OR users.break_glass OR EXISTS (SELECT 1 FROM memberships WHERE memberships.user_id = users.id))
+)`
+
+// requestFirebaseUserDeletion records a pending deletion of the Firebase
+// account with the email. If a deletion is already pending, its grace period
+// is not restarted.
+func requestFirebaseUserDeletion(tx *gorm.DB, email string) error {
+	sql := `
+		INSERT INTO firebase_user_deletions (email, requested_at)
+			VALUES ($1, $2)
+		ON CONFLICT (email) DO NOTHING
+	`
+
+	if err := tx.Exec(sql, strings.ToLower(email), time.Now().UTC()).Error; err != nil {
+		return fmt.Errorf("failed to request firebase user deletion: %w", err)
+	}
+	return nil
+}
+
+// cancelFirebaseUserDeletion removes any pending deletion of the Firebase
+// account with the email.
+func cancelFirebaseUserDeletion(tx *gorm.DB, email string) error {
+	if err := tx.
+		Unscoped().
+		Where("email = ?", strings.ToLower(email)).
+		Delete(&FirebaseUserDeletion{}).
+		Error; err != nil {
+		return fmt.Errorf("failed to cancel firebase user deletion: %w", err)
+	}
+	return nil
+}
+
+// FindFirebaseUserDeletion finds the pending deletion of the Firebase account
+// with the email.
+func (db *Database) FindFirebaseUserDeletion(email string) (*FirebaseUserDeletion, error) {
+	var d FirebaseUserDeletion
+	if err := db.db.
+		Model(&FirebaseUserDeletion{}).
+		Where("email = ?", strings.ToLower(email)).
+		First(&d).
+		Error; err != nil {
+		return nil, err
+	}
+	return &d, nil
+}
+
+// ListFirebaseUserDeletionsToDisable returns up to limit pending deletions
+// that were requested before the given time and have not been disabled.
+func (db *Database) ListFirebaseUserDeletionsToDisable(requestedBefore time.Time, limit uint) ([]*FirebaseUserDeletion, error) {
+	var deletions []*FirebaseUserDeletion
+	if err := db.db.
+		Model(&FirebaseUserDeletion{}).
+		Where("disabled_at IS NULL AND requested_at < ?", requestedBefore).
+		Where(firebaseUserDeletionEligible).
+		Order("requested_at ASC").
+		Limit(limit).
+		Find(&deletions).
+		Error; err != nil {
+		if IsNotFound(err) {
+			return deletions, nil
+		}
+		return nil, err
+	}
+	return deletions, nil
+}
+
+// ListFirebaseUserDeletionsToDelete returns up to limit pending deletions
+// whose accounts were disabled before the given time.
+func (db *Database) ListFirebaseUserDeletionsToDelete(disabledBefore time.Time, limit uint) ([]*FirebaseUserDeletion, error) {
+	var deletions []*FirebaseUserDeletion
+	if err := db.db.
+		Model(&FirebaseUserDeletion{}).
+		Where("disabled_at < ?", disabledBefore).
+		Where(firebaseUserDeletionEligible).
+		Order("disabled_at ASC").
+		Limit(limit).
+		Find(&deletions).
+		Error; err != nil {
+		if IsNotFound(err) {
+			return deletions, nil
+		}
+		return nil, err
+	}
+	return deletions, nil
+}
+
+// MarkFirebaseUserDisabled records that the Firebase account was disabled.
+func (db *Database) MarkFirebaseUserDisabled(d *FirebaseUserDeletion, actor Auditable) error {
+	if actor == nil {
+		return ErrMissingActor
+	}
+
+	return db.db.Transaction(func(tx *gorm.DB) error {
+		now := time.Now().UTC()
+		if err := tx.
+			Model(d).
+			UpdateColumn("disabled_at", now).
+			Error; err != nil {
+			return fmt.Errorf("failed to mark firebase user disabled: %w", err)
+		}
+		d.DisabledAt = &now
+
+		audit := BuildAuditEntry(actor, "disabled firebase account", d, 0)
+		if err := tx.Save(audit).Error; err != nil {
+			return fmt.Errorf("failed to save audit: %w", err)
+		}
+		return nil
+	})
+}
+
+// CompleteFirebaseUserDeletion records that the Firebase account was deleted
+// and removes the pending deletion.
+func (db *Database) CompleteFirebaseUserDeletion(d *FirebaseUserDeletion, actor Auditable) error {
+	if actor == nil {
+		return ErrMissingActor
+	}
+
+	return db.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Delete(d).Error; err != nil {
+			return fmt.Errorf("failed to complete firebase user deletion: %w", err)
+		}
+
+		audit := BuildAuditEntry(actor, "deleted firebase account", d, 0)
+		if err := tx.Save(audit).Error; err != nil {
+			return fmt.Errorf("failed to save audit: %w", err)
+		}
+		return nil
+	})
+}
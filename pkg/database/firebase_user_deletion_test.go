@@ -0,0 +1,173 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package database
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/exposure-notifications-verification-server/pkg/rbac"
+)
+
+func TestFirebaseUserDeletion_Lifecycle(t *testing.T) {
+	t.Parallel()
+
+	db, _ := testDatabaseInstance.NewDatabase(t, nil)
+
+	realm1 := NewRealmWithDefaults("realm1")
+	if err := db.SaveRealm(realm1, SystemTest); err != nil {
+		t.Fatal(err)
+	}
+	realm2 := NewRealmWithDefaults("realm2")
+	if err := db.SaveRealm(realm2, SystemTest); err != nil {
+		t.Fatal(err)
+	}
+
+	user := &User{
+		Email: "Firebase@example.com",
+		Name:  "Firebase",
+	}
+	if err := db.SaveUser(user, SystemTest); err != nil {
+		t.Fatal(err)
+	}
+	if err := user.AddToRealm(db, realm1, rbac.CodeIssue, SystemTest); err != nil {
+		t.Fatal(err)
+	}
+	if err := user.AddToRealm(db, realm2, rbac.CodeIssue, SystemTest); err != nil {
+		t.Fatal(err)
+	}
+
+	// Removing from one of two realms does not request deletion.
+	if err := user.DeleteFromRealm(db, realm1, SystemTest); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.FindFirebaseUserDeletion(user.Email); !IsNotFound(err) {
+		t.Fatalf("expected not found, got %v", err)
+	}
+
+	// Removing from the last realm requests deletion.
+	if err := user.DeleteFromRealm(db, realm2, SystemTest); err != nil {
+		t.Fatal(err)
+	}
+	deletion, err := db.FindFirebaseUserDeletion(user.Email)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := deletion.Email, "firebase@example.com"; got != want {
+		t.Errorf("expected %q to be %q", got, want)
+	}
+
+	// Nothing is due before the grace period.
+	toDisable, err := db.ListFirebaseUserDeletionsToDisable(deletion.RequestedAt.Add(-time.Second), 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(toDisable), 0; got != want {
+		t.Errorf("expected %d to be %d", got, want)
+	}
+
+	future := time.Now().UTC().Add(time.Hour)
+	toDisable, err = db.ListFirebaseUserDeletionsToDisable(future, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(toDisable), 1; got != want {
+		t.Fatalf("expected %d to be %d", got, want)
+	}
+
+	if err := db.MarkFirebaseUserDisabled(toDisable[0], SystemTest); err != nil {
+		t.Fatal(err)
+	}
+
+	// Disabled accounts are not disabled again.
+	toDisable, err = db.ListFirebaseUserDeletionsToDisable(future, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(toDisable), 0; got != want {
+		t.Errorf("expected %d to be %d", got, want)
+	}
+
+	toDelete, err := db.ListFirebaseUserDeletionsToDelete(future, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(toDelete), 1; got != want {
+		t.Fatalf("expected %d to be %d", got, want)
+	}
+
+	if err := db.CompleteFirebaseUserDeletion(toDelete[0], SystemTest); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.FindFirebaseUserDeletion(user.Email); !IsNotFound(err) {
+		t.Errorf("expected not found, got %v", err)
+	}
+}
+
+func TestFirebaseUserDeletion_Cancel(t *testing.T) {
+	t.Parallel()
+
+	db, _ := testDatabaseInstance.NewDatabase(t, nil)
+
+	realm := NewRealmWithDefaults("realm")
+	if err := db.SaveRealm(realm, SystemTest); err != nil {
+		t.Fatal(err)
+	}
+
+	user := &User{
+		Email: "cancel@example.com",
+		Name:  "Cancel",
+	}
+	if err := db.SaveUser(user, SystemTest); err != nil {
+		t.Fatal(err)
+	}
+	if err := user.AddToRealm(db, realm, rbac.CodeIssue, SystemTest); err != nil {
+		t.Fatal(err)
+	}
+	if err := user.DeleteFromRealm(db, realm, SystemTest); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.FindFirebaseUserDeletion(user.Email); err != nil {
+		t.Fatal(err)
+	}
+
+	// Adding the user back cancels the deletion.
+	if err := user.AddToRealm(db, realm, rbac.CodeIssue, SystemTest); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.FindFirebaseUserDeletion(user.Email); !IsNotFound(err) {
+		t.Errorf("expected not found, got %v", err)
+	}
+
+	// Deleting the user requests deletion.
+	if err := db.DeleteUser(user, SystemTest); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.FindFirebaseUserDeletion(user.Email); err != nil {
+		t.Fatal(err)
+	}
+
+	// Recreating the user cancels the deletion.
+	recreated := &User{
+		Email: "cancel@example.com",
+		Name:  "Cancel",
+	}
+	if err := db.SaveUser(recreated, SystemTest); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.FindFirebaseUserDeletion(user.Email); !IsNotFound(err) {
+		t.Errorf("expected not found, got %v", err)
+	}
+}
@@ -0,0 +1,234 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package database
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/exposure-notifications-verification-server/internal/project"
+	"github.com/google/exposure-notifications-verification-server/pkg/api"
+	"github.com/jinzhu/gorm"
+)
+
+// maxIssuancePresetNameLength is the maximum length of a preset name.
+const maxIssuancePresetNameLength = 64
+
+// IssuancePreset is a named set of values that are commonly used together
+// when issuing verification codes in a realm, such as "Lab confirmed, SMS in
+// Spanish". Presets can be selected in the issue UI or referenced by name in
+// the issue API. Values in the request take precedence over the preset.
+type IssuancePreset struct {
+	Errorable
+
+	ID      uint `gorm:"primary_key;" json:"id,omitempty"`
+	RealmID uint `gorm:"column:realm_id; type:integer; not null;" json:"-"`
+
+	// Name is the unique (per-realm) name of the preset.
+	Name string `gorm:"column:name; type:text; not null;" json:"name"`
+
+	// TestType is the test type to issue, or blank to leave it unset.
+	TestType string `gorm:"column:test_type; type:text; not null; default:'';" json:"testType"`
+
+	// SymptomDateOffsetDays, if set, is the number of days before the current
+	// day to use as the symptom onset date.
+	SymptomDateOffsetDays *uint `gorm:"column:symptom_date_offset_days; type:integer;" json:"symptomDateOffsetDays,omitempty"`
+
+	// SMSTemplateLabel is the SMS template to use, or blank for the default.
+	SMSTemplateLabel string `gorm:"column:sms_template_label; type:text; not null; default:'';" json:"smsTemplateLabel"`
+}
+
+// TableName sets the table name.
+func (IssuancePreset) TableName() string {
+	return "issuance_presets"
+}
+
+// BeforeSave runs validations. If there are errors, the save fails.
+func (p *IssuancePreset) BeforeSave(tx *gorm.DB) error {
+	p.Name = project.TrimSpace(p.Name)
+	if p.Name == "" {
+		p.AddError("name", "cannot be blank")
+	}
+	if len(p.Name) > maxIssuancePresetNameLength {
+		p.AddError("name", fmt.Sprintf("must be %d characters or fewer", maxIssuancePresetNameLength))
+	}
+
+	p.TestType = strings.ToLower(project.TrimSpace(p.TestType))
+	switch p.TestType {
+	case "", api.TestTypeConfirmed, api.TestTypeLikely, api.TestTypeNegative:
+	default:
+		p.AddError("testType", "must be confirmed, likely, or negative")
+	}
+
+	p.SMSTemplateLabel = project.TrimSpace(p.SMSTemplateLabel)
+
+	return p.ErrorOrNil()
+}
+
+// SymptomDate returns the symptom date for the preset relative to now, in the
+// timezone given by tzOffset (in minutes). It returns the empty string if the
+// preset does not set a symptom date.
+func (p *IssuancePreset) SymptomDate(now time.Time, tzOffset float32) string {
+	if p.SymptomDateOffsetDays == nil {
+		return ""
+	}
+
+	local := now.UTC().Add(time.Duration(tzOffset) * time.Minute)
+	return local.AddDate(0, 0, -int(*p.SymptomDateOffsetDays)).Format(project.RFC3339Date)
+}
+
+// ApplyTo fills the unset values on the given request from the preset. Values
+// already present on the request are not changed.
+func (p *IssuancePreset) ApplyTo(req *api.IssueCodeRequest, now time.Time) {
+	if req.TestType == "" {
+		req.TestType = p.TestType
+	}
+	if req.SymptomDate == "" && req.TestDate == "" {
+		req.SymptomDate = p.SymptomDate(now, req.TZOffset)
+	}
+	if req.SMSTemplateLabel == "" {
+		req.SMSTemplateLabel = p.SMSTemplateLabel
+	}
+}
+
+// auditString is the representation of the preset in audit diffs.
+func (p *IssuancePreset) auditString() string {
+	offset := "none"
+	if p.SymptomDateOffsetDays != nil {
+		offset = fmt.Sprintf("%d", *p.SymptomDateOffsetDays)
+	}
+	return fmt.Sprintf("%s (test_type=%q, symptom_date_offset_days=%s, sms_template_label=%q)",
+		p.Name, p.TestType, offset, p.SMSTemplateLabel)
+}
+
+// ListIssuancePresets returns the realm's issuance presets, sorted by name.
+func (r *Realm) ListIssuancePresets(db *Database) ([]*IssuancePreset, error) {
+	var presets []*IssuancePreset
+	if err := db.db.
+		Model(&IssuancePreset{}).
+		Where("realm_id = ?", r.ID).
+		Order("LOWER(name) ASC").
+		Find(&presets).
+		Error; err != nil {
+		if IsNotFound(err) {
+			return presets, nil
+		}
+		return nil, err
+	}
+	return presets, nil
+}
+
+// FindIssuancePreset finds the realm's issuance preset by name. Names are
+// matched case-insensitively.
+func (r *Realm) FindIssuancePreset(db *Database, name string) (*IssuancePreset, error) {
+	var preset IssuancePreset
+	if err := db.db.
+		Model(&IssuancePreset{}).
+		Where("realm_id = ?", r.ID).
+		Where("LOWER(name) = LOWER(?)", project.TrimSpace(name)).
+		First(&preset).
+		Error; err != nil {
+		return nil, err
+	}
+	return &preset, nil
+}
+
+// SaveIssuancePresets replaces the realm's issuance presets with the given
+// list. Presets with an ID are updated, presets without an ID are created, and
+// existing presets which are not in the list are deleted. Validation errors
+// are added to the individual presets.
+func (r *Realm) SaveIssuancePresets(db *Database, presets []*IssuancePreset, actor Auditable) error {
+	if actor == nil {
+		return ErrMissingActor
+	}
+
+	return db.db.Transaction(func(tx *gorm.DB) error {
+		var existing []*IssuancePreset
+		if err := tx.
+			Model(&IssuancePreset{}).
+			Where("realm_id = ?", r.ID).
+			Find(&existing).
+			Error; err != nil && !IsNotFound(err) {
+			return fmt.Errorf("failed to list existing issuance presets: %w", err)
+		}
+
+		// Only allow updating presets which belong to this realm.
+		existingIDs := make(map[uint]struct{}, len(existing))
+		for _, preset := range existing {
+			existingIDs[preset.ID] = struct{}{}
+		}
+
+		seen := make(map[string]struct{}, len(presets))
+		ids := make([]uint, 0, len(presets))
+		var invalid bool
+		for _, preset := range presets {
+			preset.RealmID = r.ID
+			if _, ok := existingIDs[preset.ID]; !ok {
+				preset.ID = 0
+			}
+
+			key := strings.ToLower(project.TrimSpace(preset.Name))
+			if _, ok := seen[key]; ok {
+				preset.AddError("name", "must be unique")
+				invalid = true
+				continue
+			}
+			seen[key] = struct{}{}
+
+			if err := tx.Save(preset).Error; err != nil {
+				if IsValidationError(err) {
+					invalid = true
+					continue
+				}
+				return fmt.Errorf("failed to save issuance preset %q: %w", preset.Name, err)
+			}
+			ids = append(ids, preset.ID)
+		}
+		if invalid {
+			return ErrValidationFailed
+		}
+
+		del := tx.Unscoped().Where("realm_id = ?", r.ID)
+		if len(ids) > 0 {
+			del = del.Where("id NOT IN (?)", ids)
+		}
+		if err := del.Delete(&IssuancePreset{}).Error; err != nil {
+			return fmt.Errorf("failed to delete old issuance presets: %w", err)
+		}
+
+		then, now := issuancePresetsAuditString(existing), issuancePresetsAuditString(presets)
+		if then != now {
+			audit := BuildAuditEntry(actor, "updated issuance presets", r, r.ID)
+			audit.Diff = stringDiff(then, now)
+			if err := tx.Save(audit).Error; err != nil {
+				return fmt.Errorf("failed to save audits: %w", err)
+			}
+		}
+		return nil
+	})
+}
+
+// issuancePresetsAuditString returns a stable, multi-line representation of
+// the presets for audit diffs.
+func issuancePresetsAuditString(presets []*IssuancePreset) string {
+	lines := make([]string, 0, len(presets))
+	for _, p := range presets {
+		lines = append(lines, p.auditString())
+	}
+	sort.Strings(lines)
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,163 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package database
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/exposure-notifications-verification-server/pkg/api"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestIssuancePreset_ApplyTo(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2022, 3, 10, 1, 0, 0, 0, time.UTC)
+
+	preset := &IssuancePreset{
+		Name:                  "Lab confirmed",
+		TestType:              api.TestTypeConfirmed,
+		SymptomDateOffsetDays: uintPtr(2),
+		SMSTemplateLabel:      "Spanish",
+	}
+
+	cases := []struct {
+		name string
+		req  *api.IssueCodeRequest
+		exp  *api.IssueCodeRequest
+	}{
+		{
+			name: "empty",
+			req:  &api.IssueCodeRequest{},
+			exp: &api.IssueCodeRequest{
+				TestType:         api.TestTypeConfirmed,
+				SymptomDate:      "2022-03-08",
+				SMSTemplateLabel: "Spanish",
+			},
+		},
+		{
+			name: "tz_offset",
+			req:  &api.IssueCodeRequest{TZOffset: -120},
+			exp: &api.IssueCodeRequest{
+				TestType:         api.TestTypeConfirmed,
+				SymptomDate:      "2022-03-07",
+				SMSTemplateLabel: "Spanish",
+				TZOffset:         -120,
+			},
+		},
+		{
+			name: "request_values_win",
+			req: &api.IssueCodeRequest{
+				TestType:         api.TestTypeLikely,
+				TestDate:         "2022-03-09",
+				SMSTemplateLabel: "French",
+			},
+			exp: &api.IssueCodeRequest{
+				TestType:         api.TestTypeLikely,
+				TestDate:         "2022-03-09",
+				SMSTemplateLabel: "French",
+			},
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			preset.ApplyTo(tc.req, now)
+			if diff := cmp.Diff(tc.exp, tc.req); diff != "" {
+				t.Errorf("mismatch (-want, +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestRealm_SaveIssuancePresets(t *testing.T) {
+	t.Parallel()
+
+	db, _ := testDatabaseInstance.NewDatabase(t, nil)
+
+	realm := NewRealmWithDefaults("test")
+	if err := db.SaveRealm(realm, SystemTest); err != nil {
+		t.Fatal(err)
+	}
+
+	presets := []*IssuancePreset{
+		{Name: "Lab confirmed", TestType: api.TestTypeConfirmed, SymptomDateOffsetDays: uintPtr(0)},
+		{Name: "Clinical", TestType: api.TestTypeLikely},
+	}
+	if err := realm.SaveIssuancePresets(db, presets, SystemTest); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := realm.ListIssuancePresets(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(got), 2; got != want {
+		t.Fatalf("expected %d presets to be %d", got, want)
+	}
+
+	preset, err := realm.FindIssuancePreset(db, "lab CONFIRMED")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := preset.TestType, api.TestTypeConfirmed; got != want {
+		t.Errorf("expected %q to be %q", got, want)
+	}
+
+	// Removing a preset deletes it.
+	if err := realm.SaveIssuancePresets(db, []*IssuancePreset{preset}, SystemTest); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := realm.FindIssuancePreset(db, "Clinical"); !IsNotFound(err) {
+		t.Errorf("expected not found, got %v", err)
+	}
+
+	// Duplicate names are rejected.
+	dupes := []*IssuancePreset{
+		{Name: "Negative", TestType: api.TestTypeNegative},
+		{Name: "negative", TestType: api.TestTypeNegative},
+	}
+	if err := realm.SaveIssuancePresets(db, dupes, SystemTest); !IsValidationError(err) {
+		t.Errorf("expected validation error, got %v", err)
+	}
+	if errs := dupes[1].ErrorsFor("name"); len(errs) == 0 {
+		t.Errorf("expected name errors")
+	}
+
+	// Invalid test types are rejected.
+	invalid := []*IssuancePreset{{Name: "Bad", TestType: api.TestTypeUserReport}}
+	if err := realm.SaveIssuancePresets(db, invalid, SystemTest); !IsValidationError(err) {
+		t.Errorf("expected validation error, got %v", err)
+	}
+
+	audits, _, err := db.ListAudits(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var found int
+	for _, a := range audits {
+		if a.Action == "updated issuance presets" {
+			found++
+		}
+	}
+	if got, want := found, 2; got != want {
+		t.Errorf("expected %d preset audits to be %d", got, want)
+	}
+}
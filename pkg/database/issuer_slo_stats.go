@@ -0,0 +1,196 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package database
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/google/exposure-notifications-server/pkg/logging"
+	"github.com/google/exposure-notifications-server/pkg/timeutils"
+	"github.com/google/exposure-notifications-verification-server/pkg/cache"
+)
+
+// Issuer types for IssuerSLOStat.
+const (
+	IssuerTypeUser   = "user"
+	IssuerTypeAPIKey = "api_key"
+)
+
+// IssuerSLOStatsDays is the number of days aggregated by IssuerSLOStats.
+const IssuerSLOStatsDays = 7
+
+// IssuanceOutcome is the outcome of a single issue request (which may issue
+// multiple codes) by a user or API key.
+type IssuanceOutcome struct {
+	RealmID         uint
+	UserID          uint
+	AuthorizedAppID uint
+
+	// Failed is the number of codes which failed to issue. Latency is the time
+	// to serve the request.
+	Failed  uint
+	Latency time.Duration
+}
+
+// RecordIssuanceOutcome records the failures and latency of an issue request
+// against the issuing user's and API key's daily stats. Successful codes are
+// counted by UpdateStats. Errors are logged, but otherwise ignored, so they do
+// not change the outcome of the request.
+func (db *Database) RecordIssuanceOutcome(ctx context.Context, o *IssuanceOutcome) {
+	logger := logging.FromContext(ctx).Named("database.RecordIssuanceOutcome")
+
+	date := timeutils.UTCMidnight(time.Now())
+	latencyMs := o.Latency.Milliseconds()
+
+	if o.UserID != 0 && o.RealmID != 0 {
+		sql := `
+			INSERT INTO user_stats (date, realm_id, user_id, codes_failed, issue_requests, issue_latency_ms)
+				VALUES ($1, $2, $3, $4, 1, $5)
+			ON CONFLICT (date, realm_id, user_id) DO UPDATE
+				SET codes_failed = user_stats.codes_failed + $4,
+					issue_requests = user_stats.issue_requests + 1,
+					issue_latency_ms = user_stats.issue_latency_ms + $5`
+
+		if err := db.db.Exec(sql, date, o.RealmID, o.UserID, o.Failed, latencyMs).Error; err != nil {
+			logger.Warnw("failed to update user issuance stats", "error", err)
+		}
+	}
+
+	if o.AuthorizedAppID != 0 {
+		sql := `
+			INSERT INTO authorized_app_stats (date, authorized_app_id, codes_failed, issue_requests, issue_latency_ms)
+				VALUES ($1, $2, $3, 1, $4)
+			ON CONFLICT (date, authorized_app_id) DO UPDATE
+				SET codes_failed = authorized_app_stats.codes_failed + $3,
+					issue_requests = authorized_app_stats.issue_requests + 1,
+					issue_latency_ms = authorized_app_stats.issue_latency_ms + $4`
+
+		if err := db.db.Exec(sql, date, o.AuthorizedAppID, o.Failed, latencyMs).Error; err != nil {
+			logger.Warnw("failed to update authorized app issuance stats", "error", err)
+		}
+	}
+}
+
+// IssuerSLOStat is the issuance SLO of a single user or API key, aggregated
+// over IssuerSLOStatsDays.
+type IssuerSLOStat struct {
+	IssuerType     string `json:"issuer_type"`
+	IssuerID       uint   `json:"issuer_id"`
+	Name           string `json:"name"`
+	CodesIssued    uint   `json:"codes_issued"`
+	CodesFailed    uint   `json:"codes_failed"`
+	IssueRequests  uint   `json:"issue_requests"`
+	IssueLatencyMs uint64 `json:"issue_latency_ms"`
+}
+
+// ErrorRate returns the fraction of codes which failed to issue, between 0 and
+// 1.
+func (s *IssuerSLOStat) ErrorRate() float64 {
+	total := s.CodesIssued + s.CodesFailed
+	if total == 0 {
+		return 0
+	}
+	return float64(s.CodesFailed) / float64(total)
+}
+
+// ErrorPercent returns the error rate as a percentage.
+func (s *IssuerSLOStat) ErrorPercent() string {
+	return strconv.FormatFloat(s.ErrorRate()*100, 'f', 1, 64) + "%"
+}
+
+// AverageLatency returns the mean latency of the issuer's requests.
+func (s *IssuerSLOStat) AverageLatency() time.Duration {
+	if s.IssueRequests == 0 {
+		return 0
+	}
+	return time.Duration(s.IssueLatencyMs/uint64(s.IssueRequests)) * time.Millisecond
+}
+
+// IssuerSLOStats returns the issuance SLO of each user and API key which issued
+// codes in the realm over the past IssuerSLOStatsDays, sorted with the highest
+// error rate first.
+func (r *Realm) IssuerSLOStats(db *Database) ([]*IssuerSLOStat, error) {
+	start := timeutils.UTCMidnight(time.Now()).Add((IssuerSLOStatsDays - 1) * -24 * time.Hour)
+
+	sql := `
+		SELECT
+			'user' AS issuer_type,
+			s.user_id AS issuer_id,
+			u.name AS name,
+			SUM(s.codes_issued) AS codes_issued,
+			SUM(s.codes_failed) AS codes_failed,
+			SUM(s.issue_requests) AS issue_requests,
+			SUM(s.issue_latency_ms) AS issue_latency_ms
+		FROM user_stats s
+		LEFT JOIN users u ON u.id = s.user_id
+		WHERE s.realm_id = $1 AND s.date >= $2
+		GROUP BY s.user_id, u.name
+		HAVING SUM(s.issue_requests) > 0
+
+		UNION ALL
+
+		SELECT
+			'api_key' AS issuer_type,
+			s.authorized_app_id AS issuer_id,
+			a.name AS name,
+			SUM(s.codes_issued) AS codes_issued,
+			SUM(s.codes_failed) AS codes_failed,
+			SUM(s.issue_requests) AS issue_requests,
+			SUM(s.issue_latency_ms) AS issue_latency_ms
+		FROM authorized_app_stats s
+		INNER JOIN authorized_apps a ON a.id = s.authorized_app_id
+		WHERE a.realm_id = $1 AND s.date >= $2
+		GROUP BY s.authorized_app_id, a.name
+		HAVING SUM(s.issue_requests) > 0`
+
+	var stats []*IssuerSLOStat
+	if err := db.db.Raw(sql, r.ID, start).Scan(&stats).Error; err != nil {
+		if IsNotFound(err) {
+			return stats, nil
+		}
+		return nil, fmt.Errorf("failed to get issuer slo stats: %w", err)
+	}
+
+	sort.SliceStable(stats, func(i, j int) bool {
+		if a, b := stats[i].ErrorRate(), stats[j].ErrorRate(); a != b {
+			return a > b
+		}
+		return stats[i].AverageLatency() > stats[j].AverageLatency()
+	})
+	return stats, nil
+}
+
+// IssuerSLOStatsCached is IssuerSLOStats, but cached.
+func (r *Realm) IssuerSLOStatsCached(ctx context.Context, db *Database, cacher cache.Cacher) ([]*IssuerSLOStat, error) {
+	if cacher == nil {
+		return nil, fmt.Errorf("cacher cannot be nil")
+	}
+
+	var stats []*IssuerSLOStat
+	cacheKey := &cache.Key{
+		Namespace: "stats:realm:issuer_slo",
+		Key:       strconv.FormatUint(uint64(r.ID), 10),
+	}
+	if err := cacher.Fetch(ctx, cacheKey, &stats, 30*time.Minute, func() (interface{}, error) {
+		return r.IssuerSLOStats(db)
+	}); err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
@@ -0,0 +1,157 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package database
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/exposure-notifications-verification-server/internal/project"
+	"github.com/jinzhu/gorm"
+)
+
+func TestIssuerSLOStat(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name       string
+		stat       *IssuerSLOStat
+		errorRate  float64
+		errPercent string
+		latency    time.Duration
+	}{
+		{
+			name:       "empty",
+			stat:       &IssuerSLOStat{},
+			errorRate:  0,
+			errPercent: "0.0%",
+			latency:    0,
+		},
+		{
+			name: "some_failures",
+			stat: &IssuerSLOStat{
+				CodesIssued:    3,
+				CodesFailed:    1,
+				IssueRequests:  4,
+				IssueLatencyMs: 2000,
+			},
+			errorRate:  0.25,
+			errPercent: "25.0%",
+			latency:    500 * time.Millisecond,
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			if got, want := tc.stat.ErrorRate(), tc.errorRate; got != want {
+				t.Errorf("expected error rate %f to be %f", got, want)
+			}
+			if got, want := tc.stat.ErrorPercent(), tc.errPercent; got != want {
+				t.Errorf("expected error percent %q to be %q", got, want)
+			}
+			if got, want := tc.stat.AverageLatency(), tc.latency; got != want {
+				t.Errorf("expected latency %s to be %s", got, want)
+			}
+		})
+	}
+}
+
+func TestRealm_IssuerSLOStats(t *testing.T) {
+	t.Parallel()
+
+	ctx := project.TestContext(t)
+	db, _ := testDatabaseInstance.NewDatabase(t, nil)
+
+	realm, err := db.FindRealm(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	user := &User{
+		Name:  "Rocky",
+		Email: "rocky@example.com",
+	}
+	if err := db.SaveUser(user, SystemTest); err != nil {
+		t.Fatal(err)
+	}
+
+	app := &AuthorizedApp{
+		Name: "Appy",
+	}
+	if _, err := realm.CreateAuthorizedApp(db, app, SystemTest); err != nil {
+		t.Fatal(err)
+	}
+
+	// No requests yet.
+	stats, err := realm.IssuerSLOStats(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(stats), 0; got != want {
+		t.Fatalf("expected %d stats to be %d", got, want)
+	}
+
+	// The user issued 2 codes in one request and had 2 failures in another.
+	db.UpdateStats(ctx,
+		&VerificationCode{RealmID: realm.ID, IssuingUserID: user.ID, Model: gorm.Model{CreatedAt: time.Now()}},
+		&VerificationCode{RealmID: realm.ID, IssuingUserID: user.ID, Model: gorm.Model{CreatedAt: time.Now()}})
+	db.RecordIssuanceOutcome(ctx, &IssuanceOutcome{RealmID: realm.ID, UserID: user.ID, Latency: 100 * time.Millisecond})
+	db.RecordIssuanceOutcome(ctx, &IssuanceOutcome{RealmID: realm.ID, UserID: user.ID, Failed: 2, Latency: 300 * time.Millisecond})
+
+	// The API key issued 1 code.
+	db.UpdateStats(ctx,
+		&VerificationCode{RealmID: realm.ID, IssuingAppID: app.ID, Model: gorm.Model{CreatedAt: time.Now()}})
+	db.RecordIssuanceOutcome(ctx, &IssuanceOutcome{RealmID: realm.ID, AuthorizedAppID: app.ID, Latency: 50 * time.Millisecond})
+
+	stats, err = realm.IssuerSLOStats(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(stats), 2; got != want {
+		t.Fatalf("expected %d stats to be %d", got, want)
+	}
+
+	// Highest error rate first.
+	userStat, appStat := stats[0], stats[1]
+	if got, want := userStat.IssuerType, IssuerTypeUser; got != want {
+		t.Errorf("expected issuer type %q to be %q", got, want)
+	}
+	if got, want := userStat.Name, "Rocky"; got != want {
+		t.Errorf("expected name %q to be %q", got, want)
+	}
+	if got, want := userStat.CodesIssued, uint(2); got != want {
+		t.Errorf("expected codes issued %d to be %d", got, want)
+	}
+	if got, want := userStat.CodesFailed, uint(2); got != want {
+		t.Errorf("expected codes failed %d to be %d", got, want)
+	}
+	if got, want := userStat.AverageLatency(), 200*time.Millisecond; got != want {
+		t.Errorf("expected latency %s to be %s", got, want)
+	}
+
+	if got, want := appStat.IssuerType, IssuerTypeAPIKey; got != want {
+		t.Errorf("expected issuer type %q to be %q", got, want)
+	}
+	if got, want := appStat.IssuerID, app.ID; got != want {
+		t.Errorf("expected issuer id %d to be %d", got, want)
+	}
+	if got, want := appStat.ErrorRate(), 0.0; got != want {
+		t.Errorf("expected error rate %f to be %f", got, want)
+	}
+}
@@ -0,0 +1,121 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package database
+
+import (
+	"fmt"
+	"time"
+)
+
+// JobStatus records the outcome of the most recent runs of a scheduled job,
+// such as cleanup or the stats puller. Jobs run on multiple instances, so the
+// status is stored in the database rather than in memory.
+type JobStatus struct {
+	// Name is the unique name of the job.
+	Name string `gorm:"column:name; type:text; primary_key;"`
+
+	// LastRunAt is when the job last finished, successfully or not.
+	LastRunAt *time.Time `gorm:"column:last_run_at; type:timestamp with time zone;"`
+
+	// LastSuccessAt is when the job last finished successfully.
+	LastSuccessAt *time.Time `gorm:"column:last_success_at; type:timestamp with time zone;"`
+
+	// LastProcessed is the number of items processed by the last successful run.
+	// The meaning of an item is job-specific.
+	LastProcessed int64 `gorm:"column:last_processed; type:bigint; not null; default:0;"`
+
+	// LastFailureAt and LastError are the time and error of the last failed
+	// run.
+	LastFailureAt *time.Time `gorm:"column:last_failure_at; type:timestamp with time zone;"`
+	LastError     string     `gorm:"column:last_error; type:text;"`
+}
+
+// TableName sets the table name.
+func (JobStatus) TableName() string {
+	return "job_statuses"
+}
+
+// SinceLastSuccess returns the time elapsed since the last successful run, or
+// 0 if the job has never succeeded.
+func (s *JobStatus) SinceLastSuccess(now time.Time) time.Duration {
+	if s == nil || s.LastSuccessAt == nil {
+		return 0
+	}
+	return now.Sub(*s.LastSuccessAt)
+}
+
+// Failing returns true if the job's most recent run failed.
+func (s *JobStatus) Failing() bool {
+	if s == nil || s.LastFailureAt == nil {
+		return false
+	}
+	return s.LastSuccessAt == nil || s.LastFailureAt.After(*s.LastSuccessAt)
+}
+
+// RecordJobSuccess records a successful run of the named job which processed
+// the given number of items.
+func (db *Database) RecordJobSuccess(name string, processed int64) error {
+	sql := `
+		INSERT INTO job_statuses (name, last_run_at, last_success_at, last_processed)
+			VALUES ($1, $2, $2, $3)
+		ON CONFLICT (name) DO UPDATE
+			SET last_run_at = EXCLUDED.last_run_at,
+				last_success_at = EXCLUDED.last_success_at,
+				last_processed = EXCLUDED.last_processed
+	`
+
+	now := time.Now().UTC()
+	if err := db.db.Exec(sql, name, now, processed).Error; err != nil {
+		return fmt.Errorf("failed to record %s job success: %w", name, err)
+	}
+	return nil
+}
+
+// RecordJobFailure records a failed run of the named job.
+func (db *Database) RecordJobFailure(name string, jobErr error) error {
+	sql := `
+		INSERT INTO job_statuses (name, last_run_at, last_failure_at, last_error)
+			VALUES ($1, $2, $2, $3)
+		ON CONFLICT (name) DO UPDATE
+			SET last_run_at = EXCLUDED.last_run_at,
+				last_failure_at = EXCLUDED.last_failure_at,
+				last_error = EXCLUDED.last_error
+	`
+
+	msg := ""
+	if jobErr != nil {
+		msg = jobErr.Error()
+	}
+
+	now := time.Now().UTC()
+	if err := db.db.Exec(sql, name, now, msg).Error; err != nil {
+		return fmt.Errorf("failed to record %s job failure: %w", name, err)
+	}
+	return nil
+}
+
+// FindJobStatus returns the status of the named job. It returns NotFound if
+// the job has never run.
+func (db *Database) FindJobStatus(name string) (*JobStatus, error) {
+	var status JobStatus
+	if err := db.db.
+		Model(&JobStatus{}).
+		Where("name = ?", name).
+		First(&status).
+		Error; err != nil {
+		return nil, err
+	}
+	return &status, nil
+}
@@ -0,0 +1,76 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package database
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestDatabase_JobStatus(t *testing.T) {
+	t.Parallel()
+
+	db, _ := testDatabaseInstance.NewDatabase(t, nil)
+
+	if _, err := db.FindJobStatus("cleanup"); !IsNotFound(err) {
+		t.Fatalf("expected not found, got %v", err)
+	}
+
+	if err := db.RecordJobSuccess("cleanup", 12); err != nil {
+		t.Fatal(err)
+	}
+
+	status, err := db.FindJobStatus("cleanup")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.LastSuccessAt == nil {
+		t.Fatalf("expected last success to be set")
+	}
+	if got, want := status.LastProcessed, int64(12); got != want {
+		t.Errorf("expected processed %d to be %d", got, want)
+	}
+	lastSuccess := *status.LastSuccessAt
+
+	// Failures do not change the last success.
+	if err := db.RecordJobFailure("cleanup", fmt.Errorf("oops")); err != nil {
+		t.Fatal(err)
+	}
+
+	status, err = db.FindJobStatus("cleanup")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.LastFailureAt == nil {
+		t.Fatalf("expected last failure to be set")
+	}
+	if got, want := status.LastError, "oops"; got != want {
+		t.Errorf("expected error %q to be %q", got, want)
+	}
+	if got, want := *status.LastSuccessAt, lastSuccess; !got.Equal(want) {
+		t.Errorf("expected last success %s to be %s", got, want)
+	}
+	if got, want := status.LastProcessed, int64(12); got != want {
+		t.Errorf("expected processed %d to be %d", got, want)
+	}
+	if !status.Failing() {
+		t.Errorf("expected job to be failing")
+	}
+
+	if got := status.SinceLastSuccess(time.Now()); got <= 0 {
+		t.Errorf("expected positive time since last success, got %s", got)
+	}
+}
@@ -0,0 +1,258 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package database
+
+import (
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/google/exposure-notifications-verification-server/internal/project"
+	"github.com/jinzhu/gorm"
+)
+
+var _ Auditable = (*KeyServer)(nil)
+
+// KeyServer is an upstream key server to which realms upload keys. Some
+// jurisdictions run separate key servers (e.g. pilot and production); a realm
+// may be associated with one of these instead of the system default.
+type KeyServer struct {
+	Errorable
+
+	// ID is the key server's ID.
+	ID uint `gorm:"primary_key;"`
+
+	// Name is the display name of the key server.
+	Name string `gorm:"column:name; type:text; not null;"`
+
+	// URL is the base URL of the key server, used for statistics pulls.
+	URL string `gorm:"column:url; type:text; not null;"`
+
+	// StatsAudience is the JWT audience used when authenticating statistics
+	// pulls to this key server.
+	StatsAudience string `gorm:"column:stats_audience; type:text; not null;"`
+
+	// CertificateAudience is the audience for verification certificates for
+	// realms that use the system certificate signing key. If empty, the system
+	// certificate audience is used. Realms with their own certificate keys
+	// configure their audience directly.
+	CertificateAudience string `gorm:"column:certificate_audience; type:text;"`
+
+	// SigningKey is the key manager reference of the key used to sign
+	// certificates and statistics pulls for realms that use the system
+	// certificate signing key. SigningKeyID is the "kid" the key server knows
+	// the key by. If empty, the system certificate signing key is used.
+	SigningKey   string `gorm:"column:signing_key; type:text;"`
+	SigningKeyID string `gorm:"column:signing_key_id; type:text;"`
+
+	// Issuer is the issuer for tokens signed with SigningKey. If empty, the
+	// system certificate issuer is used.
+	Issuer string `gorm:"column:issuer; type:text;"`
+
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// TableName sets the table name.
+func (KeyServer) TableName() string {
+	return "key_servers"
+}
+
+// BeforeSave runs validations. If there are errors, the save fails.
+func (k *KeyServer) BeforeSave(tx *gorm.DB) error {
+	k.Name = project.TrimSpace(k.Name)
+	if k.Name == "" {
+		k.AddError("name", "cannot be blank")
+	}
+
+	k.URL = project.TrimSpace(k.URL)
+	if k.URL == "" {
+		k.AddError("url", "cannot be blank")
+	} else if u, err := url.Parse(k.URL); err != nil || u.Host == "" ||
+		(u.Scheme != "http" && u.Scheme != "https") {
+		k.AddError("url", "must be a valid http or https URL")
+	}
+
+	k.StatsAudience = project.TrimSpace(k.StatsAudience)
+	if k.StatsAudience == "" {
+		k.AddError("stats_audience", "cannot be blank")
+	}
+
+	k.CertificateAudience = project.TrimSpace(k.CertificateAudience)
+
+	// The signing key and its ID are all or nothing.
+	k.SigningKey = project.TrimSpace(k.SigningKey)
+	k.SigningKeyID = project.TrimSpace(k.SigningKeyID)
+	if (k.SigningKey == "") != (k.SigningKeyID == "") {
+		k.AddError("signing_key", "signing key and key ID must both be specified or both be blank")
+		k.AddError("signing_key_id", "signing key and key ID must both be specified or both be blank")
+	}
+
+	k.Issuer = project.TrimSpace(k.Issuer)
+	if k.Issuer != "" && k.SigningKey == "" {
+		k.AddError("issuer", "requires a signing key")
+	}
+
+	return k.ErrorOrNil()
+}
+
+// AuditID is how the key server is stored in the audit entry.
+func (k *KeyServer) AuditID() string {
+	return fmt.Sprintf("key_servers:%d", k.ID)
+}
+
+// AuditDisplay is how the key server will be displayed in audit entries.
+func (k *KeyServer) AuditDisplay() string {
+	return k.Name
+}
+
+// ListKeyServers lists all key servers, ordered by name.
+func (db *Database) ListKeyServers() ([]*KeyServer, error) {
+	var keyServers []*KeyServer
+	if err := db.db.
+		Model(&KeyServer{}).
+		Order("name ASC").
+		Find(&keyServers).
+		Error; err != nil {
+		if IsNotFound(err) {
+			return keyServers, nil
+		}
+		return nil, err
+	}
+	return keyServers, nil
+}
+
+// FindKeyServer finds the key server by the given id.
+func (db *Database) FindKeyServer(id interface{}) (*KeyServer, error) {
+	var keyServer KeyServer
+	if err := db.db.
+		Model(&KeyServer{}).
+		Where("id = ?", id).
+		First(&keyServer).
+		Error; err != nil {
+		return nil, err
+	}
+	return &keyServer, nil
+}
+
+// FindKeyServerForRealm returns the key server associated with the realm. It
+// returns nil if the realm uses the system default key server.
+func (db *Database) FindKeyServerForRealm(realmID uint) (*KeyServer, error) {
+	var keyServer KeyServer
+	if err := db.db.
+		Model(&KeyServer{}).
+		Joins("JOIN realms ON realms.key_server_id = key_servers.id").
+		Where("realms.id = ?", realmID).
+		First(&keyServer).
+		Error; err != nil {
+		if IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &keyServer, nil
+}
+
+// SaveKeyServer creates or updates the key server.
+func (db *Database) SaveKeyServer(k *KeyServer, actor Auditable) error {
+	if k == nil {
+		return fmt.Errorf("provided key server is nil")
+	}
+
+	if actor == nil {
+		return ErrMissingActor
+	}
+
+	return db.db.Transaction(func(tx *gorm.DB) error {
+		var audits []*AuditEntry
+
+		var existing KeyServer
+		if err := tx.
+			Model(&KeyServer{}).
+			Where("id = ?", k.ID).
+			First(&existing).
+			Error; err != nil && !IsNotFound(err) {
+			return fmt.Errorf("failed to get existing key server: %w", err)
+		}
+
+		if err := tx.Save(k).Error; err != nil {
+			if IsUniqueViolation(err, "uix_key_servers_name") {
+				k.AddError("name", "must be unique")
+				return ErrValidationFailed
+			}
+			return err
+		}
+
+		if existing.ID == 0 {
+			audit := BuildAuditEntry(actor, "created key server", k, 0)
+			audits = append(audits, audit)
+		} else {
+			if existing.Name != k.Name {
+				audit := BuildAuditEntry(actor, "updated key server name", k, 0)
+				audit.Diff = stringDiff(existing.Name, k.Name)
+				audits = append(audits, audit)
+			}
+
+			if existing.URL != k.URL {
+				audit := BuildAuditEntry(actor, "updated key server url", k, 0)
+				audit.Diff = stringDiff(existing.URL, k.URL)
+				audits = append(audits, audit)
+			}
+
+			if existing.StatsAudience != k.StatsAudience {
+				audit := BuildAuditEntry(actor, "updated key server stats audience", k, 0)
+				audit.Diff = stringDiff(existing.StatsAudience, k.StatsAudience)
+				audits = append(audits, audit)
+			}
+
+			if existing.CertificateAudience != k.CertificateAudience {
+				audit := BuildAuditEntry(actor, "updated key server certificate audience", k, 0)
+				audit.Diff = stringDiff(existing.CertificateAudience, k.CertificateAudience)
+				audits = append(audits, audit)
+			}
+
+			if existing.SigningKey != k.SigningKey {
+				audit := BuildAuditEntry(actor, "updated key server signing key", k, 0)
+				audit.Diff = stringDiff(existing.SigningKey, k.SigningKey)
+				audits = append(audits, audit)
+			}
+
+			if existing.SigningKeyID != k.SigningKeyID {
+				audit := BuildAuditEntry(actor, "updated key server signing key id", k, 0)
+				audit.Diff = stringDiff(existing.SigningKeyID, k.SigningKeyID)
+				audits = append(audits, audit)
+			}
+
+			if existing.Issuer != k.Issuer {
+				audit := BuildAuditEntry(actor, "updated key server issuer", k, 0)
+				audit.Diff = stringDiff(existing.Issuer, k.Issuer)
+				audits = append(audits, audit)
+			}
+		}
+
+		for _, audit := range audits {
+			if err := tx.Save(audit).Error; err != nil {
+				return fmt.Errorf("failed to save audits: %w", err)
+			}
+		}
+
+		if len(audits) > 0 {
+			if err := recordSystemEvent(tx, configChangeEvent(k.Name, actor, audits)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
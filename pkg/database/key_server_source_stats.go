@@ -0,0 +1,249 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package database
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/google/exposure-notifications-server/pkg/timeutils"
+	"github.com/google/exposure-notifications-verification-server/internal/icsv"
+	"github.com/google/exposure-notifications-verification-server/internal/project"
+	"github.com/google/exposure-notifications-verification-server/pkg/cache"
+)
+
+var _ icsv.Marshaler = (KeyServerSourceStats)(nil)
+
+// KeyServerSourceStats is a collection of key server publish requests, broken
+// out by key server source.
+type KeyServerSourceStats []*KeyServerSourceStat
+
+// KeyServerSourceStat represents the number of publish requests a realm had on
+// a single key server source on a single day.
+type KeyServerSourceStat struct {
+	Date            time.Time `gorm:"column:date; type:date;"`
+	RealmID         uint      `gorm:"column:realm_id; type:int;"`
+	Source          string    `gorm:"column:source; type:text;"`
+	PublishRequests int64     `gorm:"column:publish_requests; type:bigint;"`
+}
+
+// MarshalCSV returns bytes in CSV format.
+func (s KeyServerSourceStats) MarshalCSV() ([]byte, error) {
+	// Do nothing if there's no records
+	if len(s) == 0 {
+		return nil, nil
+	}
+
+	var b bytes.Buffer
+	w := csv.NewWriter(&b)
+
+	if err := w.Write([]string{"date", "realm_id", "source", "publish_requests"}); err != nil {
+		return nil, fmt.Errorf("failed to write CSV header: %w", err)
+	}
+
+	for i, stat := range s {
+		if err := w.Write([]string{
+			stat.Date.Format(project.RFC3339Date),
+			strconv.FormatUint(uint64(stat.RealmID), 10),
+			stat.Source,
+			strconv.FormatInt(stat.PublishRequests, 10),
+		}); err != nil {
+			return nil, fmt.Errorf("failed to write CSV entry %d: %w", i, err)
+		}
+	}
+
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return nil, fmt.Errorf("failed to create CSV: %w", err)
+	}
+
+	return b.Bytes(), nil
+}
+
+type jsonKeyServerSourceStat struct {
+	RealmID uint                            `json:"realm_id"`
+	Stats   []*jsonKeyServerSourceStatstats `json:"statistics"`
+}
+
+type jsonKeyServerSourceStatstats struct {
+	Date       time.Time                            `json:"date"`
+	SourceData []*jsonKeyServerSourceStatSourceData `json:"source_data"`
+}
+
+type jsonKeyServerSourceStatSourceData struct {
+	Source          string `json:"source"`
+	PublishRequests int64  `json:"publish_requests"`
+}
+
+// MarshalJSON is a custom JSON marshaller.
+func (s KeyServerSourceStats) MarshalJSON() ([]byte, error) {
+	// Do nothing if there's no records
+	if len(s) == 0 {
+		return json.Marshal(struct{}{})
+	}
+
+	m := make(map[time.Time][]*jsonKeyServerSourceStatSourceData)
+	for _, stat := range s {
+		if m[stat.Date] == nil {
+			m[stat.Date] = make([]*jsonKeyServerSourceStatSourceData, 0, 4)
+		}
+
+		m[stat.Date] = append(m[stat.Date], &jsonKeyServerSourceStatSourceData{
+			Source:          stat.Source,
+			PublishRequests: stat.PublishRequests,
+		})
+	}
+
+	stats := make([]*jsonKeyServerSourceStatstats, 0, len(m))
+	for k, v := range m {
+		stats = append(stats, &jsonKeyServerSourceStatstats{
+			Date:       k,
+			SourceData: v,
+		})
+	}
+
+	// Sort in descending order.
+	sort.Slice(stats, func(i, j int) bool {
+		return stats[i].Date.After(stats[j].Date)
+	})
+
+	var result jsonKeyServerSourceStat
+	result.RealmID = s[0].RealmID
+	result.Stats = stats
+
+	b, err := json.Marshal(result)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal json: %w", err)
+	}
+	return b, nil
+}
+
+func (s *KeyServerSourceStats) UnmarshalJSON(b []byte) error {
+	if len(b) == 0 {
+		return nil
+	}
+
+	var result jsonKeyServerSourceStat
+	if err := json.Unmarshal(b, &result); err != nil {
+		return err
+	}
+
+	for _, stat := range result.Stats {
+		for _, r := range stat.SourceData {
+			*s = append(*s, &KeyServerSourceStat{
+				Date:            stat.Date,
+				RealmID:         result.RealmID,
+				Source:          r.Source,
+				PublishRequests: r.PublishRequests,
+			})
+		}
+	}
+
+	return nil
+}
+
+// ListKeyServerStatsSources returns the distinct key server sources that have
+// statistics for the realm over the stats display period.
+func (db *Database) ListKeyServerStatsSources(realmID uint) ([]string, error) {
+	stop := timeutils.UTCMidnight(time.Now())
+	start := stop.Add(project.StatsDisplayDays * -24 * time.Hour)
+
+	var sources []string
+	if err := db.db.
+		Model(&KeyServerStatsDay{}).
+		Where("realm_id = ?", realmID).
+		Where("day >= ? AND day <= ?", start, stop).
+		Order("source").
+		Pluck("DISTINCT(source)", &sources).
+		Error; err != nil {
+		if IsNotFound(err) {
+			return sources, nil
+		}
+		return nil, err
+	}
+	return sources, nil
+}
+
+// ListKeyServerSourceStats returns the publish requests by key server source
+// for the realm over the stats display period.
+func (db *Database) ListKeyServerSourceStats(realmID uint) (KeyServerSourceStats, error) {
+	stop := timeutils.UTCMidnight(time.Now())
+	start := stop.Add(project.StatsDisplayDays * -24 * time.Hour)
+	return db.ListKeyServerSourceStatsBetween(realmID, start, stop)
+}
+
+// ListKeyServerSourceStatsBetween returns the publish requests by key server
+// source for the realm for each day from start to stop, inclusive.
+func (db *Database) ListKeyServerSourceStatsBetween(realmID uint, start, stop time.Time) (KeyServerSourceStats, error) {
+	if start.After(stop) {
+		return nil, ErrBadDateRange
+	}
+
+	// Ensure we have a full list (with values of 0 where appropriate) to ensure
+	// continuity in graphs.
+	sql := `
+		SELECT
+			d.date AS date,
+			$1 AS realm_id,
+			d.source AS source,
+			COALESCE((SELECT SUM(v) FROM unnest(s.publish_requests) v), 0) AS publish_requests
+		FROM (
+			SELECT
+				d.date::date AS date,
+				i.source AS source
+			FROM generate_series($2, $3, '1 day'::interval) d
+			CROSS JOIN (
+				SELECT DISTINCT(source)
+				FROM key_server_stats_days
+				WHERE realm_id = $1 AND day >= $2 AND day <= $3
+			) AS i
+		) d
+		LEFT JOIN key_server_stats_days s ON s.realm_id = $1 AND s.source = d.source AND s.day = d.date
+		ORDER BY date DESC, source`
+
+	var stats []*KeyServerSourceStat
+	if err := db.db.Raw(sql, realmID, start, stop).Scan(&stats).Error; err != nil {
+		if IsNotFound(err) {
+			return stats, nil
+		}
+		return nil, err
+	}
+	return stats, nil
+}
+
+// ListKeyServerSourceStatsCached is ListKeyServerSourceStats, but cached.
+func (db *Database) ListKeyServerSourceStatsCached(ctx context.Context, realmID uint, cacher cache.Cacher) (KeyServerSourceStats, error) {
+	if cacher == nil {
+		return nil, fmt.Errorf("cacher cannot be nil")
+	}
+
+	var stats KeyServerSourceStats
+	cacheKey := &cache.Key{
+		Namespace: "stats:realm:key_server_sources",
+		Key:       strconv.FormatUint(uint64(realmID), 10),
+	}
+	if err := cacher.Fetch(ctx, cacheKey, &stats, 30*time.Minute, func() (interface{}, error) {
+		return db.ListKeyServerSourceStats(realmID)
+	}); err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
@@ -0,0 +1,70 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package database
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestKeyServerSourceStats_MarshalCSV(t *testing.T) {
+	t.Parallel()
+
+	date := time.Date(2022, 4, 1, 0, 0, 0, 0, time.UTC)
+
+	stats := KeyServerSourceStats{
+		{Date: date, RealmID: 1, Source: "primary", PublishRequests: 6},
+		{Date: date, RealmID: 1, Source: "regional", PublishRequests: 15},
+	}
+
+	b, err := stats.MarshalCSV()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := "date,realm_id,source,publish_requests\n" +
+		"2022-04-01,1,primary,6\n" +
+		"2022-04-01,1,regional,15\n"
+	if diff := cmp.Diff(want, string(b)); diff != "" {
+		t.Errorf("mismatch (-want, +got):\n%s", diff)
+	}
+}
+
+func TestKeyServerSourceStats_JSON(t *testing.T) {
+	t.Parallel()
+
+	date := time.Date(2022, 4, 1, 0, 0, 0, 0, time.UTC)
+
+	stats := KeyServerSourceStats{
+		{Date: date, RealmID: 1, Source: "primary", PublishRequests: 6},
+		{Date: date, RealmID: 1, Source: "regional", PublishRequests: 15},
+	}
+
+	b, err := json.Marshal(stats)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got KeyServerSourceStats
+	if err := json.Unmarshal(b, &got); err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(stats, got); diff != "" {
+		t.Errorf("mismatch (-want, +got):\n%s", diff)
+	}
+}
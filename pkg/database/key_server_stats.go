@@ -16,6 +16,7 @@ package database
 
 import (
 	"context"
+	"sort"
 	"strconv"
 	"time"
 
@@ -28,6 +29,11 @@ import (
 	"github.com/lib/pq"
 )
 
+// KeyServerStatsSourcePrimary is the source label for statistics pulled from a
+// realm's primary key server. Additional key servers configured for the
+// deployment use their own labels.
+const KeyServerStatsSourcePrimary = "primary"
+
 // KeyServerStats represents statistics for a key-server for this realm
 type KeyServerStats struct {
 	Errorable
@@ -48,6 +54,10 @@ type KeyServerStatsDay struct {
 	// RealmId that these stats belong to.
 	RealmID uint `gorm:"column:realm_id; primary_key; type:integer; not null;"`
 
+	// Source is the label of the key server these stats were pulled from. It
+	// defaults to KeyServerStatsSourcePrimary.
+	Source string `gorm:"column:source; primary_key; type:text; not null;"`
+
 	// Day will be set to midnight UTC of the day represented. An individual day
 	// isn't released until there is a minimum threshold for updates has been met.
 	Day time.Time `gorm:"column:day; primary_key;"`
@@ -91,6 +101,10 @@ func (kssd *KeyServerStatsDay) BeforeSave(tx *gorm.DB) error {
 		kssd.AddError("realm_id", "statistics may not be saved on the system realm")
 	}
 
+	if kssd.Source == "" {
+		kssd.Source = KeyServerStatsSourcePrimary
+	}
+
 	return kssd.ErrorOrNil()
 }
 
@@ -174,14 +188,8 @@ func (db *Database) SaveKeyServerStatsDay(day *KeyServerStatsDay) error {
 
 // DeleteOldKeyServerStatsDays deletes rows from KeyServerStatsDays that are older than maxAge (default 90d)
 func (db *Database) DeleteOldKeyServerStatsDays(maxAge time.Duration) (int64, error) {
-	if maxAge > 0 {
-		maxAge = -1 * maxAge
-	}
-	a := time.Now().UTC().Add(maxAge)
-	rtn := db.db.Unscoped().
-		Where("day < ?", a).
-		Delete(&KeyServerStatsDay{})
-	return rtn.RowsAffected, rtn.Error
+	return db.purgeWithRealmRetention(&KeyServerStatsDay{}, "key_server_stats_days.day", "key_server_stats_days.realm_id",
+		"stats_retention_days", maxAge)
 }
 
 // ListKeyServerStatsDaysCached retrieves the last 90 days of key-server statistics
@@ -203,36 +211,81 @@ func (db *Database) ListKeyServerStatsDaysCached(ctx context.Context, realmID ui
 func (db *Database) ListKeyServerStatsDays(realmID uint) ([]*KeyServerStatsDay, error) {
 	stop := timeutils.UTCMidnight(time.Now())
 	start := stop.Add(project.StatsDisplayDays * -24 * time.Hour)
+	return db.ListKeyServerStatsDaysBetween(realmID, start, stop)
+}
+
+// ListKeyServerStatsDaysBetween retrieves the key-server statistics for each
+// day from start to stop, inclusive, newest first. Statistics from all key
+// server sources are summed.
+func (db *Database) ListKeyServerStatsDaysBetween(realmID uint, start, stop time.Time) ([]*KeyServerStatsDay, error) {
 	if start.After(stop) {
 		return nil, ErrBadDateRange
 	}
 
-	sql := `
-		SELECT
-			d.day AS day,
-			$1 AS realm_id,
-			COALESCE(s.publish_requests, array[0,0,0]::bigint[]) AS publish_requests,
-			COALESCE(s.total_teks_published, 0) AS total_teks_published,
-			COALESCE(s.revision_requests, 0) AS revision_requests,
-			COALESCE(s.tek_age_distribution, array[0,0,0,0,0,0,0,0,0,0,0,0,0,0,0,0]::bigint[]) AS tek_age_distribution,
-			COALESCE(s.onset_to_upload_distribution, array[0,0,0,0,0,0,0,0,0,0,0,0,0,0,0,0,0,0,0,0,0,0,0,0,0,0,0,0,0,0]::bigint[]) AS onset_to_upload_distribution,
-			COALESCE(s.request_missing_onset_date, 0) AS request_missing_onset_date
-		FROM (
-			SELECT day::date FROM generate_series($2, $3, '1 day'::interval) day
-		) d
-		LEFT JOIN key_server_stats_days s ON s.realm_id = $1 AND s.day = d.day
-		ORDER BY day DESC`
+	var rows []*KeyServerStatsDay
+	if err := db.db.
+		Model(&KeyServerStatsDay{}).
+		Where("realm_id = ?", realmID).
+		Where("day >= ? AND day <= ?", timeutils.UTCMidnight(start), stop).
+		Find(&rows).
+		Error; err != nil && !IsNotFound(err) {
+		return nil, err
+	}
 
-	var stats []*KeyServerStatsDay
-	if err := db.db.Raw(sql, realmID, start, stop).Scan(&stats).Error; err != nil {
-		if IsNotFound(err) {
-			return stats, nil
+	// Ensure we have a full list (with values of 0 where appropriate) to ensure
+	// continuity in graphs.
+	byDay := make(map[time.Time]*KeyServerStatsDay)
+	stats := make([]*KeyServerStatsDay, 0, int(stop.Sub(start)/(24*time.Hour))+1)
+	for t := start; !t.After(stop); t = t.Add(24 * time.Hour) {
+		day := timeutils.UTCMidnight(t)
+		stat := &KeyServerStatsDay{
+			RealmID:                   realmID,
+			Day:                       day,
+			PublishRequests:           make([]int64, 3),
+			TEKAgeDistribution:        make([]int64, 16),
+			OnsetToUploadDistribution: make([]int64, 30),
+		}
+		byDay[day] = stat
+		stats = append(stats, stat)
+	}
+
+	for _, row := range rows {
+		if stat, ok := byDay[timeutils.UTCMidnight(row.Day)]; ok {
+			stat.add(row)
 		}
-		return nil, err
 	}
+
+	// Sort in descending order.
+	sort.Slice(stats, func(i, j int) bool {
+		return stats[i].Day.After(stats[j].Day)
+	})
 	return stats, nil
 }
 
+// add sums the statistics from other into kssd.
+func (kssd *KeyServerStatsDay) add(other *KeyServerStatsDay) {
+	kssd.PublishRequests = addInt64Arrays(kssd.PublishRequests, other.PublishRequests)
+	kssd.TotalTEKsPublished += other.TotalTEKsPublished
+	kssd.RevisionRequests += other.RevisionRequests
+	kssd.TEKAgeDistribution = addInt64Arrays(kssd.TEKAgeDistribution, other.TEKAgeDistribution)
+	kssd.OnsetToUploadDistribution = addInt64Arrays(kssd.OnsetToUploadDistribution, other.OnsetToUploadDistribution)
+	kssd.RequestsMissingOnsetDate += other.RequestsMissingOnsetDate
+}
+
+// addInt64Arrays returns the element-wise sum of a and b. The result is as long
+// as the longer of the two.
+func addInt64Arrays(a, b pq.Int64Array) pq.Int64Array {
+	if len(b) > len(a) {
+		a, b = b, a
+	}
+	result := make(pq.Int64Array, len(a))
+	copy(result, a)
+	for i, v := range b {
+		result[i] += v
+	}
+	return result
+}
+
 // MakeKeyServerStatsDay creates a storage struct from a key-server StatsDay response
 func MakeKeyServerStatsDay(realmID uint, d *keyserver.StatsDay) *KeyServerStatsDay {
 	pr := make([]int64, 3)
@@ -149,3 +149,90 @@ func TestConvertStatsDay(t *testing.T) {
 		t.Errorf("round trip failed. got %#v want %#v", roundTripped, day)
 	}
 }
+
+func TestListKeyServerStatsDaysBetween_sources(t *testing.T) {
+	t.Parallel()
+
+	db, _ := testDatabaseInstance.NewDatabase(t, nil)
+	realm, err := db.FindRealm(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := timeutils.UTCMidnight(time.Now())
+
+	for _, day := range []*KeyServerStatsDay{
+		{
+			RealmID:            realm.ID,
+			Day:                now,
+			PublishRequests:    []int64{1, 2, 3},
+			TotalTEKsPublished: 10,
+			TEKAgeDistribution: []int64{1, 2},
+		},
+		{
+			RealmID:            realm.ID,
+			Source:             "regional",
+			Day:                now,
+			PublishRequests:    []int64{4, 5, 6},
+			TotalTEKsPublished: 20,
+			TEKAgeDistribution: []int64{3, 4, 5},
+		},
+	} {
+		if err := db.SaveKeyServerStatsDay(day); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	stats, err := db.ListKeyServerStatsDaysBetween(realm.ID, now.Add(-24*time.Hour), now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(stats), 2; got != want {
+		t.Fatalf("expected %d days, got %d", want, got)
+	}
+
+	got := stats[0]
+	if got, want := got.Day, now; !got.Equal(want) {
+		t.Errorf("expected newest day %s to be %s", got, want)
+	}
+	if got, want := []int64(got.PublishRequests), []int64{5, 7, 9}; !reflect.DeepEqual(got, want) {
+		t.Errorf("expected publish requests %v to be %v", got, want)
+	}
+	if got, want := got.TotalTEKsPublished, int64(30); got != want {
+		t.Errorf("expected total TEKs %d to be %d", got, want)
+	}
+	if got, want := []int64(got.TEKAgeDistribution[:3]), []int64{4, 6, 5}; !reflect.DeepEqual(got, want) {
+		t.Errorf("expected TEK age distribution %v to be %v", got, want)
+	}
+	if got, want := stats[1].TotalPublishRequests(), int64(0); got != want {
+		t.Errorf("expected empty day to have %d publish requests, got %d", want, got)
+	}
+
+	sources, err := db.ListKeyServerStatsSources(realm.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := sources, []string{"primary", "regional"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("expected sources %v to be %v", got, want)
+	}
+
+	sourceStats, err := db.ListKeyServerSourceStatsBetween(realm.ID, now, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := KeyServerSourceStats{
+		{Date: now, RealmID: realm.ID, Source: "primary", PublishRequests: 6},
+		{Date: now, RealmID: realm.ID, Source: "regional", PublishRequests: 15},
+	}
+	if got := len(sourceStats); got != len(want) {
+		t.Fatalf("expected %d source stats, got %d", len(want), got)
+	}
+	for i := range want {
+		if got, want := sourceStats[i].Source, want[i].Source; got != want {
+			t.Errorf("expected source %q to be %q", got, want)
+		}
+		if got, want := sourceStats[i].PublishRequests, want[i].PublishRequests; got != want {
+			t.Errorf("expected publish requests %d to be %d", got, want)
+		}
+	}
+}
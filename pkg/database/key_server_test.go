@@ -0,0 +1,166 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package database
+
+import (
+	"testing"
+)
+
+func TestKeyServer_BeforeSave(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name      string
+		keyServer *KeyServer
+		errs      []string
+	}{
+		{
+			name:      "empty",
+			keyServer: &KeyServer{},
+			errs:      []string{"name", "url", "stats_audience"},
+		},
+		{
+			name: "bad_url",
+			keyServer: &KeyServer{
+				Name:          "Pilot",
+				URL:           "ftp://keys.example.com",
+				StatsAudience: "pilot",
+			},
+			errs: []string{"url"},
+		},
+		{
+			name: "signing_key_without_id",
+			keyServer: &KeyServer{
+				Name:          "Pilot",
+				URL:           "https://pilot.keys.example.com",
+				StatsAudience: "pilot",
+				SigningKey:    "projects/p/locations/l/keyRings/r/cryptoKeys/k/cryptoKeyVersions/1",
+			},
+			errs: []string{"signing_key", "signing_key_id"},
+		},
+		{
+			name: "issuer_without_signing_key",
+			keyServer: &KeyServer{
+				Name:          "Pilot",
+				URL:           "https://pilot.keys.example.com",
+				StatsAudience: "pilot",
+				Issuer:        "pilot-issuer",
+			},
+			errs: []string{"issuer"},
+		},
+		{
+			name: "valid_credentials",
+			keyServer: &KeyServer{
+				Name:          "Pilot",
+				URL:           "https://pilot.keys.example.com",
+				StatsAudience: "pilot",
+				SigningKey:    "projects/p/locations/l/keyRings/r/cryptoKeys/k/cryptoKeyVersions/1",
+				SigningKeyID:  "pilot-v1",
+				Issuer:        "pilot-issuer",
+			},
+		},
+		{
+			name: "valid",
+			keyServer: &KeyServer{
+				Name:          " Pilot ",
+				URL:           "https://pilot.keys.example.com",
+				StatsAudience: "pilot",
+			},
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			_ = tc.keyServer.BeforeSave(nil)
+			for _, field := range tc.errs {
+				if errs := tc.keyServer.ErrorsFor(field); len(errs) < 1 {
+					t.Errorf("expected errors for %s", field)
+				}
+			}
+			if len(tc.errs) == 0 {
+				if errs := tc.keyServer.ErrorMessages(); len(errs) > 0 {
+					t.Errorf("expected no errors, got %v", errs)
+				}
+			}
+		})
+	}
+}
+
+func TestDatabase_FindKeyServerForRealm(t *testing.T) {
+	t.Parallel()
+
+	db, _ := testDatabaseInstance.NewDatabase(t, nil)
+
+	realm, err := db.FindRealm(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// No key server associated.
+	keyServer, err := db.FindKeyServerForRealm(realm.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if keyServer != nil {
+		t.Errorf("expected no key server, got %#v", keyServer)
+	}
+
+	pilot := &KeyServer{
+		Name:                "Pilot",
+		URL:                 "https://pilot.keys.example.com",
+		StatsAudience:       "pilot",
+		CertificateAudience: "pilot-verification",
+	}
+	if err := db.SaveKeyServer(pilot, SystemTest); err != nil {
+		t.Fatal(err)
+	}
+
+	// Names are unique.
+	if err := db.SaveKeyServer(&KeyServer{
+		Name:          "Pilot",
+		URL:           "https://other.example.com",
+		StatsAudience: "other",
+	}, SystemTest); !IsValidationError(err) {
+		t.Errorf("expected validation error, got %v", err)
+	}
+
+	realm.KeyServerID = &pilot.ID
+	if err := db.SaveRealm(realm, SystemTest); err != nil {
+		t.Fatal(err)
+	}
+
+	keyServer, err = db.FindKeyServerForRealm(realm.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if keyServer == nil {
+		t.Fatal("expected key server")
+	}
+	if got, want := keyServer.CertificateAudience, "pilot-verification"; got != want {
+		t.Errorf("expected %q to be %q", got, want)
+	}
+
+	keyServers, err := db.ListKeyServers()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(keyServers), 1; got != want {
+		t.Errorf("expected %d key servers, got %d", want, got)
+	}
+}
@@ -0,0 +1,508 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/exposure-notifications-verification-server/pkg/rbac"
+)
+
+const (
+	// LegacyUpgradeRowsLost is reported when a stage removed realms, authorized
+	// apps, or active users. None of the migrations are expected to delete them.
+	LegacyUpgradeRowsLost = "rows_lost"
+
+	// LegacyUpgradeMissingMemberships are legacy user_realms or admin_realms
+	// associations that have no corresponding membership.
+	LegacyUpgradeMissingMemberships = "missing_memberships"
+
+	// LegacyUpgradeMissingAdminPermissions are legacy admin_realms associations
+	// whose membership does not grant the legacy realm admin permissions.
+	LegacyUpgradeMissingAdminPermissions = "missing_admin_permissions"
+)
+
+// LegacyUpgradeStage is a checkpoint in a staged upgrade of a deployment that
+// is still on the pre-membership schema. Stages are applied in order, and each
+// stage is verified before the next one starts.
+type LegacyUpgradeStage struct {
+	// Name is the short name of the stage.
+	Name string `json:"name"`
+
+	// Target is the last migration applied by this stage. The empty string
+	// means all remaining migrations.
+	Target string `json:"target"`
+
+	// Description is a human-readable summary of the stage.
+	Description string `json:"description"`
+
+	// Preserve are the tables that are copied before the stage runs, because
+	// the stage drops or rewrites them and the migration rollback cannot
+	// restore their contents.
+	Preserve []string `json:"preserve,omitempty"`
+}
+
+// LegacyUpgradeStages are the stages of a legacy upgrade, in order.
+var LegacyUpgradeStages = []*LegacyUpgradeStage{
+	{
+		Name:        "realms",
+		Target:      "00016-MigrateSMSConfigs",
+		Description: "realms and per-realm SMS configuration",
+	},
+	{
+		Name:        "hmac",
+		Target:      "00030-HMACCodes",
+		Description: "HMAC API keys and verification codes",
+	},
+	{
+		Name:        "pre-rbac",
+		Target:      "00074-MigrateSystemSMSConfig",
+		Description: "all schema changes before memberships",
+	},
+	{
+		Name:        "rbac",
+		Target:      "00075-CreateRBAC",
+		Description: "convert user_realms and admin_realms into memberships",
+		Preserve:    []string{"user_realms", "admin_realms"},
+	},
+	{
+		Name:        "memberships",
+		Target:      "00085-DeleteUsers",
+		Description: "membership timestamps and purge of soft-deleted users",
+		Preserve:    []string{"users"},
+	},
+	{
+		Name:        "latest",
+		Target:      "",
+		Description: "all remaining migrations",
+	},
+}
+
+// FindLegacyUpgradeStage returns the stage with the given name, or nil if no
+// such stage exists.
+func FindLegacyUpgradeStage(name string) *LegacyUpgradeStage {
+	for _, stage := range LegacyUpgradeStages {
+		if stage.Name == name {
+			return stage
+		}
+	}
+	return nil
+}
+
+// LegacyMembership is a user's association with a realm on the legacy schema.
+type LegacyMembership struct {
+	UserID  uint `json:"userID"`
+	RealmID uint `json:"realmID"`
+	Admin   bool `json:"admin"`
+}
+
+// LegacyUpgradeSnapshot is the state of the database at a point in a legacy
+// upgrade.
+type LegacyUpgradeSnapshot struct {
+	// LastMigration is the ID of the most recently applied migration, or the
+	// empty string if no migrations have been applied.
+	LastMigration string `json:"lastMigration"`
+
+	// Applied and Pending are the number of applied and pending migrations.
+	Applied int `json:"applied"`
+	Pending int `json:"pending"`
+
+	// Counts are the number of rows in each of the tracked tables that exist.
+	// Users are only counted if they are not soft-deleted.
+	Counts map[string]int64 `json:"counts"`
+
+	// LegacyMemberships are the associations in the legacy user_realms and
+	// admin_realms tables for active users and existing realms. It is empty once
+	// the memberships migration has run.
+	LegacyMemberships []*LegacyMembership `json:"legacyMemberships,omitempty"`
+
+	applied map[string]struct{}
+}
+
+// IsApplied returns true if the migration with the given ID had been applied
+// when the snapshot was taken.
+func (s *LegacyUpgradeSnapshot) IsApplied(id string) bool {
+	_, ok := s.applied[id]
+	return ok
+}
+
+// StageComplete returns true if every migration of the stage had been applied
+// when the snapshot was taken.
+func (s *LegacyUpgradeSnapshot) StageComplete(stage *LegacyUpgradeStage) bool {
+	if stage.Target == "" {
+		return s.Pending == 0
+	}
+	return s.IsApplied(stage.Target)
+}
+
+// LegacyUpgradeFinding is a single problem found when verifying a stage.
+type LegacyUpgradeFinding struct {
+	Check   string `json:"check"`
+	Count   int64  `json:"count"`
+	Message string `json:"message"`
+}
+
+// LegacyUpgradeCheckpoint is the record of a single stage of a legacy upgrade.
+type LegacyUpgradeCheckpoint struct {
+	Stage *LegacyUpgradeStage `json:"stage"`
+
+	// RollbackTo is the last migration that was applied before the stage ran.
+	// It can be passed to the migrate command with -rollback, but many early
+	// migrations do not implement a rollback, so restoring a database backup is
+	// the only reliable way back to this point.
+	RollbackTo string `json:"rollbackTo"`
+
+	// Backups are the copies of the preserved tables taken before the stage.
+	Backups []string `json:"backups,omitempty"`
+
+	Before   *LegacyUpgradeSnapshot  `json:"before"`
+	After    *LegacyUpgradeSnapshot  `json:"after"`
+	Findings []*LegacyUpgradeFinding `json:"findings"`
+
+	StartedAt   time.Time `json:"startedAt"`
+	CompletedAt time.Time `json:"completedAt"`
+}
+
+// legacyUpgradeCountTables are the tables whose rows are counted in a
+// snapshot.
+var legacyUpgradeCountTables = []string{
+	"users",
+	"realms",
+	"authorized_apps",
+	"verification_codes",
+	"tokens",
+	"user_realms",
+	"admin_realms",
+	"memberships",
+}
+
+// legacyUpgradeStableTables are the tables that no migration removes rows
+// from. Other tables have migrations that intentionally delete invalid rows.
+var legacyUpgradeStableTables = []string{
+	"users",
+	"realms",
+	"authorized_apps",
+}
+
+// TakeLegacyUpgradeSnapshot records the migration status, row counts, and
+// legacy realm associations of the database.
+func (db *Database) TakeLegacyUpgradeSnapshot(ctx context.Context) (*LegacyUpgradeSnapshot, error) {
+	raw := db.db.DB()
+
+	applied, last, err := appliedMigrations(ctx, raw)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list applied migrations: %w", err)
+	}
+
+	snapshot := &LegacyUpgradeSnapshot{
+		LastMigration: last,
+		Applied:       len(applied),
+		Counts:        make(map[string]int64, len(legacyUpgradeCountTables)),
+		applied:       applied,
+	}
+	for _, m := range db.Migrations(ctx) {
+		if m.ID == initState {
+			continue
+		}
+		if _, ok := applied[m.ID]; !ok {
+			snapshot.Pending++
+		}
+	}
+
+	exists := make(map[string]bool, len(legacyUpgradeCountTables))
+	for _, table := range legacyUpgradeCountTables {
+		ok, err := tableExists(ctx, raw, table)
+		if err != nil {
+			return nil, fmt.Errorf("failed to check table %s: %w", table, err)
+		}
+		exists[table] = ok
+		if !ok {
+			continue
+		}
+
+		q := fmt.Sprintf(`SELECT COUNT(*) FROM %s`, table)
+		if table == "users" {
+			q += ` WHERE deleted_at IS NULL`
+		}
+
+		var count int64
+		if err := raw.QueryRowContext(ctx, q).Scan(&count); err != nil {
+			return nil, fmt.Errorf("failed to count %s: %w", table, err)
+		}
+		snapshot.Counts[table] = count
+	}
+
+	// The user_realms table is renamed to memberships by the RBAC migration, so
+	// legacy associations only exist before that.
+	if exists["user_realms"] && !exists["memberships"] {
+		memberships, err := legacyMemberships(ctx, raw, exists["admin_realms"])
+		if err != nil {
+			return nil, fmt.Errorf("failed to list legacy memberships: %w", err)
+		}
+		snapshot.LegacyMemberships = memberships
+	}
+
+	return snapshot, nil
+}
+
+// CreateLegacyUpgradeBackups copies the tables preserved by the stage into
+// backup tables named legacy_upgrade_<stage>_<table>, replacing any previous
+// backup of the same stage. Tables that do not exist are skipped. It returns
+// the names of the backup tables.
+func (db *Database) CreateLegacyUpgradeBackups(ctx context.Context, stage *LegacyUpgradeStage) ([]string, error) {
+	raw := db.db.DB()
+
+	backups := make([]string, 0, len(stage.Preserve))
+	for _, table := range stage.Preserve {
+		ok, err := tableExists(ctx, raw, table)
+		if err != nil {
+			return nil, fmt.Errorf("failed to check table %s: %w", table, err)
+		}
+		if !ok {
+			continue
+		}
+
+		backup := legacyUpgradeBackupTable(stage, table)
+		if _, err := raw.ExecContext(ctx, fmt.Sprintf(`DROP TABLE IF EXISTS %s`, backup)); err != nil {
+			return nil, fmt.Errorf("failed to drop previous backup %s: %w", backup, err)
+		}
+		if _, err := raw.ExecContext(ctx, fmt.Sprintf(`CREATE TABLE %s AS TABLE %s`, backup, table)); err != nil {
+			return nil, fmt.Errorf("failed to back up %s: %w", table, err)
+		}
+		backups = append(backups, backup)
+	}
+	return backups, nil
+}
+
+// RunLegacyUpgradeStage runs a single stage of a legacy upgrade. It takes a
+// snapshot and backs up the preserved tables, applies the stage's migrations,
+// and verifies the result against the snapshot. Verification problems are
+// returned as findings on the checkpoint, not as an error.
+func (db *Database) RunLegacyUpgradeStage(ctx context.Context, stage *LegacyUpgradeStage) (*LegacyUpgradeCheckpoint, error) {
+	checkpoint := &LegacyUpgradeCheckpoint{
+		Stage:     stage,
+		StartedAt: time.Now().UTC(),
+	}
+
+	before, err := db.TakeLegacyUpgradeSnapshot(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to snapshot before %s: %w", stage.Name, err)
+	}
+	checkpoint.Before = before
+	checkpoint.RollbackTo = before.LastMigration
+
+	backups, err := db.CreateLegacyUpgradeBackups(ctx, stage)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create backups for %s: %w", stage.Name, err)
+	}
+	checkpoint.Backups = backups
+
+	if err := db.MigrateTo(ctx, stage.Target, false); err != nil {
+		return nil, fmt.Errorf("failed to migrate %s: %w", stage.Name, err)
+	}
+
+	after, err := db.TakeLegacyUpgradeSnapshot(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to snapshot after %s: %w", stage.Name, err)
+	}
+	checkpoint.After = after
+
+	findings, err := db.VerifyLegacyUpgrade(ctx, before, after)
+	if err != nil {
+		return nil, fmt.Errorf("failed to verify %s: %w", stage.Name, err)
+	}
+	checkpoint.Findings = findings
+	checkpoint.CompletedAt = time.Now().UTC()
+
+	return checkpoint, nil
+}
+
+// VerifyLegacyUpgrade compares snapshots taken before and after a stage. It
+// reports lost rows in tables that migrations never delete from and, once the
+// memberships table exists, legacy associations that were not converted.
+func (db *Database) VerifyLegacyUpgrade(ctx context.Context, before, after *LegacyUpgradeSnapshot) ([]*LegacyUpgradeFinding, error) {
+	var findings []*LegacyUpgradeFinding
+
+	for _, table := range legacyUpgradeStableTables {
+		was, ok := before.Counts[table]
+		if !ok {
+			continue
+		}
+		if now := after.Counts[table]; now < was {
+			findings = append(findings, &LegacyUpgradeFinding{
+				Check:   LegacyUpgradeRowsLost,
+				Count:   was - now,
+				Message: fmt.Sprintf("%s went from %d to %d rows", table, was, now),
+			})
+		}
+	}
+
+	if len(before.LegacyMemberships) == 0 {
+		return findings, nil
+	}
+	if _, ok := after.Counts["memberships"]; !ok {
+		return findings, nil
+	}
+
+	permissions, err := membershipPermissions(ctx, db.db.DB())
+	if err != nil {
+		return nil, fmt.Errorf("failed to list memberships: %w", err)
+	}
+
+	var missing, missingAdmin int64
+	for _, legacy := range before.LegacyMemberships {
+		key := [2]uint{legacy.UserID, legacy.RealmID}
+		perms, ok := permissions[key]
+		if !ok {
+			missing++
+			continue
+		}
+		if legacy.Admin && !rbac.Can(perms, rbac.LegacyRealmAdmin) {
+			missingAdmin++
+		}
+	}
+
+	if missing > 0 {
+		findings = append(findings, &LegacyUpgradeFinding{
+			Check:   LegacyUpgradeMissingMemberships,
+			Count:   missing,
+			Message: fmt.Sprintf("%d legacy realm associations have no membership", missing),
+		})
+	}
+	if missingAdmin > 0 {
+		findings = append(findings, &LegacyUpgradeFinding{
+			Check:   LegacyUpgradeMissingAdminPermissions,
+			Count:   missingAdmin,
+			Message: fmt.Sprintf("%d legacy realm admins are missing admin permissions", missingAdmin),
+		})
+	}
+
+	return findings, nil
+}
+
+// legacyUpgradeBackupTable returns the name of the backup of table for the
+// stage.
+func legacyUpgradeBackupTable(stage *LegacyUpgradeStage, table string) string {
+	name := strings.ReplaceAll(stage.Name, "-", "_")
+	return fmt.Sprintf("legacy_upgrade_%s_%s", name, table)
+}
+
+// appliedMigrations returns the set of applied migration IDs and the ID of the
+// most recent one. The migration IDs sort in the order they are applied.
+func appliedMigrations(ctx context.Context, db *sql.DB) (map[string]struct{}, string, error) {
+	applied := make(map[string]struct{})
+
+	ok, err := tableExists(ctx, db, "migrations")
+	if err != nil {
+		return nil, "", err
+	}
+	if !ok {
+		return applied, "", nil
+	}
+
+	rows, err := db.QueryContext(ctx, `SELECT id FROM migrations ORDER BY id`)
+	if err != nil {
+		return nil, "", err
+	}
+	defer rows.Close()
+
+	var last string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, "", err
+		}
+		if id == initState {
+			continue
+		}
+		applied[id] = struct{}{}
+		last = id
+	}
+	if err := rows.Err(); err != nil {
+		return nil, "", err
+	}
+	return applied, last, nil
+}
+
+// tableExists returns true if the table exists in the public schema.
+func tableExists(ctx context.Context, db *sql.DB, table string) (bool, error) {
+	var exists bool
+	if err := db.QueryRowContext(ctx, `SELECT to_regclass($1) IS NOT NULL`, "public."+table).Scan(&exists); err != nil {
+		return false, err
+	}
+	return exists, nil
+}
+
+// legacyMemberships returns the legacy realm associations of active users with
+// existing realms.
+func legacyMemberships(ctx context.Context, db *sql.DB, includeAdmins bool) ([]*LegacyMembership, error) {
+	source := `SELECT user_id, realm_id, false AS admin FROM user_realms`
+	if includeAdmins {
+		source += ` UNION ALL SELECT user_id, realm_id, true AS admin FROM admin_realms`
+	}
+
+	rows, err := db.QueryContext(ctx, fmt.Sprintf(`
+		SELECT p.user_id, p.realm_id, bool_or(p.admin)
+		FROM (%s) p
+		INNER JOIN users u ON u.id = p.user_id AND u.deleted_at IS NULL
+		INNER JOIN realms r ON r.id = p.realm_id
+		GROUP BY p.user_id, p.realm_id
+		ORDER BY p.user_id, p.realm_id`, source))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var memberships []*LegacyMembership
+	for rows.Next() {
+		var m LegacyMembership
+		if err := rows.Scan(&m.UserID, &m.RealmID, &m.Admin); err != nil {
+			return nil, err
+		}
+		memberships = append(memberships, &m)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return memberships, nil
+}
+
+// membershipPermissions returns the permissions of every membership, keyed by
+// user and realm ID.
+func membershipPermissions(ctx context.Context, db *sql.DB) (map[[2]uint]rbac.Permission, error) {
+	rows, err := db.QueryContext(ctx, `SELECT user_id, realm_id, COALESCE(permissions, 0) FROM memberships`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	permissions := make(map[[2]uint]rbac.Permission)
+	for rows.Next() {
+		var userID, realmID uint
+		var perms int64
+		if err := rows.Scan(&userID, &realmID, &perms); err != nil {
+			return nil, err
+		}
+		permissions[[2]uint{userID, realmID}] = rbac.Permission(perms)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return permissions, nil
+}
@@ -0,0 +1,188 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package database
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/exposure-notifications-verification-server/pkg/rbac"
+)
+
+func TestDatabase_TakeLegacyUpgradeSnapshot(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	db, _ := testDatabaseInstance.NewDatabase(t, nil)
+
+	snapshot, err := db.TakeLegacyUpgradeSnapshot(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	migrations := db.Migrations(ctx)
+	if got, want := snapshot.LastMigration, migrations[len(migrations)-1].ID; got != want {
+		t.Errorf("expected last migration %q to be %q", got, want)
+	}
+	if got, want := snapshot.Pending, 0; got != want {
+		t.Errorf("expected %d pending migrations, got %d", want, got)
+	}
+	if got, want := snapshot.Applied, len(migrations)-1; got != want {
+		t.Errorf("expected %d applied migrations, got %d", want, got)
+	}
+	for _, stage := range LegacyUpgradeStages {
+		if !snapshot.StageComplete(stage) {
+			t.Errorf("expected stage %q to be complete", stage.Name)
+		}
+	}
+
+	if _, ok := snapshot.Counts["memberships"]; !ok {
+		t.Errorf("expected memberships to be counted")
+	}
+	if _, ok := snapshot.Counts["user_realms"]; ok {
+		t.Errorf("expected user_realms to not exist")
+	}
+	if got := snapshot.LegacyMemberships; len(got) != 0 {
+		t.Errorf("expected no legacy memberships, got %#v", got)
+	}
+}
+
+func TestDatabase_CreateLegacyUpgradeBackups(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	db, _ := testDatabaseInstance.NewDatabase(t, nil)
+
+	// The legacy tables no longer exist, so there is nothing to back up.
+	backups, err := db.CreateLegacyUpgradeBackups(ctx, FindLegacyUpgradeStage("rbac"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(backups), 0; got != want {
+		t.Fatalf("expected %d backups, got %d: %v", want, got, backups)
+	}
+
+	if err := db.SaveUser(&User{Email: "user@example.com", Name: "User"}, SystemTest); err != nil {
+		t.Fatal(err)
+	}
+
+	// Backups are replaced when taken again.
+	for i := 0; i < 2; i++ {
+		backups, err = db.CreateLegacyUpgradeBackups(ctx, FindLegacyUpgradeStage("memberships"))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got, want := backups, []string{"legacy_upgrade_memberships_users"}; len(got) != 1 || got[0] != want[0] {
+		t.Fatalf("expected %v to be %v", got, want)
+	}
+
+	var got, want int64
+	if err := db.db.Table(backups[0]).Count(&got).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := db.db.Table("users").Count(&want).Error; err != nil {
+		t.Fatal(err)
+	}
+	if got != want {
+		t.Errorf("expected %d backed up users, got %d", want, got)
+	}
+}
+
+func TestDatabase_VerifyLegacyUpgrade(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	db, _ := testDatabaseInstance.NewDatabase(t, nil)
+
+	realm := NewRealmWithDefaults("test")
+	if err := db.SaveRealm(realm, SystemTest); err != nil {
+		t.Fatal(err)
+	}
+
+	user := &User{Email: "user@example.com", Name: "User"}
+	if err := db.SaveUser(user, SystemTest); err != nil {
+		t.Fatal(err)
+	}
+	admin := &User{Email: "admin@example.com", Name: "Admin"}
+	if err := db.SaveUser(admin, SystemTest); err != nil {
+		t.Fatal(err)
+	}
+
+	// The admin was converted with only user permissions.
+	if err := user.AddToRealm(db, realm, rbac.LegacyRealmUser, SystemTest); err != nil {
+		t.Fatal(err)
+	}
+	if err := admin.AddToRealm(db, realm, rbac.LegacyRealmUser, SystemTest); err != nil {
+		t.Fatal(err)
+	}
+
+	after, err := db.TakeLegacyUpgradeSnapshot(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("converted", func(t *testing.T) {
+		t.Parallel()
+
+		before := &LegacyUpgradeSnapshot{
+			Counts: map[string]int64{"realms": after.Counts["realms"]},
+			LegacyMemberships: []*LegacyMembership{
+				{UserID: user.ID, RealmID: realm.ID},
+			},
+		}
+
+		findings, err := db.VerifyLegacyUpgrade(ctx, before, after)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(findings) != 0 {
+			t.Errorf("expected no findings, got %#v", findings)
+		}
+	})
+
+	t.Run("problems", func(t *testing.T) {
+		t.Parallel()
+
+		before := &LegacyUpgradeSnapshot{
+			Counts: map[string]int64{"realms": after.Counts["realms"] + 2},
+			LegacyMemberships: []*LegacyMembership{
+				{UserID: user.ID, RealmID: realm.ID},
+				{UserID: admin.ID, RealmID: realm.ID, Admin: true},
+				{UserID: user.ID, RealmID: realm.ID + 100},
+			},
+		}
+
+		findings, err := db.VerifyLegacyUpgrade(ctx, before, after)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		got := make(map[string]int64, len(findings))
+		for _, f := range findings {
+			got[f.Check] += f.Count
+		}
+
+		for check, want := range map[string]int64{
+			LegacyUpgradeRowsLost:                2,
+			LegacyUpgradeMissingMemberships:      1,
+			LegacyUpgradeMissingAdminPermissions: 1,
+		} {
+			if got[check] != want {
+				t.Errorf("expected %s to be %d, got %d", check, want, got[check])
+			}
+		}
+	})
+}
@@ -25,6 +25,9 @@ type ManagedKey interface {
 	ManagedKeyID() string
 	// IsActive() returns true if this key is active
 	IsActive() bool
+	// MigratedPublicKey returns the PEM-encoded public key if this key was
+	// migrated to a different key manager, or the empty string otherwise.
+	MigratedPublicKey() string
 
 	SetManagedKeyID(keyID string)
 	SetActive(active bool)
@@ -37,5 +40,6 @@ type ManagedKey interface {
 // RealmManagedKey indicates that this key is owned by a realm.
 type RealmManagedKey interface {
 	ManagedKey
+	GetRealmID() uint
 	SetRealmID(id uint)
 }
@@ -45,6 +45,10 @@ type Membership struct {
 	// the membership's fields, not the user fields (e.g. email, name).
 	CreatedAt time.Time
 	UpdatedAt time.Time
+
+	// LastActiveAt is the last time the user selected this realm. It is nil if
+	// the user has never selected the realm.
+	LastActiveAt *time.Time
 }
 
 // SaveMembership saves the membership details. Should have a userID and a
@@ -98,6 +102,26 @@ func (db *Database) SaveMembership(m *Membership, actor Auditable) error {
 	})
 }
 
+// TouchMembershipLastActive updates the last active time on the membership. It
+// updates the column directly and does not invoke callbacks or create an audit
+// entry.
+func (db *Database) TouchMembershipLastActive(m *Membership) error {
+	if m == nil {
+		return fmt.Errorf("provided membership is nil")
+	}
+
+	now := time.Now().UTC()
+	if err := db.db.
+		Model(&Membership{}).
+		Where("user_id = ? AND realm_id = ?", m.UserID, m.RealmID).
+		UpdateColumn("last_active_at", now).
+		Error; err != nil {
+		return err
+	}
+	m.LastActiveAt = &now
+	return nil
+}
+
 // AfterFind does a sanity check to ensure the User and Realm properties were
 // preloaded and the referenced values exist.
 func (m *Membership) AfterFind() error {
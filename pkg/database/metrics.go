@@ -25,7 +25,13 @@ import (
 
 const metricPrefix = observability.MetricRoot + "/database"
 
-var mAuditEntryCreated = stats.Int64(metricPrefix+"/audit_entry_created", "The number of times an audit entry was created", stats.UnitDimensionless)
+var (
+	mAuditEntryCreated = stats.Int64(metricPrefix+"/audit_entry_created", "The number of times an audit entry was created", stats.UnitDimensionless)
+
+	mDualWriteMirrored       = stats.Int64(metricPrefix+"/dual_write_mirrored", "The number of statements mirrored to the secondary database", stats.UnitDimensionless)
+	mDualWriteErrors         = stats.Int64(metricPrefix+"/dual_write_errors", "The number of statements that failed to mirror to the secondary database", stats.UnitDimensionless)
+	mDualWriteDivergedTables = stats.Int64(metricPrefix+"/dual_write_diverged_tables", "The number of tables that differ between the primary and secondary database", stats.UnitDimensionless)
+)
 
 func init() {
 	enobs.CollectViews([]*view.View{
@@ -36,5 +42,26 @@ func init() {
 			TagKeys:     observability.CommonTagKeys(),
 			Aggregation: view.Count(),
 		},
+		{
+			Name:        metricPrefix + "/dual_write_mirrored_count",
+			Measure:     mDualWriteMirrored,
+			Description: "The count of statements mirrored to the secondary database",
+			TagKeys:     observability.CommonTagKeys(),
+			Aggregation: view.Count(),
+		},
+		{
+			Name:        metricPrefix + "/dual_write_errors_count",
+			Measure:     mDualWriteErrors,
+			Description: "The count of statements that failed to mirror to the secondary database",
+			TagKeys:     observability.CommonTagKeys(),
+			Aggregation: view.Count(),
+		},
+		{
+			Name:        metricPrefix + "/dual_write_diverged_tables_latest",
+			Measure:     mDualWriteDivergedTables,
+			Description: "The number of tables that differed between the primary and secondary database at the last verification",
+			TagKeys:     observability.CommonTagKeys(),
+			Aggregation: view.LastValue(),
+		},
 	}...)
 }
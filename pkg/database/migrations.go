@@ -2613,6 +2613,1325 @@ func (db *Database) Migrations(ctx context.Context) []*gormigrate.Migration {
 					`ALTER TABLE realm_stats DROP COLUMN IF EXISTS user_reports_invalid_nonce_by_os`)
 			},
 		},
+		{
+			ID: "00126-CreateSandboxSMS",
+			Migrate: func(tx *gorm.DB) error {
+				return multiExec(tx,
+					`CREATE TABLE sandbox_sms (
+						id BIGSERIAL PRIMARY KEY,
+						realm_id INTEGER NOT NULL REFERENCES realms(id) ON DELETE CASCADE,
+						to_number TEXT NOT NULL,
+						message TEXT NOT NULL,
+						created_at TIMESTAMP WITH TIME ZONE NOT NULL
+					)`,
+					`CREATE INDEX idx_sandbox_sms_realm_id_created_at ON sandbox_sms(realm_id, created_at)`,
+				)
+			},
+			Rollback: func(tx *gorm.DB) error {
+				return multiExec(tx,
+					`DROP TABLE IF EXISTS sandbox_sms`,
+				)
+			},
+		},
+		{
+			ID: "00127-CreateRealmStatsAnnotations",
+			Migrate: func(tx *gorm.DB) error {
+				return multiExec(tx,
+					`CREATE TABLE realm_stats_annotations (
+						id BIGSERIAL PRIMARY KEY,
+						realm_id INTEGER NOT NULL REFERENCES realms(id) ON DELETE CASCADE,
+						date DATE NOT NULL,
+						message TEXT NOT NULL,
+						created_at TIMESTAMP WITH TIME ZONE NOT NULL,
+						updated_at TIMESTAMP WITH TIME ZONE NOT NULL
+					)`,
+					`CREATE INDEX idx_realm_stats_annotations_realm_id_date ON realm_stats_annotations(realm_id, date)`,
+				)
+			},
+			Rollback: func(tx *gorm.DB) error {
+				return multiExec(tx,
+					`DROP TABLE IF EXISTS realm_stats_annotations`,
+				)
+			},
+		},
+		{
+			ID: "00128-AddUserDefaultRealmAndMembershipLastActive",
+			Migrate: func(tx *gorm.DB) error {
+				return multiExec(tx,
+					`ALTER TABLE users ADD COLUMN IF NOT EXISTS default_realm_id INTEGER REFERENCES realms(id) ON DELETE SET NULL`,
+					`ALTER TABLE memberships ADD COLUMN IF NOT EXISTS last_active_at TIMESTAMP WITH TIME ZONE`,
+				)
+			},
+			Rollback: func(tx *gorm.DB) error {
+				return multiExec(tx,
+					`ALTER TABLE users DROP COLUMN IF EXISTS default_realm_id`,
+					`ALTER TABLE memberships DROP COLUMN IF EXISTS last_active_at`,
+				)
+			},
+		},
+		{
+			ID: "00129-AddRealmCertificateSigningAlgorithm",
+			Migrate: func(tx *gorm.DB) error {
+				return multiExec(tx,
+					`ALTER TABLE realms ADD COLUMN IF NOT EXISTS certificate_signing_algorithm VARCHAR(10) NOT NULL DEFAULT 'ES256'`,
+				)
+			},
+			Rollback: func(tx *gorm.DB) error {
+				return multiExec(tx,
+					`ALTER TABLE realms DROP COLUMN IF EXISTS certificate_signing_algorithm`,
+				)
+			},
+		},
+		{
+			ID: "00130-AddRealmExports",
+			Migrate: func(tx *gorm.DB) error {
+				return multiExec(tx,
+					`CREATE TABLE IF NOT EXISTS realm_exports (
+						id BIGSERIAL PRIMARY KEY,
+						realm_id INTEGER NOT NULL REFERENCES realms(id) ON DELETE CASCADE,
+						status TEXT NOT NULL DEFAULT 'pending',
+						object_name TEXT,
+						digest TEXT,
+						error TEXT,
+						completed_at TIMESTAMPTZ,
+						created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+						updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+					)`,
+					`CREATE INDEX IF NOT EXISTS idx_realm_exports_realm_id ON realm_exports (realm_id)`,
+					`CREATE INDEX IF NOT EXISTS idx_realm_exports_status ON realm_exports (status)`,
+				)
+			},
+			Rollback: func(tx *gorm.DB) error {
+				return multiExec(tx,
+					`DROP TABLE IF EXISTS realm_exports`,
+				)
+			},
+		},
+		{
+			ID: "00131-AddKeyServers",
+			Migrate: func(tx *gorm.DB) error {
+				return multiExec(tx,
+					`CREATE TABLE IF NOT EXISTS key_servers (
+						id SERIAL PRIMARY KEY,
+						name TEXT NOT NULL,
+						url TEXT NOT NULL,
+						stats_audience TEXT NOT NULL,
+						certificate_audience TEXT,
+						created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+						updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+					)`,
+					`CREATE UNIQUE INDEX IF NOT EXISTS uix_key_servers_name ON key_servers (name)`,
+					`ALTER TABLE realms ADD COLUMN IF NOT EXISTS key_server_id INTEGER REFERENCES key_servers(id) ON DELETE SET NULL`,
+				)
+			},
+			Rollback: func(tx *gorm.DB) error {
+				return multiExec(tx,
+					`ALTER TABLE realms DROP COLUMN IF EXISTS key_server_id`,
+					`DROP TABLE IF EXISTS key_servers`,
+				)
+			},
+		},
+		{
+			ID: "00132-AddRealmStatsPrivacy",
+			Migrate: func(tx *gorm.DB) error {
+				return multiExec(tx,
+					`ALTER TABLE realms ADD COLUMN IF NOT EXISTS stats_privacy_mode VARCHAR(10) NOT NULL DEFAULT 'off'`,
+					`ALTER TABLE realms ADD COLUMN IF NOT EXISTS stats_privacy_threshold INTEGER NOT NULL DEFAULT 10`,
+					`ALTER TABLE realms ADD COLUMN IF NOT EXISTS stats_privacy_epsilon DOUBLE PRECISION NOT NULL DEFAULT 1.0`,
+					`ALTER TABLE realms ADD COLUMN IF NOT EXISTS stats_privacy_salt TEXT`,
+				)
+			},
+			Rollback: func(tx *gorm.DB) error {
+				return multiExec(tx,
+					`ALTER TABLE realms DROP COLUMN IF EXISTS stats_privacy_mode`,
+					`ALTER TABLE realms DROP COLUMN IF EXISTS stats_privacy_threshold`,
+					`ALTER TABLE realms DROP COLUMN IF EXISTS stats_privacy_epsilon`,
+					`ALTER TABLE realms DROP COLUMN IF EXISTS stats_privacy_salt`,
+				)
+			},
+		},
+		{
+			ID: "00133-AddJobStatuses",
+			Migrate: func(tx *gorm.DB) error {
+				return multiExec(tx,
+					`CREATE TABLE IF NOT EXISTS job_statuses (
+						name TEXT PRIMARY KEY,
+						last_run_at TIMESTAMPTZ,
+						last_success_at TIMESTAMPTZ,
+						last_processed BIGINT NOT NULL DEFAULT 0,
+						last_failure_at TIMESTAMPTZ,
+						last_error TEXT
+					)`,
+				)
+			},
+			Rollback: func(tx *gorm.DB) error {
+				return multiExec(tx,
+					`DROP TABLE IF EXISTS job_statuses`,
+				)
+			},
+		},
+		{
+			ID: "00134-AddIssuancePresets",
+			Migrate: func(tx *gorm.DB) error {
+				return multiExec(tx,
+					`CREATE TABLE IF NOT EXISTS issuance_presets (
+						id SERIAL PRIMARY KEY,
+						realm_id INTEGER NOT NULL REFERENCES realms(id) ON DELETE CASCADE,
+						name TEXT NOT NULL,
+						test_type TEXT NOT NULL DEFAULT '',
+						symptom_date_offset_days INTEGER,
+						sms_template_label TEXT NOT NULL DEFAULT ''
+					)`,
+					`CREATE UNIQUE INDEX IF NOT EXISTS uix_issuance_presets_realm_id_name ON issuance_presets (realm_id, LOWER(name))`,
+				)
+			},
+			Rollback: func(tx *gorm.DB) error {
+				return multiExec(tx,
+					`DROP TABLE IF EXISTS issuance_presets`,
+				)
+			},
+		},
+		{
+			ID: "00135-AddClaimFailures",
+			Migrate: func(tx *gorm.DB) error {
+				return multiExec(tx,
+					`CREATE TABLE IF NOT EXISTS claim_failures (
+						id BIGSERIAL PRIMARY KEY,
+						realm_id INTEGER NOT NULL,
+						authorized_app_id INTEGER NOT NULL,
+						reason TEXT NOT NULL,
+						created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+					)`,
+					`CREATE INDEX IF NOT EXISTS idx_claim_failures_created_at ON claim_failures (created_at)`,
+				)
+			},
+			Rollback: func(tx *gorm.DB) error {
+				return multiExec(tx,
+					`DROP TABLE IF EXISTS claim_failures`,
+				)
+			},
+		},
+		{
+			ID: "00136-AddAuthorizedAppCallbacks",
+			Migrate: func(tx *gorm.DB) error {
+				return multiExec(tx,
+					`ALTER TABLE authorized_apps
+						ADD COLUMN IF NOT EXISTS callback_url TEXT,
+						ADD COLUMN IF NOT EXISTS callback_secret TEXT`,
+				)
+			},
+			Rollback: func(tx *gorm.DB) error {
+				return multiExec(tx,
+					`ALTER TABLE authorized_apps
+						DROP COLUMN IF EXISTS callback_url,
+						DROP COLUMN IF EXISTS callback_secret`)
+			},
+		},
+		{
+			ID: "00137-AddAnnouncements",
+			Migrate: func(tx *gorm.DB) error {
+				return multiExec(tx,
+					`CREATE TABLE IF NOT EXISTS announcements (
+						id SERIAL PRIMARY KEY,
+						title TEXT NOT NULL,
+						body TEXT NOT NULL,
+						audience TEXT NOT NULL,
+						starts_at TIMESTAMP WITH TIME ZONE NOT NULL,
+						ends_at TIMESTAMP WITH TIME ZONE,
+						created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+						updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+					)`,
+					`CREATE TABLE IF NOT EXISTS announcement_reads (
+						announcement_id INTEGER NOT NULL REFERENCES announcements(id) ON DELETE CASCADE,
+						user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+						read_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+						PRIMARY KEY (announcement_id, user_id)
+					)`,
+				)
+			},
+			Rollback: func(tx *gorm.DB) error {
+				return multiExec(tx,
+					`DROP TABLE IF EXISTS announcement_reads`,
+					`DROP TABLE IF EXISTS announcements`,
+				)
+			},
+		},
+		{
+			ID: "00138-CreateRealmStatCorrections",
+			Migrate: func(tx *gorm.DB) error {
+				return multiExec(tx,
+					`CREATE TABLE realm_stat_corrections (
+						id BIGSERIAL PRIMARY KEY,
+						realm_id INTEGER NOT NULL REFERENCES realms(id) ON DELETE CASCADE,
+						date DATE NOT NULL,
+						field TEXT NOT NULL,
+						original_value INTEGER NOT NULL,
+						corrected_value INTEGER NOT NULL,
+						reason TEXT NOT NULL,
+						created_at TIMESTAMP WITH TIME ZONE NOT NULL
+					)`,
+					`CREATE INDEX idx_realm_stat_corrections_realm_id_date ON realm_stat_corrections(realm_id, date)`,
+				)
+			},
+			Rollback: func(tx *gorm.DB) error {
+				return multiExec(tx,
+					`DROP TABLE IF EXISTS realm_stat_corrections`,
+				)
+			},
+		},
+		{
+			ID: "00139-CreateSMSFromNumberChanges",
+			Migrate: func(tx *gorm.DB) error {
+				return multiExec(tx,
+					`CREATE TABLE sms_from_number_changes (
+						id BIGSERIAL PRIMARY KEY,
+						realm_id INTEGER NOT NULL REFERENCES realms(id) ON DELETE CASCADE,
+						sms_from_number_id INTEGER NOT NULL REFERENCES sms_from_numbers(id) ON DELETE CASCADE,
+						label TEXT NOT NULL,
+						old_value TEXT NOT NULL,
+						new_value TEXT NOT NULL,
+						recipients TEXT[],
+						notified_at TIMESTAMP WITH TIME ZONE,
+						created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+					)`,
+					`CREATE INDEX idx_sms_from_number_changes_pending ON sms_from_number_changes(realm_id) WHERE notified_at IS NULL`,
+				)
+			},
+			Rollback: func(tx *gorm.DB) error {
+				return multiExec(tx,
+					`DROP TABLE IF EXISTS sms_from_number_changes`,
+				)
+			},
+		},
+		{
+			ID: "00141-AddRealmSMSAllowedCountries",
+			Migrate: func(tx *gorm.DB) error {
+				return multiExec(tx,
+					`ALTER TABLE realms ADD COLUMN IF NOT EXISTS sms_allowed_countries VARCHAR(5)[]`,
+					`ALTER TABLE realms ADD COLUMN IF NOT EXISTS sms_allowed_countries_warn_only BOOL NOT NULL DEFAULT false`,
+				)
+			},
+			Rollback: func(tx *gorm.DB) error {
+				return multiExec(tx,
+					`ALTER TABLE realms DROP COLUMN IF EXISTS sms_allowed_countries`,
+					`ALTER TABLE realms DROP COLUMN IF EXISTS sms_allowed_countries_warn_only`,
+				)
+			},
+		},
+		{
+			ID: "00142-AddKeyServerCredentials",
+			Migrate: func(tx *gorm.DB) error {
+				return multiExec(tx,
+					`ALTER TABLE key_servers ADD COLUMN IF NOT EXISTS signing_key TEXT`,
+					`ALTER TABLE key_servers ADD COLUMN IF NOT EXISTS signing_key_id TEXT`,
+					`ALTER TABLE key_servers ADD COLUMN IF NOT EXISTS issuer TEXT`,
+				)
+			},
+			Rollback: func(tx *gorm.DB) error {
+				return multiExec(tx,
+					`ALTER TABLE key_servers DROP COLUMN IF EXISTS signing_key`,
+					`ALTER TABLE key_servers DROP COLUMN IF EXISTS signing_key_id`,
+					`ALTER TABLE key_servers DROP COLUMN IF EXISTS issuer`,
+				)
+			},
+		},
+		{
+			ID: "00143-AddCallbackDeliveries",
+			Migrate: func(tx *gorm.DB) error {
+				return multiExec(tx,
+					`CREATE TABLE IF NOT EXISTS callback_deliveries (
+						id BIGSERIAL PRIMARY KEY,
+						authorized_app_id INTEGER NOT NULL REFERENCES authorized_apps(id) ON DELETE CASCADE,
+						payload TEXT NOT NULL,
+						attempts INTEGER NOT NULL DEFAULT 0,
+						next_attempt_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+						last_error TEXT,
+						delivered_at TIMESTAMPTZ,
+						failed_at TIMESTAMPTZ,
+						created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+						updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+					)`,
+					`CREATE INDEX IF NOT EXISTS idx_callback_deliveries_pending ON callback_deliveries (next_attempt_at) WHERE delivered_at IS NULL AND failed_at IS NULL`,
+					`CREATE INDEX IF NOT EXISTS idx_callback_deliveries_created_at ON callback_deliveries (created_at)`,
+				)
+			},
+			Rollback: func(tx *gorm.DB) error {
+				return multiExec(tx,
+					`DROP TABLE IF EXISTS callback_deliveries`,
+				)
+			},
+		},
+		{
+			ID: "00144-AddDataAccessLogs",
+			Migrate: func(tx *gorm.DB) error {
+				return multiExec(tx,
+					`CREATE TABLE IF NOT EXISTS data_access_logs (
+						id BIGSERIAL PRIMARY KEY,
+						realm_id INTEGER NOT NULL,
+						actor_id TEXT NOT NULL,
+						actor_display TEXT NOT NULL,
+						action TEXT NOT NULL,
+						target_id TEXT NOT NULL,
+						target_display TEXT NOT NULL,
+						fields TEXT NOT NULL,
+						records INTEGER NOT NULL DEFAULT 0,
+						created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+					)`,
+					`CREATE INDEX IF NOT EXISTS idx_data_access_logs_realm_id_created_at ON data_access_logs (realm_id, created_at)`,
+					`CREATE INDEX IF NOT EXISTS idx_data_access_logs_created_at ON data_access_logs (created_at)`,
+				)
+			},
+			Rollback: func(tx *gorm.DB) error {
+				return multiExec(tx,
+					`DROP TABLE IF EXISTS data_access_logs`,
+				)
+			},
+		},
+		{
+			ID: "00145-AddCallbackDeliveryAttempts",
+			Migrate: func(tx *gorm.DB) error {
+				return multiExec(tx,
+					`ALTER TABLE callback_deliveries ADD COLUMN IF NOT EXISTS replay_of_id INTEGER`,
+					`CREATE TABLE IF NOT EXISTS callback_delivery_attempts (
+						id BIGSERIAL PRIMARY KEY,
+						callback_delivery_id INTEGER NOT NULL REFERENCES callback_deliveries(id) ON DELETE CASCADE,
+						attempt INTEGER NOT NULL,
+						url TEXT NOT NULL,
+						request_headers TEXT,
+						response_headers TEXT,
+						response_status INTEGER NOT NULL DEFAULT 0,
+						response_body TEXT,
+						error TEXT,
+						duration_ms INTEGER NOT NULL DEFAULT 0,
+						created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+					)`,
+					`CREATE INDEX IF NOT EXISTS idx_callback_delivery_attempts_callback_delivery_id ON callback_delivery_attempts (callback_delivery_id)`,
+					`CREATE INDEX IF NOT EXISTS idx_callback_deliveries_authorized_app_id ON callback_deliveries (authorized_app_id)`,
+				)
+			},
+			Rollback: func(tx *gorm.DB) error {
+				return multiExec(tx,
+					`DROP INDEX IF EXISTS idx_callback_deliveries_authorized_app_id`,
+					`DROP TABLE IF EXISTS callback_delivery_attempts`,
+					`ALTER TABLE callback_deliveries DROP COLUMN IF EXISTS replay_of_id`,
+				)
+			},
+		},
+		{
+			ID: "00146-AddRealmCleanupProgress",
+			Migrate: func(tx *gorm.DB) error {
+				return multiExec(tx,
+					`CREATE TABLE IF NOT EXISTS realm_cleanup_progress (
+						realm_id INTEGER PRIMARY KEY REFERENCES realms(id) ON DELETE CASCADE,
+						completed_at TIMESTAMPTZ NOT NULL,
+						purged BIGINT NOT NULL DEFAULT 0
+					)`,
+					`CREATE INDEX IF NOT EXISTS idx_verification_codes_realm_id_expires_at ON verification_codes (realm_id, expires_at)`,
+					`CREATE INDEX IF NOT EXISTS idx_tokens_realm_id_expires_at ON tokens (realm_id, expires_at)`,
+				)
+			},
+			Rollback: func(tx *gorm.DB) error {
+				return multiExec(tx,
+					`DROP INDEX IF EXISTS idx_tokens_realm_id_expires_at`,
+					`DROP INDEX IF EXISTS idx_verification_codes_realm_id_expires_at`,
+					`DROP TABLE IF EXISTS realm_cleanup_progress`,
+				)
+			},
+		},
+		{
+			ID: "00147-AddRealmMinimumAppVersion",
+			Migrate: func(tx *gorm.DB) error {
+				return multiExec(tx,
+					`ALTER TABLE realms ADD COLUMN IF NOT EXISTS minimum_app_version TEXT`,
+				)
+			},
+			Rollback: func(tx *gorm.DB) error {
+				return multiExec(tx,
+					`ALTER TABLE realms DROP COLUMN IF EXISTS minimum_app_version`,
+				)
+			},
+		},
+		{
+			ID: "00148-AddVerificationCodeHistory",
+			Migrate: func(tx *gorm.DB) error {
+				return multiExec(tx,
+					`CREATE TABLE IF NOT EXISTS verification_code_history (
+						id BIGSERIAL PRIMARY KEY,
+						realm_id INTEGER NOT NULL REFERENCES realms(id) ON DELETE CASCADE,
+						issue_date DATE NOT NULL,
+						issuer_type TEXT NOT NULL,
+						test_type TEXT NOT NULL,
+						outcome TEXT NOT NULL,
+						claim_latency_minutes INTEGER
+					)`,
+					`CREATE INDEX IF NOT EXISTS idx_verification_code_history_realm_id_issue_date ON verification_code_history (realm_id, issue_date)`,
+					`CREATE INDEX IF NOT EXISTS idx_verification_code_history_issue_date ON verification_code_history (issue_date)`,
+				)
+			},
+			Rollback: func(tx *gorm.DB) error {
+				return multiExec(tx,
+					`DROP TABLE IF EXISTS verification_code_history`,
+				)
+			},
+		},
+		{
+			ID: "00149-AddRealmCustomDomain",
+			Migrate: func(tx *gorm.DB) error {
+				return multiExec(tx,
+					`ALTER TABLE realms ADD COLUMN IF NOT EXISTS custom_domain TEXT`,
+					`ALTER TABLE realms ADD CONSTRAINT uix_realms_custom_domain UNIQUE (custom_domain)`,
+				)
+			},
+			Rollback: func(tx *gorm.DB) error {
+				return multiExec(tx,
+					`ALTER TABLE realms DROP CONSTRAINT IF EXISTS uix_realms_custom_domain`,
+					`ALTER TABLE realms DROP COLUMN IF EXISTS custom_domain`,
+				)
+			},
+		},
+		{
+			ID: "00150-AddFirebaseUserDeletions",
+			Migrate: func(tx *gorm.DB) error {
+				return multiExec(tx,
+					`CREATE TABLE IF NOT EXISTS firebase_user_deletions (
+						id BIGSERIAL PRIMARY KEY,
+						email TEXT NOT NULL,
+						requested_at TIMESTAMP WITH TIME ZONE NOT NULL,
+						disabled_at TIMESTAMP WITH TIME ZONE
+					)`,
+					`CREATE UNIQUE INDEX IF NOT EXISTS uix_firebase_user_deletions_email ON firebase_user_deletions (email)`,
+					`CREATE INDEX IF NOT EXISTS idx_firebase_user_deletions_requested_at ON firebase_user_deletions (requested_at)`,
+					`CREATE INDEX IF NOT EXISTS idx_firebase_user_deletions_disabled_at ON firebase_user_deletions (disabled_at)`,
+				)
+			},
+			Rollback: func(tx *gorm.DB) error {
+				return multiExec(tx,
+					`DROP TABLE IF EXISTS firebase_user_deletions`,
+				)
+			},
+		},
+		{
+			ID: "00151-AddRealmAgencyImages",
+			Migrate: func(tx *gorm.DB) error {
+				return multiExec(tx,
+					`ALTER TABLE realms ADD COLUMN IF NOT EXISTS agency_branding_managed BOOL NOT NULL DEFAULT false`,
+					`CREATE TABLE IF NOT EXISTS realm_agency_images (
+						realm_id INTEGER PRIMARY KEY REFERENCES realms(id) ON DELETE CASCADE,
+						content_type TEXT NOT NULL,
+						data BYTEA NOT NULL,
+						checksum TEXT NOT NULL,
+						created_at TIMESTAMP WITH TIME ZONE NOT NULL
+					)`,
+				)
+			},
+			Rollback: func(tx *gorm.DB) error {
+				return multiExec(tx,
+					`DROP TABLE IF EXISTS realm_agency_images`,
+					`ALTER TABLE realms DROP COLUMN IF EXISTS agency_branding_managed`,
+				)
+			},
+		},
+		{
+			ID: "00152-AddRealmStatsCodeCollisions",
+			Migrate: func(tx *gorm.DB) error {
+				return multiExec(tx,
+					`ALTER TABLE realm_stats ADD COLUMN IF NOT EXISTS code_collisions INTEGER NOT NULL DEFAULT 0`,
+				)
+			},
+			Rollback: func(tx *gorm.DB) error {
+				return multiExec(tx,
+					`ALTER TABLE realm_stats DROP COLUMN IF EXISTS code_collisions`,
+				)
+			},
+		},
+		{
+			ID: "00153-AddSMSConfigMessageBird",
+			Migrate: func(tx *gorm.DB) error {
+				return multiExec(tx,
+					`ALTER TABLE sms_configs ADD COLUMN IF NOT EXISTS message_bird_originator TEXT`,
+					`ALTER TABLE sms_configs ADD COLUMN IF NOT EXISTS message_bird_access_key TEXT`,
+				)
+			},
+			Rollback: func(tx *gorm.DB) error {
+				return multiExec(tx,
+					`ALTER TABLE sms_configs DROP COLUMN IF EXISTS message_bird_originator`,
+					`ALTER TABLE sms_configs DROP COLUMN IF EXISTS message_bird_access_key`,
+				)
+			},
+		},
+		{
+			ID: "00154-AddVerificationCodeTransfers",
+			Migrate: func(tx *gorm.DB) error {
+				return multiExec(tx,
+					`CREATE TABLE IF NOT EXISTS verification_code_transfers (
+						id BIGSERIAL,
+						verification_code_uuid UUID NOT NULL,
+						from_realm_id INTEGER NOT NULL,
+						to_realm_id INTEGER NOT NULL,
+						actor_id TEXT NOT NULL,
+						actor_display TEXT NOT NULL,
+						patient_consent BOOL NOT NULL DEFAULT FALSE,
+						reason TEXT NOT NULL DEFAULT '',
+						created_at TIMESTAMP WITH TIME ZONE,
+						PRIMARY KEY (id)
+					)`,
+					`CREATE INDEX IF NOT EXISTS idx_verification_code_transfers_uuid ON verification_code_transfers (verification_code_uuid)`,
+					`CREATE INDEX IF NOT EXISTS idx_verification_code_transfers_from_realm_id ON verification_code_transfers (from_realm_id)`,
+					`CREATE INDEX IF NOT EXISTS idx_verification_code_transfers_to_realm_id ON verification_code_transfers (to_realm_id)`,
+				)
+			},
+			Rollback: func(tx *gorm.DB) error {
+				return multiExec(tx,
+					`DROP TABLE IF EXISTS verification_code_transfers`,
+				)
+			},
+		},
+		{
+			ID: "00155-AddUserReportConsents",
+			Migrate: func(tx *gorm.DB) error {
+				return multiExec(tx,
+					`ALTER TABLE realms ADD COLUMN IF NOT EXISTS user_report_consent_version INTEGER NOT NULL DEFAULT 0`,
+					`CREATE TABLE IF NOT EXISTS user_report_consents (
+						id BIGSERIAL,
+						realm_id INTEGER NOT NULL REFERENCES realms(id) ON DELETE CASCADE,
+						version INTEGER NOT NULL,
+						locale TEXT NOT NULL,
+						text TEXT NOT NULL,
+						created_at TIMESTAMP WITH TIME ZONE,
+						PRIMARY KEY (id)
+					)`,
+					`CREATE UNIQUE INDEX IF NOT EXISTS uix_user_report_consents_realm_version_locale ON user_report_consents (realm_id, version, LOWER(locale))`,
+					`CREATE TABLE IF NOT EXISTS user_report_consent_acceptances (
+						id BIGSERIAL,
+						realm_id INTEGER NOT NULL,
+						user_report_id INTEGER NOT NULL,
+						version INTEGER NOT NULL,
+						locale TEXT NOT NULL,
+						accepted_at TIMESTAMP WITH TIME ZONE NOT NULL,
+						PRIMARY KEY (id)
+					)`,
+					`CREATE INDEX IF NOT EXISTS idx_user_report_consent_acceptances_realm_accepted_at ON user_report_consent_acceptances (realm_id, accepted_at)`,
+					`CREATE INDEX IF NOT EXISTS idx_user_report_consent_acceptances_accepted_at ON user_report_consent_acceptances (accepted_at)`,
+				)
+			},
+			Rollback: func(tx *gorm.DB) error {
+				return multiExec(tx,
+					`DROP TABLE IF EXISTS user_report_consent_acceptances`,
+					`DROP TABLE IF EXISTS user_report_consents`,
+					`ALTER TABLE realms DROP COLUMN IF EXISTS user_report_consent_version`,
+				)
+			},
+		},
+		{
+			ID: "00156-AddRealmCodeFormat",
+			Migrate: func(tx *gorm.DB) error {
+				return multiExec(tx,
+					`ALTER TABLE realms ADD COLUMN IF NOT EXISTS code_charset TEXT NOT NULL DEFAULT 'numeric'`,
+					`ALTER TABLE realms ADD COLUMN IF NOT EXISTS code_group_size SMALLINT NOT NULL DEFAULT 0`,
+				)
+			},
+			Rollback: func(tx *gorm.DB) error {
+				return multiExec(tx,
+					`ALTER TABLE realms DROP COLUMN IF EXISTS code_charset`,
+					`ALTER TABLE realms DROP COLUMN IF EXISTS code_group_size`,
+				)
+			},
+		},
+		{
+			ID: "00157-AddVerificationCodeHistoryOnsetClaimDays",
+			Migrate: func(tx *gorm.DB) error {
+				return multiExec(tx,
+					`ALTER TABLE verification_code_history ADD COLUMN IF NOT EXISTS onset_claim_days INTEGER`,
+				)
+			},
+			Rollback: func(tx *gorm.DB) error {
+				return multiExec(tx,
+					`ALTER TABLE verification_code_history DROP COLUMN IF EXISTS onset_claim_days`,
+				)
+			},
+		},
+		{
+			ID: "00158-AddRealmInvitations",
+			Migrate: func(tx *gorm.DB) error {
+				return multiExec(tx,
+					`CREATE TABLE IF NOT EXISTS realm_invitations (
+						id BIGSERIAL PRIMARY KEY,
+						created_at TIMESTAMP WITH TIME ZONE,
+						updated_at TIMESTAMP WITH TIME ZONE,
+						deleted_at TIMESTAMP WITH TIME ZONE,
+						realm_id INTEGER NOT NULL REFERENCES realms(id) ON DELETE CASCADE,
+						email TEXT NOT NULL,
+						token_hmac TEXT NOT NULL,
+						expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+						submitted_at TIMESTAMP WITH TIME ZONE,
+						approved_at TIMESTAMP WITH TIME ZONE
+					)`,
+					`CREATE UNIQUE INDEX IF NOT EXISTS uix_realm_invitations_token_hmac ON realm_invitations (token_hmac)`,
+					`CREATE INDEX IF NOT EXISTS idx_realm_invitations_realm_id ON realm_invitations (realm_id)`,
+				)
+			},
+			Rollback: func(tx *gorm.DB) error {
+				return multiExec(tx,
+					`DROP TABLE IF EXISTS realm_invitations`,
+				)
+			},
+		},
+		{
+			ID: "00159-AddRealmAuditRetentionDays",
+			Migrate: func(tx *gorm.DB) error {
+				return multiExec(tx,
+					`ALTER TABLE realms ADD COLUMN IF NOT EXISTS audit_retention_days SMALLINT NOT NULL DEFAULT 0`,
+				)
+			},
+			Rollback: func(tx *gorm.DB) error {
+				return multiExec(tx,
+					`ALTER TABLE realms DROP COLUMN IF EXISTS audit_retention_days`,
+				)
+			},
+		},
+		{
+			ID: "00160-AddAuthorizedAppClientFingerprints",
+			Migrate: func(tx *gorm.DB) error {
+				return multiExec(tx,
+					`ALTER TABLE authorized_apps ADD COLUMN IF NOT EXISTS allowed_user_agents TEXT[]`,
+					`ALTER TABLE authorized_apps ADD COLUMN IF NOT EXISTS allowed_app_packages TEXT[]`,
+					`ALTER TABLE authorized_apps ADD COLUMN IF NOT EXISTS enforce_client_fingerprint BOOL NOT NULL DEFAULT false`,
+				)
+			},
+			Rollback: func(tx *gorm.DB) error {
+				return multiExec(tx,
+					`ALTER TABLE authorized_apps DROP COLUMN IF EXISTS allowed_user_agents`,
+					`ALTER TABLE authorized_apps DROP COLUMN IF EXISTS allowed_app_packages`,
+					`ALTER TABLE authorized_apps DROP COLUMN IF EXISTS enforce_client_fingerprint`,
+				)
+			},
+		},
+		{
+			ID: "00161-AddUserImports",
+			Migrate: func(tx *gorm.DB) error {
+				return multiExec(tx,
+					`CREATE TABLE IF NOT EXISTS user_imports (
+						id BIGSERIAL PRIMARY KEY,
+						realm_id INTEGER NOT NULL REFERENCES realms(id) ON DELETE CASCADE,
+						authorized_app_id INTEGER NOT NULL,
+						status TEXT NOT NULL DEFAULT 'pending',
+						entries TEXT NOT NULL,
+						created INTEGER NOT NULL DEFAULT 0,
+						updated INTEGER NOT NULL DEFAULT 0,
+						failed INTEGER NOT NULL DEFAULT 0,
+						failures TEXT,
+						error TEXT,
+						completed_at TIMESTAMPTZ,
+						created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+						updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+					)`,
+					`CREATE INDEX IF NOT EXISTS idx_user_imports_realm_id ON user_imports (realm_id)`,
+					`CREATE INDEX IF NOT EXISTS idx_user_imports_status ON user_imports (status)`,
+				)
+			},
+			Rollback: func(tx *gorm.DB) error {
+				return multiExec(tx,
+					`DROP TABLE IF EXISTS user_imports`,
+				)
+			},
+		},
+		{
+			ID: "00162-AddExternalCaseIDs",
+			Migrate: func(tx *gorm.DB) error {
+				return multiExec(tx,
+					`ALTER TABLE realms ADD COLUMN IF NOT EXISTS external_case_id_pattern TEXT`,
+					`ALTER TABLE realms ADD COLUMN IF NOT EXISTS require_external_case_id BOOL NOT NULL DEFAULT false`,
+					`ALTER TABLE verification_codes ADD COLUMN IF NOT EXISTS external_case_id TEXT`,
+				)
+			},
+			Rollback: func(tx *gorm.DB) error {
+				return multiExec(tx,
+					`ALTER TABLE realms DROP COLUMN IF EXISTS external_case_id_pattern`,
+					`ALTER TABLE realms DROP COLUMN IF EXISTS require_external_case_id`,
+					`ALTER TABLE verification_codes DROP COLUMN IF EXISTS external_case_id`,
+				)
+			},
+		},
+		{
+			ID: "00163-AddDailyIssuerLimits",
+			Migrate: func(tx *gorm.DB) error {
+				return multiExec(tx,
+					`ALTER TABLE realms ADD COLUMN IF NOT EXISTS daily_user_issue_limit INTEGER NOT NULL DEFAULT 0`,
+					`ALTER TABLE realms ADD COLUMN IF NOT EXISTS daily_api_key_issue_limit INTEGER NOT NULL DEFAULT 0`,
+				)
+			},
+			Rollback: func(tx *gorm.DB) error {
+				return multiExec(tx,
+					`ALTER TABLE realms DROP COLUMN IF EXISTS daily_user_issue_limit`,
+					`ALTER TABLE realms DROP COLUMN IF EXISTS daily_api_key_issue_limit`,
+				)
+			},
+		},
+		{
+			ID: "00164-AddScheduler",
+			Migrate: func(tx *gorm.DB) error {
+				return multiExec(tx,
+					`CREATE TABLE IF NOT EXISTS scheduler_leases (
+						name TEXT PRIMARY KEY,
+						holder TEXT NOT NULL,
+						expires_at TIMESTAMP WITH TIME ZONE NOT NULL
+					)`,
+					`CREATE TABLE IF NOT EXISTS scheduler_runs (
+						id BIGSERIAL PRIMARY KEY,
+						job TEXT NOT NULL,
+						leader TEXT NOT NULL,
+						started_at TIMESTAMP WITH TIME ZONE NOT NULL,
+						finished_at TIMESTAMP WITH TIME ZONE NOT NULL,
+						next_run_at TIMESTAMP WITH TIME ZONE NOT NULL,
+						status_code INTEGER NOT NULL DEFAULT 0,
+						error TEXT
+					)`,
+					`CREATE INDEX IF NOT EXISTS idx_scheduler_runs_job_started_at ON scheduler_runs (job, started_at DESC)`,
+				)
+			},
+			Rollback: func(tx *gorm.DB) error {
+				return multiExec(tx,
+					`DROP TABLE IF EXISTS scheduler_runs`,
+					`DROP TABLE IF EXISTS scheduler_leases`,
+				)
+			},
+		},
+		{
+			ID: "00165-AddIssuerSLOStats",
+			Migrate: func(tx *gorm.DB) error {
+				return multiExec(tx,
+					`ALTER TABLE user_stats ADD COLUMN IF NOT EXISTS codes_failed INTEGER NOT NULL DEFAULT 0`,
+					`ALTER TABLE user_stats ADD COLUMN IF NOT EXISTS issue_requests INTEGER NOT NULL DEFAULT 0`,
+					`ALTER TABLE user_stats ADD COLUMN IF NOT EXISTS issue_latency_ms BIGINT NOT NULL DEFAULT 0`,
+					`ALTER TABLE authorized_app_stats ADD COLUMN IF NOT EXISTS codes_failed INTEGER NOT NULL DEFAULT 0`,
+					`ALTER TABLE authorized_app_stats ADD COLUMN IF NOT EXISTS issue_requests INTEGER NOT NULL DEFAULT 0`,
+					`ALTER TABLE authorized_app_stats ADD COLUMN IF NOT EXISTS issue_latency_ms BIGINT NOT NULL DEFAULT 0`,
+				)
+			},
+			Rollback: func(tx *gorm.DB) error {
+				return multiExec(tx,
+					`ALTER TABLE user_stats DROP COLUMN IF EXISTS codes_failed`,
+					`ALTER TABLE user_stats DROP COLUMN IF EXISTS issue_requests`,
+					`ALTER TABLE user_stats DROP COLUMN IF EXISTS issue_latency_ms`,
+					`ALTER TABLE authorized_app_stats DROP COLUMN IF EXISTS codes_failed`,
+					`ALTER TABLE authorized_app_stats DROP COLUMN IF EXISTS issue_requests`,
+					`ALTER TABLE authorized_app_stats DROP COLUMN IF EXISTS issue_latency_ms`,
+				)
+			},
+		},
+		{
+			ID: "00166-AddUserReportPurgeReceipts",
+			Migrate: func(tx *gorm.DB) error {
+				return multiExec(tx,
+					`CREATE TABLE IF NOT EXISTS user_report_purge_receipts (
+						id BIGSERIAL,
+						receipt_id UUID NOT NULL,
+						realm_id INTEGER NOT NULL REFERENCES realms(id) ON DELETE CASCADE,
+						authorized_app_id INTEGER NOT NULL,
+						codes_expired INTEGER NOT NULL DEFAULT 0,
+						consents_unlinked INTEGER NOT NULL DEFAULT 0,
+						purged_at TIMESTAMP WITH TIME ZONE NOT NULL,
+						PRIMARY KEY (id)
+					)`,
+					`CREATE UNIQUE INDEX IF NOT EXISTS uix_user_report_purge_receipts_receipt_id ON user_report_purge_receipts (receipt_id)`,
+				)
+			},
+			Rollback: func(tx *gorm.DB) error {
+				return multiExec(tx,
+					`DROP TABLE IF EXISTS user_report_purge_receipts`,
+				)
+			},
+		},
+		{
+			ID: "00167-AddRealmAPIRateLimits",
+			Migrate: func(tx *gorm.DB) error {
+				return multiExec(tx,
+					`ALTER TABLE realms ADD COLUMN IF NOT EXISTS api_rate_limit_key_mode SMALLINT NOT NULL DEFAULT 0`,
+					`ALTER TABLE realms ADD COLUMN IF NOT EXISTS api_rate_limit_burst INTEGER NOT NULL DEFAULT 0`,
+					`ALTER TABLE realms ADD COLUMN IF NOT EXISTS api_rate_limit_ban_duration BIGINT NOT NULL DEFAULT 0`,
+				)
+			},
+			Rollback: func(tx *gorm.DB) error {
+				return multiExec(tx,
+					`ALTER TABLE realms DROP COLUMN IF EXISTS api_rate_limit_key_mode`,
+					`ALTER TABLE realms DROP COLUMN IF EXISTS api_rate_limit_burst`,
+					`ALTER TABLE realms DROP COLUMN IF EXISTS api_rate_limit_ban_duration`,
+				)
+			},
+		},
+		{
+			ID: "00168-AddLocalizedEmailTemplates",
+			Migrate: func(tx *gorm.DB) error {
+				return multiExec(tx,
+					`ALTER TABLE realms ADD COLUMN IF NOT EXISTS email_invite_templates hstore`,
+					`ALTER TABLE realms ADD COLUMN IF NOT EXISTS email_password_reset_templates hstore`,
+					`ALTER TABLE realms ADD COLUMN IF NOT EXISTS email_verify_templates hstore`,
+					`ALTER TABLE users ADD COLUMN IF NOT EXISTS preferred_language TEXT`,
+				)
+			},
+			Rollback: func(tx *gorm.DB) error {
+				return multiExec(tx,
+					`ALTER TABLE realms DROP COLUMN IF EXISTS email_invite_templates`,
+					`ALTER TABLE realms DROP COLUMN IF EXISTS email_password_reset_templates`,
+					`ALTER TABLE realms DROP COLUMN IF EXISTS email_verify_templates`,
+					`ALTER TABLE users DROP COLUMN IF EXISTS preferred_language`,
+				)
+			},
+		},
+		{
+			ID: "00169-AddRealmDataRetention",
+			Migrate: func(tx *gorm.DB) error {
+				return multiExec(tx,
+					`ALTER TABLE realms ADD COLUMN IF NOT EXISTS verification_code_retention_days SMALLINT NOT NULL DEFAULT 0`,
+					`ALTER TABLE realms ADD COLUMN IF NOT EXISTS token_retention_days SMALLINT NOT NULL DEFAULT 0`,
+					`ALTER TABLE realms ADD COLUMN IF NOT EXISTS stats_retention_days SMALLINT NOT NULL DEFAULT 0`,
+					`ALTER TABLE realms ADD COLUMN IF NOT EXISTS user_report_retention_days SMALLINT NOT NULL DEFAULT 0`,
+					`ALTER TABLE user_reports ADD COLUMN IF NOT EXISTS realm_id INTEGER NOT NULL DEFAULT 0`,
+				)
+			},
+			Rollback: func(tx *gorm.DB) error {
+				return multiExec(tx,
+					`ALTER TABLE realms DROP COLUMN IF EXISTS verification_code_retention_days`,
+					`ALTER TABLE realms DROP COLUMN IF EXISTS token_retention_days`,
+					`ALTER TABLE realms DROP COLUMN IF EXISTS stats_retention_days`,
+					`ALTER TABLE realms DROP COLUMN IF EXISTS user_report_retention_days`,
+					`ALTER TABLE user_reports DROP COLUMN IF EXISTS realm_id`,
+				)
+			},
+		},
+		{
+			ID: "00170-AddSystemEvents",
+			Migrate: func(tx *gorm.DB) error {
+				return multiExec(tx,
+					`CREATE TABLE IF NOT EXISTS system_events (
+						id BIGSERIAL PRIMARY KEY,
+						kind TEXT NOT NULL,
+						source TEXT NOT NULL,
+						realm_id INTEGER NOT NULL DEFAULT 0,
+						message TEXT NOT NULL,
+						failed BOOL NOT NULL DEFAULT FALSE,
+						created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+					)`,
+					`CREATE INDEX IF NOT EXISTS idx_system_events_created_at ON system_events (created_at)`,
+					`CREATE INDEX IF NOT EXISTS idx_system_events_kind_created_at ON system_events (kind, created_at)`,
+					`CREATE UNIQUE INDEX IF NOT EXISTS uix_system_events_builds ON system_events (source, message) WHERE kind = 'build'`,
+				)
+			},
+			Rollback: func(tx *gorm.DB) error {
+				return multiExec(tx,
+					`DROP TABLE IF EXISTS system_events`,
+				)
+			},
+		},
+		{
+			ID: "00171-AddVerificationCodeHMACKeyID",
+			Migrate: func(tx *gorm.DB) error {
+				return multiExec(tx,
+					`ALTER TABLE verification_codes ADD COLUMN IF NOT EXISTS hmac_key_id TEXT`,
+					`CREATE INDEX IF NOT EXISTS idx_verification_codes_unclaimed_hmac_key_id ON verification_codes (hmac_key_id) WHERE claimed IS FALSE`,
+				)
+			},
+			Rollback: func(tx *gorm.DB) error {
+				return multiExec(tx,
+					`DROP INDEX IF EXISTS idx_verification_codes_unclaimed_hmac_key_id`,
+					`ALTER TABLE verification_codes DROP COLUMN IF EXISTS hmac_key_id`,
+				)
+			},
+		},
+		{
+			ID: "00172-AddKeyServerStatsDaySource",
+			Migrate: func(tx *gorm.DB) error {
+				return multiExec(tx,
+					`ALTER TABLE key_server_stats_days ADD COLUMN IF NOT EXISTS source TEXT NOT NULL DEFAULT 'primary'`,
+					`ALTER TABLE key_server_stats_days DROP CONSTRAINT IF EXISTS key_server_stats_days_pkey`,
+					`ALTER TABLE key_server_stats_days ADD PRIMARY KEY (realm_id, source, day)`,
+				)
+			},
+			Rollback: func(tx *gorm.DB) error {
+				return multiExec(tx,
+					`DELETE FROM key_server_stats_days WHERE source != 'primary'`,
+					`ALTER TABLE key_server_stats_days DROP CONSTRAINT IF EXISTS key_server_stats_days_pkey`,
+					`ALTER TABLE key_server_stats_days ADD PRIMARY KEY (realm_id, day)`,
+					`ALTER TABLE key_server_stats_days DROP COLUMN IF EXISTS source`,
+				)
+			},
+		},
+		{
+			ID: "00173-AddAPIKeyInactivity",
+			Migrate: func(tx *gorm.DB) error {
+				return multiExec(tx,
+					`ALTER TABLE realms ADD COLUMN IF NOT EXISTS api_key_inactivity_days SMALLINT NOT NULL DEFAULT 0`,
+					`ALTER TABLE authorized_apps ADD COLUMN IF NOT EXISTS inactive_flagged_at TIMESTAMP WITH TIME ZONE`,
+					`ALTER TABLE authorized_apps ADD COLUMN IF NOT EXISTS disabled_for_inactivity_at TIMESTAMP WITH TIME ZONE`,
+					`CREATE TABLE IF NOT EXISTS api_key_inactivity_events (
+						id BIGSERIAL PRIMARY KEY,
+						realm_id INTEGER NOT NULL REFERENCES realms(id) ON DELETE CASCADE,
+						authorized_app_id INTEGER NOT NULL,
+						name TEXT NOT NULL,
+						action TEXT NOT NULL,
+						recipients TEXT[],
+						notified_at TIMESTAMP WITH TIME ZONE,
+						created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+					)`,
+					`CREATE INDEX IF NOT EXISTS idx_api_key_inactivity_events_pending ON api_key_inactivity_events(realm_id) WHERE notified_at IS NULL`,
+				)
+			},
+			Rollback: func(tx *gorm.DB) error {
+				return multiExec(tx,
+					`DROP TABLE IF EXISTS api_key_inactivity_events`,
+					`ALTER TABLE authorized_apps DROP COLUMN IF EXISTS disabled_for_inactivity_at`,
+					`ALTER TABLE authorized_apps DROP COLUMN IF EXISTS inactive_flagged_at`,
+					`ALTER TABLE realms DROP COLUMN IF EXISTS api_key_inactivity_days`,
+				)
+			},
+		},
+		{
+			ID: "00174-AddRealmNotifications",
+			Migrate: func(tx *gorm.DB) error {
+				return multiExec(tx,
+					`ALTER TABLE realms ADD COLUMN IF NOT EXISTS notification_phone_numbers TEXT[] NOT NULL DEFAULT '{}'`,
+					`CREATE TABLE IF NOT EXISTS realm_notifications (
+						id BIGSERIAL PRIMARY KEY,
+						realm_id INTEGER NOT NULL REFERENCES realms(id) ON DELETE CASCADE,
+						kind TEXT NOT NULL,
+						dedupe_key TEXT NOT NULL DEFAULT '',
+						title TEXT NOT NULL,
+						body TEXT NOT NULL,
+						link TEXT NOT NULL DEFAULT '',
+						send_email BOOL NOT NULL DEFAULT FALSE,
+						send_sms BOOL NOT NULL DEFAULT FALSE,
+						fanout_recipients TEXT[],
+						fanned_out_at TIMESTAMP WITH TIME ZONE,
+						created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+					)`,
+					`CREATE INDEX IF NOT EXISTS idx_realm_notifications_realm_created_at ON realm_notifications (realm_id, created_at)`,
+					`CREATE UNIQUE INDEX IF NOT EXISTS uix_realm_notifications_dedupe ON realm_notifications (realm_id, kind, dedupe_key) WHERE dedupe_key != ''`,
+					`CREATE INDEX IF NOT EXISTS idx_realm_notifications_pending_fanout ON realm_notifications (realm_id) WHERE fanned_out_at IS NULL AND (send_email OR send_sms)`,
+					`CREATE TABLE IF NOT EXISTS realm_notification_reads (
+						notification_id BIGINT NOT NULL REFERENCES realm_notifications(id) ON DELETE CASCADE,
+						user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+						read_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+						PRIMARY KEY (notification_id, user_id)
+					)`,
+				)
+			},
+			Rollback: func(tx *gorm.DB) error {
+				return multiExec(tx,
+					`DROP TABLE IF EXISTS realm_notification_reads`,
+					`DROP TABLE IF EXISTS realm_notifications`,
+					`ALTER TABLE realms DROP COLUMN IF EXISTS notification_phone_numbers`,
+				)
+			},
+		},
+		{
+			ID: "00175-AddSMSSuppressions",
+			Migrate: func(tx *gorm.DB) error {
+				return multiExec(tx,
+					`CREATE TABLE IF NOT EXISTS sms_suppressions (
+						id BIGSERIAL PRIMARY KEY,
+						realm_id INTEGER NOT NULL REFERENCES realms(id) ON DELETE CASCADE,
+						phone_hash TEXT NOT NULL,
+						source TEXT NOT NULL,
+						created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+					)`,
+					`CREATE UNIQUE INDEX IF NOT EXISTS uix_sms_suppressions_realm_phone_hash ON sms_suppressions (realm_id, phone_hash)`,
+					`ALTER TABLE realm_stats ADD COLUMN IF NOT EXISTS sms_suppressed INTEGER NOT NULL DEFAULT 0`,
+				)
+			},
+			Rollback: func(tx *gorm.DB) error {
+				return multiExec(tx,
+					`ALTER TABLE realm_stats DROP COLUMN IF EXISTS sms_suppressed`,
+					`DROP TABLE IF EXISTS sms_suppressions`,
+				)
+			},
+		},
+		{
+			ID: "00176-AddVerificationCodesResendCount",
+			Migrate: func(tx *gorm.DB) error {
+				return multiExec(tx,
+					`ALTER TABLE verification_codes ADD COLUMN IF NOT EXISTS resend_count INTEGER NOT NULL DEFAULT 0`,
+				)
+			},
+			Rollback: func(tx *gorm.DB) error {
+				return multiExec(tx,
+					`ALTER TABLE verification_codes DROP COLUMN IF EXISTS resend_count`,
+				)
+			},
+		},
+		{
+			ID: "00177-AddRealmsEnableStatusPage",
+			Migrate: func(tx *gorm.DB) error {
+				return multiExec(tx,
+					`ALTER TABLE realms ADD COLUMN IF NOT EXISTS enable_status_page BOOL NOT NULL DEFAULT FALSE`,
+				)
+			},
+			Rollback: func(tx *gorm.DB) error {
+				return multiExec(tx,
+					`ALTER TABLE realms DROP COLUMN IF EXISTS enable_status_page`,
+				)
+			},
+		},
+		{
+			ID: "00178-AddUnclaimedCodesReport",
+			Migrate: func(tx *gorm.DB) error {
+				return multiExec(tx,
+					`ALTER TABLE users ADD COLUMN IF NOT EXISTS unclaimed_codes_report BOOL NOT NULL DEFAULT FALSE`,
+					`ALTER TABLE authorized_apps ADD COLUMN IF NOT EXISTS unclaimed_codes_report BOOL NOT NULL DEFAULT FALSE`,
+					`CREATE INDEX IF NOT EXISTS idx_vercode_unclaimed_created_at ON verification_codes (created_at) WHERE claimed = FALSE`,
+				)
+			},
+			Rollback: func(tx *gorm.DB) error {
+				return multiExec(tx,
+					`DROP INDEX IF EXISTS idx_vercode_unclaimed_created_at`,
+					`ALTER TABLE authorized_apps DROP COLUMN IF EXISTS unclaimed_codes_report`,
+					`ALTER TABLE users DROP COLUMN IF EXISTS unclaimed_codes_report`,
+				)
+			},
+		},
+		{
+			ID: "00179-AddBreakGlassActivations",
+			Migrate: func(tx *gorm.DB) error {
+				return multiExec(tx,
+					`ALTER TABLE users ADD COLUMN IF NOT EXISTS break_glass BOOL NOT NULL DEFAULT FALSE`,
+					`ALTER TABLE users ADD COLUMN IF NOT EXISTS break_glass_expires_at TIMESTAMP WITH TIME ZONE`,
+					`CREATE TABLE IF NOT EXISTS break_glass_activations (
+						id BIGSERIAL PRIMARY KEY,
+						user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+						requested_by_id INTEGER NOT NULL,
+						reason TEXT NOT NULL,
+						approved_by_id INTEGER,
+						approved_at TIMESTAMP WITH TIME ZONE,
+						expires_at TIMESTAMP WITH TIME ZONE,
+						revoked_by_id INTEGER,
+						revoked_at TIMESTAMP WITH TIME ZONE,
+						expiry_recorded_at TIMESTAMP WITH TIME ZONE,
+						created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+						updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+					)`,
+					`CREATE INDEX IF NOT EXISTS idx_break_glass_activations_user_created_at ON break_glass_activations (user_id, created_at)`,
+					`CREATE INDEX IF NOT EXISTS idx_break_glass_activations_pending_expiry ON break_glass_activations (expires_at) WHERE approved_at IS NOT NULL AND revoked_at IS NULL AND expiry_recorded_at IS NULL`,
+				)
+			},
+			Rollback: func(tx *gorm.DB) error {
+				return multiExec(tx,
+					`DROP TABLE IF EXISTS break_glass_activations`,
+					`ALTER TABLE users DROP COLUMN IF EXISTS break_glass_expires_at`,
+					`ALTER TABLE users DROP COLUMN IF EXISTS break_glass`,
+				)
+			},
+		},
+		{
+			ID: "00180-AddAuthorizedAppKeyRotation",
+			Migrate: func(tx *gorm.DB) error {
+				return multiExec(tx,
+					`ALTER TABLE authorized_apps ADD COLUMN IF NOT EXISTS previous_api_key VARCHAR(512)`,
+					`ALTER TABLE authorized_apps ADD COLUMN IF NOT EXISTS previous_api_key_preview VARCHAR(32)`,
+					`ALTER TABLE authorized_apps ADD COLUMN IF NOT EXISTS previous_api_key_expires_at TIMESTAMP WITH TIME ZONE`,
+					`ALTER TABLE authorized_apps ADD COLUMN IF NOT EXISTS api_key_rotated_at TIMESTAMP WITH TIME ZONE`,
+					`CREATE UNIQUE INDEX IF NOT EXISTS uix_authorized_apps_previous_api_key ON authorized_apps (previous_api_key) WHERE previous_api_key IS NOT NULL`,
+				)
+			},
+			Rollback: func(tx *gorm.DB) error {
+				return multiExec(tx,
+					`DROP INDEX IF EXISTS uix_authorized_apps_previous_api_key`,
+					`ALTER TABLE authorized_apps DROP COLUMN IF EXISTS api_key_rotated_at`,
+					`ALTER TABLE authorized_apps DROP COLUMN IF EXISTS previous_api_key_expires_at`,
+					`ALTER TABLE authorized_apps DROP COLUMN IF EXISTS previous_api_key_preview`,
+					`ALTER TABLE authorized_apps DROP COLUMN IF EXISTS previous_api_key`,
+				)
+			},
+		},
+		{
+			ID: "00181-AddRealmStatsTimezone",
+			Migrate: func(tx *gorm.DB) error {
+				// Existing realms keep UTC so historical buckets are unchanged.
+				return multiExec(tx,
+					`ALTER TABLE realms ADD COLUMN IF NOT EXISTS stats_timezone TEXT NOT NULL DEFAULT 'UTC'`,
+				)
+			},
+			Rollback: func(tx *gorm.DB) error {
+				return multiExec(tx,
+					`ALTER TABLE realms DROP COLUMN IF EXISTS stats_timezone`,
+				)
+			},
+		},
+		{
+			ID: "00182-AddSigningKeyMigration",
+			Migrate: func(tx *gorm.DB) error {
+				return multiExec(tx,
+					`ALTER TABLE signing_keys ADD COLUMN IF NOT EXISTS public_key TEXT`,
+					`ALTER TABLE signing_keys ADD COLUMN IF NOT EXISTS destroy_after TIMESTAMP WITH TIME ZONE`,
+					`ALTER TABLE sms_signing_keys ADD COLUMN IF NOT EXISTS public_key TEXT`,
+					`ALTER TABLE sms_signing_keys ADD COLUMN IF NOT EXISTS destroy_after TIMESTAMP WITH TIME ZONE`,
+				)
+			},
+			Rollback: func(tx *gorm.DB) error {
+				return multiExec(tx,
+					`ALTER TABLE sms_signing_keys DROP COLUMN IF EXISTS destroy_after`,
+					`ALTER TABLE sms_signing_keys DROP COLUMN IF EXISTS public_key`,
+					`ALTER TABLE signing_keys DROP COLUMN IF EXISTS destroy_after`,
+					`ALTER TABLE signing_keys DROP COLUMN IF EXISTS public_key`,
+				)
+			},
+		},
+		{
+			ID: "00183-AddVerificationCodeSearchIndex",
+			Migrate: func(tx *gorm.DB) error {
+				return multiExec(tx,
+					`CREATE INDEX IF NOT EXISTS idx_verification_codes_realm_id_id ON verification_codes (realm_id, id DESC)`,
+				)
+			},
+			Rollback: func(tx *gorm.DB) error {
+				return multiExec(tx,
+					`DROP INDEX IF EXISTS idx_verification_codes_realm_id_id`,
+				)
+			},
+		},
+		{
+			ID: "00184-AddAPICaptures",
+			Migrate: func(tx *gorm.DB) error {
+				return multiExec(tx,
+					`ALTER TABLE authorized_apps ADD COLUMN IF NOT EXISTS capture_sample_rate INTEGER NOT NULL DEFAULT 0`,
+					`ALTER TABLE authorized_apps ADD COLUMN IF NOT EXISTS capture_until TIMESTAMP WITH TIME ZONE`,
+					`CREATE TABLE IF NOT EXISTS api_captures (
+						id BIGSERIAL PRIMARY KEY,
+						realm_id INTEGER NOT NULL REFERENCES realms(id) ON DELETE CASCADE,
+						authorized_app_id INTEGER NOT NULL REFERENCES authorized_apps(id) ON DELETE CASCADE,
+						request_id TEXT,
+						method TEXT NOT NULL,
+						path TEXT NOT NULL,
+						request_headers TEXT,
+						response_headers TEXT,
+						request_body TEXT,
+						response_body TEXT,
+						response_status INTEGER NOT NULL DEFAULT 0,
+						duration_ms INTEGER NOT NULL DEFAULT 0,
+						created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+					)`,
+					`CREATE INDEX IF NOT EXISTS idx_api_captures_realm_id_created_at ON api_captures (realm_id, created_at)`,
+					`CREATE INDEX IF NOT EXISTS idx_api_captures_authorized_app_id ON api_captures (authorized_app_id)`,
+				)
+			},
+			Rollback: func(tx *gorm.DB) error {
+				return multiExec(tx,
+					`DROP TABLE IF EXISTS api_captures`,
+					`ALTER TABLE authorized_apps DROP COLUMN IF EXISTS capture_until`,
+					`ALTER TABLE authorized_apps DROP COLUMN IF EXISTS capture_sample_rate`,
+				)
+			},
+		},
+		{
+			ID: "00185-AddRealmLocalizations",
+			Migrate: func(tx *gorm.DB) error {
+				return multiExec(tx,
+					`ALTER TABLE realms ADD COLUMN IF NOT EXISTS sms_text_localized_templates HSTORE`,
+					`ALTER TABLE realms ADD COLUMN IF NOT EXISTS localized_web_strings HSTORE`,
+				)
+			},
+			Rollback: func(tx *gorm.DB) error {
+				return multiExec(tx,
+					`ALTER TABLE realms DROP COLUMN IF EXISTS sms_text_localized_templates`,
+					`ALTER TABLE realms DROP COLUMN IF EXISTS localized_web_strings`,
+				)
+			},
+		},
+		{
+			ID: "00186-AddRealmENXLinkParams",
+			Migrate: func(tx *gorm.DB) error {
+				return multiExec(tx,
+					`ALTER TABLE realms ADD COLUMN IF NOT EXISTS enx_link_params HSTORE`,
+				)
+			},
+			Rollback: func(tx *gorm.DB) error {
+				return multiExec(tx,
+					`ALTER TABLE realms DROP COLUMN IF EXISTS enx_link_params`,
+				)
+			},
+		},
+		{
+			ID: "00187-AddRealmImpersonations",
+			Migrate: func(tx *gorm.DB) error {
+				return multiExec(tx,
+					`CREATE TABLE IF NOT EXISTS realm_impersonations (
+						id BIGSERIAL PRIMARY KEY,
+						user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+						realm_id INTEGER NOT NULL REFERENCES realms(id) ON DELETE CASCADE,
+						permissions BIGINT NOT NULL,
+						reason TEXT NOT NULL,
+						expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+						ended_at TIMESTAMP WITH TIME ZONE,
+						created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+						updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+					)`,
+					`CREATE INDEX IF NOT EXISTS idx_realm_impersonations_user_realm ON realm_impersonations (user_id, realm_id)`,
+					`CREATE INDEX IF NOT EXISTS idx_realm_impersonations_expires_at ON realm_impersonations (expires_at)`,
+				)
+			},
+			Rollback: func(tx *gorm.DB) error {
+				return multiExec(tx,
+					`DROP TABLE IF EXISTS realm_impersonations`,
+				)
+			},
+		},
+		{
+			ID: "00188-AddRealmCertificateClaims",
+			Migrate: func(tx *gorm.DB) error {
+				return multiExec(tx,
+					`ALTER TABLE realms ADD COLUMN IF NOT EXISTS allowed_certificate_claims TEXT[]`,
+					`ALTER TABLE realms ADD COLUMN IF NOT EXISTS certificate_claims HSTORE`,
+				)
+			},
+			Rollback: func(tx *gorm.DB) error {
+				return multiExec(tx,
+					`ALTER TABLE realms DROP COLUMN IF EXISTS allowed_certificate_claims`,
+					`ALTER TABLE realms DROP COLUMN IF EXISTS certificate_claims`,
+				)
+			},
+		},
+		{
+			ID: "00189-AddBulkIssueJobs",
+			Migrate: func(tx *gorm.DB) error {
+				return multiExec(tx,
+					`CREATE TABLE IF NOT EXISTS bulk_issue_jobs (
+						id BIGSERIAL PRIMARY KEY,
+						realm_id INTEGER NOT NULL REFERENCES realms(id) ON DELETE CASCADE,
+						user_id INTEGER NOT NULL,
+						filename TEXT NOT NULL DEFAULT '',
+						sms_template_label TEXT NOT NULL DEFAULT '',
+						tz_offset REAL NOT NULL DEFAULT 0,
+						status TEXT NOT NULL DEFAULT 'pending',
+						total INTEGER NOT NULL DEFAULT 0,
+						issued INTEGER NOT NULL DEFAULT 0,
+						failed INTEGER NOT NULL DEFAULT 0,
+						rows TEXT NOT NULL,
+						error TEXT,
+						completed_at TIMESTAMPTZ,
+						created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+						updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+					)`,
+					`CREATE INDEX IF NOT EXISTS idx_bulk_issue_jobs_realm_id ON bulk_issue_jobs (realm_id)`,
+					`CREATE INDEX IF NOT EXISTS idx_bulk_issue_jobs_status ON bulk_issue_jobs (status)`,
+					`CREATE INDEX IF NOT EXISTS idx_bulk_issue_jobs_created_at ON bulk_issue_jobs (created_at)`,
+				)
+			},
+			Rollback: func(tx *gorm.DB) error {
+				return multiExec(tx,
+					`DROP TABLE IF EXISTS bulk_issue_jobs`,
+				)
+			},
+		},
+		{
+			ID: "00190-RedactSensitiveAuditDiffs",
+			Migrate: func(tx *gorm.DB) error {
+				// Scrub the values of redacted fields from audit diffs that were
+				// saved before redaction was added.
+				return redactAuditEntries(tx)
+			},
+			Rollback: func(tx *gorm.DB) error {
+				// The values cannot be restored.
+				return nil
+			},
+		},
 	}
 }
 
@@ -30,11 +30,14 @@ import (
 	"strings"
 	"time"
 
+	"github.com/google/exposure-notifications-server/pkg/keys"
 	"github.com/google/exposure-notifications-server/pkg/timeutils"
 	"github.com/google/exposure-notifications-verification-server/internal/project"
+	"github.com/google/exposure-notifications-verification-server/pkg/api"
 	"github.com/google/exposure-notifications-verification-server/pkg/cache"
 	"github.com/google/exposure-notifications-verification-server/pkg/digest"
 	"github.com/google/exposure-notifications-verification-server/pkg/email"
+	"github.com/google/exposure-notifications-verification-server/pkg/jwthelper"
 	"github.com/google/exposure-notifications-verification-server/pkg/pagination"
 	"github.com/google/exposure-notifications-verification-server/pkg/rbac"
 	"github.com/google/exposure-notifications-verification-server/pkg/sms"
@@ -81,6 +84,29 @@ func (t TestType) Display() string {
 	return strings.Join(types, ", ")
 }
 
+// RateLimitKeyMode determines how Device API requests are grouped when they
+// are rate limited.
+type RateLimitKeyMode int16
+
+const (
+	// RateLimitKeyModeIP rate limits requests by the realm and client IP
+	// address.
+	RateLimitKeyModeIP RateLimitKeyMode = iota
+	// RateLimitKeyModeAPIKey rate limits requests by API key, regardless of the
+	// client IP address.
+	RateLimitKeyModeAPIKey
+)
+
+func (m RateLimitKeyMode) String() string {
+	switch m {
+	case RateLimitKeyModeIP:
+		return "ip"
+	case RateLimitKeyModeAPIKey:
+		return "api-key"
+	}
+	return ""
+}
+
 // AuthRequirement represents authentication requirements for the realm
 type AuthRequirement int16
 
@@ -130,6 +156,12 @@ var (
 
 	colorRegex = regexp.MustCompile(`\A#[0-9a-f]{6}\z`)
 
+	// hostnameRegex matches a lowercase hostname with at least two labels.
+	hostnameRegex = regexp.MustCompile(`\A([a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?\.)+[a-z]([a-z0-9-]{0,61}[a-z0-9])?\z`)
+
+	// enxLinkParamKeyRegex matches the allowed names of ENX link parameters.
+	enxLinkParamKeyRegex = regexp.MustCompile(`\A[A-Za-z][A-Za-z0-9_-]{0,31}\z`)
+
 	smsMultipleSpaceRegex = regexp.MustCompile(`[\s]+`)
 	smsNewlineRegex       = regexp.MustCompile(`[\n|\r]`)
 )
@@ -144,6 +176,33 @@ const (
 	DefaultSMSRegion                  = "us"
 	DefaultLanguage                   = "en"
 
+	// MinAuditRetentionDays and MaxAuditRetentionDays bound a realm's audit
+	// retention period. The minimum matches the cleanup job's minimum audit
+	// entry max age.
+	MinAuditRetentionDays = 7
+	MaxAuditRetentionDays = 3650
+
+	// The bounds of a realm's retention period for the other data classes the
+	// cleanup job purges. Verification codes must be kept longer than they are
+	// recycled, and statistics for at least a month of reporting.
+	MinVerificationCodeRetentionDays = 3
+	MaxVerificationCodeRetentionDays = 90
+	MinTokenRetentionDays            = 1
+	MaxTokenRetentionDays            = 30
+	MinStatsRetentionDays            = 30
+	MaxStatsRetentionDays            = 3650
+	MinUserReportRetentionDays       = 1
+	MaxUserReportRetentionDays       = 365
+
+	// MaxAPIRateLimitBurst and MaxAPIRateLimitBanDuration bound the Device API
+	// rate limit overrides a realm may configure.
+	MaxAPIRateLimitBurst       = 100000
+	MaxAPIRateLimitBanDuration = 24 * time.Hour
+
+	// MaxAPIKeyInactivityDays bounds the API key inactivity period a realm may
+	// configure.
+	MaxAPIKeyInactivityDays = 365
+
 	SMSRegion        = "[region]"
 	SMSCode          = "[code]"
 	SMSExpires       = "[expires]"
@@ -154,6 +213,12 @@ const (
 	SMSTemplateMaxLength    = 800
 	SMSTemplateExpansionMax = 918
 
+	// ENXLinkParamsMax is the maximum number of ENX link parameters, and
+	// ENXLinkParamsMaxLength is the maximum length of their encoded query
+	// string.
+	ENXLinkParamsMax       = 5
+	ENXLinkParamsMaxLength = 100
+
 	DefaultTemplateLabel      = "Default SMS template"
 	DefaultSMSTextTemplate    = "This is your Exposure Notifications Verification code: [longcode] Expires in [longexpires] hours"
 	DefaultENXSMSTextTemplate = "Your Exposure Notifications verification link: [enslink] Expires in [longexpires] hours (click for mobile device only)"
@@ -191,6 +256,14 @@ type Realm struct {
 	RegionCode    string  `gorm:"-"`
 	RegionCodePtr *string `gorm:"column:region_code; type:varchar(10);"`
 
+	// CustomDomain is an optional hostname (e.g. verify.health.example.gov) on
+	// which the realm admin UI is served. Requests to the hostname always use
+	// this realm, so staff do not select their realm. Like RegionCode, the
+	// field is converted from its ptr type in callbacks to handle NULL and
+	// uniqueness. Do not modify CustomDomainPtr directly.
+	CustomDomain    string  `gorm:"-"`
+	CustomDomainPtr *string `gorm:"column:custom_domain; type:text;"`
+
 	// IsE2E returns true if this realm is the e2e realm, or false otherwise.
 	IsE2E bool `gorm:"column:is_e2e; type:boolean; default:false; not null;"`
 
@@ -201,7 +274,7 @@ type Realm struct {
 	WelcomeMessagePtr *string `gorm:"column:welcome_message; type:text;"`
 
 	// AgencyBackgroundColor, AgencyImage, DefaultLocale are synced from the Google
-	// ENX-Express sync source
+	// ENX-Express sync source, unless AgencyBrandingManaged is set.
 	AgencyBackgroundColor     string  `gorm:"-"`
 	AgencyBackgroundColorPtr  *string `gorm:"column:agency_background_color; type:text;"`
 	AgencyImage               string  `gorm:"-"`
@@ -211,14 +284,19 @@ type Realm struct {
 	UserReportLearnMoreURL    string  `gorm:"-"`
 	UserReportLearnMoreURLPtr *string `gorm:"column:user_report_learn_more_url; type:text;"`
 
+	// AgencyBrandingManaged is true when the agency branding was set through the
+	// realm branding API. The ENX-Express sync does not overwrite managed
+	// branding.
+	AgencyBrandingManaged bool `gorm:"column:agency_branding_managed; type:bool; not null; default:false;"`
+
 	// UserReportWebhookURL and UserReportWebhookSecret are used as callbacks for
 	// user reports.
 	UserReportWebhookURL                   string  `gorm:"-"`
 	UserReportWebhookURLPtr                *string `gorm:"column:user_report_webhook_url; type:text;"`
-	UserReportWebhookSecret                string  `gorm:"-" json:"-"`
-	UserReportWebhookSecretPtr             *string `gorm:"column:user_report_webhook_secret; type:text;" json:"-"`
-	UserReportWebhookSecretPlaintextCache  string  `gorm:"-"`
-	UserReportWebhookSecretCiphertextCache string  `gorm:"-"`
+	UserReportWebhookSecret                string  `gorm:"-" json:"-" audit:"redact"`
+	UserReportWebhookSecretPtr             *string `gorm:"column:user_report_webhook_secret; type:text;" json:"-" audit:"redact"`
+	UserReportWebhookSecretPlaintextCache  string  `gorm:"-" audit:"redact"`
+	UserReportWebhookSecretCiphertextCache string  `gorm:"-" audit:"redact"`
 
 	// AllowBulkUpload allows users to issue codes from a batch file of test results.
 	AllowBulkUpload bool `gorm:"type:boolean; not null; default:false;"`
@@ -229,6 +307,21 @@ type Realm struct {
 	LongCodeLength   uint            `gorm:"type:smallint; not null; default: 16;"`
 	LongCodeDuration DurationSeconds `gorm:"type:bigint; not null; default: 86400;"` // default 24h
 
+	// CodeCharset is the alphabet short codes are generated from. It is one of
+	// CodeCharsetNumeric or CodeCharsetAlphanumeric.
+	CodeCharset string `gorm:"column:code_charset; type:text; not null; default:'numeric';"`
+
+	// CodeGroupSize is the number of characters between separators when a short
+	// code is displayed (e.g. 4 for "ABCD-2345"). If 0, codes are not grouped.
+	CodeGroupSize uint `gorm:"column:code_group_size; type:smallint; not null; default:0;"`
+
+	// ExternalCaseIDPattern is an optional regular expression that external
+	// case IDs, such as lab accession numbers, must fully match when issuing
+	// codes. RequireExternalCaseID requires an external case ID on every issued
+	// code, except for user reports.
+	ExternalCaseIDPattern string `gorm:"column:external_case_id_pattern; type:text;"`
+	RequireExternalCaseID bool   `gorm:"column:require_external_case_id; type:bool; not null; default:false;"`
+
 	// ShortCodeMaxMinutes can only be set by system admins and allows for a
 	// realm to have a higher max short code duration
 	ShortCodeMaxMinutes uint `gorm:"column:short_code_max_minutes; type:smallint; not null; default: 60;"`
@@ -236,15 +329,48 @@ type Realm struct {
 	// for an ENX realm to change the short code expiration time (normally fixed)
 	ENXCodeExpirationConfigurable bool `gorm:"column:enx_code_expiration_configurable; type:bool; not null; default: false;"`
 
+	// AuditRetentionDays can only be set by system admins and is the number of
+	// days the realm's audit entries are kept before the cleanup job purges
+	// them. If 0, the system default applies.
+	AuditRetentionDays uint `gorm:"column:audit_retention_days; type:smallint; not null; default: 0;"`
+
+	// VerificationCodeRetentionDays, TokenRetentionDays, StatsRetentionDays, and
+	// UserReportRetentionDays can only be set by system admins and are the
+	// number of days the realm's verification codes, verification tokens,
+	// statistics, and claimed user report phone hashes are kept before the
+	// cleanup job purges them. If 0, the system default applies.
+	VerificationCodeRetentionDays uint `gorm:"column:verification_code_retention_days; type:smallint; not null; default: 0;"`
+	TokenRetentionDays            uint `gorm:"column:token_retention_days; type:smallint; not null; default: 0;"`
+	StatsRetentionDays            uint `gorm:"column:stats_retention_days; type:smallint; not null; default: 0;"`
+	UserReportRetentionDays       uint `gorm:"column:user_report_retention_days; type:smallint; not null; default: 0;"`
+
 	// SMS configuration
 	SMSTextTemplate           string          `gorm:"type:text; not null; default: 'This is your Exposure Notifications Verification code: [longcode] Expires in [longexpires] hours';"`
 	SMSTextAlternateTemplates postgres.Hstore `gorm:"column:alternate_sms_templates; type:hstore;"`
 
+	// SMSTextLocalizedTemplates are per-locale variants of the default and
+	// alternate SMS templates, keyed by LocalizedKey(locale, label).
+	SMSTextLocalizedTemplates postgres.Hstore `gorm:"column:sms_text_localized_templates; type:hstore;"`
+
+	// LocalizedWebStrings are realm-managed translations of the user report web
+	// view strings, keyed by LocalizedKey(locale, messageID). They take
+	// precedence over translations synced from the ENX-Express app config.
+	LocalizedWebStrings postgres.Hstore `gorm:"column:localized_web_strings; type:hstore;"`
+
 	// SMSCountry is an optional field to hint the default phone picker country
 	// code.
 	SMSCountry    string  `gorm:"-"`
 	SMSCountryPtr *string `gorm:"column:sms_country; type:varchar(5);"`
 
+	// SMSAllowedCountries is the list of lowercase ISO country codes to which
+	// SMS messages may be sent. Codes issued to phone numbers outside of this
+	// list are rejected. An empty list permits all countries.
+	SMSAllowedCountries pq.StringArray `gorm:"column:sms_allowed_countries; type:varchar(5)[];"`
+
+	// SMSAllowedCountriesWarnOnly causes numbers outside SMSAllowedCountries to
+	// be logged instead of rejected.
+	SMSAllowedCountriesWarnOnly bool `gorm:"column:sms_allowed_countries_warn_only; type:bool; not null; default:false;"`
+
 	// CanUseSystemSMSConfig is configured by system administrators to share the
 	// system SMS config with this realm. Note that the system SMS config could be
 	// empty and a local SMS config is preferred over the system value.
@@ -278,6 +404,14 @@ type Realm struct {
 	// EmailPasswordResetTemplate is the template for resetting password.
 	EmailPasswordResetTemplate string `gorm:"type:text;"`
 
+	// EmailInviteTemplates, EmailPasswordResetTemplates, and EmailVerifyTemplates
+	// are per-locale variants of the email templates, keyed by locale (e.g.
+	// "es" or "es-mx"). The variant that best matches the recipient's preferred
+	// language is used, falling back to the template above.
+	EmailInviteTemplates        postgres.Hstore `gorm:"column:email_invite_templates; type:hstore;"`
+	EmailPasswordResetTemplates postgres.Hstore `gorm:"column:email_password_reset_templates; type:hstore;"`
+	EmailVerifyTemplates        postgres.Hstore `gorm:"column:email_verify_templates; type:hstore;"`
+
 	// EmailVerifyTemplate is the template used for email verification.
 	EmailVerifyTemplate string `gorm:"type:text;"`
 
@@ -315,6 +449,32 @@ type Realm struct {
 	AllowedCIDRsAPIServer pq.StringArray `gorm:"column:allowed_cidrs_apiserver; type:varchar(50)[];"`
 	AllowedCIDRsServer    pq.StringArray `gorm:"column:allowed_cidrs_server; type:varchar(50)[];"`
 
+	// MinimumAppVersion is the oldest mobile app version, as reported in the
+	// app version header, that may use the device API. Apps that report an older
+	// version are told to upgrade. Apps that do not report a version are
+	// allowed. If blank, all versions are allowed.
+	MinimumAppVersion string `gorm:"column:minimum_app_version; type:text;"`
+
+	// APIRateLimitKeyMode determines whether Device API requests are rate
+	// limited per client IP address or per API key.
+	APIRateLimitKeyMode RateLimitKeyMode `gorm:"column:api_rate_limit_key_mode; type:smallint; not null; default: 0;"`
+
+	// APIRateLimitBurst is the number of Device API requests a single rate limit
+	// key may make in each rate limit interval. If 0, the server default is
+	// used.
+	APIRateLimitBurst uint `gorm:"column:api_rate_limit_burst; type:integer; not null; default: 0;"`
+
+	// APIRateLimitBanDuration is how long a rate limit key is rejected after it
+	// exceeds its limit. If 0, requests are allowed again as soon as the limit
+	// resets.
+	APIRateLimitBanDuration DurationSeconds `gorm:"column:api_rate_limit_ban_duration; type:bigint; not null; default: 0;"`
+
+	// APIKeyInactivityDays is the number of days an API key may go unused before
+	// it is flagged as inactive. Flagged keys that stay unused for
+	// APIKeyInactivityGracePeriod are automatically disabled. If 0, API keys are
+	// never flagged or disabled for inactivity.
+	APIKeyInactivityDays uint `gorm:"column:api_key_inactivity_days; type:smallint; not null; default: 0;"`
+
 	// AllowedTestTypes is the type of tests that this realm permits. The default
 	// value is to allow all test types.
 	AllowedTestTypes TestType `gorm:"type:smallint; not null; default: 14;"`
@@ -323,6 +483,11 @@ type Realm struct {
 	// on the redirect server for this realm. If disabled, it will 404.
 	AllowUserReportWebView bool `gorm:"column:allow_user_report_web_view; type:bool; not null; default:false"`
 
+	// UserReportConsentVersion is the current version of the consent text that
+	// self-reporters must accept. If 0, the realm has not published consent
+	// text and the default agreement is shown.
+	UserReportConsentVersion uint `gorm:"column:user_report_consent_version; type:integer; not null; default:0;"`
+
 	// AllowAdminUserReport - is the adminapi:/api/issue allowed to use the user-report
 	// test type if enabled on the realm.
 	AllowAdminUserReport bool `gorm:"column:allow_admin_user_report; type:bool; not null; default:false"`
@@ -338,9 +503,57 @@ type Realm struct {
 	CertificateDuration      DurationSeconds `gorm:"type:bigint; default: 900;"` // 15m
 	AutoRotateCertificateKey bool            `gorm:"type:boolean; default: false;"`
 
+	// CertificateSigningAlgorithm is the JWT algorithm used when creating new
+	// realm certificate signing keys. Existing keys continue to sign with the
+	// algorithm of their key type. See jwthelper.SupportedAlgorithms.
+	CertificateSigningAlgorithm string `gorm:"column:certificate_signing_algorithm; type:varchar(10); not null; default:'ES256';"`
+
+	// AllowedCertificateClaims are the names of additional verification
+	// certificate claims that a system administrator approved for this realm,
+	// for key servers that need more than the standard claims.
+	AllowedCertificateClaims pq.StringArray `gorm:"column:allowed_certificate_claims; type:text[];"`
+
+	// CertificateClaims are static claims added to every verification
+	// certificate the realm issues. Each name must be in
+	// AllowedCertificateClaims.
+	CertificateClaims postgres.Hstore `gorm:"column:certificate_claims; type:hstore;"`
+
+	// KeyServerID is the key server this realm uploads to, from the system
+	// admin managed list. If nil, the system default key server is used for
+	// statistics and certificate audiences.
+	KeyServerID *uint `gorm:"column:key_server_id; type:integer;"`
+
+	// StatsPrivacyMode controls how small counts are treated in statistics
+	// exported via the API (see RealmStats.WithPrivacy). Statistics viewed in
+	// the UI by realm members are never altered.
+	StatsPrivacyMode string `gorm:"column:stats_privacy_mode; type:varchar(10); not null; default:'off';"`
+
+	// StatsPrivacyThreshold is the count below which values are suppressed or
+	// noised. Zero values are never altered.
+	StatsPrivacyThreshold uint `gorm:"column:stats_privacy_threshold; type:integer; not null; default:10;"`
+
+	// StatsPrivacyEpsilon is the privacy budget used when noising small counts.
+	// Smaller values add more noise.
+	StatsPrivacyEpsilon float64 `gorm:"column:stats_privacy_epsilon; type:double precision; not null; default:1.0;"`
+
+	// StatsPrivacySalt seeds the noise so that repeated exports of the same day
+	// return the same value and cannot be averaged away. It is generated
+	// automatically and never displayed.
+	StatsPrivacySalt string `gorm:"column:stats_privacy_salt; type:text;" json:"-" audit:"redact"`
+
+	// StatsTimezone is the IANA time zone in which daily statistics are bucketed
+	// and displayed (e.g. "America/New_York"). Days recorded before a change are
+	// not re-bucketed; the change is annotated on the stats instead.
+	StatsTimezone string `gorm:"column:stats_timezone; type:text; not null; default:'UTC';"`
+
 	// EN Express
 	EnableENExpress bool `gorm:"type:boolean; default: false;"`
 
+	// ENXLinkParams are static query parameters appended to the [enslink]
+	// expansion, such as campaign or clinic identifiers. The redirect server
+	// passes them through to the app.
+	ENXLinkParams postgres.Hstore `gorm:"column:enx_link_params; type:hstore;"`
+
 	// AbusePreventionEnabled determines if abuse protection is enabled.
 	AbusePreventionEnabled bool `gorm:"type:boolean; not null; default:false;"`
 
@@ -357,6 +570,14 @@ type Realm struct {
 	// before triggering abuse protections.
 	AbusePreventionLimitFactor float32 `gorm:"type:numeric(6, 3); not null; default:1.0;"`
 
+	// DailyUserIssueLimit and DailyAPIKeyIssueLimit are the maximum number of
+	// codes a single user or API key can issue in a 24 hour period. They are
+	// enforced in addition to abuse prevention, so a single compromised
+	// credential cannot exhaust the realm's quota. 0 means unlimited. User
+	// reports are not counted against the API key limit.
+	DailyUserIssueLimit   uint `gorm:"column:daily_user_issue_limit; type:integer; not null; default:0;"`
+	DailyAPIKeyIssueLimit uint `gorm:"column:daily_api_key_issue_limit; type:integer; not null; default:0;"`
+
 	// LastCodesClaimedRatio is the percentage of codes claimed (out of all codes
 	// issued) for the most recent completely full UTC day. CodesClaimedRatioMean and
 	// CodesClaimedRatioStddev represent the mean and standard deviation for the
@@ -377,6 +598,16 @@ type Realm struct {
 	// the Google Workspace SMTP relay is configured.
 	ContactEmailAddresses pq.StringArray `gorm:"column:contact_email_addresses; type:text[]; not null; default:'{}';"`
 
+	// NotificationPhoneNumbers is a list of E.164 phone numbers that receive
+	// realm notifications posted with SMS fan-out. Messages are sent using the
+	// realm's SMS configuration.
+	NotificationPhoneNumbers pq.StringArray `gorm:"column:notification_phone_numbers; type:text[]; not null; default:'{}';"`
+
+	// EnableStatusPage determines if the realm's public status page is served.
+	// The page shows API availability, SMS delivery health, and active banners,
+	// so realm admins can share it with their integration partners.
+	EnableStatusPage bool `gorm:"column:enable_status_page; type:bool; not null; default:false;"`
+
 	// Relations to items that belong to a realm.
 	Codes  []*VerificationCode `gorm:"PRELOAD:false; SAVE_ASSOCIATIONS:false; ASSOCIATION_AUTOUPDATE:false, ASSOCIATION_SAVE_REFERENCE:false;"`
 	Tokens []*Token            `gorm:"PRELOAD:false; SAVE_ASSOCIATIONS:false; ASSOCIATION_AUTOUPDATE:false, ASSOCIATION_SAVE_REFERENCE:false;"`
@@ -392,6 +623,7 @@ func NewRealmWithDefaults(name string) *Realm {
 	return &Realm{
 		Name:                name,
 		CodeLength:          DefaultShortCodeLength,
+		CodeCharset:         CodeCharsetNumeric,
 		CodeDuration:        FromDuration(DefaultShortCodeExpirationMinutes * time.Minute),
 		LongCodeLength:      DefaultLongCodeLength,
 		LongCodeDuration:    FromDuration(DefaultLongCodeExpirationHours * time.Hour),
@@ -438,6 +670,13 @@ func (r *Realm) ResetSMSTextTemplates() {
 			r.SMSTextAlternateTemplates[k] = &m
 		}
 	}
+	// Localized variants cannot be rewritten, so drop any that no longer match
+	// the ENX setting.
+	for k, v := range r.SMSTextLocalizedTemplates {
+		if v == nil || strings.Contains(*v, SMSENExpressLink) != r.EnableENExpress {
+			delete(r.SMSTextLocalizedTemplates, k)
+		}
+	}
 }
 
 // SMSTemplateMaxLength returns database.SMSTemplateMaxLength.
@@ -467,6 +706,7 @@ func (r *Realm) AddUserReportToAllowedTestTypes() {
 // AfterFind runs after a realm is found.
 func (r *Realm) AfterFind(tx *gorm.DB) error {
 	r.RegionCode = stringValue(r.RegionCodePtr)
+	r.CustomDomain = stringValue(r.CustomDomainPtr)
 	r.WelcomeMessage = stringValue(r.WelcomeMessagePtr)
 	r.SMSCountry = stringValue(r.SMSCountryPtr)
 	r.SMSFromNumberID = uintValue(r.SMSFromNumberIDPtr)
@@ -496,6 +736,12 @@ func (r *Realm) BeforeSave(tx *gorm.DB) error {
 	}
 	r.RegionCodePtr = stringPtr(r.RegionCode)
 
+	r.CustomDomain = strings.TrimSuffix(strings.ToLower(project.TrimSpace(r.CustomDomain)), ".")
+	if r.CustomDomain != "" && !isHostname(r.CustomDomain) {
+		r.AddError("customDomain", "must be a hostname like verify.example.com")
+	}
+	r.CustomDomainPtr = stringPtr(r.CustomDomain)
+
 	// Ensure e2e naming uniqueness. This isn't strictly required - uniqueness is
 	// enforced by a boolean column - but it makes the UI clearer if we forbid
 	// similar names.
@@ -550,14 +796,47 @@ func (r *Realm) BeforeSave(tx *gorm.DB) error {
 
 	r.SMSCountryPtr = stringPtr(r.SMSCountry)
 
+	if len(r.SMSAllowedCountries) > 0 {
+		r.SMSAllowedCountries = normalizeCountryList(r.SMSAllowedCountries)
+		for _, code := range r.SMSAllowedCountries {
+			if !isKnownCountryCode(code) {
+				r.AddError("smsAllowedCountries", fmt.Sprintf("%q is not a valid country code", code))
+			}
+		}
+	}
+
 	r.SMSFromNumberIDPtr = uintPtr(r.SMSFromNumberID)
 
+	r.MinimumAppVersion = strings.TrimSpace(r.MinimumAppVersion)
+	if r.MinimumAppVersion != "" {
+		if _, err := api.ParseAppVersion(r.MinimumAppVersion); err != nil {
+			r.AddError("minimumAppVersion", "must be a version like 1.2.3")
+		}
+	}
+
+	if r.APIRateLimitKeyMode.String() == "" {
+		r.AddError("apiRateLimitKeyMode", "is not a valid rate limit mode")
+	}
+	if r.APIRateLimitBurst > MaxAPIRateLimitBurst {
+		r.AddError("apiRateLimitBurst", fmt.Sprintf("must be no more than %d", MaxAPIRateLimitBurst))
+	}
+	if d := r.APIRateLimitBanDuration.Duration; d < 0 || d > MaxAPIRateLimitBanDuration {
+		r.AddError("apiRateLimitBanDuration", "must be between 0 and 24 hours")
+	}
+	if r.APIKeyInactivityDays > MaxAPIKeyInactivityDays {
+		r.AddError("apiKeyInactivityDays", fmt.Sprintf("must be no more than %d", MaxAPIKeyInactivityDays))
+	}
+
 	if r.EnableENExpress {
 		if r.RegionCode == "" {
 			r.AddError("regionCode", "cannot be blank when using EN Express")
 		}
 	}
 
+	// Validate the link parameters before the SMS templates, since they count
+	// toward the expanded length of [enslink].
+	r.ENXLinkParams = r.validateENXLinkParams(r.ENXLinkParams)
+
 	if r.PasswordRotationWarningDays > r.PasswordRotationPeriodDays {
 		r.AddError("passwordWarn", "may not be longer than password rotation period")
 	}
@@ -568,9 +847,28 @@ func (r *Realm) BeforeSave(tx *gorm.DB) error {
 		r.AddError("shortCodeMaxMinutes", "must be >= 60 and <= 120")
 	}
 
+	retentions := []struct {
+		field    string
+		days     uint
+		min, max uint
+	}{
+		{"auditRetentionDays", r.AuditRetentionDays, MinAuditRetentionDays, MaxAuditRetentionDays},
+		{"verificationCodeRetentionDays", r.VerificationCodeRetentionDays, MinVerificationCodeRetentionDays, MaxVerificationCodeRetentionDays},
+		{"tokenRetentionDays", r.TokenRetentionDays, MinTokenRetentionDays, MaxTokenRetentionDays},
+		{"statsRetentionDays", r.StatsRetentionDays, MinStatsRetentionDays, MaxStatsRetentionDays},
+		{"userReportRetentionDays", r.UserReportRetentionDays, MinUserReportRetentionDays, MaxUserReportRetentionDays},
+	}
+	for _, v := range retentions {
+		if v.days != 0 && (v.days < v.min || v.days > v.max) {
+			r.AddError(v.field, fmt.Sprintf("must be 0 or between %d and %d", v.min, v.max))
+		}
+	}
+
 	if r.CodeLength < 6 {
 		r.AddError("codeLength", "must be at least 6")
 	}
+	r.validateCodeFormat()
+	r.validateExternalCaseID()
 
 	// Validation of the max code duration is dependent on overrides.
 	realmMaxCodeDuration := time.Minute * time.Duration(r.ShortCodeMaxMinutes)
@@ -628,6 +926,9 @@ func (r *Realm) BeforeSave(tx *gorm.DB) error {
 		}
 	}
 
+	r.SMSTextLocalizedTemplates = r.validateLocalizedSMSTemplates(r.SMSTextLocalizedTemplates)
+	r.LocalizedWebStrings = r.validateLocalizedWebStrings(r.LocalizedWebStrings)
+
 	if r.AllowsUserReport() {
 		if r.SMSCountry == "" {
 			r.AddError("smsCountry", "A default SMS Country must be set when user report is enabled")
@@ -656,6 +957,10 @@ func (r *Realm) BeforeSave(tx *gorm.DB) error {
 		}
 	}
 
+	r.EmailInviteTemplates = r.validateEmailTemplates("emailInviteTemplates", r.EmailInviteTemplates, EmailInviteLink)
+	r.EmailPasswordResetTemplates = r.validateEmailTemplates("emailPasswordResetTemplates", r.EmailPasswordResetTemplates, EmailPasswordResetLink)
+	r.EmailVerifyTemplates = r.validateEmailTemplates("emailVerifyTemplates", r.EmailVerifyTemplates, EmailVerifyLink)
+
 	r.CertificateIssuer = project.TrimSpaceAndNonPrintable(r.CertificateIssuer)
 	r.CertificateAudience = project.TrimSpaceAndNonPrintable(r.CertificateAudience)
 	if r.UseRealmCertificateKey {
@@ -673,6 +978,50 @@ func (r *Realm) BeforeSave(tx *gorm.DB) error {
 		}
 	}
 
+	if r.CertificateSigningAlgorithm == "" {
+		r.CertificateSigningAlgorithm = jwthelper.AlgorithmES256
+	}
+	if !jwthelper.IsSupportedAlgorithm(r.CertificateSigningAlgorithm) {
+		r.AddError("certificateSigningAlgorithm", fmt.Sprintf("must be one of %s",
+			strings.Join(jwthelper.SupportedAlgorithms, ", ")))
+	} else if !supportsSigningAlgorithm(tx, r.CertificateSigningAlgorithm) {
+		// Otherwise the realm could never create or rotate its signing keys.
+		r.AddError("certificateSigningAlgorithm", "is not supported by the configured key manager")
+	}
+
+	r.AllowedCertificateClaims = r.validateAllowedCertificateClaims(r.AllowedCertificateClaims)
+	r.CertificateClaims = r.validateCertificateClaims(r.CertificateClaims)
+
+	if r.StatsPrivacyMode == "" {
+		r.StatsPrivacyMode = StatsPrivacyModeOff
+	}
+	if !IsValidStatsPrivacyMode(r.StatsPrivacyMode) {
+		r.AddError("statsPrivacyMode", fmt.Sprintf("must be one of %s",
+			strings.Join(StatsPrivacyModes, ", ")))
+	}
+	if r.StatsPrivacyMode != StatsPrivacyModeOff {
+		if r.StatsPrivacyThreshold == 0 {
+			r.AddError("statsPrivacyThreshold", "must be greater than 0")
+		}
+		if r.StatsPrivacyEpsilon <= 0 {
+			r.AddError("statsPrivacyEpsilon", "must be greater than 0")
+		}
+	}
+	if r.StatsTimezone == "" {
+		r.StatsTimezone = DefaultStatsTimezone
+	}
+	if !IsValidStatsTimezone(r.StatsTimezone) {
+		r.AddError("statsTimezone", "is not a valid time zone")
+	}
+
+	if r.StatsPrivacyMode == StatsPrivacyModeNoise && r.StatsPrivacySalt == "" {
+		salt, err := project.RandomHexString(32)
+		if err != nil {
+			return fmt.Errorf("failed to generate stats privacy salt: %w", err)
+		}
+		r.StatsPrivacySalt = salt
+	}
+
 	if limit := 10; len(r.ContactEmailAddresses) > limit {
 		r.AddError("contactEmailAddresses", fmt.Sprintf("must have less than %d entries", limit))
 	}
@@ -682,12 +1031,30 @@ func (r *Realm) BeforeSave(tx *gorm.DB) error {
 		}
 	}
 
+	if limit := 10; len(r.NotificationPhoneNumbers) > limit {
+		r.AddError("notificationPhoneNumbers", fmt.Sprintf("must have less than %d entries", limit))
+	}
+	for i, phone := range r.NotificationPhoneNumbers {
+		canonical, err := project.CanonicalPhoneNumber(phone, r.SMSCountry)
+		if err != nil {
+			r.AddError("notificationPhoneNumbers", fmt.Sprintf("includes invalid phone number %q", phone))
+			continue
+		}
+		r.NotificationPhoneNumbers[i] = canonical
+	}
+
 	return r.ErrorOrNil()
 }
 
 // validateSMSTemplate is a helper method to validate a single SMSTemplate.
 // Errors are returned by appending them to the realm's Errorable fields.
 func (r *Realm) validateSMSTemplate(label, t string) string {
+	return r.validateSMSTemplateText("smsTextTemplate", label, label, t)
+}
+
+// validateSMSTemplateText validates the template text t for the given label.
+// Errors are added to both field and key.
+func (r *Realm) validateSMSTemplateText(field, key, label, t string) string {
 	// Replace all newlines with spaces.
 	t = smsNewlineRegex.ReplaceAllString(t, " ")
 	// Replace all sets of multiple spaces with a single space
@@ -696,58 +1063,110 @@ func (r *Realm) validateSMSTemplate(label, t string) string {
 	if !r.EnableENExpress {
 		// Check that we have exactly one of [code] or [longcode] as template substitutions.
 		if c, lc := strings.Contains(t, SMSCode), strings.Contains(t, SMSLongCode); !(c || lc) || (c && lc) {
-			r.AddError("smsTextTemplate", fmt.Sprintf("must contain exactly one of %q or %q", SMSCode, SMSLongCode))
-			r.AddError(label, fmt.Sprintf("must contain exactly one of %q or %q", SMSCode, SMSLongCode))
+			r.AddError(field, fmt.Sprintf("must contain exactly one of %q or %q", SMSCode, SMSLongCode))
+			r.AddError(key, fmt.Sprintf("must contain exactly one of %q or %q", SMSCode, SMSLongCode))
 		}
 		if strings.Contains(t, SMSENExpressLink) {
-			r.AddError("smsTextTemplate", fmt.Sprintf("cannot contain %q because Exposure Notifications Express is not enabled", SMSENExpressLink))
-			r.AddError(label, fmt.Sprintf("cannot contain %q", SMSENExpressLink))
+			r.AddError(field, fmt.Sprintf("cannot contain %q because Exposure Notifications Express is not enabled", SMSENExpressLink))
+			r.AddError(key, fmt.Sprintf("cannot contain %q", SMSENExpressLink))
 		}
 	} else {
 		if !strings.Contains(t, SMSENExpressLink) {
-			r.AddError("smsTextTemplate", fmt.Sprintf("must contain %q", SMSENExpressLink))
-			r.AddError(label, fmt.Sprintf("must contain %q", SMSENExpressLink))
+			r.AddError(field, fmt.Sprintf("must contain %q", SMSENExpressLink))
+			r.AddError(key, fmt.Sprintf("must contain %q", SMSENExpressLink))
 		}
 		if strings.Contains(t, SMSRegion) {
-			r.AddError("smsTextTemplate", fmt.Sprintf("cannot contain %q - this is automatically included in %q", SMSRegion, SMSENExpressLink))
-			r.AddError(label, fmt.Sprintf("must contain %q", SMSENExpressLink))
+			r.AddError(field, fmt.Sprintf("cannot contain %q - this is automatically included in %q", SMSRegion, SMSENExpressLink))
+			r.AddError(key, fmt.Sprintf("must contain %q", SMSENExpressLink))
 		}
 		if strings.Contains(t, SMSLongCode) {
-			r.AddError("smsTextTemplate", fmt.Sprintf("cannot contain %q - the long code is automatically included in %q", SMSLongCode, SMSENExpressLink))
-			r.AddError(label, fmt.Sprintf("must contain %q", SMSENExpressLink))
+			r.AddError(field, fmt.Sprintf("cannot contain %q - the long code is automatically included in %q", SMSLongCode, SMSENExpressLink))
+			r.AddError(key, fmt.Sprintf("must contain %q", SMSENExpressLink))
 		}
 	}
 
 	if label == UserReportTemplateLabel {
 		if strings.Contains(t, SMSLongExpires) {
-			r.AddError("smsTextTemplate", fmt.Sprintf("cannot contain %q - for %q the 'short expiration' time is used an is represented in minutes", SMSLongExpires, UserReportTemplateLabel))
-			r.AddError(label, fmt.Sprintf("cannot contain %q", SMSLongExpires))
+			r.AddError(field, fmt.Sprintf("cannot contain %q - for %q the 'short expiration' time is used an is represented in minutes", SMSLongExpires, UserReportTemplateLabel))
+			r.AddError(key, fmt.Sprintf("cannot contain %q", SMSLongExpires))
 		}
 	}
 
 	// Check template length.
 	if l := len(t); l > SMSTemplateMaxLength {
-		r.AddError("smsTextTemplate", fmt.Sprintf("must be %d characters or less, current message is %v characters long", SMSTemplateMaxLength, l))
-		r.AddError(label, fmt.Sprintf("must contain %q", SMSENExpressLink))
+		r.AddError(field, fmt.Sprintf("must be %d characters or less, current message is %v characters long", SMSTemplateMaxLength, l))
+		r.AddError(key, fmt.Sprintf("must contain %q", SMSENExpressLink))
 	}
 
 	// Check expansion length based on settings.
-	fakeCode := fmt.Sprintf(fmt.Sprintf("\\%0%d\\%d", r.CodeLength), 0)
+	fakeCode := r.FormatCode(strings.Repeat("0", int(r.CodeLength)))
 	fakeLongCode := fmt.Sprintf(fmt.Sprintf("\\%0%d\\%d", r.LongCodeLength), 0)
 	enxDomain := r.enxRedirectDomain()
-	expandedSMSText, err := r.BuildSMSText(fakeCode, fakeLongCode, enxDomain, label)
-	if err != nil {
-		r.AddError("smsTextTemplate", fmt.Sprintf("SMS template expansion failed: %s", err))
-		r.AddError(label, fmt.Sprintf("SMS template expansion failed: %s", err))
-	}
+	expandedSMSText := r.expandSMSText(t, fakeCode, fakeLongCode, enxDomain)
 	if l := len(expandedSMSText); l > SMSTemplateExpansionMax {
-		r.AddError("smsTextTemplate", fmt.Sprintf("when expanded, the result message is too long (%v characters). The max expanded message is %v characters", l, SMSTemplateExpansionMax))
-		r.AddError(label, fmt.Sprintf("when expanded, the result message is too long (%v characters). The max expanded message is %v characters", l, SMSTemplateExpansionMax))
+		r.AddError(field, fmt.Sprintf("when expanded, the result message is too long (%v characters). The max expanded message is %v characters", l, SMSTemplateExpansionMax))
+		r.AddError(key, fmt.Sprintf("when expanded, the result message is too long (%v characters). The max expanded message is %v characters", l, SMSTemplateExpansionMax))
 	}
 
 	return t
 }
 
+// ENXLinkQuery returns the ENX link parameters as a query string suffix
+// (beginning with "&"), sorted by name, or the empty string if there are none.
+func (r *Realm) ENXLinkQuery() string {
+	if len(r.ENXLinkParams) == 0 {
+		return ""
+	}
+
+	q := make(url.Values, len(r.ENXLinkParams))
+	for k, v := range r.ENXLinkParams {
+		q.Set(k, stringValue(v))
+	}
+	return "&" + q.Encode()
+}
+
+// validateENXLinkParams trims the ENX link parameters and checks their names,
+// values, and encoded length. It returns the trimmed parameters.
+func (r *Realm) validateENXLinkParams(params postgres.Hstore) postgres.Hstore {
+	if len(params) == 0 {
+		return nil
+	}
+
+	if len(params) > ENXLinkParamsMax {
+		r.AddError("enxLinkParams", fmt.Sprintf("cannot have more than %d parameters", ENXLinkParamsMax))
+	}
+
+	trimmed := make(postgres.Hstore, len(params))
+	for k, v := range params {
+		k = project.TrimSpace(k)
+		if !enxLinkParamKeyRegex.MatchString(k) {
+			r.AddError("enxLinkParams", fmt.Sprintf("parameter name %q must start with a letter and contain only letters, digits, underscores, and dashes (max 32)", k))
+			continue
+		}
+		if lower := strings.ToLower(k); lower == "c" || lower == "r" {
+			r.AddError("enxLinkParams", fmt.Sprintf("parameter name %q is reserved", k))
+			continue
+		}
+		if _, ok := trimmed[k]; ok {
+			r.AddError("enxLinkParams", fmt.Sprintf("parameter %q is listed more than once", k))
+			continue
+		}
+
+		val := project.TrimSpaceAndNonPrintable(stringValue(v))
+		if val == "" {
+			r.AddError("enxLinkParams", fmt.Sprintf("parameter %q must have a value", k))
+			continue
+		}
+		trimmed[k] = &val
+	}
+
+	r.ENXLinkParams = trimmed
+	if l := len(r.ENXLinkQuery()); l > ENXLinkParamsMaxLength {
+		r.AddError("enxLinkParams", fmt.Sprintf("must be %d characters or less when encoded, currently %d characters", ENXLinkParamsMaxLength, l))
+	}
+	return trimmed
+}
+
 // enxRedirectDomain returns the configured ENX redirect domain for this realm.
 func (r *Realm) enxRedirectDomain() string {
 	if v := r.enxRedirectDomainOverride; v != "" {
@@ -768,6 +1187,25 @@ func (r *Realm) GetLongCodeDurationHours() int {
 	return int(r.LongCodeDuration.Duration.Hours())
 }
 
+// VerificationCodeRetention returns how long the realm's verification codes
+// are kept after they expire, or def if the realm uses the system default.
+func (r *Realm) VerificationCodeRetention(def time.Duration) time.Duration {
+	return retentionDays(r.VerificationCodeRetentionDays, def)
+}
+
+// TokenRetention returns how long the realm's verification tokens are kept
+// after they expire, or def if the realm uses the system default.
+func (r *Realm) TokenRetention(def time.Duration) time.Duration {
+	return retentionDays(r.TokenRetentionDays, def)
+}
+
+func retentionDays(days uint, def time.Duration) time.Duration {
+	if days == 0 {
+		return def
+	}
+	return time.Duration(days) * 24 * time.Hour
+}
+
 // EffectiveMFAMode returns the realm's default MFAMode but first checks if the
 // time is in the grace-period (if so, required becomes prompt).
 func (r *Realm) EffectiveMFAMode(t time.Time) AuthRequirement {
@@ -813,24 +1251,32 @@ func (r *Realm) FindVerificationCodeByUUID(db *Database, uuidStr string) (*Verif
 
 // BuildSMSText replaces certain strings with the right values.
 func (r *Realm) BuildSMSText(code, longCode string, enxDomain, templateLabel string) (string, error) {
-	text := r.SMSTextTemplate
-	if templateLabel != "" && templateLabel != DefaultTemplateLabel && r.SMSTextAlternateTemplates != nil {
-		if t, has := r.SMSTextAlternateTemplates[templateLabel]; has && t != nil && *t != "" {
-			text = *t
-		} else {
-			return "", fmt.Errorf("no template found for label %s", templateLabel)
-		}
+	return r.BuildLocalizedSMSText(code, longCode, enxDomain, templateLabel, "")
+}
+
+// BuildLocalizedSMSText is like BuildSMSText, but uses the variant of the
+// template that best matches the locale. See SMSTextTemplateFor for the
+// fallback order.
+func (r *Realm) BuildLocalizedSMSText(code, longCode, enxDomain, templateLabel, locale string) (string, error) {
+	text, err := r.SMSTextTemplateFor(templateLabel, locale)
+	if err != nil {
+		return "", err
 	}
+	return r.expandSMSText(text, code, longCode, enxDomain), nil
+}
 
+// expandSMSText replaces the substitution strings in the template text.
+func (r *Realm) expandSMSText(text, code, longCode, enxDomain string) string {
 	if enxDomain == "" {
 		// preserves legacy behavior.
-		text = strings.ReplaceAll(text, SMSENExpressLink, fmt.Sprintf("ens://v?r=%s&c=%s", SMSRegion, SMSLongCode))
+		text = strings.ReplaceAll(text, SMSENExpressLink, fmt.Sprintf("ens://v?r=%s&c=%s%s", SMSRegion, SMSLongCode, r.ENXLinkQuery()))
 	} else {
 		text = strings.ReplaceAll(text, SMSENExpressLink,
-			fmt.Sprintf("https://%s.%s/v?c=%s",
+			fmt.Sprintf("https://%s.%s/v?c=%s%s",
 				strings.ToLower(r.RegionCode),
 				enxDomain,
-				SMSLongCode))
+				SMSLongCode,
+				r.ENXLinkQuery()))
 	}
 	text = strings.ReplaceAll(text, SMSRegion, r.RegionCode)
 	text = strings.ReplaceAll(text, SMSCode, code)
@@ -838,31 +1284,125 @@ func (r *Realm) BuildSMSText(code, longCode string, enxDomain, templateLabel str
 	text = strings.ReplaceAll(text, SMSLongCode, longCode)
 	text = strings.ReplaceAll(text, SMSLongExpires, fmt.Sprintf("%d", r.GetLongCodeDurationHours()))
 
-	return text, nil
+	return text
+}
+
+// EmailInviteTemplateFor returns the invitation email template for the
+// locale. If the realm has no variant for the locale or its base language, the
+// default template is returned. An empty result means the system template
+// should be used.
+func (r *Realm) EmailInviteTemplateFor(locale string) string {
+	return emailTemplateFor(r.EmailInviteTemplates, locale, r.EmailInviteTemplate)
+}
+
+// EmailPasswordResetTemplateFor returns the password reset email template for
+// the locale, with the same fallbacks as EmailInviteTemplateFor.
+func (r *Realm) EmailPasswordResetTemplateFor(locale string) string {
+	return emailTemplateFor(r.EmailPasswordResetTemplates, locale, r.EmailPasswordResetTemplate)
+}
+
+// EmailVerifyTemplateFor returns the email verification template for the
+// locale, with the same fallbacks as EmailInviteTemplateFor.
+func (r *Realm) EmailVerifyTemplateFor(locale string) string {
+	return emailTemplateFor(r.EmailVerifyTemplates, locale, r.EmailVerifyTemplate)
 }
 
 // BuildInviteEmail replaces certain strings with the right values for invitations.
-func (r *Realm) BuildInviteEmail(inviteLink string) string {
-	text := r.EmailInviteTemplate
-	text = strings.ReplaceAll(text, EmailInviteLink, inviteLink)
-	text = strings.ReplaceAll(text, RealmName, r.Name)
-	return text
+func (r *Realm) BuildInviteEmail(inviteLink, locale string) string {
+	text := r.EmailInviteTemplateFor(locale)
+	return expandEmailText(text, EmailInviteLink, inviteLink, r.Name)
 }
 
 // BuildPasswordResetEmail replaces certain strings with the right values for password reset.
-func (r *Realm) BuildPasswordResetEmail(passwordResetLink string) string {
-	text := r.EmailPasswordResetTemplate
-	text = strings.ReplaceAll(text, EmailPasswordResetLink, passwordResetLink)
-	text = strings.ReplaceAll(text, RealmName, r.Name)
-	return text
+func (r *Realm) BuildPasswordResetEmail(passwordResetLink, locale string) string {
+	text := r.EmailPasswordResetTemplateFor(locale)
+	return expandEmailText(text, EmailPasswordResetLink, passwordResetLink, r.Name)
 }
 
 // BuildVerifyEmail replaces certain strings with the right values for email verification.
-func (r *Realm) BuildVerifyEmail(verifyLink string) string {
-	text := r.EmailVerifyTemplate
-	text = strings.ReplaceAll(text, EmailVerifyLink, verifyLink)
-	text = strings.ReplaceAll(text, RealmName, r.Name)
-	return text
+func (r *Realm) BuildVerifyEmail(verifyLink, locale string) string {
+	text := r.EmailVerifyTemplateFor(locale)
+	return expandEmailText(text, EmailVerifyLink, verifyLink, r.Name)
+}
+
+// emailTemplateFor returns the variant that best matches the locale. An exact
+// match is preferred, followed by a match on the base language (e.g. "es" for
+// "es-MX"). If nothing matches, fallback is returned.
+func emailTemplateFor(variants postgres.Hstore, locale, fallback string) string {
+	for _, l := range localeCandidates(locale) {
+		if t, ok := variants[l]; ok && t != nil && *t != "" {
+			return *t
+		}
+	}
+	return fallback
+}
+
+// validateEmailTemplates normalizes the locales of the email template variants
+// and checks that each variant contains the required link. It returns the
+// normalized variants.
+func (r *Realm) validateEmailTemplates(field string, variants postgres.Hstore, link string) postgres.Hstore {
+	if len(variants) == 0 {
+		return nil
+	}
+
+	normalized := make(postgres.Hstore, len(variants))
+	for l, t := range variants {
+		locale := normalizeLocale(l)
+		if locale == "" {
+			r.AddError(field, "locale cannot be blank")
+			continue
+		}
+		if _, ok := normalized[locale]; ok {
+			r.AddError(field, fmt.Sprintf("locale %q is listed more than once", l))
+			continue
+		}
+		if t == nil || strings.TrimSpace(*t) == "" {
+			r.AddError(field, fmt.Sprintf("no template for locale %s", locale))
+			continue
+		}
+		if !strings.Contains(*t, link) {
+			r.AddError(field, fmt.Sprintf("template for locale %s must contain %q", locale, link))
+		}
+		normalized[locale] = t
+	}
+	return normalized
+}
+
+// hstoreString renders the hstore values sorted by key, for audit diffs.
+func hstoreString(h postgres.Hstore) string {
+	keys := make([]string, 0, len(h))
+	for k := range h {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	for _, k := range keys {
+		fmt.Fprintf(&b, "%s: %s\n", k, stringValue(h[k]))
+	}
+	return b.String()
+}
+
+// SMSDestinationAllowed returns the lowercase region of the given E.164 phone
+// number and whether the realm permits sending SMS to that region. If the realm
+// has no allowed countries configured, all destinations are permitted and the
+// number is not inspected.
+func (r *Realm) SMSDestinationAllowed(phone string) (string, bool, error) {
+	if len(r.SMSAllowedCountries) == 0 {
+		return "", true, nil
+	}
+
+	region, err := project.PhoneNumberRegion(phone)
+	if err != nil {
+		return "", false, err
+	}
+
+	for _, v := range r.SMSAllowedCountries {
+		if v == region {
+			return region, true, nil
+		}
+	}
+	return region, false, nil
 }
 
 // SMSConfig returns the SMS configuration for this realm, if one exists. If the
@@ -953,10 +1493,21 @@ func (r *Realm) SMSProvider(db *Database, opts ...SMSProviderOption) (sms.Provid
 	}
 
 	config := &sms.Config{
-		ProviderType:     smsConfig.ProviderType,
-		TwilioAccountSid: smsConfig.TwilioAccountSid,
-		TwilioAuthToken:  smsConfig.TwilioAuthToken,
-		TwilioFromNumber: smsConfig.TwilioFromNumber,
+		ProviderType:          smsConfig.ProviderType,
+		TwilioAccountSid:      smsConfig.TwilioAccountSid,
+		TwilioAuthToken:       smsConfig.TwilioAuthToken,
+		TwilioFromNumber:      smsConfig.TwilioFromNumber,
+		MessageBirdAccessKey:  smsConfig.MessageBirdAccessKey,
+		MessageBirdOriginator: smsConfig.MessageBirdOriginator,
+	}
+
+	// Recorded messages contain live verification codes and phone numbers, so
+	// they are only persisted when fake SMS is enabled.
+	if smsConfig.ProviderType == sms.ProviderTypeNoopInspect {
+		if !db.config.FakeSMS {
+			return nil, fmt.Errorf("sms provider %s is only available when fake SMS is enabled", sms.ProviderTypeNoopInspect)
+		}
+		config.Recorder = &sandboxSMSRecorder{db: db, realmID: r.ID}
 	}
 
 	// Resolve options. Last writer wins
@@ -1083,6 +1634,12 @@ func (r *Realm) setActiveManagedSigningKey(db *Database, id uint, signingKey Rea
 			return fmt.Errorf("failed to find newly active key: %w", err)
 		}
 
+		// Migrated keys are held by a key manager that is no longer configured, so
+		// they cannot sign.
+		if signingKey.MigratedPublicKey() != "" {
+			return fmt.Errorf("cannot activate %s signing key that was migrated to another key manager", signingKey.Purpose())
+		}
+
 		// Mark all other keys as inactive.
 		if err := tx.
 			Table(signingKey.Table()).
@@ -1347,6 +1904,20 @@ func (db *Database) FindRealmByRegion(region string) (*Realm, error) {
 	return &realm, nil
 }
 
+// FindRealmByCustomDomain finds the realm served on the given hostname. The
+// hostname must not include a port.
+func (db *Database) FindRealmByCustomDomain(host string) (*Realm, error) {
+	var realm Realm
+	if err := db.db.
+		Model(&Realm{}).
+		Where("custom_domain = ?", strings.TrimSuffix(strings.ToLower(host), ".")).
+		First(&realm).
+		Error; err != nil {
+		return nil, err
+	}
+	return &realm, nil
+}
+
 func (db *Database) FindRealmByName(name string) (*Realm, error) {
 	var realm Realm
 
@@ -1436,6 +2007,9 @@ func (db *Database) SaveRealm(r *Realm, actor Auditable) error {
 			case IsUniqueViolation(err, "uix_realms_region_code"):
 				r.AddError("regionCode", "must be unique")
 				return ErrValidationFailed
+			case IsUniqueViolation(err, "uix_realms_custom_domain"):
+				r.AddError("customDomain", "is already in use by another realm")
+				return ErrValidationFailed
 			}
 			return err
 		}
@@ -1469,6 +2043,30 @@ func (db *Database) SaveRealm(r *Realm, actor Auditable) error {
 				audits = append(audits, audit)
 			}
 
+			if existing.CodeCharset != r.CodeCharset {
+				audit := BuildAuditEntry(actor, "updated code charset", r, r.ID)
+				audit.Diff = stringDiff(existing.CodeCharset, r.CodeCharset)
+				audits = append(audits, audit)
+			}
+
+			if existing.CodeGroupSize != r.CodeGroupSize {
+				audit := BuildAuditEntry(actor, "updated code group size", r, r.ID)
+				audit.Diff = uintDiff(existing.CodeGroupSize, r.CodeGroupSize)
+				audits = append(audits, audit)
+			}
+
+			if existing.ExternalCaseIDPattern != r.ExternalCaseIDPattern {
+				audit := BuildAuditEntry(actor, "updated external case ID pattern", r, r.ID)
+				audit.Diff = stringDiff(existing.ExternalCaseIDPattern, r.ExternalCaseIDPattern)
+				audits = append(audits, audit)
+			}
+
+			if existing.RequireExternalCaseID != r.RequireExternalCaseID {
+				audit := BuildAuditEntry(actor, "updated require external case ID", r, r.ID)
+				audit.Diff = boolDiff(existing.RequireExternalCaseID, r.RequireExternalCaseID)
+				audits = append(audits, audit)
+			}
+
 			if existing.CodeDuration != r.CodeDuration {
 				audit := BuildAuditEntry(actor, "updated code duration", r, r.ID)
 				audit.Diff = stringDiff(existing.CodeDuration.AsString, r.CodeDuration.AsString)
@@ -1493,12 +2091,42 @@ func (db *Database) SaveRealm(r *Realm, actor Auditable) error {
 				audits = append(audits, audit)
 			}
 
+			if before, after := hstoreString(existing.SMSTextLocalizedTemplates), hstoreString(r.SMSTextLocalizedTemplates); before != after {
+				audit := BuildAuditEntry(actor, "updated localized SMS templates", r, r.ID)
+				audit.Diff = stringDiff(before, after)
+				audits = append(audits, audit)
+			}
+
+			if before, after := hstoreString(existing.LocalizedWebStrings), hstoreString(r.LocalizedWebStrings); before != after {
+				audit := BuildAuditEntry(actor, "updated localized web strings", r, r.ID)
+				audit.Diff = stringDiff(before, after)
+				audits = append(audits, audit)
+			}
+
 			if existing.SMSCountry != r.SMSCountry {
 				audit := BuildAuditEntry(actor, "updated SMS country", r, r.ID)
 				audit.Diff = stringDiff(existing.SMSCountry, r.SMSCountry)
 				audits = append(audits, audit)
 			}
 
+			if then, now := existing.SMSAllowedCountries, r.SMSAllowedCountries; !reflect.DeepEqual(then, now) {
+				audit := BuildAuditEntry(actor, "updated SMS allowed countries", r, r.ID)
+				audit.Diff = stringSliceDiff(then, now)
+				audits = append(audits, audit)
+			}
+
+			if then, now := existing.NotificationPhoneNumbers, r.NotificationPhoneNumbers; strings.Join(then, ",") != strings.Join(now, ",") {
+				audit := BuildAuditEntry(actor, "updated notification phone numbers", r, r.ID)
+				audit.Diff = stringSliceDiff(then, now)
+				audits = append(audits, audit)
+			}
+
+			if existing.SMSAllowedCountriesWarnOnly != r.SMSAllowedCountriesWarnOnly {
+				audit := BuildAuditEntry(actor, "updated SMS allowed countries warn only", r, r.ID)
+				audit.Diff = boolDiff(existing.SMSAllowedCountriesWarnOnly, r.SMSAllowedCountriesWarnOnly)
+				audits = append(audits, audit)
+			}
+
 			if existing.CanUseSystemSMSConfig != r.CanUseSystemSMSConfig {
 				audit := BuildAuditEntry(actor, "updated ability to use system SMS config", r, r.ID)
 				audit.Diff = boolDiff(existing.CanUseSystemSMSConfig, r.CanUseSystemSMSConfig)
@@ -1535,6 +2163,24 @@ func (db *Database) SaveRealm(r *Realm, actor Auditable) error {
 				audits = append(audits, audit)
 			}
 
+			if before, after := hstoreString(existing.EmailInviteTemplates), hstoreString(r.EmailInviteTemplates); before != after {
+				audit := BuildAuditEntry(actor, "updated email invite template variants", r, r.ID)
+				audit.Diff = stringDiff(before, after)
+				audits = append(audits, audit)
+			}
+
+			if before, after := hstoreString(existing.EmailPasswordResetTemplates), hstoreString(r.EmailPasswordResetTemplates); before != after {
+				audit := BuildAuditEntry(actor, "updated email password reset template variants", r, r.ID)
+				audit.Diff = stringDiff(before, after)
+				audits = append(audits, audit)
+			}
+
+			if before, after := hstoreString(existing.EmailVerifyTemplates), hstoreString(r.EmailVerifyTemplates); before != after {
+				audit := BuildAuditEntry(actor, "updated email verify template variants", r, r.ID)
+				audit.Diff = stringDiff(before, after)
+				audits = append(audits, audit)
+			}
+
 			if existing.CanUseSystemEmailConfig != r.CanUseSystemEmailConfig {
 				audit := BuildAuditEntry(actor, "updated ability to use system email config", r, r.ID)
 				audit.Diff = boolDiff(existing.CanUseSystemEmailConfig, r.CanUseSystemEmailConfig)
@@ -1595,6 +2241,121 @@ func (db *Database) SaveRealm(r *Realm, actor Auditable) error {
 				audits = append(audits, audit)
 			}
 
+			if existing.CustomDomain != r.CustomDomain {
+				audit := BuildAuditEntry(actor, "updated custom domain", r, r.ID)
+				audit.Diff = stringDiff(existing.CustomDomain, r.CustomDomain)
+				audits = append(audits, audit)
+			}
+
+			if existing.AgencyBackgroundColor != r.AgencyBackgroundColor {
+				audit := BuildAuditEntry(actor, "updated agency background color", r, r.ID)
+				audit.Diff = stringDiff(existing.AgencyBackgroundColor, r.AgencyBackgroundColor)
+				audits = append(audits, audit)
+			}
+
+			if existing.AgencyImage != r.AgencyImage {
+				audit := BuildAuditEntry(actor, "updated agency image", r, r.ID)
+				audit.Diff = stringDiff(existing.AgencyImage, r.AgencyImage)
+				audits = append(audits, audit)
+			}
+
+			if existing.DefaultLocale != r.DefaultLocale {
+				audit := BuildAuditEntry(actor, "updated default locale", r, r.ID)
+				audit.Diff = stringDiff(existing.DefaultLocale, r.DefaultLocale)
+				audits = append(audits, audit)
+			}
+
+			if existing.UserReportLearnMoreURL != r.UserReportLearnMoreURL {
+				audit := BuildAuditEntry(actor, "updated user report learn more url", r, r.ID)
+				audit.Diff = stringDiff(existing.UserReportLearnMoreURL, r.UserReportLearnMoreURL)
+				audits = append(audits, audit)
+			}
+
+			if existing.AgencyBrandingManaged != r.AgencyBrandingManaged {
+				audit := BuildAuditEntry(actor, "updated agency branding managed", r, r.ID)
+				audit.Diff = boolDiff(existing.AgencyBrandingManaged, r.AgencyBrandingManaged)
+				audits = append(audits, audit)
+			}
+
+			if existing.MinimumAppVersion != r.MinimumAppVersion {
+				audit := BuildAuditEntry(actor, "updated minimum app version", r, r.ID)
+				audit.Diff = stringDiff(existing.MinimumAppVersion, r.MinimumAppVersion)
+				audits = append(audits, audit)
+			}
+
+			if existing.APIRateLimitKeyMode != r.APIRateLimitKeyMode {
+				audit := BuildAuditEntry(actor, "updated api rate limit mode", r, r.ID)
+				audit.Diff = stringDiff(existing.APIRateLimitKeyMode.String(), r.APIRateLimitKeyMode.String())
+				audits = append(audits, audit)
+			}
+
+			if existing.APIRateLimitBurst != r.APIRateLimitBurst {
+				audit := BuildAuditEntry(actor, "updated api rate limit burst", r, r.ID)
+				audit.Diff = uintDiff(existing.APIRateLimitBurst, r.APIRateLimitBurst)
+				audits = append(audits, audit)
+			}
+
+			if existing.APIRateLimitBanDuration != r.APIRateLimitBanDuration {
+				audit := BuildAuditEntry(actor, "updated api rate limit ban duration", r, r.ID)
+				audit.Diff = stringDiff(existing.APIRateLimitBanDuration.AsString, r.APIRateLimitBanDuration.AsString)
+				audits = append(audits, audit)
+			}
+
+			if existing.APIKeyInactivityDays != r.APIKeyInactivityDays {
+				audit := BuildAuditEntry(actor, "updated api key inactivity period", r, r.ID)
+				audit.Diff = uintDiff(existing.APIKeyInactivityDays, r.APIKeyInactivityDays)
+				audits = append(audits, audit)
+			}
+
+			if existing.AuditRetentionDays != r.AuditRetentionDays {
+				audit := BuildAuditEntry(actor, "updated audit retention days", r, r.ID)
+				audit.Diff = uintDiff(existing.AuditRetentionDays, r.AuditRetentionDays)
+				audits = append(audits, audit)
+			}
+
+			if existing.VerificationCodeRetentionDays != r.VerificationCodeRetentionDays {
+				audit := BuildAuditEntry(actor, "updated verification code retention days", r, r.ID)
+				audit.Diff = uintDiff(existing.VerificationCodeRetentionDays, r.VerificationCodeRetentionDays)
+				audits = append(audits, audit)
+			}
+
+			if existing.TokenRetentionDays != r.TokenRetentionDays {
+				audit := BuildAuditEntry(actor, "updated token retention days", r, r.ID)
+				audit.Diff = uintDiff(existing.TokenRetentionDays, r.TokenRetentionDays)
+				audits = append(audits, audit)
+			}
+
+			if existing.StatsRetentionDays != r.StatsRetentionDays {
+				audit := BuildAuditEntry(actor, "updated stats retention days", r, r.ID)
+				audit.Diff = uintDiff(existing.StatsRetentionDays, r.StatsRetentionDays)
+				audits = append(audits, audit)
+			}
+
+			if existing.UserReportRetentionDays != r.UserReportRetentionDays {
+				audit := BuildAuditEntry(actor, "updated user report retention days", r, r.ID)
+				audit.Diff = uintDiff(existing.UserReportRetentionDays, r.UserReportRetentionDays)
+				audits = append(audits, audit)
+			}
+
+			if existing.MaintenanceMode != r.MaintenanceMode {
+				audit := BuildAuditEntry(actor, "updated maintenance mode", r, r.ID)
+				audit.Diff = boolDiff(existing.MaintenanceMode, r.MaintenanceMode)
+				audits = append(audits, audit)
+
+				msg := "left maintenance mode"
+				if r.MaintenanceMode {
+					msg = "entered maintenance mode"
+				}
+				if err := recordSystemEvent(tx, &SystemEvent{
+					Kind:    SystemEventMaintenance,
+					Source:  r.Name,
+					RealmID: r.ID,
+					Message: fmt.Sprintf("%s by %s", msg, actor.AuditDisplay()),
+				}); err != nil {
+					return err
+				}
+			}
+
 			if existing.AllowedTestTypes != r.AllowedTestTypes {
 				audit := BuildAuditEntry(actor, "updated allowed test types", r, r.ID)
 				audit.Diff = stringDiff(existing.AllowedTestTypes.Display(), r.AllowedTestTypes.Display())
@@ -1631,6 +2392,71 @@ func (db *Database) SaveRealm(r *Realm, actor Auditable) error {
 				audits = append(audits, audit)
 			}
 
+			if existing.CertificateSigningAlgorithm != r.CertificateSigningAlgorithm {
+				audit := BuildAuditEntry(actor, "updated certificate signing algorithm", r, r.ID)
+				audit.Diff = stringDiff(existing.CertificateSigningAlgorithm, r.CertificateSigningAlgorithm)
+				audits = append(audits, audit)
+			}
+
+			if before, after := strings.Join(existing.AllowedCertificateClaims, ", "), strings.Join(r.AllowedCertificateClaims, ", "); before != after {
+				audit := BuildAuditEntry(actor, "updated allowed certificate claims", r, r.ID)
+				audit.Diff = stringDiff(before, after)
+				audits = append(audits, audit)
+			}
+
+			if before, after := hstoreString(existing.CertificateClaims), hstoreString(r.CertificateClaims); before != after {
+				audit := BuildAuditEntry(actor, "updated certificate claims", r, r.ID)
+				audit.Diff = stringDiff(before, after)
+				audits = append(audits, audit)
+			}
+
+			if existingID, newID := uintValue(existing.KeyServerID), uintValue(r.KeyServerID); existingID != newID {
+				audit := BuildAuditEntry(actor, "updated key server", r, r.ID)
+				audit.Diff = uintDiff(existingID, newID)
+				audits = append(audits, audit)
+			}
+
+			if existing.StatsPrivacyMode != r.StatsPrivacyMode {
+				audit := BuildAuditEntry(actor, "updated stats privacy mode", r, r.ID)
+				audit.Diff = stringDiff(existing.StatsPrivacyMode, r.StatsPrivacyMode)
+				audits = append(audits, audit)
+			}
+
+			if existing.StatsPrivacyThreshold != r.StatsPrivacyThreshold {
+				audit := BuildAuditEntry(actor, "updated stats privacy threshold", r, r.ID)
+				audit.Diff = uintDiff(existing.StatsPrivacyThreshold, r.StatsPrivacyThreshold)
+				audits = append(audits, audit)
+			}
+
+			if existing.StatsPrivacyEpsilon != r.StatsPrivacyEpsilon {
+				audit := BuildAuditEntry(actor, "updated stats privacy epsilon", r, r.ID)
+				audit.Diff = float64Diff(existing.StatsPrivacyEpsilon, r.StatsPrivacyEpsilon)
+				audits = append(audits, audit)
+			}
+
+			if existing.StatsTimezone != r.StatsTimezone {
+				audit := BuildAuditEntry(actor, "updated stats timezone", r, r.ID)
+				audit.Diff = stringDiff(existing.StatsTimezone, r.StatsTimezone)
+				audits = append(audits, audit)
+
+				// Existing days stay in the old timezone, so the first day in the new
+				// timezone may be shorter or longer than 24 hours. Annotate it so the
+				// discontinuity is explained on the charts.
+				if err := tx.Create(&RealmStatsAnnotation{
+					RealmID: r.ID,
+					Date:    r.StatsToday(),
+					Message: fmt.Sprintf("Stats timezone changed from %s to %s", existing.StatsTimezone, r.StatsTimezone),
+				}).Error; err != nil {
+					return fmt.Errorf("failed to annotate stats timezone change: %w", err)
+				}
+			}
+
+			if existing.EnableStatusPage != r.EnableStatusPage {
+				audit := BuildAuditEntry(actor, "updated enable status page", r, r.ID)
+				audit.Diff = boolDiff(existing.EnableStatusPage, r.EnableStatusPage)
+				audits = append(audits, audit)
+			}
+
 			if existing.AutoRotateCertificateKey != r.AutoRotateCertificateKey {
 				audit := BuildAuditEntry(actor, "updated auto-rotate certificate keys", r, r.ID)
 				audit.Diff = boolDiff(existing.AutoRotateCertificateKey, r.AutoRotateCertificateKey)
@@ -1643,6 +2469,12 @@ func (db *Database) SaveRealm(r *Realm, actor Auditable) error {
 				audits = append(audits, audit)
 			}
 
+			if before, after := hstoreString(existing.ENXLinkParams), hstoreString(r.ENXLinkParams); before != after {
+				audit := BuildAuditEntry(actor, "updated ENX link parameters", r, r.ID)
+				audit.Diff = stringDiff(before, after)
+				audits = append(audits, audit)
+			}
+
 			if existing.AbusePreventionEnabled != r.AbusePreventionEnabled {
 				audit := BuildAuditEntry(actor, "updated enable abuse prevention", r, r.ID)
 				audit.Diff = boolDiff(existing.AbusePreventionEnabled, r.AbusePreventionEnabled)
@@ -1660,6 +2492,30 @@ func (db *Database) SaveRealm(r *Realm, actor Auditable) error {
 				audit.Diff = float32Diff(existing.AbusePreventionLimitFactor, r.AbusePreventionLimitFactor)
 				audits = append(audits, audit)
 			}
+
+			if existing.DailyUserIssueLimit != r.DailyUserIssueLimit {
+				audit := BuildAuditEntry(actor, "updated daily user issue limit", r, r.ID)
+				audit.Diff = uintDiff(existing.DailyUserIssueLimit, r.DailyUserIssueLimit)
+				audits = append(audits, audit)
+			}
+
+			if existing.DailyAPIKeyIssueLimit != r.DailyAPIKeyIssueLimit {
+				audit := BuildAuditEntry(actor, "updated daily API key issue limit", r, r.ID)
+				audit.Diff = uintDiff(existing.DailyAPIKeyIssueLimit, r.DailyAPIKeyIssueLimit)
+				audits = append(audits, audit)
+			}
+
+			if existing.UserReportWebhookURL != r.UserReportWebhookURL {
+				audit := BuildAuditEntry(actor, "updated user report webhook URL", r, r.ID)
+				audit.Diff = stringDiff(existing.UserReportWebhookURL, r.UserReportWebhookURL)
+				audits = append(audits, audit)
+			}
+
+			if existing.UserReportWebhookSecret != r.UserReportWebhookSecret {
+				audit := BuildAuditEntry(actor, "updated user report webhook secret", r, r.ID)
+				audit.Diff = auditFieldDiff(r, "UserReportWebhookSecret", existing.UserReportWebhookSecret, r.UserReportWebhookSecret)
+				audits = append(audits, audit)
+			}
 		}
 
 		// Save all audits
@@ -1678,25 +2534,14 @@ func (db *Database) SaveRealm(r *Realm, actor Auditable) error {
 // only time the API key is available is as the string return parameter from
 // invoking this function.
 func (r *Realm) CreateAuthorizedApp(db *Database, app *AuthorizedApp, actor Auditable) (string, error) {
-	fullAPIKey, err := db.GenerateAPIKey(r.ID)
+	fullAPIKey, hmacedKey, preview, err := db.generateAuthorizedAppAPIKey(r.ID)
 	if err != nil {
-		return "", fmt.Errorf("failed to generate API key: %w", err)
-	}
-
-	parts := strings.SplitN(fullAPIKey, ".", 3)
-	if len(parts) != 3 {
-		return "", fmt.Errorf("internal error, key is invalid")
-	}
-	apiKey := parts[0]
-
-	hmacedKey, err := db.GenerateAPIKeyHMAC(apiKey)
-	if err != nil {
-		return "", fmt.Errorf("failed to create hmac: %w", err)
+		return "", err
 	}
 
 	app.RealmID = r.ID
 	app.APIKey = hmacedKey
-	app.APIKeyPreview = apiKey[:6]
+	app.APIKeyPreview = preview
 
 	if err := db.SaveAuthorizedApp(app, actor); err != nil {
 		return "", err
@@ -1709,9 +2554,13 @@ func (r *Realm) CanUpgradeToRealmSigningKeys() bool {
 }
 
 // certificateSigningKMSKeyName is the unique name of the certificate signing
-// key in the upstream KMS.
-func (r *Realm) certificateSigningKMSKeyName() string {
-	return fmt.Sprintf("realm-%d", r.ID)
+// key in the upstream KMS. Keys in the KMS have a fixed algorithm, so
+// algorithms other than ES256 use a separate key.
+func (r *Realm) certificateSigningKMSKeyName(algorithm string) string {
+	if algorithm == "" || algorithm == jwthelper.AlgorithmES256 {
+		return fmt.Sprintf("realm-%d", r.ID)
+	}
+	return fmt.Sprintf("realm-%d-%s", r.ID, strings.ToLower(algorithm))
 }
 
 // smsSigningKMSKeyName is the unique name of the SMS signing key in the
@@ -1725,17 +2574,23 @@ func (r *Realm) smsSigningKMSKeyName() string {
 // key in the key manager fails, the database is not updated. However, if
 // updating the signing key in the database fails, the key is NOT deleted from
 // the key manager.
+//
+// The key is created with the realm's CertificateSigningAlgorithm.
 func (r *Realm) CreateSigningKeyVersion(ctx context.Context, db *Database, actor Auditable) (string, error) {
-	return r.createManagedSigningKey(ctx, db, r.certificateSigningKMSKeyName(), &SigningKey{}, actor)
+	algorithm := r.CertificateSigningAlgorithm
+	if algorithm == "" {
+		algorithm = jwthelper.AlgorithmES256
+	}
+	return r.createManagedSigningKey(ctx, db, r.certificateSigningKMSKeyName(algorithm), algorithm, &SigningKey{}, actor)
 }
 
 // CreateSMSSigningKeyVersion creates a new SMS signing key version on the key manager
 // and saves a reference to the new key version in the database.
 func (r *Realm) CreateSMSSigningKeyVersion(ctx context.Context, db *Database, actor Auditable) (string, error) {
-	return r.createManagedSigningKey(ctx, db, r.smsSigningKMSKeyName(), &SMSSigningKey{}, actor)
+	return r.createManagedSigningKey(ctx, db, r.smsSigningKMSKeyName(), jwthelper.AlgorithmES256, &SMSSigningKey{}, actor)
 }
 
-func (r *Realm) createManagedSigningKey(ctx context.Context, db *Database, keyID string, signingKey RealmManagedKey, actor Auditable) (string, error) {
+func (r *Realm) createManagedSigningKey(ctx context.Context, db *Database, keyID, algorithm string, signingKey RealmManagedKey, actor Auditable) (string, error) {
 	manager := db.signingKeyManager
 	if manager == nil {
 		return "", ErrNoSigningKeyManager
@@ -1769,17 +2624,9 @@ func (r *Realm) createManagedSigningKey(ctx context.Context, db *Database, keyID
 		return "", fmt.Errorf("too many available %s signing keys (maximum: %d)", signingKey.Purpose(), max)
 	}
 
-	// Create the parent key - this interface does not return an error if the key
-	// already exists, so this is safe to run each time.
-	keyName, err := manager.CreateSigningKey(ctx, parent, name)
+	version, err := createSigningKeyVersion(ctx, manager, parent, name, algorithm)
 	if err != nil {
-		return "", fmt.Errorf("failed to create signing key: %w", err)
-	}
-
-	// Create a new key version. This returns the full version name.
-	version, err := manager.CreateKeyVersion(ctx, keyName)
-	if err != nil {
-		return "", fmt.Errorf("failed to create signing key version: %w", err)
+		return "", err
 	}
 
 	// Drop a log message for debugging.
@@ -1830,6 +2677,36 @@ func (r *Realm) createManagedSigningKey(ctx context.Context, db *Database, keyID
 	return signingKey.GetKID(), nil
 }
 
+// createSigningKeyVersion creates the named signing key in the parent, if it
+// does not already exist, and a new version of it. It returns the full version
+// name.
+func createSigningKeyVersion(ctx context.Context, manager keys.SigningKeyManager, parent, name, algorithm string) (string, error) {
+	// Create the parent key - this interface does not return an error if the key
+	// already exists, so this is safe to run each time. The base interface only
+	// creates ES256 keys.
+	var keyName string
+	var err error
+	if algorithm == jwthelper.AlgorithmES256 {
+		keyName, err = manager.CreateSigningKey(ctx, parent, name)
+	} else {
+		creator, ok := manager.(AlgorithmSigningKeyCreator)
+		if !ok {
+			return "", fmt.Errorf("%w: %s", ErrUnsupportedSigningAlgorithm, algorithm)
+		}
+		keyName, err = creator.CreateSigningKeyWithAlgorithm(ctx, parent, name, algorithm)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to create signing key: %w", err)
+	}
+
+	// Create a new key version. This returns the full version name.
+	version, err := manager.CreateKeyVersion(ctx, keyName)
+	if err != nil {
+		return "", fmt.Errorf("failed to create signing key version: %w", err)
+	}
+	return version, nil
+}
+
 // DestroySigningKeyVersion destroys the given key version in both the database
 // and the key manager. ID is the primary key ID from the database. If the id
 // does not exist, it does nothing.
@@ -1866,6 +2743,12 @@ func (r *Realm) destroyManagedSigningKey(ctx context.Context, db *Database, id i
 			return fmt.Errorf("cannot destroy active %s signing key", signingKey.Purpose())
 		}
 
+		// Migrated keys are destroyed in their original key manager by the
+		// migrate-keys command once their grace period has passed.
+		if signingKey.MigratedPublicKey() != "" {
+			return fmt.Errorf("cannot destroy %s signing key that was migrated to another key manager", signingKey.Purpose())
+		}
+
 		// Delete the signing key from the key manager - we want to do this in the
 		// transaction so, if it fails, we can rollback and try again.
 		if err := manager.DestroyKeyVersion(ctx, signingKey.ManagedKeyID()); err != nil {
@@ -1892,11 +2775,17 @@ func (r *Realm) destroyManagedSigningKey(ctx context.Context, db *Database, id i
 	return nil
 }
 
-// Stats returns the usage statistics for this realm. If no stats exist, returns
-// an empty array.
+// Stats returns the usage statistics for this realm over the stats display
+// period. If no stats exist, returns an empty array.
 func (r *Realm) Stats(db *Database) (RealmStats, error) {
-	stop := timeutils.UTCMidnight(time.Now())
+	stop := r.StatsToday()
 	start := stop.Add(project.StatsDisplayDays * -24 * time.Hour)
+	return r.StatsBetween(db, start, stop)
+}
+
+// StatsBetween returns the usage statistics for this realm for each day from
+// start to stop, inclusive, newest first. Days without stats are zero.
+func (r *Realm) StatsBetween(db *Database, start, stop time.Time) (RealmStats, error) {
 	if start.After(stop) {
 		return nil, ErrBadDateRange
 	}
@@ -1913,6 +2802,8 @@ func (r *Realm) Stats(db *Database) (RealmStats, error) {
 			COALESCE(s.tokens_claimed, 0) AS tokens_claimed,
 			COALESCE(s.tokens_invalid, 0) AS tokens_invalid,
 			COALESCE(s.user_report_tokens_claimed, 0) AS user_report_tokens_claimed,
+			COALESCE(s.code_collisions, 0) AS code_collisions,
+			COALESCE(s.sms_suppressed, 0) AS sms_suppressed,
 			COALESCE(s.code_claim_age_distribution, array[]::integer[]) AS code_claim_age_distribution,
 			COALESCE(s.code_claim_mean_age, 0) AS code_claim_mean_age,
 			COALESCE(s.codes_invalid_by_os, array[0,0,0]::bigint[]) AS codes_invalid_by_os,
@@ -2049,7 +2940,7 @@ func (r *Realm) StatsCached(ctx context.Context, db *Database, cacher cache.Cach
 // ExternalIssuerStats returns the external issuer stats for this realm. If no
 // stats exist, returns an empty slice.
 func (r *Realm) ExternalIssuerStats(db *Database) (ExternalIssuerStats, error) {
-	stop := timeutils.UTCMidnight(time.Now())
+	stop := r.StatsToday()
 	start := stop.Add(project.StatsDisplayDays * -24 * time.Hour)
 	if start.After(stop) {
 		return nil, ErrBadDateRange
@@ -2109,9 +3000,10 @@ func (r *Realm) ExternalIssuerStatsCached(ctx context.Context, db *Database, cac
 }
 
 // RecentSMSErrorsCount returns the number of SMS errors that have occurred in
-// the current UTC day, excluding any of the ignored codes.
+// the current day in the realm's stats timezone, excluding any of the ignored
+// codes.
 func (r *Realm) RecentSMSErrorsCount(db *Database, ignored []string) (int64, error) {
-	today := timeutils.UTCMidnight(time.Now())
+	today := r.StatsToday()
 
 	sql := `
 		SELECT
@@ -2143,10 +3035,17 @@ func (r *Realm) RecentSMSErrorsCount(db *Database, ignored []string) (int64, err
 	return result.Quantity, nil
 }
 
-// SMSErrorStats returns the sms error stats for this realm.
+// SMSErrorStats returns the sms error stats for this realm over the stats
+// display period.
 func (r *Realm) SMSErrorStats(db *Database) (SMSErrorStats, error) {
-	stop := timeutils.UTCMidnight(time.Now())
+	stop := r.StatsToday()
 	start := stop.Add(project.StatsDisplayDays * -24 * time.Hour)
+	return r.SMSErrorStatsBetween(db, start, stop)
+}
+
+// SMSErrorStatsBetween returns the sms error stats for this realm for each day
+// from start to stop, inclusive.
+func (r *Realm) SMSErrorStatsBetween(db *Database, start, stop time.Time) (SMSErrorStats, error) {
 	if start.After(stop) {
 		return nil, ErrBadDateRange
 	}
@@ -2204,7 +3103,7 @@ func (r *Realm) SMSErrorStatsCached(ctx context.Context, db *Database, cacher ca
 
 // UserStats returns the stats by user.
 func (r *Realm) UserStats(db *Database) (RealmUserStats, error) {
-	stop := timeutils.UTCMidnight(time.Now())
+	stop := r.StatsToday()
 	start := stop.Add(project.StatsDisplayDays * -24 * time.Hour)
 	if start.After(stop) {
 		return nil, ErrBadDateRange
@@ -2287,6 +3186,26 @@ func (r *Realm) QuotaKey(hmacKey []byte) (string, error) {
 	return fmt.Sprintf("realm:quota:%s", dig), nil
 }
 
+// UserQuotaKey returns the limiter key for the user's daily issuance limit in
+// this realm.
+func (r *Realm) UserQuotaKey(userID uint, hmacKey []byte) (string, error) {
+	dig, err := digest.HMAC(fmt.Sprintf("%d:%d", r.ID, userID), hmacKey)
+	if err != nil {
+		return "", fmt.Errorf("failed to create user quota key: %w", err)
+	}
+	return fmt.Sprintf("realm:user_quota:%s", dig), nil
+}
+
+// APIKeyQuotaKey returns the limiter key for the API key's daily issuance
+// limit.
+func (r *Realm) APIKeyQuotaKey(authorizedAppID uint, hmacKey []byte) (string, error) {
+	dig, err := digest.HMAC(fmt.Sprintf("%d:%d", r.ID, authorizedAppID), hmacKey)
+	if err != nil {
+		return "", fmt.Errorf("failed to create api key quota key: %w", err)
+	}
+	return fmt.Sprintf("realm:api_key_quota:%s", dig), nil
+}
+
 // RecordChaffEvent records that the realm received a chaff event on the given
 // date. This is not a counter, but a boolean: chaff was either received or it
 // wasn't. This is used to help server operators identify if an app is not
@@ -2336,6 +3255,16 @@ func (r *Realm) ListChaffEvents(db *Database) ([]*RealmChaffEvent, error) {
 	return events, nil
 }
 
+// ToCountryList converts the newline-separated and/or comma-separated list of
+// country codes into a sorted, de-duplicated array of lowercase strings.
+func ToCountryList(s string) []string {
+	var codes []string
+	for _, line := range strings.Split(s, "\n") {
+		codes = append(codes, strings.Split(line, ",")...)
+	}
+	return normalizeCountryList(codes)
+}
+
 // ToCIDRList converts the newline-separated and/or comma-separated CIDR list
 // into an array of strings.
 func ToCIDRList(s string) ([]string, error) {
@@ -2371,3 +3300,9 @@ func ToCIDRList(s string) ([]string, error) {
 	sort.Strings(cidrs)
 	return cidrs, nil
 }
+
+// isHostname returns true if the value is a valid, lowercase hostname without
+// a scheme, port, or path.
+func isHostname(s string) bool {
+	return len(s) <= 253 && hostnameRegex.MatchString(s)
+}
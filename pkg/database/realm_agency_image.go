@@ -0,0 +1,205 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package database
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+// RealmAgencyImage is an agency image uploaded through the realm branding API.
+// Each realm has at most one. It is served publicly so the ENX Express user
+// report pages (and CDNs in front of them) can load it.
+type RealmAgencyImage struct {
+	// RealmID is the realm that owns the image.
+	RealmID uint `gorm:"column:realm_id; type:integer; primary_key;"`
+
+	// ContentType is the MIME type of the image.
+	ContentType string `gorm:"column:content_type; type:text; not null;"`
+
+	// Data is the image.
+	Data []byte `gorm:"column:data; type:bytea; not null;"`
+
+	// Checksum is the hex-encoded SHA-256 of Data. It is part of the image URL,
+	// so a new image gets a new URL and cached copies never need purging.
+	Checksum string `gorm:"column:checksum; type:text; not null;"`
+
+	// CreatedAt is when the image was uploaded.
+	CreatedAt time.Time `gorm:"column:created_at; type:timestamp with time zone; not null;"`
+}
+
+// TableName sets the table name.
+func (RealmAgencyImage) TableName() string {
+	return "realm_agency_images"
+}
+
+// NewRealmAgencyImage creates a new agency image for the realm, computing its
+// checksum.
+func NewRealmAgencyImage(realmID uint, contentType string, data []byte) *RealmAgencyImage {
+	sum := sha256.Sum256(data)
+	return &RealmAgencyImage{
+		RealmID:     realmID,
+		ContentType: contentType,
+		Data:        data,
+		Checksum:    hex.EncodeToString(sum[:]),
+	}
+}
+
+// Path is the path at which the image is served.
+func (i *RealmAgencyImage) Path() string {
+	return fmt.Sprintf("/branding/%d/agency-image/%s", i.RealmID, i.Checksum)
+}
+
+// FindRealmAgencyImage finds the agency image for the realm with the given
+// checksum. It returns NotFound if the realm's image has since been replaced.
+func (db *Database) FindRealmAgencyImage(realmID uint, checksum string) (*RealmAgencyImage, error) {
+	var image RealmAgencyImage
+	if err := db.db.
+		Model(&RealmAgencyImage{}).
+		Where("realm_id = ? AND checksum = ?", realmID, checksum).
+		First(&image).
+		Error; err != nil {
+		return nil, err
+	}
+	return &image, nil
+}
+
+// SaveRealmAgencyImage stores the image as the realm's agency image, replacing
+// any previous image, and sets the realm's AgencyImage to imageURL. The realm's
+// branding becomes managed, so the ENX-Express sync no longer overwrites it.
+func (db *Database) SaveRealmAgencyImage(r *Realm, image *RealmAgencyImage, imageURL string, actor Auditable) error {
+	if actor == nil {
+		return ErrMissingActor
+	}
+
+	sql := `
+		INSERT INTO realm_agency_images (realm_id, content_type, data, checksum, created_at)
+			VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (realm_id) DO UPDATE
+			SET content_type = EXCLUDED.content_type,
+				data = EXCLUDED.data,
+				checksum = EXCLUDED.checksum,
+				created_at = EXCLUDED.created_at
+	`
+
+	return db.db.Transaction(func(tx *gorm.DB) error {
+		image.CreatedAt = time.Now().UTC()
+		if err := tx.Exec(sql, r.ID, image.ContentType, image.Data, image.Checksum, image.CreatedAt).Error; err != nil {
+			return fmt.Errorf("failed to save agency image: %w", err)
+		}
+
+		if err := updateRealmAgencyImage(tx, r, imageURL, actor); err != nil {
+			return err
+		}
+		return nil
+	})
+}
+
+// DeleteRealmAgencyImage deletes the realm's uploaded agency image and clears
+// the realm's AgencyImage.
+func (db *Database) DeleteRealmAgencyImage(r *Realm, actor Auditable) error {
+	if actor == nil {
+		return ErrMissingActor
+	}
+
+	return db.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.
+			Where("realm_id = ?", r.ID).
+			Delete(&RealmAgencyImage{}).
+			Error; err != nil {
+			return fmt.Errorf("failed to delete agency image: %w", err)
+		}
+
+		if err := updateRealmAgencyImage(tx, r, "", actor); err != nil {
+			return err
+		}
+		return nil
+	})
+}
+
+// ResetRealmAgencyBranding returns control of the realm's agency branding to
+// the ENX-Express sync. It deletes the realm's uploaded agency image, if any,
+// and clears the AgencyImage that pointed to it. The other branding values are
+// kept until the next sync replaces them.
+func (db *Database) ResetRealmAgencyBranding(r *Realm, actor Auditable) error {
+	if actor == nil {
+		return ErrMissingActor
+	}
+
+	return db.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.
+			Where("realm_id = ?", r.ID).
+			Delete(&RealmAgencyImage{})
+		if err := result.Error; err != nil {
+			return fmt.Errorf("failed to delete agency image: %w", err)
+		}
+
+		updates := map[string]interface{}{
+			"agency_branding_managed": false,
+		}
+		if result.RowsAffected > 0 {
+			updates["agency_image"] = nil
+		}
+		if err := tx.
+			Model(&Realm{}).
+			Where("id = ?", r.ID).
+			UpdateColumns(updates).
+			Error; err != nil {
+			return fmt.Errorf("failed to reset realm agency branding: %w", err)
+		}
+
+		audit := BuildAuditEntry(actor, "reset agency branding", r, r.ID)
+		if err := tx.Save(audit).Error; err != nil {
+			return fmt.Errorf("failed to save audit: %w", err)
+		}
+
+		if result.RowsAffected > 0 {
+			r.AgencyImage = ""
+			r.AgencyImagePtr = nil
+		}
+		r.AgencyBrandingManaged = false
+		return nil
+	})
+}
+
+// updateRealmAgencyImage sets the realm's AgencyImage, marks its branding as
+// managed, and audits the change.
+func updateRealmAgencyImage(tx *gorm.DB, r *Realm, imageURL string, actor Auditable) error {
+	if err := tx.
+		Model(&Realm{}).
+		Where("id = ?", r.ID).
+		UpdateColumns(map[string]interface{}{
+			"agency_image":            stringPtr(imageURL),
+			"agency_branding_managed": true,
+		}).
+		Error; err != nil {
+		return fmt.Errorf("failed to update realm agency image: %w", err)
+	}
+
+	audit := BuildAuditEntry(actor, "updated agency image", r, r.ID)
+	audit.Diff = stringDiff(r.AgencyImage, imageURL)
+	if err := tx.Save(audit).Error; err != nil {
+		return fmt.Errorf("failed to save audit: %w", err)
+	}
+
+	r.AgencyImage = imageURL
+	r.AgencyImagePtr = stringPtr(imageURL)
+	r.AgencyBrandingManaged = true
+	return nil
+}
@@ -0,0 +1,162 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package database
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+
+	"github.com/google/exposure-notifications-verification-server/internal/project"
+	"github.com/jinzhu/gorm/dialects/postgres"
+	"github.com/lib/pq"
+)
+
+// CertificateClaimsMax is the maximum number of additional claim names a
+// realm may be approved for.
+const CertificateClaimsMax = 10
+
+var (
+	// certificateClaimNameRegex matches the allowed names of additional
+	// certificate claims.
+	certificateClaimNameRegex = regexp.MustCompile(`\A[A-Za-z][A-Za-z0-9_.-]{0,63}\z`)
+
+	// reservedCertificateClaims are the claims the server sets on every
+	// verification certificate. They can never be overridden.
+	reservedCertificateClaims = map[string]struct{}{
+		"aud":                  {},
+		"exp":                  {},
+		"iat":                  {},
+		"iss":                  {},
+		"jti":                  {},
+		"nbf":                  {},
+		"sub":                  {},
+		"reportType":           {},
+		"symptomOnsetInterval": {},
+		"tekmac":               {},
+		"trisk":                {},
+	}
+)
+
+// IsReservedCertificateClaim returns true if the claim name is set by the
+// server on every verification certificate.
+func IsReservedCertificateClaim(name string) bool {
+	_, ok := reservedCertificateClaims[name]
+	return ok
+}
+
+// CertificateClaimAllowed returns true if a system administrator approved the
+// claim name for this realm's verification certificates.
+func (r *Realm) CertificateClaimAllowed(name string) bool {
+	if IsReservedCertificateClaim(name) {
+		return false
+	}
+	for _, v := range r.AllowedCertificateClaims {
+		if v == name {
+			return true
+		}
+	}
+	return false
+}
+
+// StaticCertificateClaims returns the realm's static certificate claims whose
+// names are still approved.
+func (r *Realm) StaticCertificateClaims() map[string]interface{} {
+	claims := make(map[string]interface{}, len(r.CertificateClaims))
+	for k, v := range r.CertificateClaims {
+		if r.CertificateClaimAllowed(k) {
+			claims[k] = stringValue(v)
+		}
+	}
+	return claims
+}
+
+// PruneCertificateClaims removes static claims whose names are no longer
+// approved for the realm. System administrators call this after changing the
+// approved names so the realm remains valid.
+func (r *Realm) PruneCertificateClaims() {
+	for k := range r.CertificateClaims {
+		if !r.CertificateClaimAllowed(k) {
+			delete(r.CertificateClaims, k)
+		}
+	}
+}
+
+// validateAllowedCertificateClaims trims, sorts, and checks the approved claim
+// names.
+func (r *Realm) validateAllowedCertificateClaims(names pq.StringArray) pq.StringArray {
+	if len(names) == 0 {
+		return nil
+	}
+
+	seen := make(map[string]struct{}, len(names))
+	result := make(pq.StringArray, 0, len(names))
+	for _, name := range names {
+		name = project.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		if _, ok := seen[name]; ok {
+			continue
+		}
+		seen[name] = struct{}{}
+
+		if !certificateClaimNameRegex.MatchString(name) {
+			r.AddError("allowedCertificateClaims", fmt.Sprintf("claim name %q must start with a letter and contain only letters, digits, periods, underscores, and dashes (max 64)", name))
+			continue
+		}
+		if IsReservedCertificateClaim(name) {
+			r.AddError("allowedCertificateClaims", fmt.Sprintf("claim name %q is reserved", name))
+			continue
+		}
+		result = append(result, name)
+	}
+
+	if len(result) > CertificateClaimsMax {
+		r.AddError("allowedCertificateClaims", fmt.Sprintf("cannot have more than %d claims", CertificateClaimsMax))
+	}
+
+	sort.Strings(result)
+	return result
+}
+
+// validateCertificateClaims trims the static certificate claims and checks
+// that each one is approved and has a value.
+func (r *Realm) validateCertificateClaims(claims postgres.Hstore) postgres.Hstore {
+	if len(claims) == 0 {
+		return nil
+	}
+
+	trimmed := make(postgres.Hstore, len(claims))
+	for k, v := range claims {
+		k = project.TrimSpace(k)
+		if !r.CertificateClaimAllowed(k) {
+			r.AddError("certificateClaims", fmt.Sprintf("claim %q is not approved for this realm; contact a system administrator", k))
+			continue
+		}
+		if _, ok := trimmed[k]; ok {
+			r.AddError("certificateClaims", fmt.Sprintf("claim %q is listed more than once", k))
+			continue
+		}
+
+		val := project.TrimSpaceAndNonPrintable(stringValue(v))
+		if val == "" {
+			r.AddError("certificateClaims", fmt.Sprintf("claim %q must have a value", k))
+			continue
+		}
+		trimmed[k] = &val
+	}
+	return trimmed
+}
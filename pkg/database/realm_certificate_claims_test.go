@@ -0,0 +1,144 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package database
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/jinzhu/gorm/dialects/postgres"
+	"github.com/lib/pq"
+)
+
+func TestRealm_ValidateAllowedCertificateClaims(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name  string
+		input pq.StringArray
+		want  pq.StringArray
+		err   string
+	}{
+		{
+			name:  "empty",
+			input: nil,
+			want:  nil,
+		},
+		{
+			name:  "trims_sorts_dedupes",
+			input: pq.StringArray{" region ", "lab", "region", ""},
+			want:  pq.StringArray{"lab", "region"},
+		},
+		{
+			name:  "invalid_name",
+			input: pq.StringArray{"1region"},
+			err:   "must start with a letter",
+		},
+		{
+			name:  "reserved",
+			input: pq.StringArray{"tekmac"},
+			err:   "is reserved",
+		},
+		{
+			name:  "too_many",
+			input: pq.StringArray{"a", "b", "c", "d", "e", "f", "g", "h", "i", "j", "k"},
+			err:   "cannot have more than",
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			var r Realm
+			got := r.validateAllowedCertificateClaims(tc.input)
+
+			errs := strings.Join(r.ErrorsFor("allowedCertificateClaims"), ", ")
+			if tc.err != "" {
+				if !strings.Contains(errs, tc.err) {
+					t.Errorf("expected %q to contain %q", errs, tc.err)
+				}
+				return
+			}
+			if errs != "" {
+				t.Fatalf("unexpected errors: %s", errs)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("mismatch (-want, +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestRealm_CertificateClaims(t *testing.T) {
+	t.Parallel()
+
+	value := func(s string) *string { return &s }
+
+	t.Run("validate", func(t *testing.T) {
+		t.Parallel()
+
+		r := &Realm{AllowedCertificateClaims: pq.StringArray{"region", "lab"}}
+		got := r.validateCertificateClaims(postgres.Hstore{
+			" region ": value(" us-wa "),
+		})
+		if errs := r.ErrorMessages(); len(errs) > 0 {
+			t.Fatalf("unexpected errors: %q", errs)
+		}
+		if got, want := stringValue(got["region"]), "us-wa"; got != want {
+			t.Errorf("expected %q to be %q", got, want)
+		}
+
+		r = &Realm{AllowedCertificateClaims: pq.StringArray{"region", "lab"}}
+		r.validateCertificateClaims(postgres.Hstore{
+			"other": value("x"),
+			"lab":   value(" "),
+		})
+		errs := strings.Join(r.ErrorsFor("certificateClaims"), ", ")
+		for _, want := range []string{`"other" is not approved`, `"lab" must have a value`} {
+			if !strings.Contains(errs, want) {
+				t.Errorf("expected %q to contain %q", errs, want)
+			}
+		}
+	})
+
+	t.Run("static_and_prune", func(t *testing.T) {
+		t.Parallel()
+
+		r := &Realm{
+			AllowedCertificateClaims: pq.StringArray{"region"},
+			CertificateClaims: postgres.Hstore{
+				"region": value("us-wa"),
+				"lab":    value("a"),
+			},
+		}
+
+		want := map[string]interface{}{"region": "us-wa"}
+		if diff := cmp.Diff(want, r.StaticCertificateClaims()); diff != "" {
+			t.Errorf("mismatch (-want, +got):\n%s", diff)
+		}
+
+		r.PruneCertificateClaims()
+		if _, ok := r.CertificateClaims["lab"]; ok {
+			t.Errorf("expected %q to be pruned", "lab")
+		}
+		if _, ok := r.CertificateClaims["region"]; !ok {
+			t.Errorf("expected %q to be kept", "region")
+		}
+	})
+}
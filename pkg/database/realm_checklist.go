@@ -0,0 +1,178 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package database
+
+import (
+	"fmt"
+)
+
+// RealmChecklist item IDs. These are stable and returned in the JSON API.
+const (
+	RealmChecklistSMS        = "sms"
+	RealmChecklistSigningKey = "signing_key"
+	RealmChecklistDeviceKey  = "device_api_key"
+	RealmChecklistMobileApp  = "mobile_app"
+	RealmChecklistCodeIssued = "code_issued"
+)
+
+// RealmChecklistItem is a single onboarding step for a realm.
+type RealmChecklistItem struct {
+	// ID is the stable identifier for the step.
+	ID string `json:"id"`
+
+	// Title and Description are human-readable explanations of the step.
+	Title       string `json:"title"`
+	Description string `json:"description"`
+
+	// Done indicates the step is complete.
+	Done bool `json:"done"`
+
+	// FixURL is the path in the UI where the step can be completed.
+	FixURL string `json:"fixURL"`
+}
+
+// RealmChecklist is the list of onboarding steps for a realm. It is computed
+// from the realm's current configuration and is never stored.
+type RealmChecklist struct {
+	Items []*RealmChecklistItem `json:"items"`
+}
+
+// Complete returns true if all steps on the checklist are done.
+func (c *RealmChecklist) Complete() bool {
+	return c.Remaining() == 0
+}
+
+// Remaining returns the number of steps that are not done.
+func (c *RealmChecklist) Remaining() int {
+	var n int
+	for _, item := range c.Items {
+		if !item.Done {
+			n++
+		}
+	}
+	return n
+}
+
+// Item returns the checklist item with the given ID, or nil if it does not
+// exist.
+func (c *RealmChecklist) Item(id string) *RealmChecklistItem {
+	for _, item := range c.Items {
+		if item.ID == id {
+			return item
+		}
+	}
+	return nil
+}
+
+// Checklist computes the onboarding checklist for the realm.
+func (r *Realm) Checklist(db *Database) (*RealmChecklist, error) {
+	hasSMS, err := r.HasSMSConfig(db)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check sms config: %w", err)
+	}
+
+	// Realms that do not use realm-specific signing keys use the system key,
+	// which is always available.
+	hasSigningKey := true
+	if r.UseRealmCertificateKey {
+		if _, err := r.CurrentSigningKey(db); err != nil {
+			if !IsNotFound(err) {
+				return nil, err
+			}
+			hasSigningKey = false
+		}
+	}
+
+	var deviceKeys int64
+	if err := db.db.
+		Model(&AuthorizedApp{}).
+		Where("realm_id = ?", r.ID).
+		Where("api_key_type = ?", APIKeyTypeDevice).
+		Where("deleted_at IS NULL").
+		Count(&deviceKeys).
+		Error; err != nil && !IsNotFound(err) {
+		return nil, fmt.Errorf("failed to count device api keys: %w", err)
+	}
+
+	var mobileApps int64
+	if err := db.db.
+		Model(&MobileApp{}).
+		Where("realm_id = ?", r.ID).
+		Where("deleted_at IS NULL").
+		Count(&mobileApps).
+		Error; err != nil && !IsNotFound(err) {
+		return nil, fmt.Errorf("failed to count mobile apps: %w", err)
+	}
+
+	codesIssued, err := r.hasIssuedCodes(db)
+	if err != nil {
+		return nil, err
+	}
+
+	return &RealmChecklist{
+		Items: []*RealmChecklistItem{
+			{
+				ID:          RealmChecklistSMS,
+				Title:       "Configure SMS",
+				Description: "Add SMS credentials or use the system SMS configuration so codes can be sent to patients.",
+				Done:        hasSMS,
+				FixURL:      "/realm/settings#sms",
+			},
+			{
+				ID:          RealmChecklistSigningKey,
+				Title:       "Activate a signing key",
+				Description: "Create and activate a realm certificate signing key.",
+				Done:        hasSigningKey,
+				FixURL:      "/realm/keys",
+			},
+			{
+				ID:          RealmChecklistDeviceKey,
+				Title:       "Create a device API key",
+				Description: "Create at least one device API key for your mobile app to verify codes.",
+				Done:        deviceKeys > 0,
+				FixURL:      "/realm/apikeys/new",
+			},
+			{
+				ID:          RealmChecklistMobileApp,
+				Title:       "Register a mobile app",
+				Description: "Register your iOS and Android apps so verification links open the app.",
+				Done:        mobileApps > 0,
+				FixURL:      "/realm/mobile-apps/new",
+			},
+			{
+				ID:          RealmChecklistCodeIssued,
+				Title:       "Issue a test code",
+				Description: "Issue a verification code to confirm the realm is set up end to end.",
+				Done:        codesIssued,
+				FixURL:      "/codes/issue",
+			},
+		},
+	}, nil
+}
+
+// hasIssuedCodes returns true if the realm has ever issued a verification
+// code, according to the realm's stats.
+func (r *Realm) hasIssuedCodes(db *Database) (bool, error) {
+	var count int64
+	if err := db.db.
+		Model(&RealmStat{}).
+		Where("realm_id = ?", r.ID).
+		Where("codes_issued > 0").
+		Count(&count).
+		Error; err != nil && !IsNotFound(err) {
+		return false, fmt.Errorf("failed to check issued codes: %w", err)
+	}
+	return count > 0, nil
+}
@@ -0,0 +1,133 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package database
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/exposure-notifications-verification-server/internal/project"
+	"github.com/google/exposure-notifications-verification-server/pkg/sms"
+)
+
+func TestRealm_Checklist(t *testing.T) {
+	t.Parallel()
+
+	db, _ := testDatabaseInstance.NewDatabase(t, nil)
+	db.config.KeyRing = filepath.Join(project.Root(), "local", "test", "realm")
+
+	realm := NewRealmWithDefaults("checklist")
+	realm.RegionCode = "CL"
+	if err := db.SaveRealm(realm, SystemTest); err != nil {
+		t.Fatal(err, realm.ErrorMessages())
+	}
+
+	expectDone := func(tb testing.TB, exp map[string]bool) {
+		tb.Helper()
+
+		checklist, err := realm.Checklist(db)
+		if err != nil {
+			tb.Fatal(err)
+		}
+
+		for id, want := range exp {
+			item := checklist.Item(id)
+			if item == nil {
+				tb.Fatalf("missing checklist item %q", id)
+			}
+			if got := item.Done; got != want {
+				tb.Errorf("expected %q done to be %t, got %t", id, want, got)
+			}
+		}
+	}
+
+	// Brand new realm.
+	expectDone(t, map[string]bool{
+		RealmChecklistSMS:        false,
+		RealmChecklistSigningKey: true,
+		RealmChecklistDeviceKey:  false,
+		RealmChecklistMobileApp:  false,
+		RealmChecklistCodeIssued: false,
+	})
+
+	// Realm-specific signing keys require an active key.
+	realm.UseRealmCertificateKey = true
+	realm.CertificateIssuer = "iss"
+	realm.CertificateAudience = "aud"
+	if err := db.SaveRealm(realm, SystemTest); err != nil {
+		t.Fatal(err, realm.ErrorMessages())
+	}
+	expectDone(t, map[string]bool{
+		RealmChecklistSigningKey: false,
+	})
+
+	if err := db.SaveSMSConfig(&SMSConfig{
+		RealmID:          realm.ID,
+		ProviderType:     sms.ProviderType("TWILIO"),
+		TwilioAccountSid: "abc123",
+		TwilioAuthToken:  "def123",
+		TwilioFromNumber: "+15005550006",
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := realm.CreateAuthorizedApp(db, &AuthorizedApp{
+		Name:       "device",
+		APIKeyType: APIKeyTypeDevice,
+	}, SystemTest); err != nil {
+		t.Fatal(err)
+	}
+
+	app := &MobileApp{
+		Name:  "app1",
+		Realm: realm,
+		URL:   "https://example1.com",
+		OS:    OSTypeIOS,
+		AppID: "app1",
+	}
+	if err := db.SaveMobileApp(app, SystemTest); err != nil {
+		t.Fatal(err, app.ErrorMessages())
+	}
+
+	if err := db.RawDB().Create(&RealmStat{
+		Date:        time.Now().UTC().Truncate(24 * time.Hour),
+		RealmID:     realm.ID,
+		CodesIssued: 1,
+	}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	expectDone(t, map[string]bool{
+		RealmChecklistSMS:        true,
+		RealmChecklistSigningKey: false,
+		RealmChecklistDeviceKey:  true,
+		RealmChecklistMobileApp:  true,
+		RealmChecklistCodeIssued: true,
+	})
+
+	ctx := project.TestContext(t)
+	if _, err := realm.CreateSigningKeyVersion(ctx, db, SystemTest); err != nil {
+		t.Fatal(err)
+	}
+
+	checklist, err := realm.Checklist(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !checklist.Complete() {
+		t.Errorf("expected checklist to be complete, %d remaining", checklist.Remaining())
+	}
+}
@@ -0,0 +1,226 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package database
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+// RealmCleanupProgress is the durable marker of when cleanup last finished
+// purging a realm's data. Cleanup processes the realms that finished longest
+// ago first, so realms that were not reached before a run's deadline are
+// processed first on the next run.
+type RealmCleanupProgress struct {
+	// RealmID is the realm that was cleaned.
+	RealmID uint `gorm:"column:realm_id; type:integer; primary_key;"`
+
+	// CompletedAt is when cleanup last finished the realm.
+	CompletedAt time.Time `gorm:"column:completed_at; type:timestamp with time zone; not null;"`
+
+	// Purged is the number of records purged or recycled in that run.
+	Purged int64 `gorm:"column:purged; type:bigint; not null; default:0;"`
+}
+
+// TableName sets the table name.
+func (RealmCleanupProgress) TableName() string {
+	return "realm_cleanup_progress"
+}
+
+// ListRealmIDsForCleanup returns the IDs of all realms, ordered so realms that
+// have never been cleaned come first, followed by the realms that were cleaned
+// longest ago.
+func (db *Database) ListRealmIDsForCleanup() ([]uint, error) {
+	var ids []uint
+	if err := db.db.
+		Table("realms").
+		Joins("LEFT JOIN realm_cleanup_progress ON realm_cleanup_progress.realm_id = realms.id").
+		Order("realm_cleanup_progress.completed_at ASC NULLS FIRST, realms.id ASC").
+		Pluck("realms.id", &ids).
+		Error; err != nil {
+		if IsNotFound(err) {
+			return ids, nil
+		}
+		return nil, fmt.Errorf("failed to list realms for cleanup: %w", err)
+	}
+	return ids, nil
+}
+
+// FindRealmCleanupProgress returns the cleanup marker for the realm. It
+// returns NotFound if the realm has never been cleaned.
+func (db *Database) FindRealmCleanupProgress(realmID uint) (*RealmCleanupProgress, error) {
+	var progress RealmCleanupProgress
+	if err := db.db.
+		Model(&RealmCleanupProgress{}).
+		Where("realm_id = ?", realmID).
+		First(&progress).
+		Error; err != nil {
+		return nil, err
+	}
+	return &progress, nil
+}
+
+// MarkRealmCleanupComplete records that cleanup finished the realm, having
+// purged the given number of records.
+func (db *Database) MarkRealmCleanupComplete(realmID uint, purged int64) error {
+	sql := `
+		INSERT INTO realm_cleanup_progress (realm_id, completed_at, purged)
+			VALUES ($1, $2, $3)
+		ON CONFLICT (realm_id) DO UPDATE
+			SET completed_at = EXCLUDED.completed_at,
+				purged = EXCLUDED.purged
+	`
+
+	now := time.Now().UTC()
+	if err := db.db.Exec(sql, realmID, now, purged).Error; err != nil {
+		return fmt.Errorf("failed to mark realm %d cleanup complete: %w", realmID, err)
+	}
+	return nil
+}
+
+// RecycleRealmVerificationCodes is RecycleVerificationCodes for a single realm.
+// Codes are updated in batches of at most batchSize rows, each in its own
+// statement, so locks are held briefly. It stops between batches if the
+// context is done.
+func (db *Database) RecycleRealmVerificationCodes(ctx context.Context, realmID uint, maxAge time.Duration, batchSize uint) (int64, error) {
+	if maxAge > 0 {
+		maxAge = -1 * maxAge
+	}
+	deleteBefore := time.Now().UTC().Add(maxAge)
+
+	sql := `
+		UPDATE verification_codes SET code = '', long_code = ''
+		WHERE id IN (
+			SELECT id FROM verification_codes
+			WHERE realm_id = $1 AND expires_at < $2 AND long_expires_at < $2
+				AND (code != '' OR long_code != '')
+			LIMIT $3
+		)
+	`
+	return db.execInBatches(ctx, sql, batchSize, realmID, deleteBefore)
+}
+
+// PurgeRealmVerificationCodes is PurgeVerificationCodes for a single realm.
+// Codes are deleted in batches of at most batchSize rows. Each deleted code is
+// recorded as a VerificationCodeHistory in the same statement.
+func (db *Database) PurgeRealmVerificationCodes(ctx context.Context, realmID uint, maxAge time.Duration, batchSize uint) (int64, error) {
+	if maxAge > 0 {
+		maxAge = -1 * maxAge
+	}
+	deleteBefore := time.Now().UTC().Add(maxAge)
+
+	sql := `
+		WITH purged AS (
+			DELETE FROM verification_codes
+			WHERE id IN (
+				SELECT id FROM verification_codes
+				WHERE realm_id = $1 AND expires_at < $2 AND long_expires_at < $2
+				LIMIT $3
+			)
+			RETURNING *
+		)
+		INSERT INTO verification_code_history
+			(realm_id, issue_date, issuer_type, test_type, outcome, claim_latency_minutes, onset_claim_days)
+		SELECT ` + verificationCodeHistorySelect() + `
+		FROM purged
+	`
+	return db.execInBatches(ctx, sql, batchSize, realmID, deleteBefore)
+}
+
+// PurgeRealmTokens is PurgeTokens for a single realm. Tokens are deleted in
+// batches of at most batchSize rows.
+func (db *Database) PurgeRealmTokens(ctx context.Context, realmID uint, maxAge time.Duration, batchSize uint) (int64, error) {
+	if maxAge > 0 {
+		maxAge = -1 * maxAge
+	}
+	deleteBefore := time.Now().UTC().Add(maxAge)
+
+	sql := `
+		DELETE FROM tokens
+		WHERE id IN (
+			SELECT id FROM tokens
+			WHERE realm_id = $1 AND expires_at < $2
+			LIMIT $3
+		)
+	`
+	return db.execInBatches(ctx, sql, batchSize, realmID, deleteBefore)
+}
+
+// purgeWithRealmRetention deletes the model's records whose timeColumn is older
+// than maxAge. Records of realms that set a retention period, in days, in the
+// given realms column are instead deleted once they are older than that
+// period. realmIDColumn is the qualified column, or expression, of the record's
+// realm ID.
+func (db *Database) purgeWithRealmRetention(model interface{}, timeColumn, realmIDColumn, retentionColumn string, maxAge time.Duration, scopes ...func(*gorm.DB) *gorm.DB) (int64, error) {
+	if maxAge > 0 {
+		maxAge = -1 * maxAge
+	}
+	now := time.Now().UTC()
+	deleteBefore := now.Add(maxAge)
+
+	result := db.db.
+		Unscoped().
+		Where(timeColumn+" < ?", deleteBefore).
+		Where(realmIDColumn + " NOT IN (SELECT id FROM realms WHERE " + retentionColumn + " > 0)").
+		Scopes(scopes...).
+		Delete(model)
+	if err := result.Error; err != nil {
+		return 0, err
+	}
+	total := result.RowsAffected
+
+	result = db.db.
+		Unscoped().
+		Where(timeColumn+` < ? - INTERVAL '1 day' * (
+			SELECT `+retentionColumn+` FROM realms
+			WHERE realms.id = `+realmIDColumn+` AND `+retentionColumn+` > 0)`, now).
+		Scopes(scopes...).
+		Delete(model)
+	if err := result.Error; err != nil {
+		return total, err
+	}
+	return total + result.RowsAffected, nil
+}
+
+// execInBatches runs the statement until it affects fewer than batchSize rows.
+// The statement's last parameter must be the batch size. It returns the total
+// number of rows affected, including when it stops early because the context
+// is done.
+func (db *Database) execInBatches(ctx context.Context, sql string, batchSize uint, args ...interface{}) (int64, error) {
+	if batchSize == 0 {
+		return 0, fmt.Errorf("batch size must be positive")
+	}
+	args = append(args, batchSize)
+
+	var total int64
+	for {
+		if err := ctx.Err(); err != nil {
+			return total, err
+		}
+
+		result := db.db.Exec(sql, args...)
+		if err := result.Error; err != nil {
+			return total, err
+		}
+		total += result.RowsAffected
+
+		if result.RowsAffected < int64(batchSize) {
+			return total, nil
+		}
+	}
+}
@@ -0,0 +1,168 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/google/exposure-notifications-verification-server/internal/project"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestDatabase_RealmCleanupProgress(t *testing.T) {
+	t.Parallel()
+
+	db, _ := testDatabaseInstance.NewDatabase(t, nil)
+
+	other := NewRealmWithDefaults("cleanup")
+	if err := db.SaveRealm(other, SystemTest); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := db.FindRealmCleanupProgress(1); !IsNotFound(err) {
+		t.Errorf("expected not found, got %v", err)
+	}
+
+	// Realms that have never been cleaned come first, in ID order.
+	ids, err := db.ListRealmIDsForCleanup()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff([]uint{1, other.ID}, ids); diff != "" {
+		t.Errorf("mismatch (-want, +got):\n%s", diff)
+	}
+
+	// Once the first realm is cleaned, it moves to the end.
+	if err := db.MarkRealmCleanupComplete(1, 5); err != nil {
+		t.Fatal(err)
+	}
+	ids, err = db.ListRealmIDsForCleanup()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff([]uint{other.ID, 1}, ids); diff != "" {
+		t.Errorf("mismatch (-want, +got):\n%s", diff)
+	}
+
+	// The marker is updated in place.
+	if err := db.MarkRealmCleanupComplete(1, 7); err != nil {
+		t.Fatal(err)
+	}
+	progress, err := db.FindRealmCleanupProgress(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := progress.Purged, int64(7); got != want {
+		t.Errorf("expected %d to be %d", got, want)
+	}
+}
+
+func TestDatabase_PurgeRealmVerificationCodes(t *testing.T) {
+	t.Parallel()
+
+	ctx := project.TestContext(t)
+	db, _ := testDatabaseInstance.NewDatabase(t, nil)
+
+	realm, err := db.FindRealm(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expired := time.Now().UTC().Add(-24 * time.Hour)
+	for i := 0; i < 5; i++ {
+		code := &VerificationCode{
+			RealmID:       realm.ID,
+			Code:          fmt.Sprintf("1234%02d", i),
+			LongCode:      fmt.Sprintf("abcdefghijk%02d", i),
+			TestType:      "confirmed",
+			ExpiresAt:     time.Now().UTC().Add(time.Hour),
+			LongExpiresAt: time.Now().UTC().Add(time.Hour),
+		}
+		if err := realm.SaveVerificationCode(db, code); err != nil {
+			t.Fatal(err)
+		}
+		if err := db.db.Model(code).UpdateColumns(&VerificationCode{
+			ExpiresAt:     expired,
+			LongExpiresAt: expired,
+		}).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Other realms are not affected.
+	if n, err := db.RecycleRealmVerificationCodes(ctx, realm.ID+1, time.Nanosecond, 2); err != nil {
+		t.Fatal(err)
+	} else if n != 0 {
+		t.Errorf("expected no codes to be recycled, got %d", n)
+	}
+
+	// Recycling takes several batches and does not revisit recycled codes.
+	if n, err := db.RecycleRealmVerificationCodes(ctx, realm.ID, time.Nanosecond, 2); err != nil {
+		t.Fatal(err)
+	} else if got, want := n, int64(5); got != want {
+		t.Errorf("expected %d to be %d", got, want)
+	}
+	if n, err := db.RecycleRealmVerificationCodes(ctx, realm.ID, time.Nanosecond, 2); err != nil {
+		t.Fatal(err)
+	} else if n != 0 {
+		t.Errorf("expected no codes to be recycled, got %d", n)
+	}
+
+	// A done context stops before the first batch.
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	if _, err := db.PurgeRealmVerificationCodes(canceled, realm.ID, time.Nanosecond, 2); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected %v to be %v", err, context.Canceled)
+	}
+
+	if n, err := db.PurgeRealmVerificationCodes(ctx, realm.ID, time.Nanosecond, 2); err != nil {
+		t.Fatal(err)
+	} else if got, want := n, int64(5); got != want {
+		t.Errorf("expected %d to be %d", got, want)
+	}
+}
+
+func TestDatabase_PurgeRealmTokens(t *testing.T) {
+	t.Parallel()
+
+	ctx := project.TestContext(t)
+	db, _ := testDatabaseInstance.NewDatabase(t, nil)
+
+	for i := 0; i < 3; i++ {
+		token := &Token{
+			TokenID:   fmt.Sprintf("token-%d", i),
+			RealmID:   1,
+			TestType:  "confirmed",
+			ExpiresAt: time.Now().UTC().Add(-24 * time.Hour),
+		}
+		if err := db.db.Save(token).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, err := db.PurgeRealmTokens(ctx, 1, time.Nanosecond, 0); err == nil {
+		t.Errorf("expected error for zero batch size")
+	}
+
+	if n, err := db.PurgeRealmTokens(ctx, 1, time.Nanosecond, 2); err != nil {
+		t.Fatal(err)
+	} else if got, want := n, int64(3); got != want {
+		t.Errorf("expected %d to be %d", got, want)
+	}
+}
@@ -0,0 +1,73 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package database
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/google/exposure-notifications-server/pkg/timeutils"
+)
+
+// CodeCollisionStats is the number of code collisions relative to the number
+// of codes issued over a period.
+type CodeCollisionStats struct {
+	CodesIssued    uint `gorm:"column:codes_issued;"`
+	CodeCollisions uint `gorm:"column:code_collisions;"`
+}
+
+// Rate returns the number of collisions per code issued. It returns 0 if no
+// codes were issued.
+func (s *CodeCollisionStats) Rate() float64 {
+	if s == nil || s.CodesIssued == 0 {
+		return 0
+	}
+	return float64(s.CodeCollisions) / float64(s.CodesIssued)
+}
+
+// RecordCodeCollision records that a generated code for the realm collided
+// with an existing code.
+func (db *Database) RecordCodeCollision(realmID uint) error {
+	sql := `
+		INSERT INTO realm_stats(date, realm_id, code_collisions)
+			VALUES ($1, $2, 1)
+		ON CONFLICT (date, realm_id) DO UPDATE
+			SET code_collisions = realm_stats.code_collisions + 1`
+
+	if err := db.db.Exec(sql, timeutils.UTCMidnight(time.Now()), realmID).Error; err != nil {
+		return fmt.Errorf("failed to record code collision: %w", err)
+	}
+	return nil
+}
+
+// CodeCollisionStats returns the realm's code collisions and codes issued on
+// or after the given day.
+func (r *Realm) CodeCollisionStats(db *Database, since time.Time) (*CodeCollisionStats, error) {
+	sql := `
+		SELECT
+			COALESCE(SUM(codes_issued), 0) AS codes_issued,
+			COALESCE(SUM(code_collisions), 0) AS code_collisions
+		FROM realm_stats
+		WHERE realm_id = $1 AND date >= $2`
+
+	var stats CodeCollisionStats
+	if err := db.db.Raw(sql, r.ID, timeutils.UTCMidnight(since)).Scan(&stats).Error; err != nil {
+		if IsNotFound(err) {
+			return &stats, nil
+		}
+		return nil, fmt.Errorf("failed to get code collision stats: %w", err)
+	}
+	return &stats, nil
+}
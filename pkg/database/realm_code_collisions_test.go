@@ -0,0 +1,90 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package database
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCodeCollisionStats_Rate(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name  string
+		stats *CodeCollisionStats
+		exp   float64
+	}{
+		{"nil", nil, 0},
+		{"no_codes", &CodeCollisionStats{CodeCollisions: 3}, 0},
+		{"rate", &CodeCollisionStats{CodesIssued: 200, CodeCollisions: 3}, 0.015},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			if got, want := tc.stats.Rate(), tc.exp; got != want {
+				t.Errorf("expected %v to be %v", got, want)
+			}
+		})
+	}
+}
+
+func TestRealm_CodeCollisionStats(t *testing.T) {
+	t.Parallel()
+
+	db, _ := testDatabaseInstance.NewDatabase(t, nil)
+
+	realm, err := db.FindRealm(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	since := time.Now().Add(-7 * 24 * time.Hour)
+
+	stats, err := realm.CodeCollisionStats(db, since)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := stats.CodeCollisions, uint(0); got != want {
+		t.Errorf("expected %d to be %d", got, want)
+	}
+
+	for i := 0; i < 3; i++ {
+		if err := db.RecordCodeCollision(realm.ID); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	stats, err = realm.CodeCollisionStats(db, since)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := stats.CodeCollisions, uint(3); got != want {
+		t.Errorf("expected %d to be %d", got, want)
+	}
+
+	// Collisions are included in the realm's daily stats.
+	realmStats, err := realm.Stats(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := realmStats[0].CodeCollisions, uint(3); got != want {
+		t.Errorf("expected %d to be %d", got, want)
+	}
+}
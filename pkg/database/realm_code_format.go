@@ -0,0 +1,120 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package database
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+const (
+	// CodeCharsetNumeric generates short codes from the digits 0-9.
+	CodeCharsetNumeric = "numeric"
+
+	// CodeCharsetAlphanumeric generates short codes from letters and digits,
+	// excluding characters that are easily confused when read aloud or written
+	// down (0/O, 1/I/L).
+	CodeCharsetAlphanumeric = "alphanumeric"
+
+	// CodeAlphabetAlphanumeric is the alphabet for CodeCharsetAlphanumeric.
+	// Codes are generated and stored in lowercase and displayed in uppercase.
+	CodeAlphabetAlphanumeric = "abcdefghjkmnpqrstuvwxyz23456789"
+
+	// CodeGroupSeparator separates groups of characters in a formatted code.
+	CodeGroupSeparator = "-"
+
+	// MinCodeGroupSize is the smallest group size for formatted codes.
+	MinCodeGroupSize = 2
+)
+
+// validCodeCharsets are the allowed values for Realm.CodeCharset.
+var validCodeCharsets = map[string]struct{}{
+	CodeCharsetNumeric:      {},
+	CodeCharsetAlphanumeric: {},
+}
+
+// validateCodeFormat validates the short code charset and grouping. It is
+// called from BeforeSave.
+func (r *Realm) validateCodeFormat() {
+	r.CodeCharset = strings.ToLower(strings.TrimSpace(r.CodeCharset))
+	if r.CodeCharset == "" {
+		r.CodeCharset = CodeCharsetNumeric
+	}
+
+	if _, ok := validCodeCharsets[r.CodeCharset]; !ok {
+		r.AddError("codeCharset", fmt.Sprintf("must be %q or %q", CodeCharsetNumeric, CodeCharsetAlphanumeric))
+	}
+
+	if r.CodeGroupSize != 0 {
+		if r.CodeGroupSize < MinCodeGroupSize {
+			r.AddError("codeGroupSize", fmt.Sprintf("must be 0 or at least %d", MinCodeGroupSize))
+		}
+		if r.CodeGroupSize >= r.CodeLength {
+			r.AddError("codeGroupSize", "must be less than the short code length")
+		}
+	}
+
+	// EN Express apps only accept numeric codes.
+	if r.EnableENExpress {
+		if r.CodeCharset != CodeCharsetNumeric {
+			r.AddError("codeCharset", "must be numeric when using EN Express")
+		}
+		if r.CodeGroupSize != 0 {
+			r.AddError("codeGroupSize", "cannot be set when using EN Express")
+		}
+	}
+}
+
+// FormatCode formats a generated short code for display, according to the
+// realm's charset and grouping. For example, an alphanumeric code "abcd2345"
+// with a group size of 4 is formatted as "ABCD-2345".
+func (r *Realm) FormatCode(code string) string {
+	if r.CodeCharset == CodeCharsetAlphanumeric {
+		code = strings.ToUpper(code)
+	}
+
+	size := int(r.CodeGroupSize)
+	if size <= 0 || len(code) <= size {
+		return code
+	}
+
+	var b strings.Builder
+	b.Grow(len(code) + len(code)/size)
+	for i := 0; i < len(code); i += size {
+		if i > 0 {
+			b.WriteString(CodeGroupSeparator)
+		}
+		end := i + size
+		if end > len(code) {
+			end = len(code)
+		}
+		b.WriteString(code[i:end])
+	}
+	return b.String()
+}
+
+// NormalizeCode converts a code as entered by a user into the form it was
+// generated in. It removes whitespace and group separators and lowercases
+// letters. Numeric codes and long codes are unchanged, so this is safe to call
+// on any code.
+func NormalizeCode(code string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsSpace(r) || string(r) == CodeGroupSeparator {
+			return -1
+		}
+		return unicode.ToLower(r)
+	}, code)
+}
@@ -0,0 +1,85 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package database
+
+import (
+	"testing"
+)
+
+func TestRealm_FormatCode(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name      string
+		charset   string
+		groupSize uint
+		code      string
+		exp       string
+	}{
+		{name: "numeric", charset: CodeCharsetNumeric, code: "12345678", exp: "12345678"},
+		{name: "numeric_grouped", charset: CodeCharsetNumeric, groupSize: 4, code: "12345678", exp: "1234-5678"},
+		{name: "uneven_groups", charset: CodeCharsetNumeric, groupSize: 3, code: "12345678", exp: "123-456-78"},
+		{name: "alphanumeric", charset: CodeCharsetAlphanumeric, code: "abcd2345", exp: "ABCD2345"},
+		{name: "alphanumeric_grouped", charset: CodeCharsetAlphanumeric, groupSize: 4, code: "abcd2345", exp: "ABCD-2345"},
+		{name: "short_code", charset: CodeCharsetNumeric, groupSize: 4, code: "1234", exp: "1234"},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			r := &Realm{CodeCharset: tc.charset, CodeGroupSize: tc.groupSize}
+			if got, want := r.FormatCode(tc.code), tc.exp; got != want {
+				t.Errorf("expected %q to be %q", got, want)
+			}
+		})
+	}
+}
+
+func TestNormalizeCode(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name string
+		code string
+		exp  string
+	}{
+		{name: "numeric", code: "12345678", exp: "12345678"},
+		{name: "grouped", code: "1234-5678", exp: "12345678"},
+		{name: "spaces", code: " 1234 5678 ", exp: "12345678"},
+		{name: "uppercase", code: "ABCD-2345", exp: "abcd2345"},
+		{name: "long_code", code: "abcdefgh12345678", exp: "abcdefgh12345678"},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			if got, want := NormalizeCode(tc.code), tc.exp; got != want {
+				t.Errorf("expected %q to be %q", got, want)
+			}
+		})
+	}
+
+	// A formatted code normalizes to the generated code.
+	r := &Realm{CodeCharset: CodeCharsetAlphanumeric, CodeGroupSize: 3}
+	if got, want := NormalizeCode(r.FormatCode("xyz234abc")), "xyz234abc"; got != want {
+		t.Errorf("expected %q to be %q", got, want)
+	}
+}
@@ -0,0 +1,268 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package database
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+const (
+	// RealmExportStatusPending indicates the export has been requested, but not
+	// yet written. While an export is pending, the realm's audit entries and
+	// statistics are not purged.
+	RealmExportStatusPending = "pending"
+
+	// RealmExportStatusCompleted indicates the export was written to storage.
+	RealmExportStatusCompleted = "completed"
+
+	// RealmExportStatusFailed indicates the export could not be written. Failed
+	// exports are not retried; request a new export instead.
+	RealmExportStatusFailed = "failed"
+)
+
+var _ Auditable = (*RealmExport)(nil)
+
+// RealmExport is a request to produce a signed archive of a realm's records
+// (configuration, audit log, and statistics) for retention, typically when the
+// realm is being decommissioned.
+type RealmExport struct {
+	Errorable
+
+	// ID is the export's ID.
+	ID uint `gorm:"primary_key;"`
+
+	// RealmID is the realm being exported.
+	RealmID uint `gorm:"column:realm_id; type:integer; not null;"`
+
+	// Status is the export status.
+	Status string `gorm:"column:status; type:text; not null; default:'pending';"`
+
+	// ObjectName is the name of the archive in storage. It is only set once the
+	// export has completed.
+	ObjectName string `gorm:"column:object_name; type:text;"`
+
+	// Digest is the hex-encoded SHA-256 digest of the archive.
+	Digest string `gorm:"column:digest; type:text;"`
+
+	// Error is the reason the export failed, if any.
+	Error string `gorm:"column:error; type:text;"`
+
+	// CompletedAt is when the export finished, successfully or not.
+	CompletedAt *time.Time `gorm:"column:completed_at; type:timestamp with time zone;"`
+
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// TableName sets the table name.
+func (RealmExport) TableName() string {
+	return "realm_exports"
+}
+
+// BeforeSave runs validations. If there are errors, the save fails.
+func (e *RealmExport) BeforeSave(tx *gorm.DB) error {
+	if e.RealmID == 0 {
+		e.AddError("realm_id", "is required")
+	}
+
+	if e.Status == "" {
+		e.Status = RealmExportStatusPending
+	}
+	switch e.Status {
+	case RealmExportStatusPending, RealmExportStatusCompleted, RealmExportStatusFailed:
+	default:
+		e.AddError("status", fmt.Sprintf("is not a valid status %q", e.Status))
+	}
+
+	return e.ErrorOrNil()
+}
+
+// IsPending returns true if the export has not yet been processed.
+func (e *RealmExport) IsPending() bool {
+	return e.Status == RealmExportStatusPending
+}
+
+// AuditID is how the export is stored in the audit entry.
+func (e *RealmExport) AuditID() string {
+	return fmt.Sprintf("realm_exports:%d", e.ID)
+}
+
+// AuditDisplay is how the export will be displayed in audit entries.
+func (e *RealmExport) AuditDisplay() string {
+	return fmt.Sprintf("export %d", e.ID)
+}
+
+// RequestRealmExport creates a new pending export for the realm. If the realm
+// already has a pending export, that export is returned instead.
+func (db *Database) RequestRealmExport(r *Realm, actor Auditable) (*RealmExport, error) {
+	if r == nil {
+		return nil, fmt.Errorf("provided realm is nil")
+	}
+
+	if actor == nil {
+		return nil, ErrMissingActor
+	}
+
+	var export RealmExport
+	if err := db.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.
+			Model(&RealmExport{}).
+			Where("realm_id = ?", r.ID).
+			Where("status = ?", RealmExportStatusPending).
+			First(&export).
+			Error; err == nil {
+			return nil
+		} else if !IsNotFound(err) {
+			return fmt.Errorf("failed to find pending export: %w", err)
+		}
+
+		export = RealmExport{
+			RealmID: r.ID,
+			Status:  RealmExportStatusPending,
+		}
+		if err := tx.Save(&export).Error; err != nil {
+			return err
+		}
+
+		audit := BuildAuditEntry(actor, "requested realm export", &export, r.ID)
+		if err := tx.Save(audit).Error; err != nil {
+			return fmt.Errorf("failed to save audit: %w", err)
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return &export, nil
+}
+
+// ListRealmExports lists all exports for the realm, newest first.
+func (r *Realm) ListRealmExports(db *Database) ([]*RealmExport, error) {
+	var exports []*RealmExport
+	if err := db.db.
+		Model(&RealmExport{}).
+		Where("realm_id = ?", r.ID).
+		Order("created_at DESC, id DESC").
+		Find(&exports).
+		Error; err != nil {
+		if IsNotFound(err) {
+			return exports, nil
+		}
+		return nil, err
+	}
+	return exports, nil
+}
+
+// ListPendingRealmExports lists all pending exports across all realms, oldest
+// first.
+func (db *Database) ListPendingRealmExports() ([]*RealmExport, error) {
+	var exports []*RealmExport
+	if err := db.db.
+		Model(&RealmExport{}).
+		Where("status = ?", RealmExportStatusPending).
+		Order("created_at ASC, id ASC").
+		Find(&exports).
+		Error; err != nil {
+		if IsNotFound(err) {
+			return exports, nil
+		}
+		return nil, err
+	}
+	return exports, nil
+}
+
+// CompleteRealmExport marks the export as completed with the given storage
+// object name and digest.
+func (db *Database) CompleteRealmExport(e *RealmExport, objectName, digest string) error {
+	now := time.Now().UTC()
+	e.Status = RealmExportStatusCompleted
+	e.ObjectName = objectName
+	e.Digest = digest
+	e.Error = ""
+	e.CompletedAt = &now
+	return db.db.Save(e).Error
+}
+
+// FailRealmExport marks the export as failed with the given reason.
+func (db *Database) FailRealmExport(e *RealmExport, reason error) error {
+	now := time.Now().UTC()
+	e.Status = RealmExportStatusFailed
+	e.Error = reason.Error()
+	e.CompletedAt = &now
+	return db.db.Save(e).Error
+}
+
+// ListAllAudits returns every audit entry for the realm, oldest first. This
+// is intended for exports and is not paginated.
+func (r *Realm) ListAllAudits(db *Database) ([]*AuditEntry, error) {
+	var entries []*AuditEntry
+	if err := db.db.
+		Model(&AuditEntry{}).
+		Where("realm_id = ?", r.ID).
+		Order("created_at ASC, id ASC").
+		Find(&entries).
+		Error; err != nil {
+		if IsNotFound(err) {
+			return entries, nil
+		}
+		return nil, err
+	}
+	return entries, nil
+}
+
+// ListAllStats returns every retained stats row for the realm, oldest first.
+// Unlike Stats, days without activity are not filled in.
+func (r *Realm) ListAllStats(db *Database) (RealmStats, error) {
+	var stats RealmStats
+	if err := db.db.
+		Model(&RealmStat{}).
+		Where("realm_id = ?", r.ID).
+		Order("date ASC").
+		Find(&stats).
+		Error; err != nil {
+		if IsNotFound(err) {
+			return stats, nil
+		}
+		return nil, err
+	}
+	return stats, nil
+}
+
+// ListAllStatsAnnotations returns every stats annotation for the realm,
+// ordered by date.
+func (r *Realm) ListAllStatsAnnotations(db *Database) (RealmStatsAnnotations, error) {
+	var annotations RealmStatsAnnotations
+	if err := db.db.
+		Model(&RealmStatsAnnotation{}).
+		Where("realm_id = ?", r.ID).
+		Order("date ASC, id ASC").
+		Find(&annotations).
+		Error; err != nil {
+		if IsNotFound(err) {
+			return annotations, nil
+		}
+		return nil, err
+	}
+	return annotations, nil
+}
+
+// withoutPendingRealmExports excludes records belonging to realms that have a
+// pending export, so data is not purged before it has been exported.
+func withoutPendingRealmExports(db *gorm.DB) *gorm.DB {
+	return db.Where("realm_id NOT IN (SELECT realm_id FROM realm_exports WHERE status = ?)",
+		RealmExportStatusPending)
+}
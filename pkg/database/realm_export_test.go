@@ -0,0 +1,117 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package database
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDatabase_RequestRealmExport(t *testing.T) {
+	t.Parallel()
+
+	db, _ := testDatabaseInstance.NewDatabase(t, nil)
+
+	realm, err := db.FindRealm(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := db.RequestRealmExport(realm, nil); err != ErrMissingActor {
+		t.Errorf("expected %v to be %v", err, ErrMissingActor)
+	}
+
+	export, err := db.RequestRealmExport(realm, SystemTest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !export.IsPending() {
+		t.Errorf("expected export to be pending, got %q", export.Status)
+	}
+
+	// Requesting again returns the existing pending export.
+	again, err := db.RequestRealmExport(realm, SystemTest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := again.ID, export.ID; got != want {
+		t.Errorf("expected %d to be %d", got, want)
+	}
+
+	pending, err := db.ListPendingRealmExports()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(pending), 1; got != want {
+		t.Fatalf("expected %d pending exports, got %d", want, got)
+	}
+
+	// Old audit entries are retained while the export is pending.
+	entry := BuildAuditEntry(SystemTest, "old action", realm, realm.ID)
+	entry.CreatedAt = time.Now().UTC().Add(-365 * 24 * time.Hour)
+	if err := db.SaveAuditEntry(entry); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := db.PurgeAuditEntries(30 * 24 * time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	audits, err := realm.ListAllAudits(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !hasAuditEntry(audits, entry.ID) {
+		t.Errorf("expected audit entry %d to be retained", entry.ID)
+	}
+
+	if err := db.CompleteRealmExport(export, "realm-1.tar.gz", "abc123"); err != nil {
+		t.Fatal(err)
+	}
+
+	exports, err := realm.ListRealmExports(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(exports), 1; got != want {
+		t.Fatalf("expected %d exports, got %d", want, got)
+	}
+	if got, want := exports[0].Status, RealmExportStatusCompleted; got != want {
+		t.Errorf("expected %q to be %q", got, want)
+	}
+	if exports[0].CompletedAt == nil {
+		t.Errorf("expected completed_at to be set")
+	}
+
+	// Once completed, old entries are purged.
+	if _, err := db.PurgeAuditEntries(30 * 24 * time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	audits, err = realm.ListAllAudits(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hasAuditEntry(audits, entry.ID) {
+		t.Errorf("expected audit entry %d to be purged", entry.ID)
+	}
+}
+
+func hasAuditEntry(entries []*AuditEntry, id uint) bool {
+	for _, e := range entries {
+		if e.ID == id {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,107 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package database
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+
+	"github.com/google/exposure-notifications-verification-server/internal/project"
+)
+
+const (
+	// MaxExternalCaseIDLength is the maximum length of an external case ID.
+	MaxExternalCaseIDLength = 255
+
+	// maxExternalCaseIDPatternLength is the maximum length of a realm's
+	// external case ID pattern.
+	maxExternalCaseIDPatternLength = 512
+)
+
+var (
+	// ErrExternalCaseIDRequired is returned when the realm requires an external
+	// case ID, but none was provided.
+	ErrExternalCaseIDRequired = errors.New("external case ID is required")
+
+	// ErrExternalCaseIDInvalid is returned when an external case ID does not
+	// match the realm's pattern.
+	ErrExternalCaseIDInvalid = errors.New("external case ID is not in the expected format")
+)
+
+// UsesExternalCaseID returns true if the realm collects external case IDs,
+// such as lab accession numbers, when issuing codes.
+func (r *Realm) UsesExternalCaseID() bool {
+	return r.RequireExternalCaseID || r.ExternalCaseIDPattern != ""
+}
+
+// validateExternalCaseID validates the external case ID settings. It is called
+// from BeforeSave.
+func (r *Realm) validateExternalCaseID() {
+	r.ExternalCaseIDPattern = project.TrimSpace(r.ExternalCaseIDPattern)
+	if r.ExternalCaseIDPattern == "" {
+		return
+	}
+
+	if len(r.ExternalCaseIDPattern) > maxExternalCaseIDPatternLength {
+		r.AddError("externalCaseIDPattern", fmt.Sprintf("cannot exceed %d characters", maxExternalCaseIDPatternLength))
+		return
+	}
+
+	if _, err := compileExternalCaseIDPattern(r.ExternalCaseIDPattern); err != nil {
+		r.AddError("externalCaseIDPattern", fmt.Sprintf("is not a valid regular expression: %s", err))
+	}
+}
+
+// NormalizeExternalCaseID trims whitespace and non-printable characters, such
+// as the carriage return or group separator sent by many barcode scanners.
+func NormalizeExternalCaseID(id string) string {
+	return project.TrimSpaceAndNonPrintable(id)
+}
+
+// ValidateExternalCaseID validates a normalized external case ID against the
+// realm's settings. The realm's pattern must match the entire ID. An empty ID
+// is valid unless the realm requires one.
+func (r *Realm) ValidateExternalCaseID(id string) error {
+	if id == "" {
+		if r.RequireExternalCaseID {
+			return ErrExternalCaseIDRequired
+		}
+		return nil
+	}
+
+	if len(id) > MaxExternalCaseIDLength {
+		return fmt.Errorf("external case ID cannot exceed %d characters", MaxExternalCaseIDLength)
+	}
+
+	if r.ExternalCaseIDPattern == "" {
+		return nil
+	}
+
+	re, err := compileExternalCaseIDPattern(r.ExternalCaseIDPattern)
+	if err != nil {
+		return fmt.Errorf("invalid external case ID pattern: %w", err)
+	}
+	if !re.MatchString(id) {
+		return ErrExternalCaseIDInvalid
+	}
+	return nil
+}
+
+// compileExternalCaseIDPattern compiles the pattern so that it must match the
+// entire ID.
+func compileExternalCaseIDPattern(pattern string) (*regexp.Regexp, error) {
+	return regexp.Compile(`^(?:` + pattern + `)$`)
+}
@@ -0,0 +1,97 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package database
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestRealm_ValidateExternalCaseID(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name     string
+		pattern  string
+		required bool
+		id       string
+		err      error
+		errMsg   string
+	}{
+		{name: "empty_optional", id: ""},
+		{name: "empty_required", required: true, id: "", err: ErrExternalCaseIDRequired},
+		{name: "any_value", id: "abc-123"},
+		{name: "matches", pattern: `LAB-[0-9]{4}`, id: "LAB-1234"},
+		{name: "partial_match", pattern: `LAB-[0-9]{4}`, id: "xLAB-12345", err: ErrExternalCaseIDInvalid},
+		{name: "no_match", pattern: `LAB-[0-9]{4}`, id: "LAB-12a4", err: ErrExternalCaseIDInvalid},
+		{name: "alternation", pattern: `A[0-9]+|B[0-9]+`, id: "B12"},
+		{name: "empty_with_pattern", pattern: `LAB-[0-9]{4}`, id: ""},
+		{name: "too_long", id: strings.Repeat("a", MaxExternalCaseIDLength+1), errMsg: "cannot exceed"},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			r := &Realm{ExternalCaseIDPattern: tc.pattern, RequireExternalCaseID: tc.required}
+			err := r.ValidateExternalCaseID(tc.id)
+
+			switch {
+			case tc.err != nil:
+				if !errors.Is(err, tc.err) {
+					t.Errorf("expected %v to be %v", err, tc.err)
+				}
+			case tc.errMsg != "":
+				if err == nil || !strings.Contains(err.Error(), tc.errMsg) {
+					t.Errorf("expected %v to contain %q", err, tc.errMsg)
+				}
+			default:
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+			}
+		})
+	}
+}
+
+func TestNormalizeExternalCaseID(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name string
+		id   string
+		exp  string
+	}{
+		{name: "plain", id: "LAB-1234", exp: "LAB-1234"},
+		{name: "spaces", id: "  LAB-1234 ", exp: "LAB-1234"},
+		{name: "scanner_suffix", id: "LAB-1234\r\n", exp: "LAB-1234"},
+		{name: "group_separator", id: "\x1dLAB-1234", exp: "LAB-1234"},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			if got, want := NormalizeExternalCaseID(tc.id), tc.exp; got != want {
+				t.Errorf("expected %q to be %q", got, want)
+			}
+		})
+	}
+}
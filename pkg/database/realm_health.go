@@ -0,0 +1,115 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package database
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/google/exposure-notifications-server/pkg/timeutils"
+	"github.com/google/exposure-notifications-verification-server/pkg/cache"
+	"github.com/lib/pq"
+)
+
+// RealmHealth summarizes recent code issuance and SMS delivery for a realm.
+// Stats are recorded per UTC day, so the counts cover the current and previous
+// UTC day.
+type RealmHealth struct {
+	CodesIssued uint `json:"codes_issued"`
+	CodesFailed uint `json:"codes_failed"`
+	SMSErrors   uint `json:"sms_errors"`
+}
+
+// IssueErrorRate returns the fraction of codes which failed to issue, between
+// 0 and 1.
+func (h *RealmHealth) IssueErrorRate() float64 {
+	total := h.CodesIssued + h.CodesFailed
+	if total == 0 {
+		return 0
+	}
+	return float64(h.CodesFailed) / float64(total)
+}
+
+// SMSErrorRate returns the number of SMS delivery errors reported by the
+// provider relative to the number of codes issued. Not every code is sent by
+// SMS, so this is a lower bound of the true rate. The result is capped at 1.
+func (h *RealmHealth) SMSErrorRate() float64 {
+	if h.SMSErrors == 0 {
+		return 0
+	}
+	if h.SMSErrors >= h.CodesIssued {
+		return 1
+	}
+	return float64(h.SMSErrors) / float64(h.CodesIssued)
+}
+
+// Health returns the realm's issuance and SMS delivery counts for the current
+// and previous UTC day. SMS errors with any of the ignored codes, which are
+// usually caused by the recipient rather than the provider, are not counted.
+func (r *Realm) Health(db *Database, ignoredSMSErrors []string) (*RealmHealth, error) {
+	start := timeutils.UTCMidnight(time.Now()).Add(-24 * time.Hour)
+
+	sql := `
+		SELECT
+			(
+				SELECT COALESCE(SUM(codes_issued), 0)
+				FROM realm_stats
+				WHERE realm_id = $1 AND date >= $2
+			) AS codes_issued,
+			(
+				SELECT COALESCE(SUM(codes_failed), 0)
+				FROM user_stats
+				WHERE realm_id = $1 AND date >= $2
+			) + (
+				SELECT COALESCE(SUM(s.codes_failed), 0)
+				FROM authorized_app_stats s
+				INNER JOIN authorized_apps a ON a.id = s.authorized_app_id
+				WHERE a.realm_id = $1 AND s.date >= $2
+			) AS codes_failed,
+			(
+				SELECT COALESCE(SUM(quantity), 0)
+				FROM sms_error_stats
+				WHERE realm_id = $1 AND date >= $2 AND NOT error_code = ANY ($3)
+			) AS sms_errors`
+
+	values := []any{r.ID, start, pq.Array(ignoredSMSErrors)}
+
+	var health RealmHealth
+	if err := db.db.Raw(sql, values...).Scan(&health).Error; err != nil {
+		return nil, fmt.Errorf("failed to get realm health: %w", err)
+	}
+	return &health, nil
+}
+
+// HealthCached is Health, but cached.
+func (r *Realm) HealthCached(ctx context.Context, db *Database, cacher cache.Cacher, ignoredSMSErrors []string) (*RealmHealth, error) {
+	if cacher == nil {
+		return nil, fmt.Errorf("cacher cannot be nil")
+	}
+
+	var health *RealmHealth
+	cacheKey := &cache.Key{
+		Namespace: "realm:health",
+		Key:       strconv.FormatUint(uint64(r.ID), 10),
+	}
+	if err := cacher.Fetch(ctx, cacheKey, &health, time.Minute, func() (interface{}, error) {
+		return r.Health(db, ignoredSMSErrors)
+	}); err != nil {
+		return nil, err
+	}
+	return health, nil
+}
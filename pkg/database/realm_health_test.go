@@ -0,0 +1,119 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package database
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/exposure-notifications-verification-server/internal/project"
+	"github.com/jinzhu/gorm"
+)
+
+func TestRealmHealth_Rates(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name      string
+		health    *RealmHealth
+		issueRate float64
+		smsRate   float64
+	}{
+		{
+			name:   "empty",
+			health: &RealmHealth{},
+		},
+		{
+			name: "some_failures",
+			health: &RealmHealth{
+				CodesIssued: 3,
+				CodesFailed: 1,
+				SMSErrors:   1,
+			},
+			issueRate: 0.25,
+			smsRate:   1.0 / 3.0,
+		},
+		{
+			name: "more_sms_errors_than_codes",
+			health: &RealmHealth{
+				CodesIssued: 1,
+				SMSErrors:   4,
+			},
+			smsRate: 1,
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			if got, want := tc.health.IssueErrorRate(), tc.issueRate; got != want {
+				t.Errorf("expected issue error rate %f to be %f", got, want)
+			}
+			if got, want := tc.health.SMSErrorRate(), tc.smsRate; got != want {
+				t.Errorf("expected sms error rate %f to be %f", got, want)
+			}
+		})
+	}
+}
+
+func TestRealm_Health(t *testing.T) {
+	t.Parallel()
+
+	ctx := project.TestContext(t)
+	db, _ := testDatabaseInstance.NewDatabase(t, nil)
+
+	realm, err := db.FindRealm(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	user := &User{
+		Name:  "Rocky",
+		Email: "rocky@example.com",
+	}
+	if err := db.SaveUser(user, SystemTest); err != nil {
+		t.Fatal(err)
+	}
+
+	health, err := realm.Health(db, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := *health, (RealmHealth{}); got != want {
+		t.Errorf("expected %#v to be %#v", got, want)
+	}
+
+	db.UpdateStats(ctx,
+		&VerificationCode{RealmID: realm.ID, IssuingUserID: user.ID, Model: gorm.Model{CreatedAt: time.Now()}},
+		&VerificationCode{RealmID: realm.ID, IssuingUserID: user.ID, Model: gorm.Model{CreatedAt: time.Now()}})
+	db.RecordIssuanceOutcome(ctx, &IssuanceOutcome{RealmID: realm.ID, UserID: user.ID, Failed: 1})
+
+	for _, code := range []string{"30007", "30007", "30005"} {
+		if err := db.InsertSMSErrorStat(time.Now(), realm.ID, code); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	health, err = realm.Health(db, []string{"30005"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := *health, (RealmHealth{CodesIssued: 2, CodesFailed: 1, SMSErrors: 2}); got != want {
+		t.Errorf("expected %#v to be %#v", got, want)
+	}
+}
@@ -0,0 +1,303 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package database
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/exposure-notifications-verification-server/internal/project"
+	"github.com/google/exposure-notifications-verification-server/pkg/rbac"
+	"github.com/jinzhu/gorm"
+)
+
+// RealmImpersonationMaxDuration is the maximum length of a realm
+// impersonation.
+const RealmImpersonationMaxDuration = 4 * time.Hour
+
+// RealmImpersonationReadOnly are the permissions granted by a read-only realm
+// impersonation, the default.
+const RealmImpersonationReadOnly = rbac.AuditRead |
+	rbac.APIKeyRead |
+	rbac.CodeRead |
+	rbac.SettingsRead |
+	rbac.StatsRead |
+	rbac.MobileAppRead |
+	rbac.UserRead
+
+var (
+	// ErrRealmImpersonationOperator is returned when the actor is not a system
+	// admin.
+	ErrRealmImpersonationOperator = errors.New("only system admins can impersonate a realm")
+
+	// ErrRealmImpersonationMember is returned when the system admin is already a
+	// member of the realm.
+	ErrRealmImpersonationMember = errors.New("you are already a member of this realm")
+
+	// ErrRealmImpersonationDuration is returned when impersonating a realm for
+	// longer than RealmImpersonationMaxDuration.
+	ErrRealmImpersonationDuration = fmt.Errorf("realm impersonations must last between 1 minute and %s", RealmImpersonationMaxDuration)
+
+	// ErrRealmImpersonationEnded is returned when ending an impersonation that
+	// already ended or expired.
+	ErrRealmImpersonationEnded = errors.New("realm impersonation already ended")
+)
+
+// RealmImpersonation is a time-boxed session in which a system admin uses a
+// realm's UI without being a member of the realm. The system admin has the
+// given permissions in the realm until the impersonation expires or is ended.
+// Starting and ending an impersonation, and every page viewed during it, are
+// recorded in the realm's audit log.
+type RealmImpersonation struct {
+	Errorable
+
+	// ID is the impersonation's ID.
+	ID uint `gorm:"primary_key;"`
+
+	// UserID is the system admin impersonating the realm, and RealmID is the
+	// realm.
+	UserID  uint `gorm:"column:user_id; type:integer; not null;"`
+	RealmID uint `gorm:"column:realm_id; type:integer; not null;"`
+
+	// Permissions are the compiled RBAC permissions granted in the realm.
+	Permissions rbac.Permission `gorm:"column:permissions; type:bigint; not null;"`
+
+	// Reason is why the system admin is impersonating the realm.
+	Reason string `gorm:"column:reason; type:text; not null;"`
+
+	// ExpiresAt is when the impersonation ends automatically. EndedAt is when
+	// the system admin ended it early.
+	ExpiresAt time.Time  `gorm:"column:expires_at; type:timestamp with time zone; not null;"`
+	EndedAt   *time.Time `gorm:"column:ended_at; type:timestamp with time zone;"`
+
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// TableName sets the table name.
+func (RealmImpersonation) TableName() string {
+	return "realm_impersonations"
+}
+
+// BeforeSave runs validations. If there are errors, the save fails.
+func (i *RealmImpersonation) BeforeSave(tx *gorm.DB) error {
+	if i.UserID == 0 {
+		i.AddError("userID", "is required")
+	}
+	if i.RealmID == 0 {
+		i.AddError("realmID", "is required")
+	}
+	if i.Permissions == 0 {
+		i.AddError("permissions", "must include at least one permission")
+	}
+	if i.ExpiresAt.IsZero() {
+		i.AddError("expiresAt", "is required")
+	}
+
+	i.Reason = project.TrimSpace(i.Reason)
+	if i.Reason == "" {
+		i.AddError("reason", "cannot be blank")
+	}
+
+	return i.ErrorOrNil()
+}
+
+// IsActive returns true if the impersonation has not ended or expired.
+func (i *RealmImpersonation) IsActive() bool {
+	return i.EndedAt == nil && time.Now().Before(i.ExpiresAt)
+}
+
+// IsReadOnly returns true if the impersonation only grants read permissions.
+func (i *RealmImpersonation) IsReadOnly() bool {
+	return i.Permissions&^RealmImpersonationReadOnly == 0
+}
+
+// Membership returns the membership the system admin has in the realm for the
+// duration of the impersonation. It is never saved.
+func (i *RealmImpersonation) Membership(user *User, realm *Realm) *Membership {
+	return &Membership{
+		UserID:      user.ID,
+		User:        user,
+		RealmID:     realm.ID,
+		Realm:       realm,
+		Permissions: i.Permissions,
+		CreatedAt:   i.CreatedAt,
+		UpdatedAt:   i.UpdatedAt,
+	}
+}
+
+// AuditID is how the impersonation is stored in the audit entry.
+func (i *RealmImpersonation) AuditID() string {
+	return fmt.Sprintf("realm_impersonations:%d", i.ID)
+}
+
+// AuditDisplay is how the impersonation will be displayed in audit entries.
+func (i *RealmImpersonation) AuditDisplay() string {
+	return fmt.Sprintf("realm impersonation %d", i.ID)
+}
+
+// FindActiveRealmImpersonation finds the user's active impersonation of the
+// realm.
+func (u *User) FindActiveRealmImpersonation(db *Database, realmID uint) (*RealmImpersonation, error) {
+	var impersonation RealmImpersonation
+	if err := db.db.
+		Model(&RealmImpersonation{}).
+		Where("user_id = ? AND realm_id = ?", u.ID, realmID).
+		Where("ended_at IS NULL AND expires_at > ?", time.Now().UTC()).
+		Order("created_at DESC, id DESC").
+		First(&impersonation).
+		Error; err != nil {
+		return nil, err
+	}
+	return &impersonation, nil
+}
+
+// StartRealmImpersonation starts an impersonation of the realm by the actor,
+// with the given permissions, for the given duration. Any other active
+// impersonation by the actor is ended, so a system admin only impersonates one
+// realm at a time.
+func (db *Database) StartRealmImpersonation(realm *Realm, permissions rbac.Permission, duration time.Duration, reason string, actor *User) (*RealmImpersonation, error) {
+	if actor == nil {
+		return nil, ErrMissingActor
+	}
+	if !actor.IsSystemAdmin() {
+		return nil, ErrRealmImpersonationOperator
+	}
+	if duration < time.Minute || duration > RealmImpersonationMaxDuration {
+		return nil, ErrRealmImpersonationDuration
+	}
+
+	now := time.Now().UTC()
+	impersonation := &RealmImpersonation{
+		UserID:      actor.ID,
+		RealmID:     realm.ID,
+		Permissions: rbac.AddImplied(permissions),
+		Reason:      reason,
+		ExpiresAt:   now.Add(duration),
+	}
+
+	if err := db.db.Transaction(func(tx *gorm.DB) error {
+		var count int
+		if err := tx.
+			Model(&Membership{}).
+			Where("user_id = ? AND realm_id = ?", actor.ID, realm.ID).
+			Count(&count).
+			Error; err != nil {
+			return fmt.Errorf("failed to check membership: %w", err)
+		}
+		if count > 0 {
+			return ErrRealmImpersonationMember
+		}
+
+		var active []*RealmImpersonation
+		if err := tx.
+			Set("gorm:query_option", "FOR UPDATE").
+			Model(&RealmImpersonation{}).
+			Where("user_id = ? AND ended_at IS NULL AND expires_at > ?", actor.ID, now).
+			Find(&active).
+			Error; err != nil && !IsNotFound(err) {
+			return fmt.Errorf("failed to list active impersonations: %w", err)
+		}
+		for _, a := range active {
+			if err := endRealmImpersonation(tx, a, now, actor); err != nil {
+				return err
+			}
+		}
+
+		if err := tx.Create(impersonation).Error; err != nil {
+			return err
+		}
+
+		audit := BuildAuditEntry(actor, "started realm impersonation", realm, realm.ID)
+		audit.Diff = stringDiff("", fmt.Sprintf("%s until %s: %s",
+			strings.Join(rbac.PermissionNames(impersonation.Permissions), ", "),
+			impersonation.ExpiresAt.Format(time.RFC3339),
+			impersonation.Reason))
+		if err := tx.Save(audit).Error; err != nil {
+			return fmt.Errorf("failed to save audits: %w", err)
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return impersonation, nil
+}
+
+// EndRealmImpersonation ends the active impersonation before it expires.
+func (db *Database) EndRealmImpersonation(impersonation *RealmImpersonation, actor *User) error {
+	if actor == nil {
+		return ErrMissingActor
+	}
+
+	return db.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.
+			Set("gorm:query_option", "FOR UPDATE").
+			Where("id = ?", impersonation.ID).
+			First(impersonation).
+			Error; err != nil {
+			return fmt.Errorf("failed to lock impersonation: %w", err)
+		}
+
+		if !impersonation.IsActive() {
+			return ErrRealmImpersonationEnded
+		}
+		return endRealmImpersonation(tx, impersonation, time.Now().UTC(), actor)
+	})
+}
+
+// endRealmImpersonation marks the impersonation ended and audits it in the
+// realm.
+func endRealmImpersonation(tx *gorm.DB, impersonation *RealmImpersonation, now time.Time, actor *User) error {
+	impersonation.EndedAt = &now
+	if err := tx.Save(impersonation).Error; err != nil {
+		return fmt.Errorf("failed to end impersonation: %w", err)
+	}
+
+	audit := BuildAuditEntry(actor, "ended realm impersonation", impersonation, impersonation.RealmID)
+	if err := tx.Save(audit).Error; err != nil {
+		return fmt.Errorf("failed to save audits: %w", err)
+	}
+	return nil
+}
+
+// RecordRealmImpersonationView records a page viewed by the system admin
+// during the impersonation in the realm's audit log. Query strings are not
+// recorded, since they can contain search terms.
+func (db *Database) RecordRealmImpersonationView(impersonation *RealmImpersonation, actor *User, method, path string) error {
+	if actor == nil {
+		return ErrMissingActor
+	}
+
+	audit := BuildAuditEntry(actor, "viewed page while impersonating realm", impersonation, impersonation.RealmID)
+	audit.Diff = stringDiff("", method+" "+path)
+	return db.SaveAuditEntry(audit)
+}
+
+// PurgeRealmImpersonations deletes impersonations that expired longer than
+// maxAge ago. The audit entries for the impersonation are kept.
+func (db *Database) PurgeRealmImpersonations(maxAge time.Duration) (int64, error) {
+	if maxAge > 0 {
+		maxAge = -1 * maxAge
+	}
+	deleteBefore := time.Now().UTC().Add(maxAge)
+
+	result := db.db.
+		Unscoped().
+		Where("expires_at < ?", deleteBefore).
+		Delete(&RealmImpersonation{})
+	return result.RowsAffected, result.Error
+}
@@ -0,0 +1,188 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package database
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/exposure-notifications-verification-server/pkg/rbac"
+)
+
+func TestDatabase_StartRealmImpersonation(t *testing.T) {
+	t.Parallel()
+
+	db, _ := testDatabaseInstance.NewDatabase(t, nil)
+	realm, err := db.FindRealm(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	other := NewRealmWithDefaults("Other")
+	if err := db.SaveRealm(other, SystemTest); err != nil {
+		t.Fatal(err)
+	}
+
+	admin := &User{
+		Email:       "admin@example.com",
+		Name:        "Admin",
+		SystemAdmin: true,
+	}
+	if err := db.SaveUser(admin, SystemTest); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("missing_actor", func(t *testing.T) {
+		t.Parallel()
+
+		if _, err := db.StartRealmImpersonation(realm, RealmImpersonationReadOnly, time.Hour, "debugging", nil); !errors.Is(err, ErrMissingActor) {
+			t.Errorf("expected %v to be %v", err, ErrMissingActor)
+		}
+	})
+
+	t.Run("not_system_admin", func(t *testing.T) {
+		t.Parallel()
+
+		user := &User{Email: "user@example.com", Name: "User"}
+		if _, err := db.StartRealmImpersonation(realm, RealmImpersonationReadOnly, time.Hour, "debugging", user); !errors.Is(err, ErrRealmImpersonationOperator) {
+			t.Errorf("expected %v to be %v", err, ErrRealmImpersonationOperator)
+		}
+	})
+
+	t.Run("duration", func(t *testing.T) {
+		t.Parallel()
+
+		if _, err := db.StartRealmImpersonation(realm, RealmImpersonationReadOnly, 5*time.Hour, "debugging", admin); !errors.Is(err, ErrRealmImpersonationDuration) {
+			t.Errorf("expected %v to be %v", err, ErrRealmImpersonationDuration)
+		}
+	})
+
+	t.Run("member", func(t *testing.T) {
+		t.Parallel()
+
+		member := &User{Email: "member@example.com", Name: "Member", SystemAdmin: true}
+		if err := db.SaveUser(member, SystemTest); err != nil {
+			t.Fatal(err)
+		}
+		if err := member.AddToRealm(db, realm, rbac.LegacyRealmAdmin, SystemTest); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := db.StartRealmImpersonation(realm, RealmImpersonationReadOnly, time.Hour, "debugging", member); !errors.Is(err, ErrRealmImpersonationMember) {
+			t.Errorf("expected %v to be %v", err, ErrRealmImpersonationMember)
+		}
+	})
+
+	t.Run("lifecycle", func(t *testing.T) {
+		t.Parallel()
+
+		first, err := db.StartRealmImpersonation(realm, rbac.SettingsWrite, time.Hour, "  ticket 1  ", admin)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := first.Permissions, rbac.SettingsWrite|rbac.SettingsRead; got != want {
+			t.Errorf("expected permissions %v to be %v", got, want)
+		}
+		if got, want := first.Reason, "ticket 1"; got != want {
+			t.Errorf("expected reason %q to be %q", got, want)
+		}
+		if first.IsReadOnly() {
+			t.Errorf("expected impersonation to not be read-only")
+		}
+
+		// Starting a second impersonation ends the first.
+		second, err := db.StartRealmImpersonation(other, RealmImpersonationReadOnly, time.Hour, "ticket 2", admin)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !second.IsReadOnly() {
+			t.Errorf("expected impersonation to be read-only")
+		}
+		if _, err := admin.FindActiveRealmImpersonation(db, realm.ID); !IsNotFound(err) {
+			t.Errorf("expected first impersonation to be ended, got %v", err)
+		}
+
+		active, err := admin.FindActiveRealmImpersonation(db, other.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := active.ID, second.ID; got != want {
+			t.Errorf("expected active impersonation %d to be %d", got, want)
+		}
+
+		if err := db.EndRealmImpersonation(active, admin); err != nil {
+			t.Fatal(err)
+		}
+		if err := db.EndRealmImpersonation(active, admin); !errors.Is(err, ErrRealmImpersonationEnded) {
+			t.Errorf("expected %v to be %v", err, ErrRealmImpersonationEnded)
+		}
+
+		audits, _, err := realm.ListAudits(db, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		actions := make(map[string]int)
+		for _, a := range audits {
+			actions[a.Action]++
+		}
+		if got, want := actions["started realm impersonation"], 1; got != want {
+			t.Errorf("expected %d start audits, got %d", want, got)
+		}
+		if got, want := actions["ended realm impersonation"], 1; got != want {
+			t.Errorf("expected %d end audits, got %d", want, got)
+		}
+	})
+}
+
+func TestRealmImpersonation_IsActive(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now()
+
+	cases := []struct {
+		name          string
+		impersonation *RealmImpersonation
+		active        bool
+	}{
+		{
+			name:          "active",
+			impersonation: &RealmImpersonation{ExpiresAt: now.Add(time.Minute)},
+			active:        true,
+		},
+		{
+			name:          "expired",
+			impersonation: &RealmImpersonation{ExpiresAt: now.Add(-time.Minute)},
+			active:        false,
+		},
+		{
+			name:          "ended",
+			impersonation: &RealmImpersonation{ExpiresAt: now.Add(time.Minute), EndedAt: &now},
+			active:        false,
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			if got, want := tc.impersonation.IsActive(), tc.active; got != want {
+				t.Errorf("expected %t to be %t", got, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,321 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package database
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/exposure-notifications-verification-server/internal/project"
+	"github.com/google/exposure-notifications-verification-server/pkg/pagination"
+	"github.com/google/exposure-notifications-verification-server/pkg/rbac"
+	"github.com/jinzhu/gorm"
+)
+
+const (
+	// RealmInvitationStatusPending is an invitation that the invitee has not
+	// yet submitted.
+	RealmInvitationStatusPending = "pending"
+
+	// RealmInvitationStatusSubmitted is an invitation that the invitee has
+	// submitted and is awaiting approval by a system admin.
+	RealmInvitationStatusSubmitted = "submitted"
+
+	// RealmInvitationStatusApproved is an invitation that a system admin has
+	// approved. The invitee is an admin of the realm.
+	RealmInvitationStatusApproved = "approved"
+
+	// RealmInvitationStatusExpired is an invitation that was not submitted
+	// before it expired.
+	RealmInvitationStatusExpired = "expired"
+
+	// realmInvitationTokenBytes is the number of random bytes in an invitation
+	// token.
+	realmInvitationTokenBytes = 32
+)
+
+var (
+	// ErrRealmInvitationExpired is the error returned when an expired invitation
+	// is modified.
+	ErrRealmInvitationExpired = errors.New("realm invitation has expired")
+
+	// ErrRealmInvitationSubmitted is the error returned when an invitation that
+	// was already submitted is modified by the invitee.
+	ErrRealmInvitationSubmitted = errors.New("realm invitation has already been submitted")
+
+	// ErrRealmInvitationNotSubmitted is the error returned when an invitation
+	// is approved before the invitee submitted it.
+	ErrRealmInvitationNotSubmitted = errors.New("realm invitation has not been submitted")
+
+	// ErrRealmInvitationApproved is the error returned when an invitation that
+	// was already approved is approved again.
+	ErrRealmInvitationApproved = errors.New("realm invitation has already been approved")
+)
+
+// RealmInvitation invites a public health authority admin to complete the
+// settings of a new, pending realm. The realm has no members until a system
+// admin approves the submitted invitation, at which point the invitee is made
+// an admin of the realm.
+type RealmInvitation struct {
+	gorm.Model
+	Errorable
+
+	// RealmID is the pending realm.
+	RealmID uint `gorm:"column:realm_id; type:integer; not null;"`
+
+	// Email is the email address of the invitee.
+	Email string `gorm:"column:email; type:text; not null;"`
+
+	// TokenHMAC is the HMAC of the token in the invitation link. The token
+	// itself is only available when the invitation is created.
+	TokenHMAC string `gorm:"column:token_hmac; type:text; not null;" json:"-"`
+
+	// ExpiresAt is when the invitation link stops working, unless the
+	// invitation was submitted.
+	ExpiresAt time.Time `gorm:"column:expires_at; type:timestamp with time zone; not null;"`
+
+	// SubmittedAt is when the invitee finished the setup wizard.
+	SubmittedAt *time.Time `gorm:"column:submitted_at; type:timestamp with time zone;"`
+
+	// ApprovedAt is when a system admin approved the invitation.
+	ApprovedAt *time.Time `gorm:"column:approved_at; type:timestamp with time zone;"`
+}
+
+// TableName sets the table name.
+func (RealmInvitation) TableName() string {
+	return "realm_invitations"
+}
+
+// BeforeSave runs validations. If there are errors, the save fails.
+func (i *RealmInvitation) BeforeSave(tx *gorm.DB) error {
+	i.Email = project.TrimSpace(strings.ToLower(i.Email))
+	if i.Email == "" {
+		i.AddError("email", "cannot be blank")
+	} else if !strings.Contains(i.Email, "@") {
+		i.AddError("email", "is invalid")
+	}
+
+	if i.ExpiresAt.IsZero() {
+		i.AddError("expiresAt", "cannot be blank")
+	}
+
+	return i.ErrorOrNil()
+}
+
+// Status returns the invitation's status, one of the RealmInvitationStatus
+// constants.
+func (i *RealmInvitation) Status() string {
+	switch {
+	case i.ApprovedAt != nil:
+		return RealmInvitationStatusApproved
+	case i.SubmittedAt != nil:
+		return RealmInvitationStatusSubmitted
+	case time.Now().After(i.ExpiresAt):
+		return RealmInvitationStatusExpired
+	default:
+		return RealmInvitationStatusPending
+	}
+}
+
+// Realm returns the invitation's realm.
+func (i *RealmInvitation) Realm(db *Database) (*Realm, error) {
+	return db.FindRealm(i.RealmID)
+}
+
+// AuditID is how the invitation is stored in the audit entry.
+func (i *RealmInvitation) AuditID() string {
+	return fmt.Sprintf("realm_invitations:%d", i.ID)
+}
+
+// AuditDisplay is how the invitation will be displayed in audit entries.
+func (i *RealmInvitation) AuditDisplay() string {
+	return fmt.Sprintf("realm invitation for %s", i.Email)
+}
+
+// CreateRealmInvitation creates an invitation for the given email address to
+// complete the settings of the realm. It returns the invitation token, which
+// is not stored and cannot be recovered.
+func (db *Database) CreateRealmInvitation(realm *Realm, email string, expiresAt time.Time, actor Auditable) (*RealmInvitation, string, error) {
+	if actor == nil {
+		return nil, "", ErrMissingActor
+	}
+
+	token, err := project.RandomBase64String(realmInvitationTokenBytes)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to generate token: %w", err)
+	}
+	tokenHMAC, err := db.GenerateAPIKeyHMAC(token)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to generate token hmac: %w", err)
+	}
+
+	invitation := &RealmInvitation{
+		RealmID:   realm.ID,
+		Email:     email,
+		TokenHMAC: tokenHMAC,
+		ExpiresAt: expiresAt,
+	}
+
+	if err := db.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(invitation).Error; err != nil {
+			if IsValidationError(err) {
+				return ErrValidationFailed
+			}
+			return err
+		}
+
+		audit := BuildAuditEntry(actor, "created realm invitation", invitation, realm.ID)
+		if err := tx.Save(audit).Error; err != nil {
+			return fmt.Errorf("failed to save audit: %w", err)
+		}
+		return nil
+	}); err != nil {
+		return invitation, "", err
+	}
+	return invitation, token, nil
+}
+
+// FindRealmInvitation finds the invitation by its ID.
+func (db *Database) FindRealmInvitation(id interface{}) (*RealmInvitation, error) {
+	var invitation RealmInvitation
+	if err := db.db.
+		Model(&RealmInvitation{}).
+		Where("id = ?", id).
+		First(&invitation).
+		Error; err != nil {
+		return nil, err
+	}
+	return &invitation, nil
+}
+
+// FindRealmInvitationByToken finds the invitation with the given token,
+// checking the HMAC of the token against all HMAC keys.
+func (db *Database) FindRealmInvitationByToken(token string) (*RealmInvitation, error) {
+	hmacs, err := db.generateAPIKeyHMACs(token)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create hmac: %w", err)
+	}
+
+	var invitation RealmInvitation
+	if err := db.db.
+		Model(&RealmInvitation{}).
+		Where("token_hmac IN (?)", hmacs).
+		First(&invitation).
+		Error; err != nil {
+		return nil, err
+	}
+	return &invitation, nil
+}
+
+// ListRealmInvitations lists the invitations, newest first.
+func (db *Database) ListRealmInvitations(p *pagination.PageParams) ([]*RealmInvitation, *pagination.Paginator, error) {
+	var invitations []*RealmInvitation
+	query := db.db.
+		Model(&RealmInvitation{}).
+		Order("created_at DESC, id DESC")
+
+	if p == nil {
+		p = new(pagination.PageParams)
+	}
+
+	paginator, err := Paginate(query, &invitations, p.Page, p.Limit)
+	if err != nil {
+		if IsNotFound(err) {
+			return invitations, nil, nil
+		}
+		return nil, nil, err
+	}
+	return invitations, paginator, nil
+}
+
+// SubmitRealmInvitation records that the invitee finished the setup wizard.
+// The realm remains pending until a system admin approves the invitation.
+func (db *Database) SubmitRealmInvitation(invitation *RealmInvitation, actor Auditable) error {
+	if actor == nil {
+		return ErrMissingActor
+	}
+
+	switch invitation.Status() {
+	case RealmInvitationStatusExpired:
+		return ErrRealmInvitationExpired
+	case RealmInvitationStatusSubmitted, RealmInvitationStatusApproved:
+		return ErrRealmInvitationSubmitted
+	}
+
+	return db.db.Transaction(func(tx *gorm.DB) error {
+		now := time.Now().UTC()
+		if err := tx.
+			Model(invitation).
+			UpdateColumn("submitted_at", now).
+			Error; err != nil {
+			return fmt.Errorf("failed to submit realm invitation: %w", err)
+		}
+		invitation.SubmittedAt = &now
+
+		audit := BuildAuditEntry(actor, "submitted realm invitation", invitation, invitation.RealmID)
+		if err := tx.Save(audit).Error; err != nil {
+			return fmt.Errorf("failed to save audit: %w", err)
+		}
+		return nil
+	})
+}
+
+// ApproveRealmInvitation approves a submitted invitation and makes the
+// invitee, who must already have a user account, an admin of the realm.
+func (db *Database) ApproveRealmInvitation(invitation *RealmInvitation, actor Auditable) error {
+	if actor == nil {
+		return ErrMissingActor
+	}
+
+	switch invitation.Status() {
+	case RealmInvitationStatusApproved:
+		return ErrRealmInvitationApproved
+	case RealmInvitationStatusPending, RealmInvitationStatusExpired:
+		return ErrRealmInvitationNotSubmitted
+	}
+
+	realm, err := invitation.Realm(db)
+	if err != nil {
+		return fmt.Errorf("failed to find realm: %w", err)
+	}
+
+	user, err := db.FindUserByEmail(invitation.Email)
+	if err != nil {
+		return fmt.Errorf("failed to find invited user: %w", err)
+	}
+
+	if err := user.AddToRealm(db, realm, rbac.LegacyRealmAdmin, actor); err != nil {
+		return fmt.Errorf("failed to add invited user to realm: %w", err)
+	}
+
+	return db.db.Transaction(func(tx *gorm.DB) error {
+		now := time.Now().UTC()
+		if err := tx.
+			Model(invitation).
+			UpdateColumn("approved_at", now).
+			Error; err != nil {
+			return fmt.Errorf("failed to approve realm invitation: %w", err)
+		}
+		invitation.ApprovedAt = &now
+
+		audit := BuildAuditEntry(actor, "approved realm invitation", invitation, invitation.RealmID)
+		if err := tx.Save(audit).Error; err != nil {
+			return fmt.Errorf("failed to save audit: %w", err)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,155 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package database
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/exposure-notifications-verification-server/pkg/rbac"
+)
+
+func TestRealmInvitation_Status(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now().UTC()
+
+	cases := []struct {
+		name       string
+		invitation *RealmInvitation
+		exp        string
+	}{
+		{
+			name:       "pending",
+			invitation: &RealmInvitation{ExpiresAt: now.Add(time.Hour)},
+			exp:        RealmInvitationStatusPending,
+		},
+		{
+			name:       "expired",
+			invitation: &RealmInvitation{ExpiresAt: now.Add(-time.Hour)},
+			exp:        RealmInvitationStatusExpired,
+		},
+		{
+			name:       "submitted",
+			invitation: &RealmInvitation{ExpiresAt: now.Add(-time.Hour), SubmittedAt: &now},
+			exp:        RealmInvitationStatusSubmitted,
+		},
+		{
+			name:       "approved",
+			invitation: &RealmInvitation{ExpiresAt: now.Add(-time.Hour), SubmittedAt: &now, ApprovedAt: &now},
+			exp:        RealmInvitationStatusApproved,
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			if got, want := tc.invitation.Status(), tc.exp; got != want {
+				t.Errorf("expected %q to be %q", got, want)
+			}
+		})
+	}
+}
+
+func TestDatabase_RealmInvitation(t *testing.T) {
+	t.Parallel()
+
+	db, _ := testDatabaseInstance.NewDatabase(t, nil)
+
+	realm := NewRealmWithDefaults("pending realm")
+	if err := db.SaveRealm(realm, SystemTest); err != nil {
+		t.Fatal(err)
+	}
+
+	user := &User{
+		Email: "invitee@example.com",
+		Name:  "invitee@example.com",
+	}
+	if err := db.SaveUser(user, SystemTest); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, err := db.CreateRealmInvitation(realm, "not-an-email", time.Now().Add(time.Hour), SystemTest); !errors.Is(err, ErrValidationFailed) {
+		t.Errorf("expected %v to be %v", err, ErrValidationFailed)
+	}
+
+	invitation, token, err := db.CreateRealmInvitation(realm, "Invitee@example.com", time.Now().Add(time.Hour), SystemTest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := invitation.Email, "invitee@example.com"; got != want {
+		t.Errorf("expected %q to be %q", got, want)
+	}
+
+	found, err := db.FindRealmInvitationByToken(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := found.ID, invitation.ID; got != want {
+		t.Errorf("expected %d to be %d", got, want)
+	}
+
+	if _, err := db.FindRealmInvitationByToken("not-the-token"); !IsNotFound(err) {
+		t.Errorf("expected %v to be not found", err)
+	}
+
+	// Cannot approve before the invitee submits.
+	if err := db.ApproveRealmInvitation(found, SystemTest); !errors.Is(err, ErrRealmInvitationNotSubmitted) {
+		t.Errorf("expected %v to be %v", err, ErrRealmInvitationNotSubmitted)
+	}
+
+	if err := db.SubmitRealmInvitation(found, found); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.SubmitRealmInvitation(found, found); !errors.Is(err, ErrRealmInvitationSubmitted) {
+		t.Errorf("expected %v to be %v", err, ErrRealmInvitationSubmitted)
+	}
+
+	// The invitee is not a member until the invitation is approved.
+	if _, err := user.FindMembership(db, realm.ID); !IsNotFound(err) {
+		t.Errorf("expected %v to be not found", err)
+	}
+
+	if err := db.ApproveRealmInvitation(found, SystemTest); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := found.Status(), RealmInvitationStatusApproved; got != want {
+		t.Errorf("expected %q to be %q", got, want)
+	}
+
+	membership, err := user.FindMembership(db, realm.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := membership.Permissions, rbac.LegacyRealmAdmin; got != want {
+		t.Errorf("expected %v to be %v", got, want)
+	}
+
+	if err := db.ApproveRealmInvitation(found, SystemTest); !errors.Is(err, ErrRealmInvitationApproved) {
+		t.Errorf("expected %v to be %v", err, ErrRealmInvitationApproved)
+	}
+
+	invitations, _, err := db.ListRealmInvitations(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(invitations), 1; got != want {
+		t.Errorf("expected %d to be %d", got, want)
+	}
+}
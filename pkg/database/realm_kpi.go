@@ -0,0 +1,93 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package database
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/google/exposure-notifications-server/pkg/timeutils"
+)
+
+// RealmKPI is a snapshot of the business indicators for a single realm on a
+// single UTC day. Rates are derived from the counts so they are always
+// consistent with each other.
+type RealmKPI struct {
+	RealmID           uint `json:"realm_id"`
+	CodesIssued       uint `json:"codes_issued"`
+	CodesClaimed      uint `json:"codes_claimed"`
+	UserReportsIssued uint `json:"user_reports_issued"`
+	SMSErrors         uint `json:"sms_errors"`
+}
+
+// ClaimRate is the fraction of codes issued that were claimed. It returns 0 if
+// no codes were issued.
+func (k *RealmKPI) ClaimRate() float64 {
+	return kpiRatio(k.CodesClaimed, k.CodesIssued)
+}
+
+// UserReportShare is the fraction of codes issued that were user reports. It
+// returns 0 if no codes were issued.
+func (k *RealmKPI) UserReportShare() float64 {
+	return kpiRatio(k.UserReportsIssued, k.CodesIssued)
+}
+
+// SMSFailureRate is the number of SMS delivery errors relative to the number of
+// codes issued. Successful SMS sends are not persisted, so codes issued is the
+// closest available denominator. It returns 0 if no codes were issued.
+func (k *RealmKPI) SMSFailureRate() float64 {
+	return kpiRatio(k.SMSErrors, k.CodesIssued)
+}
+
+func kpiRatio(num, denom uint) float64 {
+	if denom == 0 {
+		return 0
+	}
+	return float64(num) / float64(denom)
+}
+
+// ListRealmKPIs returns the business indicators for all active realms on the
+// UTC day containing t. Realms with no activity are included with zero counts
+// so that gauges reset at the start of each day.
+func (db *Database) ListRealmKPIs(t time.Time) ([]*RealmKPI, error) {
+	date := timeutils.UTCMidnight(t)
+
+	sql := `
+		SELECT
+			r.id AS realm_id,
+			COALESCE(s.codes_issued, 0) AS codes_issued,
+			COALESCE(s.codes_claimed, 0) AS codes_claimed,
+			COALESCE(s.user_reports_issued, 0) AS user_reports_issued,
+			COALESCE(e.quantity, 0) AS sms_errors
+		FROM realms r
+		LEFT JOIN realm_stats s ON s.realm_id = r.id AND s.date = $1
+		LEFT JOIN (
+			SELECT realm_id, SUM(quantity) AS quantity
+			FROM sms_error_stats
+			WHERE date = $1
+			GROUP BY realm_id
+		) e ON e.realm_id = r.id
+		WHERE r.deleted_at IS NULL
+		ORDER BY r.id`
+
+	var kpis []*RealmKPI
+	if err := db.db.Raw(sql, date).Scan(&kpis).Error; err != nil {
+		if IsNotFound(err) {
+			return kpis, nil
+		}
+		return nil, fmt.Errorf("failed to list realm kpis: %w", err)
+	}
+	return kpis, nil
+}
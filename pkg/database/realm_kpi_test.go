@@ -0,0 +1,124 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package database
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/exposure-notifications-server/pkg/timeutils"
+)
+
+func TestRealmKPI_Rates(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name            string
+		kpi             *RealmKPI
+		claimRate       float64
+		userReportShare float64
+		smsFailureRate  float64
+	}{
+		{
+			name: "empty",
+			kpi:  &RealmKPI{},
+		},
+		{
+			name: "values",
+			kpi: &RealmKPI{
+				CodesIssued:       20,
+				CodesClaimed:      10,
+				UserReportsIssued: 5,
+				SMSErrors:         2,
+			},
+			claimRate:       0.5,
+			userReportShare: 0.25,
+			smsFailureRate:  0.1,
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			if got, want := tc.kpi.ClaimRate(), tc.claimRate; got != want {
+				t.Errorf("expected claim rate %v to be %v", got, want)
+			}
+			if got, want := tc.kpi.UserReportShare(), tc.userReportShare; got != want {
+				t.Errorf("expected user report share %v to be %v", got, want)
+			}
+			if got, want := tc.kpi.SMSFailureRate(), tc.smsFailureRate; got != want {
+				t.Errorf("expected sms failure rate %v to be %v", got, want)
+			}
+		})
+	}
+}
+
+func TestDatabase_ListRealmKPIs(t *testing.T) {
+	t.Parallel()
+
+	db, _ := testDatabaseInstance.NewDatabase(t, nil)
+
+	realm, err := db.FindRealm(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	today := timeutils.UTCMidnight(time.Now())
+	yesterday := today.Add(-24 * time.Hour)
+
+	for _, stat := range []*RealmStat{
+		{Date: today, RealmID: realm.ID, CodesIssued: 10, CodesClaimed: 4, UserReportsIssued: 2},
+		{Date: yesterday, RealmID: realm.ID, CodesIssued: 100, CodesClaimed: 100},
+	} {
+		if err := db.RawDB().Create(stat).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, code := range []string{"30003", "30003", "30005"} {
+		if err := db.InsertSMSErrorStat(today, realm.ID, code); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	kpis, err := db.ListRealmKPIs(time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got *RealmKPI
+	for _, k := range kpis {
+		if k.RealmID == realm.ID {
+			got = k
+		}
+	}
+	if got == nil {
+		t.Fatalf("expected kpis for realm %d in %#v", realm.ID, kpis)
+	}
+
+	want := &RealmKPI{
+		RealmID:           realm.ID,
+		CodesIssued:       10,
+		CodesClaimed:      4,
+		UserReportsIssued: 2,
+		SMSErrors:         3,
+	}
+	if *got != *want {
+		t.Errorf("expected %#v to be %#v", got, want)
+	}
+}
@@ -0,0 +1,244 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package database
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/jinzhu/gorm/dialects/postgres"
+	"golang.org/x/text/language"
+)
+
+// Message IDs of the user report web view strings that realms can localize.
+const (
+	WebStringAgencyDisplayName = "agencyDisplayName"
+	WebStringReportIntro       = "webReportIntroMessage"
+	WebStringReportDate        = "webReportDateMessage"
+	WebStringReportPhoneNumber = "webReportPhoneNumberMessage"
+	WebStringReportSuccess     = "webReportSuccessMessage"
+
+	// LocalizedWebStringMaxLength is the maximum length of a localized web
+	// string.
+	LocalizedWebStringMaxLength = 1000
+)
+
+const (
+	localizedKeySeparator      = ":"
+	localizedSMSTemplatesField = "smsTextLocalizedTemplates"
+	localizedWebStringsField   = "localizedWebStrings"
+)
+
+// LocalizableWebStrings are the message IDs of the web view strings that realm
+// admins can localize, in display order.
+var LocalizableWebStrings = []string{
+	WebStringAgencyDisplayName,
+	WebStringReportIntro,
+	WebStringReportDate,
+	WebStringReportPhoneNumber,
+	WebStringReportSuccess,
+}
+
+// LocalizedKey returns the key for the localized variant of name (an SMS
+// template label or web string message ID) in the given locale.
+func LocalizedKey(locale, name string) string {
+	return normalizeLocale(locale) + localizedKeySeparator + name
+}
+
+// SplitLocalizedKey is the inverse of LocalizedKey. It returns false if the key
+// is not a localized key.
+func SplitLocalizedKey(key string) (string, string, bool) {
+	parts := strings.SplitN(key, localizedKeySeparator, 2)
+	if len(parts) != 2 {
+		return "", "", false
+	}
+	return normalizeLocale(parts[0]), parts[1], true
+}
+
+// localeCandidates returns the normalized locale followed by its base language
+// (e.g. "es" for "es-MX"), if different.
+func localeCandidates(locale string) []string {
+	locale = normalizeLocale(locale)
+	if locale == "" {
+		return nil
+	}
+
+	candidates := []string{locale}
+	if i := strings.Index(locale, "-"); i > 0 {
+		candidates = append(candidates, locale[:i])
+	}
+	return candidates
+}
+
+// SMSTextTemplateFor returns the SMS template for the label in the given
+// locale. It prefers a variant for the locale, then its base language, then
+// the realm's default locale and its base language. If the realm has no
+// matching variant, the template for the label is returned. An empty label
+// selects the default template.
+func (r *Realm) SMSTextTemplateFor(label, locale string) (string, error) {
+	if label == "" {
+		label = DefaultTemplateLabel
+	}
+
+	text := r.SMSTextTemplate
+	if label != DefaultTemplateLabel {
+		t, ok := r.SMSTextAlternateTemplates[label]
+		if !ok || t == nil || *t == "" {
+			return "", fmt.Errorf("no template found for label %s", label)
+		}
+		text = *t
+	}
+
+	if len(r.SMSTextLocalizedTemplates) == 0 {
+		return text, nil
+	}
+
+	candidates := append(localeCandidates(locale), localeCandidates(r.DefaultLocale)...)
+	for _, l := range candidates {
+		if t, ok := r.SMSTextLocalizedTemplates[LocalizedKey(l, label)]; ok && t != nil && *t != "" {
+			return *t, nil
+		}
+	}
+	return text, nil
+}
+
+// SMSTemplateLabels returns the labels of all SMS templates on the realm,
+// starting with the default template.
+func (r *Realm) SMSTemplateLabels() []string {
+	labels := []string{DefaultTemplateLabel}
+	for l := range r.SMSTextAlternateTemplates {
+		labels = append(labels, l)
+	}
+	sort.Strings(labels[1:])
+	return labels
+}
+
+// validateLocale normalizes the locale and checks that it is a valid BCP-47
+// language tag. Errors are added to field.
+func (r *Realm) validateLocale(field, locale string) (string, bool) {
+	if locale == "" {
+		r.AddError(field, "locale cannot be blank")
+		return "", false
+	}
+	if _, err := language.Parse(locale); err != nil {
+		r.AddError(field, fmt.Sprintf("locale %q is not a valid language tag", locale))
+		return "", false
+	}
+	return locale, true
+}
+
+// validateLocalizedSMSTemplates normalizes the keys of the localized SMS
+// templates and validates each template like the template it localizes. It
+// returns the normalized templates.
+func (r *Realm) validateLocalizedSMSTemplates(variants postgres.Hstore) postgres.Hstore {
+	if len(variants) == 0 {
+		return nil
+	}
+
+	normalized := make(postgres.Hstore, len(variants))
+	for k, t := range variants {
+		locale, label, _ := SplitLocalizedKey(k)
+		locale, ok := r.validateLocale(localizedSMSTemplatesField, locale)
+		if !ok {
+			continue
+		}
+		if label != DefaultTemplateLabel {
+			if _, ok := r.SMSTextAlternateTemplates[label]; !ok {
+				r.AddError(localizedSMSTemplatesField, fmt.Sprintf("no SMS template with label %q", label))
+				continue
+			}
+		}
+
+		key := LocalizedKey(locale, label)
+		if _, ok := normalized[key]; ok {
+			r.AddError(localizedSMSTemplatesField, fmt.Sprintf("locale %q is listed more than once for %s", locale, label))
+			continue
+		}
+		if t == nil || strings.TrimSpace(*t) == "" {
+			r.AddError(localizedSMSTemplatesField, fmt.Sprintf("no template for %s in locale %s", label, locale))
+			continue
+		}
+
+		text := r.validateSMSTemplateText(localizedSMSTemplatesField, key, label, *t)
+		normalized[key] = &text
+	}
+	return normalized
+}
+
+// validateLocalizedWebStrings normalizes the keys of the localized web strings
+// and checks that each one is a known, non-empty message. It returns the
+// normalized strings.
+func (r *Realm) validateLocalizedWebStrings(variants postgres.Hstore) postgres.Hstore {
+	if len(variants) == 0 {
+		return nil
+	}
+
+	known := make(map[string]struct{}, len(LocalizableWebStrings))
+	for _, id := range LocalizableWebStrings {
+		known[id] = struct{}{}
+	}
+
+	normalized := make(postgres.Hstore, len(variants))
+	for k, t := range variants {
+		locale, id, _ := SplitLocalizedKey(k)
+		locale, ok := r.validateLocale(localizedWebStringsField, locale)
+		if !ok {
+			continue
+		}
+		if _, ok := known[id]; !ok {
+			r.AddError(localizedWebStringsField, fmt.Sprintf("%q is not a localizable string", id))
+			continue
+		}
+
+		key := LocalizedKey(locale, id)
+		if _, ok := normalized[key]; ok {
+			r.AddError(localizedWebStringsField, fmt.Sprintf("locale %q is listed more than once for %s", locale, id))
+			continue
+		}
+		if t == nil || strings.TrimSpace(*t) == "" {
+			r.AddError(localizedWebStringsField, fmt.Sprintf("no text for %s in locale %s", id, locale))
+			continue
+		}
+		if l := len(*t); l > LocalizedWebStringMaxLength {
+			r.AddError(localizedWebStringsField, fmt.Sprintf("text for %s in locale %s must be %d characters or less", id, locale, LocalizedWebStringMaxLength))
+			r.AddError(key, fmt.Sprintf("must be %d characters or less", LocalizedWebStringMaxLength))
+			continue
+		}
+
+		text := strings.TrimSpace(*t)
+		normalized[key] = &text
+	}
+	return normalized
+}
+
+// localizedWebStringTranslations returns the realm's localized web strings as
+// dynamic translations.
+func (r *Realm) localizedWebStringTranslations() []*DynamicTranslation {
+	translations := make([]*DynamicTranslation, 0, len(r.LocalizedWebStrings))
+	for k, v := range r.LocalizedWebStrings {
+		locale, id, ok := SplitLocalizedKey(k)
+		if !ok || v == nil {
+			continue
+		}
+		translations = append(translations, &DynamicTranslation{
+			RealmID:   r.ID,
+			MessageID: id,
+			Locale:    locale,
+			Message:   *v,
+		})
+	}
+	return translations
+}
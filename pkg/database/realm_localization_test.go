@@ -0,0 +1,264 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package database
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/jinzhu/gorm/dialects/postgres"
+)
+
+func TestLocalizedKey(t *testing.T) {
+	t.Parallel()
+
+	key := LocalizedKey("es_MX", "my:label")
+	if got, want := key, "es-mx:my:label"; got != want {
+		t.Errorf("expected %q to be %q", got, want)
+	}
+
+	locale, name, ok := SplitLocalizedKey(key)
+	if !ok {
+		t.Fatal("expected key to split")
+	}
+	if got, want := locale, "es-mx"; got != want {
+		t.Errorf("expected %q to be %q", got, want)
+	}
+	if got, want := name, "my:label"; got != want {
+		t.Errorf("expected %q to be %q", got, want)
+	}
+
+	if _, _, ok := SplitLocalizedKey("nolocale"); ok {
+		t.Error("expected key without separator not to split")
+	}
+}
+
+func TestRealm_SMSTextTemplateFor(t *testing.T) {
+	t.Parallel()
+
+	alternate := "Alternate [longcode]"
+	es := "Spanish [longcode]"
+	esMX := "Mexican Spanish [longcode]"
+	fr := "French [longcode]"
+	esAlternate := "Spanish alternate [longcode]"
+
+	realm := NewRealmWithDefaults("test")
+	realm.SMSTextTemplate = "Default [longcode]"
+	realm.SMSTextAlternateTemplates = postgres.Hstore{
+		"alternate": &alternate,
+	}
+	realm.SMSTextLocalizedTemplates = postgres.Hstore{
+		LocalizedKey("es", DefaultTemplateLabel):    &es,
+		LocalizedKey("es-mx", DefaultTemplateLabel): &esMX,
+		LocalizedKey("fr", DefaultTemplateLabel):    &fr,
+		LocalizedKey("es", "alternate"):             &esAlternate,
+	}
+
+	cases := []struct {
+		name          string
+		label         string
+		locale        string
+		defaultLocale string
+		exp           string
+		err           bool
+	}{
+		{
+			name: "blank",
+			exp:  realm.SMSTextTemplate,
+		},
+		{
+			name:   "exact",
+			locale: "es-MX",
+			exp:    esMX,
+		},
+		{
+			name:   "base_language",
+			locale: "es-AR",
+			exp:    es,
+		},
+		{
+			name:   "alternate_label",
+			label:  "alternate",
+			locale: "es-MX",
+			exp:    esAlternate,
+		},
+		{
+			name:   "alternate_no_match",
+			label:  "alternate",
+			locale: "fr",
+			exp:    alternate,
+		},
+		{
+			name:          "default_locale",
+			locale:        "de",
+			defaultLocale: "fr-CA",
+			exp:           fr,
+		},
+		{
+			name:          "default_locale_blank_request",
+			defaultLocale: "es",
+			exp:           es,
+		},
+		{
+			name:   "no_match",
+			locale: "de",
+			exp:    realm.SMSTextTemplate,
+		},
+		{
+			name:  "missing_label",
+			label: "nope",
+			err:   true,
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			r := *realm
+			r.DefaultLocale = tc.defaultLocale
+
+			got, err := r.SMSTextTemplateFor(tc.label, tc.locale)
+			if (err != nil) != tc.err {
+				t.Fatalf("expected error %t, got %v", tc.err, err)
+			}
+			if got != tc.exp {
+				t.Errorf("expected %q to be %q", got, tc.exp)
+			}
+		})
+	}
+
+	got, err := realm.BuildLocalizedSMSText("123456", "abcdefgh", "", "", "es")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "Spanish abcdefgh"; got != want {
+		t.Errorf("expected %q to be %q", got, want)
+	}
+}
+
+func TestRealm_validateLocalizedSMSTemplates(t *testing.T) {
+	t.Parallel()
+
+	ok := "Su código [longcode] vence en [longexpires] horas"
+	missingCode := "Su código vence pronto"
+
+	cases := []struct {
+		name      string
+		templates postgres.Hstore
+		errField  string
+		errSubstr string
+	}{
+		{
+			name: "valid",
+			templates: postgres.Hstore{
+				"ES_mx:" + DefaultTemplateLabel: &ok,
+			},
+		},
+		{
+			name: "blank_locale",
+			templates: postgres.Hstore{
+				":" + DefaultTemplateLabel: &ok,
+			},
+			errField:  localizedSMSTemplatesField,
+			errSubstr: "locale cannot be blank",
+		},
+		{
+			name: "invalid_locale",
+			templates: postgres.Hstore{
+				"not a locale:" + DefaultTemplateLabel: &ok,
+			},
+			errField:  localizedSMSTemplatesField,
+			errSubstr: "not a valid language tag",
+		},
+		{
+			name: "unknown_label",
+			templates: postgres.Hstore{
+				"es:nope": &ok,
+			},
+			errField:  localizedSMSTemplatesField,
+			errSubstr: "no SMS template with label",
+		},
+		{
+			name: "invalid_template",
+			templates: postgres.Hstore{
+				"es:" + DefaultTemplateLabel: &missingCode,
+			},
+			errField:  LocalizedKey("es", DefaultTemplateLabel),
+			errSubstr: "must contain exactly one of",
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			realm := NewRealmWithDefaults("test")
+			realm.SMSTextLocalizedTemplates = realm.validateLocalizedSMSTemplates(tc.templates)
+
+			errs := realm.ErrorsFor(tc.errField)
+			if tc.errField == "" {
+				if msgs := realm.ErrorMessages(); len(msgs) > 0 {
+					t.Fatalf("expected no errors, got %v", msgs)
+				}
+				if got, want := len(realm.SMSTextLocalizedTemplates), 1; got != want {
+					t.Fatalf("expected %d templates, got %d", want, got)
+				}
+				if _, ok := realm.SMSTextLocalizedTemplates[LocalizedKey("es-mx", DefaultTemplateLabel)]; !ok {
+					t.Errorf("expected normalized key in %v", realm.SMSTextLocalizedTemplates)
+				}
+				return
+			}
+			if got := strings.Join(errs, ","); !strings.Contains(got, tc.errSubstr) {
+				t.Errorf("expected %q to contain %q", got, tc.errSubstr)
+			}
+		})
+	}
+}
+
+func TestRealm_validateLocalizedWebStrings(t *testing.T) {
+	t.Parallel()
+
+	hello := "  Hola  "
+	long := strings.Repeat("a", LocalizedWebStringMaxLength+1)
+
+	realm := NewRealmWithDefaults("test")
+	got := realm.validateLocalizedWebStrings(postgres.Hstore{
+		"es:" + WebStringReportIntro: &hello,
+	})
+	if msgs := realm.ErrorMessages(); len(msgs) > 0 {
+		t.Fatalf("expected no errors, got %v", msgs)
+	}
+	if v := got[LocalizedKey("es", WebStringReportIntro)]; v == nil || *v != "Hola" {
+		t.Errorf("expected trimmed value, got %v", v)
+	}
+
+	realm = NewRealmWithDefaults("test")
+	_ = realm.validateLocalizedWebStrings(postgres.Hstore{
+		"es:unknownMessage":         &hello,
+		"fr:" + WebStringReportDate: &long,
+	})
+	errs := strings.Join(realm.ErrorsFor(localizedWebStringsField), ",")
+	if !strings.Contains(errs, "is not a localizable string") {
+		t.Errorf("expected unknown message error, got %q", errs)
+	}
+	if !strings.Contains(errs, "characters or less") {
+		t.Errorf("expected length error, got %q", errs)
+	}
+}
@@ -0,0 +1,391 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package database
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/exposure-notifications-verification-server/internal/project"
+	"github.com/google/exposure-notifications-verification-server/pkg/pagination"
+	"github.com/jinzhu/gorm"
+	"github.com/lib/pq"
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/russross/blackfriday/v2"
+)
+
+// RealmNotificationKind is the source of a realm notification.
+type RealmNotificationKind string
+
+const (
+	// RealmNotificationKindSigningKeyRotation is posted when a new certificate
+	// signing key was created and will soon become active.
+	RealmNotificationKindSigningKeyRotation RealmNotificationKind = "signing_key_rotation"
+
+	// RealmNotificationKindAbusePrevention is posted when the realm exceeds the
+	// daily quota configured by abuse prevention.
+	RealmNotificationKindAbusePrevention RealmNotificationKind = "abuse_prevention"
+
+	// RealmNotificationKindCodesClaimedRatio is posted when the modeler detects
+	// an anomalous codes claimed ratio.
+	RealmNotificationKindCodesClaimedRatio RealmNotificationKind = "codes_claimed_ratio"
+
+	// RealmNotificationKindOperator is posted manually by a system admin.
+	RealmNotificationKindOperator RealmNotificationKind = "operator"
+)
+
+// Display is the human-readable kind.
+func (k RealmNotificationKind) Display() string {
+	switch k {
+	case RealmNotificationKindSigningKeyRotation:
+		return "Signing key rotation"
+	case RealmNotificationKindAbusePrevention:
+		return "Abuse prevention"
+	case RealmNotificationKindCodesClaimedRatio:
+		return "Codes claimed ratio"
+	case RealmNotificationKindOperator:
+		return "System operator"
+	default:
+		return "Unknown"
+	}
+}
+
+// RealmNotification is a message the system posts to a realm's notification
+// center. It can optionally be fanned out by email to the realm's contacts and
+// by SMS to the realm's notification phone numbers.
+type RealmNotification struct {
+	Errorable
+
+	// ID is the notification's ID.
+	ID uint `gorm:"primary_key;"`
+
+	// RealmID is the realm to which the notification was posted.
+	RealmID uint `gorm:"column:realm_id; type:integer; not null;"`
+
+	// Kind is the source of the notification.
+	Kind RealmNotificationKind `gorm:"column:kind; type:text; not null;"`
+
+	// DedupeKey prevents the same condition from being posted more than once. At
+	// most one notification exists for each realm, kind, and non-empty dedupe
+	// key.
+	DedupeKey string `gorm:"column:dedupe_key; type:text; not null; default:'';"`
+
+	// Title is the short headline of the notification.
+	Title string `gorm:"column:title; type:text; not null;"`
+
+	// Body is the notification text. It supports markdown syntax.
+	Body string `gorm:"column:body; type:text; not null;"`
+
+	// Link is an optional path on the server where the realm admin can act on
+	// the notification, such as "/realm/keys".
+	Link string `gorm:"column:link; type:text; not null; default:'';"`
+
+	// SendEmail and SendSMS determine if the notification is fanned out to the
+	// realm's contact email addresses and notification phone numbers.
+	SendEmail bool `gorm:"column:send_email; type:bool; not null; default:false;"`
+	SendSMS   bool `gorm:"column:send_sms; type:bool; not null; default:false;"`
+
+	// FanoutRecipients are the email addresses and phone numbers the
+	// notification was sent to.
+	FanoutRecipients pq.StringArray `gorm:"column:fanout_recipients; type:text[];"`
+
+	// FannedOutAt is when the fan-out was processed. It is nil while the fan-out
+	// is pending or if there is nothing to fan out.
+	FannedOutAt *time.Time `gorm:"column:fanned_out_at; type:timestamp with time zone;"`
+
+	// Read is true if the current user has read the notification. It is
+	// populated by ListRealmNotifications.
+	Read bool `gorm:"-"`
+
+	CreatedAt time.Time
+}
+
+// TableName sets the table name.
+func (RealmNotification) TableName() string {
+	return "realm_notifications"
+}
+
+// BeforeSave runs validations. If there are errors, the save fails.
+func (n *RealmNotification) BeforeSave(tx *gorm.DB) error {
+	if n.RealmID == 0 {
+		n.AddError("realmID", "is required")
+	}
+
+	switch n.Kind {
+	case RealmNotificationKindSigningKeyRotation,
+		RealmNotificationKindAbusePrevention,
+		RealmNotificationKindCodesClaimedRatio,
+		RealmNotificationKindOperator:
+	default:
+		n.AddError("kind", "is invalid")
+	}
+
+	n.Title = project.TrimSpace(n.Title)
+	if n.Title == "" {
+		n.AddError("title", "cannot be blank")
+	}
+
+	n.Body = project.TrimSpace(n.Body)
+	if n.Body == "" {
+		n.AddError("body", "cannot be blank")
+	}
+
+	n.Link = project.TrimSpace(n.Link)
+	if n.Link != "" && (!strings.HasPrefix(n.Link, "/") || strings.HasPrefix(n.Link, "//")) {
+		n.AddError("link", "must be a path on this server, like /realm/keys")
+	}
+
+	return n.ErrorOrNil()
+}
+
+// AuditID is how the notification is stored in the audit entry.
+func (n *RealmNotification) AuditID() string {
+	return fmt.Sprintf("realm_notifications:%d", n.ID)
+}
+
+// AuditDisplay is how the notification will be displayed in audit entries.
+func (n *RealmNotification) AuditDisplay() string {
+	return n.Title
+}
+
+// BodyHTML returns the body rendered from markdown and sanitized.
+func (n *RealmNotification) BodyHTML() string {
+	raw := blackfriday.Run([]byte(strings.TrimSpace(n.Body)))
+	return string(bluemonday.UGCPolicy().SanitizeBytes(raw))
+}
+
+// PostRealmNotification posts the notification to its realm. If the
+// notification has a dedupe key and one was already posted for the realm, kind,
+// and key, it does nothing and returns false.
+func (db *Database) PostRealmNotification(n *RealmNotification) (bool, error) {
+	if n == nil {
+		return false, fmt.Errorf("provided notification is nil")
+	}
+
+	// Most repeat posts are caught here, which avoids a failed insert.
+	if n.DedupeKey != "" {
+		var count int64
+		if err := db.db.
+			Model(&RealmNotification{}).
+			Where("realm_id = ? AND kind = ? AND dedupe_key = ?", n.RealmID, n.Kind, n.DedupeKey).
+			Count(&count).
+			Error; err != nil {
+			return false, fmt.Errorf("failed to check for existing notification: %w", err)
+		}
+		if count > 0 {
+			return false, nil
+		}
+	}
+
+	if err := db.db.Create(n).Error; err != nil {
+		if IsUniqueViolation(err, "uix_realm_notifications_dedupe") {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to post notification: %w", err)
+	}
+	return true, nil
+}
+
+// PostOperatorRealmNotification posts a notification written by a system
+// operator to its realm and audits it. Operator notifications are never
+// deduplicated.
+func (db *Database) PostOperatorRealmNotification(n *RealmNotification, actor Auditable) error {
+	if n == nil {
+		return fmt.Errorf("provided notification is nil")
+	}
+	if actor == nil {
+		return ErrMissingActor
+	}
+
+	n.Kind = RealmNotificationKindOperator
+	n.DedupeKey = ""
+
+	return db.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(n).Error; err != nil {
+			return err
+		}
+
+		audit := BuildAuditEntry(actor, "posted notification", n, n.RealmID)
+		if err := tx.Save(audit).Error; err != nil {
+			return fmt.Errorf("failed to save audit: %w", err)
+		}
+		return nil
+	})
+}
+
+// ListRealmNotifications lists the realm's notifications, newest first, with
+// Read populated for the given user.
+func (r *Realm) ListRealmNotifications(db *Database, user *User, p *pagination.PageParams) ([]*RealmNotification, *pagination.Paginator, error) {
+	var notifications []*RealmNotification
+
+	query := db.db.
+		Model(&RealmNotification{}).
+		Where("realm_id = ?", r.ID).
+		Order("created_at DESC, id DESC")
+
+	if p == nil {
+		p = new(pagination.PageParams)
+	}
+
+	paginator, err := Paginate(query, &notifications, p.Page, p.Limit)
+	if err != nil {
+		if IsNotFound(err) {
+			return notifications, nil, nil
+		}
+		return nil, nil, err
+	}
+
+	if user != nil && len(notifications) > 0 {
+		ids := make([]uint, 0, len(notifications))
+		for _, n := range notifications {
+			ids = append(ids, n.ID)
+		}
+
+		var readIDs []uint
+		if err := db.db.
+			Table("realm_notification_reads").
+			Where("user_id = ?", user.ID).
+			Where("notification_id IN (?)", ids).
+			Pluck("notification_id", &readIDs).
+			Error; err != nil && !IsNotFound(err) {
+			return nil, nil, fmt.Errorf("failed to load notification reads: %w", err)
+		}
+
+		read := make(map[uint]struct{}, len(readIDs))
+		for _, id := range readIDs {
+			read[id] = struct{}{}
+		}
+		for _, n := range notifications {
+			_, n.Read = read[n.ID]
+		}
+	}
+
+	return notifications, paginator, nil
+}
+
+// FindRealmNotification finds the notification by the given id associated with
+// the realm.
+func (r *Realm) FindRealmNotification(db *Database, id interface{}) (*RealmNotification, error) {
+	var n RealmNotification
+	if err := db.db.
+		Model(&RealmNotification{}).
+		Where("id = ?", id).
+		Where("realm_id = ?", r.ID).
+		First(&n).
+		Error; err != nil {
+		return nil, err
+	}
+	return &n, nil
+}
+
+// CountUnreadRealmNotifications returns the number of the realm's
+// notifications that the user has not read.
+func (r *Realm) CountUnreadRealmNotifications(db *Database, user *User) (int64, error) {
+	if user == nil {
+		return 0, nil
+	}
+
+	var count int64
+	if err := db.db.
+		Model(&RealmNotification{}).
+		Where("realm_id = ?", r.ID).
+		Where("NOT EXISTS (SELECT 1 FROM realm_notification_reads WHERE notification_id = realm_notifications.id AND user_id = ?)", user.ID).
+		Count(&count).
+		Error; err != nil {
+		return 0, fmt.Errorf("failed to count unread notifications: %w", err)
+	}
+	return count, nil
+}
+
+// MarkRealmNotificationRead records that the user read the notification. It is
+// safe to call multiple times.
+func (db *Database) MarkRealmNotificationRead(n *RealmNotification, user *User) error {
+	if n == nil {
+		return fmt.Errorf("provided notification is nil")
+	}
+	if user == nil {
+		return ErrMissingActor
+	}
+
+	sql := `
+		INSERT INTO realm_notification_reads (notification_id, user_id, read_at)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (notification_id, user_id) DO NOTHING`
+	if err := db.db.Exec(sql, n.ID, user.ID, time.Now().UTC()).Error; err != nil {
+		return fmt.Errorf("failed to mark notification read: %w", err)
+	}
+	return nil
+}
+
+// MarkAllRealmNotificationsRead records that the user read all of the realm's
+// notifications.
+func (r *Realm) MarkAllRealmNotificationsRead(db *Database, user *User) error {
+	if user == nil {
+		return ErrMissingActor
+	}
+
+	sql := `
+		INSERT INTO realm_notification_reads (notification_id, user_id, read_at)
+		SELECT id, $1, $2 FROM realm_notifications WHERE realm_id = $3
+		ON CONFLICT (notification_id, user_id) DO NOTHING`
+	if err := db.db.Exec(sql, user.ID, time.Now().UTC(), r.ID).Error; err != nil {
+		return fmt.Errorf("failed to mark notifications read: %w", err)
+	}
+	return nil
+}
+
+// ListPendingRealmNotificationFanouts lists the notifications that should be
+// sent by email or SMS but have not been processed, ordered by realm.
+func (db *Database) ListPendingRealmNotificationFanouts() ([]*RealmNotification, error) {
+	var notifications []*RealmNotification
+	if err := db.db.
+		Model(&RealmNotification{}).
+		Where("send_email IS TRUE OR send_sms IS TRUE").
+		Where("fanned_out_at IS NULL").
+		Order("realm_id ASC, id ASC").
+		Find(&notifications).
+		Error; err != nil {
+		if IsNotFound(err) {
+			return notifications, nil
+		}
+		return nil, err
+	}
+	return notifications, nil
+}
+
+// MarkRealmNotificationFannedOut marks the notification's fan-out as
+// processed, recording the email addresses and phone numbers that received it.
+func (db *Database) MarkRealmNotificationFannedOut(n *RealmNotification, recipients []string) error {
+	if n == nil {
+		return fmt.Errorf("provided notification is nil")
+	}
+
+	now := time.Now().UTC()
+	if err := db.db.
+		Model(&RealmNotification{}).
+		Where("id = ?", n.ID).
+		UpdateColumns(map[string]interface{}{
+			"fanned_out_at":     now,
+			"fanout_recipients": pq.StringArray(recipients),
+		}).
+		Error; err != nil {
+		return fmt.Errorf("failed to mark notification fanned out: %w", err)
+	}
+
+	n.FannedOutAt = &now
+	n.FanoutRecipients = recipients
+	return nil
+}
@@ -0,0 +1,296 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package database
+
+import (
+	"testing"
+)
+
+func TestRealmNotification_BeforeSave(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name         string
+		notification *RealmNotification
+		errKey       string
+	}{
+		{
+			name: "valid",
+			notification: &RealmNotification{
+				RealmID: 1,
+				Kind:    RealmNotificationKindOperator,
+				Title:   "Title",
+				Body:    "Body",
+				Link:    "/realm/keys",
+			},
+		},
+		{
+			name: "invalid_kind",
+			notification: &RealmNotification{
+				RealmID: 1,
+				Kind:    "nope",
+				Title:   "Title",
+				Body:    "Body",
+			},
+			errKey: "kind",
+		},
+		{
+			name: "blank_title",
+			notification: &RealmNotification{
+				RealmID: 1,
+				Kind:    RealmNotificationKindOperator,
+				Title:   "  ",
+				Body:    "Body",
+			},
+			errKey: "title",
+		},
+		{
+			name: "blank_body",
+			notification: &RealmNotification{
+				RealmID: 1,
+				Kind:    RealmNotificationKindOperator,
+				Title:   "Title",
+			},
+			errKey: "body",
+		},
+		{
+			name: "absolute_link",
+			notification: &RealmNotification{
+				RealmID: 1,
+				Kind:    RealmNotificationKindOperator,
+				Title:   "Title",
+				Body:    "Body",
+				Link:    "https://example.com",
+			},
+			errKey: "link",
+		},
+		{
+			name: "protocol_relative_link",
+			notification: &RealmNotification{
+				RealmID: 1,
+				Kind:    RealmNotificationKindOperator,
+				Title:   "Title",
+				Body:    "Body",
+				Link:    "//example.com",
+			},
+			errKey: "link",
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			_ = tc.notification.BeforeSave(nil)
+
+			errs := tc.notification.ErrorsFor(tc.errKey)
+			if tc.errKey == "" {
+				if msgs := tc.notification.ErrorMessages(); len(msgs) > 0 {
+					t.Errorf("expected no errors, got %v", msgs)
+				}
+				return
+			}
+			if len(errs) == 0 {
+				t.Errorf("expected errors for %q", tc.errKey)
+			}
+		})
+	}
+}
+
+func TestDatabase_PostRealmNotification(t *testing.T) {
+	t.Parallel()
+
+	db, _ := testDatabaseInstance.NewDatabase(t, nil)
+
+	realm := NewRealmWithDefaults("notifications")
+	if err := db.SaveRealm(realm, SystemTest); err != nil {
+		t.Fatal(err)
+	}
+
+	build := func() *RealmNotification {
+		return &RealmNotification{
+			RealmID:   realm.ID,
+			Kind:      RealmNotificationKindSigningKeyRotation,
+			DedupeKey: "v1",
+			Title:     "Rotation",
+			Body:      "A new key will be activated.",
+		}
+	}
+
+	posted, err := db.PostRealmNotification(build())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !posted {
+		t.Errorf("expected notification to be posted")
+	}
+
+	// Same dedupe key is ignored.
+	posted, err = db.PostRealmNotification(build())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if posted {
+		t.Errorf("expected duplicate notification to be ignored")
+	}
+
+	// Different dedupe key is posted.
+	n := build()
+	n.DedupeKey = "v2"
+	if posted, err := db.PostRealmNotification(n); err != nil {
+		t.Fatal(err)
+	} else if !posted {
+		t.Errorf("expected notification with new dedupe key to be posted")
+	}
+
+	notifications, _, err := realm.ListRealmNotifications(db, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(notifications), 2; got != want {
+		t.Errorf("expected %d notifications, got %d", want, got)
+	}
+}
+
+func TestRealm_RealmNotificationReads(t *testing.T) {
+	t.Parallel()
+
+	db, _ := testDatabaseInstance.NewDatabase(t, nil)
+
+	realm := NewRealmWithDefaults("notifications")
+	if err := db.SaveRealm(realm, SystemTest); err != nil {
+		t.Fatal(err)
+	}
+
+	user := &User{
+		Email: "notifications@example.com",
+		Name:  "Notifications",
+	}
+	if err := db.SaveUser(user, SystemTest); err != nil {
+		t.Fatal(err)
+	}
+
+	var posted []*RealmNotification
+	for _, title := range []string{"One", "Two", "Three"} {
+		n := &RealmNotification{
+			RealmID: realm.ID,
+			Kind:    RealmNotificationKindOperator,
+			Title:   title,
+			Body:    title,
+		}
+		if _, err := db.PostRealmNotification(n); err != nil {
+			t.Fatal(err)
+		}
+		posted = append(posted, n)
+	}
+
+	countUnread := func(tb testing.TB) int64 {
+		tb.Helper()
+
+		count, err := realm.CountUnreadRealmNotifications(db, user)
+		if err != nil {
+			tb.Fatal(err)
+		}
+		return count
+	}
+
+	if got, want := countUnread(t), int64(3); got != want {
+		t.Errorf("expected %d to be %d", got, want)
+	}
+
+	// Marking read twice is safe.
+	for i := 0; i < 2; i++ {
+		if err := db.MarkRealmNotificationRead(posted[0], user); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got, want := countUnread(t), int64(2); got != want {
+		t.Errorf("expected %d to be %d", got, want)
+	}
+
+	notifications, _, err := realm.ListRealmNotifications(db, user, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, n := range notifications {
+		if got, want := n.Read, n.ID == posted[0].ID; got != want {
+			t.Errorf("expected notification %d read to be %t", n.ID, want)
+		}
+	}
+
+	if err := realm.MarkAllRealmNotificationsRead(db, user); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := countUnread(t), int64(0); got != want {
+		t.Errorf("expected %d to be %d", got, want)
+	}
+}
+
+func TestDatabase_RealmNotificationFanouts(t *testing.T) {
+	t.Parallel()
+
+	db, _ := testDatabaseInstance.NewDatabase(t, nil)
+
+	realm := NewRealmWithDefaults("notifications")
+	if err := db.SaveRealm(realm, SystemTest); err != nil {
+		t.Fatal(err)
+	}
+
+	quiet := &RealmNotification{
+		RealmID: realm.ID,
+		Kind:    RealmNotificationKindOperator,
+		Title:   "Quiet",
+		Body:    "Only in the notification center",
+	}
+	if _, err := db.PostRealmNotification(quiet); err != nil {
+		t.Fatal(err)
+	}
+
+	loud := &RealmNotification{
+		RealmID:   realm.ID,
+		Kind:      RealmNotificationKindOperator,
+		Title:     "Loud",
+		Body:      "Also by email",
+		SendEmail: true,
+	}
+	if _, err := db.PostRealmNotification(loud); err != nil {
+		t.Fatal(err)
+	}
+
+	pending, err := db.ListPendingRealmNotificationFanouts()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(pending), 1; got != want {
+		t.Fatalf("expected %d pending, got %d", want, got)
+	}
+	if got, want := pending[0].ID, loud.ID; got != want {
+		t.Errorf("expected %d to be %d", got, want)
+	}
+
+	if err := db.MarkRealmNotificationFannedOut(pending[0], []string{"admin@example.com"}); err != nil {
+		t.Fatal(err)
+	}
+
+	pending, err = db.ListPendingRealmNotificationFanouts()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(pending), 0; got != want {
+		t.Errorf("expected %d pending, got %d", want, got)
+	}
+}
@@ -0,0 +1,253 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package database
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/google/exposure-notifications-server/pkg/timeutils"
+	"github.com/google/exposure-notifications-verification-server/internal/project"
+	"github.com/jinzhu/gorm"
+)
+
+// maxStatCorrectionReasonLength is the maximum length of a correction reason.
+const maxStatCorrectionReasonLength = 255
+
+// RealmStatCorrectionFields are the realm_stats columns that system admins may
+// correct.
+var RealmStatCorrectionFields = []string{
+	"codes_issued",
+	"codes_claimed",
+	"codes_invalid",
+	"user_reports_issued",
+	"user_reports_claimed",
+	"user_reports_invalid_nonce",
+	"tokens_claimed",
+	"tokens_invalid",
+	"user_report_tokens_claimed",
+}
+
+var _ Auditable = (*RealmStatCorrection)(nil)
+
+// RealmStatCorrection is a system admin correction to a single value of a
+// realm's historical statistics (e.g. after an incident double-counted
+// claims). Both the original and corrected values are kept so the change can
+// be traced and, if necessary, reverted with another correction.
+type RealmStatCorrection struct {
+	Errorable
+
+	// ID is the correction's ID.
+	ID uint `gorm:"primary_key;"`
+
+	// RealmID is the realm whose statistics were corrected.
+	RealmID uint `gorm:"column:realm_id; type:integer; not null;"`
+
+	// Date is the UTC day of the corrected statistics row.
+	Date time.Time `gorm:"column:date; type:date; not null;"`
+
+	// Field is the corrected column, one of RealmStatCorrectionFields.
+	Field string `gorm:"column:field; type:text; not null;"`
+
+	// OriginalValue is the value before the correction. It is populated when
+	// the correction is applied.
+	OriginalValue uint `gorm:"column:original_value; type:integer; not null;"`
+
+	// CorrectedValue is the value after the correction.
+	CorrectedValue uint `gorm:"column:corrected_value; type:integer; not null;"`
+
+	// Reason is the system admin provided explanation for the correction.
+	Reason string `gorm:"column:reason; type:text; not null;"`
+
+	CreatedAt time.Time
+}
+
+// TableName sets the table name.
+func (RealmStatCorrection) TableName() string {
+	return "realm_stat_corrections"
+}
+
+// BeforeSave runs validations. If there are errors, the save fails.
+func (c *RealmStatCorrection) BeforeSave(tx *gorm.DB) error {
+	if c.RealmID == 0 {
+		c.AddError("realm_id", "is required")
+	}
+
+	if c.Date.IsZero() {
+		c.AddError("date", "is required")
+	}
+	c.Date = timeutils.UTCMidnight(c.Date)
+	if c.Date.After(time.Now()) {
+		c.AddError("date", "cannot be in the future")
+	}
+
+	if !isRealmStatCorrectionField(c.Field) {
+		c.AddError("field", "is not a correctable statistic")
+	}
+
+	c.Reason = project.TrimSpace(c.Reason)
+	if c.Reason == "" {
+		c.AddError("reason", "cannot be blank")
+	}
+	if len(c.Reason) > maxStatCorrectionReasonLength {
+		c.AddError("reason", fmt.Sprintf("must be %d characters or fewer", maxStatCorrectionReasonLength))
+	}
+
+	return c.ErrorOrNil()
+}
+
+// AuditID is how the correction is stored in the audit entry.
+func (c *RealmStatCorrection) AuditID() string {
+	return fmt.Sprintf("realm_stat_corrections:%d", c.ID)
+}
+
+// AuditDisplay is how the correction will be displayed in audit entries.
+func (c *RealmStatCorrection) AuditDisplay() string {
+	return fmt.Sprintf("%s (%s)", c.Field, c.Date.Format(project.RFC3339Date))
+}
+
+// Message is the text shown on the statistics dashboards for the corrected
+// day.
+func (c *RealmStatCorrection) Message() string {
+	return fmt.Sprintf("Corrected %s from %d to %d: %s",
+		c.Field, c.OriginalValue, c.CorrectedValue, c.Reason)
+}
+
+func isRealmStatCorrectionField(s string) bool {
+	for _, f := range RealmStatCorrectionFields {
+		if s == f {
+			return true
+		}
+	}
+	return false
+}
+
+// RealmStatCorrections is a collection of corrections.
+type RealmStatCorrections []*RealmStatCorrection
+
+// Annotations returns the corrections as stats annotations, so corrected days
+// are marked on the statistics dashboards. The returned annotations are not
+// persisted and cannot be edited by realm admins.
+func (s RealmStatCorrections) Annotations() RealmStatsAnnotations {
+	annotations := make(RealmStatsAnnotations, 0, len(s))
+	for _, c := range s {
+		annotations = append(annotations, &RealmStatsAnnotation{
+			RealmID:   c.RealmID,
+			Date:      c.Date,
+			Message:   c.Message(),
+			CreatedAt: c.CreatedAt,
+			UpdatedAt: c.CreatedAt,
+		})
+	}
+	return annotations
+}
+
+// ListStatsCorrections lists the stats corrections for the realm over the
+// stats display period, ordered by date.
+func (r *Realm) ListStatsCorrections(db *Database) (RealmStatCorrections, error) {
+	stop := r.StatsToday()
+	start := stop.Add(project.StatsDisplayDays * -24 * time.Hour)
+
+	var corrections RealmStatCorrections
+	if err := db.db.
+		Model(&RealmStatCorrection{}).
+		Where("realm_id = ?", r.ID).
+		Where("date >= ?", start).
+		Order("date ASC, id ASC").
+		Find(&corrections).
+		Error; err != nil {
+		if IsNotFound(err) {
+			return corrections, nil
+		}
+		return nil, err
+	}
+	return corrections, nil
+}
+
+// ListRealmStatCorrections lists the most recent stats corrections across all
+// realms, newest first.
+func (db *Database) ListRealmStatCorrections(limit int) (RealmStatCorrections, error) {
+	var corrections RealmStatCorrections
+	if err := db.db.
+		Model(&RealmStatCorrection{}).
+		Order("id DESC").
+		Limit(limit).
+		Find(&corrections).
+		Error; err != nil {
+		if IsNotFound(err) {
+			return corrections, nil
+		}
+		return nil, err
+	}
+	return corrections, nil
+}
+
+// ApplyRealmStatCorrection records the correction and updates the matching
+// realm_stats row in a single transaction. The current value of the field is
+// stored as the original value. It is an error to correct a day for which the
+// realm has no statistics.
+func (db *Database) ApplyRealmStatCorrection(c *RealmStatCorrection, actor Auditable) error {
+	if c == nil {
+		return fmt.Errorf("provided stats correction is nil")
+	}
+
+	if actor == nil {
+		return ErrMissingActor
+	}
+
+	// Validate before building any SQL, since the field is interpolated into the
+	// queries below.
+	if err := c.BeforeSave(db.db); err != nil {
+		return err
+	}
+
+	return db.db.Transaction(func(tx *gorm.DB) error {
+		var original struct {
+			Value uint
+		}
+		if err := tx.
+			Raw(fmt.Sprintf(`SELECT %s AS value FROM realm_stats
+				WHERE realm_id = ? AND date = ? FOR UPDATE`, c.Field), c.RealmID, c.Date).
+			Scan(&original).
+			Error; err != nil {
+			if IsNotFound(err) {
+				c.AddError("date", "has no statistics for this realm")
+				return c.ErrorOrNil()
+			}
+			return fmt.Errorf("failed to lookup realm stats: %w", err)
+		}
+		c.OriginalValue = original.Value
+
+		if err := tx.
+			Exec(fmt.Sprintf(`UPDATE realm_stats SET %s = ?
+				WHERE realm_id = ? AND date = ?`, c.Field), c.CorrectedValue, c.RealmID, c.Date).
+			Error; err != nil {
+			return fmt.Errorf("failed to update realm stats: %w", err)
+		}
+
+		if err := tx.Save(c).Error; err != nil {
+			return err
+		}
+
+		audit := BuildAuditEntry(actor, "corrected realm stats", c, c.RealmID)
+		audit.Diff = stringDiff(strconv.FormatUint(uint64(c.OriginalValue), 10),
+			strconv.FormatUint(uint64(c.CorrectedValue), 10))
+		if err := tx.Save(audit).Error; err != nil {
+			return fmt.Errorf("failed to save audit: %w", err)
+		}
+		return nil
+	})
+}
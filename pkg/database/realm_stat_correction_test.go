@@ -0,0 +1,187 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package database
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/google/exposure-notifications-server/pkg/timeutils"
+)
+
+func TestRealmStatCorrection_BeforeSave(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name       string
+		correction *RealmStatCorrection
+		errs       map[string][]string
+	}{
+		{
+			name:       "empty",
+			correction: &RealmStatCorrection{},
+			errs: map[string][]string{
+				"realm_id": {"is required"},
+				"date":     {"is required"},
+				"field":    {"is not a correctable statistic"},
+				"reason":   {"cannot be blank"},
+			},
+		},
+		{
+			name: "future",
+			correction: &RealmStatCorrection{
+				RealmID: 1,
+				Date:    time.Now().Add(48 * time.Hour),
+				Field:   "codes_claimed",
+				Reason:  "incident",
+			},
+			errs: map[string][]string{
+				"date": {"cannot be in the future"},
+			},
+		},
+		{
+			name: "unknown_field",
+			correction: &RealmStatCorrection{
+				RealmID: 1,
+				Date:    time.Now(),
+				Field:   "codes_claimed; DROP TABLE realms",
+				Reason:  "incident",
+			},
+			errs: map[string][]string{
+				"field": {"is not a correctable statistic"},
+			},
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			_ = tc.correction.BeforeSave(nil)
+			for field, want := range tc.errs {
+				if got := tc.correction.ErrorsFor(field); !reflect.DeepEqual(got, want) {
+					t.Errorf("expected %s errors %q to be %q", field, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestDatabase_ApplyRealmStatCorrection(t *testing.T) {
+	t.Parallel()
+
+	db, _ := testDatabaseInstance.NewDatabase(t, nil)
+
+	realm, err := db.FindRealm(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	date := timeutils.UTCMidnight(time.Now()).Add(-72 * time.Hour)
+	if err := db.RawDB().Create(&RealmStat{
+		Date:         date,
+		RealmID:      realm.ID,
+		CodesIssued:  100,
+		CodesClaimed: 120,
+	}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("missing_actor", func(t *testing.T) {
+		t.Parallel()
+
+		if err := db.ApplyRealmStatCorrection(&RealmStatCorrection{}, nil); err != ErrMissingActor {
+			t.Errorf("expected %v to be %v", err, ErrMissingActor)
+		}
+	})
+
+	t.Run("missing_stats", func(t *testing.T) {
+		t.Parallel()
+
+		correction := &RealmStatCorrection{
+			RealmID:        realm.ID,
+			Date:           date.Add(-24 * time.Hour),
+			Field:          "codes_claimed",
+			CorrectedValue: 10,
+			Reason:         "incident",
+		}
+		if err := db.ApplyRealmStatCorrection(correction, SystemTest); err == nil {
+			t.Fatal("expected error")
+		}
+		if got, want := correction.ErrorsFor("date"), []string{"has no statistics for this realm"}; !reflect.DeepEqual(got, want) {
+			t.Errorf("expected %q to be %q", got, want)
+		}
+	})
+
+	correction := &RealmStatCorrection{
+		RealmID:        realm.ID,
+		Date:           date,
+		Field:          "codes_claimed",
+		CorrectedValue: 60,
+		Reason:         "claims were double-counted",
+	}
+	if err := db.ApplyRealmStatCorrection(correction, SystemTest); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := correction.OriginalValue, uint(120); got != want {
+		t.Errorf("expected original value %d to be %d", got, want)
+	}
+
+	var stat RealmStat
+	if err := db.RawDB().
+		Model(&RealmStat{}).
+		Where("realm_id = ? AND date = ?", realm.ID, date).
+		First(&stat).
+		Error; err != nil {
+		t.Fatal(err)
+	}
+	if got, want := stat.CodesClaimed, uint(60); got != want {
+		t.Errorf("expected codes claimed %d to be %d", got, want)
+	}
+	if got, want := stat.CodesIssued, uint(100); got != want {
+		t.Errorf("expected codes issued %d to be %d", got, want)
+	}
+
+	corrections, err := realm.ListStatsCorrections(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(corrections), 1; got != want {
+		t.Fatalf("expected %d corrections to be %d", got, want)
+	}
+
+	annotations := corrections.Annotations()
+	if got, want := annotations.ByDate()[date.Format("2006-01-02")], []string{
+		"Corrected codes_claimed from 120 to 60: claims were double-counted",
+	}; !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %q to be %q", got, want)
+	}
+
+	var audits []*AuditEntry
+	if err := db.RawDB().
+		Model(&AuditEntry{}).
+		Where("action = ?", "corrected realm stats").
+		Find(&audits).
+		Error; err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(audits), 1; got != want {
+		t.Errorf("expected %d audits to be %d", got, want)
+	}
+}
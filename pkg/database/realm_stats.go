@@ -77,12 +77,24 @@ type RealmStat struct {
 	// initiated report. This sum is also included in tokens claimed.
 	UserReportTokensClaimed uint `gorm:"column:user_report_tokens_claimed; type:integer; not null; default:0;"`
 
+	// CodeCollisions is the number of times a generated code collided with an
+	// existing code and had to be regenerated.
+	CodeCollisions uint `gorm:"column:code_collisions; type:integer; not null; default:0;"`
+
+	// SMSSuppressed is the number of codes that were not sent because the phone
+	// number is on the realm's SMS suppression list.
+	SMSSuppressed uint `gorm:"column:sms_suppressed; type:integer; not null; default:0;"`
+
 	// CodeClaimAgeDistribution shows a distribution of time from code issue to claim.
 	// Buckets are: 1m, 5m, 15m, 30m, 1h, 2h, 3h, 6h, 12h, 24h, >24h
 	CodeClaimAgeDistribution pq.Int32Array `gorm:"column:code_claim_age_distribution; type:int[];"`
 
 	// CodeClaimMeanAge tracks the average age to claim a code.
 	CodeClaimMeanAge DurationSeconds `gorm:"column:code_claim_mean_age; type:bigint; not null; default: 0;"`
+
+	// Annotations are the realm admin provided notes for this date. They are not
+	// stored with the stats; use RealmStats.WithAnnotations to populate them.
+	Annotations []string `gorm:"-"`
 }
 
 func (s *RealmStat) IsEmpty() bool {
@@ -154,6 +166,21 @@ func (s *RealmStat) CodeClaimAgeDistributionAsStrings() []string {
 	return str
 }
 
+// WithAnnotations returns a copy of the stats with the matching annotations
+// attached to each day. The receiver is not modified, since it may be shared
+// via the cache.
+func (s RealmStats) WithAnnotations(annotations RealmStatsAnnotations) RealmStats {
+	byDate := annotations.ByDate()
+
+	result := make(RealmStats, 0, len(s))
+	for _, stat := range s {
+		copied := *stat
+		copied.Annotations = byDate[stat.Date.Format(project.RFC3339Date)]
+		result = append(result, &copied)
+	}
+	return result
+}
+
 // MarshalCSV returns bytes in CSV format.
 func (s RealmStats) MarshalCSV() ([]byte, error) {
 	// Do nothing if there's no records
@@ -171,6 +198,8 @@ func (s RealmStats) MarshalCSV() ([]byte, error) {
 		"user_reports_issued", "user_reports_claimed", "user_report_tokens_claimed",
 		"codes_invalid_unknown_os", "codes_invalid_ios", "codes_invalid_android",
 		"user_reports_invalid_nonce", "user_report_invalid_nonce_unknown_os", "user_report_invalid_nonce_ios", "user_report_invalid_nonce_android",
+		"sms_suppressed",
+		"annotations",
 	}); err != nil {
 		return nil, fmt.Errorf("failed to write CSV header: %w", err)
 	}
@@ -195,6 +224,8 @@ func (s RealmStats) MarshalCSV() ([]byte, error) {
 			strconv.FormatUint(uint64(stat.UserReportsInvalidNonceByOS[OSTypeUnknown]), 10),
 			strconv.FormatUint(uint64(stat.UserReportsInvalidNonceByOS[OSTypeIOS]), 10),
 			strconv.FormatUint(uint64(stat.UserReportsInvalidNonceByOS[OSTypeAndroid]), 10),
+			strconv.FormatUint(uint64(stat.SMSSuppressed), 10),
+			strings.Join(stat.Annotations, "|"),
 		}); err != nil {
 			return nil, fmt.Errorf("failed to write CSV entry %d: %w", i, err)
 		}
@@ -225,8 +256,9 @@ type jsonRealmStat struct {
 }
 
 type jsonRealmStatStats struct {
-	Date time.Time               `json:"date"`
-	Data *JSONRealmStatStatsData `json:"data"`
+	Date        time.Time               `json:"date"`
+	Data        *JSONRealmStatStatsData `json:"data"`
+	Annotations []string                `json:"annotations,omitempty"`
 }
 
 type CodesInvalidByOSData struct {
@@ -249,6 +281,7 @@ type JSONRealmStatStatsData struct {
 	UserReportTokensClaimed     uint                 `json:"user_report_tokens_claimed"`
 	CodeClaimMeanAge            uint                 `json:"code_claim_mean_age_seconds"`
 	CodeClaimDistribution       []int32              `json:"code_claim_age_distribution"`
+	SMSSuppressed               uint                 `json:"sms_suppressed"`
 }
 
 // MarshalJSON is a custom JSON marshaller.
@@ -284,7 +317,9 @@ func (s RealmStats) MarshalJSON() ([]byte, error) {
 				UserReportTokensClaimed: stat.UserReportTokensClaimed,
 				CodeClaimMeanAge:        uint(stat.CodeClaimMeanAge.Duration.Seconds()),
 				CodeClaimDistribution:   stat.CodeClaimAgeDistribution,
+				SMSSuppressed:           stat.SMSSuppressed,
 			},
+			Annotations: stat.Annotations,
 		})
 	}
 
@@ -339,6 +374,8 @@ func (s *RealmStats) UnmarshalJSON(b []byte) error {
 			UserReportTokensClaimed:  stat.Data.UserReportTokensClaimed,
 			CodeClaimMeanAge:         FromDuration(time.Duration(stat.Data.CodeClaimMeanAge) * time.Second),
 			CodeClaimAgeDistribution: stat.Data.CodeClaimDistribution,
+			SMSSuppressed:            stat.Data.SMSSuppressed,
+			Annotations:              stat.Annotations,
 		})
 	}
 
@@ -346,12 +383,14 @@ func (s *RealmStats) UnmarshalJSON(b []byte) error {
 }
 
 // HistoricalCodesIssued returns a slice of the historical codes issued for
-// this realm by date descending.
+// this realm by date descending. The current day in the realm's stats timezone
+// is incomplete and is excluded.
 func (r *Realm) HistoricalCodesIssued(db *Database, limit uint64) ([]uint64, error) {
 	var stats []uint64
 	if err := db.db.
 		Model(&RealmStats{}).
 		Where("realm_id = ?", r.ID).
+		Where("date < ?", r.StatsToday()).
 		Order("date DESC").
 		Limit(limit).
 		Pluck("codes_issued", &stats).
@@ -362,16 +401,8 @@ func (r *Realm) HistoricalCodesIssued(db *Database, limit uint64) ([]uint64, err
 }
 
 // PurgeRealmStats will delete stats that were created longer than
-// maxAge ago.
+// maxAge ago. Stats for realms with a pending export are retained.
 func (db *Database) PurgeRealmStats(maxAge time.Duration) (int64, error) {
-	if maxAge > 0 {
-		maxAge = -1 * maxAge
-	}
-	createdBefore := time.Now().UTC().Add(maxAge)
-
-	result := db.db.
-		Unscoped().
-		Where("date < ?", createdBefore).
-		Delete(&RealmStat{})
-	return result.RowsAffected, result.Error
+	return db.purgeWithRealmRetention(&RealmStat{}, "realm_stats.date", "realm_stats.realm_id",
+		"stats_retention_days", maxAge, withoutPendingRealmExports)
 }
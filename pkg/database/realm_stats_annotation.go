@@ -0,0 +1,201 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package database
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/google/exposure-notifications-server/pkg/timeutils"
+	"github.com/google/exposure-notifications-verification-server/internal/project"
+	"github.com/jinzhu/gorm"
+)
+
+// maxStatsAnnotationLength is the maximum length of an annotation message.
+const maxStatsAnnotationLength = 255
+
+var _ Auditable = (*RealmStatsAnnotation)(nil)
+
+// RealmStatsAnnotation is a dated note on a realm's statistics, used to give
+// context to dips and spikes (e.g. "new app version released").
+type RealmStatsAnnotation struct {
+	Errorable
+
+	// ID is the annotation's ID.
+	ID uint `gorm:"primary_key;"`
+
+	// RealmID is the realm to which the annotation belongs.
+	RealmID uint `gorm:"column:realm_id; type:integer; not null;"`
+
+	// Date is the day, in the realm's stats timezone, to which the annotation
+	// applies.
+	Date time.Time `gorm:"column:date; type:date; not null;"`
+
+	// Message is the annotation text.
+	Message string `gorm:"column:message; type:text; not null;"`
+
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// TableName sets the table name.
+func (RealmStatsAnnotation) TableName() string {
+	return "realm_stats_annotations"
+}
+
+// BeforeSave runs validations. If there are errors, the save fails.
+func (a *RealmStatsAnnotation) BeforeSave(tx *gorm.DB) error {
+	if a.RealmID == 0 {
+		a.AddError("realm_id", "is required")
+	}
+
+	if a.Date.IsZero() {
+		a.AddError("date", "is required")
+	}
+	a.Date = timeutils.UTCMidnight(a.Date)
+
+	a.Message = project.TrimSpace(a.Message)
+	if a.Message == "" {
+		a.AddError("message", "cannot be blank")
+	}
+	if len(a.Message) > maxStatsAnnotationLength {
+		a.AddError("message", fmt.Sprintf("must be %d characters or fewer", maxStatsAnnotationLength))
+	}
+
+	return a.ErrorOrNil()
+}
+
+// AuditID is how the annotation is stored in the audit entry.
+func (a *RealmStatsAnnotation) AuditID() string {
+	return fmt.Sprintf("realm_stats_annotations:%d", a.ID)
+}
+
+// AuditDisplay is how the annotation will be displayed in audit entries.
+func (a *RealmStatsAnnotation) AuditDisplay() string {
+	return fmt.Sprintf("%s (%s)", a.Message, a.Date.Format(project.RFC3339Date))
+}
+
+// RealmStatsAnnotations is a collection of annotations.
+type RealmStatsAnnotations []*RealmStatsAnnotation
+
+// ByDate returns the annotation messages keyed by their date, formatted as
+// RFC3339Date.
+func (s RealmStatsAnnotations) ByDate() map[string][]string {
+	m := make(map[string][]string, len(s))
+	for _, a := range s {
+		k := a.Date.Format(project.RFC3339Date)
+		m[k] = append(m[k], a.Message)
+	}
+	return m
+}
+
+// ListStatsAnnotations lists the stats annotations for the realm over the
+// stats display period, ordered by date.
+func (r *Realm) ListStatsAnnotations(db *Database) (RealmStatsAnnotations, error) {
+	stop := r.StatsToday()
+	start := stop.Add(project.StatsDisplayDays * -24 * time.Hour)
+
+	var annotations RealmStatsAnnotations
+	if err := db.db.
+		Model(&RealmStatsAnnotation{}).
+		Where("realm_id = ?", r.ID).
+		Where("date >= ?", start).
+		Order("date ASC, id ASC").
+		Find(&annotations).
+		Error; err != nil {
+		if IsNotFound(err) {
+			return annotations, nil
+		}
+		return nil, err
+	}
+	return annotations, nil
+}
+
+// FindStatsAnnotation finds the stats annotation with the given ID in the
+// realm.
+func (r *Realm) FindStatsAnnotation(db *Database, id interface{}) (*RealmStatsAnnotation, error) {
+	var annotation RealmStatsAnnotation
+	if err := db.db.
+		Model(&RealmStatsAnnotation{}).
+		Where("id = ?", id).
+		Where("realm_id = ?", r.ID).
+		First(&annotation).
+		Error; err != nil {
+		return nil, err
+	}
+	return &annotation, nil
+}
+
+// SaveRealmStatsAnnotation creates or updates the stats annotation.
+func (db *Database) SaveRealmStatsAnnotation(a *RealmStatsAnnotation, actor Auditable) error {
+	if a == nil {
+		return fmt.Errorf("provided stats annotation is nil")
+	}
+
+	if actor == nil {
+		return ErrMissingActor
+	}
+
+	return db.db.Transaction(func(tx *gorm.DB) error {
+		var existing RealmStatsAnnotation
+		if err := tx.
+			Model(&RealmStatsAnnotation{}).
+			Where("id = ?", a.ID).
+			First(&existing).
+			Error; err != nil && !IsNotFound(err) {
+			return fmt.Errorf("failed to get existing stats annotation: %w", err)
+		}
+
+		if err := tx.Save(a).Error; err != nil {
+			return err
+		}
+
+		var audit *AuditEntry
+		if existing.ID == 0 {
+			audit = BuildAuditEntry(actor, "created stats annotation", a, a.RealmID)
+		} else {
+			audit = BuildAuditEntry(actor, "updated stats annotation", a, a.RealmID)
+			audit.Diff = stringDiff(existing.AuditDisplay(), a.AuditDisplay())
+		}
+
+		if err := tx.Save(audit).Error; err != nil {
+			return fmt.Errorf("failed to save audit: %w", err)
+		}
+		return nil
+	})
+}
+
+// DeleteRealmStatsAnnotation deletes the stats annotation.
+func (db *Database) DeleteRealmStatsAnnotation(a *RealmStatsAnnotation, actor Auditable) error {
+	if a == nil {
+		return fmt.Errorf("provided stats annotation is nil")
+	}
+
+	if actor == nil {
+		return ErrMissingActor
+	}
+
+	return db.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(a).Error; err != nil {
+			return err
+		}
+
+		audit := BuildAuditEntry(actor, "deleted stats annotation", a, a.RealmID)
+		if err := tx.Save(audit).Error; err != nil {
+			return fmt.Errorf("failed to save audit: %w", err)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,137 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package database
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/exposure-notifications-server/pkg/timeutils"
+)
+
+func TestRealmStatsAnnotation_BeforeSave(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name       string
+		annotation *RealmStatsAnnotation
+		errs       []string
+	}{
+		{
+			name:       "empty",
+			annotation: &RealmStatsAnnotation{},
+			errs:       []string{"realm_id", "date", "message"},
+		},
+		{
+			name: "too_long",
+			annotation: &RealmStatsAnnotation{
+				RealmID: 1,
+				Date:    time.Now(),
+				Message: strings.Repeat("a", maxStatsAnnotationLength+1),
+			},
+			errs: []string{"message"},
+		},
+		{
+			name: "valid",
+			annotation: &RealmStatsAnnotation{
+				RealmID: 1,
+				Date:    time.Now(),
+				Message: "  new app version  ",
+			},
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			_ = tc.annotation.BeforeSave(nil)
+			for _, field := range tc.errs {
+				if errs := tc.annotation.ErrorsFor(field); len(errs) < 1 {
+					t.Errorf("expected errors for %s", field)
+				}
+			}
+			if len(tc.errs) == 0 {
+				if errs := tc.annotation.ErrorMessages(); len(errs) > 0 {
+					t.Errorf("expected no errors, got %v", errs)
+				}
+				if got, want := tc.annotation.Message, "new app version"; got != want {
+					t.Errorf("expected %q to be %q", got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestRealm_StatsAnnotations(t *testing.T) {
+	t.Parallel()
+
+	db, _ := testDatabaseInstance.NewDatabase(t, nil)
+
+	realm, err := db.FindRealm(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	today := timeutils.UTCMidnight(time.Now())
+	old := &RealmStatsAnnotation{
+		RealmID: realm.ID,
+		Date:    today.Add(-365 * 24 * time.Hour),
+		Message: "too old",
+	}
+	if err := db.SaveRealmStatsAnnotation(old, SystemTest); err != nil {
+		t.Fatal(err)
+	}
+
+	annotation := &RealmStatsAnnotation{
+		RealmID: realm.ID,
+		Date:    today,
+		Message: "lab outage",
+	}
+	if err := db.SaveRealmStatsAnnotation(annotation, SystemTest); err != nil {
+		t.Fatal(err)
+	}
+
+	annotations, err := realm.ListStatsAnnotations(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(annotations), 1; got != want {
+		t.Fatalf("expected %d annotations, got %d", want, got)
+	}
+	if got, want := annotations[0].Message, "lab outage"; got != want {
+		t.Errorf("expected %q to be %q", got, want)
+	}
+
+	byDate := annotations.ByDate()
+	if got, want := byDate[today.Format("2006-01-02")], []string{"lab outage"}; len(got) != 1 || got[0] != want[0] {
+		t.Errorf("expected %v to be %v", got, want)
+	}
+
+	found, err := realm.FindStatsAnnotation(db, annotation.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.DeleteRealmStatsAnnotation(found, SystemTest); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := realm.FindStatsAnnotation(db, annotation.ID); !IsNotFound(err) {
+		t.Errorf("expected not found, got %v", err)
+	}
+}
@@ -0,0 +1,154 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package database
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"math"
+
+	"github.com/google/exposure-notifications-verification-server/internal/project"
+)
+
+const (
+	// StatsPrivacyModeOff exports statistics unaltered.
+	StatsPrivacyModeOff = "off"
+
+	// StatsPrivacyModeSuppress replaces non-zero counts below the realm's
+	// threshold with zero.
+	StatsPrivacyModeSuppress = "suppress"
+
+	// StatsPrivacyModeNoise adds Laplace noise, scaled by the realm's epsilon,
+	// to non-zero counts below the realm's threshold.
+	StatsPrivacyModeNoise = "noise"
+)
+
+// StatsPrivacyModes is the list of valid stats privacy modes.
+var StatsPrivacyModes = []string{
+	StatsPrivacyModeOff,
+	StatsPrivacyModeSuppress,
+	StatsPrivacyModeNoise,
+}
+
+// IsValidStatsPrivacyMode returns true if the given mode is supported.
+func IsValidStatsPrivacyMode(mode string) bool {
+	for _, m := range StatsPrivacyModes {
+		if m == mode {
+			return true
+		}
+	}
+	return false
+}
+
+// WithPrivacy returns a copy of the stats with the realm's stats privacy mode
+// applied to small counts. If the mode is off, the stats are returned as-is.
+// The receiver is not modified, since it may be shared via the cache.
+//
+// Noise is deterministic for a given realm, day, and field so that repeatedly
+// downloading the same export does not allow the noise to be averaged away.
+func (s RealmStats) WithPrivacy(r *Realm) RealmStats {
+	if r == nil || r.StatsPrivacyMode == "" || r.StatsPrivacyMode == StatsPrivacyModeOff {
+		return s
+	}
+
+	result := make(RealmStats, 0, len(s))
+	for _, stat := range s {
+		copied := *stat
+		p := &statsPrivatizer{realm: r, date: stat.Date.Format(project.RFC3339Date)}
+
+		copied.CodesIssued = p.count("codes_issued", stat.CodesIssued)
+		copied.CodesClaimed = p.count("codes_claimed", stat.CodesClaimed)
+		copied.CodesInvalid = p.count("codes_invalid", stat.CodesInvalid)
+		copied.UserReportsIssued = p.count("user_reports_issued", stat.UserReportsIssued)
+		copied.UserReportsClaimed = p.count("user_reports_claimed", stat.UserReportsClaimed)
+		copied.UserReportsInvalidNonce = p.count("user_reports_invalid_nonce", stat.UserReportsInvalidNonce)
+		copied.TokensClaimed = p.count("tokens_claimed", stat.TokensClaimed)
+		copied.TokensInvalid = p.count("tokens_invalid", stat.TokensInvalid)
+		copied.UserReportTokensClaimed = p.count("user_report_tokens_claimed", stat.UserReportTokensClaimed)
+		copied.SMSSuppressed = p.count("sms_suppressed", stat.SMSSuppressed)
+
+		copied.CodesInvalidByOS = make([]int64, len(stat.CodesInvalidByOS))
+		for i, v := range stat.CodesInvalidByOS {
+			copied.CodesInvalidByOS[i] = p.value(fmt.Sprintf("codes_invalid_by_os:%d", i), v)
+		}
+
+		copied.UserReportsInvalidNonceByOS = make([]int64, len(stat.UserReportsInvalidNonceByOS))
+		for i, v := range stat.UserReportsInvalidNonceByOS {
+			copied.UserReportsInvalidNonceByOS[i] = p.value(fmt.Sprintf("user_reports_invalid_nonce_by_os:%d", i), v)
+		}
+
+		copied.CodeClaimAgeDistribution = make([]int32, len(stat.CodeClaimAgeDistribution))
+		for i, v := range stat.CodeClaimAgeDistribution {
+			copied.CodeClaimAgeDistribution[i] = int32(p.value(fmt.Sprintf("code_claim_age_distribution:%d", i), int64(v)))
+		}
+
+		result = append(result, &copied)
+	}
+	return result
+}
+
+// statsPrivatizer applies a realm's stats privacy settings to the values of a
+// single day.
+type statsPrivatizer struct {
+	realm *Realm
+	date  string
+}
+
+func (p *statsPrivatizer) count(field string, v uint) uint {
+	return uint(p.value(field, int64(v)))
+}
+
+// value returns the privatized value for the given field. Zero and values at
+// or above the threshold are returned unchanged.
+func (p *statsPrivatizer) value(field string, v int64) int64 {
+	if v <= 0 || v >= int64(p.realm.StatsPrivacyThreshold) {
+		return v
+	}
+
+	switch p.realm.StatsPrivacyMode {
+	case StatsPrivacyModeSuppress:
+		return 0
+	case StatsPrivacyModeNoise:
+		noised := math.Round(float64(v) + p.laplace(field))
+		if noised < 0 {
+			return 0
+		}
+		return int64(noised)
+	default:
+		return v
+	}
+}
+
+// laplace returns a sample from the Laplace distribution with scale
+// 1/epsilon. The sample is derived from an HMAC of the realm's salt and the
+// field being noised, so it is stable across requests.
+func (p *statsPrivatizer) laplace(field string) float64 {
+	mac := hmac.New(sha256.New, []byte(p.realm.StatsPrivacySalt))
+	fmt.Fprintf(mac, "%d:%s:%s", p.realm.ID, p.date, field)
+	sum := mac.Sum(nil)
+
+	// Map the first 53 bits to a uniform value in (-0.5, 0.5), avoiding the
+	// endpoints so the logarithm below is always finite.
+	bits := binary.BigEndian.Uint64(sum[:8]) >> 11
+	u := (float64(bits)+0.5)/float64(uint64(1)<<53) - 0.5
+
+	scale := 1 / p.realm.StatsPrivacyEpsilon
+	if u < 0 {
+		return scale * math.Log(1+2*u)
+	}
+	return -scale * math.Log(1-2*u)
+}
@@ -0,0 +1,112 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package database
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/jinzhu/gorm"
+)
+
+func TestRealmStats_WithPrivacy(t *testing.T) {
+	t.Parallel()
+
+	date := time.Date(2020, 2, 3, 0, 0, 0, 0, time.UTC)
+	newStats := func() RealmStats {
+		return RealmStats{
+			{
+				Date:                        date,
+				RealmID:                     1,
+				CodesIssued:                 25,
+				CodesClaimed:                9,
+				CodesInvalid:                0,
+				CodesInvalidByOS:            []int64{0, 3, 12},
+				UserReportsIssued:           4,
+				UserReportsInvalidNonceByOS: []int64{0, 0, 0},
+				TokensClaimed:               10,
+				CodeClaimAgeDistribution:    []int32{1, 30, 0},
+			},
+		}
+	}
+
+	t.Run("off", func(t *testing.T) {
+		t.Parallel()
+
+		stats := newStats()
+		got := stats.WithPrivacy(&Realm{StatsPrivacyMode: StatsPrivacyModeOff, StatsPrivacyThreshold: 10})
+		if diff := cmp.Diff(newStats(), got); diff != "" {
+			t.Errorf("mismatch (-want, +got):\n%s", diff)
+		}
+	})
+
+	t.Run("suppress", func(t *testing.T) {
+		t.Parallel()
+
+		stats := newStats()
+		got := stats.WithPrivacy(&Realm{StatsPrivacyMode: StatsPrivacyModeSuppress, StatsPrivacyThreshold: 10})
+
+		want := newStats()
+		want[0].CodesClaimed = 0
+		want[0].CodesInvalidByOS = []int64{0, 0, 12}
+		want[0].UserReportsIssued = 0
+		want[0].CodeClaimAgeDistribution = []int32{0, 30, 0}
+		if diff := cmp.Diff(want, got); diff != "" {
+			t.Errorf("mismatch (-want, +got):\n%s", diff)
+		}
+
+		// Original is not modified.
+		if diff := cmp.Diff(newStats(), stats); diff != "" {
+			t.Errorf("receiver modified (-want, +got):\n%s", diff)
+		}
+	})
+
+	t.Run("noise", func(t *testing.T) {
+		t.Parallel()
+
+		realm := &Realm{
+			Model:                 gorm.Model{ID: 1},
+			StatsPrivacyMode:      StatsPrivacyModeNoise,
+			StatsPrivacyThreshold: 10,
+			StatsPrivacyEpsilon:   0.5,
+			StatsPrivacySalt:      "abc123",
+		}
+
+		stats := newStats()
+		got := stats.WithPrivacy(realm)
+
+		// Values at or above the threshold, and zero values, are unchanged.
+		if got, want := got[0].CodesIssued, uint(25); got != want {
+			t.Errorf("expected codes issued %d to be %d", got, want)
+		}
+		if got, want := got[0].TokensClaimed, uint(10); got != want {
+			t.Errorf("expected tokens claimed %d to be %d", got, want)
+		}
+		if got, want := got[0].CodesInvalid, uint(0); got != want {
+			t.Errorf("expected codes invalid %d to be %d", got, want)
+		}
+		for i, v := range got[0].CodesInvalidByOS {
+			if v < 0 {
+				t.Errorf("expected codes invalid by os[%d] to be non-negative, got %d", i, v)
+			}
+		}
+
+		// Noise is deterministic.
+		if diff := cmp.Diff(got, stats.WithPrivacy(realm)); diff != "" {
+			t.Errorf("expected stable noise (-want, +got):\n%s", diff)
+		}
+	})
+}
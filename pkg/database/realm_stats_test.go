@@ -54,10 +54,10 @@ func TestRealmStats_MarshalCSV(t *testing.T) {
 					UserReportsInvalidNonceByOS: []int64{0, 0, 0},
 				},
 			},
-			expCSV: `date,codes_issued,codes_claimed,codes_invalid,tokens_claimed,tokens_invalid,code_claim_mean_age_seconds,code_claim_age_distribution,user_reports_issued,user_reports_claimed,user_report_tokens_claimed,codes_invalid_unknown_os,codes_invalid_ios,codes_invalid_android,user_reports_invalid_nonce,user_report_invalid_nonce_unknown_os,user_report_invalid_nonce_ios,user_report_invalid_nonce_android
-2020-02-03,10,9,1,7,2,60,1|3|4,0,0,0,0,0,0,0,0,0,0
+			expCSV: `date,codes_issued,codes_claimed,codes_invalid,tokens_claimed,tokens_invalid,code_claim_mean_age_seconds,code_claim_age_distribution,user_reports_issued,user_reports_claimed,user_report_tokens_claimed,codes_invalid_unknown_os,codes_invalid_ios,codes_invalid_android,user_reports_invalid_nonce,user_report_invalid_nonce_unknown_os,user_report_invalid_nonce_ios,user_report_invalid_nonce_android,sms_suppressed,annotations
+2020-02-03,10,9,1,7,2,60,1|3|4,0,0,0,0,0,0,0,0,0,0,0,
 `,
-			expJSON: `{"realm_id":1,"statistics":[{"date":"2020-02-03T00:00:00Z","data":{"codes_issued":10,"codes_claimed":9,"codes_invalid":1,"codes_invalid_by_os":{"unknown_os":0,"ios":0,"android":0},"user_reports_issued":0,"user_reports_claimed":0,"user_reports_invalid_nonce":0,"user_reports_invalid_nonce_by_os":{"unknown_os":0,"ios":0,"android":0},"tokens_claimed":7,"tokens_invalid":2,"user_report_tokens_claimed":0,"code_claim_mean_age_seconds":60,"code_claim_age_distribution":[1,3,4]}}]}`,
+			expJSON: `{"realm_id":1,"statistics":[{"date":"2020-02-03T00:00:00Z","data":{"codes_issued":10,"codes_claimed":9,"codes_invalid":1,"codes_invalid_by_os":{"unknown_os":0,"ios":0,"android":0},"user_reports_issued":0,"user_reports_claimed":0,"user_reports_invalid_nonce":0,"user_reports_invalid_nonce_by_os":{"unknown_os":0,"ios":0,"android":0},"tokens_claimed":7,"tokens_invalid":2,"user_report_tokens_claimed":0,"code_claim_mean_age_seconds":60,"code_claim_age_distribution":[1,3,4],"sms_suppressed":0}}]}`,
 		},
 		{
 			name: "multi",
@@ -87,6 +87,7 @@ func TestRealmStats_MarshalCSV(t *testing.T) {
 					CodeClaimMeanAge:            FromDuration(time.Hour),
 					CodeClaimAgeDistribution:    []int32{4, 5, 6},
 					UserReportsInvalidNonceByOS: []int64{0, 0, 0},
+					Annotations:                 []string{"lab outage", "new app version"},
 				},
 				{
 					Date:                        time.Date(2020, 2, 5, 0, 0, 0, 0, time.UTC),
@@ -104,14 +105,15 @@ func TestRealmStats_MarshalCSV(t *testing.T) {
 					UserReportTokensClaimed:     1,
 					CodeClaimMeanAge:            FromDuration(time.Millisecond),
 					CodeClaimAgeDistribution:    []int32{7, 8, 9},
+					SMSSuppressed:               3,
 				},
 			},
-			expCSV: `date,codes_issued,codes_claimed,codes_invalid,tokens_claimed,tokens_invalid,code_claim_mean_age_seconds,code_claim_age_distribution,user_reports_issued,user_reports_claimed,user_report_tokens_claimed,codes_invalid_unknown_os,codes_invalid_ios,codes_invalid_android,user_reports_invalid_nonce,user_report_invalid_nonce_unknown_os,user_report_invalid_nonce_ios,user_report_invalid_nonce_android
-2020-02-03,10,9,1,7,2,60,1|2|3,0,0,0,1,2,3,0,0,0,0
-2020-02-04,45,30,29,27,2,3600,4|5|6,0,0,0,0,20,9,0,0,0,0
-2020-02-05,15,2,0,2,0,0,7|8|9,2,1,1,0,0,0,32,0,16,16
+			expCSV: `date,codes_issued,codes_claimed,codes_invalid,tokens_claimed,tokens_invalid,code_claim_mean_age_seconds,code_claim_age_distribution,user_reports_issued,user_reports_claimed,user_report_tokens_claimed,codes_invalid_unknown_os,codes_invalid_ios,codes_invalid_android,user_reports_invalid_nonce,user_report_invalid_nonce_unknown_os,user_report_invalid_nonce_ios,user_report_invalid_nonce_android,sms_suppressed,annotations
+2020-02-03,10,9,1,7,2,60,1|2|3,0,0,0,1,2,3,0,0,0,0,0,
+2020-02-04,45,30,29,27,2,3600,4|5|6,0,0,0,0,20,9,0,0,0,0,0,lab outage|new app version
+2020-02-05,15,2,0,2,0,0,7|8|9,2,1,1,0,0,0,32,0,16,16,3,
 `,
-			expJSON: `{"realm_id":1,"statistics":[{"date":"2020-02-05T00:00:00Z","data":{"codes_issued":15,"codes_claimed":2,"codes_invalid":0,"codes_invalid_by_os":{"unknown_os":0,"ios":0,"android":0},"user_reports_issued":2,"user_reports_claimed":1,"user_reports_invalid_nonce":32,"user_reports_invalid_nonce_by_os":{"unknown_os":0,"ios":16,"android":16},"tokens_claimed":2,"tokens_invalid":0,"user_report_tokens_claimed":1,"code_claim_mean_age_seconds":0,"code_claim_age_distribution":[7,8,9]}},{"date":"2020-02-04T00:00:00Z","data":{"codes_issued":45,"codes_claimed":30,"codes_invalid":29,"codes_invalid_by_os":{"unknown_os":0,"ios":20,"android":9},"user_reports_issued":0,"user_reports_claimed":0,"user_reports_invalid_nonce":0,"user_reports_invalid_nonce_by_os":{"unknown_os":0,"ios":0,"android":0},"tokens_claimed":27,"tokens_invalid":2,"user_report_tokens_claimed":0,"code_claim_mean_age_seconds":3600,"code_claim_age_distribution":[4,5,6]}},{"date":"2020-02-03T00:00:00Z","data":{"codes_issued":10,"codes_claimed":9,"codes_invalid":1,"codes_invalid_by_os":{"unknown_os":1,"ios":2,"android":3},"user_reports_issued":0,"user_reports_claimed":0,"user_reports_invalid_nonce":0,"user_reports_invalid_nonce_by_os":{"unknown_os":0,"ios":0,"android":0},"tokens_claimed":7,"tokens_invalid":2,"user_report_tokens_claimed":0,"code_claim_mean_age_seconds":60,"code_claim_age_distribution":[1,2,3]}}]}`,
+			expJSON: `{"realm_id":1,"statistics":[{"date":"2020-02-05T00:00:00Z","data":{"codes_issued":15,"codes_claimed":2,"codes_invalid":0,"codes_invalid_by_os":{"unknown_os":0,"ios":0,"android":0},"user_reports_issued":2,"user_reports_claimed":1,"user_reports_invalid_nonce":32,"user_reports_invalid_nonce_by_os":{"unknown_os":0,"ios":16,"android":16},"tokens_claimed":2,"tokens_invalid":0,"user_report_tokens_claimed":1,"code_claim_mean_age_seconds":0,"code_claim_age_distribution":[7,8,9],"sms_suppressed":3}},{"date":"2020-02-04T00:00:00Z","data":{"codes_issued":45,"codes_claimed":30,"codes_invalid":29,"codes_invalid_by_os":{"unknown_os":0,"ios":20,"android":9},"user_reports_issued":0,"user_reports_claimed":0,"user_reports_invalid_nonce":0,"user_reports_invalid_nonce_by_os":{"unknown_os":0,"ios":0,"android":0},"tokens_claimed":27,"tokens_invalid":2,"user_report_tokens_claimed":0,"code_claim_mean_age_seconds":3600,"code_claim_age_distribution":[4,5,6],"sms_suppressed":0},"annotations":["lab outage","new app version"]},{"date":"2020-02-03T00:00:00Z","data":{"codes_issued":10,"codes_claimed":9,"codes_invalid":1,"codes_invalid_by_os":{"unknown_os":1,"ios":2,"android":3},"user_reports_issued":0,"user_reports_claimed":0,"user_reports_invalid_nonce":0,"user_reports_invalid_nonce_by_os":{"unknown_os":0,"ios":0,"android":0},"tokens_claimed":7,"tokens_invalid":2,"user_report_tokens_claimed":0,"code_claim_mean_age_seconds":60,"code_claim_age_distribution":[1,2,3],"sms_suppressed":0}}]}`,
 		},
 	}
 
@@ -167,3 +169,60 @@ func TestDatabase_PurgeRealmStats(t *testing.T) {
 		t.Errorf("expected %d entries, got %d: %#v", want, got, entries)
 	}
 }
+
+func TestDatabase_PurgeRealmStats_realmRetention(t *testing.T) {
+	t.Parallel()
+
+	db, _ := testDatabaseInstance.NewDatabase(t, nil)
+
+	realm := NewRealmWithDefaults("retention")
+	realm.StatsRetentionDays = 60
+	if err := db.SaveRealm(realm, SystemTest); err != nil {
+		t.Fatal(err)
+	}
+
+	// 45 days ago: purged under the system default of 30 days, but kept by the
+	// realm's 60 day retention.
+	date := timeutils.UTCMidnight(time.Now().UTC()).Add(-45 * 24 * time.Hour)
+	for _, realmID := range []uint{1, realm.ID} {
+		if err := db.RawDB().Create(&RealmStat{
+			Date:    date,
+			RealmID: realmID,
+		}).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	n, err := db.PurgeRealmStats(30 * 24 * time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := n, int64(1); got != want {
+		t.Errorf("expected %d to purge, got %d", want, got)
+	}
+
+	var entries []*RealmStat
+	if err := db.RawDB().Model(&RealmStat{}).Find(&entries).Error; err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(entries), 1; got != want {
+		t.Fatalf("expected %d entries, got %d: %#v", want, got, entries)
+	}
+	if got, want := entries[0].RealmID, realm.ID; got != want {
+		t.Errorf("expected %d to be %d", got, want)
+	}
+
+	// Shortening the realm's retention purges the entry.
+	realm.StatsRetentionDays = MinStatsRetentionDays
+	if err := db.SaveRealm(realm, SystemTest); err != nil {
+		t.Fatal(err)
+	}
+
+	n, err = db.PurgeRealmStats(30 * 24 * time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := n, int64(1); got != want {
+		t.Errorf("expected %d to purge, got %d", want, got)
+	}
+}
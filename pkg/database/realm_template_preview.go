@@ -0,0 +1,200 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package database
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf16"
+)
+
+const (
+	// TemplatePreviewSMS previews an SMS template.
+	TemplatePreviewSMS = "sms"
+
+	// TemplatePreviewEmailInvite previews the invitation email template.
+	TemplatePreviewEmailInvite = "email-invite"
+
+	// TemplatePreviewEmailPasswordReset previews the password reset email
+	// template.
+	TemplatePreviewEmailPasswordReset = "email-password-reset"
+
+	// TemplatePreviewEmailVerify previews the email verification template.
+	TemplatePreviewEmailVerify = "email-verify"
+
+	// TemplatePreviewMaxLength is the longest draft template that will be
+	// rendered.
+	TemplatePreviewMaxLength = 16 * 1024
+)
+
+// ErrTemplatePreviewKind is returned when previewing an unknown kind of
+// template.
+var ErrTemplatePreviewKind = errors.New("unknown template kind")
+
+// TemplatePreview is a draft template expanded with sample data.
+type TemplatePreview struct {
+	// Rendered is the expanded template.
+	Rendered string
+
+	// Length is the length of the rendered template in characters.
+	Length int
+
+	// Segments is the number of SMS segments the rendered message will be sent
+	// as. It is zero for email templates.
+	Segments int
+
+	// Warnings are the problems that would prevent the template from being
+	// saved, and other things the author should know before sending it.
+	Warnings []string
+}
+
+// PreviewTemplate expands the draft template text of the given kind with
+// sample data for the realm, without saving anything. For SMS templates, label
+// is the template label, which controls label-specific checks such as those
+// for the user report template. Sample email links point to origin.
+//
+// The realm is not modified, even if the draft is invalid.
+func (r *Realm) PreviewTemplate(kind, label, text, origin string) (*TemplatePreview, error) {
+	if l := len(text); l > TemplatePreviewMaxLength {
+		return nil, fmt.Errorf("template must be %d characters or less, got %d", TemplatePreviewMaxLength, l)
+	}
+
+	// Validate against a copy so errors are not added to the realm.
+	sample := *r
+	sample.Errorable = Errorable{}
+
+	origin = strings.TrimSuffix(origin, "/")
+	if origin == "" {
+		origin = "https://example.com"
+	}
+
+	switch kind {
+	case TemplatePreviewSMS:
+		return sample.previewSMSTemplate(label, text), nil
+	case TemplatePreviewEmailInvite:
+		return sample.previewEmailTemplate(text, EmailInviteLink, origin+"/login"), nil
+	case TemplatePreviewEmailPasswordReset:
+		return sample.previewEmailTemplate(text, EmailPasswordResetLink, origin+"/login/reset-password"), nil
+	case TemplatePreviewEmailVerify:
+		return sample.previewEmailTemplate(text, EmailVerifyLink, origin+"/login/manage-account?mode=verifyEmail"), nil
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrTemplatePreviewKind, kind)
+	}
+}
+
+func (r *Realm) previewSMSTemplate(label, text string) *TemplatePreview {
+	if label == "" {
+		label = DefaultTemplateLabel
+	}
+
+	var warnings []string
+	if strings.TrimSpace(text) == "" {
+		warnings = append(warnings, "template is blank")
+	}
+
+	normalized := r.validateSMSTemplateText("smsTextTemplate", label, label, text)
+	if normalized != text {
+		warnings = append(warnings, "line breaks and repeated spaces will be replaced by a single space")
+	}
+	warnings = append(warnings, r.ErrorsFor("smsTextTemplate")...)
+
+	code := r.FormatCode(sampleCode(int(r.CodeLength), "1234567890"))
+	longCode := sampleCode(int(r.LongCodeLength), "a2b4c6d8e0f1g3h5")
+	rendered := r.expandSMSText(normalized, code, longCode, r.enxRedirectDomain())
+
+	segments, gsm7 := smsSegments(rendered)
+	if !gsm7 {
+		warnings = append(warnings, "contains characters outside the GSM-7 character set, so the message will be sent with UCS-2 encoding and may cost more")
+	}
+	if segments > 1 {
+		warnings = append(warnings, fmt.Sprintf("will be sent as %d SMS segments; some carriers may deliver them out of order", segments))
+	}
+
+	return &TemplatePreview{
+		Rendered: rendered,
+		Length:   len([]rune(rendered)),
+		Segments: segments,
+		Warnings: warnings,
+	}
+}
+
+func (r *Realm) previewEmailTemplate(text, link, sampleLink string) *TemplatePreview {
+	var warnings []string
+	if strings.TrimSpace(text) == "" {
+		warnings = append(warnings, "template is blank, so the system default template will be used")
+	} else if !strings.Contains(text, link) {
+		warnings = append(warnings, fmt.Sprintf("must contain %q", link))
+	}
+
+	rendered := expandEmailText(text, link, sampleLink, r.Name)
+	return &TemplatePreview{
+		Rendered: rendered,
+		Length:   len([]rune(rendered)),
+		Warnings: warnings,
+	}
+}
+
+// sampleCode returns a code of the given length by repeating alphabet.
+func sampleCode(length int, alphabet string) string {
+	var b strings.Builder
+	for i := 0; i < length; i++ {
+		b.WriteByte(alphabet[i%len(alphabet)])
+	}
+	return b.String()
+}
+
+// gsm7Basic and gsm7Extended are the characters of the GSM 03.38 basic
+// character set and its extension table. Extended characters take two
+// septets.
+const (
+	gsm7Basic = "@£$¥èéùìòÇ\nØø\rÅåΔ_ΦΓΛΩΠΨΣΘΞÆæßÉ !\"#¤%&'()*+,-./0123456789:;<=>?" +
+		"¡ABCDEFGHIJKLMNOPQRSTUVWXYZÄÖÑÜ§¿abcdefghijklmnopqrstuvwxyzäöñüà"
+	gsm7Extended = "^{}\\[~]|€\f"
+)
+
+// smsSegments returns the number of segments needed to send msg and whether
+// it can be encoded with GSM-7.
+func smsSegments(msg string) (int, bool) {
+	septets := 0
+	for _, ch := range msg {
+		switch {
+		case strings.ContainsRune(gsm7Basic, ch):
+			septets++
+		case strings.ContainsRune(gsm7Extended, ch):
+			septets += 2
+		default:
+			// UCS-2 messages are measured in UTF-16 code units.
+			return segmentCount(len(utf16.Encode([]rune(msg))), 70, 67), false
+		}
+	}
+	return segmentCount(septets, 160, 153), true
+}
+
+// segmentCount returns how many segments n units need, given the size of a
+// single message and of each part of a concatenated message.
+func segmentCount(n, single, part int) int {
+	if n <= single {
+		return 1
+	}
+	return (n + part - 1) / part
+}
+
+// expandEmailText replaces the link and realm name in an email template.
+func expandEmailText(text, placeholder, link, realmName string) string {
+	text = strings.ReplaceAll(text, placeholder, link)
+	text = strings.ReplaceAll(text, RealmName, realmName)
+	return text
+}
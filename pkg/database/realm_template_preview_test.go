@@ -0,0 +1,182 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package database
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRealm_PreviewTemplate(t *testing.T) {
+	t.Parallel()
+
+	contains := func(list []string, substr string) bool {
+		for _, v := range list {
+			if strings.Contains(v, substr) {
+				return true
+			}
+		}
+		return false
+	}
+
+	t.Run("unknown_kind", func(t *testing.T) {
+		t.Parallel()
+
+		realm := NewRealmWithDefaults("test")
+		if _, err := realm.PreviewTemplate("fax", "", "hi", ""); !errors.Is(err, ErrTemplatePreviewKind) {
+			t.Errorf("expected %q to be %q", err, ErrTemplatePreviewKind)
+		}
+	})
+
+	t.Run("too_long", func(t *testing.T) {
+		t.Parallel()
+
+		realm := NewRealmWithDefaults("test")
+		text := strings.Repeat("a", TemplatePreviewMaxLength+1)
+		if _, err := realm.PreviewTemplate(TemplatePreviewSMS, "", text, ""); err == nil {
+			t.Error("expected error")
+		}
+	})
+
+	t.Run("sms_valid", func(t *testing.T) {
+		t.Parallel()
+
+		realm := NewRealmWithDefaults("test")
+		realm.LongCodeLength = 16
+		realm.LongCodeDuration = FromDuration(24 * time.Hour)
+
+		preview, err := realm.PreviewTemplate(TemplatePreviewSMS, "", "Your code: [longcode] Expires in [longexpires] hours", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := preview.Rendered, "Your code: a2b4c6d8e0f1g3h5 Expires in 24 hours"; got != want {
+			t.Errorf("expected %q to be %q", got, want)
+		}
+		if got, want := preview.Length, len(preview.Rendered); got != want {
+			t.Errorf("expected %d to be %d", got, want)
+		}
+		if got, want := preview.Segments, 1; got != want {
+			t.Errorf("expected %d to be %d", got, want)
+		}
+		if len(preview.Warnings) > 0 {
+			t.Errorf("expected no warnings, got %q", preview.Warnings)
+		}
+
+		// The realm must not pick up validation errors from a preview.
+		if _, err := realm.PreviewTemplate(TemplatePreviewSMS, "", "no code", ""); err != nil {
+			t.Fatal(err)
+		}
+		if errs := realm.ErrorMessages(); len(errs) > 0 {
+			t.Errorf("expected realm to have no errors, got %q", errs)
+		}
+	})
+
+	t.Run("sms_invalid", func(t *testing.T) {
+		t.Parallel()
+
+		realm := NewRealmWithDefaults("test")
+
+		preview, err := realm.PreviewTemplate(TemplatePreviewSMS, "", "Code [code] or\n\n[longcode]", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !contains(preview.Warnings, "exactly one of") {
+			t.Errorf("expected code warning, got %q", preview.Warnings)
+		}
+		if !contains(preview.Warnings, "line breaks") {
+			t.Errorf("expected whitespace warning, got %q", preview.Warnings)
+		}
+	})
+
+	t.Run("sms_enx", func(t *testing.T) {
+		t.Parallel()
+
+		realm := NewRealmWithDefaults("test")
+		realm.RegionCode = "US-WA"
+		realm.EnableENExpress = true
+		realm.enxRedirectDomainOverride = "en.express"
+
+		preview, err := realm.PreviewTemplate(TemplatePreviewSMS, "", "Visit [enslink]", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := preview.Rendered, "https://us-wa.en.express/v?c="; !strings.Contains(got, want) {
+			t.Errorf("expected %q to contain %q", got, want)
+		}
+		if len(preview.Warnings) > 0 {
+			t.Errorf("expected no warnings, got %q", preview.Warnings)
+		}
+
+		preview, err = realm.PreviewTemplate(TemplatePreviewSMS, "", "Your code is [longcode]", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !contains(preview.Warnings, SMSENExpressLink) {
+			t.Errorf("expected enslink warning, got %q", preview.Warnings)
+		}
+	})
+
+	t.Run("sms_segments", func(t *testing.T) {
+		t.Parallel()
+
+		realm := NewRealmWithDefaults("test")
+
+		preview, err := realm.PreviewTemplate(TemplatePreviewSMS, "", "Código 📱 [code]", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !contains(preview.Warnings, "UCS-2") {
+			t.Errorf("expected encoding warning, got %q", preview.Warnings)
+		}
+
+		preview, err = realm.PreviewTemplate(TemplatePreviewSMS, "", strings.Repeat("a", 200)+" [code]", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := preview.Segments, 2; got != want {
+			t.Errorf("expected %d to be %d", got, want)
+		}
+		if !contains(preview.Warnings, "2 SMS segments") {
+			t.Errorf("expected segment warning, got %q", preview.Warnings)
+		}
+	})
+
+	t.Run("email", func(t *testing.T) {
+		t.Parallel()
+
+		realm := NewRealmWithDefaults("Example Health")
+
+		preview, err := realm.PreviewTemplate(TemplatePreviewEmailInvite, "", "Join [realmname]: [invitelink]", "https://verify.example.org/")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := preview.Rendered, "Join Example Health: https://verify.example.org/login"; got != want {
+			t.Errorf("expected %q to be %q", got, want)
+		}
+		if len(preview.Warnings) > 0 {
+			t.Errorf("expected no warnings, got %q", preview.Warnings)
+		}
+
+		preview, err = realm.PreviewTemplate(TemplatePreviewEmailVerify, "", "Verify your account", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !contains(preview.Warnings, EmailVerifyLink) {
+			t.Errorf("expected link warning, got %q", preview.Warnings)
+		}
+	})
+}
@@ -16,17 +16,21 @@ package database
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"path/filepath"
+	"reflect"
 	"strings"
 	"testing"
 	"time"
 
 	"github.com/google/exposure-notifications-server/pkg/timeutils"
 	"github.com/google/exposure-notifications-verification-server/internal/project"
+	"github.com/google/exposure-notifications-verification-server/pkg/jwthelper"
 	"github.com/google/exposure-notifications-verification-server/pkg/pagination"
 	"github.com/google/exposure-notifications-verification-server/pkg/rbac"
 	"github.com/jinzhu/gorm"
+	"github.com/jinzhu/gorm/dialects/postgres"
 	"github.com/lib/pq"
 )
 
@@ -255,6 +259,52 @@ func TestRealm_BeforeSave(t *testing.T) {
 			},
 			Error: "longCodeDuration must be no more than 24 hours",
 		},
+		{
+			Name: "code_charset_invalid",
+			Input: &Realm{
+				Name:        "a",
+				CodeLength:  6,
+				CodeCharset: "hex",
+			},
+			Error: `codeCharset must be "numeric" or "alphanumeric"`,
+		},
+		{
+			Name: "code_group_size_too_small",
+			Input: &Realm{
+				Name:          "a",
+				CodeLength:    6,
+				CodeGroupSize: 1,
+			},
+			Error: "codeGroupSize must be 0 or at least 2",
+		},
+		{
+			Name: "code_group_size_too_large",
+			Input: &Realm{
+				Name:          "a",
+				CodeLength:    6,
+				CodeGroupSize: 6,
+			},
+			Error: "codeGroupSize must be less than the short code length",
+		},
+		{
+			Name: "external_case_id_pattern_invalid",
+			Input: &Realm{
+				Name:                  "a",
+				CodeLength:            6,
+				ExternalCaseIDPattern: "LAB-[0-9",
+			},
+			Error: "externalCaseIDPattern is not a valid regular expression",
+		},
+		{
+			Name: "code_charset_enx",
+			Input: &Realm{
+				Name:            "a",
+				CodeLength:      6,
+				CodeCharset:     CodeCharsetAlphanumeric,
+				EnableENExpress: true,
+			},
+			Error: "codeCharset must be numeric when using EN Express",
+		},
 		{
 			Name: "missing_enx_link",
 			Input: &Realm{
@@ -435,6 +485,99 @@ func TestRealm_BeforeSave(t *testing.T) {
 			},
 			Error: "contactEmailAddresses includes invalid email address \"a\"",
 		},
+		{
+			Name: "sms_allowed_countries_invalid",
+			Input: &Realm{
+				SMSAllowedCountries: []string{"US", "zz"},
+			},
+			Error: "smsAllowedCountries \"zz\" is not a valid country code",
+		},
+		{
+			Name: "email_invite_template_variant_missing_link",
+			Input: &Realm{
+				EmailInviteTemplates: postgres.Hstore{
+					"es": stringPtr("Bienvenido"),
+				},
+			},
+			Error: "emailInviteTemplates template for locale es must contain \"[invitelink]\"",
+		},
+		{
+			Name: "minimum_app_version_invalid",
+			Input: &Realm{
+				MinimumAppVersion: "latest",
+			},
+			Error: "minimumAppVersion must be a version like 1.2.3",
+		},
+		{
+			Name: "api_rate_limit_key_mode_invalid",
+			Input: &Realm{
+				APIRateLimitKeyMode: 99,
+			},
+			Error: "apiRateLimitKeyMode is not a valid rate limit mode",
+		},
+		{
+			Name: "api_rate_limit_burst_too_large",
+			Input: &Realm{
+				APIRateLimitBurst: MaxAPIRateLimitBurst + 1,
+			},
+			Error: "apiRateLimitBurst must be no more than 100000",
+		},
+		{
+			Name: "api_rate_limit_ban_duration_too_long",
+			Input: &Realm{
+				APIRateLimitBanDuration: FromDuration(25 * time.Hour),
+			},
+			Error: "apiRateLimitBanDuration must be between 0 and 24 hours",
+		},
+		{
+			Name: "verification_code_retention_too_short",
+			Input: &Realm{
+				VerificationCodeRetentionDays: MinVerificationCodeRetentionDays - 1,
+			},
+			Error: "verificationCodeRetentionDays must be 0 or between 3 and 90",
+		},
+		{
+			Name: "token_retention_too_long",
+			Input: &Realm{
+				TokenRetentionDays: MaxTokenRetentionDays + 1,
+			},
+			Error: "tokenRetentionDays must be 0 or between 1 and 30",
+		},
+		{
+			Name: "stats_retention_too_short",
+			Input: &Realm{
+				StatsRetentionDays: MinStatsRetentionDays - 1,
+			},
+			Error: "statsRetentionDays must be 0 or between 30 and 3650",
+		},
+		{
+			Name: "user_report_retention_too_long",
+			Input: &Realm{
+				UserReportRetentionDays: MaxUserReportRetentionDays + 1,
+			},
+			Error: "userReportRetentionDays must be 0 or between 1 and 365",
+		},
+		{
+			Name: "custom_domain_scheme",
+			Input: &Realm{
+				CustomDomain: "https://verify.example.com",
+			},
+			Error: "customDomain must be a hostname like verify.example.com",
+		},
+		{
+			Name: "custom_domain_port",
+			Input: &Realm{
+				CustomDomain: "verify.example.com:8080",
+			},
+			Error: "customDomain must be a hostname like verify.example.com",
+		},
+		{
+			Name: "custom_domain_single_label",
+			Input: &Realm{
+				CustomDomain: "localhost",
+			},
+			Error: "customDomain must be a hostname like verify.example.com",
+		},
 	}
 
 	for _, tc := range cases {
@@ -458,6 +601,73 @@ func TestRealm_BeforeSave(t *testing.T) {
 	}
 }
 
+func TestToCountryList(t *testing.T) {
+	t.Parallel()
+
+	got := ToCountryList("US, ca\n\nmx,us \n")
+	if want := []string{"ca", "mx", "us"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %q to be %q", got, want)
+	}
+}
+
+func TestRealm_SMSDestinationAllowed(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name    string
+		allowed []string
+		phone   string
+		region  string
+		ok      bool
+		err     bool
+	}{
+		{
+			name:  "no_allowlist",
+			phone: "+442071838750",
+			ok:    true,
+		},
+		{
+			name:    "allowed",
+			allowed: []string{"ca", "us"},
+			phone:   "+12068675309",
+			region:  "us",
+			ok:      true,
+		},
+		{
+			name:    "not_allowed",
+			allowed: []string{"ca", "us"},
+			phone:   "+442071838750",
+			region:  "gb",
+		},
+		{
+			name:    "invalid",
+			allowed: []string{"us"},
+			phone:   "nope",
+			err:     true,
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			realm := &Realm{SMSAllowedCountries: tc.allowed}
+			region, ok, err := realm.SMSDestinationAllowed(tc.phone)
+			if (err != nil) != tc.err {
+				t.Fatalf("expected error %t, got %v", tc.err, err)
+			}
+			if got, want := region, tc.region; got != want {
+				t.Errorf("expected region %q to be %q", got, want)
+			}
+			if got, want := ok, tc.ok; got != want {
+				t.Errorf("expected allowed %t to be %t", got, want)
+			}
+		})
+	}
+}
+
 func TestRealm_ValidateSMSTemplateUserReport(t *testing.T) {
 	t.Parallel()
 
@@ -531,24 +741,185 @@ func TestRealm_BuildSMSText(t *testing.T) {
 	}
 }
 
+func TestRealm_BuildSMSText_enxLinkParams(t *testing.T) {
+	t.Parallel()
+
+	campaign, clinic := "fall 2021", "c-12"
+
+	realm := NewRealmWithDefaults("test")
+	realm.SMSTextTemplate = "Share your result [enslink]"
+	realm.RegionCode = "US-WA"
+	realm.ENXLinkParams = postgres.Hstore{
+		"utm_campaign": &campaign,
+		"clinic":       &clinic,
+	}
+
+	got, err := realm.BuildSMSText("12345678", "abcdefgh12345678", "en.express", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "Share your result https://us-wa.en.express/v?c=abcdefgh12345678&clinic=c-12&utm_campaign=fall+2021"; got != want {
+		t.Errorf("SMS text wrong, want: %q got %q", want, got)
+	}
+
+	got, err = realm.BuildSMSText("12345678", "abcdefgh12345678", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "Share your result ens://v?r=US-WA&c=abcdefgh12345678&clinic=c-12&utm_campaign=fall+2021"; got != want {
+		t.Errorf("SMS text wrong, want: %q got %q", want, got)
+	}
+}
+
+func TestRealm_validateENXLinkParams(t *testing.T) {
+	t.Parallel()
+
+	ptr := func(s string) *string { return &s }
+
+	cases := []struct {
+		name   string
+		params postgres.Hstore
+		err    string
+	}{
+		{
+			name:   "valid",
+			params: postgres.Hstore{" clinic ": ptr(" c-12 ")},
+		},
+		{
+			name:   "bad_name",
+			params: postgres.Hstore{"1clinic": ptr("c-12")},
+			err:    "must start with a letter",
+		},
+		{
+			name:   "reserved",
+			params: postgres.Hstore{"C": ptr("c-12")},
+			err:    "is reserved",
+		},
+		{
+			name:   "blank_value",
+			params: postgres.Hstore{"clinic": ptr("  ")},
+			err:    "must have a value",
+		},
+		{
+			name:   "too_long",
+			params: postgres.Hstore{"clinic": ptr(strings.Repeat("a", ENXLinkParamsMaxLength))},
+			err:    "characters or less when encoded",
+		},
+		{
+			name: "too_many",
+			params: postgres.Hstore{
+				"a": ptr("1"), "b": ptr("2"), "d": ptr("3"), "e": ptr("4"), "f": ptr("5"), "g": ptr("6"),
+			},
+			err: "cannot have more than",
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			realm := NewRealmWithDefaults("test")
+			got := realm.validateENXLinkParams(tc.params)
+
+			errs := strings.Join(realm.ErrorsFor("enxLinkParams"), ",")
+			if tc.err == "" {
+				if errs != "" {
+					t.Fatalf("expected no errors, got %q", errs)
+				}
+				if v := got["clinic"]; v == nil || *v != "c-12" {
+					t.Errorf("expected trimmed parameter, got %v", got)
+				}
+				return
+			}
+			if !strings.Contains(errs, tc.err) {
+				t.Errorf("expected %q to contain %q", errs, tc.err)
+			}
+		})
+	}
+}
+
 func TestRealm_BuildInviteEmail(t *testing.T) {
 	t.Parallel()
 
 	realm := NewRealmWithDefaults("test")
 	realm.EmailInviteTemplate = "Welcome to [realmname] [invitelink]."
 
-	if got, want := realm.BuildInviteEmail("https://join.now"), "Welcome to test https://join.now."; got != want {
+	if got, want := realm.BuildInviteEmail("https://join.now", ""), "Welcome to test https://join.now."; got != want {
 		t.Errorf("Expected %q to be %q", got, want)
 	}
 }
 
+func TestRealm_EmailInviteTemplateFor(t *testing.T) {
+	t.Parallel()
+
+	es := "Bienvenido a [realmname] [invitelink]."
+	esMX := "Bienvenido a [realmname] desde México [invitelink]."
+
+	realm := NewRealmWithDefaults("test")
+	realm.EmailInviteTemplate = "Welcome to [realmname] [invitelink]."
+	realm.EmailInviteTemplates = postgres.Hstore{
+		"es":    &es,
+		"es-mx": &esMX,
+	}
+
+	cases := []struct {
+		name   string
+		locale string
+		exp    string
+	}{
+		{
+			name:   "blank",
+			locale: "",
+			exp:    realm.EmailInviteTemplate,
+		},
+		{
+			name:   "exact",
+			locale: "es-MX",
+			exp:    esMX,
+		},
+		{
+			name:   "underscore",
+			locale: "es_mx",
+			exp:    esMX,
+		},
+		{
+			name:   "base_language",
+			locale: "es-AR",
+			exp:    es,
+		},
+		{
+			name:   "no_match",
+			locale: "fr",
+			exp:    realm.EmailInviteTemplate,
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			if got, want := realm.EmailInviteTemplateFor(tc.locale), tc.exp; got != want {
+				t.Errorf("expected %q to be %q", got, want)
+			}
+		})
+	}
+
+	if got, want := realm.BuildInviteEmail("https://join.now", "es"), "Bienvenido a test https://join.now."; got != want {
+		t.Errorf("expected %q to be %q", got, want)
+	}
+}
+
 func TestRealm_BuildPasswordResetEmail(t *testing.T) {
 	t.Parallel()
 
 	realm := NewRealmWithDefaults("test")
 	realm.EmailPasswordResetTemplate = "Hey [realmname] reset [passwordresetlink]."
 
-	if got, want := realm.BuildPasswordResetEmail("https://reset.now"), "Hey test reset https://reset.now."; got != want {
+	if got, want := realm.BuildPasswordResetEmail("https://reset.now", ""), "Hey test reset https://reset.now."; got != want {
 		t.Errorf("Expected %q to be %q", got, want)
 	}
 }
@@ -559,7 +930,7 @@ func TestRealm_BuildVerifyEmail(t *testing.T) {
 	realm := NewRealmWithDefaults("test")
 	realm.EmailVerifyTemplate = "Hey [realmname] verify [verifylink]."
 
-	if got, want := realm.BuildVerifyEmail("https://verify.now"), "Hey test verify https://verify.now."; got != want {
+	if got, want := realm.BuildVerifyEmail("https://verify.now", ""), "Hey test verify https://verify.now."; got != want {
 		t.Errorf("Expected %q to be %q", got, want)
 	}
 }
@@ -602,6 +973,71 @@ func TestRealm_SMSErrorStats(t *testing.T) {
 	}
 }
 
+func TestRealm_StatsBetween(t *testing.T) {
+	t.Parallel()
+
+	db, _ := testDatabaseInstance.NewDatabase(t, nil)
+
+	realm, err := db.FindRealm(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Older than the stats display period.
+	day := timeutils.UTCMidnight(time.Now()).Add(-200 * 24 * time.Hour)
+	if err := db.RawDB().Create(&RealmStat{
+		Date:        day,
+		RealmID:     realm.ID,
+		CodesIssued: 5,
+	}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("bad_range", func(t *testing.T) {
+		t.Parallel()
+
+		if _, err := realm.StatsBetween(db, day, day.Add(-24*time.Hour)); !errors.Is(err, ErrBadDateRange) {
+			t.Errorf("expected %v to be %v", err, ErrBadDateRange)
+		}
+	})
+
+	t.Run("range", func(t *testing.T) {
+		t.Parallel()
+
+		stats, err := realm.StatsBetween(db, day.Add(-24*time.Hour), day.Add(24*time.Hour))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if got, want := len(stats), 3; got != want {
+			t.Fatalf("Expected %d to be %d", got, want)
+		}
+
+		var issued uint
+		for _, stat := range stats {
+			issued += stat.CodesIssued
+		}
+		if got, want := issued, uint(5); got != want {
+			t.Errorf("Expected %d to be %d", got, want)
+		}
+	})
+
+	t.Run("default", func(t *testing.T) {
+		t.Parallel()
+
+		stats, err := realm.Stats(db)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, stat := range stats {
+			if stat.CodesIssued != 0 {
+				t.Errorf("expected stats outside the display period to be excluded, got %#v", stat)
+			}
+		}
+	})
+}
+
 func TestRealm_UserStats(t *testing.T) {
 	t.Parallel()
 
@@ -768,10 +1204,18 @@ func TestRealm_FindRealm(t *testing.T) {
 
 	realm1 := NewRealmWithDefaults("realm1")
 	realm1.RegionCode = "US-MOO"
+	realm1.CustomDomain = "Verify.Moo.Example.com"
 	if err := db.SaveRealm(realm1, SystemTest); err != nil {
 		t.Fatal(err)
 	}
 
+	// Custom domains are unique.
+	realm2 := NewRealmWithDefaults("realm2")
+	realm2.CustomDomain = "verify.moo.example.com"
+	if err := db.SaveRealm(realm2, SystemTest); !errors.Is(err, ErrValidationFailed) {
+		t.Errorf("expected %v to be %v", err, ErrValidationFailed)
+	}
+
 	cases := []struct {
 		name   string
 		findFn func() (*Realm, error)
@@ -796,6 +1240,10 @@ func TestRealm_FindRealm(t *testing.T) {
 			name:   "find_by_region_or_id/region_code",
 			findFn: func() (*Realm, error) { return db.FindRealmByRegionOrID(realm1.RegionCode) },
 		},
+		{
+			name:   "find_by_custom_domain",
+			findFn: func() (*Realm, error) { return db.FindRealmByCustomDomain("verify.moo.example.com.") },
+		},
 	}
 
 	for _, tc := range cases {
@@ -952,6 +1400,42 @@ func TestRealm_CreateSigningKeyVersion(t *testing.T) {
 	}
 }
 
+func TestRealm_CreateSigningKeyVersion_Algorithm(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	db, _ := testDatabaseInstance.NewDatabase(t, nil)
+
+	db.config.KeyRing = filepath.Join(project.Root(), "local", "test", "realm")
+
+	invalid := NewRealmWithDefaults("invalid")
+	invalid.CertificateSigningAlgorithm = "HS256"
+	if err := db.SaveRealm(invalid, SystemTest); !IsValidationError(err) {
+		t.Fatalf("expected validation error, got %v", err)
+	}
+
+	// The test key manager only creates ES256 keys, so a realm cannot be saved
+	// with an algorithm for which it could never create keys.
+	if db.SupportsSigningAlgorithm(jwthelper.AlgorithmES384) {
+		t.Skip("key manager supports ES384")
+	}
+	unsupported := NewRealmWithDefaults("unsupported")
+	unsupported.CertificateSigningAlgorithm = jwthelper.AlgorithmES384
+	if err := db.SaveRealm(unsupported, SystemTest); !IsValidationError(err) {
+		t.Fatalf("expected validation error, got %v", err)
+	}
+
+	// A realm whose algorithm is no longer supported fails to create keys.
+	realm := NewRealmWithDefaults("realm1")
+	if err := db.SaveRealm(realm, SystemTest); err != nil {
+		t.Fatal(err)
+	}
+	realm.CertificateSigningAlgorithm = jwthelper.AlgorithmES384
+	if _, err := realm.CreateSigningKeyVersion(ctx, db, SystemTest); !errors.Is(err, ErrUnsupportedSigningAlgorithm) {
+		t.Errorf("expected %v, got %v", ErrUnsupportedSigningAlgorithm, err)
+	}
+}
+
 func TestRealm_CreateSMSSigningKeyVersion(t *testing.T) {
 	t.Parallel()
 
@@ -1416,3 +1900,46 @@ func TestRealm_ListAndRecordChaffEvent(t *testing.T) {
 	expectEvents(t, 7)
 	expectPresents(t, 2)
 }
+
+func TestRealm_IssuerQuotaKeys(t *testing.T) {
+	t.Parallel()
+
+	hmacKey := []byte("abcdefghijklmnopqrstuvwxyz")
+
+	realm := NewRealmWithDefaults("test")
+	realm.ID = 1
+
+	user1, err := realm.UserQuotaKey(1, hmacKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	user2, err := realm.UserQuotaKey(2, hmacKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	app1, err := realm.APIKeyQuotaKey(1, hmacKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.HasPrefix(user1, "realm:user_quota:") {
+		t.Errorf("expected %q to start with %q", user1, "realm:user_quota:")
+	}
+	if !strings.HasPrefix(app1, "realm:api_key_quota:") {
+		t.Errorf("expected %q to start with %q", app1, "realm:api_key_quota:")
+	}
+	if user1 == user2 {
+		t.Errorf("expected quota keys for different users to differ, both were %q", user1)
+	}
+
+	// The same user in a different realm gets a different key.
+	other := NewRealmWithDefaults("other")
+	other.ID = 2
+	otherUser1, err := other.UserQuotaKey(1, hmacKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user1 == otherUser1 {
+		t.Errorf("expected quota keys for different realms to differ, both were %q", user1)
+	}
+}
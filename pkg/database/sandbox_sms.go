@@ -0,0 +1,118 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package database
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/exposure-notifications-verification-server/pkg/pagination"
+	"github.com/google/exposure-notifications-verification-server/pkg/sms"
+	"github.com/jinzhu/gorm"
+)
+
+// SandboxSMS is an SMS message that was recorded by the NOOP_INSPECT SMS
+// provider instead of being sent. These only exist in development and test
+// environments.
+type SandboxSMS struct {
+	// ID is the message's ID.
+	ID uint `gorm:"primary_key;"`
+
+	// RealmID is the realm for which the message was sent.
+	RealmID uint `gorm:"column:realm_id; type:integer; not null;"`
+
+	// ToNumber is the phone number to which the message would have been sent.
+	ToNumber string `gorm:"column:to_number; type:text; not null;"`
+
+	// Message is the full message body.
+	Message string `gorm:"column:message; type:text; not null;"`
+
+	// CreatedAt is when the message was recorded.
+	CreatedAt time.Time
+}
+
+// TableName sets the table name.
+func (SandboxSMS) TableName() string {
+	return "sandbox_sms"
+}
+
+// sandboxSMSRecorder records sandbox messages for a realm.
+type sandboxSMSRecorder struct {
+	db      *Database
+	realmID uint
+}
+
+var _ sms.Recorder = (*sandboxSMSRecorder)(nil)
+
+// RecordSMS implements sms.Recorder.
+func (r *sandboxSMSRecorder) RecordSMS(_ context.Context, to, message string) error {
+	return r.db.db.Create(&SandboxSMS{
+		RealmID:  r.realmID,
+		ToNumber: to,
+		Message:  message,
+	}).Error
+}
+
+// ListSandboxSMS lists the recorded sandbox messages for the realm, newest
+// first.
+func (r *Realm) ListSandboxSMS(db *Database, p *pagination.PageParams, scopes ...Scope) ([]*SandboxSMS, *pagination.Paginator, error) {
+	scopes = append(scopes, func(db *gorm.DB) *gorm.DB {
+		return db.Where("realm_id = ?", r.ID)
+	})
+	return db.ListSandboxSMS(p, scopes...)
+}
+
+// ListSandboxSMS lists the recorded sandbox messages across all realms, newest
+// first.
+func (db *Database) ListSandboxSMS(p *pagination.PageParams, scopes ...Scope) ([]*SandboxSMS, *pagination.Paginator, error) {
+	var messages []*SandboxSMS
+
+	query := db.db.
+		Model(&SandboxSMS{}).
+		Scopes(scopes...).
+		Order("created_at DESC, id DESC")
+
+	if p == nil {
+		p = new(pagination.PageParams)
+	}
+
+	paginator, err := Paginate(query, &messages, p.Page, p.Limit)
+	if err != nil {
+		if IsNotFound(err) {
+			return messages, nil, nil
+		}
+		return nil, nil, err
+	}
+
+	return messages, paginator, nil
+}
+
+// PurgeSandboxSMS deletes sandbox messages older than maxAge.
+func (db *Database) PurgeSandboxSMS(maxAge time.Duration) (int64, error) {
+	if maxAge > 0 {
+		maxAge = -1 * maxAge
+	}
+	deleteBefore := time.Now().UTC().Add(maxAge)
+
+	result := db.db.
+		Unscoped().
+		Where("created_at < ?", deleteBefore).
+		Delete(&SandboxSMS{})
+	if err := result.Error; err != nil {
+		return 0, fmt.Errorf("failed to purge sandbox sms: %w", err)
+	}
+	return result.RowsAffected, nil
+}
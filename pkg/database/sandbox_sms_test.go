@@ -0,0 +1,100 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package database
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/exposure-notifications-verification-server/internal/project"
+)
+
+func TestRealm_ListSandboxSMS(t *testing.T) {
+	t.Parallel()
+
+	ctx := project.TestContext(t)
+	db, _ := testDatabaseInstance.NewDatabase(t, nil)
+
+	realm, err := db.FindRealm(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	recorder := &sandboxSMSRecorder{db: db, realmID: realm.ID}
+	if err := recorder.RecordSMS(ctx, "+12065551234", "first"); err != nil {
+		t.Fatal(err)
+	}
+	if err := recorder.RecordSMS(ctx, "+12065551234", "second"); err != nil {
+		t.Fatal(err)
+	}
+	if err := recorder.RecordSMS(ctx, "+12065550000", "other"); err != nil {
+		t.Fatal(err)
+	}
+
+	messages, _, err := realm.ListSandboxSMS(db, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(messages), 3; got != want {
+		t.Fatalf("expected %d messages, got %d", want, got)
+	}
+
+	messages, _, err = realm.ListSandboxSMS(db, nil, WithSandboxSMSToNumber("+12065551234"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(messages), 2; got != want {
+		t.Fatalf("expected %d messages, got %d", want, got)
+	}
+	if got, want := messages[0].Message, "second"; got != want {
+		t.Errorf("expected newest message %q to be %q", got, want)
+	}
+}
+
+func TestDatabase_PurgeSandboxSMS(t *testing.T) {
+	t.Parallel()
+
+	ctx := project.TestContext(t)
+	db, _ := testDatabaseInstance.NewDatabase(t, nil)
+
+	recorder := &sandboxSMSRecorder{db: db, realmID: 1}
+	for i := 0; i < 5; i++ {
+		if err := recorder.RecordSMS(ctx, "+12065551234", "message"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Should not purge entries (too young).
+	{
+		n, err := db.PurgeSandboxSMS(24 * time.Hour)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := n, int64(0); got != want {
+			t.Errorf("expected %d to purge, got %d", want, got)
+		}
+	}
+
+	// Purges entries.
+	{
+		n, err := db.PurgeSandboxSMS(1 * time.Nanosecond)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := n, int64(5); got != want {
+			t.Errorf("expected %d to purge, got %d", want, got)
+		}
+	}
+}
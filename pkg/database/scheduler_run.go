@@ -0,0 +1,146 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package database
+
+import (
+	"fmt"
+	"time"
+)
+
+// SchedulerRun is a single trigger of a scheduled job by the built-in
+// scheduler. The history is stored in the database so a newly elected leader
+// resumes the schedule where the previous leader left off.
+type SchedulerRun struct {
+	ID uint `gorm:"primary_key;"`
+
+	// Job is the name of the scheduled job.
+	Job string `gorm:"column:job; type:text; not null;"`
+
+	// Leader is the identity of the scheduler instance that triggered the job.
+	Leader string `gorm:"column:leader; type:text; not null;"`
+
+	// StartedAt and FinishedAt bound the request to the job.
+	StartedAt  time.Time `gorm:"column:started_at; type:timestamp with time zone; not null;"`
+	FinishedAt time.Time `gorm:"column:finished_at; type:timestamp with time zone; not null;"`
+
+	// NextRunAt is when the job is next due, including jitter.
+	NextRunAt time.Time `gorm:"column:next_run_at; type:timestamp with time zone; not null;"`
+
+	// StatusCode is the HTTP status returned by the job, or 0 if the request
+	// failed. Error is the failure, if any.
+	StatusCode int    `gorm:"column:status_code; type:integer; not null; default:0;"`
+	Error      string `gorm:"column:error; type:text;"`
+}
+
+// TableName sets the table name.
+func (SchedulerRun) TableName() string {
+	return "scheduler_runs"
+}
+
+// Succeeded returns true if the job responded with a 2xx.
+func (r *SchedulerRun) Succeeded() bool {
+	return r.Error == "" && r.StatusCode >= 200 && r.StatusCode < 300
+}
+
+// ClaimSchedulerLeadership attempts to become (or remain) the leader for the
+// named scheduler for the given duration. It returns true if holder is the
+// leader. The current leader renews its lease on each call; other holders can
+// only take over once the lease has expired.
+func (db *Database) ClaimSchedulerLeadership(name, holder string, lease time.Duration) (bool, error) {
+	sql := `
+		INSERT INTO scheduler_leases (name, holder, expires_at)
+			VALUES ($1, $2, $3)
+		ON CONFLICT (name) DO UPDATE
+			SET holder = EXCLUDED.holder,
+				expires_at = EXCLUDED.expires_at
+			WHERE scheduler_leases.holder = EXCLUDED.holder
+				OR scheduler_leases.expires_at < $4
+		RETURNING holder`
+
+	now := time.Now().UTC()
+
+	var result struct {
+		Holder string
+	}
+	if err := db.db.Raw(sql, name, holder, now.Add(lease), now).Scan(&result).Error; err != nil {
+		// No rows are returned when another holder has the lease.
+		if IsNotFound(err) {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to claim scheduler leadership: %w", err)
+	}
+	return result.Holder == holder, nil
+}
+
+// SaveSchedulerRun records a run of a scheduled job.
+func (db *Database) SaveSchedulerRun(r *SchedulerRun) error {
+	if err := db.db.Create(r).Error; err != nil {
+		return fmt.Errorf("failed to save scheduler run: %w", err)
+	}
+	return nil
+}
+
+// LatestSchedulerRuns returns the most recent run of each job, keyed by job
+// name. Jobs which have never run are not present.
+func (db *Database) LatestSchedulerRuns() (map[string]*SchedulerRun, error) {
+	var runs []*SchedulerRun
+	if err := db.db.
+		Raw(`SELECT DISTINCT ON (job) * FROM scheduler_runs ORDER BY job, started_at DESC`).
+		Scan(&runs).
+		Error; err != nil {
+		if IsNotFound(err) {
+			return map[string]*SchedulerRun{}, nil
+		}
+		return nil, fmt.Errorf("failed to list latest scheduler runs: %w", err)
+	}
+
+	m := make(map[string]*SchedulerRun, len(runs))
+	for _, r := range runs {
+		m[r.Job] = r
+	}
+	return m, nil
+}
+
+// ListSchedulerRuns returns the most recent runs across all jobs, newest
+// first.
+func (db *Database) ListSchedulerRuns(limit int) ([]*SchedulerRun, error) {
+	var runs []*SchedulerRun
+	if err := db.db.
+		Model(&SchedulerRun{}).
+		Order("started_at DESC").
+		Limit(limit).
+		Find(&runs).
+		Error; err != nil {
+		if IsNotFound(err) {
+			return runs, nil
+		}
+		return nil, fmt.Errorf("failed to list scheduler runs: %w", err)
+	}
+	return runs, nil
+}
+
+// PurgeSchedulerRuns deletes scheduler runs older than maxAge.
+func (db *Database) PurgeSchedulerRuns(maxAge time.Duration) (int64, error) {
+	if maxAge > 0 {
+		maxAge = -1 * maxAge
+	}
+	deleteBefore := time.Now().UTC().Add(maxAge)
+
+	result := db.db.
+		Unscoped().
+		Where("started_at < ?", deleteBefore).
+		Delete(&SchedulerRun{})
+	return result.RowsAffected, result.Error
+}
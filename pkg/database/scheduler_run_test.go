@@ -0,0 +1,127 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package database
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDatabase_ClaimSchedulerLeadership(t *testing.T) {
+	t.Parallel()
+
+	db, _ := testDatabaseInstance.NewDatabase(t, nil)
+
+	lease := 500 * time.Millisecond
+
+	if ok, err := db.ClaimSchedulerLeadership("scheduler", "a", lease); err != nil {
+		t.Fatal(err)
+	} else if !ok {
+		t.Fatalf("expected a to claim leadership when available")
+	}
+
+	// The leader can renew.
+	if ok, err := db.ClaimSchedulerLeadership("scheduler", "a", lease); err != nil {
+		t.Fatal(err)
+	} else if !ok {
+		t.Fatalf("expected a to renew leadership")
+	}
+
+	// Another holder cannot take over an active lease.
+	if ok, err := db.ClaimSchedulerLeadership("scheduler", "b", lease); err != nil {
+		t.Fatal(err)
+	} else if ok {
+		t.Fatalf("expected b to not claim leadership while a holds the lease")
+	}
+
+	time.Sleep(lease)
+
+	// Once expired, another holder takes over.
+	if ok, err := db.ClaimSchedulerLeadership("scheduler", "b", lease); err != nil {
+		t.Fatal(err)
+	} else if !ok {
+		t.Fatalf("expected b to claim leadership after the lease expired")
+	}
+	if ok, err := db.ClaimSchedulerLeadership("scheduler", "a", lease); err != nil {
+		t.Fatal(err)
+	} else if ok {
+		t.Fatalf("expected a to have lost leadership")
+	}
+}
+
+func TestDatabase_SchedulerRuns(t *testing.T) {
+	t.Parallel()
+
+	db, _ := testDatabaseInstance.NewDatabase(t, nil)
+
+	latest, err := db.LatestSchedulerRuns()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(latest), 0; got != want {
+		t.Errorf("expected %d latest runs to be %d", got, want)
+	}
+
+	now := time.Now().UTC().Truncate(time.Second)
+	runs := []*SchedulerRun{
+		{Job: "cleanup", Leader: "a", StartedAt: now.Add(-48 * time.Hour), StatusCode: 200},
+		{Job: "cleanup", Leader: "a", StartedAt: now.Add(-5 * time.Minute), StatusCode: 500, Error: "oops"},
+		{Job: "appsync", Leader: "a", StartedAt: now.Add(-1 * time.Minute), StatusCode: 200},
+	}
+	for _, run := range runs {
+		run.FinishedAt = run.StartedAt.Add(time.Second)
+		run.NextRunAt = run.StartedAt.Add(5 * time.Minute)
+		if err := db.SaveSchedulerRun(run); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	latest, err = db.LatestSchedulerRuns()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(latest), 2; got != want {
+		t.Fatalf("expected %d latest runs to be %d", got, want)
+	}
+	cleanup := latest["cleanup"]
+	if got, want := cleanup.StartedAt, runs[1].StartedAt; !got.Equal(want) {
+		t.Errorf("expected latest cleanup run %s to be %s", got, want)
+	}
+	if cleanup.Succeeded() {
+		t.Errorf("expected latest cleanup run to have failed")
+	}
+	if !latest["appsync"].Succeeded() {
+		t.Errorf("expected latest appsync run to have succeeded")
+	}
+
+	list, err := db.ListSchedulerRuns(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(list), 2; got != want {
+		t.Fatalf("expected %d runs to be %d", got, want)
+	}
+	if got, want := list[0].Job, "appsync"; got != want {
+		t.Errorf("expected newest run %q to be %q", got, want)
+	}
+
+	count, err := db.PurgeSchedulerRuns(24 * time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := count, int64(1); got != want {
+		t.Errorf("expected %d purged runs to be %d", got, want)
+	}
+}
@@ -35,11 +35,12 @@ func Unscoped() Scope {
 	}
 }
 
-// OnlySystemAdmins returns a scope that restricts the query to system admins.
+// OnlySystemAdmins returns a scope that restricts the query to system admins
+// and break-glass accounts.
 // It's only applicable to functions that query User.
 func OnlySystemAdmins() Scope {
 	return func(db *gorm.DB) *gorm.DB {
-		return db.Where(&User{SystemAdmin: true})
+		return db.Where("users.system_admin = ? OR users.break_glass = ?", true, true)
 	}
 }
 
@@ -210,6 +211,41 @@ func WithoutAuditTest() Scope {
 	}
 }
 
+// WithAuditActorID returns a scope that adds querying for Audit events by the
+// actor's ID (e.g. users:1). An empty ID does not filter.
+func WithAuditActorID(id string) Scope {
+	return func(db *gorm.DB) *gorm.DB {
+		id = project.TrimSpace(id)
+		if id != "" {
+			return db.Where("audit_entries.actor_id = ?", id)
+		}
+		return db
+	}
+}
+
+// WithAuditAction returns a scope that adds querying for Audit events by
+// action. An empty action does not filter.
+func WithAuditAction(action string) Scope {
+	return func(db *gorm.DB) *gorm.DB {
+		action = project.TrimSpace(action)
+		if action != "" {
+			return db.Where("audit_entries.action = ?", action)
+		}
+		return db
+	}
+}
+
+// WithAuditAfterID returns a scope that adds querying for Audit events with an
+// ID greater than the given ID. It is used as the cursor for exports.
+func WithAuditAfterID(id uint) Scope {
+	return func(db *gorm.DB) *gorm.DB {
+		if id > 0 {
+			return db.Where("audit_entries.id > ?", id)
+		}
+		return db
+	}
+}
+
 // WithAppOS returns a scope that for querying MobileApps by Operating System type.
 func WithAppOS(os OSType) Scope {
 	return func(db *gorm.DB) *gorm.DB {
@@ -251,3 +287,11 @@ func WithMobileAppSearch(q string) Scope {
 		return db
 	}
 }
+
+// WithSandboxSMSToNumber returns a scope that filters sandbox SMS messages to
+// those sent to the given phone number.
+func WithSandboxSMSToNumber(to string) Scope {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where("to_number = ?", to)
+	}
+}
@@ -31,6 +31,7 @@ const (
 	SecretTypeAPIKeySignatureHMAC          = SecretType("api_key_signature_hmac")
 	SecretTypeCookieKeys                   = SecretType("cookie_keys")
 	SecretTypePhoneNumberDatabaseHMAC      = SecretType("phone_number_database_hmac")
+	SecretTypeSMSSuppressionHMAC           = SecretType("sms_suppression_hmac")
 	SecretTypeVerificationCodeDatabaseHMAC = SecretType("verification_code_database_hmac")
 )
 
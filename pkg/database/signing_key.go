@@ -35,6 +35,15 @@ type SigningKey struct {
 	// Reference to an exact version of a key in the KMS
 	KeyID  string
 	Active bool
+
+	// PublicKey is the PEM-encoded public key, recorded when the key version is
+	// migrated to a different key manager. The key manager that holds a migrated
+	// version is no longer configured, so this is what gets published.
+	PublicKey string `gorm:"column:public_key; type:text;"`
+
+	// DestroyAfter is the time after which a migrated key version is destroyed
+	// in the key manager from which it was migrated.
+	DestroyAfter *time.Time `gorm:"column:destroy_after; type:timestamp with time zone;"`
 }
 
 // AuditID is how the signing key is stored in the audit entry.
@@ -60,6 +69,14 @@ func (s *SigningKey) IsActive() bool {
 	return s.Active
 }
 
+func (s *SigningKey) MigratedPublicKey() string {
+	return s.PublicKey
+}
+
+func (s *SigningKey) GetRealmID() uint {
+	return s.RealmID
+}
+
 func (s *SigningKey) SetRealmID(id uint) {
 	s.RealmID = id
 }
@@ -0,0 +1,236 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package database
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/exposure-notifications-server/pkg/keys"
+	"github.com/google/exposure-notifications-verification-server/pkg/jwthelper"
+	"github.com/google/exposure-notifications-verification-server/pkg/keyutils"
+	"github.com/jinzhu/gorm"
+)
+
+// MigrateSigningKeys moves the realm's certificate signing keys from the source
+// key manager to the database's key manager. See migrateManagedSigningKeys for
+// details.
+func (r *Realm) MigrateSigningKeys(ctx context.Context, db *Database, source keys.KeyManager, gracePeriod time.Duration, actor Auditable) (string, int, error) {
+	algorithm := r.CertificateSigningAlgorithm
+	if algorithm == "" {
+		algorithm = jwthelper.AlgorithmES256
+	}
+	return r.migrateManagedSigningKeys(ctx, db, source, r.certificateSigningKMSKeyName(algorithm), algorithm, &SigningKey{}, gracePeriod, actor)
+}
+
+// MigrateSMSSigningKeys moves the realm's SMS signing keys from the source key
+// manager to the database's key manager. See migrateManagedSigningKeys for
+// details.
+func (r *Realm) MigrateSMSSigningKeys(ctx context.Context, db *Database, source keys.KeyManager, gracePeriod time.Duration, actor Auditable) (string, int, error) {
+	return r.migrateManagedSigningKeys(ctx, db, source, r.smsSigningKMSKeyName(), jwthelper.AlgorithmES256, &SMSSigningKey{}, gracePeriod, actor)
+}
+
+// migrateManagedSigningKeys creates a new signing key version in the
+// database's key manager, makes it the active key, and marks the realm's
+// existing key versions for destruction after the grace period. The public key
+// of each existing version is recorded so it remains published for verifying
+// signatures made before the migration.
+//
+// Key versions that can already be loaded from the database's key manager are
+// not migrated, so it is safe to run this more than once. If there is nothing
+// to migrate, it returns ErrNoSigningKeysToMigrate. It returns the KID of the
+// new active key and the number of key versions that were marked for
+// destruction.
+func (r *Realm) migrateManagedSigningKeys(ctx context.Context, db *Database, source keys.KeyManager, keyID, algorithm string, signingKey RealmManagedKey, gracePeriod time.Duration, actor Auditable) (string, int, error) {
+	manager := db.signingKeyManager
+	if manager == nil {
+		return "", 0, ErrNoSigningKeyManager
+	}
+	if source == nil {
+		return "", 0, fmt.Errorf("missing source key manager")
+	}
+	if gracePeriod <= 0 {
+		return "", 0, fmt.Errorf("grace period must be positive")
+	}
+
+	parent := db.config.KeyRing
+	if parent == "" {
+		return "", 0, fmt.Errorf("missing DB_KEYRING")
+	}
+
+	var existing []*struct {
+		ID    uint
+		KeyID string
+	}
+	if err := db.db.
+		Table(signingKey.Table()).
+		Select("id, key_id").
+		Where("realm_id = ?", r.ID).
+		Where("deleted_at IS NULL").
+		Where("destroy_after IS NULL").
+		Order("id ASC").
+		Scan(&existing).
+		Error; err != nil {
+		if !IsNotFound(err) {
+			return "", 0, fmt.Errorf("failed to list %s signing keys: %w", signingKey.Purpose(), err)
+		}
+	}
+
+	// Record the public key of each version that is still held by the source key
+	// manager.
+	publicKeys := make(map[uint]string, len(existing))
+	for _, k := range existing {
+		if _, err := db.keyManager.NewSigner(ctx, k.KeyID); err == nil {
+			continue
+		}
+
+		signer, err := source.NewSigner(ctx, k.KeyID)
+		if err != nil {
+			return "", 0, fmt.Errorf("failed to load %s signing key %s from source key manager: %w", signingKey.Purpose(), k.KeyID, err)
+		}
+		pem, err := keyutils.EncodePublicKey(signer.Public())
+		if err != nil {
+			return "", 0, fmt.Errorf("failed to encode %s signing key %s: %w", signingKey.Purpose(), k.KeyID, err)
+		}
+		publicKeys[k.ID] = pem
+	}
+	if len(publicKeys) == 0 {
+		return "", 0, ErrNoSigningKeysToMigrate
+	}
+
+	version, err := createSigningKeyVersion(ctx, manager, parent, keyID, algorithm)
+	if err != nil {
+		return "", 0, err
+	}
+
+	db.logger.Debugw("provisioned migrated signing key for realm",
+		"realm_id", r.ID,
+		"purpose", signingKey.Purpose(),
+		"key_id", version)
+
+	destroyAfter := time.Now().UTC().Add(gracePeriod)
+	if err := db.db.Transaction(func(tx *gorm.DB) error {
+		for id, pem := range publicKeys {
+			if err := tx.
+				Table(signingKey.Table()).
+				Where("id = ?", id).
+				Where("realm_id = ?", r.ID).
+				Update(map[string]interface{}{
+					"active":        false,
+					"public_key":    pem,
+					"destroy_after": destroyAfter,
+					"updated_at":    time.Now().UTC(),
+				}).
+				Error; err != nil {
+				return fmt.Errorf("failed to mark %s signing key for destruction: %w", signingKey.Purpose(), err)
+			}
+		}
+
+		// Any other keys are already in the new key manager, but only the new key
+		// may be active.
+		if err := tx.
+			Table(signingKey.Table()).
+			Where("realm_id = ?", r.ID).
+			Where("deleted_at IS NULL").
+			Update(map[string]interface{}{"active": false, "updated_at": time.Now().UTC()}).
+			Error; err != nil {
+			return fmt.Errorf("failed to mark existing %s keys as inactive: %w", signingKey.Purpose(), err)
+		}
+
+		signingKey.SetRealmID(r.ID)
+		signingKey.SetManagedKeyID(version)
+		signingKey.SetActive(true)
+		if err := tx.Save(signingKey).Error; err != nil {
+			return fmt.Errorf("failed to save reference to %s signing key: %w", signingKey.Purpose(), err)
+		}
+
+		audit := BuildAuditEntry(actor, "migrated signing keys to new key manager", signingKey, r.ID)
+		if err := tx.Save(audit).Error; err != nil {
+			return fmt.Errorf("failed to save audits: %w", err)
+		}
+
+		return nil
+	}); err != nil {
+		return "", 0, err
+	}
+
+	return signingKey.GetKID(), len(publicKeys), nil
+}
+
+// DestroyMigratedSigningKeys destroys the certificate and SMS signing key
+// versions whose migration grace period has passed. The versions are destroyed
+// in the source key manager from which they were migrated, and then deleted. It
+// returns the number of key versions destroyed.
+func (db *Database) DestroyMigratedSigningKeys(ctx context.Context, source keys.KeyVersionDestroyer, actor Auditable) (int64, error) {
+	if source == nil {
+		return 0, fmt.Errorf("missing source key manager")
+	}
+
+	var destroyed int64
+	for _, newKey := range []func() RealmManagedKey{
+		func() RealmManagedKey { return &SigningKey{} },
+		func() RealmManagedKey { return &SMSSigningKey{} },
+	} {
+		table := newKey()
+
+		var ids []uint
+		if err := db.db.
+			Table(table.Table()).
+			Where("deleted_at IS NULL").
+			Where("destroy_after IS NOT NULL AND destroy_after < ?", time.Now().UTC()).
+			Order("id ASC").
+			Pluck("id", &ids).
+			Error; err != nil {
+			return destroyed, fmt.Errorf("failed to list migrated %s signing keys: %w", table.Purpose(), err)
+		}
+
+		for _, id := range ids {
+			signingKey := newKey()
+			if err := db.db.Transaction(func(tx *gorm.DB) error {
+				if err := tx.
+					Set("gorm:query_option", "FOR UPDATE").
+					Table(signingKey.Table()).
+					Where("id = ?", id).
+					First(signingKey).
+					Error; err != nil {
+					return fmt.Errorf("failed to load %s signing key: %w", signingKey.Purpose(), err)
+				}
+
+				// Destroy in the key manager inside the transaction so, if it fails, the
+				// record is kept and the next run tries again.
+				if err := source.DestroyKeyVersion(ctx, signingKey.ManagedKeyID()); err != nil {
+					return fmt.Errorf("failed to destroy %s signing key in source key manager: %w", signingKey.Purpose(), err)
+				}
+
+				if err := tx.Delete(signingKey).Error; err != nil {
+					return fmt.Errorf("successfully destroyed %s signing key in source key manager, "+
+						"but failed to delete signing key from database: %w", signingKey.Purpose(), err)
+				}
+
+				audit := BuildAuditEntry(actor, "destroyed migrated signing key", signingKey, signingKey.GetRealmID())
+				if err := tx.Save(audit).Error; err != nil {
+					return fmt.Errorf("failed to save audits: %w", err)
+				}
+				return nil
+			}); err != nil {
+				return destroyed, err
+			}
+			destroyed++
+		}
+	}
+
+	return destroyed, nil
+}
@@ -0,0 +1,149 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package database
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/exposure-notifications-server/pkg/keys"
+	"github.com/google/exposure-notifications-verification-server/internal/project"
+)
+
+func TestRealm_MigrateSigningKeys(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	db, _ := testDatabaseInstance.NewDatabase(t, nil)
+
+	db.config.KeyRing = filepath.Join(project.Root(), "local", "test", "realm")
+
+	realm := NewRealmWithDefaults("realm1")
+	if err := db.SaveRealm(realm, SystemTest); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if _, err := realm.CreateSigningKeyVersion(ctx, db, SystemTest); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Switch the database to a new key manager, keeping the old one as the
+	// source.
+	source := db.keyManager
+	target, err := keys.NewFilesystem(ctx, &keys.Config{
+		Type:           "FILESYSTEM",
+		FilesystemRoot: t.TempDir(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.keyManager = target
+	db.signingKeyManager = target.(keys.SigningKeyManager)
+
+	// There are no SMS keys to migrate.
+	if _, _, err := realm.MigrateSMSSigningKeys(ctx, db, source, time.Hour, SystemTest); !errors.Is(err, ErrNoSigningKeysToMigrate) {
+		t.Errorf("expected %v to be %v", err, ErrNoSigningKeysToMigrate)
+	}
+
+	kid, migrated, err := realm.MigrateSigningKeys(ctx, db, source, time.Hour, SystemTest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := migrated, 2; got != want {
+		t.Errorf("expected %d to be %d", got, want)
+	}
+
+	// The new key is active and held by the new key manager.
+	current, err := realm.CurrentSigningKey(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := current.GetKID(), kid; got != want {
+		t.Errorf("expected %q to be %q", got, want)
+	}
+	if _, err := target.NewSigner(ctx, current.KeyID); err != nil {
+		t.Errorf("expected active key to be in the new key manager: %s", err)
+	}
+
+	// The old keys keep their public key until they are destroyed.
+	list, err := realm.ListSigningKeys(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(list), 3; got != want {
+		t.Fatalf("expected %d keys, got %d", want, got)
+	}
+	for _, k := range list {
+		if k.ID == current.ID {
+			continue
+		}
+		if k.Active {
+			t.Errorf("expected migrated key %s to be inactive", k.GetKID())
+		}
+		if k.PublicKey == "" {
+			t.Errorf("expected migrated key %s to have a public key", k.GetKID())
+		}
+		if k.DestroyAfter == nil {
+			t.Errorf("expected migrated key %s to be marked for destruction", k.GetKID())
+		}
+
+		if _, err := realm.SetActiveSigningKey(db, k.ID, SystemTest); err == nil {
+			t.Errorf("expected error activating migrated key %s", k.GetKID())
+		}
+	}
+
+	// Running again is a no-op.
+	if _, _, err := realm.MigrateSigningKeys(ctx, db, source, time.Hour, SystemTest); !errors.Is(err, ErrNoSigningKeysToMigrate) {
+		t.Errorf("expected %v to be %v", err, ErrNoSigningKeysToMigrate)
+	}
+
+	// Nothing is destroyed during the grace period.
+	destroyed, err := db.DestroyMigratedSigningKeys(ctx, source.(keys.KeyVersionDestroyer), SystemTest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := destroyed, int64(0); got != want {
+		t.Errorf("expected %d to be %d", got, want)
+	}
+
+	if err := db.RawDB().
+		Model(&SigningKey{}).
+		Where("destroy_after IS NOT NULL").
+		UpdateColumn("destroy_after", time.Now().UTC().Add(-time.Minute)).
+		Error; err != nil {
+		t.Fatal(err)
+	}
+
+	destroyed, err = db.DestroyMigratedSigningKeys(ctx, source.(keys.KeyVersionDestroyer), SystemTest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := destroyed, int64(2); got != want {
+		t.Errorf("expected %d to be %d", got, want)
+	}
+
+	list, err = realm.ListSigningKeys(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(list), 1; got != want {
+		t.Errorf("expected %d keys, got %d", want, got)
+	}
+}
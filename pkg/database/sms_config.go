@@ -47,9 +47,20 @@ type SMSConfig struct {
 
 	// TwilioAuthToken is encrypted/decrypted automatically by callbacks. The
 	// cache fields exist as optimizations.
-	TwilioAuthToken                string `gorm:"text" json:"-"` // ignored by zap's JSON formatter
-	TwilioAuthTokenPlaintextCache  string `gorm:"-"`
-	TwilioAuthTokenCiphertextCache string `gorm:"-"`
+	TwilioAuthToken                string `gorm:"text" json:"-" audit:"redact"` // ignored by zap's JSON formatter
+	TwilioAuthTokenPlaintextCache  string `gorm:"-" audit:"redact"`
+	TwilioAuthTokenCiphertextCache string `gorm:"-" audit:"redact"`
+
+	// MessageBird configuration options. MessageBirdOriginator is the E.164
+	// format telephone number or alphanumeric sender ID from which messages are
+	// sent.
+	MessageBirdOriginator string `gorm:"type:text"`
+
+	// MessageBirdAccessKey is encrypted/decrypted automatically by callbacks. The
+	// cache fields exist as optimizations.
+	MessageBirdAccessKey                string `gorm:"type:text" json:"-" audit:"redact"` // ignored by zap's JSON formatter
+	MessageBirdAccessKeyPlaintextCache  string `gorm:"-" audit:"redact"`
+	MessageBirdAccessKeyCiphertextCache string `gorm:"-" audit:"redact"`
 
 	// IsSystem determines if this is a system-level SMS configuration. There can
 	// only be one system-level SMS configuration.
@@ -57,6 +68,31 @@ type SMSConfig struct {
 }
 
 func (s *SMSConfig) BeforeSave(tx *gorm.DB) error {
+	if s.ProviderType == sms.ProviderTypeNoopInspect && !allowsFakeSMS(tx) {
+		s.AddError("providerType", "sandbox provider is only available when fake SMS is enabled")
+	}
+
+	// MessageBird config is all or nothing
+	if s.ProviderType == sms.ProviderTypeMessageBird {
+		if (s.MessageBirdAccessKey == "") != (s.MessageBirdOriginator == "") {
+			s.AddError("messageBirdAccessKey", "all must be specified or all must be blank")
+			s.AddError("messageBirdOriginator", "all must be specified or all must be blank")
+		}
+
+		if v := s.MessageBirdOriginator; v != "" {
+			switch {
+			case strings.HasPrefix(v, "+"):
+				if !project.AllDigits(v[1:]) {
+					s.AddError("messageBirdOriginator", `an E.164 format phone number should begin with "+" followed by digits`)
+				}
+			case len(v) > 11:
+				s.AddError("messageBirdOriginator", "an alphanumeric sender ID must be at most 11 characters")
+			case !isAlphanumeric(v):
+				s.AddError("messageBirdOriginator", "an alphanumeric sender ID must contain only letters and digits")
+			}
+		}
+	}
+
 	// Twilio config is all or nothing
 	if (s.TwilioAccountSid == "") != (s.TwilioAuthToken == "") {
 		s.AddError("twilioAccountSid", "all must be specified or all must be blank")
@@ -98,6 +134,28 @@ func (s *SMSConfig) BeforeSave(tx *gorm.DB) error {
 	return s.ErrorOrNil()
 }
 
+// isBlank returns true if the provider's credentials are all blank.
+func (s *SMSConfig) isBlank() bool {
+	switch s.ProviderType {
+	case sms.ProviderTypeTwilio:
+		return s.TwilioAccountSid == "" && s.TwilioAuthToken == "" && s.TwilioFromNumber == ""
+	case sms.ProviderTypeMessageBird:
+		return s.MessageBirdAccessKey == "" && s.MessageBirdOriginator == ""
+	default:
+		return false
+	}
+}
+
+// isAlphanumeric returns true if s contains only ASCII letters and digits.
+func isAlphanumeric(s string) bool {
+	for _, ch := range s {
+		if (ch < 'a' || ch > 'z') && (ch < 'A' || ch > 'Z') && (ch < '0' || ch > '9') {
+			return false
+		}
+	}
+	return true
+}
+
 // SystemSMSConfig returns the system SMS config, if one exists
 func (db *Database) SystemSMSConfig() (*SMSConfig, error) {
 	var smsConfig SMSConfig
@@ -113,8 +171,7 @@ func (db *Database) SystemSMSConfig() (*SMSConfig, error) {
 
 // SaveSMSConfig creates or updates an SMS configuration record.
 func (db *Database) SaveSMSConfig(s *SMSConfig) error {
-	if s.ProviderType == sms.ProviderTypeTwilio &&
-		s.TwilioAccountSid == "" && s.TwilioAuthToken == "" && s.TwilioFromNumber == "" {
+	if s.isBlank() {
 		if db.db.NewRecord(s) {
 			// The fields are all blank, do not create the record.
 			return nil
@@ -89,6 +89,52 @@ func TestSMSConfig_Validate(t *testing.T) {
 			},
 			err: "validation failed",
 		},
+		{
+			name: "sandbox without fake sms",
+			smsConfig: &SMSConfig{
+				RealmID:      realm.ID,
+				ProviderType: sms.ProviderTypeNoopInspect,
+			},
+			err: "validation failed",
+		},
+		{
+			name: "messagebird missing access key",
+			smsConfig: &SMSConfig{
+				RealmID:               realm.ID,
+				ProviderType:          sms.ProviderTypeMessageBird,
+				MessageBirdOriginator: "+15005550006",
+			},
+			err: "validation failed",
+		},
+		{
+			name: "messagebird originator too long",
+			smsConfig: &SMSConfig{
+				RealmID:               realm.ID,
+				ProviderType:          sms.ProviderTypeMessageBird,
+				MessageBirdAccessKey:  "abc123",
+				MessageBirdOriginator: "HealthDepartment",
+			},
+			err: "validation failed",
+		},
+		{
+			name: "messagebird originator invalid",
+			smsConfig: &SMSConfig{
+				RealmID:               realm.ID,
+				ProviderType:          sms.ProviderTypeMessageBird,
+				MessageBirdAccessKey:  "abc123",
+				MessageBirdOriginator: "Health-Dept",
+			},
+			err: "validation failed",
+		},
+		{
+			name: "messagebird valid",
+			smsConfig: &SMSConfig{
+				RealmID:               realm.ID,
+				ProviderType:          sms.ProviderTypeMessageBird,
+				MessageBirdAccessKey:  "abc123",
+				MessageBirdOriginator: "HealthDept",
+			},
+		},
 	}
 
 	for _, tc := range cases {
@@ -23,7 +23,6 @@ import (
 	"strconv"
 	"time"
 
-	"github.com/google/exposure-notifications-server/pkg/timeutils"
 	"github.com/google/exposure-notifications-verification-server/internal/icsv"
 	"github.com/google/exposure-notifications-verification-server/internal/project"
 )
@@ -44,7 +43,7 @@ type SMSErrorStat struct {
 // InsertSMSErrorStat inserts a new SMS error stat for the given realm and error
 // code.
 func (db *Database) InsertSMSErrorStat(t time.Time, realmID uint, errorCode string) error {
-	date := timeutils.UTCMidnight(time.Now())
+	date := db.realmStatsMidnight(realmID, t)
 
 	sql := `
 		INSERT INTO sms_error_stats (date, realm_id, error_code, quantity)
@@ -177,14 +176,6 @@ func (s *SMSErrorStats) UnmarshalJSON(b []byte) error {
 // PurgeSMSErrorStats will delete stats that were created longer than
 // maxAge ago.
 func (db *Database) PurgeSMSErrorStats(maxAge time.Duration) (int64, error) {
-	if maxAge > 0 {
-		maxAge = -1 * maxAge
-	}
-	createdBefore := time.Now().UTC().Add(maxAge)
-
-	result := db.db.
-		Unscoped().
-		Where("date < ?", createdBefore).
-		Delete(&SMSErrorStat{})
-	return result.RowsAffected, result.Error
+	return db.purgeWithRealmRetention(&SMSErrorStat{}, "sms_error_stats.date", "sms_error_stats.realm_id",
+		"stats_retention_days", maxAge)
 }
@@ -79,7 +79,8 @@ func (db *Database) FindSMSFromNumber(id interface{}) (*SMSFromNumber, error) {
 
 // CreateOrUpdateSMSFromNumbers takes the list of SMS numbers and creates new
 // records, updates existing records, and deletes records that are not present
-// in the list.
+// in the list. When the value of an existing number changes, a pending
+// SMSFromNumberChange is recorded for each realm that uses it.
 func (db *Database) CreateOrUpdateSMSFromNumbers(numbers []*SMSFromNumber) error {
 	ids := make([]uint, 0, len(numbers))
 
@@ -90,9 +91,24 @@ func (db *Database) CreateOrUpdateSMSFromNumbers(numbers []*SMSFromNumber) error
 					return fmt.Errorf("failed to create %s: %w", number.Label, err)
 				}
 			} else {
+				var existing SMSFromNumber
+				if err := tx.
+					Model(&SMSFromNumber{}).
+					Where("id = ?", number.ID).
+					First(&existing).
+					Error; err != nil && !IsNotFound(err) {
+					return fmt.Errorf("failed to lookup %s: %w", number.Label, err)
+				}
+
 				if err := tx.Model(&SMSFromNumber{}).Update(number).Error; err != nil {
 					return fmt.Errorf("failed to update %s: %w", number.Label, err)
 				}
+
+				if existing.ID != 0 && existing.Value != number.Value {
+					if err := recordSMSFromNumberChange(tx, number.ID, number.Label, existing.Value, number.Value); err != nil {
+						return err
+					}
+				}
 			}
 
 			ids = append(ids, number.ID)
@@ -0,0 +1,130 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package database
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/jinzhu/gorm"
+	"github.com/lib/pq"
+)
+
+// SMSFromNumberChange records that a system SMS from number used by a realm
+// changed, and whether the realm's contacts have been notified.
+type SMSFromNumberChange struct {
+	ID uint `gorm:"primary_key;"`
+
+	// RealmID is the affected realm.
+	RealmID uint `gorm:"column:realm_id; type:integer; not null;"`
+
+	// SMSFromNumberID is the system SMS from number that changed.
+	SMSFromNumberID uint `gorm:"column:sms_from_number_id; type:integer; not null;"`
+
+	// Label is the label of the number at the time of the change.
+	Label string `gorm:"column:label; type:text; not null;"`
+
+	// OldValue and NewValue are the phone numbers before and after the change.
+	OldValue string `gorm:"column:old_value; type:text; not null;"`
+	NewValue string `gorm:"column:new_value; type:text; not null;"`
+
+	// Recipients are the addresses that were sent the notification. It is empty
+	// if the realm had no contacts when the notification was processed.
+	Recipients pq.StringArray `gorm:"column:recipients; type:text[];"`
+
+	// NotifiedAt is when the notification was processed. It is nil while the
+	// notification is pending.
+	NotifiedAt *time.Time `gorm:"column:notified_at; type:timestamp with time zone;"`
+
+	CreatedAt time.Time
+}
+
+// TableName sets the table name.
+func (SMSFromNumberChange) TableName() string {
+	return "sms_from_number_changes"
+}
+
+// recordSMSFromNumberChange records a pending change for every realm that
+// sends SMS from the given system number.
+func recordSMSFromNumberChange(tx *gorm.DB, id uint, label, oldValue, newValue string) error {
+	if err := tx.Exec(`
+		INSERT INTO sms_from_number_changes (realm_id, sms_from_number_id, label, old_value, new_value, created_at)
+		SELECT id, ?, ?, ?, ?, NOW()
+		FROM realms
+		WHERE use_system_sms_config IS TRUE
+			AND sms_from_number_id = ?
+			AND deleted_at IS NULL`,
+		id, label, oldValue, newValue, id).
+		Error; err != nil {
+		return fmt.Errorf("failed to record sms from number change: %w", err)
+	}
+	return nil
+}
+
+// ListPendingSMSFromNumberChanges lists the changes whose realms have not yet
+// been notified, ordered by realm.
+func (db *Database) ListPendingSMSFromNumberChanges() ([]*SMSFromNumberChange, error) {
+	var changes []*SMSFromNumberChange
+	if err := db.db.
+		Model(&SMSFromNumberChange{}).
+		Where("notified_at IS NULL").
+		Order("realm_id ASC, id ASC").
+		Find(&changes).
+		Error; err != nil {
+		if IsNotFound(err) {
+			return changes, nil
+		}
+		return nil, err
+	}
+	return changes, nil
+}
+
+// ListSMSFromNumberChanges lists the most recent changes across all realms,
+// newest first.
+func (db *Database) ListSMSFromNumberChanges(limit int) ([]*SMSFromNumberChange, error) {
+	var changes []*SMSFromNumberChange
+	if err := db.db.
+		Model(&SMSFromNumberChange{}).
+		Order("id DESC").
+		Limit(limit).
+		Find(&changes).
+		Error; err != nil {
+		if IsNotFound(err) {
+			return changes, nil
+		}
+		return nil, err
+	}
+	return changes, nil
+}
+
+// MarkSMSFromNumberChangesNotified marks the changes as notified, recording the
+// addresses that received the notification.
+func (db *Database) MarkSMSFromNumberChangesNotified(ids []uint, recipients []string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	if err := db.db.
+		Model(&SMSFromNumberChange{}).
+		Where("id IN (?)", ids).
+		UpdateColumns(map[string]interface{}{
+			"notified_at": time.Now().UTC(),
+			"recipients":  pq.StringArray(recipients),
+		}).
+		Error; err != nil {
+		return fmt.Errorf("failed to mark sms from number changes notified: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,117 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package database
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSMSFromNumberChanges(t *testing.T) {
+	t.Parallel()
+
+	db, _ := testDatabaseInstance.NewDatabase(t, nil)
+
+	number := &SMSFromNumber{
+		Label: "primary",
+		Value: "+15005550000",
+	}
+	if err := db.CreateOrUpdateSMSFromNumbers([]*SMSFromNumber{number}); err != nil {
+		t.Fatal(err)
+	}
+
+	realm, err := db.FindRealm(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.RawDB().
+		Model(&Realm{}).
+		Where("id = ?", realm.ID).
+		UpdateColumns(map[string]interface{}{
+			"can_use_system_sms_config": true,
+			"use_system_sms_config":     true,
+			"sms_from_number_id":        number.ID,
+		}).
+		Error; err != nil {
+		t.Fatal(err)
+	}
+
+	// Label-only changes do not affect recipients.
+	if err := db.CreateOrUpdateSMSFromNumbers([]*SMSFromNumber{
+		{ID: number.ID, Label: "renamed", Value: "+15005550000"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	pending, err := db.ListPendingSMSFromNumberChanges()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(pending), 0; got != want {
+		t.Fatalf("expected %d pending changes to be %d", got, want)
+	}
+
+	if err := db.CreateOrUpdateSMSFromNumbers([]*SMSFromNumber{
+		{ID: number.ID, Label: "renamed", Value: "+15005550006"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	pending, err = db.ListPendingSMSFromNumberChanges()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(pending), 1; got != want {
+		t.Fatalf("expected %d pending changes to be %d", got, want)
+	}
+
+	change := pending[0]
+	if got, want := change.RealmID, realm.ID; got != want {
+		t.Errorf("expected realm %d to be %d", got, want)
+	}
+	if got, want := change.OldValue, "+15005550000"; got != want {
+		t.Errorf("expected old value %q to be %q", got, want)
+	}
+	if got, want := change.NewValue, "+15005550006"; got != want {
+		t.Errorf("expected new value %q to be %q", got, want)
+	}
+
+	recipients := []string{"contact@example.com"}
+	if err := db.MarkSMSFromNumberChangesNotified([]uint{change.ID}, recipients); err != nil {
+		t.Fatal(err)
+	}
+
+	pending, err = db.ListPendingSMSFromNumberChanges()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(pending), 0; got != want {
+		t.Errorf("expected %d pending changes to be %d", got, want)
+	}
+
+	changes, err := db.ListSMSFromNumberChanges(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(changes), 1; got != want {
+		t.Fatalf("expected %d changes to be %d", got, want)
+	}
+	if changes[0].NotifiedAt == nil {
+		t.Errorf("expected change to be notified")
+	}
+	if got, want := []string(changes[0].Recipients), recipients; !reflect.DeepEqual(got, want) {
+		t.Errorf("expected recipients %q to be %q", got, want)
+	}
+}
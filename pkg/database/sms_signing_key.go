@@ -35,6 +35,15 @@ type SMSSigningKey struct {
 	// Reference to an exact version of a key in the KMS
 	KeyID  string
 	Active bool
+
+	// PublicKey is the PEM-encoded public key, recorded when the key version is
+	// migrated to a different key manager. The key manager that holds a migrated
+	// version is no longer configured, so this is what gets published.
+	PublicKey string `gorm:"column:public_key; type:text;"`
+
+	// DestroyAfter is the time after which a migrated key version is destroyed
+	// in the key manager from which it was migrated.
+	DestroyAfter *time.Time `gorm:"column:destroy_after; type:timestamp with time zone;"`
 }
 
 // FindSMSSigningKey finds an SMS signing key by the provided database id.
@@ -73,6 +82,14 @@ func (s *SMSSigningKey) IsActive() bool {
 	return s.Active
 }
 
+func (s *SMSSigningKey) MigratedPublicKey() string {
+	return s.PublicKey
+}
+
+func (s *SMSSigningKey) GetRealmID() uint {
+	return s.RealmID
+}
+
 func (s *SMSSigningKey) SetRealmID(id uint) {
 	s.RealmID = id
 }
@@ -0,0 +1,170 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package database
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/google/exposure-notifications-server/pkg/timeutils"
+)
+
+// SMSSuppressionSource is where a suppression came from.
+type SMSSuppressionSource string
+
+const (
+	// SMSSuppressionSourceTwilio is an opt-out keyword (e.g. STOP) sent to the
+	// realm's Twilio number.
+	SMSSuppressionSourceTwilio = SMSSuppressionSource("twilio")
+)
+
+// SMSSuppression is a phone number that opted out of SMS messages from a realm.
+// Codes are never sent by SMS to suppressed numbers. The phone number is only
+// stored as an HMAC.
+type SMSSuppression struct {
+	// ID is the primary key.
+	ID uint
+
+	// RealmID is the realm the number opted out of.
+	RealmID uint
+
+	// PhoneHash is the HMAC of the E.164 phone number.
+	PhoneHash string `json:"-" audit:"redact"`
+
+	// Source is where the opt-out came from.
+	Source SMSSuppressionSource
+
+	CreatedAt time.Time
+}
+
+// TableName sets the table name.
+func (SMSSuppression) TableName() string {
+	return "sms_suppressions"
+}
+
+// SuppressSMS adds the phone number to the realm's suppression list. The phone
+// number must be in E.164 format. Suppressing a number that is already
+// suppressed is not an error.
+func (r *Realm) SuppressSMS(db *Database, phoneNumber string, source SMSSuppressionSource) error {
+	keys, err := db.GetSMSSuppressionHMAC()
+	if err != nil {
+		return fmt.Errorf("failed to get sms suppression hmac keys: %w", err)
+	}
+
+	// Check all keys first, so a number suppressed with an older key is not
+	// added twice.
+	suppressed, err := r.isSMSSuppressed(db, keys, phoneNumber)
+	if err != nil {
+		return err
+	}
+	if suppressed {
+		return nil
+	}
+
+	hash, err := initialHMAC(keys, phoneNumber)
+	if err != nil {
+		return fmt.Errorf("failed to generate sms suppression hmac: %w", err)
+	}
+
+	sql := `
+		INSERT INTO sms_suppressions (realm_id, phone_hash, source)
+			VALUES ($1, $2, $3)
+		ON CONFLICT (realm_id, phone_hash) DO NOTHING`
+	if err := db.db.Exec(sql, r.ID, hash, source).Error; err != nil {
+		return fmt.Errorf("failed to suppress sms: %w", err)
+	}
+	return nil
+}
+
+// UnsuppressSMS removes the phone number from the realm's suppression list,
+// for example when the number opts back in. Removing a number that is not
+// suppressed is not an error.
+func (r *Realm) UnsuppressSMS(db *Database, phoneNumber string) error {
+	keys, err := db.GetSMSSuppressionHMAC()
+	if err != nil {
+		return fmt.Errorf("failed to get sms suppression hmac keys: %w", err)
+	}
+
+	hashes, err := allAllowedHMACs(keys, phoneNumber)
+	if err != nil {
+		return fmt.Errorf("failed to generate sms suppression hmacs: %w", err)
+	}
+
+	if err := db.db.
+		Where("realm_id = ?", r.ID).
+		Where("phone_hash IN (?)", hashes).
+		Delete(&SMSSuppression{}).
+		Error; err != nil {
+		return fmt.Errorf("failed to unsuppress sms: %w", err)
+	}
+	return nil
+}
+
+// IsSMSSuppressed returns true if the phone number is on the realm's
+// suppression list. The phone number must be in E.164 format.
+func (r *Realm) IsSMSSuppressed(db *Database, phoneNumber string) (bool, error) {
+	keys, err := db.GetSMSSuppressionHMAC()
+	if err != nil {
+		return false, fmt.Errorf("failed to get sms suppression hmac keys: %w", err)
+	}
+	return r.isSMSSuppressed(db, keys, phoneNumber)
+}
+
+func (r *Realm) isSMSSuppressed(db *Database, keys [][]byte, phoneNumber string) (bool, error) {
+	hashes, err := allAllowedHMACs(keys, phoneNumber)
+	if err != nil {
+		return false, fmt.Errorf("failed to generate sms suppression hmacs: %w", err)
+	}
+
+	var count int64
+	if err := db.db.
+		Model(&SMSSuppression{}).
+		Where("realm_id = ?", r.ID).
+		Where("phone_hash IN (?)", hashes).
+		Count(&count).
+		Error; err != nil {
+		return false, fmt.Errorf("failed to check sms suppression: %w", err)
+	}
+	return count > 0, nil
+}
+
+// CountSMSSuppressions returns the number of phone numbers on the realm's
+// suppression list.
+func (r *Realm) CountSMSSuppressions(db *Database) (int64, error) {
+	var count int64
+	if err := db.db.
+		Model(&SMSSuppression{}).
+		Where("realm_id = ?", r.ID).
+		Count(&count).
+		Error; err != nil {
+		return 0, fmt.Errorf("failed to count sms suppressions: %w", err)
+	}
+	return count, nil
+}
+
+// RecordSMSSuppressed records that a code was not sent to a phone number
+// because it is on the realm's suppression list.
+func (db *Database) RecordSMSSuppressed(realmID uint) error {
+	sql := `
+		INSERT INTO realm_stats(date, realm_id, sms_suppressed)
+			VALUES ($1, $2, 1)
+		ON CONFLICT (date, realm_id) DO UPDATE
+			SET sms_suppressed = realm_stats.sms_suppressed + 1`
+
+	if err := db.db.Exec(sql, timeutils.UTCMidnight(time.Now()), realmID).Error; err != nil {
+		return fmt.Errorf("failed to record sms suppression: %w", err)
+	}
+	return nil
+}
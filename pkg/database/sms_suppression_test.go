@@ -0,0 +1,131 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package database
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/exposure-notifications-server/pkg/timeutils"
+)
+
+func TestRealm_SMSSuppression(t *testing.T) {
+	t.Parallel()
+
+	db, _ := testDatabaseInstance.NewDatabase(t, nil)
+
+	realm := NewRealmWithDefaults("suppressions")
+	if err := db.SaveRealm(realm, SystemTest); err != nil {
+		t.Fatal(err)
+	}
+
+	otherRealm := NewRealmWithDefaults("other")
+	if err := db.SaveRealm(otherRealm, SystemTest); err != nil {
+		t.Fatal(err)
+	}
+
+	const phone = "+12068675309"
+
+	if err := realm.SuppressSMS(db, phone, SMSSuppressionSourceTwilio); err != nil {
+		t.Fatal(err)
+	}
+
+	// Suppressing twice is not an error and does not add a second entry.
+	if err := realm.SuppressSMS(db, phone, SMSSuppressionSourceTwilio); err != nil {
+		t.Fatal(err)
+	}
+
+	count, err := realm.CountSMSSuppressions(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := count, int64(1); got != want {
+		t.Errorf("expected %d to be %d", got, want)
+	}
+
+	suppressed, err := realm.IsSMSSuppressed(db, phone)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !suppressed {
+		t.Errorf("expected %q to be suppressed", phone)
+	}
+
+	// The entry does not store the raw phone number.
+	var entries []*SMSSuppression
+	if err := db.RawDB().Where("realm_id = ?", realm.ID).Find(&entries).Error; err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range entries {
+		if e.PhoneHash == phone {
+			t.Errorf("expected phone hash to not be the phone number")
+		}
+	}
+
+	// Suppressions are per-realm.
+	suppressed, err = otherRealm.IsSMSSuppressed(db, phone)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if suppressed {
+		t.Errorf("expected %q to not be suppressed in other realm", phone)
+	}
+
+	if err := realm.UnsuppressSMS(db, phone); err != nil {
+		t.Fatal(err)
+	}
+
+	// Unsuppressing twice is not an error.
+	if err := realm.UnsuppressSMS(db, phone); err != nil {
+		t.Fatal(err)
+	}
+
+	suppressed, err = realm.IsSMSSuppressed(db, phone)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if suppressed {
+		t.Errorf("expected %q to not be suppressed", phone)
+	}
+}
+
+func TestDatabase_RecordSMSSuppressed(t *testing.T) {
+	t.Parallel()
+
+	db, _ := testDatabaseInstance.NewDatabase(t, nil)
+
+	realm, err := db.FindRealm(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := db.RecordSMSSuppressed(realm.ID); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var stat RealmStat
+	if err := db.RawDB().
+		Where("realm_id = ?", realm.ID).
+		Where("date = ?", timeutils.UTCMidnight(time.Now())).
+		First(&stat).
+		Error; err != nil {
+		t.Fatal(err)
+	}
+	if got, want := stat.SMSSuppressed, uint(2); got != want {
+		t.Errorf("expected %d to be %d", got, want)
+	}
+}
@@ -0,0 +1,93 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package database
+
+import (
+	"time"
+
+	"github.com/google/exposure-notifications-server/pkg/timeutils"
+)
+
+// DefaultStatsTimezone is the stats timezone for realms that have not chosen
+// one.
+const DefaultStatsTimezone = "UTC"
+
+// IsValidStatsTimezone returns true if the given name is an IANA time zone
+// that can be used to bucket realm statistics.
+func IsValidStatsTimezone(name string) bool {
+	// "Local" is accepted by time.LoadLocation, but would make the buckets depend
+	// on the configuration of whichever server recorded the stat.
+	if name == "" || name == "Local" {
+		return false
+	}
+	_, err := time.LoadLocation(name)
+	return err == nil
+}
+
+// statsLocation returns the location for the given stats timezone, falling back
+// to UTC if the name is blank or unknown.
+func statsLocation(name string) *time.Location {
+	if name == "" {
+		return time.UTC
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
+
+// statsMidnight returns the calendar day in loc that contains t. Stats are
+// stored in date columns, so the day is returned as midnight UTC, matching
+// timeutils.UTCMidnight.
+func statsMidnight(loc *time.Location, t time.Time) time.Time {
+	y, m, d := t.In(loc).Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
+
+// StatsLocation returns the location in which the realm's daily statistics are
+// bucketed.
+func (r *Realm) StatsLocation() *time.Location {
+	return statsLocation(r.StatsTimezone)
+}
+
+// StatsMidnight returns the stats day that contains t in the realm's stats
+// timezone.
+func (r *Realm) StatsMidnight(t time.Time) time.Time {
+	return statsMidnight(r.StatsLocation(), t)
+}
+
+// StatsToday returns the current stats day in the realm's stats timezone. The
+// stats for this day are incomplete.
+func (r *Realm) StatsToday() time.Time {
+	return r.StatsMidnight(time.Now())
+}
+
+// realmStatsMidnight returns the stats day that contains t for the realm with
+// the given ID. It is used when recording stats where only the realm ID is
+// available. If the realm cannot be loaded, it falls back to the UTC day so the
+// stat is not lost.
+func (db *Database) realmStatsMidnight(realmID uint, t time.Time) time.Time {
+	var names []string
+	if err := db.db.
+		Model(&Realm{}).
+		Where("id = ?", realmID).
+		Pluck("stats_timezone", &names).
+		Error; err != nil || len(names) == 0 {
+		db.logger.Warnw("failed to lookup realm stats timezone", "realm_id", realmID, "error", err)
+		return timeutils.UTCMidnight(t)
+	}
+	return statsMidnight(statsLocation(names[0]), t)
+}
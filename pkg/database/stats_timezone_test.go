@@ -0,0 +1,133 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package database
+
+import (
+	"testing"
+	"time"
+)
+
+func TestIsValidStatsTimezone(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name string
+		exp  bool
+	}{
+		{name: "", exp: false},
+		{name: "Local", exp: false},
+		{name: "Not/AZone", exp: false},
+		{name: "UTC", exp: true},
+		{name: "America/New_York", exp: true},
+		{name: "Pacific/Auckland", exp: true},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			if got, want := IsValidStatsTimezone(tc.name), tc.exp; got != want {
+				t.Errorf("expected %t to be %t", got, want)
+			}
+		})
+	}
+}
+
+func TestRealm_StatsMidnight(t *testing.T) {
+	t.Parallel()
+
+	// 03:30 UTC is still the previous evening in New York and already the
+	// afternoon in Auckland.
+	now := time.Date(2022, 3, 10, 3, 30, 0, 0, time.UTC)
+
+	cases := []struct {
+		tz  string
+		exp time.Time
+	}{
+		{tz: "", exp: time.Date(2022, 3, 10, 0, 0, 0, 0, time.UTC)},
+		{tz: "UTC", exp: time.Date(2022, 3, 10, 0, 0, 0, 0, time.UTC)},
+		{tz: "America/New_York", exp: time.Date(2022, 3, 9, 0, 0, 0, 0, time.UTC)},
+		{tz: "Pacific/Auckland", exp: time.Date(2022, 3, 10, 0, 0, 0, 0, time.UTC)},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.tz, func(t *testing.T) {
+			t.Parallel()
+
+			realm := &Realm{StatsTimezone: tc.tz}
+			if got, want := realm.StatsMidnight(now), tc.exp; !got.Equal(want) {
+				t.Errorf("expected %s to be %s", got, want)
+			}
+		})
+	}
+}
+
+func TestDatabase_RealmStatsTimezone(t *testing.T) {
+	t.Parallel()
+
+	db, _ := testDatabaseInstance.NewDatabase(t, nil)
+
+	realm, err := db.FindRealm(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := realm.StatsTimezone, DefaultStatsTimezone; got != want {
+		t.Errorf("expected %q to be %q", got, want)
+	}
+
+	realm.StatsTimezone = "Nope/Nope"
+	if err := db.SaveRealm(realm, SystemTest); err == nil {
+		t.Fatal("expected error")
+	}
+	if errs := realm.ErrorsFor("statsTimezone"); len(errs) < 1 {
+		t.Errorf("expected errors for statsTimezone")
+	}
+
+	realm.StatsTimezone = "America/New_York"
+	if err := db.SaveRealm(realm, SystemTest); err != nil {
+		t.Fatal(err)
+	}
+
+	// The change is annotated on the stats so the discontinuity is explained.
+	annotations, err := realm.ListStatsAnnotations(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(annotations), 1; got != want {
+		t.Fatalf("expected %d annotations, got %d", want, got)
+	}
+	if got, want := annotations[0].Message, "Stats timezone changed from UTC to America/New_York"; got != want {
+		t.Errorf("expected %q to be %q", got, want)
+	}
+	if got, want := annotations[0].Date, realm.StatsToday(); !got.Equal(want) {
+		t.Errorf("expected %s to be %s", got, want)
+	}
+
+	// Stats recorded with only the realm ID are bucketed in the realm's
+	// timezone.
+	now := time.Date(2022, 3, 10, 3, 30, 0, 0, time.UTC)
+	if got, want := db.realmStatsMidnight(realm.ID, now), time.Date(2022, 3, 9, 0, 0, 0, 0, time.UTC); !got.Equal(want) {
+		t.Errorf("expected %s to be %s", got, want)
+	}
+
+	// Unknown realms fall back to UTC.
+	if got, want := db.realmStatsMidnight(0, now), time.Date(2022, 3, 10, 0, 0, 0, 0, time.UTC); !got.Equal(want) {
+		t.Errorf("expected %s to be %s", got, want)
+	}
+}
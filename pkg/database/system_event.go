@@ -0,0 +1,209 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package database
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/exposure-notifications-verification-server/internal/project"
+	"github.com/google/exposure-notifications-verification-server/pkg/pagination"
+	"github.com/jinzhu/gorm"
+)
+
+// SystemEventKind is the kind of operational event on the system event
+// timeline.
+type SystemEventKind string
+
+const (
+	// SystemEventKeyRotation is the rotation of a signing key or secret.
+	SystemEventKeyRotation SystemEventKind = "key_rotation"
+
+	// SystemEventBuild is the first time a service started with a build.
+	SystemEventBuild SystemEventKind = "build"
+
+	// SystemEventMaintenance is a realm entering or leaving maintenance mode.
+	SystemEventMaintenance SystemEventKind = "maintenance"
+
+	// SystemEventConfigChange is a change to system-wide configuration, such as
+	// the system SMS or email configuration or a key server.
+	SystemEventConfigChange SystemEventKind = "config_change"
+
+	// SystemEventWorkerRun is the outcome of a scheduled job. Failed runs, and
+	// the first successful run after a failure, are recorded.
+	SystemEventWorkerRun SystemEventKind = "worker_run"
+
+	// SystemEventBreakGlass is a change to a break-glass account activation.
+	SystemEventBreakGlass SystemEventKind = "break_glass"
+)
+
+// SystemEventKinds is the list of all system event kinds.
+var SystemEventKinds = []SystemEventKind{
+	SystemEventKeyRotation,
+	SystemEventBuild,
+	SystemEventMaintenance,
+	SystemEventConfigChange,
+	SystemEventWorkerRun,
+	SystemEventBreakGlass,
+}
+
+// SystemEvent is an operational event, recorded so post-incident reviews can
+// correlate verification failures with what changed in the system at the time.
+type SystemEvent struct {
+	// ID is the event's ID.
+	ID uint `gorm:"primary_key;" json:"id"`
+
+	// Kind is the kind of event.
+	Kind SystemEventKind `gorm:"column:kind; type:text; not null;" json:"kind"`
+
+	// Source is what the event is about, such as the job, service, or key
+	// server name.
+	Source string `gorm:"column:source; type:text; not null;" json:"source"`
+
+	// RealmID is the realm the event applies to, or 0 for the whole system.
+	RealmID uint `gorm:"column:realm_id; type:integer; not null; default:0;" json:"realmID,omitempty"`
+
+	// Message is a human-readable description of the event.
+	Message string `gorm:"column:message; type:text; not null;" json:"message"`
+
+	// Failed is true if the event records a failure.
+	Failed bool `gorm:"column:failed; type:bool; not null; default:false;" json:"failed"`
+
+	// CreatedAt is when the event occurred.
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// TableName sets the table name.
+func (SystemEvent) TableName() string {
+	return "system_events"
+}
+
+// RecordSystemEvent saves the event on the system event timeline.
+func (db *Database) RecordSystemEvent(e *SystemEvent) error {
+	return recordSystemEvent(db.db, e)
+}
+
+// recordSystemEvent saves the event using the given transaction, so events can
+// be recorded atomically with the change they describe.
+func recordSystemEvent(tx *gorm.DB, e *SystemEvent) error {
+	if e == nil {
+		return fmt.Errorf("provided system event is nil")
+	}
+	if err := tx.Create(e).Error; err != nil {
+		return fmt.Errorf("failed to record %s system event: %w", e.Kind, err)
+	}
+	return nil
+}
+
+// configChangeEvent builds the system event for a configuration change made by
+// the actor, summarizing the audited changes.
+func configChangeEvent(source string, actor Auditable, audits []*AuditEntry) *SystemEvent {
+	actions := make([]string, 0, len(audits))
+	for _, audit := range audits {
+		actions = append(actions, audit.Action)
+	}
+
+	return &SystemEvent{
+		Kind:    SystemEventConfigChange,
+		Source:  source,
+		Message: fmt.Sprintf("%s by %s", strings.Join(actions, ", "), actor.AuditDisplay()),
+	}
+}
+
+// RecordBuildSeen records that the named service is running the given build.
+// Only the first time each service reports a build is recorded, so the event
+// marks when the build was deployed.
+func (db *Database) RecordBuildSeen(service, buildID, buildTag string) error {
+	sql := `
+		INSERT INTO system_events (kind, source, message, created_at)
+			VALUES ($1, $2, $3, $4)
+		ON CONFLICT (source, message) WHERE kind = 'build' DO NOTHING
+	`
+
+	msg := fmt.Sprintf("build %s (%s)", buildID, buildTag)
+	now := time.Now().UTC()
+	if err := db.db.Exec(sql, SystemEventBuild, service, msg, now).Error; err != nil {
+		return fmt.Errorf("failed to record build for %s: %w", service, err)
+	}
+	return nil
+}
+
+// ListSystemEvents lists the system events, newest first.
+func (db *Database) ListSystemEvents(p *pagination.PageParams, scopes ...Scope) ([]*SystemEvent, *pagination.Paginator, error) {
+	var events []*SystemEvent
+
+	query := db.db.
+		Model(&SystemEvent{}).
+		Scopes(scopes...).
+		Order("created_at DESC, id DESC")
+
+	if p == nil {
+		p = new(pagination.PageParams)
+	}
+
+	paginator, err := Paginate(query, &events, p.Page, p.Limit)
+	if err != nil {
+		if IsNotFound(err) {
+			return events, nil, nil
+		}
+		return nil, nil, err
+	}
+
+	return events, paginator, nil
+}
+
+// WithSystemEventKind returns a scope that filters system events by kind. A
+// blank kind matches all events.
+func WithSystemEventKind(kind SystemEventKind) Scope {
+	return func(db *gorm.DB) *gorm.DB {
+		if kind == "" {
+			return db
+		}
+		return db.Where("system_events.kind = ?", kind)
+	}
+}
+
+// WithSystemEventTime returns a scope that filters system events to those
+// between from and to, inclusive. Either may be blank.
+func WithSystemEventTime(from, to string) Scope {
+	return func(db *gorm.DB) *gorm.DB {
+		from = project.TrimSpace(from)
+		if from != "" {
+			db = db.Where("system_events.created_at >= ?", from)
+		}
+
+		to = project.TrimSpace(to)
+		if to != "" {
+			db = db.Where("system_events.created_at <= ?", to)
+		}
+		return db
+	}
+}
+
+// PurgeSystemEvents will delete system events which were created longer than
+// maxAge ago.
+func (db *Database) PurgeSystemEvents(maxAge time.Duration) (int64, error) {
+	if maxAge > 0 {
+		maxAge = -1 * maxAge
+	}
+	createdBefore := time.Now().UTC().Add(maxAge)
+
+	result := db.db.
+		Unscoped().
+		Where("created_at < ?", createdBefore).
+		Delete(&SystemEvent{})
+	return result.RowsAffected, result.Error
+}
@@ -0,0 +1,125 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package database
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDatabase_SystemEvents(t *testing.T) {
+	t.Parallel()
+
+	db, _ := testDatabaseInstance.NewDatabase(t, nil)
+
+	if err := db.RecordSystemEvent(&SystemEvent{
+		Kind:    SystemEventWorkerRun,
+		Source:  "cleanup",
+		Message: "failed: oops",
+		Failed:  true,
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Only the first time a service reports a build is recorded.
+	for i := 0; i < 2; i++ {
+		if err := db.RecordBuildSeen("server", "abc123", "v1.2.3"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := db.RecordBuildSeen("apiserver", "abc123", "v1.2.3"); err != nil {
+		t.Fatal(err)
+	}
+
+	events, _, err := db.ListSystemEvents(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(events), 3; got != want {
+		t.Fatalf("expected %d events, got %d: %#v", want, got, events)
+	}
+
+	// Newest first.
+	if got, want := events[0].Source, "apiserver"; got != want {
+		t.Errorf("expected %q to be %q", got, want)
+	}
+
+	builds, _, err := db.ListSystemEvents(nil, WithSystemEventKind(SystemEventBuild))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(builds), 2; got != want {
+		t.Errorf("expected %d builds, got %d", want, got)
+	}
+	if got, want := builds[0].Message, "build abc123 (v1.2.3)"; got != want {
+		t.Errorf("expected %q to be %q", got, want)
+	}
+
+	future := time.Now().UTC().Add(time.Hour).Format(time.RFC3339)
+	none, _, err := db.ListSystemEvents(nil, WithSystemEventTime(future, ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(none), 0; got != want {
+		t.Errorf("expected %d events, got %d", want, got)
+	}
+
+	// Events newer than the max age are kept.
+	n, err := db.PurgeSystemEvents(time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := n, int64(0); got != want {
+		t.Errorf("expected %d to purge, got %d", want, got)
+	}
+
+	n, err = db.PurgeSystemEvents(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := n, int64(3); got != want {
+		t.Errorf("expected %d to purge, got %d", want, got)
+	}
+}
+
+func TestDatabase_SaveRealm_maintenanceEvent(t *testing.T) {
+	t.Parallel()
+
+	db, _ := testDatabaseInstance.NewDatabase(t, nil)
+
+	realm := NewRealmWithDefaults("maintenance")
+	if err := db.SaveRealm(realm, SystemTest); err != nil {
+		t.Fatal(err)
+	}
+
+	realm.MaintenanceMode = true
+	if err := db.SaveRealm(realm, SystemTest); err != nil {
+		t.Fatal(err)
+	}
+
+	events, _, err := db.ListSystemEvents(nil, WithSystemEventKind(SystemEventMaintenance))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(events), 1; got != want {
+		t.Fatalf("expected %d events, got %d", want, got)
+	}
+	if got, want := events[0].RealmID, realm.ID; got != want {
+		t.Errorf("expected %d to be %d", got, want)
+	}
+	if got, want := events[0].Message, "entered maintenance mode by SystemTest"; got != want {
+		t.Errorf("expected %q to be %q", got, want)
+	}
+}
@@ -22,7 +22,6 @@ import (
 	"strings"
 	"time"
 
-	"github.com/google/exposure-notifications-server/pkg/timeutils"
 	"github.com/google/exposure-notifications-verification-server/internal/project"
 	"github.com/google/exposure-notifications-verification-server/pkg/api"
 	"github.com/jinzhu/gorm"
@@ -41,6 +40,10 @@ var (
 	ErrTokenUsed                = errors.New("verification token used")
 	ErrTokenMetadataMismatch    = errors.New("verification token test metadata mismatch")
 	ErrUnsupportedTestType      = errors.New("verification code has unsupported test type")
+
+	// ErrVerificationCodeNonceMismatch wraps ErrVerificationCodeNotFound so a
+	// mismatched nonce is presented to callers the same as an unknown code.
+	ErrVerificationCodeNonceMismatch = fmt.Errorf("%w: nonce mismatch", ErrVerificationCodeNotFound)
 )
 
 // Token represents an issued "long term" from a validated verification code.
@@ -141,12 +144,23 @@ func (t *Token) Subject() *Subject {
 // ClaimToken looks up the token by ID, verifies that it is not expired and that
 // the specified subject matches the parameters that were configured when issued.
 func (db *Database) ClaimToken(t time.Time, authApp *AuthorizedApp, tokenID string, subject *Subject) error {
+	return db.claimToken(t, authApp, tokenID, subject, false)
+}
+
+// CheckToken performs the same checks as ClaimToken, but does not claim the
+// token or record statistics. It does not lock the token, so it never blocks a
+// concurrent claim. It is used by shadow deployments.
+func (db *Database) CheckToken(t time.Time, authApp *AuthorizedApp, tokenID string, subject *Subject) error {
+	return db.claimToken(t, authApp, tokenID, subject, true)
+}
+
+func (db *Database) claimToken(t time.Time, authApp *AuthorizedApp, tokenID string, subject *Subject, dryRun bool) error {
 	t = t.UTC()
 
 	var tok Token
 	if err := db.db.Transaction(func(tx *gorm.DB) error {
 		if err := tx.
-			Set("gorm:query_option", "FOR UPDATE").
+			Set("gorm:query_option", lockOption(dryRun)).
 			Where("token_id = ?", tokenID).
 			Where("realm_id = ?", authApp.RealmID).
 			First(&tok).
@@ -185,6 +199,10 @@ func (db *Database) ClaimToken(t time.Time, authApp *AuthorizedApp, tokenID stri
 			return ErrTokenMetadataMismatch
 		}
 
+		if dryRun {
+			return nil
+		}
+
 		// Save token.
 		tok.Used = true
 		if err := tx.Save(&tok).Error; err != nil {
@@ -193,13 +211,15 @@ func (db *Database) ClaimToken(t time.Time, authApp *AuthorizedApp, tokenID stri
 
 		return nil
 	}); err != nil {
-		if !errors.Is(err, ErrTokenUsed) {
+		if !dryRun && !errors.Is(err, ErrTokenUsed) {
 			go db.updateStatsTokenInvalid(t, authApp)
 		}
 		return err
 	}
 
-	go db.updateStatsTokenClaimed(t, authApp, &tok)
+	if !dryRun {
+		go db.updateStatsTokenClaimed(t, authApp, &tok)
+	}
 	return nil
 }
 
@@ -213,6 +233,11 @@ type IssueTokenRequest struct {
 	AcceptTypes api.AcceptTypes
 	ExpireAfter time.Duration
 	OS          OSType
+
+	// DryRun checks the code without claiming it or recording statistics. The
+	// returned token is not saved, so it cannot be used for a certificate. It is
+	// used by shadow deployments.
+	DryRun bool
 }
 
 // VerifyCodeAndIssueToken takes a previously issued verification code and exchanges
@@ -238,7 +263,7 @@ func (db *Database) VerifyCodeAndIssueToken(request *IssueTokenRequest) (*Token,
 		// Load the verification code - do quick expiry and claim checks.
 		// Also lock the row for update.
 		if err := tx.
-			Set("gorm:query_option", "FOR UPDATE").
+			Set("gorm:query_option", lockOption(request.DryRun)).
 			Where("realm_id = ?", request.AuthApp.RealmID).
 			Where("(code IN (?) OR long_code IN (?))", hmacedCodes, hmacedCodes).
 			First(&vc).
@@ -275,7 +300,7 @@ func (db *Database) VerifyCodeAndIssueToken(request *IssueTokenRequest) (*Token,
 
 			var ur UserReport
 			if err := tx.
-				Set("gorm:query_option", "FOR UPDATE").
+				Set("gorm:query_option", lockOption(request.DryRun)).
 				Where("id = ?", *vc.UserReportID).
 				First(&ur).
 				Error; err != nil {
@@ -303,6 +328,9 @@ func (db *Database) VerifyCodeAndIssueToken(request *IssueTokenRequest) (*Token,
 				}
 			} else {
 				db.logger.Debugw("unable to satisfy nonce requirements", "nonce-required", ur.NonceRequired, "nonce-mismatch", nonceMismatch)
+				if badNonce {
+					return ErrVerificationCodeNonceMismatch
+				}
 				return ErrVerificationCodeNotFound
 			}
 		}
@@ -332,20 +360,39 @@ func (db *Database) VerifyCodeAndIssueToken(request *IssueTokenRequest) (*Token,
 			RealmID:     request.AuthApp.RealmID,
 		}
 
+		if request.DryRun {
+			return errDryRun
+		}
 		return tx.Create(tok).Error
-	}); err != nil {
-		if !errors.Is(err, ErrVerificationCodeUsed) {
+	}); err != nil && !errors.Is(err, errDryRun) {
+		if !request.DryRun && !errors.Is(err, ErrVerificationCodeUsed) {
 			go db.updateStatsCodeInvalid(t, request.AuthApp, request.OS, badNonce)
 		}
 		db.logger.Debugw("unable to process verification code", "error", err)
 		return nil, err
 	}
 
+	if request.DryRun {
+		return tok, nil
+	}
+
 	go db.updateStatsCodeClaimed(t, request.AuthApp)
 	go db.updateStatsAgeDistrib(t, request.AuthApp, &vc)
 	return tok, nil
 }
 
+// errDryRun rolls back a dry-run transaction after all checks pass.
+var errDryRun = errors.New("dry run")
+
+// lockOption returns the query option to lock rows that are about to be
+// claimed. Dry runs never claim rows, so they do not lock them either.
+func lockOption(dryRun bool) string {
+	if dryRun {
+		return ""
+	}
+	return "FOR UPDATE"
+}
+
 func (db *Database) FindTokenByID(tokenID string) (*Token, error) {
 	var token Token
 	if err := db.db.
@@ -373,7 +420,7 @@ func (db *Database) PurgeTokens(maxAge time.Duration) (int64, error) {
 // updateStatsCodeInvalid updates the statistics, increasing the number of codes
 // that were invalid.
 func (db *Database) updateStatsCodeInvalid(t time.Time, authApp *AuthorizedApp, os OSType, badNonce bool) {
-	t = timeutils.UTCMidnight(t)
+	t = db.realmStatsMidnight(authApp.RealmID, t)
 
 	if err := db.db.Transaction(func(tx *gorm.DB) error {
 		var existing RealmStat
@@ -441,7 +488,7 @@ func (db *Database) updateStatsCodeInvalid(t time.Time, authApp *AuthorizedApp,
 // updateStatsAgeDistrib updates the statistics, increasing the number of codes
 // claimed and the distribution of issue-claim time.
 func (db *Database) updateStatsAgeDistrib(t time.Time, authApp *AuthorizedApp, vc *VerificationCode) {
-	midnight := timeutils.UTCMidnight(t)
+	midnight := db.realmStatsMidnight(authApp.RealmID, t)
 
 	if err := db.db.Transaction(func(tx *gorm.DB) error {
 		var existing RealmStat
@@ -506,7 +553,7 @@ func (db *Database) updateStatsAgeDistrib(t time.Time, authApp *AuthorizedApp, v
 // updateStatsCodeClaimed updates the statistics, increasing the number of codes
 // claimed.
 func (db *Database) updateStatsCodeClaimed(t time.Time, authApp *AuthorizedApp) {
-	midnight := timeutils.UTCMidnight(t)
+	midnight := db.realmStatsMidnight(authApp.RealmID, t)
 	authAppSQL := `
 			INSERT INTO authorized_app_stats(date, authorized_app_id, codes_claimed)
 				VALUES ($1, $2, 1)
@@ -521,7 +568,7 @@ func (db *Database) updateStatsCodeClaimed(t time.Time, authApp *AuthorizedApp)
 // updateStatsTokenInvalid updates the statistics, increasing the number of
 // tokens that were invalid.
 func (db *Database) updateStatsTokenInvalid(t time.Time, authApp *AuthorizedApp) {
-	t = timeutils.UTCMidnight(t)
+	t = db.realmStatsMidnight(authApp.RealmID, t)
 
 	realmSQL := `
 			INSERT INTO realm_stats(date, realm_id, tokens_invalid)
@@ -547,7 +594,7 @@ func (db *Database) updateStatsTokenInvalid(t time.Time, authApp *AuthorizedApp)
 // updateStatsTokenClaimed updates the statistics, increasing the number of
 // tokens claimed.
 func (db *Database) updateStatsTokenClaimed(t time.Time, authApp *AuthorizedApp, tok *Token) {
-	t = timeutils.UTCMidnight(t)
+	t = db.realmStatsMidnight(authApp.RealmID, t)
 
 	realmSQL := `
 			INSERT INTO realm_stats(date, realm_id, tokens_claimed, user_report_tokens_claimed)
@@ -15,6 +15,7 @@
 package database
 
 import (
+	"errors"
 	"reflect"
 	"strings"
 	"testing"
@@ -636,3 +637,87 @@ func TestPurgeTokens(t *testing.T) {
 		t.Fatalf("purge record count mismatch, want: 2, got: %v", count)
 	}
 }
+
+func TestIssueToken_DryRun(t *testing.T) {
+	t.Parallel()
+
+	db, _ := testDatabaseInstance.NewDatabase(t, nil)
+
+	realm, err := db.FindRealm(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	authApp := &AuthorizedApp{
+		RealmID: realm.ID,
+		Name:    "Appy",
+	}
+	if _, err := realm.CreateAuthorizedApp(db, authApp, SystemTest); err != nil {
+		t.Fatal(err)
+	}
+
+	symptomDate := timeutils.UTCMidnight(time.Now())
+	verification := &VerificationCode{
+		Code:          "12345678",
+		LongCode:      "12345678ABC",
+		TestType:      "confirmed",
+		SymptomDate:   &symptomDate,
+		ExpiresAt:     time.Now().Add(time.Hour),
+		LongExpiresAt: time.Now().Add(time.Hour),
+	}
+	code := verification.Code
+	if err := realm.SaveVerificationCode(db, verification); err != nil {
+		t.Fatal(err)
+	}
+
+	request := &IssueTokenRequest{
+		Time:        time.Now(),
+		AuthApp:     authApp,
+		VerCode:     code,
+		AcceptTypes: api.AcceptTypes{api.TestTypeConfirmed: struct{}{}},
+		ExpireAfter: time.Hour,
+		DryRun:      true,
+	}
+
+	// A dry run does not save the token or claim the code, so it can be
+	// repeated.
+	for i := 0; i < 2; i++ {
+		tok, err := db.VerifyCodeAndIssueToken(request)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := db.FindTokenByID(tok.TokenID); !IsNotFound(err) {
+			t.Errorf("expected dry run token to not be saved, got %v", err)
+		}
+	}
+
+	request.DryRun = false
+	tok, err := db.VerifyCodeAndIssueToken(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Checking the token does not claim it.
+	subject := &Subject{TestType: verification.TestType, SymptomDate: verification.SymptomDate}
+	if err := db.CheckToken(time.Now(), authApp, tok.TokenID, subject); err != nil {
+		t.Fatal(err)
+	}
+	got, err := db.FindTokenByID(tok.TokenID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Used {
+		t.Errorf("expected checked token to not be used")
+	}
+
+	if err := db.ClaimToken(time.Now(), authApp, tok.TokenID, subject); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.CheckToken(time.Now(), authApp, tok.TokenID, subject); !errors.Is(err, ErrTokenUsed) {
+		t.Errorf("expected %v to be %v", err, ErrTokenUsed)
+	}
+
+	// There are deferred go funcs to update stats. Give some time for those to
+	// complete.
+	time.Sleep(500 * time.Millisecond)
+}
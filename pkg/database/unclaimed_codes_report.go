@@ -0,0 +1,63 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package database
+
+import (
+	"fmt"
+	"time"
+
+	verifyapi "github.com/google/exposure-notifications-server/pkg/api/v1"
+	"github.com/lib/pq"
+)
+
+// UnclaimedCodesReportTestTypes are the test types included in unclaimed codes
+// reports. Only positive results are reported, since those are the patients
+// case investigators need to follow up with.
+var UnclaimedCodesReportTestTypes = []string{
+	verifyapi.ReportTypeConfirmed,
+	verifyapi.ReportTypeClinical,
+}
+
+// ListUnclaimedCodesForReport lists the unclaimed codes created since the given
+// time that were issued by a user or API key that opted in to unclaimed codes
+// reports, oldest first. Disabled API keys are not included. The code and long
+// code are always cleared, only metadata is returned.
+func (db *Database) ListUnclaimedCodesForReport(since time.Time) ([]*VerificationCode, error) {
+	var codes []*VerificationCode
+	if err := db.db.
+		Model(&VerificationCode{}).
+		Where("claimed = ?", false).
+		Where("created_at >= ?", since).
+		Where("test_type = ANY (?)", pq.Array(UnclaimedCodesReportTestTypes)).
+		Where(`
+			issuing_user_id IN (SELECT id FROM users WHERE unclaimed_codes_report IS TRUE AND deleted_at IS NULL) OR
+			issuing_app_id IN (SELECT id FROM authorized_apps WHERE unclaimed_codes_report IS TRUE AND deleted_at IS NULL)`).
+		Order("created_at ASC, id ASC").
+		Find(&codes).
+		Error; err != nil {
+		if IsNotFound(err) {
+			return codes, nil
+		}
+		return nil, fmt.Errorf("failed to list unclaimed codes: %w", err)
+	}
+
+	// Never return the codes themselves, only metadata.
+	for _, t := range codes {
+		t.Code = ""
+		t.LongCode = ""
+	}
+
+	return codes, nil
+}
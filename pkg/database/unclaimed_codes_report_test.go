@@ -0,0 +1,108 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package database
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestDatabase_ListUnclaimedCodesForReport(t *testing.T) {
+	t.Parallel()
+
+	db, _ := testDatabaseInstance.NewDatabase(t, nil)
+
+	realm := NewRealmWithDefaults("unclaimed")
+	if err := db.SaveRealm(realm, SystemTest); err != nil {
+		t.Fatal(err)
+	}
+
+	optedIn := &User{Email: "opted-in@example.com", Name: "Opted in", UnclaimedCodesReport: true}
+	if err := db.SaveUser(optedIn, SystemTest); err != nil {
+		t.Fatal(err)
+	}
+	optedOut := &User{Email: "opted-out@example.com", Name: "Opted out"}
+	if err := db.SaveUser(optedOut, SystemTest); err != nil {
+		t.Fatal(err)
+	}
+
+	app := &AuthorizedApp{
+		Name:                 "Reported",
+		APIKeyType:           APIKeyTypeAdmin,
+		CallbackURL:          "https://example.com/callback",
+		CallbackSecret:       "super-secret-value",
+		UnclaimedCodesReport: true,
+	}
+	if _, err := realm.CreateAuthorizedApp(db, app, SystemTest); err != nil {
+		t.Fatal(err)
+	}
+
+	var n int
+	createCode := func(tb testing.TB, userID, appID uint, testType string, claimed bool) *VerificationCode {
+		tb.Helper()
+
+		n++
+		vc := &VerificationCode{
+			RealmID:       realm.ID,
+			Code:          fmt.Sprintf("%08d", n),
+			LongCode:      fmt.Sprintf("%016d", n),
+			TestType:      testType,
+			Claimed:       claimed,
+			IssuingUserID: userID,
+			IssuingAppID:  appID,
+			ExpiresAt:     time.Now().Add(time.Hour),
+			LongExpiresAt: time.Now().Add(2 * time.Hour),
+		}
+		if err := realm.SaveVerificationCode(db, vc); err != nil {
+			tb.Fatal(err)
+		}
+		return vc
+	}
+
+	fromUser := createCode(t, optedIn.ID, 0, "confirmed", false)
+	createCode(t, optedIn.ID, 0, "confirmed", true)
+	createCode(t, optedIn.ID, 0, "negative", false)
+	createCode(t, optedOut.ID, 0, "confirmed", false)
+	fromApp := createCode(t, 0, app.ID, "likely", false)
+
+	old := createCode(t, optedIn.ID, 0, "confirmed", false)
+	if err := db.db.
+		Model(old).
+		UpdateColumn("created_at", time.Now().Add(-72*time.Hour)).
+		Error; err != nil {
+		t.Fatal(err)
+	}
+
+	codes, err := db.ListUnclaimedCodesForReport(time.Now().Add(-24 * time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := len(codes), 2; got != want {
+		t.Fatalf("expected %d codes to be %d: %#v", got, want, codes)
+	}
+	if got, want := codes[0].ID, fromUser.ID; got != want {
+		t.Errorf("expected first code %d to be %d", got, want)
+	}
+	if got, want := codes[1].ID, fromApp.ID; got != want {
+		t.Errorf("expected second code %d to be %d", got, want)
+	}
+	for _, code := range codes {
+		if code.Code != "" || code.LongCode != "" {
+			t.Errorf("expected codes to be cleared, got %q and %q", code.Code, code.LongCode)
+		}
+	}
+}
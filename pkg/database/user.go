@@ -20,12 +20,12 @@ import (
 	"strings"
 	"time"
 
-	"github.com/google/exposure-notifications-server/pkg/timeutils"
 	"github.com/google/exposure-notifications-verification-server/internal/project"
 	"github.com/google/exposure-notifications-verification-server/pkg/cache"
 	"github.com/google/exposure-notifications-verification-server/pkg/pagination"
 	"github.com/google/exposure-notifications-verification-server/pkg/rbac"
 	"github.com/jinzhu/gorm"
+	"golang.org/x/text/language"
 )
 
 const minDuration = -1 << 63
@@ -47,6 +47,27 @@ type User struct {
 
 	LastRevokeCheck    time.Time
 	LastPasswordChange time.Time
+
+	// DefaultRealmID is the realm the user prefers to be signed into when they
+	// are a member of multiple realms. It is nil if the user has not chosen a
+	// default realm.
+	DefaultRealmID *uint `gorm:"column:default_realm_id; type:integer;"`
+
+	// PreferredLanguage is the language (e.g. "es" or "es-MX") in which the user
+	// prefers to receive email. It chooses among the realm's localized email
+	// templates. If blank, the realm's default templates are used.
+	PreferredLanguage string `gorm:"column:preferred_language; type:text;"`
+
+	// UnclaimedCodesReport opts the user in to a daily email listing the codes
+	// they issued that have not been claimed.
+	UnclaimedCodesReport bool `gorm:"column:unclaimed_codes_report; type:bool; not null; default:false;"`
+
+	// BreakGlass designates an emergency account that is only a system admin
+	// while a break-glass activation, approved by two other system admins, is
+	// in effect. BreakGlassExpiresAt is when the current activation ends. It is
+	// nil if the account is not activated.
+	BreakGlass          bool       `gorm:"column:break_glass; type:bool; not null; default:false;"`
+	BreakGlassExpiresAt *time.Time `gorm:"column:break_glass_expires_at; type:timestamp with time zone;"`
 }
 
 // BeforeSave runs validations. If there are errors, the save fails.
@@ -63,9 +84,39 @@ func (u *User) BeforeSave(tx *gorm.DB) error {
 		u.AddError("name", "cannot be blank")
 	}
 
+	u.PreferredLanguage = project.TrimSpace(u.PreferredLanguage)
+	if u.PreferredLanguage != "" {
+		tag, err := language.Parse(u.PreferredLanguage)
+		if err != nil {
+			u.AddError("preferredLanguage", "must be a language like en or es-MX")
+		} else {
+			u.PreferredLanguage = tag.String()
+		}
+	}
+
+	if u.BreakGlass && u.SystemAdmin {
+		u.AddError("systemAdmin", "cannot be set on break-glass accounts")
+	}
+
 	return u.ErrorOrNil()
 }
 
+// IsSystemAdmin returns true if the user is a system admin, or is a break-glass
+// account with an activation in effect. Use this instead of SystemAdmin to
+// authorize system admin actions.
+func (u *User) IsSystemAdmin() bool {
+	return u.SystemAdmin || u.BreakGlassActive()
+}
+
+// BreakGlassActive returns true if the user is a break-glass account and its
+// current activation has not expired.
+func (u *User) BreakGlassActive() bool {
+	if !u.BreakGlass || u.BreakGlassExpiresAt == nil {
+		return false
+	}
+	return time.Now().Before(*u.BreakGlassExpiresAt)
+}
+
 // PasswordChanged returns password change time or account creation time if unset.
 func (u *User) PasswordChanged() time.Time {
 	if u.LastPasswordChange.Before(launched) {
@@ -210,6 +261,11 @@ func (u *User) AddToRealm(db *Database, r *Realm, permissions rbac.Permission, a
 			return err
 		}
 
+		// The user has a realm again, so keep their Firebase account.
+		if err := cancelFirebaseUserDeletion(tx, u.Email); err != nil {
+			return err
+		}
+
 		// Brand new member?
 		if existing.UserID == 0 {
 			audit := BuildAuditEntry(actor, "added user to realm", u, r.ID)
@@ -267,6 +323,24 @@ func (u *User) DeleteFromRealm(db *Database, r *Realm, actor Auditable) error {
 			return fmt.Errorf("failed to save audit: %w", err)
 		}
 
+		// Schedule deletion of the Firebase account if that was the user's last
+		// realm.
+		if !u.SystemAdmin && !u.BreakGlass {
+			var remaining int64
+			if err := tx.
+				Model(&Membership{}).
+				Where("user_id = ?", u.ID).
+				Count(&remaining).
+				Error; err != nil {
+				return fmt.Errorf("failed to count memberships: %w", err)
+			}
+			if remaining == 0 {
+				if err := requestFirebaseUserDeletion(tx, u.Email); err != nil {
+					return err
+				}
+			}
+		}
+
 		// Cascade updated_at on user
 		if err := tx.
 			Model(&User{}).
@@ -283,7 +357,7 @@ func (u *User) DeleteFromRealm(db *Database, r *Realm, actor Auditable) error {
 // Stats returns the usage statistics for this user at the provided realm. If no
 // stats exist, it returns an empty array.
 func (u *User) Stats(db *Database, realm *Realm) (UserStats, error) {
-	stop := timeutils.UTCMidnight(time.Now())
+	stop := realm.StatsToday()
 	start := stop.Add(project.StatsDisplayDays * -24 * time.Hour)
 	if start.After(stop) {
 		return nil, ErrBadDateRange
@@ -316,6 +390,23 @@ func (u *User) Stats(db *Database, realm *Realm) (UserStats, error) {
 	return stats, nil
 }
 
+// CodesIssuedToday returns the number of codes this user has issued at the
+// provided realm since midnight in the realm's stats timezone.
+func (u *User) CodesIssuedToday(db *Database, realm *Realm) (uint, error) {
+	var stat UserStat
+	if err := db.db.
+		Model(&UserStat{}).
+		Where("user_id = ? AND realm_id = ? AND date = ?", u.ID, realm.ID, realm.StatsToday()).
+		First(&stat).
+		Error; err != nil {
+		if IsNotFound(err) {
+			return 0, nil
+		}
+		return 0, err
+	}
+	return stat.CodesIssued, nil
+}
+
 // StatsCached is stats, but cached.
 func (u *User) StatsCached(ctx context.Context, db *Database, cacher cache.Cacher, realm *Realm) (UserStats, error) {
 	if cacher == nil {
@@ -376,6 +467,71 @@ func (db *Database) UntouchUserRevokeCheck(u *User) error {
 		Error
 }
 
+// SetDefaultRealm sets the user's default realm to the given realm ID. The user
+// must be a member of the realm. A realm ID of 0 clears the default realm.
+func (db *Database) SetDefaultRealm(u *User, realmID uint, actor Auditable) error {
+	if u == nil {
+		return fmt.Errorf("provided user is nil")
+	}
+
+	if actor == nil {
+		return ErrMissingActor
+	}
+
+	var defaultRealmID *uint
+	if realmID != 0 {
+		defaultRealmID = &realmID
+	}
+
+	return db.db.Transaction(func(tx *gorm.DB) error {
+		var existing User
+		if err := tx.
+			Model(&User{}).
+			Where("id = ?", u.ID).
+			First(&existing).
+			Error; err != nil {
+			return fmt.Errorf("failed to get existing user: %w", err)
+		}
+
+		if defaultRealmID != nil {
+			var count int
+			if err := tx.
+				Model(&Membership{}).
+				Where("user_id = ? AND realm_id = ?", u.ID, realmID).
+				Count(&count).
+				Error; err != nil {
+				return fmt.Errorf("failed to check membership: %w", err)
+			}
+			if count == 0 {
+				return fmt.Errorf("user is not a member of realm %d: %w", realmID, gorm.ErrRecordNotFound)
+			}
+		}
+
+		if err := tx.
+			Model(u).
+			UpdateColumn("default_realm_id", defaultRealmID).
+			Error; err != nil {
+			return fmt.Errorf("failed to update default realm: %w", err)
+		}
+		u.DefaultRealmID = defaultRealmID
+
+		audit := BuildAuditEntry(actor, "updated default realm", u, 0)
+		audit.Diff = stringDiff(defaultRealmString(existing.DefaultRealmID), defaultRealmString(defaultRealmID))
+		if err := tx.Save(audit).Error; err != nil {
+			return fmt.Errorf("failed to save audit: %w", err)
+		}
+		return nil
+	})
+}
+
+// defaultRealmString formats the default realm ID for audit diffs.
+func defaultRealmString(id *uint) string {
+	if id == nil {
+		return ""
+	}
+	return fmt.Sprintf("%d", *id)
+}
+
 // PasswordChanged updates the last password change timestamp of the user.
 func (db *Database) PasswordChanged(email string, t time.Time) error {
 	q := db.db.
@@ -422,12 +578,18 @@ func (db *Database) DeleteUser(u *User, actor Auditable) error {
 			return fmt.Errorf("failed to save user: %w", err)
 		}
 
+		// Schedule deletion of the Firebase account
+		if err := requestFirebaseUserDeletion(tx, u.Email); err != nil {
+			return err
+		}
+
 		return nil
 	})
 }
 
-// PurgeUsers will delete users who are not a system admin, not a member of any realms
-// and have not been modified before the expiry time.
+// PurgeUsers will delete users who are not a system admin or break-glass
+// account, not a member of any realms and have not been modified before the
+// expiry time.
 func (db *Database) PurgeUsers(maxAge time.Duration) (int64, error) {
 	if maxAge > 0 {
 		maxAge = -1 * maxAge
@@ -435,7 +597,7 @@ func (db *Database) PurgeUsers(maxAge time.Duration) (int64, error) {
 	deleteBefore := time.Now().UTC().Add(maxAge)
 	// Delete users who were created/updated before the expiry time.
 	rtn := db.db.Unscoped().
-		Where("users.system_admin = false AND users.created_at < ? AND users.updated_at < ?", deleteBefore, deleteBefore).
+		Where("users.system_admin = false AND users.break_glass = false AND users.created_at < ? AND users.updated_at < ?", deleteBefore, deleteBefore).
 		Where("NOT EXISTS(SELECT 1 FROM memberships WHERE memberships.user_id = users.id LIMIT 1)"). // delete where no realm association exists.
 		Delete(&User{})
 	return rtn.RowsAffected, rtn.Error
@@ -468,6 +630,14 @@ func (db *Database) SaveUser(u *User, actor Auditable) error {
 			return fmt.Errorf("failed to save user: %w", err)
 		}
 
+		// A new user, system admin, or break-glass account keeps their Firebase
+		// account.
+		if existing.ID == 0 || u.SystemAdmin || u.BreakGlass {
+			if err := cancelFirebaseUserDeletion(tx, u.Email); err != nil {
+				return err
+			}
+		}
+
 		// Brand new user?
 		if existing.ID == 0 {
 			audit := BuildAuditEntry(actor, "created user", u, 0)
@@ -479,6 +649,12 @@ func (db *Database) SaveUser(u *User, actor Auditable) error {
 				audits = append(audits, audit)
 			}
 
+			if existing.BreakGlass != u.BreakGlass {
+				audit := BuildAuditEntry(actor, "updated user break-glass designation", u, 0)
+				audit.Diff = boolDiff(existing.BreakGlass, u.BreakGlass)
+				audits = append(audits, audit)
+			}
+
 			if existing.Name != u.Name {
 				audit := BuildAuditEntry(actor, "updated user's name", u, 0)
 				audit.Diff = stringDiff(existing.Name, u.Name)
@@ -490,6 +666,18 @@ func (db *Database) SaveUser(u *User, actor Auditable) error {
 				audit.Diff = stringDiff(existing.Email, u.Email)
 				audits = append(audits, audit)
 			}
+
+			if existing.PreferredLanguage != u.PreferredLanguage {
+				audit := BuildAuditEntry(actor, "updated user's preferred language", u, 0)
+				audit.Diff = stringDiff(existing.PreferredLanguage, u.PreferredLanguage)
+				audits = append(audits, audit)
+			}
+
+			if existing.UnclaimedCodesReport != u.UnclaimedCodesReport {
+				audit := BuildAuditEntry(actor, "updated user's unclaimed codes report", u, 0)
+				audit.Diff = boolDiff(existing.UnclaimedCodesReport, u.UnclaimedCodesReport)
+				audits = append(audits, audit)
+			}
 		}
 
 		// Save all audits
@@ -502,3 +690,17 @@ func (db *Database) SaveUser(u *User, actor Auditable) error {
 		return nil
 	})
 }
+
+// RecordUserElevation records an audit entry indicating the user recently
+// re-authenticated and is using that elevated session to perform a sensitive
+// action. The realmID is 0 for system-level actions.
+func (db *Database) RecordUserElevation(u *User, realmID uint, authenticatedAt time.Time, action string) error {
+	if u == nil {
+		return fmt.Errorf("provided user is nil")
+	}
+
+	audit := BuildAuditEntry(u, "elevated session", u, realmID)
+	audit.Diff = stringDiff("", fmt.Sprintf("authenticated at %s for %s",
+		authenticatedAt.UTC().Format(time.RFC3339), action))
+	return db.SaveAuditEntry(audit)
+}
@@ -0,0 +1,370 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package database
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/exposure-notifications-verification-server/internal/project"
+	"github.com/google/exposure-notifications-verification-server/pkg/rbac"
+	"github.com/jinzhu/gorm"
+)
+
+const (
+	// UserImportStatusPending indicates the import has been requested, but not
+	// yet processed.
+	UserImportStatusPending = "pending"
+
+	// UserImportStatusCompleted indicates every user in the import was
+	// processed. Individual users may still have failed; see Failures.
+	UserImportStatusCompleted = "completed"
+
+	// UserImportStatusFailed indicates the import could not be processed at
+	// all.
+	UserImportStatusFailed = "failed"
+
+	// MaxUserImportSize is the maximum number of users in a single import.
+	MaxUserImportSize = 1000
+
+	// UserImportPermissions are the permissions that can be granted by a user
+	// import. Imports are requested with API keys, so they cannot grant
+	// administrative permissions. Other permissions of existing members are
+	// left unchanged.
+	UserImportPermissions = rbac.LegacyRealmUser | rbac.StatsRead
+)
+
+var _ Auditable = (*UserImport)(nil)
+
+// UserImportEntry is a single user in a user import.
+type UserImportEntry struct {
+	Email string `json:"email"`
+	Name  string `json:"name"`
+
+	// Permissions are the names of the permissions to grant. If empty, the
+	// user is granted LegacyRealmUser.
+	Permissions []string `json:"permissions,omitempty"`
+}
+
+// Permission returns the permissions to grant to the user, including implied
+// permissions. It returns an error if a permission is unknown or cannot be
+// granted by an import.
+func (e *UserImportEntry) Permission() (rbac.Permission, error) {
+	if len(e.Permissions) == 0 {
+		return rbac.LegacyRealmUser, nil
+	}
+
+	var permission rbac.Permission
+	for _, name := range e.Permissions {
+		p, ok := rbac.NamePermissionMap[name]
+		if !ok {
+			return 0, fmt.Errorf("permission %q is unknown", name)
+		}
+		if !rbac.Can(UserImportPermissions, p) {
+			return 0, fmt.Errorf("permission %q cannot be granted by an import", name)
+		}
+		permission = permission | p
+	}
+	return rbac.AddImplied(permission), nil
+}
+
+// UserImportFailure is the reason a single user in an import failed.
+type UserImportFailure struct {
+	Email string `json:"email"`
+	Error string `json:"error"`
+}
+
+// UserImport is a request to create or update many realm memberships at once,
+// typically to sync issuers from an external directory. Imports are processed
+// asynchronously by a scheduled worker.
+type UserImport struct {
+	Errorable
+
+	// ID is the import's ID.
+	ID uint `gorm:"primary_key;"`
+
+	// RealmID is the realm the users are imported into.
+	RealmID uint `gorm:"column:realm_id; type:integer; not null;"`
+
+	// AuthorizedAppID is the API key that requested the import.
+	AuthorizedAppID uint `gorm:"column:authorized_app_id; type:integer; not null;"`
+
+	// Status is the import status.
+	Status string `gorm:"column:status; type:text; not null; default:'pending';"`
+
+	// Entries are the users to import, stored as JSON.
+	Entries        []*UserImportEntry `gorm:"-"`
+	EntriesPayload string             `gorm:"column:entries; type:text; not null;"`
+
+	// Created, Updated, and Failed are the number of users that were added to
+	// the realm, whose existing membership was updated, and that could not be
+	// imported.
+	Created uint `gorm:"column:created; type:integer; not null; default:0;"`
+	Updated uint `gorm:"column:updated; type:integer; not null; default:0;"`
+	Failed  uint `gorm:"column:failed; type:integer; not null; default:0;"`
+
+	// Failures are the reasons users could not be imported, stored as JSON.
+	Failures        []*UserImportFailure `gorm:"-"`
+	FailuresPayload string               `gorm:"column:failures; type:text;"`
+
+	// Error is the reason the import failed, if any.
+	Error string `gorm:"column:error; type:text;"`
+
+	// CompletedAt is when the import finished, successfully or not.
+	CompletedAt *time.Time `gorm:"column:completed_at; type:timestamp with time zone;"`
+
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// TableName sets the table name.
+func (UserImport) TableName() string {
+	return "user_imports"
+}
+
+// AfterFind decodes the entries and failures.
+func (i *UserImport) AfterFind(tx *gorm.DB) error {
+	if err := json.Unmarshal([]byte(i.EntriesPayload), &i.Entries); err != nil {
+		return fmt.Errorf("failed to decode entries: %w", err)
+	}
+	if i.FailuresPayload != "" {
+		if err := json.Unmarshal([]byte(i.FailuresPayload), &i.Failures); err != nil {
+			return fmt.Errorf("failed to decode failures: %w", err)
+		}
+	}
+	return nil
+}
+
+// BeforeSave runs validations. If there are errors, the save fails.
+func (i *UserImport) BeforeSave(tx *gorm.DB) error {
+	if i.RealmID == 0 {
+		i.AddError("realm_id", "is required")
+	}
+	if i.AuthorizedAppID == 0 {
+		i.AddError("authorized_app_id", "is required")
+	}
+
+	if i.Status == "" {
+		i.Status = UserImportStatusPending
+	}
+	switch i.Status {
+	case UserImportStatusPending, UserImportStatusCompleted, UserImportStatusFailed:
+	default:
+		i.AddError("status", fmt.Sprintf("is not a valid status %q", i.Status))
+	}
+
+	if len(i.Entries) == 0 {
+		i.AddError("users", "is required")
+	}
+	if len(i.Entries) > MaxUserImportSize {
+		i.AddError("users", fmt.Sprintf("cannot have more than %d users", MaxUserImportSize))
+	}
+	for idx, entry := range i.Entries {
+		entry.Email = project.TrimSpace(entry.Email)
+		entry.Name = project.TrimSpace(entry.Name)
+		if !strings.Contains(entry.Email, "@") {
+			i.AddError("users", fmt.Sprintf("user %d: email is invalid", idx))
+		}
+		if _, err := entry.Permission(); err != nil {
+			i.AddError("users", fmt.Sprintf("user %d: %s", idx, err))
+		}
+	}
+
+	if err := i.ErrorOrNil(); err != nil {
+		return err
+	}
+
+	b, err := json.Marshal(i.Entries)
+	if err != nil {
+		return fmt.Errorf("failed to encode entries: %w", err)
+	}
+	i.EntriesPayload = string(b)
+
+	if len(i.Failures) > 0 {
+		b, err := json.Marshal(i.Failures)
+		if err != nil {
+			return fmt.Errorf("failed to encode failures: %w", err)
+		}
+		i.FailuresPayload = string(b)
+	}
+	return nil
+}
+
+// IsPending returns true if the import has not yet been processed.
+func (i *UserImport) IsPending() bool {
+	return i.Status == UserImportStatusPending
+}
+
+// AuditID is how the import is stored in the audit entry.
+func (i *UserImport) AuditID() string {
+	return fmt.Sprintf("user_imports:%d", i.ID)
+}
+
+// AuditDisplay is how the import will be displayed in audit entries.
+func (i *UserImport) AuditDisplay() string {
+	return fmt.Sprintf("user import %d", i.ID)
+}
+
+// CreateUserImport saves a new pending import.
+func (db *Database) CreateUserImport(i *UserImport, actor Auditable) error {
+	if i == nil {
+		return fmt.Errorf("provided import is nil")
+	}
+
+	if actor == nil {
+		return ErrMissingActor
+	}
+
+	return db.db.Transaction(func(tx *gorm.DB) error {
+		i.Status = UserImportStatusPending
+		if err := tx.Save(i).Error; err != nil {
+			return err
+		}
+
+		audit := BuildAuditEntry(actor, "requested user import", i, i.RealmID)
+		if err := tx.Save(audit).Error; err != nil {
+			return fmt.Errorf("failed to save audit: %w", err)
+		}
+		return nil
+	})
+}
+
+// FindUserImport finds the import in the realm by its ID.
+func (r *Realm) FindUserImport(db *Database, id interface{}) (*UserImport, error) {
+	var i UserImport
+	if err := db.db.
+		Model(&UserImport{}).
+		Where("realm_id = ?", r.ID).
+		Where("id = ?", id).
+		First(&i).
+		Error; err != nil {
+		return nil, err
+	}
+	return &i, nil
+}
+
+// ListPendingUserImports lists up to limit pending imports across all realms,
+// oldest first.
+func (db *Database) ListPendingUserImports(limit uint64) ([]*UserImport, error) {
+	var imports []*UserImport
+	if err := db.db.
+		Model(&UserImport{}).
+		Where("status = ?", UserImportStatusPending).
+		Order("created_at ASC, id ASC").
+		Limit(limit).
+		Find(&imports).
+		Error; err != nil {
+		if IsNotFound(err) {
+			return imports, nil
+		}
+		return nil, err
+	}
+	return imports, nil
+}
+
+// ApplyUserImport creates or updates the realm membership of each user in the
+// import, then marks the import as completed. Users that do not exist are
+// created. A failure for one user is recorded on the import and does not stop
+// the others.
+//
+// For existing members, only the permissions an import can grant are replaced;
+// other permissions are left unchanged.
+func (db *Database) ApplyUserImport(i *UserImport, actor Auditable) error {
+	if actor == nil {
+		return ErrMissingActor
+	}
+
+	realm, err := db.FindRealm(i.RealmID)
+	if err != nil {
+		return fmt.Errorf("failed to find realm: %w", err)
+	}
+
+	memberships, err := realm.MembershipPermissionMap(db)
+	if err != nil {
+		return fmt.Errorf("failed to list memberships: %w", err)
+	}
+
+	i.Created, i.Updated, i.Failed = 0, 0, 0
+	i.Failures = nil
+	for _, entry := range i.Entries {
+		existed, err := db.applyUserImportEntry(realm, memberships, entry, actor)
+		if err != nil {
+			i.Failed++
+			i.Failures = append(i.Failures, &UserImportFailure{
+				Email: entry.Email,
+				Error: err.Error(),
+			})
+			continue
+		}
+
+		if existed {
+			i.Updated++
+		} else {
+			i.Created++
+		}
+	}
+
+	now := time.Now().UTC()
+	i.Status = UserImportStatusCompleted
+	i.CompletedAt = &now
+	return db.db.Save(i).Error
+}
+
+// applyUserImportEntry creates or updates the membership for a single entry.
+// It returns true if the user was already a member of the realm.
+func (db *Database) applyUserImportEntry(realm *Realm, memberships map[uint]rbac.Permission, entry *UserImportEntry, actor Auditable) (bool, error) {
+	requested, err := entry.Permission()
+	if err != nil {
+		return false, err
+	}
+
+	user, err := db.FindUserByEmail(entry.Email)
+	if err != nil {
+		if !IsNotFound(err) {
+			return false, fmt.Errorf("failed to find user: %w", err)
+		}
+
+		user = &User{
+			Email: entry.Email,
+			Name:  entry.Name,
+		}
+		if err := db.SaveUser(user, actor); err != nil {
+			return false, fmt.Errorf("failed to create user: %w", err)
+		}
+	}
+
+	existing, existed := memberships[user.ID]
+	permission := rbac.AddImplied(existing&^UserImportPermissions | requested)
+	if existed && permission == existing {
+		return true, nil
+	}
+
+	if err := user.AddToRealm(db, realm, permission, actor); err != nil {
+		return existed, fmt.Errorf("failed to add user to realm: %w", err)
+	}
+	memberships[user.ID] = permission
+	return existed, nil
+}
+
+// FailUserImport marks the import as failed with the given reason.
+func (db *Database) FailUserImport(i *UserImport, reason error) error {
+	now := time.Now().UTC()
+	i.Status = UserImportStatusFailed
+	i.Error = reason.Error()
+	i.CompletedAt = &now
+	return db.db.Save(i).Error
+}
@@ -0,0 +1,221 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package database
+
+import (
+	"testing"
+
+	"github.com/google/exposure-notifications-verification-server/pkg/rbac"
+	"github.com/jinzhu/gorm"
+)
+
+func TestUserImportEntry_Permission(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name        string
+		permissions []string
+		exp         rbac.Permission
+		err         bool
+	}{
+		{
+			name: "default",
+			exp:  rbac.LegacyRealmUser,
+		},
+		{
+			name:        "implied",
+			permissions: []string{"CodeBulkIssue"},
+			exp:         rbac.CodeBulkIssue | rbac.CodeIssue,
+		},
+		{
+			name:        "unknown",
+			permissions: []string{"Banana"},
+			err:         true,
+		},
+		{
+			name:        "administrative",
+			permissions: []string{"CodeIssue", "UserWrite"},
+			err:         true,
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			entry := &UserImportEntry{Permissions: tc.permissions}
+			got, err := entry.Permission()
+			if (err != nil) != tc.err {
+				t.Fatalf("expected error: %t, got %v", tc.err, err)
+			}
+			if got != tc.exp {
+				t.Errorf("expected %v to be %v", got, tc.exp)
+			}
+		})
+	}
+}
+
+func TestUserImport_BeforeSave(t *testing.T) {
+	t.Parallel()
+
+	{
+		var i UserImport
+		_ = i.BeforeSave(&gorm.DB{})
+		if errs := i.ErrorsFor("users"); len(errs) < 1 {
+			t.Errorf("expected errors for users")
+		}
+	}
+
+	{
+		i := &UserImport{
+			RealmID:         1,
+			AuthorizedAppID: 1,
+			Entries: []*UserImportEntry{
+				{Email: "not-an-email", Name: "Nope"},
+			},
+		}
+		_ = i.BeforeSave(&gorm.DB{})
+		if errs := i.ErrorsFor("users"); len(errs) < 1 {
+			t.Errorf("expected errors for users")
+		}
+	}
+
+	{
+		i := &UserImport{
+			RealmID:         1,
+			AuthorizedAppID: 1,
+			Entries: []*UserImportEntry{
+				{Email: " issuer@example.com ", Name: "Issuer"},
+			},
+		}
+		if err := i.BeforeSave(&gorm.DB{}); err != nil {
+			t.Fatal(err)
+		}
+		if got, want := i.Entries[0].Email, "issuer@example.com"; got != want {
+			t.Errorf("expected %q to be %q", got, want)
+		}
+		if i.EntriesPayload == "" {
+			t.Errorf("expected entries to be encoded")
+		}
+	}
+}
+
+func TestDatabase_UserImport(t *testing.T) {
+	t.Parallel()
+
+	db, _ := testDatabaseInstance.NewDatabase(t, nil)
+
+	realm, err := db.FindRealm(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// An existing admin keeps their administrative permissions.
+	admin := &User{Email: "admin@example.com", Name: "Admin"}
+	if err := db.SaveUser(admin, SystemTest); err != nil {
+		t.Fatal(err)
+	}
+	if err := admin.AddToRealm(db, realm, rbac.LegacyRealmAdmin, SystemTest); err != nil {
+		t.Fatal(err)
+	}
+
+	userImport := &UserImport{
+		RealmID:         realm.ID,
+		AuthorizedAppID: 1,
+		Entries: []*UserImportEntry{
+			{Email: "issuer@example.com", Name: "Issuer", Permissions: []string{"CodeIssue"}},
+			{Email: "admin@example.com", Name: "Admin", Permissions: []string{"CodeRead"}},
+			{Email: "unnamed@example.com"},
+		},
+	}
+
+	if err := db.CreateUserImport(userImport, nil); err != ErrMissingActor {
+		t.Errorf("expected %v to be %v", err, ErrMissingActor)
+	}
+	if err := db.CreateUserImport(userImport, SystemTest); err != nil {
+		t.Fatal(err)
+	}
+	if !userImport.IsPending() {
+		t.Errorf("expected import to be pending, got %q", userImport.Status)
+	}
+
+	pending, err := db.ListPendingUserImports(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(pending), 1; got != want {
+		t.Fatalf("expected %d to be %d", got, want)
+	}
+	if got, want := len(pending[0].Entries), 3; got != want {
+		t.Errorf("expected %d to be %d", got, want)
+	}
+
+	if err := db.ApplyUserImport(pending[0], SystemTest); err != nil {
+		t.Fatal(err)
+	}
+
+	found, err := realm.FindUserImport(db, userImport.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := found.Status, UserImportStatusCompleted; got != want {
+		t.Errorf("expected %q to be %q", got, want)
+	}
+	if got, want := found.Created, uint(1); got != want {
+		t.Errorf("expected %d to be %d", got, want)
+	}
+	if got, want := found.Updated, uint(1); got != want {
+		t.Errorf("expected %d to be %d", got, want)
+	}
+
+	// The user without a name cannot be created.
+	if got, want := found.Failed, uint(1); got != want {
+		t.Errorf("expected %d to be %d", got, want)
+	}
+	if got, want := len(found.Failures), 1; got != want {
+		t.Fatalf("expected %d to be %d", got, want)
+	}
+	if got, want := found.Failures[0].Email, "unnamed@example.com"; got != want {
+		t.Errorf("expected %q to be %q", got, want)
+	}
+
+	memberships, err := realm.MembershipPermissionMap(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	issuer, err := db.FindUserByEmail("issuer@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := memberships[issuer.ID], rbac.CodeIssue; got != want {
+		t.Errorf("expected %v to be %v", got, want)
+	}
+
+	exp := rbac.LegacyRealmAdmin&^UserImportPermissions | rbac.CodeRead
+	if got, want := memberships[admin.ID], exp; got != want {
+		t.Errorf("expected %v to be %v", got, want)
+	}
+
+	pending, err = db.ListPendingUserImports(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(pending), 0; got != want {
+		t.Errorf("expected %d to be %d", got, want)
+	}
+}
@@ -40,8 +40,13 @@ type UserReport struct {
 	// ID is an auto-increment primary key
 	ID uint
 
+	// RealmID is the realm that issued the code for this report. It determines
+	// how long the record is kept once the code is claimed. It is 0 for reports
+	// created before it was recorded.
+	RealmID uint
+
 	// PhoneHash is the base64 encoded HMAC of the phone number used to create a user report
-	PhoneHash string `json:"-"` // unique
+	PhoneHash string `json:"-" audit:"redact"` // unique
 	// Nonce is the random data that must be presented when verifying a verification code attached to this user report
 	Nonce string
 	// NonceRequired indicates if this is request requires a nonce, some do not if issued by a PHA web site for example.
@@ -202,16 +207,11 @@ func (db *Database) PurgeUnclaimedUserReports(maxAge time.Duration) (int64, erro
 	return rtn.RowsAffected, rtn.Error
 }
 
-// PurgeClaimedUserReports removes expired user reports.
+// PurgeClaimedUserReports removes expired user reports. Reports of realms with
+// a user report retention period are removed after that period instead.
 func (db *Database) PurgeClaimedUserReports(maxAge time.Duration) (int64, error) {
-	if maxAge > 0 {
-		maxAge = -1 * maxAge
-	}
-	deleteBefore := time.Now().UTC().Add(maxAge)
-	rtn := db.db.Unscoped().
-		Where("created_at < ?", deleteBefore).
-		Delete(&UserReport{})
-	return rtn.RowsAffected, rtn.Error
+	return db.purgeWithRealmRetention(&UserReport{}, "user_reports.created_at", "user_reports.realm_id",
+		"user_report_retention_days", maxAge)
 }
 
 // GeneratePhoneNumberHMAC generates the HMAC of the phone number using the latest key.
@@ -0,0 +1,333 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package database
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+const (
+	// MaxUserReportConsentLength is the maximum length of consent text.
+	MaxUserReportConsentLength = 4096
+)
+
+// UserReportConsent is the text of one version of a realm's user report consent
+// in one locale. Published versions are never modified, so the exact text a
+// self-reporter accepted can always be recovered.
+type UserReportConsent struct {
+	Errorable
+
+	// ID is the consent's ID.
+	ID uint `gorm:"primary_key;"`
+
+	// RealmID is the realm that published the consent.
+	RealmID uint `gorm:"column:realm_id; type:integer; not null;"`
+
+	// Version is the consent version. All locales published together share a
+	// version.
+	Version uint `gorm:"column:version; type:integer; not null;"`
+
+	// Locale is the language of the text (e.g. "en" or "es-MX").
+	Locale string `gorm:"column:locale; type:text; not null;"`
+
+	// Text is the consent text shown to self-reporters.
+	Text string `gorm:"column:text; type:text; not null;"`
+
+	// CreatedAt is when the version was published.
+	CreatedAt time.Time
+}
+
+// TableName sets the table name.
+func (UserReportConsent) TableName() string {
+	return "user_report_consents"
+}
+
+// BeforeSave validates the consent.
+func (c *UserReportConsent) BeforeSave(tx *gorm.DB) error {
+	c.Locale = strings.TrimSpace(c.Locale)
+	c.Text = strings.TrimSpace(c.Text)
+
+	if c.Locale == "" {
+		c.AddError("locale", "cannot be blank")
+	}
+	if c.Text == "" {
+		c.AddError("text", "cannot be blank")
+	}
+	if len(c.Text) > MaxUserReportConsentLength {
+		c.AddError("text", fmt.Sprintf("cannot exceed %d characters", MaxUserReportConsentLength))
+	}
+
+	return c.ErrorOrNil()
+}
+
+// UserReportConsentAcceptance records that a self-reporter accepted a version
+// of the realm's consent text. Acceptances are kept after the user report is
+// purged, so they do not reference it with a foreign key.
+type UserReportConsentAcceptance struct {
+	// ID is the acceptance's ID.
+	ID uint `gorm:"primary_key;"`
+
+	// RealmID is the realm whose consent was accepted.
+	RealmID uint `gorm:"column:realm_id; type:integer; not null;"`
+
+	// UserReportID is the user report that was created with the acceptance.
+	UserReportID uint `gorm:"column:user_report_id; type:integer; not null;"`
+
+	// Version and Locale identify the consent text that was shown.
+	Version uint   `gorm:"column:version; type:integer; not null;"`
+	Locale  string `gorm:"column:locale; type:text; not null;"`
+
+	// AcceptedAt is when the self-reporter submitted the report.
+	AcceptedAt time.Time `gorm:"column:accepted_at; type:timestamp with time zone; not null;"`
+}
+
+// TableName sets the table name.
+func (UserReportConsentAcceptance) TableName() string {
+	return "user_report_consent_acceptances"
+}
+
+// UserReportConsentAcceptances is a list of acceptances, exported for audits.
+type UserReportConsentAcceptances []*UserReportConsentAcceptance
+
+// MarshalCSV returns bytes in CSV format.
+func (s UserReportConsentAcceptances) MarshalCSV() ([]byte, error) {
+	// Do nothing if there's no records
+	if len(s) == 0 {
+		return nil, nil
+	}
+
+	var b bytes.Buffer
+	w := csv.NewWriter(&b)
+
+	if err := w.Write([]string{"accepted_at", "realm_id", "user_report_id", "version", "locale"}); err != nil {
+		return nil, fmt.Errorf("failed to write CSV header: %w", err)
+	}
+
+	for i, a := range s {
+		if err := w.Write([]string{
+			a.AcceptedAt.UTC().Format(time.RFC3339),
+			strconv.FormatUint(uint64(a.RealmID), 10),
+			strconv.FormatUint(uint64(a.UserReportID), 10),
+			strconv.FormatUint(uint64(a.Version), 10),
+			a.Locale,
+		}); err != nil {
+			return nil, fmt.Errorf("failed to write CSV entry %d: %w", i, err)
+		}
+	}
+
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return nil, fmt.Errorf("failed to create CSV: %w", err)
+	}
+
+	return b.Bytes(), nil
+}
+
+// PublishUserReportConsent publishes the given localized texts as the next
+// version of the realm's user report consent. Every locale must be given, since
+// self-reporters are only shown texts from the current version.
+func (r *Realm) PublishUserReportConsent(db *Database, texts []*UserReportConsent, actor Auditable) error {
+	if actor == nil {
+		return ErrMissingActor
+	}
+
+	if len(texts) == 0 {
+		r.AddError("userReportConsent", "at least one locale is required")
+		return ErrValidationFailed
+	}
+
+	seen := make(map[string]struct{}, len(texts))
+	for _, t := range texts {
+		locale := strings.ToLower(strings.TrimSpace(t.Locale))
+		if _, ok := seen[locale]; ok {
+			r.AddError("userReportConsent", fmt.Sprintf("locale %q is listed more than once", t.Locale))
+			return ErrValidationFailed
+		}
+		seen[locale] = struct{}{}
+	}
+
+	return db.db.Transaction(func(tx *gorm.DB) error {
+		var existing Realm
+		if err := tx.
+			Set("gorm:query_option", "FOR UPDATE").
+			Model(&Realm{}).
+			Where("id = ?", r.ID).
+			First(&existing).
+			Error; err != nil {
+			return fmt.Errorf("failed to get existing realm: %w", err)
+		}
+		version := existing.UserReportConsentVersion + 1
+
+		for _, t := range texts {
+			t.ID = 0
+			t.RealmID = r.ID
+			t.Version = version
+			if err := tx.Create(t).Error; err != nil {
+				if IsValidationError(err) {
+					for _, msg := range t.ErrorMessages() {
+						r.AddError("userReportConsent", fmt.Sprintf("%s: %s", t.Locale, msg))
+					}
+					return ErrValidationFailed
+				}
+				return fmt.Errorf("failed to save consent: %w", err)
+			}
+		}
+
+		if err := tx.
+			Model(&Realm{}).
+			Where("id = ?", r.ID).
+			UpdateColumn("user_report_consent_version", version).
+			Error; err != nil {
+			return fmt.Errorf("failed to update consent version: %w", err)
+		}
+
+		audit := BuildAuditEntry(actor, "published user report consent", r, r.ID)
+		audit.Diff = uintDiff(existing.UserReportConsentVersion, version)
+		if err := tx.Save(audit).Error; err != nil {
+			return fmt.Errorf("failed to save audits: %w", err)
+		}
+
+		r.UserReportConsentVersion = version
+		return nil
+	})
+}
+
+// ListUserReportConsents lists all published consent texts for the realm,
+// newest version first.
+func (r *Realm) ListUserReportConsents(db *Database) ([]*UserReportConsent, error) {
+	var consents []*UserReportConsent
+	if err := db.db.
+		Model(&UserReportConsent{}).
+		Where("realm_id = ?", r.ID).
+		Order("version DESC, locale ASC").
+		Find(&consents).
+		Error; err != nil {
+		if IsNotFound(err) {
+			return consents, nil
+		}
+		return nil, err
+	}
+	return consents, nil
+}
+
+// CurrentUserReportConsent returns the text of the realm's current consent
+// version that best matches the given locales, in order of preference. If none
+// match, the text for the realm's default locale is returned, followed by the
+// first text. It returns an error that satisfies IsNotFound if the realm has not
+// published consent text.
+func (r *Realm) CurrentUserReportConsent(db *Database, locales ...string) (*UserReportConsent, error) {
+	if r.UserReportConsentVersion == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+
+	var consents []*UserReportConsent
+	if err := db.db.
+		Model(&UserReportConsent{}).
+		Where("realm_id = ? AND version = ?", r.ID, r.UserReportConsentVersion).
+		Order("locale ASC").
+		Find(&consents).
+		Error; err != nil {
+		return nil, err
+	}
+	if len(consents) == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+
+	return matchUserReportConsent(consents, append(locales, r.DefaultLocale)), nil
+}
+
+// matchUserReportConsent returns the consent that best matches the locales, in
+// order of preference. An exact match is preferred, followed by a match on the
+// base language (e.g. "es" for "es-MX"). If nothing matches, the first consent
+// is returned.
+func matchUserReportConsent(consents []*UserReportConsent, locales []string) *UserReportConsent {
+	byLocale := make(map[string]*UserReportConsent, len(consents))
+	for _, c := range consents {
+		byLocale[normalizeLocale(c.Locale)] = c
+	}
+
+	for _, l := range locales {
+		l = normalizeLocale(l)
+		if l == "" {
+			continue
+		}
+		if c, ok := byLocale[l]; ok {
+			return c
+		}
+		if i := strings.Index(l, "-"); i > 0 {
+			if c, ok := byLocale[l[:i]]; ok {
+				return c
+			}
+		}
+	}
+
+	sorted := make([]*UserReportConsent, len(consents))
+	copy(sorted, consents)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Locale < sorted[j].Locale
+	})
+	return sorted[0]
+}
+
+// normalizeLocale lowercases the locale and uses "-" as the separator.
+func normalizeLocale(l string) string {
+	return strings.ReplaceAll(strings.ToLower(strings.TrimSpace(l)), "_", "-")
+}
+
+// ListUserReportConsentAcceptances lists the consent acceptances for the realm
+// between start and end, oldest first.
+func (r *Realm) ListUserReportConsentAcceptances(db *Database, start, end time.Time) ([]*UserReportConsentAcceptance, error) {
+	if end.Before(start) {
+		return nil, ErrBadDateRange
+	}
+
+	var acceptances []*UserReportConsentAcceptance
+	if err := db.db.
+		Model(&UserReportConsentAcceptance{}).
+		Where("realm_id = ?", r.ID).
+		Where("accepted_at >= ? AND accepted_at <= ?", start, end).
+		Order("accepted_at ASC, id ASC").
+		Find(&acceptances).
+		Error; err != nil {
+		if IsNotFound(err) {
+			return acceptances, nil
+		}
+		return nil, err
+	}
+	return acceptances, nil
+}
+
+// PurgeUserReportConsentAcceptances deletes acceptances that were recorded
+// longer than maxAge ago.
+func (db *Database) PurgeUserReportConsentAcceptances(maxAge time.Duration) (int64, error) {
+	if maxAge > 0 {
+		maxAge = -1 * maxAge
+	}
+	acceptedBefore := time.Now().UTC().Add(maxAge)
+
+	result := db.db.
+		Unscoped().
+		Where("accepted_at < ?", acceptedBefore).
+		Delete(&UserReportConsentAcceptance{})
+	return result.RowsAffected, result.Error
+}
@@ -0,0 +1,231 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package database
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/exposure-notifications-verification-server/pkg/pagination"
+)
+
+func TestMatchUserReportConsent(t *testing.T) {
+	t.Parallel()
+
+	consents := []*UserReportConsent{
+		{Locale: "es", Text: "es"},
+		{Locale: "en", Text: "en"},
+		{Locale: "zh-TW", Text: "zh-TW"},
+	}
+
+	cases := []struct {
+		name    string
+		locales []string
+		exp     string
+	}{
+		{name: "exact", locales: []string{"en"}, exp: "en"},
+		{name: "case_insensitive", locales: []string{"ZH-tw"}, exp: "zh-TW"},
+		{name: "underscore", locales: []string{"zh_TW"}, exp: "zh-TW"},
+		{name: "base_language", locales: []string{"es-MX"}, exp: "es"},
+		{name: "preference_order", locales: []string{"fr", "es", "en"}, exp: "es"},
+		{name: "skips_blank", locales: []string{"", "en"}, exp: "en"},
+		{name: "no_match", locales: []string{"fr"}, exp: "en"},
+		{name: "empty", locales: nil, exp: "en"},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			if got, want := matchUserReportConsent(consents, tc.locales).Text, tc.exp; got != want {
+				t.Errorf("expected %q to be %q", got, want)
+			}
+		})
+	}
+}
+
+func TestRealm_PublishUserReportConsent(t *testing.T) {
+	t.Parallel()
+
+	db, _ := testDatabaseInstance.NewDatabase(t, nil)
+	realm := NewRealmWithDefaults("Consent")
+	realm.DefaultLocale = "en"
+	if err := db.SaveRealm(realm, SystemTest); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := realm.CurrentUserReportConsent(db, "en"); !IsNotFound(err) {
+		t.Errorf("expected %v to be not found", err)
+	}
+
+	if err := realm.PublishUserReportConsent(db, nil, SystemTest); !errors.Is(err, ErrValidationFailed) {
+		t.Errorf("expected %v to be %v", err, ErrValidationFailed)
+	}
+
+	if err := realm.PublishUserReportConsent(db, []*UserReportConsent{
+		{Locale: "en", Text: "one"},
+		{Locale: "EN", Text: "two"},
+	}, SystemTest); !errors.Is(err, ErrValidationFailed) {
+		t.Errorf("expected %v to be %v", err, ErrValidationFailed)
+	}
+
+	if err := realm.PublishUserReportConsent(db, []*UserReportConsent{
+		{Locale: "en", Text: strings.Repeat("a", MaxUserReportConsentLength+1)},
+	}, SystemTest); !errors.Is(err, ErrValidationFailed) {
+		t.Errorf("expected %v to be %v", err, ErrValidationFailed)
+	}
+	if realm.UserReportConsentVersion != 0 {
+		t.Errorf("expected %d to be %d", realm.UserReportConsentVersion, 0)
+	}
+
+	if err := realm.PublishUserReportConsent(db, []*UserReportConsent{
+		{Locale: "en", Text: "I agree"},
+		{Locale: "es", Text: "Estoy de acuerdo"},
+	}, SystemTest); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := realm.UserReportConsentVersion, uint(1); got != want {
+		t.Errorf("expected %d to be %d", got, want)
+	}
+
+	if err := realm.PublishUserReportConsent(db, []*UserReportConsent{
+		{Locale: "en", Text: "I really agree"},
+	}, SystemTest); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := realm.UserReportConsentVersion, uint(2); got != want {
+		t.Errorf("expected %d to be %d", got, want)
+	}
+
+	// The version is persisted on the realm.
+	found, err := db.FindRealm(realm.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := found.UserReportConsentVersion, uint(2); got != want {
+		t.Errorf("expected %d to be %d", got, want)
+	}
+
+	// Only the current version's locales are used.
+	consent, err := found.CurrentUserReportConsent(db, "es")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := consent.Text, "I really agree"; got != want {
+		t.Errorf("expected %q to be %q", got, want)
+	}
+
+	// Old versions are kept.
+	consents, err := found.ListUserReportConsents(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(consents), 3; got != want {
+		t.Errorf("expected %d to be %d", got, want)
+	}
+
+	audits, _, err := db.ListAudits(&pagination.PageParams{Limit: 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var published int
+	for _, a := range audits {
+		if a.Action == "published user report consent" {
+			published++
+		}
+	}
+	if got, want := published, 2; got != want {
+		t.Errorf("expected %d to be %d", got, want)
+	}
+}
+
+func TestRealm_UserReportConsentAcceptances(t *testing.T) {
+	t.Parallel()
+
+	db, _ := testDatabaseInstance.NewDatabase(t, nil)
+	realm := NewRealmWithDefaults("Consent acceptances")
+	realm.AddUserReportToAllowedTestTypes()
+	realm.SMSCountry = "us"
+	if err := db.SaveRealm(realm, SystemTest); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := realm.PublishUserReportConsent(db, []*UserReportConsent{
+		{Locale: "en", Text: "I agree"},
+	}, SystemTest); err != nil {
+		t.Fatal(err)
+	}
+
+	vc := &VerificationCode{
+		RealmID:        realm.ID,
+		Code:           "123456",
+		LongCode:       "defghijk329024",
+		TestType:       "user-report",
+		ExpiresAt:      time.Now().Add(time.Hour),
+		LongExpiresAt:  time.Now().Add(2 * time.Hour),
+		Nonce:          generateNonce(t),
+		PhoneNumber:    "+12068675309",
+		NonceRequired:  true,
+		ConsentVersion: realm.UserReportConsentVersion,
+		ConsentLocale:  "en",
+	}
+	if err := realm.SaveVerificationCode(db, vc); err != nil {
+		t.Fatal(err, vc.ErrorMessages())
+	}
+
+	now := time.Now().UTC()
+	acceptances, err := realm.ListUserReportConsentAcceptances(db, now.Add(-time.Hour), now.Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(acceptances), 1; got != want {
+		t.Fatalf("expected %d to be %d", got, want)
+	}
+	if got, want := acceptances[0].UserReportID, *vc.UserReportID; got != want {
+		t.Errorf("expected %d to be %d", got, want)
+	}
+	if got, want := acceptances[0].Version, uint(1); got != want {
+		t.Errorf("expected %d to be %d", got, want)
+	}
+
+	b, err := UserReportConsentAcceptances(acceptances).MarshalCSV()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(b), "accepted_at,realm_id,user_report_id,version,locale\n"; !strings.HasPrefix(got, want) {
+		t.Errorf("expected %q to start with %q", got, want)
+	}
+
+	if _, err := realm.ListUserReportConsentAcceptances(db, now, now.Add(-time.Hour)); !errors.Is(err, ErrBadDateRange) {
+		t.Errorf("expected %v to be %v", err, ErrBadDateRange)
+	}
+
+	// Purging with a large max age keeps the acceptance.
+	if n, err := db.PurgeUserReportConsentAcceptances(time.Hour); err != nil {
+		t.Fatal(err)
+	} else if n != 0 {
+		t.Errorf("expected %d to be %d", n, 0)
+	}
+
+	if n, err := db.PurgeUserReportConsentAcceptances(time.Nanosecond); err != nil {
+		t.Fatal(err)
+	} else if n != 1 {
+		t.Errorf("expected %d to be %d", n, 1)
+	}
+}
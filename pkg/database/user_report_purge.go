@@ -0,0 +1,162 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package database
+
+import (
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jinzhu/gorm"
+)
+
+// ErrUserReportPurgeMismatch is returned when no user report matches the phone
+// number and nonce of a purge request. It does not distinguish between a
+// missing user report and a wrong nonce.
+var ErrUserReportPurgeMismatch = errors.New("no user report matches the phone number and nonce")
+
+// UserReportPurgeReceipt records that a self-reporter requested the deletion of
+// the data derived from their phone number. It does not contain the phone
+// number or any value derived from it.
+type UserReportPurgeReceipt struct {
+	// ID is the receipt's database ID.
+	ID uint `gorm:"primary_key;"`
+
+	// ReceiptID is the identifier returned to the self-reporter.
+	ReceiptID string `gorm:"column:receipt_id; type:uuid; not null;"`
+
+	// RealmID and AuthorizedAppID identify the API key that requested the purge.
+	RealmID         uint `gorm:"column:realm_id; type:integer; not null;"`
+	AuthorizedAppID uint `gorm:"column:authorized_app_id; type:integer; not null;"`
+
+	// CodesExpired is the number of unexpired verification codes that were
+	// expired by the purge.
+	CodesExpired uint `gorm:"column:codes_expired; type:integer; not null;"`
+
+	// ConsentsUnlinked is the number of consent acceptances that were unlinked
+	// from the user report. The acceptances themselves are kept for the realm's
+	// consent records.
+	ConsentsUnlinked uint `gorm:"column:consents_unlinked; type:integer; not null;"`
+
+	// PurgedAt is when the purge happened.
+	PurgedAt time.Time `gorm:"column:purged_at; type:timestamp with time zone; not null;"`
+}
+
+// TableName sets the table name.
+func (UserReportPurgeReceipt) TableName() string {
+	return "user_report_purge_receipts"
+}
+
+// PurgeUserReport deletes the user report for the phone number at the request of
+// the self-reporter. The nonce must be the nonce that was sent with the user
+// report, which proves the request comes from the device that created it. User
+// reports that were created without a nonce cannot be purged this way.
+//
+// The purge expires any unexpired verification codes for the user report,
+// unlinks consent acceptances from it, and deletes it. The returned receipt is
+// saved so the purge can be confirmed later.
+//
+// Statistics are not changed. They are daily aggregate counts with no link to
+// the phone number or user report, so removing the report's contribution is
+// deferred.
+func (db *Database) PurgeUserReport(phoneNumber string, nonce []byte, authApp *AuthorizedApp) (*UserReportPurgeReceipt, error) {
+	if authApp == nil {
+		return nil, ErrMissingActor
+	}
+
+	hmacedCodes, err := db.generatePhoneNumberHMACs(phoneNumber)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create hmac: %w", err)
+	}
+
+	receiptID, err := uuid.NewRandom()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate receipt id: %w", err)
+	}
+
+	var receipt *UserReportPurgeReceipt
+	if err := db.db.Transaction(func(tx *gorm.DB) error {
+		var ur UserReport
+		if err := tx.
+			Set("gorm:query_option", "FOR UPDATE").
+			Model(&UserReport{}).
+			Where("phone_hash IN (?)", hmacedCodes).
+			First(&ur).
+			Error; err != nil {
+			if IsNotFound(err) {
+				return ErrUserReportPurgeMismatch
+			}
+			return fmt.Errorf("failed to find user report: %w", err)
+		}
+
+		expected := []byte(ur.Nonce)
+		got := []byte(base64.StdEncoding.EncodeToString(nonce))
+		if !ur.NonceRequired || len(nonce) == 0 || subtle.ConstantTimeCompare(expected, got) != 1 {
+			return ErrUserReportPurgeMismatch
+		}
+
+		now := time.Now().UTC()
+
+		// Expire the codes so they can't be used by anyone else. The link to the
+		// user report is cleared by the foreign key when the report is deleted.
+		codes := tx.
+			Model(&VerificationCode{}).
+			Where("user_report_id = ?", ur.ID).
+			Where("expires_at > ? OR long_expires_at > ?", now, now).
+			UpdateColumns(map[string]interface{}{
+				"expires_at":      now,
+				"long_expires_at": now,
+			})
+		if err := codes.Error; err != nil {
+			return fmt.Errorf("failed to expire codes: %w", err)
+		}
+
+		consents := tx.
+			Model(&UserReportConsentAcceptance{}).
+			Where("user_report_id = ?", ur.ID).
+			UpdateColumn("user_report_id", 0)
+		if err := consents.Error; err != nil {
+			return fmt.Errorf("failed to unlink consent acceptances: %w", err)
+		}
+
+		if err := tx.Delete(&ur).Error; err != nil {
+			return fmt.Errorf("failed to delete user report: %w", err)
+		}
+
+		receipt = &UserReportPurgeReceipt{
+			ReceiptID:        receiptID.String(),
+			RealmID:          authApp.RealmID,
+			AuthorizedAppID:  authApp.ID,
+			CodesExpired:     uint(codes.RowsAffected),
+			ConsentsUnlinked: uint(consents.RowsAffected),
+			PurgedAt:         now,
+		}
+		if err := tx.Create(receipt).Error; err != nil {
+			return fmt.Errorf("failed to save receipt: %w", err)
+		}
+
+		audit := BuildAuditEntry(authApp, "purged user report phone at user request", &ur, authApp.RealmID)
+		if err := tx.Save(audit).Error; err != nil {
+			return fmt.Errorf("failed to save audits: %w", err)
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return receipt, nil
+}
@@ -0,0 +1,120 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package database
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestDatabase_PurgeUserReport(t *testing.T) {
+	t.Parallel()
+
+	db, _ := testDatabaseInstance.NewDatabase(t, nil)
+	realm, err := db.FindRealm(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	realm.AddUserReportToAllowedTestTypes()
+	if err := db.SaveRealm(realm, SystemTest); err != nil {
+		t.Fatal(err)
+	}
+
+	authApp := &AuthorizedApp{
+		Name:       "Appy",
+		APIKeyType: APIKeyTypeDevice,
+	}
+	if _, err := realm.CreateAuthorizedApp(db, authApp, SystemTest); err != nil {
+		t.Fatal(err)
+	}
+
+	phoneNumber := "+12068675309"
+	nonce := generateNonce(t)
+	verCode := &VerificationCode{
+		RealmID:        realm.ID,
+		Code:           "12345678",
+		LongCode:       "12345678",
+		TestType:       "user-report",
+		PhoneNumber:    phoneNumber,
+		Nonce:          nonce,
+		NonceRequired:  true,
+		ConsentVersion: 1,
+		ConsentLocale:  "en",
+		ExpiresAt:      time.Now().Add(time.Hour),
+		LongExpiresAt:  time.Now().Add(time.Hour),
+	}
+	if err := realm.SaveVerificationCode(db, verCode); err != nil {
+		t.Fatal(err)
+	}
+
+	// The wrong nonce does not purge the user report.
+	if _, err := db.PurgeUserReport(phoneNumber, generateNonce(t), authApp); !errors.Is(err, ErrUserReportPurgeMismatch) {
+		t.Fatalf("expected %v to be %v", err, ErrUserReportPurgeMismatch)
+	}
+	if _, err := db.FindUserReport(phoneNumber); err != nil {
+		t.Fatal(err)
+	}
+
+	receipt, err := db.PurgeUserReport(phoneNumber, nonce, authApp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if receipt.ReceiptID == "" {
+		t.Errorf("expected receipt id")
+	}
+	if got, want := receipt.CodesExpired, uint(1); got != want {
+		t.Errorf("expected %d to be %d", got, want)
+	}
+	if got, want := receipt.ConsentsUnlinked, uint(1); got != want {
+		t.Errorf("expected %d to be %d", got, want)
+	}
+
+	if _, err := db.FindUserReport(phoneNumber); !IsNotFound(err) {
+		t.Errorf("expected not found, got %#v", err)
+	}
+
+	code, err := realm.FindVerificationCode(db, verCode.Code)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !code.IsExpired() {
+		t.Errorf("expected code to be expired")
+	}
+	if code.UserReportID != nil {
+		t.Errorf("expected code to be unlinked, got %d", *code.UserReportID)
+	}
+
+	var count int
+	if err := db.db.Model(&UserReportConsentAcceptance{}).Where("user_report_id = 0").Count(&count).Error; err != nil {
+		t.Fatal(err)
+	}
+	if got, want := count, 1; got != want {
+		t.Errorf("expected %d to be %d", got, want)
+	}
+
+	var saved UserReportPurgeReceipt
+	if err := db.db.Where("receipt_id = ?", receipt.ReceiptID).First(&saved).Error; err != nil {
+		t.Fatal(err)
+	}
+	if got, want := saved.AuthorizedAppID, authApp.ID; got != want {
+		t.Errorf("expected %d to be %d", got, want)
+	}
+
+	// Purging again finds nothing.
+	if _, err := db.PurgeUserReport(phoneNumber, nonce, authApp); !errors.Is(err, ErrUserReportPurgeMismatch) {
+		t.Errorf("expected %v to be %v", err, ErrUserReportPurgeMismatch)
+	}
+}
@@ -17,6 +17,7 @@ package database
 import (
 	"crypto/rand"
 	"encoding/base64"
+	"fmt"
 	"testing"
 	"time"
 
@@ -235,3 +236,49 @@ func TestPurgeUserReports(t *testing.T) {
 		})
 	}
 }
+
+func TestPurgeClaimedUserReports_realmRetention(t *testing.T) {
+	t.Parallel()
+	db, _ := testDatabaseInstance.NewDatabase(t, nil)
+
+	realm := NewRealmWithDefaults("retention")
+	realm.UserReportRetentionDays = 60
+	if err := db.SaveRealm(realm, SystemTest); err != nil {
+		t.Fatal(err)
+	}
+
+	// Created 45 days ago: purged under the system default of 30 days, but kept
+	// by the realm's 60 day retention.
+	createdAt := time.Now().UTC().Add(-45 * 24 * time.Hour)
+	for _, realmID := range []uint{0, realm.ID} {
+		userReport, err := db.NewUserReport(fmt.Sprintf("+1555555%04d", realmID), generateNonce(t), true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		userReport.RealmID = realmID
+		userReport.CodeClaimed = true
+		userReport.CreatedAt = createdAt
+		if err := db.db.Save(userReport).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	n, err := db.PurgeClaimedUserReports(30 * 24 * time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := n, int64(1); got != want {
+		t.Errorf("expected %d to purge, got %d", want, got)
+	}
+
+	var reports []*UserReport
+	if err := db.db.Model(&UserReport{}).Find(&reports).Error; err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(reports), 1; got != want {
+		t.Fatalf("expected %d reports, got %d", want, got)
+	}
+	if got, want := reports[0].RealmID, realm.ID; got != want {
+		t.Errorf("expected %d to be %d", got, want)
+	}
+}
@@ -40,6 +40,13 @@ type UserStat struct {
 	RealmID     uint      `gorm:"realm_id; default:0;"`
 	CodesIssued uint      `gorm:"codes_issued; default:0;"`
 
+	// CodesFailed, IssueRequests, and IssueLatencyMs track the user's issuance
+	// SLO: the number of codes which failed to issue, the number of issue
+	// requests, and the total latency of those requests.
+	CodesFailed    uint   `gorm:"codes_failed; default:0;"`
+	IssueRequests  uint   `gorm:"issue_requests; default:0;"`
+	IssueLatencyMs uint64 `gorm:"issue_latency_ms; default:0;"`
+
 	// Non-database fields, these are added via the stats lookup using the join
 	// table.
 	UserName  string `gorm:"-"`
@@ -172,14 +179,6 @@ func (db *Database) SaveUserStat(u *UserStat) error {
 // PurgeUserStats will delete stats that were created longer than
 // maxAge ago.
 func (db *Database) PurgeUserStats(maxAge time.Duration) (int64, error) {
-	if maxAge > 0 {
-		maxAge = -1 * maxAge
-	}
-	createdBefore := time.Now().UTC().Add(maxAge)
-
-	result := db.db.
-		Unscoped().
-		Where("date < ?", createdBefore).
-		Delete(&UserStat{})
-	return result.RowsAffected, result.Error
+	return db.purgeWithRealmRetention(&UserStat{}, "user_stats.date", "user_stats.realm_id",
+		"stats_retention_days", maxAge)
 }
@@ -15,6 +15,7 @@
 package database
 
 import (
+	"strings"
 	"testing"
 	"time"
 
@@ -43,6 +44,48 @@ func TestUser_BeforeSave(t *testing.T) {
 	}
 }
 
+func TestUser_BeforeSave_PreferredLanguage(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name  string
+		input string
+		exp   string
+		err   bool
+	}{
+		{name: "blank", input: "", exp: ""},
+		{name: "language", input: " es ", exp: "es"},
+		{name: "region", input: "es-mx", exp: "es-MX"},
+		{name: "invalid", input: "not a language", err: true},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			user := &User{
+				Email:             "user@example.com",
+				Name:              "User",
+				PreferredLanguage: tc.input,
+			}
+			_ = user.BeforeSave(nil)
+
+			errs := user.ErrorsFor("preferredLanguage")
+			if got, want := len(errs) > 0, tc.err; got != want {
+				t.Fatalf("expected errors %t to be %t: %v", got, want, errs)
+			}
+			if tc.err {
+				return
+			}
+			if got, want := user.PreferredLanguage, tc.exp; got != want {
+				t.Errorf("expected %q to be %q", got, want)
+			}
+		})
+	}
+}
+
 func TestUser_Lifecycle(t *testing.T) {
 	t.Parallel()
 
@@ -279,6 +322,62 @@ func TestUser_DeleteFromRealm(t *testing.T) {
 	})
 }
 
+func TestDatabase_SetDefaultRealm(t *testing.T) {
+	t.Parallel()
+
+	db, _ := testDatabaseInstance.NewDatabase(t, nil)
+
+	realm, err := db.FindRealm(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	otherRealm := NewRealmWithDefaults("other")
+	if err := db.SaveRealm(otherRealm, SystemTest); err != nil {
+		t.Fatal(err)
+	}
+
+	user := &User{
+		Email: "default-realm@example.com",
+		Name:  "Dr Default",
+	}
+	if err := db.SaveUser(user, SystemTest); err != nil {
+		t.Fatal(err)
+	}
+	if err := user.AddToRealm(db, realm, rbac.CodeIssue, SystemTest); err != nil {
+		t.Fatal(err)
+	}
+
+	// Not a member
+	if err := db.SetDefaultRealm(user, otherRealm.ID, SystemTest); !IsNotFound(err) {
+		t.Errorf("expected not found, got %v", err)
+	}
+
+	// Member
+	if err := db.SetDefaultRealm(user, realm.ID, SystemTest); err != nil {
+		t.Fatal(err)
+	}
+	got, err := db.FindUser(user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.DefaultRealmID == nil || *got.DefaultRealmID != realm.ID {
+		t.Errorf("expected default realm to be %d, got %v", realm.ID, got.DefaultRealmID)
+	}
+
+	// Clear
+	if err := db.SetDefaultRealm(user, 0, SystemTest); err != nil {
+		t.Fatal(err)
+	}
+	got, err = db.FindUser(user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.DefaultRealmID != nil {
+		t.Errorf("expected default realm to be cleared, got %d", *got.DefaultRealmID)
+	}
+}
+
 func expectExists(t *testing.T, db *Database, id uint) {
 	got, err := db.FindUser(id)
 	if err != nil {
@@ -333,3 +432,42 @@ func TestUser_Audits(t *testing.T) {
 		t.Errorf("expected %d audits, got %d: %v", want, got, audits)
 	}
 }
+
+func TestDatabase_RecordUserElevation(t *testing.T) {
+	t.Parallel()
+
+	db, _ := testDatabaseInstance.NewDatabase(t, nil)
+
+	if err := db.RecordUserElevation(nil, 0, time.Now(), "DELETE /realm/keys/1"); err == nil {
+		t.Errorf("expected error for nil user")
+	}
+
+	user := &User{
+		Email: "elevated@example.com",
+		Name:  "Dr Elevated",
+	}
+	if err := db.SaveUser(user, SystemTest); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := db.RecordUserElevation(user, 1, time.Now(), "DELETE /realm/keys/1"); err != nil {
+		t.Fatal(err)
+	}
+
+	audits, _, err := db.ListAudits(&pagination.PageParams{Limit: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(audits), 1; got != want {
+		t.Fatalf("expected %d audits, got %d", want, got)
+	}
+	if got, want := audits[0].Action, "elevated session"; got != want {
+		t.Errorf("expected action %q to be %q", got, want)
+	}
+	if got, want := audits[0].RealmID, uint(1); got != want {
+		t.Errorf("expected realm id %d to be %d", got, want)
+	}
+	if got := audits[0].Diff; !strings.Contains(got, "DELETE /realm/keys/1") {
+		t.Errorf("expected diff %q to include the action", got)
+	}
+}
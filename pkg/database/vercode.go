@@ -16,15 +16,17 @@ package database
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/base64"
 	"errors"
 	"fmt"
 	"time"
 
 	"github.com/google/exposure-notifications-verification-server/internal/project"
+	"github.com/google/exposure-notifications-verification-server/pkg/pagination"
 
 	verifyapi "github.com/google/exposure-notifications-server/pkg/api/v1"
 	"github.com/google/exposure-notifications-server/pkg/logging"
-	"github.com/google/exposure-notifications-server/pkg/timeutils"
 
 	"github.com/jinzhu/gorm"
 )
@@ -87,6 +89,12 @@ type VerificationCode struct {
 	PhoneNumber   string `gorm:"-"`
 	NonceRequired bool   `gorm:"-"`
 
+	// ConsentVersion and ConsentLocale identify the realm's user report consent
+	// text the self-reporter accepted. If the version is set, an acceptance is
+	// recorded with the user report.
+	ConsentVersion uint   `gorm:"-"`
+	ConsentLocale  string `gorm:"-"`
+
 	// IssuingUserID is the ID of the user in the database that created this
 	// verification code. This is only populated if the code was created via the
 	// UI.
@@ -104,6 +112,19 @@ type VerificationCode struct {
 	// API AND the API caller supplied it in the request. This ID has no meaning
 	// in this system. It can be up to 255 characters in length.
 	IssuingExternalID string `gorm:"column:issuing_external_id; type:text;"`
+
+	// ExternalCaseID is an optional identifier for the case in an external
+	// system, such as a lab accession number. It is validated against the
+	// realm's external case ID settings when the code is issued.
+	ExternalCaseID string `gorm:"column:external_case_id; type:text;"`
+
+	// HMACKeyID identifies the verification code database HMAC key that was used
+	// to HMAC Code and LongCode. It is set automatically on create and lets key
+	// rotation keep an older key available until no outstanding code needs it.
+	HMACKeyID string `gorm:"column:hmac_key_id; type:text;"`
+
+	// ResendCount is the number of times the code was resent by SMS.
+	ResendCount uint `gorm:"column:resend_count; type:integer; not null; default:0;"`
 }
 
 // BeforeSave is used by callbacks.
@@ -119,6 +140,10 @@ func (v *VerificationCode) BeforeSave(tx *gorm.DB) error {
 		v.AddError("issuingExternalID", "cannot exceed 255 characters")
 	}
 
+	if len(v.ExternalCaseID) > MaxExternalCaseIDLength {
+		v.AddError("externalCaseID", fmt.Sprintf("cannot exceed %d characters", MaxExternalCaseIDLength))
+	}
+
 	return v.ErrorOrNil()
 }
 
@@ -215,7 +240,7 @@ func (v *VerificationCode) Validate(realm *Realm) error {
 // FindVerificationCode find a verification code by the code number (can be
 // short code or long code).
 func (r *Realm) FindVerificationCode(db *Database, code string) (*VerificationCode, error) {
-	hmacedCodes, err := db.generateVerificationCodeHMACs(code)
+	hmacedCodes, err := db.generateVerificationCodeHMACs(NormalizeCode(code))
 	if err != nil {
 		return nil, fmt.Errorf("failed to create hmac: %w", err)
 	}
@@ -258,6 +283,58 @@ func (r *Realm) ListRecentCodes(db *Database, user *User) ([]*VerificationCode,
 	return codes, nil
 }
 
+// ListCodeMetadata lists the codes issued in the realm between start and end,
+// oldest first, for reconciliation against external systems. The code and
+// longCode are always cleared, only metadata is returned. Each page that is
+// listed is recorded in the realm's audit log.
+func (r *Realm) ListCodeMetadata(db *Database, start, end time.Time, p *pagination.PageParams, actor Auditable) ([]*VerificationCode, *pagination.Paginator, error) {
+	if actor == nil {
+		return nil, nil, ErrMissingActor
+	}
+
+	if end.Before(start) {
+		return nil, nil, ErrBadDateRange
+	}
+
+	if p == nil {
+		p = new(pagination.PageParams)
+	}
+
+	var codes []*VerificationCode
+	var paginator *pagination.Paginator
+	if err := db.db.Transaction(func(tx *gorm.DB) error {
+		query := tx.
+			Model(&VerificationCode{}).
+			Where("realm_id = ?", r.ID).
+			Where("created_at >= ? AND created_at <= ?", start, end).
+			Order("created_at ASC, id ASC")
+
+		var err error
+		paginator, err = Paginate(query, &codes, p.Page, p.Limit)
+		if err != nil && !IsNotFound(err) {
+			return fmt.Errorf("failed to list verification codes: %w", err)
+		}
+
+		audit := BuildAuditEntry(actor, "downloaded issued code metadata", r, r.ID)
+		audit.Diff = fmt.Sprintf("%d codes issued between %s and %s",
+			len(codes), start.UTC().Format(time.RFC3339), end.UTC().Format(time.RFC3339))
+		if err := tx.Save(audit).Error; err != nil {
+			return fmt.Errorf("failed to save audits: %w", err)
+		}
+		return nil
+	}); err != nil {
+		return nil, nil, err
+	}
+
+	// Never return the codes themselves, only metadata.
+	for _, t := range codes {
+		t.Code = ""
+		t.LongCode = ""
+	}
+
+	return codes, paginator, nil
+}
+
 // ExpireCode saves a verification code as expired.
 func (r *Realm) ExpireCode(db *Database, uuid string, actor Auditable) (*VerificationCode, error) {
 	if actor == nil {
@@ -332,6 +409,7 @@ func (r *Realm) SaveVerificationCode(db *Database, vc *VerificationCode) error {
 			if err != nil {
 				return fmt.Errorf("newUserReport: %w", err)
 			}
+			userReport.RealmID = r.ID
 			if err := tx.Create(userReport).Error; err != nil {
 				return ErrAlreadyReported
 			}
@@ -339,6 +417,19 @@ func (r *Realm) SaveVerificationCode(db *Database, vc *VerificationCode) error {
 		if userReport != nil {
 			vc.UserReportID = &userReport.ID
 			vc.LongExpiresAt = vc.ExpiresAt // Self report expiration codes are all short.
+
+			if vc.ConsentVersion > 0 {
+				acceptance := &UserReportConsentAcceptance{
+					RealmID:      r.ID,
+					UserReportID: userReport.ID,
+					Version:      vc.ConsentVersion,
+					Locale:       vc.ConsentLocale,
+					AcceptedAt:   time.Now().UTC(),
+				}
+				if err := tx.Create(acceptance).Error; err != nil {
+					return fmt.Errorf("failed to record consent acceptance: %w", err)
+				}
+			}
 		}
 
 		if vc.ID == 0 {
@@ -374,7 +465,7 @@ func (db *Database) UpdateStats(ctx context.Context, codes ...*VerificationCode)
 	}
 	logger := logging.FromContext(ctx).Named("issueapi.recordStats")
 	v := codes[0]
-	date := timeutils.UTCMidnight(v.CreatedAt)
+	date := db.realmStatsMidnight(v.RealmID, v.CreatedAt)
 
 	// If the issuer was a user, update the user stats for the day.
 	if v.IssuingUserID != 0 {
@@ -479,6 +570,33 @@ func (db *Database) GenerateVerificationCodeHMAC(verCode string) (string, error)
 	return initialHMAC(keys, verCode)
 }
 
+// VerificationCodeHMACKeyID returns a short, non-reversible identifier for the
+// given verification code database HMAC key.
+func VerificationCodeHMACKeyID(key []byte) string {
+	sum := sha256.Sum256(key)
+	return base64.RawURLEncoding.EncodeToString(sum[:8])
+}
+
+// CountOutstandingVerificationCodesForHMACKey returns the number of unclaimed,
+// unexpired verification codes that were HMACed with the key identified by
+// keyID. Codes created before key IDs were recorded are always counted, since
+// the key that protects them is unknown.
+func (db *Database) CountOutstandingVerificationCodesForHMACKey(keyID string) (int64, error) {
+	now := time.Now().UTC()
+
+	var count int64
+	if err := db.db.
+		Model(&VerificationCode{}).
+		Where("claimed IS FALSE").
+		Where("(expires_at > ? OR long_expires_at > ?)", now, now).
+		Where("(hmac_key_id = ? OR hmac_key_id IS NULL OR hmac_key_id = '')", keyID).
+		Count(&count).
+		Error; err != nil {
+		return 0, fmt.Errorf("failed to count outstanding verification codes: %w", err)
+	}
+	return count, nil
+}
+
 // generateVerificationCodeHMACs is a helper for generating all possible HMACs of a
 // token.
 func (db *Database) generateVerificationCodeHMACs(v string) ([]string, error) {
@@ -0,0 +1,125 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package database
+
+import (
+	"errors"
+	"fmt"
+
+	verifyapi "github.com/google/exposure-notifications-server/pkg/api/v1"
+	"github.com/jinzhu/gorm"
+)
+
+var (
+	// ErrCodeResendLimit is the error returned when a code has already been
+	// resent the maximum number of times.
+	ErrCodeResendLimit = errors.New("code has been resent too many times")
+
+	// ErrCodeResendUserReport is the error returned when resending a user
+	// report code. The patient must request a new user report instead.
+	ErrCodeResendUserReport = errors.New("user report codes cannot be resent")
+
+	// ErrCodeResendConflict is the error returned when the new code or long code
+	// is already in use. The caller should generate new values and retry.
+	ErrCodeResendConflict = errors.New("new code is already in use")
+)
+
+// ResendCode replaces the code and long code of the unclaimed, unexpired code
+// with the given UUID, so that they can be sent to the patient again. Only the
+// HMACs of codes are stored, so the original values cannot be resent. The code
+// keeps its UUID, expiration, and metadata, and is not counted as a new
+// issuance. The previous values stop working immediately.
+//
+// The given code and longCode must be unformatted. Codes cannot be resent more
+// than maxResends times.
+func (r *Realm) ResendCode(db *Database, uuid, code, longCode string, maxResends uint, actor Auditable) (*VerificationCode, error) {
+	if actor == nil {
+		return nil, ErrMissingActor
+	}
+
+	codeHMAC, err := db.GenerateVerificationCodeHMAC(code)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate code hmac: %w", err)
+	}
+	longCodeHMAC, err := db.GenerateVerificationCodeHMAC(longCode)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate long code hmac: %w", err)
+	}
+
+	keys, err := db.GetVerificationCodeDatabaseHMAC()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get verification code hmac keys: %w", err)
+	}
+	if len(keys) < 1 {
+		return nil, fmt.Errorf("expected at least 1 verification code hmac key")
+	}
+	keyID := VerificationCodeHMACKeyID(keys[0])
+
+	var vc VerificationCode
+	if err := db.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.
+			Set("gorm:query_option", "FOR UPDATE").
+			Where("realm_id = ? AND uuid = ?", r.ID, uuid).
+			First(&vc).
+			Error; err != nil {
+			return fmt.Errorf("failed to get existing verification code: %w", err)
+		}
+
+		if vc.Claimed {
+			return ErrCodeAlreadyClaimed
+		}
+		if vc.IsExpired() {
+			return ErrCodeAlreadyExpired
+		}
+		if vc.TestType == verifyapi.ReportTypeSelfReport {
+			return ErrCodeResendUserReport
+		}
+		if vc.ResendCount >= maxResends {
+			return ErrCodeResendLimit
+		}
+
+		if err := tx.
+			Model(&VerificationCode{}).
+			Where("id = ?", vc.ID).
+			UpdateColumns(map[string]interface{}{
+				"code":         codeHMAC,
+				"long_code":    longCodeHMAC,
+				"hmac_key_id":  keyID,
+				"resend_count": gorm.Expr("resend_count + 1"),
+			}).
+			Error; err != nil {
+			if IsUniqueViolation(err, VerCodesCodeUniqueIndex) || IsUniqueViolation(err, VerCodesLongCodeUniqueIndex) {
+				return ErrCodeResendConflict
+			}
+			return fmt.Errorf("failed to resend verification code: %w", err)
+		}
+
+		audit := BuildAuditEntry(actor, "resent verification code", &vc, r.ID)
+		audit.Diff = stringDiff(fmt.Sprintf("%d resends", vc.ResendCount), fmt.Sprintf("%d resends", vc.ResendCount+1))
+		if err := tx.Save(audit).Error; err != nil {
+			return fmt.Errorf("failed to save audits: %w", err)
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	// Return the values to send to the patient, never the HMACs.
+	vc.Code = r.FormatCode(code)
+	vc.LongCode = longCode
+	vc.HMACKeyID = keyID
+	vc.ResendCount++
+	return &vc, nil
+}
@@ -0,0 +1,175 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package database
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	verifyapi "github.com/google/exposure-notifications-server/pkg/api/v1"
+)
+
+func TestRealm_ResendCode(t *testing.T) {
+	t.Parallel()
+
+	db, _ := testDatabaseInstance.NewDatabase(t, nil)
+	realm, err := db.FindRealm(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	newCode := func(t *testing.T, code, longCode string, expiresAt time.Time) *VerificationCode {
+		t.Helper()
+
+		vc := &VerificationCode{
+			RealmID:       realm.ID,
+			Code:          code,
+			LongCode:      longCode,
+			TestType:      "confirmed",
+			ExpiresAt:     expiresAt,
+			LongExpiresAt: expiresAt.Add(time.Hour),
+		}
+		if err := realm.SaveVerificationCode(db, vc); err != nil {
+			t.Fatal(err)
+		}
+		return vc
+	}
+
+	t.Run("missing_actor", func(t *testing.T) {
+		t.Parallel()
+
+		if _, err := realm.ResendCode(db, "", "11111111", "1111111111111111", 1, nil); !errors.Is(err, ErrMissingActor) {
+			t.Errorf("expected %v to be %v", err, ErrMissingActor)
+		}
+	})
+
+	t.Run("not_found", func(t *testing.T) {
+		t.Parallel()
+
+		if _, err := realm.ResendCode(db, "7a2d3b46-7ccb-4a5e-8d5a-bb1ad1a9b7b1", "11111112", "1111111111111112", 1, SystemTest); !IsNotFound(err) {
+			t.Errorf("expected not found, got %v", err)
+		}
+	})
+
+	t.Run("expired", func(t *testing.T) {
+		t.Parallel()
+
+		vc := newCode(t, "22222221", "2222222222222221", time.Now().Add(time.Hour))
+		past := time.Now().Add(-time.Minute)
+		if err := db.RawDB().Model(vc).UpdateColumns(map[string]interface{}{
+			"expires_at":      past,
+			"long_expires_at": past,
+		}).Error; err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := realm.ResendCode(db, vc.UUID, "22222222", "2222222222222222", 1, SystemTest); !errors.Is(err, ErrCodeAlreadyExpired) {
+			t.Errorf("expected %v to be %v", err, ErrCodeAlreadyExpired)
+		}
+	})
+
+	t.Run("claimed", func(t *testing.T) {
+		t.Parallel()
+
+		vc := newCode(t, "33333331", "3333333333333331", time.Now().Add(time.Hour))
+		if err := db.RawDB().Model(vc).UpdateColumn("claimed", true).Error; err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := realm.ResendCode(db, vc.UUID, "33333332", "3333333333333332", 1, SystemTest); !errors.Is(err, ErrCodeAlreadyClaimed) {
+			t.Errorf("expected %v to be %v", err, ErrCodeAlreadyClaimed)
+		}
+	})
+
+	t.Run("user_report", func(t *testing.T) {
+		t.Parallel()
+
+		vc := newCode(t, "44444441", "4444444444444441", time.Now().Add(time.Hour))
+		if err := db.RawDB().Model(vc).UpdateColumn("test_type", verifyapi.ReportTypeSelfReport).Error; err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := realm.ResendCode(db, vc.UUID, "44444442", "4444444444444442", 1, SystemTest); !errors.Is(err, ErrCodeResendUserReport) {
+			t.Errorf("expected %v to be %v", err, ErrCodeResendUserReport)
+		}
+	})
+
+	t.Run("conflict", func(t *testing.T) {
+		t.Parallel()
+
+		vc := newCode(t, "55555551", "5555555555555551", time.Now().Add(time.Hour))
+		newCode(t, "55555552", "5555555555555552", time.Now().Add(time.Hour))
+
+		if _, err := realm.ResendCode(db, vc.UUID, "55555552", "5555555555555553", 1, SystemTest); !errors.Is(err, ErrCodeResendConflict) {
+			t.Errorf("expected %v to be %v", err, ErrCodeResendConflict)
+		}
+	})
+
+	t.Run("resends", func(t *testing.T) {
+		t.Parallel()
+
+		vc := newCode(t, "66666661", "6666666666666661", time.Now().Add(time.Hour))
+
+		got, err := realm.ResendCode(db, vc.UUID, "66666662", "6666666666666662", 1, SystemTest)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := got.ResendCount, uint(1); got != want {
+			t.Errorf("expected %d to be %d", got, want)
+		}
+		if got, want := got.Code, realm.FormatCode("66666662"); got != want {
+			t.Errorf("expected %q to be %q", got, want)
+		}
+		if got, want := got.ExpiresAt.Unix(), vc.ExpiresAt.Unix(); got != want {
+			t.Errorf("expected expiration %d to be unchanged %d", got, want)
+		}
+
+		// The previous code no longer works, the new code does.
+		if _, err := realm.FindVerificationCode(db, "66666661"); !IsNotFound(err) {
+			t.Errorf("expected previous code to not be found, got %v", err)
+		}
+		found, err := realm.FindVerificationCode(db, "66666662")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := found.UUID, vc.UUID; got != want {
+			t.Errorf("expected %q to be %q", got, want)
+		}
+		if _, err := realm.FindVerificationCode(db, "6666666666666662"); err != nil {
+			t.Errorf("expected new long code to be found, got %v", err)
+		}
+
+		// The limit is enforced.
+		if _, err := realm.ResendCode(db, vc.UUID, "66666663", "6666666666666663", 1, SystemTest); !errors.Is(err, ErrCodeResendLimit) {
+			t.Errorf("expected %v to be %v", err, ErrCodeResendLimit)
+		}
+
+		// The resend was audited.
+		audits, _, err := realm.ListAudits(db, nil, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var audited bool
+		for _, a := range audits {
+			if a.Action == "resent verification code" && a.TargetDisplay == vc.UUID {
+				audited = true
+			}
+		}
+		if !audited {
+			t.Errorf("expected resend to be audited")
+		}
+	})
+}
@@ -0,0 +1,165 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package database
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+const (
+	// CodeStatusClaimed and CodeStatusUnclaimed are the statuses by which
+	// issued codes can be searched.
+	CodeStatusClaimed   = "claimed"
+	CodeStatusUnclaimed = "unclaimed"
+
+	// MaxCodeSearchLimit is the largest number of codes returned by a single
+	// search.
+	MaxCodeSearchLimit = 100
+)
+
+// WithCodeIssuingUserID returns a scope that filters codes by the user that
+// issued them. An ID of 0 does not filter.
+func WithCodeIssuingUserID(id uint) Scope {
+	return func(db *gorm.DB) *gorm.DB {
+		if id > 0 {
+			return db.Where("verification_codes.issuing_user_id = ?", id)
+		}
+		return db
+	}
+}
+
+// WithCodeIssuingAppID returns a scope that filters codes by the API key that
+// issued them. An ID of 0 does not filter.
+func WithCodeIssuingAppID(id uint) Scope {
+	return func(db *gorm.DB) *gorm.DB {
+		if id > 0 {
+			return db.Where("verification_codes.issuing_app_id = ?", id)
+		}
+		return db
+	}
+}
+
+// WithCodeTestType returns a scope that filters codes by test type. A blank
+// test type does not filter.
+func WithCodeTestType(testType string) Scope {
+	return func(db *gorm.DB) *gorm.DB {
+		if testType != "" {
+			return db.Where("verification_codes.test_type = ?", testType)
+		}
+		return db
+	}
+}
+
+// WithCodeStatus returns a scope that filters codes by whether they have been
+// claimed. An unknown or blank status does not filter.
+func WithCodeStatus(status string) Scope {
+	return func(db *gorm.DB) *gorm.DB {
+		switch status {
+		case CodeStatusClaimed:
+			return db.Where("verification_codes.claimed IS TRUE")
+		case CodeStatusUnclaimed:
+			return db.Where("verification_codes.claimed IS FALSE")
+		default:
+			return db
+		}
+	}
+}
+
+// WithCodeIssuedTime returns a scope that filters codes by the time they were
+// issued. Zero times do not filter.
+func WithCodeIssuedTime(from, to time.Time) Scope {
+	return func(db *gorm.DB) *gorm.DB {
+		if !from.IsZero() {
+			db = db.Where("verification_codes.created_at >= ?", from.UTC())
+		}
+		if !to.IsZero() {
+			db = db.Where("verification_codes.created_at <= ?", to.UTC())
+		}
+		return db
+	}
+}
+
+// WithCodeBeforeID returns a scope that filters codes to those with an ID less
+// than the given ID. It is used as the cursor for code searches.
+func WithCodeBeforeID(id uint) Scope {
+	return func(db *gorm.DB) *gorm.DB {
+		if id > 0 {
+			return db.Where("verification_codes.id < ?", id)
+		}
+		return db
+	}
+}
+
+// SearchCodes lists the codes issued in the realm that match the scopes,
+// newest first. It returns at most limit codes, and the cursor for the next
+// page, which is 0 if there are no more results. Pass the cursor to
+// WithCodeBeforeID to fetch the next page. The code and longCode are always
+// cleared, only metadata is returned. Each search is recorded in the realm's
+// audit log.
+func (r *Realm) SearchCodes(db *Database, limit uint, actor Auditable, scopes ...Scope) ([]*VerificationCode, uint, error) {
+	if actor == nil {
+		return nil, 0, ErrMissingActor
+	}
+
+	if limit == 0 || limit > MaxCodeSearchLimit {
+		limit = MaxCodeSearchLimit
+	}
+
+	var codes []*VerificationCode
+	if err := db.db.Transaction(func(tx *gorm.DB) error {
+		// Fetch one extra code to know if there is another page.
+		if err := tx.
+			Model(&VerificationCode{}).
+			Scopes(scopes...).
+			Where("verification_codes.realm_id = ?", r.ID).
+			Order("verification_codes.id DESC").
+			Limit(limit + 1).
+			Find(&codes).
+			Error; err != nil && !IsNotFound(err) {
+			return fmt.Errorf("failed to search verification codes: %w", err)
+		}
+
+		listed := len(codes)
+		if uint(listed) > limit {
+			listed = int(limit)
+		}
+
+		audit := BuildAuditEntry(actor, "searched issued codes", r, r.ID)
+		audit.Diff = fmt.Sprintf("%d codes listed", listed)
+		if err := tx.Save(audit).Error; err != nil {
+			return fmt.Errorf("failed to save audits: %w", err)
+		}
+		return nil
+	}); err != nil {
+		return nil, 0, err
+	}
+
+	var cursor uint
+	if uint(len(codes)) > limit {
+		codes = codes[:limit]
+		cursor = codes[len(codes)-1].ID
+	}
+
+	// Never return the codes themselves, only metadata.
+	for _, t := range codes {
+		t.Code = ""
+		t.LongCode = ""
+	}
+
+	return codes, cursor, nil
+}
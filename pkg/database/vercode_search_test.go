@@ -0,0 +1,139 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package database
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestRealm_SearchCodes(t *testing.T) {
+	t.Parallel()
+
+	db, _ := testDatabaseInstance.NewDatabase(t, nil)
+	realm := NewRealmWithDefaults("search-codes")
+	if err := db.SaveRealm(realm, SystemTest); err != nil {
+		t.Fatal(err)
+	}
+
+	app := &AuthorizedApp{
+		RealmID: realm.ID,
+		Name:    "Searcher",
+	}
+	if _, err := realm.CreateAuthorizedApp(db, app, SystemTest); err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now().UTC()
+	testTypes := []string{"confirmed", "likely", "negative", "confirmed", "confirmed"}
+	for i, testType := range testTypes {
+		vc := &VerificationCode{
+			RealmID:       realm.ID,
+			Code:          fmt.Sprintf("5678%02d", i),
+			LongCode:      fmt.Sprintf("searchcodes%02d", i),
+			TestType:      testType,
+			Claimed:       i == 0,
+			ExpiresAt:     now.Add(time.Hour),
+			LongExpiresAt: now.Add(2 * time.Hour),
+		}
+		if i%2 == 0 {
+			vc.IssuingAppID = app.ID
+		}
+		if err := realm.SaveVerificationCode(db, vc); err != nil {
+			t.Fatal(err, vc.ErrorMessages())
+		}
+	}
+
+	t.Run("missing_actor", func(t *testing.T) {
+		t.Parallel()
+
+		if _, _, err := realm.SearchCodes(db, 0, nil); !errors.Is(err, ErrMissingActor) {
+			t.Errorf("expected %v to be %v", err, ErrMissingActor)
+		}
+	})
+
+	t.Run("filters", func(t *testing.T) {
+		t.Parallel()
+
+		cases := []struct {
+			name   string
+			scopes []Scope
+			want   int
+		}{
+			{"all", nil, 5},
+			{"app", []Scope{WithCodeIssuingAppID(app.ID)}, 3},
+			{"user", []Scope{WithCodeIssuingUserID(999999)}, 0},
+			{"test_type", []Scope{WithCodeTestType("confirmed")}, 3},
+			{"claimed", []Scope{WithCodeStatus(CodeStatusClaimed)}, 1},
+			{"unclaimed", []Scope{WithCodeStatus(CodeStatusUnclaimed)}, 4},
+			{"unknown_status", []Scope{WithCodeStatus("nope")}, 5},
+			{"in_range", []Scope{WithCodeIssuedTime(now.Add(-time.Hour), now.Add(time.Hour))}, 5},
+			{"out_of_range", []Scope{WithCodeIssuedTime(now.Add(time.Hour), time.Time{})}, 0},
+			{"combined", []Scope{WithCodeIssuingAppID(app.ID), WithCodeTestType("confirmed"), WithCodeStatus(CodeStatusUnclaimed)}, 1},
+		}
+
+		for _, tc := range cases {
+			tc := tc
+
+			t.Run(tc.name, func(t *testing.T) {
+				t.Parallel()
+
+				codes, cursor, err := realm.SearchCodes(db, 0, SystemTest, tc.scopes...)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got, want := len(codes), tc.want; got != want {
+					t.Errorf("expected %d codes to be %d", got, want)
+				}
+				if cursor != 0 {
+					t.Errorf("expected no cursor, got %d", cursor)
+				}
+			})
+		}
+	})
+
+	t.Run("paginates", func(t *testing.T) {
+		t.Parallel()
+
+		var seen []uint
+		var cursor uint
+		for i := 0; i < 10; i++ {
+			codes, next, err := realm.SearchCodes(db, 2, SystemTest, WithCodeBeforeID(cursor))
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, code := range codes {
+				if code.Code != "" || code.LongCode != "" {
+					t.Errorf("expected code and long code to be cleared: %#v", code)
+				}
+				if len(seen) > 0 && code.ID >= seen[len(seen)-1] {
+					t.Errorf("expected codes newest first, got %d after %d", code.ID, seen[len(seen)-1])
+				}
+				seen = append(seen, code.ID)
+			}
+
+			if next == 0 {
+				break
+			}
+			cursor = next
+		}
+
+		if got, want := len(seen), 5; got != want {
+			t.Errorf("expected %d codes to be %d", got, want)
+		}
+	})
+}
@@ -23,6 +23,7 @@ import (
 
 	"github.com/google/exposure-notifications-verification-server/internal/project"
 	"github.com/google/exposure-notifications-verification-server/pkg/cache"
+	"github.com/google/exposure-notifications-verification-server/pkg/pagination"
 	"github.com/google/uuid"
 	"github.com/jinzhu/gorm"
 )
@@ -231,6 +232,88 @@ func TestVerificationCode_ListRecentCodes(t *testing.T) {
 	}
 }
 
+func TestRealm_ListCodeMetadata(t *testing.T) {
+	t.Parallel()
+
+	db, _ := testDatabaseInstance.NewDatabase(t, nil)
+	realm, err := db.FindRealm(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now().UTC()
+	for i := 0; i < 3; i++ {
+		vc := &VerificationCode{
+			RealmID:           realm.ID,
+			Code:              fmt.Sprintf("1234%02d", i),
+			LongCode:          fmt.Sprintf("abcdefghijk%02d", i),
+			TestType:          "confirmed",
+			IssuingExternalID: fmt.Sprintf("lab-%d", i),
+			ExpiresAt:         now.Add(time.Hour),
+			LongExpiresAt:     now.Add(2 * time.Hour),
+		}
+		if err := realm.SaveVerificationCode(db, vc); err != nil {
+			t.Fatal(err, vc.ErrorMessages())
+		}
+	}
+
+	t.Run("missing_actor", func(t *testing.T) {
+		t.Parallel()
+
+		if _, _, err := realm.ListCodeMetadata(db, now, now, nil, nil); !errors.Is(err, ErrMissingActor) {
+			t.Errorf("expected %v to be %v", err, ErrMissingActor)
+		}
+	})
+
+	t.Run("bad_range", func(t *testing.T) {
+		t.Parallel()
+
+		if _, _, err := realm.ListCodeMetadata(db, now, now.Add(-time.Hour), nil, SystemTest); !errors.Is(err, ErrBadDateRange) {
+			t.Errorf("expected %v to be %v", err, ErrBadDateRange)
+		}
+	})
+
+	t.Run("lists", func(t *testing.T) {
+		t.Parallel()
+
+		codes, _, err := realm.ListCodeMetadata(db, now.Add(-time.Hour), now.Add(time.Hour),
+			&pagination.PageParams{Page: 1, Limit: 2}, SystemTest)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if got, want := len(codes), 2; got != want {
+			t.Fatalf("expected %d codes to be %d", got, want)
+		}
+		for _, code := range codes {
+			if code.Code != "" || code.LongCode != "" {
+				t.Errorf("expected code and long code to be cleared: %#v", code)
+			}
+			if code.UUID == "" {
+				t.Errorf("expected uuid")
+			}
+		}
+		if got, want := codes[0].IssuingExternalID, "lab-0"; got != want {
+			t.Errorf("expected %q to be %q", got, want)
+		}
+
+		audits, _, err := realm.ListAudits(db, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var found bool
+		for _, audit := range audits {
+			if audit.Action == "downloaded issued code metadata" {
+				found = true
+			}
+		}
+		if !found {
+			t.Errorf("expected audit entry for download")
+		}
+	})
+}
+
 func TestVerificationCode_ExpireVerificationCode(t *testing.T) {
 	t.Parallel()
 
@@ -276,6 +359,66 @@ func TestVerificationCode_ExpireVerificationCode(t *testing.T) {
 	}
 }
 
+func TestCountOutstandingVerificationCodesForHMACKey(t *testing.T) {
+	t.Parallel()
+
+	db, _ := testDatabaseInstance.NewDatabase(t, nil)
+	realm, err := db.FindRealm(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keys, err := db.GetVerificationCodeDatabaseHMAC()
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyID := VerificationCodeHMACKeyID(keys[0])
+
+	vc := &VerificationCode{
+		RealmID:       realm.ID,
+		Code:          "123456",
+		LongCode:      "defghijk329024",
+		TestType:      "confirmed",
+		ExpiresAt:     time.Now().Add(time.Hour),
+		LongExpiresAt: time.Now().Add(2 * time.Hour),
+	}
+	if err := realm.SaveVerificationCode(db, vc); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := vc.HMACKeyID, keyID; got != want {
+		t.Errorf("expected hmac key id %q to be %q", got, want)
+	}
+
+	count, err := db.CountOutstandingVerificationCodesForHMACKey(keyID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := count, int64(1); got != want {
+		t.Errorf("expected %d outstanding codes, got %d", want, got)
+	}
+
+	count, err = db.CountOutstandingVerificationCodesForHMACKey(VerificationCodeHMACKeyID([]byte("other")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := count, int64(0); got != want {
+		t.Errorf("expected %d outstanding codes, got %d", want, got)
+	}
+
+	// Claimed codes no longer hold the key.
+	vc.Claimed = true
+	if err := realm.SaveVerificationCode(db, vc); err != nil {
+		t.Fatal(err)
+	}
+	count, err = db.CountOutstandingVerificationCodesForHMACKey(keyID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := count, int64(0); got != want {
+		t.Errorf("expected %d outstanding codes, got %d", want, got)
+	}
+}
+
 func TestSaveUserReport(t *testing.T) {
 	t.Parallel()
 
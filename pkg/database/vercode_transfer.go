@@ -0,0 +1,190 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package database
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+var (
+	// ErrCodeTransferSameRealm is the error returned when a code is transferred
+	// to the realm that already owns it.
+	ErrCodeTransferSameRealm = errors.New("code is already in the destination realm")
+
+	// ErrCodeTransferConsent is the error returned when a code is transferred
+	// without the patient's consent.
+	ErrCodeTransferConsent = errors.New("patient consent is required to transfer a code")
+
+	// ErrCodeTransferTestType is the error returned when the destination realm
+	// does not allow the code's test type.
+	ErrCodeTransferTestType = errors.New("destination realm does not allow the code's test type")
+
+	// ErrCodeTransferConflict is the error returned when the code is already in
+	// use in the destination realm. The code must be reissued instead.
+	ErrCodeTransferConflict = errors.New("code is already in use in the destination realm")
+)
+
+// VerificationCodeTransfer records that an unclaimed verification code was
+// moved from one realm to another, usually because the patient's case was
+// reassigned to a different jurisdiction. Like audit entries, transfers do not
+// use foreign keys, so they remain after the code is purged.
+type VerificationCodeTransfer struct {
+	// ID is the transfer's ID.
+	ID uint `gorm:"primary_key;"`
+
+	// VerificationCodeUUID is the UUID of the transferred code.
+	VerificationCodeUUID string `gorm:"column:verification_code_uuid; type:uuid; not null;"`
+
+	// FromRealmID and ToRealmID are the source and destination realms.
+	FromRealmID uint `gorm:"column:from_realm_id; type:integer; not null;"`
+	ToRealmID   uint `gorm:"column:to_realm_id; type:integer; not null;"`
+
+	// ActorID and ActorDisplay identify who performed the transfer, in the same
+	// format as audit entries.
+	ActorID      string `gorm:"column:actor_id; type:text; not null;"`
+	ActorDisplay string `gorm:"column:actor_display; type:text; not null;"`
+
+	// PatientConsent records that the patient consented to their code being
+	// handled by the destination realm.
+	PatientConsent bool `gorm:"column:patient_consent; type:bool; not null; default:false;"`
+
+	// Reason is an optional note explaining the transfer.
+	Reason string `gorm:"column:reason; type:text; not null; default:'';"`
+
+	// CreatedAt is when the transfer occurred.
+	CreatedAt time.Time
+}
+
+// TableName sets the table name.
+func (VerificationCodeTransfer) TableName() string {
+	return "verification_code_transfers"
+}
+
+// TransferCode moves the unclaimed, unexpired code with the given UUID from
+// this realm to the destination realm. The code keeps its value, expiration,
+// and metadata, so the patient can still use the code they were given. The
+// transfer is recorded, along with an entry in both realms' audit logs.
+func (r *Realm) TransferCode(db *Database, uuid string, to *Realm, consent bool, reason string, actor Auditable) (*VerificationCode, error) {
+	if actor == nil {
+		return nil, ErrMissingActor
+	}
+	if to == nil || to.ID == r.ID {
+		return nil, ErrCodeTransferSameRealm
+	}
+	if !consent {
+		return nil, ErrCodeTransferConsent
+	}
+
+	var vc VerificationCode
+	if err := db.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.
+			Set("gorm:query_option", "FOR UPDATE").
+			Where("realm_id = ? AND uuid = ?", r.ID, uuid).
+			First(&vc).
+			Error; err != nil {
+			return fmt.Errorf("failed to get existing verification code: %w", err)
+		}
+
+		if vc.Claimed {
+			return ErrCodeAlreadyClaimed
+		}
+		if vc.IsExpired() {
+			return ErrCodeAlreadyExpired
+		}
+		if !to.ValidTestType(vc.TestType) {
+			return ErrCodeTransferTestType
+		}
+
+		if err := tx.
+			Model(&VerificationCode{}).
+			Where("id = ?", vc.ID).
+			UpdateColumn("realm_id", to.ID).
+			Error; err != nil {
+			if IsUniqueViolation(err, VerCodesCodeUniqueIndex) || IsUniqueViolation(err, VerCodesLongCodeUniqueIndex) {
+				return ErrCodeTransferConflict
+			}
+			return fmt.Errorf("failed to transfer verification code: %w", err)
+		}
+		vc.RealmID = to.ID
+
+		transfer := &VerificationCodeTransfer{
+			VerificationCodeUUID: vc.UUID,
+			FromRealmID:          r.ID,
+			ToRealmID:            to.ID,
+			ActorID:              actor.AuditID(),
+			ActorDisplay:         actor.AuditDisplay(),
+			PatientConsent:       consent,
+			Reason:               reason,
+		}
+		if err := tx.Create(transfer).Error; err != nil {
+			return fmt.Errorf("failed to record transfer: %w", err)
+		}
+
+		diff := stringDiff(r.Name, to.Name)
+
+		audit := BuildAuditEntry(actor, "transferred verification code out", &vc, r.ID)
+		audit.Diff = diff
+		if err := tx.Save(audit).Error; err != nil {
+			return fmt.Errorf("failed to save audits: %w", err)
+		}
+
+		audit = BuildAuditEntry(actor, "transferred verification code in", &vc, to.ID)
+		audit.Diff = diff
+		if err := tx.Save(audit).Error; err != nil {
+			return fmt.Errorf("failed to save audits: %w", err)
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return &vc, nil
+}
+
+// ListVerificationCodeTransfers lists the transfers of the code with the given
+// UUID, oldest first.
+func (db *Database) ListVerificationCodeTransfers(uuid string) ([]*VerificationCodeTransfer, error) {
+	var transfers []*VerificationCodeTransfer
+	if err := db.db.
+		Model(&VerificationCodeTransfer{}).
+		Where("verification_code_uuid = ?", uuid).
+		Order("created_at ASC, id ASC").
+		Find(&transfers).
+		Error; err != nil {
+		if IsNotFound(err) {
+			return transfers, nil
+		}
+		return nil, err
+	}
+	return transfers, nil
+}
+
+// PurgeVerificationCodeTransfers deletes transfers that were created longer
+// than maxAge ago.
+func (db *Database) PurgeVerificationCodeTransfers(maxAge time.Duration) (int64, error) {
+	if maxAge > 0 {
+		maxAge = -1 * maxAge
+	}
+	createdBefore := time.Now().UTC().Add(maxAge)
+
+	result := db.db.
+		Unscoped().
+		Where("created_at < ?", createdBefore).
+		Delete(&VerificationCodeTransfer{})
+	return result.RowsAffected, result.Error
+}
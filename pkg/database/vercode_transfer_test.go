@@ -0,0 +1,169 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package database
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRealm_TransferCode(t *testing.T) {
+	t.Parallel()
+
+	db, _ := testDatabaseInstance.NewDatabase(t, nil)
+	realm, err := db.FindRealm(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	other := NewRealmWithDefaults("Other jurisdiction")
+	if err := db.SaveRealm(other, SystemTest); err != nil {
+		t.Fatal(err)
+	}
+
+	newCode := func(t *testing.T, r *Realm, code, longCode string) *VerificationCode {
+		t.Helper()
+
+		vc := &VerificationCode{
+			RealmID:       r.ID,
+			Code:          code,
+			LongCode:      longCode,
+			TestType:      "confirmed",
+			ExpiresAt:     time.Now().Add(time.Hour),
+			LongExpiresAt: time.Now().Add(2 * time.Hour),
+		}
+		if err := r.SaveVerificationCode(db, vc); err != nil {
+			t.Fatal(err)
+		}
+		return vc
+	}
+
+	t.Run("missing_actor", func(t *testing.T) {
+		t.Parallel()
+
+		if _, err := realm.TransferCode(db, "", other, true, "", nil); !errors.Is(err, ErrMissingActor) {
+			t.Errorf("expected %v to be %v", err, ErrMissingActor)
+		}
+	})
+
+	t.Run("same_realm", func(t *testing.T) {
+		t.Parallel()
+
+		if _, err := realm.TransferCode(db, "", realm, true, "", SystemTest); !errors.Is(err, ErrCodeTransferSameRealm) {
+			t.Errorf("expected %v to be %v", err, ErrCodeTransferSameRealm)
+		}
+	})
+
+	t.Run("no_consent", func(t *testing.T) {
+		t.Parallel()
+
+		if _, err := realm.TransferCode(db, "", other, false, "", SystemTest); !errors.Is(err, ErrCodeTransferConsent) {
+			t.Errorf("expected %v to be %v", err, ErrCodeTransferConsent)
+		}
+	})
+
+	t.Run("conflict", func(t *testing.T) {
+		t.Parallel()
+
+		vc := newCode(t, realm, "11111111", "1111111111111111")
+		newCode(t, other, "11111111", "2222222222222222")
+
+		if _, err := realm.TransferCode(db, vc.UUID, other, true, "", SystemTest); !errors.Is(err, ErrCodeTransferConflict) {
+			t.Errorf("expected %v to be %v", err, ErrCodeTransferConflict)
+		}
+
+		// The code is still in the source realm.
+		if _, err := realm.FindVerificationCodeByUUID(db, vc.UUID); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("transfers", func(t *testing.T) {
+		t.Parallel()
+
+		vc := newCode(t, realm, "33333333", "3333333333333333")
+
+		got, err := realm.TransferCode(db, vc.UUID, other, true, "case reassigned", SystemTest)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := got.RealmID, other.ID; got != want {
+			t.Errorf("expected %d to be %d", got, want)
+		}
+
+		// The code moved to the destination realm.
+		if _, err := realm.FindVerificationCodeByUUID(db, vc.UUID); !IsNotFound(err) {
+			t.Errorf("expected code to be removed from source realm, got %v", err)
+		}
+		moved, err := other.FindVerificationCodeByUUID(db, vc.UUID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := moved.ExpiresAt.Unix(), vc.ExpiresAt.Unix(); got != want {
+			t.Errorf("expected %d to be %d", got, want)
+		}
+
+		transfers, err := db.ListVerificationCodeTransfers(vc.UUID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := len(transfers), 1; got != want {
+			t.Fatalf("expected %d to be %d", got, want)
+		}
+		if got, want := transfers[0].FromRealmID, realm.ID; got != want {
+			t.Errorf("expected %d to be %d", got, want)
+		}
+		if got, want := transfers[0].ToRealmID, other.ID; got != want {
+			t.Errorf("expected %d to be %d", got, want)
+		}
+		if !transfers[0].PatientConsent {
+			t.Errorf("expected patient consent")
+		}
+		if got, want := transfers[0].Reason, "case reassigned"; got != want {
+			t.Errorf("expected %q to be %q", got, want)
+		}
+
+		// Both realms have an audit entry.
+		for _, r := range []*Realm{realm, other} {
+			var count int
+			if err := db.db.
+				Model(&AuditEntry{}).
+				Where("realm_id = ? AND target_id = ?", r.ID, vc.AuditID()).
+				Where("action LIKE ?", "transferred verification code%").
+				Count(&count).
+				Error; err != nil {
+				t.Fatal(err)
+			}
+			if got, want := count, 1; got != want {
+				t.Errorf("realm %d: expected %d to be %d", r.ID, got, want)
+			}
+		}
+	})
+
+	t.Run("claimed", func(t *testing.T) {
+		t.Parallel()
+
+		vc := newCode(t, realm, "44444444", "4444444444444444")
+		vc.Claimed = true
+		if err := db.db.Save(vc).Error; err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := realm.TransferCode(db, vc.UUID, other, true, "", SystemTest); !errors.Is(err, ErrCodeAlreadyClaimed) {
+			t.Errorf("expected %v to be %v", err, ErrCodeAlreadyClaimed)
+		}
+	})
+}
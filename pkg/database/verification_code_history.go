@@ -0,0 +1,151 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package database
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	verifyapi "github.com/google/exposure-notifications-server/pkg/api/v1"
+)
+
+const (
+	// CodeIssuerTypeUser indicates the code was issued by a user in the UI.
+	CodeIssuerTypeUser = "USER"
+
+	// CodeIssuerTypeAPI indicates the code was issued via the admin API.
+	CodeIssuerTypeAPI = "API"
+
+	// CodeIssuerTypeUserReport indicates the code was issued for a user report.
+	// These are identified by their test type.
+	CodeIssuerTypeUserReport = "USER_REPORT"
+
+	// CodeIssuerTypeUnknown indicates the issuer was deleted before the code
+	// was purged.
+	CodeIssuerTypeUnknown = "UNKNOWN"
+
+	// CodeOutcomeClaimed indicates the code was claimed.
+	CodeOutcomeClaimed = "CLAIMED"
+
+	// CodeOutcomeExpired indicates the code expired, or was expired early,
+	// without being claimed.
+	CodeOutcomeExpired = "EXPIRED"
+)
+
+// VerificationCodeHistory is a compact, anonymized record of a verification
+// code, written when the code is purged. It has no link to the code, its
+// issuer, or the person who received it, so it can be retained far longer than
+// the operational tables for program evaluation.
+type VerificationCodeHistory struct {
+	// ID is the history entry's ID.
+	ID uint `gorm:"primary_key;"`
+
+	// RealmID is the realm in which the code was issued.
+	RealmID uint `gorm:"column:realm_id; type:integer; not null;"`
+
+	// IssueDate is the UTC day on which the code was issued.
+	IssueDate time.Time `gorm:"column:issue_date; type:date; not null;"`
+
+	// IssuerType is how the code was issued, one of the CodeIssuerType
+	// constants.
+	IssuerType string `gorm:"column:issuer_type; type:text; not null;"`
+
+	// TestType is the test type of the code.
+	TestType string `gorm:"column:test_type; type:text; not null;"`
+
+	// Outcome is one of the CodeOutcome constants.
+	Outcome string `gorm:"column:outcome; type:text; not null;"`
+
+	// ClaimLatencyMinutes is the upper bound, in minutes, of the claim
+	// distribution bucket in which the code was claimed. It is nil if the code
+	// was not claimed.
+	ClaimLatencyMinutes *uint `gorm:"column:claim_latency_minutes; type:integer;"`
+
+	// OnsetClaimDays is the number of days from symptom onset, or the test date
+	// if there was no symptom onset date, to the day on which the code was
+	// claimed. It is nil if the code was not claimed or had neither date.
+	OnsetClaimDays *uint `gorm:"column:onset_claim_days; type:integer;"`
+}
+
+// TableName sets the table name.
+func (VerificationCodeHistory) TableName() string {
+	return "verification_code_history"
+}
+
+// ListVerificationCodeHistory returns the realm's code history for codes
+// issued between from and to, inclusive, ordered by issue date.
+func (r *Realm) ListVerificationCodeHistory(db *Database, from, to time.Time) ([]*VerificationCodeHistory, error) {
+	var history []*VerificationCodeHistory
+	if err := db.db.
+		Model(&VerificationCodeHistory{}).
+		Where("realm_id = ?", r.ID).
+		Where("issue_date >= ? AND issue_date <= ?", from, to).
+		Order("issue_date ASC, id ASC").
+		Find(&history).
+		Error; err != nil {
+		if IsNotFound(err) {
+			return history, nil
+		}
+		return nil, err
+	}
+	return history, nil
+}
+
+// PurgeVerificationCodeHistory deletes history for codes issued longer than
+// maxAge ago.
+func (db *Database) PurgeVerificationCodeHistory(maxAge time.Duration) (int64, error) {
+	if maxAge > 0 {
+		maxAge = -1 * maxAge
+	}
+	issuedBefore := time.Now().UTC().Add(maxAge)
+
+	result := db.db.
+		Unscoped().
+		Where("issue_date < ?", issuedBefore).
+		Delete(&VerificationCodeHistory{})
+	return result.RowsAffected, result.Error
+}
+
+// verificationCodeHistorySelect returns the SELECT list that converts rows
+// returned from a DELETE on verification_codes into history rows.
+func verificationCodeHistorySelect() string {
+	var latency strings.Builder
+	latency.WriteString("CASE")
+	for _, bucket := range claimDistributionBuckets {
+		fmt.Fprintf(&latency, " WHEN updated_at - created_at <= INTERVAL '%d seconds' THEN %d",
+			int64(bucket.Seconds()), int64(bucket.Minutes()))
+	}
+	fmt.Fprintf(&latency, " ELSE %d END",
+		int64(claimDistributionBuckets[len(claimDistributionBuckets)-1].Minutes()))
+
+	return fmt.Sprintf(`
+		realm_id,
+		(created_at AT TIME ZONE 'UTC')::date,
+		CASE
+			WHEN test_type = '%s' THEN '%s'
+			WHEN issuing_user_id IS NOT NULL THEN '%s'
+			WHEN issuing_app_id IS NOT NULL THEN '%s'
+			ELSE '%s'
+		END,
+		COALESCE(test_type, ''),
+		CASE WHEN claimed THEN '%s' ELSE '%s' END,
+		CASE WHEN claimed THEN %s END,
+		CASE WHEN claimed THEN GREATEST(
+			(updated_at AT TIME ZONE 'UTC')::date - (COALESCE(symptom_date, test_date) AT TIME ZONE 'UTC')::date, 0) END`,
+		verifyapi.ReportTypeSelfReport, CodeIssuerTypeUserReport, CodeIssuerTypeUser, CodeIssuerTypeAPI, CodeIssuerTypeUnknown,
+		CodeOutcomeClaimed, CodeOutcomeExpired,
+		latency.String())
+}
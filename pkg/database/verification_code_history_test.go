@@ -0,0 +1,139 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package database
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/exposure-notifications-verification-server/internal/project"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+)
+
+func TestDatabase_VerificationCodeHistory(t *testing.T) {
+	t.Parallel()
+
+	ctx := project.TestContext(t)
+	db, _ := testDatabaseInstance.NewDatabase(t, nil)
+
+	realm, err := db.FindRealm(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	user, err := db.FindUser(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	codes := []*VerificationCode{
+		{
+			RealmID:       realm.ID,
+			IssuingUserID: user.ID,
+			Code:          "123456",
+			LongCode:      "abcdefghijk123",
+			TestType:      "confirmed",
+			Claimed:       true,
+		},
+		{
+			RealmID:  realm.ID,
+			Code:     "654321",
+			LongCode: "abcdefghijk321",
+			TestType: "likely",
+		},
+	}
+
+	expired := time.Now().UTC().Add(-24 * time.Hour)
+	for _, code := range codes {
+		code.ExpiresAt = time.Now().UTC().Add(time.Hour)
+		code.LongExpiresAt = code.ExpiresAt
+		if err := realm.SaveVerificationCode(db, code); err != nil {
+			t.Fatal(err)
+		}
+		if err := db.db.Model(code).UpdateColumns(&VerificationCode{
+			ExpiresAt:     expired,
+			LongExpiresAt: expired,
+		}).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if n, err := db.PurgeRealmVerificationCodes(ctx, realm.ID, time.Nanosecond, 10); err != nil {
+		t.Fatal(err)
+	} else if got, want := n, int64(2); got != want {
+		t.Errorf("expected %d to be %d", got, want)
+	}
+
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	history, err := realm.ListVerificationCodeHistory(db, today.Add(-24*time.Hour), today.Add(24*time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	oneMinute := uint(1)
+	want := []*VerificationCodeHistory{
+		{
+			RealmID:             realm.ID,
+			IssuerType:          CodeIssuerTypeUser,
+			TestType:            "confirmed",
+			Outcome:             CodeOutcomeClaimed,
+			ClaimLatencyMinutes: &oneMinute,
+		},
+		{
+			RealmID:    realm.ID,
+			IssuerType: CodeIssuerTypeUnknown,
+			TestType:   "likely",
+			Outcome:    CodeOutcomeExpired,
+		},
+	}
+	opts := cmpopts.IgnoreFields(VerificationCodeHistory{}, "ID", "IssueDate")
+	if diff := cmp.Diff(want, history, opts); diff != "" {
+		t.Errorf("mismatch (-want, +got):\n%s", diff)
+	}
+
+	for _, h := range history {
+		if got, want := h.IssueDate.Format(project.RFC3339Date), today.Format(project.RFC3339Date); got != want {
+			t.Errorf("expected %q to be %q", got, want)
+		}
+	}
+}
+
+func TestDatabase_PurgeVerificationCodeHistory(t *testing.T) {
+	t.Parallel()
+
+	db, _ := testDatabaseInstance.NewDatabase(t, nil)
+
+	now := time.Now().UTC()
+	for _, issued := range []time.Time{now, now.Add(-10 * 24 * time.Hour), now.Add(-20 * 24 * time.Hour)} {
+		if err := db.db.Create(&VerificationCodeHistory{
+			RealmID:    1,
+			IssueDate:  issued,
+			IssuerType: CodeIssuerTypeAPI,
+			TestType:   "confirmed",
+			Outcome:    CodeOutcomeExpired,
+		}).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	n, err := db.PurgeVerificationCodeHistory(5 * 24 * time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := n, int64(2); got != want {
+		t.Errorf("expected %d to purge, got %d", want, got)
+	}
+}
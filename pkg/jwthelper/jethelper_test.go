@@ -15,9 +15,11 @@
 package jwthelper
 
 import (
+	"crypto"
 	"crypto/ecdsa"
 	"crypto/elliptic"
 	"crypto/rand"
+	"crypto/rsa"
 	"testing"
 	"time"
 
@@ -66,3 +68,80 @@ func TestSignJWT(t *testing.T) {
 		t.Fatalf("mismatch (-want, +got):\n%s", diff)
 	}
 }
+
+func TestSignJWT_Algorithms(t *testing.T) {
+	t.Parallel()
+
+	p256, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p384, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		name   string
+		signer crypto.Signer
+		alg    string
+	}{
+		{"es256", p256, AlgorithmES256},
+		{"es384", p384, AlgorithmES384},
+		{"rs256", rsaKey, AlgorithmRS256},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			claims := &jwt.StandardClaims{
+				Issuer:  "test_sign_jwt",
+				Subject: "unit_test",
+			}
+			token, err := NewWithClaims(tc.signer, claims)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got, want := token.Method.Alg(), tc.alg; got != want {
+				t.Errorf("expected %q to be %q", got, want)
+			}
+
+			signedJWT, err := SignJWT(token, tc.signer)
+			if err != nil {
+				t.Fatalf("failed to sign: %v", err)
+			}
+
+			parser := &jwt.Parser{ValidMethods: []string{tc.alg}}
+			gotToken, err := parser.ParseWithClaims(signedJWT, &jwt.StandardClaims{}, func(tok *jwt.Token) (interface{}, error) {
+				return tc.signer.Public(), nil
+			})
+			if err != nil {
+				t.Fatalf("failed to parse signed JWT: %v", err)
+			}
+			if diff := cmp.Diff(token.Claims, gotToken.Claims); diff != "" {
+				t.Fatalf("mismatch (-want, +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestSignJWT_MethodMismatch(t *testing.T) {
+	t.Parallel()
+
+	key, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodES256, &jwt.StandardClaims{})
+	if _, err := SignJWT(token, key); err == nil {
+		t.Fatal("expected error")
+	}
+}
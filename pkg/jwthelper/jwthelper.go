@@ -19,8 +19,12 @@ package jwthelper
 
 import (
 	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
 	"crypto/rand"
-	"crypto/sha256"
+	"crypto/rsa"
+	_ "crypto/sha256" // register SHA-256
+	_ "crypto/sha512" // register SHA-384
 	"encoding/asn1"
 	"fmt"
 	"math/big"
@@ -29,23 +33,128 @@ import (
 	"github.com/golang-jwt/jwt"
 )
 
-// keyBytes is 256 bits / 32 bytes.
-const keyBytes = 32
+const (
+	// AlgorithmES256 is ECDSA using P-256 and SHA-256. This is the default.
+	AlgorithmES256 = "ES256"
+
+	// AlgorithmES384 is ECDSA using P-384 and SHA-384.
+	AlgorithmES384 = "ES384"
+
+	// AlgorithmRS256 is RSASSA-PKCS1-v1_5 using SHA-256.
+	AlgorithmRS256 = "RS256"
+)
+
+// SupportedAlgorithms is the list of supported JWT signing algorithms, in
+// order of preference.
+var SupportedAlgorithms = []string{AlgorithmES256, AlgorithmES384, AlgorithmRS256}
+
+// IsSupportedAlgorithm returns true if the given algorithm is supported.
+func IsSupportedAlgorithm(alg string) bool {
+	for _, v := range SupportedAlgorithms {
+		if v == alg {
+			return true
+		}
+	}
+	return false
+}
+
+// SigningMethodForAlgorithm returns the JWT signing method for the given
+// algorithm name.
+func SigningMethodForAlgorithm(alg string) (jwt.SigningMethod, error) {
+	switch alg {
+	case AlgorithmES256:
+		return jwt.SigningMethodES256, nil
+	case AlgorithmES384:
+		return jwt.SigningMethodES384, nil
+	case AlgorithmRS256:
+		return jwt.SigningMethodRS256, nil
+	default:
+		return nil, fmt.Errorf("unsupported signing algorithm %q", alg)
+	}
+}
+
+// AlgorithmForKey returns the JWT algorithm name for the given public key.
+// ECDSA P-256 keys use ES256, ECDSA P-384 keys use ES384, and RSA keys use
+// RS256.
+func AlgorithmForKey(pub crypto.PublicKey) (string, error) {
+	switch typ := pub.(type) {
+	case *ecdsa.PublicKey:
+		switch typ.Curve {
+		case elliptic.P256():
+			return AlgorithmES256, nil
+		case elliptic.P384():
+			return AlgorithmES384, nil
+		default:
+			return "", fmt.Errorf("unsupported ecdsa curve %s", typ.Curve.Params().Name)
+		}
+	case *rsa.PublicKey:
+		return AlgorithmRS256, nil
+	default:
+		return "", fmt.Errorf("unsupported public key type %T", typ)
+	}
+}
+
+// SigningMethodForKey returns the JWT signing method for the given public key.
+func SigningMethodForKey(pub crypto.PublicKey) (jwt.SigningMethod, error) {
+	alg, err := AlgorithmForKey(pub)
+	if err != nil {
+		return nil, err
+	}
+	return SigningMethodForAlgorithm(alg)
+}
+
+// NewWithClaims creates a new JWT with the signing method that matches the
+// signer's public key.
+func NewWithClaims(signer crypto.Signer, claims jwt.Claims) (*jwt.Token, error) {
+	method, err := SigningMethodForKey(signer.Public())
+	if err != nil {
+		return nil, err
+	}
+	return jwt.NewWithClaims(method, claims), nil
+}
 
 // SignJWT takes a JWT structure, extracts the signing string and signs it with the
-// provided signer. The base64 serialized JWT is returned.
+// provided signer. The base64 serialized JWT is returned. The token's signing
+// method must match the signer's public key.
 func SignJWT(token *jwt.Token, signer crypto.Signer) (string, error) {
+	alg, err := AlgorithmForKey(signer.Public())
+	if err != nil {
+		return "", err
+	}
+	if got := token.Method.Alg(); got != alg {
+		return "", fmt.Errorf("token signing method %s does not match key algorithm %s", got, alg)
+	}
+
 	signingString, err := token.SigningString()
 	if err != nil {
 		return "", err
 	}
 
-	digest := sha256.Sum256([]byte(signingString))
-	sig, err := signer.Sign(rand.Reader, digest[:], nil)
+	var hash crypto.Hash
+	var keyBytes int
+	switch alg {
+	case AlgorithmES256:
+		hash, keyBytes = crypto.SHA256, 32
+	case AlgorithmES384:
+		hash, keyBytes = crypto.SHA384, 48
+	case AlgorithmRS256:
+		hash = crypto.SHA256
+	}
+
+	hasher := hash.New()
+	hasher.Write([]byte(signingString))
+	digest := hasher.Sum(nil)
+
+	sig, err := signer.Sign(rand.Reader, digest, hash)
 	if err != nil {
 		return "", fmt.Errorf("error signing token: %w", err)
 	}
 
+	// RSA PKCS #1 v1.5 signatures are used as-is.
+	if alg == AlgorithmRS256 {
+		return strings.Join([]string{signingString, jwt.EncodeSegment(sig)}, "."), nil
+	}
+
 	// Unpack the ASN1 signature. ECDSA signers are supposed to return this format
 	// https://golang.org/pkg/crypto/#Signer
 	// All supported signers in thise codebase are verified to return ASN1.
@@ -58,6 +167,8 @@ func SignJWT(token *jwt.Token, signer crypto.Signer) (string, error) {
 	// 1 .  Generate a digital signature of the JWS Signing Input using ECDSA
 	//      P-256 SHA-256 with the desired private key.  The output will be
 	//      the pair (R, S), where R and S are 256-bit unsigned integers.
+	//
+	// ES384 is identical, except R and S are 384-bit unsigned integers.
 	_, err = asn1.Unmarshal(sig, &parsedSig)
 	if err != nil {
 		return "", fmt.Errorf("unable to unmarshal signature: %w", err)
@@ -17,6 +17,7 @@ package keyutils
 import (
 	"crypto"
 	"crypto/ecdsa"
+	"crypto/rsa"
 	"crypto/x509"
 	"encoding/pem"
 	"fmt"
@@ -25,9 +26,8 @@ import (
 // EncodePublicKey returns the base64 encoded PEM block.
 func EncodePublicKey(publicKey crypto.PublicKey) (string, error) {
 	switch typ := publicKey.(type) {
-	case *ecdsa.PublicKey:
-		ecdsaKey := publicKey.(*ecdsa.PublicKey)
-		derBytes, err := x509.MarshalPKIXPublicKey(ecdsaKey)
+	case *ecdsa.PublicKey, *rsa.PublicKey:
+		derBytes, err := x509.MarshalPKIXPublicKey(typ)
 		if err != nil {
 			return "", fmt.Errorf("unable to parse public key: %w", err)
 		}
@@ -42,3 +42,24 @@ func EncodePublicKey(publicKey crypto.PublicKey) (string, error) {
 		return "", fmt.Errorf("unsupported public key type: %T", typ)
 	}
 }
+
+// ParsePublicKey parses a PEM-encoded public key, as returned by
+// EncodePublicKey.
+func ParsePublicKey(s string) (crypto.PublicKey, error) {
+	block, _ := pem.Decode([]byte(s))
+	if block == nil || block.Type != "PUBLIC KEY" {
+		return nil, fmt.Errorf("failed to decode PEM public key")
+	}
+
+	pub, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse public key: %w", err)
+	}
+
+	switch typ := pub.(type) {
+	case *ecdsa.PublicKey, *rsa.PublicKey:
+		return typ, nil
+	default:
+		return nil, fmt.Errorf("unsupported public key type: %T", typ)
+	}
+}
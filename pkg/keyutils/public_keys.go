@@ -76,3 +76,14 @@ func (c *PublicKeyCache) GetPublicKey(ctx context.Context, id string, kms keys.K
 		return fmt.Errorf("unknown public key type: %T", pub), nil
 	}
 }
+
+// GetManagedPublicKey returns the public key for a realm managed key. Keys that
+// were migrated to a different key manager carry their PEM-encoded public key,
+// since the key manager that holds them is no longer configured. Otherwise the
+// key is looked up by ID.
+func (c *PublicKeyCache) GetManagedPublicKey(ctx context.Context, id, migratedPublicKey string, kms keys.KeyManager) (crypto.PublicKey, error) {
+	if migratedPublicKey != "" {
+		return ParsePublicKey(migratedPublicKey)
+	}
+	return c.GetPublicKey(ctx, id, kms)
+}
@@ -0,0 +1,129 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package observability
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"contrib.go.opencensus.io/exporter/prometheus"
+	"github.com/google/exposure-notifications-server/pkg/logging"
+	enobs "github.com/google/exposure-notifications-server/pkg/observability"
+	"go.opencensus.io/stats/view"
+)
+
+// MetricsDoneFunc stops the metrics server.
+type MetricsDoneFunc func() error
+
+// Setup starts the observability exporter and, if metricsPort is not empty,
+// serves Prometheus metrics on that port. Every service sets up monitoring this
+// way at startup. The returned function stops both and logs any errors.
+func Setup(ctx context.Context, oeConfig *enobs.Config, metricsPort string) (func(), error) {
+	logger := logging.FromContext(ctx).Named("observability.Setup")
+
+	logger.Info("configuring observability exporter")
+	oe, err := enobs.NewFromEnv(ctx, oeConfig)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create ObservabilityExporter provider: %w", err)
+	}
+	if err := oe.StartExporter(); err != nil {
+		return nil, fmt.Errorf("error initializing observability exporter: %w", err)
+	}
+	logger.Infow("observability exporter", "config", oeConfig)
+
+	metricsDone, err := ServeMetrics(ctx, metricsPort)
+	if err != nil {
+		if err := oe.Close(); err != nil {
+			logger.Errorw("failed to close observability exporter", "error", err)
+		}
+		return nil, fmt.Errorf("failed to serve prometheus metrics: %w", err)
+	}
+
+	return func() {
+		if err := metricsDone(); err != nil {
+			logger.Errorw("failed to stop prometheus metrics", "error", err)
+		}
+		if err := oe.Close(); err != nil {
+			logger.Errorw("failed to close observability exporter", "error", err)
+		}
+	}, nil
+}
+
+// ServeMetrics serves all collected OpenCensus views in the Prometheus
+// exposition format at /metrics on the given port. It returns once the port is
+// bound, and the returned function stops the server. If port is empty, it does
+// nothing.
+func ServeMetrics(ctx context.Context, port string) (MetricsDoneFunc, error) {
+	if port == "" {
+		return func() error { return nil }, nil
+	}
+
+	listener, err := net.Listen("tcp", ":"+port)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on metrics port %s: %w", port, err)
+	}
+	return serveMetrics(ctx, listener)
+}
+
+// serveMetrics serves metrics on the listener, which it closes when the
+// returned function is called.
+func serveMetrics(ctx context.Context, listener net.Listener) (MetricsDoneFunc, error) {
+	logger := logging.FromContext(ctx).Named("observability.ServeMetrics")
+
+	// Views are only registered by some exporters (e.g. not NOOP). Registering
+	// the same view again is a no-op.
+	if err := view.Register(enobs.AllViews()...); err != nil {
+		listener.Close()
+		return nil, fmt.Errorf("failed to register views: %w", err)
+	}
+
+	exporter, err := prometheus.NewExporter(prometheus.Options{
+		OnError: func(err error) {
+			logger.Errorw("failed to export prometheus metrics", "error", err)
+		},
+	})
+	if err != nil {
+		listener.Close()
+		return nil, fmt.Errorf("failed to create prometheus exporter: %w", err)
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", exporter)
+	srv := &http.Server{
+		ReadHeaderTimeout: 10 * time.Second,
+		Handler:           mux,
+	}
+
+	go func() {
+		if err := srv.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.Errorw("failed to serve prometheus metrics", "error", err)
+		}
+	}()
+	logger.Infow("serving prometheus metrics", "addr", listener.Addr().String())
+
+	return func() error {
+		shutdownCtx, done := context.WithTimeout(context.Background(), 10*time.Second)
+		defer done()
+
+		if err := srv.Shutdown(shutdownCtx); err != nil {
+			return fmt.Errorf("failed to shutdown metrics server: %w", err)
+		}
+		return nil
+	}, nil
+}
@@ -0,0 +1,111 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package observability
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+
+	enobs "github.com/google/exposure-notifications-server/pkg/observability"
+	"github.com/google/exposure-notifications-verification-server/internal/project"
+	"go.opencensus.io/stats"
+	"go.opencensus.io/stats/view"
+)
+
+func TestSetup_noop(t *testing.T) {
+	t.Parallel()
+
+	ctx := project.TestContext(t)
+
+	stop, err := Setup(ctx, &enobs.Config{ExporterType: enobs.ExporterNoop}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	stop()
+}
+
+func TestServeMetrics_disabled(t *testing.T) {
+	t.Parallel()
+
+	ctx := project.TestContext(t)
+
+	done, err := ServeMetrics(ctx, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := done(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestServeMetrics_scrape(t *testing.T) {
+	t.Parallel()
+
+	ctx := project.TestContext(t)
+
+	testCount := stats.Int64(MetricRoot+"/test/scrape_count", "scrape test", stats.UnitDimensionless)
+	testView := &view.View{
+		Name:        MetricRoot + "/test/scrape_count",
+		Measure:     testCount,
+		Description: "Count of scrape test measurements",
+		Aggregation: view.Count(),
+	}
+	if err := view.Register(testView); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { view.Unregister(testView) })
+	stats.Record(context.Background(), testCount.M(1))
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	done, err := serveMetrics(ctx, listener)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if err := done(); err != nil {
+			t.Error(err)
+		}
+	})
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://"+listener.Addr().String()+"/metrics", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if got, want := resp.StatusCode, http.StatusOK; got != want {
+		t.Fatalf("expected %d to be %d", got, want)
+	}
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Prometheus replaces characters that are not allowed in metric names.
+	if got, want := string(b), "en_verification_server_test_scrape_count 1"; !strings.Contains(got, want) {
+		t.Errorf("expected metrics to contain %q:\n%s", want, got)
+	}
+}
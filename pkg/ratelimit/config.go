@@ -50,10 +50,39 @@ type Config struct {
 	// them in the rate limiter.
 	HMACKey envconfig.Base64Bytes `env:"RATE_LIMIT_HMAC_KEY, required"`
 
+	// FairShareTokens is the total number of API requests the server accepts
+	// per FairShareInterval before it starts shedding load from realms and API
+	// keys that use more than FairShareMax of it. Once FairShareSpikeThreshold
+	// of the capacity is used in an interval, requests from realms or API keys
+	// over their share are rejected until the interval resets. If 0, fair share
+	// limiting is disabled.
+	FairShareTokens         uint64        `env:"RATE_LIMIT_FAIR_SHARE_TOKENS, default=0"`
+	FairShareInterval       time.Duration `env:"RATE_LIMIT_FAIR_SHARE_INTERVAL, default=1m"`
+	FairShareMax            float64       `env:"RATE_LIMIT_FAIR_SHARE_MAX, default=0.25"`
+	FairShareSpikeThreshold float64       `env:"RATE_LIMIT_FAIR_SHARE_SPIKE_THRESHOLD, default=0.75"`
+
 	// Redis configuration
 	Redis redis.Config `env:",prefix=RATE_LIMIT_"`
 }
 
+// Validate validates the rate limiting configuration.
+func (c *Config) Validate() error {
+	if c.FairShareTokens == 0 {
+		return nil
+	}
+
+	if c.FairShareInterval <= 0 {
+		return fmt.Errorf("RATE_LIMIT_FAIR_SHARE_INTERVAL must be positive")
+	}
+	if c.FairShareMax <= 0 || c.FairShareMax > 1 {
+		return fmt.Errorf("RATE_LIMIT_FAIR_SHARE_MAX must be greater than 0 and at most 1")
+	}
+	if c.FairShareSpikeThreshold < 0 || c.FairShareSpikeThreshold > 1 {
+		return fmt.Errorf("RATE_LIMIT_FAIR_SHARE_SPIKE_THRESHOLD must be between 0 and 1")
+	}
+	return nil
+}
+
 // RateLimiterFor returns the rate limiter for the given type, or an error
 // if one does not exist.
 func RateLimiterFor(ctx context.Context, c *Config) (limiter.Store, error) {
@@ -0,0 +1,223 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package limitware
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/google/exposure-notifications-server/pkg/logging"
+	"github.com/google/exposure-notifications-verification-server/pkg/api"
+	"github.com/google/exposure-notifications-verification-server/pkg/controller"
+	"github.com/google/exposure-notifications-verification-server/pkg/digest"
+	"github.com/google/exposure-notifications-verification-server/pkg/ratelimit"
+	"github.com/google/exposure-notifications-verification-server/pkg/render"
+
+	"github.com/sethvargo/go-limiter"
+	"github.com/sethvargo/go-limiter/httplimit"
+	"go.opencensus.io/stats"
+	"go.opencensus.io/tag"
+)
+
+// FairShare is a second-tier limiter that protects shared deployments from
+// noisy neighbors. It counts all requests against the server's total capacity,
+// and each realm and API key against its share of that capacity. During a
+// spike, when most of the capacity for the interval is used, requests from a
+// realm or API key that has used up its share are shed until the interval
+// resets. Outside of spikes, no requests are rejected.
+//
+// Errors talking to the store fail open, since the per-key rate limits still
+// apply.
+type FairShare struct {
+	store    limiter.Store
+	scope    string
+	hmacKey  []byte
+	h        *render.Renderer
+	interval time.Duration
+
+	// tokens is the total capacity per interval, share is the capacity of each
+	// realm and API key, and spike is the number of used tokens above which
+	// load is shed.
+	tokens uint64
+	share  uint64
+	spike  uint64
+}
+
+// NewFairShare creates a fair share limiter from the configuration. If fair
+// share limiting is disabled in the configuration, the limiter allows all
+// requests.
+func NewFairShare(s limiter.Store, scope string, cfg *ratelimit.Config, h *render.Renderer) (*FairShare, error) {
+	if s == nil {
+		return nil, fmt.Errorf("store cannot be nil")
+	}
+
+	f := &FairShare{
+		store:    s,
+		scope:    scope,
+		hmacKey:  cfg.HMACKey,
+		h:        h,
+		interval: cfg.FairShareInterval,
+		tokens:   cfg.FairShareTokens,
+	}
+	if f.tokens == 0 {
+		return f, nil
+	}
+
+	f.share = uint64(math.Floor(float64(f.tokens) * cfg.FairShareMax))
+	if f.share == 0 {
+		f.share = 1
+	}
+	f.spike = uint64(math.Ceil(float64(f.tokens) * cfg.FairShareSpikeThreshold))
+	return f, nil
+}
+
+// fairShareTenant is a realm or API key whose share of capacity is tracked.
+type fairShareTenant struct {
+	kind string
+	key  string
+}
+
+// Handle returns the fair share limiter as a middleware. It must be installed
+// after the API key has been authenticated.
+func (f *FairShare) Handle(next http.Handler) http.Handler {
+	if f.tokens == 0 {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		logger := logging.FromContext(ctx).Named("ratelimit.FairShare")
+
+		tenants, err := f.tenants(ctx)
+		if err != nil {
+			logger.Errorw("failed to build fair share keys", "error", err)
+			next.ServeHTTP(w, r)
+			return
+		}
+		if len(tenants) == 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		spike, err := f.takeGlobal(ctx)
+		if err != nil {
+			logger.Errorw("failed to take from global capacity", "error", err)
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		for _, t := range tenants {
+			_, _, reset, ok, err := f.take(ctx, t.key, f.share)
+			if err != nil {
+				logger.Errorw("failed to take from fair share", "error", err)
+				continue
+			}
+
+			if !ok && spike {
+				logger.Warnw("shedding load", "tenant", t.kind, "key", t.key)
+				f.shed(ctx, w, t.kind, time.Unix(0, int64(reset)).UTC())
+				return
+			}
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// tenants returns the realm and API key on the request context.
+func (f *FairShare) tenants(ctx context.Context) ([]*fairShareTenant, error) {
+	var tenants []*fairShareTenant
+
+	if realm := controller.RealmFromContext(ctx); realm != nil {
+		tenants = append(tenants, &fairShareTenant{
+			kind: "realm",
+			key:  fmt.Sprintf("%sfairshare:realm:%d", f.scope, realm.ID),
+		})
+	}
+
+	if authApp := controller.AuthorizedAppFromContext(ctx); authApp != nil {
+		dig, err := digest.HMACUint(authApp.ID, f.hmacKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to digest authorized app id: %w", err)
+		}
+		tenants = append(tenants, &fairShareTenant{
+			kind: "api_key",
+			key:  fmt.Sprintf("%sfairshare:app:%s", f.scope, dig),
+		})
+	}
+
+	return tenants, nil
+}
+
+// takeGlobal counts the request against the total capacity and returns whether
+// the server is in a spike.
+func (f *FairShare) takeGlobal(ctx context.Context) (bool, error) {
+	limit, remaining, _, _, err := f.take(ctx, f.scope+"fairshare:global", f.tokens)
+	if err != nil {
+		return false, err
+	}
+	if remaining > limit {
+		remaining = limit
+	}
+	return limit-remaining >= f.spike, nil
+}
+
+// take takes a token for the key. The store creates keys with its default
+// limit, so if the limit on the key does not match tokens, the limit is set and
+// the token is taken again. This only happens the first time a key is used in
+// an interval, so most requests make a single round trip to the store.
+func (f *FairShare) take(ctx context.Context, key string, tokens uint64) (uint64, uint64, uint64, bool, error) {
+	limit, remaining, reset, ok, err := f.store.Take(ctx, key)
+	if err != nil {
+		return 0, 0, 0, false, fmt.Errorf("failed to take: %w", err)
+	}
+	if limit == tokens {
+		return limit, remaining, reset, ok, nil
+	}
+
+	if err := f.store.Set(ctx, key, tokens, f.interval); err != nil {
+		return 0, 0, 0, false, fmt.Errorf("failed to set limit: %w", err)
+	}
+	limit, remaining, reset, ok, err = f.store.Take(ctx, key)
+	if err != nil {
+		return 0, 0, 0, false, fmt.Errorf("failed to take: %w", err)
+	}
+	return limit, remaining, reset, ok, nil
+}
+
+// shed records the shed request and renders the rejection.
+func (f *FairShare) shed(ctx context.Context, w http.ResponseWriter, kind string, retryAt time.Time) {
+	if ctx, err := tag.New(ctx, tag.Upsert(fairShareTenantTagKey, kind)); err == nil {
+		stats.Record(ctx, mFairShareShed.M(1))
+	}
+
+	retrySeconds := int64(math.Ceil(time.Until(retryAt).Seconds()))
+	if retrySeconds < 1 {
+		retrySeconds = 1
+	}
+	w.Header().Set(httplimit.HeaderRetryAfter, strconv.FormatInt(retrySeconds, 10))
+
+	if f.h == nil {
+		http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+		return
+	}
+	f.h.RenderJSON(w, http.StatusServiceUnavailable,
+		api.Errorf("server is under heavy load, retry after %d seconds", retrySeconds).
+			WithCode(api.ErrLoadShed))
+}
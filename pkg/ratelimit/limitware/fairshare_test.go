@@ -0,0 +1,270 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package limitware_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/google/exposure-notifications-verification-server/internal/project"
+	"github.com/google/exposure-notifications-verification-server/pkg/controller"
+	"github.com/google/exposure-notifications-verification-server/pkg/database"
+	"github.com/google/exposure-notifications-verification-server/pkg/ratelimit"
+	"github.com/google/exposure-notifications-verification-server/pkg/ratelimit/limitware"
+	"github.com/google/exposure-notifications-verification-server/pkg/render"
+
+	"github.com/sethvargo/go-limiter"
+	"github.com/sethvargo/go-limiter/httplimit"
+	"github.com/sethvargo/go-limiter/memorystore"
+)
+
+// testFairShareConfig gives each tenant 2 of 10 tokens and sheds load once 8
+// tokens have been used.
+func testFairShareConfig() *ratelimit.Config {
+	return &ratelimit.Config{
+		HMACKey:                 []byte("abcdefghijklmnopqrstuvwxyz"),
+		FairShareTokens:         10,
+		FairShareInterval:       time.Hour,
+		FairShareMax:            0.2,
+		FairShareSpikeThreshold: 0.8,
+	}
+}
+
+func testFairShare(tb testing.TB, s limiter.Store) http.Handler {
+	tb.Helper()
+
+	ctx := project.TestContext(tb)
+	h, err := render.New(ctx, nil, true)
+	if err != nil {
+		tb.Fatal(err)
+	}
+
+	f, err := limitware.NewFairShare(s, "test:", testFairShareConfig(), h)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return f.Handle(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+}
+
+func testMemoryStore(tb testing.TB) limiter.Store {
+	tb.Helper()
+
+	s, err := memorystore.New(&memorystore.Config{
+		Tokens:   100,
+		Interval: time.Hour,
+	})
+	if err != nil {
+		tb.Fatal(err)
+	}
+	tb.Cleanup(func() {
+		if err := s.Close(context.Background()); err != nil {
+			tb.Fatal(err)
+		}
+	})
+	return s
+}
+
+func realmContext(ctx context.Context, id uint) context.Context {
+	realm := new(database.Realm)
+	realm.ID = id
+	return controller.WithRealm(ctx, realm)
+}
+
+func appContext(ctx context.Context, id uint) context.Context {
+	app := new(database.AuthorizedApp)
+	app.ID = id
+	return controller.WithAuthorizedApp(ctx, app)
+}
+
+func serve(ctx context.Context, tb testing.TB, handler http.Handler) *httptest.ResponseRecorder {
+	tb.Helper()
+
+	r := httptest.NewRequest(http.MethodPost, "/", nil).WithContext(ctx)
+	r.Header.Set("Accept", "application/json")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	return w
+}
+
+func TestFairShare_Handle(t *testing.T) {
+	t.Parallel()
+
+	ctx := project.TestContext(t)
+
+	t.Run("disabled", func(t *testing.T) {
+		t.Parallel()
+
+		cfg := testFairShareConfig()
+		cfg.FairShareTokens = 0
+		f, err := limitware.NewFairShare(testMemoryStore(t), "test:", cfg, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		handler := f.Handle(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		}))
+
+		for i := 0; i < 20; i++ {
+			if got, want := serve(realmContext(ctx, 1), t, handler).Code, http.StatusOK; got != want {
+				t.Fatalf("request %d: expected %d to be %d", i, got, want)
+			}
+		}
+	})
+
+	t.Run("no_tenant", func(t *testing.T) {
+		t.Parallel()
+
+		handler := testFairShare(t, testMemoryStore(t))
+		for i := 0; i < 20; i++ {
+			if got, want := serve(ctx, t, handler).Code, http.StatusOK; got != want {
+				t.Fatalf("request %d: expected %d to be %d", i, got, want)
+			}
+		}
+	})
+
+	t.Run("sheds_only_during_spike", func(t *testing.T) {
+		t.Parallel()
+
+		handler := testFairShare(t, testMemoryStore(t))
+
+		// Realm 1 goes well over its share, but the server is not in a spike.
+		for i := 0; i < 7; i++ {
+			if got, want := serve(realmContext(ctx, 1), t, handler).Code, http.StatusOK; got != want {
+				t.Fatalf("request %d: expected %d to be %d", i, got, want)
+			}
+		}
+
+		// Realm 2 pushes the server into a spike, but is within its share.
+		if got, want := serve(realmContext(ctx, 2), t, handler).Code, http.StatusOK; got != want {
+			t.Fatalf("expected %d to be %d", got, want)
+		}
+
+		// Realm 1 is over its share during the spike.
+		if got, want := serve(realmContext(ctx, 1), t, handler).Code, http.StatusServiceUnavailable; got != want {
+			t.Fatalf("expected %d to be %d", got, want)
+		}
+
+		// Realm 2 still has one token of its share, then is shed too.
+		if got, want := serve(realmContext(ctx, 2), t, handler).Code, http.StatusOK; got != want {
+			t.Fatalf("expected %d to be %d", got, want)
+		}
+		if got, want := serve(realmContext(ctx, 2), t, handler).Code, http.StatusServiceUnavailable; got != want {
+			t.Fatalf("expected %d to be %d", got, want)
+		}
+	})
+
+	t.Run("api_key_tenant", func(t *testing.T) {
+		t.Parallel()
+
+		handler := testFairShare(t, testMemoryStore(t))
+
+		// Each API key stays within its share and pushes the server into a spike.
+		for i := uint(1); i <= 4; i++ {
+			appCtx := appContext(ctx, i)
+			for j := 0; j < 2; j++ {
+				if got, want := serve(appCtx, t, handler).Code, http.StatusOK; got != want {
+					t.Fatalf("app %d request %d: expected %d to be %d", i, j, got, want)
+				}
+			}
+		}
+
+		// API key 1 is over its share during the spike, even though it has no
+		// realm.
+		if got, want := serve(appContext(ctx, 1), t, handler).Code, http.StatusServiceUnavailable; got != want {
+			t.Fatalf("expected %d to be %d", got, want)
+		}
+
+		// API key 5 is within its share.
+		if got, want := serve(appContext(ctx, 5), t, handler).Code, http.StatusOK; got != want {
+			t.Fatalf("expected %d to be %d", got, want)
+		}
+
+		// An API key within its share is shed if its realm is over its share.
+		realmCtx := realmContext(ctx, 1)
+		if got, want := serve(appContext(realmCtx, 6), t, handler).Code, http.StatusOK; got != want {
+			t.Fatalf("expected %d to be %d", got, want)
+		}
+		if got, want := serve(appContext(realmCtx, 7), t, handler).Code, http.StatusOK; got != want {
+			t.Fatalf("expected %d to be %d", got, want)
+		}
+		if got, want := serve(appContext(realmCtx, 8), t, handler).Code, http.StatusServiceUnavailable; got != want {
+			t.Fatalf("expected %d to be %d", got, want)
+		}
+	})
+
+	t.Run("retry_after", func(t *testing.T) {
+		t.Parallel()
+
+		handler := testFairShare(t, testMemoryStore(t))
+
+		for i := 0; i < 8; i++ {
+			serve(realmContext(ctx, 1), t, handler)
+		}
+
+		w := serve(realmContext(ctx, 1), t, handler)
+		if got, want := w.Code, http.StatusServiceUnavailable; got != want {
+			t.Fatalf("expected %d to be %d", got, want)
+		}
+
+		retryAfter, err := strconv.ParseInt(w.Header().Get(httplimit.HeaderRetryAfter), 10, 64)
+		if err != nil {
+			t.Fatalf("invalid Retry-After: %s", err)
+		}
+		if retryAfter < 1 || retryAfter > int64(time.Hour.Seconds()) {
+			t.Errorf("expected Retry-After %d to be between 1 and %d", retryAfter, int64(time.Hour.Seconds()))
+		}
+	})
+
+	t.Run("fails_open", func(t *testing.T) {
+		t.Parallel()
+
+		handler := testFairShare(t, &errorStore{})
+		for i := 0; i < 20; i++ {
+			if got, want := serve(realmContext(ctx, 1), t, handler).Code, http.StatusOK; got != want {
+				t.Fatalf("request %d: expected %d to be %d", i, got, want)
+			}
+		}
+	})
+}
+
+// errorStore is a limiter.Store that fails every call.
+type errorStore struct{}
+
+func (s *errorStore) Take(context.Context, string) (uint64, uint64, uint64, bool, error) {
+	return 0, 0, 0, false, fmt.Errorf("store is down")
+}
+
+func (s *errorStore) Get(context.Context, string) (uint64, uint64, error) {
+	return 0, 0, fmt.Errorf("store is down")
+}
+
+func (s *errorStore) Set(context.Context, string, uint64, time.Duration) error {
+	return fmt.Errorf("store is down")
+}
+
+func (s *errorStore) Burst(context.Context, string, uint64) error {
+	return fmt.Errorf("store is down")
+}
+
+func (s *errorStore) Close(context.Context) error {
+	return nil
+}
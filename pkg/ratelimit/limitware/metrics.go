@@ -22,11 +22,20 @@ import (
 	"github.com/opencensus-integrations/redigo/redis"
 	"go.opencensus.io/stats"
 	"go.opencensus.io/stats/view"
+	"go.opencensus.io/tag"
 )
 
 const metricPrefix = observability.MetricRoot + "/ratelimit/limitware"
 
-var mRequest = stats.Int64(metricPrefix+"/request", "requests seen by middleware", stats.UnitDimensionless)
+var (
+	mRequest = stats.Int64(metricPrefix+"/request", "requests seen by middleware", stats.UnitDimensionless)
+
+	mFairShareShed = stats.Int64(metricPrefix+"/fair_share_shed", "requests shed by the fair share limiter", stats.UnitDimensionless)
+
+	// fairShareTenantTagKey is whether a shed request exceeded the share of its
+	// realm or its API key.
+	fairShareTenantTagKey = tag.MustNewKey("tenant")
+)
 
 func init() {
 	enobs.CollectViews(append(redis.ObservabilityMetricViews,
@@ -35,5 +44,12 @@ func init() {
 			Measure:     mRequest,
 			Aggregation: view.Count(),
 			TagKeys:     append(observability.CommonTagKeys(), enobs.ResultTagKey),
+		},
+		&view.View{
+			Name:        metricPrefix + "/fair_share_shed_count",
+			Measure:     mFairShareShed,
+			Description: "Count of requests shed by the fair share limiter",
+			TagKeys:     append(observability.CommonTagKeys(), fairShareTenantTagKey),
+			Aggregation: view.Count(),
 		})...)
 }
@@ -17,17 +17,22 @@ package limitware
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"math"
 	"net/http"
 	"strconv"
 	"time"
 
 	"github.com/google/exposure-notifications-server/pkg/logging"
 	enobs "github.com/google/exposure-notifications-server/pkg/observability"
+	"github.com/google/exposure-notifications-verification-server/pkg/api"
+	"github.com/google/exposure-notifications-verification-server/pkg/cache"
 	"github.com/google/exposure-notifications-verification-server/pkg/controller"
 	"github.com/google/exposure-notifications-verification-server/pkg/database"
 	"github.com/google/exposure-notifications-verification-server/pkg/digest"
 	"github.com/google/exposure-notifications-verification-server/pkg/realip"
+	"github.com/google/exposure-notifications-verification-server/pkg/render"
 
 	"github.com/sethvargo/go-limiter"
 	"github.com/sethvargo/go-limiter/httplimit"
@@ -35,6 +40,32 @@ import (
 	"go.opencensus.io/tag"
 )
 
+// Standard rate limit response headers. These are set alongside the
+// X-RateLimit-* headers so clients can implement backoff without knowing this
+// server's conventions. Unlike X-RateLimit-Reset, RateLimit-Reset is the number
+// of seconds until the limit resets.
+const (
+	HeaderRateLimitLimit     = "RateLimit-Limit"
+	HeaderRateLimitRemaining = "RateLimit-Remaining"
+	HeaderRateLimitReset     = "RateLimit-Reset"
+)
+
+// Policy overrides the store's default limits for a request's key.
+type Policy struct {
+	// Tokens is the number of requests allowed per Interval. If 0, the store's
+	// default limit is used.
+	Tokens   uint64
+	Interval time.Duration
+
+	// BanDuration is how long a key is rejected after it exceeds its limit. Bans
+	// are only enforced when the middleware has a ban cache.
+	BanDuration time.Duration
+}
+
+// PolicyFunc returns the policy for the request. It returns nil to use the
+// store's defaults.
+type PolicyFunc func(r *http.Request) *Policy
+
 // Middleware is a handler/mux that can wrap other middlware to implement HTTP
 // rate limiting. It can rate limit based on an arbitrary KeyFunc, and supports
 // anything that implements limiter.Store.
@@ -42,7 +73,11 @@ type Middleware struct {
 	store   limiter.Store
 	keyFunc httplimit.KeyFunc
 
-	allowOnError bool
+	allowOnError  bool
+	onRateLimited func(r *http.Request)
+	policyFunc    PolicyFunc
+	bans          cache.Cacher
+	h             *render.Renderer
 }
 
 // Option is an option to the middleware.
@@ -57,6 +92,42 @@ func AllowOnError(v bool) Option {
 	}
 }
 
+// OnRateLimited registers a function that is called with the request whenever
+// a request is rejected because it exceeded the rate limit.
+func OnRateLimited(fn func(r *http.Request)) Option {
+	return func(m *Middleware) *Middleware {
+		m.onRateLimited = fn
+		return m
+	}
+}
+
+// WithPolicy configures a function that returns per-request overrides of the
+// limit and ban duration.
+func WithPolicy(fn PolicyFunc) Option {
+	return func(m *Middleware) *Middleware {
+		m.policyFunc = fn
+		return m
+	}
+}
+
+// WithBans configures the cache in which bans are recorded. Without a ban
+// cache, a policy's BanDuration is ignored.
+func WithBans(c cache.Cacher) Option {
+	return func(m *Middleware) *Middleware {
+		m.bans = c
+		return m
+	}
+}
+
+// WithRenderer renders rejected requests as a JSON API error instead of plain
+// text.
+func WithRenderer(h *render.Renderer) Option {
+	return func(m *Middleware) *Middleware {
+		m.h = h
+		return m
+	}
+}
+
 // NewMiddleware creates a new middleware suitable for use as an HTTP handler.
 // This function returns an error if either the Store or KeyFunc are nil.
 func NewMiddleware(ctx context.Context, s limiter.Store, f httplimit.KeyFunc, opts ...Option) (*Middleware, error) {
@@ -114,6 +185,31 @@ func (m *Middleware) Handle(next http.Handler) http.Handler {
 			return
 		}
 
+		var policy *Policy
+		if m.policyFunc != nil {
+			policy = m.policyFunc(r)
+		}
+
+		// Reject banned keys without taking from the store.
+		if until, banned := m.bannedUntil(ctx, key, policy); banned {
+			logger.Infow("rate limited", "key", key, "banned_until", until)
+			result = enobs.ResultError("BANNED")
+			m.setHeaders(w, policy.Tokens, 0, until)
+			m.rateLimited(w, r, until)
+			return
+		}
+
+		// Apply the policy's limit to the key's bucket.
+		if err := m.applyPolicy(ctx, key, policy); err != nil {
+			logger.Errorw("failed to apply policy", "error", err)
+
+			if !m.allowOnError {
+				result = enobs.ResultError("FAILED_TO_APPLY_POLICY")
+				http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+				return
+			}
+		}
+
 		// Take from the store.
 		limit, remaining, reset, ok, err := m.store.Take(ctx, key)
 		if err != nil {
@@ -126,19 +222,20 @@ func (m *Middleware) Handle(next http.Handler) http.Handler {
 			}
 		}
 
-		resetTime := time.Unix(0, int64(reset)).UTC().Format(time.RFC1123)
+		resetAt := time.Unix(0, int64(reset)).UTC()
 
 		// Set headers (we do this regardless of whether the request is permitted).
-		w.Header().Set(httplimit.HeaderRateLimitLimit, strconv.FormatUint(limit, 10))
-		w.Header().Set(httplimit.HeaderRateLimitRemaining, strconv.FormatUint(remaining, 10))
-		w.Header().Set(httplimit.HeaderRateLimitReset, resetTime)
+		m.setHeaders(w, limit, remaining, resetAt)
 
 		// Fail if there were no tokens remaining.
 		if !ok {
 			logger.Infow("rate limited", "key", key)
 			result = enobs.ResultError("RATE_LIMITED")
-			w.Header().Set(httplimit.HeaderRetryAfter, resetTime)
-			http.Error(w, http.StatusText(http.StatusTooManyRequests), http.StatusTooManyRequests)
+			if until, ok := m.ban(ctx, key, policy); ok {
+				m.setHeaders(w, limit, 0, until)
+				resetAt = until
+			}
+			m.rateLimited(w, r, resetAt)
 			return
 		}
 
@@ -148,13 +245,149 @@ func (m *Middleware) Handle(next http.Handler) http.Handler {
 	})
 }
 
+// setHeaders sets the rate limit headers on the response.
+func (m *Middleware) setHeaders(w http.ResponseWriter, limit, remaining uint64, reset time.Time) {
+	resetSeconds := int64(math.Ceil(time.Until(reset).Seconds()))
+	if resetSeconds < 0 {
+		resetSeconds = 0
+	}
+
+	w.Header().Set(httplimit.HeaderRateLimitLimit, strconv.FormatUint(limit, 10))
+	w.Header().Set(httplimit.HeaderRateLimitRemaining, strconv.FormatUint(remaining, 10))
+	w.Header().Set(httplimit.HeaderRateLimitReset, reset.Format(time.RFC1123))
+
+	w.Header().Set(HeaderRateLimitLimit, strconv.FormatUint(limit, 10))
+	w.Header().Set(HeaderRateLimitRemaining, strconv.FormatUint(remaining, 10))
+	w.Header().Set(HeaderRateLimitReset, strconv.FormatInt(resetSeconds, 10))
+}
+
+// rateLimited calls the rate limited callback and renders the rejection.
+func (m *Middleware) rateLimited(w http.ResponseWriter, r *http.Request, retryAt time.Time) {
+	if m.onRateLimited != nil {
+		m.onRateLimited(r)
+	}
+
+	w.Header().Set(httplimit.HeaderRetryAfter, retryAt.Format(time.RFC1123))
+
+	if m.h == nil {
+		http.Error(w, http.StatusText(http.StatusTooManyRequests), http.StatusTooManyRequests)
+		return
+	}
+	m.h.RenderJSON(w, http.StatusTooManyRequests,
+		api.Errorf("rate limit exceeded, retry after %s", retryAt.Format(time.RFC1123)).
+			WithCode(api.ErrRateLimited))
+}
+
+// applyPolicy resets the key's bucket if its limit does not match the policy.
+// Existing buckets keep their remaining tokens until the limit changes.
+func (m *Middleware) applyPolicy(ctx context.Context, key string, policy *Policy) error {
+	if policy == nil || policy.Tokens == 0 || policy.Interval <= 0 {
+		return nil
+	}
+	return ensureLimit(ctx, m.store, key, policy.Tokens, policy.Interval)
+}
+
+// ensureLimit resets the key's bucket if its limit is not tokens.
+func ensureLimit(ctx context.Context, s limiter.Store, key string, tokens uint64, interval time.Duration) error {
+	limit, _, err := s.Get(ctx, key)
+	if err != nil {
+		return fmt.Errorf("failed to get limit: %w", err)
+	}
+	if limit == tokens {
+		return nil
+	}
+
+	if err := s.Set(ctx, key, tokens, interval); err != nil {
+		return fmt.Errorf("failed to set limit: %w", err)
+	}
+	return nil
+}
+
+// bannedUntil returns the time until which the key is banned, if it is banned.
+// Errors reading the ban cache are treated as not banned.
+func (m *Middleware) bannedUntil(ctx context.Context, key string, policy *Policy) (time.Time, bool) {
+	if m.bans == nil || policy == nil || policy.BanDuration <= 0 {
+		return time.Time{}, false
+	}
+
+	var until time.Time
+	if err := m.bans.Read(ctx, banCacheKey(key), &until); err != nil {
+		if !errors.Is(err, cache.ErrNotFound) {
+			logging.FromContext(ctx).Named("ratelimit.bannedUntil").
+				Errorw("failed to read ban", "error", err)
+		}
+		return time.Time{}, false
+	}
+	return until, time.Now().Before(until)
+}
+
+// ban bans the key for the policy's ban duration, returning the time at which
+// the ban expires.
+func (m *Middleware) ban(ctx context.Context, key string, policy *Policy) (time.Time, bool) {
+	if m.bans == nil || policy == nil || policy.BanDuration <= 0 {
+		return time.Time{}, false
+	}
+
+	until := time.Now().UTC().Add(policy.BanDuration)
+	if err := m.bans.Write(ctx, banCacheKey(key), until, policy.BanDuration); err != nil {
+		logging.FromContext(ctx).Named("ratelimit.ban").
+			Errorw("failed to write ban", "error", err)
+		return time.Time{}, false
+	}
+	return until, true
+}
+
+func banCacheKey(key string) *cache.Key {
+	return &cache.Key{
+		Namespace: "ratelimit:bans",
+		Key:       key,
+	}
+}
+
+// RealmPolicy returns a policy function that applies the Device API rate limit
+// settings of the realm on the request context. Requests without a realm use
+// the store's defaults. Realms without a burst override use tokens, so buckets
+// are reset when an override is removed.
+func RealmPolicy(tokens uint64, interval time.Duration) PolicyFunc {
+	return func(r *http.Request) *Policy {
+		realm := controller.RealmFromContext(r.Context())
+		if realm == nil {
+			return nil
+		}
+
+		policy := &Policy{
+			Tokens:      tokens,
+			Interval:    interval,
+			BanDuration: realm.APIRateLimitBanDuration.Duration,
+		}
+		if realm.APIRateLimitBurst > 0 {
+			policy.Tokens = uint64(realm.APIRateLimitBurst)
+		}
+		return policy
+	}
+}
+
 // APIKeyFunc returns a default key function for ratelimiting on our API key
 // header. Since APIKeys are assumed to be "public" at some point, they are rate
-// limited by [realm,ip], and API keys have a 1-1 mapping to a realm.
+// limited by [realm,ip], and API keys have a 1-1 mapping to a realm. Realms
+// that rate limit by API key are limited by the device API key instead, when it
+// is on the request context.
 func APIKeyFunc(ctx context.Context, db *database.Database, scope string, hmacKey []byte) httplimit.KeyFunc {
 	ipAddrLimit := IPAddressKeyFunc(ctx, scope, hmacKey)
 
 	return func(r *http.Request) (string, error) {
+		realm := controller.RealmFromContext(r.Context())
+		authApp := controller.AuthorizedAppFromContext(r.Context())
+		if realm != nil && authApp != nil &&
+			authApp.APIKeyType == database.APIKeyTypeDevice &&
+			realm.APIRateLimitKeyMode == database.RateLimitKeyModeAPIKey {
+			dig, err := digest.HMACUint(authApp.ID, hmacKey)
+			if err != nil {
+				return "", fmt.Errorf("failed to digest authorized app id: %w", err)
+			}
+			return fmt.Sprintf("%sapp:%s", scope, dig), nil
+		}
+
 		// Procss the API key
 		v := r.Header.Get("x-api-key")
 		if v != "" {
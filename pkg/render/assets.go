@@ -39,8 +39,6 @@ var (
   integrity="{{.SRI}}" crossorigin="anonymous" referrerpolicy="no-referrer" />
 {{ end }}
 `)))
-
-	cssIncludeTagCache htmltemplate.HTML
 )
 
 var (
@@ -50,7 +48,6 @@ var (
   integrity="{{.SRI}}" crossorigin="anonymous" referrerpolicy="no-referrer"></script>
 {{ end }}
 `)))
-	jsIncludeTagCache htmltemplate.HTML
 )
 
 // asset represents a javascript or css asset.
@@ -69,16 +66,18 @@ func (a *asset) BuildID() string {
 
 // assetIncludeTag searches the fs for all assets of the given search type and
 // renders the template. In non-dev mode, the results are cached on the first
-// invocation.
-func assetIncludeTag(fsys fs.FS, search string, tmpl *texttemplate.Template, cache *htmltemplate.HTML, devMode bool) func() (htmltemplate.HTML, error) {
+// invocation. Each renderer has its own cache, since renderers may be backed by
+// different filesystems.
+func assetIncludeTag(fsys fs.FS, search string, tmpl *texttemplate.Template, devMode bool) func() (htmltemplate.HTML, error) {
 	var mu sync.Mutex
+	var cache htmltemplate.HTML
 
 	return func() (htmltemplate.HTML, error) {
 		if !devMode {
 			mu.Lock()
 			defer mu.Unlock()
-			if *cache != "" {
-				return *cache, nil
+			if cache != "" {
+				return cache, nil
 			}
 		}
 
@@ -115,7 +114,7 @@ func assetIncludeTag(fsys fs.FS, search string, tmpl *texttemplate.Template, cac
 		result := htmltemplate.HTML(b.String())
 
 		if !devMode {
-			*cache = result
+			cache = result
 		}
 
 		return result, nil
@@ -28,7 +28,7 @@ import (
 // performance.
 func (r *Renderer) RenderEmail(tmpl string, data interface{}) ([]byte, error) {
 	if r.debug {
-		if err := r.loadTemplates(); err != nil {
+		if err := r.loadTextTemplates(); err != nil {
 			return nil, fmt.Errorf("error loading templates: %w", err)
 		}
 	}
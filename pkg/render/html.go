@@ -53,7 +53,7 @@ func (r *Renderer) RenderHTMLStatus(w http.ResponseWriter, code int, tmpl string
 	}
 
 	if r.debug {
-		if err := r.loadTemplates(); err != nil {
+		if err := r.loadHTMLTemplates(); err != nil {
 			r.logger.Errorw("failed to reload templates in renderer", "error", err)
 
 			msg := html.EscapeString(err.Error())
@@ -0,0 +1,62 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package render
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// NDJSONMarshaler is an interface for items that can convert to
+// newline-delimited JSON.
+type NDJSONMarshaler interface {
+	// MarshalNDJSON produces newline-delimited JSON.
+	MarshalNDJSON() ([]byte, error)
+}
+
+// RenderNDJSON renders the input as newline-delimited JSON. Like RenderCSV,
+// it marshals to a buffer first so marshaling errors do not produce partial
+// responses, and the response is forced as a download.
+func (r *Renderer) RenderNDJSON(w http.ResponseWriter, code int, filename string, data NDJSONMarshaler) {
+	b, err := data.MarshalNDJSON()
+	if err != nil {
+		r.logger.Errorw("failed to marshal ndjson", "error", err)
+
+		msg := "An internal error occurred."
+		if r.debug {
+			msg = err.Error()
+		}
+
+		w.Header().Set("Content-Type", "text/plain")
+		w.WriteHeader(http.StatusInternalServerError)
+		fmt.Fprintf(w, "%s", msg)
+		return
+	}
+
+	// Ensure there's a filename.
+	if filename == "" {
+		filename = "data.ndjson"
+	}
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment;filename=%s", filename))
+	w.WriteHeader(code)
+	if _, err := w.Write(b); err != nil {
+		// We couldn't write the buffer. We can't change the response header or
+		// content type if we got this far, so the best option we have is to log the
+		// error.
+		r.logger.Errorw("failed to write ndjson to response", "error", err)
+	}
+}
@@ -46,6 +46,7 @@ import (
 // bad status code.
 var allowedResponseCodes = map[int]struct{}{
 	http.StatusOK:                    {},
+	http.StatusAccepted:              {},
 	http.StatusBadRequest:            {},
 	http.StatusUnauthorized:          {},
 	http.StatusNotFound:              {},
@@ -53,8 +54,10 @@ var allowedResponseCodes = map[int]struct{}{
 	http.StatusConflict:              {},
 	http.StatusPreconditionFailed:    {},
 	http.StatusRequestEntityTooLarge: {},
+	http.StatusUpgradeRequired:       {},
 	http.StatusTooManyRequests:       {},
 	http.StatusInternalServerError:   {},
+	http.StatusServiceUnavailable:    {},
 }
 
 // Renderer is responsible for rendering various content and templates like HTML
@@ -71,17 +74,32 @@ type Renderer struct {
 	// prevent partial responses being sent to clients.
 	rendererPool *sync.Pool
 
-	// templates is the actually collection of templates. templatesLoader is a
-	// function for (re)loading templates. templatesLock is a mutex to prevent
-	// concurrent modification of the templates field.
+	// templates and textTemplates are the parsed HTML and text templates.
+	// templatesLock is a mutex to prevent concurrent modification of the
+	// templates fields.
 	templates     *htmltemplate.Template
 	textTemplates *texttemplate.Template
 	templatesLock sync.RWMutex
 
+	// textOnce parses the text templates once outside of debug mode. Text
+	// templates are only used for emails, so they are parsed on first use
+	// instead of when the renderer is created.
+	textOnce sync.Once
+	textErr  error
+
+	// jsIncludeTag and cssIncludeTag render the script and stylesheet tags for
+	// the static assets, including their integrity hashes.
+	jsIncludeTag  func() (htmltemplate.HTML, error)
+	cssIncludeTag func() (htmltemplate.HTML, error)
+
 	fs fs.FS
 }
 
-// New creates a new renderer with the given details.
+// New creates a new renderer with the given details. The HTML templates are
+// parsed from fsys, which is embedded in the binary outside of dev mode, and
+// any parse error is returned. In debug mode, templates are reloaded on each
+// render. Otherwise the asset hashes are also computed up front, and the text
+// templates are parsed the first time an email is rendered.
 func New(ctx context.Context, fsys fs.FS, debug bool) (*Renderer, error) {
 	logger := logging.FromContext(ctx)
 
@@ -95,15 +113,43 @@ func New(ctx context.Context, fsys fs.FS, debug bool) (*Renderer, error) {
 		},
 		fs: fsys,
 	}
+	r.jsIncludeTag = assetIncludeTag(fsys, "static/js", jsIncludeTmpl, debug)
+	r.cssIncludeTag = assetIncludeTag(fsys, "static/css", cssIncludeTmpl, debug)
+
+	if debug {
+		if err := r.loadTemplates(); err != nil {
+			return nil, err
+		}
+		return r, nil
+	}
 
-	// Load initial templates
-	if err := r.loadTemplates(); err != nil {
+	if err := r.loadHTMLTemplates(); err != nil {
 		return nil, err
 	}
 
+	if fsys != nil {
+		// Not every filesystem has static assets, so errors are reported when
+		// the tags are rendered instead.
+		if _, err := r.jsIncludeTag(); err != nil {
+			logger.Debugw("failed to precompute js include tags", "error", err)
+		}
+		if _, err := r.cssIncludeTag(); err != nil {
+			logger.Debugw("failed to precompute css include tags", "error", err)
+		}
+	}
+
 	return r, nil
 }
 
+// ensureTextTemplates parses the text templates if they have not been already.
+// It returns the parse error, if any.
+func (r *Renderer) ensureTextTemplates() error {
+	r.textOnce.Do(func() {
+		r.textErr = r.loadTextTemplates()
+	})
+	return r.textErr
+}
+
 // executeHTMLTemplate executes a single HTML template with the provided data.
 func (r *Renderer) executeHTMLTemplate(w io.Writer, name string, data interface{}) error {
 	r.templatesLock.RLock()
@@ -118,6 +164,12 @@ func (r *Renderer) executeHTMLTemplate(w io.Writer, name string, data interface{
 
 // executeTextTemplate executes a single text template with the provided data.
 func (r *Renderer) executeTextTemplate(w io.Writer, name string, data interface{}) error {
+	if !r.debug {
+		if err := r.ensureTextTemplates(); err != nil {
+			return err
+		}
+	}
+
 	r.templatesLock.RLock()
 	defer r.templatesLock.RUnlock()
 
@@ -130,9 +182,14 @@ func (r *Renderer) executeTextTemplate(w io.Writer, name string, data interface{
 
 // loadTemplates loads or reloads all templates.
 func (r *Renderer) loadTemplates() error {
-	r.templatesLock.Lock()
-	defer r.templatesLock.Unlock()
+	if err := r.loadHTMLTemplates(); err != nil {
+		return err
+	}
+	return r.loadTextTemplates()
+}
 
+// loadHTMLTemplates loads or reloads the HTML templates.
+func (r *Renderer) loadHTMLTemplates() error {
 	if r.fs == nil {
 		return nil
 	}
@@ -141,19 +198,43 @@ func (r *Renderer) loadTemplates() error {
 		Option("missingkey=zero").
 		Funcs(r.templateFuncs())
 
+	if err := walkTemplates(r.fs, ".html", func(pth string) error {
+		_, err := htmltmpl.ParseFS(r.fs, pth)
+		return err
+	}); err != nil {
+		return fmt.Errorf("failed to load html templates: %w", err)
+	}
+
+	r.templatesLock.Lock()
+	defer r.templatesLock.Unlock()
+	r.templates = htmltmpl
+	return nil
+}
+
+// loadTextTemplates loads or reloads the text templates.
+func (r *Renderer) loadTextTemplates() error {
+	if r.fs == nil {
+		return nil
+	}
+
 	texttmpl := texttemplate.New("").
 		Funcs(r.textFuncs())
 
-	if err := loadTemplates(r.fs, htmltmpl, texttmpl); err != nil {
-		return fmt.Errorf("failed to load templates: %w", err)
+	if err := walkTemplates(r.fs, ".txt", func(pth string) error {
+		_, err := texttmpl.ParseFS(r.fs, pth)
+		return err
+	}); err != nil {
+		return fmt.Errorf("failed to load text templates: %w", err)
 	}
 
-	r.templates = htmltmpl
+	r.templatesLock.Lock()
+	defer r.templatesLock.Unlock()
 	r.textTemplates = texttmpl
 	return nil
 }
 
-func loadTemplates(fsys fs.FS, htmltmpl *htmltemplate.Template, texttmpl *texttemplate.Template) error {
+// walkTemplates calls parse for each file in fsys with the given extension.
+func walkTemplates(fsys fs.FS, ext string, parse func(pth string) error) error {
 	// You might be thinking to yourself, wait, why don't you just use
 	// template.ParseFS(fsys, "**/*.html"). Well, still as of Go 1.16, glob
 	// doesn't support shopt globbing, so you still have to walk the entire
@@ -164,17 +245,15 @@ func loadTemplates(fsys fs.FS, htmltmpl *htmltemplate.Template, texttmpl *textte
 		}
 
 		if info.IsDir() {
-			return nil
-		}
-
-		if strings.HasSuffix(info.Name(), ".html") {
-			if _, err := htmltmpl.ParseFS(fsys, pth); err != nil {
-				return fmt.Errorf("failed to parse %s: %w", pth, err)
+			// Static assets are served as-is and never contain templates.
+			if pth == "static" {
+				return fs.SkipDir
 			}
+			return nil
 		}
 
-		if strings.HasSuffix(info.Name(), ".txt") {
-			if _, err := texttmpl.ParseFS(fsys, pth); err != nil {
+		if strings.HasSuffix(info.Name(), ext) {
+			if err := parse(pth); err != nil {
 				return fmt.Errorf("failed to parse %s: %w", pth, err)
 			}
 		}
@@ -347,8 +426,8 @@ func toPercent(f float64) string {
 
 func (r *Renderer) templateFuncs() htmltemplate.FuncMap {
 	return map[string]interface{}{
-		"jsIncludeTag":  assetIncludeTag(r.fs, "static/js", jsIncludeTmpl, &jsIncludeTagCache, r.debug),
-		"cssIncludeTag": assetIncludeTag(r.fs, "static/css", cssIncludeTmpl, &cssIncludeTagCache, r.debug),
+		"jsIncludeTag":  r.jsIncludeTag,
+		"cssIncludeTag": r.cssIncludeTag,
 
 		"joinStrings":      joinStrings,
 		"toSentence":       toSentence,
@@ -0,0 +1,114 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package render_test
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/google/exposure-notifications-verification-server/assets"
+	"github.com/google/exposure-notifications-verification-server/internal/project"
+	"github.com/google/exposure-notifications-verification-server/pkg/render"
+)
+
+// TestNew_embedded parses the templates embedded in the binary, so template
+// errors are caught when the server is built and tested instead of when it
+// starts.
+func TestNew_embedded(t *testing.T) {
+	t.Parallel()
+
+	ctx := project.TestContext(t)
+
+	for _, debug := range []bool{true, false} {
+		if _, err := render.New(ctx, assets.ServerFS(), debug); err != nil {
+			t.Fatalf("debug=%t: %s", debug, err)
+		}
+	}
+
+	h, err := render.New(ctx, assets.ServerFS(), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := h.RenderEmail("email/passwordresetemail", map[string]string{
+		"ToEmail":   "to@example.com",
+		"FromEmail": "from@example.com",
+		"ResetLink": "https://example.com/reset",
+	}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestNew_parseError(t *testing.T) {
+	t.Parallel()
+
+	ctx := project.TestContext(t)
+
+	fsys := fstest.MapFS{
+		"good.html": &fstest.MapFile{Data: []byte(`{{ define "good" }}ok{{ end }}`)},
+		"bad.html":  &fstest.MapFile{Data: []byte(`{{ define "bad" }}{{ .Missing }`)},
+	}
+
+	for _, debug := range []bool{true, false} {
+		h, err := render.New(ctx, fsys, debug)
+		if err == nil {
+			t.Fatalf("debug=%t: expected error", debug)
+		}
+		if h != nil {
+			t.Errorf("debug=%t: expected nil renderer", debug)
+		}
+		if got, want := err.Error(), "failed to load html templates"; !strings.Contains(got, want) {
+			t.Errorf("debug=%t: expected %q to contain %q", debug, got, want)
+		}
+	}
+}
+
+func TestRenderEmail_parseError(t *testing.T) {
+	t.Parallel()
+
+	ctx := project.TestContext(t)
+
+	fsys := fstest.MapFS{
+		"page.html": &fstest.MapFile{Data: []byte(`{{ define "page" }}ok{{ end }}`)},
+		"bad.txt":   &fstest.MapFile{Data: []byte(`{{ define "bad" }}{{ .Missing }`)},
+	}
+
+	// Text templates are parsed on first use, so the renderer is still created
+	// and can render HTML.
+	h, err := render.New(ctx, fsys, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := httptest.NewRecorder()
+	h.RenderHTML(w, "page", nil)
+	if got, want := w.Code, http.StatusOK; got != want {
+		t.Errorf("expected %d to be %d", got, want)
+	}
+	if got, want := w.Body.Bytes(), []byte("ok"); !bytes.Equal(got, want) {
+		t.Errorf("expected %q to be %q", got, want)
+	}
+
+	for i := 0; i < 2; i++ {
+		if _, err := h.RenderEmail("bad", nil); err == nil {
+			t.Fatal("expected error")
+		} else if got, want := err.Error(), "failed to load text templates"; !strings.Contains(got, want) {
+			t.Errorf("expected %q to contain %q", got, want)
+		}
+	}
+}
@@ -0,0 +1,152 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package residency
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	secretmanager "cloud.google.com/go/secretmanager/apiv1"
+	"cloud.google.com/go/secretmanager/apiv1/secretmanagerpb"
+	"cloud.google.com/go/storage"
+	"google.golang.org/api/option"
+	sqladmin "google.golang.org/api/sqladmin/v1"
+)
+
+// locationGlobal is the location reported for secrets that use automatic
+// replication, which does not pin data to any region.
+const locationGlobal = "global"
+
+// DatabaseInstanceResource builds a resource for a Cloud SQL instance from its
+// connection name ("project:region:instance"). The region is queried from the
+// Cloud SQL Admin API. If the connection name is empty, the location of the
+// resource is unknown.
+func DatabaseInstanceResource(name, connectionName string, opts ...option.ClientOption) *Resource {
+	r := &Resource{Name: name}
+	if connectionName == "" {
+		return r
+	}
+
+	r.Locate = func(ctx context.Context) ([]string, error) {
+		project, instance, err := parseConnectionName(connectionName)
+		if err != nil {
+			return nil, err
+		}
+
+		svc, err := sqladmin.NewService(ctx,
+			append([]option.ClientOption{option.WithScopes(sqladmin.SqlserviceAdminScope)}, opts...)...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create cloud sql admin client: %w", err)
+		}
+
+		inst, err := svc.Instances.Get(project, instance).Context(ctx).Do()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get cloud sql instance: %w", err)
+		}
+
+		locations := []string{inst.Region}
+		for _, replica := range inst.ReplicaNames {
+			replicaInst, err := svc.Instances.Get(project, replica).Context(ctx).Do()
+			if err != nil {
+				return nil, fmt.Errorf("failed to get cloud sql replica %s: %w", replica, err)
+			}
+			locations = append(locations, replicaInst.Region)
+		}
+		return locations, nil
+	}
+	return r
+}
+
+// parseConnectionName parses a Cloud SQL connection name into the project and
+// instance name.
+func parseConnectionName(s string) (string, string, error) {
+	parts := strings.Split(s, ":")
+	if len(parts) != 3 || parts[0] == "" || parts[2] == "" {
+		return "", "", fmt.Errorf("invalid cloud sql connection name %q, expected project:region:instance", s)
+	}
+	return parts[0], parts[2], nil
+}
+
+// SecretResource builds a resource for a Secret Manager secret. The locations
+// of the secret's replicas are queried from the Secret Manager API. Secrets
+// with automatic replication are reported in the "global" location, since
+// their data is not pinned to a region.
+func SecretResource(name, secret string, opts ...option.ClientOption) *Resource {
+	return &Resource{
+		Name: name,
+		Locate: func(ctx context.Context) ([]string, error) {
+			client, err := secretmanager.NewClient(ctx, opts...)
+			if err != nil {
+				return nil, fmt.Errorf("failed to create secret manager client: %w", err)
+			}
+			defer client.Close()
+
+			result, err := client.GetSecret(ctx, &secretmanagerpb.GetSecretRequest{
+				Name: secretName(secret),
+			})
+			if err != nil {
+				return nil, fmt.Errorf("failed to get secret: %w", err)
+			}
+
+			replication := result.GetReplication()
+			if replication.GetAutomatic() != nil {
+				return []string{locationGlobal}, nil
+			}
+
+			var locations []string
+			for _, replica := range replication.GetUserManaged().GetReplicas() {
+				locations = append(locations, replica.GetLocation())
+			}
+			return locations, nil
+		},
+	}
+}
+
+// secretName returns the secret resource name for a secret reference, which
+// may include the "secret://" prefix and a version.
+func secretName(s string) string {
+	s = strings.TrimPrefix(s, "secret://")
+	if i := strings.Index(s, "/versions/"); i >= 0 {
+		s = s[:i]
+	}
+	return s
+}
+
+// BucketResource builds a resource for a Cloud Storage bucket. The location of
+// the bucket is queried from the Cloud Storage API. For configurable
+// dual-region buckets, the locations of both regions are returned.
+func BucketResource(name, bucket string, opts ...option.ClientOption) *Resource {
+	return &Resource{
+		Name: name,
+		Locate: func(ctx context.Context) ([]string, error) {
+			client, err := storage.NewClient(ctx, opts...)
+			if err != nil {
+				return nil, fmt.Errorf("failed to create storage client: %w", err)
+			}
+			defer client.Close()
+
+			attrs, err := client.Bucket(bucket).Attrs(ctx)
+			if err != nil {
+				return nil, fmt.Errorf("failed to get bucket: %w", err)
+			}
+
+			if cfg := attrs.CustomPlacementConfig; cfg != nil && len(cfg.DataLocations) > 0 {
+				return cfg.DataLocations, nil
+			}
+			return []string{attrs.Location}, nil
+		},
+	}
+}
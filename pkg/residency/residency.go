@@ -0,0 +1,242 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package residency verifies that the resources a deployment depends on are
+// located in the region the operator has declared for data residency.
+//
+// The locations of KMS keys are read from their resource IDs. The locations of
+// the Cloud SQL instance, Secret Manager secrets, and Cloud Storage buckets are
+// queried from their APIs, so the check reflects where data actually lives.
+package residency
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/exposure-notifications-server/pkg/logging"
+	"github.com/hashicorp/go-multierror"
+	"google.golang.org/api/option"
+)
+
+// Config represents the data residency configuration.
+type Config struct {
+	// Region is the region in which all data must reside. If empty, data
+	// residency checks are disabled. The region can either be an exact location
+	// (e.g. "us-east1") or a multi-region (e.g. "us"). A multi-region permits any
+	// location that is prefixed by the multi-region.
+	Region string `env:"DATA_RESIDENCY_REGION"`
+
+	// DatabaseInstance is the connection name of the Cloud SQL instance, in the
+	// format "project:region:instance".
+	DatabaseInstance string `env:"DATA_RESIDENCY_DATABASE_INSTANCE"`
+
+	// Secrets are the resource names of the Secret Manager secrets, in the format
+	// "projects/p/secrets/s".
+	Secrets []string `env:"DATA_RESIDENCY_SECRETS"`
+
+	// StorageBuckets are the names of the Cloud Storage buckets.
+	StorageBuckets []string `env:"DATA_RESIDENCY_STORAGE_BUCKETS"`
+
+	// CheckPeriod is the frequency with which data residency is re-verified
+	// after startup. Set to 0 to only check at startup.
+	CheckPeriod time.Duration `env:"DATA_RESIDENCY_CHECK_PERIOD, default=1h"`
+}
+
+// Enabled returns true if data residency checks are enabled.
+func (c *Config) Enabled() bool {
+	return c != nil && strings.TrimSpace(c.Region) != ""
+}
+
+// Locator returns the locations in which a resource stores data.
+type Locator func(ctx context.Context) ([]string, error)
+
+// Resource is a single resource whose location is checked.
+type Resource struct {
+	// Name is the human-readable name of the resource, used in error messages.
+	Name string
+
+	// Locate returns the locations of the resource. If nil, the location of the
+	// resource is unknown.
+	Locate Locator
+}
+
+// Resources returns the resources declared in the configuration. Resource
+// types with nothing declared are returned with an unknown location, so they
+// fail the check. The options are used for the clients that query resource
+// locations.
+func (c *Config) Resources(opts ...option.ClientOption) []*Resource {
+	resources := []*Resource{
+		DatabaseInstanceResource("database", c.DatabaseInstance, opts...),
+	}
+
+	if len(c.Secrets) == 0 {
+		resources = append(resources, &Resource{Name: "secret manager"})
+	}
+	for _, s := range c.Secrets {
+		resources = append(resources, SecretResource("secret "+s, s, opts...))
+	}
+
+	if len(c.StorageBuckets) == 0 {
+		resources = append(resources, &Resource{Name: "storage"})
+	}
+	for _, b := range c.StorageBuckets {
+		resources = append(resources, BucketResource("bucket "+b, b, opts...))
+	}
+
+	return resources
+}
+
+// StaticResource builds a resource with known locations. If no non-empty
+// locations are given, the location of the resource is unknown.
+func StaticResource(name string, locations ...string) *Resource {
+	r := &Resource{Name: name}
+
+	var known []string
+	for _, l := range locations {
+		if l != "" {
+			known = append(known, l)
+		}
+	}
+	if len(known) > 0 {
+		r.Locate = func(_ context.Context) ([]string, error) {
+			return known, nil
+		}
+	}
+	return r
+}
+
+// KeyResource builds a resource from a key management resource ID. The
+// location is parsed from the ID (e.g.
+// "projects/p/locations/us-east1/keyRings/r"). If the ID is empty, it returns
+// nil.
+func KeyResource(name, id string) *Resource {
+	if id == "" {
+		return nil
+	}
+	return StaticResource(name, LocationFromResourceID(id))
+}
+
+// LocationFromResourceID extracts the location segment from a Google Cloud
+// resource ID. It returns the empty string if there is no location segment.
+func LocationFromResourceID(id string) string {
+	parts := strings.Split(strings.Trim(id, "/"), "/")
+	for i := 0; i < len(parts)-1; i++ {
+		if parts[i] == "locations" {
+			return parts[i+1]
+		}
+	}
+	return ""
+}
+
+// LocationMatches returns true if the location is within the region.
+func LocationMatches(region, location string) bool {
+	region = strings.ToLower(strings.TrimSpace(region))
+	location = strings.ToLower(strings.TrimSpace(location))
+
+	if region == "" || location == "" {
+		return false
+	}
+	return location == region || strings.HasPrefix(location, region+"-")
+}
+
+// Checker verifies the location of resources.
+type Checker struct {
+	config    *Config
+	resources []*Resource
+}
+
+// New creates a new checker for the given resources. Nil resources are
+// ignored. The declared resources from the config are always included, and
+// their locations are queried with clients created with opts.
+func New(cfg *Config, opts []option.ClientOption, resources ...*Resource) *Checker {
+	all := cfg.Resources(opts...)
+	for _, r := range resources {
+		if r != nil {
+			all = append(all, r)
+		}
+	}
+
+	return &Checker{
+		config:    cfg,
+		resources: all,
+	}
+}
+
+// Check verifies that all resources are within the configured region. It
+// returns an error describing every resource that is not, or whose location
+// could not be determined. If data residency is not enabled, it always returns
+// nil.
+func (c *Checker) Check(ctx context.Context) error {
+	if !c.config.Enabled() {
+		return nil
+	}
+
+	var merr *multierror.Error
+	for _, r := range c.resources {
+		if r.Locate == nil {
+			merr = multierror.Append(merr, fmt.Errorf("%s: location is unknown", r.Name))
+			continue
+		}
+
+		locations, err := r.Locate(ctx)
+		if err != nil {
+			merr = multierror.Append(merr, fmt.Errorf("%s: failed to get location: %w", r.Name, err))
+			continue
+		}
+		if len(locations) == 0 {
+			merr = multierror.Append(merr, fmt.Errorf("%s: location is unknown", r.Name))
+			continue
+		}
+
+		for _, l := range locations {
+			if !LocationMatches(c.config.Region, l) {
+				merr = multierror.Append(merr, fmt.Errorf("%s: location %q is outside of region %q",
+					r.Name, l, c.config.Region))
+			}
+		}
+	}
+	return merr.ErrorOrNil()
+}
+
+// Run periodically re-checks data residency until the context is cancelled.
+// Since resources can be moved or reconfigured while the service is running,
+// failures are logged as errors. It returns immediately if data residency is
+// not enabled or the check period is 0.
+func (c *Checker) Run(ctx context.Context) {
+	if !c.config.Enabled() || c.config.CheckPeriod <= 0 {
+		return
+	}
+
+	logger := logging.FromContext(ctx).Named("residency.Run").
+		With("region", c.config.Region)
+
+	ticker := time.NewTicker(c.config.CheckPeriod)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if err := c.Check(ctx); err != nil {
+			logger.Errorw("data residency check failed", "error", err)
+			continue
+		}
+		logger.Debugw("data residency check passed")
+	}
+}
@@ -0,0 +1,323 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package residency
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLocationFromResourceID(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name string
+		id   string
+		exp  string
+	}{
+		{
+			name: "empty",
+			id:   "",
+			exp:  "",
+		},
+		{
+			name: "no_location",
+			id:   "projects/p/secrets/s",
+			exp:  "",
+		},
+		{
+			name: "keyring",
+			id:   "projects/p/locations/us-east1/keyRings/r",
+			exp:  "us-east1",
+		},
+		{
+			name: "key_version",
+			id:   "/projects/p/locations/europe-west1/keyRings/r/cryptoKeys/k/cryptoKeyVersions/1",
+			exp:  "europe-west1",
+		},
+		{
+			name: "trailing_locations",
+			id:   "projects/p/locations",
+			exp:  "",
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			if got, want := LocationFromResourceID(tc.id), tc.exp; got != want {
+				t.Errorf("expected %q to be %q", got, want)
+			}
+		})
+	}
+}
+
+func TestLocationMatches(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name     string
+		region   string
+		location string
+		exp      bool
+	}{
+		{"empty_region", "", "us-east1", false},
+		{"empty_location", "us-east1", "", false},
+		{"exact", "us-east1", "us-east1", true},
+		{"exact_case", "US-EAST1", "us-east1", true},
+		{"different", "us-east1", "us-west1", false},
+		{"multi_region", "us", "us-central1", true},
+		{"multi_region_self", "us", "us", true},
+		{"multi_region_outside", "us", "europe-west1", false},
+		{"prefix_not_boundary", "us-east", "us-east1", false},
+		{"global", "us", "global", false},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			if got, want := LocationMatches(tc.region, tc.location), tc.exp; got != want {
+				t.Errorf("expected %t to be %t", got, want)
+			}
+		})
+	}
+}
+
+func TestConfig_Resources(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name    string
+		config  *Config
+		exp     []string
+		unknown []string
+	}{
+		{
+			name:    "undeclared",
+			config:  &Config{Region: "us"},
+			exp:     []string{"database", "secret manager", "storage"},
+			unknown: []string{"database", "secret manager", "storage"},
+		},
+		{
+			name: "declared",
+			config: &Config{
+				Region:           "us",
+				DatabaseInstance: "p:us-east1:db",
+				Secrets:          []string{"projects/p/secrets/a", "projects/p/secrets/b"},
+				StorageBuckets:   []string{"backups"},
+			},
+			exp: []string{
+				"database",
+				"secret projects/p/secrets/a",
+				"secret projects/p/secrets/b",
+				"bucket backups",
+			},
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			var names, unknown []string
+			for _, r := range tc.config.Resources() {
+				names = append(names, r.Name)
+				if r.Locate == nil {
+					unknown = append(unknown, r.Name)
+				}
+			}
+
+			if got, want := names, tc.exp; !reflect.DeepEqual(got, want) {
+				t.Errorf("expected %q to be %q", got, want)
+			}
+			if got, want := unknown, tc.unknown; !reflect.DeepEqual(got, want) {
+				t.Errorf("expected unknown %q to be %q", got, want)
+			}
+		})
+	}
+}
+
+func TestChecker_Check(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	failing := &Resource{
+		Name: "bucket backups",
+		Locate: func(_ context.Context) ([]string, error) {
+			return nil, fmt.Errorf("permission denied")
+		},
+	}
+
+	cases := []struct {
+		name      string
+		config    *Config
+		resources []*Resource
+		err       string
+	}{
+		{
+			name:   "disabled",
+			config: &Config{},
+			resources: []*Resource{
+				StaticResource("database", "europe-west1"),
+			},
+		},
+		{
+			name:   "all_match",
+			config: &Config{Region: "us"},
+			resources: []*Resource{
+				StaticResource("database", "us-east1"),
+				StaticResource("secret", "us-east1", "us-central1"),
+				KeyResource("database keyring", "projects/p/locations/us-east1/keyRings/r"),
+			},
+		},
+		{
+			name:   "unknown",
+			config: &Config{Region: "us"},
+			resources: []*Resource{
+				StaticResource("storage"),
+			},
+			err: "storage: location is unknown",
+		},
+		{
+			name:   "key_without_location",
+			config: &Config{Region: "us"},
+			resources: []*Resource{
+				KeyResource("database keyring", "projects/p/keyRings/r"),
+			},
+			err: "database keyring: location is unknown",
+		},
+		{
+			name:   "mismatch",
+			config: &Config{Region: "us"},
+			resources: []*Resource{
+				KeyResource("database keyring", "projects/p/locations/europe-west1/keyRings/r"),
+			},
+			err: `database keyring: location "europe-west1" is outside of region "us"`,
+		},
+		{
+			name:   "one_replica_outside",
+			config: &Config{Region: "us"},
+			resources: []*Resource{
+				StaticResource("secret", "us-east1", "europe-west1"),
+			},
+			err: `secret: location "europe-west1" is outside of region "us"`,
+		},
+		{
+			name:   "automatic_replication",
+			config: &Config{Region: "us"},
+			resources: []*Resource{
+				StaticResource("secret", locationGlobal),
+			},
+			err: `secret: location "global" is outside of region "us"`,
+		},
+		{
+			name:      "lookup_error",
+			config:    &Config{Region: "us"},
+			resources: []*Resource{failing},
+			err:       "bucket backups: failed to get location: permission denied",
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			checker := &Checker{config: tc.config, resources: tc.resources}
+			err := checker.Check(ctx)
+			if tc.err == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+
+			if err == nil {
+				t.Fatalf("expected error")
+			}
+			if got, want := err.Error(), tc.err; !strings.Contains(got, want) {
+				t.Errorf("expected %q to contain %q", got, want)
+			}
+		})
+	}
+}
+
+func TestParseConnectionName(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name     string
+		input    string
+		project  string
+		instance string
+		err      bool
+	}{
+		{"valid", "p:us-east1:db", "p", "db", false},
+		{"empty", "", "", "", true},
+		{"missing_region", "p:db", "", "", true},
+		{"missing_instance", "p:us-east1:", "", "", true},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			project, instance, err := parseConnectionName(tc.input)
+			if (err != nil) != tc.err {
+				t.Fatalf("expected error %t, got %v", tc.err, err)
+			}
+			if got, want := project, tc.project; got != want {
+				t.Errorf("expected %q to be %q", got, want)
+			}
+			if got, want := instance, tc.instance; got != want {
+				t.Errorf("expected %q to be %q", got, want)
+			}
+		})
+	}
+}
+
+func TestSecretName(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		input string
+		exp   string
+	}{
+		{"projects/p/secrets/s", "projects/p/secrets/s"},
+		{"secret://projects/p/secrets/s", "projects/p/secrets/s"},
+		{"secret://projects/p/secrets/s/versions/latest", "projects/p/secrets/s"},
+	}
+
+	for _, tc := range cases {
+		if got, want := secretName(tc.input), tc.exp; got != want {
+			t.Errorf("expected %q to be %q", got, want)
+		}
+	}
+}
@@ -0,0 +1,114 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package shadow
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"reflect"
+
+	"github.com/google/exposure-notifications-verification-server/pkg/api"
+)
+
+// Results of mirroring a request.
+const (
+	// ResultMatch means the candidate responded the same as this server.
+	ResultMatch = "MATCH"
+
+	// ResultStatus means the candidate responded with a different status code.
+	ResultStatus = "DIVERGED_STATUS"
+
+	// ResultErrorCode means the candidate responded with a different API error
+	// code.
+	ResultErrorCode = "DIVERGED_ERROR_CODE"
+
+	// ResultBody means the candidate responded with a different body.
+	ResultBody = "DIVERGED_BODY"
+
+	// ResultClaimRace means this server succeeded, but the candidate reported
+	// the code or token as already used. This usually means this server claimed
+	// it before the candidate checked it, but a high rate can also indicate a
+	// real divergence.
+	ResultClaimRace = "CLAIM_RACE"
+
+	// ResultCandidateError means the request could not be sent to the
+	// candidate or it did not respond in time.
+	ResultCandidateError = "CANDIDATE_ERROR"
+
+	// ResultDropped means the request was sampled, but not mirrored because too
+	// many mirrored requests were outstanding.
+	ResultDropped = "DROPPED"
+)
+
+// ignoredFields are response fields that legitimately differ between two
+// servers handling the same request. Tokens and certificates are signed with
+// different timestamps and IDs, error messages are free text, and padding is
+// random.
+var ignoredFields = []string{"padding", "token", "certificate", "error", "error_code"}
+
+// Response is a response to a mirrored request.
+type Response struct {
+	StatusCode int
+	Body       []byte
+}
+
+// errorCode returns the API error code in the response body, if any.
+func (r *Response) errorCode() string {
+	var body struct {
+		ErrorCode string `json:"errorCode"`
+	}
+	_ = json.Unmarshal(r.Body, &body)
+	return body.ErrorCode
+}
+
+// Compare compares the candidate's response to the primary response and
+// returns ResultMatch or the kind of divergence.
+func Compare(primary, candidate *Response) string {
+	if primary.StatusCode != candidate.StatusCode {
+		if primary.StatusCode == http.StatusOK && isClaimedError(candidate.errorCode()) {
+			return ResultClaimRace
+		}
+		return ResultStatus
+	}
+
+	if primary.errorCode() != candidate.errorCode() {
+		return ResultErrorCode
+	}
+
+	var primaryBody, candidateBody map[string]interface{}
+	if json.Unmarshal(primary.Body, &primaryBody) != nil || json.Unmarshal(candidate.Body, &candidateBody) != nil {
+		// Not JSON, so the bodies must match exactly.
+		if !bytes.Equal(primary.Body, candidate.Body) {
+			return ResultBody
+		}
+		return ResultMatch
+	}
+
+	for _, field := range ignoredFields {
+		delete(primaryBody, field)
+		delete(candidateBody, field)
+	}
+	if !reflect.DeepEqual(primaryBody, candidateBody) {
+		return ResultBody
+	}
+	return ResultMatch
+}
+
+// isClaimedError returns true if the error code is returned for a code or
+// token that was already used.
+func isClaimedError(code string) bool {
+	return code == api.ErrVerifyCodeInvalid || code == api.ErrTokenExpired
+}
@@ -0,0 +1,86 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package shadow
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestCompare(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name      string
+		primary   *Response
+		candidate *Response
+		exp       string
+	}{
+		{
+			name:      "match",
+			primary:   &Response{StatusCode: http.StatusOK, Body: []byte(`{"testtype":"confirmed","token":"a","padding":"x"}`)},
+			candidate: &Response{StatusCode: http.StatusOK, Body: []byte(`{"testtype":"confirmed","token":"b","padding":"yy"}`)},
+			exp:       ResultMatch,
+		},
+		{
+			name:      "match_errors",
+			primary:   &Response{StatusCode: http.StatusBadRequest, Body: []byte(`{"error":"one","errorCode":"code_invalid"}`)},
+			candidate: &Response{StatusCode: http.StatusBadRequest, Body: []byte(`{"error":"two","errorCode":"code_invalid"}`)},
+			exp:       ResultMatch,
+		},
+		{
+			name:      "status",
+			primary:   &Response{StatusCode: http.StatusOK, Body: []byte(`{}`)},
+			candidate: &Response{StatusCode: http.StatusInternalServerError, Body: []byte(`{"errorCode":"internal_server_error"}`)},
+			exp:       ResultStatus,
+		},
+		{
+			name:      "claim_race",
+			primary:   &Response{StatusCode: http.StatusOK, Body: []byte(`{"token":"a"}`)},
+			candidate: &Response{StatusCode: http.StatusBadRequest, Body: []byte(`{"errorCode":"code_invalid"}`)},
+			exp:       ResultClaimRace,
+		},
+		{
+			name:      "error_code",
+			primary:   &Response{StatusCode: http.StatusBadRequest, Body: []byte(`{"errorCode":"code_invalid"}`)},
+			candidate: &Response{StatusCode: http.StatusBadRequest, Body: []byte(`{"errorCode":"code_expired"}`)},
+			exp:       ResultErrorCode,
+		},
+		{
+			name:      "body",
+			primary:   &Response{StatusCode: http.StatusOK, Body: []byte(`{"testtype":"confirmed"}`)},
+			candidate: &Response{StatusCode: http.StatusOK, Body: []byte(`{"testtype":"likely"}`)},
+			exp:       ResultBody,
+		},
+		{
+			name:      "body_not_json",
+			primary:   &Response{StatusCode: http.StatusOK, Body: []byte(`ok`)},
+			candidate: &Response{StatusCode: http.StatusOK, Body: []byte(`not ok`)},
+			exp:       ResultBody,
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			if got, want := Compare(tc.primary, tc.candidate), tc.exp; got != want {
+				t.Errorf("expected %q to be %q", got, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,62 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package shadow
+
+import (
+	"context"
+
+	"github.com/google/exposure-notifications-server/pkg/logging"
+	enobs "github.com/google/exposure-notifications-server/pkg/observability"
+	"github.com/google/exposure-notifications-verification-server/pkg/observability"
+
+	"go.opencensus.io/stats"
+	"go.opencensus.io/stats/view"
+	"go.opencensus.io/tag"
+)
+
+const metricPrefix = observability.MetricRoot + "/shadow"
+
+var (
+	mRequests = stats.Int64(metricPrefix+"/requests", "sampled requests mirrored to a candidate", stats.UnitDimensionless)
+
+	// pathTagKey is the path of the mirrored request.
+	pathTagKey = tag.MustNewKey("path")
+
+	// resultTagKey is the outcome of the mirrored request, one of the Result
+	// constants.
+	resultTagKey = tag.MustNewKey("shadow_result")
+)
+
+func init() {
+	enobs.CollectViews([]*view.View{
+		{
+			Name:        metricPrefix + "/requests",
+			Description: "Number of sampled requests mirrored to a candidate, by outcome",
+			TagKeys:     append(observability.CommonTagKeys(), pathTagKey, resultTagKey),
+			Measure:     mRequests,
+			Aggregation: view.Count(),
+		},
+	}...)
+}
+
+// record records the outcome of a mirrored request.
+func record(ctx context.Context, path, result string) {
+	if err := stats.RecordWithTags(ctx, []tag.Mutator{
+		tag.Upsert(pathTagKey, path),
+		tag.Upsert(resultTagKey, result),
+	}, mRequests.M(1)); err != nil {
+		logging.FromContext(ctx).Named("shadow.record").Errorw("failed to record metric", "error", err)
+	}
+}
@@ -0,0 +1,215 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package shadow mirrors a sample of device API requests to a candidate
+// deployment and compares the responses, so changes to the verification path
+// can be validated against production traffic before they are rolled out.
+//
+// Mirroring never affects the production response: requests are sent to the
+// candidate in the background, and the responses are compared after the
+// production response has been written. The candidate is expected to run in
+// dry-run mode, so it does not claim codes or tokens.
+package shadow
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"strings"
+
+	"github.com/google/exposure-notifications-server/pkg/logging"
+	"github.com/google/exposure-notifications-verification-server/pkg/config"
+	"github.com/google/exposure-notifications-verification-server/pkg/controller"
+
+	"go.opencensus.io/tag"
+)
+
+// HeaderShadow is set on mirrored requests, so the candidate can tell them
+// apart in logs.
+const HeaderShadow = "X-Shadow-Request"
+
+// maxResponseBytes is the largest candidate response that is read. Device API
+// responses are much smaller than this.
+const maxResponseBytes = 64 * 1024
+
+// Mirror mirrors requests to a candidate deployment.
+type Mirror struct {
+	candidateURL string
+	sampleRate   float64
+	client       *http.Client
+
+	// inFlight limits the number of outstanding mirrored requests.
+	inFlight chan struct{}
+
+	// sample decides if a request is mirrored. It is replaced in tests.
+	sample func() bool
+}
+
+// New creates a new mirror from the configuration.
+func New(cfg *config.ShadowConfig) (*Mirror, error) {
+	if !cfg.Enabled() {
+		return nil, fmt.Errorf("shadow traffic is not enabled")
+	}
+
+	m := &Mirror{
+		candidateURL: strings.TrimSuffix(cfg.CandidateURL, "/"),
+		sampleRate:   cfg.SampleRate,
+		client: &http.Client{
+			Timeout: cfg.Timeout,
+		},
+		inFlight: make(chan struct{}, cfg.MaxInFlight),
+	}
+	m.sample = func() bool {
+		return rand.Float64() < m.sampleRate //nolint:gosec // sampling does not need to be unpredictable
+	}
+	return m, nil
+}
+
+// Handle mirrors a sample of requests to the candidate and records how the
+// candidate's responses compare to this server's.
+//
+// This must come after RequireAPIKey, so the realm is on the context. It
+// should also come after chaff processing and rate limiting, so chaff and
+// rate limited requests are not mirrored.
+func (m *Mirror) Handle(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		logger := logging.FromContext(ctx).Named("shadow.Handle")
+
+		if !m.sample() {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		// The candidate sees this server's address instead of the client's, so
+		// realms with an API server firewall would always be rejected.
+		realm := controller.RealmFromContext(ctx)
+		if realm == nil || len(realm.AllowedCIDRsAPIServer) > 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		path := r.URL.Path
+
+		select {
+		case m.inFlight <- struct{}{}:
+		default:
+			record(ctx, path, ResultDropped)
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		body, err := io.ReadAll(r.Body)
+		r.Body = io.NopCloser(io.MultiReader(bytes.NewReader(body), r.Body))
+		if err != nil {
+			// Let the handler see and report the same error.
+			<-m.inFlight
+			logger.Debugw("failed to read request body, not mirroring", "error", err)
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		// The mirrored request outlives this request, so it gets its own context
+		// that keeps the logger and metric tags.
+		bgCtx := logging.WithLogger(tag.NewContext(context.Background(), tag.FromContext(ctx)), logger)
+		header := r.Header.Clone()
+		uri := r.URL.RequestURI()
+
+		// Send the mirrored request now, instead of after this server responds,
+		// to make it less likely that this server claims the code or token
+		// before the candidate checks it.
+		primaryCh := make(chan *Response, 1)
+		go func() {
+			defer func() { <-m.inFlight }()
+
+			candidate, err := m.send(bgCtx, r.Method, uri, header, body)
+			primary := <-primaryCh
+
+			if err != nil {
+				logger.Warnw("failed to mirror request", "path", path, "error", err)
+				record(bgCtx, path, ResultCandidateError)
+				return
+			}
+
+			result := Compare(primary, candidate)
+			if result != ResultMatch {
+				logger.Warnw("candidate response diverged",
+					"path", path,
+					"result", result,
+					"primary_status", primary.StatusCode,
+					"candidate_status", candidate.StatusCode,
+					"primary_error_code", primary.errorCode(),
+					"candidate_error_code", candidate.errorCode())
+			}
+			record(bgCtx, path, result)
+		}()
+
+		rec := &recorder{ResponseWriter: w, status: http.StatusOK}
+		defer func() { primaryCh <- rec.response() }()
+		next.ServeHTTP(rec, r)
+	})
+}
+
+// send sends the mirrored request to the candidate and reads the response.
+func (m *Mirror) send(ctx context.Context, method, uri string, header http.Header, body []byte) (*Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, m.candidateURL+uri, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to build request: %w", err)
+	}
+	req.Header = header
+	req.Header.Set(HeaderShadow, "1")
+
+	// Let the transport negotiate compression, so it also decompresses the
+	// response.
+	req.Header.Del("Accept-Encoding")
+
+	resp, err := m.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	b, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseBytes))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	return &Response{StatusCode: resp.StatusCode, Body: b}, nil
+}
+
+// recorder captures the status and body written to a response, while still
+// writing them to the client.
+type recorder struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (r *recorder) WriteHeader(code int) {
+	r.status = code
+	r.ResponseWriter.WriteHeader(code)
+}
+
+func (r *recorder) Write(b []byte) (int, error) {
+	if r.body.Len() < maxResponseBytes {
+		r.body.Write(b)
+	}
+	return r.ResponseWriter.Write(b)
+}
+
+func (r *recorder) response() *Response {
+	return &Response{StatusCode: r.status, Body: r.body.Bytes()}
+}
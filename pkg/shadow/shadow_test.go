@@ -0,0 +1,126 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package shadow
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/exposure-notifications-verification-server/pkg/config"
+	"github.com/google/exposure-notifications-verification-server/pkg/controller"
+	"github.com/google/exposure-notifications-verification-server/pkg/database"
+)
+
+func TestMirror_Handle(t *testing.T) {
+	t.Parallel()
+
+	type candidateRequest struct {
+		path   string
+		body   string
+		shadow string
+	}
+	received := make(chan *candidateRequest, 1)
+
+	candidate := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		received <- &candidateRequest{
+			path:   r.URL.Path,
+			body:   string(b),
+			shadow: r.Header.Get(HeaderShadow),
+		}
+		w.WriteHeader(http.StatusTeapot)
+	}))
+	t.Cleanup(candidate.Close)
+
+	m, err := New(&config.ShadowConfig{
+		CandidateURL: candidate.URL,
+		SampleRate:   1,
+		Timeout:      5 * time.Second,
+		MaxInFlight:  1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	handler := m.Handle(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write(b)
+	}))
+
+	t.Run("no_realm", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodPost, "/api/verify", strings.NewReader(`{}`))
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+
+		select {
+		case got := <-received:
+			t.Errorf("expected no mirrored request, got %#v", got)
+		case <-time.After(100 * time.Millisecond):
+		}
+	})
+
+	t.Run("firewall", func(t *testing.T) {
+		realm := database.NewRealmWithDefaults("test")
+		realm.AllowedCIDRsAPIServer = []string{"0.0.0.0/0"}
+
+		r := httptest.NewRequest(http.MethodPost, "/api/verify", strings.NewReader(`{}`))
+		r = r.Clone(controller.WithRealm(r.Context(), realm))
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+
+		select {
+		case got := <-received:
+			t.Errorf("expected no mirrored request, got %#v", got)
+		case <-time.After(100 * time.Millisecond):
+		}
+	})
+
+	t.Run("mirrors", func(t *testing.T) {
+		realm := database.NewRealmWithDefaults("test")
+
+		r := httptest.NewRequest(http.MethodPost, "/api/verify", strings.NewReader(`{"code":"123456"}`))
+		r = r.Clone(controller.WithRealm(r.Context(), realm))
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+
+		// The primary response is not affected by the candidate.
+		if got, want := w.Code, http.StatusOK; got != want {
+			t.Errorf("expected %d to be %d", got, want)
+		}
+		if got, want := w.Body.String(), `{"code":"123456"}`; got != want {
+			t.Errorf("expected %q to be %q", got, want)
+		}
+
+		select {
+		case got := <-received:
+			if got, want := got.path, "/api/verify"; got != want {
+				t.Errorf("expected %q to be %q", got, want)
+			}
+			if got, want := got.body, `{"code":"123456"}`; got != want {
+				t.Errorf("expected %q to be %q", got, want)
+			}
+			if got, want := got.shadow, "1"; got != want {
+				t.Errorf("expected %q to be %q", got, want)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("expected mirrored request")
+		}
+	})
+}
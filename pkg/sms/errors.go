@@ -0,0 +1,39 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sms
+
+import (
+	"errors"
+	"strconv"
+)
+
+// ErrorCode returns the provider's error code for a failed send, normalized
+// for recording in the SMS error statistics. Twilio codes are returned as-is so
+// they match the codes reported by the Twilio alerts webhook. Codes from other
+// providers are prefixed with the provider name, since their numbering
+// overlaps. It returns false if the error did not come from a provider.
+func ErrorCode(err error) (string, bool) {
+	var tErr *TwilioError
+	if errors.As(err, &tErr) && tErr.Code != 0 {
+		return strconv.Itoa(tErr.Code), true
+	}
+
+	var mErr *MessageBirdError
+	if errors.As(err, &mErr) && mErr.Code() != 0 {
+		return "messagebird-" + strconv.Itoa(mErr.Code()), true
+	}
+
+	return "", false
+}
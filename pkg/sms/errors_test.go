@@ -0,0 +1,82 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sms
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestErrorCode(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name string
+		err  error
+		code string
+		ok   bool
+	}{
+		{
+			name: "nil",
+			err:  nil,
+		},
+		{
+			name: "other",
+			err:  errors.New("oops"),
+		},
+		{
+			name: "twilio",
+			err:  &TwilioError{Code: 21211, Message: "invalid number"},
+			code: "21211",
+			ok:   true,
+		},
+		{
+			name: "twilio_wrapped",
+			err:  fmt.Errorf("failed: %w", &TwilioError{Code: 21611}),
+			code: "21611",
+			ok:   true,
+		},
+		{
+			name: "messagebird",
+			err: &MessageBirdError{Errors: []*MessageBirdErrorDetail{
+				{Code: 9, Description: "no (correct) recipients found", Parameter: "recipients"},
+				{Code: 2, Description: "request not allowed"},
+			}},
+			code: "messagebird-9",
+			ok:   true,
+		},
+		{
+			name: "messagebird_empty",
+			err:  &MessageBirdError{},
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			code, ok := ErrorCode(tc.err)
+			if got, want := ok, tc.ok; got != want {
+				t.Errorf("expected %t to be %t", got, want)
+			}
+			if got, want := code, tc.code; got != want {
+				t.Errorf("expected %q to be %q", got, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,55 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sms
+
+import (
+	"context"
+	"fmt"
+)
+
+// Recorder records messages instead of sending them.
+type Recorder interface {
+	// RecordSMS records that the message would have been sent to the given
+	// number.
+	RecordSMS(ctx context.Context, to, message string) error
+}
+
+// NoopInspect does not send messages, but records them so they can be
+// inspected later. It is intended for local development and testing.
+type NoopInspect struct {
+	recorder Recorder
+}
+
+var _ Provider = (*NoopInspect)(nil)
+
+// NewNoopInspect creates a new SMS sender that records messages with the
+// given recorder.
+func NewNoopInspect(_ context.Context, recorder Recorder) (Provider, error) {
+	if recorder == nil {
+		return nil, fmt.Errorf("missing recorder")
+	}
+
+	return &NoopInspect{
+		recorder: recorder,
+	}, nil
+}
+
+// SendSMS records the message.
+func (p *NoopInspect) SendSMS(ctx context.Context, to, message string) error {
+	if err := p.recorder.RecordSMS(ctx, to, message); err != nil {
+		return fmt.Errorf("failed to record sms: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,157 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sms
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/google/exposure-notifications-verification-server/internal/project"
+	"github.com/sethvargo/go-retry"
+)
+
+var _ Provider = (*MessageBird)(nil)
+
+// MessageBird sends messages via the MessageBird API.
+type MessageBird struct {
+	client     *http.Client
+	originator string
+}
+
+// NewMessageBird creates a new MessageBird SMS sender with the given access
+// key. The originator is the E.164 phone number or alphanumeric sender ID from
+// which messages are sent.
+func NewMessageBird(ctx context.Context, accessKey, originator string) (Provider, error) {
+	transport := project.DefaultHTTPTransport()
+	client := &http.Client{
+		Timeout:   5 * time.Second,
+		Transport: &messageBirdAuthRoundTripper{transport, accessKey},
+	}
+
+	return &MessageBird{
+		client:     client,
+		originator: originator,
+	}, nil
+}
+
+// messageBirdRequest is the request body for creating a message.
+type messageBirdRequest struct {
+	Originator string   `json:"originator"`
+	Recipients []string `json:"recipients"`
+	Body       string   `json:"body"`
+}
+
+// SendSMS sends a message using the MessageBird API.
+func (p *MessageBird) SendSMS(ctx context.Context, to, message string) error {
+	b := retry.NewFibonacci(250 * time.Millisecond)
+	b = retry.WithMaxRetries(4, b)
+
+	reqBody, err := json.Marshal(&messageBirdRequest{
+		Originator: p.originator,
+		Recipients: []string{to},
+		Body:       message,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	return retry.Do(ctx, b, func(ctx context.Context) error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, "/messages", bytes.NewReader(reqBody))
+		if err != nil {
+			return fmt.Errorf("failed to build request: %w", err)
+		}
+		req.Close = true
+
+		resp, err := p.client.Do(req)
+		if err != nil {
+			return retry.RetryableError(fmt.Errorf("failed to make request: %w", err))
+		}
+		defer resp.Body.Close()
+
+		respBody, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return fmt.Errorf("failed to read response body: %w", err)
+		}
+
+		if code := resp.StatusCode; code < http.StatusOK || code >= http.StatusMultipleChoices {
+			var merr MessageBirdError
+			if err := json.Unmarshal(respBody, &merr); err != nil || len(merr.Errors) == 0 {
+				return fmt.Errorf("messagebird error %d: %s", code, respBody)
+			}
+			return &merr
+		}
+
+		return nil
+	})
+}
+
+// messageBirdAuthRoundTripper is an http.RoundTripper that updates the
+// authentication and headers to match MessageBird's API.
+type messageBirdAuthRoundTripper struct {
+	transport *http.Transport
+	accessKey string
+}
+
+// RoundTrip implements http.RoundTripper.
+func (rt *messageBirdAuthRoundTripper) RoundTrip(r *http.Request) (*http.Response, error) {
+	r.URL = &url.URL{
+		Scheme: "https",
+		Host:   "rest.messagebird.com",
+		Path:   "/" + strings.Trim(r.URL.Path, "/"),
+	}
+
+	r.Header.Set("Authorization", "AccessKey "+rt.accessKey)
+	r.Header.Set("Accept", "application/json")
+	r.Header.Set("Content-Type", "application/json")
+
+	return rt.transport.RoundTrip(r)
+}
+
+// MessageBirdError represents an error returned from the MessageBird API. The
+// API returns a list of errors, but only the first is used to classify the
+// failure.
+type MessageBirdError struct {
+	Errors []*MessageBirdErrorDetail `json:"errors"`
+}
+
+// MessageBirdErrorDetail is a single error returned from the MessageBird API.
+type MessageBirdErrorDetail struct {
+	Code        int    `json:"code"`
+	Description string `json:"description"`
+	Parameter   string `json:"parameter"`
+}
+
+func (e *MessageBirdError) Error() string {
+	msgs := make([]string, 0, len(e.Errors))
+	for _, d := range e.Errors {
+		msgs = append(msgs, d.Description)
+	}
+	return strings.Join(msgs, ", ")
+}
+
+// Code returns the code of the first error, or 0 if there are no errors.
+func (e *MessageBirdError) Code() int {
+	if len(e.Errors) == 0 {
+		return 0
+	}
+	return e.Errors[0].Code
+}
@@ -0,0 +1,72 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sms
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/google/exposure-notifications-verification-server/internal/project"
+)
+
+func TestMessageBird_SendSMS(t *testing.T) {
+	t.Parallel()
+
+	if testing.Short() {
+		t.Skipf("🚧 Skipping messagebird tests (short)!")
+	}
+
+	accessKey := os.Getenv("MESSAGEBIRD_ACCESS_KEY")
+	if accessKey == "" {
+		t.Skipf("🚧 🚧 Skipping messagebird tests (missing MESSAGEBIRD_ACCESS_KEY)")
+	}
+
+	cases := []struct {
+		name string
+		to   string
+		err  bool
+	}{
+		{
+			name: "invalid",
+			to:   "not-a-number",
+			err:  true,
+		},
+		{
+			name: "sends",
+			to:   project.TestPhoneNumber,
+			err:  false,
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			ctx := context.Background()
+			messageBird, err := NewMessageBird(ctx, accessKey, "TestMessage")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = messageBird.SendSMS(ctx, tc.to, "testing 123")
+			if (err != nil) != tc.err {
+				t.Fatal(err)
+			}
+		})
+	}
+}
@@ -15,6 +15,7 @@
 package sms
 
 import (
+	"context"
 	"testing"
 
 	"github.com/google/exposure-notifications-verification-server/internal/project"
@@ -49,3 +50,38 @@ func TestNoopFail_SendSMS(t *testing.T) {
 		t.Fatal("Noop fail should always fail")
 	}
 }
+
+type testRecorder struct {
+	to, message string
+}
+
+func (r *testRecorder) RecordSMS(_ context.Context, to, message string) error {
+	r.to, r.message = to, message
+	return nil
+}
+
+func TestNoopInspect_SendSMS(t *testing.T) {
+	t.Parallel()
+	ctx := project.TestContext(t)
+
+	if _, err := ProviderFor(ctx, &Config{ProviderType: ProviderTypeNoopInspect}); err == nil {
+		t.Fatal("expected error without recorder")
+	}
+
+	recorder := new(testRecorder)
+	c := &Config{ProviderType: ProviderTypeNoopInspect, Recorder: recorder}
+	p, err := ProviderFor(ctx, c)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := p.SendSMS(ctx, "+nobody", "inspect me"); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := recorder.to, "+nobody"; got != want {
+		t.Errorf("expected %q to be %q", got, want)
+	}
+	if got, want := recorder.message, "inspect me"; got != want {
+		t.Errorf("expected %q to be %q", got, want)
+	}
+}
@@ -28,9 +28,11 @@ import (
 type ProviderType string
 
 const (
-	ProviderTypeNoop     ProviderType = "NOOP"
-	ProviderTypeNoopFail ProviderType = "NOOP_FAIL"
-	ProviderTypeTwilio   ProviderType = "TWILIO"
+	ProviderTypeNoop        ProviderType = "NOOP"
+	ProviderTypeNoopFail    ProviderType = "NOOP_FAIL"
+	ProviderTypeNoopInspect ProviderType = "NOOP_INSPECT"
+	ProviderTypeTwilio      ProviderType = "TWILIO"
+	ProviderTypeMessageBird ProviderType = "MESSAGEBIRD"
 )
 
 // Config represents configuration for an SMS provider.
@@ -41,6 +43,13 @@ type Config struct {
 	TwilioAccountSid string
 	TwilioAuthToken  string
 	TwilioFromNumber string
+
+	// MessageBird options
+	MessageBirdAccessKey  string
+	MessageBirdOriginator string
+
+	// NoopInspect options
+	Recorder Recorder
 }
 
 type Provider interface {
@@ -55,8 +64,12 @@ func ProviderFor(ctx context.Context, c *Config) (Provider, error) {
 		return NewNoop(ctx)
 	case ProviderTypeNoopFail:
 		return NewNoopFail(ctx)
+	case ProviderTypeNoopInspect:
+		return NewNoopInspect(ctx, c.Recorder)
 	case ProviderTypeTwilio:
 		return NewTwilio(ctx, c.TwilioAccountSid, c.TwilioAuthToken, c.TwilioFromNumber)
+	case ProviderTypeMessageBird:
+		return NewMessageBird(ctx, c.MessageBirdAccessKey, c.MessageBirdOriginator)
 	default:
 		return nil, fmt.Errorf("unknown sms provider type: %v", typ)
 	}
@@ -0,0 +1,80 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"cloud.google.com/go/storage"
+)
+
+var _ Blobstore = (*GoogleCloudStorage)(nil)
+
+// GoogleCloudStorage implements the Blobstore interface and provides the
+// ability to write files to Google Cloud Storage.
+type GoogleCloudStorage struct {
+	client *storage.Client
+}
+
+// NewGoogleCloudStorage creates a Google Cloud Storage client using the
+// default application credentials.
+func NewGoogleCloudStorage(ctx context.Context) (Blobstore, error) {
+	client, err := storage.NewClient(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create storage client: %w", err)
+	}
+	return &GoogleCloudStorage{client: client}, nil
+}
+
+// CreateObject creates a new cloud storage object or overwrites an existing
+// one.
+func (s *GoogleCloudStorage) CreateObject(ctx context.Context, bucket, name string, contents []byte, cacheable bool, contentType string) error {
+	cacheControl := "public, max-age=86400"
+	if !cacheable {
+		cacheControl = "no-cache, max-age=0"
+	}
+
+	wc := s.client.Bucket(bucket).Object(name).NewWriter(ctx)
+	wc.CacheControl = cacheControl
+	if contentType != "" {
+		wc.ContentType = contentType
+	}
+
+	if _, err := wc.Write(contents); err != nil {
+		_ = wc.Close()
+		return fmt.Errorf("failed to write %s/%s: %w", bucket, name, err)
+	}
+	if err := wc.Close(); err != nil {
+		return fmt.Errorf("failed to close writer for %s/%s: %w", bucket, name, err)
+	}
+	return nil
+}
+
+// GetObject returns the contents for the given object.
+func (s *GoogleCloudStorage) GetObject(ctx context.Context, bucket, name string) ([]byte, error) {
+	r, err := s.client.Bucket(bucket).Object(name).NewReader(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create reader for %s/%s: %w", bucket, name, err)
+	}
+	defer r.Close()
+
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s/%s: %w", bucket, name, err)
+	}
+	return b, nil
+}
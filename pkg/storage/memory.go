@@ -0,0 +1,60 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+var _ Blobstore = (*Memory)(nil)
+
+// Memory is a blobstore that stores objects in memory. It is intended for
+// testing and local development.
+type Memory struct {
+	lock    sync.Mutex
+	objects map[string][]byte
+}
+
+// NewMemory creates a new in-memory blobstore.
+func NewMemory(ctx context.Context) (Blobstore, error) {
+	return &Memory{
+		objects: make(map[string][]byte),
+	}, nil
+}
+
+// CreateObject creates or overwrites the object.
+func (s *Memory) CreateObject(ctx context.Context, bucket, name string, contents []byte, cacheable bool, contentType string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	b := make([]byte, len(contents))
+	copy(b, contents)
+	s.objects[bucket+"/"+name] = b
+	return nil
+}
+
+// GetObject returns the contents of the object.
+func (s *Memory) GetObject(ctx context.Context, bucket, name string) ([]byte, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	b, ok := s.objects[bucket+"/"+name]
+	if !ok {
+		return nil, fmt.Errorf("object %s/%s does not exist", bucket, name)
+	}
+	return b, nil
+}
@@ -0,0 +1,46 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"context"
+	"testing"
+)
+
+func TestMemory(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	store, err := BlobstoreFor(ctx, &Config{BlobstoreType: BlobstoreTypeMemory})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := store.GetObject(ctx, "bucket", "missing"); err == nil {
+		t.Errorf("expected error for missing object")
+	}
+
+	if err := store.CreateObject(ctx, "bucket", "a/b.zip", []byte("hello"), false, "application/zip"); err != nil {
+		t.Fatal(err)
+	}
+	b, err := store.GetObject(ctx, "bucket", "a/b.zip")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(b), "hello"; got != want {
+		t.Errorf("expected %q to be %q", got, want)
+	}
+}
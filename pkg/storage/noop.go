@@ -0,0 +1,40 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"context"
+	"fmt"
+)
+
+var _ Blobstore = (*Noop)(nil)
+
+// Noop is a blobstore that discards every object.
+type Noop struct{}
+
+// NewNoop creates a new blobstore that discards every object.
+func NewNoop(ctx context.Context) (Blobstore, error) {
+	return &Noop{}, nil
+}
+
+// CreateObject discards the object.
+func (s *Noop) CreateObject(ctx context.Context, bucket, name string, contents []byte, cacheable bool, contentType string) error {
+	return nil
+}
+
+// GetObject always returns an error, because no objects are stored.
+func (s *Noop) GetObject(ctx context.Context, bucket, name string) ([]byte, error) {
+	return nil, fmt.Errorf("object %s/%s does not exist", bucket, name)
+}
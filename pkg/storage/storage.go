@@ -0,0 +1,60 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package storage is an interface over blob storage.
+package storage
+
+import (
+	"context"
+	"fmt"
+)
+
+// BlobstoreType defines a specific blobstore.
+type BlobstoreType string
+
+const (
+	BlobstoreTypeGoogleCloudStorage BlobstoreType = "GOOGLE_CLOUD_STORAGE"
+	BlobstoreTypeMemory             BlobstoreType = "MEMORY"
+	BlobstoreTypeNoop               BlobstoreType = "NOOP"
+)
+
+// Config defines the configuration for a blobstore.
+type Config struct {
+	BlobstoreType BlobstoreType `env:"BLOBSTORE, default=GOOGLE_CLOUD_STORAGE"`
+}
+
+// Blobstore defines the minimum interface for a blob storage system.
+type Blobstore interface {
+	// CreateObject creates or overwrites an object in the storage system. If
+	// cacheable is false, the object is served with headers that prevent
+	// caching.
+	CreateObject(ctx context.Context, bucket, name string, contents []byte, cacheable bool, contentType string) error
+
+	// GetObject fetches the object's contents.
+	GetObject(ctx context.Context, bucket, name string) ([]byte, error)
+}
+
+// BlobstoreFor returns the blobstore for the given config.
+func BlobstoreFor(ctx context.Context, cfg *Config) (Blobstore, error) {
+	switch typ := cfg.BlobstoreType; typ {
+	case BlobstoreTypeGoogleCloudStorage:
+		return NewGoogleCloudStorage(ctx)
+	case BlobstoreTypeMemory:
+		return NewMemory(ctx)
+	case BlobstoreTypeNoop:
+		return NewNoop(ctx)
+	default:
+		return nil, fmt.Errorf("unknown blobstore type: %v", typ)
+	}
+}
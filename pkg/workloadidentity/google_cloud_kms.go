@@ -0,0 +1,217 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build google || all
+
+package workloadidentity
+
+import (
+	"context"
+	"crypto"
+	"errors"
+	"fmt"
+	"time"
+
+	kms "cloud.google.com/go/kms/apiv1"
+	"cloud.google.com/go/kms/apiv1/kmspb"
+	"github.com/google/exposure-notifications-server/pkg/keys"
+	"github.com/sethvargo/go-gcpkms/pkg/gcpkms"
+	"github.com/sethvargo/go-retry"
+	"google.golang.org/api/iterator"
+	"google.golang.org/api/option"
+	grpccodes "google.golang.org/grpc/codes"
+	grpcstatus "google.golang.org/grpc/status"
+)
+
+func init() {
+	googleCloudKMSFunc = newGoogleCloudKMS
+}
+
+// Compile-time check to verify implements interface.
+var (
+	_ keys.KeyManager        = (*googleCloudKMS)(nil)
+	_ keys.SigningKeyManager = (*googleCloudKMS)(nil)
+)
+
+// googleCloudKMS is a Cloud KMS client that authenticates with explicit client
+// options. It behaves like keys.GoogleCloudKMS.
+type googleCloudKMS struct {
+	client *kms.KeyManagementClient
+	useHSM bool
+}
+
+// newGoogleCloudKMS creates a new key manager for Cloud KMS.
+func newGoogleCloudKMS(ctx context.Context, cfg *keys.Config, opts ...option.ClientOption) (keys.KeyManager, error) {
+	client, err := kms.NewKeyManagementClient(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("kms.NewKeyManagementClient: %w", err)
+	}
+	return &googleCloudKMS{client: client, useHSM: cfg.CreateHSMKeys}, nil
+}
+
+// cloudKMSSigningKeyVersion is a signing key version in Cloud KMS.
+type cloudKMSSigningKeyVersion struct {
+	keyID       string
+	createdAt   time.Time
+	destroyedAt time.Time
+	keyManager  *googleCloudKMS
+}
+
+func (k *cloudKMSSigningKeyVersion) KeyID() string          { return k.keyID }
+func (k *cloudKMSSigningKeyVersion) CreatedAt() time.Time   { return k.createdAt }
+func (k *cloudKMSSigningKeyVersion) DestroyedAt() time.Time { return k.destroyedAt }
+func (k *cloudKMSSigningKeyVersion) Signer(ctx context.Context) (crypto.Signer, error) {
+	return k.keyManager.NewSigner(ctx, k.keyID)
+}
+
+// NewSigner returns a signer for the key version.
+func (m *googleCloudKMS) NewSigner(ctx context.Context, keyID string) (crypto.Signer, error) {
+	return gcpkms.NewSigner(ctx, m.client, keyID)
+}
+
+// Encrypt encrypts the plaintext with the key.
+func (m *googleCloudKMS) Encrypt(ctx context.Context, keyID string, plaintext []byte, aad []byte) ([]byte, error) {
+	result, err := m.client.Encrypt(ctx, &kmspb.EncryptRequest{
+		Name:                        keyID,
+		Plaintext:                   plaintext,
+		AdditionalAuthenticatedData: aad,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt: %w", err)
+	}
+	return result.Ciphertext, nil
+}
+
+// Decrypt decrypts the ciphertext with the key.
+func (m *googleCloudKMS) Decrypt(ctx context.Context, keyID string, ciphertext []byte, aad []byte) ([]byte, error) {
+	result, err := m.client.Decrypt(ctx, &kmspb.DecryptRequest{
+		Name:                        keyID,
+		Ciphertext:                  ciphertext,
+		AdditionalAuthenticatedData: aad,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt: %w", err)
+	}
+	return result.Plaintext, nil
+}
+
+// CreateSigningKey creates a new signing key in Cloud KMS. If a key already
+// exists, it returns the existing key.
+func (m *googleCloudKMS) CreateSigningKey(ctx context.Context, parent, name string) (string, error) {
+	result, err := m.client.CreateCryptoKey(ctx, &kmspb.CreateCryptoKeyRequest{
+		Parent:      parent,
+		CryptoKeyId: name,
+		CryptoKey: &kmspb.CryptoKey{
+			Purpose: kmspb.CryptoKey_ASYMMETRIC_SIGN,
+			VersionTemplate: &kmspb.CryptoKeyVersionTemplate{
+				ProtectionLevel: m.protectionLevel(),
+				Algorithm:       kmspb.CryptoKeyVersion_EC_SIGN_P256_SHA256,
+			},
+		},
+	})
+	if err != nil {
+		if grpcstatus.Code(err) == grpccodes.AlreadyExists {
+			return fmt.Sprintf("%s/cryptoKeys/%s", parent, name), nil
+		}
+		return "", fmt.Errorf("failed to create signing key: %w", err)
+	}
+	return result.Name, nil
+}
+
+// SigningKeyVersions returns the list of enabled key versions for the parent
+// parsed as signing keys.
+func (m *googleCloudKMS) SigningKeyVersions(ctx context.Context, parent string) ([]keys.SigningKeyVersion, error) {
+	results := make([]keys.SigningKeyVersion, 0, 32)
+
+	it := m.client.ListCryptoKeyVersions(ctx, &kmspb.ListCryptoKeyVersionsRequest{
+		Parent:   parent,
+		PageSize: 200,
+		Filter:   `Filter: "state = ENABLED"`,
+	})
+	for {
+		resp, err := it.Next()
+		if errors.Is(err, iterator.Done) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to list keys: %w", err)
+		}
+
+		key := &cloudKMSSigningKeyVersion{
+			keyID:      resp.Name,
+			keyManager: m,
+		}
+		if t := resp.GetCreateTime(); t != nil {
+			key.createdAt = t.AsTime()
+		}
+		if t := resp.GetDestroyEventTime(); t != nil {
+			key.destroyedAt = t.AsTime()
+		}
+		results = append(results, key)
+	}
+
+	return results, nil
+}
+
+// CreateKeyVersion creates a new version for the given key and waits for it to
+// be enabled. The parent key must already exist.
+func (m *googleCloudKMS) CreateKeyVersion(ctx context.Context, parent string) (string, error) {
+	result, err := m.client.CreateCryptoKeyVersion(ctx, &kmspb.CreateCryptoKeyVersionRequest{
+		Parent: parent,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to create key version: %w", err)
+	}
+
+	b := retry.WithMaxRetries(10, retry.NewConstant(500*time.Millisecond))
+	if err := retry.Do(ctx, b, func(ctx context.Context) error {
+		version, err := m.client.GetCryptoKeyVersion(ctx, &kmspb.GetCryptoKeyVersionRequest{
+			Name: result.Name,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to validate if key was created: %w", err)
+		}
+
+		if version.State == kmspb.CryptoKeyVersion_ENABLED {
+			return nil
+		}
+		return retry.RetryableError(fmt.Errorf("key is not ready (%s)", version.State))
+	}); err != nil {
+		return "", err
+	}
+
+	return result.Name, nil
+}
+
+// DestroyKeyVersion marks the given key version for destruction. If the version
+// does not exist or is already destroyed, it does nothing.
+func (m *googleCloudKMS) DestroyKeyVersion(ctx context.Context, id string) error {
+	if _, err := m.client.DestroyCryptoKeyVersion(ctx, &kmspb.DestroyCryptoKeyVersionRequest{
+		Name: id,
+	}); err != nil {
+		code := grpcstatus.Code(err)
+		if code == grpccodes.NotFound || code == grpccodes.FailedPrecondition {
+			return nil
+		}
+		return fmt.Errorf("failed to destroy key version: %w", err)
+	}
+	return nil
+}
+
+func (m *googleCloudKMS) protectionLevel() kmspb.ProtectionLevel {
+	if m.useHSM {
+		return kmspb.ProtectionLevel_HSM
+	}
+	return kmspb.ProtectionLevel_SOFTWARE
+}
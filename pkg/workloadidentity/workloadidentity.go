@@ -0,0 +1,218 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package workloadidentity configures authentication to Google Cloud (Secret
+// Manager and Cloud KMS) through workload identity federation. Instead of a
+// long-lived service account key, the server exchanges an OIDC token issued by
+// its own platform (e.g. a Kubernetes projected service account token, or an
+// on-prem or other cloud identity provider) for short-lived Google
+// credentials.
+//
+// The credentials are passed explicitly to the clients this package creates.
+// Application default credentials and the process environment are not
+// modified.
+package workloadidentity
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/google/exposure-notifications-server/pkg/keys"
+	"github.com/google/exposure-notifications-server/pkg/secrets"
+
+	"google.golang.org/api/option"
+)
+
+const (
+	audiencePrefix = "//iam.googleapis.com/"
+	tokenURL       = "https://sts.googleapis.com/v1/token"
+	subjectType    = "urn:ietf:params:oauth:token-type:jwt"
+	impersonateURL = "https://iamcredentials.googleapis.com/v1/projects/-/serviceAccounts/%s:generateAccessToken"
+
+	// keyManagerGoogleCloudKMS and secretManagerGoogle are the key manager and
+	// secret manager types that authenticate to Google Cloud.
+	keyManagerGoogleCloudKMS = "GOOGLE_CLOUD_KMS"
+	secretManagerGoogle      = "GOOGLE_SECRET_MANAGER"
+)
+
+// Config represents the workload identity federation configuration.
+type Config struct {
+	// Audience is the full resource name of the workload identity pool
+	// provider, for example
+	// "//iam.googleapis.com/projects/123/locations/global/workloadIdentityPools/pool/providers/provider".
+	// If empty, workload identity federation is not used.
+	Audience string `env:"GCP_WORKLOAD_IDENTITY_AUDIENCE"`
+
+	// TokenFile is the path to a file containing the OIDC token. The file is
+	// re-read whenever credentials are refreshed, so it may be rotated.
+	TokenFile string `env:"GCP_WORKLOAD_IDENTITY_TOKEN_FILE"`
+
+	// TokenURL is a local URL that returns the OIDC token, for example a
+	// metadata server. Exactly one of TokenFile and TokenURL must be set.
+	TokenURL string `env:"GCP_WORKLOAD_IDENTITY_TOKEN_URL"`
+
+	// TokenHeaders are sent with requests to TokenURL, e.g. "Metadata:True".
+	TokenHeaders map[string]string `env:"GCP_WORKLOAD_IDENTITY_TOKEN_HEADERS"`
+
+	// TokenField is the name of the field holding the token when the token
+	// source returns JSON. If empty, the token source returns the raw token.
+	TokenField string `env:"GCP_WORKLOAD_IDENTITY_TOKEN_FIELD"`
+
+	// ServiceAccount is the email of a service account to impersonate with the
+	// federated credentials. If empty, the federated identity is granted
+	// access directly.
+	ServiceAccount string `env:"GCP_WORKLOAD_IDENTITY_SERVICE_ACCOUNT"`
+}
+
+// Enabled returns true if workload identity federation is configured.
+func (c *Config) Enabled() bool {
+	return c != nil && c.Audience != ""
+}
+
+// Validate validates the configuration.
+func (c *Config) Validate() error {
+	if !c.Enabled() {
+		if c.TokenFile != "" || c.TokenURL != "" || c.ServiceAccount != "" {
+			return fmt.Errorf("GCP_WORKLOAD_IDENTITY_AUDIENCE is required when workload identity federation is configured")
+		}
+		return nil
+	}
+
+	if !strings.HasPrefix(c.Audience, audiencePrefix) {
+		return fmt.Errorf("GCP_WORKLOAD_IDENTITY_AUDIENCE must start with %q, got: %q",
+			audiencePrefix, c.Audience)
+	}
+
+	if (c.TokenFile == "") == (c.TokenURL == "") {
+		return fmt.Errorf("exactly one of GCP_WORKLOAD_IDENTITY_TOKEN_FILE and GCP_WORKLOAD_IDENTITY_TOKEN_URL is required")
+	}
+
+	if c.TokenURL != "" {
+		u, err := url.Parse(c.TokenURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("GCP_WORKLOAD_IDENTITY_TOKEN_URL must be an http(s) URL, got: %q", c.TokenURL)
+		}
+	} else if len(c.TokenHeaders) > 0 {
+		return fmt.Errorf("GCP_WORKLOAD_IDENTITY_TOKEN_HEADERS requires GCP_WORKLOAD_IDENTITY_TOKEN_URL")
+	}
+
+	if c.ServiceAccount != "" && !strings.Contains(c.ServiceAccount, "@") {
+		return fmt.Errorf("GCP_WORKLOAD_IDENTITY_SERVICE_ACCOUNT must be a service account email, got: %q", c.ServiceAccount)
+	}
+	return nil
+}
+
+// CredentialsJSON returns the external account credential configuration that
+// Google client libraries use to exchange the OIDC token for credentials.
+func (c *Config) CredentialsJSON() ([]byte, error) {
+	source := map[string]interface{}{}
+	if c.TokenFile != "" {
+		source["file"] = c.TokenFile
+	} else {
+		source["url"] = c.TokenURL
+		if len(c.TokenHeaders) > 0 {
+			source["headers"] = c.TokenHeaders
+		}
+	}
+	if c.TokenField != "" {
+		source["format"] = map[string]string{
+			"type":                     "json",
+			"subject_token_field_name": c.TokenField,
+		}
+	}
+
+	creds := map[string]interface{}{
+		"type":               "external_account",
+		"audience":           c.Audience,
+		"subject_token_type": subjectType,
+		"token_url":          tokenURL,
+		"credential_source":  source,
+	}
+	if c.ServiceAccount != "" {
+		creds["service_account_impersonation_url"] = fmt.Sprintf(impersonateURL, c.ServiceAccount)
+	}
+
+	b, err := json.Marshal(creds)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal credentials: %w", err)
+	}
+	return b, nil
+}
+
+// ClientOptions returns the options that authenticate a Google Cloud client
+// through workload identity federation. If workload identity federation is not
+// configured, it returns no options, so clients use application default
+// credentials.
+func (c *Config) ClientOptions() ([]option.ClientOption, error) {
+	if !c.Enabled() {
+		return nil, nil
+	}
+
+	b, err := c.CredentialsJSON()
+	if err != nil {
+		return nil, err
+	}
+	return []option.ClientOption{option.WithCredentialsJSON(b)}, nil
+}
+
+// googleCloudKMSFunc and googleSecretManagerFunc create the Google Cloud key
+// manager and secret manager with the given client options. They are only
+// set when the Google Cloud implementations are compiled in.
+var (
+	googleCloudKMSFunc      func(ctx context.Context, cfg *keys.Config, opts ...option.ClientOption) (keys.KeyManager, error)
+	googleSecretManagerFunc func(ctx context.Context, cfg *secrets.Config, opts ...option.ClientOption) (secrets.SecretManager, error)
+)
+
+// KeyManagerFor returns the key manager for the given configuration. If
+// workload identity federation is configured and the key manager is Google
+// Cloud KMS, the client authenticates with the federated credentials.
+// Otherwise it is the same as keys.KeyManagerFor.
+func KeyManagerFor(ctx context.Context, c *Config, cfg *keys.Config) (keys.KeyManager, error) {
+	if !c.Enabled() || cfg.Type != keyManagerGoogleCloudKMS {
+		return keys.KeyManagerFor(ctx, cfg)
+	}
+
+	if googleCloudKMSFunc == nil {
+		return nil, fmt.Errorf("unknown or uncompiled key manager %q", cfg.Type)
+	}
+
+	opts, err := c.ClientOptions()
+	if err != nil {
+		return nil, err
+	}
+	return googleCloudKMSFunc(ctx, cfg, opts...)
+}
+
+// SecretManagerFor returns the secret manager for the given configuration. If
+// workload identity federation is configured and the secret manager is Google
+// Secret Manager, the client authenticates with the federated credentials.
+// Otherwise it is the same as secrets.SecretManagerFor.
+func SecretManagerFor(ctx context.Context, c *Config, cfg *secrets.Config) (secrets.SecretManager, error) {
+	if !c.Enabled() || cfg.Type != secretManagerGoogle {
+		return secrets.SecretManagerFor(ctx, cfg)
+	}
+
+	if googleSecretManagerFunc == nil {
+		return nil, fmt.Errorf("unknown or uncompiled secret manager %q", cfg.Type)
+	}
+
+	opts, err := c.ClientOptions()
+	if err != nil {
+		return nil, err
+	}
+	return googleSecretManagerFunc(ctx, cfg, opts...)
+}
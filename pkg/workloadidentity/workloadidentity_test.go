@@ -0,0 +1,263 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package workloadidentity
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/google/exposure-notifications-server/pkg/keys"
+	"github.com/google/exposure-notifications-server/pkg/secrets"
+	"github.com/google/exposure-notifications-verification-server/internal/project"
+	"github.com/google/go-cmp/cmp"
+)
+
+const testAudience = "//iam.googleapis.com/projects/123/locations/global/workloadIdentityPools/pool/providers/provider"
+
+func TestConfig_Validate(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name   string
+		config *Config
+		err    string
+	}{
+		{
+			name:   "disabled",
+			config: &Config{},
+		},
+		{
+			name:   "token_without_audience",
+			config: &Config{TokenFile: "/var/run/token"},
+			err:    "GCP_WORKLOAD_IDENTITY_AUDIENCE is required",
+		},
+		{
+			name:   "bad_audience",
+			config: &Config{Audience: "projects/123", TokenFile: "/var/run/token"},
+			err:    "GCP_WORKLOAD_IDENTITY_AUDIENCE must start with",
+		},
+		{
+			name:   "no_token_source",
+			config: &Config{Audience: testAudience},
+			err:    "exactly one of",
+		},
+		{
+			name: "both_token_sources",
+			config: &Config{
+				Audience:  testAudience,
+				TokenFile: "/var/run/token",
+				TokenURL:  "http://169.254.169.254/token",
+			},
+			err: "exactly one of",
+		},
+		{
+			name:   "bad_token_url",
+			config: &Config{Audience: testAudience, TokenURL: "file:///var/run/token"},
+			err:    "GCP_WORKLOAD_IDENTITY_TOKEN_URL must be an http(s) URL",
+		},
+		{
+			name: "headers_without_url",
+			config: &Config{
+				Audience:     testAudience,
+				TokenFile:    "/var/run/token",
+				TokenHeaders: map[string]string{"Metadata": "True"},
+			},
+			err: "GCP_WORKLOAD_IDENTITY_TOKEN_HEADERS requires",
+		},
+		{
+			name: "bad_service_account",
+			config: &Config{
+				Audience:       testAudience,
+				TokenFile:      "/var/run/token",
+				ServiceAccount: "en-verification",
+			},
+			err: "must be a service account email",
+		},
+		{
+			name: "token_file",
+			config: &Config{
+				Audience:       testAudience,
+				TokenFile:      "/var/run/token",
+				ServiceAccount: "en@project.iam.gserviceaccount.com",
+			},
+		},
+		{
+			name: "token_url",
+			config: &Config{
+				Audience:     testAudience,
+				TokenURL:     "http://169.254.169.254/token",
+				TokenHeaders: map[string]string{"Metadata": "True"},
+			},
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			err := tc.config.Validate()
+			if tc.err == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+
+			if err == nil {
+				t.Fatal("expected error")
+			}
+			if got, want := err.Error(), tc.err; !strings.Contains(got, want) {
+				t.Errorf("expected %q to contain %q", got, want)
+			}
+		})
+	}
+}
+
+func TestConfig_CredentialsJSON(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name   string
+		config *Config
+		exp    map[string]interface{}
+	}{
+		{
+			name: "token_file",
+			config: &Config{
+				Audience:  testAudience,
+				TokenFile: "/var/run/token",
+			},
+			exp: map[string]interface{}{
+				"type":               "external_account",
+				"audience":           testAudience,
+				"subject_token_type": "urn:ietf:params:oauth:token-type:jwt",
+				"token_url":          "https://sts.googleapis.com/v1/token",
+				"credential_source": map[string]interface{}{
+					"file": "/var/run/token",
+				},
+			},
+		},
+		{
+			name: "token_url_json_impersonation",
+			config: &Config{
+				Audience:       testAudience,
+				TokenURL:       "http://169.254.169.254/token",
+				TokenHeaders:   map[string]string{"Metadata": "True"},
+				TokenField:     "access_token",
+				ServiceAccount: "en@project.iam.gserviceaccount.com",
+			},
+			exp: map[string]interface{}{
+				"type":               "external_account",
+				"audience":           testAudience,
+				"subject_token_type": "urn:ietf:params:oauth:token-type:jwt",
+				"token_url":          "https://sts.googleapis.com/v1/token",
+				"credential_source": map[string]interface{}{
+					"url":     "http://169.254.169.254/token",
+					"headers": map[string]interface{}{"Metadata": "True"},
+					"format": map[string]interface{}{
+						"type":                     "json",
+						"subject_token_field_name": "access_token",
+					},
+				},
+				"service_account_impersonation_url": "https://iamcredentials.googleapis.com/v1/projects/-/serviceAccounts/en@project.iam.gserviceaccount.com:generateAccessToken",
+			},
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			b, err := tc.config.CredentialsJSON()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var got map[string]interface{}
+			if err := json.Unmarshal(b, &got); err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tc.exp, got); diff != "" {
+				t.Errorf("mismatch (-want, +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestConfig_ClientOptions(t *testing.T) {
+	t.Parallel()
+
+	opts, err := (&Config{}).ClientOptions()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(opts) != 0 {
+		t.Errorf("expected no options when disabled, got %d", len(opts))
+	}
+
+	opts, err = (&Config{Audience: testAudience, TokenFile: "/var/run/token"}).ClientOptions()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(opts), 1; got != want {
+		t.Errorf("expected %d options to be %d", got, want)
+	}
+}
+
+func TestKeyManagerFor(t *testing.T) {
+	t.Parallel()
+
+	ctx := project.TestContext(t)
+	enabled := &Config{Audience: testAudience, TokenFile: "/var/run/token"}
+
+	// Key managers that are not hosted on Google Cloud ignore workload identity
+	// federation.
+	for _, wi := range []*Config{{}, enabled} {
+		km, err := KeyManagerFor(ctx, wi, &keys.Config{
+			Type:           "FILESYSTEM",
+			FilesystemRoot: t.TempDir(),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, ok := km.(*keys.Filesystem); !ok {
+			t.Errorf("expected %T to be *keys.Filesystem", km)
+		}
+	}
+}
+
+func TestSecretManagerFor(t *testing.T) {
+	t.Parallel()
+
+	ctx := project.TestContext(t)
+	enabled := &Config{Audience: testAudience, TokenFile: "/var/run/token"}
+
+	// Secret managers that are not hosted on Google Cloud ignore workload
+	// identity federation.
+	for _, wi := range []*Config{{}, enabled} {
+		sm, err := SecretManagerFor(ctx, wi, &secrets.Config{Type: "IN_MEMORY"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, ok := sm.(*secrets.InMemory); !ok {
+			t.Errorf("expected %T to be *secrets.InMemory", sm)
+		}
+	}
+}
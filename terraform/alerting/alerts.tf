@@ -326,6 +326,38 @@ resource "google_monitoring_alert_policy" "AuthenticatedSMSFailure" {
   ]
 }
 
+resource "google_monitoring_alert_policy" "ElevatedCodeCollisions" {
+  project      = var.project
+  combiner     = "OR"
+  display_name = "ElevatedCodeCollisions"
+  conditions {
+    display_name = "Generated verification codes colliding with existing codes"
+    condition_monitoring_query_language {
+      duration = "0s"
+      query    = <<-EOT
+      fetch
+      generic_task :: ${local.custom_prefix}/api/issue/code_collision_count
+      | align delta(1h)
+      | every 1h
+      | group_by [metric.realm], [val: sum(value.code_collision_count)]
+      | condition val > 50
+      EOT
+      trigger {
+        count = 1
+      }
+    }
+  }
+  documentation {
+    content   = "${local.playbook_prefix}/ElevatedCodeCollisions.md"
+    mime_type = "text/markdown"
+  }
+  notification_channels = [for x in values(google_monitoring_notification_channel.non-paging) : x.id]
+
+  depends_on = [
+    null_resource.manual-step-to-enable-workspace,
+  ]
+}
+
 resource "google_monitoring_alert_policy" "HumanAccessedSecret" {
   count = var.alert_on_human_accessed_secret ? 1 : 0
 
@@ -487,3 +519,43 @@ resource "google_monitoring_alert_policy" "CloudRunBreakglass" {
     null_resource.manual-step-to-enable-workspace,
   ]
 }
+
+resource "google_monitoring_alert_policy" "BreakGlassActivated" {
+  project      = var.project
+  display_name = "BreakGlassActivated"
+  combiner     = "OR"
+
+  conditions {
+    display_name = "A break-glass system admin account was activated"
+
+    condition_monitoring_query_language {
+      duration = "0s"
+
+      query = <<-EOT
+      fetch generic_task
+      | metric '${local.custom_prefix}/admin/break_glass_count'
+      | filter metric.action == 'approved'
+      | align delta(5m)
+      | every 1m
+      | group_by [resource.project_id],
+          [val: aggregate(value.break_glass_count)]
+      | condition val > 0
+      EOT
+
+      trigger {
+        count = 1
+      }
+    }
+  }
+
+  documentation {
+    content   = "${local.playbook_prefix}/BreakGlassActivated.md"
+    mime_type = "text/markdown"
+  }
+
+  notification_channels = [for x in values(google_monitoring_notification_channel.paging) : x.id]
+
+  depends_on = [
+    null_resource.manual-step-to-enable-workspace,
+  ]
+}
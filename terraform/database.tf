@@ -25,6 +25,7 @@ locals {
     google_secret_manager_secret.db-apikey-sig-hmac.id,
     google_secret_manager_secret.db-verification-code-hmac.id,
     google_secret_manager_secret.db-phone-number-hmac.id,
+    google_secret_manager_secret.db-sms-suppression-hmac.id,
   ]
 }
 
@@ -239,6 +240,18 @@ resource "google_secret_manager_secret" "db-phone-number-hmac" {
   ]
 }
 
+resource "google_secret_manager_secret" "db-sms-suppression-hmac" {
+  secret_id = "db-sms-suppression-hmac"
+
+  replication {
+    automatic = true
+  }
+
+  depends_on = [
+    google_project_service.services["secretmanager.googleapis.com"],
+  ]
+}
+
 # Grant Cloud Build the ability to access the database secrets (required to run
 # migrations).
 resource "google_secret_manager_secret_iam_member" "cloudbuild-db-pwd" {
@@ -291,6 +304,16 @@ resource "google_secret_manager_secret_iam_member" "cloudbuild-db-phone-number-h
   ]
 }
 
+resource "google_secret_manager_secret_iam_member" "cloudbuild-db-sms-suppression-hmac" {
+  secret_id = google_secret_manager_secret.db-sms-suppression-hmac.id
+  role      = "roles/secretmanager.secretAccessor"
+  member    = "serviceAccount:${local.cloudbuild_email}"
+
+  depends_on = [
+    google_project_service.services["cloudbuild.googleapis.com"],
+  ]
+}
+
 # Grant Cloud Build the ability to connect to Cloud SQL.
 resource "google_project_iam_member" "cloudbuild-sql" {
   project = var.project
@@ -35,6 +35,14 @@ resource "google_project_iam_member" "cleanup-observability" {
   member   = "serviceAccount:${google_service_account.cleanup.email}"
 }
 
+# Required to disable and delete the Firebase accounts of users who lost their
+# last realm membership, when FIREBASE_USER_DELETION_ENABLED is set.
+resource "google_project_iam_member" "cleanup-firebase-admin" {
+  project = var.project
+  role    = "roles/firebaseauth.admin"
+  member  = "serviceAccount:${google_service_account.cleanup.email}"
+}
+
 resource "google_kms_crypto_key_iam_member" "cleanup-database-encrypter" {
   crypto_key_id = google_kms_crypto_key.database-encrypter.id
   role          = "roles/cloudkms.cryptoKeyEncrypterDecrypter"
@@ -53,6 +61,55 @@ resource "google_kms_crypto_key_iam_member" "cleanup-token-signing-admin" {
   member        = "serviceAccount:${google_service_account.cleanup.email}"
 }
 
+# Bucket and signing key for realm offboarding exports.
+resource "google_storage_bucket" "realm-exports" {
+  project  = var.project
+  name     = "${var.project}-realm-exports"
+  location = var.storage_location
+
+  force_destroy               = var.force_destroy
+  uniform_bucket_level_access = true
+
+  depends_on = [
+    google_project_service.services["storage.googleapis.com"],
+  ]
+}
+
+resource "google_storage_bucket_iam_member" "cleanup-realm-exports" {
+  bucket = google_storage_bucket.realm-exports.name
+  role   = "roles/storage.objectAdmin"
+  member = "serviceAccount:${google_service_account.cleanup.email}"
+}
+
+resource "google_kms_crypto_key" "realm-export-signer" {
+  key_ring = google_kms_key_ring.verification.id
+  name     = "realm-export-signer"
+  purpose  = "ASYMMETRIC_SIGN"
+
+  version_template {
+    algorithm        = "EC_SIGN_P256_SHA256"
+    protection_level = "HSM"
+  }
+}
+
+data "google_kms_crypto_key_version" "realm-export-signer-version" {
+  crypto_key = google_kms_crypto_key.realm-export-signer.id
+}
+
+resource "google_kms_crypto_key_iam_member" "cleanup-realm-export-signer" {
+  crypto_key_id = google_kms_crypto_key.realm-export-signer.id
+  role          = "roles/cloudkms.signerVerifier"
+  member        = "serviceAccount:${google_service_account.cleanup.email}"
+}
+
+locals {
+  realm_export_config = {
+    REALM_EXPORT_BUCKET      = google_storage_bucket.realm-exports.name
+    REALM_EXPORT_KEY_MANAGER = "GOOGLE_CLOUD_KMS"
+    REALM_EXPORT_SIGNING_KEY = trimprefix(data.google_kms_crypto_key_version.realm-export-signer-version.id, "//cloudkms.googleapis.com/v1/")
+  }
+}
+
 locals {
   cleanup_secrets = flatten([
     local.database_secrets,
@@ -106,6 +163,7 @@ resource "google_cloud_run_service" "cleanup" {
             local.gcp_config,
             local.signing_config,
             local.observability_config,
+            local.realm_export_config,
 
             // This MUST come last to allow overrides!
             lookup(var.service_environment, "_all", {}),
@@ -134,9 +192,11 @@ resource "google_cloud_run_service" "cleanup" {
 
     google_kms_crypto_key_iam_member.cleanup-cert-signing-admin,
     google_kms_crypto_key_iam_member.cleanup-database-encrypter,
+    google_kms_crypto_key_iam_member.cleanup-realm-export-signer,
     google_kms_crypto_key_iam_member.cleanup-token-signing-admin,
     google_project_iam_member.cleanup-observability,
     google_secret_manager_secret_iam_member.cleanup-secrets,
+    google_storage_bucket_iam_member.cleanup-realm-exports,
 
     null_resource.build,
     null_resource.migrate,
@@ -211,3 +271,165 @@ resource "google_cloud_scheduler_job" "cleanup-worker" {
     google_project_service.services["cloudscheduler.googleapis.com"],
   ]
 }
+
+resource "google_cloud_scheduler_job" "consistency-worker" {
+  name             = "consistency-worker"
+  region           = var.cloudscheduler_location
+  schedule         = "0 3 * * *"
+  time_zone        = var.cloud_scheduler_timezone
+  attempt_deadline = "${google_cloud_run_service.cleanup.template[0].spec[0].timeout_seconds + 60}s"
+
+  retry_config {
+    retry_count = 3
+  }
+
+  http_target {
+    http_method = "GET"
+    uri         = "${google_cloud_run_service.cleanup.status.0.url}/consistency"
+    oidc_token {
+      audience              = google_cloud_run_service.cleanup.status.0.url
+      service_account_email = google_service_account.cleanup-invoker.email
+    }
+  }
+
+  depends_on = [
+    google_app_engine_application.app,
+    google_cloud_run_service_iam_member.cleanup-invoker,
+    google_project_service.services["cloudscheduler.googleapis.com"],
+  ]
+}
+
+resource "google_cloud_scheduler_job" "realm-kpi-worker" {
+  name             = "realm-kpi-worker"
+  region           = var.cloudscheduler_location
+  schedule         = "*/5 * * * *"
+  time_zone        = var.cloud_scheduler_timezone
+  attempt_deadline = "${google_cloud_run_service.cleanup.template[0].spec[0].timeout_seconds + 60}s"
+
+  retry_config {
+    retry_count = 3
+  }
+
+  http_target {
+    http_method = "GET"
+    uri         = "${google_cloud_run_service.cleanup.status.0.url}/realm-kpi"
+    oidc_token {
+      audience              = google_cloud_run_service.cleanup.status.0.url
+      service_account_email = google_service_account.cleanup-invoker.email
+    }
+  }
+
+  depends_on = [
+    google_app_engine_application.app,
+    google_cloud_run_service_iam_member.cleanup-invoker,
+    google_project_service.services["cloudscheduler.googleapis.com"],
+  ]
+}
+
+resource "google_cloud_scheduler_job" "realm-export-worker" {
+  name             = "realm-export-worker"
+  region           = var.cloudscheduler_location
+  schedule         = "*/15 * * * *"
+  time_zone        = var.cloud_scheduler_timezone
+  attempt_deadline = "${google_cloud_run_service.cleanup.template[0].spec[0].timeout_seconds + 60}s"
+
+  retry_config {
+    retry_count = 3
+  }
+
+  http_target {
+    http_method = "GET"
+    uri         = "${google_cloud_run_service.cleanup.status.0.url}/realm-export"
+    oidc_token {
+      audience              = google_cloud_run_service.cleanup.status.0.url
+      service_account_email = google_service_account.cleanup-invoker.email
+    }
+  }
+
+  depends_on = [
+    google_app_engine_application.app,
+    google_cloud_run_service_iam_member.cleanup-invoker,
+    google_project_service.services["cloudscheduler.googleapis.com"],
+  ]
+}
+
+resource "google_cloud_scheduler_job" "callback-worker" {
+  name             = "callback-worker"
+  region           = var.cloudscheduler_location
+  schedule         = "* * * * *"
+  time_zone        = var.cloud_scheduler_timezone
+  attempt_deadline = "${google_cloud_run_service.cleanup.template[0].spec[0].timeout_seconds + 60}s"
+
+  retry_config {
+    retry_count = 0
+  }
+
+  http_target {
+    http_method = "GET"
+    uri         = "${google_cloud_run_service.cleanup.status.0.url}/callbacks"
+    oidc_token {
+      audience              = google_cloud_run_service.cleanup.status.0.url
+      service_account_email = google_service_account.cleanup-invoker.email
+    }
+  }
+
+  depends_on = [
+    google_app_engine_application.app,
+    google_cloud_run_service_iam_member.cleanup-invoker,
+    google_project_service.services["cloudscheduler.googleapis.com"],
+  ]
+}
+
+resource "google_cloud_scheduler_job" "user-import-worker" {
+  name             = "user-import-worker"
+  region           = var.cloudscheduler_location
+  schedule         = "* * * * *"
+  time_zone        = var.cloud_scheduler_timezone
+  attempt_deadline = "${google_cloud_run_service.cleanup.template[0].spec[0].timeout_seconds + 60}s"
+
+  retry_config {
+    retry_count = 0
+  }
+
+  http_target {
+    http_method = "GET"
+    uri         = "${google_cloud_run_service.cleanup.status.0.url}/user-imports"
+    oidc_token {
+      audience              = google_cloud_run_service.cleanup.status.0.url
+      service_account_email = google_service_account.cleanup-invoker.email
+    }
+  }
+
+  depends_on = [
+    google_app_engine_application.app,
+    google_cloud_run_service_iam_member.cleanup-invoker,
+    google_project_service.services["cloudscheduler.googleapis.com"],
+  ]
+}
+
+resource "google_cloud_scheduler_job" "dual-write-verify-worker" {
+  name             = "dual-write-verify-worker"
+  region           = var.cloudscheduler_location
+  schedule         = "0 */6 * * *"
+  time_zone        = var.cloud_scheduler_timezone
+  attempt_deadline = "${google_cloud_run_service.cleanup.template[0].spec[0].timeout_seconds + 60}s"
+
+  retry_config {
+    retry_count = 0
+  }
+
+  http_target {
+    http_method = "GET"
+    uri         = "${google_cloud_run_service.cleanup.status.0.url}/dual-write-verify"
+    oidc_token {
+      audience              = google_cloud_run_service.cleanup.status.0.url
+      service_account_email = google_service_account.cleanup-invoker.email
+    }
+  }
+
+  depends_on = [
+    google_app_engine_application.app,
+    google_cloud_run_service_iam_member.cleanup-invoker,
+    google_project_service.services["cloudscheduler.googleapis.com"],
+  ]
+}
@@ -94,6 +94,7 @@ resource "google_cloud_run_service" "e2e-runner" {
             local.gcp_config,
             local.signing_config,
             local.e2e_runner_config,
+            local.enx_redirect_config,
             local.observability_config,
 
             // This MUST come last to allow overrides!
@@ -279,3 +280,30 @@ resource "google_cloud_scheduler_job" "e2e-enx-redirect-workflow" {
     google_project_service.services["cloudscheduler.googleapis.com"],
   ]
 }
+
+resource "google_cloud_scheduler_job" "e2e-app-links-workflow" {
+  name             = "e2e-app-links-workflow"
+  region           = var.cloudscheduler_location
+  schedule         = "15 * * * *"
+  time_zone        = var.cloud_scheduler_timezone
+  attempt_deadline = "${google_cloud_run_service.e2e-runner.template[0].spec[0].timeout_seconds + 60}s"
+
+  retry_config {
+    retry_count = 3
+  }
+
+  http_target {
+    http_method = "GET"
+    uri         = "${google_cloud_run_service.e2e-runner.status.0.url}/app-links"
+    oidc_token {
+      audience              = "${google_cloud_run_service.e2e-runner.status.0.url}/app-links"
+      service_account_email = google_service_account.e2e-runner-invoker.email
+    }
+  }
+
+  depends_on = [
+    google_app_engine_application.app,
+    google_cloud_run_service_iam_member.e2e-runner-invoker,
+    google_project_service.services["cloudscheduler.googleapis.com"],
+  ]
+}
@@ -49,6 +49,14 @@ resource "google_secret_manager_secret_iam_member" "emailer-secrets-accessor" {
   member    = "serviceAccount:${google_service_account.emailer.email}"
 }
 
+# The emailer decrypts realm SMS configurations to fan out realm notifications
+# by SMS.
+resource "google_kms_crypto_key_iam_member" "emailer-database-encrypter" {
+  crypto_key_id = google_kms_crypto_key.database-encrypter.id
+  role          = "roles/cloudkms.cryptoKeyEncrypterDecrypter"
+  member        = "serviceAccount:${google_service_account.emailer.email}"
+}
+
 resource "google_cloud_run_service" "emailer" {
   name     = "emailer"
   location = var.region
@@ -118,6 +126,7 @@ resource "google_cloud_run_service" "emailer" {
   depends_on = [
     google_project_service.services["run.googleapis.com"],
 
+    google_kms_crypto_key_iam_member.emailer-database-encrypter,
     google_project_iam_member.emailer-observability,
     google_secret_manager_secret_iam_member.emailer-secrets-accessor,
 
@@ -218,3 +227,123 @@ resource "google_cloud_scheduler_job" "emailer-sms-errors" {
     google_project_service.services["cloudscheduler.googleapis.com"],
   ]
 }
+
+resource "google_cloud_scheduler_job" "emailer-sms-from-number-changes" {
+  count = var.enable_emailer ? 1 : 0
+
+  name   = "emailer-sms-from-number-changes"
+  region = var.cloudscheduler_location
+
+  schedule         = "*/15 * * * *"
+  time_zone        = var.cloud_scheduler_timezone
+  attempt_deadline = "${google_cloud_run_service.emailer.template[0].spec[0].timeout_seconds + 60}s"
+
+  retry_config {
+    retry_count = 1
+  }
+
+  http_target {
+    http_method = "GET"
+    uri         = "${google_cloud_run_service.emailer.status.0.url}/sms-from-number-changes"
+    oidc_token {
+      audience              = google_cloud_run_service.emailer.status.0.url
+      service_account_email = google_service_account.emailer-invoker.email
+    }
+  }
+
+  depends_on = [
+    google_app_engine_application.app,
+    google_cloud_run_service_iam_member.emailer-invoker,
+    google_project_service.services["cloudscheduler.googleapis.com"],
+  ]
+}
+
+resource "google_cloud_scheduler_job" "emailer-inactive-api-keys" {
+  count = var.enable_emailer ? 1 : 0
+
+  name   = "emailer-inactive-api-keys"
+  region = var.cloudscheduler_location
+
+  schedule         = "*/15 * * * *"
+  time_zone        = var.cloud_scheduler_timezone
+  attempt_deadline = "${google_cloud_run_service.emailer.template[0].spec[0].timeout_seconds + 60}s"
+
+  retry_config {
+    retry_count = 1
+  }
+
+  http_target {
+    http_method = "GET"
+    uri         = "${google_cloud_run_service.emailer.status.0.url}/inactive-api-keys"
+    oidc_token {
+      audience              = google_cloud_run_service.emailer.status.0.url
+      service_account_email = google_service_account.emailer-invoker.email
+    }
+  }
+
+  depends_on = [
+    google_app_engine_application.app,
+    google_cloud_run_service_iam_member.emailer-invoker,
+    google_project_service.services["cloudscheduler.googleapis.com"],
+  ]
+}
+
+resource "google_cloud_scheduler_job" "emailer-realm-notifications" {
+  count = var.enable_emailer ? 1 : 0
+
+  name   = "emailer-realm-notifications"
+  region = var.cloudscheduler_location
+
+  schedule         = "*/15 * * * *"
+  time_zone        = var.cloud_scheduler_timezone
+  attempt_deadline = "${google_cloud_run_service.emailer.template[0].spec[0].timeout_seconds + 60}s"
+
+  retry_config {
+    retry_count = 1
+  }
+
+  http_target {
+    http_method = "GET"
+    uri         = "${google_cloud_run_service.emailer.status.0.url}/realm-notifications"
+    oidc_token {
+      audience              = google_cloud_run_service.emailer.status.0.url
+      service_account_email = google_service_account.emailer-invoker.email
+    }
+  }
+
+  depends_on = [
+    google_app_engine_application.app,
+    google_cloud_run_service_iam_member.emailer-invoker,
+    google_project_service.services["cloudscheduler.googleapis.com"],
+  ]
+}
+
+resource "google_cloud_scheduler_job" "emailer-unclaimed-codes" {
+  count = var.enable_emailer ? 1 : 0
+
+  name   = "emailer-unclaimed-codes"
+  region = var.cloudscheduler_location
+
+  schedule         = "0 9 * * *"
+  time_zone        = var.cloud_scheduler_timezone
+  attempt_deadline = "${google_cloud_run_service.emailer.template[0].spec[0].timeout_seconds + 60}s"
+
+  retry_config {
+    retry_count = 1
+  }
+
+  http_target {
+    http_method = "GET"
+    uri         = "${google_cloud_run_service.emailer.status.0.url}/unclaimed-codes"
+    oidc_token {
+      audience              = google_cloud_run_service.emailer.status.0.url
+      service_account_email = google_service_account.emailer-invoker.email
+    }
+  }
+
+  depends_on = [
+    google_app_engine_application.app,
+    google_cloud_run_service_iam_member.emailer-invoker,
+    google_project_service.services["cloudscheduler.googleapis.com"],
+  ]
+}
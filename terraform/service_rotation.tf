@@ -279,3 +279,31 @@ resource "google_cloud_scheduler_job" "rotation-worker-secrets" {
     google_project_service.services["cloudscheduler.googleapis.com"],
   ]
 }
+
+resource "google_cloud_scheduler_job" "rotation-worker-cookie-keys" {
+  name   = "rotation-worker-cookie-keys"
+  region = var.cloudscheduler_location
+
+  schedule         = "*/5 * * * *"
+  time_zone        = var.cloud_scheduler_timezone
+  attempt_deadline = "${google_cloud_run_service.rotation.template[0].spec[0].timeout_seconds + 60}s"
+
+  retry_config {
+    retry_count = 3
+  }
+
+  http_target {
+    http_method = "GET"
+    uri         = "${google_cloud_run_service.rotation.status.0.url}/cookie-keys"
+    oidc_token {
+      audience              = google_cloud_run_service.rotation.status.0.url
+      service_account_email = google_service_account.rotation-invoker.email
+    }
+  }
+
+  depends_on = [
+    google_app_engine_application.app,
+    google_cloud_run_service_iam_member.rotation-invoker,
+    google_project_service.services["cloudscheduler.googleapis.com"],
+  ]
+}
@@ -221,3 +221,35 @@ resource "google_cloud_run_service_iam_member" "server-public" {
 output "server_urls" {
   value = concat([google_cloud_run_service.server.status.0.url], formatlist("https://%s", var.server_hosts))
 }
+
+resource "google_service_account" "server-invoker" {
+  project      = data.google_project.project.project_id
+  account_id   = "en-server-invoker-sa"
+  display_name = "Verification server invoker"
+}
+
+resource "google_cloud_scheduler_job" "bulk-issue-worker" {
+  name             = "bulk-issue-worker"
+  region           = var.cloudscheduler_location
+  schedule         = "* * * * *"
+  time_zone        = var.cloud_scheduler_timezone
+  attempt_deadline = "${google_cloud_run_service.server.template[0].spec[0].timeout_seconds + 60}s"
+
+  retry_config {
+    retry_count = 0
+  }
+
+  http_target {
+    http_method = "GET"
+    uri         = "${google_cloud_run_service.server.status.0.url}/jobs/bulk-issue"
+    oidc_token {
+      audience              = google_cloud_run_service.server.status.0.url
+      service_account_email = google_service_account.server-invoker.email
+    }
+  }
+
+  depends_on = [
+    google_app_engine_application.app,
+    google_project_service.services["cloudscheduler.googleapis.com"],
+  ]
+}
@@ -202,3 +202,32 @@ resource "google_cloud_scheduler_job" "stats-puller-worker" {
     google_project_service.services["cloudscheduler.googleapis.com"],
   ]
 }
+
+# The stats pusher is a no-op unless STATS_PUSH_URL is configured on the
+# service, so it is always scheduled.
+resource "google_cloud_scheduler_job" "stats-pusher-worker" {
+  name             = "stats-pusher-worker"
+  region           = var.cloudscheduler_location
+  schedule         = "30 * * * *"
+  time_zone        = var.cloud_scheduler_timezone
+  attempt_deadline = "${google_cloud_run_service.stats-puller.template[0].spec[0].timeout_seconds + 60}s"
+
+  retry_config {
+    retry_count = 3
+  }
+
+  http_target {
+    http_method = "GET"
+    uri         = "${google_cloud_run_service.stats-puller.status.0.url}/push"
+    oidc_token {
+      audience              = google_cloud_run_service.stats-puller.status.0.url
+      service_account_email = google_service_account.stats-puller-invoker.email
+    }
+  }
+
+  depends_on = [
+    google_app_engine_application.app,
+    google_cloud_run_service_iam_member.stats-puller-invoker,
+    google_project_service.services["cloudscheduler.googleapis.com"],
+  ]
+}
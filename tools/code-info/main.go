@@ -0,0 +1,243 @@
+// Copyright 2022 the Exposure Notifications Verification Server authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Prints a redacted report of everything the database knows about a single
+// verification code, for investigating complaints escalated to the platform
+// team. It connects directly to the database and is not scoped to a realm, so
+// it must only be run by system administrators.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/google/exposure-notifications-verification-server/internal/project"
+	"github.com/google/exposure-notifications-verification-server/pkg/config"
+	"github.com/google/exposure-notifications-verification-server/pkg/database"
+
+	"github.com/google/exposure-notifications-server/pkg/logging"
+
+	"github.com/sethvargo/go-envconfig"
+)
+
+var uuidFlag = flag.String("uuid", "", "UUID of the verification code to report on")
+
+func main() {
+	flag.Parse()
+
+	ctx, done := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+
+	logger := logging.NewLoggerFromEnv().Named("code-info")
+	ctx = logging.WithLogger(ctx, logger)
+
+	err := realMain(ctx)
+	done()
+
+	if err != nil {
+		logger.Fatal(err)
+	}
+}
+
+func realMain(ctx context.Context) error {
+	if *uuidFlag == "" {
+		return fmt.Errorf("--uuid is required")
+	}
+
+	var dbConfig database.Config
+	if err := config.ProcessWith(ctx, &dbConfig, envconfig.OsLookuper()); err != nil {
+		return fmt.Errorf("failed to process config: %w", err)
+	}
+
+	db, err := dbConfig.Load(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to load database config: %w", err)
+	}
+	if err := db.Open(ctx); err != nil {
+		return fmt.Errorf("failed to connect to database: %w", err)
+	}
+	defer db.Close()
+
+	info, err := db.FindCodeInfo(*uuidFlag)
+	if err != nil {
+		if database.IsNotFound(err) {
+			return fmt.Errorf("no verification code with uuid %q", *uuidFlag)
+		}
+		return fmt.Errorf("failed to find code: %w", err)
+	}
+
+	return printReport(os.Stdout, info, time.Now())
+}
+
+// printReport writes the report for the code. Code values, phone numbers, and
+// token IDs are never stored in a form that can be shown, and identifiers that
+// could relate to the patient are masked.
+func printReport(out io.Writer, info *database.CodeInfo, now time.Time) error {
+	w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	vc := info.Code
+
+	fmt.Fprintf(w, "Verification code %s\n\n", vc.UUID)
+
+	fmt.Fprintf(w, "LIFECYCLE\n")
+	fmt.Fprintf(w, "  Status\t%s\n", codeStatus(vc, now))
+	fmt.Fprintf(w, "  Test type\t%s\n", vc.TestType)
+	fmt.Fprintf(w, "  Symptom date\t%s\n", orNone(vc.FormatSymptomDate()))
+	fmt.Fprintf(w, "  Test date\t%s\n", orNone(formatDate(vc.TestDate)))
+	fmt.Fprintf(w, "  Issued\t%s\n", formatTime(vc.CreatedAt))
+	fmt.Fprintf(w, "  Last updated\t%s\n", formatTime(vc.UpdatedAt))
+	fmt.Fprintf(w, "  Short code expires\t%s\n", formatTime(vc.ExpiresAt))
+	fmt.Fprintf(w, "  Long code expires\t%s\n", formatTime(vc.LongExpiresAt))
+	if vc.DeletedAt != nil {
+		fmt.Fprintf(w, "  Deleted\t%s\n", formatTime(*vc.DeletedAt))
+	}
+	fmt.Fprintf(w, "  Codes recycled\t%t\n", vc.Code == "" && vc.LongCode == "")
+	fmt.Fprintf(w, "  HMAC key\t%s\n", orNone(vc.HMACKeyID))
+	fmt.Fprintf(w, "\n")
+
+	fmt.Fprintf(w, "ISSUING CONTEXT\n")
+	if info.Realm != nil {
+		fmt.Fprintf(w, "  Realm\t%s (%d)\n", info.Realm.Name, info.Realm.ID)
+	} else {
+		fmt.Fprintf(w, "  Realm\t%d (deleted)\n", vc.RealmID)
+	}
+	switch {
+	case vc.IssuingUserID != 0:
+		if u := info.IssuingUser; u != nil {
+			fmt.Fprintf(w, "  Issued by user\t%s <%s> (%d)\n", u.Name, maskEmail(u.Email), u.ID)
+		} else {
+			fmt.Fprintf(w, "  Issued by user\t%d (deleted)\n", vc.IssuingUserID)
+		}
+	case vc.IssuingAppID != 0:
+		if a := info.IssuingApp; a != nil {
+			fmt.Fprintf(w, "  Issued by API key\t%s (%d)\n", a.Name, a.ID)
+		} else {
+			fmt.Fprintf(w, "  Issued by API key\t%d (deleted)\n", vc.IssuingAppID)
+		}
+	default:
+		fmt.Fprintf(w, "  Issued by\tunknown\n")
+	}
+	fmt.Fprintf(w, "  External issuer ID\t%s\n", orNone(vc.IssuingExternalID))
+	fmt.Fprintf(w, "  External case ID\t%s\n", orNone(mask(vc.ExternalCaseID)))
+	if vc.UserReportID != nil {
+		if ur := info.UserReport; ur != nil {
+			fmt.Fprintf(w, "  User report\t%d (nonce required: %t, claimed: %t)\n", ur.ID, ur.NonceRequired, ur.CodeClaimed)
+		} else {
+			fmt.Fprintf(w, "  User report\t%d (purged)\n", *vc.UserReportID)
+		}
+	}
+	fmt.Fprintf(w, "\n")
+
+	fmt.Fprintf(w, "SMS\n")
+	fmt.Fprintf(w, "  Resends\t%d\n", vc.ResendCount)
+	fmt.Fprintf(w, "  Captured API requests\t%d\n", len(info.Captures))
+	for _, c := range info.Captures {
+		fmt.Fprintf(w, "    %s\t%s %s -> %d (request %s, key %d, %dms)\n",
+			formatTime(c.CreatedAt), c.Method, c.Path, c.ResponseStatus, orNone(c.RequestID), c.AuthorizedAppID, c.DurationMs)
+	}
+	fmt.Fprintf(w, "\n")
+
+	fmt.Fprintf(w, "TOKEN\n")
+	switch {
+	case !vc.Claimed:
+		fmt.Fprintf(w, "  Not claimed\n")
+	case len(info.CandidateTokens) == 0:
+		fmt.Fprintf(w, "  No candidate tokens (purged or not found)\n")
+	default:
+		for _, t := range info.CandidateTokens {
+			fmt.Fprintf(w, "  Candidate %d\tcreated %s, expires %s, used: %t\n",
+				t.ID, formatTime(t.CreatedAt), formatTime(t.ExpiresAt), t.Used)
+		}
+	}
+	fmt.Fprintf(w, "\n")
+
+	fmt.Fprintf(w, "TRANSFERS\n")
+	if len(info.Transfers) == 0 {
+		fmt.Fprintf(w, "  None\n")
+	}
+	for _, t := range info.Transfers {
+		fmt.Fprintf(w, "  %s\trealm %d -> %d by %s (consent: %t)\n",
+			formatTime(t.CreatedAt), t.FromRealmID, t.ToRealmID, t.ActorDisplay, t.PatientConsent)
+	}
+	fmt.Fprintf(w, "\n")
+
+	fmt.Fprintf(w, "AUDIT ENTRIES\n")
+	if len(info.Audits) == 0 {
+		fmt.Fprintf(w, "  None\n")
+	}
+	for _, a := range info.Audits {
+		fmt.Fprintf(w, "  %s\t%s by %s (realm %d)\n", formatTime(a.CreatedAt), a.Action, a.ActorDisplay, a.RealmID)
+	}
+
+	return w.Flush()
+}
+
+// codeStatus summarizes the state of the code at the given time.
+func codeStatus(vc *database.VerificationCode, now time.Time) string {
+	switch {
+	case vc.Claimed:
+		return "claimed"
+	case now.After(vc.ExpiresAt) && now.After(vc.LongExpiresAt):
+		return "expired"
+	case now.After(vc.ExpiresAt):
+		return "short code expired, long code valid"
+	default:
+		return "valid"
+	}
+}
+
+// mask hides all but the last 4 characters of s.
+func mask(s string) string {
+	if len(s) <= 4 {
+		return strings.Repeat("*", len(s))
+	}
+	return strings.Repeat("*", len(s)-4) + s[len(s)-4:]
+}
+
+// maskEmail hides the local part of the email address, except for its first
+// character.
+func maskEmail(email string) string {
+	i := strings.LastIndex(email, "@")
+	if i < 1 {
+		return mask(email)
+	}
+	return email[:1] + "***" + email[i:]
+}
+
+func formatDate(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.UTC().Format(project.RFC3339Date)
+}
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+func orNone(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
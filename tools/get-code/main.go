@@ -23,8 +23,8 @@ import (
 	"syscall"
 	"time"
 
-	"github.com/google/exposure-notifications-verification-server/internal/clients"
 	"github.com/google/exposure-notifications-verification-server/pkg/api"
+	"github.com/google/exposure-notifications-verification-server/pkg/apiclient"
 
 	"github.com/google/exposure-notifications-server/pkg/logging"
 )
@@ -62,8 +62,8 @@ func main() {
 func realMain(ctx context.Context) error {
 	logger := logging.FromContext(ctx)
 
-	client, err := clients.NewAdminAPIServerClient(*addrFlag, *apikeyFlag,
-		clients.WithTimeout(*timeoutFlag))
+	client, err := apiclient.NewAdminAPIServerClient(*addrFlag, *apikeyFlag,
+		apiclient.WithTimeout(*timeoutFlag))
 	if err != nil {
 		return err
 	}
@@ -23,8 +23,8 @@ import (
 	"syscall"
 	"time"
 
-	"github.com/google/exposure-notifications-verification-server/internal/clients"
 	"github.com/google/exposure-notifications-verification-server/pkg/api"
+	"github.com/google/exposure-notifications-verification-server/pkg/apiclient"
 
 	"github.com/google/exposure-notifications-server/pkg/logging"
 )
@@ -60,13 +60,13 @@ func main() {
 func realMain(ctx context.Context) error {
 	logger := logging.FromContext(ctx)
 
-	opts := make([]clients.Option, 0, 2)
-	opts = append(opts, clients.WithTimeout(*timeoutFlag))
+	opts := make([]apiclient.Option, 0, 2)
+	opts = append(opts, apiclient.WithTimeout(*timeoutFlag))
 	if ua := *userAgent; ua != "" {
-		opts = append(opts, clients.WithUserAgent(ua))
+		opts = append(opts, apiclient.WithUserAgent(ua))
 	}
 
-	client, err := clients.NewAPIServerClient(*addrFlag, *apikeyFlag, opts...)
+	client, err := apiclient.NewAPIServerClient(*addrFlag, *apikeyFlag, opts...)
 	if err != nil {
 		return err
 	}
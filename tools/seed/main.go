@@ -724,6 +724,10 @@ func createSecrets(ctx context.Context, db *database.Database, keyManager keys.S
 		return fmt.Errorf("failed to create initial secrets: %w", err)
 	}
 
+	if err := rotationController.RotateCookieKeys(ctx); err != nil {
+		return fmt.Errorf("failed to create initial cookie keys: %w", err)
+	}
+
 	if err := rotationController.RotateTokenSigningKey(ctx); err != nil {
 		return fmt.Errorf("failed to create initial token signing key: %w", err)
 	}
@@ -25,8 +25,8 @@ import (
 	"syscall"
 	"time"
 
-	"github.com/google/exposure-notifications-verification-server/internal/clients"
 	"github.com/google/exposure-notifications-verification-server/pkg/api"
+	"github.com/google/exposure-notifications-verification-server/pkg/apiclient"
 
 	"github.com/google/exposure-notifications-server/pkg/logging"
 )
@@ -75,8 +75,8 @@ func realMain(ctx context.Context) error {
 		return nil
 	}
 
-	client, err := clients.NewAPIServerClient(*addrFlag, *apikeyFlag,
-		clients.WithTimeout(*timeoutFlag))
+	client, err := apiclient.NewAPIServerClient(*addrFlag, *apikeyFlag,
+		apiclient.WithTimeout(*timeoutFlag))
 	if err != nil {
 		return err
 	}